@@ -16,6 +16,7 @@ import (
 	"github.com/influxdata/influxdb/cmd/influxd/help"
 	"github.com/influxdata/influxdb/cmd/influxd/restore"
 	"github.com/influxdata/influxdb/cmd/influxd/run"
+	"go.uber.org/zap"
 )
 
 // These variables are populated via the Go linker.
@@ -86,9 +87,35 @@ func (m *Main) Run(args ...string) error {
 		signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
 		cmd.Logger.Info("Listening for signals")
 
+		// Reload the configuration whenever SIGHUP is received, until shutdown
+		// begins.
+		reloadCh := make(chan os.Signal, 1)
+		reloadDone := make(chan struct{})
+		reloadStopped := make(chan struct{})
+		signal.Notify(reloadCh, syscall.SIGHUP)
+		go func() {
+			defer close(reloadStopped)
+			for {
+				select {
+				case <-reloadCh:
+					cmd.Logger.Info("SIGHUP received, reloading configuration")
+					if err := cmd.Reload(); err != nil {
+						cmd.Logger.Error("Failed to reload configuration", zap.Error(err))
+					}
+				case <-reloadDone:
+					return
+				}
+			}
+		}()
+
 		// Block until one of the signals above is received
 		<-signalCh
+		signal.Stop(reloadCh)
+		close(reloadDone)
 		cmd.Logger.Info("Signal received, initializing clean shutdown...")
+
+		// Let a reload in progress complete before closing the services.
+		<-reloadStopped
 		go cmd.Close()
 
 		// Block again until another signal is received, a shutdown timeout elapses,
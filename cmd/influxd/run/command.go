@@ -36,9 +36,10 @@ type Command struct {
 	Commit    string
 	BuildTime string
 
-	closing chan struct{}
-	pidfile string
-	Closed  chan struct{}
+	closing    chan struct{}
+	pidfile    string
+	configPath string
+	Closed     chan struct{}
 
 	Stdin  io.Reader
 	Stdout io.Writer
@@ -71,7 +72,8 @@ func (cmd *Command) Run(args ...string) error {
 		return err
 	}
 
-	config, err := cmd.ParseConfig(options.GetConfigPath())
+	cmd.configPath = options.GetConfigPath()
+	config, err := cmd.ParseConfig(cmd.configPath)
 	if err != nil {
 		return fmt.Errorf("parse config: %s", err)
 	}
@@ -159,6 +161,28 @@ func (cmd *Command) Close() error {
 	return nil
 }
 
+// Reload re-reads the configuration file and applies the settings that can be
+// changed while the server is running.
+func (cmd *Command) Reload() error {
+	config, err := cmd.ParseConfig(cmd.configPath)
+	if err != nil {
+		return fmt.Errorf("parse config: %s", err)
+	}
+
+	if err := config.ApplyEnvOverrides(cmd.Getenv); err != nil {
+		return fmt.Errorf("apply env config: %v", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if cmd.Server == nil {
+		return nil
+	}
+	return cmd.Server.Reload(config)
+}
+
 func (cmd *Command) monitorServerErrors() {
 	logger := log.New(cmd.Stderr, "", log.LstdFlags)
 	for {
@@ -18,17 +18,27 @@ import (
 	"github.com/influxdata/influxdb/logger"
 	"github.com/influxdata/influxdb/monitor"
 	"github.com/influxdata/influxdb/monitor/diagnostics"
+	"github.com/influxdata/influxdb/services/amqp"
 	"github.com/influxdata/influxdb/services/collectd"
 	"github.com/influxdata/influxdb/services/continuous_querier"
 	"github.com/influxdata/influxdb/services/graphite"
+	"github.com/influxdata/influxdb/services/grpc"
 	"github.com/influxdata/influxdb/services/httpd"
+	"github.com/influxdata/influxdb/services/kafka"
 	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/services/mqtt"
+	"github.com/influxdata/influxdb/services/nats"
 	"github.com/influxdata/influxdb/services/opentsdb"
+	"github.com/influxdata/influxdb/services/otel"
 	"github.com/influxdata/influxdb/services/precreator"
 	"github.com/influxdata/influxdb/services/retention"
+	"github.com/influxdata/influxdb/services/snmp"
+	"github.com/influxdata/influxdb/services/statsd"
 	"github.com/influxdata/influxdb/services/storage"
 	"github.com/influxdata/influxdb/services/subscriber"
+	"github.com/influxdata/influxdb/services/syslog"
 	"github.com/influxdata/influxdb/services/udp"
+	"github.com/influxdata/influxdb/services/webhook"
 	"github.com/influxdata/influxdb/tsdb"
 	"golang.org/x/text/encoding/unicode"
 	"golang.org/x/text/transform"
@@ -52,10 +62,20 @@ type Config struct {
 	HTTPD          httpd.Config      `toml:"http"`
 	Logging        logger.Config     `toml:"logging"`
 	Storage        storage.Config    `toml:"ifql"`
+	GRPC           grpc.Config       `toml:"grpc"`
+	Tracing        otel.Config       `toml:"tracing"`
 	GraphiteInputs []graphite.Config `toml:"graphite"`
 	CollectdInputs []collectd.Config `toml:"collectd"`
 	OpenTSDBInputs []opentsdb.Config `toml:"opentsdb"`
 	UDPInputs      []udp.Config      `toml:"udp"`
+	StatsdInputs   []statsd.Config   `toml:"statsd"`
+	MQTTInputs     []mqtt.Config     `toml:"mqtt"`
+	KafkaInputs    []kafka.Config    `toml:"kafka"`
+	NATSInputs     []nats.Config     `toml:"nats"`
+	AMQPInputs     []amqp.Config     `toml:"amqp"`
+	SyslogInputs   []syslog.Config   `toml:"syslog"`
+	WebhookInputs  []webhook.Config  `toml:"webhook"`
+	SNMPInputs     []snmp.Config     `toml:"snmp"`
 
 	ContinuousQuery continuous_querier.Config `toml:"continuous_queries"`
 
@@ -79,11 +99,21 @@ func NewConfig() *Config {
 	c.HTTPD = httpd.NewConfig()
 	c.Logging = logger.NewConfig()
 	c.Storage = storage.NewConfig()
+	c.GRPC = grpc.NewConfig()
+	c.Tracing = otel.NewConfig()
 
 	c.GraphiteInputs = []graphite.Config{graphite.NewConfig()}
 	c.CollectdInputs = []collectd.Config{collectd.NewConfig()}
 	c.OpenTSDBInputs = []opentsdb.Config{opentsdb.NewConfig()}
 	c.UDPInputs = []udp.Config{udp.NewConfig()}
+	c.StatsdInputs = []statsd.Config{statsd.NewConfig()}
+	c.MQTTInputs = []mqtt.Config{mqtt.NewConfig()}
+	c.KafkaInputs = []kafka.Config{kafka.NewConfig()}
+	c.NATSInputs = []nats.Config{nats.NewConfig()}
+	c.AMQPInputs = []amqp.Config{amqp.NewConfig()}
+	c.SyslogInputs = []syslog.Config{syslog.NewConfig()}
+	c.WebhookInputs = []webhook.Config{webhook.NewConfig()}
+	c.SNMPInputs = []snmp.Config{snmp.NewConfig()}
 
 	c.ContinuousQuery = continuous_querier.NewConfig()
 	c.Retention = retention.NewConfig()
@@ -178,6 +208,18 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	if err := c.HTTPD.Validate(); err != nil {
+		return fmt.Errorf("invalid http config: %v", err)
+	}
+
+	if err := c.GRPC.Validate(); err != nil {
+		return fmt.Errorf("invalid grpc config: %v", err)
+	}
+
+	if err := c.Tracing.Validate(); err != nil {
+		return fmt.Errorf("invalid tracing config: %v", err)
+	}
+
 	for _, graphite := range c.GraphiteInputs {
 		if err := graphite.Validate(); err != nil {
 			return fmt.Errorf("invalid graphite config: %v", err)
@@ -190,6 +232,66 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for _, opentsdb := range c.OpenTSDBInputs {
+		if err := opentsdb.Validate(); err != nil {
+			return fmt.Errorf("invalid opentsdb config: %v", err)
+		}
+	}
+
+	for _, udp := range c.UDPInputs {
+		if err := udp.Validate(); err != nil {
+			return fmt.Errorf("invalid udp config: %v", err)
+		}
+	}
+
+	for _, statsd := range c.StatsdInputs {
+		if err := statsd.Validate(); err != nil {
+			return fmt.Errorf("invalid statsd config: %v", err)
+		}
+	}
+
+	for _, mqtt := range c.MQTTInputs {
+		if err := mqtt.Validate(); err != nil {
+			return fmt.Errorf("invalid mqtt config: %v", err)
+		}
+	}
+
+	for _, kafka := range c.KafkaInputs {
+		if err := kafka.Validate(); err != nil {
+			return fmt.Errorf("invalid kafka config: %v", err)
+		}
+	}
+
+	for _, nats := range c.NATSInputs {
+		if err := nats.Validate(); err != nil {
+			return fmt.Errorf("invalid nats config: %v", err)
+		}
+	}
+
+	for _, amqp := range c.AMQPInputs {
+		if err := amqp.Validate(); err != nil {
+			return fmt.Errorf("invalid amqp config: %v", err)
+		}
+	}
+
+	for _, syslog := range c.SyslogInputs {
+		if err := syslog.Validate(); err != nil {
+			return fmt.Errorf("invalid syslog config: %v", err)
+		}
+	}
+
+	for _, webhook := range c.WebhookInputs {
+		if err := webhook.Validate(); err != nil {
+			return fmt.Errorf("invalid webhook config: %v", err)
+		}
+	}
+
+	for _, snmp := range c.SNMPInputs {
+		if err := snmp.Validate(); err != nil {
+			return fmt.Errorf("invalid snmp config: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -339,6 +441,8 @@ func (c *Config) diagnosticsClients() map[string]diagnostics.Client {
 		"config-monitor":    c.Monitor,
 		"config-subscriber": c.Subscriber,
 		"config-httpd":      c.HTTPD,
+		"config-grpc":       c.GRPC,
+		"config-tracing":    c.Tracing,
 
 		"config-cqs": c.ContinuousQuery,
 	}
@@ -356,6 +460,30 @@ func (c *Config) diagnosticsClients() map[string]diagnostics.Client {
 	if u := udp.Configs(c.UDPInputs); u.Enabled() {
 		m["config-udp"] = u
 	}
+	if sd := statsd.Configs(c.StatsdInputs); sd.Enabled() {
+		m["config-statsd"] = sd
+	}
+	if mq := mqtt.Configs(c.MQTTInputs); mq.Enabled() {
+		m["config-mqtt"] = mq
+	}
+	if k := kafka.Configs(c.KafkaInputs); k.Enabled() {
+		m["config-kafka"] = k
+	}
+	if n := nats.Configs(c.NATSInputs); n.Enabled() {
+		m["config-nats"] = n
+	}
+	if a := amqp.Configs(c.AMQPInputs); a.Enabled() {
+		m["config-amqp"] = a
+	}
+	if sl := syslog.Configs(c.SyslogInputs); sl.Enabled() {
+		m["config-syslog"] = sl
+	}
+	if wh := webhook.Configs(c.WebhookInputs); wh.Enabled() {
+		m["config-webhook"] = wh
+	}
+	if sn := snmp.Configs(c.SNMPInputs); sn.Enabled() {
+		m["config-snmp"] = sn
+	}
 
 	return m
 }
@@ -0,0 +1,172 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/influxdb/services/httpd"
+	"github.com/influxdata/influxdb/tsdb"
+)
+
+// healthState holds the error counters seen by the previous health check, so
+// that a subsystem is reported degraded when its errors increase.
+type healthState struct {
+	mu            sync.Mutex
+	walErrs       int64
+	compactionErr int64
+}
+
+// Health returns the health of the subsystems of the server: the meta store,
+// each open shard, the WAL, the compactions, the subscriber queues and the
+// services.
+func (s *Server) Health() []httpd.HealthCheck {
+	checks := []httpd.HealthCheck{s.metaHealth()}
+	checks = append(checks, s.storeHealth()...)
+	if s.Subscriber != nil {
+		checks = append(checks, s.subscriberHealth())
+	}
+	for _, svc := range s.Services {
+		name := strings.TrimPrefix(fmt.Sprintf("%T", svc), "*")
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			name = name[:i]
+		}
+		checks = append(checks, httpd.HealthCheck{
+			Name:    "service",
+			Status:  httpd.HealthPass,
+			Details: map[string]interface{}{"service": name},
+		})
+	}
+	return checks
+}
+
+// metaHealth checks that the directory of the meta store is available.
+func (s *Server) metaHealth() httpd.HealthCheck {
+	c := httpd.HealthCheck{Name: "meta", Status: httpd.HealthPass}
+	if _, err := os.Stat(s.config.Meta.Dir); err != nil {
+		c.Status, c.Message = httpd.HealthFail, err.Error()
+		return c
+	}
+	c.Details = map[string]interface{}{
+		"databases": len(s.MetaClient.Databases()),
+		"index":     s.MetaClient.Data().Index,
+	}
+	return c
+}
+
+// storeHealth checks each open shard, and reports the WAL and compactions of
+// all of them.
+func (s *Server) storeHealth() []httpd.HealthCheck {
+	var checks []httpd.HealthCheck
+	var walOld, walCurrent, walErrs int64
+	var queued, active, compactionErrs int64
+	for _, sh := range s.TSDBStore.Shards(s.TSDBStore.ShardIDs()) {
+		c := httpd.HealthCheck{
+			Name:   "shard",
+			Status: httpd.HealthPass,
+			Details: map[string]interface{}{
+				"id":              sh.ID(),
+				"database":        sh.Database(),
+				"retentionPolicy": sh.RetentionPolicy(),
+			},
+		}
+		if _, err := sh.Index(); err == tsdb.ErrShardDisabled {
+			c.Status, c.Message = httpd.HealthWarn, err.Error()
+		} else if err != nil {
+			c.Status, c.Message = httpd.HealthFail, err.Error()
+		}
+		if size, err := sh.DiskSize(); err == nil {
+			c.Details["diskBytes"] = size
+		}
+		checks = append(checks, c)
+
+		for _, stat := range sh.Statistics(nil) {
+			switch stat.Name {
+			case "tsm1_wal":
+				walOld += statInt(stat.Values["oldSegmentsDiskBytes"])
+				walCurrent += statInt(stat.Values["currentSegmentDiskBytes"])
+				walErrs += statInt(stat.Values["writeErr"])
+			case "tsm1_engine":
+				for k, v := range stat.Values {
+					switch {
+					case strings.HasSuffix(k, "CompactionQueue"):
+						queued += statInt(v)
+					case strings.HasSuffix(k, "CompactionsActive"):
+						active += statInt(v)
+					case strings.HasSuffix(k, "CompactionErr"):
+						compactionErrs += statInt(v)
+					}
+				}
+			}
+		}
+	}
+
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+
+	wal := httpd.HealthCheck{
+		Name:   "wal",
+		Status: httpd.HealthPass,
+		Details: map[string]interface{}{
+			"oldSegmentsDiskBytes":    walOld,
+			"currentSegmentDiskBytes": walCurrent,
+			"writeErr":                walErrs,
+		},
+	}
+	if walErrs > s.health.walErrs {
+		wal.Status = httpd.HealthWarn
+		wal.Message = fmt.Sprintf("%d WAL write errors since the last check", walErrs-s.health.walErrs)
+	}
+	s.health.walErrs = walErrs
+
+	compactions := httpd.HealthCheck{
+		Name:   "compactions",
+		Status: httpd.HealthPass,
+		Details: map[string]interface{}{
+			"queued": queued,
+			"active": active,
+			"errors": compactionErrs,
+		},
+	}
+	if compactionErrs > s.health.compactionErr {
+		compactions.Status = httpd.HealthWarn
+		compactions.Message = fmt.Sprintf("%d compaction errors since the last check", compactionErrs-s.health.compactionErr)
+	}
+	s.health.compactionErr = compactionErrs
+
+	return append(checks, wal, compactions)
+}
+
+// subscriberHealth reports the queues of the subscriptions. Points written
+// to full queues are dropped.
+func (s *Server) subscriberHealth() httpd.HealthCheck {
+	queues := s.Subscriber.Queues()
+	c := httpd.HealthCheck{
+		Name:    "subscriber",
+		Status:  httpd.HealthPass,
+		Details: map[string]interface{}{"queues": queues},
+	}
+	var full []string
+	for _, q := range queues {
+		if q.Cap > 0 && q.Len >= q.Cap {
+			full = append(full, fmt.Sprintf("%s.%s.%s", q.Database, q.RetentionPolicy, q.Name))
+		}
+	}
+	if len(full) > 0 {
+		c.Status = httpd.HealthWarn
+		c.Message = "full subscription queues: " + strings.Join(full, ", ")
+	}
+	return c
+}
+
+// statInt returns the value of an integer statistic.
+func statInt(v interface{}) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	return 0
+}
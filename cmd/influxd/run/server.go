@@ -17,17 +17,27 @@ import (
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/monitor"
 	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxdb/services/amqp"
 	"github.com/influxdata/influxdb/services/collectd"
 	"github.com/influxdata/influxdb/services/continuous_querier"
 	"github.com/influxdata/influxdb/services/graphite"
+	"github.com/influxdata/influxdb/services/grpc"
 	"github.com/influxdata/influxdb/services/httpd"
+	"github.com/influxdata/influxdb/services/kafka"
 	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/services/mqtt"
+	"github.com/influxdata/influxdb/services/nats"
 	"github.com/influxdata/influxdb/services/opentsdb"
+	"github.com/influxdata/influxdb/services/otel"
 	"github.com/influxdata/influxdb/services/precreator"
 	"github.com/influxdata/influxdb/services/retention"
 	"github.com/influxdata/influxdb/services/snapshotter"
+	"github.com/influxdata/influxdb/services/snmp"
+	"github.com/influxdata/influxdb/services/statsd"
 	"github.com/influxdata/influxdb/services/subscriber"
+	"github.com/influxdata/influxdb/services/syslog"
 	"github.com/influxdata/influxdb/services/udp"
+	"github.com/influxdata/influxdb/services/webhook"
 	"github.com/influxdata/influxdb/tcp"
 	"github.com/influxdata/influxdb/tsdb"
 	client "github.com/influxdata/usage-client/v1"
@@ -73,6 +83,9 @@ type Server struct {
 	QueryExecutor *query.QueryExecutor
 	PointsWriter  *coordinator.PointsWriter
 	Subscriber    *subscriber.Service
+	SlowQueryLog  *coordinator.SlowQueryLog
+	ResultCache   *coordinator.ResultCache
+	Tracer        *otel.Service
 
 	Services []Service
 
@@ -97,6 +110,9 @@ type Server struct {
 	// tcpAddr is the host:port combination for the TCP listener that services mux onto
 	tcpAddr string
 
+	// health holds the state of the previous health check.
+	health healthState
+
 	config *Config
 }
 
@@ -177,6 +193,27 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 	s.PointsWriter.WriteTimeout = time.Duration(c.Coordinator.WriteTimeout)
 	s.PointsWriter.TSDBStore = s.TSDBStore
 
+	// Initialize the slow query log, if enabled.
+	slowQueryLog, err := coordinator.NewSlowQueryLog(c.Coordinator)
+	if err != nil {
+		return nil, fmt.Errorf("open slow query log: %s", err)
+	}
+	if slowQueryLog != nil && c.Coordinator.SlowQueryLogInternal {
+		slowQueryLog.Monitor = s.Monitor
+	}
+	s.SlowQueryLog = slowQueryLog
+
+	// Initialize the query result cache, if enabled.
+	if s.ResultCache = coordinator.NewResultCache(c.Coordinator); s.ResultCache != nil {
+		s.PointsWriter.ResultCache = s.ResultCache
+	}
+
+	// Initialize the tracer of HTTP requests, if enabled.
+	if c.Tracing.Enabled {
+		s.Tracer = otel.NewService(c.Tracing)
+		s.Tracer.Version = s.buildInfo.Version
+	}
+
 	// Initialize query executor.
 	s.QueryExecutor = query.NewQueryExecutor()
 	s.QueryExecutor.StatementExecutor = &coordinator.StatementExecutor{
@@ -192,10 +229,17 @@ func NewServer(c *Config, buildInfo *BuildInfo) (*Server, error) {
 		MaxSelectPointN:   c.Coordinator.MaxSelectPointN,
 		MaxSelectSeriesN:  c.Coordinator.MaxSelectSeriesN,
 		MaxSelectBucketsN: c.Coordinator.MaxSelectBucketsN,
+		FillLookback:      time.Duration(c.Coordinator.FillLookback),
+		IntoSliceDuration: time.Duration(c.Coordinator.IntoSliceDuration),
+		IntoWriteRate:     c.Coordinator.IntoWriteRate,
+		ResultCache:       s.ResultCache,
+		SlowQueryLog:      s.SlowQueryLog,
 	}
 	s.QueryExecutor.TaskManager.QueryTimeout = time.Duration(c.Coordinator.QueryTimeout)
 	s.QueryExecutor.TaskManager.LogQueriesAfter = time.Duration(c.Coordinator.LogQueriesAfter)
 	s.QueryExecutor.TaskManager.MaxConcurrentQueries = c.Coordinator.MaxConcurrentQueries
+	s.QueryExecutor.TaskManager.Scheduler = c.Coordinator.NewQueryScheduler()
+	s.QueryExecutor.TaskManager.Monitor = s.Monitor
 
 	// Initialize the monitor
 	s.Monitor.Version = s.buildInfo.Version
@@ -213,6 +257,9 @@ func (s *Server) Statistics(tags map[string]string) []models.Statistic {
 	statistics = append(statistics, s.TSDBStore.Statistics(tags)...)
 	statistics = append(statistics, s.PointsWriter.Statistics(tags)...)
 	statistics = append(statistics, s.Subscriber.Statistics(tags)...)
+	if s.ResultCache != nil {
+		statistics = append(statistics, s.ResultCache.Statistics(tags)...)
+	}
 	for _, srv := range s.Services {
 		if m, ok := srv.(monitor.Reporter); ok {
 			statistics = append(statistics, m.Statistics(tags)...)
@@ -260,8 +307,18 @@ func (s *Server) appendHTTPDService(c httpd.Config) {
 	srv.Handler.QueryExecutor = s.QueryExecutor
 	srv.Handler.Monitor = s.Monitor
 	srv.Handler.PointsWriter = s.PointsWriter
+	srv.Handler.TSDBStore = s.TSDBStore
+	if s.Tracer != nil {
+		srv.Handler.Tracer = s.Tracer
+	}
 	srv.Handler.Version = s.buildInfo.Version
 	srv.Handler.BuildType = "OSS"
+	srv.Handler.Health = s
+	for _, svc := range s.Services {
+		if cq, ok := svc.(*continuous_querier.Service); ok {
+			srv.Handler.ContinuousQuerier = cq
+		}
+	}
 
 	s.Services = append(s.Services, srv)
 }
@@ -277,6 +334,18 @@ func (s *Server) appendStorageService(c storage.Config) {
 	s.Services = append(s.Services, srv)
 }
 
+func (s *Server) appendGRPCService(c grpc.Config) {
+	if !c.Enabled {
+		return
+	}
+	srv := grpc.NewService(c)
+	srv.MetaClient = s.MetaClient
+	srv.WriteAuthorizer = meta.NewWriteAuthorizer(s.MetaClient)
+	srv.PointsWriter = s.PointsWriter
+
+	s.Services = append(s.Services, srv)
+}
+
 func (s *Server) appendCollectdService(c collectd.Config) {
 	if !c.Enabled {
 		return
@@ -297,6 +366,7 @@ func (s *Server) appendOpenTSDBService(c opentsdb.Config) error {
 	}
 	srv.PointsWriter = s.PointsWriter
 	srv.MetaClient = s.MetaClient
+	srv.QueryExecutor = s.QueryExecutor
 	s.Services = append(s.Services, srv)
 	return nil
 }
@@ -337,6 +407,86 @@ func (s *Server) appendUDPService(c udp.Config) {
 	s.Services = append(s.Services, srv)
 }
 
+func (s *Server) appendStatsdService(c statsd.Config) {
+	if !c.Enabled {
+		return
+	}
+	srv := statsd.NewService(c)
+	srv.PointsWriter = s.PointsWriter
+	srv.MetaClient = s.MetaClient
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendMQTTService(c mqtt.Config) {
+	if !c.Enabled {
+		return
+	}
+	srv := mqtt.NewService(c)
+	srv.PointsWriter = s.PointsWriter
+	srv.MetaClient = s.MetaClient
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendKafkaService(c kafka.Config) {
+	if !c.Enabled {
+		return
+	}
+	srv := kafka.NewService(c)
+	srv.PointsWriter = s.PointsWriter
+	srv.MetaClient = s.MetaClient
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendNATSService(c nats.Config) {
+	if !c.Enabled {
+		return
+	}
+	srv := nats.NewService(c)
+	srv.PointsWriter = s.PointsWriter
+	srv.MetaClient = s.MetaClient
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendAMQPService(c amqp.Config) {
+	if !c.Enabled {
+		return
+	}
+	srv := amqp.NewService(c)
+	srv.PointsWriter = s.PointsWriter
+	srv.MetaClient = s.MetaClient
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendSyslogService(c syslog.Config) {
+	if !c.Enabled {
+		return
+	}
+	srv := syslog.NewService(c)
+	srv.PointsWriter = s.PointsWriter
+	srv.MetaClient = s.MetaClient
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendWebhookService(c webhook.Config) {
+	if !c.Enabled {
+		return
+	}
+	srv := webhook.NewService(c)
+	srv.PointsWriter = s.PointsWriter
+	srv.MetaClient = s.MetaClient
+	s.Services = append(s.Services, srv)
+}
+
+func (s *Server) appendSNMPService(c snmp.Config) {
+	if !c.Enabled {
+		return
+	}
+	srv := snmp.NewService(c)
+	srv.PointsWriter = s.PointsWriter
+	srv.MetaClient = s.MetaClient
+	s.Services = append(s.Services, srv)
+}
+
 func (s *Server) appendContinuousQueryService(c continuous_querier.Config) {
 	if !c.Enabled {
 		return
@@ -348,6 +498,47 @@ func (s *Server) appendContinuousQueryService(c continuous_querier.Config) {
 	s.Services = append(s.Services, srv)
 }
 
+// Reload applies the settings in c that can be changed while the server is
+// running. Services that cannot be reconfigured at runtime are left untouched.
+func (s *Server) Reload(c *Config) error {
+	for _, service := range s.Services {
+		switch srv := service.(type) {
+		case *udp.Service:
+			for _, uc := range c.UDPInputs {
+				if !uc.Enabled || uc.BindAddress != srv.BindAddress() {
+					continue
+				}
+				if err := srv.Reload(uc); err != nil {
+					return fmt.Errorf("reload udp service %s: %s", uc.BindAddress, err)
+				}
+			}
+		case *graphite.Service:
+			for _, gc := range c.GraphiteInputs {
+				d := gc.WithDefaults()
+				if !d.Enabled || d.BindAddress != srv.BindAddress() || d.Protocol != srv.Protocol() {
+					continue
+				}
+				if err := srv.Reload(gc); err != nil {
+					return fmt.Errorf("reload graphite service %s: %s", gc.BindAddress, err)
+				}
+			}
+		case *collectd.Service:
+			for _, cc := range c.CollectdInputs {
+				d := cc.WithDefaults()
+				if !d.Enabled || d.BindAddress != srv.Config.BindAddress {
+					continue
+				}
+				if err := srv.Reload(cc); err != nil {
+					return fmt.Errorf("reload collectd service %s: %s", cc.BindAddress, err)
+				}
+			}
+		default:
+			// The service cannot be reconfigured at runtime.
+		}
+	}
+	return nil
+}
+
 // Err returns an error channel that multiplexes all out of band errors received from all services.
 func (s *Server) Err() <-chan error { return s.err }
 
@@ -374,6 +565,7 @@ func (s *Server) Open() error {
 	s.appendContinuousQueryService(s.config.ContinuousQuery)
 	s.appendHTTPDService(s.config.HTTPD)
 	s.appendStorageService(s.config.Storage)
+	s.appendGRPCService(s.config.GRPC)
 	s.appendRetentionPolicyService(s.config.Retention)
 	for _, i := range s.config.GraphiteInputs {
 		if err := s.appendGraphiteService(i); err != nil {
@@ -391,6 +583,35 @@ func (s *Server) Open() error {
 	for _, i := range s.config.UDPInputs {
 		s.appendUDPService(i)
 	}
+	for _, i := range s.config.StatsdInputs {
+		s.appendStatsdService(i)
+	}
+	for _, i := range s.config.MQTTInputs {
+		s.appendMQTTService(i)
+	}
+	for _, i := range s.config.KafkaInputs {
+		s.appendKafkaService(i)
+	}
+	for _, i := range s.config.NATSInputs {
+		s.appendNATSService(i)
+	}
+	for _, i := range s.config.AMQPInputs {
+		s.appendAMQPService(i)
+	}
+	for _, i := range s.config.SyslogInputs {
+		s.appendSyslogService(i)
+	}
+	for _, i := range s.config.WebhookInputs {
+		s.appendWebhookService(i)
+	}
+	for _, i := range s.config.SNMPInputs {
+		s.appendSNMPService(i)
+	}
+
+	// The tracer is closed last, to export the spans of inflight requests.
+	if s.Tracer != nil {
+		s.Services = append(s.Services, s.Tracer)
+	}
 
 	s.Subscriber.MetaClient = s.MetaClient
 	s.PointsWriter.MetaClient = s.MetaClient
@@ -413,6 +634,9 @@ func (s *Server) Open() error {
 	}
 	s.SnapshotterService.WithLogger(s.Logger)
 	s.Monitor.WithLogger(s.Logger)
+	if s.SlowQueryLog != nil {
+		s.SlowQueryLog.WithLogger(s.Logger)
+	}
 
 	// Open TSDB store.
 	if err := s.TSDBStore.Open(); err != nil {
@@ -470,6 +694,10 @@ func (s *Server) Close() error {
 		s.QueryExecutor.Close()
 	}
 
+	if s.SlowQueryLog != nil {
+		s.SlowQueryLog.Close()
+	}
+
 	// Close the TSDBStore, no more reads or writes at this point
 	if s.TSDBStore != nil {
 		s.TSDBStore.Close()
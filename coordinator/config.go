@@ -25,6 +25,21 @@ const (
 	// DefaultMaxSelectSeriesN is the maximum number of series a SELECT can run.
 	// A value of zero will make the maximum series count unlimited.
 	DefaultMaxSelectSeriesN = 0
+
+	// DefaultInteractiveQueryWeight, DefaultBatchQueryWeight and
+	// DefaultBackgroundQueryWeight are the default shares of the queries
+	// admitted from each class by the query scheduler.
+	DefaultInteractiveQueryWeight = 8
+	DefaultBatchQueryWeight       = 2
+	DefaultBackgroundQueryWeight  = 1
+
+	// DefaultResultCacheTTL is the default duration the results of
+	// statements are cached for.
+	DefaultResultCacheTTL = time.Minute
+
+	// DefaultSlowQuerySampleInterval is the default interval between samples
+	// of the iterator stats of statements in the slow query log.
+	DefaultSlowQuerySampleInterval = time.Second
 )
 
 // Config represents the configuration for the coordinator service.
@@ -36,6 +51,24 @@ type Config struct {
 	MaxSelectPointN      int           `toml:"max-select-point"`
 	MaxSelectSeriesN     int           `toml:"max-select-series"`
 	MaxSelectBucketsN    int           `toml:"max-select-buckets"`
+	FillLookback         toml.Duration `toml:"fill-lookback"`
+	IntoSliceDuration    toml.Duration `toml:"into-slice-duration"`
+	IntoWriteRate        int           `toml:"into-write-rate"`
+	ResultCacheMaxSize   toml.Size     `toml:"result-cache-max-size"`
+	ResultCacheTTL       toml.Duration `toml:"result-cache-ttl"`
+
+	QueryConcurrency            int `toml:"query-concurrency"`
+	InteractiveQueryConcurrency int `toml:"interactive-query-concurrency"`
+	BatchQueryConcurrency       int `toml:"batch-query-concurrency"`
+	BackgroundQueryConcurrency  int `toml:"background-query-concurrency"`
+	InteractiveQueryWeight      int `toml:"interactive-query-weight"`
+	BatchQueryWeight            int `toml:"batch-query-weight"`
+	BackgroundQueryWeight       int `toml:"background-query-weight"`
+
+	SlowQueryThreshold      toml.Duration `toml:"slow-query-threshold"`
+	SlowQuerySampleInterval toml.Duration `toml:"slow-query-sample-interval"`
+	SlowQueryLogPath        string        `toml:"slow-query-log-path"`
+	SlowQueryLogInternal    bool          `toml:"slow-query-log-internal"`
 }
 
 // NewConfig returns an instance of Config with defaults.
@@ -46,6 +79,13 @@ func NewConfig() Config {
 		MaxConcurrentQueries: DefaultMaxConcurrentQueries,
 		MaxSelectPointN:      DefaultMaxSelectPointN,
 		MaxSelectSeriesN:     DefaultMaxSelectSeriesN,
+		ResultCacheTTL:       toml.Duration(DefaultResultCacheTTL),
+
+		InteractiveQueryWeight: DefaultInteractiveQueryWeight,
+		BatchQueryWeight:       DefaultBatchQueryWeight,
+		BackgroundQueryWeight:  DefaultBackgroundQueryWeight,
+
+		SlowQuerySampleInterval: toml.Duration(DefaultSlowQuerySampleInterval),
 	}
 }
 
@@ -59,5 +99,25 @@ func (c Config) Diagnostics() (*diagnostics.Diagnostics, error) {
 		"max-select-point":       c.MaxSelectPointN,
 		"max-select-series":      c.MaxSelectSeriesN,
 		"max-select-buckets":     c.MaxSelectBucketsN,
+		"fill-lookback":          c.FillLookback,
+		"into-slice-duration":    c.IntoSliceDuration,
+		"into-write-rate":        c.IntoWriteRate,
+		"result-cache-max-size":  c.ResultCacheMaxSize,
+		"result-cache-ttl":       c.ResultCacheTTL,
+		"query-concurrency":      c.QueryConcurrency,
+		"slow-query-threshold":   c.SlowQueryThreshold,
 	}), nil
 }
+
+// NewQueryScheduler returns the query scheduler of c, or nil if neither the
+// concurrency of queries nor of a query class is limited.
+func (c Config) NewQueryScheduler() *query.QueryScheduler {
+	if c.QueryConcurrency <= 0 && c.InteractiveQueryConcurrency <= 0 && c.BatchQueryConcurrency <= 0 && c.BackgroundQueryConcurrency <= 0 {
+		return nil
+	}
+	return query.NewQueryScheduler(c.QueryConcurrency,
+		query.QueryClassLimits{Concurrency: c.InteractiveQueryConcurrency, Weight: c.InteractiveQueryWeight},
+		query.QueryClassLimits{Concurrency: c.BatchQueryConcurrency, Weight: c.BatchQueryWeight},
+		query.QueryClassLimits{Concurrency: c.BackgroundQueryConcurrency, Weight: c.BackgroundQueryWeight},
+	)
+}
@@ -0,0 +1,300 @@
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxql"
+	"golang.org/x/time/rate"
+)
+
+// intoChunkSize is the maximum number of points of a series emitted at once
+// by a SELECT INTO statement, so a series is never held in memory as a whole.
+const intoChunkSize = 10000
+
+// intoSlice is the part of a SELECT INTO statement executed for a time slice.
+type intoSlice struct {
+	stmt  *influxql.SelectStatement
+	start time.Time
+}
+
+// executeSelectIntoStatement executes a SELECT INTO statement. The statement
+// is executed in consecutive time slices if the IntoSliceDuration is set and
+// the statement produces the same points when executed in parts. The points
+// of a slice are written before the next slice is read, so the statement can
+// be resumed from the start of the failed slice if an error occurs.
+func (e *StatementExecutor) executeSelectIntoStatement(ctx context.Context, stmt *influxql.SelectStatement, ectx *query.ExecutionContext) error {
+	slices, err := e.intoSlices(stmt)
+	if err != nil {
+		return err
+	}
+
+	var limiter *rate.Limiter
+	if e.IntoWriteRate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(e.IntoWriteRate), e.IntoWriteRate)
+	}
+
+	pointsWriter := NewBufferedPointsWriter(e.PointsWriter, stmt.Target.Measurement.Database, stmt.Target.Measurement.RetentionPolicy, intoChunkSize)
+
+	var writeN int64
+	for i, slice := range slices {
+		n, err := e.writeIntoSlice(ctx, slice.stmt, ectx, pointsWriter, limiter)
+		writeN += n
+		if err != nil {
+			if i > 0 && err != query.ErrQueryInterrupted {
+				return fmt.Errorf("%s (points before %s were written, resume the statement from this time)", err, slice.start.UTC().Format(time.RFC3339Nano))
+			}
+			return err
+		}
+
+		if len(slices) > 1 && ectx.Query != nil {
+			ectx.Query.SetProgress(fmt.Sprintf("%d/%d slices, %d points written", i+1, len(slices), writeN))
+		}
+	}
+
+	var messages []*query.Message
+	if ectx.ReadOnly {
+		messages = append(messages, query.ReadOnlyWarning(stmt.String()))
+	}
+
+	return ectx.Send(&query.Result{
+		StatementID: ectx.StatementID,
+		Messages:    messages,
+		Series: []*models.Row{{
+			Name:    "result",
+			Columns: []string{"time", "written"},
+			Values:  [][]interface{}{{time.Unix(0, 0).UTC(), writeN}},
+		}},
+	})
+}
+
+// writeIntoSlice executes the statement of a slice and writes its points. It
+// returns the number of points written.
+func (e *StatementExecutor) writeIntoSlice(ctx context.Context, stmt *influxql.SelectStatement, ectx *query.ExecutionContext, w *BufferedPointsWriter, limiter *rate.Limiter) (int64, error) {
+	itrs, columns, err := e.createIterators(ctx, stmt, ectx)
+	if err != nil {
+		return 0, err
+	}
+
+	chunkSize := intoChunkSize
+	if ectx.ChunkSize > 0 && ectx.ChunkSize < chunkSize {
+		chunkSize = ectx.ChunkSize
+	}
+
+	em := query.NewEmitter(itrs, stmt.TimeAscending(), chunkSize)
+	em.Columns = columns
+	if stmt.Location != nil {
+		em.Location = stmt.Location
+	}
+	em.OmitTime = stmt.OmitTime
+	em.EmitName = stmt.EmitName
+	defer em.Close()
+
+	// Record the statement if it is slow, before the iterators are closed.
+	if e.SlowQueryLog != nil {
+		defer e.SlowQueryLog.track(itrs).finish(stmt.String(), ectx)
+	}
+
+	var writeN int64
+	for {
+		row, _, err := em.Emit()
+		if err != nil {
+			return writeN, err
+		} else if row == nil {
+			// Check if the query was interrupted while emitting.
+			select {
+			case <-ectx.InterruptCh:
+				return writeN, query.ErrQueryInterrupted
+			default:
+			}
+			break
+		}
+
+		if err := e.writeInto(w, stmt, row); err != nil {
+			return writeN, err
+		}
+		writeN += int64(len(row.Values))
+
+		if err := waitIntoRate(limiter, len(row.Values), ectx.InterruptCh); err != nil {
+			return writeN, err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return writeN, err
+	}
+	return writeN, nil
+}
+
+// waitIntoRate waits until n points may be written according to the limiter.
+// It returns query.ErrQueryInterrupted if the query is interrupted while
+// waiting.
+func waitIntoRate(limiter *rate.Limiter, n int, interrupt <-chan struct{}) error {
+	if limiter == nil {
+		return nil
+	}
+
+	for n > 0 {
+		// A reservation cannot exceed the burst of the limiter.
+		m := n
+		if burst := limiter.Burst(); m > burst {
+			m = burst
+		}
+		n -= m
+
+		d := limiter.ReserveN(time.Now(), m).Delay()
+		if d <= 0 {
+			continue
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-interrupt:
+			timer.Stop()
+			return query.ErrQueryInterrupted
+		}
+	}
+	return nil
+}
+
+// intoSlices returns the statements executing a SELECT INTO statement in
+// consecutive time slices. A single slice executing the statement itself is
+// returned if the statement cannot be sliced.
+func (e *StatementExecutor) intoSlices(stmt *influxql.SelectStatement) ([]intoSlice, error) {
+	now := time.Now().UTC()
+	cond, timeRange, err := influxql.ConditionExpr(stmt.Condition, &influxql.NowValuer{Now: now, Location: stmt.Location})
+	if err != nil {
+		return nil, err
+	}
+
+	if e.IntoSliceDuration <= 0 || timeRange.Min.IsZero() || !intoSliceable(stmt) {
+		return []intoSlice{{stmt: stmt, start: timeRange.Min}}, nil
+	}
+
+	// Slices end at the end of the time range or, if there is none, at the
+	// current time with the last slice left open like the original statement.
+	max := timeRange.Max
+	if max.IsZero() {
+		max = now
+	}
+
+	// Slices of an aggregate query are made of whole intervals so every
+	// interval is computed from all of its points.
+	var opt query.IteratorOptions
+	sliceDuration := e.IntoSliceDuration
+	if !stmt.IsRawQuery {
+		interval, _ := stmt.GroupByInterval()
+		offset, err := stmt.GroupByOffset()
+		if err != nil {
+			return nil, err
+		}
+		opt.Interval = query.Interval{Duration: interval, Offset: offset}
+		opt.Location = stmt.Location
+		if sliceDuration < interval {
+			sliceDuration = interval
+		}
+	}
+
+	var slices []intoSlice
+	start := timeRange.Min
+	for !start.After(max) {
+		end := start.Add(sliceDuration)
+		if !stmt.IsRawQuery {
+			// Move the end of the slice to the start of its interval, unless
+			// the slice would not contain any interval.
+			if windowStart, _ := opt.Window(end.UnixNano()); windowStart > start.UnixNano() {
+				end = time.Unix(0, windowStart).UTC()
+			}
+		}
+
+		slice := intoSlice{stmt: stmt.Clone(), start: start}
+		timeCond := influxql.Expr(&influxql.BinaryExpr{
+			Op:  influxql.GTE,
+			LHS: &influxql.VarRef{Val: "time"},
+			RHS: &influxql.TimeLiteral{Val: start},
+		})
+		if end.After(max) {
+			if !timeRange.Max.IsZero() {
+				timeCond = &influxql.BinaryExpr{
+					Op:  influxql.AND,
+					LHS: timeCond,
+					RHS: &influxql.BinaryExpr{
+						Op:  influxql.LTE,
+						LHS: &influxql.VarRef{Val: "time"},
+						RHS: &influxql.TimeLiteral{Val: timeRange.Max},
+					},
+				}
+			}
+		} else {
+			timeCond = &influxql.BinaryExpr{
+				Op:  influxql.AND,
+				LHS: timeCond,
+				RHS: &influxql.BinaryExpr{
+					Op:  influxql.LT,
+					LHS: &influxql.VarRef{Val: "time"},
+					RHS: &influxql.TimeLiteral{Val: end},
+				},
+			}
+		}
+
+		if cond != nil {
+			slice.stmt.Condition = &influxql.BinaryExpr{
+				Op:  influxql.AND,
+				LHS: &influxql.ParenExpr{Expr: influxql.CloneExpr(cond)},
+				RHS: timeCond,
+			}
+		} else {
+			slice.stmt.Condition = timeCond
+		}
+		slices = append(slices, slice)
+		start = end
+	}
+	return slices, nil
+}
+
+// intoSliceable returns true if executing the statement in consecutive time
+// slices produces the same points as executing it at once. Limits, fills
+// reading the previous interval and functions reading previous points depend
+// on the points outside of a slice.
+func intoSliceable(stmt *influxql.SelectStatement) bool {
+	if stmt.Limit > 0 || stmt.Offset > 0 || stmt.SLimit > 0 || stmt.SOffset > 0 {
+		return false
+	}
+
+	for _, source := range stmt.Sources {
+		if _, ok := source.(*influxql.Measurement); !ok {
+			return false
+		}
+	}
+
+	if !stmt.IsRawQuery {
+		if interval, err := stmt.GroupByInterval(); err != nil || interval <= 0 {
+			return false
+		}
+		if stmt.Fill == influxql.PreviousFill || stmt.Fill == influxql.LinearFill {
+			return false
+		}
+	}
+
+	sliceable := true
+	for _, f := range stmt.Fields {
+		influxql.WalkFunc(f.Expr, func(n influxql.Node) {
+			if call, ok := n.(*influxql.Call); ok {
+				switch call.Name {
+				case "cumulative_sum", "derivative", "difference", "elapsed",
+					"holt_winters", "holt_winters_with_fit", "moving_average",
+					"exponential_moving_average", "double_exponential_moving_average",
+					"triple_exponential_moving_average", "kaufmans_adaptive_moving_average",
+					"median_absolute_deviation", "zscore", "lag", "lead",
+					"non_negative_derivative", "non_negative_difference":
+					sliceable = false
+				}
+			}
+		})
+	}
+	return sliceable
+}
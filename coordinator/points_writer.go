@@ -1,14 +1,18 @@
 package coordinator
 
 import (
+	"context"
 	"errors"
 	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/influxdb"
 	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/tracing"
+	"github.com/influxdata/influxdb/pkg/tracing/fields"
 	"github.com/influxdata/influxdb/services/meta"
 	"github.com/influxdata/influxdb/tsdb"
 	"go.uber.org/zap"
@@ -57,6 +61,13 @@ type PointsWriter struct {
 	TSDBStore interface {
 		CreateShard(database, retentionPolicy string, shardID uint64, enabled bool) error
 		WriteToShard(shardID uint64, points []models.Point) error
+		ValidateShardPoints(shardID uint64, points []models.Point) (map[int]error, error)
+	}
+
+	// Drops the cached results of the statements reading the points
+	// written, if set.
+	ResultCache interface {
+		Invalidate(database, retentionPolicy string, min, max int64)
 	}
 
 	subPoints []chan<- *WritePointsRequest
@@ -238,6 +249,59 @@ func (w *PointsWriter) MapShards(wp *WritePointsRequest) (*ShardMapping, error)
 	return mapping, nil
 }
 
+// ValidatePoints returns the errors the points would be dropped with if they
+// were written, by index, without writing them or creating shard groups.
+func (w *PointsWriter) ValidatePoints(database, retentionPolicy string, points []models.Point) (map[int]error, error) {
+	if retentionPolicy == "" {
+		db := w.MetaClient.Database(database)
+		if db == nil {
+			return nil, influxdb.ErrDatabaseNotFound(database)
+		}
+		retentionPolicy = db.DefaultRetentionPolicy
+	}
+
+	rp, err := w.MetaClient.RetentionPolicy(database, retentionPolicy)
+	if err != nil {
+		return nil, err
+	} else if rp == nil {
+		return nil, influxdb.ErrRetentionPolicyNotFound(retentionPolicy)
+	}
+
+	min := time.Unix(0, models.MinNanoTime)
+	if rp.Duration > 0 {
+		min = time.Now().Add(-rp.Duration)
+	}
+
+	// Group the points of existing shards, keeping their indexes.
+	errs := make(map[int]error)
+	shards := make(map[uint64][]int)
+	for i, p := range points {
+		if p.Time().Before(min) {
+			errs[i] = errors.New("point beyond retention policy")
+			continue
+		}
+		if sg := rp.ShardGroupByTimestamp(p.Time()); sg != nil {
+			sh := sg.ShardFor(p.HashID())
+			shards[sh.ID] = append(shards[sh.ID], i)
+		}
+	}
+
+	for shardID, indexes := range shards {
+		pts := make([]models.Point, len(indexes))
+		for j, i := range indexes {
+			pts[j] = points[i]
+		}
+		shardErrs, err := w.TSDBStore.ValidateShardPoints(shardID, pts)
+		if err != nil {
+			return nil, err
+		}
+		for j, err := range shardErrs {
+			errs[indexes[j]] = err
+		}
+	}
+	return errs, nil
+}
+
 // sgList is a wrapper around a meta.ShardGroupInfos where we can also check
 // if a given time is covered by any of the shard groups in the list.
 type sgList meta.ShardGroupInfos
@@ -286,8 +350,18 @@ func (w *PointsWriter) WritePoints(database, retentionPolicy string, consistency
 	return w.WritePointsPrivileged(database, retentionPolicy, consistencyLevel, points)
 }
 
+// WritePointsWithContext is like WritePoints, but records spans of the write
+// and of the writes to each shard if ctx carries a span.
+func (w *PointsWriter) WritePointsWithContext(ctx context.Context, database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error {
+	return w.writePoints(ctx, database, retentionPolicy, consistencyLevel, points)
+}
+
 // WritePointsPrivileged writes the data to the underlying storage, consitencyLevel is only used for clustered scenarios
 func (w *PointsWriter) WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	return w.writePoints(context.Background(), database, retentionPolicy, consistencyLevel, points)
+}
+
+func (w *PointsWriter) writePoints(ctx context.Context, database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
 	atomic.AddInt64(&w.stats.WriteReq, 1)
 	atomic.AddInt64(&w.stats.PointWriteReq, int64(len(points)))
 
@@ -299,6 +373,14 @@ func (w *PointsWriter) WritePointsPrivileged(database, retentionPolicy string, c
 		retentionPolicy = db.DefaultRetentionPolicy
 	}
 
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		span = span.StartSpan("write_points")
+		span.SetLabels("db", database, "rp", retentionPolicy)
+		span.SetFields(fields.New(fields.Int64("points", int64(len(points)))))
+		defer span.Finish()
+		ctx = tracing.NewContextWithSpan(ctx, span)
+	}
+
 	shardMappings, err := w.MapShards(&WritePointsRequest{Database: database, RetentionPolicy: retentionPolicy, Points: points})
 	if err != nil {
 		return err
@@ -308,7 +390,7 @@ func (w *PointsWriter) WritePointsPrivileged(database, retentionPolicy string, c
 	ch := make(chan error, len(shardMappings.Points))
 	for shardID, points := range shardMappings.Points {
 		go func(shard *meta.ShardInfo, database, retentionPolicy string, points []models.Point) {
-			ch <- w.writeToShard(shard, database, retentionPolicy, points)
+			ch <- w.writeToShard(ctx, shard, database, retentionPolicy, points)
 		}(shardMappings.Shards[shardID], database, retentionPolicy, points)
 	}
 
@@ -359,9 +441,20 @@ func (w *PointsWriter) WritePointsPrivileged(database, retentionPolicy string, c
 }
 
 // writeToShards writes points to a shard.
-func (w *PointsWriter) writeToShard(shard *meta.ShardInfo, database, retentionPolicy string, points []models.Point) error {
+func (w *PointsWriter) writeToShard(ctx context.Context, shard *meta.ShardInfo, database, retentionPolicy string, points []models.Point) error {
 	atomic.AddInt64(&w.stats.PointWriteReqLocal, int64(len(points)))
 
+	if w.ResultCache != nil {
+		defer w.invalidateResults(database, retentionPolicy, points)
+	}
+
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		span = span.StartSpan("write_shard")
+		span.SetLabels("shard_id", strconv.FormatUint(shard.ID, 10))
+		span.SetFields(fields.New(fields.Int64("points", int64(len(points)))))
+		defer span.Finish()
+	}
+
 	err := w.TSDBStore.WriteToShard(shard.ID, points)
 	if err == nil {
 		atomic.AddInt64(&w.stats.WriteOK, 1)
@@ -395,3 +488,21 @@ func (w *PointsWriter) writeToShard(shard *meta.ShardInfo, database, retentionPo
 	atomic.AddInt64(&w.stats.WriteOK, 1)
 	return nil
 }
+
+// invalidateResults drops the cached results of the statements reading the
+// time range of points.
+func (w *PointsWriter) invalidateResults(database, retentionPolicy string, points []models.Point) {
+	if len(points) == 0 {
+		return
+	}
+
+	min, max := points[0].UnixNano(), points[0].UnixNano()
+	for _, p := range points[1:] {
+		if t := p.UnixNano(); t < min {
+			min = t
+		} else if t > max {
+			max = t
+		}
+	}
+	w.ResultCache.Invalidate(database, retentionPolicy, min, max)
+}
@@ -1,6 +1,8 @@
 package coordinator_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sync"
@@ -11,6 +13,7 @@ import (
 	"github.com/influxdata/influxdb"
 	"github.com/influxdata/influxdb/coordinator"
 	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/tracing"
 	"github.com/influxdata/influxdb/services/meta"
 	"github.com/influxdata/influxdb/tsdb"
 )
@@ -428,6 +431,89 @@ func TestPointsWriter_WritePoints_Dropped(t *testing.T) {
 	}
 }
 
+// Ensures the points writer records spans of the write and of each shard
+// written to.
+func TestPointsWriter_WritePointsWithContext(t *testing.T) {
+	// Ensure that the test shard groups are created before the points
+	// are created.
+	ms := NewPointsWriterMetaClient()
+	ms.NodeIDFn = func() uint64 { return 1 }
+
+	// Two points mapped to distinct shards.
+	pr := &coordinator.WritePointsRequest{
+		Database:        "mydb",
+		RetentionPolicy: "myrp",
+	}
+	pr.AddPoint("cpu", 1.0, time.Now(), nil)
+	pr.AddPoint("cpu", 2.0, time.Now().Add(time.Hour), nil)
+
+	c := coordinator.NewPointsWriter()
+	c.MetaClient = ms
+	c.TSDBStore = &fakeStore{
+		WriteFn: func(shardID uint64, points []models.Point) error { return nil },
+	}
+	c.Node = &influxdb.Node{ID: 1}
+
+	c.Open()
+	defer c.Close()
+
+	tr, span := tracing.NewTrace("write")
+	ctx := tracing.NewContextWithSpan(context.Background(), span)
+	if err := c.WritePointsWithContext(ctx, pr.Database, pr.RetentionPolicy, models.ConsistencyLevelOne, nil, pr.Points); err != nil {
+		t.Fatal(err)
+	}
+	span.Finish()
+
+	var names []string
+	for _, raw := range tr.Spans() {
+		names = append(names, raw.Name)
+	}
+	if exp := []string{"write", "write_points", "write_shard", "write_shard"}; !reflect.DeepEqual(names, exp) {
+		t.Fatalf("unexpected spans: %v", names)
+	}
+}
+
+// Ensure points are validated by the shards they map to, without being written.
+func TestPointsWriter_ValidatePoints(t *testing.T) {
+	ms := NewPointsWriterMetaClient()
+	ms.NodeIDFn = func() uint64 { return 1 }
+
+	pr := &coordinator.WritePointsRequest{
+		Database:        "mydb",
+		RetentionPolicy: "myrp",
+	}
+	pr.AddPoint("cpu", 1.0, time.Now().Add(-2*time.Hour), nil)
+	pr.AddPoint("cpu", 2.0, time.Now().Add(time.Minute), nil)
+	pr.AddPoint("cpu", 3.0, time.Now().Add(time.Minute), map[string]string{"host": "serverA"})
+	pr.AddPoint("cpu", 4.0, time.Now().Add(24*time.Hour), nil)
+
+	c := coordinator.NewPointsWriter()
+	c.MetaClient = ms
+	c.TSDBStore = &fakeStore{
+		WriteFn: func(shardID uint64, points []models.Point) error {
+			t.Fatal("unexpected write")
+			return nil
+		},
+		ValidateFn: func(shardID uint64, points []models.Point) (map[int]error, error) {
+			if len(points) != 2 {
+				t.Fatalf("unexpected points: %v", points)
+			}
+			return map[int]error{1: errors.New("field type conflict")}, nil
+		},
+	}
+
+	errs, err := c.ValidatePoints(pr.Database, pr.RetentionPolicy, pr.Points)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(errs) != 2 {
+		t.Fatalf("unexpected errors: %v", errs)
+	} else if errs[0] == nil || errs[0].Error() != "point beyond retention policy" {
+		t.Fatalf("unexpected error: %v", errs[0])
+	} else if errs[2] == nil || errs[2].Error() != "field type conflict" {
+		t.Fatalf("unexpected error: %v", errs[2])
+	}
+}
+
 type fakePointsWriter struct {
 	WritePointsIntoFn func(*coordinator.IntoWriteRequest) error
 }
@@ -551,12 +637,17 @@ var shardID uint64
 type fakeStore struct {
 	WriteFn       func(shardID uint64, points []models.Point) error
 	CreateShardfn func(database, retentionPolicy string, shardID uint64, enabled bool) error
+	ValidateFn    func(shardID uint64, points []models.Point) (map[int]error, error)
 }
 
 func (f *fakeStore) WriteToShard(shardID uint64, points []models.Point) error {
 	return f.WriteFn(shardID, points)
 }
 
+func (f *fakeStore) ValidateShardPoints(shardID uint64, points []models.Point) (map[int]error, error) {
+	return f.ValidateFn(shardID, points)
+}
+
 func (f *fakeStore) CreateShard(database, retentionPolicy string, shardID uint64, enabled bool) error {
 	return f.CreateShardfn(database, retentionPolicy, shardID, enabled)
 }
@@ -0,0 +1,379 @@
+package coordinator
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxql"
+)
+
+// The keys for statistics generated by the "resultCache" module.
+const (
+	statResultCacheHits          = "hits"
+	statResultCacheMisses        = "misses"
+	statResultCacheEvictions     = "evictions"
+	statResultCacheInvalidations = "invalidations"
+	statResultCacheEntries       = "entries"
+	statResultCacheSize          = "sizeBytes"
+)
+
+// ResultCache caches the results of SELECT statements, so identical
+// statements executed repeatedly, such as by dashboards refreshing, are only
+// executed once.
+//
+// Results are keyed by the statement and the time range it reads. Writes
+// drop the results of the statements reading the retention policy written to
+// over an overlapping time range, and statements deleting data drop every
+// result. Shards dropped by retention policy enforcement are not tracked, so
+// results also expire after the TTL.
+type ResultCache struct {
+	// MaxSize is the maximum estimated size in bytes of the cached results.
+	// The least recently used results are evicted above it.
+	MaxSize int64
+
+	// TTL is the duration results are cached for.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// sources indexes the entries by the retention policies they read.
+	// seqs is the sequence number of the last invalidation of a source,
+	// so results read before a write to their source are not cached.
+	sources map[Source]map[*resultCacheEntry]struct{}
+	seqs    map[Source]uint64
+	seq     uint64
+	cleared uint64
+
+	stats ResultCacheStatistics
+}
+
+// ResultCacheStatistics keeps statistics related to the result cache.
+type ResultCacheStatistics struct {
+	Hits          int64
+	Misses        int64
+	Evictions     int64
+	Invalidations int64
+}
+
+// NewResultCache returns a result cache of c, or nil if it is disabled.
+func NewResultCache(c Config) *ResultCache {
+	if c.ResultCacheMaxSize == 0 {
+		return nil
+	}
+
+	ttl := time.Duration(c.ResultCacheTTL)
+	if ttl <= 0 {
+		ttl = DefaultResultCacheTTL
+	}
+	return &ResultCache{
+		MaxSize: int64(c.ResultCacheMaxSize),
+		TTL:     ttl,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		sources: make(map[Source]map[*resultCacheEntry]struct{}),
+		seqs:    make(map[Source]uint64),
+	}
+}
+
+// resultCacheEntry is the cached results of a statement.
+type resultCacheEntry struct {
+	key      string
+	sources  []Source
+	min, max int64
+	results  []*query.Result
+	size     int64
+	expires  time.Time
+}
+
+// Statistics returns statistics for periodic monitoring.
+func (c *ResultCache) Statistics(tags map[string]string) []models.Statistic {
+	c.mu.Lock()
+	entries, size := len(c.entries), c.size
+	c.mu.Unlock()
+
+	return []models.Statistic{{
+		Name: "resultCache",
+		Tags: tags,
+		Values: map[string]interface{}{
+			statResultCacheHits:          atomic.LoadInt64(&c.stats.Hits),
+			statResultCacheMisses:        atomic.LoadInt64(&c.stats.Misses),
+			statResultCacheEvictions:     atomic.LoadInt64(&c.stats.Evictions),
+			statResultCacheInvalidations: atomic.LoadInt64(&c.stats.Invalidations),
+			statResultCacheEntries:       int64(entries),
+			statResultCacheSize:          size,
+		},
+	}}
+}
+
+// get returns a copy of the cached results of key, or nil if they aren't
+// cached.
+func (c *ResultCache) get(key string) []*query.Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		atomic.AddInt64(&c.stats.Misses, 1)
+		return nil
+	}
+
+	entry := elem.Value.(*resultCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(entry)
+		atomic.AddInt64(&c.stats.Misses, 1)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	atomic.AddInt64(&c.stats.Hits, 1)
+	return copyResults(entry.results)
+}
+
+// Invalidate drops the results of the statements reading the retention
+// policy of a database between min and max.
+func (c *ResultCache) Invalidate(database, retentionPolicy string, min, max int64) {
+	source := Source{Database: database, RetentionPolicy: retentionPolicy}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	c.seqs[source] = c.seq
+	for entry := range c.sources[source] {
+		if entry.min <= max && entry.max >= min {
+			c.remove(entry)
+			atomic.AddInt64(&c.stats.Invalidations, 1)
+		}
+	}
+}
+
+// Clear drops all results.
+func (c *ResultCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	c.cleared = c.seq
+	atomic.AddInt64(&c.stats.Invalidations, int64(len(c.entries)))
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.sources = make(map[Source]map[*resultCacheEntry]struct{})
+	c.size = 0
+}
+
+// remove removes an entry from the cache. The lock must be held.
+func (c *ResultCache) remove(entry *resultCacheEntry) {
+	elem, ok := c.entries[entry.key]
+	if !ok {
+		return
+	}
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	for _, source := range entry.sources {
+		delete(c.sources[source], entry)
+		if len(c.sources[source]) == 0 {
+			delete(c.sources, source)
+		}
+	}
+	c.size -= entry.size
+}
+
+// record returns a recorder of the results of a statement that caches them
+// when they are complete. It returns nil if the results of the statement
+// cannot be cached. Points up to lookback before the start time of the
+// statement are read to seed its fill.
+func (c *ResultCache) record(stmt *influxql.SelectStatement, ectx *query.ExecutionContext, lookback time.Duration) *resultCacheRecorder {
+	if stmt.Target != nil || !query.AuthorizerIsOpen(ectx.Authorizer) {
+		return nil
+	}
+
+	valuer := &influxql.NowValuer{Now: time.Now(), Location: stmt.Location}
+	cond, timeRange, err := influxql.ConditionExpr(stmt.Condition, valuer)
+	if err != nil {
+		return nil
+	}
+
+	// The intervals of a statement without an end time extend to the
+	// current time, so they change without any write.
+	if interval, err := stmt.GroupByInterval(); err != nil || (interval > 0 && timeRange.Max.IsZero()) {
+		return nil
+	}
+
+	sources := resultCacheSources(stmt.Sources, nil)
+	if sources == nil {
+		return nil
+	}
+
+	// The statement is normalized by replacing its time condition with the
+	// time range it resolves to.
+	other := stmt.Clone()
+	other.Condition = cond
+	key := fmt.Sprintf("%s\x00%d\x00%d\x00%d", other.String(), timeRange.MinTimeNano(), timeRange.MaxTimeNano(), ectx.ChunkSize)
+	if results := c.get(key); results != nil {
+		return &resultCacheRecorder{key: key, cached: results}
+	}
+
+	min := timeRange.MinTimeNano()
+	if lookback > 0 && min > influxql.MinTime {
+		min -= int64(lookback)
+	}
+
+	c.mu.Lock()
+	seq := c.seq
+	c.mu.Unlock()
+
+	return &resultCacheRecorder{
+		cache: c,
+		key:   key,
+		seq:   seq,
+		entry: &resultCacheEntry{
+			key:     key,
+			sources: sources,
+			min:     min,
+			max:     timeRange.MaxTimeNano(),
+		},
+	}
+}
+
+// resultCacheSources appends the retention policies read by sources to a.
+// It returns nil if a source isn't a measurement or a subquery.
+func resultCacheSources(sources influxql.Sources, a []Source) []Source {
+	for _, source := range sources {
+		switch source := source.(type) {
+		case *influxql.Measurement:
+			s := Source{Database: source.Database, RetentionPolicy: source.RetentionPolicy}
+			if s.Database == "" || s.RetentionPolicy == "" {
+				return nil
+			}
+			found := false
+			for _, other := range a {
+				if other == s {
+					found = true
+					break
+				}
+			}
+			if !found {
+				a = append(a, s)
+			}
+		case *influxql.SubQuery:
+			if a = resultCacheSources(source.Statement.Sources, a); a == nil {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+	return a
+}
+
+// resultCacheRecorder records the results of a statement to cache them, or
+// holds the cached results of the statement.
+type resultCacheRecorder struct {
+	cache  *ResultCache
+	key    string
+	seq    uint64
+	entry  *resultCacheEntry
+	cached []*query.Result
+}
+
+// add records a result of the statement. Results are no longer recorded once
+// they exceed the maximum size of the cache.
+func (r *resultCacheRecorder) add(result *query.Result) {
+	if r == nil || r.entry == nil {
+		return
+	}
+
+	r.entry.size += resultSize(result)
+	if r.entry.size > r.cache.MaxSize {
+		r.entry = nil
+		return
+	}
+	r.entry.results = append(r.entry.results, copyResults([]*query.Result{result})...)
+}
+
+// commit caches the recorded results, unless the sources of the statement
+// were written to since it started.
+func (r *resultCacheRecorder) commit() {
+	if r == nil || r.entry == nil {
+		return
+	}
+
+	c := r.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cleared > r.seq {
+		return
+	}
+	for _, source := range r.entry.sources {
+		if c.seqs[source] > r.seq {
+			return
+		}
+	}
+
+	if elem, ok := c.entries[r.key]; ok {
+		c.remove(elem.Value.(*resultCacheEntry))
+	}
+	r.entry.expires = time.Now().Add(c.TTL)
+	c.entries[r.key] = c.lru.PushFront(r.entry)
+	for _, source := range r.entry.sources {
+		if c.sources[source] == nil {
+			c.sources[source] = make(map[*resultCacheEntry]struct{})
+		}
+		c.sources[source][r.entry] = struct{}{}
+	}
+	c.size += r.entry.size
+
+	// Evict the least recently used results.
+	for c.size > c.MaxSize {
+		c.remove(c.lru.Back().Value.(*resultCacheEntry))
+		atomic.AddInt64(&c.stats.Evictions, 1)
+	}
+}
+
+// copyResults returns a copy of results whose rows can be truncated and
+// appended to without modifying the rows of results.
+func copyResults(results []*query.Result) []*query.Result {
+	a := make([]*query.Result, len(results))
+	for i, result := range results {
+		other := *result
+		other.Series = make([]*models.Row, len(result.Series))
+		for j, row := range result.Series {
+			r := *row
+			r.Values = row.Values[:len(row.Values):len(row.Values)]
+			other.Series[j] = &r
+		}
+		a[i] = &other
+	}
+	return a
+}
+
+// resultSize returns an estimate of the size in bytes of a result.
+func resultSize(result *query.Result) int64 {
+	var n int64
+	for _, row := range result.Series {
+		n += int64(len(row.Name))
+		for k, v := range row.Tags {
+			n += int64(len(k) + len(v))
+		}
+		for _, column := range row.Columns {
+			n += int64(len(column))
+		}
+		for _, values := range row.Values {
+			for _, v := range values {
+				if s, ok := v.(string); ok {
+					n += int64(len(s))
+				}
+				n += 16
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,245 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/query"
+	"go.uber.org/zap"
+)
+
+// maxSlowQuerySamples is the maximum number of samples of the iterator stats
+// kept for a statement. Once reached, every other sample is dropped and the
+// sampling interval is doubled, so long statements are sampled evenly.
+const maxSlowQuerySamples = 64
+
+// SlowQuery is an entry of the slow query log.
+type SlowQuery struct {
+	Time      time.Time     `json:"time"`
+	QueryID   uint64        `json:"qid"`
+	Database  string        `json:"database"`
+	User      string        `json:"user,omitempty"`
+	Statement string        `json:"statement"`
+	Duration  time.Duration `json:"duration_ns"`
+
+	// SeriesN and PointN are the stats of the iterators of the statement
+	// when it finished, and Samples how they grew while it ran.
+	SeriesN int               `json:"series"`
+	PointN  int               `json:"points"`
+	Samples []SlowQuerySample `json:"samples"`
+}
+
+// SlowQuerySample is a sample of the stats of the iterators of a statement.
+type SlowQuerySample struct {
+	Elapsed time.Duration `json:"elapsed_ns"`
+	SeriesN int           `json:"series"`
+	PointN  int           `json:"points"`
+}
+
+// SlowQueryLog records the SELECT statements running longer than a
+// threshold, with samples of the stats of their iterators, to a file of JSON
+// lines and to the monitor. Statements are logged to the logger if neither is
+// set.
+type SlowQueryLog struct {
+	// Threshold is the duration above which statements are recorded.
+	Threshold time.Duration
+
+	// SampleInterval is the interval between samples of iterator stats.
+	SampleInterval time.Duration
+
+	// Monitor records the slow statements as points, if set.
+	Monitor interface {
+		Enabled() bool
+		WritePoints(models.Points) error
+	}
+
+	Logger *zap.Logger
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewSlowQueryLog returns a slow query log of c, or nil if it is disabled.
+func NewSlowQueryLog(c Config) (*SlowQueryLog, error) {
+	if c.SlowQueryThreshold <= 0 {
+		return nil, nil
+	}
+
+	l := &SlowQueryLog{
+		Threshold:      time.Duration(c.SlowQueryThreshold),
+		SampleInterval: time.Duration(c.SlowQuerySampleInterval),
+		Logger:         zap.NewNop(),
+	}
+	if l.SampleInterval <= 0 {
+		l.SampleInterval = DefaultSlowQuerySampleInterval
+	}
+	if c.SlowQueryLogPath != "" {
+		f, err := os.OpenFile(c.SlowQueryLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		l.file = f
+	}
+	return l, nil
+}
+
+// WithLogger sets the logger of the log.
+func (l *SlowQueryLog) WithLogger(log *zap.Logger) {
+	l.Logger = log.With(zap.String("service", "slow-query-log"))
+}
+
+// Close closes the file of the log.
+func (l *SlowQueryLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// slowQueryTracker samples the stats of the iterators of a statement while it
+// runs.
+type slowQueryTracker struct {
+	log   *SlowQueryLog
+	itrs  query.Iterators
+	start time.Time
+
+	done    chan struct{}
+	wg      sync.WaitGroup
+	samples []SlowQuerySample
+}
+
+// track starts sampling the stats of itrs. The tracker must be finished once
+// the statement is done.
+func (l *SlowQueryLog) track(itrs query.Iterators) *slowQueryTracker {
+	t := &slowQueryTracker{
+		log:   l,
+		itrs:  itrs,
+		start: time.Now(),
+		done:  make(chan struct{}),
+	}
+	t.wg.Add(1)
+	go t.sample()
+	return t
+}
+
+func (t *slowQueryTracker) sample() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.log.SampleInterval)
+	defer ticker.Stop()
+
+	skip := 1
+	for n := 1; ; n++ {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		}
+		if n%skip != 0 {
+			continue
+		}
+
+		stats := t.itrs.Stats()
+		t.samples = append(t.samples, SlowQuerySample{
+			Elapsed: time.Since(t.start),
+			SeriesN: stats.SeriesN,
+			PointN:  stats.PointN,
+		})
+		if len(t.samples) == maxSlowQuerySamples {
+			for i := 0; i < len(t.samples)/2; i++ {
+				t.samples[i] = t.samples[2*i+1]
+			}
+			t.samples = t.samples[:len(t.samples)/2]
+			skip *= 2
+		}
+	}
+}
+
+// finish stops sampling, and records the statement of the query of ectx if
+// it ran longer than the threshold.
+func (t *slowQueryTracker) finish(stmt string, ectx *query.ExecutionContext) {
+	d := time.Since(t.start)
+	close(t.done)
+	t.wg.Wait()
+	if d < t.log.Threshold {
+		return
+	}
+
+	stats := t.itrs.Stats()
+	sq := &SlowQuery{
+		Time:      t.start.UTC(),
+		QueryID:   ectx.QueryID,
+		Database:  ectx.Database,
+		Statement: stmt,
+		Duration:  d,
+		SeriesN:   stats.SeriesN,
+		PointN:    stats.PointN,
+		Samples:   t.samples,
+	}
+	if u, ok := ectx.Authorizer.(interface {
+		ID() string
+	}); ok {
+		sq.User = u.ID()
+	}
+	t.log.record(sq)
+}
+
+// record writes sq to the destinations of the log.
+func (l *SlowQueryLog) record(sq *SlowQuery) {
+	b, err := json.Marshal(sq)
+	if err != nil {
+		l.Logger.Info("Unable to encode slow query", zap.Error(err))
+		return
+	}
+
+	l.mu.Lock()
+	file := l.file
+	if file != nil {
+		if _, err := file.Write(append(b, '\n')); err != nil {
+			l.Logger.Info("Unable to write slow query", zap.Error(err))
+		}
+	}
+	l.mu.Unlock()
+
+	monitored := l.Monitor != nil && l.Monitor.Enabled()
+	if monitored {
+		samples, _ := json.Marshal(sq.Samples)
+		tags := make(map[string]string)
+		if sq.Database != "" {
+			tags["db"] = sq.Database
+		}
+		if sq.User != "" {
+			tags["user"] = sq.User
+		}
+		fields := map[string]interface{}{
+			"qid":        int64(sq.QueryID),
+			"statement":  sq.Statement,
+			"durationNs": int64(sq.Duration),
+			"seriesN":    int64(sq.SeriesN),
+			"pointN":     int64(sq.PointN),
+			"samples":    string(samples),
+		}
+		p, err := models.NewPoint("slow_query", models.NewTags(tags), fields, sq.Time)
+		if err != nil {
+			l.Logger.Info("Unable to record slow query", zap.Error(err))
+		} else {
+			l.Monitor.WritePoints(models.Points{p})
+		}
+	}
+
+	if file == nil && !monitored {
+		l.Logger.Warn("Slow query",
+			zap.Uint64("qid", sq.QueryID),
+			zap.String("statement", sq.Statement),
+			zap.Duration("duration", sq.Duration),
+			zap.Int("series", sq.SeriesN),
+			zap.Int("points", sq.PointN))
+	}
+}
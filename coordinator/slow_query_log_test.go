@@ -0,0 +1,130 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxdb/toml"
+)
+
+// Ensure slow statements are recorded to the file and the monitor, with
+// samples of their iterator stats.
+func TestSlowQueryLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slow-query-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "slow.log")
+
+	c := NewConfig()
+	if l, err := NewSlowQueryLog(c); err != nil {
+		t.Fatal(err)
+	} else if l != nil {
+		t.Fatal("expected disabled log")
+	}
+
+	c.SlowQueryThreshold = toml.Duration(20 * time.Millisecond)
+	c.SlowQuerySampleInterval = toml.Duration(time.Millisecond)
+	c.SlowQueryLogPath = path
+	l, err := NewSlowQueryLog(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	m := &slowQueryMonitor{}
+	l.Monitor = m
+
+	ectx := &query.ExecutionContext{QueryID: 3}
+	ectx.Database = "db0"
+
+	// Fast statements aren't recorded.
+	itr := &statsIterator{}
+	l.track(query.Iterators{itr}).finish("SELECT * FROM fast", ectx)
+
+	tr := l.track(query.Iterators{itr})
+	for i := 1; i <= 10; i++ {
+		itr.setStats(query.IteratorStats{SeriesN: 1, PointN: i})
+		time.Sleep(5 * time.Millisecond)
+	}
+	tr.finish("SELECT * FROM slow", ectx)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sq SlowQuery
+	if err := json.Unmarshal(b, &sq); err != nil {
+		t.Fatal(err)
+	}
+	if sq.QueryID != 3 || sq.Database != "db0" || sq.Statement != "SELECT * FROM slow" {
+		t.Fatalf("unexpected slow query: %+v", sq)
+	} else if sq.Duration < 20*time.Millisecond || sq.SeriesN != 1 || sq.PointN != 10 {
+		t.Fatalf("unexpected stats: %+v", sq)
+	} else if len(sq.Samples) == 0 || len(sq.Samples) >= maxSlowQuerySamples {
+		t.Fatalf("unexpected number of samples: %d", len(sq.Samples))
+	}
+	for i := 1; i < len(sq.Samples); i++ {
+		if sq.Samples[i].Elapsed <= sq.Samples[i-1].Elapsed || sq.Samples[i].PointN < sq.Samples[i-1].PointN {
+			t.Fatalf("unexpected samples: %+v", sq.Samples)
+		}
+	}
+
+	if len(m.points) != 1 {
+		t.Fatalf("unexpected number of points: %d", len(m.points))
+	} else if p := m.points[0]; string(p.Name()) != "slow_query" || p.Tags().GetString("db") != "db0" {
+		t.Fatalf("unexpected point: %s", p)
+	}
+}
+
+// Ensure the samples of long statements are thinned out.
+func TestSlowQueryLog_Samples(t *testing.T) {
+	l := &SlowQueryLog{Threshold: time.Hour, SampleInterval: time.Microsecond}
+	tr := l.track(query.Iterators{&statsIterator{}})
+	time.Sleep(50 * time.Millisecond)
+	close(tr.done)
+	tr.wg.Wait()
+
+	if n := len(tr.samples); n == 0 || n >= maxSlowQuerySamples {
+		t.Fatalf("unexpected number of samples: %d", n)
+	}
+}
+
+// statsIterator is an iterator of stats only.
+type statsIterator struct {
+	mu    sync.Mutex
+	stats query.IteratorStats
+}
+
+func (itr *statsIterator) setStats(stats query.IteratorStats) {
+	itr.mu.Lock()
+	itr.stats = stats
+	itr.mu.Unlock()
+}
+
+func (itr *statsIterator) Stats() query.IteratorStats {
+	itr.mu.Lock()
+	defer itr.mu.Unlock()
+	return itr.stats
+}
+
+func (itr *statsIterator) Close() error { return nil }
+
+// slowQueryMonitor records the points written to it.
+type slowQueryMonitor struct {
+	points models.Points
+}
+
+func (m *slowQueryMonitor) Enabled() bool { return true }
+
+func (m *slowQueryMonitor) WritePoints(points models.Points) error {
+	m.points = append(m.points, points...)
+	return nil
+}
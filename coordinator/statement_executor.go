@@ -53,13 +53,43 @@ type StatementExecutor struct {
 	MaxSelectPointN   int
 	MaxSelectSeriesN  int
 	MaxSelectBucketsN int
+
+	// SELECT INTO statements are executed in time slices of this duration,
+	// if set, and write at most this number of points per second, if set.
+	IntoSliceDuration time.Duration
+	IntoWriteRate     int
+
+	// Caches the results of SELECT statements, if set.
+	ResultCache *ResultCache
+
+	// Maximum duration before a SELECT statement to seed its fill from.
+	FillLookback time.Duration
+
+	// Records the SELECT statements slower than its threshold, if set.
+	SlowQueryLog *SlowQueryLog
 }
 
 // ExecuteStatement executes the given statement with the given execution context.
 func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement, ctx query.ExecutionContext) error {
+	// Statements deleting data drop all cached results once executed.
+	if e.ResultCache != nil {
+		switch stmt.(type) {
+		case *influxql.DeleteSeriesStatement, *influxql.DropDatabaseStatement, *influxql.DropMeasurementStatement,
+			*influxql.DropRetentionPolicyStatement, *influxql.DropSeriesStatement, *influxql.DropShardStatement:
+			defer e.ResultCache.Clear()
+		}
+	}
+
 	// Select statements are handled separately so that they can be streamed.
 	if stmt, ok := stmt.(*influxql.SelectStatement); ok {
-		return e.executeSelectStatement(context.Background(), stmt, &ctx)
+		sctx := context.Background()
+		if ctx.Span != nil {
+			span := ctx.Span.StartSpan("select")
+			span.SetLabels("statement", stmt.String())
+			defer span.Finish()
+			sctx = tracing.NewContextWithSpan(sctx, span)
+		}
+		return e.executeSelectStatement(sctx, stmt, &ctx)
 	}
 
 	var rows models.Rows
@@ -179,6 +209,9 @@ func (e *StatementExecutor) ExecuteStatement(stmt influxql.Statement, ctx query.
 	case *influxql.ShowRetentionPoliciesStatement:
 		rows, err = e.executeShowRetentionPoliciesStatement(stmt)
 	case *influxql.ShowSeriesCardinalityStatement:
+		if query.IsSeriesCardinalityByMeasurement(stmt) {
+			return e.executeShowSeriesCardinalityByMeasurement(stmt, &ctx)
+		}
 		rows, err = e.executeShowSeriesCardinalityStatement(stmt)
 	case *influxql.ShowShardsStatement:
 		rows, err = e.executeShowShardsStatement(stmt)
@@ -323,8 +356,9 @@ func (e *StatementExecutor) executeDeleteSeriesStatement(stmt *influxql.DeleteSe
 	// Convert "now()" to current time.
 	stmt.Condition = influxql.Reduce(stmt.Condition, &influxql.NowValuer{Now: time.Now().UTC()})
 
-	// Locally delete the series.
-	return e.TSDBStore.DeleteSeries(database, stmt.Sources, stmt.Condition)
+	// Locally delete the points, including those matched by conditions on
+	// fields.
+	return e.TSDBStore.DeletePoints(database, stmt.Sources, stmt.Condition)
 }
 
 func (e *StatementExecutor) executeDropContinuousQueryStatement(q *influxql.DropContinuousQueryStatement) error {
@@ -409,11 +443,12 @@ func (e *StatementExecutor) executeDropUserStatement(q *influxql.DropUserStateme
 
 func (e *StatementExecutor) executeExplainStatement(q *influxql.ExplainStatement, ectx *query.ExecutionContext) (models.Rows, error) {
 	opt := query.SelectOptions{
-		InterruptCh: ectx.InterruptCh,
-		NodeID:      ectx.ExecutionOptions.NodeID,
-		MaxSeriesN:  e.MaxSelectSeriesN,
-		MaxBucketsN: e.MaxSelectBucketsN,
-		Authorizer:  ectx.Authorizer,
+		InterruptCh:  ectx.InterruptCh,
+		NodeID:       ectx.ExecutionOptions.NodeID,
+		MaxSeriesN:   e.MaxSelectSeriesN,
+		MaxBucketsN:  e.MaxSelectBucketsN,
+		FillLookback: e.FillLookback,
+		Authorizer:   ectx.Authorizer,
 	}
 
 	// Prepare the query for execution, but do not actually execute it.
@@ -545,6 +580,27 @@ func (e *StatementExecutor) executeSetPasswordUserStatement(q *influxql.SetPassw
 }
 
 func (e *StatementExecutor) executeSelectStatement(ctx context.Context, stmt *influxql.SelectStatement, ectx *query.ExecutionContext) error {
+	// Points are written back into the system for INTO statements.
+	if stmt.Target != nil {
+		return e.executeSelectIntoStatement(ctx, stmt, ectx)
+	}
+
+	// Send the cached results of the statement, if any, and record its
+	// results otherwise.
+	var recorder *resultCacheRecorder
+	if e.ResultCache != nil {
+		recorder = e.ResultCache.record(stmt, ectx, e.FillLookback)
+		if recorder != nil && recorder.cached != nil {
+			for _, result := range recorder.cached {
+				result.StatementID = ectx.StatementID
+				if err := ectx.Send(result); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
 	itrs, columns, err := e.createIterators(ctx, stmt, ectx)
 	if err != nil {
 		return err
@@ -560,15 +616,13 @@ func (e *StatementExecutor) executeSelectStatement(ctx context.Context, stmt *in
 	em.EmitName = stmt.EmitName
 	defer em.Close()
 
-	// Emit rows to the results channel.
-	var writeN int64
-	var emitted bool
-
-	var pointsWriter *BufferedPointsWriter
-	if stmt.Target != nil {
-		pointsWriter = NewBufferedPointsWriter(e.PointsWriter, stmt.Target.Measurement.Database, stmt.Target.Measurement.RetentionPolicy, 10000)
+	// Record the statement if it is slow, before the iterators are closed.
+	if e.SlowQueryLog != nil {
+		defer e.SlowQueryLog.track(itrs).finish(stmt.String(), ectx)
 	}
 
+	// Emit rows to the results channel.
+	var emitted bool
 	for {
 		row, partial, err := em.Emit()
 		if err != nil {
@@ -583,20 +637,12 @@ func (e *StatementExecutor) executeSelectStatement(ctx context.Context, stmt *in
 			break
 		}
 
-		// Write points back into system for INTO statements.
-		if stmt.Target != nil {
-			if err := e.writeInto(pointsWriter, stmt, row); err != nil {
-				return err
-			}
-			writeN += int64(len(row.Values))
-			continue
-		}
-
 		result := &query.Result{
 			StatementID: ectx.StatementID,
 			Series:      []*models.Row{row},
 			Partial:     partial,
 		}
+		recorder.add(result)
 
 		// Send results or exit if closing.
 		if err := ectx.Send(result); err != nil {
@@ -606,46 +652,46 @@ func (e *StatementExecutor) executeSelectStatement(ctx context.Context, stmt *in
 		emitted = true
 	}
 
-	// Flush remaining points and emit write count if an INTO statement.
-	if stmt.Target != nil {
-		if err := pointsWriter.Flush(); err != nil {
-			return err
-		}
-
-		var messages []*query.Message
-		if ectx.ReadOnly {
-			messages = append(messages, query.ReadOnlyWarning(stmt.String()))
-		}
-
-		return ectx.Send(&query.Result{
-			StatementID: ectx.StatementID,
-			Messages:    messages,
-			Series: []*models.Row{{
-				Name:    "result",
-				Columns: []string{"time", "written"},
-				Values:  [][]interface{}{{time.Unix(0, 0).UTC(), writeN}},
-			}},
-		})
-	}
-
 	// Always emit at least one result.
 	if !emitted {
-		return ectx.Send(&query.Result{
+		result := &query.Result{
 			StatementID: ectx.StatementID,
 			Series:      make([]*models.Row, 0),
-		})
+		}
+		recorder.add(result)
+		if err := ectx.Send(result); err != nil {
+			return err
+		}
 	}
 
+	recorder.commit()
 	return nil
 }
 
 func (e *StatementExecutor) createIterators(ctx context.Context, stmt *influxql.SelectStatement, ectx *query.ExecutionContext) ([]query.Iterator, []string, error) {
+	maxPointN, maxSeriesN, maxBucketsN := e.MaxSelectPointN, e.MaxSelectSeriesN, e.MaxSelectBucketsN
+
+	// The limits of the user executing the statement override the limits of
+	// the node.
+	if u, ok := ectx.Authorizer.(*meta.UserInfo); ok {
+		if u.Limits.MaxSelectPointN > 0 {
+			maxPointN = u.Limits.MaxSelectPointN
+		}
+		if u.Limits.MaxSelectSeriesN > 0 {
+			maxSeriesN = u.Limits.MaxSelectSeriesN
+		}
+		if u.Limits.MaxSelectBucketsN > 0 {
+			maxBucketsN = u.Limits.MaxSelectBucketsN
+		}
+	}
+
 	opt := query.SelectOptions{
-		InterruptCh: ectx.InterruptCh,
-		NodeID:      ectx.ExecutionOptions.NodeID,
-		MaxSeriesN:  e.MaxSelectSeriesN,
-		MaxBucketsN: e.MaxSelectBucketsN,
-		Authorizer:  ectx.Authorizer,
+		InterruptCh:  ectx.InterruptCh,
+		NodeID:       ectx.ExecutionOptions.NodeID,
+		MaxSeriesN:   maxSeriesN,
+		MaxBucketsN:  maxBucketsN,
+		FillLookback: e.FillLookback,
+		Authorizer:   ectx.Authorizer,
 	}
 
 	// Create a set of iterators from a selection.
@@ -654,8 +700,8 @@ func (e *StatementExecutor) createIterators(ctx context.Context, stmt *influxql.
 		return nil, nil, err
 	}
 
-	if e.MaxSelectPointN > 0 {
-		monitor := query.PointLimitMonitor(itrs, query.DefaultStatsInterval, e.MaxSelectPointN)
+	if maxPointN > 0 {
+		monitor := query.PointLimitMonitor(itrs, query.DefaultStatsInterval, maxPointN)
 		ectx.Query.Monitor(monitor)
 	}
 	return itrs, columns, nil
@@ -865,6 +911,69 @@ func (e *StatementExecutor) executeShowSeriesCardinalityStatement(stmt *influxql
 	}}, nil
 }
 
+// errCardinalityLimit stops counting the series cardinality of measurements
+// once the limit of the statement is reached.
+var errCardinalityLimit = errors.New("cardinality limit reached")
+
+// executeShowSeriesCardinalityByMeasurement streams the exact series
+// cardinality of each measurement, with a result per measurement. The first
+// value of a measurement is its number of series, with a null tag key, and the
+// following values the number of its series having each tag key.
+func (e *StatementExecutor) executeShowSeriesCardinalityByMeasurement(stmt *influxql.ShowSeriesCardinalityStatement, ctx *query.ExecutionContext) error {
+	if stmt.Database == "" {
+		return ErrDatabaseNameRequired
+	}
+
+	// The limit and offset apply to measurements.
+	emitted := false
+	var i int
+	err := e.TSDBStore.SeriesCardinalityByMeasurement(ctx.Authorizer, stmt.Database, stmt.Condition, func(c tsdb.MeasurementCardinality) error {
+		if i++; i <= stmt.Offset {
+			return nil
+		}
+
+		row := &models.Row{
+			Name:    c.Measurement,
+			Columns: []string{"tagKey", "count"},
+			Values:  make([][]interface{}, 0, len(c.TagKeys)+1),
+		}
+		row.Values = append(row.Values, []interface{}{nil, c.Series})
+		for j, key := range c.TagKeys {
+			row.Values = append(row.Values, []interface{}{key, c.TagKeySeries[j]})
+		}
+
+		if err := ctx.Send(&query.Result{
+			StatementID: ctx.StatementID,
+			Series:      []*models.Row{row},
+		}); err != nil {
+			return err
+		}
+		emitted = true
+
+		if stmt.Limit > 0 && i >= stmt.Offset+stmt.Limit {
+			return errCardinalityLimit
+		}
+		return nil
+	})
+	if err != nil && err != errCardinalityLimit {
+		if emitted {
+			return err
+		}
+		return ctx.Send(&query.Result{
+			StatementID: ctx.StatementID,
+			Err:         err,
+		})
+	}
+
+	// Ensure at least one result is emitted.
+	if !emitted {
+		return ctx.Send(&query.Result{
+			StatementID: ctx.StatementID,
+		})
+	}
+	return nil
+}
+
 func (e *StatementExecutor) executeShowShardGroupsStatement(stmt *influxql.ShowShardGroupsStatement) (models.Rows, error) {
 	dis := e.MetaClient.Databases()
 
@@ -948,9 +1057,11 @@ func (e *StatementExecutor) executeShowTagKeys(q *influxql.ShowTagKeysStatement,
 	}
 
 	// Determine appropriate time range. If one or fewer time boundaries provided
-	// then min/max possible time should be used instead.
-	valuer := &influxql.NowValuer{Now: time.Now()}
-	cond, timeRange, err := influxql.ConditionExpr(q.Condition, valuer)
+	// then min/max possible time should be used instead. The store only returns
+	// the tag keys of the series with values in the time range, so the
+	// condition is passed with now() converted to the current time.
+	cond := influxql.Reduce(q.Condition, &influxql.NowValuer{Now: time.Now()})
+	_, timeRange, err := influxql.ConditionExpr(cond, nil)
 	if err != nil {
 		return err
 	}
@@ -1039,9 +1150,11 @@ func (e *StatementExecutor) executeShowTagValues(q *influxql.ShowTagValuesStatem
 	}
 
 	// Determine appropriate time range. If one or fewer time boundaries provided
-	// then min/max possible time should be used instead.
-	valuer := &influxql.NowValuer{Now: time.Now()}
-	cond, timeRange, err := influxql.ConditionExpr(q.Condition, valuer)
+	// then min/max possible time should be used instead. The store only returns
+	// the tag values of the series with values in the time range, so the
+	// condition is passed with now() converted to the current time.
+	cond := influxql.Reduce(q.Condition, &influxql.NowValuer{Now: time.Now()})
+	_, timeRange, err := influxql.ConditionExpr(cond, nil)
 	if err != nil {
 		return err
 	}
@@ -1377,6 +1490,7 @@ type TSDBStore interface {
 	DeleteMeasurement(database, name string) error
 	DeleteRetentionPolicy(database, name string) error
 	DeleteSeries(database string, sources []influxql.Source, condition influxql.Expr) error
+	DeletePoints(database string, sources []influxql.Source, condition influxql.Expr) error
 	DeleteShard(id uint64) error
 
 	MeasurementNames(auth query.Authorizer, database string, cond influxql.Expr) ([][]byte, error)
@@ -1384,6 +1498,7 @@ type TSDBStore interface {
 	TagValues(auth query.Authorizer, shardIDs []uint64, cond influxql.Expr) ([]tsdb.TagValues, error)
 
 	SeriesCardinality(database string) (int64, error)
+	SeriesCardinalityByMeasurement(auth query.Authorizer, database string, cond influxql.Expr, fn func(c tsdb.MeasurementCardinality) error) error
 	MeasurementsCardinality(database string) (int64, error)
 }
 
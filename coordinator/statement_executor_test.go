@@ -129,6 +129,279 @@ func TestQueryExecutor_ExecuteQuery_MaxSelectBucketsN(t *testing.T) {
 	}
 }
 
+// Ensure the limits of the user executing a statement override the limits of
+// the node.
+func TestQueryExecutor_ExecuteQuery_UserLimits(t *testing.T) {
+	e := DefaultQueryExecutor()
+	e.StatementExecutor.MaxSelectBucketsN = 10
+
+	e.MetaClient.ShardGroupsByTimeRangeFn = func(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error) {
+		return []meta.ShardGroupInfo{
+			{ID: 1, Shards: []meta.ShardInfo{
+				{ID: 100, Owners: []meta.ShardOwner{{NodeID: 0}}},
+			}},
+		}, nil
+	}
+
+	e.TSDBStore.ShardGroupFn = func(ids []uint64) tsdb.ShardGroup {
+		var sh MockShard
+		sh.CreateIteratorFn = func(_ context.Context, _ *influxql.Measurement, _ query.IteratorOptions) (query.Iterator, error) {
+			return &FloatIterator{
+				Points: []query.FloatPoint{{Name: "cpu", Time: int64(0 * time.Second), Aux: []interface{}{float64(100)}}},
+			}, nil
+		}
+		sh.FieldDimensionsFn = func(measurements []string) (fields map[string]influxql.DataType, dimensions map[string]struct{}, err error) {
+			return map[string]influxql.DataType{"value": influxql.Float}, nil, nil
+		}
+		return &sh
+	}
+
+	user := &meta.UserInfo{Name: "dashboard", Admin: true, Limits: meta.UserLimits{MaxSelectBucketsN: 3}}
+	results := e.QueryExecutor.ExecuteQuery(MustParseQuery(`SELECT count(value) FROM cpu WHERE time >= '2000-01-01T00:00:05Z' AND time < '2000-01-01T00:00:35Z' GROUP BY time(10s)`), query.ExecutionOptions{
+		Database:   "db0",
+		Authorizer: user,
+	}, make(chan struct{}))
+	if a := ReadAllResults(results); !reflect.DeepEqual(a, []*query.Result{
+		{
+			StatementID: 0,
+			Err:         errors.New("max-select-buckets limit exceeded: (4/3)"),
+		},
+	}) {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure SELECT INTO statements are executed and written in time slices.
+func TestQueryExecutor_ExecuteQuery_SelectInto_Slices(t *testing.T) {
+	e := DefaultQueryExecutor()
+	e.StatementExecutor.IntoSliceDuration = 10 * time.Second
+
+	e.MetaClient.ShardGroupsByTimeRangeFn = func(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error) {
+		return []meta.ShardGroupInfo{
+			{ID: 1, Shards: []meta.ShardInfo{
+				{ID: 100, Owners: []meta.ShardOwner{{NodeID: 0}}},
+			}},
+		}, nil
+	}
+
+	// The shard only returns the points within the time range of the slice.
+	var ranges [][2]int64
+	e.TSDBStore.ShardGroupFn = func(ids []uint64) tsdb.ShardGroup {
+		var sh MockShard
+		sh.CreateIteratorFn = func(_ context.Context, _ *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+			ranges = append(ranges, [2]int64{opt.StartTime, opt.EndTime})
+
+			var points []query.FloatPoint
+			for _, ts := range []time.Duration{0, 5 * time.Second, 10 * time.Second, 15 * time.Second, 25 * time.Second} {
+				if int64(ts) >= opt.StartTime && int64(ts) <= opt.EndTime {
+					points = append(points, query.FloatPoint{Name: "cpu", Time: int64(ts), Aux: []interface{}{float64(ts / time.Second)}})
+				}
+			}
+			return &FloatIterator{Points: points}, nil
+		}
+		sh.FieldDimensionsFn = func(measurements []string) (fields map[string]influxql.DataType, dimensions map[string]struct{}, err error) {
+			return map[string]influxql.DataType{"value": influxql.Float}, nil, nil
+		}
+		return &sh
+	}
+
+	var writes []int
+	e.StatementExecutor.PointsWriter = &fakePointsWriter{
+		WritePointsIntoFn: func(req *coordinator.IntoWriteRequest) error {
+			if req.Database != "db0" || req.RetentionPolicy != "rp0" {
+				t.Fatalf("unexpected target: %s.%s", req.Database, req.RetentionPolicy)
+			}
+			writes = append(writes, len(req.Points))
+			return nil
+		},
+	}
+
+	if a := ReadAllResults(e.ExecuteQuery(`SELECT value INTO db0.rp0.out FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z'`, "db0", 0)); !reflect.DeepEqual(a, []*query.Result{
+		{
+			StatementID: 0,
+			Series: []*models.Row{{
+				Name:    "result",
+				Columns: []string{"time", "written"},
+				Values:  [][]interface{}{{time.Unix(0, 0).UTC(), int64(5)}},
+			}},
+		},
+	}) {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+
+	if exp := [][2]int64{
+		{0, int64(10*time.Second) - 1},
+		{int64(10 * time.Second), int64(20*time.Second) - 1},
+		{int64(20 * time.Second), int64(30*time.Second) - 1},
+	}; !reflect.DeepEqual(ranges, exp) {
+		t.Fatalf("unexpected time ranges: %v", ranges)
+	}
+	if exp := []int{2, 2, 1}; !reflect.DeepEqual(writes, exp) {
+		t.Fatalf("unexpected writes: %v", writes)
+	}
+}
+
+// Ensure a failed SELECT INTO statement reports the time it can be resumed from.
+func TestQueryExecutor_ExecuteQuery_SelectInto_Resume(t *testing.T) {
+	e := DefaultQueryExecutor()
+	e.StatementExecutor.IntoSliceDuration = 10 * time.Second
+
+	e.MetaClient.ShardGroupsByTimeRangeFn = func(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error) {
+		return []meta.ShardGroupInfo{
+			{ID: 1, Shards: []meta.ShardInfo{
+				{ID: 100, Owners: []meta.ShardOwner{{NodeID: 0}}},
+			}},
+		}, nil
+	}
+
+	e.TSDBStore.ShardGroupFn = func(ids []uint64) tsdb.ShardGroup {
+		var sh MockShard
+		sh.CreateIteratorFn = func(_ context.Context, _ *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+			return &FloatIterator{Points: []query.FloatPoint{
+				{Name: "cpu", Time: opt.StartTime, Aux: []interface{}{float64(100)}},
+			}}, nil
+		}
+		sh.FieldDimensionsFn = func(measurements []string) (fields map[string]influxql.DataType, dimensions map[string]struct{}, err error) {
+			return map[string]influxql.DataType{"value": influxql.Float}, nil, nil
+		}
+		return &sh
+	}
+
+	// Fail to write the points of the second slice.
+	var n int
+	e.StatementExecutor.PointsWriter = &fakePointsWriter{
+		WritePointsIntoFn: func(req *coordinator.IntoWriteRequest) error {
+			if n++; n == 2 {
+				return errors.New("write failed")
+			}
+			return nil
+		},
+	}
+
+	if a := ReadAllResults(e.ExecuteQuery(`SELECT value INTO db0.rp0.out FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:30Z'`, "db0", 0)); !reflect.DeepEqual(a, []*query.Result{
+		{
+			StatementID: 0,
+			Err:         errors.New("write failed (points before 1970-01-01T00:00:10Z were written, resume the statement from this time)"),
+		},
+	}) {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+}
+
+// Ensure the results of SELECT statements are cached until points are
+// written to their time range.
+func TestQueryExecutor_ExecuteQuery_ResultCache(t *testing.T) {
+	e := DefaultQueryExecutor()
+	c := coordinator.NewConfig()
+	c.ResultCacheMaxSize = 1 << 20
+	cache := coordinator.NewResultCache(c)
+	e.StatementExecutor.ResultCache = cache
+
+	e.MetaClient.ShardGroupsByTimeRangeFn = func(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error) {
+		return []meta.ShardGroupInfo{
+			{ID: 1, Shards: []meta.ShardInfo{
+				{ID: 100, Owners: []meta.ShardOwner{{NodeID: 0}}},
+			}},
+		}, nil
+	}
+
+	var n int
+	e.TSDBStore.ShardGroupFn = func(ids []uint64) tsdb.ShardGroup {
+		var sh MockShard
+		sh.CreateIteratorFn = func(_ context.Context, _ *influxql.Measurement, _ query.IteratorOptions) (query.Iterator, error) {
+			n++
+			return &FloatIterator{Points: []query.FloatPoint{
+				{Name: "cpu", Time: int64(0 * time.Second), Aux: []interface{}{float64(100)}},
+				{Name: "cpu", Time: int64(1 * time.Second), Aux: []interface{}{float64(200)}},
+			}}, nil
+		}
+		sh.FieldDimensionsFn = func(measurements []string) (fields map[string]influxql.DataType, dimensions map[string]struct{}, err error) {
+			return map[string]influxql.DataType{"value": influxql.Float}, nil, nil
+		}
+		return &sh
+	}
+
+	exp := []*query.Result{
+		{
+			StatementID: 0,
+			Series: []*models.Row{{
+				Name:    "cpu",
+				Columns: []string{"time", "value"},
+				Values: [][]interface{}{
+					{time.Unix(0, 0).UTC(), float64(100)},
+					{time.Unix(1, 0).UTC(), float64(200)},
+				},
+			}},
+		},
+	}
+
+	const q = `SELECT value FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:01:00Z'`
+	for i, tt := range []struct {
+		invalidate func()
+		exp        int
+	}{
+		{exp: 1},
+		{exp: 1},
+		{invalidate: func() { cache.Invalidate("db0", "rp0", int64(time.Hour), int64(2*time.Hour)) }, exp: 1},
+		{invalidate: func() { cache.Invalidate("db0", "rp0", int64(30*time.Second), int64(30*time.Second)) }, exp: 2},
+		{exp: 2},
+	} {
+		if tt.invalidate != nil {
+			tt.invalidate()
+		}
+		if a := ReadAllResults(e.ExecuteQuery(q, "db0", 0)); !reflect.DeepEqual(a, exp) {
+			t.Fatalf("%d. unexpected results: %s", i, spew.Sdump(a))
+		} else if n != tt.exp {
+			t.Fatalf("%d. unexpected number of executions: got %d, exp %d", i, n, tt.exp)
+		}
+	}
+
+	stats := cache.Statistics(nil)[0].Values
+	if stats["hits"] != int64(3) || stats["misses"] != int64(2) || stats["invalidations"] != int64(1) {
+		t.Fatalf("unexpected statistics: %v", stats)
+	}
+}
+
+func TestQueryExecutor_ShowSeriesCardinalityByMeasurement(t *testing.T) {
+	e := DefaultQueryExecutor()
+	e.TSDBStore.SeriesCardinalityByMeasurementFn = func(auth query.Authorizer, database string, cond influxql.Expr, fn func(c tsdb.MeasurementCardinality) error) error {
+		if database != "db0" {
+			t.Fatalf("unexpected database: %s", database)
+		} else if got, exp := cond.String(), `_name =~ /^c|m/`; got != exp {
+			t.Fatalf("unexpected condition: got %s, exp %s", got, exp)
+		}
+		for _, c := range []tsdb.MeasurementCardinality{
+			{Measurement: "cpu", Series: 3, TagKeys: []string{"host", "region"}, TagKeySeries: []int64{3, 2}},
+			{Measurement: "mem", Series: 1, TagKeys: []string{"host"}, TagKeySeries: []int64{1}},
+			{Measurement: "mongo", Series: 2},
+		} {
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+		t.Fatal("expected counting to stop at the limit")
+		return nil
+	}
+
+	exp := []*query.Result{
+		{
+			StatementID: 0,
+			Series: []*models.Row{{
+				Name:    "mem",
+				Columns: []string{"tagKey", "count"},
+				Values: [][]interface{}{
+					{nil, int64(1)},
+					{"host", int64(1)},
+				},
+			}},
+		},
+	}
+	const q = `SHOW SERIES EXACT CARDINALITY FROM /^c|m/ GROUP BY _name LIMIT 1 OFFSET 1`
+	if a := ReadAllResults(e.ExecuteQuery(q, "db0", 0)); !reflect.DeepEqual(a, exp) {
+		t.Fatalf("unexpected results: %s", spew.Sdump(a))
+	}
+}
+
 func TestStatementExecutor_NormalizeDropSeries(t *testing.T) {
 	q, err := influxql.ParseQuery("DROP SERIES FROM cpu")
 	if err != nil {
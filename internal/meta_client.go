@@ -42,6 +42,7 @@ type MetaClientMock struct {
 	SetAdminPrivilegeFn      func(username string, admin bool) error
 	SetDataFn                func(*meta.Data) error
 	SetPrivilegeFn           func(username, database string, p influxql.Privilege) error
+	SetUserLimitsFn          func(username string, limits meta.UserLimits) error
 	ShardGroupsByTimeRangeFn func(database, policy string, min, max time.Time) (a []meta.ShardGroupInfo, err error)
 	ShardOwnerFn             func(shardID uint64) (database, policy string, sgi *meta.ShardGroupInfo)
 	TruncateShardGroupsFn    func(t time.Time) error
@@ -149,6 +150,10 @@ func (c *MetaClientMock) UpdateRetentionPolicy(database, name string, rpu *meta.
 	return c.UpdateRetentionPolicyFn(database, name, rpu, makeDefault)
 }
 
+func (c *MetaClientMock) SetUserLimits(username string, limits meta.UserLimits) error {
+	return c.SetUserLimitsFn(username, limits)
+}
+
 func (c *MetaClientMock) UpdateUser(name, password string) error {
 	return c.UpdateUserFn(name, password)
 }
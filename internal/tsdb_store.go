@@ -13,40 +13,43 @@ import (
 
 // TSDBStoreMock is a mockable implementation of tsdb.Store.
 type TSDBStoreMock struct {
-	BackupShardFn             func(id uint64, since time.Time, w io.Writer) error
-	BackupSeriesFileFn        func(database string, w io.Writer) error
-	ExportShardFn             func(id uint64, ExportStart time.Time, ExportEnd time.Time, w io.Writer) error
-	CloseFn                   func() error
-	CreateShardFn             func(database, policy string, shardID uint64, enabled bool) error
-	CreateShardSnapshotFn     func(id uint64) (string, error)
-	DatabasesFn               func() []string
-	DeleteDatabaseFn          func(name string) error
-	DeleteMeasurementFn       func(database, name string) error
-	DeleteRetentionPolicyFn   func(database, name string) error
-	DeleteSeriesFn            func(database string, sources []influxql.Source, condition influxql.Expr) error
-	DeleteShardFn             func(id uint64) error
-	DiskSizeFn                func() (int64, error)
-	ExpandSourcesFn           func(sources influxql.Sources) (influxql.Sources, error)
-	ImportShardFn             func(id uint64, r io.Reader) error
-	MeasurementSeriesCountsFn func(database string) (measuments int, series int)
-	MeasurementsCardinalityFn func(database string) (int64, error)
-	MeasurementNamesFn        func(auth query.Authorizer, database string, cond influxql.Expr) ([][]byte, error)
-	OpenFn                    func() error
-	PathFn                    func() string
-	RestoreShardFn            func(id uint64, r io.Reader) error
-	SeriesCardinalityFn       func(database string) (int64, error)
-	SetShardEnabledFn         func(shardID uint64, enabled bool) error
-	ShardFn                   func(id uint64) *tsdb.Shard
-	ShardGroupFn              func(ids []uint64) tsdb.ShardGroup
-	ShardIDsFn                func() []uint64
-	ShardNFn                  func() int
-	ShardRelativePathFn       func(id uint64) (string, error)
-	ShardsFn                  func(ids []uint64) []*tsdb.Shard
-	StatisticsFn              func(tags map[string]string) []models.Statistic
-	TagKeysFn                 func(auth query.Authorizer, shardIDs []uint64, cond influxql.Expr) ([]tsdb.TagKeys, error)
-	TagValuesFn               func(auth query.Authorizer, shardIDs []uint64, cond influxql.Expr) ([]tsdb.TagValues, error)
-	WithLoggerFn              func(log *zap.Logger)
-	WriteToShardFn            func(shardID uint64, points []models.Point) error
+	BackupShardFn                    func(id uint64, since time.Time, w io.Writer) error
+	BackupSeriesFileFn               func(database string, w io.Writer) error
+	ExportShardFn                    func(id uint64, ExportStart time.Time, ExportEnd time.Time, w io.Writer) error
+	CloseFn                          func() error
+	CreateShardFn                    func(database, policy string, shardID uint64, enabled bool) error
+	CreateShardSnapshotFn            func(id uint64) (string, error)
+	DatabasesFn                      func() []string
+	DeleteDatabaseFn                 func(name string) error
+	DeleteMeasurementFn              func(database, name string) error
+	DeleteRetentionPolicyFn          func(database, name string) error
+	DeleteSeriesFn                   func(database string, sources []influxql.Source, condition influxql.Expr) error
+	DeletePointsFn                   func(database string, sources []influxql.Source, condition influxql.Expr) error
+	DeleteShardFn                    func(id uint64) error
+	DiskSizeFn                       func() (int64, error)
+	ExpandSourcesFn                  func(sources influxql.Sources) (influxql.Sources, error)
+	ImportShardFn                    func(id uint64, r io.Reader) error
+	MeasurementSeriesCountsFn        func(database string) (measuments int, series int)
+	MeasurementsCardinalityFn        func(database string) (int64, error)
+	MeasurementNamesFn               func(auth query.Authorizer, database string, cond influxql.Expr) ([][]byte, error)
+	OpenFn                           func() error
+	PathFn                           func() string
+	RestoreShardFn                   func(id uint64, r io.Reader) error
+	SeriesCardinalityFn              func(database string) (int64, error)
+	SeriesCardinalityByMeasurementFn func(auth query.Authorizer, database string, cond influxql.Expr, fn func(c tsdb.MeasurementCardinality) error) error
+	SetShardEnabledFn                func(shardID uint64, enabled bool) error
+	ShardFn                          func(id uint64) *tsdb.Shard
+	ShardGroupFn                     func(ids []uint64) tsdb.ShardGroup
+	ShardIDsFn                       func() []uint64
+	ShardNFn                         func() int
+	ShardRelativePathFn              func(id uint64) (string, error)
+	ShardsFn                         func(ids []uint64) []*tsdb.Shard
+	StatisticsFn                     func(tags map[string]string) []models.Statistic
+	TagKeysFn                        func(auth query.Authorizer, shardIDs []uint64, cond influxql.Expr) ([]tsdb.TagKeys, error)
+	TagValuesFn                      func(auth query.Authorizer, shardIDs []uint64, cond influxql.Expr) ([]tsdb.TagValues, error)
+	ValidateShardPointsFn            func(shardID uint64, points []models.Point) (map[int]error, error)
+	WithLoggerFn                     func(log *zap.Logger)
+	WriteToShardFn                   func(shardID uint64, points []models.Point) error
 }
 
 func (s *TSDBStoreMock) BackupShard(id uint64, since time.Time, w io.Writer) error {
@@ -80,6 +83,9 @@ func (s *TSDBStoreMock) DeleteRetentionPolicy(database string, name string) erro
 func (s *TSDBStoreMock) DeleteSeries(database string, sources []influxql.Source, condition influxql.Expr) error {
 	return s.DeleteSeriesFn(database, sources, condition)
 }
+func (s *TSDBStoreMock) DeletePoints(database string, sources []influxql.Source, condition influxql.Expr) error {
+	return s.DeletePointsFn(database, sources, condition)
+}
 func (s *TSDBStoreMock) DeleteShard(shardID uint64) error {
 	return s.DeleteShardFn(shardID)
 }
@@ -113,6 +119,9 @@ func (s *TSDBStoreMock) RestoreShard(id uint64, r io.Reader) error {
 func (s *TSDBStoreMock) SeriesCardinality(database string) (int64, error) {
 	return s.SeriesCardinalityFn(database)
 }
+func (s *TSDBStoreMock) SeriesCardinalityByMeasurement(auth query.Authorizer, database string, cond influxql.Expr, fn func(c tsdb.MeasurementCardinality) error) error {
+	return s.SeriesCardinalityByMeasurementFn(auth, database, cond, fn)
+}
 func (s *TSDBStoreMock) SetShardEnabled(shardID uint64, enabled bool) error {
 	return s.SetShardEnabledFn(shardID, enabled)
 }
@@ -143,6 +152,9 @@ func (s *TSDBStoreMock) TagKeys(auth query.Authorizer, shardIDs []uint64, cond i
 func (s *TSDBStoreMock) TagValues(auth query.Authorizer, shardIDs []uint64, cond influxql.Expr) ([]tsdb.TagValues, error) {
 	return s.TagValuesFn(auth, shardIDs, cond)
 }
+func (s *TSDBStoreMock) ValidateShardPoints(shardID uint64, points []models.Point) (map[int]error, error) {
+	return s.ValidateShardPointsFn(shardID, points)
+}
 func (s *TSDBStoreMock) WithLogger(log *zap.Logger) {
 	s.WithLoggerFn(log)
 }
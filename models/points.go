@@ -302,15 +302,42 @@ func ParseName(buf []byte) ([]byte, error) {
 // This can have the unintended effect preventing buf from being garbage collected.
 func ParsePointsWithPrecision(buf []byte, defaultTime time.Time, precision string) ([]Point, error) {
 	points := make([]Point, 0, bytes.Count(buf, []byte{'\n'})+1)
+	var failed []string
+	ParseLines(buf, defaultTime, precision, func(_ int, block []byte, pt Point, err error) {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("unable to parse '%s': %v", string(block), err))
+		} else {
+			points = append(points, pt)
+		}
+	})
+	if len(failed) > 0 {
+		return points, fmt.Errorf("%s", strings.Join(failed, "\n"))
+	}
+	return points, nil
+
+}
+
+// ParseLines parses the lines of buf like ParsePointsWithPrecision, calling fn
+// with the number of each line, starting at 1, the line itself and either its
+// point or the error parsing it. Empty lines and comments are skipped.
+func ParseLines(buf []byte, defaultTime time.Time, precision string, fn func(line int, block []byte, pt Point, err error)) {
 	var (
-		pos    int
-		block  []byte
-		failed []string
+		pos   int
+		block []byte
+		line  = 1
 	)
 	for pos < len(buf) {
+		begin, n := pos, line
 		pos, block = scanLine(buf, pos)
 		pos++
 
+		// Lines may span newlines within quoted field values.
+		end := pos
+		if end > len(buf) {
+			end = len(buf)
+		}
+		line += bytes.Count(buf[begin:end], []byte{'\n'})
+
 		if len(block) == 0 {
 			continue
 		}
@@ -333,18 +360,8 @@ func ParsePointsWithPrecision(buf []byte, defaultTime time.Time, precision strin
 		}
 
 		pt, err := parsePoint(block[start:], defaultTime, precision)
-		if err != nil {
-			failed = append(failed, fmt.Sprintf("unable to parse '%s': %v", string(block[start:]), err))
-		} else {
-			points = append(points, pt)
-		}
-
-	}
-	if len(failed) > 0 {
-		return points, fmt.Errorf("%s", strings.Join(failed, "\n"))
+		fn(n, block[start:], pt, err)
 	}
-	return points, nil
-
 }
 
 func parsePoint(buf []byte, defaultTime time.Time, precision string) (Point, error) {
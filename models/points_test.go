@@ -1789,6 +1789,28 @@ func TestParsePointsWithPrecision(t *testing.T) {
 	}
 }
 
+func TestParseLines(t *testing.T) {
+	buf := "# comment\ncpu value=1 1\n\ncpu value=\n  mem text=\"a\nb\" 2\ncpu value=3 3"
+	var got []string
+	models.ParseLines([]byte(buf), time.Unix(0, 0), "n", func(line int, block []byte, pt models.Point, err error) {
+		if err != nil {
+			got = append(got, fmt.Sprintf("%d: %s: error", line, block))
+		} else {
+			got = append(got, fmt.Sprintf("%d: %s", line, pt.String()))
+		}
+	})
+
+	exp := []string{
+		"2: cpu value=1 1",
+		"4: cpu value=: error",
+		"5: mem text=\"a\nb\" 2",
+		"7: cpu value=3 3",
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected lines:\n%s", strings.Join(got, "\n"))
+	}
+}
+
 func TestParsePointsWithPrecisionNoTime(t *testing.T) {
 	line := `cpu,host=serverA,region=us-east value=1.0`
 	tm, _ := time.Parse(time.RFC3339Nano, "2000-01-01T12:34:56.789012345Z")
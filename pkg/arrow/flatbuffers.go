@@ -0,0 +1,169 @@
+package arrow
+
+import "encoding/binary"
+
+// builder builds a flatbuffer. Like the reference implementation, it builds
+// the buffer back to front, so objects are written before the objects
+// referring to them, and offsets are positions from the end of the buffer.
+type builder struct {
+	buf []byte
+
+	// Fields of the table being built, by slot.
+	fields []int
+	start  int
+}
+
+// offset returns the position of the last object written.
+func (b *builder) offset() int {
+	return len(b.buf)
+}
+
+// prepend writes n zero bytes at the front of the buffer, and returns them.
+func (b *builder) prepend(n int) []byte {
+	b.buf = append(make([]byte, n, n+len(b.buf)), b.buf...)
+	return b.buf[:n]
+}
+
+// align pads the buffer so it is aligned to size after writing n bytes.
+func (b *builder) align(size, n int) {
+	if pad := (size - (len(b.buf)+n)%size) % size; pad > 0 {
+		b.prepend(pad)
+	}
+}
+
+func (b *builder) prependUint8(v uint8) {
+	b.prepend(1)[0] = v
+}
+
+func (b *builder) prependUint16(v uint16) {
+	b.align(2, 2)
+	binary.LittleEndian.PutUint16(b.prepend(2), v)
+}
+
+func (b *builder) prependUint32(v uint32) {
+	b.align(4, 4)
+	binary.LittleEndian.PutUint32(b.prepend(4), v)
+}
+
+func (b *builder) prependUint64(v uint64) {
+	b.align(8, 8)
+	binary.LittleEndian.PutUint64(b.prepend(8), v)
+}
+
+// prependOffset writes the offset of the object at off, relative to itself.
+func (b *builder) prependOffset(off int) {
+	b.align(4, 4)
+	v := uint32(len(b.buf) + 4 - off)
+	binary.LittleEndian.PutUint32(b.prepend(4), v)
+}
+
+// createString writes s, and returns its offset.
+func (b *builder) createString(s string) int {
+	b.align(4, len(s)+1)
+	b.prepend(1)
+	copy(b.prepend(len(s)), s)
+	b.prependUint32(uint32(len(s)))
+	return b.offset()
+}
+
+// createOffsets writes a vector of the objects at offs, and returns its
+// offset.
+func (b *builder) createOffsets(offs []int) int {
+	b.align(4, 4*len(offs))
+	for i := len(offs) - 1; i >= 0; i-- {
+		b.prependOffset(offs[i])
+	}
+	b.prependUint32(uint32(len(offs)))
+	return b.offset()
+}
+
+// createStructs writes a vector of structs of pairs of 64-bit integers, and
+// returns its offset.
+func (b *builder) createStructs(pairs [][2]int64) int {
+	b.align(8, 16*len(pairs))
+	for i := len(pairs) - 1; i >= 0; i-- {
+		b.prependUint64(uint64(pairs[i][1]))
+		b.prependUint64(uint64(pairs[i][0]))
+	}
+	b.prependUint32(uint32(len(pairs)))
+	return b.offset()
+}
+
+// startTable starts a table. Its fields must be added before any other
+// object is written.
+func (b *builder) startTable() {
+	b.fields = b.fields[:0]
+	b.start = b.offset()
+}
+
+// slot records that the last value written is the field of slot.
+func (b *builder) slot(slot int) {
+	for len(b.fields) <= slot {
+		b.fields = append(b.fields, 0)
+	}
+	b.fields[slot] = b.offset()
+}
+
+func (b *builder) addUint8(slot int, v uint8) {
+	b.prependUint8(v)
+	b.slot(slot)
+}
+
+func (b *builder) addBool(slot int, v bool) {
+	if v {
+		b.addUint8(slot, 1)
+	} else {
+		b.addUint8(slot, 0)
+	}
+}
+
+func (b *builder) addInt16(slot int, v int16) {
+	b.prependUint16(uint16(v))
+	b.slot(slot)
+}
+
+func (b *builder) addInt32(slot int, v int32) {
+	b.prependUint32(uint32(v))
+	b.slot(slot)
+}
+
+func (b *builder) addInt64(slot int, v int64) {
+	b.prependUint64(uint64(v))
+	b.slot(slot)
+}
+
+func (b *builder) addOffset(slot int, off int) {
+	b.prependOffset(off)
+	b.slot(slot)
+}
+
+// endTable writes the table started last and its vtable, and returns the
+// offset of the table.
+func (b *builder) endTable() int {
+	// The table starts with the offset of its vtable, which is written
+	// right before it.
+	b.prependUint32(0)
+	table := b.offset()
+
+	for i := len(b.fields) - 1; i >= 0; i-- {
+		if b.fields[i] == 0 {
+			b.prependUint16(0)
+		} else {
+			b.prependUint16(uint16(table - b.fields[i]))
+		}
+	}
+	b.prependUint16(uint16(table - b.start))
+	b.prependUint16(uint16(4 + 2*len(b.fields)))
+	vtable := b.offset()
+
+	pos := len(b.buf) - table
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(vtable-table)))
+	return table
+}
+
+// finish writes the offset of the root table, and returns the buffer.
+func (b *builder) finish(root int) []byte {
+	b.align(8, 4)
+	b.prependOffset(root)
+	return b.buf
+}
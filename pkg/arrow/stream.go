@@ -0,0 +1,313 @@
+// Package arrow writes tables in the Arrow IPC streaming format.
+//
+// Only the subset of the format needed to return query results is
+// supported: flat schemas of 64-bit numbers, booleans, strings and
+// timestamps, without dictionaries or compression.
+package arrow // import "github.com/influxdata/influxdb/pkg/arrow"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Type is the type of the values of a column.
+type Type int
+
+// Supported types. Values of columns must be of the corresponding Go type,
+// or nil for nulls.
+const (
+	Int64     Type = iota // int64
+	Uint64                // uint64
+	Float64               // float64
+	Boolean               // bool
+	String                // string
+	Timestamp             // time.Time, written in nanoseconds in UTC.
+)
+
+// Column is a named column of values.
+type Column struct {
+	Name   string
+	Type   Type
+	Values []interface{}
+}
+
+// KeyValue is an entry of the custom metadata of a schema.
+type KeyValue struct {
+	Key, Value string
+}
+
+// Table is a set of columns of the same length, with custom metadata.
+type Table struct {
+	Metadata []KeyValue
+	Columns  []Column
+}
+
+// Flatbuffer enums and unions of the Arrow format.
+const (
+	metadataVersionV5 = 4
+
+	messageHeaderSchema      = 1
+	messageHeaderRecordBatch = 3
+
+	typeInt           = 2
+	typeFloatingPoint = 3
+	typeUtf8          = 5
+	typeBool          = 6
+	typeTimestamp     = 10
+
+	precisionDouble    = 2
+	timeUnitNanosecond = 3
+)
+
+// continuation marks the start of each message of a stream.
+const continuation = 0xFFFFFFFF
+
+// StreamWriter writes tables to an Arrow IPC stream.
+type StreamWriter struct {
+	w io.Writer
+	n int
+}
+
+// NewStreamWriter returns a writer of a stream to w.
+func NewStreamWriter(w io.Writer) *StreamWriter {
+	return &StreamWriter{w: w}
+}
+
+// N returns the number of bytes written.
+func (w *StreamWriter) N() int {
+	return w.n
+}
+
+// WriteTable writes t as a complete stream: its schema, a record batch of
+// its values and the end of the stream. Readers of concatenated streams must
+// open a new stream after each.
+func (w *StreamWriter) WriteTable(t *Table) error {
+	length := 0
+	if len(t.Columns) > 0 {
+		length = len(t.Columns[0].Values)
+	}
+	for _, c := range t.Columns {
+		if len(c.Values) != length {
+			return fmt.Errorf("arrow: column %q has %d values, expected %d", c.Name, len(c.Values), length)
+		}
+	}
+
+	if err := w.writeMessage(schemaMessage(t), nil); err != nil {
+		return err
+	}
+
+	var body []byte
+	var nodes, buffers [][2]int64
+	for _, c := range t.Columns {
+		bufs, nulls, err := columnBuffers(&c)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, [2]int64{int64(length), int64(nulls)})
+		for _, buf := range bufs {
+			buffers = append(buffers, [2]int64{int64(len(body)), int64(len(buf))})
+			body = append(body, buf...)
+			body = append(body, make([]byte, padding(len(body)))...)
+		}
+	}
+	if err := w.writeMessage(recordBatchMessage(length, nodes, buffers, len(body)), body); err != nil {
+		return err
+	}
+
+	// End of stream.
+	return w.write([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0})
+}
+
+// writeMessage writes an encapsulated message of the flatbuffer metadata,
+// followed by its body.
+func (w *StreamWriter) writeMessage(metadata, body []byte) error {
+	size := len(metadata) + padding(8+len(metadata))
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header, continuation)
+	binary.LittleEndian.PutUint32(header[4:], uint32(size))
+	if err := w.write(header); err != nil {
+		return err
+	} else if err := w.write(metadata); err != nil {
+		return err
+	} else if err := w.write(make([]byte, size-len(metadata))); err != nil {
+		return err
+	}
+	return w.write(body)
+}
+
+func (w *StreamWriter) write(p []byte) error {
+	n, err := w.w.Write(p)
+	w.n += n
+	return err
+}
+
+// schemaMessage returns the metadata of a message of the schema of t.
+func schemaMessage(t *Table) []byte {
+	b := &builder{}
+
+	fields := make([]int, len(t.Columns))
+	for i, c := range t.Columns {
+		typeType, typ := fieldType(b, c.Type)
+		name := b.createString(c.Name)
+		children := b.createOffsets(nil)
+
+		b.startTable()
+		b.addOffset(0, name)
+		b.addBool(1, true)
+		b.addUint8(2, typeType)
+		b.addOffset(3, typ)
+		b.addOffset(5, children)
+		fields[i] = b.endTable()
+	}
+	fieldsVec := b.createOffsets(fields)
+
+	kvs := make([]int, len(t.Metadata))
+	for i, kv := range t.Metadata {
+		key, value := b.createString(kv.Key), b.createString(kv.Value)
+		b.startTable()
+		b.addOffset(0, key)
+		b.addOffset(1, value)
+		kvs[i] = b.endTable()
+	}
+	metadata := b.createOffsets(kvs)
+
+	b.startTable()
+	b.addInt16(0, 0) // Little endian.
+	b.addOffset(1, fieldsVec)
+	b.addOffset(2, metadata)
+	schema := b.endTable()
+
+	return message(b, messageHeaderSchema, schema, 0)
+}
+
+// fieldType writes the type table of the field of a column of type t, and
+// returns its union type and offset.
+func fieldType(b *builder, t Type) (uint8, int) {
+	switch t {
+	case Int64, Uint64:
+		b.startTable()
+		b.addInt32(0, 64)
+		b.addBool(1, t == Int64)
+		return typeInt, b.endTable()
+	case Float64:
+		b.startTable()
+		b.addInt16(0, precisionDouble)
+		return typeFloatingPoint, b.endTable()
+	case Boolean:
+		b.startTable()
+		return typeBool, b.endTable()
+	case Timestamp:
+		tz := b.createString("UTC")
+		b.startTable()
+		b.addInt16(0, timeUnitNanosecond)
+		b.addOffset(1, tz)
+		return typeTimestamp, b.endTable()
+	default:
+		b.startTable()
+		return typeUtf8, b.endTable()
+	}
+}
+
+// recordBatchMessage returns the metadata of a message of a record batch.
+func recordBatchMessage(length int, nodes, buffers [][2]int64, bodyLength int) []byte {
+	b := &builder{}
+	nodesVec := b.createStructs(nodes)
+	buffersVec := b.createStructs(buffers)
+
+	b.startTable()
+	b.addInt64(0, int64(length))
+	b.addOffset(1, nodesVec)
+	b.addOffset(2, buffersVec)
+	batch := b.endTable()
+
+	return message(b, messageHeaderRecordBatch, batch, bodyLength)
+}
+
+// message writes a message of the header, and returns the flatbuffer.
+func message(b *builder, headerType uint8, header, bodyLength int) []byte {
+	b.startTable()
+	b.addInt64(3, int64(bodyLength))
+	b.addOffset(2, header)
+	b.addInt16(0, metadataVersionV5)
+	b.addUint8(1, headerType)
+	return b.finish(b.endTable())
+}
+
+// columnBuffers returns the buffers of the values of c: its validity bitmap,
+// followed by its offsets for strings, and its data. The validity bitmap is
+// empty if there are no nulls.
+func columnBuffers(c *Column) (buffers [][]byte, nulls int, err error) {
+	n := len(c.Values)
+	validity := make([]byte, (n+7)/8)
+	var offsets, data []byte
+	switch c.Type {
+	case Boolean:
+		data = make([]byte, (n+7)/8)
+	case String:
+		offsets = make([]byte, 4*(n+1))
+	default:
+		data = make([]byte, 8*n)
+	}
+
+	for i, v := range c.Values {
+		if v == nil {
+			nulls++
+			if c.Type == String {
+				binary.LittleEndian.PutUint32(offsets[4*(i+1):], uint32(len(data)))
+			}
+			continue
+		}
+		validity[i/8] |= 1 << uint(i%8)
+
+		ok := true
+		switch c.Type {
+		case Int64:
+			var x int64
+			x, ok = v.(int64)
+			binary.LittleEndian.PutUint64(data[8*i:], uint64(x))
+		case Uint64:
+			var x uint64
+			x, ok = v.(uint64)
+			binary.LittleEndian.PutUint64(data[8*i:], x)
+		case Float64:
+			var x float64
+			x, ok = v.(float64)
+			binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(x))
+		case Boolean:
+			var x bool
+			if x, ok = v.(bool); x {
+				data[i/8] |= 1 << uint(i%8)
+			}
+		case String:
+			var x string
+			x, ok = v.(string)
+			data = append(data, x...)
+			binary.LittleEndian.PutUint32(offsets[4*(i+1):], uint32(len(data)))
+		case Timestamp:
+			var x time.Time
+			x, ok = v.(time.Time)
+			binary.LittleEndian.PutUint64(data[8*i:], uint64(x.UnixNano()))
+		default:
+			return nil, 0, fmt.Errorf("arrow: unknown type %d of column %q", c.Type, c.Name)
+		}
+		if !ok {
+			return nil, 0, fmt.Errorf("arrow: invalid value of type %T in column %q", v, c.Name)
+		}
+	}
+
+	if nulls == 0 {
+		validity = nil
+	}
+	if c.Type == String {
+		return [][]byte{validity, offsets, data}, nulls, nil
+	}
+	return [][]byte{validity, data}, nulls, nil
+}
+
+// padding returns the number of bytes aligning n to 8 bytes.
+func padding(n int) int {
+	return (8 - n%8) % 8
+}
@@ -0,0 +1,259 @@
+package arrow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreamWriter_WriteTable(t *testing.T) {
+	now := time.Unix(0, 1500000000000000000)
+	table := &Table{
+		Metadata: []KeyValue{{Key: "measurement", Value: "cpu"}, {Key: "statement_id", Value: "0"}},
+		Columns: []Column{
+			{Name: "time", Type: Timestamp, Values: []interface{}{now, now.Add(time.Second), now.Add(2 * time.Second)}},
+			{Name: "host", Type: String, Values: []interface{}{"server01", nil, "server02"}},
+			{Name: "value", Type: Float64, Values: []interface{}{1.5, 2.5, nil}},
+			{Name: "count", Type: Int64, Values: []interface{}{int64(-1), int64(0), int64(1)}},
+			{Name: "total", Type: Uint64, Values: []interface{}{uint64(1), nil, uint64(math.MaxUint64)}},
+			{Name: "ok", Type: Boolean, Values: []interface{}{true, false, true}},
+		},
+	}
+
+	var buf bytes.Buffer
+	w := NewStreamWriter(&buf)
+	if err := w.WriteTable(table); err != nil {
+		t.Fatal(err)
+	} else if w.N() != buf.Len() || buf.Len()%8 != 0 {
+		t.Fatalf("unexpected length: %d, %d", w.N(), buf.Len())
+	}
+
+	r := &testStreamReader{t: t, buf: buf.Bytes()}
+
+	// Schema.
+	msg, body := r.message()
+	if msg.scalar(0, 2) != metadataVersionV5 || msg.scalar(1, 1) != messageHeaderSchema || len(body) != 0 {
+		t.Fatalf("unexpected schema message")
+	}
+	schema := msg.table(2)
+	var metadata []KeyValue
+	for _, kv := range schema.tables(2) {
+		metadata = append(metadata, KeyValue{Key: kv.string(0), Value: kv.string(1)})
+	}
+	if !reflect.DeepEqual(metadata, table.Metadata) {
+		t.Fatalf("unexpected metadata: %v", metadata)
+	}
+	fields := schema.tables(1)
+	if len(fields) != len(table.Columns) {
+		t.Fatalf("unexpected number of fields: %d", len(fields))
+	}
+	for i, exp := range []struct {
+		name     string
+		typeType uint64
+	}{
+		{"time", typeTimestamp},
+		{"host", typeUtf8},
+		{"value", typeFloatingPoint},
+		{"count", typeInt},
+		{"total", typeInt},
+		{"ok", typeBool},
+	} {
+		f := fields[i]
+		if f.string(0) != exp.name || f.scalar(1, 1) != 1 || f.scalar(2, 1) != exp.typeType {
+			t.Fatalf("unexpected field %d: %s", i, f.string(0))
+		} else if len(f.tables(5)) != 0 {
+			t.Fatalf("unexpected children of field %d", i)
+		}
+	}
+	if typ := fields[0].table(3); typ.scalar(0, 2) != timeUnitNanosecond || typ.string(1) != "UTC" {
+		t.Fatal("unexpected timestamp type")
+	} else if typ := fields[2].table(3); typ.scalar(0, 2) != precisionDouble {
+		t.Fatal("unexpected floating point type")
+	} else if typ := fields[3].table(3); typ.scalar(0, 4) != 64 || typ.scalar(1, 1) != 1 {
+		t.Fatal("unexpected int type")
+	} else if typ := fields[4].table(3); typ.scalar(0, 4) != 64 || typ.scalar(1, 1) != 0 {
+		t.Fatal("unexpected uint type")
+	}
+
+	// Record batch.
+	msg, body = r.message()
+	if msg.scalar(1, 1) != messageHeaderRecordBatch || msg.scalar(3, 8) != uint64(len(body)) {
+		t.Fatalf("unexpected record batch message")
+	}
+	batch := msg.table(2)
+	if batch.scalar(0, 8) != 3 {
+		t.Fatalf("unexpected length: %d", batch.scalar(0, 8))
+	}
+	if nodes := batch.structs(1); !reflect.DeepEqual(nodes, [][2]int64{{3, 0}, {3, 1}, {3, 1}, {3, 0}, {3, 1}, {3, 0}}) {
+		t.Fatalf("unexpected nodes: %v", nodes)
+	}
+	var buffers [][]byte
+	for _, b := range batch.structs(2) {
+		if b[0]%8 != 0 {
+			t.Fatalf("unaligned buffer: %v", b)
+		}
+		buffers = append(buffers, body[b[0]:b[0]+b[1]])
+	}
+	if len(buffers) != 13 {
+		t.Fatalf("unexpected number of buffers: %d", len(buffers))
+	}
+
+	u64 := func(b []byte, i int) uint64 { return binary.LittleEndian.Uint64(b[8*i:]) }
+	if len(buffers[0]) != 0 || int64(u64(buffers[1], 2)) != now.Add(2*time.Second).UnixNano() {
+		t.Fatal("unexpected time buffers")
+	}
+	if !bytes.Equal(buffers[2], []byte{0x05}) || !bytes.Equal(buffers[3], []byte{0, 0, 0, 0, 8, 0, 0, 0, 8, 0, 0, 0, 16, 0, 0, 0}) || string(buffers[4]) != "server01server02" {
+		t.Fatal("unexpected string buffers")
+	}
+	if !bytes.Equal(buffers[5], []byte{0x03}) || math.Float64frombits(u64(buffers[6], 1)) != 2.5 {
+		t.Fatal("unexpected float buffers")
+	}
+	if len(buffers[7]) != 0 || int64(u64(buffers[8], 0)) != -1 {
+		t.Fatal("unexpected int buffers")
+	}
+	if !bytes.Equal(buffers[9], []byte{0x05}) || u64(buffers[10], 2) != math.MaxUint64 {
+		t.Fatal("unexpected uint buffers")
+	}
+	if len(buffers[11]) != 0 || !bytes.Equal(buffers[12], []byte{0x05}) {
+		t.Fatal("unexpected bool buffers")
+	}
+
+	// End of stream.
+	if !bytes.Equal(r.buf, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}) {
+		t.Fatalf("unexpected end of stream: %x", r.buf)
+	}
+}
+
+func TestStreamWriter_WriteTable_Invalid(t *testing.T) {
+	w := NewStreamWriter(&bytes.Buffer{})
+	if err := w.WriteTable(&Table{Columns: []Column{
+		{Name: "a", Type: Int64, Values: []interface{}{int64(1)}},
+		{Name: "b", Type: Int64},
+	}}); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := w.WriteTable(&Table{Columns: []Column{
+		{Name: "a", Type: Int64, Values: []interface{}{"1"}},
+	}}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// testStreamReader reads the messages of a stream.
+type testStreamReader struct {
+	t   *testing.T
+	buf []byte
+}
+
+// message returns the root table of the metadata of the next message, and
+// its body.
+func (r *testStreamReader) message() (testTable, []byte) {
+	if binary.LittleEndian.Uint32(r.buf) != continuation {
+		r.t.Fatal("expected continuation")
+	}
+	size := int(binary.LittleEndian.Uint32(r.buf[4:]))
+	if (8+size)%8 != 0 {
+		r.t.Fatalf("unaligned metadata size: %d", size)
+	}
+	fb := r.buf[8 : 8+size]
+	root := testTable{t: r.t, buf: fb, pos: int(binary.LittleEndian.Uint32(fb))}
+	bodyLength := int(root.scalar(3, 8))
+	body := r.buf[8+size : 8+size+bodyLength]
+	r.buf = r.buf[8+size+bodyLength:]
+	return root, body
+}
+
+// testTable is a flatbuffer table.
+type testTable struct {
+	t   *testing.T
+	buf []byte
+	pos int
+}
+
+// field returns the position of the field of slot, or 0 if it is absent.
+func (t testTable) field(slot int) int {
+	vtable := t.pos - int(int32(binary.LittleEndian.Uint32(t.buf[t.pos:])))
+	if 4+2*slot >= int(binary.LittleEndian.Uint16(t.buf[vtable:])) {
+		return 0
+	}
+	off := int(binary.LittleEndian.Uint16(t.buf[vtable+4+2*slot:]))
+	if off == 0 {
+		return 0
+	}
+	return t.pos + off
+}
+
+// scalar returns the unsigned scalar of size bytes of slot.
+func (t testTable) scalar(slot, size int) uint64 {
+	pos := t.field(slot)
+	if pos == 0 {
+		return 0
+	} else if pos%size != 0 {
+		t.t.Fatalf("unaligned field %d", slot)
+	}
+	switch size {
+	case 1:
+		return uint64(t.buf[pos])
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(t.buf[pos:]))
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(t.buf[pos:]))
+	default:
+		return binary.LittleEndian.Uint64(t.buf[pos:])
+	}
+}
+
+// deref returns the position of the object referred to by the offset at pos.
+func (t testTable) deref(pos int) int {
+	return pos + int(binary.LittleEndian.Uint32(t.buf[pos:]))
+}
+
+func (t testTable) table(slot int) testTable {
+	pos := t.field(slot)
+	if pos == 0 {
+		t.t.Fatalf("missing table %d", slot)
+	}
+	return testTable{t: t.t, buf: t.buf, pos: t.deref(pos)}
+}
+
+func (t testTable) string(slot int) string {
+	pos := t.field(slot)
+	if pos == 0 {
+		return ""
+	}
+	s := t.deref(pos)
+	n := int(binary.LittleEndian.Uint32(t.buf[s:]))
+	if t.buf[s+4+n] != 0 {
+		t.t.Fatalf("unterminated string %d", slot)
+	}
+	return string(t.buf[s+4 : s+4+n])
+}
+
+func (t testTable) tables(slot int) []testTable {
+	pos := t.field(slot)
+	if pos == 0 {
+		t.t.Fatalf("missing vector %d", slot)
+	}
+	v := t.deref(pos)
+	tables := make([]testTable, binary.LittleEndian.Uint32(t.buf[v:]))
+	for i := range tables {
+		tables[i] = testTable{t: t.t, buf: t.buf, pos: t.deref(v + 4 + 4*i)}
+	}
+	return tables
+}
+
+func (t testTable) structs(slot int) [][2]int64 {
+	v := t.deref(t.field(slot))
+	if (v+4)%8 != 0 {
+		t.t.Fatalf("unaligned structs %d", slot)
+	}
+	structs := make([][2]int64, binary.LittleEndian.Uint32(t.buf[v:]))
+	for i := range structs {
+		structs[i][0] = int64(binary.LittleEndian.Uint64(t.buf[v+4+16*i:]))
+		structs[i][1] = int64(binary.LittleEndian.Uint64(t.buf[v+12+16*i:]))
+	}
+	return structs
+}
@@ -0,0 +1,226 @@
+// Package tdigest implements the merging t-digest of Ted Dunning, a sketch of
+// the distribution of values for estimating their quantiles.
+//
+// A digest summarizes the values added to it with centroids, the means and
+// weights of clusters of values. Clusters are small near the tails of the
+// distribution and large near its median, so that extreme quantiles are
+// estimated accurately. Digests of subsets of values are merged into a digest
+// of all the values, with about the same accuracy.
+package tdigest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Current version of the encoding of digests.
+const version uint8 = 1
+
+// DefaultCompression is the default compression of digests, which bounds
+// their number of centroids.
+const DefaultCompression = 100
+
+// Centroid is the mean and the weight of a cluster of values.
+type Centroid struct {
+	Mean   float64
+	Weight float64
+}
+
+// TDigest is a t-digest.
+type TDigest struct {
+	compression float64
+
+	centroids []Centroid // Processed centroids, sorted by mean.
+	buf       []Centroid // Centroids not yet processed.
+	weight    float64    // Total weight of centroids and buf.
+
+	min, max float64
+}
+
+// New returns a digest with the default compression.
+func New() *TDigest {
+	return NewWithCompression(DefaultCompression)
+}
+
+// NewWithCompression returns a digest with the given compression. Digests with
+// more compression have more centroids, and are more accurate.
+func NewWithCompression(compression float64) *TDigest {
+	return &TDigest{
+		compression: compression,
+		buf:         make([]Centroid, 0, bufferSize(compression)),
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+// bufferSize returns the number of centroids added before they are merged.
+func bufferSize(compression float64) int {
+	return int(5 * math.Ceil(compression))
+}
+
+// Add adds a value to the digest.
+func (t *TDigest) Add(x float64) {
+	t.AddWeighted(x, 1)
+}
+
+// AddWeighted adds a value of weight w to the digest. NaN values and
+// non-positive weights are ignored.
+func (t *TDigest) AddWeighted(x, w float64) {
+	if math.IsNaN(x) || w <= 0 {
+		return
+	}
+	if len(t.buf) == cap(t.buf) {
+		t.process()
+	}
+	t.buf = append(t.buf, Centroid{Mean: x, Weight: w})
+	t.weight += w
+	t.min = math.Min(t.min, x)
+	t.max = math.Max(t.max, x)
+}
+
+// Merge adds the values of other to the digest.
+func (t *TDigest) Merge(other *TDigest) {
+	other.process()
+	if len(other.centroids) == 0 {
+		return
+	}
+	for _, c := range other.centroids {
+		if len(t.buf) == cap(t.buf) {
+			t.process()
+		}
+		t.buf = append(t.buf, c)
+		t.weight += c.Weight
+	}
+	t.min = math.Min(t.min, other.min)
+	t.max = math.Max(t.max, other.max)
+}
+
+// Count returns the total weight of the values of the digest.
+func (t *TDigest) Count() float64 {
+	return t.weight
+}
+
+// Centroids returns the centroids of the digest, sorted by mean.
+func (t *TDigest) Centroids() []Centroid {
+	t.process()
+	return t.centroids
+}
+
+// process merges the buffered centroids with the processed centroids.
+func (t *TDigest) process() {
+	if len(t.buf) == 0 {
+		return
+	}
+
+	all := append(t.buf, t.centroids...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Mean < all[j].Mean })
+
+	merged := make([]Centroid, 0, len(t.centroids)+1)
+	cur := all[0]
+	var sofar float64
+	for _, c := range all[1:] {
+		// Clusters merge while the quantiles they span increase the scale
+		// function by at most 1, which keeps them small near the tails.
+		if t.k((sofar+cur.Weight+c.Weight)/t.weight)-t.k(sofar/t.weight) <= 1 {
+			cur.Mean += (c.Mean - cur.Mean) * c.Weight / (cur.Weight + c.Weight)
+			cur.Weight += c.Weight
+			continue
+		}
+		merged = append(merged, cur)
+		sofar += cur.Weight
+		cur = c
+	}
+	merged = append(merged, cur)
+
+	t.centroids = merged
+	t.buf = make([]Centroid, 0, bufferSize(t.compression))
+}
+
+// k is the scale function mapping quantiles to the indexes of clusters.
+func (t *TDigest) k(q float64) float64 {
+	return t.compression / (2 * math.Pi) * math.Asin(2*math.Min(q, 1)-1)
+}
+
+// Quantile returns an estimate of the value of the quantile q, between 0 and
+// 1, of the values of the digest. It returns NaN if the digest is empty or q
+// is out of range.
+func (t *TDigest) Quantile(q float64) float64 {
+	t.process()
+	if len(t.centroids) == 0 || q < 0 || q > 1 {
+		return math.NaN()
+	} else if len(t.centroids) == 1 {
+		return t.centroids[0].Mean
+	}
+
+	// Values are interpolated between the centers of the centroids, and
+	// between the minimum and maximum values and the centers of the first
+	// and last centroids.
+	index := q * t.weight
+	first := t.centroids[0]
+	if index < first.Weight/2 {
+		return t.min + (first.Mean-t.min)*index/(first.Weight/2)
+	}
+
+	var sofar float64
+	for i := 0; i < len(t.centroids)-1; i++ {
+		c, next := t.centroids[i], t.centroids[i+1]
+		left := sofar + c.Weight/2
+		right := sofar + c.Weight + next.Weight/2
+		if index < right {
+			return c.Mean + (next.Mean-c.Mean)*(index-left)/(right-left)
+		}
+		sofar += c.Weight
+	}
+
+	last := t.centroids[len(t.centroids)-1]
+	left := t.weight - last.Weight/2
+	if left >= t.weight {
+		return t.max
+	}
+	return last.Mean + (t.max-last.Mean)*(index-left)/(t.weight-left)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t *TDigest) MarshalBinary() ([]byte, error) {
+	t.process()
+
+	b := make([]byte, 1+8*3+4+16*len(t.centroids))
+	b[0] = version
+	binary.BigEndian.PutUint64(b[1:], math.Float64bits(t.compression))
+	binary.BigEndian.PutUint64(b[9:], math.Float64bits(t.min))
+	binary.BigEndian.PutUint64(b[17:], math.Float64bits(t.max))
+	binary.BigEndian.PutUint32(b[25:], uint32(len(t.centroids)))
+	for i, c := range t.centroids {
+		binary.BigEndian.PutUint64(b[29+16*i:], math.Float64bits(c.Mean))
+		binary.BigEndian.PutUint64(b[37+16*i:], math.Float64bits(c.Weight))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *TDigest) UnmarshalBinary(data []byte) error {
+	if len(data) < 29 {
+		return errors.New("tdigest: data too short")
+	} else if data[0] != version {
+		return fmt.Errorf("tdigest: unsupported version %d", data[0])
+	}
+	n := int(binary.BigEndian.Uint32(data[25:]))
+	if len(data) != 29+16*n {
+		return errors.New("tdigest: invalid data length")
+	}
+
+	compression := math.Float64frombits(binary.BigEndian.Uint64(data[1:]))
+	*t = *NewWithCompression(compression)
+	t.min = math.Float64frombits(binary.BigEndian.Uint64(data[9:]))
+	t.max = math.Float64frombits(binary.BigEndian.Uint64(data[17:]))
+	t.centroids = make([]Centroid, n)
+	for i := range t.centroids {
+		t.centroids[i].Mean = math.Float64frombits(binary.BigEndian.Uint64(data[29+16*i:]))
+		t.centroids[i].Weight = math.Float64frombits(binary.BigEndian.Uint64(data[37+16*i:]))
+		t.weight += t.centroids[i].Weight
+	}
+	return nil
+}
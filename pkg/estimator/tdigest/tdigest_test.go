@@ -0,0 +1,115 @@
+package tdigest
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Ensure the quantiles of uniformly distributed values are estimated within
+// a small error of their rank, and more accurately near the tails.
+func TestTDigest_Quantile(t *testing.T) {
+	const n = 100000
+	d := New()
+	for _, i := range rand.New(rand.NewSource(0)).Perm(n) {
+		d.Add(float64(i))
+	}
+
+	if got := d.Count(); got != n {
+		t.Fatalf("unexpected count: %v", got)
+	}
+	for _, tt := range []struct {
+		q, err float64
+	}{
+		{q: 0, err: 0},
+		{q: 0.001, err: 0.0005},
+		{q: 0.01, err: 0.001},
+		{q: 0.25, err: 0.005},
+		{q: 0.5, err: 0.005},
+		{q: 0.75, err: 0.005},
+		{q: 0.99, err: 0.001},
+		{q: 0.999, err: 0.0005},
+		{q: 1, err: 0},
+	} {
+		got := d.Quantile(tt.q) / (n - 1)
+		if math.Abs(got-tt.q) > tt.err {
+			t.Errorf("quantile %v: got %v, expected within %v", tt.q, got, tt.err)
+		}
+	}
+	if got := len(d.Centroids()); got > 2*DefaultCompression {
+		t.Errorf("unexpected number of centroids: %d", got)
+	}
+}
+
+// Ensure merged digests estimate the quantiles of all their values.
+func TestTDigest_Merge(t *testing.T) {
+	const n = 100000
+	digests := make([]*TDigest, 10)
+	for i := range digests {
+		digests[i] = New()
+	}
+	rnd := rand.New(rand.NewSource(0))
+	for i := 0; i < n; i++ {
+		digests[rnd.Intn(len(digests))].Add(rnd.NormFloat64())
+	}
+
+	d := New()
+	for _, other := range digests {
+		d.Merge(other)
+	}
+	if got := d.Count(); got != n {
+		t.Fatalf("unexpected count: %v", got)
+	}
+	for q, exp := range map[float64]float64{0.05: -1.6449, 0.5: 0, 0.95: 1.6449} {
+		if got := d.Quantile(q); math.Abs(got-exp) > 0.02 {
+			t.Errorf("quantile %v: got %v, expected %v", q, got, exp)
+		}
+	}
+}
+
+// Ensure the quantiles of empty digests, of a single value and out of range
+// are handled.
+func TestTDigest_Quantile_Edges(t *testing.T) {
+	d := New()
+	if got := d.Quantile(0.5); !math.IsNaN(got) {
+		t.Fatalf("unexpected quantile of empty digest: %v", got)
+	}
+	d.Add(math.NaN())
+	if got := d.Count(); got != 0 {
+		t.Fatalf("unexpected count: %v", got)
+	}
+
+	d.Add(42)
+	if got := d.Quantile(0.99); got != 42 {
+		t.Fatalf("unexpected quantile of single value: %v", got)
+	} else if got := d.Quantile(1.5); !math.IsNaN(got) {
+		t.Fatalf("unexpected quantile out of range: %v", got)
+	}
+}
+
+// Ensure digests are encoded and decoded.
+func TestTDigest_MarshalBinary(t *testing.T) {
+	d := New()
+	for i := 0; i < 1000; i++ {
+		d.Add(float64(i))
+	}
+	b, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var other TDigest
+	if err := other.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(other.Centroids(), d.Centroids()) {
+		t.Fatal("unexpected centroids")
+	} else if other.Count() != d.Count() || other.Quantile(0.9) != d.Quantile(0.9) {
+		t.Fatalf("unexpected count or quantile: %v %v", other.Count(), other.Quantile(0.9))
+	}
+
+	if err := other.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Fatal("expected error")
+	}
+}
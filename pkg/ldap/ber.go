@@ -0,0 +1,189 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER tags of the LDAP protocol, as defined by RFC 4511.
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+
+	tagBindRequest           = 0x60
+	tagBindResponse          = 0x61
+	tagUnbindRequest         = 0x42
+	tagSearchRequest         = 0x63
+	tagSearchResultEntry     = 0x64
+	tagSearchResultDone      = 0x65
+	tagSearchResultReference = 0x73
+	tagExtendedRequest       = 0x77
+	tagExtendedResponse      = 0x78
+
+	tagSimpleAuthentication = 0x80 // [0] of the AuthenticationChoice.
+	tagExtendedRequestName  = 0x80 // [0] of the ExtendedRequest.
+)
+
+// maxMessageSize is the size of the largest message accepted from a server.
+const maxMessageSize = 16 * 1024 * 1024
+
+// errTruncated is returned when a message ends in the middle of an element.
+var errTruncated = errors.New("ldap: truncated message")
+
+// encode returns the element with tag whose contents are the concatenation
+// of contents.
+func encode(tag byte, contents ...[]byte) []byte {
+	n := 0
+	for _, c := range contents {
+		n += len(c)
+	}
+	b := appendHeader(make([]byte, 0, n+6), tag, n)
+	for _, c := range contents {
+		b = append(b, c...)
+	}
+	return b
+}
+
+func appendHeader(b []byte, tag byte, n int) []byte {
+	b = append(b, tag)
+	if n < 0x80 {
+		return append(b, byte(n))
+	}
+	var l []byte
+	for ; n > 0; n >>= 8 {
+		l = append([]byte{byte(n)}, l...)
+	}
+	b = append(b, 0x80|byte(len(l)))
+	return append(b, l...)
+}
+
+func encodeString(tag byte, s string) []byte {
+	return encode(tag, []byte(s))
+}
+
+// encodeInt returns the element with tag of the two's complement encoding of
+// i.
+func encodeInt(tag byte, i int64) []byte {
+	b := []byte{byte(i)}
+	for (i > 0x7f || i < -0x80) && len(b) < 8 {
+		i >>= 8
+		b = append([]byte{byte(i)}, b...)
+	}
+	return encode(tag, b)
+}
+
+func encodeBool(v bool) []byte {
+	if v {
+		return encode(tagBoolean, []byte{0xff})
+	}
+	return encode(tagBoolean, []byte{0})
+}
+
+// decoder reads the elements of the contents of a constructed element.
+type decoder struct {
+	buf []byte
+}
+
+// more returns true if there are elements left.
+func (d *decoder) more() bool {
+	return len(d.buf) > 0
+}
+
+// next returns the tag and contents of the next element.
+func (d *decoder) next() (byte, []byte, error) {
+	if len(d.buf) < 2 {
+		return 0, nil, errTruncated
+	}
+	tag, n := d.buf[0], int(d.buf[1])
+	d.buf = d.buf[2:]
+	if n&0x80 != 0 {
+		l := n & 0x7f
+		if l == 0 || l > 4 || len(d.buf) < l {
+			return 0, nil, errors.New("ldap: invalid length")
+		}
+		n = 0
+		for _, c := range d.buf[:l] {
+			n = n<<8 | int(c)
+		}
+		d.buf = d.buf[l:]
+	}
+	if n < 0 || n > len(d.buf) {
+		return 0, nil, errTruncated
+	}
+	v := d.buf[:n]
+	d.buf = d.buf[n:]
+	return tag, v, nil
+}
+
+// expect returns the contents of the next element, which must have tag.
+func (d *decoder) expect(tag byte) ([]byte, error) {
+	t, v, err := d.next()
+	if err != nil {
+		return nil, err
+	} else if t != tag {
+		return nil, fmt.Errorf("ldap: unexpected tag 0x%02x, expected 0x%02x", t, tag)
+	}
+	return v, nil
+}
+
+// int returns the next element with tag as an integer.
+func (d *decoder) int(tag byte) (int64, error) {
+	b, err := d.expect(tag)
+	if err != nil {
+		return 0, err
+	} else if len(b) == 0 || len(b) > 8 {
+		return 0, errors.New("ldap: invalid integer")
+	}
+	i := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		i = i<<8 | int64(c)
+	}
+	return i, nil
+}
+
+// string returns the next element with tag as a string.
+func (d *decoder) string(tag byte) (string, error) {
+	b, err := d.expect(tag)
+	return string(b), err
+}
+
+// readElement reads an element of at most maxSize bytes from r, and returns
+// its tag and contents.
+func readElement(r *bufio.Reader, maxSize int) (byte, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(hdr[1])
+	if n&0x80 != 0 {
+		l := n & 0x7f
+		if l == 0 || l > 4 {
+			return 0, nil, errors.New("ldap: invalid length")
+		}
+		var b [4]byte
+		if _, err := io.ReadFull(r, b[:l]); err != nil {
+			return 0, nil, err
+		}
+		n = 0
+		for _, c := range b[:l] {
+			n = n<<8 | int(c)
+		}
+	}
+	if n < 0 || n > maxSize {
+		return 0, nil, fmt.Errorf("ldap: message of %d bytes too large", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return hdr[0], buf, nil
+}
@@ -0,0 +1,350 @@
+// Package ldap implements the subset of the LDAPv3 protocol, as defined by
+// RFC 4511, needed to authenticate users against a directory: simple binds,
+// searches and StartTLS.
+package ldap // import "github.com/influxdata/influxdb/pkg/ldap"
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Result codes of LDAP operations.
+const (
+	ResultSuccess            = 0
+	ResultSizeLimitExceeded  = 4
+	ResultNoSuchObject       = 32
+	ResultInvalidCredentials = 49
+)
+
+// startTLSOID is the name of the StartTLS extended operation.
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// Error is the result of an operation that did not succeed.
+type Error struct {
+	ResultCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.ResultCode)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.ResultCode, e.Message)
+}
+
+// IsResultCode returns true if err is an *Error with the result code.
+func IsResultCode(err error, code int) bool {
+	e, ok := err.(*Error)
+	return ok && e.ResultCode == code
+}
+
+// Scope is the scope of a search.
+type Scope int
+
+// Scopes of a search.
+const (
+	ScopeBaseObject   Scope = 0
+	ScopeSingleLevel  Scope = 1
+	ScopeWholeSubtree Scope = 2
+)
+
+// SearchRequest describes a search.
+type SearchRequest struct {
+	BaseDN string
+	Scope  Scope
+
+	// Filter is the string representation of the filter of the search, as
+	// defined by RFC 4515. Values taken from users must be escaped with
+	// EscapeFilter.
+	Filter string
+
+	// Attributes are the attributes returned for each entry. All user
+	// attributes are returned if empty.
+	Attributes []string
+
+	// SizeLimit is the maximum number of entries returned, if positive.
+	SizeLimit int
+}
+
+// Entry is an entry returned by a search.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Values returns the values of the attribute of the entry. Attribute names
+// are case insensitive.
+func (e *Entry) Values(name string) []string {
+	if v, ok := e.Attributes[name]; ok {
+		return v
+	}
+	for k, v := range e.Attributes {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// Conn is a connection to an LDAP server. Operations are performed one at a
+// time, so a Conn is not safe for concurrent use.
+type Conn struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	msgID int64
+
+	// Timeout limits the time of each operation, if positive.
+	Timeout time.Duration
+}
+
+// NewConn returns a Conn using an established connection.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// Dial connects to the LDAP server at addr.
+func Dial(addr string, timeout time.Duration) (*Conn, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := NewConn(conn)
+	c.Timeout = timeout
+	return c, nil
+}
+
+// DialTLS connects to the LDAP server at addr over TLS.
+func DialTLS(addr string, config *tls.Config, timeout time.Duration) (*Conn, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	c := NewConn(conn)
+	c.Timeout = timeout
+	return c, nil
+}
+
+// StartTLS upgrades the connection to TLS.
+func (c *Conn) StartTLS(config *tls.Config) error {
+	if _, ok := c.conn.(*tls.Conn); ok {
+		return errors.New("ldap: TLS already started")
+	}
+	if _, err := c.do(encode(tagExtendedRequest, encodeString(tagExtendedRequestName, startTLSOID)), tagExtendedResponse); err != nil {
+		return err
+	}
+
+	conn := tls.Client(c.conn, config)
+	c.deadline()
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	return nil
+}
+
+// Bind authenticates the connection as dn with a simple bind. An empty
+// password requests an unauthenticated bind, which servers may accept for
+// any dn, so callers verifying the password of a user must reject empty
+// passwords first.
+func (c *Conn) Bind(dn, password string) error {
+	_, err := c.do(encode(tagBindRequest,
+		encodeInt(tagInteger, 3),
+		encodeString(tagOctetString, dn),
+		encodeString(tagSimpleAuthentication, password),
+	), tagBindResponse)
+	return err
+}
+
+// Search returns the entries matching the request. If the size limit of the
+// request is exceeded, the entries returned so far are returned along with
+// an *Error with ResultSizeLimitExceeded.
+func (c *Conn) Search(req *SearchRequest) ([]*Entry, error) {
+	filter, err := compileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make([][]byte, len(req.Attributes))
+	for i, a := range req.Attributes {
+		attrs[i] = encodeString(tagOctetString, a)
+	}
+	sizeLimit := req.SizeLimit
+	if sizeLimit < 0 {
+		sizeLimit = 0
+	}
+
+	id, err := c.send(encode(tagSearchRequest,
+		encodeString(tagOctetString, req.BaseDN),
+		encodeInt(tagEnumerated, int64(req.Scope)),
+		encodeInt(tagEnumerated, 0), // Never dereference aliases.
+		encodeInt(tagInteger, int64(sizeLimit)),
+		encodeInt(tagInteger, 0),
+		encodeBool(false),
+		filter,
+		encode(tagSequence, attrs...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		tag, op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case tagSearchResultEntry:
+			e, err := decodeEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		case tagSearchResultReference:
+			// Referrals to other servers are not followed.
+		case tagSearchResultDone:
+			return entries, decodeResult(op)
+		default:
+			return nil, fmt.Errorf("ldap: unexpected response tag 0x%02x", tag)
+		}
+	}
+}
+
+// Close sends an unbind request and closes the connection.
+func (c *Conn) Close() error {
+	c.send(encode(tagUnbindRequest))
+	return c.conn.Close()
+}
+
+// deadline sets the deadline of the operation starting.
+func (c *Conn) deadline() {
+	if c.Timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+}
+
+// do performs an operation whose response is a single LDAPResult with tag,
+// and returns the contents of the response.
+func (c *Conn) do(op []byte, tag byte) ([]byte, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+	t, resp, err := c.receive(id)
+	if err != nil {
+		return nil, err
+	} else if t != tag {
+		return nil, fmt.Errorf("ldap: unexpected response tag 0x%02x", t)
+	}
+	return resp, decodeResult(resp)
+}
+
+// send writes a message with the protocol operation op, and returns its
+// message ID.
+func (c *Conn) send(op []byte) (int64, error) {
+	c.msgID++
+	if c.msgID > 1<<31-1 {
+		c.msgID = 1
+	}
+	c.deadline()
+	_, err := c.conn.Write(encode(tagSequence, encodeInt(tagInteger, c.msgID), op))
+	return c.msgID, err
+}
+
+// receive reads the next message for the message ID, and returns the tag
+// and contents of its protocol operation.
+func (c *Conn) receive(id int64) (byte, []byte, error) {
+	for {
+		tag, msg, err := readElement(c.r, maxMessageSize)
+		if err != nil {
+			return 0, nil, err
+		} else if tag != tagSequence {
+			return 0, nil, fmt.Errorf("ldap: unexpected message tag 0x%02x", tag)
+		}
+
+		d := decoder{buf: msg}
+		msgID, err := d.int(tagInteger)
+		if err != nil {
+			return 0, nil, err
+		}
+		tag, op, err := d.next()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch msgID {
+		case id:
+			return tag, op, nil
+		case 0:
+			// An unsolicited notification, such as a notice of
+			// disconnection, ends the connection.
+			if err := decodeResult(op); err != nil {
+				return 0, nil, err
+			}
+			return 0, nil, errors.New("ldap: unsolicited notification")
+		}
+		// Responses to abandoned requests are ignored.
+	}
+}
+
+// decodeResult returns an *Error if the LDAPResult in b did not succeed.
+func decodeResult(b []byte) error {
+	d := decoder{buf: b}
+	code, err := d.int(tagEnumerated)
+	if err != nil {
+		return err
+	}
+	if _, err := d.string(tagOctetString); err != nil {
+		return err
+	}
+	msg, err := d.string(tagOctetString)
+	if err != nil {
+		return err
+	}
+	if code != ResultSuccess {
+		return &Error{ResultCode: int(code), Message: msg}
+	}
+	return nil
+}
+
+// decodeEntry decodes the contents of a SearchResultEntry.
+func decodeEntry(b []byte) (*Entry, error) {
+	d := decoder{buf: b}
+	dn, err := d.string(tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := d.expect(tagSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Entry{DN: dn, Attributes: make(map[string][]string)}
+	for ad := (decoder{buf: attrs}); ad.more(); {
+		attr, err := ad.expect(tagSequence)
+		if err != nil {
+			return nil, err
+		}
+		d := decoder{buf: attr}
+		name, err := d.string(tagOctetString)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := d.expect(tagSet)
+		if err != nil {
+			return nil, err
+		}
+		for vd := (decoder{buf: vals}); vd.more(); {
+			v, err := vd.string(tagOctetString)
+			if err != nil {
+				return nil, err
+			}
+			e.Attributes[name] = append(e.Attributes[name], v)
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,354 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConn_Bind(t *testing.T) {
+	s := NewTestServer(t)
+	defer s.Close()
+	s.Passwords["cn=alice,dc=example,dc=com"] = "secret"
+
+	c, err := Dial(s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Bind("cn=alice,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Bind("cn=alice,dc=example,dc=com", "wrong"); !IsResultCode(err, ResultInvalidCredentials) {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err.Error() != "ldap: result code 49: invalid credentials" {
+		t.Fatalf("unexpected error message: %s", err)
+	}
+
+	// The connection is still usable after a failed bind.
+	if err := c.Bind("cn=alice,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConn_Search(t *testing.T) {
+	s := NewTestServer(t)
+	defer s.Close()
+	s.Entries = []*Entry{
+		{DN: "uid=alice,ou=people,dc=example,dc=com", Attributes: map[string][]string{
+			"memberOf": {"cn=admins,dc=example,dc=com", "cn=users,dc=example,dc=com"},
+		}},
+		{DN: "uid=bob,ou=people,dc=example,dc=com", Attributes: map[string][]string{}},
+	}
+
+	c, err := Dial(s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	req := &SearchRequest{
+		BaseDN:     "ou=people,dc=example,dc=com",
+		Scope:      ScopeWholeSubtree,
+		Filter:     "(uid=*)",
+		Attributes: []string{"memberOf"},
+	}
+	entries, err := c.Search(req)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(entries, s.Entries) {
+		t.Fatalf("unexpected entries: %+v", entries)
+	} else if v := entries[0].Values("MEMBEROF"); len(v) != 2 {
+		t.Fatalf("unexpected values: %v", v)
+	}
+
+	s.mu.Lock()
+	if s.search.baseDN != req.BaseDN || s.search.scope != int64(ScopeWholeSubtree) {
+		t.Fatalf("unexpected search: %+v", s.search)
+	} else if !bytes.Equal(s.search.filter, encodeString(filterPresent, "uid")) {
+		t.Fatalf("unexpected filter: %x", s.search.filter)
+	} else if !reflect.DeepEqual(s.search.attrs, req.Attributes) {
+		t.Fatalf("unexpected attributes: %v", s.search.attrs)
+	}
+	s.mu.Unlock()
+
+	// Exceeding the size limit returns the entries with an error.
+	req.SizeLimit = 1
+	entries, err = c.Search(req)
+	if !IsResultCode(err, ResultSizeLimitExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	} else if len(entries) != 1 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	// Invalid filters are not sent.
+	if _, err := c.Search(&SearchRequest{Filter: "(uid=alice"}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestConn_StartTLS(t *testing.T) {
+	s := NewTestServer(t)
+	defer s.Close()
+	s.Passwords["cn=alice,dc=example,dc=com"] = "secret"
+
+	c, err := Dial(s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The certificate of the server is verified.
+	if err := c.StartTLS(&tls.Config{ServerName: "ldap.example.com"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	c, err = Dial(s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Certificate)
+	if err := c.StartTLS(&tls.Config{ServerName: "ldap.example.com", RootCAs: pool}); err != nil {
+		t.Fatal(err)
+	} else if err := c.Bind("cn=alice,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure operations time out when the server does not respond.
+func TestConn_Timeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	c, err := Dial(l.Addr().String(), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Bind("", ""); err == nil {
+		t.Fatal("expected error")
+	} else if e, ok := err.(net.Error); !ok || !e.Timeout() {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestServer is a minimal LDAP server with a single set of entries.
+type TestServer struct {
+	t        *testing.T
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	// Passwords of the DNs that may bind.
+	Passwords map[string]string
+
+	// Entries returned by every search.
+	Entries []*Entry
+
+	Certificate *x509.Certificate
+	tlsConfig   *tls.Config
+
+	mu     sync.Mutex
+	search struct {
+		baseDN string
+		scope  int64
+		filter []byte
+		attrs  []string
+	}
+}
+
+func NewTestServer(t *testing.T) *TestServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldap.example.com"},
+		DNSNames:              []string{"ldap.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &TestServer{
+		t:           t,
+		listener:    l,
+		Passwords:   make(map[string]string),
+		Certificate: cert,
+		tlsConfig: &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		},
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+	return s
+}
+
+// Addr returns the address of the server.
+func (s *TestServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and waits for its connections to end.
+func (s *TestServer) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *TestServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for {
+		tag, msg, err := readElement(r, maxMessageSize)
+		if err != nil {
+			return
+		} else if tag != tagSequence {
+			s.t.Errorf("unexpected message tag 0x%02x", tag)
+			return
+		}
+		d := decoder{buf: msg}
+		id, err := d.int(tagInteger)
+		if err != nil {
+			s.t.Error(err)
+			return
+		}
+		tag, op, err := d.next()
+		if err != nil {
+			s.t.Error(err)
+			return
+		}
+
+		reply := func(tag byte, code int, msg string) {
+			conn.Write(encode(tagSequence, encodeInt(tagInteger, id), encode(tag,
+				encodeInt(tagEnumerated, int64(code)),
+				encodeString(tagOctetString, ""),
+				encodeString(tagOctetString, msg),
+			)))
+		}
+
+		switch tag {
+		case tagBindRequest:
+			d := decoder{buf: op}
+			d.int(tagInteger)
+			dn, _ := d.string(tagOctetString)
+			password, _ := d.string(tagSimpleAuthentication)
+			if p, ok := s.Passwords[dn]; ok && p == password {
+				reply(tagBindResponse, ResultSuccess, "")
+			} else {
+				reply(tagBindResponse, ResultInvalidCredentials, "invalid credentials")
+			}
+
+		case tagSearchRequest:
+			d := decoder{buf: op}
+			baseDN, _ := d.string(tagOctetString)
+			scope, _ := d.int(tagEnumerated)
+			d.int(tagEnumerated)
+			sizeLimit, _ := d.int(tagInteger)
+			d.int(tagInteger)
+			d.expect(tagBoolean)
+			ftag, filter, _ := d.next()
+			attrs, _ := d.expect(tagSequence)
+
+			s.mu.Lock()
+			s.search.baseDN, s.search.scope, s.search.attrs = baseDN, scope, nil
+			s.search.filter = encode(ftag, filter)
+			for ad := (decoder{buf: attrs}); ad.more(); {
+				a, _ := ad.string(tagOctetString)
+				s.search.attrs = append(s.search.attrs, a)
+			}
+			s.mu.Unlock()
+
+			code := ResultSuccess
+			for i, e := range s.Entries {
+				if sizeLimit > 0 && int64(i) >= sizeLimit {
+					code = ResultSizeLimitExceeded
+					break
+				}
+				var a [][]byte
+				for name, vals := range e.Attributes {
+					v := make([][]byte, len(vals))
+					for j := range vals {
+						v[j] = encodeString(tagOctetString, vals[j])
+					}
+					a = append(a, encode(tagSequence, encodeString(tagOctetString, name), encode(tagSet, v...)))
+				}
+				conn.Write(encode(tagSequence, encodeInt(tagInteger, id), encode(tagSearchResultEntry,
+					encodeString(tagOctetString, e.DN),
+					encode(tagSequence, a...),
+				)))
+			}
+			reply(tagSearchResultDone, code, "")
+
+		case tagExtendedRequest:
+			d := decoder{buf: op}
+			if name, _ := d.string(tagExtendedRequestName); name != startTLSOID {
+				reply(tagExtendedResponse, 2, "unsupported operation")
+				continue
+			}
+			reply(tagExtendedResponse, ResultSuccess, "")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r = tlsConn, bufio.NewReader(tlsConn)
+
+		case tagUnbindRequest:
+			return
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Tags of the Filter choice of a search request.
+const (
+	filterAnd            = 0xa0
+	filterOr             = 0xa1
+	filterNot            = 0xa2
+	filterEqualityMatch  = 0xa3
+	filterSubstrings     = 0xa4
+	filterGreaterOrEqual = 0xa5
+	filterLessOrEqual    = 0xa6
+	filterPresent        = 0x87
+	filterApproxMatch    = 0xa8
+
+	substringInitial = 0x80
+	substringAny     = 0x81
+	substringFinal   = 0x82
+)
+
+// EscapeFilter escapes the characters of s that are special in the string
+// representation of a search filter, so that s matches itself as a value.
+func EscapeFilter(s string) string {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '*', '(', ')', '\\', 0:
+			buf = append(buf, fmt.Sprintf(`\%02x`, c)...)
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return string(buf)
+}
+
+// compileFilter returns the encoding of the string representation of a
+// search filter, as defined by RFC 4515. Extensible matches are not
+// supported.
+func compileFilter(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if s != "" && s[0] != '(' {
+		s = "(" + s + ")"
+	}
+	b, rest, err := parseFilter(s)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid filter %q: %s", s, err)
+	} else if rest != "" {
+		return nil, fmt.Errorf("ldap: invalid filter %q: unexpected %q", s, rest)
+	}
+	return b, nil
+}
+
+// parseFilter returns the encoding of the parenthesized filter at the start
+// of s, and the rest of s.
+func parseFilter(s string) ([]byte, string, error) {
+	if s == "" || s[0] != '(' {
+		return nil, "", errors.New("expected '('")
+	}
+	s = s[1:]
+	if s == "" {
+		return nil, "", errors.New("unexpected end")
+	}
+
+	var b []byte
+	switch s[0] {
+	case '&', '|':
+		tag := byte(filterAnd)
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		var items [][]byte
+		for s = s[1:]; s != "" && s[0] != ')'; {
+			var item []byte
+			var err error
+			if item, s, err = parseFilter(s); err != nil {
+				return nil, "", err
+			}
+			items = append(items, item)
+		}
+		if len(items) == 0 {
+			return nil, "", errors.New("empty filter list")
+		}
+		b = encode(tag, items...)
+
+	case '!':
+		item, rest, err := parseFilter(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		b, s = encode(filterNot, item), rest
+
+	default:
+		i := strings.IndexByte(s, ')')
+		if i < 0 {
+			return nil, "", errors.New("expected ')'")
+		}
+		item, err := parseItem(s[:i])
+		if err != nil {
+			return nil, "", err
+		}
+		b, s = item, s[i:]
+	}
+
+	if s == "" || s[0] != ')' {
+		return nil, "", errors.New("expected ')'")
+	}
+	return b, s[1:], nil
+}
+
+// parseItem returns the encoding of a simple, present or substrings filter
+// without its parentheses.
+func parseItem(s string) ([]byte, error) {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return nil, errors.New("expected attribute and value")
+	}
+
+	attr, value, tag := s[:i], s[i+1:], byte(filterEqualityMatch)
+	switch attr[len(attr)-1] {
+	case '~':
+		tag = filterApproxMatch
+	case '>':
+		tag = filterGreaterOrEqual
+	case '<':
+		tag = filterLessOrEqual
+	}
+	if tag != filterEqualityMatch {
+		attr = attr[:len(attr)-1]
+	}
+	if !validAttribute(attr) {
+		return nil, fmt.Errorf("invalid attribute %q", attr)
+	}
+
+	if tag == filterEqualityMatch && value == "*" {
+		return encodeString(filterPresent, attr), nil
+	} else if tag == filterEqualityMatch && strings.Contains(value, "*") {
+		parts := strings.Split(value, "*")
+		var subs [][]byte
+		for j, p := range parts {
+			if p == "" {
+				continue
+			}
+			v, err := unescapeFilterValue(p)
+			if err != nil {
+				return nil, err
+			}
+			switch j {
+			case 0:
+				subs = append(subs, encodeString(substringInitial, v))
+			case len(parts) - 1:
+				subs = append(subs, encodeString(substringFinal, v))
+			default:
+				subs = append(subs, encodeString(substringAny, v))
+			}
+		}
+		return encode(filterSubstrings, encodeString(tagOctetString, attr), encode(tagSequence, subs...)), nil
+	}
+
+	v, err := unescapeFilterValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return encode(tag, encodeString(tagOctetString, attr), encodeString(tagOctetString, v)), nil
+}
+
+// validAttribute returns true if s is an attribute description: a name or
+// numeric OID, with options.
+func validAttribute(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.', c == ';':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unescapeFilterValue replaces the \XX escapes of a filter value by the
+// bytes they encode.
+func unescapeFilterValue(s string) (string, error) {
+	if !strings.ContainsAny(s, `\()`) {
+		return s, nil
+	}
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(', ')':
+			return "", fmt.Errorf("unescaped %q in value", c)
+		case '\\':
+			if i+2 >= len(s) {
+				return "", errors.New("invalid escape in value")
+			}
+			hi, ok1 := unhex(s[i+1])
+			lo, ok2 := unhex(s[i+2])
+			if !ok1 || !ok2 {
+				return "", errors.New("invalid escape in value")
+			}
+			buf = append(buf, hi<<4|lo)
+			i += 2
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return string(buf), nil
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
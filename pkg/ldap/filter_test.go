@@ -0,0 +1,90 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompileFilter(t *testing.T) {
+	attr := func(tag byte, a, v string) []byte {
+		return encode(tag, encodeString(tagOctetString, a), encodeString(tagOctetString, v))
+	}
+	for _, tt := range []struct {
+		s   string
+		exp []byte
+	}{
+		{s: "(uid=alice)", exp: attr(filterEqualityMatch, "uid", "alice")},
+		{s: "uid=alice", exp: attr(filterEqualityMatch, "uid", "alice")},
+		{s: `(cn=a\2ab\29\5c)`, exp: attr(filterEqualityMatch, "cn", `a*b)\`)},
+		{s: "(objectClass=*)", exp: encodeString(filterPresent, "objectClass")},
+		{s: "(uidNumber>=1000)", exp: attr(filterGreaterOrEqual, "uidNumber", "1000")},
+		{s: "(uidNumber<=1000)", exp: attr(filterLessOrEqual, "uidNumber", "1000")},
+		{s: "(cn~=alice)", exp: attr(filterApproxMatch, "cn", "alice")},
+		{
+			s: "(cn=a*b*c)",
+			exp: encode(filterSubstrings, encodeString(tagOctetString, "cn"), encode(tagSequence,
+				encodeString(substringInitial, "a"),
+				encodeString(substringAny, "b"),
+				encodeString(substringFinal, "c"),
+			)),
+		},
+		{
+			s:   "(cn=*b*)",
+			exp: encode(filterSubstrings, encodeString(tagOctetString, "cn"), encode(tagSequence, encodeString(substringAny, "b"))),
+		},
+		{
+			s: "(&(objectClass=person)(|(uid=alice)(mail=alice@example.com))(!(cn=bob)))",
+			exp: encode(filterAnd,
+				attr(filterEqualityMatch, "objectClass", "person"),
+				encode(filterOr,
+					attr(filterEqualityMatch, "uid", "alice"),
+					attr(filterEqualityMatch, "mail", "alice@example.com"),
+				),
+				encode(filterNot, attr(filterEqualityMatch, "cn", "bob")),
+			),
+		},
+	} {
+		b, err := compileFilter(tt.s)
+		if err != nil {
+			t.Errorf("%s: %s", tt.s, err)
+		} else if !bytes.Equal(b, tt.exp) {
+			t.Errorf("%s: got %x, exp %x", tt.s, b, tt.exp)
+		}
+	}
+}
+
+func TestCompileFilter_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"()",
+		"(uid=alice",
+		"(uid=alice))",
+		"(&)",
+		"(!(uid=alice)",
+		"(=alice)",
+		"(u id=alice)",
+		"(uid:dn:=alice)",
+		`(uid=a\2)`,
+		`(uid=a\zz)`,
+		"(uid=a(b)",
+	} {
+		if _, err := compileFilter(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+// Ensure escaped values match themselves.
+func TestEscapeFilter(t *testing.T) {
+	for _, s := range []string{"alice", "a*b", "(admin)", `a\b`, "a\x00b", "ü"} {
+		b, err := compileFilter("(uid=" + EscapeFilter(s) + ")")
+		if err != nil {
+			t.Errorf("%q: %s", s, err)
+			continue
+		}
+		exp := encode(filterEqualityMatch, encodeString(tagOctetString, "uid"), encodeString(tagOctetString, s))
+		if !bytes.Equal(b, exp) {
+			t.Errorf("%q: got %x, exp %x", s, b, exp)
+		}
+	}
+}
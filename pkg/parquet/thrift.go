@@ -0,0 +1,103 @@
+package parquet
+
+import "encoding/binary"
+
+// Types of the Thrift compact protocol.
+const (
+	thriftBooleanTrue  = 1
+	thriftBooleanFalse = 2
+	thriftI32          = 5
+	thriftI64          = 6
+	thriftBinary       = 8
+	thriftList         = 9
+	thriftStruct       = 12
+)
+
+// thriftWriter encodes structs in the Thrift compact protocol, in which the
+// metadata of Parquet files is encoded.
+type thriftWriter struct {
+	buf []byte
+
+	// Ids of the last fields written, of the structs being written.
+	last []int16
+}
+
+// field writes the header of the field id of type typ.
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+// varint writes a zigzag encoded integer.
+func (w *thriftWriter) varint(v int64) {
+	w.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (w *thriftWriter) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf = append(w.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) bool(id int16, v bool) {
+	if v {
+		w.field(id, thriftBooleanTrue)
+	} else {
+		w.field(id, thriftBooleanFalse)
+	}
+}
+
+func (w *thriftWriter) binary(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.string(s)
+}
+
+// string writes the value of a binary field or element.
+func (w *thriftWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// list writes the header of a list field of n elements of type typ.
+func (w *thriftWriter) list(id int16, typ byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|typ)
+	} else {
+		w.buf = append(w.buf, 0xF0|typ)
+		w.uvarint(uint64(n))
+	}
+}
+
+// structField starts a struct field. Its fields must be followed by end.
+func (w *thriftWriter) structField(id int16) {
+	w.field(id, thriftStruct)
+	w.begin()
+}
+
+// begin starts a struct, at the top level or as an element of a list. Its
+// fields must be followed by end.
+func (w *thriftWriter) begin() {
+	w.last = append(w.last, 0)
+}
+
+// end ends the struct started last.
+func (w *thriftWriter) end() {
+	w.buf = append(w.buf, 0)
+	w.last = w.last[:len(w.last)-1]
+}
@@ -0,0 +1,369 @@
+// Package parquet writes tables in the Apache Parquet file format.
+//
+// Only the subset of the format needed to export query results is
+// supported: flat schemas of optional 64-bit numbers, booleans, strings and
+// timestamps, written with the plain encoding in uncompressed data pages.
+package parquet // import "github.com/influxdata/influxdb/pkg/parquet"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Type is the type of the values of a column.
+type Type int
+
+// Supported types. Values of columns must be of the corresponding Go type,
+// or nil for nulls.
+const (
+	Int64     Type = iota // int64
+	Uint64                // uint64
+	Float64               // float64
+	Boolean               // bool
+	String                // string
+	Timestamp             // time.Time, written in nanoseconds in UTC.
+)
+
+// Column is a named column of a schema.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Enums of the Parquet format.
+const (
+	physicalBoolean   = 0
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	repetitionOptional = 1
+
+	convertedUTF8   = 0
+	convertedUint64 = 14
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+
+	pageTypeData = 0
+)
+
+// magic starts and ends each file.
+const magic = "PAR1"
+
+// ErrWriterClosed is returned when writing to a closed writer.
+var ErrWriterClosed = errors.New("parquet: writer closed")
+
+// Writer writes row groups of a schema to a file. The file is only valid
+// once the writer is closed, which writes its metadata.
+type Writer struct {
+	w       io.Writer
+	columns []Column
+	n       int64
+
+	rows      int64
+	rowGroups []rowGroup
+	closed    bool
+}
+
+// rowGroup is the metadata of a row group written.
+type rowGroup struct {
+	rows   int64
+	size   int64
+	chunks []columnChunk
+}
+
+// columnChunk is the metadata of a column chunk written.
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+// NewWriter returns a writer of a file of columns to w.
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	return &Writer{w: w, columns: columns}
+}
+
+// N returns the number of bytes written.
+func (w *Writer) N() int64 {
+	return w.n
+}
+
+// WriteRowGroup writes rows as a row group. Each row must have a value for
+// each column of the schema. Rows are written as soon as the row group is,
+// so the memory used by a writer doesn't grow with the size of the file.
+func (w *Writer) WriteRowGroup(rows [][]interface{}) error {
+	if w.closed {
+		return ErrWriterClosed
+	} else if len(rows) == 0 {
+		return nil
+	}
+	for _, row := range rows {
+		if len(row) != len(w.columns) {
+			return fmt.Errorf("parquet: row has %d values, expected %d", len(row), len(w.columns))
+		}
+	}
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+
+	rg := rowGroup{rows: int64(len(rows))}
+	for i := range w.columns {
+		page, err := dataPage(&w.columns[i], i, rows)
+		if err != nil {
+			return err
+		}
+		chunk := columnChunk{offset: w.n, size: int64(len(page))}
+		if err := w.write(page); err != nil {
+			return err
+		}
+		rg.chunks = append(rg.chunks, chunk)
+		rg.size += chunk.size
+	}
+	w.rowGroups = append(w.rowGroups, rg)
+	w.rows += rg.rows
+	return nil
+}
+
+// Close writes the metadata of the file. It doesn't close the underlying
+// writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	w.closed = true
+	if err := w.writeMagic(); err != nil {
+		return err
+	}
+
+	footer := w.fileMetaData()
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(len(footer)))
+	if err := w.write(footer); err != nil {
+		return err
+	} else if err := w.write(length); err != nil {
+		return err
+	}
+	return w.write([]byte(magic))
+}
+
+// writeMagic starts the file, if nothing has been written yet.
+func (w *Writer) writeMagic() error {
+	if w.n > 0 {
+		return nil
+	}
+	return w.write([]byte(magic))
+}
+
+func (w *Writer) write(p []byte) error {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return err
+}
+
+// fileMetaData returns the encoded metadata of the file.
+func (w *Writer) fileMetaData() []byte {
+	t := &thriftWriter{}
+	t.begin()
+	t.i32(1, 1) // Version.
+
+	// The schema is a tree, flattened depth first, of a root and its
+	// columns.
+	t.list(2, thriftStruct, len(w.columns)+1)
+	t.begin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.end()
+	for _, c := range w.columns {
+		t.begin()
+		t.i32(1, physicalType(c.Type))
+		t.i32(3, repetitionOptional)
+		t.binary(4, c.Name)
+		switch c.Type {
+		case Uint64:
+			t.i32(6, convertedUint64)
+		case String:
+			t.i32(6, convertedUTF8)
+			t.structField(10)
+			t.structField(1) // StringType.
+			t.end()
+			t.end()
+		case Timestamp:
+			t.structField(10)
+			t.structField(8) // TimestampType.
+			t.bool(1, true)
+			t.structField(2)
+			t.structField(3) // Nanoseconds.
+			t.end()
+			t.end()
+			t.end()
+			t.end()
+		}
+		t.end()
+	}
+
+	t.i64(3, w.rows)
+	t.list(4, thriftStruct, len(w.rowGroups))
+	for _, rg := range w.rowGroups {
+		t.begin()
+		t.list(1, thriftStruct, len(rg.chunks))
+		for i, chunk := range rg.chunks {
+			c := &w.columns[i]
+			t.begin()
+			t.i64(2, chunk.offset)
+			t.structField(3)
+			t.i32(1, physicalType(c.Type))
+			t.list(2, thriftI32, 2)
+			t.varint(encodingPlain)
+			t.varint(encodingRLE)
+			t.list(3, thriftBinary, 1)
+			t.string(c.Name)
+			t.i32(4, codecUncompressed)
+			t.i64(5, rg.rows)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, rg.size)
+		t.i64(3, rg.rows)
+		t.end()
+	}
+
+	t.binary(6, "influxdb")
+	t.end()
+	return t.buf
+}
+
+// physicalType returns the physical type of values of type typ.
+func physicalType(typ Type) int32 {
+	switch typ {
+	case Float64:
+		return physicalDouble
+	case Boolean:
+		return physicalBoolean
+	case String:
+		return physicalByteArray
+	default:
+		return physicalInt64
+	}
+}
+
+// dataPage returns the values of column i of rows, encoded as a data page
+// with its header.
+func dataPage(c *Column, i int, rows [][]interface{}) ([]byte, error) {
+	// Definition levels are 1 for values and 0 for nulls. They are encoded
+	// as runs of the RLE/bit-packing hybrid encoding, prefixed by their
+	// length.
+	levels := make([]byte, 4)
+	var data []byte
+	var bits byte
+	var nbits uint
+	run, level := 0, byte(0)
+	flush := func() {
+		if run > 0 {
+			var b [binary.MaxVarintLen64]byte
+			levels = append(levels, b[:binary.PutUvarint(b[:], uint64(run)<<1)]...)
+			levels = append(levels, level)
+		}
+	}
+
+	for _, row := range rows {
+		v := row[i]
+		l := byte(0)
+		if v != nil {
+			l = 1
+		}
+		if l != level {
+			flush()
+			run, level = 0, l
+		}
+		run++
+		if v == nil {
+			continue
+		}
+
+		ok := true
+		switch c.Type {
+		case Int64:
+			var x int64
+			x, ok = v.(int64)
+			data = appendUint64(data, uint64(x))
+		case Uint64:
+			var x uint64
+			x, ok = v.(uint64)
+			data = appendUint64(data, x)
+		case Float64:
+			var x float64
+			x, ok = v.(float64)
+			data = appendUint64(data, math.Float64bits(x))
+		case Boolean:
+			// Booleans are bit-packed, least significant bit first.
+			var x bool
+			if x, ok = v.(bool); x {
+				bits |= 1 << nbits
+			}
+			if nbits++; nbits == 8 {
+				data = append(data, bits)
+				bits, nbits = 0, 0
+			}
+		case String:
+			var x string
+			x, ok = v.(string)
+			data = appendUint32(data, uint32(len(x)))
+			data = append(data, x...)
+		case Timestamp:
+			var x time.Time
+			x, ok = v.(time.Time)
+			data = appendUint64(data, uint64(x.UnixNano()))
+		default:
+			return nil, fmt.Errorf("parquet: unknown type %d of column %q", c.Type, c.Name)
+		}
+		if !ok {
+			return nil, fmt.Errorf("parquet: invalid value of type %T in column %q", v, c.Name)
+		}
+	}
+	flush()
+	if nbits > 0 {
+		data = append(data, bits)
+	}
+	binary.LittleEndian.PutUint32(levels, uint32(len(levels)-4))
+	size := len(levels) + len(data)
+
+	t := &thriftWriter{}
+	t.begin()
+	t.i32(1, pageTypeData)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.structField(5)
+	t.i32(1, int32(len(rows)))
+	t.i32(2, encodingPlain)
+	t.i32(3, encodingRLE)
+	t.i32(4, encodingRLE)
+	t.end()
+	t.end()
+
+	page := make([]byte, 0, len(t.buf)+size)
+	page = append(page, t.buf...)
+	page = append(page, levels...)
+	return append(page, data...), nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,235 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	now := time.Unix(0, 1500000000000000000)
+	columns := []Column{
+		{Name: "time", Type: Timestamp},
+		{Name: "host", Type: String},
+		{Name: "value", Type: Float64},
+		{Name: "count", Type: Int64},
+		{Name: "total", Type: Uint64},
+		{Name: "ok", Type: Boolean},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, columns)
+	if err := w.WriteRowGroup([][]interface{}{
+		{now, "server01", 1.5, int64(-1), uint64(1), true},
+		{now.Add(time.Second), nil, 2.5, int64(0), nil, false},
+		{now.Add(2 * time.Second), "server02", nil, int64(1), uint64(math.MaxUint64), true},
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := w.WriteRowGroup(nil); err != nil {
+		t.Fatal(err)
+	} else if err := w.WriteRowGroup([][]interface{}{
+		{now.Add(3 * time.Second), "server03", 3.5, nil, uint64(2), nil},
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := w.Close(); err != nil {
+		t.Fatal(err)
+	} else if w.N() != int64(buf.Len()) {
+		t.Fatalf("unexpected length: %d, %d", w.N(), buf.Len())
+	} else if err := w.WriteRowGroup(nil); err != ErrWriterClosed {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b := buf.Bytes()
+	if string(b[:4]) != magic || string(b[len(b)-4:]) != magic {
+		t.Fatal("expected magic")
+	}
+	size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	r := &testThriftReader{t: t, buf: b[len(b)-8-size : len(b)-8]}
+	meta := r.structure()
+	if len(r.buf) != 0 {
+		t.Fatal("unexpected bytes after metadata")
+	}
+
+	if meta[1] != int64(1) || meta[3] != int64(4) {
+		t.Fatalf("unexpected metadata: %v", meta)
+	}
+	schema := meta[2].([]interface{})
+	if root := schema[0].(testStruct); root[4] != "schema" || root[5] != int64(6) {
+		t.Fatalf("unexpected root: %v", root)
+	}
+	for i, exp := range []testStruct{
+		{1: int64(physicalInt64), 3: int64(repetitionOptional), 4: "time", 10: testStruct{8: testStruct{1: true, 2: testStruct{3: testStruct{}}}}},
+		{1: int64(physicalByteArray), 3: int64(repetitionOptional), 4: "host", 6: int64(convertedUTF8), 10: testStruct{1: testStruct{}}},
+		{1: int64(physicalDouble), 3: int64(repetitionOptional), 4: "value"},
+		{1: int64(physicalInt64), 3: int64(repetitionOptional), 4: "count"},
+		{1: int64(physicalInt64), 3: int64(repetitionOptional), 4: "total", 6: int64(convertedUint64)},
+		{1: int64(physicalBoolean), 3: int64(repetitionOptional), 4: "ok"},
+	} {
+		if !reflect.DeepEqual(schema[i+1], exp) {
+			t.Fatalf("unexpected schema element %d: %v", i, schema[i+1])
+		}
+	}
+
+	rowGroups := meta[4].([]interface{})
+	if len(rowGroups) != 2 {
+		t.Fatalf("unexpected number of row groups: %d", len(rowGroups))
+	}
+	var pages [][]byte
+	for _, v := range rowGroups {
+		rg := v.(testStruct)
+		chunks := rg[1].([]interface{})
+		var total int64
+		for i, v := range chunks {
+			md := v.(testStruct)[3].(testStruct)
+			if md[1] != schema[i+1].(testStruct)[1] || !reflect.DeepEqual(md[3], []interface{}{columns[i].Name}) || md[5] != rg[3] {
+				t.Fatalf("unexpected column metadata: %v", md)
+			}
+			offset, size := md[9].(int64), md[7].(int64)
+			total += size
+
+			// Skip the page header.
+			r := &testThriftReader{t: t, buf: b[offset : offset+size]}
+			header := r.structure()
+			if header[1] != int64(pageTypeData) || header[2] != int64(len(r.buf)) || header[5].(testStruct)[1] != rg[3] {
+				t.Fatalf("unexpected page header: %v", header)
+			}
+			pages = append(pages, r.buf)
+		}
+		if rg[2] != total {
+			t.Fatalf("unexpected row group size: %v", rg[2])
+		}
+	}
+
+	u64 := func(b []byte, i int) uint64 { return binary.LittleEndian.Uint64(b[8*i:]) }
+	for i, exp := range []struct {
+		levels []byte
+		data   []byte
+	}{
+		{levels: []byte{6, 1}},
+		{levels: []byte{2, 1, 2, 0, 2, 1}, data: []byte("\x08\x00\x00\x00server01\x08\x00\x00\x00server02")},
+		{levels: []byte{4, 1, 2, 0}},
+		{levels: []byte{6, 1}},
+		{levels: []byte{2, 1, 2, 0, 2, 1}},
+		{levels: []byte{6, 1}, data: []byte{0x05}},
+		{levels: []byte{2, 1}},
+		{levels: []byte{2, 1}, data: []byte("\x08\x00\x00\x00server03")},
+		{levels: []byte{2, 1}},
+		{levels: []byte{2, 0}, data: []byte{}},
+		{levels: []byte{2, 1}},
+		{levels: []byte{2, 0}, data: []byte{}},
+	} {
+		page := pages[i]
+		n := int(binary.LittleEndian.Uint32(page))
+		if !bytes.Equal(page[4:4+n], exp.levels) {
+			t.Fatalf("unexpected levels of page %d: %v", i, page[4:4+n])
+		} else if exp.data != nil && !bytes.Equal(page[4+n:], exp.data) {
+			t.Fatalf("unexpected data of page %d: %v", i, page[4+n:])
+		}
+		pages[i] = page[4+n:]
+	}
+	if int64(u64(pages[0], 2)) != now.Add(2*time.Second).UnixNano() {
+		t.Fatal("unexpected time data")
+	} else if math.Float64frombits(u64(pages[2], 1)) != 2.5 {
+		t.Fatal("unexpected float data")
+	} else if int64(u64(pages[3], 0)) != -1 {
+		t.Fatal("unexpected int data")
+	} else if u64(pages[4], 1) != math.MaxUint64 {
+		t.Fatal("unexpected uint data")
+	}
+}
+
+func TestWriter_Invalid(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{}, []Column{{Name: "a", Type: Int64}})
+	if err := w.WriteRowGroup([][]interface{}{{int64(1), int64(2)}}); err == nil {
+		t.Fatal("expected error")
+	} else if err := w.WriteRowGroup([][]interface{}{{"1"}}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// testStruct is a decoded Thrift struct, by field id.
+type testStruct map[int16]interface{}
+
+// testThriftReader decodes the Thrift compact protocol.
+type testThriftReader struct {
+	t   *testing.T
+	buf []byte
+}
+
+func (r *testThriftReader) byte() byte {
+	if len(r.buf) == 0 {
+		r.t.Fatal("unexpected end of buffer")
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *testThriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.t.Fatal("invalid varint")
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *testThriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *testThriftReader) structure() testStruct {
+	s := testStruct{}
+	var id int16
+	for {
+		b := r.byte()
+		if b == 0 {
+			return s
+		}
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		switch typ := b & 0x0F; typ {
+		case thriftBooleanTrue:
+			s[id] = true
+		case thriftBooleanFalse:
+			s[id] = false
+		default:
+			s[id] = r.value(typ)
+		}
+	}
+}
+
+func (r *testThriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.buf[:n])
+		r.buf = r.buf[n:]
+		return s
+	case thriftList:
+		b := r.byte()
+		n := int(b >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		l := make([]interface{}, n)
+		for i := range l {
+			l[i] = r.value(b & 0x0F)
+		}
+		return l
+	case thriftStruct:
+		return r.structure()
+	default:
+		r.t.Fatalf("unexpected type: %d", typ)
+		return nil
+	}
+}
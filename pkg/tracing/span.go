@@ -75,6 +75,7 @@ func (s *Span) MergeFields(args ...fields.Field) {
 // If Finish is not called, the span will not appear in the trace.
 func (s *Span) Finish() {
 	s.mu.Lock()
+	s.raw.Duration = time.Since(s.raw.Start)
 	s.tracer.addRawSpan(s.raw)
 	s.mu.Unlock()
 }
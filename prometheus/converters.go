@@ -22,6 +22,24 @@ const (
 
 var ErrNaNDropped = errors.New("dropped NaN from Prometheus since they are not supported")
 
+// hintAggregates maps the functions of read hints, whose results are the same
+// over samples aggregated within each step, to the aggregates used.
+var hintAggregates = map[string]string{
+	"avg_over_time": "mean",
+	"min_over_time": "min",
+	"max_over_time": "max",
+	"sum_over_time": "sum",
+}
+
+// HintAggregate returns the InfluxQL aggregate of the samples within each step
+// of the read hints, or an empty string if the raw samples must be read.
+func HintAggregate(hints *remote.ReadHints) string {
+	if hints == nil || hints.StepMs <= 0 {
+		return ""
+	}
+	return hintAggregates[hints.Func]
+}
+
 // WriteRequestToPoints converts a Prometheus remote write request of time series and their
 // samples into Points that can be written into Influx
 func WriteRequestToPoints(req *remote.WriteRequest) ([]models.Point, error) {
@@ -61,7 +79,8 @@ func WriteRequestToPoints(req *remote.WriteRequest) ([]models.Point, error) {
 }
 
 // ReadRequestToInfluxQLQuery converts a Prometheus remote read request to an equivalent InfluxQL
-// query that will return the requested data when executed
+// query that will return the requested data when executed. Samples are aggregated within each
+// step when the hints of the query allow it.
 func ReadRequestToInfluxQLQuery(req *remote.ReadRequest, db, rp string) (*influxql.Query, error) {
 	if len(req.Queries) != 1 {
 		return nil, errors.New("Prometheus read endpoint currently only supports one query at a time")
@@ -88,6 +107,21 @@ func ReadRequestToInfluxQLQuery(req *remote.ReadRequest, db, rp string) (*influx
 
 	stmt.Condition = cond
 
+	if agg := HintAggregate(promQuery.Hints); agg != "" {
+		stmt.IsRawQuery = false
+		stmt.Fields = []*influxql.Field{{
+			Expr:  &influxql.Call{Name: agg, Args: []influxql.Expr{&influxql.VarRef{Val: fieldName}}},
+			Alias: fieldName,
+		}}
+		stmt.Dimensions = append([]*influxql.Dimension{{
+			Expr: &influxql.Call{
+				Name: "time",
+				Args: []influxql.Expr{&influxql.DurationLiteral{Val: time.Duration(promQuery.Hints.StepMs) * time.Millisecond}},
+			},
+		}}, stmt.Dimensions...)
+		stmt.Fill = influxql.NoFill
+	}
+
 	return &influxql.Query{Statements: []influxql.Statement{stmt}}, nil
 }
 
@@ -68,6 +68,30 @@ func TestReadRequestToInfluxQLQuery(t *testing.T) {
 			}},
 			expQuery: `SELECT f64 FROM db0.rp0._ WHERE test_type =~ /a\/b/ AND time >= '1970-01-01T00:00:00.001Z' AND time <= '1970-01-01T00:00:00.1Z' GROUP BY *`,
 		},
+		{
+			name: "aggregate hints",
+			queries: []*remote.Query{{
+				StartTimestampMs: 1,
+				EndTimestampMs:   100,
+				Matchers: []*remote.LabelMatcher{
+					{Name: "region", Value: "west", Type: remote.MatchType_EQUAL},
+				},
+				Hints: &remote.ReadHints{StepMs: 10, Func: "max_over_time"},
+			}},
+			expQuery: "SELECT max(f64) AS f64 FROM db0.rp0._ WHERE region = 'west' AND time >= '1970-01-01T00:00:00.001Z' AND time <= '1970-01-01T00:00:00.1Z' GROUP BY time(10ms), * fill(none)",
+		},
+		{
+			name: "raw hints",
+			queries: []*remote.Query{{
+				StartTimestampMs: 1,
+				EndTimestampMs:   100,
+				Matchers: []*remote.LabelMatcher{
+					{Name: "region", Value: "west", Type: remote.MatchType_EQUAL},
+				},
+				Hints: &remote.ReadHints{StepMs: 10, Func: "rate"},
+			}},
+			expQuery: "SELECT f64 FROM db0.rp0._ WHERE region = 'west' AND time >= '1970-01-01T00:00:00.001Z' AND time <= '1970-01-01T00:00:00.1Z' GROUP BY *",
+		},
 	}
 
 	for _, example := range examples {
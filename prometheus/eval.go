@@ -0,0 +1,479 @@
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/influxdata/influxdb/prometheus/remote"
+)
+
+// LookbackDelta is how far back samples are selected by instant vector
+// selectors, as in Prometheus.
+const LookbackDelta = 5 * time.Minute
+
+// SeriesReader reads the series matching the label matchers of a remote read
+// query, with their samples within its range sorted by time.
+type SeriesReader func(q *remote.Query) ([]*remote.TimeSeries, error)
+
+// Point is the value of a series at a timestamp in milliseconds.
+type Point struct {
+	T int64
+	V float64
+}
+
+// Series is a series of the result of an evaluated expression. The metric of
+// a scalar is nil.
+type Series struct {
+	Metric map[string]string
+	Points []Point
+}
+
+// Eval evaluates the expression expr at each step from start to end, reading
+// the samples of its selectors with read, and returns the series of the
+// results. Series are read once for the whole range.
+func Eval(expr Expr, read SeriesReader, start, end time.Time, step time.Duration) ([]*Series, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("step must be positive")
+	} else if end.Before(start) {
+		return nil, fmt.Errorf("end timestamp must not be before start time")
+	}
+
+	ev := &evaluator{series: make(map[*VectorSelector][]*remote.TimeSeries)}
+	startMs, endMs := toMs(start), toMs(end)
+	if err := ev.read(expr, read, startMs, endMs); err != nil {
+		return nil, err
+	}
+
+	if expr.Type() == ValueTypeScalar {
+		s := &Series{}
+		v := ev.scalar(expr)
+		for t := startMs; t <= endMs; t += int64(step / time.Millisecond) {
+			s.Points = append(s.Points, Point{T: t, V: v})
+		}
+		return []*Series{s}, nil
+	}
+
+	series := make(map[string]*Series)
+	for t := startMs; t <= endMs; t += int64(step / time.Millisecond) {
+		vec, err := ev.eval(expr, t)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range vec {
+			key := labelsKey(s.metric)
+			if series[key] == nil {
+				series[key] = &Series{Metric: s.metric}
+			}
+			series[key].Points = append(series[key].Points, Point{T: t, V: s.v})
+		}
+	}
+
+	keys := make([]string, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make([]*Series, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, series[k])
+	}
+	return result, nil
+}
+
+func toMs(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// sample is the value of a series of a vector at an evaluation timestamp.
+type sample struct {
+	metric map[string]string
+	v      float64
+}
+
+type vector []sample
+
+// evaluator evaluates expressions over the series read for their selectors.
+type evaluator struct {
+	series map[*VectorSelector][]*remote.TimeSeries
+}
+
+// read reads the series of the selectors of expr, with the samples needed to
+// evaluate it from start to end.
+func (ev *evaluator) read(expr Expr, read SeriesReader, start, end int64) error {
+	var sel *VectorSelector
+	lookback := LookbackDelta
+	switch e := expr.(type) {
+	case *VectorSelector:
+		sel = e
+	case *MatrixSelector:
+		sel, lookback = e.VectorSelector, e.Range
+	case *Call:
+		for _, arg := range e.Args {
+			if err := ev.read(arg, read, start, end); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *AggregateExpr:
+		return ev.read(e.Expr, read, start, end)
+	case *BinaryExpr:
+		if err := ev.read(e.LHS, read, start, end); err != nil {
+			return err
+		}
+		return ev.read(e.RHS, read, start, end)
+	case *ParenExpr:
+		return ev.read(e.Expr, read, start, end)
+	default:
+		return nil
+	}
+
+	series, err := read(&remote.Query{
+		StartTimestampMs: start - int64(lookback/time.Millisecond),
+		EndTimestampMs:   end,
+		Matchers:         sel.Matchers,
+	})
+	if err != nil {
+		return err
+	}
+	ev.series[sel] = series
+	return nil
+}
+
+// scalar returns the value of the scalar expression expr.
+func (ev *evaluator) scalar(expr Expr) float64 {
+	switch e := expr.(type) {
+	case *NumberLiteral:
+		return e.Val
+	case *ParenExpr:
+		return ev.scalar(e.Expr)
+	case *BinaryExpr:
+		return arithmetic(e.Op, ev.scalar(e.LHS), ev.scalar(e.RHS))
+	}
+	panic(fmt.Sprintf("unexpected scalar expression %T", expr))
+}
+
+// eval returns the vector of the expression expr at the timestamp t.
+func (ev *evaluator) eval(expr Expr, t int64) (vector, error) {
+	switch e := expr.(type) {
+	case *VectorSelector:
+		var vec vector
+		for _, s := range ev.series[e] {
+			samples := window(s.Samples, t-int64(LookbackDelta/time.Millisecond)+1, t)
+			if len(samples) == 0 {
+				continue
+			}
+			vec = append(vec, sample{metric: labels(s.Labels), v: samples[len(samples)-1].Value})
+		}
+		return vec, nil
+	case *Call:
+		return ev.call(e, t), nil
+	case *AggregateExpr:
+		vec, err := ev.eval(e.Expr, t)
+		if err != nil {
+			return nil, err
+		}
+		return aggregate(e, vec), nil
+	case *BinaryExpr:
+		return ev.binary(e, t)
+	case *ParenExpr:
+		return ev.eval(e.Expr, t)
+	}
+	return nil, fmt.Errorf("unexpected expression %T", expr)
+}
+
+// call returns the vector of the function call e at the timestamp t.
+func (ev *evaluator) call(e *Call, t int64) vector {
+	m := e.Args[0].(*MatrixSelector)
+	rangeStart := t - int64(m.Range/time.Millisecond)
+
+	var vec vector
+	for _, s := range ev.series[m.VectorSelector] {
+		samples := window(s.Samples, rangeStart, t)
+		if len(samples) == 0 {
+			continue
+		}
+
+		var v float64
+		switch e.Func {
+		case "rate", "increase", "delta":
+			if len(samples) < 2 {
+				continue
+			}
+			v = extrapolatedRate(samples, rangeStart, t, e.Func != "delta", e.Func == "rate")
+		case "irate":
+			if len(samples) < 2 {
+				continue
+			}
+			last, prev := samples[len(samples)-1], samples[len(samples)-2]
+			v = last.Value - prev.Value
+			if last.Value < prev.Value {
+				// Counter reset.
+				v = last.Value
+			}
+			v /= float64(last.TimestampMs-prev.TimestampMs) / 1000
+		case "avg_over_time", "sum_over_time":
+			for _, s := range samples {
+				v += s.Value
+			}
+			if e.Func == "avg_over_time" {
+				v /= float64(len(samples))
+			}
+		case "min_over_time", "max_over_time":
+			v = samples[0].Value
+			for _, s := range samples[1:] {
+				if e.Func == "min_over_time" && s.Value < v || e.Func == "max_over_time" && s.Value > v || math.IsNaN(v) {
+					v = s.Value
+				}
+			}
+		case "count_over_time":
+			v = float64(len(samples))
+		}
+		vec = append(vec, sample{metric: dropMetricName(labels(s.Labels)), v: v})
+	}
+	return vec
+}
+
+// extrapolatedRate returns the increase, or the rate if isRate is true, of
+// the samples within the range from rangeStart to rangeEnd, extrapolated to
+// the range like Prometheus does. Decreases of counters are counter resets.
+func extrapolatedRate(samples []*remote.Sample, rangeStart, rangeEnd int64, isCounter, isRate bool) float64 {
+	first, last := samples[0], samples[len(samples)-1]
+	result := last.Value - first.Value
+	if isCounter {
+		for i := 1; i < len(samples); i++ {
+			if samples[i].Value < samples[i-1].Value {
+				result += samples[i-1].Value
+			}
+		}
+	}
+
+	durationToStart := float64(first.TimestampMs-rangeStart) / 1000
+	durationToEnd := float64(rangeEnd-last.TimestampMs) / 1000
+	sampledInterval := float64(last.TimestampMs-first.TimestampMs) / 1000
+	averageDurationBetweenSamples := sampledInterval / float64(len(samples)-1)
+
+	// Counters cannot be extrapolated below zero.
+	if isCounter && result > 0 && first.Value >= 0 {
+		if durationToZero := sampledInterval * (first.Value / result); durationToZero < durationToStart {
+			durationToStart = durationToZero
+		}
+	}
+
+	// Extrapolate to the bounds of the range if the first and last samples
+	// are close enough to them, or else by half the average interval.
+	threshold := averageDurationBetweenSamples * 1.1
+	extrapolateToInterval := sampledInterval
+	if durationToStart < threshold {
+		extrapolateToInterval += durationToStart
+	} else {
+		extrapolateToInterval += averageDurationBetweenSamples / 2
+	}
+	if durationToEnd < threshold {
+		extrapolateToInterval += durationToEnd
+	} else {
+		extrapolateToInterval += averageDurationBetweenSamples / 2
+	}
+
+	result *= extrapolateToInterval / sampledInterval
+	if isRate {
+		result /= float64(rangeEnd-rangeStart) / 1000
+	}
+	return result
+}
+
+// aggregate returns the vector of the aggregation e of the vector vec.
+func aggregate(e *AggregateExpr, vec vector) vector {
+	type group struct {
+		metric map[string]string
+		v      float64
+		n      int
+	}
+	groups := make(map[string]*group)
+	var keys []string
+	for _, s := range vec {
+		metric := groupingLabels(s.metric, e.Grouping, e.Without)
+		key := labelsKey(metric)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{metric: metric, v: s.v}
+			groups[key] = g
+			keys = append(keys, key)
+		} else {
+			switch e.Op {
+			case "sum", "avg":
+				g.v += s.v
+			case "min":
+				if s.v < g.v || math.IsNaN(g.v) {
+					g.v = s.v
+				}
+			case "max":
+				if s.v > g.v || math.IsNaN(g.v) {
+					g.v = s.v
+				}
+			}
+		}
+		g.n++
+	}
+
+	result := make(vector, 0, len(keys))
+	for _, k := range keys {
+		g := groups[k]
+		switch e.Op {
+		case "avg":
+			g.v /= float64(g.n)
+		case "count":
+			g.v = float64(g.n)
+		}
+		result = append(result, sample{metric: g.metric, v: g.v})
+	}
+	return result
+}
+
+// binary returns the vector of the arithmetic operation e at the timestamp
+// t. Vectors are matched by their labels, other than the metric name.
+func (ev *evaluator) binary(e *BinaryExpr, t int64) (vector, error) {
+	switch {
+	case e.LHS.Type() == ValueTypeScalar:
+		vec, err := ev.eval(e.RHS, t)
+		if err != nil {
+			return nil, err
+		}
+		lhs := ev.scalar(e.LHS)
+		for i, s := range vec {
+			vec[i] = sample{metric: dropMetricName(s.metric), v: arithmetic(e.Op, lhs, s.v)}
+		}
+		return vec, nil
+	case e.RHS.Type() == ValueTypeScalar:
+		vec, err := ev.eval(e.LHS, t)
+		if err != nil {
+			return nil, err
+		}
+		rhs := ev.scalar(e.RHS)
+		for i, s := range vec {
+			vec[i] = sample{metric: dropMetricName(s.metric), v: arithmetic(e.Op, s.v, rhs)}
+		}
+		return vec, nil
+	}
+
+	lhs, err := ev.eval(e.LHS, t)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := ev.eval(e.RHS, t)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[string]sample, len(rhs))
+	for _, s := range rhs {
+		metric := dropMetricName(s.metric)
+		key := labelsKey(metric)
+		if _, ok := matches[key]; ok {
+			return nil, fmt.Errorf("many-to-many matching not allowed: matching labels must be unique on one side")
+		}
+		matches[key] = sample{metric: metric, v: s.v}
+	}
+
+	var vec vector
+	seen := make(map[string]bool, len(lhs))
+	for _, s := range lhs {
+		metric := dropMetricName(s.metric)
+		key := labelsKey(metric)
+		m, ok := matches[key]
+		if !ok {
+			continue
+		} else if seen[key] {
+			return nil, fmt.Errorf("many-to-many matching not allowed: matching labels must be unique on one side")
+		}
+		seen[key] = true
+		vec = append(vec, sample{metric: metric, v: arithmetic(e.Op, s.v, m.v)})
+	}
+	return vec, nil
+}
+
+// arithmetic returns the result of the arithmetic operator op.
+func arithmetic(op string, lhs, rhs float64) float64 {
+	switch op {
+	case "+":
+		return lhs + rhs
+	case "-":
+		return lhs - rhs
+	case "*":
+		return lhs * rhs
+	case "/":
+		return lhs / rhs
+	}
+	panic(fmt.Sprintf("unexpected operator %q", op))
+}
+
+// window returns the samples from start to end.
+func window(samples []*remote.Sample, start, end int64) []*remote.Sample {
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].TimestampMs >= start })
+	j := sort.Search(len(samples), func(i int) bool { return samples[i].TimestampMs > end })
+	return samples[i:j]
+}
+
+// labels returns the labels of a series as a map.
+func labels(pairs []*remote.LabelPair) map[string]string {
+	m := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		m[p.Name] = p.Value
+	}
+	return m
+}
+
+// dropMetricName returns the labels other than the metric name.
+func dropMetricName(metric map[string]string) map[string]string {
+	if _, ok := metric["__name__"]; !ok {
+		return metric
+	}
+	m := make(map[string]string, len(metric)-1)
+	for k, v := range metric {
+		if k != "__name__" {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// groupingLabels returns the labels of metric kept by the grouping of an
+// aggregation.
+func groupingLabels(metric map[string]string, grouping []string, without bool) map[string]string {
+	m := make(map[string]string)
+	if without {
+		for k, v := range metric {
+			m[k] = v
+		}
+		delete(m, "__name__")
+		for _, k := range grouping {
+			delete(m, k)
+		}
+		return m
+	}
+	for _, k := range grouping {
+		if v, ok := metric[k]; ok && v != "" {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// labelsKey returns a key identifying the labels of metric.
+func labelsKey(metric map[string]string) string {
+	keys := make([]string, 0, len(metric))
+	for k := range metric {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0xff)
+		b.WriteString(metric[k])
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
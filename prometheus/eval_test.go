@@ -0,0 +1,146 @@
+package prometheus_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/prometheus"
+	"github.com/influxdata/influxdb/prometheus/remote"
+)
+
+// testSeries are counters scraped every 15s for 10m, increasing by 1.5/s on
+// instance a and 3/s on instance b, which is reset after 5m.
+func testSeries() []*remote.TimeSeries {
+	a := &remote.TimeSeries{Labels: []*remote.LabelPair{{Name: "__name__", Value: "requests"}, {Name: "job", Value: "api"}, {Name: "instance", Value: "a"}}}
+	b := &remote.TimeSeries{Labels: []*remote.LabelPair{{Name: "__name__", Value: "requests"}, {Name: "job", Value: "api"}, {Name: "instance", Value: "b"}}}
+	for i := int64(0); i <= 40; i++ {
+		a.Samples = append(a.Samples, &remote.Sample{TimestampMs: i * 15000, Value: float64(i) * 22.5})
+		v := float64(i) * 45
+		if i >= 20 {
+			v = float64(i-20) * 45
+		}
+		b.Samples = append(b.Samples, &remote.Sample{TimestampMs: i * 15000, Value: v})
+	}
+	return []*remote.TimeSeries{a, b}
+}
+
+func TestEval(t *testing.T) {
+	examples := []struct {
+		name string
+		expr string
+		exp  []*prometheus.Series
+	}{
+		{
+			name: "selector",
+			expr: `requests{instance="a"}`,
+			exp: []*prometheus.Series{{
+				Metric: map[string]string{"__name__": "requests", "job": "api", "instance": "a"},
+				Points: []prometheus.Point{{T: 300000, V: 450}, {T: 420000, V: 630}, {T: 540000, V: 810}},
+			}},
+		},
+		{
+			name: "rate",
+			expr: `rate(requests[1m])`,
+			exp: []*prometheus.Series{
+				{
+					Metric: map[string]string{"job": "api", "instance": "a"},
+					Points: []prometheus.Point{{T: 300000, V: 1.5}, {T: 420000, V: 1.5}, {T: 540000, V: 1.5}},
+				},
+				{
+					// The reset within the range loses the increase before it.
+					Metric: map[string]string{"job": "api", "instance": "b"},
+					Points: []prometheus.Point{{T: 300000, V: 2.25}, {T: 420000, V: 3}, {T: 540000, V: 3}},
+				},
+			},
+		},
+		{
+			name: "sum by",
+			expr: `sum by (job) (increase(requests[1m])) / 60`,
+			exp: []*prometheus.Series{{
+				Metric: map[string]string{"job": "api"},
+				Points: []prometheus.Point{{T: 300000, V: 3.75}, {T: 420000, V: 4.5}, {T: 540000, V: 4.5}},
+			}},
+		},
+		{
+			name: "vector matching",
+			expr: `max_over_time(requests[2m]) - min_over_time(requests[2m])`,
+			exp: []*prometheus.Series{
+				{
+					Metric: map[string]string{"job": "api", "instance": "a"},
+					Points: []prometheus.Point{{T: 300000, V: 180}, {T: 420000, V: 180}, {T: 540000, V: 180}},
+				},
+				{
+					Metric: map[string]string{"job": "api", "instance": "b"},
+					Points: []prometheus.Point{{T: 300000, V: 855}, {T: 420000, V: 360}, {T: 540000, V: 360}},
+				},
+			},
+		},
+		{
+			name: "scalar",
+			expr: `2 * (3 + 1)`,
+			exp: []*prometheus.Series{{
+				Points: []prometheus.Point{{T: 300000, V: 8}, {T: 420000, V: 8}, {T: 540000, V: 8}},
+			}},
+		},
+	}
+
+	for _, example := range examples {
+		t.Run(example.name, func(t *testing.T) {
+			expr, err := prometheus.ParseExpr(example.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			read := func(q *remote.Query) ([]*remote.TimeSeries, error) {
+				var series []*remote.TimeSeries
+				for _, s := range testSeries() {
+					if !matches(s, q.Matchers) {
+						continue
+					}
+					filtered := &remote.TimeSeries{Labels: s.Labels}
+					for _, sample := range s.Samples {
+						if sample.TimestampMs >= q.StartTimestampMs && sample.TimestampMs <= q.EndTimestampMs {
+							filtered.Samples = append(filtered.Samples, sample)
+						}
+					}
+					series = append(series, filtered)
+				}
+				return series, nil
+			}
+
+			series, err := prometheus.Eval(expr, read, time.Unix(300, 0), time.Unix(600, 0), 2*time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range series {
+				for i, p := range s.Points {
+					s.Points[i].V = math.Floor(p.V*1e6+0.5) / 1e6
+				}
+			}
+			if !reflect.DeepEqual(series, example.exp) {
+				for _, s := range series {
+					t.Logf("%v: %v", s.Metric, s.Points)
+				}
+				t.Fatal("unexpected series")
+			}
+		})
+	}
+}
+
+// matches returns true if the series s matches the equality matchers.
+func matches(s *remote.TimeSeries, matchers []*remote.LabelMatcher) bool {
+	for _, m := range matchers {
+		var v string
+		for _, l := range s.Labels {
+			if l.Name == m.Name {
+				v = l.Value
+			}
+		}
+		if v != m.Value {
+			return false
+		}
+	}
+	return true
+}
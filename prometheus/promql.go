@@ -0,0 +1,608 @@
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/influxdata/influxdb/prometheus/remote"
+)
+
+// ValueType is the type of the value of a PromQL expression.
+type ValueType string
+
+// The types of the values of PromQL expressions.
+const (
+	ValueTypeScalar ValueType = "scalar"
+	ValueTypeVector ValueType = "vector"
+	ValueTypeMatrix ValueType = "matrix"
+)
+
+// Expr is a node of a parsed PromQL expression.
+type Expr interface {
+	// Type returns the type of the value of the expression.
+	Type() ValueType
+}
+
+// NumberLiteral is a scalar number such as 8 or 1e3.
+type NumberLiteral struct {
+	Val float64
+}
+
+// VectorSelector selects the series matching its label matchers, such as
+// http_requests_total{job="api"}. The metric name is matched as the
+// __name__ label.
+type VectorSelector struct {
+	Matchers []*remote.LabelMatcher
+}
+
+// MatrixSelector selects the samples of the series of a vector selector
+// within a range, such as http_requests_total{job="api"}[5m].
+type MatrixSelector struct {
+	*VectorSelector
+	Range time.Duration
+}
+
+// Call is a call of a function such as rate(http_requests_total[5m]).
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+// AggregateExpr aggregates the series of a vector, such as
+// sum by (job) (rate(http_requests_total[5m])).
+type AggregateExpr struct {
+	Op       string
+	Expr     Expr
+	Grouping []string
+	Without  bool
+}
+
+// BinaryExpr is an arithmetic operation between scalars and vectors.
+type BinaryExpr struct {
+	Op  string
+	LHS Expr
+	RHS Expr
+}
+
+// ParenExpr is an expression within parentheses.
+type ParenExpr struct {
+	Expr Expr
+}
+
+func (*NumberLiteral) Type() ValueType  { return ValueTypeScalar }
+func (*VectorSelector) Type() ValueType { return ValueTypeVector }
+func (*MatrixSelector) Type() ValueType { return ValueTypeMatrix }
+func (*Call) Type() ValueType           { return ValueTypeVector }
+func (*AggregateExpr) Type() ValueType  { return ValueTypeVector }
+func (e *ParenExpr) Type() ValueType    { return e.Expr.Type() }
+
+func (e *BinaryExpr) Type() ValueType {
+	if e.LHS.Type() == ValueTypeScalar && e.RHS.Type() == ValueTypeScalar {
+		return ValueTypeScalar
+	}
+	return ValueTypeVector
+}
+
+// functions are the supported functions, all taking a range vector.
+var functions = map[string]bool{
+	"rate":            true,
+	"irate":           true,
+	"increase":        true,
+	"delta":           true,
+	"avg_over_time":   true,
+	"min_over_time":   true,
+	"max_over_time":   true,
+	"sum_over_time":   true,
+	"count_over_time": true,
+}
+
+// aggregations are the supported aggregation operators.
+var aggregations = map[string]bool{
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+	"count": true,
+}
+
+// ParseExpr parses a PromQL expression of the supported subset: vector and
+// range vector selectors, the functions over range vectors, the sum, avg,
+// min, max and count aggregations and arithmetic.
+func ParseExpr(s string) (Expr, error) {
+	p := &parser{s: s}
+	p.next()
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil || p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	if expr.Type() == ValueTypeMatrix {
+		return nil, fmt.Errorf("expression must be a scalar or an instant vector, got a range vector")
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokDuration
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return strconv.Quote(t.val)
+}
+
+// parser is a recursive descent parser of PromQL expressions.
+type parser struct {
+	s   string
+	pos int
+	tok token
+	err error
+
+	// inBrackets lexes durations rather than numbers.
+	inBrackets bool
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf("parse error at char %d: %s", p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+// next lexes the next token.
+func (p *parser) next() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.s) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.s[p.pos]
+	switch {
+	case p.inBrackets && isDigit(c):
+		for p.pos < len(p.s) && (isDigit(p.s[p.pos]) || isLetter(p.s[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokDuration, val: p.s[start:p.pos], pos: start}
+	case isDigit(c) || (c == '.' && p.pos+1 < len(p.s) && isDigit(p.s[p.pos+1])):
+		for p.pos < len(p.s) && (isDigit(p.s[p.pos]) || p.s[p.pos] == '.' || p.s[p.pos] == 'e' || p.s[p.pos] == 'E' ||
+			((p.s[p.pos] == '+' || p.s[p.pos] == '-') && (p.s[p.pos-1] == 'e' || p.s[p.pos-1] == 'E'))) {
+			p.pos++
+		}
+		p.tok = token{kind: tokNumber, val: p.s[start:p.pos], pos: start}
+	case isLetter(c) || c == '_' || c == ':':
+		for p.pos < len(p.s) && (isLetter(p.s[p.pos]) || isDigit(p.s[p.pos]) || p.s[p.pos] == '_' || p.s[p.pos] == ':') {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, val: p.s[start:p.pos], pos: start}
+	case c == '"' || c == '\'' || c == '`':
+		p.pos++
+		for p.pos < len(p.s) && p.s[p.pos] != c {
+			if p.s[p.pos] == '\\' && c != '`' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.s) {
+			p.tok = token{kind: tokEOF, pos: start}
+			p.err = fmt.Errorf("parse error at char %d: unterminated string", start+1)
+			return
+		}
+		p.pos++
+		p.tok = token{kind: tokString, val: p.s[start:p.pos], pos: start}
+	default:
+		for _, op := range []string{"!=", "=~", "!~", "==", "=", "+", "-", "*", "/", "(", ")", "{", "}", "[", "]", ","} {
+			if strings.HasPrefix(p.s[p.pos:], op) {
+				p.pos += len(op)
+				p.tok = token{kind: tokOp, val: op, pos: start}
+				return
+			}
+		}
+		r, _ := utf8.DecodeRuneInString(p.s[p.pos:])
+		p.tok = token{kind: tokOp, val: string(r), pos: start}
+		p.err = fmt.Errorf("parse error at char %d: unexpected character %q", start+1, r)
+	}
+}
+
+// isOp returns true if the current token is the operator op.
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.val == op
+}
+
+// isIdent returns true if the current token is the identifier ident.
+func (p *parser) isIdent(ident string) bool {
+	return p.tok.kind == tokIdent && p.tok.val == ident
+}
+
+// expect consumes the operator op.
+func (p *parser) expect(op string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if !p.isOp(op) {
+		return p.errorf("expected %q, got %s", op, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+// parseExpr parses additions and subtractions of terms.
+func (p *parser) parseExpr() (Expr, error) {
+	lhs, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.tok.val
+		p.next()
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		if lhs, err = p.binaryExpr(op, lhs, rhs); err != nil {
+			return nil, err
+		}
+	}
+	return lhs, nil
+}
+
+// parseTerm parses multiplications and divisions of unary expressions.
+func (p *parser) parseTerm() (Expr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") {
+		op := p.tok.val
+		p.next()
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if lhs, err = p.binaryExpr(op, lhs, rhs); err != nil {
+			return nil, err
+		}
+	}
+	return lhs, nil
+}
+
+func (p *parser) binaryExpr(op string, lhs, rhs Expr) (Expr, error) {
+	if lhs.Type() == ValueTypeMatrix || rhs.Type() == ValueTypeMatrix {
+		return nil, fmt.Errorf("binary expression must contain only scalar and instant vector types")
+	}
+	return &BinaryExpr{Op: op, LHS: lhs, RHS: rhs}, nil
+}
+
+// parseUnary parses a primary expression, or its negation.
+func (p *parser) parseUnary() (Expr, error) {
+	if p.isOp("-") || p.isOp("+") {
+		op := p.tok.val
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		} else if op == "+" {
+			return expr, nil
+		}
+		if n, ok := expr.(*NumberLiteral); ok {
+			return &NumberLiteral{Val: -n.Val}, nil
+		}
+		return p.binaryExpr("*", expr, &NumberLiteral{Val: -1})
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses numbers, parenthesized expressions, aggregations,
+// function calls and selectors.
+func (p *parser) parsePrimary() (Expr, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	switch tok := p.tok; tok.kind {
+	case tokNumber:
+		p.next()
+		v, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse error at char %d: invalid number %q", tok.pos+1, tok.val)
+		}
+		return &NumberLiteral{Val: v}, nil
+	case tokOp:
+		switch tok.val {
+		case "(":
+			p.next()
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return &ParenExpr{Expr: expr}, nil
+		case "{":
+			return p.parseSelector("")
+		}
+	case tokIdent:
+		p.next()
+		if tok.val == "NaN" || tok.val == "Inf" {
+			v, _ := strconv.ParseFloat(tok.val, 64)
+			return &NumberLiteral{Val: v}, nil
+		} else if aggregations[tok.val] && (p.isOp("(") || p.isIdent("by") || p.isIdent("without")) {
+			return p.parseAggregate(tok.val)
+		} else if p.isOp("(") {
+			if !functions[tok.val] {
+				return nil, fmt.Errorf("parse error at char %d: unknown function %q", tok.pos+1, tok.val)
+			}
+			return p.parseCall(tok.val)
+		}
+		return p.parseSelector(tok.val)
+	}
+	return nil, p.errorf("unexpected %s", p.tok)
+}
+
+// parseAggregate parses an aggregation, with its grouping before or after
+// the expression aggregated.
+func (p *parser) parseAggregate(op string) (Expr, error) {
+	agg := &AggregateExpr{Op: op}
+	grouped := false
+	if p.tok.kind == tokIdent {
+		if err := p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+		grouped = true
+	}
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if expr.Type() != ValueTypeVector {
+		return nil, fmt.Errorf("expected type %s in aggregation %q, got %s", ValueTypeVector, op, expr.Type())
+	}
+	agg.Expr = expr
+
+	if !grouped && (p.isIdent("by") || p.isIdent("without")) {
+		if err := p.parseGrouping(agg); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
+
+// parseGrouping parses the by or without clause of an aggregation.
+func (p *parser) parseGrouping(agg *AggregateExpr) error {
+	if p.tok.val != "by" && p.tok.val != "without" {
+		return p.errorf("unexpected %s in aggregation", p.tok)
+	}
+	agg.Without = p.tok.val == "without"
+	p.next()
+
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for p.tok.kind == tokIdent {
+		agg.Grouping = append(agg.Grouping, p.tok.val)
+		p.next()
+		if !p.isOp(",") {
+			break
+		}
+		p.next()
+	}
+	return p.expect(")")
+}
+
+// parseCall parses the arguments of a call of the function name.
+func (p *parser) parseCall(name string) (Expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if expr.Type() != ValueTypeMatrix {
+		return nil, fmt.Errorf("expected type %s in call to function %q, got %s", ValueTypeMatrix, name, expr.Type())
+	}
+	return &Call{Func: name, Args: []Expr{expr}}, nil
+}
+
+// parseSelector parses the label matchers and range of the selector of the
+// metric name.
+func (p *parser) parseSelector(name string) (Expr, error) {
+	sel := &VectorSelector{}
+	if name != "" {
+		sel.Matchers = append(sel.Matchers, &remote.LabelMatcher{Type: remote.MatchType_EQUAL, Name: "__name__", Value: name})
+	}
+
+	if p.isOp("{") {
+		p.next()
+		for p.tok.kind == tokIdent {
+			m, err := p.parseMatcher()
+			if err != nil {
+				return nil, err
+			}
+			sel.Matchers = append(sel.Matchers, m)
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Like Prometheus, refuse to select all series.
+	empty := true
+	for _, m := range sel.Matchers {
+		if !matchesEmpty(m) {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		return nil, fmt.Errorf("vector selector must contain at least one non-empty matcher")
+	}
+
+	if !p.isOp("[") {
+		return sel, nil
+	}
+	p.inBrackets = true
+	p.next()
+	p.inBrackets = false
+	if p.err != nil {
+		return nil, p.err
+	} else if p.tok.kind != tokDuration {
+		return nil, p.errorf("expected duration, got %s", p.tok)
+	}
+	d, err := ParseDuration(p.tok.val)
+	if err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	p.next()
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return &MatrixSelector{VectorSelector: sel, Range: d}, nil
+}
+
+// parseMatcher parses a label matcher such as job="api".
+func (p *parser) parseMatcher() (*remote.LabelMatcher, error) {
+	m := &remote.LabelMatcher{Name: p.tok.val}
+	p.next()
+	if p.tok.kind != tokOp {
+		return nil, p.errorf("expected label matching operator, got %s", p.tok)
+	}
+	switch p.tok.val {
+	case "=":
+		m.Type = remote.MatchType_EQUAL
+	case "!=":
+		m.Type = remote.MatchType_NOT_EQUAL
+	case "=~":
+		m.Type = remote.MatchType_REGEX_MATCH
+	case "!~":
+		m.Type = remote.MatchType_REGEX_NO_MATCH
+	default:
+		return nil, p.errorf("expected label matching operator, got %s", p.tok)
+	}
+	p.next()
+
+	if p.err != nil {
+		return nil, p.err
+	} else if p.tok.kind != tokString {
+		return nil, p.errorf("expected string, got %s", p.tok)
+	}
+	v, err := unquote(p.tok.val)
+	if err != nil {
+		return nil, p.errorf("invalid string %s: %s", p.tok.val, err)
+	}
+	p.next()
+
+	// Regular expressions of PromQL are anchored.
+	if m.Type == remote.MatchType_REGEX_MATCH || m.Type == remote.MatchType_REGEX_NO_MATCH {
+		v = "^(?:" + v + ")$"
+		if _, err := regexp.Compile(v); err != nil {
+			return nil, fmt.Errorf("invalid regular expression in matcher of %q: %s", m.Name, err)
+		}
+	}
+	m.Value = v
+	return m, nil
+}
+
+// matchesEmpty returns true if the matcher m matches the empty value of the
+// labels series do not have.
+func matchesEmpty(m *remote.LabelMatcher) bool {
+	switch m.Type {
+	case remote.MatchType_EQUAL:
+		return m.Value == ""
+	case remote.MatchType_NOT_EQUAL:
+		return m.Value != ""
+	case remote.MatchType_REGEX_MATCH:
+		return regexp.MustCompile(m.Value).MatchString("")
+	default:
+		return !regexp.MustCompile(m.Value).MatchString("")
+	}
+}
+
+// unquote returns the value of a single, double or back quoted string.
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		s = `"` + strings.Replace(strings.Replace(s[1:len(s)-1], `\'`, `'`, -1), `"`, `\"`, -1) + `"`
+	}
+	return strconv.Unquote(s)
+}
+
+// durationUnits are the units of PromQL durations.
+var durationUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// ParseDuration parses a PromQL duration such as 5m or 1h30m.
+func ParseDuration(s string) (time.Duration, error) {
+	var d time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && isDigit(rest[i]) {
+			i++
+		}
+		j := i
+		for j < len(rest) && isLetter(rest[j]) {
+			j++
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		unit, ok := durationUnits[rest[i:j]]
+		if err != nil || !ok {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		d += time.Duration(n) * unit
+		rest = rest[j:]
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func isDigit(c byte) bool  { return '0' <= c && c <= '9' }
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
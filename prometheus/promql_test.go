@@ -0,0 +1,91 @@
+package prometheus_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/prometheus"
+	"github.com/influxdata/influxdb/prometheus/remote"
+)
+
+func TestParseExpr(t *testing.T) {
+	name := func(v string) *remote.LabelMatcher {
+		return &remote.LabelMatcher{Type: remote.MatchType_EQUAL, Name: "__name__", Value: v}
+	}
+	requests := &prometheus.VectorSelector{Matchers: []*remote.LabelMatcher{
+		name("http_requests_total"),
+		{Type: remote.MatchType_EQUAL, Name: "job", Value: "api"},
+		{Type: remote.MatchType_REGEX_MATCH, Name: "code", Value: "^(?:5..)$"},
+	}}
+
+	examples := []struct {
+		name string
+		expr string
+		exp  prometheus.Expr
+		err  string
+	}{
+		{
+			name: "selector",
+			expr: `http_requests_total{job="api", code=~'5..'}`,
+			exp:  requests,
+		},
+		{
+			name: "rate",
+			expr: `rate(http_requests_total{job="api",code=~"5.."}[5m])`,
+			exp:  &prometheus.Call{Func: "rate", Args: []prometheus.Expr{&prometheus.MatrixSelector{VectorSelector: requests, Range: 5 * time.Minute}}},
+		},
+		{
+			name: "aggregation",
+			expr: `sum by (job) (up) / 2`,
+			exp: &prometheus.BinaryExpr{
+				Op:  "/",
+				LHS: &prometheus.AggregateExpr{Op: "sum", Expr: &prometheus.VectorSelector{Matchers: []*remote.LabelMatcher{name("up")}}, Grouping: []string{"job"}},
+				RHS: &prometheus.NumberLiteral{Val: 2},
+			},
+		},
+		{
+			name: "trailing grouping",
+			expr: `max(up) without (instance)`,
+			exp:  &prometheus.AggregateExpr{Op: "max", Expr: &prometheus.VectorSelector{Matchers: []*remote.LabelMatcher{name("up")}}, Grouping: []string{"instance"}, Without: true},
+		},
+		{
+			name: "precedence",
+			expr: `1 + 2 * -up`,
+			exp: &prometheus.BinaryExpr{
+				Op:  "+",
+				LHS: &prometheus.NumberLiteral{Val: 1},
+				RHS: &prometheus.BinaryExpr{
+					Op:  "*",
+					LHS: &prometheus.NumberLiteral{Val: 2},
+					RHS: &prometheus.BinaryExpr{Op: "*", LHS: &prometheus.VectorSelector{Matchers: []*remote.LabelMatcher{name("up")}}, RHS: &prometheus.NumberLiteral{Val: -1}},
+				},
+			},
+		},
+		{name: "empty selector", expr: `{job=~".*"}`, err: "vector selector must contain at least one non-empty matcher"},
+		{name: "range vector", expr: `up[5m]`, err: "expression must be a scalar or an instant vector, got a range vector"},
+		{name: "instant vector argument", expr: `rate(up)`, err: `expected type matrix in call to function "rate", got vector`},
+		{name: "unknown function", expr: `histogram_quantile(0.9, up)`, err: `parse error at char 1: unknown function "histogram_quantile"`},
+		{name: "invalid duration", expr: `rate(up[5x])`, err: `parse error at char 9: invalid duration "5x"`},
+		{name: "unterminated string", expr: `up{job="api}`, err: "parse error at char 8: unterminated string"},
+		{name: "trailing tokens", expr: `up)`, err: `parse error at char 3: unexpected ")"`},
+	}
+
+	for _, example := range examples {
+		t.Run(example.name, func(t *testing.T) {
+			expr, err := prometheus.ParseExpr(example.expr)
+			if example.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), example.err) {
+					t.Fatalf("got error %v, expected %s", err, example.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(expr, example.exp) {
+				t.Errorf("got expression %#v, expected %#v", expr, example.exp)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@
 		ReadRequest
 		ReadResponse
 		Query
+		ReadHints
 		LabelMatcher
 		QueryResult
 */
@@ -191,6 +192,9 @@ type Query struct {
 	StartTimestampMs int64           `protobuf:"varint,1,opt,name=start_timestamp_ms,json=startTimestampMs,proto3" json:"start_timestamp_ms,omitempty"`
 	EndTimestampMs   int64           `protobuf:"varint,2,opt,name=end_timestamp_ms,json=endTimestampMs,proto3" json:"end_timestamp_ms,omitempty"`
 	Matchers         []*LabelMatcher `protobuf:"bytes,3,rep,name=matchers" json:"matchers,omitempty"`
+	// Hints of how the series are used, which servers may use to serve
+	// less data.
+	Hints *ReadHints `protobuf:"bytes,4,opt,name=hints" json:"hints,omitempty"`
 }
 
 func (m *Query) Reset()                    { *m = Query{} }
@@ -219,6 +223,57 @@ func (m *Query) GetMatchers() []*LabelMatcher {
 	return nil
 }
 
+func (m *Query) GetHints() *ReadHints {
+	if m != nil {
+		return m.Hints
+	}
+	return nil
+}
+
+type ReadHints struct {
+	// Query step size in milliseconds.
+	StepMs int64 `protobuf:"varint,1,opt,name=step_ms,json=stepMs,proto3" json:"step_ms,omitempty"`
+	// String representation of the surrounding function or aggregation.
+	Func string `protobuf:"bytes,2,opt,name=func,proto3" json:"func,omitempty"`
+	// Start time in milliseconds.
+	StartMs int64 `protobuf:"varint,3,opt,name=start_ms,json=startMs,proto3" json:"start_ms,omitempty"`
+	// End time in milliseconds.
+	EndMs int64 `protobuf:"varint,4,opt,name=end_ms,json=endMs,proto3" json:"end_ms,omitempty"`
+}
+
+func (m *ReadHints) Reset()                    { *m = ReadHints{} }
+func (m *ReadHints) String() string            { return proto.CompactTextString(m) }
+func (*ReadHints) ProtoMessage()               {}
+func (*ReadHints) Descriptor() ([]byte, []int) { return fileDescriptorRemote, []int{7} }
+
+func (m *ReadHints) GetStepMs() int64 {
+	if m != nil {
+		return m.StepMs
+	}
+	return 0
+}
+
+func (m *ReadHints) GetFunc() string {
+	if m != nil {
+		return m.Func
+	}
+	return ""
+}
+
+func (m *ReadHints) GetStartMs() int64 {
+	if m != nil {
+		return m.StartMs
+	}
+	return 0
+}
+
+func (m *ReadHints) GetEndMs() int64 {
+	if m != nil {
+		return m.EndMs
+	}
+	return 0
+}
+
 type LabelMatcher struct {
 	Type  MatchType `protobuf:"varint,1,opt,name=type,proto3,enum=remote.MatchType" json:"type,omitempty"`
 	Name  string    `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
@@ -228,7 +283,7 @@ type LabelMatcher struct {
 func (m *LabelMatcher) Reset()                    { *m = LabelMatcher{} }
 func (m *LabelMatcher) String() string            { return proto.CompactTextString(m) }
 func (*LabelMatcher) ProtoMessage()               {}
-func (*LabelMatcher) Descriptor() ([]byte, []int) { return fileDescriptorRemote, []int{7} }
+func (*LabelMatcher) Descriptor() ([]byte, []int) { return fileDescriptorRemote, []int{8} }
 
 func (m *LabelMatcher) GetType() MatchType {
 	if m != nil {
@@ -258,7 +313,7 @@ type QueryResult struct {
 func (m *QueryResult) Reset()                    { *m = QueryResult{} }
 func (m *QueryResult) String() string            { return proto.CompactTextString(m) }
 func (*QueryResult) ProtoMessage()               {}
-func (*QueryResult) Descriptor() ([]byte, []int) { return fileDescriptorRemote, []int{8} }
+func (*QueryResult) Descriptor() ([]byte, []int) { return fileDescriptorRemote, []int{9} }
 
 func (m *QueryResult) GetTimeseries() []*TimeSeries {
 	if m != nil {
@@ -275,6 +330,7 @@ func init() {
 	proto.RegisterType((*ReadRequest)(nil), "remote.ReadRequest")
 	proto.RegisterType((*ReadResponse)(nil), "remote.ReadResponse")
 	proto.RegisterType((*Query)(nil), "remote.Query")
+	proto.RegisterType((*ReadHints)(nil), "remote.ReadHints")
 	proto.RegisterType((*LabelMatcher)(nil), "remote.LabelMatcher")
 	proto.RegisterType((*QueryResult)(nil), "remote.QueryResult")
 	proto.RegisterEnum("remote.MatchType", MatchType_name, MatchType_value)
@@ -506,6 +562,55 @@ func (m *Query) MarshalTo(dAtA []byte) (int, error) {
 			i += n
 		}
 	}
+	if m.Hints != nil {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintRemote(dAtA, i, uint64(m.Hints.Size()))
+		n1, err := m.Hints.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n1
+	}
+	return i, nil
+}
+
+func (m *ReadHints) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ReadHints) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.StepMs != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintRemote(dAtA, i, uint64(m.StepMs))
+	}
+	if len(m.Func) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintRemote(dAtA, i, uint64(len(m.Func)))
+		i += copy(dAtA[i:], m.Func)
+	}
+	if m.StartMs != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintRemote(dAtA, i, uint64(m.StartMs))
+	}
+	if m.EndMs != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintRemote(dAtA, i, uint64(m.EndMs))
+	}
 	return i, nil
 }
 
@@ -696,6 +801,29 @@ func (m *Query) Size() (n int) {
 			n += 1 + l + sovRemote(uint64(l))
 		}
 	}
+	if m.Hints != nil {
+		l = m.Hints.Size()
+		n += 1 + l + sovRemote(uint64(l))
+	}
+	return n
+}
+
+func (m *ReadHints) Size() (n int) {
+	var l int
+	_ = l
+	if m.StepMs != 0 {
+		n += 1 + sovRemote(uint64(m.StepMs))
+	}
+	l = len(m.Func)
+	if l > 0 {
+		n += 1 + l + sovRemote(uint64(l))
+	}
+	if m.StartMs != 0 {
+		n += 1 + sovRemote(uint64(m.StartMs))
+	}
+	if m.EndMs != 0 {
+		n += 1 + sovRemote(uint64(m.EndMs))
+	}
 	return n
 }
 
@@ -1389,6 +1517,175 @@ func (m *Query) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hints", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRemote
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRemote
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Hints == nil {
+				m.Hints = &ReadHints{}
+			}
+			if err := m.Hints.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRemote(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRemote
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ReadHints) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRemote
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ReadHints: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ReadHints: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StepMs", wireType)
+			}
+			m.StepMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRemote
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StepMs |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Func", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRemote
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRemote
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Func = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StartMs", wireType)
+			}
+			m.StartMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRemote
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StartMs |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EndMs", wireType)
+			}
+			m.EndMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRemote
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.EndMs |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRemote(dAtA[iNdEx:])
@@ -1726,34 +2023,38 @@ var (
 func init() { proto.RegisterFile("remote.proto", fileDescriptorRemote) }
 
 var fileDescriptorRemote = []byte{
-	// 449 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x53, 0x4d, 0x6f, 0xd3, 0x40,
-	0x10, 0xed, 0xc6, 0x4d, 0x82, 0xc7, 0x6e, 0x30, 0x43, 0x0f, 0x39, 0x45, 0xc1, 0x12, 0xc2, 0x20,
-	0xa8, 0x50, 0x11, 0xdc, 0x38, 0xa4, 0x28, 0x02, 0xa1, 0xa6, 0xa5, 0x5b, 0x23, 0xb8, 0x59, 0x5b,
-	0x32, 0x12, 0x96, 0xec, 0xc4, 0xdd, 0x5d, 0x23, 0xe5, 0x5f, 0xc0, 0xbf, 0xe2, 0xc8, 0x4f, 0x40,
-	0xe1, 0x8f, 0xa0, 0xec, 0xc6, 0x1f, 0x91, 0x72, 0xea, 0x2d, 0x33, 0xef, 0xbd, 0x99, 0x97, 0x7d,
-	0x63, 0xf0, 0x25, 0xe5, 0x4b, 0x4d, 0x27, 0x85, 0x5c, 0xea, 0x25, 0xf6, 0x6c, 0x15, 0x4e, 0xa0,
-	0x77, 0x2d, 0xf2, 0x22, 0x23, 0x3c, 0x86, 0xee, 0x0f, 0x91, 0x95, 0x34, 0x64, 0x63, 0x16, 0x31,
-	0x6e, 0x0b, 0x7c, 0x04, 0xbe, 0x4e, 0x73, 0x52, 0x5a, 0xe4, 0x45, 0x92, 0xab, 0x61, 0x67, 0xcc,
-	0x22, 0x87, 0x7b, 0x75, 0x6f, 0xa6, 0xc2, 0xd7, 0xe0, 0x9e, 0x8b, 0x1b, 0xca, 0x3e, 0x89, 0x54,
-	0x22, 0xc2, 0xe1, 0x42, 0xe4, 0x76, 0x88, 0xcb, 0xcd, 0xef, 0x66, 0x72, 0xc7, 0x34, 0x6d, 0x11,
-	0x0a, 0x80, 0x38, 0xcd, 0xe9, 0x9a, 0x64, 0x4a, 0x0a, 0x9f, 0x42, 0x2f, 0xdb, 0x0c, 0x51, 0x43,
-	0x36, 0x76, 0x22, 0xef, 0xf4, 0xc1, 0xc9, 0xd6, 0x6e, 0x3d, 0x9a, 0x6f, 0x09, 0x18, 0x41, 0x5f,
-	0x19, 0xcb, 0x1b, 0x37, 0x1b, 0xee, 0xa0, 0xe2, 0xda, 0x7f, 0xc2, 0x2b, 0x38, 0x3c, 0x03, 0xff,
-	0x8b, 0x4c, 0x35, 0x71, 0xba, 0x2d, 0x49, 0x69, 0x3c, 0x05, 0x30, 0xc6, 0xcd, 0xca, 0xed, 0x22,
-	0xac, 0xc4, 0x8d, 0x19, 0xde, 0x62, 0x85, 0x6f, 0xc0, 0xe3, 0x24, 0xe6, 0xd5, 0x88, 0x27, 0xd0,
-	0xbf, 0x2d, 0xdb, 0xfa, 0xa3, 0x4a, 0x7f, 0x55, 0x92, 0x5c, 0xf1, 0x0a, 0x0d, 0xdf, 0x82, 0x6f,
-	0x75, 0xaa, 0x58, 0x2e, 0x14, 0xe1, 0x0b, 0xe8, 0x4b, 0x52, 0x65, 0xa6, 0x2b, 0xe1, 0xc3, 0x5d,
-	0xa1, 0xc1, 0x78, 0xc5, 0x09, 0x7f, 0x31, 0xe8, 0x1a, 0x00, 0x9f, 0x03, 0x2a, 0x2d, 0xa4, 0x4e,
-	0x76, 0x72, 0x60, 0x26, 0x87, 0xc0, 0x20, 0x71, 0x13, 0x06, 0x46, 0x10, 0xd0, 0x62, 0x9e, 0xec,
-	0xc9, 0x6c, 0x40, 0x8b, 0x79, 0x9b, 0xf9, 0x12, 0xee, 0xe5, 0x42, 0x7f, 0xfb, 0x4e, 0x52, 0x0d,
-	0x1d, 0xe3, 0xe8, 0x78, 0xe7, 0xcd, 0x67, 0x16, 0xe4, 0x35, 0x2b, 0x4c, 0xc0, 0x6f, 0x23, 0xf8,
-	0x18, 0x0e, 0xf5, 0xaa, 0xb0, 0x59, 0x0f, 0x9a, 0xc4, 0x0c, 0x1c, 0xaf, 0x0a, 0xe2, 0x06, 0xae,
-	0x4f, 0xa2, 0xb3, 0xef, 0x24, 0x9c, 0xf6, 0x49, 0x4c, 0xc0, 0x6b, 0x3d, 0xc6, 0x5d, 0xe2, 0x7a,
-	0xf6, 0x11, 0xdc, 0x7a, 0x3f, 0xba, 0xd0, 0x9d, 0x5e, 0x7d, 0x9e, 0x9c, 0x07, 0x07, 0x78, 0x04,
-	0xee, 0xc5, 0x65, 0x9c, 0xd8, 0x92, 0xe1, 0x7d, 0xf0, 0xf8, 0xf4, 0xfd, 0xf4, 0x6b, 0x32, 0x9b,
-	0xc4, 0xef, 0x3e, 0x04, 0x1d, 0x44, 0x18, 0xd8, 0xc6, 0xc5, 0xe5, 0xb6, 0xe7, 0x9c, 0x05, 0xbf,
-	0xd7, 0x23, 0xf6, 0x67, 0x3d, 0x62, 0x7f, 0xd7, 0x23, 0xf6, 0xf3, 0xdf, 0xe8, 0xe0, 0xa6, 0x67,
-	0x3e, 0x9e, 0x57, 0xff, 0x03, 0x00, 0x00, 0xff, 0xff, 0x28, 0xd6, 0xf1, 0x18, 0x4c, 0x03, 0x00,
-	0x00,
+	// 520 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x53, 0xc1, 0x6e, 0xd3, 0x4c,
+	0x10, 0xee, 0xc6, 0x89, 0x53, 0x4f, 0xd2, 0xfc, 0xf9, 0x87, 0x22, 0xc2, 0x25, 0x0a, 0x96, 0x50,
+	0x03, 0x82, 0x0a, 0x15, 0xc1, 0x8d, 0x43, 0x8a, 0x22, 0x2a, 0x54, 0xb7, 0x74, 0x1b, 0x04, 0x37,
+	0xcb, 0x6d, 0x06, 0xd5, 0x28, 0xeb, 0xb8, 0xde, 0x35, 0x52, 0xde, 0x82, 0xe7, 0xe1, 0x09, 0x38,
+	0xf2, 0x08, 0x28, 0xbc, 0x08, 0xda, 0x5d, 0xdb, 0x71, 0xa4, 0x9e, 0xb8, 0xed, 0xcc, 0xf7, 0xcd,
+	0xcc, 0x37, 0x9e, 0xcf, 0xd0, 0xcd, 0x48, 0x2c, 0x15, 0x1d, 0xa6, 0xd9, 0x52, 0x2d, 0xd1, 0xb5,
+	0x91, 0x3f, 0x01, 0xf7, 0x32, 0x12, 0xe9, 0x82, 0x70, 0x1f, 0x5a, 0xdf, 0xa2, 0x45, 0x4e, 0x03,
+	0x36, 0x62, 0x63, 0xc6, 0x6d, 0x80, 0x8f, 0xa0, 0xab, 0x62, 0x41, 0x52, 0x45, 0x22, 0x0d, 0x85,
+	0x1c, 0x34, 0x46, 0x6c, 0xec, 0xf0, 0x4e, 0x95, 0x0b, 0xa4, 0xff, 0x0a, 0xbc, 0xd3, 0xe8, 0x8a,
+	0x16, 0x1f, 0xa2, 0x38, 0x43, 0x84, 0x66, 0x12, 0x09, 0xdb, 0xc4, 0xe3, 0xe6, 0xbd, 0xe9, 0xdc,
+	0x30, 0x49, 0x1b, 0xf8, 0x11, 0xc0, 0x2c, 0x16, 0x74, 0x49, 0x59, 0x4c, 0x12, 0x9f, 0x80, 0xbb,
+	0xd0, 0x4d, 0xe4, 0x80, 0x8d, 0x9c, 0x71, 0xe7, 0xe8, 0xff, 0xc3, 0x42, 0x6e, 0xd5, 0x9a, 0x17,
+	0x04, 0x1c, 0x43, 0x5b, 0x1a, 0xc9, 0x5a, 0x8d, 0xe6, 0xf6, 0x4a, 0xae, 0xdd, 0x84, 0x97, 0xb0,
+	0x7f, 0x0c, 0xdd, 0x4f, 0x59, 0xac, 0x88, 0xd3, 0x6d, 0x4e, 0x52, 0xe1, 0x11, 0x80, 0x11, 0x6e,
+	0x46, 0x16, 0x83, 0xb0, 0x2c, 0xde, 0x88, 0xe1, 0x35, 0x96, 0xff, 0x1a, 0x3a, 0x9c, 0xa2, 0x79,
+	0xd9, 0xe2, 0x00, 0xda, 0xb7, 0x79, 0xbd, 0x7e, 0xaf, 0xac, 0xbf, 0xc8, 0x29, 0x5b, 0xf1, 0x12,
+	0xf5, 0xdf, 0x40, 0xd7, 0xd6, 0xc9, 0x74, 0x99, 0x48, 0xc2, 0xe7, 0xd0, 0xce, 0x48, 0xe6, 0x0b,
+	0x55, 0x16, 0xde, 0xdb, 0x2e, 0x34, 0x18, 0x2f, 0x39, 0xfe, 0x0f, 0x06, 0x2d, 0x03, 0xe0, 0x33,
+	0x40, 0xa9, 0xa2, 0x4c, 0x85, 0x5b, 0x77, 0x60, 0xe6, 0x0e, 0x7d, 0x83, 0xcc, 0x36, 0xc7, 0xc0,
+	0x31, 0xf4, 0x29, 0x99, 0x87, 0x77, 0xdc, 0xac, 0x47, 0xc9, 0xbc, 0xce, 0x7c, 0x01, 0xbb, 0x22,
+	0x52, 0xd7, 0x37, 0x94, 0xc9, 0x81, 0x63, 0x14, 0xed, 0x6f, 0x7d, 0xf3, 0xc0, 0x82, 0xbc, 0x62,
+	0xe1, 0x01, 0xb4, 0x6e, 0xe2, 0x44, 0xc9, 0x41, 0x73, 0xc4, 0xea, 0x27, 0xd2, 0x7b, 0x9e, 0x68,
+	0x80, 0x5b, 0xdc, 0xff, 0x0a, 0x5e, 0x95, 0xc3, 0x07, 0xd0, 0x96, 0x8a, 0x6a, 0xa2, 0x5d, 0x1d,
+	0x06, 0x52, 0x5b, 0xe5, 0x4b, 0x9e, 0x5c, 0x17, 0xae, 0x30, 0x6f, 0x7c, 0x08, 0xbb, 0x76, 0x59,
+	0xa1, 0x45, 0x69, 0x76, 0xdb, 0xc4, 0x81, 0xc4, 0xfb, 0xe0, 0xea, 0xcd, 0x84, 0x1d, 0xef, 0xf0,
+	0x16, 0x25, 0xf3, 0x40, 0xfa, 0x21, 0x74, 0xeb, 0x72, 0xf1, 0x31, 0x34, 0xd5, 0x2a, 0xb5, 0x06,
+	0xec, 0x6d, 0x34, 0x1a, 0x78, 0xb6, 0x4a, 0x89, 0x1b, 0xb8, 0xf2, 0x69, 0xe3, 0x2e, 0x9f, 0x3a,
+	0x75, 0x9f, 0x4e, 0xa0, 0x53, 0xbb, 0xd0, 0xbf, 0x78, 0xe8, 0xe9, 0x7b, 0xf0, 0xaa, 0xf9, 0xe8,
+	0x41, 0x6b, 0x7a, 0xf1, 0x71, 0x72, 0xda, 0xdf, 0xc1, 0x3d, 0xf0, 0xce, 0xce, 0x67, 0xa1, 0x0d,
+	0x19, 0xfe, 0x07, 0x1d, 0x3e, 0x7d, 0x37, 0xfd, 0x1c, 0x06, 0x93, 0xd9, 0xdb, 0x93, 0x7e, 0x03,
+	0x11, 0x7a, 0x36, 0x71, 0x76, 0x5e, 0xe4, 0x9c, 0xe3, 0xfe, 0xcf, 0xf5, 0x90, 0xfd, 0x5a, 0x0f,
+	0xd9, 0xef, 0xf5, 0x90, 0x7d, 0xff, 0x33, 0xdc, 0xb9, 0x72, 0xcd, 0x1f, 0xfd, 0xf2, 0xef, 0x00,
+	0x48, 0xbd, 0xb5, 0xc8, 0xe1, 0x03, 0x00, 0x00,
 }
@@ -0,0 +1,172 @@
+package query
+
+import (
+	"math"
+	"sort"
+)
+
+// pointWindow is the window of the last values of a series.
+type pointWindow struct {
+	pos int
+	buf []float64
+}
+
+func newPointWindow(n int) pointWindow {
+	return pointWindow{buf: make([]float64, 0, n)}
+}
+
+// add adds a value to the window, replacing the oldest value if it is full.
+func (w *pointWindow) add(v float64) {
+	if len(w.buf) != cap(w.buf) {
+		w.buf = append(w.buf, v)
+		return
+	}
+	w.buf[w.pos] = v
+	w.pos++
+	if w.pos >= cap(w.buf) {
+		w.pos = 0
+	}
+}
+
+// full returns true if the window holds as many values as its size.
+func (w *pointWindow) full() bool {
+	return len(w.buf) == cap(w.buf)
+}
+
+// median returns the median of a sorted slice.
+func median(a []float64) float64 {
+	if n := len(a); n%2 == 0 {
+		return (a[n/2-1] + a[n/2]) / 2
+	}
+	return a[len(a)/2]
+}
+
+// FloatMedianAbsoluteDeviationReducer calculates the median absolute deviation
+// of the window of the last aggregated points: the median of the absolute
+// differences between the values of the window and their median.
+type FloatMedianAbsoluteDeviationReducer struct {
+	pointWindow
+	time    int64
+	scratch []float64
+}
+
+// NewFloatMedianAbsoluteDeviationReducer creates a new
+// FloatMedianAbsoluteDeviationReducer with a window of n points.
+func NewFloatMedianAbsoluteDeviationReducer(n int) *FloatMedianAbsoluteDeviationReducer {
+	return &FloatMedianAbsoluteDeviationReducer{
+		pointWindow: newPointWindow(n),
+		scratch:     make([]float64, n),
+	}
+}
+
+// AggregateFloat aggregates a point into the reducer and updates the current window.
+func (r *FloatMedianAbsoluteDeviationReducer) AggregateFloat(p *FloatPoint) {
+	r.add(p.Value)
+	r.time = p.Time
+}
+
+// AggregateInteger aggregates a point into the reducer and updates the current window.
+func (r *FloatMedianAbsoluteDeviationReducer) AggregateInteger(p *IntegerPoint) {
+	r.add(float64(p.Value))
+	r.time = p.Time
+}
+
+// AggregateUnsigned aggregates a point into the reducer and updates the current window.
+func (r *FloatMedianAbsoluteDeviationReducer) AggregateUnsigned(p *UnsignedPoint) {
+	r.add(float64(p.Value))
+	r.time = p.Time
+}
+
+// Emit emits the median absolute deviation of the current window. Emit should
+// be called after every call to an aggregate method and it will produce one
+// point if there is enough data to fill a window, otherwise it will produce
+// zero points.
+func (r *FloatMedianAbsoluteDeviationReducer) Emit() []FloatPoint {
+	if !r.full() {
+		return []FloatPoint{}
+	}
+
+	a := r.scratch[:len(r.buf)]
+	copy(a, r.buf)
+	sort.Float64s(a)
+	m := median(a)
+	for i, v := range a {
+		a[i] = math.Abs(v - m)
+	}
+	sort.Float64s(a)
+
+	return []FloatPoint{
+		{
+			Value:      median(a),
+			Time:       r.time,
+			Aggregated: uint32(len(r.buf)),
+		},
+	}
+}
+
+// FloatZScoreReducer calculates the z-score of the aggregated points: the
+// number of standard deviations between the value of a point and the mean of
+// the window of the last points, including the point itself.
+type FloatZScoreReducer struct {
+	pointWindow
+	point FloatPoint
+}
+
+// NewFloatZScoreReducer creates a new FloatZScoreReducer with a window of n
+// points.
+func NewFloatZScoreReducer(n int) *FloatZScoreReducer {
+	return &FloatZScoreReducer{pointWindow: newPointWindow(n)}
+}
+
+// AggregateFloat aggregates a point into the reducer and updates the current window.
+func (r *FloatZScoreReducer) AggregateFloat(p *FloatPoint) {
+	r.add(p.Value)
+	r.point = FloatPoint{Time: p.Time, Value: p.Value}
+}
+
+// AggregateInteger aggregates a point into the reducer and updates the current window.
+func (r *FloatZScoreReducer) AggregateInteger(p *IntegerPoint) {
+	r.add(float64(p.Value))
+	r.point = FloatPoint{Time: p.Time, Value: float64(p.Value)}
+}
+
+// AggregateUnsigned aggregates a point into the reducer and updates the current window.
+func (r *FloatZScoreReducer) AggregateUnsigned(p *UnsignedPoint) {
+	r.add(float64(p.Value))
+	r.point = FloatPoint{Time: p.Time, Value: float64(p.Value)}
+}
+
+// Emit emits the z-score of the current point. Emit should be called after
+// every call to an aggregate method and it will produce one point if there is
+// enough data to fill a window, otherwise it will produce zero points. The
+// z-score of a point in a window of identical values is zero.
+func (r *FloatZScoreReducer) Emit() []FloatPoint {
+	if !r.full() {
+		return []FloatPoint{}
+	}
+
+	var sum float64
+	for _, v := range r.buf {
+		sum += v
+	}
+	mean := sum / float64(len(r.buf))
+
+	// Use the sample standard deviation, like stddev().
+	var variance float64
+	for _, v := range r.buf {
+		variance += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(r.buf)-1))
+
+	var z float64
+	if stddev != 0 {
+		z = (r.point.Value - mean) / stddev
+	}
+	return []FloatPoint{
+		{
+			Value:      z,
+			Time:       r.point.Time,
+			Aggregated: uint32(len(r.buf)),
+		},
+	}
+}
@@ -0,0 +1,119 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxql"
+)
+
+// averageMonth is the average length of a month in the Gregorian calendar.
+// It approximates the duration of calendar intervals wherever a fixed
+// duration is needed, such as when estimating the number of buckets.
+const averageMonth = 2629746 * time.Second
+
+// parseCalendarInterval parses a calendar interval, such as 1mo or 1y, and
+// returns its number of months.
+func parseCalendarInterval(s string) (int, error) {
+	var unit string
+	var months int
+	switch {
+	case strings.HasSuffix(s, "mo"):
+		unit, months = "mo", 1
+	case strings.HasSuffix(s, "y"):
+		unit, months = "y", 12
+	default:
+		return 0, fmt.Errorf("invalid calendar interval: %s", s)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSuffix(s, unit))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid calendar interval: %s", s)
+	}
+	return n * months, nil
+}
+
+// calendarInterval returns the interval of a time() dimension whose first
+// argument is a calendar interval, such as time('1mo') or time('1y', 14d).
+func calendarInterval(call *influxql.Call) (Interval, error) {
+	lit := call.Args[0].(*influxql.StringLiteral)
+	months, err := parseCalendarInterval(lit.Val)
+	if err != nil {
+		return Interval{}, err
+	}
+
+	interval := Interval{
+		Duration: time.Duration(months) * averageMonth,
+		Months:   months,
+	}
+	if len(call.Args) == 2 {
+		offset, ok := call.Args[1].(*influxql.DurationLiteral)
+		if !ok || offset.Val < 0 {
+			return Interval{}, errors.New("calendar time dimension offset must be a positive duration")
+		}
+		interval.Offset = offset.Val
+	}
+	return interval, nil
+}
+
+// groupByInterval returns the interval of the time() dimension of the
+// statement, which may be a calendar interval.
+func groupByInterval(stmt *influxql.SelectStatement) (Interval, error) {
+	for _, d := range stmt.Dimensions {
+		if call, ok := d.Expr.(*influxql.Call); ok && call.Name == "time" && len(call.Args) > 0 {
+			if _, ok := call.Args[0].(*influxql.StringLiteral); ok {
+				return calendarInterval(call)
+			}
+			break
+		}
+	}
+
+	duration, err := stmt.GroupByInterval()
+	if err != nil || duration <= 0 {
+		// Negative intervals are ignored.
+		return Interval{}, err
+	}
+	offset, err := stmt.GroupByOffset()
+	if err != nil {
+		return Interval{}, err
+	}
+	return Interval{Duration: duration, Offset: offset}, nil
+}
+
+// calendarWindow returns the start and end time of the calendar interval
+// containing t. Intervals are counted in months from January 1970 in the
+// location of the options, so yearly intervals start in January and quarters
+// start in January, April, July and October.
+func (opt IteratorOptions) calendarWindow(t int64) (start, end int64) {
+	loc := time.UTC
+	if opt.Location != nil {
+		loc = opt.Location
+	}
+
+	// Subtract the offset to the time so we calculate the correct base interval.
+	ts := time.Unix(0, t).Add(-opt.Interval.Offset).In(loc)
+	month := (ts.Year()-1970)*12 + int(ts.Month()) - 1
+	if m := month % opt.Interval.Months; m < 0 {
+		month -= m + opt.Interval.Months
+	} else {
+		month -= m
+	}
+
+	startTime := time.Date(1970, time.January+time.Month(month), 1, 0, 0, 0, 0, loc).Add(opt.Interval.Offset)
+	endTime := time.Date(1970, time.January+time.Month(month+opt.Interval.Months), 1, 0, 0, 0, 0, loc).Add(opt.Interval.Offset)
+
+	if startTime.Before(time.Unix(0, influxql.MinTime)) {
+		start = influxql.MinTime
+	} else {
+		start = startTime.UnixNano()
+	}
+	if endTime.After(time.Unix(0, influxql.MaxTime)) {
+		end = influxql.MaxTime
+	} else {
+		end = endTime.UnixNano()
+	}
+	return start, end
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/influxdata/influxql"
@@ -52,6 +53,10 @@ func NewCallIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
 		return newLastIterator(input, opt)
 	case "mean":
 		return newMeanIterator(input, opt)
+	case "approx_percentile":
+		return newDigestIterator(input, opt)
+	case "histogram":
+		return newHistogramIterator(input, opt)
 	default:
 		return nil, fmt.Errorf("unsupported function call: %s", name)
 	}
@@ -1131,6 +1136,286 @@ func NewUnsignedPercentileReduceSliceFunc(percentile float64) UnsignedReduceSlic
 	}
 }
 
+// newDigestIterator returns an iterator for operating on an approx_percentile()
+// call. It emits a t-digest of the points of each window, encoded as a string,
+// so that the digests of shards are merged by newDigestMergeIterator.
+func newDigestIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, StringPointEmitter) {
+			fn := NewDigestReducer()
+			return fn, fn
+		}
+		return newFloatReduceStringIterator(input, opt, createFn), nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, StringPointEmitter) {
+			fn := NewDigestReducer()
+			return fn, fn
+		}
+		return newIntegerReduceStringIterator(input, opt, createFn), nil
+	case UnsignedIterator:
+		createFn := func() (UnsignedPointAggregator, StringPointEmitter) {
+			fn := NewDigestReducer()
+			return fn, fn
+		}
+		return newUnsignedReduceStringIterator(input, opt, createFn), nil
+	default:
+		return nil, fmt.Errorf("unsupported approx_percentile iterator type: %T", input)
+	}
+}
+
+// newDigestMergeIterator returns an iterator merging the digests of each
+// window emitted by newDigestIterator.
+func newDigestMergeIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
+	switch input := input.(type) {
+	case StringIterator:
+		createFn := func() (StringPointAggregator, StringPointEmitter) {
+			fn := NewDigestReducer()
+			return fn, fn
+		}
+		return newStringReduceStringIterator(input, opt, createFn), nil
+	default:
+		return nil, fmt.Errorf("unsupported approx_percentile iterator type: %T", input)
+	}
+}
+
+// newApproxPercentileIterator returns an iterator estimating the percentile
+// of each window from the digests emitted by newDigestMergeIterator.
+func newApproxPercentileIterator(input Iterator, opt IteratorOptions, percentile float64) (Iterator, error) {
+	switch input := input.(type) {
+	case StringIterator:
+		createFn := func() (StringPointAggregator, FloatPointEmitter) {
+			fn := NewApproxPercentileReducer(percentile)
+			return fn, fn
+		}
+		return newStringReduceFloatIterator(input, opt, createFn), nil
+	case *nilFloatIterator:
+		return input, nil
+	default:
+		return nil, fmt.Errorf("unsupported approx_percentile iterator type: %T", input)
+	}
+}
+
+// histogramBuckets returns the boundaries of the buckets of a histogram()
+// call with the given arguments. The n buckets between min and max are of
+// equal width, or of equal ratio when the scale is 'log'.
+func histogramBuckets(args []influxql.Expr) ([]float64, error) {
+	if got := len(args); got != 4 && got != 5 {
+		return nil, fmt.Errorf("invalid number of arguments for histogram, expected 4 or 5, got %d", got)
+	}
+
+	var bounds [2]float64
+	for i, arg := range args[1:3] {
+		switch arg := arg.(type) {
+		case *influxql.IntegerLiteral:
+			bounds[i] = float64(arg.Val)
+		case *influxql.NumberLiteral:
+			bounds[i] = arg.Val
+		default:
+			return nil, fmt.Errorf("expected number as argument %d in histogram(), found %s", i+2, arg)
+		}
+	}
+	min, max := bounds[0], bounds[1]
+	if min >= max {
+		return nil, fmt.Errorf("histogram minimum (%v) must be less than its maximum (%v)", min, max)
+	}
+
+	n, ok := args[3].(*influxql.IntegerLiteral)
+	if !ok {
+		return nil, fmt.Errorf("expected integer as argument 4 in histogram(), found %s", args[3])
+	} else if n.Val <= 0 || n.Val > maxHistogramBuckets {
+		return nil, fmt.Errorf("number of buckets (%d) in histogram must be between 1 and %d", n.Val, maxHistogramBuckets)
+	}
+
+	scale := "linear"
+	if len(args) == 5 {
+		lit, ok := args[4].(*influxql.StringLiteral)
+		if !ok {
+			return nil, fmt.Errorf("expected string as argument 5 in histogram(), found %s", args[4])
+		}
+		scale = lit.Val
+	}
+
+	buckets := make([]float64, n.Val+1)
+	switch scale {
+	case "linear":
+		width := (max - min) / float64(n.Val)
+		for i := range buckets {
+			buckets[i] = min + width*float64(i)
+		}
+	case "log":
+		if min <= 0 {
+			return nil, fmt.Errorf("histogram minimum (%v) must be positive with a log scale", min)
+		}
+		ratio := math.Pow(max/min, 1/float64(n.Val))
+		for i := range buckets {
+			buckets[i] = min * math.Pow(ratio, float64(i))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported histogram scale: %s", scale)
+	}
+	// Avoid rounding errors on the last boundary.
+	buckets[n.Val] = max
+	return buckets, nil
+}
+
+// maxHistogramBuckets is the maximum number of buckets of a histogram() call.
+const maxHistogramBuckets = 1000
+
+// newHistogramIterator returns an iterator for operating on a histogram()
+// call. It emits the bucket counts of each window, encoded as a string, so
+// that the counts of shards are added by newHistogramMergeIterator.
+func newHistogramIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
+	buckets, err := histogramBuckets(opt.Expr.(*influxql.Call).Args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, StringPointEmitter) {
+			fn := NewHistogramReducer(buckets)
+			return fn, fn
+		}
+		return newFloatReduceStringIterator(input, opt, createFn), nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, StringPointEmitter) {
+			fn := NewHistogramReducer(buckets)
+			return fn, fn
+		}
+		return newIntegerReduceStringIterator(input, opt, createFn), nil
+	case UnsignedIterator:
+		createFn := func() (UnsignedPointAggregator, StringPointEmitter) {
+			fn := NewHistogramReducer(buckets)
+			return fn, fn
+		}
+		return newUnsignedReduceStringIterator(input, opt, createFn), nil
+	default:
+		return nil, fmt.Errorf("unsupported histogram iterator type: %T", input)
+	}
+}
+
+// newHistogramMergeIterator returns an iterator adding the bucket counts of
+// each window emitted by newHistogramIterator.
+func newHistogramMergeIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
+	buckets, err := histogramBuckets(opt.Expr.(*influxql.Call).Args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch input := input.(type) {
+	case StringIterator:
+		createFn := func() (StringPointAggregator, StringPointEmitter) {
+			fn := NewHistogramReducer(buckets)
+			return fn, fn
+		}
+		return newStringReduceStringIterator(input, opt, createFn), nil
+	default:
+		return nil, fmt.Errorf("unsupported histogram iterator type: %T", input)
+	}
+}
+
+// histogramIterator emits the bucket counts of the windows emitted by
+// newHistogramMergeIterator. The counts of each bucket are emitted as a
+// series tagged with the upper bound of the bucket, so the windows of a
+// series are read before emitting its first bucket.
+type histogramIterator struct {
+	input   *bufStringIterator
+	buckets []float64
+	points  []IntegerPoint
+}
+
+// newHistogramBucketIterator returns an iterator emitting the bucket counts of
+// a histogram() call.
+func newHistogramBucketIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
+	buckets, err := histogramBuckets(opt.Expr.(*influxql.Call).Args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch input := input.(type) {
+	case StringIterator:
+		return &histogramIterator{
+			input:   newBufStringIterator(input),
+			buckets: buckets,
+		}, nil
+	case *nilFloatIterator:
+		return input, nil
+	default:
+		return nil, fmt.Errorf("unsupported histogram iterator type: %T", input)
+	}
+}
+
+// Stats returns stats from the input iterator.
+func (itr *histogramIterator) Stats() IteratorStats { return itr.input.Stats() }
+
+// Close closes the iterator and all child iterators.
+func (itr *histogramIterator) Close() error { return itr.input.Close() }
+
+// Next returns the count of the next bucket.
+func (itr *histogramIterator) Next() (*IntegerPoint, error) {
+	if len(itr.points) == 0 {
+		if err := itr.read(); err != nil || len(itr.points) == 0 {
+			return nil, err
+		}
+	}
+	p := &itr.points[0]
+	itr.points = itr.points[1:]
+	return p, nil
+}
+
+// read reads the windows of the next series and buffers the counts of each of
+// its buckets.
+func (itr *histogramIterator) read() error {
+	var windows []StringPoint
+	for {
+		p, err := itr.input.Next()
+		if err != nil {
+			return err
+		} else if p == nil {
+			break
+		} else if len(windows) > 0 && (p.Name != windows[0].Name || p.Tags.ID() != windows[0].Tags.ID()) {
+			itr.input.unread(p)
+			break
+		}
+		windows = append(windows, *p)
+	}
+	if len(windows) == 0 {
+		return nil
+	}
+
+	n := len(itr.buckets) - 1
+	counts := make([][]uint64, len(windows))
+	for i, w := range windows {
+		c, err := decodeHistogram(w.Value, n)
+		if err != nil {
+			return err
+		}
+		counts[i] = c
+	}
+
+	name, tags := windows[0].Name, windows[0].Tags.KeyValues()
+	itr.points = make([]IntegerPoint, 0, n*len(windows))
+	for i := 0; i < n; i++ {
+		m := make(map[string]string, len(tags)+1)
+		for k, v := range tags {
+			m[k] = v
+		}
+		m["le"] = strconv.FormatFloat(itr.buckets[i+1], 'f', -1, 64)
+		bucketTags := NewTags(m)
+
+		for j, w := range windows {
+			itr.points = append(itr.points, IntegerPoint{
+				Name:  name,
+				Tags:  bucketTags,
+				Time:  w.Time,
+				Value: int64(counts[j][i]),
+			})
+		}
+	}
+	return nil
+}
+
 // newDerivativeIterator returns an iterator for operating on a derivative() call.
 func newDerivativeIterator(input Iterator, opt IteratorOptions, interval Interval, isNonNegative bool) (Iterator, error) {
 	switch input := input.(type) {
@@ -1247,6 +1532,114 @@ func newMovingAverageIterator(input Iterator, n int, opt IteratorOptions) (Itera
 	}
 }
 
+// newMedianAbsoluteDeviationIterator returns an iterator for operating on a
+// median_absolute_deviation() call.
+func newMedianAbsoluteDeviationIterator(input Iterator, n int, opt IteratorOptions) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatMedianAbsoluteDeviationReducer(n)
+			return fn, fn
+		}
+		return newFloatStreamFloatIterator(input, createFn, opt), nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, FloatPointEmitter) {
+			fn := NewFloatMedianAbsoluteDeviationReducer(n)
+			return fn, fn
+		}
+		return newIntegerStreamFloatIterator(input, createFn, opt), nil
+	case UnsignedIterator:
+		createFn := func() (UnsignedPointAggregator, FloatPointEmitter) {
+			fn := NewFloatMedianAbsoluteDeviationReducer(n)
+			return fn, fn
+		}
+		return newUnsignedStreamFloatIterator(input, createFn, opt), nil
+	default:
+		return nil, fmt.Errorf("unsupported median absolute deviation iterator type: %T", input)
+	}
+}
+
+// newZScoreIterator returns an iterator for operating on a zscore() call.
+func newZScoreIterator(input Iterator, n int, opt IteratorOptions) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatZScoreReducer(n)
+			return fn, fn
+		}
+		return newFloatStreamFloatIterator(input, createFn, opt), nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, FloatPointEmitter) {
+			fn := NewFloatZScoreReducer(n)
+			return fn, fn
+		}
+		return newIntegerStreamFloatIterator(input, createFn, opt), nil
+	case UnsignedIterator:
+		createFn := func() (UnsignedPointAggregator, FloatPointEmitter) {
+			fn := NewFloatZScoreReducer(n)
+			return fn, fn
+		}
+		return newUnsignedStreamFloatIterator(input, createFn, opt), nil
+	default:
+		return nil, fmt.Errorf("unsupported zscore iterator type: %T", input)
+	}
+}
+
+// newExponentialMovingAverageIterator returns an iterator for operating on an
+// exponential_moving_average(), double_exponential_moving_average() or
+// triple_exponential_moving_average() call.
+func newExponentialMovingAverageIterator(input Iterator, order, period, hold int, warmup EMAWarmup, opt IteratorOptions) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatExponentialMovingAverageReducer(order, period, hold, warmup)
+			return fn, fn
+		}
+		return newFloatStreamFloatIterator(input, createFn, opt), nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, FloatPointEmitter) {
+			fn := NewFloatExponentialMovingAverageReducer(order, period, hold, warmup)
+			return fn, fn
+		}
+		return newIntegerStreamFloatIterator(input, createFn, opt), nil
+	case UnsignedIterator:
+		createFn := func() (UnsignedPointAggregator, FloatPointEmitter) {
+			fn := NewFloatExponentialMovingAverageReducer(order, period, hold, warmup)
+			return fn, fn
+		}
+		return newUnsignedStreamFloatIterator(input, createFn, opt), nil
+	default:
+		return nil, fmt.Errorf("unsupported exponential moving average iterator type: %T", input)
+	}
+}
+
+// newKaufmansAdaptiveMovingAverageIterator returns an iterator for operating
+// on a kaufmans_adaptive_moving_average() call.
+func newKaufmansAdaptiveMovingAverageIterator(input Iterator, period, hold int, opt IteratorOptions) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatKaufmansAdaptiveMovingAverageReducer(period, hold)
+			return fn, fn
+		}
+		return newFloatStreamFloatIterator(input, createFn, opt), nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, FloatPointEmitter) {
+			fn := NewFloatKaufmansAdaptiveMovingAverageReducer(period, hold)
+			return fn, fn
+		}
+		return newIntegerStreamFloatIterator(input, createFn, opt), nil
+	case UnsignedIterator:
+		createFn := func() (UnsignedPointAggregator, FloatPointEmitter) {
+			fn := NewFloatKaufmansAdaptiveMovingAverageReducer(period, hold)
+			return fn, fn
+		}
+		return newUnsignedStreamFloatIterator(input, createFn, opt), nil
+	default:
+		return nil, fmt.Errorf("unsupported kaufmans adaptive moving average iterator type: %T", input)
+	}
+}
+
 // newCumulativeSumIterator returns an iterator for operating on a cumulative_sum() call.
 func newCumulativeSumIterator(input Iterator, opt IteratorOptions) (Iterator, error) {
 	switch input := input.(type) {
@@ -1273,6 +1666,59 @@ func newCumulativeSumIterator(input Iterator, opt IteratorOptions) (Iterator, er
 	}
 }
 
+// lagOffset returns the offset of a validated lag() or lead() call, either a
+// number of points or a duration. The offset defaults to one point.
+func lagOffset(call *influxql.Call) (n int, d time.Duration) {
+	if len(call.Args) == 1 {
+		return 1, 0
+	}
+	switch arg1 := call.Args[1].(type) {
+	case *influxql.IntegerLiteral:
+		return int(arg1.Val), 0
+	case *influxql.DurationLiteral:
+		return 0, arg1.Val
+	}
+	return 1, 0
+}
+
+// newLagIterator returns an iterator for operating on a lag() or lead() call.
+func newLagIterator(input Iterator, n int, d time.Duration, lookback bool, opt IteratorOptions) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		createFn := func() (FloatPointAggregator, FloatPointEmitter) {
+			fn := NewFloatLagReducer(n, d, lookback, opt.Ascending)
+			return fn, fn
+		}
+		return newFloatStreamFloatIterator(input, createFn, opt), nil
+	case IntegerIterator:
+		createFn := func() (IntegerPointAggregator, IntegerPointEmitter) {
+			fn := NewIntegerLagReducer(n, d, lookback, opt.Ascending)
+			return fn, fn
+		}
+		return newIntegerStreamIntegerIterator(input, createFn, opt), nil
+	case UnsignedIterator:
+		createFn := func() (UnsignedPointAggregator, UnsignedPointEmitter) {
+			fn := NewUnsignedLagReducer(n, d, lookback, opt.Ascending)
+			return fn, fn
+		}
+		return newUnsignedStreamUnsignedIterator(input, createFn, opt), nil
+	case StringIterator:
+		createFn := func() (StringPointAggregator, StringPointEmitter) {
+			fn := NewStringLagReducer(n, d, lookback, opt.Ascending)
+			return fn, fn
+		}
+		return newStringStreamStringIterator(input, createFn, opt), nil
+	case BooleanIterator:
+		createFn := func() (BooleanPointAggregator, BooleanPointEmitter) {
+			fn := NewBooleanLagReducer(n, d, lookback, opt.Ascending)
+			return fn, fn
+		}
+		return newBooleanStreamBooleanIterator(input, createFn, opt), nil
+	default:
+		return nil, fmt.Errorf("unsupported lag iterator type: %T", input)
+	}
+}
+
 // newHoltWintersIterator returns an iterator for operating on a holt_winters() call.
 func newHoltWintersIterator(input Iterator, opt IteratorOptions, h, m int, includeFitData bool, interval time.Duration) (Iterator, error) {
 	switch input := input.(type) {
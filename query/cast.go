@@ -1,5 +1,14 @@
 package query
 
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/influxql"
+)
+
 func castToFloat(v interface{}) float64 {
 	switch v := v.(type) {
 	case float64:
@@ -56,3 +65,329 @@ func castToBoolean(v interface{}) bool {
 		return false
 	}
 }
+
+// isCastFunction returns true if the call is a call to cast(). A cast is
+// evaluated on every point of its argument, which is a field, a tag or an
+// aggregate, and may also be used in conditions.
+func isCastFunction(call *influxql.Call) bool {
+	return call.Name == "cast"
+}
+
+// validateCast validates the arguments of a cast() call.
+func validateCast(call *influxql.Call) error {
+	if exp, got := 2, len(call.Args); got != exp {
+		return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", call.Name, exp, got)
+	} else if _, ok := call.Args[0].(influxql.Literal); ok {
+		return fmt.Errorf("expected field argument in %s()", call.Name)
+	}
+
+	lit, ok := call.Args[1].(*influxql.StringLiteral)
+	if !ok {
+		return fmt.Errorf("expected type as second argument in %s(), found %s", call.Name, call.Args[1])
+	} else if castType(call) == influxql.Unknown {
+		return fmt.Errorf("cannot cast to %s, expected float, integer, unsigned, string or boolean", lit.Val)
+	}
+	return nil
+}
+
+// castType returns the type a cast() call casts to, or Unknown if it isn't a
+// type values can be cast to.
+func castType(call *influxql.Call) influxql.DataType {
+	lit, ok := call.Args[1].(*influxql.StringLiteral)
+	if !ok {
+		return influxql.Unknown
+	}
+	switch strings.ToLower(lit.Val) {
+	case "float":
+		return influxql.Float
+	case "integer":
+		return influxql.Integer
+	case "unsigned":
+		return influxql.Unsigned
+	case "string":
+		return influxql.String
+	case "boolean":
+		return influxql.Boolean
+	}
+	return influxql.Unknown
+}
+
+// castValue converts a value to a type. It returns nil if the value is nil or
+// cannot be represented by the type:
+//
+// Floats are truncated toward zero when cast to integers, and NaN or values
+// out of the range of the integer type are nil. Booleans are cast to 1 and 0,
+// and numbers to true if they are not zero. Strings are parsed, and are nil if
+// they are not a valid number or boolean. An integer string is parsed exactly,
+// and any other number is parsed as a float and then cast.
+func castValue(v interface{}, typ influxql.DataType) interface{} {
+	switch v := v.(type) {
+	case float64:
+		return castFloat(v, typ)
+	case int64:
+		switch typ {
+		case influxql.Float:
+			return float64(v)
+		case influxql.Integer:
+			return v
+		case influxql.Unsigned:
+			if v < 0 {
+				return nil
+			}
+			return uint64(v)
+		case influxql.String:
+			return strconv.FormatInt(v, 10)
+		case influxql.Boolean:
+			return v != 0
+		}
+	case uint64:
+		switch typ {
+		case influxql.Float:
+			return float64(v)
+		case influxql.Integer:
+			if v > math.MaxInt64 {
+				return nil
+			}
+			return int64(v)
+		case influxql.Unsigned:
+			return v
+		case influxql.String:
+			return strconv.FormatUint(v, 10)
+		case influxql.Boolean:
+			return v != 0
+		}
+	case string:
+		return castString(v, typ)
+	case bool:
+		if typ == influxql.String {
+			return strconv.FormatBool(v)
+		} else if typ == influxql.Boolean {
+			return v
+		}
+		var i int64
+		if v {
+			i = 1
+		}
+		return castValue(i, typ)
+	}
+	return nil
+}
+
+// castFloat converts a float to a type.
+func castFloat(v float64, typ influxql.DataType) interface{} {
+	switch typ {
+	case influxql.Float:
+		return v
+	case influxql.String:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	if math.IsNaN(v) {
+		return nil
+	}
+	switch typ {
+	case influxql.Integer:
+		// The bounds are the nearest floats beyond the range of int64.
+		if v = math.Trunc(v); v < -(1<<63) || v >= 1<<63 {
+			return nil
+		}
+		return int64(v)
+	case influxql.Unsigned:
+		if v = math.Trunc(v); v < 0 || v >= 1<<64 {
+			return nil
+		}
+		return uint64(v)
+	case influxql.Boolean:
+		return v != 0
+	}
+	return nil
+}
+
+// castString parses a string as a type.
+func castString(s string, typ influxql.DataType) interface{} {
+	s = strings.TrimSpace(s)
+	switch typ {
+	case influxql.String:
+		return s
+	case influxql.Boolean:
+		if v, err := strconv.ParseBool(s); err == nil {
+			return v
+		}
+		return nil
+	case influxql.Integer:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v
+		}
+	case influxql.Unsigned:
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return v
+		}
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+	return castFloat(v, typ)
+}
+
+// evalCast evaluates a cast() call whose argument is a reference to the values
+// in m or a literal.
+func evalCast(call *influxql.Call, m map[string]interface{}) interface{} {
+	return castValue(evalValue(call.Args[0], m), castType(call))
+}
+
+// evalValue returns the value of a reference to the values in m or of a
+// literal.
+func evalValue(expr influxql.Expr, m map[string]interface{}) interface{} {
+	switch expr := expr.(type) {
+	case *influxql.VarRef:
+		return m[expr.Val]
+	case *influxql.NumberLiteral:
+		return expr.Val
+	case *influxql.IntegerLiteral:
+		return expr.Val
+	case *influxql.UnsignedLiteral:
+		return expr.Val
+	case *influxql.StringLiteral:
+		return expr.Val
+	case *influxql.BooleanLiteral:
+		return expr.Val
+	case *influxql.ParenExpr:
+		return evalValue(expr.Expr, m)
+	}
+	return nil
+}
+
+// rewriteCastFields reads the fields cast to another type with their type in
+// each shard, instead of the type the field has in all of the shards, so the
+// values of a field whose type changed over time are all cast. Only the
+// fields read from measurements can be read with any type.
+func rewriteCastFields(stmt *influxql.SelectStatement) {
+	for _, source := range stmt.Sources {
+		if _, ok := source.(*influxql.Measurement); !ok {
+			return
+		}
+	}
+
+	influxql.WalkFunc(stmt.Fields, func(n influxql.Node) {
+		call, ok := n.(*influxql.Call)
+		if !ok || !isCastFunction(call) {
+			return
+		}
+		if ref, ok := call.Args[0].(*influxql.VarRef); ok {
+			switch ref.Type {
+			case influxql.Float, influxql.Integer, influxql.Unsigned, influxql.String, influxql.Boolean:
+				ref.Type = influxql.AnyField
+			}
+		}
+	})
+}
+
+// newCastIterator returns an iterator casting the points of input to a type.
+// The points that cannot be cast are nil.
+func newCastIterator(input Iterator, typ influxql.DataType) (Iterator, error) {
+	switch typ {
+	case influxql.Float:
+		return &castFloatIterator{input: input}, nil
+	case influxql.Integer:
+		return &castIntegerIterator{input: input}, nil
+	case influxql.Unsigned:
+		return &castUnsignedIterator{input: input}, nil
+	case influxql.String:
+		return &castStringIterator{input: input}, nil
+	case influxql.Boolean:
+		return &castBooleanIterator{input: input}, nil
+	}
+	return nil, fmt.Errorf("cannot cast to %s", typ)
+}
+
+type castFloatIterator struct {
+	input Iterator
+	point FloatPoint
+}
+
+func (itr *castFloatIterator) Stats() IteratorStats { return itr.input.Stats() }
+func (itr *castFloatIterator) Close() error         { return itr.input.Close() }
+func (itr *castFloatIterator) Next() (*FloatPoint, error) {
+	p, err := nextPoint(itr.input)
+	if p == nil || err != nil {
+		return nil, err
+	}
+
+	v, ok := castValue(p.value(), influxql.Float).(float64)
+	itr.point = FloatPoint{Name: p.name(), Tags: p.tags(), Time: p.time(), Value: v, Nil: !ok, Aux: p.aux()}
+	return &itr.point, nil
+}
+
+type castIntegerIterator struct {
+	input Iterator
+	point IntegerPoint
+}
+
+func (itr *castIntegerIterator) Stats() IteratorStats { return itr.input.Stats() }
+func (itr *castIntegerIterator) Close() error         { return itr.input.Close() }
+func (itr *castIntegerIterator) Next() (*IntegerPoint, error) {
+	p, err := nextPoint(itr.input)
+	if p == nil || err != nil {
+		return nil, err
+	}
+
+	v, ok := castValue(p.value(), influxql.Integer).(int64)
+	itr.point = IntegerPoint{Name: p.name(), Tags: p.tags(), Time: p.time(), Value: v, Nil: !ok, Aux: p.aux()}
+	return &itr.point, nil
+}
+
+type castUnsignedIterator struct {
+	input Iterator
+	point UnsignedPoint
+}
+
+func (itr *castUnsignedIterator) Stats() IteratorStats { return itr.input.Stats() }
+func (itr *castUnsignedIterator) Close() error         { return itr.input.Close() }
+func (itr *castUnsignedIterator) Next() (*UnsignedPoint, error) {
+	p, err := nextPoint(itr.input)
+	if p == nil || err != nil {
+		return nil, err
+	}
+
+	v, ok := castValue(p.value(), influxql.Unsigned).(uint64)
+	itr.point = UnsignedPoint{Name: p.name(), Tags: p.tags(), Time: p.time(), Value: v, Nil: !ok, Aux: p.aux()}
+	return &itr.point, nil
+}
+
+type castStringIterator struct {
+	input Iterator
+	point StringPoint
+}
+
+func (itr *castStringIterator) Stats() IteratorStats { return itr.input.Stats() }
+func (itr *castStringIterator) Close() error         { return itr.input.Close() }
+func (itr *castStringIterator) Next() (*StringPoint, error) {
+	p, err := nextPoint(itr.input)
+	if p == nil || err != nil {
+		return nil, err
+	}
+
+	v, ok := castValue(p.value(), influxql.String).(string)
+	itr.point = StringPoint{Name: p.name(), Tags: p.tags(), Time: p.time(), Value: v, Nil: !ok, Aux: p.aux()}
+	return &itr.point, nil
+}
+
+type castBooleanIterator struct {
+	input Iterator
+	point BooleanPoint
+}
+
+func (itr *castBooleanIterator) Stats() IteratorStats { return itr.input.Stats() }
+func (itr *castBooleanIterator) Close() error         { return itr.input.Close() }
+func (itr *castBooleanIterator) Next() (*BooleanPoint, error) {
+	p, err := nextPoint(itr.input)
+	if p == nil || err != nil {
+		return nil, err
+	}
+
+	v, ok := castValue(p.value(), influxql.Boolean).(bool)
+	itr.point = BooleanPoint{Name: p.name(), Tags: p.tags(), Time: p.time(), Value: v, Nil: !ok, Aux: p.aux()}
+	return &itr.point, nil
+}
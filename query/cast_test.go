@@ -0,0 +1,42 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/influxdata/influxdb/query"
+)
+
+func TestEvalBool_Cast(t *testing.T) {
+	m := map[string]interface{}{
+		"f": float64(-2.5),
+		"i": int64(-3),
+		"u": uint64(1 << 63),
+		"s": " 12 ",
+		"b": true,
+	}
+
+	for _, tt := range []struct {
+		cond string
+		want bool
+	}{
+		{cond: `cast(f, 'integer') = -2`, want: true},
+		{cond: `cast(f, 'string') = '-2.5'`, want: true},
+		{cond: `cast(f, 'boolean') = true`, want: true},
+		{cond: `cast(i, 'unsigned') = 0`, want: false},
+		{cond: `cast(u, 'integer') = 0`, want: false},
+		{cond: `cast(u, 'string') = '9223372036854775808'`, want: true},
+		{cond: `cast(s, 'integer') = 12`, want: true},
+		{cond: `cast(s, 'float') > 11.5`, want: true},
+		{cond: `cast(s, 'boolean') = true`, want: false},
+		{cond: `cast(b, 'integer') = 1`, want: true},
+		{cond: `cast(b, 'STRING') = 'true'`, want: true},
+		{cond: `cast(missing, 'integer') = 0`, want: false},
+		{cond: `strlen(cast(i, 'string')) = 2`, want: true},
+	} {
+		t.Run(tt.cond, func(t *testing.T) {
+			if got := query.EvalBool(MustParseExpr(tt.cond), m); got != tt.want {
+				t.Fatalf("unexpected result: got=%v want=%v", got, tt.want)
+			}
+		})
+	}
+}
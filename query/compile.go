@@ -52,6 +52,9 @@ type compiledStatement struct {
 	// HasDistinct is set when the distinct() function is encountered.
 	HasDistinct bool
 
+	// HasHistogram is set when the histogram() function is encountered.
+	HasHistogram bool
+
 	// FillOption contains the fill option for aggregates.
 	FillOption influxql.FillOption
 
@@ -122,14 +125,41 @@ func (c *compiledStatement) preprocess(stmt *influxql.SelectStatement) error {
 	c.Limit = stmt.Limit
 	c.HasTarget = stmt.Target != nil
 
+	condition := stmt.Condition
+	if condition != nil {
+		condition = rewriteRegexConditions(influxql.CloneExpr(condition))
+	}
+
 	valuer := influxql.NowValuer{Now: c.Options.Now, Location: stmt.Location}
-	cond, t, err := influxql.ConditionExpr(stmt.Condition, &valuer)
+	cond, t, err := influxql.ConditionExpr(condition, &valuer)
 	if err != nil {
 		return err
 	}
 	c.Condition = cond
 	c.TimeRange = t
 
+	// String functions and casts are the only functions evaluated in
+	// conditions.
+	if cond != nil {
+		var err error
+		influxql.WalkFunc(cond, func(n influxql.Node) {
+			call, ok := n.(*influxql.Call)
+			if !ok || err != nil {
+				return
+			} else if isCastFunction(call) {
+				err = validateCast(call)
+				return
+			} else if !isStringFunction(call) {
+				err = fmt.Errorf("unsupported function %s() in condition", call.Name)
+				return
+			}
+			err = validateStringFunction(call)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	// Read the dimensions of the query, validate them, and retrieve the interval
 	// if it exists.
 	if err := c.compileDimensions(stmt); err != nil {
@@ -244,12 +274,31 @@ func (c *compiledField) compileExpr(expr influxql.Expr) error {
 		c.global.HasAuxiliaryFields = true
 		return nil
 	case *influxql.Call:
+		// Math and string functions and casts are not aggregates and are not
+		// registered.
+		if isMathFunction(expr) {
+			return c.compileMathFunction(expr)
+		} else if isStringFunction(expr) {
+			return c.compileStringFunction(expr)
+		} else if isCastFunction(expr) {
+			return c.compileCast(expr)
+		}
+
+		// Conditional aggregates are compiled as their aggregate.
+		if inner, cond, ok := conditionalCall(expr); ok {
+			return c.compileConditional(expr, inner, cond)
+		}
+
 		// Register the function call in the list of function calls.
 		c.global.FunctionCalls = append(c.global.FunctionCalls, expr)
 
 		switch expr.Name {
 		case "percentile":
 			return c.compilePercentile(expr.Args)
+		case "approx_percentile":
+			return c.compileApproxPercentile(expr.Args)
+		case "histogram":
+			return c.compileHistogram(expr.Args)
 		case "sample":
 			return c.compileSample(expr.Args)
 		case "distinct":
@@ -266,6 +315,12 @@ func (c *compiledField) compileExpr(expr influxql.Expr) error {
 			return c.compileCumulativeSum(expr.Args)
 		case "moving_average":
 			return c.compileMovingAverage(expr.Args)
+		case "median_absolute_deviation", "zscore":
+			return c.compileAnomalyFunction(expr.Name, expr.Args)
+		case "lag", "lead":
+			return c.compileLag(expr.Name, expr.Args)
+		case "exponential_moving_average", "double_exponential_moving_average", "triple_exponential_moving_average", "kaufmans_adaptive_moving_average":
+			return c.compileExponentialMovingAverage(expr.Name, expr.Args)
 		case "elapsed":
 			return c.compileElapsed(expr.Args)
 		case "integral":
@@ -361,6 +416,62 @@ func (c *compiledField) compileFunction(expr *influxql.Call) error {
 	return c.compileSymbol(expr.Name, expr.Args[0])
 }
 
+func (c *compiledField) compileMathFunction(expr *influxql.Call) error {
+	// Disallow wildcards in math functions for the same reason as binary
+	// expressions.
+	c.AllowWildcard = false
+
+	exp := 1
+	if expr.Name == "log" || expr.Name == "pow" {
+		exp = 2
+	}
+	if got := len(expr.Args); exp != got {
+		return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", expr.Name, exp, got)
+	}
+
+	if exp == 2 {
+		switch expr.Args[1].(type) {
+		case *influxql.IntegerLiteral, *influxql.NumberLiteral:
+		default:
+			return fmt.Errorf("expected number as second argument in %s(), found %s", expr.Name, expr.Args[1])
+		}
+	}
+
+	if _, ok := expr.Args[0].(influxql.Literal); ok {
+		return fmt.Errorf("expected field argument in %s()", expr.Name)
+	}
+	return c.compileExpr(expr.Args[0])
+}
+
+func (c *compiledField) compileStringFunction(expr *influxql.Call) error {
+	// Disallow wildcards in string functions for the same reason as binary
+	// expressions.
+	c.AllowWildcard = false
+
+	if err := validateStringFunction(expr); err != nil {
+		return err
+	}
+	for _, arg := range expr.Args {
+		if _, ok := arg.(influxql.Literal); ok {
+			continue
+		}
+		if err := c.compileExpr(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiledField) compileCast(expr *influxql.Call) error {
+	// Disallow wildcards in casts for the same reason as binary expressions.
+	c.AllowWildcard = false
+
+	if err := validateCast(expr); err != nil {
+		return err
+	}
+	return c.compileExpr(expr.Args[0])
+}
+
 func (c *compiledField) compilePercentile(args []influxql.Expr) error {
 	if exp, got := 2, len(args); got != exp {
 		return fmt.Errorf("invalid number of arguments for percentile, expected %d, got %d", exp, got)
@@ -375,6 +486,45 @@ func (c *compiledField) compilePercentile(args []influxql.Expr) error {
 	return c.compileSymbol("percentile", args[0])
 }
 
+func (c *compiledField) compileApproxPercentile(args []influxql.Expr) error {
+	if exp, got := 2, len(args); got != exp {
+		return fmt.Errorf("invalid number of arguments for approx_percentile, expected %d, got %d", exp, got)
+	}
+
+	var percentile float64
+	switch arg1 := args[1].(type) {
+	case *influxql.IntegerLiteral:
+		percentile = float64(arg1.Val)
+	case *influxql.NumberLiteral:
+		percentile = arg1.Val
+	default:
+		return fmt.Errorf("expected float argument in approx_percentile()")
+	}
+	if percentile < 0 || percentile > 100 {
+		return fmt.Errorf("approx_percentile must be between 0 and 100, got %v", percentile)
+	}
+
+	// Percentiles are estimated, so they are not the values of points.
+	c.global.OnlySelectors = false
+	return c.compileSymbol("approx_percentile", args[0])
+}
+
+func (c *compiledField) compileHistogram(args []influxql.Expr) error {
+	if _, err := histogramBuckets(args); err != nil {
+		return err
+	}
+
+	// Wildcards would expand into a histogram() call for each field.
+	if _, ok := args[0].(*influxql.VarRef); !ok {
+		return fmt.Errorf("expected field argument in histogram()")
+	}
+
+	// The counts of buckets are not the values of points.
+	c.global.OnlySelectors = false
+	c.global.HasHistogram = true
+	return nil
+}
+
 func (c *compiledField) compileSample(args []influxql.Expr) error {
 	if exp, got := 2, len(args); got != exp {
 		return fmt.Errorf("invalid number of arguments for sample, expected %d, got %d", exp, got)
@@ -539,6 +689,129 @@ func (c *compiledField) compileMovingAverage(args []influxql.Expr) error {
 	}
 }
 
+func (c *compiledField) compileAnomalyFunction(name string, args []influxql.Expr) error {
+	if got := len(args); got != 2 {
+		return fmt.Errorf("invalid number of arguments for %s, expected 2, got %d", name, got)
+	}
+
+	switch arg1 := args[1].(type) {
+	case *influxql.IntegerLiteral:
+		if arg1.Val <= 1 {
+			return fmt.Errorf("%s window must be greater than 1, got %d", name, arg1.Val)
+		}
+	default:
+		return fmt.Errorf("second argument for %s must be an integer, got %T", name, args[1])
+	}
+	c.global.OnlySelectors = false
+
+	// Must be a variable reference, function, wildcard, or regexp.
+	switch arg0 := args[0].(type) {
+	case *influxql.Call:
+		if c.global.Interval.IsZero() {
+			return fmt.Errorf("%s aggregate requires a GROUP BY interval", name)
+		}
+		return c.compileExpr(arg0)
+	default:
+		if !c.global.Interval.IsZero() {
+			return fmt.Errorf("aggregate function required inside the call to %s", name)
+		}
+		return c.compileSymbol(name, arg0)
+	}
+}
+
+func (c *compiledField) compileLag(name string, args []influxql.Expr) error {
+	if min, max, got := 1, 2, len(args); got > max || got < min {
+		return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", name, min, max, got)
+	}
+
+	// The offset is either a number of points or a duration.
+	if len(args) == 2 {
+		switch arg1 := args[1].(type) {
+		case *influxql.IntegerLiteral:
+			if arg1.Val < 1 {
+				return fmt.Errorf("%s offset must be greater than or equal to 1, got %d", name, arg1.Val)
+			}
+		case *influxql.DurationLiteral:
+			if arg1.Val <= 0 {
+				return fmt.Errorf("duration argument must be positive, got %s", influxql.FormatDuration(arg1.Val))
+			}
+		default:
+			return fmt.Errorf("second argument for %s must be an integer or a duration, got %T", name, args[1])
+		}
+	}
+	c.global.OnlySelectors = false
+
+	// Must be a variable reference, function, wildcard, or regexp.
+	switch arg0 := args[0].(type) {
+	case *influxql.Call:
+		if c.global.Interval.IsZero() {
+			return fmt.Errorf("%s aggregate requires a GROUP BY interval", name)
+		}
+		return c.compileExpr(arg0)
+	default:
+		if !c.global.Interval.IsZero() {
+			return fmt.Errorf("aggregate function required inside the call to %s", name)
+		}
+		return c.compileSymbol(name, arg0)
+	}
+}
+
+func (c *compiledField) compileExponentialMovingAverage(name string, args []influxql.Expr) error {
+	max := 4
+	if name == "kaufmans_adaptive_moving_average" {
+		max = 3
+	}
+	if min, got := 2, len(args); got > max || got < min {
+		return fmt.Errorf("invalid number of arguments for %s, expected at least %d but no more than %d, got %d", name, min, max, got)
+	}
+
+	switch arg1 := args[1].(type) {
+	case *influxql.IntegerLiteral:
+		if arg1.Val < 1 {
+			return fmt.Errorf("%s period must be greater than or equal to 1, got %d", name, arg1.Val)
+		}
+	default:
+		return fmt.Errorf("second argument for %s must be an integer, got %T", name, args[1])
+	}
+
+	if len(args) > 2 {
+		switch arg2 := args[2].(type) {
+		case *influxql.IntegerLiteral:
+			if arg2.Val < 0 {
+				return fmt.Errorf("%s hold period must be greater than or equal to 0, got %d", name, arg2.Val)
+			}
+		default:
+			return fmt.Errorf("third argument for %s must be an integer, got %T", name, args[2])
+		}
+	}
+
+	if len(args) > 3 {
+		switch arg3 := args[3].(type) {
+		case *influxql.StringLiteral:
+			if _, err := ParseEMAWarmup(arg3.Val); err != nil {
+				return fmt.Errorf("%s warmup type must be exponential, simple or none, got %s", name, arg3.Val)
+			}
+		default:
+			return fmt.Errorf("fourth argument for %s must be a string, got %T", name, args[3])
+		}
+	}
+	c.global.OnlySelectors = false
+
+	// Must be a variable reference, function, wildcard, or regexp.
+	switch arg0 := args[0].(type) {
+	case *influxql.Call:
+		if c.global.Interval.IsZero() {
+			return fmt.Errorf("%s aggregate requires a GROUP BY interval", name)
+		}
+		return c.compileExpr(arg0)
+	default:
+		if !c.global.Interval.IsZero() {
+			return fmt.Errorf("aggregate function required inside the call to %s", name)
+		}
+		return c.compileSymbol(name, arg0)
+	}
+}
+
 func (c *compiledField) compileIntegral(args []influxql.Expr) error {
 	if min, max, got := 1, 2, len(args); got > max || got < min {
 		return fmt.Errorf("invalid number of arguments for integral, expected at least %d but no more than %d, got %d", min, max, got)
@@ -671,6 +944,16 @@ func (c *compiledStatement) compileDimensions(stmt *influxql.SelectStatement) er
 				return errors.New("only time() calls allowed in dimensions")
 			} else if got := len(expr.Args); got < 1 || got > 2 {
 				return errors.New("time dimension expected 1 or 2 arguments")
+			} else if _, ok := expr.Args[0].(*influxql.StringLiteral); ok {
+				// Calendar intervals, such as time('1mo'), are given as strings.
+				if c.Interval.Duration != 0 {
+					return errors.New("multiple time dimensions not allowed")
+				}
+				interval, err := calendarInterval(expr)
+				if err != nil {
+					return err
+				}
+				c.Interval = interval
 			} else if lit, ok := expr.Args[0].(*influxql.DurationLiteral); !ok {
 				return errors.New("time dimension must have duration argument")
 			} else if c.Interval.Duration != 0 {
@@ -741,6 +1024,11 @@ func (c *compiledStatement) validateFields() error {
 	if c.HasDistinct && (len(c.FunctionCalls) != 1 || c.HasAuxiliaryFields) {
 		return errors.New("aggregate function distinct() cannot be combined with other functions or fields")
 	}
+	// The buckets of a histogram() call are emitted as separate series, so it
+	// must be the only field.
+	if c.HasHistogram && (len(c.FunctionCalls) != 1 || len(c.Fields) != 1) {
+		return errors.New("aggregate function histogram() cannot be combined with other functions or fields")
+	}
 	// Validate we are using a selector or raw query if auxiliary fields are required.
 	if c.HasAuxiliaryFields {
 		if !c.OnlySelectors {
@@ -762,9 +1050,13 @@ func (c *compiledStatement) subquery(stmt *influxql.SelectStatement) error {
 
 	// Substitute now() into the subquery condition. Then use ConditionExpr to
 	// validate the expression. Do not store the results. We have no way to store
-	// and read those results at the moment.
+	// and read those results at the moment. The regex calls of the condition
+	// are rewritten like the condition of the statement.
 	valuer := influxql.NowValuer{Now: c.Options.Now, Location: stmt.Location}
 	stmt.Condition = influxql.Reduce(stmt.Condition, &valuer)
+	if stmt.Condition != nil {
+		stmt.Condition = rewriteRegexConditions(stmt.Condition)
+	}
 
 	// If the ordering is different and the sort field was specified for the subquery,
 	// throw an error.
@@ -798,49 +1090,57 @@ func (c *compiledStatement) Prepare(shardMapper ShardMapper, sopt SelectOptions)
 	// the select statement. Determine the shard time range here.
 	timeRange := c.TimeRange
 	if sopt.MaxBucketsN > 0 && !c.stmt.IsRawQuery && timeRange.MinTimeNano() == influxql.MinTime {
-		interval, err := c.stmt.GroupByInterval()
-		if err != nil {
-			return nil, err
-		}
-
-		offset, err := c.stmt.GroupByOffset()
+		interval, err := groupByInterval(c.stmt)
 		if err != nil {
 			return nil, err
 		}
 
-		if interval > 0 {
+		if !interval.IsZero() {
 			// Determine the last bucket using the end time.
 			opt := IteratorOptions{
-				Interval: Interval{
-					Duration: interval,
-					Offset:   offset,
-				},
+				Interval: interval,
 			}
 			last, _ := opt.Window(c.TimeRange.MaxTimeNano() - 1)
 
 			// Determine the time difference using the number of buckets.
 			// Determine the maximum difference between the buckets based on the end time.
 			maxDiff := last - models.MinNanoTime
-			if maxDiff/int64(interval) > int64(sopt.MaxBucketsN) {
+			if maxDiff/int64(interval.Duration) > int64(sopt.MaxBucketsN) {
 				timeRange.Min = time.Unix(0, models.MinNanoTime)
 			} else {
-				timeRange.Min = time.Unix(0, last-int64(interval)*int64(sopt.MaxBucketsN-1))
+				timeRange.Min = time.Unix(0, last-int64(interval.Duration)*int64(sopt.MaxBucketsN-1))
 			}
 		}
 	}
 
+	// Include the shards within the fill lookback before the time range if
+	// the fill is seeded with the points before it.
+	if sopt.FillLookback > 0 && !c.Interval.IsZero() && c.Ascending && c.TimeRange.MinTimeNano() > influxql.MinTime {
+		if c.FillOption == influxql.PreviousFill || c.FillOption == influxql.LinearFill {
+			timeRange.Min = timeRange.Min.Add(-sopt.FillLookback)
+		}
+	}
+
 	// Create an iterator creator based on the shards in the cluster.
 	shards, err := shardMapper.MapShards(c.stmt.Sources, timeRange, sopt)
 	if err != nil {
 		return nil, err
 	}
 
+	// Joins are validated once the shards are mapped, as a field may be named
+	// like a field qualified with a measurement.
+	if err := validateJoins(c.stmt, shards); err != nil {
+		shards.Close()
+		return nil, err
+	}
+
 	// Rewrite wildcards, if any exist.
 	stmt, err := c.stmt.RewriteFields(shards)
 	if err != nil {
 		shards.Close()
 		return nil, err
 	}
+	rewriteCastFields(stmt)
 
 	// Determine base options for iterators.
 	opt, err := newIteratorOptionsStmt(stmt, sopt)
@@ -852,13 +1152,7 @@ func (c *compiledStatement) Prepare(shardMapper ShardMapper, sopt SelectOptions)
 	opt.Ascending = c.Ascending
 
 	if sopt.MaxBucketsN > 0 && !stmt.IsRawQuery && c.TimeRange.MinTimeNano() > influxql.MinTime {
-		interval, err := stmt.GroupByInterval()
-		if err != nil {
-			shards.Close()
-			return nil, err
-		}
-
-		if interval > 0 {
+		if interval := opt.Interval.Duration; interval > 0 {
 			// Determine the start and end time matched to the interval (may not match the actual times).
 			first, _ := opt.Window(opt.StartTime)
 			last, _ := opt.Window(opt.EndTime - 1)
@@ -65,6 +65,28 @@ func TestCompile_Success(t *testing.T) {
 		`SELECT max(bottom) FROM (SELECT bottom(value, host, 1) FROM cpu) GROUP BY region`,
 		`SELECT percentile(value, 75) FROM cpu`,
 		`SELECT percentile(value, 75.0) FROM cpu`,
+		`SELECT approx_percentile(value, 99.9) FROM cpu`,
+		`SELECT count_if(status = 'error'), sum_if(value, status = 'error' AND value > 0) FROM cpu`,
+		`SELECT percentile_if(value, 95, host =~ /^server/) FROM cpu WHERE time >= now() - 1h GROUP BY time(10m)`,
+		`SELECT histogram(value, 0, 100, 10) FROM cpu`,
+		`SELECT sqrt(value) FROM cpu`,
+		`SELECT upper(host), strlen(value) FROM cpu`,
+		`SELECT concat(host, '-', region) FROM cpu WHERE lower(host) = 'servera'`,
+		`SELECT mean(value) FROM cpu WHERE substr(host, 0, 6) = 'server' AND time >= now() - 1h GROUP BY time(10m)`,
+		`SELECT pow(value, 2) + log(value, 10) FROM cpu`,
+		`SELECT cast(value, 'string'), host FROM cpu`,
+		`SELECT cast(max(value), 'integer') FROM cpu WHERE cast(status, 'integer') >= 500`,
+		`SELECT regex_extract(message, 'status=([0-9]+)', 1) FROM cpu WHERE regex_match(message, 'error|timeout')`,
+		`SELECT count(value) FROM cpu WHERE regex_match(message, /^GET /) AND time >= now() - 1h`,
+		`SELECT lag(value) FROM cpu`,
+		`SELECT lead(value, 2) FROM cpu`,
+		`SELECT mean(value) - lag(mean(value), 1w) FROM cpu WHERE time >= now() - 30d GROUP BY time(1d)`,
+		`SELECT round(mean(value)) FROM cpu WHERE time >= now() - 1h GROUP BY time(10m)`,
+		`SELECT histogram(value, 0.1, 1000, 4, 'log') FROM cpu WHERE time >= now() - 1h GROUP BY time(1m)`,
+		`SELECT sum(errors.value) / sum(requests.value) FROM errors, requests WHERE time >= now() - 1h GROUP BY time(1m), host`,
+		`SELECT sum(value) FROM cpu WHERE time >= now() - 365d GROUP BY time('1mo')`,
+		`SELECT sum(value) FROM cpu WHERE time >= now() - 3650d GROUP BY time('1y', 14d), host`,
+		`SELECT round(mean(errors.value)), max(requests.value) FROM errors, requests`,
 		`SELECT sample(value, 2) FROM cpu`,
 		`SELECT sample(*, 2) FROM cpu`,
 		`SELECT sample(/val/, 2) FROM cpu`,
@@ -195,6 +217,36 @@ func TestCompile_Failures(t *testing.T) {
 		{s: `SELECT percentile(field1) FROM myseries`, err: `invalid number of arguments for percentile, expected 2, got 1`},
 		{s: `SELECT percentile(field1, foo) FROM myseries`, err: `expected float argument in percentile()`},
 		{s: `SELECT percentile(max(field1), 75) FROM myseries`, err: `expected field argument in percentile()`},
+		{s: `SELECT approx_percentile(field1) FROM myseries`, err: `invalid number of arguments for approx_percentile, expected 2, got 1`},
+		{s: `SELECT approx_percentile(field1, foo) FROM myseries`, err: `expected float argument in approx_percentile()`},
+		{s: `SELECT approx_percentile(field1, 101) FROM myseries`, err: `approx_percentile must be between 0 and 100, got 101`},
+		{s: `SELECT approx_percentile(max(field1), 75) FROM myseries`, err: `expected field argument in approx_percentile()`},
+		{s: `SELECT upper(field1, field2) FROM myseries`, err: `invalid number of arguments for upper, expected 1, got 2`},
+		{s: `SELECT substr(field1, -1) FROM myseries`, err: `expected non-negative integer as argument 2 in substr(), found -1`},
+		{s: `SELECT replace(field1, 'a', field2) FROM myseries`, err: `expected string as argument 3 in replace(), found field2`},
+		{s: `SELECT concat('a', 'b') FROM myseries`, err: `expected field argument in concat()`},
+		{s: `SELECT field1 FROM myseries WHERE upper(host, 'a') = 'A'`, err: `invalid number of arguments for upper, expected 1, got 2`},
+		{s: `SELECT field1 FROM myseries WHERE sqrt(field1) > 2`, err: `unsupported function sqrt() in condition`},
+		{s: `SELECT cast(field1) FROM myseries`, err: `invalid number of arguments for cast, expected 2, got 1`},
+		{s: `SELECT cast(1, 'string') FROM myseries`, err: `expected field argument in cast()`},
+		{s: `SELECT cast(field1, field2) FROM myseries`, err: `expected type as second argument in cast(), found field2`},
+		{s: `SELECT cast(field1, 'time') FROM myseries`, err: `cannot cast to time, expected float, integer, unsigned, string or boolean`},
+		{s: `SELECT field1 FROM myseries WHERE cast(field1, 'tag') = 'a'`, err: `cannot cast to tag, expected float, integer, unsigned, string or boolean`},
+		{s: `SELECT pow(field1) FROM myseries`, err: `invalid number of arguments for pow, expected 2, got 1`},
+		{s: `SELECT log(field1, field2) FROM myseries`, err: `expected number as second argument in log(), found field2`},
+		{s: `SELECT sqrt(4) FROM myseries`, err: `expected field argument in sqrt()`},
+		{s: `SELECT sqrt(field1) FROM myseries GROUP BY time(1m)`, err: `GROUP BY requires at least one aggregate function`},
+		{s: `SELECT sum(field1) FROM myseries GROUP BY time('1week')`, err: `invalid calendar interval: 1week`},
+		{s: `SELECT sum(field1) FROM myseries GROUP BY time('0mo')`, err: `invalid calendar interval: 0mo`},
+		{s: `SELECT sum(field1) FROM myseries GROUP BY time('1mo', now())`, err: `calendar time dimension offset must be a positive duration`},
+		{s: `SELECT histogram(field1, 0, 100) FROM myseries`, err: `invalid number of arguments for histogram, expected 4 or 5, got 3`},
+		{s: `SELECT histogram(field1, 100, 0, 10) FROM myseries`, err: `histogram minimum (100) must be less than its maximum (0)`},
+		{s: `SELECT histogram(field1, 0, 100, 0) FROM myseries`, err: `number of buckets (0) in histogram must be between 1 and 1000`},
+		{s: `SELECT histogram(field1, 0, 100, 10, 'log') FROM myseries`, err: `histogram minimum (0) must be positive with a log scale`},
+		{s: `SELECT histogram(field1, 0, 100, 10, 'sqrt') FROM myseries`, err: `unsupported histogram scale: sqrt`},
+		{s: `SELECT histogram(*, 0, 100, 10) FROM myseries`, err: `expected field argument in histogram()`},
+		{s: `SELECT histogram(field1, 0, 100, 10), max(field1) FROM myseries`, err: `aggregate function histogram() cannot be combined with other functions or fields`},
+		{s: `SELECT histogram(field1, 0, 100, 10), field2 FROM myseries`, err: `aggregate function histogram() cannot be combined with other functions or fields`},
 		{s: `SELECT field1 FROM foo group by time(1s)`, err: `GROUP BY requires at least one aggregate function`},
 		{s: `SELECT field1 FROM foo fill(none)`, err: `fill(none) must be used with a function`},
 		{s: `SELECT field1 FROM foo fill(linear)`, err: `fill(linear) must be used with a function`},
@@ -277,6 +329,37 @@ func TestCompile_Failures(t *testing.T) {
 		{s: `SELECT moving_average(max(), 2) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for max, expected 1, got 0`},
 		{s: `SELECT moving_average(percentile(value), 2) FROM myseries where time < now() and time > now() - 1d group by time(1h)`, err: `invalid number of arguments for percentile, expected 2, got 1`},
 		{s: `SELECT moving_average(mean(value), 2) FROM myseries where time < now() and time > now() - 1d`, err: `moving_average aggregate requires a GROUP BY interval`},
+		{s: `SELECT median_absolute_deviation(value) FROM myseries`, err: `invalid number of arguments for median_absolute_deviation, expected 2, got 1`},
+		{s: `SELECT median_absolute_deviation(value, 1) FROM myseries`, err: `median_absolute_deviation window must be greater than 1, got 1`},
+		{s: `SELECT zscore(value, 'a') FROM myseries`, err: `second argument for zscore must be an integer, got *influxql.StringLiteral`},
+		{s: `SELECT zscore(mean(value), 10) FROM myseries where time < now() and time > now() - 1d`, err: `zscore aggregate requires a GROUP BY interval`},
+		{s: `SELECT zscore(value, 10) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to zscore`},
+		{s: `SELECT exponential_moving_average(value) FROM myseries`, err: `invalid number of arguments for exponential_moving_average, expected at least 2 but no more than 4, got 1`},
+		{s: `SELECT exponential_moving_average(value, 0) FROM myseries`, err: `exponential_moving_average period must be greater than or equal to 1, got 0`},
+		{s: `SELECT exponential_moving_average(value, 2.0) FROM myseries`, err: `second argument for exponential_moving_average must be an integer, got *influxql.NumberLiteral`},
+		{s: `SELECT double_exponential_moving_average(value, 2, -1) FROM myseries`, err: `double_exponential_moving_average hold period must be greater than or equal to 0, got -1`},
+		{s: `SELECT triple_exponential_moving_average(value, 2, 1, 'linear') FROM myseries`, err: `triple_exponential_moving_average warmup type must be exponential, simple or none, got linear`},
+		{s: `SELECT exponential_moving_average(mean(value), 2) FROM myseries where time < now() and time > now() - 1d`, err: `exponential_moving_average aggregate requires a GROUP BY interval`},
+		{s: `SELECT count_if(value) FROM myseries`, err: `expected condition argument in count_if()`},
+		{s: `SELECT count_if(value, host = 'a', 1) FROM myseries`, err: `expected condition argument in count_if()`},
+		{s: `SELECT sum_if(value > 1) FROM myseries`, err: `invalid number of arguments for sum_if, expected at least 2, got 1`},
+		{s: `SELECT sum_if(value, value + 1) FROM myseries`, err: `expected condition argument in sum_if()`},
+		{s: `SELECT sum_if(value, time > now() - 1h) FROM myseries`, err: `condition of sum_if() cannot reference time`},
+		{s: `SELECT mean_if(value, value > abs(other)) FROM myseries`, err: `condition of mean_if() cannot call abs()`},
+		{s: `SELECT max_if(value, host = 'a'), host FROM myseries`, err: `mixing aggregate and non-aggregate queries is not supported`},
+		{s: `SELECT median_if(value, 1, host = 'a') FROM myseries`, err: `invalid number of arguments for median, expected 1, got 2`},
+		{s: `SELECT kaufmans_adaptive_moving_average(value, 2, 1, 'none') FROM myseries`, err: `invalid number of arguments for kaufmans_adaptive_moving_average, expected at least 2 but no more than 3, got 4`},
+		{s: `SELECT kaufmans_adaptive_moving_average(value, 2) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to kaufmans_adaptive_moving_average`},
+		{s: `SELECT regex_match(field1) FROM myseries`, err: `invalid number of arguments for regex_match, expected 2, got 1`},
+		{s: `SELECT regex_extract(field1, 1) FROM myseries`, err: `expected pattern as argument 2 in regex_extract(), found 1`},
+		{s: `SELECT regex_extract(field1, 'a(b)', 2) FROM myseries`, err: `regex_extract group 2 out of range, expected at most 1`},
+		{s: `SELECT field1 FROM myseries WHERE regex_match(field1, '(')`, err: "invalid pattern in regex_match(): error parsing regexp: missing closing ): `(`"},
+		{s: `SELECT lag() FROM myseries`, err: `invalid number of arguments for lag, expected at least 1 but no more than 2, got 0`},
+		{s: `SELECT lag(value, 0) FROM myseries`, err: `lag offset must be greater than or equal to 1, got 0`},
+		{s: `SELECT lead(value, 'a') FROM myseries`, err: `second argument for lead must be an integer or a duration, got *influxql.StringLiteral`},
+		{s: `SELECT lag(mean(value), 1) FROM myseries where time < now() and time > now() - 1d`, err: `lag aggregate requires a GROUP BY interval`},
+		{s: `SELECT lead(value) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to lead`},
+		{s: `SELECT lag(value), value FROM myseries`, err: `mixing aggregate and non-aggregate queries is not supported`},
 		{s: `SELECT cumulative_sum(field1), field1 FROM myseries`, err: `mixing aggregate and non-aggregate queries is not supported`},
 		{s: `SELECT cumulative_sum() from myseries`, err: `invalid number of arguments for cumulative_sum, expected 1, got 0`},
 		{s: `SELECT cumulative_sum(value) FROM myseries group by time(1h)`, err: `aggregate function required inside the call to cumulative_sum`},
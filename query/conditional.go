@@ -0,0 +1,151 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/influxql"
+)
+
+// conditionalCall returns the aggregate of a conditional call and its
+// condition. A conditional call is an aggregate whose name is suffixed with
+// _if and whose last argument is a condition, such as sum_if(value, status =
+// 'error'), and it aggregates only the points matching the condition.
+// count_if() only takes a condition and counts the points of the first field
+// it compares, so its aggregate is nil.
+func conditionalCall(call *influxql.Call) (*influxql.Call, influxql.Expr, bool) {
+	name := strings.TrimSuffix(call.Name, "_if")
+	if name == call.Name || !isConditionalAggregate(name) || len(call.Args) == 0 {
+		return nil, nil, false
+	}
+
+	cond := call.Args[len(call.Args)-1]
+	if name == "count" && len(call.Args) == 1 {
+		return nil, cond, true
+	}
+	return &influxql.Call{Name: name, Args: call.Args[:len(call.Args)-1]}, cond, true
+}
+
+// isConditionalAggregate returns true if the function can be made conditional
+// with the _if suffix.
+func isConditionalAggregate(name string) bool {
+	switch name {
+	case "count", "sum", "mean", "median", "mode", "spread", "stddev",
+		"min", "max", "first", "last", "percentile":
+		return true
+	}
+	return false
+}
+
+// compileConditional validates a conditional call and its aggregate.
+func (c *compiledField) compileConditional(call, inner *influxql.Call, cond influxql.Expr) error {
+	if inner == nil && len(call.Args) != 1 {
+		return fmt.Errorf("invalid number of arguments for %s, expected 1, got %d", call.Name, len(call.Args))
+	} else if inner != nil && len(inner.Args) == 0 {
+		return fmt.Errorf("invalid number of arguments for %s, expected at least 2, got %d", call.Name, len(call.Args))
+	} else if err := validateCallCondition(call.Name, cond); err != nil {
+		return err
+	}
+
+	if inner == nil {
+		c.global.FunctionCalls = append(c.global.FunctionCalls, call)
+	} else if err := c.compileExpr(inner); err != nil {
+		return err
+	}
+
+	// The points of a conditional selector are not the points of the
+	// other fields, so it is never a selector.
+	c.global.OnlySelectors = false
+	return nil
+}
+
+// validateCallCondition returns an error if the condition of a conditional
+// call isn't a comparison of fields and tags. Time can only be restricted by
+// the condition of the statement.
+func validateCallCondition(name string, cond influxql.Expr) error {
+	expr := cond
+	for {
+		paren, ok := expr.(*influxql.ParenExpr)
+		if !ok {
+			break
+		}
+		expr = paren.Expr
+	}
+
+	if binary, ok := expr.(*influxql.BinaryExpr); !ok || !isConditionOperator(binary.Op) {
+		return fmt.Errorf("expected condition argument in %s()", name)
+	}
+
+	var err error
+	influxql.WalkFunc(cond, func(n influxql.Node) {
+		if err != nil {
+			return
+		}
+		switch n := n.(type) {
+		case *influxql.VarRef:
+			if strings.ToLower(n.Val) == "time" {
+				err = fmt.Errorf("condition of %s() cannot reference time", name)
+			}
+		case *influxql.Call:
+			err = fmt.Errorf("condition of %s() cannot call %s()", name, n.Name)
+		}
+	})
+	return err
+}
+
+// isConditionOperator returns true if the operator compares its operands or
+// combines conditions.
+func isConditionOperator(op influxql.Token) bool {
+	switch op {
+	case influxql.EQ, influxql.NEQ, influxql.LT, influxql.LTE, influxql.GT, influxql.GTE,
+		influxql.EQREGEX, influxql.NEQREGEX, influxql.AND, influxql.OR:
+		return true
+	}
+	return false
+}
+
+// buildConditionalCallIterator builds the iterator of the aggregate of a
+// conditional call with its condition added to the condition of the
+// statement, so only the matching points are read from the shards.
+func (b *exprIteratorBuilder) buildConditionalCallIterator(ctx context.Context, inner *influxql.Call, cond influxql.Expr) (Iterator, error) {
+	if inner == nil {
+		ref := conditionField(cond)
+		if ref == nil {
+			return nil, errors.New("count_if() condition must compare a field")
+		}
+		inner = &influxql.Call{Name: "count", Args: []influxql.Expr{ref}}
+	}
+
+	builder := *b
+	builder.opt.Expr = inner
+	if builder.opt.Condition != nil {
+		builder.opt.Condition = &influxql.BinaryExpr{
+			Op:  influxql.AND,
+			LHS: &influxql.ParenExpr{Expr: builder.opt.Condition},
+			RHS: &influxql.ParenExpr{Expr: cond},
+		}
+	} else {
+		builder.opt.Condition = cond
+	}
+	return builder.buildCallIterator(ctx, inner)
+}
+
+// conditionField returns the first field referenced by a condition, or nil if
+// it only references tags. The types of the references are resolved when the
+// fields of the statement are rewritten.
+func conditionField(cond influxql.Expr) *influxql.VarRef {
+	var field *influxql.VarRef
+	influxql.WalkFunc(cond, func(n influxql.Node) {
+		ref, ok := n.(*influxql.VarRef)
+		if !ok || field != nil {
+			return
+		}
+		switch ref.Type {
+		case influxql.Float, influxql.Integer, influxql.Unsigned, influxql.String, influxql.Boolean:
+			field = &influxql.VarRef{Val: ref.Val, Type: ref.Type}
+		}
+	})
+	return field
+}
@@ -496,6 +496,84 @@ func (r *FloatSampleReducer) Emit() []FloatPoint {
 	return pts
 }
 
+// FloatLagReducer emits, at every point, the value of the point offset
+// from it by a number of points or a duration. A lookback reducer emits the
+// value of a point read before the current point, and a lookahead reducer
+// emits the value of the current point at the points read before it.
+type FloatLagReducer struct {
+	n         int   // offset in points, or zero if offset by duration
+	d         int64 // offset by duration
+	lookback  bool
+	ascending bool
+
+	buf    []FloatPoint
+	points []FloatPoint
+}
+
+// NewFloatLagReducer creates a new FloatLagReducer offsetting the points
+// by n points, or by the duration d if n is zero.
+func NewFloatLagReducer(n int, d time.Duration, lookback, ascending bool) *FloatLagReducer {
+	return &FloatLagReducer{
+		n:         n,
+		d:         int64(d),
+		lookback:  lookback,
+		ascending: ascending,
+	}
+}
+
+// distance returns the duration between two points in the order of the points.
+func (r *FloatLagReducer) distance(a, b *FloatPoint) int64 {
+	if r.ascending {
+		return b.Time - a.Time
+	}
+	return a.Time - b.Time
+}
+
+// AggregateFloat aggregates a point into the reducer.
+func (r *FloatLagReducer) AggregateFloat(p *FloatPoint) {
+	curr := FloatPoint{Time: p.Time, Value: p.Value}
+	switch {
+	case r.lookback && r.n > 0:
+		if len(r.buf) == r.n {
+			r.points = append(r.points, FloatPoint{Time: curr.Time, Value: r.buf[0].Value})
+			r.buf = r.buf[1:]
+		}
+	case r.lookback:
+		// Keep the last point at least the duration before the current
+		// point.
+		for len(r.buf) > 1 && r.distance(&r.buf[1], &curr) >= r.d {
+			r.buf = r.buf[1:]
+		}
+		if len(r.buf) > 0 && r.distance(&r.buf[0], &curr) >= r.d {
+			r.points = append(r.points, FloatPoint{Time: curr.Time, Value: r.buf[0].Value})
+		}
+	case r.n > 0:
+		if len(r.buf) == r.n {
+			r.points = append(r.points, FloatPoint{Time: r.buf[0].Time, Value: curr.Value})
+			r.buf = r.buf[1:]
+		}
+	default:
+		// The current point is the first point at least the duration
+		// after the waiting points.
+		for len(r.buf) > 0 && r.distance(&r.buf[0], &curr) >= r.d {
+			r.points = append(r.points, FloatPoint{Time: r.buf[0].Time, Value: curr.Value})
+			r.buf = r.buf[1:]
+		}
+	}
+	r.buf = append(r.buf, curr)
+}
+
+// Emit emits the points whose offset point has been aggregated.
+func (r *FloatLagReducer) Emit() []FloatPoint {
+	// The points are emitted from the end of the slice.
+	points := r.points
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	r.points = nil
+	return points
+}
+
 // IntegerPointAggregator aggregates points to produce a single point.
 type IntegerPointAggregator interface {
 	AggregateInteger(p *IntegerPoint)
@@ -980,6 +1058,84 @@ func (r *IntegerSampleReducer) Emit() []IntegerPoint {
 	return pts
 }
 
+// IntegerLagReducer emits, at every point, the value of the point offset
+// from it by a number of points or a duration. A lookback reducer emits the
+// value of a point read before the current point, and a lookahead reducer
+// emits the value of the current point at the points read before it.
+type IntegerLagReducer struct {
+	n         int   // offset in points, or zero if offset by duration
+	d         int64 // offset by duration
+	lookback  bool
+	ascending bool
+
+	buf    []IntegerPoint
+	points []IntegerPoint
+}
+
+// NewIntegerLagReducer creates a new IntegerLagReducer offsetting the points
+// by n points, or by the duration d if n is zero.
+func NewIntegerLagReducer(n int, d time.Duration, lookback, ascending bool) *IntegerLagReducer {
+	return &IntegerLagReducer{
+		n:         n,
+		d:         int64(d),
+		lookback:  lookback,
+		ascending: ascending,
+	}
+}
+
+// distance returns the duration between two points in the order of the points.
+func (r *IntegerLagReducer) distance(a, b *IntegerPoint) int64 {
+	if r.ascending {
+		return b.Time - a.Time
+	}
+	return a.Time - b.Time
+}
+
+// AggregateInteger aggregates a point into the reducer.
+func (r *IntegerLagReducer) AggregateInteger(p *IntegerPoint) {
+	curr := IntegerPoint{Time: p.Time, Value: p.Value}
+	switch {
+	case r.lookback && r.n > 0:
+		if len(r.buf) == r.n {
+			r.points = append(r.points, IntegerPoint{Time: curr.Time, Value: r.buf[0].Value})
+			r.buf = r.buf[1:]
+		}
+	case r.lookback:
+		// Keep the last point at least the duration before the current
+		// point.
+		for len(r.buf) > 1 && r.distance(&r.buf[1], &curr) >= r.d {
+			r.buf = r.buf[1:]
+		}
+		if len(r.buf) > 0 && r.distance(&r.buf[0], &curr) >= r.d {
+			r.points = append(r.points, IntegerPoint{Time: curr.Time, Value: r.buf[0].Value})
+		}
+	case r.n > 0:
+		if len(r.buf) == r.n {
+			r.points = append(r.points, IntegerPoint{Time: r.buf[0].Time, Value: curr.Value})
+			r.buf = r.buf[1:]
+		}
+	default:
+		// The current point is the first point at least the duration
+		// after the waiting points.
+		for len(r.buf) > 0 && r.distance(&r.buf[0], &curr) >= r.d {
+			r.points = append(r.points, IntegerPoint{Time: r.buf[0].Time, Value: curr.Value})
+			r.buf = r.buf[1:]
+		}
+	}
+	r.buf = append(r.buf, curr)
+}
+
+// Emit emits the points whose offset point has been aggregated.
+func (r *IntegerLagReducer) Emit() []IntegerPoint {
+	// The points are emitted from the end of the slice.
+	points := r.points
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	r.points = nil
+	return points
+}
+
 // UnsignedPointAggregator aggregates points to produce a single point.
 type UnsignedPointAggregator interface {
 	AggregateUnsigned(p *UnsignedPoint)
@@ -1464,6 +1620,84 @@ func (r *UnsignedSampleReducer) Emit() []UnsignedPoint {
 	return pts
 }
 
+// UnsignedLagReducer emits, at every point, the value of the point offset
+// from it by a number of points or a duration. A lookback reducer emits the
+// value of a point read before the current point, and a lookahead reducer
+// emits the value of the current point at the points read before it.
+type UnsignedLagReducer struct {
+	n         int   // offset in points, or zero if offset by duration
+	d         int64 // offset by duration
+	lookback  bool
+	ascending bool
+
+	buf    []UnsignedPoint
+	points []UnsignedPoint
+}
+
+// NewUnsignedLagReducer creates a new UnsignedLagReducer offsetting the points
+// by n points, or by the duration d if n is zero.
+func NewUnsignedLagReducer(n int, d time.Duration, lookback, ascending bool) *UnsignedLagReducer {
+	return &UnsignedLagReducer{
+		n:         n,
+		d:         int64(d),
+		lookback:  lookback,
+		ascending: ascending,
+	}
+}
+
+// distance returns the duration between two points in the order of the points.
+func (r *UnsignedLagReducer) distance(a, b *UnsignedPoint) int64 {
+	if r.ascending {
+		return b.Time - a.Time
+	}
+	return a.Time - b.Time
+}
+
+// AggregateUnsigned aggregates a point into the reducer.
+func (r *UnsignedLagReducer) AggregateUnsigned(p *UnsignedPoint) {
+	curr := UnsignedPoint{Time: p.Time, Value: p.Value}
+	switch {
+	case r.lookback && r.n > 0:
+		if len(r.buf) == r.n {
+			r.points = append(r.points, UnsignedPoint{Time: curr.Time, Value: r.buf[0].Value})
+			r.buf = r.buf[1:]
+		}
+	case r.lookback:
+		// Keep the last point at least the duration before the current
+		// point.
+		for len(r.buf) > 1 && r.distance(&r.buf[1], &curr) >= r.d {
+			r.buf = r.buf[1:]
+		}
+		if len(r.buf) > 0 && r.distance(&r.buf[0], &curr) >= r.d {
+			r.points = append(r.points, UnsignedPoint{Time: curr.Time, Value: r.buf[0].Value})
+		}
+	case r.n > 0:
+		if len(r.buf) == r.n {
+			r.points = append(r.points, UnsignedPoint{Time: r.buf[0].Time, Value: curr.Value})
+			r.buf = r.buf[1:]
+		}
+	default:
+		// The current point is the first point at least the duration
+		// after the waiting points.
+		for len(r.buf) > 0 && r.distance(&r.buf[0], &curr) >= r.d {
+			r.points = append(r.points, UnsignedPoint{Time: r.buf[0].Time, Value: curr.Value})
+			r.buf = r.buf[1:]
+		}
+	}
+	r.buf = append(r.buf, curr)
+}
+
+// Emit emits the points whose offset point has been aggregated.
+func (r *UnsignedLagReducer) Emit() []UnsignedPoint {
+	// The points are emitted from the end of the slice.
+	points := r.points
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	r.points = nil
+	return points
+}
+
 // StringPointAggregator aggregates points to produce a single point.
 type StringPointAggregator interface {
 	AggregateString(p *StringPoint)
@@ -1948,6 +2182,84 @@ func (r *StringSampleReducer) Emit() []StringPoint {
 	return pts
 }
 
+// StringLagReducer emits, at every point, the value of the point offset
+// from it by a number of points or a duration. A lookback reducer emits the
+// value of a point read before the current point, and a lookahead reducer
+// emits the value of the current point at the points read before it.
+type StringLagReducer struct {
+	n         int   // offset in points, or zero if offset by duration
+	d         int64 // offset by duration
+	lookback  bool
+	ascending bool
+
+	buf    []StringPoint
+	points []StringPoint
+}
+
+// NewStringLagReducer creates a new StringLagReducer offsetting the points
+// by n points, or by the duration d if n is zero.
+func NewStringLagReducer(n int, d time.Duration, lookback, ascending bool) *StringLagReducer {
+	return &StringLagReducer{
+		n:         n,
+		d:         int64(d),
+		lookback:  lookback,
+		ascending: ascending,
+	}
+}
+
+// distance returns the duration between two points in the order of the points.
+func (r *StringLagReducer) distance(a, b *StringPoint) int64 {
+	if r.ascending {
+		return b.Time - a.Time
+	}
+	return a.Time - b.Time
+}
+
+// AggregateString aggregates a point into the reducer.
+func (r *StringLagReducer) AggregateString(p *StringPoint) {
+	curr := StringPoint{Time: p.Time, Value: p.Value}
+	switch {
+	case r.lookback && r.n > 0:
+		if len(r.buf) == r.n {
+			r.points = append(r.points, StringPoint{Time: curr.Time, Value: r.buf[0].Value})
+			r.buf = r.buf[1:]
+		}
+	case r.lookback:
+		// Keep the last point at least the duration before the current
+		// point.
+		for len(r.buf) > 1 && r.distance(&r.buf[1], &curr) >= r.d {
+			r.buf = r.buf[1:]
+		}
+		if len(r.buf) > 0 && r.distance(&r.buf[0], &curr) >= r.d {
+			r.points = append(r.points, StringPoint{Time: curr.Time, Value: r.buf[0].Value})
+		}
+	case r.n > 0:
+		if len(r.buf) == r.n {
+			r.points = append(r.points, StringPoint{Time: r.buf[0].Time, Value: curr.Value})
+			r.buf = r.buf[1:]
+		}
+	default:
+		// The current point is the first point at least the duration
+		// after the waiting points.
+		for len(r.buf) > 0 && r.distance(&r.buf[0], &curr) >= r.d {
+			r.points = append(r.points, StringPoint{Time: r.buf[0].Time, Value: curr.Value})
+			r.buf = r.buf[1:]
+		}
+	}
+	r.buf = append(r.buf, curr)
+}
+
+// Emit emits the points whose offset point has been aggregated.
+func (r *StringLagReducer) Emit() []StringPoint {
+	// The points are emitted from the end of the slice.
+	points := r.points
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	r.points = nil
+	return points
+}
+
 // BooleanPointAggregator aggregates points to produce a single point.
 type BooleanPointAggregator interface {
 	AggregateBoolean(p *BooleanPoint)
@@ -2431,3 +2743,81 @@ func (r *BooleanSampleReducer) Emit() []BooleanPoint {
 	sort.Sort(pts)
 	return pts
 }
+
+// BooleanLagReducer emits, at every point, the value of the point offset
+// from it by a number of points or a duration. A lookback reducer emits the
+// value of a point read before the current point, and a lookahead reducer
+// emits the value of the current point at the points read before it.
+type BooleanLagReducer struct {
+	n         int   // offset in points, or zero if offset by duration
+	d         int64 // offset by duration
+	lookback  bool
+	ascending bool
+
+	buf    []BooleanPoint
+	points []BooleanPoint
+}
+
+// NewBooleanLagReducer creates a new BooleanLagReducer offsetting the points
+// by n points, or by the duration d if n is zero.
+func NewBooleanLagReducer(n int, d time.Duration, lookback, ascending bool) *BooleanLagReducer {
+	return &BooleanLagReducer{
+		n:         n,
+		d:         int64(d),
+		lookback:  lookback,
+		ascending: ascending,
+	}
+}
+
+// distance returns the duration between two points in the order of the points.
+func (r *BooleanLagReducer) distance(a, b *BooleanPoint) int64 {
+	if r.ascending {
+		return b.Time - a.Time
+	}
+	return a.Time - b.Time
+}
+
+// AggregateBoolean aggregates a point into the reducer.
+func (r *BooleanLagReducer) AggregateBoolean(p *BooleanPoint) {
+	curr := BooleanPoint{Time: p.Time, Value: p.Value}
+	switch {
+	case r.lookback && r.n > 0:
+		if len(r.buf) == r.n {
+			r.points = append(r.points, BooleanPoint{Time: curr.Time, Value: r.buf[0].Value})
+			r.buf = r.buf[1:]
+		}
+	case r.lookback:
+		// Keep the last point at least the duration before the current
+		// point.
+		for len(r.buf) > 1 && r.distance(&r.buf[1], &curr) >= r.d {
+			r.buf = r.buf[1:]
+		}
+		if len(r.buf) > 0 && r.distance(&r.buf[0], &curr) >= r.d {
+			r.points = append(r.points, BooleanPoint{Time: curr.Time, Value: r.buf[0].Value})
+		}
+	case r.n > 0:
+		if len(r.buf) == r.n {
+			r.points = append(r.points, BooleanPoint{Time: r.buf[0].Time, Value: curr.Value})
+			r.buf = r.buf[1:]
+		}
+	default:
+		// The current point is the first point at least the duration
+		// after the waiting points.
+		for len(r.buf) > 0 && r.distance(&r.buf[0], &curr) >= r.d {
+			r.points = append(r.points, BooleanPoint{Time: r.buf[0].Time, Value: curr.Value})
+			r.buf = r.buf[1:]
+		}
+	}
+	r.buf = append(r.buf, curr)
+}
+
+// Emit emits the points whose offset point has been aggregated.
+func (r *BooleanLagReducer) Emit() []BooleanPoint {
+	// The points are emitted from the end of the slice.
+	points := r.points
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	r.points = nil
+	return points
+}
@@ -2,10 +2,13 @@ package query
 
 import (
 	"container/heap"
+	"encoding/binary"
+	"errors"
 	"math"
 	"sort"
 	"time"
 
+	"github.com/influxdata/influxdb/pkg/estimator/tdigest"
 	"github.com/influxdata/influxdb/query/neldermead"
 	"github.com/influxdata/influxql"
 )
@@ -103,6 +106,159 @@ func (r *UnsignedMeanReducer) Emit() []FloatPoint {
 	}}
 }
 
+// DigestReducer builds a t-digest of the aggregated points for
+// approx_percentile(). Digests emitted by other digest reducers are merged.
+type DigestReducer struct {
+	digest *tdigest.TDigest
+}
+
+// NewDigestReducer creates a new DigestReducer.
+func NewDigestReducer() *DigestReducer {
+	return &DigestReducer{digest: tdigest.New()}
+}
+
+// AggregateFloat aggregates a point into the reducer.
+func (r *DigestReducer) AggregateFloat(p *FloatPoint) {
+	r.digest.Add(p.Value)
+}
+
+// AggregateInteger aggregates a point into the reducer.
+func (r *DigestReducer) AggregateInteger(p *IntegerPoint) {
+	r.digest.Add(float64(p.Value))
+}
+
+// AggregateUnsigned aggregates a point into the reducer.
+func (r *DigestReducer) AggregateUnsigned(p *UnsignedPoint) {
+	r.digest.Add(float64(p.Value))
+}
+
+// AggregateString merges the digest of a point into the reducer.
+func (r *DigestReducer) AggregateString(p *StringPoint) {
+	mergeDigest(r.digest, p.Value)
+}
+
+// Emit emits the encoded digest as a single point.
+func (r *DigestReducer) Emit() []StringPoint {
+	b, _ := r.digest.MarshalBinary()
+	return []StringPoint{{Time: ZeroTime, Value: string(b)}}
+}
+
+// ApproxPercentileReducer estimates a percentile of the points summarized by
+// the digests of the aggregated points.
+type ApproxPercentileReducer struct {
+	digest     *tdigest.TDigest
+	percentile float64
+}
+
+// NewApproxPercentileReducer creates a new ApproxPercentileReducer.
+func NewApproxPercentileReducer(percentile float64) *ApproxPercentileReducer {
+	return &ApproxPercentileReducer{digest: tdigest.New(), percentile: percentile}
+}
+
+// AggregateString merges the digest of a point into the reducer.
+func (r *ApproxPercentileReducer) AggregateString(p *StringPoint) {
+	mergeDigest(r.digest, p.Value)
+}
+
+// Emit emits the estimated percentile as a single point, or no point if the
+// digests are empty.
+func (r *ApproxPercentileReducer) Emit() []FloatPoint {
+	v := r.digest.Quantile(r.percentile / 100)
+	if math.IsNaN(v) {
+		return nil
+	}
+	return []FloatPoint{{Time: ZeroTime, Value: v}}
+}
+
+// mergeDigest merges the digest encoded in s into d. Invalid digests are
+// ignored.
+func mergeDigest(d *tdigest.TDigest, s string) {
+	var other tdigest.TDigest
+	if err := other.UnmarshalBinary([]byte(s)); err != nil {
+		return
+	}
+	d.Merge(&other)
+}
+
+// HistogramReducer counts the aggregated points in the buckets of a
+// histogram(). Counts emitted by other histogram reducers are added.
+type HistogramReducer struct {
+	buckets []float64
+	counts  []uint64
+}
+
+// NewHistogramReducer creates a new HistogramReducer with the boundaries of
+// its buckets.
+func NewHistogramReducer(buckets []float64) *HistogramReducer {
+	return &HistogramReducer{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)-1),
+	}
+}
+
+// AggregateFloat aggregates a point into the reducer.
+func (r *HistogramReducer) AggregateFloat(p *FloatPoint) {
+	r.add(p.Value)
+}
+
+// AggregateInteger aggregates a point into the reducer.
+func (r *HistogramReducer) AggregateInteger(p *IntegerPoint) {
+	r.add(float64(p.Value))
+}
+
+// AggregateUnsigned aggregates a point into the reducer.
+func (r *HistogramReducer) AggregateUnsigned(p *UnsignedPoint) {
+	r.add(float64(p.Value))
+}
+
+// AggregateString adds the counts encoded in a point to the reducer. Invalid
+// counts are ignored.
+func (r *HistogramReducer) AggregateString(p *StringPoint) {
+	counts, err := decodeHistogram(p.Value, len(r.counts))
+	if err != nil {
+		return
+	}
+	for i, n := range counts {
+		r.counts[i] += n
+	}
+}
+
+// add counts v in the bucket whose upper bound is the first one greater than
+// or equal to v. Values outside of the buckets are not counted.
+func (r *HistogramReducer) add(v float64) {
+	if math.IsNaN(v) || v < r.buckets[0] || v > r.buckets[len(r.buckets)-1] {
+		return
+	}
+	r.counts[sort.SearchFloat64s(r.buckets[1:], v)]++
+}
+
+// Emit emits the encoded counts as a single point.
+func (r *HistogramReducer) Emit() []StringPoint {
+	b := make([]byte, 0, binary.MaxVarintLen64*len(r.counts))
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, n := range r.counts {
+		b = append(b, buf[:binary.PutUvarint(buf, n)]...)
+	}
+	return []StringPoint{{Time: ZeroTime, Value: string(b)}}
+}
+
+// decodeHistogram decodes the n bucket counts encoded by a HistogramReducer.
+func decodeHistogram(s string, n int) ([]uint64, error) {
+	b := []byte(s)
+	counts := make([]uint64, n)
+	for i := range counts {
+		v, sz := binary.Uvarint(b)
+		if sz <= 0 {
+			return nil, errors.New("invalid histogram encoding")
+		}
+		counts[i], b = v, b[sz:]
+	}
+	if len(b) != 0 {
+		return nil, errors.New("invalid histogram encoding")
+	}
+	return counts, nil
+}
+
 // FloatDerivativeReducer calculates the derivative of the aggregated points.
 type FloatDerivativeReducer struct {
 	interval      Interval
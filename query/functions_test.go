@@ -388,6 +388,64 @@ func TestHoltWinters_MaxTime(t *testing.T) {
 	}
 }
 
+func TestExponentialMovingAverage_ExponentialWarmup(t *testing.T) {
+	r := query.NewFloatExponentialMovingAverageReducer(1, 3, 0, query.ExponentialWarmup)
+	var points []query.FloatPoint
+	for i, v := range []float64{20, 10, 19, 3} {
+		r.AggregateFloat(&query.FloatPoint{Time: int64(i), Value: v})
+		points = append(points, r.Emit()...)
+	}
+
+	exp := []float64{20, 13.333333, 16.166667, 9.583333}
+	if len(points) != len(exp) {
+		t.Fatalf("unexpected number of points emitted: got %d exp %d", len(points), len(exp))
+	}
+	for i := range exp {
+		if got := points[i].Value; !almostEqual(got, exp[i]) {
+			t.Errorf("unexpected value on points[%d] got %v exp %v", i, got, exp[i])
+		}
+	}
+}
+
+func TestExponentialMovingAverage_SimpleWarmup(t *testing.T) {
+	r := query.NewFloatExponentialMovingAverageReducer(1, 3, 0, query.SimpleWarmup)
+	var points []query.FloatPoint
+	for i, v := range []int64{20, 10, 18, 4} {
+		r.AggregateInteger(&query.IntegerPoint{Time: int64(i), Value: v})
+		points = append(points, r.Emit()...)
+	}
+
+	// The first point is the simple average of the first period.
+	exp := []query.FloatPoint{{Time: 2, Value: 16}, {Time: 3, Value: 10}}
+	if !deep.Equal(points, exp) {
+		t.Fatalf("unexpected points: %s", spew.Sdump(points))
+	}
+}
+
+func TestKaufmansAdaptiveMovingAverage(t *testing.T) {
+	r := query.NewFloatKaufmansAdaptiveMovingAverageReducer(2, 2)
+	var points []query.FloatPoint
+	for i, v := range []float64{1, 2, 3, 5, 4} {
+		r.AggregateFloat(&query.FloatPoint{Time: int64(i), Value: v})
+		points = append(points, r.Emit()...)
+	}
+
+	// The average follows the trend closely and slows down once the values
+	// turn back.
+	exp := []query.FloatPoint{{Time: 2, Value: 2.444444}, {Time: 3, Value: 3.580247}, {Time: 4, Value: 3.609776}}
+	if len(points) != len(exp) {
+		t.Fatalf("unexpected number of points emitted: got %d exp %d", len(points), len(exp))
+	}
+	for i := range exp {
+		if exp, got := exp[i].Time, points[i].Time; got != exp {
+			t.Errorf("unexpected time on points[%d] got %v exp %v", i, got, exp)
+		}
+		if exp, got := exp[i].Value, points[i].Value; !almostEqual(got, exp) {
+			t.Errorf("unexpected value on points[%d] got %v exp %v", i, got, exp)
+		}
+	}
+}
+
 // TestSample_AllSamplesSeen attempts to verify that it is possible
 // to get every subsample in a reasonable number of iterations.
 //
@@ -467,6 +467,7 @@ func (m *Measurement) GetSystemIterator() string {
 type Interval struct {
 	Duration         *int64 `protobuf:"varint,1,opt,name=Duration" json:"Duration,omitempty"`
 	Offset           *int64 `protobuf:"varint,2,opt,name=Offset" json:"Offset,omitempty"`
+	Months           *int64 `protobuf:"varint,3,opt,name=Months" json:"Months,omitempty"`
 	XXX_unrecognized []byte `json:"-"`
 }
 
@@ -489,6 +490,13 @@ func (m *Interval) GetOffset() int64 {
 	return 0
 }
 
+func (m *Interval) GetMonths() int64 {
+	if m != nil && m.Months != nil {
+		return *m.Months
+	}
+	return 0
+}
+
 type IteratorStats struct {
 	SeriesN          *int64 `protobuf:"varint,1,opt,name=SeriesN" json:"SeriesN,omitempty"`
 	PointN           *int64 `protobuf:"varint,2,opt,name=PointN" json:"PointN,omitempty"`
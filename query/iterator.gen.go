@@ -625,10 +625,9 @@ type floatFillIterator struct {
 	opt       IteratorOptions
 
 	window struct {
-		name   string
-		tags   Tags
-		time   int64
-		offset int64
+		name string
+		tags Tags
+		time int64
 	}
 }
 
@@ -678,9 +677,6 @@ func (itr *floatFillIterator) Next() (*FloatPoint, error) {
 		if itr.startTime == influxql.MinTime {
 			itr.window.time, _ = itr.opt.Window(p.Time)
 		}
-		if itr.opt.Location != nil {
-			_, itr.window.offset = itr.opt.Zone(itr.window.time)
-		}
 		itr.init = true
 	}
 
@@ -689,6 +685,26 @@ func (itr *floatFillIterator) Next() (*FloatPoint, error) {
 		return nil, err
 	}
 
+	// Points before the start time were read from the fill lookback. They are
+	// not emitted, but seed the previous value of their series.
+	for itr.opt.Ascending && p != nil && p.Time < itr.startTime {
+		if p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
+			// Fill the remaining intervals of the current series first.
+			if itr.window.time <= itr.endTime {
+				itr.input.unread(p)
+				p = nil
+				goto CONSTRUCT
+			}
+			itr.window.name, itr.window.tags = p.Name, p.Tags
+			itr.window.time = itr.startTime
+		}
+		itr.prev = *p
+
+		if p, err = itr.input.Next(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check if the next point is outside of our window or is nil.
 	if p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
 		// If we are inside of an interval, unread the point and continue below to
@@ -715,9 +731,6 @@ func (itr *floatFillIterator) Next() (*FloatPoint, error) {
 		if itr.window.time == influxql.MinTime {
 			itr.window.time, _ = itr.opt.Window(p.Time)
 		}
-		if itr.opt.Location != nil {
-			_, itr.window.offset = itr.opt.Zone(itr.window.time)
-		}
 		itr.prev = FloatPoint{Nil: true}
 	}
 
@@ -770,24 +783,21 @@ CONSTRUCT:
 
 	// Advance the expected time. Do not advance to a new window here
 	// as there may be lingering points with the same timestamp in the previous
-	// window.
+	// window. Calendar intervals and intervals in a location vary in length,
+	// so the next window is found from the current one.
+	if itr.opt.Interval.Months > 0 || itr.opt.Location != nil {
+		if itr.opt.Ascending {
+			_, itr.window.time = itr.opt.Window(itr.window.time)
+		} else {
+			itr.window.time, _ = itr.opt.Window(itr.window.time - 1)
+		}
+		return p, nil
+	}
 	if itr.opt.Ascending {
 		itr.window.time += int64(itr.opt.Interval.Duration)
 	} else {
 		itr.window.time -= int64(itr.opt.Interval.Duration)
 	}
-
-	// Check to see if we have passed over an offset change and adjust the time
-	// to account for this new offset.
-	if itr.opt.Location != nil {
-		if _, offset := itr.opt.Zone(itr.window.time - 1); offset != itr.window.offset {
-			diff := itr.window.offset - offset
-			if abs(diff) < int64(itr.opt.Interval.Duration) {
-				itr.window.time += diff
-			}
-			itr.window.offset = offset
-		}
-	}
 	return p, nil
 }
 
@@ -949,6 +959,9 @@ func (itr *floatAuxIterator) Next() (*FloatPoint, error) {
 func (itr *floatAuxIterator) Iterator(name string, typ influxql.DataType) Iterator {
 	return itr.fields.iterator(name, typ)
 }
+func (itr *floatAuxIterator) CastIterator(name string, typ influxql.DataType) Iterator {
+	return itr.fields.castIterator(name, typ)
+}
 
 func (itr *floatAuxIterator) stream() {
 	for {
@@ -3344,7 +3357,7 @@ func (itr *floatFilterIterator) Next() (*FloatPoint, error) {
 			itr.m[k] = v
 		}
 
-		if !influxql.EvalBool(itr.cond, itr.m) {
+		if !EvalBool(itr.cond, itr.m) {
 			continue
 		}
 		return p, nil
@@ -4037,10 +4050,9 @@ type integerFillIterator struct {
 	opt       IteratorOptions
 
 	window struct {
-		name   string
-		tags   Tags
-		time   int64
-		offset int64
+		name string
+		tags Tags
+		time int64
 	}
 }
 
@@ -4090,9 +4102,6 @@ func (itr *integerFillIterator) Next() (*IntegerPoint, error) {
 		if itr.startTime == influxql.MinTime {
 			itr.window.time, _ = itr.opt.Window(p.Time)
 		}
-		if itr.opt.Location != nil {
-			_, itr.window.offset = itr.opt.Zone(itr.window.time)
-		}
 		itr.init = true
 	}
 
@@ -4101,6 +4110,26 @@ func (itr *integerFillIterator) Next() (*IntegerPoint, error) {
 		return nil, err
 	}
 
+	// Points before the start time were read from the fill lookback. They are
+	// not emitted, but seed the previous value of their series.
+	for itr.opt.Ascending && p != nil && p.Time < itr.startTime {
+		if p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
+			// Fill the remaining intervals of the current series first.
+			if itr.window.time <= itr.endTime {
+				itr.input.unread(p)
+				p = nil
+				goto CONSTRUCT
+			}
+			itr.window.name, itr.window.tags = p.Name, p.Tags
+			itr.window.time = itr.startTime
+		}
+		itr.prev = *p
+
+		if p, err = itr.input.Next(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check if the next point is outside of our window or is nil.
 	if p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
 		// If we are inside of an interval, unread the point and continue below to
@@ -4127,9 +4156,6 @@ func (itr *integerFillIterator) Next() (*IntegerPoint, error) {
 		if itr.window.time == influxql.MinTime {
 			itr.window.time, _ = itr.opt.Window(p.Time)
 		}
-		if itr.opt.Location != nil {
-			_, itr.window.offset = itr.opt.Zone(itr.window.time)
-		}
 		itr.prev = IntegerPoint{Nil: true}
 	}
 
@@ -4182,24 +4208,21 @@ CONSTRUCT:
 
 	// Advance the expected time. Do not advance to a new window here
 	// as there may be lingering points with the same timestamp in the previous
-	// window.
+	// window. Calendar intervals and intervals in a location vary in length,
+	// so the next window is found from the current one.
+	if itr.opt.Interval.Months > 0 || itr.opt.Location != nil {
+		if itr.opt.Ascending {
+			_, itr.window.time = itr.opt.Window(itr.window.time)
+		} else {
+			itr.window.time, _ = itr.opt.Window(itr.window.time - 1)
+		}
+		return p, nil
+	}
 	if itr.opt.Ascending {
 		itr.window.time += int64(itr.opt.Interval.Duration)
 	} else {
 		itr.window.time -= int64(itr.opt.Interval.Duration)
 	}
-
-	// Check to see if we have passed over an offset change and adjust the time
-	// to account for this new offset.
-	if itr.opt.Location != nil {
-		if _, offset := itr.opt.Zone(itr.window.time - 1); offset != itr.window.offset {
-			diff := itr.window.offset - offset
-			if abs(diff) < int64(itr.opt.Interval.Duration) {
-				itr.window.time += diff
-			}
-			itr.window.offset = offset
-		}
-	}
 	return p, nil
 }
 
@@ -4361,6 +4384,9 @@ func (itr *integerAuxIterator) Next() (*IntegerPoint, error) {
 func (itr *integerAuxIterator) Iterator(name string, typ influxql.DataType) Iterator {
 	return itr.fields.iterator(name, typ)
 }
+func (itr *integerAuxIterator) CastIterator(name string, typ influxql.DataType) Iterator {
+	return itr.fields.castIterator(name, typ)
+}
 
 func (itr *integerAuxIterator) stream() {
 	for {
@@ -6753,7 +6779,7 @@ func (itr *integerFilterIterator) Next() (*IntegerPoint, error) {
 			itr.m[k] = v
 		}
 
-		if !influxql.EvalBool(itr.cond, itr.m) {
+		if !EvalBool(itr.cond, itr.m) {
 			continue
 		}
 		return p, nil
@@ -7446,10 +7472,9 @@ type unsignedFillIterator struct {
 	opt       IteratorOptions
 
 	window struct {
-		name   string
-		tags   Tags
-		time   int64
-		offset int64
+		name string
+		tags Tags
+		time int64
 	}
 }
 
@@ -7499,9 +7524,6 @@ func (itr *unsignedFillIterator) Next() (*UnsignedPoint, error) {
 		if itr.startTime == influxql.MinTime {
 			itr.window.time, _ = itr.opt.Window(p.Time)
 		}
-		if itr.opt.Location != nil {
-			_, itr.window.offset = itr.opt.Zone(itr.window.time)
-		}
 		itr.init = true
 	}
 
@@ -7510,6 +7532,26 @@ func (itr *unsignedFillIterator) Next() (*UnsignedPoint, error) {
 		return nil, err
 	}
 
+	// Points before the start time were read from the fill lookback. They are
+	// not emitted, but seed the previous value of their series.
+	for itr.opt.Ascending && p != nil && p.Time < itr.startTime {
+		if p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
+			// Fill the remaining intervals of the current series first.
+			if itr.window.time <= itr.endTime {
+				itr.input.unread(p)
+				p = nil
+				goto CONSTRUCT
+			}
+			itr.window.name, itr.window.tags = p.Name, p.Tags
+			itr.window.time = itr.startTime
+		}
+		itr.prev = *p
+
+		if p, err = itr.input.Next(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check if the next point is outside of our window or is nil.
 	if p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
 		// If we are inside of an interval, unread the point and continue below to
@@ -7536,9 +7578,6 @@ func (itr *unsignedFillIterator) Next() (*UnsignedPoint, error) {
 		if itr.window.time == influxql.MinTime {
 			itr.window.time, _ = itr.opt.Window(p.Time)
 		}
-		if itr.opt.Location != nil {
-			_, itr.window.offset = itr.opt.Zone(itr.window.time)
-		}
 		itr.prev = UnsignedPoint{Nil: true}
 	}
 
@@ -7591,24 +7630,21 @@ CONSTRUCT:
 
 	// Advance the expected time. Do not advance to a new window here
 	// as there may be lingering points with the same timestamp in the previous
-	// window.
+	// window. Calendar intervals and intervals in a location vary in length,
+	// so the next window is found from the current one.
+	if itr.opt.Interval.Months > 0 || itr.opt.Location != nil {
+		if itr.opt.Ascending {
+			_, itr.window.time = itr.opt.Window(itr.window.time)
+		} else {
+			itr.window.time, _ = itr.opt.Window(itr.window.time - 1)
+		}
+		return p, nil
+	}
 	if itr.opt.Ascending {
 		itr.window.time += int64(itr.opt.Interval.Duration)
 	} else {
 		itr.window.time -= int64(itr.opt.Interval.Duration)
 	}
-
-	// Check to see if we have passed over an offset change and adjust the time
-	// to account for this new offset.
-	if itr.opt.Location != nil {
-		if _, offset := itr.opt.Zone(itr.window.time - 1); offset != itr.window.offset {
-			diff := itr.window.offset - offset
-			if abs(diff) < int64(itr.opt.Interval.Duration) {
-				itr.window.time += diff
-			}
-			itr.window.offset = offset
-		}
-	}
 	return p, nil
 }
 
@@ -7770,6 +7806,9 @@ func (itr *unsignedAuxIterator) Next() (*UnsignedPoint, error) {
 func (itr *unsignedAuxIterator) Iterator(name string, typ influxql.DataType) Iterator {
 	return itr.fields.iterator(name, typ)
 }
+func (itr *unsignedAuxIterator) CastIterator(name string, typ influxql.DataType) Iterator {
+	return itr.fields.castIterator(name, typ)
+}
 
 func (itr *unsignedAuxIterator) stream() {
 	for {
@@ -10162,7 +10201,7 @@ func (itr *unsignedFilterIterator) Next() (*UnsignedPoint, error) {
 			itr.m[k] = v
 		}
 
-		if !influxql.EvalBool(itr.cond, itr.m) {
+		if !EvalBool(itr.cond, itr.m) {
 			continue
 		}
 		return p, nil
@@ -10855,10 +10894,9 @@ type stringFillIterator struct {
 	opt       IteratorOptions
 
 	window struct {
-		name   string
-		tags   Tags
-		time   int64
-		offset int64
+		name string
+		tags Tags
+		time int64
 	}
 }
 
@@ -10908,9 +10946,6 @@ func (itr *stringFillIterator) Next() (*StringPoint, error) {
 		if itr.startTime == influxql.MinTime {
 			itr.window.time, _ = itr.opt.Window(p.Time)
 		}
-		if itr.opt.Location != nil {
-			_, itr.window.offset = itr.opt.Zone(itr.window.time)
-		}
 		itr.init = true
 	}
 
@@ -10919,6 +10954,26 @@ func (itr *stringFillIterator) Next() (*StringPoint, error) {
 		return nil, err
 	}
 
+	// Points before the start time were read from the fill lookback. They are
+	// not emitted, but seed the previous value of their series.
+	for itr.opt.Ascending && p != nil && p.Time < itr.startTime {
+		if p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
+			// Fill the remaining intervals of the current series first.
+			if itr.window.time <= itr.endTime {
+				itr.input.unread(p)
+				p = nil
+				goto CONSTRUCT
+			}
+			itr.window.name, itr.window.tags = p.Name, p.Tags
+			itr.window.time = itr.startTime
+		}
+		itr.prev = *p
+
+		if p, err = itr.input.Next(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check if the next point is outside of our window or is nil.
 	if p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
 		// If we are inside of an interval, unread the point and continue below to
@@ -10945,9 +11000,6 @@ func (itr *stringFillIterator) Next() (*StringPoint, error) {
 		if itr.window.time == influxql.MinTime {
 			itr.window.time, _ = itr.opt.Window(p.Time)
 		}
-		if itr.opt.Location != nil {
-			_, itr.window.offset = itr.opt.Zone(itr.window.time)
-		}
 		itr.prev = StringPoint{Nil: true}
 	}
 
@@ -10986,24 +11038,21 @@ CONSTRUCT:
 
 	// Advance the expected time. Do not advance to a new window here
 	// as there may be lingering points with the same timestamp in the previous
-	// window.
+	// window. Calendar intervals and intervals in a location vary in length,
+	// so the next window is found from the current one.
+	if itr.opt.Interval.Months > 0 || itr.opt.Location != nil {
+		if itr.opt.Ascending {
+			_, itr.window.time = itr.opt.Window(itr.window.time)
+		} else {
+			itr.window.time, _ = itr.opt.Window(itr.window.time - 1)
+		}
+		return p, nil
+	}
 	if itr.opt.Ascending {
 		itr.window.time += int64(itr.opt.Interval.Duration)
 	} else {
 		itr.window.time -= int64(itr.opt.Interval.Duration)
 	}
-
-	// Check to see if we have passed over an offset change and adjust the time
-	// to account for this new offset.
-	if itr.opt.Location != nil {
-		if _, offset := itr.opt.Zone(itr.window.time - 1); offset != itr.window.offset {
-			diff := itr.window.offset - offset
-			if abs(diff) < int64(itr.opt.Interval.Duration) {
-				itr.window.time += diff
-			}
-			itr.window.offset = offset
-		}
-	}
 	return p, nil
 }
 
@@ -11165,6 +11214,9 @@ func (itr *stringAuxIterator) Next() (*StringPoint, error) {
 func (itr *stringAuxIterator) Iterator(name string, typ influxql.DataType) Iterator {
 	return itr.fields.iterator(name, typ)
 }
+func (itr *stringAuxIterator) CastIterator(name string, typ influxql.DataType) Iterator {
+	return itr.fields.castIterator(name, typ)
+}
 
 func (itr *stringAuxIterator) stream() {
 	for {
@@ -13557,7 +13609,7 @@ func (itr *stringFilterIterator) Next() (*StringPoint, error) {
 			itr.m[k] = v
 		}
 
-		if !influxql.EvalBool(itr.cond, itr.m) {
+		if !EvalBool(itr.cond, itr.m) {
 			continue
 		}
 		return p, nil
@@ -14250,10 +14302,9 @@ type booleanFillIterator struct {
 	opt       IteratorOptions
 
 	window struct {
-		name   string
-		tags   Tags
-		time   int64
-		offset int64
+		name string
+		tags Tags
+		time int64
 	}
 }
 
@@ -14303,9 +14354,6 @@ func (itr *booleanFillIterator) Next() (*BooleanPoint, error) {
 		if itr.startTime == influxql.MinTime {
 			itr.window.time, _ = itr.opt.Window(p.Time)
 		}
-		if itr.opt.Location != nil {
-			_, itr.window.offset = itr.opt.Zone(itr.window.time)
-		}
 		itr.init = true
 	}
 
@@ -14314,6 +14362,26 @@ func (itr *booleanFillIterator) Next() (*BooleanPoint, error) {
 		return nil, err
 	}
 
+	// Points before the start time were read from the fill lookback. They are
+	// not emitted, but seed the previous value of their series.
+	for itr.opt.Ascending && p != nil && p.Time < itr.startTime {
+		if p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
+			// Fill the remaining intervals of the current series first.
+			if itr.window.time <= itr.endTime {
+				itr.input.unread(p)
+				p = nil
+				goto CONSTRUCT
+			}
+			itr.window.name, itr.window.tags = p.Name, p.Tags
+			itr.window.time = itr.startTime
+		}
+		itr.prev = *p
+
+		if p, err = itr.input.Next(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check if the next point is outside of our window or is nil.
 	if p == nil || p.Name != itr.window.name || p.Tags.ID() != itr.window.tags.ID() {
 		// If we are inside of an interval, unread the point and continue below to
@@ -14340,9 +14408,6 @@ func (itr *booleanFillIterator) Next() (*BooleanPoint, error) {
 		if itr.window.time == influxql.MinTime {
 			itr.window.time, _ = itr.opt.Window(p.Time)
 		}
-		if itr.opt.Location != nil {
-			_, itr.window.offset = itr.opt.Zone(itr.window.time)
-		}
 		itr.prev = BooleanPoint{Nil: true}
 	}
 
@@ -14381,24 +14446,21 @@ CONSTRUCT:
 
 	// Advance the expected time. Do not advance to a new window here
 	// as there may be lingering points with the same timestamp in the previous
-	// window.
+	// window. Calendar intervals and intervals in a location vary in length,
+	// so the next window is found from the current one.
+	if itr.opt.Interval.Months > 0 || itr.opt.Location != nil {
+		if itr.opt.Ascending {
+			_, itr.window.time = itr.opt.Window(itr.window.time)
+		} else {
+			itr.window.time, _ = itr.opt.Window(itr.window.time - 1)
+		}
+		return p, nil
+	}
 	if itr.opt.Ascending {
 		itr.window.time += int64(itr.opt.Interval.Duration)
 	} else {
 		itr.window.time -= int64(itr.opt.Interval.Duration)
 	}
-
-	// Check to see if we have passed over an offset change and adjust the time
-	// to account for this new offset.
-	if itr.opt.Location != nil {
-		if _, offset := itr.opt.Zone(itr.window.time - 1); offset != itr.window.offset {
-			diff := itr.window.offset - offset
-			if abs(diff) < int64(itr.opt.Interval.Duration) {
-				itr.window.time += diff
-			}
-			itr.window.offset = offset
-		}
-	}
 	return p, nil
 }
 
@@ -14560,6 +14622,9 @@ func (itr *booleanAuxIterator) Next() (*BooleanPoint, error) {
 func (itr *booleanAuxIterator) Iterator(name string, typ influxql.DataType) Iterator {
 	return itr.fields.iterator(name, typ)
 }
+func (itr *booleanAuxIterator) CastIterator(name string, typ influxql.DataType) Iterator {
+	return itr.fields.castIterator(name, typ)
+}
 
 func (itr *booleanAuxIterator) stream() {
 	for {
@@ -16952,7 +17017,7 @@ func (itr *booleanFilterIterator) Next() (*BooleanPoint, error) {
 			itr.m[k] = v
 		}
 
-		if !influxql.EvalBool(itr.cond, itr.m) {
+		if !EvalBool(itr.cond, itr.m) {
 			continue
 		}
 		return p, nil
@@ -143,6 +143,12 @@ func (a Iterators) Merge(opt IteratorOptions) (Iterator, error) {
 			Name: "sum",
 			Args: call.Args,
 		}
+	} else if call.Name == "approx_percentile" {
+		// The digests of approx_percentile() are merged into one digest.
+		return newDigestMergeIterator(itr, opt)
+	} else if call.Name == "histogram" {
+		// The bucket counts of histogram() are added together.
+		return newHistogramMergeIterator(itr, opt)
 	}
 	return NewCallIterator(itr, opt)
 }
@@ -411,6 +417,10 @@ type AuxIterator interface {
 	// Auxilary iterator
 	Iterator(name string, typ influxql.DataType) Iterator
 
+	// CastIterator returns an iterator casting the values of a field read
+	// with any type to typ.
+	CastIterator(name string, typ influxql.DataType) Iterator
+
 	// Start starts writing to the created iterators.
 	Start()
 
@@ -492,35 +502,49 @@ func (a *auxIteratorFields) iterator(name string, typ influxql.DataType) Iterato
 			continue
 		}
 
-		// Create channel iterator by data type.
-		switch f.typ {
-		case influxql.Float:
-			itr := &floatChanIterator{cond: sync.NewCond(&sync.Mutex{})}
-			f.append(itr)
-			return itr
-		case influxql.Integer:
-			itr := &integerChanIterator{cond: sync.NewCond(&sync.Mutex{})}
-			f.append(itr)
-			return itr
-		case influxql.Unsigned:
-			itr := &unsignedChanIterator{cond: sync.NewCond(&sync.Mutex{})}
-			f.append(itr)
+		if itr := f.newIterator(f.typ); itr != nil {
 			return itr
-		case influxql.String, influxql.Tag:
-			itr := &stringChanIterator{cond: sync.NewCond(&sync.Mutex{})}
-			f.append(itr)
-			return itr
-		case influxql.Boolean:
-			itr := &booleanChanIterator{cond: sync.NewCond(&sync.Mutex{})}
-			f.append(itr)
-			return itr
-		default:
 		}
 	}
 
 	return &nilFloatIterator{}
 }
 
+// castIterator creates a new iterator casting the values of a named auxiliary
+// field read with any type to typ.
+func (a *auxIteratorFields) castIterator(name string, typ influxql.DataType) Iterator {
+	for _, f := range a.fields {
+		if f.name != name || f.typ != influxql.AnyField {
+			continue
+		}
+		if itr := f.newIterator(typ); itr != nil {
+			return itr
+		}
+	}
+	return &nilFloatIterator{}
+}
+
+// newIterator creates a new channel iterator of a data type for the field.
+func (f *auxIteratorField) newIterator(typ influxql.DataType) Iterator {
+	var itr Iterator
+	switch typ {
+	case influxql.Float:
+		itr = &floatChanIterator{cond: sync.NewCond(&sync.Mutex{})}
+	case influxql.Integer:
+		itr = &integerChanIterator{cond: sync.NewCond(&sync.Mutex{})}
+	case influxql.Unsigned:
+		itr = &unsignedChanIterator{cond: sync.NewCond(&sync.Mutex{})}
+	case influxql.String, influxql.Tag:
+		itr = &stringChanIterator{cond: sync.NewCond(&sync.Mutex{})}
+	case influxql.Boolean:
+		itr = &booleanChanIterator{cond: sync.NewCond(&sync.Mutex{})}
+	default:
+		return nil
+	}
+	f.append(itr)
+	return itr
+}
+
 // send sends a point to all field iterators.
 func (a *auxIteratorFields) send(p Point) (ok bool) {
 	values := p.aux()
@@ -536,6 +560,11 @@ func (a *auxIteratorFields) send(p Point) (ok bool) {
 		// Send new point for each aux iterator.
 		// Primitive pointers represent nil values.
 		for _, itr := range f.itrs {
+			v := v
+			if f.typ == influxql.AnyField {
+				v = castValue(v, iteratorDataType(itr))
+			}
+
 			switch itr := itr.(type) {
 			case *floatChanIterator:
 				ok = itr.setBuf(p.name(), tags, p.time(), v) || ok
@@ -690,6 +719,10 @@ type IteratorOptions struct {
 	Fill      influxql.FillOption
 	FillValue interface{}
 
+	// Maximum duration before the start time to read points from to seed
+	// fill(previous) and fill(linear).
+	FillLookback time.Duration
+
 	// Condition to filter by.
 	Condition influxql.Expr
 
@@ -748,20 +781,10 @@ func newIteratorOptionsStmt(stmt *influxql.SelectStatement, sopt SelectOptions)
 	opt.Location = stmt.Location
 
 	// Determine group by interval.
-	interval, err := stmt.GroupByInterval()
+	opt.Interval, err = groupByInterval(stmt)
 	if err != nil {
 		return opt, err
 	}
-	// Set duration to zero if a negative interval has been used.
-	if interval < 0 {
-		interval = 0
-	} else if interval > 0 {
-		opt.Interval.Offset, err = stmt.GroupByOffset()
-		if err != nil {
-			return opt, err
-		}
-	}
-	opt.Interval.Duration = interval
 
 	// Always request an ordered output for the top level iterators.
 	// The emitter will always emit points as ordered.
@@ -791,6 +814,7 @@ func newIteratorOptionsStmt(stmt *influxql.SelectStatement, sopt SelectOptions)
 	opt.Limit, opt.Offset = stmt.Limit, stmt.Offset
 	opt.SLimit, opt.SOffset = stmt.SLimit, stmt.SOffset
 	opt.MaxSeriesN = sopt.MaxSeriesN
+	opt.FillLookback = sopt.FillLookback
 	opt.InterruptCh = sopt.InterruptCh
 	opt.Authorizer = sopt.Authorizer
 
@@ -860,10 +884,10 @@ func newIteratorOptionsSubstatement(ctx context.Context, stmt *influxql.SelectSt
 
 	// If there is no interval for this subquery, but the outer query has an
 	// interval, inherit the parent interval.
-	interval, err := stmt.GroupByInterval()
+	interval, err := groupByInterval(stmt)
 	if err != nil {
 		return IteratorOptions{}, err
-	} else if interval == 0 {
+	} else if interval.IsZero() {
 		subOpt.Interval = opt.Interval
 	}
 	return subOpt, nil
@@ -887,6 +911,8 @@ func (opt IteratorOptions) SeekTime() int64 {
 func (opt IteratorOptions) Window(t int64) (start, end int64) {
 	if opt.Interval.IsZero() {
 		return opt.StartTime, opt.EndTime + 1
+	} else if opt.Interval.Months > 0 {
+		return opt.calendarWindow(t)
 	}
 
 	// Subtract the offset to the time so we calculate the correct base interval.
@@ -911,17 +937,27 @@ func (opt IteratorOptions) Window(t int64) (start, end int64) {
 		start = influxql.MinTime
 	} else {
 		start = t - dt
-	}
 
-	// Look for the start offset again because the first time may have been
-	// after the offset switch. Now that we are at midnight in UTC, we can
-	// lookup the zone offset again to get the real starting offset.
-	if opt.Location != nil {
-		_, startOffset := opt.Zone(start)
-		// Do not adjust the offset if the offset change is greater than or
-		// equal to the duration.
-		if o := zone - startOffset; o != 0 && abs(o) < int64(opt.Interval.Duration) {
-			start += o
+		// The start time was found with the zone offset of t. If the offset
+		// changed between the start of the window and t, the window starts at
+		// the first time the clocks in the location read the start of the
+		// window. Clocks set back may read the start of the window twice, such
+		// as midnight in zones switching from daylight saving time at 1am,
+		// and clocks set forward may skip it, such as midnight in zones
+		// switching to daylight saving time at midnight.
+		if _, startOffset := opt.Zone(start - 1); startOffset != zone {
+			switchTime := opt.zoneSwitch(start-1, t)
+			_, prevOffset := opt.Zone(switchTime - 1)
+
+			// Do not adjust the start time if the offset change is greater than
+			// or equal to the duration.
+			if abs(zone-prevOffset) < int64(opt.Interval.Duration) {
+				if switchTime-1+prevOffset >= t+zone-dt {
+					start = t + zone - dt - prevOffset
+				} else {
+					start = switchTime
+				}
+			}
 		}
 	}
 	start += int64(opt.Interval.Offset)
@@ -931,30 +967,24 @@ func (opt IteratorOptions) Window(t int64) (start, end int64) {
 		end = influxql.MaxTime
 	} else {
 		end = t + dt
-	}
 
-	// Retrieve the zone offset for the end time.
-	if opt.Location != nil {
-		_, endOffset := opt.Zone(end)
-		// Adjust the end time if the offset is different from the start offset.
-		// Only apply the offset if it is smaller than the duration.
-		// This prevents going back in time and creating time windows
-		// that don't make any sense.
-		if o := zone - endOffset; o != 0 && abs(o) < int64(opt.Interval.Duration) {
-			// If the offset is greater than 0, that means we are adding time.
-			// Added time goes into the previous interval because the clocks
-			// move backwards. If the offset is less than 0, then we are skipping
-			// time. Skipped time comes after the switch so if we have a time
-			// interval that lands on the switch, it comes from the next
-			// interval and not the current one. For this reason, we need to know
-			// when the actual switch happens by seeing if the time switch is within
-			// the current interval. We calculate the zone offset with the offset
-			// and see if the value is the same. If it is, we apply the
-			// offset.
-			if o > 0 {
-				end += o
-			} else if _, z := opt.Zone(end + o); z == endOffset {
-				end += o
+		// Adjust the end time in the same way if the offset changes between t
+		// and the end of the window. If the clocks read the end of the window
+		// after the switch, the window ends then. Otherwise, the clocks skipped
+		// the end of the window and the window ends at the switch.
+		if _, endOffset := opt.Zone(end); endOffset != zone {
+			switchTime := opt.zoneSwitch(t, end)
+			_, nextOffset := opt.Zone(switchTime)
+
+			// Only apply the offset if it is smaller than the duration.
+			// This prevents going back in time and creating time windows
+			// that don't make any sense.
+			if abs(zone-nextOffset) < int64(opt.Interval.Duration) {
+				if switchTime+nextOffset < end+zone {
+					end += zone - nextOffset
+				} else {
+					end = switchTime
+				}
 			}
 		}
 	}
@@ -962,6 +992,45 @@ func (opt IteratorOptions) Window(t int64) (start, end int64) {
 	return
 }
 
+// extendWindows extends the time range of opt by n intervals before the start
+// time, or after the end time for descending iterators, so that the windows
+// preceding the first one are read. Calendar months differ in length, so
+// month intervals are extended one window at a time.
+func (opt *IteratorOptions) extendWindows(n int64) {
+	if opt.Interval.IsZero() || n <= 0 {
+		return
+	} else if opt.Interval.Months == 0 {
+		if opt.Ascending {
+			opt.StartTime -= int64(opt.Interval.Duration) * n
+		} else {
+			opt.EndTime += int64(opt.Interval.Duration) * n
+		}
+		return
+	}
+
+	for i := int64(0); i < n; i++ {
+		if opt.Ascending && opt.StartTime > influxql.MinTime {
+			opt.StartTime, _ = opt.Window(opt.StartTime - 1)
+		} else if !opt.Ascending && opt.EndTime < influxql.MaxTime {
+			if _, end := opt.Window(opt.EndTime + 1); end < influxql.MaxTime {
+				opt.EndTime = end - 1
+			} else {
+				opt.EndTime = influxql.MaxTime
+			}
+		}
+	}
+}
+
+// seedsFill returns true if the fill of the intervals is seeded with the points
+// within the fill lookback before the start time. Only ascending fill(previous)
+// and fill(linear) use the points before the start time.
+func (opt IteratorOptions) seedsFill() bool {
+	if opt.FillLookback <= 0 || !opt.Ascending || opt.StartTime == influxql.MinTime {
+		return false
+	}
+	return opt.Fill == influxql.PreviousFill || opt.Fill == influxql.LinearFill
+}
+
 // DerivativeInterval returns the time interval for the derivative function.
 func (opt IteratorOptions) DerivativeInterval() Interval {
 	// Use the interval on the derivative() call, if specified.
@@ -1020,6 +1089,21 @@ func (opt *IteratorOptions) Zone(ns int64) (string, int64) {
 	return name, secToNs * int64(offset)
 }
 
+// zoneSwitch returns the first time after lo and no later than hi with a
+// different zone offset than lo. The offset must change between lo and hi.
+func (opt *IteratorOptions) zoneSwitch(lo, hi int64) int64 {
+	_, offset := opt.Zone(lo)
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if _, o := opt.Zone(mid); o == offset {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
+
 // MarshalBinary encodes opt into a binary format.
 func (opt *IteratorOptions) MarshalBinary() ([]byte, error) {
 	return proto.Marshal(encodeIteratorOptions(opt))
@@ -1251,6 +1335,10 @@ func newSelectInfo(stmt *influxql.SelectStatement) *selectInfo {
 func (v *selectInfo) Visit(n influxql.Node) influxql.Visitor {
 	switch n := n.(type) {
 	case *influxql.Call:
+		// Scalar functions are evaluated on the points of their arguments.
+		if isScalarFunction(n) {
+			return v
+		}
 		v.calls[n] = struct{}{}
 		return nil
 	case *influxql.VarRef:
@@ -1280,6 +1368,10 @@ func (v *selectInfo) FindSelector() *influxql.Call {
 type Interval struct {
 	Duration time.Duration
 	Offset   time.Duration
+
+	// Months is the number of calendar months of the interval. The duration
+	// of a calendar interval approximates its length.
+	Months int
 }
 
 // IsZero returns true if the interval has no duration.
@@ -1289,6 +1381,7 @@ func encodeInterval(i Interval) *internal.Interval {
 	return &internal.Interval{
 		Duration: proto.Int64(i.Duration.Nanoseconds()),
 		Offset:   proto.Int64(i.Offset.Nanoseconds()),
+		Months:   proto.Int64(int64(i.Months)),
 	}
 }
 
@@ -1296,6 +1389,7 @@ func decodeInterval(pb *internal.Interval) Interval {
 	return Interval{
 		Duration: time.Duration(pb.GetDuration()),
 		Offset:   time.Duration(pb.GetOffset()),
+		Months:   int(pb.GetMonths()),
 	}
 }
 
@@ -1362,6 +1456,60 @@ func (itr *integerFloatTransformIterator) Next() (*FloatPoint, error) {
 // new point if possible.
 type integerFloatTransformFunc func(p *IntegerPoint) *FloatPoint
 
+// stringIntegerTransformIterator executes a function to modify an existing point for every
+// output of the input iterator.
+type stringIntegerTransformIterator struct {
+	input StringIterator
+	fn    stringIntegerTransformFunc
+}
+
+// Stats returns stats from the input iterator.
+func (itr *stringIntegerTransformIterator) Stats() IteratorStats { return itr.input.Stats() }
+
+// Close closes the iterator and all child iterators.
+func (itr *stringIntegerTransformIterator) Close() error { return itr.input.Close() }
+
+// Next returns the next transformed point.
+func (itr *stringIntegerTransformIterator) Next() (*IntegerPoint, error) {
+	p, err := itr.input.Next()
+	if err != nil {
+		return nil, err
+	} else if p != nil {
+		return itr.fn(p), nil
+	}
+	return nil, nil
+}
+
+// stringIntegerTransformFunc creates or modifies a point.
+type stringIntegerTransformFunc func(p *StringPoint) *IntegerPoint
+
+// stringBooleanTransformIterator executes a function to modify an existing point for every
+// output of the input iterator.
+type stringBooleanTransformIterator struct {
+	input StringIterator
+	fn    stringBooleanTransformFunc
+}
+
+// Stats returns stats from the input iterator.
+func (itr *stringBooleanTransformIterator) Stats() IteratorStats { return itr.input.Stats() }
+
+// Close closes the iterator and all child iterators.
+func (itr *stringBooleanTransformIterator) Close() error { return itr.input.Close() }
+
+// Next returns the next transformed point.
+func (itr *stringBooleanTransformIterator) Next() (*BooleanPoint, error) {
+	p, err := itr.input.Next()
+	if err != nil {
+		return nil, err
+	} else if p != nil {
+		return itr.fn(p), nil
+	}
+	return nil, nil
+}
+
+// stringBooleanTransformFunc creates or modifies a point.
+type stringBooleanTransformFunc func(p *StringPoint) *BooleanPoint
+
 type integerFloatCastIterator struct {
 	input IntegerIterator
 	point FloatPoint
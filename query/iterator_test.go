@@ -1041,6 +1041,38 @@ func TestFillIterator_DST(t *testing.T) {
 				Ascending: false,
 			},
 		},
+		{
+			name:  "Midnight_GroupByDay_Ascending",
+			start: mustParseTime("2006-10-28T00:00:00-04:00"),
+			end:   mustParseTime("2006-10-31T00:00:00-05:00"),
+			points: []time.Duration{
+				24 * time.Hour,
+				49 * time.Hour,
+			},
+			opt: query.IteratorOptions{
+				Interval: query.Interval{
+					Duration: 24 * time.Hour,
+				},
+				Location:  mustLoadLocation("America/Havana"),
+				Ascending: true,
+			},
+		},
+		{
+			name:  "Midnight_GroupByDay_Descending",
+			start: mustParseTime("2006-10-28T00:00:00-04:00"),
+			end:   mustParseTime("2006-10-31T00:00:00-05:00"),
+			points: []time.Duration{
+				49 * time.Hour,
+				24 * time.Hour,
+			},
+			opt: query.IteratorOptions{
+				Interval: query.Interval{
+					Duration: 24 * time.Hour,
+				},
+				Location:  mustLoadLocation("America/Havana"),
+				Ascending: false,
+			},
+		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
 			opt := tt.opt
@@ -1297,6 +1329,166 @@ func TestIteratorOptions_Window_Location(t *testing.T) {
 	}
 }
 
+// Ensure windows in a location start and end when the clocks in the location
+// read the window boundaries, even when daylight saving time starts or ends at
+// midnight or on a window boundary.
+func TestIteratorOptions_Window_Zones(t *testing.T) {
+	for _, tt := range []struct {
+		loc        *time.Location
+		now        time.Time
+		start, end time.Time
+		interval   time.Duration
+	}{
+		{
+			loc:      mustLoadLocation("Europe/London"),
+			now:      mustParseTime("2000-03-26T12:00:00+01:00"),
+			start:    mustParseTime("2000-03-26T00:00:00Z"),
+			end:      mustParseTime("2000-03-27T00:00:00+01:00"),
+			interval: 24 * time.Hour,
+		},
+		{
+			loc:      mustLoadLocation("Europe/London"),
+			now:      mustParseTime("2000-10-29T12:00:00Z"),
+			start:    mustParseTime("2000-10-29T00:00:00+01:00"),
+			end:      mustParseTime("2000-10-30T00:00:00Z"),
+			interval: 24 * time.Hour,
+		},
+		{
+			loc:      mustLoadLocation("Australia/Sydney"),
+			now:      mustParseTime("2000-03-26T12:00:00+10:00"),
+			start:    mustParseTime("2000-03-26T00:00:00+11:00"),
+			end:      mustParseTime("2000-03-27T00:00:00+10:00"),
+			interval: 24 * time.Hour,
+		},
+		{
+			loc:      mustLoadLocation("Australia/Sydney"),
+			now:      mustParseTime("2000-03-26T02:30:00+10:00"),
+			start:    mustParseTime("2000-03-26T02:00:00+11:00"),
+			end:      mustParseTime("2000-03-26T04:00:00+10:00"),
+			interval: 2 * time.Hour,
+		},
+		{
+			loc:      mustLoadLocation("Australia/Sydney"),
+			now:      mustParseTime("2001-10-28T12:00:00+11:00"),
+			start:    mustParseTime("2001-10-28T00:00:00+10:00"),
+			end:      mustParseTime("2001-10-29T00:00:00+11:00"),
+			interval: 24 * time.Hour,
+		},
+		{
+			loc:      mustLoadLocation("America/Sao_Paulo"),
+			now:      mustParseTime("2017-10-14T12:00:00-03:00"),
+			start:    mustParseTime("2017-10-14T00:00:00-03:00"),
+			end:      mustParseTime("2017-10-15T01:00:00-02:00"),
+			interval: 24 * time.Hour,
+		},
+		{
+			loc:      mustLoadLocation("America/Sao_Paulo"),
+			now:      mustParseTime("2017-10-15T12:00:00-02:00"),
+			start:    mustParseTime("2017-10-15T01:00:00-02:00"),
+			end:      mustParseTime("2017-10-16T00:00:00-02:00"),
+			interval: 24 * time.Hour,
+		},
+		{
+			loc:      mustLoadLocation("America/Havana"),
+			now:      mustParseTime("2006-10-28T12:00:00-04:00"),
+			start:    mustParseTime("2006-10-28T00:00:00-04:00"),
+			end:      mustParseTime("2006-10-29T00:00:00-04:00"),
+			interval: 24 * time.Hour,
+		},
+		{
+			loc:      mustLoadLocation("America/Havana"),
+			now:      mustParseTime("2006-10-29T00:30:00-05:00"),
+			start:    mustParseTime("2006-10-29T00:00:00-04:00"),
+			end:      mustParseTime("2006-10-30T00:00:00-05:00"),
+			interval: 24 * time.Hour,
+		},
+		{
+			loc:      mustLoadLocation("America/Los_Angeles"),
+			now:      mustParseTime("2000-04-03T12:00:00-07:00"),
+			start:    mustParseTime("2000-03-30T00:00:00-08:00"),
+			end:      mustParseTime("2000-04-06T00:00:00-07:00"),
+			interval: 7 * 24 * time.Hour,
+		},
+	} {
+		t.Run(fmt.Sprintf("%s/%s/%s", tt.loc, tt.now, tt.interval), func(t *testing.T) {
+			opt := query.IteratorOptions{
+				Location: tt.loc,
+				Interval: query.Interval{
+					Duration: tt.interval,
+				},
+			}
+			start, end := opt.Window(tt.now.UnixNano())
+			if have, want := time.Unix(0, start).In(tt.loc), tt.start; !have.Equal(want) {
+				t.Errorf("unexpected start time: %s != %s", have, want)
+			}
+			if have, want := time.Unix(0, end).In(tt.loc), tt.end; !have.Equal(want) {
+				t.Errorf("unexpected end time: %s != %s", have, want)
+			}
+		})
+	}
+}
+
+func TestIteratorOptions_Window_Calendar(t *testing.T) {
+	for _, tt := range []struct {
+		now        time.Time
+		start, end time.Time
+		months     int
+		offset     time.Duration
+		loc        *time.Location
+	}{
+		{
+			now:    mustParseTime("2000-02-15T12:00:00Z"),
+			start:  mustParseTime("2000-02-01T00:00:00Z"),
+			end:    mustParseTime("2000-03-01T00:00:00Z"),
+			months: 1,
+		},
+		{
+			now:    mustParseTime("2001-07-04T00:00:00Z"),
+			start:  mustParseTime("2001-01-01T00:00:00Z"),
+			end:    mustParseTime("2002-01-01T00:00:00Z"),
+			months: 12,
+		},
+		{
+			now:    mustParseTime("1969-11-20T00:00:00Z"),
+			start:  mustParseTime("1969-10-01T00:00:00Z"),
+			end:    mustParseTime("1970-01-01T00:00:00Z"),
+			months: 3,
+		},
+		{
+			now:    mustParseTime("2000-03-10T00:00:00Z"),
+			start:  mustParseTime("2000-02-15T00:00:00Z"),
+			end:    mustParseTime("2000-03-15T00:00:00Z"),
+			months: 1,
+			offset: 14 * 24 * time.Hour,
+		},
+		{
+			now:    mustParseTime("2000-04-02T12:14:15-07:00"),
+			start:  mustParseTime("2000-04-01T00:00:00-08:00"),
+			end:    mustParseTime("2000-05-01T00:00:00-07:00"),
+			months: 1,
+			loc:    LosAngeles,
+		},
+	} {
+		t.Run(fmt.Sprintf("%s/%dmo", tt.now, tt.months), func(t *testing.T) {
+			opt := query.IteratorOptions{
+				Location: tt.loc,
+				Interval: query.Interval{
+					Duration: time.Duration(tt.months) * 30 * 24 * time.Hour,
+					Offset:   tt.offset,
+					Months:   tt.months,
+				},
+			}
+			start, end := opt.Window(tt.now.UnixNano())
+			if have, want := time.Unix(0, start), tt.start; !have.Equal(want) {
+				t.Errorf("unexpected start time: %s != %s", have, want)
+			}
+			if have, want := time.Unix(0, end), tt.end; !have.Equal(want) {
+				t.Errorf("unexpected end time: %s != %s", have, want)
+			}
+		})
+	}
+}
+
 func TestIteratorOptions_Window_MinTime(t *testing.T) {
 	opt := query.IteratorOptions{
 		StartTime: influxql.MinTime,
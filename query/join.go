@@ -0,0 +1,203 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/influxql"
+)
+
+// joinSource returns the measurement a qualified field reference refers to and
+// the reference to the field within that measurement. It returns nil if the
+// sources are not joined or expr is not a qualified reference. A reference is
+// not qualified if fm maps its whole name in one of the sources, such as a
+// field named "errors.value".
+func joinSource(expr influxql.Expr, sources influxql.Sources, fm influxql.FieldMapper) (*influxql.Measurement, *influxql.VarRef) {
+	ref, ok := expr.(*influxql.VarRef)
+	if !ok || len(sources) < 2 {
+		return nil, nil
+	}
+
+	if fm != nil {
+		for _, source := range sources {
+			if m, ok := source.(*influxql.Measurement); ok && fm.MapType(m, ref.Val) != influxql.Unknown {
+				return nil, nil
+			}
+		}
+	}
+
+	for _, source := range sources {
+		m, ok := source.(*influxql.Measurement)
+		if !ok || m.Name == "" {
+			continue
+		}
+		if strings.HasPrefix(ref.Val, m.Name+".") && len(ref.Val) > len(m.Name)+1 {
+			return m, &influxql.VarRef{Val: ref.Val[len(m.Name)+1:], Type: ref.Type}
+		}
+	}
+	return nil, nil
+}
+
+// isJoin returns true if the fields reference a field qualified with the name
+// of one of the sources. A statement selecting from several measurements joins
+// them when its fields are qualified, such as:
+//
+//	SELECT sum(errors.value) / sum(requests.value) FROM errors, requests GROUP BY time(1m), host
+//
+// Every function call on a qualified field reads only from its measurement.
+// The results of all calls are renamed to a common name so the points of the
+// measurements are matched on their time and their GROUP BY tags.
+func isJoin(fields influxql.Fields, sources influxql.Sources, fm influxql.FieldMapper) bool {
+	var join bool
+	for _, f := range fields {
+		influxql.WalkFunc(f.Expr, func(n influxql.Node) {
+			if m, _ := joinSource(n, sources, fm); m != nil {
+				join = true
+			}
+		})
+	}
+	return join
+}
+
+// validateJoins validates the statements joining measurements within stmt,
+// including stmt itself. The fields of the sources must be mapped by fm to
+// tell qualified references from fields named like them.
+func validateJoins(stmt *influxql.SelectStatement, fm influxql.FieldMapper) error {
+	if isJoin(stmt.Fields, stmt.Sources, fm) {
+		if err := validateJoin(stmt, fm); err != nil {
+			return err
+		}
+	}
+
+	for _, source := range stmt.Sources {
+		if source, ok := source.(*influxql.SubQuery); ok {
+			if err := validateJoins(source.Statement, fm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateJoin validates the fields and sources of a statement joining
+// measurements.
+func validateJoin(stmt *influxql.SelectStatement, fm influxql.FieldMapper) error {
+	for _, source := range stmt.Sources {
+		switch source := source.(type) {
+		case *influxql.Measurement:
+			if source.Regex != nil {
+				return fmt.Errorf("cannot join measurements matching a regex: %s", source)
+			}
+		case *influxql.SubQuery:
+			return fmt.Errorf("cannot join a subquery with measurements: %s", source)
+		}
+	}
+
+	for _, f := range stmt.Fields {
+		if err := validateJoinExpr(f.Expr, stmt.Sources, fm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateJoinExpr validates an expression of a statement joining
+// measurements. Joined measurements are only matched by the results of
+// functions, so every field must be read by a function on a qualified field.
+func validateJoinExpr(expr influxql.Expr, sources influxql.Sources, fm influxql.FieldMapper) error {
+	switch expr := expr.(type) {
+	case *influxql.Call:
+		if isScalarFunction(expr) || len(expr.Args) == 0 {
+			for _, arg := range expr.Args {
+				if err := validateJoinExpr(arg, sources, fm); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		ref, ok := expr.Args[0].(*influxql.VarRef)
+		if !ok {
+			return validateJoinExpr(expr.Args[0], sources, fm)
+		} else if m, _ := joinSource(ref, sources, fm); m == nil {
+			return fmt.Errorf("field %s must be qualified with a measurement when joining measurements", ref)
+		}
+		return nil
+	case *influxql.BinaryExpr:
+		if err := validateJoinExpr(expr.LHS, sources, fm); err != nil {
+			return err
+		}
+		return validateJoinExpr(expr.RHS, sources, fm)
+	case *influxql.ParenExpr:
+		return validateJoinExpr(expr.Expr, sources, fm)
+	case *influxql.VarRef:
+		return errors.New("joining measurements requires aggregate functions")
+	case *influxql.Wildcard, *influxql.RegexLiteral:
+		return fmt.Errorf("cannot use %s when joining measurements", expr)
+	}
+	return nil
+}
+
+// joinName returns the name of the series of joined measurements.
+func joinName(sources influxql.Sources) string {
+	return strings.Join(sources.Names(), ",")
+}
+
+// newJoinIterator returns an iterator setting the name of the points of input
+// to the name of the joined series.
+func newJoinIterator(input Iterator, name string) (Iterator, error) {
+	switch input := input.(type) {
+	case FloatIterator:
+		return &floatTransformIterator{
+			input: input,
+			fn: func(p *FloatPoint) *FloatPoint {
+				if p != nil {
+					p.Name = name
+				}
+				return p
+			},
+		}, nil
+	case IntegerIterator:
+		return &integerTransformIterator{
+			input: input,
+			fn: func(p *IntegerPoint) *IntegerPoint {
+				if p != nil {
+					p.Name = name
+				}
+				return p
+			},
+		}, nil
+	case UnsignedIterator:
+		return &unsignedTransformIterator{
+			input: input,
+			fn: func(p *UnsignedPoint) *UnsignedPoint {
+				if p != nil {
+					p.Name = name
+				}
+				return p
+			},
+		}, nil
+	case StringIterator:
+		return &stringTransformIterator{
+			input: input,
+			fn: func(p *StringPoint) *StringPoint {
+				if p != nil {
+					p.Name = name
+				}
+				return p
+			},
+		}, nil
+	case BooleanIterator:
+		return &booleanTransformIterator{
+			input: input,
+			fn: func(p *BooleanPoint) *BooleanPoint {
+				if p != nil {
+					p.Name = name
+				}
+				return p
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported join iterator type: %T", input)
+	}
+}
@@ -0,0 +1,120 @@
+package query
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/influxdata/influxql"
+)
+
+// isMathFunction returns true if the call is a math function. Math functions
+// are evaluated on every point of their argument, which is either a field or
+// an aggregate.
+func isMathFunction(call *influxql.Call) bool {
+	switch call.Name {
+	case "abs", "ceil", "exp", "floor", "ln", "log", "log2", "pow", "round", "sqrt":
+		return true
+	}
+	return false
+}
+
+// mathFunc returns the function evaluating the math call on a value.
+func mathFunc(call *influxql.Call) (func(float64) float64, error) {
+	switch call.Name {
+	case "abs":
+		return math.Abs, nil
+	case "ceil":
+		return math.Ceil, nil
+	case "exp":
+		return math.Exp, nil
+	case "floor":
+		return math.Floor, nil
+	case "ln":
+		return math.Log, nil
+	case "log2":
+		return math.Log2, nil
+	case "round":
+		return round, nil
+	case "sqrt":
+		return math.Sqrt, nil
+	case "log", "pow":
+		var arg float64
+		switch lit := call.Args[1].(type) {
+		case *influxql.NumberLiteral:
+			arg = lit.Val
+		case *influxql.IntegerLiteral:
+			arg = float64(lit.Val)
+		default:
+			return nil, fmt.Errorf("expected number as second argument in %s(), found %s", call.Name, call.Args[1])
+		}
+		if call.Name == "log" {
+			base := math.Log(arg)
+			return func(v float64) float64 { return math.Log(v) / base }, nil
+		}
+		return func(v float64) float64 { return math.Pow(v, arg) }, nil
+	}
+	return nil, fmt.Errorf("unsupported math function: %s", call.Name)
+}
+
+// round returns the nearest integer to v, rounding half away from zero.
+func round(v float64) float64 {
+	if v < 0 {
+		return math.Ceil(v - 0.5)
+	}
+	return math.Floor(v + 0.5)
+}
+
+// newMathIterator returns an iterator evaluating a math call on every point of
+// input. The abs(), ceil(), floor() and round() functions keep the type of
+// integers and unsigned integers, the other functions return floats.
+func newMathIterator(input Iterator, call *influxql.Call) (Iterator, error) {
+	fn, err := mathFunc(call)
+	if err != nil {
+		return nil, err
+	}
+
+	switch itr := input.(type) {
+	case IntegerIterator:
+		switch call.Name {
+		case "ceil", "floor", "round":
+			return itr, nil
+		case "abs":
+			return &integerTransformIterator{
+				input: itr,
+				fn: func(p *IntegerPoint) *IntegerPoint {
+					if p == nil {
+						return nil
+					} else if !p.Nil && p.Value < 0 {
+						p.Value = -p.Value
+					}
+					return p
+				},
+			}, nil
+		}
+		input = &integerFloatCastIterator{input: itr}
+	case UnsignedIterator:
+		switch call.Name {
+		case "abs", "ceil", "floor", "round":
+			return itr, nil
+		}
+		input = &unsignedFloatCastIterator{input: itr}
+	}
+
+	switch input := input.(type) {
+	case FloatIterator:
+		return &floatTransformIterator{
+			input: input,
+			fn: func(p *FloatPoint) *FloatPoint {
+				if p == nil {
+					return nil
+				} else if p.Nil {
+					return p
+				}
+				p.Value = fn(p.Value)
+				return p
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported %s iterator type: %T", call.Name, input)
+	}
+}
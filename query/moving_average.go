@@ -0,0 +1,295 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/influxdata/influxql"
+)
+
+// EMAWarmup is how an exponential moving average is computed before it has
+// aggregated a whole period of points.
+type EMAWarmup int
+
+const (
+	// ExponentialWarmup weights the points of the first period as if the
+	// period was the number of points aggregated so far.
+	ExponentialWarmup EMAWarmup = iota
+
+	// SimpleWarmup seeds the average with the simple average of the first
+	// period. No value is produced before the first period is complete.
+	SimpleWarmup
+
+	// NoWarmup seeds the average with the first point.
+	NoWarmup
+)
+
+// ParseEMAWarmup returns the warmup type of its name.
+func ParseEMAWarmup(s string) (EMAWarmup, error) {
+	switch strings.ToLower(s) {
+	case "exponential":
+		return ExponentialWarmup, nil
+	case "simple":
+		return SimpleWarmup, nil
+	case "none":
+		return NoWarmup, nil
+	}
+	return 0, fmt.Errorf("unknown warmup type: %s", s)
+}
+
+// exponentialMovingAverageOrder returns the number of exponential moving
+// averages chained by a function, or zero if it isn't an exponential moving
+// average.
+func exponentialMovingAverageOrder(name string) int {
+	switch name {
+	case "exponential_moving_average":
+		return 1
+	case "double_exponential_moving_average":
+		return 2
+	case "triple_exponential_moving_average":
+		return 3
+	}
+	return 0
+}
+
+// movingAverageArgs returns the period, hold period and warmup type of a
+// validated exponential or Kaufman's adaptive moving average call. The hold
+// period defaults to the number of points before the average of a whole
+// period is available.
+func movingAverageArgs(call *influxql.Call) (period, hold int, warmup EMAWarmup) {
+	period = int(call.Args[1].(*influxql.IntegerLiteral).Val)
+	if order := exponentialMovingAverageOrder(call.Name); order > 0 {
+		hold = order * (period - 1)
+	} else {
+		hold = period
+	}
+	if len(call.Args) > 2 {
+		hold = int(call.Args[2].(*influxql.IntegerLiteral).Val)
+	}
+	if len(call.Args) > 3 {
+		warmup, _ = ParseEMAWarmup(call.Args[3].(*influxql.StringLiteral).Val)
+	}
+	return period, hold, warmup
+}
+
+// ema calculates an exponential moving average.
+type ema struct {
+	period int
+	alpha  float64
+	warmup EMAWarmup
+	n      int
+	sum    float64
+	value  float64
+}
+
+func newEMA(period int, warmup EMAWarmup) ema {
+	return ema{
+		period: period,
+		alpha:  2 / float64(period+1),
+		warmup: warmup,
+	}
+}
+
+// add adds a value to the average.
+func (e *ema) add(v float64) {
+	if e.n < e.period {
+		e.n++
+		switch e.warmup {
+		case ExponentialWarmup:
+			e.value += 2 / float64(e.n+1) * (v - e.value)
+			return
+		case SimpleWarmup:
+			e.sum += v
+			e.value = e.sum / float64(e.n)
+			return
+		}
+		if e.n == 1 {
+			e.value = v
+			return
+		}
+	}
+	e.value += e.alpha * (v - e.value)
+}
+
+// ready returns true if the average has a value.
+func (e *ema) ready() bool {
+	return e.n > 0 && (e.warmup != SimpleWarmup || e.n >= e.period)
+}
+
+// FloatExponentialMovingAverageReducer calculates the exponential moving
+// average of the aggregated points. The average of order 2 is the double
+// exponential moving average 2*EMA - EMA(EMA), and the average of order 3 is
+// the triple exponential moving average 3*EMA - 3*EMA(EMA) + EMA(EMA(EMA)).
+type FloatExponentialMovingAverageReducer struct {
+	emas []ema
+	hold int
+	n    int
+	curr FloatPoint
+}
+
+// NewFloatExponentialMovingAverageReducer creates a new
+// FloatExponentialMovingAverageReducer. The first hold points don't produce
+// any value.
+func NewFloatExponentialMovingAverageReducer(order, period, hold int, warmup EMAWarmup) *FloatExponentialMovingAverageReducer {
+	r := &FloatExponentialMovingAverageReducer{
+		emas: make([]ema, order),
+		hold: hold,
+		curr: FloatPoint{Nil: true},
+	}
+	for i := range r.emas {
+		r.emas[i] = newEMA(period, warmup)
+	}
+	return r
+}
+
+// aggregate adds a value to the averages. Each average averages the values
+// of the previous one once it is ready.
+func (r *FloatExponentialMovingAverageReducer) aggregate(time int64, v float64) {
+	r.n++
+	r.curr = FloatPoint{Time: time, Nil: true}
+	for i := range r.emas {
+		r.emas[i].add(v)
+		if !r.emas[i].ready() {
+			return
+		}
+		v = r.emas[i].value
+	}
+	if r.n <= r.hold {
+		return
+	}
+
+	switch len(r.emas) {
+	case 1:
+		r.curr.Value = r.emas[0].value
+	case 2:
+		r.curr.Value = 2*r.emas[0].value - r.emas[1].value
+	case 3:
+		r.curr.Value = 3*r.emas[0].value - 3*r.emas[1].value + r.emas[2].value
+	}
+	r.curr.Nil = false
+}
+
+// AggregateFloat aggregates a point into the reducer and updates the averages.
+func (r *FloatExponentialMovingAverageReducer) AggregateFloat(p *FloatPoint) {
+	r.aggregate(p.Time, p.Value)
+}
+
+// AggregateInteger aggregates a point into the reducer and updates the averages.
+func (r *FloatExponentialMovingAverageReducer) AggregateInteger(p *IntegerPoint) {
+	r.aggregate(p.Time, float64(p.Value))
+}
+
+// AggregateUnsigned aggregates a point into the reducer and updates the averages.
+func (r *FloatExponentialMovingAverageReducer) AggregateUnsigned(p *UnsignedPoint) {
+	r.aggregate(p.Time, float64(p.Value))
+}
+
+// Emit emits the moving average at the current point. Emit should be called
+// after every call to an aggregate method and it will produce one point once
+// the hold period has passed, otherwise it will produce zero points.
+func (r *FloatExponentialMovingAverageReducer) Emit() []FloatPoint {
+	if r.curr.Nil {
+		return nil
+	}
+	r.curr.Nil = true
+	return []FloatPoint{{Time: r.curr.Time, Value: r.curr.Value}}
+}
+
+// The smoothing constants of the Kaufman's adaptive moving average, for the
+// exponential moving averages of 2 and 30 periods.
+const (
+	kamaFast = 2.0 / (2 + 1)
+	kamaSlow = 2.0 / (30 + 1)
+)
+
+// FloatKaufmansAdaptiveMovingAverageReducer calculates the Kaufman's adaptive
+// moving average of the aggregated points. The average follows the values
+// closely while they trend, and smooths them while they are noisy, according
+// to the efficiency ratio of the last period: the net change of the values
+// over the sum of their absolute changes.
+type FloatKaufmansAdaptiveMovingAverageReducer struct {
+	hold  int
+	n     int
+	buf   []float64
+	pos   int
+	value float64
+	curr  FloatPoint
+}
+
+// NewFloatKaufmansAdaptiveMovingAverageReducer creates a new
+// FloatKaufmansAdaptiveMovingAverageReducer. The first hold points don't
+// produce any value, and neither do the first period points since the
+// efficiency ratio requires period changes.
+func NewFloatKaufmansAdaptiveMovingAverageReducer(period, hold int) *FloatKaufmansAdaptiveMovingAverageReducer {
+	return &FloatKaufmansAdaptiveMovingAverageReducer{
+		hold: hold,
+		buf:  make([]float64, 0, period+1),
+		curr: FloatPoint{Nil: true},
+	}
+}
+
+// aggregate adds a value to the window of the last period changes and
+// updates the average.
+func (r *FloatKaufmansAdaptiveMovingAverageReducer) aggregate(time int64, v float64) {
+	r.n++
+	r.curr = FloatPoint{Time: time, Nil: true}
+	if len(r.buf) != cap(r.buf) {
+		r.buf = append(r.buf, v)
+		if len(r.buf) != cap(r.buf) {
+			return
+		}
+		// Seed the average with the previous value once the window is complete.
+		r.value = r.buf[len(r.buf)-2]
+	} else {
+		r.buf[r.pos] = v
+		r.pos = (r.pos + 1) % len(r.buf)
+	}
+
+	// The oldest value of the window is at pos, and the newest before it.
+	var volatility float64
+	prev := r.buf[r.pos]
+	for i := 1; i < len(r.buf); i++ {
+		curr := r.buf[(r.pos+i)%len(r.buf)]
+		volatility += math.Abs(curr - prev)
+		prev = curr
+	}
+
+	var er float64
+	if volatility != 0 {
+		er = math.Abs(v-r.buf[r.pos]) / volatility
+	}
+	sc := er*(kamaFast-kamaSlow) + kamaSlow
+	r.value += sc * sc * (v - r.value)
+
+	if r.n > r.hold {
+		r.curr.Value = r.value
+		r.curr.Nil = false
+	}
+}
+
+// AggregateFloat aggregates a point into the reducer and updates the average.
+func (r *FloatKaufmansAdaptiveMovingAverageReducer) AggregateFloat(p *FloatPoint) {
+	r.aggregate(p.Time, p.Value)
+}
+
+// AggregateInteger aggregates a point into the reducer and updates the average.
+func (r *FloatKaufmansAdaptiveMovingAverageReducer) AggregateInteger(p *IntegerPoint) {
+	r.aggregate(p.Time, float64(p.Value))
+}
+
+// AggregateUnsigned aggregates a point into the reducer and updates the average.
+func (r *FloatKaufmansAdaptiveMovingAverageReducer) AggregateUnsigned(p *UnsignedPoint) {
+	r.aggregate(p.Time, float64(p.Value))
+}
+
+// Emit emits the moving average at the current point. Emit should be called
+// after every call to an aggregate method and it will produce one point once
+// the hold period has passed, otherwise it will produce zero points.
+func (r *FloatKaufmansAdaptiveMovingAverageReducer) Emit() []FloatPoint {
+	if r.curr.Nil {
+		return nil
+	}
+	r.curr.Nil = true
+	return []FloatPoint{{Time: r.curr.Time, Value: r.curr.Value}}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/tracing"
 	"github.com/influxdata/influxql"
 	"go.uber.org/zap"
 )
@@ -68,6 +69,14 @@ func ErrMaxConcurrentQueriesLimitExceeded(n, limit int) error {
 	return fmt.Errorf("max-concurrent-queries limit exceeded(%d, %d)", n, limit)
 }
 
+// ErrQueryKilled is an error returned by a query killed by a user, for a reason.
+func ErrQueryKilled(user, reason string) error {
+	if user == "" {
+		return fmt.Errorf("query killed: %s", reason)
+	}
+	return fmt.Errorf("query killed by %s: %s", user, reason)
+}
+
 // Authorizer determines if certain operations are authorized.
 type Authorizer interface {
 	// AuthorizeDatabase indicates whether the given Privilege is authorized on the database with the given name.
@@ -135,8 +144,14 @@ type ExecutionOptions struct {
 	// Quiet suppresses non-essential output from the query executor.
 	Quiet bool
 
+	// Class of the query for the scheduler.
+	Class QueryClass
+
 	// AbortCh is a channel that signals when results are no longer desired by the caller.
 	AbortCh <-chan struct{}
+
+	// Span is the parent of the spans of the statements, if the query is traced.
+	Span *tracing.Span
 }
 
 // ExecutionContext contains state that the query is currently executing with.
@@ -298,7 +313,7 @@ func (e *QueryExecutor) executeQuery(query *influxql.Query, opt ExecutionOptions
 		atomic.AddInt64(&e.stats.QueryExecutionDuration, time.Since(start).Nanoseconds())
 	}(time.Now())
 
-	qid, task, err := e.TaskManager.AttachQuery(query, opt.Database, closing)
+	qid, task, err := e.TaskManager.AttachQuery(query, opt, closing)
 	if err != nil {
 		select {
 		case results <- &Result{Err: err}:
@@ -308,6 +323,24 @@ func (e *QueryExecutor) executeQuery(query *influxql.Query, opt ExecutionOptions
 	}
 	defer e.TaskManager.DetachQuery(qid)
 
+	// Wait for the scheduler to admit queries reading data.
+	if e.TaskManager.Scheduler != nil && hasSelectStatement(query) {
+		task.setStatus(QueuedTask)
+		release, err := e.TaskManager.Scheduler.Acquire(opt.Class, task.closing)
+		if err != nil {
+			if qerr := task.Error(); qerr != nil {
+				err = qerr
+			}
+			select {
+			case results <- &Result{Err: err}:
+			case <-opt.AbortCh:
+			}
+			return
+		}
+		defer release()
+		task.setStatus(RunningTask)
+	}
+
 	// Setup the execution context that will be used when executing statements.
 	ctx := ExecutionContext{
 		QueryID:          qid,
@@ -434,6 +467,16 @@ LOOP:
 	}
 }
 
+// hasSelectStatement returns true if the query has a SELECT statement.
+func hasSelectStatement(q *influxql.Query) bool {
+	for _, stmt := range q.Statements {
+		if _, ok := stmt.(*influxql.SelectStatement); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // Determines if the QueryExecutor will recover any panics or let them crash
 // the server.
 var willCrash bool
@@ -473,7 +516,9 @@ type QueryMonitorFunc func(<-chan struct{}) error
 type QueryTask struct {
 	query     string
 	database  string
+	user      string
 	status    TaskStatus
+	progress  string
 	startTime time.Time
 	closing   chan struct{}
 	monitorCh chan error
@@ -497,6 +542,30 @@ func (q *QueryTask) Error() error {
 	return q.err
 }
 
+// SetProgress sets a description of the progress of the query, which is
+// reported by SHOW QUERIES.
+func (q *QueryTask) SetProgress(progress string) {
+	q.mu.Lock()
+	q.progress = progress
+	q.mu.Unlock()
+}
+
+// Progress returns the description of the progress of the query.
+func (q *QueryTask) Progress() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.progress
+}
+
+// setStatus sets the status of the query, unless it was killed.
+func (q *QueryTask) setStatus(status TaskStatus) {
+	q.mu.Lock()
+	if q.status != KilledTask {
+		q.status = status
+	}
+	q.mu.Unlock()
+}
+
 func (q *QueryTask) setError(err error) {
 	q.mu.Lock()
 	q.err = err
@@ -523,12 +592,17 @@ func (q *QueryTask) close() {
 	q.mu.Unlock()
 }
 
-func (q *QueryTask) kill() error {
+// kill closes the query task closing channel, setting the error of the query
+// to err if it isn't nil.
+func (q *QueryTask) kill(err error) error {
 	q.mu.Lock()
 	if q.status == KilledTask {
 		q.mu.Unlock()
 		return ErrAlreadyKilled
 	}
+	if err != nil {
+		q.err = err
+	}
 	q.status = KilledTask
 	close(q.closing)
 	q.mu.Unlock()
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/query"
 	"github.com/influxdata/influxql"
 )
@@ -84,6 +85,80 @@ func TestQueryExecutor_KillQuery(t *testing.T) {
 	}
 }
 
+// Ensure queries can be killed by user and database, and their kills are
+// recorded with who killed them and why.
+func TestQueryExecutor_KillQueries(t *testing.T) {
+	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qid := make(chan uint64)
+
+	e := NewQueryExecutor()
+	m := &Monitor{}
+	e.TaskManager.Monitor = m
+	e.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+			qid <- ctx.QueryID
+			select {
+			case <-ctx.InterruptCh:
+				return query.ErrQueryInterrupted
+			case <-time.After(100 * time.Millisecond):
+				t.Error("killing the query did not close the channel after 100 milliseconds")
+				return errUnexpected
+			}
+		},
+	}
+
+	alice := e.ExecuteQuery(q, query.ExecutionOptions{Database: "db0", Authorizer: &User{Authorizer: query.OpenAuthorizer, Name: "alice"}}, nil)
+	aliceID := <-qid
+	bob := e.ExecuteQuery(q, query.ExecutionOptions{Database: "db1", Authorizer: &User{Authorizer: query.OpenAuthorizer, Name: "bob"}}, nil)
+	bobID := <-qid
+
+	if _, err := e.TaskManager.KillQueries(query.KillRequest{}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	ids, err := e.TaskManager.KillQueries(query.KillRequest{User: "alice", KilledBy: "admin", Reason: "too slow"})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ids) != 1 || ids[0] != aliceID {
+		t.Fatalf("unexpected queries killed: %v", ids)
+	}
+	if result := <-alice; result.Err == nil || result.Err.Error() != "query killed by admin: too slow" {
+		t.Errorf("unexpected error: %s", result.Err)
+	}
+	discardOutput(alice)
+
+	if len(m.Points) != 1 {
+		t.Fatalf("unexpected number of points: %d", len(m.Points))
+	}
+	p := m.Points[0]
+	if string(p.Name()) != "query_kill" {
+		t.Errorf("unexpected measurement: %s", p.Name())
+	} else if tags := p.Tags(); tags.GetString("db") != "db0" || tags.GetString("user") != "alice" || tags.GetString("killedBy") != "admin" {
+		t.Errorf("unexpected tags: %s", tags)
+	}
+	if fields, err := p.Fields(); err != nil {
+		t.Fatal(err)
+	} else if fields["qid"] != int64(aliceID) || fields["reason"] != "too slow" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+
+	// Queries killed without a reason are interrupted.
+	ids, err = e.TaskManager.KillQueries(query.KillRequest{Database: "db1"})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ids) != 1 || ids[0] != bobID {
+		t.Fatalf("unexpected queries killed: %v", ids)
+	}
+	if result := <-bob; result.Err != query.ErrQueryInterrupted {
+		t.Errorf("unexpected error: %s", result.Err)
+	}
+	discardOutput(bob)
+}
+
 func TestQueryExecutor_KillQuery_Zombie(t *testing.T) {
 	q, err := influxql.ParseQuery(`SELECT count(value) FROM cpu`)
 	if err != nil {
@@ -533,3 +608,23 @@ func discardOutput(results <-chan *query.Result) {
 		// Read all results and discard.
 	}
 }
+
+// User is a named query.Authorizer.
+type User struct {
+	query.Authorizer
+	Name string
+}
+
+func (u *User) ID() string { return u.Name }
+
+// Monitor records the points written to it.
+type Monitor struct {
+	Points models.Points
+}
+
+func (m *Monitor) Enabled() bool { return true }
+
+func (m *Monitor) WritePoints(points models.Points) error {
+	m.Points = append(m.Points, points...)
+	return nil
+}
@@ -0,0 +1,167 @@
+package query
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// QueryClass is the class of a query, which determines its share of the
+// queries executed concurrently.
+type QueryClass int
+
+const (
+	// InteractiveQuery is the class of the queries of users and dashboards.
+	// It is the default class.
+	InteractiveQuery QueryClass = iota
+
+	// BatchQuery is the class of continuous queries and exports.
+	BatchQuery
+
+	// BackgroundQuery is the class of the queries whose latency doesn't
+	// matter, such as backfills.
+	BackgroundQuery
+
+	queryClassN = 3
+)
+
+func (c QueryClass) String() string {
+	switch c {
+	case InteractiveQuery:
+		return "interactive"
+	case BatchQuery:
+		return "batch"
+	case BackgroundQuery:
+		return "background"
+	}
+	panic(fmt.Sprintf("unknown query class: %d", int(c)))
+}
+
+// ParseQueryClass returns the query class of its name.
+func ParseQueryClass(s string) (QueryClass, error) {
+	switch strings.ToLower(s) {
+	case "interactive":
+		return InteractiveQuery, nil
+	case "batch":
+		return BatchQuery, nil
+	case "background":
+		return BackgroundQuery, nil
+	}
+	return 0, fmt.Errorf("unknown query class: %s", s)
+}
+
+// QueryClassLimits are the scheduling parameters of a query class.
+type QueryClassLimits struct {
+	// Concurrency is the maximum number of queries of the class executed
+	// concurrently. A value of zero only limits them to the concurrency of
+	// the scheduler.
+	Concurrency int
+
+	// Weight is the share of the queries admitted from the class while
+	// queries of several classes are waiting.
+	Weight int
+}
+
+// QueryScheduler limits the number of queries executed concurrently. Queries
+// beyond the limit wait for a running query to finish, and are admitted from
+// the classes with waiting queries in proportion to the weights of the
+// classes, so queries of a class cannot delay the queries of the other
+// classes indefinitely.
+type QueryScheduler struct {
+	concurrency int
+	classes     [queryClassN]QueryClassLimits
+
+	mu      sync.Mutex
+	running int
+	counts  [queryClassN]int
+	waiting [queryClassN]*list.List
+	credits [queryClassN]int
+}
+
+// NewQueryScheduler returns a scheduler executing at most concurrency
+// queries at once, with the limits of each class.
+func NewQueryScheduler(concurrency int, interactive, batch, background QueryClassLimits) *QueryScheduler {
+	s := &QueryScheduler{
+		concurrency: concurrency,
+		classes:     [queryClassN]QueryClassLimits{interactive, batch, background},
+	}
+	for i := range s.waiting {
+		if s.classes[i].Weight <= 0 {
+			s.classes[i].Weight = 1
+		}
+		s.waiting[i] = list.New()
+	}
+	return s
+}
+
+// Acquire waits until a query of the class may be executed. It returns a
+// function to call once the query has finished, or ErrQueryInterrupted if
+// interrupt is closed first.
+func (s *QueryScheduler) Acquire(class QueryClass, interrupt <-chan struct{}) (func(), error) {
+	ready := make(chan struct{})
+
+	s.mu.Lock()
+	elem := s.waiting[class].PushBack(ready)
+	s.dispatch()
+	s.mu.Unlock()
+
+	release := func() { s.release(class) }
+	select {
+	case <-ready:
+		return release, nil
+	case <-interrupt:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-ready:
+		// The query was admitted while it was interrupted.
+		s.running--
+		s.counts[class]--
+		s.dispatch()
+	default:
+		s.waiting[class].Remove(elem)
+	}
+	return nil, ErrQueryInterrupted
+}
+
+// release releases the slot of a query of the class.
+func (s *QueryScheduler) release(class QueryClass) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running--
+	s.counts[class]--
+	s.dispatch()
+}
+
+// dispatch admits waiting queries while slots are available. The class of
+// the next query is chosen by smooth weighted round robin among the classes
+// with waiting queries below their concurrency. The lock must be held.
+func (s *QueryScheduler) dispatch() {
+	for s.concurrency <= 0 || s.running < s.concurrency {
+		next, total := -1, 0
+		for i := range s.waiting {
+			if s.waiting[i].Len() == 0 {
+				continue
+			} else if limit := s.classes[i].Concurrency; limit > 0 && s.counts[i] >= limit {
+				continue
+			}
+			s.credits[i] += s.classes[i].Weight
+			total += s.classes[i].Weight
+			if next < 0 || s.credits[i] > s.credits[next] {
+				next = i
+			}
+		}
+		if next < 0 {
+			return
+		}
+		s.credits[next] -= total
+
+		ready := s.waiting[next].Remove(s.waiting[next].Front()).(chan struct{})
+		s.running++
+		s.counts[next]++
+		close(ready)
+	}
+}
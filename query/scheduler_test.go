@@ -0,0 +1,101 @@
+package query_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/query"
+)
+
+// Ensure waiting queries are admitted in proportion to the weights of their
+// classes.
+func TestQueryScheduler_Weights(t *testing.T) {
+	s := query.NewQueryScheduler(1,
+		query.QueryClassLimits{Weight: 2},
+		query.QueryClassLimits{Weight: 1},
+		query.QueryClassLimits{Weight: 1},
+	)
+
+	release, err := s.Acquire(query.BackgroundQuery, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var order []query.QueryClass
+	var wg sync.WaitGroup
+	for _, class := range []query.QueryClass{query.BatchQuery, query.BatchQuery, query.InteractiveQuery, query.InteractiveQuery} {
+		wg.Add(1)
+		go func(class query.QueryClass) {
+			defer wg.Done()
+			release, err := s.Acquire(class, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, class)
+			mu.Unlock()
+			release()
+		}(class)
+
+		// Wait for the query to be queued.
+		time.Sleep(10 * time.Millisecond)
+	}
+	release()
+	wg.Wait()
+
+	if exp := []query.QueryClass{query.InteractiveQuery, query.BatchQuery, query.InteractiveQuery, query.BatchQuery}; !reflect.DeepEqual(order, exp) {
+		t.Fatalf("unexpected order: %v", order)
+	}
+}
+
+// Ensure the concurrency of a class is limited, and queries waiting for it
+// can be interrupted.
+func TestQueryScheduler_ClassConcurrency(t *testing.T) {
+	s := query.NewQueryScheduler(0,
+		query.QueryClassLimits{},
+		query.QueryClassLimits{Concurrency: 1},
+		query.QueryClassLimits{},
+	)
+
+	release, err := s.Acquire(query.BatchQuery, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Queries of the other classes are not limited.
+	if release, err := s.Acquire(query.InteractiveQuery, nil); err != nil {
+		t.Fatal(err)
+	} else {
+		release()
+	}
+
+	interrupt := make(chan struct{})
+	time.AfterFunc(10*time.Millisecond, func() { close(interrupt) })
+	if _, err := s.Acquire(query.BatchQuery, interrupt); err != query.ErrQueryInterrupted {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	release()
+	if release, err := s.Acquire(query.BatchQuery, nil); err != nil {
+		t.Fatal(err)
+	} else {
+		release()
+	}
+}
+
+func TestParseQueryClass(t *testing.T) {
+	for _, class := range []query.QueryClass{query.InteractiveQuery, query.BatchQuery, query.BackgroundQuery} {
+		if got, err := query.ParseQueryClass(class.String()); err != nil {
+			t.Fatal(err)
+		} else if got != class {
+			t.Fatalf("got %s, expected %s", got, class)
+		}
+	}
+	if _, err := query.ParseQueryClass("urgent"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -31,6 +31,11 @@ type SelectOptions struct {
 
 	// Maximum number of buckets for a statement.
 	MaxBucketsN int
+
+	// Maximum duration before the start of a statement to search for the
+	// values seeding fill(previous) and fill(linear). If zero, the fill only
+	// uses the values within the time range of the statement.
+	FillLookback time.Duration
 }
 
 // ShardMapper retrieves and maps shards into an IteratorCreator that can later be
@@ -318,6 +323,30 @@ func buildAuxIterator(expr influxql.Expr, aitr AuxIterator, opt IteratorOptions)
 			}
 			return buildTransformIterator(lhs, rhs, expr.Op, opt)
 		}
+	case *influxql.Call:
+		if !isScalarFunction(expr) {
+			return nil, fmt.Errorf("invalid expression type: %T", expr)
+		}
+
+		// The values of a field read with any type are cast when they are
+		// sent to the iterator.
+		if ref, ok := expr.Args[0].(*influxql.VarRef); ok && isCastFunction(expr) && ref.Type == influxql.AnyField {
+			return aitr.CastIterator(ref.Val, castType(expr)), nil
+		}
+
+		args := make([]Iterator, len(expr.Args))
+		for i, arg := range expr.Args {
+			if _, ok := arg.(influxql.Literal); ok {
+				continue
+			}
+			input, err := buildAuxIterator(arg, aitr, opt)
+			if err != nil {
+				Iterators(Iterators(args).filterNonNil()).Close()
+				return nil, err
+			}
+			args[i] = input
+		}
+		return newScalarIterator(expr, args, opt)
 	case *influxql.ParenExpr:
 		return buildAuxIterator(expr.Expr, aitr, opt)
 	case *influxql.NilLiteral:
@@ -327,6 +356,47 @@ func buildAuxIterator(expr influxql.Expr, aitr AuxIterator, opt IteratorOptions)
 	}
 }
 
+// isScalarFunction returns true if the call is a math or string function or a
+// cast, which are evaluated on every point of their arguments.
+func isScalarFunction(call *influxql.Call) bool {
+	return isMathFunction(call) || isStringFunction(call) || isCastFunction(call)
+}
+
+// containsVarRef returns true if expr is a VarRef or contains one outside of
+// the arguments of aggregate calls.
+func containsVarRef(expr influxql.Expr) bool {
+	switch expr := expr.(type) {
+	case *influxql.VarRef:
+		return true
+	case *influxql.Call:
+		if !isScalarFunction(expr) {
+			return false
+		}
+		for _, arg := range expr.Args {
+			if containsVarRef(arg) {
+				return true
+			}
+		}
+	case *influxql.BinaryExpr:
+		return containsVarRef(expr.LHS) || containsVarRef(expr.RHS)
+	case *influxql.ParenExpr:
+		return containsVarRef(expr.Expr)
+	}
+	return false
+}
+
+// newScalarIterator returns an iterator evaluating a math or string function
+// call or a cast on the iterators of its arguments. The iterators of literal
+// arguments are nil.
+func newScalarIterator(call *influxql.Call, args []Iterator, opt IteratorOptions) (Iterator, error) {
+	if isMathFunction(call) {
+		return newMathIterator(args[0], call)
+	} else if isCastFunction(call) {
+		return newCastIterator(args[0], castType(call))
+	}
+	return newStringFunctionIterator(call, args, opt)
+}
+
 // buildFieldIterators creates an iterator for each field expression.
 func buildFieldIterators(ctx context.Context, fields influxql.Fields, ic IteratorCreator, sources influxql.Sources, opt IteratorOptions, selector, writeMode bool) ([]Iterator, error) {
 	// Create iterators from fields against the iterator creator.
@@ -341,7 +411,7 @@ func buildFieldIterators(ctx context.Context, fields influxql.Fields, ic Iterato
 			// Build iterators for calls first and save the iterator.
 			// We do this so we can keep the ordering provided by the user, but
 			// still build the Call's iterator first.
-			if influxql.ContainsVarRef(f.Expr) {
+			if containsVarRef(f.Expr) {
 				hasAuxFields = true
 				continue
 			}
@@ -490,6 +560,49 @@ func (b *exprIteratorBuilder) buildVarRefIterator(ctx context.Context, expr *inf
 }
 
 func (b *exprIteratorBuilder) buildCallIterator(ctx context.Context, expr *influxql.Call) (Iterator, error) {
+	// Scalar functions are evaluated on every point of their arguments.
+	if isScalarFunction(expr) {
+		args := make([]Iterator, len(expr.Args))
+		for i, arg := range expr.Args {
+			if _, ok := arg.(influxql.Literal); ok {
+				continue
+			}
+			input, err := buildExprIterator(ctx, arg, b.ic, b.sources, b.opt, b.selector, false)
+			if err != nil {
+				Iterators(Iterators(args).filterNonNil()).Close()
+				return nil, err
+			}
+			args[i] = input
+		}
+		return newScalarIterator(expr, args, b.opt)
+	}
+
+	// Conditional aggregates read only the points matching their condition.
+	if inner, cond, ok := conditionalCall(expr); ok {
+		return b.buildConditionalCallIterator(ctx, inner, cond)
+	}
+
+	// A call on a field qualified with a measurement reads only from that
+	// measurement and is joined with the other measurements by its name.
+	if len(expr.Args) > 0 {
+		fm, _ := b.ic.(influxql.FieldMapper)
+		if m, ref := joinSource(expr.Args[0], b.sources, fm); m != nil {
+			call := &influxql.Call{
+				Name: expr.Name,
+				Args: append([]influxql.Expr{ref}, expr.Args[1:]...),
+			}
+			builder := *b
+			builder.sources = influxql.Sources{m}
+			builder.opt.Expr = call
+
+			itr, err := builder.buildCallIterator(ctx, call)
+			if err != nil {
+				return nil, err
+			}
+			return newJoinIterator(itr, joinName(b.sources))
+		}
+	}
+
 	// TODO(jsternberg): Refactor this. This section needs to die in a fire.
 	opt := b.opt
 	// Eliminate limits and offsets if they were previously set. These are handled by the caller.
@@ -534,13 +647,7 @@ func (b *exprIteratorBuilder) buildCallIterator(ctx context.Context, expr *influ
 
 		return newHoltWintersIterator(input, opt, int(h.Val), int(m.Val), includeFitData, interval)
 	case "derivative", "non_negative_derivative", "difference", "non_negative_difference", "moving_average", "elapsed":
-		if !opt.Interval.IsZero() {
-			if opt.Ascending {
-				opt.StartTime -= int64(opt.Interval.Duration)
-			} else {
-				opt.EndTime += int64(opt.Interval.Duration)
-			}
-		}
+		opt.extendWindows(1)
 		opt.Ordered = true
 
 		input, err := buildExprIterator(ctx, expr.Args[0], b.ic, b.sources, opt, b.selector, false)
@@ -561,16 +668,73 @@ func (b *exprIteratorBuilder) buildCallIterator(ctx context.Context, expr *influ
 			return newDifferenceIterator(input, opt, isNonNegative)
 		case "moving_average":
 			n := expr.Args[1].(*influxql.IntegerLiteral)
-			if n.Val > 1 && !opt.Interval.IsZero() {
-				if opt.Ascending {
-					opt.StartTime -= int64(opt.Interval.Duration) * (n.Val - 1)
-				} else {
-					opt.EndTime += int64(opt.Interval.Duration) * (n.Val - 1)
-				}
-			}
+			opt.extendWindows(n.Val - 1)
 			return newMovingAverageIterator(input, int(n.Val), opt)
 		}
 		panic(fmt.Sprintf("invalid series aggregate function: %s", expr.Name))
+	case "median_absolute_deviation", "zscore":
+		// Read the intervals before the start time completing the window of
+		// the first interval.
+		n := expr.Args[1].(*influxql.IntegerLiteral)
+		opt.extendWindows(n.Val - 1)
+		opt.Ordered = true
+
+		input, err := buildExprIterator(ctx, expr.Args[0], b.ic, b.sources, opt, b.selector, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if expr.Name == "zscore" {
+			return newZScoreIterator(input, int(n.Val), opt)
+		}
+		return newMedianAbsoluteDeviationIterator(input, int(n.Val), opt)
+	case "lag", "lead":
+		// Read the points before the start time for lag() and after the end
+		// time for lead(), so the points at the edges of the time range have
+		// an offset point. An offset of a number of points can only be read
+		// for intervals.
+		n, d := lagOffset(expr)
+		ext := int64(d)
+		if n > 0 {
+			ext = int64(opt.Interval.Duration) * int64(n)
+		}
+		lookback := expr.Name == "lag"
+		switch {
+		case lookback && opt.StartTime >= influxql.MinTime+ext:
+			opt.StartTime -= ext
+		case lookback:
+			opt.StartTime = influxql.MinTime
+		case opt.EndTime <= influxql.MaxTime-ext:
+			opt.EndTime += ext
+		default:
+			opt.EndTime = influxql.MaxTime
+		}
+		opt.Ordered = true
+
+		input, err := buildExprIterator(ctx, expr.Args[0], b.ic, b.sources, opt, b.selector, false)
+		if err != nil {
+			return nil, err
+		}
+
+		// The points before a point in time are read after it when the
+		// points are in descending order.
+		return newLagIterator(input, n, d, lookback == opt.Ascending, opt)
+	case "exponential_moving_average", "double_exponential_moving_average", "triple_exponential_moving_average", "kaufmans_adaptive_moving_average":
+		// Read the intervals held back before the start time, so the first
+		// interval has an average.
+		period, hold, warmup := movingAverageArgs(expr)
+		opt.extendWindows(int64(hold))
+		opt.Ordered = true
+
+		input, err := buildExprIterator(ctx, expr.Args[0], b.ic, b.sources, opt, b.selector, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if expr.Name == "kaufmans_adaptive_moving_average" {
+			return newKaufmansAdaptiveMovingAverageIterator(input, period, hold, opt)
+		}
+		return newExponentialMovingAverageIterator(input, exponentialMovingAverageOrder(expr.Name), period, hold, warmup, opt)
 	case "cumulative_sum":
 		opt.Ordered = true
 		input, err := buildExprIterator(ctx, expr.Args[0], b.ic, b.sources, opt, b.selector, false)
@@ -750,6 +914,29 @@ func (b *exprIteratorBuilder) buildCallIterator(ctx context.Context, expr *influ
 				percentile = float64(arg.Val)
 			}
 			return newPercentileIterator(input, opt, percentile)
+		case "approx_percentile":
+			// The shards emit the digests of their points, which are merged
+			// before estimating the percentile.
+			input, err := b.callIterator(ctx, expr, opt)
+			if err != nil {
+				return nil, err
+			}
+			var percentile float64
+			switch arg := expr.Args[1].(type) {
+			case *influxql.NumberLiteral:
+				percentile = arg.Val
+			case *influxql.IntegerLiteral:
+				percentile = float64(arg.Val)
+			}
+			return newApproxPercentileIterator(input, opt, percentile)
+		case "histogram":
+			// The shards emit the bucket counts of their points, which are
+			// added before emitting a series for each bucket.
+			input, err := b.callIterator(ctx, expr, opt)
+			if err != nil {
+				return nil, err
+			}
+			return newHistogramBucketIterator(input, opt)
 		default:
 			return nil, fmt.Errorf("unsupported call: %s", expr.Name)
 		}
@@ -762,6 +949,12 @@ func (b *exprIteratorBuilder) buildCallIterator(ctx context.Context, expr *influ
 	if !b.selector || !opt.Interval.IsZero() {
 		itr = NewIntervalIterator(itr, opt)
 		if !opt.Interval.IsZero() && opt.Fill != influxql.NoFill {
+			if opt.seedsFill() {
+				itr, err = b.buildFillSeedIterator(ctx, expr, itr)
+				if err != nil {
+					return nil, err
+				}
+			}
 			itr = NewFillIterator(itr, expr, opt)
 		}
 	}
@@ -771,6 +964,27 @@ func (b *exprIteratorBuilder) buildCallIterator(ctx context.Context, expr *influ
 	return itr, nil
 }
 
+// buildFillSeedIterator merges input with the intervals of the call within the
+// fill lookback before the start time. The fill iterator does not emit these
+// intervals, but seeds the previous value of every series with them so that
+// the first intervals are filled even if the previous point is in an earlier
+// shard.
+func (b *exprIteratorBuilder) buildFillSeedIterator(ctx context.Context, expr *influxql.Call, input Iterator) (Iterator, error) {
+	start, _ := b.opt.Window(b.opt.StartTime)
+
+	builder := *b
+	builder.opt.StartTime = start - int64(b.opt.FillLookback)
+	builder.opt.EndTime = start - 1
+	builder.opt.Fill = influxql.NoFill
+
+	seed, err := builder.buildCallIterator(ctx, expr)
+	if err != nil {
+		input.Close()
+		return nil, err
+	}
+	return NewSortedMergeIterator([]Iterator{seed, input}, b.opt), nil
+}
+
 func (b *exprIteratorBuilder) buildBinaryExprIterator(ctx context.Context, expr *influxql.BinaryExpr) (Iterator, error) {
 	if rhs, ok := expr.RHS.(influxql.Literal); ok {
 		// The right hand side is a literal. It is more common to have the RHS be a literal,
@@ -817,6 +1031,20 @@ func (b *exprIteratorBuilder) callIterator(ctx context.Context, expr *influxql.C
 				}
 				inputs = append(inputs, input)
 			case *influxql.SubQuery:
+				// Evaluate the call in the shards when the subquery reads
+				// the field directly from measurements.
+				subquery := subqueryBuilder{
+					ic:   b.ic,
+					stmt: source.Statement,
+				}
+				itrs, err := subquery.buildCallIterator(ctx, expr, opt)
+				if err != nil {
+					return err
+				} else if itrs != nil {
+					inputs = append(inputs, itrs...)
+					continue
+				}
+
 				// Identify the name of the field we are using.
 				arg0 := expr.Args[0].(*influxql.VarRef)
 
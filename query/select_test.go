@@ -3,8 +3,10 @@ package query_test
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -53,6 +55,32 @@ func TestSelect(t *testing.T) {
 				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 0 * Second, Value: 10, Aggregated: 1}},
 			},
 		},
+		{
+			name: "Sqrt_Min",
+			q:    `SELECT sqrt(min(value)) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY time(10s), host fill(none)`,
+			typ:  influxql.Float,
+			expr: `min(value::float)`,
+			itrs: []query.Iterator{
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 0 * Second, Value: 20},
+					{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 11 * Second, Value: 3},
+					{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 31 * Second, Value: 100},
+				}},
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("region=east,host=A"), Time: 9 * Second, Value: 19},
+					{Name: "cpu", Tags: ParseTags("region=east,host=A"), Time: 10 * Second, Value: 2},
+				}},
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 5 * Second, Value: 10},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Value: math.Sqrt(19), Aggregated: 2}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 10 * Second, Value: math.Sqrt(2), Aggregated: 2}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 30 * Second, Value: 10, Aggregated: 1}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 0 * Second, Value: math.Sqrt(10), Aggregated: 1}},
+			},
+		},
 		{
 			name: "Distinct_Float",
 			q:    `SELECT distinct(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY time(10s), host fill(none)`,
@@ -1267,6 +1295,25 @@ func TestSelect(t *testing.T) {
 				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 50 * Second, Value: 1}},
 			},
 		},
+		{
+			name: "Fill_Number_Calendar",
+			q:    `SELECT mean(value) FROM cpu WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-05-01T00:00:00Z' GROUP BY host, time('1mo') fill(1)`,
+			typ:  influxql.Float,
+			expr: `mean(value::float)`,
+			itrs: []query.Iterator{
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("host=A"), Time: mustParseTime("2000-01-15T00:00:00Z").UnixNano(), Value: 2},
+					{Name: "cpu", Tags: ParseTags("host=A"), Time: mustParseTime("2000-01-31T12:00:00Z").UnixNano(), Value: 4},
+					{Name: "cpu", Tags: ParseTags("host=A"), Time: mustParseTime("2000-03-01T00:00:00Z").UnixNano(), Value: 6},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: mustParseTime("2000-01-01T00:00:00Z").UnixNano(), Value: 3, Aggregated: 2}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: mustParseTime("2000-02-01T00:00:00Z").UnixNano(), Value: 1}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: mustParseTime("2000-03-01T00:00:00Z").UnixNano(), Value: 6, Aggregated: 1}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: mustParseTime("2000-04-01T00:00:00Z").UnixNano(), Value: 1}},
+			},
+		},
 		{
 			name: "Fill_Previous_Float",
 			q:    `SELECT mean(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:01:00Z' GROUP BY host, time(10s) fill(previous)`,
@@ -1711,6 +1758,76 @@ func TestSelect(t *testing.T) {
 				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 50 * Second, Value: 9}},
 			},
 		},
+		{
+			name: "ApproxPercentile_Float",
+			q:    `SELECT approx_percentile(value, 90) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY time(10s), host fill(none)`,
+			typ:  influxql.Float,
+			itrs: []query.Iterator{
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 0 * Second, Value: 20},
+					{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 11 * Second, Value: 3},
+					{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 31 * Second, Value: 100},
+				}},
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 5 * Second, Value: 10},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 50 * Second, Value: 10},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 51 * Second, Value: 9},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 52 * Second, Value: 8},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 53 * Second, Value: 7},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 54 * Second, Value: 6},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 55 * Second, Value: 5},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 56 * Second, Value: 4},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 57 * Second, Value: 3},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 58 * Second, Value: 2},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 59 * Second, Value: 1},
+				}},
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("region=east,host=A"), Time: 9 * Second, Value: 19},
+					{Name: "cpu", Tags: ParseTags("region=east,host=A"), Time: 10 * Second, Value: 2},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 20}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 10 * Second, Value: 3}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 30 * Second, Value: 100}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 0 * Second, Value: 10}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 50 * Second, Value: 9.5}},
+			},
+		},
+		{
+			name: "Histogram_Float",
+			q:    `SELECT histogram(value, 0, 20, 2) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY time(10s), host fill(none)`,
+			typ:  influxql.Float,
+			itrs: []query.Iterator{
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 0 * Second, Value: 20},
+					{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 11 * Second, Value: 3},
+					{Name: "cpu", Tags: ParseTags("region=west,host=A"), Time: 31 * Second, Value: 100},
+				}},
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 5 * Second, Value: 10},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 50 * Second, Value: 10},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 51 * Second, Value: 9},
+					{Name: "cpu", Tags: ParseTags("region=west,host=B"), Time: 52 * Second, Value: 12},
+				}},
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("region=east,host=A"), Time: 9 * Second, Value: 19},
+					{Name: "cpu", Tags: ParseTags("region=east,host=A"), Time: 10 * Second, Value: 2},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A,le=10"), Time: 0 * Second, Value: 0}},
+				{&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A,le=10"), Time: 10 * Second, Value: 2}},
+				{&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A,le=10"), Time: 30 * Second, Value: 0}},
+				{&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A,le=20"), Time: 0 * Second, Value: 2}},
+				{&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A,le=20"), Time: 10 * Second, Value: 0}},
+				{&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A,le=20"), Time: 30 * Second, Value: 0}},
+				{&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=B,le=10"), Time: 0 * Second, Value: 1}},
+				{&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=B,le=10"), Time: 50 * Second, Value: 2}},
+				{&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=B,le=20"), Time: 0 * Second, Value: 0}},
+				{&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=B,le=20"), Time: 50 * Second, Value: 1}},
+			},
+		},
 		{
 			name: "Percentile_Integer",
 			q:    `SELECT percentile(value, 90) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-02T00:00:00Z' GROUP BY time(10s), host fill(none)`,
@@ -2615,6 +2732,151 @@ func TestSelect(t *testing.T) {
 				{&query.FloatPoint{Name: "cpu", Time: 12 * Second, Value: 11, Aggregated: 2}},
 			},
 		},
+		{
+			name: "MedianAbsoluteDeviation_Float",
+			q:    `SELECT median_absolute_deviation(value, 3) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`,
+			typ:  influxql.Float,
+			itrs: []query.Iterator{
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Time: 0 * Second, Value: 20},
+					{Name: "cpu", Time: 4 * Second, Value: 10},
+					{Name: "cpu", Time: 8 * Second, Value: 19},
+					{Name: "cpu", Time: 12 * Second, Value: 3},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 8 * Second, Value: 1, Aggregated: 3}},
+				{&query.FloatPoint{Name: "cpu", Time: 12 * Second, Value: 7, Aggregated: 3}},
+			},
+		},
+		{
+			name: "ZScore_Integer",
+			q:    `SELECT zscore(value, 3) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:20Z'`,
+			typ:  influxql.Integer,
+			itrs: []query.Iterator{
+				&IntegerIterator{Points: []query.IntegerPoint{
+					{Name: "cpu", Time: 0 * Second, Value: 1},
+					{Name: "cpu", Time: 4 * Second, Value: 2},
+					{Name: "cpu", Time: 8 * Second, Value: 3},
+					{Name: "cpu", Time: 12 * Second, Value: 4},
+					{Name: "cpu", Time: 16 * Second, Value: 2},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 8 * Second, Value: 1, Aggregated: 3}},
+				{&query.FloatPoint{Name: "cpu", Time: 12 * Second, Value: 1, Aggregated: 3}},
+				{&query.FloatPoint{Name: "cpu", Time: 16 * Second, Value: -1, Aggregated: 3}},
+			},
+		},
+		{
+			name: "Lag_Float",
+			q:    `SELECT lag(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`,
+			typ:  influxql.Float,
+			itrs: []query.Iterator{
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Time: 0 * Second, Value: 1},
+					{Name: "cpu", Time: 4 * Second, Value: 2},
+					{Name: "cpu", Time: 8 * Second, Value: 4},
+					{Name: "cpu", Time: 12 * Second, Value: 7},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 4 * Second, Value: 1}},
+				{&query.FloatPoint{Name: "cpu", Time: 8 * Second, Value: 2}},
+				{&query.FloatPoint{Name: "cpu", Time: 12 * Second, Value: 4}},
+			},
+		},
+		{
+			name: "Lag_Duration_Integer",
+			q:    `SELECT lag(value, 6s) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`,
+			typ:  influxql.Integer,
+			itrs: []query.Iterator{
+				&IntegerIterator{Points: []query.IntegerPoint{
+					{Name: "cpu", Time: 0 * Second, Value: 1},
+					{Name: "cpu", Time: 4 * Second, Value: 2},
+					{Name: "cpu", Time: 8 * Second, Value: 4},
+					{Name: "cpu", Time: 12 * Second, Value: 7},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.IntegerPoint{Name: "cpu", Time: 8 * Second, Value: 1}},
+				{&query.IntegerPoint{Name: "cpu", Time: 12 * Second, Value: 2}},
+			},
+		},
+		{
+			name: "Lead_Float",
+			q:    `SELECT lead(value, 2) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`,
+			typ:  influxql.Float,
+			itrs: []query.Iterator{
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Time: 0 * Second, Value: 1},
+					{Name: "cpu", Time: 4 * Second, Value: 2},
+					{Name: "cpu", Time: 8 * Second, Value: 4},
+					{Name: "cpu", Time: 12 * Second, Value: 7},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 4}},
+				{&query.FloatPoint{Name: "cpu", Time: 4 * Second, Value: 7}},
+			},
+		},
+		{
+			name: "Lead_Duration_String",
+			q:    `SELECT lead(value, 8s) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`,
+			typ:  influxql.String,
+			itrs: []query.Iterator{
+				&StringIterator{Points: []query.StringPoint{
+					{Name: "cpu", Time: 0 * Second, Value: "a"},
+					{Name: "cpu", Time: 4 * Second, Value: "b"},
+					{Name: "cpu", Time: 8 * Second, Value: "c"},
+					{Name: "cpu", Time: 12 * Second, Value: "d"},
+					{Name: "cpu", Time: 20 * Second, Value: "e"},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.StringPoint{Name: "cpu", Time: 0 * Second, Value: "c"}},
+				{&query.StringPoint{Name: "cpu", Time: 4 * Second, Value: "d"}},
+				{&query.StringPoint{Name: "cpu", Time: 8 * Second, Value: "e"}},
+				{&query.StringPoint{Name: "cpu", Time: 12 * Second, Value: "e"}},
+			},
+		},
+		{
+			name: "ExponentialMovingAverage_Float",
+			q:    `SELECT exponential_moving_average(value, 3, 1, 'none') FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`,
+			typ:  influxql.Float,
+			itrs: []query.Iterator{
+				&FloatIterator{Points: []query.FloatPoint{
+					{Name: "cpu", Time: 0 * Second, Value: 20},
+					{Name: "cpu", Time: 4 * Second, Value: 10},
+					{Name: "cpu", Time: 8 * Second, Value: 19},
+					{Name: "cpu", Time: 12 * Second, Value: 3},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 4 * Second, Value: 15}},
+				{&query.FloatPoint{Name: "cpu", Time: 8 * Second, Value: 17}},
+				{&query.FloatPoint{Name: "cpu", Time: 12 * Second, Value: 10}},
+			},
+		},
+		{
+			name: "DoubleExponentialMovingAverage_Integer",
+			q:    `SELECT double_exponential_moving_average(value, 3, 0, 'none') FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`,
+			typ:  influxql.Integer,
+			itrs: []query.Iterator{
+				&IntegerIterator{Points: []query.IntegerPoint{
+					{Name: "cpu", Time: 0 * Second, Value: 20},
+					{Name: "cpu", Time: 4 * Second, Value: 10},
+					{Name: "cpu", Time: 8 * Second, Value: 19},
+					{Name: "cpu", Time: 12 * Second, Value: 3},
+				}},
+			},
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 20}},
+				{&query.FloatPoint{Name: "cpu", Time: 4 * Second, Value: 12.5}},
+				{&query.FloatPoint{Name: "cpu", Time: 8 * Second, Value: 16.75}},
+				{&query.FloatPoint{Name: "cpu", Time: 12 * Second, Value: 6.375}},
+			},
+		},
 		{
 			name: "CumulativeSum_Float",
 			q:    `SELECT cumulative_sum(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:16Z'`,
@@ -2879,6 +3141,61 @@ func TestSelect_Raw(t *testing.T) {
 	}
 }
 
+// Ensure casts read a field with its type in each shard, so the values of a
+// field whose type changed are all cast.
+func TestSelect_Cast(t *testing.T) {
+	shardMapper := ShardMapper{
+		MapShardsFn: func(sources influxql.Sources, _ influxql.TimeRange) query.ShardGroup {
+			return &ShardGroup{
+				Fields: map[string]influxql.DataType{
+					"value": influxql.Float,
+				},
+				CreateIteratorFn: func(ctx context.Context, m *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+					if !reflect.DeepEqual(opt.Aux, []influxql.VarRef{
+						{Val: "value", Type: influxql.AnyField},
+						{Val: "value", Type: influxql.AnyField},
+					}) {
+						t.Fatalf("unexpected auxiliary fields: %v", opt.Aux)
+					}
+					return &FloatIterator{Points: []query.FloatPoint{
+						{Name: "cpu", Time: 0 * Second, Aux: []interface{}{float64(1.9), float64(1.9)}},
+						{Name: "cpu", Time: 1 * Second, Aux: []interface{}{"42", "42"}},
+						{Name: "cpu", Time: 2 * Second, Aux: []interface{}{true, true}},
+						{Name: "cpu", Time: 3 * Second, Aux: []interface{}{"abc", "abc"}},
+					}}, nil
+				},
+			}
+		},
+	}
+
+	stmt := MustParseSelectStatement(`SELECT cast(value, 'integer'), cast(value, 'string') FROM cpu`)
+	itrs, _, err := query.Select(context.Background(), stmt, &shardMapper, query.SelectOptions{})
+	if err != nil {
+		t.Fatalf("parse error: %s", err)
+	} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if diff := cmp.Diff(a, [][]query.Point{
+		{
+			&query.IntegerPoint{Name: "cpu", Value: 1, Time: 0 * Second},
+			&query.StringPoint{Name: "cpu", Value: "1.9", Time: 0 * Second},
+		},
+		{
+			&query.IntegerPoint{Name: "cpu", Value: 42, Time: 1 * Second},
+			&query.StringPoint{Name: "cpu", Value: "42", Time: 1 * Second},
+		},
+		{
+			&query.IntegerPoint{Name: "cpu", Value: 1, Time: 2 * Second},
+			&query.StringPoint{Name: "cpu", Value: "true", Time: 2 * Second},
+		},
+		{
+			&query.IntegerPoint{Name: "cpu", Time: 3 * Second, Nil: true},
+			&query.StringPoint{Name: "cpu", Value: "abc", Time: 3 * Second},
+		},
+	}); diff != "" {
+		t.Errorf("unexpected points:\n%s", diff)
+	}
+}
+
 // Ensure a SELECT binary expr queries can be executed as floats.
 func TestSelect_BinaryExpr(t *testing.T) {
 	shardMapper := ShardMapper{
@@ -3774,6 +4091,801 @@ func TestSelect_BinaryExpr(t *testing.T) {
 	}
 }
 
+// Ensure a SELECT with math functions can be executed.
+func TestSelect_Math(t *testing.T) {
+	shardMapper := ShardMapper{
+		MapShardsFn: func(sources influxql.Sources, _ influxql.TimeRange) query.ShardGroup {
+			return &ShardGroup{
+				Fields: map[string]influxql.DataType{
+					"f": influxql.Float,
+					"i": influxql.Integer,
+					"u": influxql.Unsigned,
+				},
+				CreateIteratorFn: func(ctx context.Context, m *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+					if m.Name != "cpu" {
+						t.Fatalf("unexpected source: %s", m.Name)
+					}
+					makeAuxFields := func(value float64) []interface{} {
+						aux := make([]interface{}, len(opt.Aux))
+						for i := range aux {
+							switch opt.Aux[i].Type {
+							case influxql.Float:
+								aux[i] = value
+							case influxql.Integer:
+								aux[i] = int64(value)
+							case influxql.Unsigned:
+								aux[i] = uint64(math.Abs(value))
+							}
+						}
+						return aux
+					}
+					return &FloatIterator{Points: []query.FloatPoint{
+						{Name: "cpu", Time: 0 * Second, Aux: makeAuxFields(-2.5)},
+						{Name: "cpu", Time: 5 * Second, Aux: makeAuxFields(4)},
+						{Name: "cpu", Time: 9 * Second, Aux: makeAuxFields(9)},
+					}}, nil
+				},
+			}
+		},
+	}
+
+	for _, test := range []struct {
+		Name      string
+		Statement string
+		Points    [][]query.Point
+		Err       string
+	}{
+		{
+			Name:      "Float_Abs",
+			Statement: `SELECT abs(f) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 2.5}},
+				{&query.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 4}},
+				{&query.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 9}},
+			},
+		},
+		{
+			Name:      "Integer_Abs",
+			Statement: `SELECT abs(i) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.IntegerPoint{Name: "cpu", Time: 0 * Second, Value: 2}},
+				{&query.IntegerPoint{Name: "cpu", Time: 5 * Second, Value: 4}},
+				{&query.IntegerPoint{Name: "cpu", Time: 9 * Second, Value: 9}},
+			},
+		},
+		{
+			Name:      "Float_Round",
+			Statement: `SELECT round(f) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 0 * Second, Value: -3}},
+				{&query.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 4}},
+				{&query.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 9}},
+			},
+		},
+		{
+			Name:      "Unsigned_Sqrt",
+			Statement: `SELECT sqrt(u) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 0 * Second, Value: math.Sqrt(2)}},
+				{&query.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 2}},
+				{&query.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 3}},
+			},
+		},
+		{
+			Name:      "Integer_Pow",
+			Statement: `SELECT pow(i, 2) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 4}},
+				{&query.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 16}},
+				{&query.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 81}},
+			},
+		},
+		{
+			Name:      "Unsigned_Log2",
+			Statement: `SELECT log2(u) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 0 * Second, Value: 1}},
+				{&query.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 2}},
+				{&query.FloatPoint{Name: "cpu", Time: 9 * Second, Value: math.Log2(9)}},
+			},
+		},
+		{
+			Name:      "Float_Nested_BinaryExpr",
+			Statement: `SELECT sqrt(abs(f)) * 2 FROM cpu`,
+			Points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: 0 * Second, Value: math.Sqrt(2.5) * 2}},
+				{&query.FloatPoint{Name: "cpu", Time: 5 * Second, Value: 4}},
+				{&query.FloatPoint{Name: "cpu", Time: 9 * Second, Value: 6}},
+			},
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			stmt := MustParseSelectStatement(test.Statement)
+			itrs, _, err := query.Select(context.Background(), stmt, &shardMapper, query.SelectOptions{})
+			if err != nil {
+				if have, want := err.Error(), test.Err; want != "" {
+					if have != want {
+						t.Errorf("%s: unexpected parse error: %s != %s", test.Name, have, want)
+					}
+				} else {
+					t.Errorf("%s: unexpected parse error: %s", test.Name, have)
+				}
+			} else if test.Err != "" {
+				t.Fatalf("%s: expected error", test.Name)
+			} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+				t.Fatalf("%s: unexpected error: %s", test.Name, err)
+			} else if diff := cmp.Diff(a, test.Points); diff != "" {
+				t.Errorf("%s: unexpected points:\n%s", test.Name, diff)
+			}
+		})
+	}
+}
+
+// Ensure a SELECT with string functions can be executed.
+func TestSelect_StringFunctions(t *testing.T) {
+	shardMapper := ShardMapper{
+		MapShardsFn: func(sources influxql.Sources, _ influxql.TimeRange) query.ShardGroup {
+			return &ShardGroup{
+				Fields: map[string]influxql.DataType{
+					"s": influxql.String,
+					"f": influxql.Float,
+				},
+				Dimensions: []string{"host"},
+				CreateIteratorFn: func(ctx context.Context, m *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+					if m.Name != "cpu" {
+						t.Fatalf("unexpected source: %s", m.Name)
+					}
+					makeAuxFields := func(value string) []interface{} {
+						aux := make([]interface{}, len(opt.Aux))
+						for i := range aux {
+							switch opt.Aux[i].Type {
+							case influxql.String:
+								aux[i] = value
+							case influxql.Tag:
+								aux[i] = "serverA"
+							case influxql.Float:
+								aux[i] = float64(len(value))
+							}
+						}
+						return aux
+					}
+					return &FloatIterator{Points: []query.FloatPoint{
+						{Name: "cpu", Time: 0 * Second, Aux: makeAuxFields("Hello")},
+						{Name: "cpu", Time: 5 * Second, Aux: makeAuxFields("wörld")},
+						{Name: "cpu", Time: 9 * Second, Aux: makeAuxFields("")},
+					}}, nil
+				},
+			}
+		},
+	}
+
+	for _, test := range []struct {
+		Name      string
+		Statement string
+		Points    [][]query.Point
+		Err       string
+	}{
+		{
+			Name:      "Upper",
+			Statement: `SELECT upper(s) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.StringPoint{Name: "cpu", Time: 0 * Second, Value: "HELLO"}},
+				{&query.StringPoint{Name: "cpu", Time: 5 * Second, Value: "WÖRLD"}},
+				{&query.StringPoint{Name: "cpu", Time: 9 * Second, Value: ""}},
+			},
+		},
+		{
+			Name:      "Strlen",
+			Statement: `SELECT strlen(s) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.IntegerPoint{Name: "cpu", Time: 0 * Second, Value: 5}},
+				{&query.IntegerPoint{Name: "cpu", Time: 5 * Second, Value: 5}},
+				{&query.IntegerPoint{Name: "cpu", Time: 9 * Second, Value: 0}},
+			},
+		},
+		{
+			Name:      "Substr",
+			Statement: `SELECT substr(s, 1, 3) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.StringPoint{Name: "cpu", Time: 0 * Second, Value: "ell"}},
+				{&query.StringPoint{Name: "cpu", Time: 5 * Second, Value: "örl"}},
+				{&query.StringPoint{Name: "cpu", Time: 9 * Second, Value: ""}},
+			},
+		},
+		{
+			Name:      "Replace_Lower",
+			Statement: `SELECT replace(lower(s), 'l', 'L') FROM cpu`,
+			Points: [][]query.Point{
+				{&query.StringPoint{Name: "cpu", Time: 0 * Second, Value: "heLLo"}},
+				{&query.StringPoint{Name: "cpu", Time: 5 * Second, Value: "wörLd"}},
+				{&query.StringPoint{Name: "cpu", Time: 9 * Second, Value: ""}},
+			},
+		},
+		{
+			Name:      "Concat_Tag",
+			Statement: `SELECT concat('[', host, ']: ', s) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.StringPoint{Name: "cpu", Time: 0 * Second, Value: "[serverA]: Hello"}},
+				{&query.StringPoint{Name: "cpu", Time: 5 * Second, Value: "[serverA]: wörld"}},
+				{&query.StringPoint{Name: "cpu", Time: 9 * Second, Value: "[serverA]: "}},
+			},
+		},
+		{
+			Name:      "RegexMatch",
+			Statement: `SELECT regex_match(s, '^[A-Z]') FROM cpu`,
+			Points: [][]query.Point{
+				{&query.BooleanPoint{Name: "cpu", Time: 0 * Second, Value: true}},
+				{&query.BooleanPoint{Name: "cpu", Time: 5 * Second, Value: false}},
+				{&query.BooleanPoint{Name: "cpu", Time: 9 * Second, Value: false}},
+			},
+		},
+		{
+			Name:      "RegexExtract",
+			Statement: `SELECT regex_extract(s, '(l+)(o|d)$', 2) FROM cpu`,
+			Points: [][]query.Point{
+				{&query.StringPoint{Name: "cpu", Time: 0 * Second, Value: "o"}},
+				{&query.StringPoint{Name: "cpu", Time: 5 * Second, Value: "d"}},
+				{&query.StringPoint{Name: "cpu", Time: 9 * Second, Nil: true}},
+			},
+		},
+		{
+			Name:      "Upper_Float",
+			Statement: `SELECT upper(f) FROM cpu`,
+			Err:       `upper() requires a string or tag argument, found float`,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			stmt := MustParseSelectStatement(test.Statement)
+			itrs, _, err := query.Select(context.Background(), stmt, &shardMapper, query.SelectOptions{})
+			if err != nil {
+				if have, want := err.Error(), test.Err; want != "" {
+					if have != want {
+						t.Errorf("%s: unexpected parse error: %s != %s", test.Name, have, want)
+					}
+				} else {
+					t.Errorf("%s: unexpected parse error: %s", test.Name, have)
+				}
+			} else if test.Err != "" {
+				t.Fatalf("%s: expected error", test.Name)
+			} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+				t.Fatalf("%s: unexpected error: %s", test.Name, err)
+			} else if diff := cmp.Diff(a, test.Points); diff != "" {
+				t.Errorf("%s: unexpected points:\n%s", test.Name, diff)
+			}
+		})
+	}
+}
+
+// Ensure measurements qualifying the fields of a SELECT are joined on time
+// and tags.
+func TestSelect_Join(t *testing.T) {
+	shardMapper := ShardMapper{
+		MapShardsFn: func(sources influxql.Sources, _ influxql.TimeRange) query.ShardGroup {
+			return &ShardGroup{
+				Fields: map[string]influxql.DataType{
+					"value": influxql.Float,
+				},
+				Dimensions: []string{"host"},
+				CreateIteratorFn: func(ctx context.Context, m *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+					if !reflect.DeepEqual(opt.Expr, MustParseExpr(`sum(value)`)) {
+						t.Fatalf("unexpected expr: %s", spew.Sdump(opt.Expr))
+					}
+
+					var itrs []query.Iterator
+					switch m.Name {
+					case "errors":
+						itrs = []query.Iterator{
+							&FloatIterator{Points: []query.FloatPoint{
+								{Name: "errors", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 1},
+								{Name: "errors", Tags: ParseTags("host=A"), Time: 5 * Second, Value: 1},
+								{Name: "errors", Tags: ParseTags("host=A"), Time: 12 * Second, Value: 2},
+							}},
+							&FloatIterator{Points: []query.FloatPoint{
+								{Name: "errors", Tags: ParseTags("host=B"), Time: 3 * Second, Value: 4},
+							}},
+						}
+					case "requests":
+						itrs = []query.Iterator{
+							&FloatIterator{Points: []query.FloatPoint{
+								{Name: "requests", Tags: ParseTags("host=A"), Time: 1 * Second, Value: 10},
+								{Name: "requests", Tags: ParseTags("host=A"), Time: 11 * Second, Value: 20},
+							}},
+							&FloatIterator{Points: []query.FloatPoint{
+								{Name: "requests", Tags: ParseTags("host=B"), Time: 2 * Second, Value: 8},
+								{Name: "requests", Tags: ParseTags("host=B"), Time: 12 * Second, Value: 5},
+							}},
+						}
+					default:
+						t.Fatalf("unexpected source: %s", m.Name)
+					}
+
+					for i, itr := range itrs {
+						itr, err := query.NewCallIterator(itr, opt)
+						if err != nil {
+							return nil, err
+						}
+						itrs[i] = itr
+					}
+					return query.Iterators(itrs).Merge(opt)
+				},
+			}
+		},
+	}
+
+	for _, test := range []struct {
+		Name      string
+		Statement string
+		Points    [][]query.Point
+	}{
+		{
+			Name:      "NoFill",
+			Statement: `SELECT sum(errors.value) / sum(requests.value) FROM errors, requests WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:20Z' GROUP BY time(10s), host fill(none)`,
+			Points: [][]query.Point{
+				{&query.FloatPoint{Name: "errors,requests", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 0.2, Aggregated: 2}},
+				{&query.FloatPoint{Name: "errors,requests", Tags: ParseTags("host=A"), Time: 10 * Second, Value: 0.1, Aggregated: 1}},
+				{&query.FloatPoint{Name: "errors,requests", Tags: ParseTags("host=B"), Time: 0 * Second, Value: 0.5, Aggregated: 1}},
+			},
+		},
+		{
+			Name:      "NumberFill",
+			Statement: `SELECT sum(errors.value) / sum(requests.value) FROM errors, requests WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:20Z' GROUP BY time(10s), host fill(0)`,
+			Points: [][]query.Point{
+				{&query.FloatPoint{Name: "errors,requests", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 0.2, Aggregated: 2}},
+				{&query.FloatPoint{Name: "errors,requests", Tags: ParseTags("host=A"), Time: 10 * Second, Value: 0.1, Aggregated: 1}},
+				{&query.FloatPoint{Name: "errors,requests", Tags: ParseTags("host=B"), Time: 0 * Second, Value: 0.5, Aggregated: 1}},
+				{&query.FloatPoint{Name: "errors,requests", Tags: ParseTags("host=B"), Time: 10 * Second, Value: 0}},
+			},
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			stmt := MustParseSelectStatement(test.Statement)
+			itrs, _, err := query.Select(context.Background(), stmt, &shardMapper, query.SelectOptions{})
+			if err != nil {
+				t.Fatalf("%s: parse error: %s", test.Name, err)
+			} else if a, err := Iterators(itrs).ReadAll(); err != nil {
+				t.Fatalf("%s: unexpected error: %s", test.Name, err)
+			} else if diff := cmp.Diff(a, test.Points); diff != "" {
+				t.Errorf("%s: unexpected points:\n%s", test.Name, diff)
+			}
+		})
+	}
+}
+
+// Ensure statements joining measurements are validated against the fields of
+// the shards.
+func TestSelect_Join_Failures(t *testing.T) {
+	shardMapper := ShardMapper{
+		MapShardsFn: func(sources influxql.Sources, _ influxql.TimeRange) query.ShardGroup {
+			return &ShardGroup{
+				Fields: map[string]influxql.DataType{
+					"value": influxql.Float,
+				},
+				CreateIteratorFn: func(ctx context.Context, m *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+					t.Fatal("unexpected iterator")
+					return nil, nil
+				},
+			}
+		},
+	}
+
+	for _, tt := range []struct {
+		s   string
+		err string
+	}{
+		{s: `SELECT errors.value / requests.value FROM errors, requests`, err: `joining measurements requires aggregate functions`},
+		{s: `SELECT sum(errors.value) / sum(value) FROM errors, requests`, err: `field value must be qualified with a measurement when joining measurements`},
+		{s: `SELECT sum(errors.value) / sum(value) FROM errors, /req/`, err: `cannot join measurements matching a regex: /req/`},
+	} {
+		t.Run(tt.s, func(t *testing.T) {
+			stmt := MustParseSelectStatement(tt.s)
+			if _, _, err := query.Select(context.Background(), stmt, &shardMapper, query.SelectOptions{}); err == nil {
+				t.Fatal("expected error")
+			} else if have, want := err.Error(), tt.err; have != want {
+				t.Fatalf("unexpected error: have=%s want=%s", have, want)
+			}
+		})
+	}
+}
+
+// Ensure a field named like a field qualified with a measurement is not read
+// as a join.
+func TestSelect_Join_DottedField(t *testing.T) {
+	shardMapper := ShardMapper{
+		MapShardsFn: func(sources influxql.Sources, _ influxql.TimeRange) query.ShardGroup {
+			return &ShardGroup{
+				Fields: map[string]influxql.DataType{
+					"errors.value": influxql.Float,
+				},
+				CreateIteratorFn: func(ctx context.Context, m *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+					if !reflect.DeepEqual(opt.Expr, MustParseExpr(`sum("errors.value"::float)`)) {
+						t.Fatalf("unexpected expr: %s", spew.Sdump(opt.Expr))
+					}
+
+					var points []query.FloatPoint
+					switch m.Name {
+					case "errors":
+						points = []query.FloatPoint{
+							{Name: "errors", Time: 0 * Second, Value: 1},
+							{Name: "errors", Time: 5 * Second, Value: 2},
+						}
+					case "requests":
+						points = []query.FloatPoint{
+							{Name: "requests", Time: 1 * Second, Value: 10},
+						}
+					default:
+						t.Fatalf("unexpected source: %s", m.Name)
+					}
+					return query.NewCallIterator(&FloatIterator{Points: points}, opt)
+				},
+			}
+		},
+	}
+
+	stmt := MustParseSelectStatement(`SELECT sum("errors.value") FROM errors, requests`)
+	itrs, _, err := query.Select(context.Background(), stmt, &shardMapper, query.SelectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Iterators(itrs).ReadAll()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if diff := cmp.Diff(a, [][]query.Point{
+		{&query.FloatPoint{Name: "errors", Time: 0, Value: 3, Aggregated: 2}},
+		{&query.FloatPoint{Name: "requests", Time: 0, Value: 10, Aggregated: 1}},
+	}); diff != "" {
+		t.Fatalf("unexpected points:\n%s", diff)
+	}
+}
+
+// Ensure conditions and aggregates of a query are pushed down into subqueries
+// reading from measurements.
+func TestSelect_Subquery_PushDown(t *testing.T) {
+	var opts []query.IteratorOptions
+	shardMapper := ShardMapper{
+		MapShardsFn: func(sources influxql.Sources, _ influxql.TimeRange) query.ShardGroup {
+			return &ShardGroup{
+				Fields: map[string]influxql.DataType{
+					"value": influxql.Float,
+				},
+				Dimensions: []string{"host", "region"},
+				CreateIteratorFn: func(ctx context.Context, m *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+					if m.Name != "cpu" {
+						t.Fatalf("unexpected source: %s", m.Name)
+					} else if _, ok := opt.Expr.(*influxql.Call); !ok {
+						t.Fatalf("unexpected expr: %s", opt.Expr)
+					}
+					opts = append(opts, opt)
+
+					var itrs []query.Iterator
+					for _, points := range [][]query.FloatPoint{
+						{
+							{Name: "cpu", Tags: ParseTags("host=a,region=west"), Time: 0 * Second, Value: 1},
+							{Name: "cpu", Tags: ParseTags("host=a,region=west"), Time: 10 * Second, Value: 5},
+						},
+						{
+							{Name: "cpu", Tags: ParseTags("host=a,region=east"), Time: 0 * Second, Value: 7},
+						},
+						{
+							{Name: "cpu", Tags: ParseTags("host=b,region=west"), Time: 0 * Second, Value: 3},
+						},
+					} {
+						// Filter the series with the condition like the index.
+						tags := make(map[string]interface{})
+						for k, v := range points[0].Tags.KeyValues() {
+							tags[k] = v
+						}
+						if opt.Condition != nil && !query.EvalBool(opt.Condition, tags) {
+							continue
+						}
+
+						itr, err := query.NewCallIterator(&FloatIterator{Points: points}, opt)
+						if err != nil {
+							return nil, err
+						}
+						itrs = append(itrs, itr)
+					}
+					return query.Iterators(itrs).Merge(opt)
+				},
+			}
+		},
+	}
+
+	for _, test := range []struct {
+		Name      string
+		Statement string
+		Expr      string
+		Condition []string
+		Value     float64
+	}{
+		{
+			Name:      "Aggregate",
+			Statement: `SELECT max(value) FROM (SELECT value, host FROM cpu WHERE region = 'west') WHERE host = 'a'`,
+			Expr:      `max(value::float)`,
+			Condition: []string{"host", "region"},
+			Value:     5,
+		},
+		{
+			Name:      "Condition",
+			Statement: `SELECT value FROM (SELECT max(value) AS value FROM cpu GROUP BY host) WHERE host = 'a'`,
+			Expr:      `max(value::float)`,
+			Condition: []string{"host"},
+			Value:     7,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			opts = nil
+			stmt := MustParseSelectStatement(test.Statement)
+			itrs, _, err := query.Select(context.Background(), stmt, &shardMapper, query.SelectOptions{})
+			if err != nil {
+				t.Fatalf("%s: parse error: %s", test.Name, err)
+			}
+			a, err := Iterators(itrs).ReadAll()
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %s", test.Name, err)
+			} else if len(a) != 1 || len(a[0]) != 1 {
+				t.Fatalf("%s: unexpected points: %s", test.Name, spew.Sdump(a))
+			} else if p, ok := a[0][0].(*query.FloatPoint); !ok || p.Value != test.Value {
+				t.Fatalf("%s: unexpected point: %s", test.Name, spew.Sdump(a[0][0]))
+			}
+
+			if len(opts) != 1 {
+				t.Fatalf("%s: unexpected number of iterators: %d", test.Name, len(opts))
+			} else if !reflect.DeepEqual(opts[0].Expr, MustParseExpr(test.Expr)) {
+				t.Errorf("%s: unexpected expr: %s", test.Name, opts[0].Expr)
+			}
+			var names []string
+			for _, ref := range influxql.ExprNames(opts[0].Condition) {
+				names = append(names, ref.Val)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, test.Condition) {
+				t.Errorf("%s: unexpected condition: %s", test.Name, opts[0].Condition)
+			}
+		})
+	}
+}
+
+// Ensure fill(previous) and fill(linear) are seeded with the last interval
+// within the fill lookback before the time range.
+func TestSelect_FillLookback(t *testing.T) {
+	var minTime time.Time
+	shardMapper := ShardMapper{
+		MapShardsFn: func(sources influxql.Sources, tr influxql.TimeRange) query.ShardGroup {
+			minTime = tr.Min
+			return &ShardGroup{
+				Fields: map[string]influxql.DataType{
+					"value": influxql.Float,
+				},
+				Dimensions: []string{"host"},
+				CreateIteratorFn: func(ctx context.Context, m *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+					// Only return the points within the time range like the shards.
+					var points []query.FloatPoint
+					for _, p := range []query.FloatPoint{
+						{Name: "cpu", Tags: ParseTags("host=A"), Time: -25 * Second, Value: 1},
+						{Name: "cpu", Tags: ParseTags("host=A"), Time: 32 * Second, Value: 4},
+						{Name: "cpu", Tags: ParseTags("host=B"), Time: -15 * Second, Value: 9},
+					} {
+						if p.Time >= opt.StartTime && p.Time <= opt.EndTime {
+							points = append(points, p)
+						}
+					}
+					return query.NewCallIterator(&FloatIterator{Points: points}, opt)
+				},
+			}
+		},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		q      string
+		points [][]query.Point
+	}{
+		{
+			name: "Previous",
+			q:    `SELECT mean(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:01:00Z' GROUP BY host, time(10s) fill(previous)`,
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 1}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 10 * Second, Value: 1}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 20 * Second, Value: 1}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 30 * Second, Value: 4, Aggregated: 1}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 40 * Second, Value: 4}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 50 * Second, Value: 4}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 0 * Second, Value: 9}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 10 * Second, Value: 9}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 20 * Second, Value: 9}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 30 * Second, Value: 9}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 40 * Second, Value: 9}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 50 * Second, Value: 9}},
+			},
+		},
+		{
+			name: "Linear",
+			q:    `SELECT mean(value) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:01:00Z' GROUP BY host, time(10s) fill(linear)`,
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 2.5}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 10 * Second, Value: 3}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 20 * Second, Value: 3.5}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 30 * Second, Value: 4, Aggregated: 1}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 40 * Second, Nil: true}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 50 * Second, Nil: true}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 0 * Second, Nil: true}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 10 * Second, Nil: true}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 20 * Second, Nil: true}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 30 * Second, Nil: true}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 40 * Second, Nil: true}},
+				{&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 50 * Second, Nil: true}},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt := MustParseSelectStatement(tt.q)
+			itrs, _, err := query.Select(context.Background(), stmt, &shardMapper, query.SelectOptions{
+				FillLookback: 30 * time.Second,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			} else if want := time.Unix(0, -30*Second); !minTime.Equal(want) {
+				t.Errorf("unexpected shard time range: %s != %s", minTime, want)
+			}
+
+			if a, err := Iterators(itrs).ReadAll(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			} else if diff := cmp.Diff(a, tt.points); diff != "" {
+				t.Fatalf("unexpected points:\n%s", diff)
+			}
+		})
+	}
+}
+
+// Ensure the window before the time range is read for derivatives of
+// calendar month intervals, whose length differs from the average month.
+func TestSelect_Derivative_Calendar(t *testing.T) {
+	shardMapper := ShardMapper{
+		MapShardsFn: func(sources influxql.Sources, _ influxql.TimeRange) query.ShardGroup {
+			return &ShardGroup{
+				Fields: map[string]influxql.DataType{
+					"value": influxql.Float,
+				},
+				CreateIteratorFn: func(ctx context.Context, m *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+					// Only return the points within the time range like the shards.
+					var points []query.FloatPoint
+					for _, p := range []query.FloatPoint{
+						{Name: "cpu", Time: mustParseTime("2000-01-01T00:00:00Z").UnixNano(), Value: 1},
+						{Name: "cpu", Time: mustParseTime("2000-02-01T00:00:00Z").UnixNano(), Value: 3},
+						{Name: "cpu", Time: mustParseTime("2000-03-01T00:00:00Z").UnixNano(), Value: 6},
+					} {
+						if p.Time >= opt.StartTime && p.Time <= opt.EndTime {
+							points = append(points, p)
+						}
+					}
+					if !opt.Ascending {
+						sort.Slice(points, func(i, j int) bool { return points[i].Time > points[j].Time })
+					}
+					return query.NewCallIterator(&FloatIterator{Points: points}, opt)
+				},
+			}
+		},
+	}
+
+	// The derivative is normalized to the average month.
+	month := float64(2629746 * Second)
+	for _, tt := range []struct {
+		name   string
+		q      string
+		points [][]query.Point
+	}{
+		{
+			name: "Ascending",
+			q:    `SELECT derivative(mean(value)) FROM cpu WHERE time >= '2000-02-01T00:00:00Z' AND time < '2000-04-01T00:00:00Z' GROUP BY time('1mo')`,
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: mustParseTime("2000-02-01T00:00:00Z").UnixNano(), Value: 2 / (float64(31*24*3600*Second) / month)}},
+				{&query.FloatPoint{Name: "cpu", Time: mustParseTime("2000-03-01T00:00:00Z").UnixNano(), Value: 3 / (float64(29*24*3600*Second) / month)}},
+			},
+		},
+		{
+			name: "Descending",
+			q:    `SELECT derivative(mean(value)) FROM cpu WHERE time >= '2000-01-01T00:00:00Z' AND time < '2000-02-01T00:00:00Z' GROUP BY time('1mo') ORDER BY time DESC`,
+			points: [][]query.Point{
+				{&query.FloatPoint{Name: "cpu", Time: mustParseTime("2000-01-01T00:00:00Z").UnixNano(), Value: -2 / (float64(31*24*3600*Second) / month)}},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt := MustParseSelectStatement(tt.q)
+			itrs, _, err := query.Select(context.Background(), stmt, &shardMapper, query.SelectOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if a, err := Iterators(itrs).ReadAll(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			} else if diff := cmp.Diff(a, tt.points); diff != "" {
+				t.Fatalf("unexpected points:\n%s", diff)
+			}
+		})
+	}
+}
+
+// Ensure conditional aggregates only aggregate the points matching their
+// condition.
+func TestSelect_ConditionalAggregate(t *testing.T) {
+	shardMapper := ShardMapper{
+		MapShardsFn: func(sources influxql.Sources, _ influxql.TimeRange) query.ShardGroup {
+			return &ShardGroup{
+				Fields: map[string]influxql.DataType{
+					"value": influxql.Float,
+				},
+				Dimensions: []string{"host", "status"},
+				CreateIteratorFn: func(ctx context.Context, m *influxql.Measurement, opt query.IteratorOptions) (query.Iterator, error) {
+					// Filter the points with the condition like the shards.
+					var points []query.FloatPoint
+					for _, p := range []query.FloatPoint{
+						{Name: "cpu", Tags: ParseTags("host=A,status=ok"), Time: 0 * Second, Value: 3},
+						{Name: "cpu", Tags: ParseTags("host=A,status=error"), Time: 1 * Second, Value: 1},
+						{Name: "cpu", Tags: ParseTags("host=A,status=error"), Time: 2 * Second, Value: 8},
+						{Name: "cpu", Tags: ParseTags("host=B,status=ok"), Time: 0 * Second, Value: 9},
+					} {
+						values := map[string]interface{}{"value": p.Value}
+						for k, v := range p.Tags.KeyValues() {
+							values[k] = v
+						}
+						if opt.Condition == nil || query.EvalBool(opt.Condition, values) {
+							points = append(points, p)
+						}
+					}
+					return query.NewCallIterator(&FloatIterator{Points: points}, opt)
+				},
+			}
+		},
+	}
+
+	for _, tt := range []struct {
+		name   string
+		q      string
+		points [][]query.Point
+	}{
+		{
+			name: "CountIf",
+			q:    `SELECT count(value), count_if(status = 'error' OR value > 5) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:10Z' GROUP BY host`,
+			points: [][]query.Point{
+				{
+					&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 3, Aggregated: 3},
+					&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 2, Aggregated: 2},
+				},
+				{
+					&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 0 * Second, Value: 1, Aggregated: 1},
+					&query.IntegerPoint{Name: "cpu", Tags: ParseTags("host=B"), Time: 0 * Second, Value: 1, Aggregated: 1},
+				},
+			},
+		},
+		{
+			name: "SumIf",
+			q:    `SELECT sum_if(value, status = 'error'), mean_if(value, value > 2) FROM cpu WHERE time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:10Z' AND host = 'A' GROUP BY host`,
+			points: [][]query.Point{
+				{
+					&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 9, Aggregated: 2},
+					&query.FloatPoint{Name: "cpu", Tags: ParseTags("host=A"), Time: 0 * Second, Value: 5.5, Aggregated: 2},
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt := MustParseSelectStatement(tt.q)
+			itrs, _, err := query.Select(context.Background(), stmt, &shardMapper, query.SelectOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if a, err := Iterators(itrs).ReadAll(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			} else if diff := cmp.Diff(a, tt.points); diff != "" {
+				t.Fatalf("unexpected points:\n%s", diff)
+			}
+		})
+	}
+}
+
 // Ensure a SELECT binary expr queries can be executed as booleans.
 func TestSelect_BinaryExpr_Boolean(t *testing.T) {
 	shardMapper := ShardMapper{
@@ -193,6 +193,14 @@ func rewriteShowSeriesCardinalityStatement(stmt *influxql.ShowSeriesCardinalityS
 		return nil, errors.New("SHOW SERIES EXACT CARDINALITY doesn't support time in WHERE clause")
 	}
 
+	// Measurements are counted from the index, so the sources are a condition
+	// on the measurement name.
+	if IsSeriesCardinalityByMeasurement(stmt) {
+		stmt.Condition = rewriteSourcesCondition(stmt.Sources, stmt.Condition)
+		stmt.Sources = nil
+		return stmt, nil
+	}
+
 	// Use all measurements, if zero.
 	if len(stmt.Sources) == 0 {
 		stmt.Sources = influxql.Sources{
@@ -213,6 +221,22 @@ func rewriteShowSeriesCardinalityStatement(stmt *influxql.ShowSeriesCardinalityS
 	}, nil
 }
 
+// IsSeriesCardinalityByMeasurement returns true if the statement counts the
+// exact series cardinality of each measurement and of each of its tag keys,
+// which is requested by grouping by the measurement name:
+//
+//	SHOW SERIES EXACT CARDINALITY GROUP BY _name
+//
+// These statements are executed on the index instead of being rewritten into
+// a SELECT statement.
+func IsSeriesCardinalityByMeasurement(stmt *influxql.ShowSeriesCardinalityStatement) bool {
+	if !stmt.Exact || len(stmt.Dimensions) != 1 {
+		return false
+	}
+	ref, ok := stmt.Dimensions[0].Expr.(*influxql.VarRef)
+	return ok && ref.Val == "_name"
+}
+
 func rewriteShowTagValuesStatement(stmt *influxql.ShowTagValuesStatement) (influxql.Statement, error) {
 	var expr influxql.Expr
 	if list, ok := stmt.TagKeyExpr.(*influxql.ListLiteral); ok {
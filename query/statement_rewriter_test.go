@@ -284,6 +284,10 @@ func TestRewriteStatement(t *testing.T) {
 			stmt: `SHOW TAG VALUES WITH KEY !~ /re.*/ OFFSET 2`,
 			s:    `SHOW TAG VALUES WITH KEY !~ /re.*/ WHERE _tagKey !~ /re.*/ OFFSET 2`,
 		},
+		{
+			stmt: `SHOW SERIES EXACT CARDINALITY FROM cpu WHERE host = 'a' GROUP BY _name`,
+			s:    `SHOW SERIES EXACT CARDINALITY WHERE (_name = 'cpu') AND (host = 'a') GROUP BY _name`,
+		},
 		{
 			stmt: `SELECT value FROM cpu`,
 			s:    `SELECT value FROM cpu`,
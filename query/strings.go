@@ -0,0 +1,458 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/influxdata/influxql"
+)
+
+// isStringFunction returns true if the call is a string function. String
+// functions are evaluated on every point of their arguments, which are string
+// fields, tags or string literals. They may also be used in conditions.
+func isStringFunction(call *influxql.Call) bool {
+	switch call.Name {
+	case "concat", "lower", "regex_extract", "regex_match", "replace", "strlen", "substr", "upper":
+		return true
+	}
+	return false
+}
+
+// validateStringFunction validates the arguments of a string function call.
+func validateStringFunction(call *influxql.Call) error {
+	got := len(call.Args)
+	switch call.Name {
+	case "lower", "strlen", "upper":
+		if got != 1 {
+			return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", call.Name, 1, got)
+		}
+	case "substr":
+		if got != 2 && got != 3 {
+			return fmt.Errorf("invalid number of arguments for %s, expected 2 or 3, got %d", call.Name, got)
+		}
+		for i, arg := range call.Args[1:] {
+			if lit, ok := arg.(*influxql.IntegerLiteral); !ok || lit.Val < 0 {
+				return fmt.Errorf("expected non-negative integer as argument %d in %s(), found %s", i+2, call.Name, arg)
+			}
+		}
+	case "replace":
+		if got != 3 {
+			return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", call.Name, 3, got)
+		}
+		for i, arg := range call.Args[1:] {
+			if _, ok := arg.(*influxql.StringLiteral); !ok {
+				return fmt.Errorf("expected string as argument %d in %s(), found %s", i+2, call.Name, arg)
+			}
+		}
+	case "regex_match", "regex_extract":
+		if call.Name == "regex_match" && got != 2 {
+			return fmt.Errorf("invalid number of arguments for %s, expected %d, got %d", call.Name, 2, got)
+		} else if got != 2 && got != 3 {
+			return fmt.Errorf("invalid number of arguments for %s, expected 2 or 3, got %d", call.Name, got)
+		}
+		re, err := regexPattern(call.Args[1])
+		if err != nil {
+			return fmt.Errorf("invalid pattern in %s(): %s", call.Name, err)
+		} else if re == nil {
+			return fmt.Errorf("expected pattern as argument 2 in %s(), found %s", call.Name, call.Args[1])
+		}
+		if got == 3 {
+			lit, ok := call.Args[2].(*influxql.IntegerLiteral)
+			if !ok || lit.Val < 0 {
+				return fmt.Errorf("expected non-negative integer as argument 3 in %s(), found %s", call.Name, call.Args[2])
+			} else if lit.Val > int64(re.NumSubexp()) {
+				return fmt.Errorf("%s group %d out of range, expected at most %d", call.Name, lit.Val, re.NumSubexp())
+			}
+		}
+	case "concat":
+		if got < 2 {
+			return fmt.Errorf("invalid number of arguments for %s, expected at least %d, got %d", call.Name, 2, got)
+		}
+		fields := 0
+		for _, arg := range call.Args {
+			switch arg.(type) {
+			case *influxql.StringLiteral:
+			case influxql.Literal:
+				return fmt.Errorf("expected string or field arguments in %s(), found %s", call.Name, arg)
+			default:
+				fields++
+			}
+		}
+		if fields == 0 {
+			return fmt.Errorf("expected field argument in %s()", call.Name)
+		}
+		return nil
+	}
+
+	if _, ok := call.Args[0].(influxql.Literal); ok {
+		return fmt.Errorf("expected field argument in %s()", call.Name)
+	}
+	return nil
+}
+
+// stringFunc returns the function evaluating the upper(), lower(), substr()
+// or replace() call on a value.
+func stringFunc(call *influxql.Call) func(string) string {
+	switch call.Name {
+	case "upper":
+		return strings.ToUpper
+	case "lower":
+		return strings.ToLower
+	case "replace":
+		old, repl := call.Args[1].(*influxql.StringLiteral).Val, call.Args[2].(*influxql.StringLiteral).Val
+		return func(s string) string { return strings.Replace(s, old, repl, -1) }
+	case "substr":
+		start, length := int(call.Args[1].(*influxql.IntegerLiteral).Val), -1
+		if len(call.Args) == 3 {
+			length = int(call.Args[2].(*influxql.IntegerLiteral).Val)
+		}
+		return func(s string) string { return substr(s, start, length) }
+	}
+	return nil
+}
+
+// substr returns the length characters of s starting at the character start,
+// counted from zero. All remaining characters are returned if length is
+// negative.
+func substr(s string, start, length int) string {
+	for i := 0; i < start; i++ {
+		if len(s) == 0 {
+			return ""
+		}
+		_, n := utf8.DecodeRuneInString(s)
+		s = s[n:]
+	}
+	if length < 0 {
+		return s
+	}
+
+	end := 0
+	for i := 0; i < length && end < len(s); i++ {
+		_, n := utf8.DecodeRuneInString(s[end:])
+		end += n
+	}
+	return s[:end]
+}
+
+// regexPattern returns the regular expression of a pattern argument, which is
+// a string or a regex literal. It returns nil if the argument isn't a pattern.
+func regexPattern(expr influxql.Expr) (*regexp.Regexp, error) {
+	switch expr := expr.(type) {
+	case *influxql.RegexLiteral:
+		return expr.Val, nil
+	case *influxql.StringLiteral:
+		return regexp.Compile(expr.Val)
+	}
+	return nil, nil
+}
+
+// regexCall returns the pattern of a regex_match() or regex_extract() call
+// and the group extracted by regex_extract(). The whole match is extracted if
+// the group is omitted.
+func regexCall(call *influxql.Call) (*regexp.Regexp, int) {
+	re, _ := regexPattern(call.Args[1])
+	group := 0
+	if len(call.Args) == 3 {
+		group = int(call.Args[2].(*influxql.IntegerLiteral).Val)
+	}
+	return re, group
+}
+
+// regexExtract returns the text of a group of the leftmost match of re in s.
+// It returns false if re doesn't match s or the group isn't part of the match.
+func regexExtract(re *regexp.Regexp, s string, group int) (string, bool) {
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil || loc[2*group] < 0 {
+		return "", false
+	}
+	return s[loc[2*group]:loc[2*group+1]], true
+}
+
+// rewriteRegexConditions rewrites the regex_match() calls used as conditions
+// into comparisons with true, since conditions are comparisons, and compiles
+// the string patterns of regex calls so they aren't compiled for every point
+// the condition is evaluated on. Invalid patterns are left for validation.
+// The condition is modified in place.
+func rewriteRegexConditions(expr influxql.Expr) influxql.Expr {
+	switch e := expr.(type) {
+	case *influxql.Call:
+		if e.Name == "regex_match" {
+			return &influxql.BinaryExpr{Op: influxql.EQ, LHS: e, RHS: &influxql.BooleanLiteral{Val: true}}
+		}
+	case *influxql.ParenExpr:
+		e.Expr = rewriteRegexConditions(e.Expr)
+	case *influxql.BinaryExpr:
+		if e.Op == influxql.AND || e.Op == influxql.OR {
+			e.LHS = rewriteRegexConditions(e.LHS)
+			e.RHS = rewriteRegexConditions(e.RHS)
+		}
+	}
+
+	influxql.WalkFunc(expr, func(n influxql.Node) {
+		call, ok := n.(*influxql.Call)
+		if !ok || (call.Name != "regex_match" && call.Name != "regex_extract") || len(call.Args) < 2 {
+			return
+		}
+		if lit, ok := call.Args[1].(*influxql.StringLiteral); ok {
+			if re, err := regexp.Compile(lit.Val); err == nil {
+				call.Args[1] = &influxql.RegexLiteral{Val: re}
+			}
+		}
+	})
+	return expr
+}
+
+// newStringFunctionIterator returns an iterator evaluating a string function
+// call on every point of the iterators of its arguments. The iterators of
+// literal arguments are nil.
+func newStringFunctionIterator(call *influxql.Call, args []Iterator, opt IteratorOptions) (Iterator, error) {
+	if call.Name == "concat" {
+		return newConcatIterator(args, call, opt)
+	}
+
+	input, ok := args[0].(StringIterator)
+	if !ok {
+		if _, ok := args[0].(*nilFloatIterator); ok {
+			return args[0], nil
+		}
+		return nil, fmt.Errorf("%s() requires a string or tag argument, found %s", call.Name, iteratorDataType(args[0]))
+	}
+
+	switch call.Name {
+	case "regex_match":
+		re, _ := regexCall(call)
+		return &stringBooleanTransformIterator{
+			input: input,
+			fn: func(p *StringPoint) *BooleanPoint {
+				if p == nil {
+					return nil
+				}
+
+				bp := &BooleanPoint{
+					Name: p.Name,
+					Tags: p.Tags,
+					Time: p.Time,
+					Aux:  p.Aux,
+				}
+				if p.Nil {
+					bp.Nil = true
+				} else {
+					bp.Value = re.MatchString(p.Value)
+				}
+				return bp
+			},
+		}, nil
+	case "regex_extract":
+		// The points whose value doesn't match the pattern are nil.
+		re, group := regexCall(call)
+		return &stringTransformIterator{
+			input: input,
+			fn: func(p *StringPoint) *StringPoint {
+				if p == nil {
+					return nil
+				} else if p.Nil {
+					return p
+				}
+				v, ok := regexExtract(re, p.Value, group)
+				p.Value, p.Nil = v, !ok
+				return p
+			},
+		}, nil
+	case "strlen":
+		return &stringIntegerTransformIterator{
+			input: input,
+			fn: func(p *StringPoint) *IntegerPoint {
+				if p == nil {
+					return nil
+				}
+
+				ip := &IntegerPoint{
+					Name: p.Name,
+					Tags: p.Tags,
+					Time: p.Time,
+					Aux:  p.Aux,
+				}
+				if p.Nil {
+					ip.Nil = true
+				} else {
+					ip.Value = int64(utf8.RuneCountInString(p.Value))
+				}
+				return ip
+			},
+		}, nil
+	}
+
+	fn := stringFunc(call)
+	return &stringTransformIterator{
+		input: input,
+		fn: func(p *StringPoint) *StringPoint {
+			if p == nil {
+				return nil
+			} else if p.Nil {
+				return p
+			}
+			p.Value = fn(p.Value)
+			return p
+		},
+	}, nil
+}
+
+// newConcatIterator returns an iterator concatenating the values of the
+// arguments of a concat() call.
+func newConcatIterator(args []Iterator, call *influxql.Call, opt IteratorOptions) (Iterator, error) {
+	var itr StringIterator
+	var prefix string
+	for i, arg := range call.Args {
+		if lit, ok := arg.(*influxql.StringLiteral); ok {
+			if itr == nil {
+				prefix += lit.Val
+			} else {
+				itr = newConcatLiteralIterator(itr, "", lit.Val)
+			}
+			continue
+		}
+
+		input, ok := args[i].(StringIterator)
+		if !ok {
+			if _, ok := args[i].(*nilFloatIterator); ok {
+				return args[i], nil
+			}
+			return nil, fmt.Errorf("%s() requires string or tag arguments, found %s", call.Name, iteratorDataType(args[i]))
+		}
+
+		if itr == nil {
+			itr = input
+			if prefix != "" {
+				itr = newConcatLiteralIterator(itr, prefix, "")
+			}
+			continue
+		}
+		itr = newStringExprIterator(itr, input, opt, func(a, b string) string { return a + b })
+	}
+	return itr, nil
+}
+
+// newConcatLiteralIterator returns an iterator adding a prefix and a suffix to
+// the values of input.
+func newConcatLiteralIterator(input StringIterator, prefix, suffix string) StringIterator {
+	return &stringTransformIterator{
+		input: input,
+		fn: func(p *StringPoint) *StringPoint {
+			if p == nil {
+				return nil
+			} else if p.Nil {
+				return p
+			}
+			p.Value = prefix + p.Value + suffix
+			return p
+		},
+	}
+}
+
+// containsStringFunction returns true if expr contains a string function call
+// or a cast.
+func containsStringFunction(expr influxql.Expr) bool {
+	var contains bool
+	influxql.WalkFunc(expr, func(n influxql.Node) {
+		if call, ok := n.(*influxql.Call); ok && (isStringFunction(call) || isCastFunction(call)) {
+			contains = true
+		}
+	})
+	return contains
+}
+
+// EvalBool evaluates expr against the values in m and returns true if the
+// result is true. Unlike influxql.EvalBool, string function calls and casts in
+// expr are evaluated. Conditions containing them are rewritten for every
+// evaluation.
+func EvalBool(expr influxql.Expr, m map[string]interface{}) bool {
+	if !containsStringFunction(expr) {
+		return influxql.EvalBool(expr, m)
+	}
+
+	// Calls are rewritten from the leaves, so the arguments of calls are
+	// either references or literals.
+	expr = influxql.RewriteExpr(influxql.CloneExpr(expr), func(expr influxql.Expr) influxql.Expr {
+		call, ok := expr.(*influxql.Call)
+		if !ok {
+			return expr
+		}
+
+		var v interface{}
+		if isStringFunction(call) {
+			v = evalStringFunction(call, m)
+		} else if isCastFunction(call) {
+			v = evalCast(call, m)
+		} else {
+			return expr
+		}
+
+		switch v := v.(type) {
+		case float64:
+			return &influxql.NumberLiteral{Val: v}
+		case int64:
+			return &influxql.IntegerLiteral{Val: v}
+		case uint64:
+			return &influxql.UnsignedLiteral{Val: v}
+		case string:
+			return &influxql.StringLiteral{Val: v}
+		case bool:
+			return &influxql.BooleanLiteral{Val: v}
+		}
+		return &influxql.NilLiteral{}
+	})
+	return influxql.EvalBool(expr, m)
+}
+
+// evalStringFunction evaluates a string function call whose arguments are
+// references to the values in m or literals. It returns nil if an argument is
+// not a string.
+func evalStringFunction(call *influxql.Call, m map[string]interface{}) interface{} {
+	if call.Name == "concat" {
+		var s string
+		for _, arg := range call.Args {
+			v, ok := evalString(arg, m)
+			if !ok {
+				return nil
+			}
+			s += v
+		}
+		return s
+	}
+
+	v, ok := evalString(call.Args[0], m)
+	if !ok {
+		return nil
+	}
+	switch call.Name {
+	case "strlen":
+		return int64(utf8.RuneCountInString(v))
+	case "regex_match":
+		re, _ := regexCall(call)
+		return re.MatchString(v)
+	case "regex_extract":
+		re, group := regexCall(call)
+		if s, ok := regexExtract(re, v, group); ok {
+			return s
+		}
+		return nil
+	}
+	return stringFunc(call)(v)
+}
+
+// evalString returns the string value of a reference to the values in m or
+// of a literal.
+func evalString(expr influxql.Expr, m map[string]interface{}) (string, bool) {
+	switch expr := expr.(type) {
+	case *influxql.StringLiteral:
+		return expr.Val, true
+	case *influxql.VarRef:
+		v, ok := m[expr.Val].(string)
+		return v, ok
+	case *influxql.ParenExpr:
+		return evalString(expr.Expr, m)
+	}
+	return "", false
+}
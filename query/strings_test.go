@@ -0,0 +1,39 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/influxdata/influxdb/query"
+)
+
+func TestEvalBool_StringFunctions(t *testing.T) {
+	m := map[string]interface{}{
+		"host":   "ServerA",
+		"region": "us-west",
+		"value":  float64(10),
+	}
+
+	for _, tt := range []struct {
+		cond string
+		want bool
+	}{
+		{cond: `lower(host) = 'servera'`, want: true},
+		{cond: `upper(host) = 'servera'`, want: false},
+		{cond: `strlen(region) = 7 AND value > 5`, want: true},
+		{cond: `substr(region, 3) = 'west'`, want: true},
+		{cond: `replace(region, '-', '_') = 'us_west'`, want: true},
+		{cond: `concat(host, '.', region) = 'ServerA.us-west'`, want: true},
+		{cond: `regex_match(host, 'A$')`, want: true},
+		{cond: `regex_match(host, /^server/) = false`, want: true},
+		{cond: `regex_extract(region, '^([a-z]+)-', 1) = 'us'`, want: true},
+		{cond: `regex_extract(host, /\d+/) = ''`, want: false},
+		{cond: `upper(missing) = ''`, want: false},
+		{cond: `host = 'ServerA'`, want: true},
+	} {
+		t.Run(tt.cond, func(t *testing.T) {
+			if got := query.EvalBool(MustParseExpr(tt.cond), m); got != tt.want {
+				t.Fatalf("unexpected result: got=%v want=%v", got, tt.want)
+			}
+		})
+	}
+}
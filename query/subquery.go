@@ -29,6 +29,7 @@ func (b *subqueryBuilder) buildAuxIterator(ctx context.Context, opt IteratorOpti
 		return nil, err
 	}
 	subOpt.Aux = auxFields
+	subOpt.Condition = b.pushDownCondition(subOpt.Condition, opt.Condition)
 
 	itrs, err := buildIterators(ctx, b.stmt, b.ic, subOpt)
 	if err != nil {
@@ -119,6 +120,7 @@ func (b *subqueryBuilder) buildVarRefIterator(ctx context.Context, expr *influxq
 		return nil, err
 	}
 	subOpt.Aux = auxFields
+	subOpt.Condition = b.pushDownCondition(subOpt.Condition, opt.Condition)
 
 	itrs, err := buildIterators(ctx, b.stmt, b.ic, subOpt)
 	if err != nil {
@@ -133,3 +135,155 @@ func (b *subqueryBuilder) buildVarRefIterator(ctx context.Context, expr *influxq
 	}
 	return input, nil
 }
+
+// buildCallIterator constructs the iterators of a call on a field of a raw
+// subquery reading from measurements. The call is evaluated by the shards
+// instead of reading every point of the subquery. It returns nil if the call
+// or the condition of the outer query cannot be evaluated on the measurements.
+func (b *subqueryBuilder) buildCallIterator(ctx context.Context, call *influxql.Call, opt IteratorOptions) ([]Iterator, error) {
+	if !b.stmt.IsRawQuery || !b.canPushDown() || len(opt.Aux) > 0 {
+		return nil, nil
+	}
+
+	// The argument must be a field of the measurements.
+	arg0 := call.Args[0].(*influxql.VarRef)
+	if _, ok := b.mapAuxField(arg0).(FieldMap); !ok {
+		return nil, nil
+	}
+	ref := b.pushDownRef(arg0)
+	if ref == nil || ref.Type == influxql.Tag {
+		return nil, nil
+	}
+
+	// The points the outer query filters out would be part of the result
+	// of the call, so the whole condition must be pushed down.
+	cond, ok := b.mapCondition(opt.Condition)
+	if !ok {
+		return nil, nil
+	}
+
+	subOpt, err := newIteratorOptionsSubstatement(ctx, b.stmt, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	callOpt := opt
+	callOpt.Expr = &influxql.Call{
+		Name: call.Name,
+		Args: append([]influxql.Expr{ref}, call.Args[1:]...),
+	}
+	callOpt.StartTime, callOpt.EndTime = subOpt.StartTime, subOpt.EndTime
+	callOpt.Condition = conjunction(subOpt.Condition, cond)
+
+	inputs := make([]Iterator, 0, len(b.stmt.Sources))
+	for _, source := range b.stmt.Sources {
+		input, err := b.ic.CreateIterator(ctx, source.(*influxql.Measurement), callOpt)
+		if err != nil {
+			Iterators(inputs).Close()
+			return nil, err
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+// canPushDown returns true if the subquery reads directly from measurements
+// and does not limit its results. The outer query may then be evaluated while
+// reading the measurements.
+func (b *subqueryBuilder) canPushDown() bool {
+	if b.stmt.Limit > 0 || b.stmt.Offset > 0 || b.stmt.SLimit > 0 || b.stmt.SOffset > 0 {
+		return false
+	}
+	for _, source := range b.stmt.Sources {
+		if _, ok := source.(*influxql.Measurement); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// pushDownCondition adds the parts of the condition of the outer query that
+// can be evaluated while reading the measurements of the subquery to the
+// condition of the subquery. The outer query still filters the points of the
+// subquery, but the shards do not read the series and points it would reject.
+func (b *subqueryBuilder) pushDownCondition(cond, outer influxql.Expr) influxql.Expr {
+	if outer == nil || !b.canPushDown() {
+		return cond
+	}
+
+	for _, expr := range conjuncts(outer) {
+		if expr, ok := b.mapCondition(expr); ok {
+			cond = conjunction(cond, expr)
+		}
+	}
+	return cond
+}
+
+// mapCondition rewrites a condition of the outer query so it references the
+// tags and fields of the measurements of the subquery. It returns false if a
+// reference in the condition does not map to a tag or field.
+func (b *subqueryBuilder) mapCondition(cond influxql.Expr) (influxql.Expr, bool) {
+	if cond == nil {
+		return nil, true
+	}
+
+	ok := true
+	cond = influxql.RewriteExpr(influxql.CloneExpr(cond), func(expr influxql.Expr) influxql.Expr {
+		ref, isRef := expr.(*influxql.VarRef)
+		if !isRef {
+			return expr
+		}
+		if ref := b.pushDownRef(ref); ref != nil {
+			return ref
+		}
+		ok = false
+		return expr
+	})
+	return cond, ok
+}
+
+// pushDownRef returns the reference to the tag or field of the measurements
+// of the subquery that a reference of the outer query maps to. It returns nil
+// if the reference maps to an expression computed by the subquery.
+func (b *subqueryBuilder) pushDownRef(ref *influxql.VarRef) *influxql.VarRef {
+	switch m := b.mapAuxField(ref).(type) {
+	case TagMap:
+		return &influxql.VarRef{Val: string(m), Type: influxql.Tag}
+	case FieldMap:
+		// Fields are only read unmodified by raw queries.
+		if !b.stmt.IsRawQuery {
+			return nil
+		}
+		if ref, ok := b.stmt.Fields[int(m)].Expr.(*influxql.VarRef); ok {
+			return &influxql.VarRef{Val: ref.Val, Type: ref.Type}
+		}
+	}
+	return nil
+}
+
+// conjuncts splits a condition into the expressions joined by AND.
+func conjuncts(expr influxql.Expr) []influxql.Expr {
+	switch e := expr.(type) {
+	case *influxql.ParenExpr:
+		return conjuncts(e.Expr)
+	case *influxql.BinaryExpr:
+		if e.Op == influxql.AND {
+			return append(conjuncts(e.LHS), conjuncts(e.RHS)...)
+		}
+	}
+	return []influxql.Expr{expr}
+}
+
+// conjunction joins two conditions with AND. Either of them may be nil.
+func conjunction(lhs, rhs influxql.Expr) influxql.Expr {
+	if lhs == nil {
+		return rhs
+	} else if rhs == nil {
+		return lhs
+	}
+	return &influxql.BinaryExpr{
+		Op:  influxql.AND,
+		LHS: &influxql.ParenExpr{Expr: lhs},
+		RHS: &influxql.ParenExpr{Expr: rhs},
+	}
+}
@@ -1,7 +1,9 @@
 package query
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -25,6 +27,9 @@ const (
 	// KilledTask is set when the task is killed, but resources are still
 	// being used.
 	KilledTask
+
+	// QueuedTask is set when the task waits for the scheduler to execute it.
+	QueuedTask
 )
 
 func (t TaskStatus) String() string {
@@ -33,6 +38,8 @@ func (t TaskStatus) String() string {
 		return "running"
 	case KilledTask:
 		return "killed"
+	case QueuedTask:
+		return "queued"
 	}
 	panic(fmt.Sprintf("unknown task status: %d", int(t)))
 }
@@ -49,10 +56,21 @@ type TaskManager struct {
 	// Maximum number of concurrent queries.
 	MaxConcurrentQueries int
 
+	// Scheduler limits the number of queries executing SELECT statements
+	// concurrently by their class, if set. Unlike MaxConcurrentQueries,
+	// queries beyond its limits wait instead of failing.
+	Scheduler *QueryScheduler
+
 	// Logger to use for all logging.
 	// Defaults to discarding all log output.
 	Logger *zap.Logger
 
+	// Monitor records the queries killed, if set.
+	Monitor interface {
+		Enabled() bool
+		WritePoints(models.Points) error
+	}
+
 	// Used for managing and tracking running queries.
 	queries  map[uint64]*QueryTask
 	nextID   uint64
@@ -89,7 +107,7 @@ func (t *TaskManager) ExecuteStatement(stmt influxql.Statement, ctx ExecutionCon
 			messages = append(messages, ReadOnlyWarning(stmt.String()))
 		}
 
-		if err := t.executeKillQueryStatement(stmt); err != nil {
+		if err := t.executeKillQueryStatement(stmt, ctx); err != nil {
 			return err
 		}
 		ctx.Results <- &Result{
@@ -102,8 +120,12 @@ func (t *TaskManager) ExecuteStatement(stmt influxql.Statement, ctx ExecutionCon
 	return nil
 }
 
-func (t *TaskManager) executeKillQueryStatement(stmt *influxql.KillQueryStatement) error {
-	return t.KillQuery(stmt.QueryID)
+func (t *TaskManager) executeKillQueryStatement(stmt *influxql.KillQueryStatement, ctx ExecutionContext) error {
+	_, err := t.KillQueries(KillRequest{
+		QueryID:  stmt.QueryID,
+		KilledBy: authorizerUser(ctx.Authorizer),
+	})
+	return err
 }
 
 func (t *TaskManager) executeShowQueriesStatement(q *influxql.ShowQueriesStatement) (models.Rows, error) {
@@ -125,11 +147,15 @@ func (t *TaskManager) executeShowQueriesStatement(q *influxql.ShowQueriesStateme
 			d = d - (d % time.Microsecond)
 		}
 
-		values = append(values, []interface{}{id, qi.query, qi.database, d.String(), qi.status.String()})
+		qi.mu.Lock()
+		status, progress := qi.status, qi.progress
+		qi.mu.Unlock()
+
+		values = append(values, []interface{}{id, qi.query, qi.database, d.String(), status.String(), progress})
 	}
 
 	return []*models.Row{{
-		Columns: []string{"qid", "query", "database", "duration", "status"},
+		Columns: []string{"qid", "query", "database", "duration", "status", "progress"},
 		Values:  values,
 	}}, nil
 }
@@ -150,7 +176,7 @@ func (t *TaskManager) queryError(qid uint64, err error) {
 // query finishes running.
 //
 // After a query finishes running, the system is free to reuse a query id.
-func (t *TaskManager) AttachQuery(q *influxql.Query, database string, interrupt <-chan struct{}) (uint64, *QueryTask, error) {
+func (t *TaskManager) AttachQuery(q *influxql.Query, opt ExecutionOptions, interrupt <-chan struct{}) (uint64, *QueryTask, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -165,7 +191,8 @@ func (t *TaskManager) AttachQuery(q *influxql.Query, database string, interrupt
 	qid := t.nextID
 	query := &QueryTask{
 		query:     q.String(),
-		database:  database,
+		database:  opt.Database,
+		user:      authorizerUser(opt.Authorizer),
 		status:    RunningTask,
 		startTime: time.Now(),
 		closing:   make(chan struct{}),
@@ -203,7 +230,113 @@ func (t *TaskManager) KillQuery(qid uint64) error {
 	if query == nil {
 		return fmt.Errorf("no such query id: %d", qid)
 	}
-	return query.kill()
+	return query.kill(nil)
+}
+
+// KillRequest describes the queries to kill, and who kills them and why.
+type KillRequest struct {
+	// QueryID is the id of the query to kill. If zero, all the queries
+	// matching User and Database are killed.
+	QueryID uint64
+
+	// User and Database, if set, restrict the queries killed to those of
+	// the user and against the database.
+	User     string
+	Database string
+
+	// KilledBy is the name of the user killing the queries.
+	KilledBy string
+
+	// Reason is why the queries are killed. If set, it is returned as the
+	// error of the queries.
+	Reason string
+}
+
+// KillQueries kills the queries matching req, and returns their ids. Each
+// query killed is logged and, if the monitor is enabled, recorded in it.
+func (t *TaskManager) KillQueries(req KillRequest) ([]uint64, error) {
+	if req.QueryID == 0 && req.User == "" && req.Database == "" {
+		return nil, errors.New("no query id, user or database of queries to kill")
+	}
+
+	t.mu.RLock()
+	matches := make(map[uint64]*QueryTask)
+	for id, query := range t.queries {
+		if (req.QueryID == 0 || id == req.QueryID) &&
+			(req.User == "" || query.user == req.User) &&
+			(req.Database == "" || query.database == req.Database) {
+			matches[id] = query
+		}
+	}
+	t.mu.RUnlock()
+
+	if req.QueryID != 0 && len(matches) == 0 {
+		return nil, fmt.Errorf("no such query id: %d", req.QueryID)
+	}
+
+	var err error
+	if req.Reason != "" {
+		err = ErrQueryKilled(req.KilledBy, req.Reason)
+	}
+
+	ids := make([]uint64, 0, len(matches))
+	for id, query := range matches {
+		if kerr := query.kill(err); kerr != nil {
+			if req.QueryID != 0 {
+				return nil, kerr
+			}
+			// Queries already killed are skipped when killing by filter.
+			continue
+		}
+		t.recordKill(id, query, req)
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// recordKill logs that query qid was killed by req and, if the monitor is
+// enabled, records it in the monitor.
+func (t *TaskManager) recordKill(qid uint64, query *QueryTask, req KillRequest) {
+	d := time.Since(query.startTime)
+	t.Logger.Info("Killed query",
+		zap.Uint64("qid", qid),
+		zap.String("query", query.query),
+		zap.String("user", query.user),
+		zap.String("killed_by", req.KilledBy),
+		zap.String("reason", req.Reason))
+
+	if t.Monitor == nil || !t.Monitor.Enabled() {
+		return
+	}
+	tags := make(map[string]string)
+	for k, v := range map[string]string{"db": query.database, "user": query.user, "killedBy": req.KilledBy} {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+	fields := map[string]interface{}{
+		"qid":        int64(qid),
+		"query":      query.query,
+		"reason":     req.Reason,
+		"durationNs": int64(d),
+	}
+	p, err := models.NewPoint("query_kill", models.NewTags(tags), fields, time.Now())
+	if err != nil {
+		t.Logger.Info("Unable to record killed query", zap.Error(err))
+		return
+	}
+	t.Monitor.WritePoints(models.Points{p})
+}
+
+// authorizerUser returns the name of the user of a, if it is a user.
+func authorizerUser(a Authorizer) string {
+	if u, ok := a.(interface {
+		ID() string
+	}); ok {
+		return u.ID()
+	}
+	return ""
 }
 
 // DetachQuery removes a query from the query table. If the query is not in the
@@ -227,7 +360,9 @@ type QueryInfo struct {
 	ID       uint64        `json:"id"`
 	Query    string        `json:"query"`
 	Database string        `json:"database"`
+	User     string        `json:"user,omitempty"`
 	Duration time.Duration `json:"duration"`
+	Progress string        `json:"progress,omitempty"`
 }
 
 // Queries returns a list of all running queries with information about them.
@@ -242,7 +377,9 @@ func (t *TaskManager) Queries() []QueryInfo {
 			ID:       id,
 			Query:    qi.query,
 			Database: qi.database,
+			User:     qi.user,
 			Duration: now.Sub(qi.startTime),
+			Progress: qi.Progress(),
 		})
 	}
 	return queries
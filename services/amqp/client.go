@@ -0,0 +1,558 @@
+package amqp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Frame types of the AMQP 0-9-1 protocol.
+const (
+	frameMethod    = 1
+	frameHeader    = 2
+	frameBody      = 3
+	frameHeartbeat = 8
+	frameEnd       = 0xCE
+)
+
+// protocolHeader starts every connection.
+var protocolHeader = []byte("AMQP\x00\x00\x09\x01")
+
+// minFrameMax is the smallest frame size brokers must accept.
+const minFrameMax = 4096
+
+// channelID is the only channel opened by the service.
+const channelID = 1
+
+// maxBodySize is the largest message body accepted.
+const maxBodySize = 64 * 1024 * 1024
+
+// method identifies a method by its class and method ids.
+type method struct {
+	class, id uint16
+}
+
+// Methods used by the service.
+var (
+	connectionStart   = method{10, 10}
+	connectionStartOk = method{10, 11}
+	connectionTune    = method{10, 30}
+	connectionTuneOk  = method{10, 31}
+	connectionOpen    = method{10, 40}
+	connectionOpenOk  = method{10, 41}
+	connectionClose   = method{10, 50}
+	connectionCloseOk = method{10, 51}
+	channelOpen       = method{20, 10}
+	channelOpenOk     = method{20, 11}
+	channelClose      = method{20, 40}
+	channelCloseOk    = method{20, 41}
+	queueDeclare      = method{50, 10}
+	queueDeclareOk    = method{50, 11}
+	queueBind         = method{50, 20}
+	queueBindOk       = method{50, 21}
+	basicQos          = method{60, 10}
+	basicQosOk        = method{60, 11}
+	basicConsume      = method{60, 20}
+	basicConsumeOk    = method{60, 21}
+	basicCancel       = method{60, 30}
+	basicDeliver      = method{60, 60}
+	basicAck          = method{60, 80}
+	basicNack         = method{60, 120}
+)
+
+var errMalformed = errors.New("amqp: malformed frame")
+
+// closeError is the reason sent by the broker when it closes the connection
+// or the channel.
+type closeError struct {
+	code uint16
+	text string
+}
+
+func (e *closeError) Error() string {
+	return fmt.Sprintf("amqp: closed by broker: %d %s", e.code, e.text)
+}
+
+// delivery is a message delivered to the consumer of the service.
+type delivery struct {
+	tag        uint64
+	routingKey string
+	body       []byte
+}
+
+// client is a connection to an AMQP 0-9-1 broker. It implements the subset
+// of the protocol needed to consume a queue on a single channel.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mu sync.Mutex // serializes writes
+
+	frameMax  uint32
+	heartbeat time.Duration
+}
+
+// newClient returns a client using conn.
+func newClient(conn net.Conn) *client {
+	return &client{conn: conn, r: bufio.NewReader(conn), frameMax: minFrameMax}
+}
+
+// open opens the connection to the virtual host vhost, authenticating with
+// the PLAIN mechanism, and opens the channel of the service. The heartbeat
+// interval is the lowest of heartbeat and the interval proposed by the
+// broker, unless either is zero.
+func (c *client) open(username, password, vhost string, heartbeat, timeout time.Duration) error {
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if _, err := c.conn.Write(protocolHeader); err != nil {
+		return err
+	}
+
+	d, err := c.expect(0, connectionStart)
+	if err != nil {
+		return err
+	}
+	d.octet() // version major
+	d.octet() // version minor
+	d.table() // server properties
+	mechanisms := string(d.longstr())
+	if d.err != nil {
+		return d.err
+	} else if !containsWord(mechanisms, "PLAIN") {
+		return fmt.Errorf("amqp: PLAIN authentication not supported by broker: %s", mechanisms)
+	}
+
+	var e encoder
+	e.table(map[string]string{"product": "InfluxDB", "platform": "Go"})
+	e.shortstr("PLAIN")
+	e.longstr([]byte("\x00" + username + "\x00" + password))
+	e.shortstr("en_US")
+	if err := c.writeMethod(0, connectionStartOk, e.buf); err != nil {
+		return err
+	}
+
+	if d, err = c.expect(0, connectionTune); err != nil {
+		return err
+	}
+	channelMax, frameMax, interval := d.short(), d.long(), time.Duration(d.short())*time.Second
+	if d.err != nil {
+		return d.err
+	}
+	if frameMax > 0 && frameMax < minFrameMax {
+		frameMax = minFrameMax
+	}
+	if interval == 0 || (heartbeat > 0 && heartbeat < interval) {
+		interval = heartbeat
+	}
+	c.frameMax, c.heartbeat = frameMax, interval
+
+	e = encoder{}
+	e.short(channelMax)
+	e.long(frameMax)
+	e.short(uint16(interval / time.Second))
+	if err := c.writeMethod(0, connectionTuneOk, e.buf); err != nil {
+		return err
+	}
+
+	e = encoder{}
+	e.shortstr(vhost)
+	e.shortstr("") // reserved
+	e.octet(0)     // reserved
+	if err := c.writeMethod(0, connectionOpen, e.buf); err != nil {
+		return err
+	}
+	if _, err := c.expect(0, connectionOpenOk); err != nil {
+		return err
+	}
+
+	e = encoder{}
+	e.shortstr("") // reserved
+	if err := c.writeMethod(channelID, channelOpen, e.buf); err != nil {
+		return err
+	}
+	_, err = c.expect(channelID, channelOpenOk)
+	return err
+}
+
+// qos limits the number of unacknowledged messages delivered to prefetch.
+func (c *client) qos(prefetch int) error {
+	var e encoder
+	e.long(0) // prefetch size
+	e.short(uint16(prefetch))
+	e.octet(0) // global
+	return c.call(basicQos, e.buf, basicQosOk)
+}
+
+// declareQueue declares queue, which is created if it does not exist.
+func (c *client) declareQueue(queue string, durable bool) error {
+	var e encoder
+	e.short(0) // reserved
+	e.shortstr(queue)
+	var bits byte
+	if durable {
+		bits |= 0x02
+	}
+	e.octet(bits)
+	e.table(nil) // arguments
+	return c.call(queueDeclare, e.buf, queueDeclareOk)
+}
+
+// bindQueue binds queue to exchange with key.
+func (c *client) bindQueue(queue, exchange, key string) error {
+	var e encoder
+	e.short(0) // reserved
+	e.shortstr(queue)
+	e.shortstr(exchange)
+	e.shortstr(key)
+	e.octet(0)   // no wait
+	e.table(nil) // arguments
+	return c.call(queueBind, e.buf, queueBindOk)
+}
+
+// consume starts consuming queue. Messages must be acknowledged.
+func (c *client) consume(queue string) error {
+	var e encoder
+	e.short(0) // reserved
+	e.shortstr(queue)
+	e.shortstr("") // consumer tag, generated by the broker
+	e.octet(0)     // no local, no ack, exclusive, no wait
+	e.table(nil)   // arguments
+	return c.call(basicConsume, e.buf, basicConsumeOk)
+}
+
+// next returns the next message delivered to the consumer.
+func (c *client) next() (delivery, error) {
+	m, d, err := c.readMethod(channelID)
+	if err != nil {
+		return delivery{}, err
+	}
+
+	switch m {
+	case basicDeliver:
+		d.shortstr() // consumer tag
+		var dv delivery
+		dv.tag = d.longlong()
+		d.octet()    // redelivered
+		d.shortstr() // exchange
+		dv.routingKey = d.shortstr()
+		if d.err != nil {
+			return delivery{}, d.err
+		}
+		dv.body, err = c.readContent()
+		return dv, err
+	case basicCancel:
+		return delivery{}, errors.New("amqp: consumer cancelled by broker")
+	default:
+		return delivery{}, fmt.Errorf("amqp: unexpected method %d.%d", m.class, m.id)
+	}
+}
+
+// readContent reads the content header and body frames following a
+// delivery.
+func (c *client) readContent() ([]byte, error) {
+	typ, payload, err := c.readContentFrame()
+	if err != nil {
+		return nil, err
+	} else if typ != frameHeader {
+		return nil, fmt.Errorf("amqp: expected content header, got frame type %d", typ)
+	}
+	d := decoder{buf: payload}
+	d.short() // class id
+	d.short() // weight
+	size := d.longlong()
+	if d.err != nil {
+		return nil, d.err
+	} else if size > maxBodySize {
+		return nil, fmt.Errorf("amqp: message body of %d bytes is too large", size)
+	}
+
+	body := make([]byte, 0, size)
+	for uint64(len(body)) < size {
+		typ, payload, err := c.readContentFrame()
+		if err != nil {
+			return nil, err
+		} else if typ != frameBody {
+			return nil, fmt.Errorf("amqp: expected content body, got frame type %d", typ)
+		}
+		body = append(body, payload...)
+	}
+	if uint64(len(body)) != size {
+		return nil, errMalformed
+	}
+	return body, nil
+}
+
+// readContentFrame reads the next frame that is not a heartbeat.
+func (c *client) readContentFrame() (byte, []byte, error) {
+	for {
+		typ, _, payload, err := c.readFrame()
+		if err != nil || typ != frameHeartbeat {
+			return typ, payload, err
+		}
+	}
+}
+
+// ack acknowledges the message with tag, and every previous message if
+// multiple is set.
+func (c *client) ack(tag uint64, multiple bool) error {
+	var e encoder
+	e.longlong(tag)
+	e.bit(multiple)
+	return c.writeMethod(channelID, basicAck, e.buf)
+}
+
+// nack rejects the message with tag, and every previous message if multiple
+// is set. Rejected messages are requeued.
+func (c *client) nack(tag uint64, multiple bool) error {
+	var e encoder
+	e.longlong(tag)
+	var bits byte = 0x02 // requeue
+	if multiple {
+		bits |= 0x01
+	}
+	e.octet(bits)
+	return c.writeMethod(channelID, basicNack, e.buf)
+}
+
+// sendHeartbeat sends a heartbeat frame to the broker.
+func (c *client) sendHeartbeat() error {
+	return c.writeFrame(frameHeartbeat, 0, nil)
+}
+
+// Close closes the connection.
+func (c *client) Close() error {
+	return c.conn.Close()
+}
+
+// call sends the method req on the channel of the service and waits for
+// the response resp.
+func (c *client) call(req method, args []byte, resp method) error {
+	if err := c.writeMethod(channelID, req, args); err != nil {
+		return err
+	}
+	_, err := c.expect(channelID, resp)
+	return err
+}
+
+// expect reads the next method of channel and returns a decoder for its
+// arguments. It fails unless the method is m.
+func (c *client) expect(channel uint16, m method) (*decoder, error) {
+	got, d, err := c.readMethod(channel)
+	if err != nil {
+		return nil, err
+	} else if got != m {
+		return nil, fmt.Errorf("amqp: expected method %d.%d, got %d.%d", m.class, m.id, got.class, got.id)
+	}
+	return d, nil
+}
+
+// readMethod reads the next method of channel, skipping heartbeats. It
+// answers a close of the connection or channel by the broker, and returns
+// the reason of the close as an error.
+func (c *client) readMethod(channel uint16) (method, *decoder, error) {
+	for {
+		typ, ch, payload, err := c.readFrame()
+		if err != nil {
+			return method{}, nil, err
+		} else if typ == frameHeartbeat {
+			continue
+		} else if typ != frameMethod {
+			return method{}, nil, fmt.Errorf("amqp: unexpected frame type %d", typ)
+		}
+
+		d := &decoder{buf: payload}
+		m := method{d.short(), d.short()}
+		if d.err != nil {
+			return method{}, nil, d.err
+		}
+
+		switch {
+		case m == connectionClose:
+			c.writeMethod(0, connectionCloseOk, nil)
+			return method{}, nil, &closeError{code: d.short(), text: d.shortstr()}
+		case m == channelClose:
+			c.writeMethod(ch, channelCloseOk, nil)
+			return method{}, nil, &closeError{code: d.short(), text: d.shortstr()}
+		case ch != channel:
+			return method{}, nil, fmt.Errorf("amqp: unexpected method %d.%d on channel %d", m.class, m.id, ch)
+		}
+		return m, d, nil
+	}
+}
+
+// readFrame reads the next frame. If heartbeats are enabled, the broker is
+// considered dead when nothing is received for two heartbeat intervals.
+func (c *client) readFrame() (typ byte, channel uint16, payload []byte, err error) {
+	if c.heartbeat > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(2 * c.heartbeat))
+	}
+
+	var header [7]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	typ, channel = header[0], binary.BigEndian.Uint16(header[1:])
+	size := binary.BigEndian.Uint32(header[3:])
+	if c.frameMax > 0 && size > c.frameMax {
+		return 0, 0, nil, fmt.Errorf("amqp: frame of %d bytes exceeds frame max", size)
+	}
+
+	payload = make([]byte, size+1)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, 0, nil, err
+	} else if payload[size] != frameEnd {
+		return 0, 0, nil, errMalformed
+	}
+	return typ, channel, payload[:size], nil
+}
+
+func (c *client) writeMethod(channel uint16, m method, args []byte) error {
+	payload := make([]byte, 4, 4+len(args))
+	binary.BigEndian.PutUint16(payload, m.class)
+	binary.BigEndian.PutUint16(payload[2:], m.id)
+	return c.writeFrame(frameMethod, channel, append(payload, args...))
+}
+
+func (c *client) writeFrame(typ byte, channel uint16, payload []byte) error {
+	buf := make([]byte, 7, 8+len(payload))
+	buf[0] = typ
+	binary.BigEndian.PutUint16(buf[1:], channel)
+	binary.BigEndian.PutUint32(buf[3:], uint32(len(payload)))
+	buf = append(buf, payload...)
+	buf = append(buf, frameEnd)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// containsWord returns true if the space separated list s contains word.
+func containsWord(s, word string) bool {
+	for _, w := range strings.Fields(s) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+// encoder encodes the arguments of methods.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) octet(v byte) { e.buf = append(e.buf, v) }
+
+func (e *encoder) bit(v bool) {
+	if v {
+		e.octet(1)
+	} else {
+		e.octet(0)
+	}
+}
+
+func (e *encoder) short(v uint16) {
+	e.buf = append(e.buf, 0, 0)
+	binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], v)
+}
+
+func (e *encoder) long(v uint32) {
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], v)
+}
+
+func (e *encoder) longlong(v uint64) {
+	e.buf = append(e.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], v)
+}
+
+// shortstr encodes s, which must not exceed 255 bytes.
+func (e *encoder) shortstr(s string) {
+	e.octet(byte(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) longstr(b []byte) {
+	e.long(uint32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// table encodes a field table of long string values.
+func (e *encoder) table(fields map[string]string) {
+	var t encoder
+	for k, v := range fields {
+		t.shortstr(k)
+		t.octet('S')
+		t.longstr([]byte(v))
+	}
+	e.longstr(t.buf)
+}
+
+// decoder decodes the arguments of methods. Decoding stops at the first
+// error, which is kept in err.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	} else if n < 0 || len(d.buf) < n {
+		d.err = errMalformed
+		d.buf = nil
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) octet() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) short() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) long() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) longlong() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) shortstr() string {
+	return string(d.next(int(d.octet())))
+}
+
+func (d *decoder) longstr() []byte {
+	return d.next(int(d.long()))
+}
+
+// table skips a field table.
+func (d *decoder) table() {
+	d.longstr()
+}
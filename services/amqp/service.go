@@ -0,0 +1,500 @@
+// Package amqp provides a service for InfluxDB to ingest line protocol consumed from AMQP queues.
+package amqp // import "github.com/influxdata/influxdb/services/amqp"
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"go.uber.org/zap"
+)
+
+// statistics gathered by the amqp package.
+const (
+	statMessagesReceived    = "messagesRx"
+	statBytesReceived       = "bytesRx"
+	statPointsReceived      = "pointsRx"
+	statPointsParseFail     = "pointsParseFail"
+	statBatchesTransmitted  = "batchesTx"
+	statPointsTransmitted   = "pointsTx"
+	statBatchesTransmitFail = "batchesTxFail"
+	statMessagesAcked       = "messagesAcked"
+	statMessagesRequeued    = "messagesRequeued"
+	statConnects            = "connects"
+	statConnectFail         = "connectFail"
+)
+
+// Service is an AMQP service that consumes line protocol from a queue. A
+// message is only acknowledged once its points are written, so messages are
+// consumed at least once.
+type Service struct {
+	wg sync.WaitGroup
+
+	mu     sync.RWMutex
+	ready  bool          // Has the required database been created?
+	done   chan struct{} // Is the service closing or closed?
+	client *client       // Current connection to the broker.
+
+	url       brokerURL
+	tlsConfig *tls.Config
+	config    Config
+
+	PointsWriter interface {
+		WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	MetaClient interface {
+		CreateDatabase(name string) (*meta.DatabaseInfo, error)
+	}
+
+	Logger      *zap.Logger
+	stats       *Statistics
+	defaultTags models.StatisticTags
+}
+
+// NewService returns a new instance of Service.
+func NewService(c Config) *Service {
+	d := *c.WithDefaults()
+	return &Service{
+		config:      d,
+		Logger:      zap.NewNop(),
+		stats:       &Statistics{},
+		defaultTags: models.StatisticTags{"queue": d.Queue},
+	}
+}
+
+// Open starts the service.
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed() {
+		return nil // Already open.
+	}
+
+	if s.config.Queue == "" {
+		return errors.New("queue has to be specified in config")
+	}
+	if s.config.Database == "" {
+		return errors.New("database has to be specified in config")
+	}
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+
+	u, err := parseURL(s.config.URL)
+	if err != nil {
+		return err
+	}
+	s.url = u
+
+	if u.secure {
+		tlsConfig, err := s.loadTLSConfig(u.addr)
+		if err != nil {
+			return err
+		}
+		s.tlsConfig = tlsConfig
+	}
+
+	s.done = make(chan struct{})
+
+	s.wg.Add(1)
+	go s.run()
+
+	return nil
+}
+
+// loadTLSConfig returns the TLS configuration used to connect to the broker
+// at addr.
+func (s *Service) loadTLSConfig(addr string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: s.config.InsecureSkipVerify,
+	}
+
+	if s.config.TLSCA != "" {
+		pem, err := ioutil.ReadFile(s.config.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", s.config.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if s.config.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(s.config.TLSCert, s.config.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Statistics maintains statistics for the AMQP service.
+type Statistics struct {
+	MessagesReceived    int64
+	BytesReceived       int64
+	PointsReceived      int64
+	PointsParseFail     int64
+	BatchesTransmitted  int64
+	PointsTransmitted   int64
+	BatchesTransmitFail int64
+	MessagesAcked       int64
+	MessagesRequeued    int64
+	Connects            int64
+	ConnectFail         int64
+}
+
+// Statistics returns statistics for periodic monitoring.
+func (s *Service) Statistics(tags map[string]string) []models.Statistic {
+	return []models.Statistic{{
+		Name: "amqp",
+		Tags: s.defaultTags.Merge(tags),
+		Values: map[string]interface{}{
+			statMessagesReceived:    atomic.LoadInt64(&s.stats.MessagesReceived),
+			statBytesReceived:       atomic.LoadInt64(&s.stats.BytesReceived),
+			statPointsReceived:      atomic.LoadInt64(&s.stats.PointsReceived),
+			statPointsParseFail:     atomic.LoadInt64(&s.stats.PointsParseFail),
+			statBatchesTransmitted:  atomic.LoadInt64(&s.stats.BatchesTransmitted),
+			statPointsTransmitted:   atomic.LoadInt64(&s.stats.PointsTransmitted),
+			statBatchesTransmitFail: atomic.LoadInt64(&s.stats.BatchesTransmitFail),
+			statMessagesAcked:       atomic.LoadInt64(&s.stats.MessagesAcked),
+			statMessagesRequeued:    atomic.LoadInt64(&s.stats.MessagesRequeued),
+			statConnects:            atomic.LoadInt64(&s.stats.Connects),
+			statConnectFail:         atomic.LoadInt64(&s.stats.ConnectFail),
+		},
+	}}
+}
+
+// run consumes the queue until the service is closed, reconnecting after
+// every error.
+func (s *Service) run() {
+	defer s.wg.Done()
+
+	for {
+		err := s.consume()
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		s.Logger.Info("AMQP consumer failed",
+			zap.String("queue", s.config.Queue), zap.Error(err))
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(time.Duration(s.config.ReconnectInterval)):
+		}
+	}
+}
+
+// consume connects to the broker and consumes the queue until an error
+// occurs. Points are written in batches, after which their messages are
+// acknowledged. Messages left unacknowledged when the connection is closed
+// are requeued by the broker.
+func (s *Service) consume() error {
+	c, err := s.connect()
+	if err != nil {
+		atomic.AddInt64(&s.stats.ConnectFail, 1)
+		return err
+	}
+	defer s.disconnect(c)
+	atomic.AddInt64(&s.stats.Connects, 1)
+
+	if err := s.subscribe(c); err != nil {
+		return err
+	}
+	s.Logger.Info("Consuming AMQP queue", zap.String("queue", s.config.Queue))
+
+	// Deliveries are read by a separate goroutine, so batches can be
+	// flushed on timeout.
+	stop := make(chan struct{})
+	defer close(stop)
+	deliveries, errc := make(chan delivery), make(chan error, 1)
+	go func() {
+		for {
+			d, err := c.next()
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case deliveries <- d:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	go s.sendHeartbeats(c, stop)
+
+	var batch []models.Point
+	var last uint64 // delivery tag of the last message of the batch
+	var pending int64
+	var timeout <-chan time.Time
+
+	flush := func() error {
+		if pending == 0 {
+			return nil
+		}
+		if len(batch) > 0 {
+			if err := s.write(batch); err != nil {
+				// Have the broker deliver the messages again.
+				if err := c.nack(last, true); err == nil {
+					atomic.AddInt64(&s.stats.MessagesRequeued, pending)
+				}
+				return err
+			}
+		}
+		if err := c.ack(last, true); err != nil {
+			return err
+		}
+		atomic.AddInt64(&s.stats.MessagesAcked, pending)
+
+		batch, pending, timeout = nil, 0, nil
+		return nil
+	}
+
+	for {
+		select {
+		case d := <-deliveries:
+			batch = append(batch, s.parse(d)...)
+			last = d.tag
+			if pending++; pending == 1 {
+				timeout = time.After(time.Duration(s.config.BatchTimeout))
+			}
+
+			// The broker stops delivering messages once prefetch-count
+			// messages are unacknowledged.
+			if len(batch) >= s.config.BatchSize || (s.config.PrefetchCount > 0 && pending >= int64(s.config.PrefetchCount)) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-timeout:
+			if err := flush(); err != nil {
+				return err
+			}
+		case err := <-errc:
+			return err
+		case <-s.done:
+			return nil
+		}
+	}
+}
+
+// subscribe declares and binds the queue, then starts consuming it.
+func (s *Service) subscribe(c *client) error {
+	if err := c.qos(s.config.PrefetchCount); err != nil {
+		return err
+	}
+	if err := c.declareQueue(s.config.Queue, s.config.QueueDurable); err != nil {
+		return err
+	}
+
+	if s.config.Exchange != "" {
+		keys := s.config.BindingKeys
+		if len(keys) == 0 {
+			keys = []string{"#"}
+		}
+		for _, key := range keys {
+			if err := c.bindQueue(s.config.Queue, s.config.Exchange, key); err != nil {
+				return err
+			}
+		}
+	}
+	return c.consume(s.config.Queue)
+}
+
+// connect dials the broker and registers the connection so it is closed
+// along with the service.
+func (s *Service) connect() (*client, error) {
+	timeout := time.Duration(s.config.ConnectTimeout)
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", s.url.addr, s.tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", s.url.addr, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := newClient(conn)
+	s.mu.Lock()
+	if s.closed() {
+		s.mu.Unlock()
+		conn.Close()
+		return nil, errors.New("service closed")
+	}
+	s.client = c
+	s.mu.Unlock()
+
+	if err := c.open(s.url.username, s.url.password, s.url.vhost, time.Duration(s.config.Heartbeat), timeout); err != nil {
+		s.disconnect(c)
+		return nil, err
+	}
+	return c, nil
+}
+
+// disconnect closes c and unregisters it.
+func (s *Service) disconnect(c *client) {
+	s.mu.Lock()
+	if s.client == c {
+		s.client = nil
+	}
+	s.mu.Unlock()
+	c.Close()
+}
+
+// sendHeartbeats sends heartbeats to the broker at half the negotiated
+// heartbeat interval until stop is closed.
+func (s *Service) sendHeartbeats(c *client, stop chan struct{}) {
+	if c.heartbeat == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.heartbeat / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.sendHeartbeat(); err != nil {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// parse returns the points of a message.
+func (s *Service) parse(d delivery) []models.Point {
+	atomic.AddInt64(&s.stats.MessagesReceived, 1)
+	atomic.AddInt64(&s.stats.BytesReceived, int64(len(d.body)))
+
+	points, err := models.ParsePointsWithPrecision(d.body, time.Now().UTC(), s.config.Precision)
+	if err != nil {
+		atomic.AddInt64(&s.stats.PointsParseFail, 1)
+		s.Logger.Info("Failed to parse points",
+			zap.String("routing_key", d.routingKey), zap.Error(err))
+		return nil
+	}
+	atomic.AddInt64(&s.stats.PointsReceived, int64(len(points)))
+	return points
+}
+
+// write writes points to the configured database.
+func (s *Service) write(points []models.Point) error {
+	// Will attempt to create database if not yet created.
+	if err := s.createInternalStorage(); err != nil {
+		s.Logger.Info("Required database not yet created",
+			logger.Database(s.config.Database), zap.Error(err))
+		atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+		return err
+	}
+
+	if err := s.PointsWriter.WritePointsPrivileged(s.config.Database, s.config.RetentionPolicy, models.ConsistencyLevelAny, points); err != nil {
+		s.Logger.Info("Failed to write point batch to database",
+			logger.Database(s.config.Database), zap.Error(err))
+		atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+		return err
+	}
+	atomic.AddInt64(&s.stats.BatchesTransmitted, 1)
+	atomic.AddInt64(&s.stats.PointsTransmitted, int64(len(points)))
+	return nil
+}
+
+// Close closes the service and the connection to the broker. Messages whose
+// points are not written yet are requeued by the broker.
+func (s *Service) Close() error {
+	if wait := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.closed() {
+			return false // Already closed.
+		}
+		close(s.done)
+
+		if s.client != nil {
+			s.client.Close()
+		}
+		return true
+	}(); !wait {
+		return nil
+	}
+	s.wg.Wait()
+
+	// Release all remaining resources.
+	s.mu.Lock()
+	s.done = nil
+	s.mu.Unlock()
+
+	s.Logger.Info("Service closed")
+
+	return nil
+}
+
+// Closed returns true if the service is currently closed.
+func (s *Service) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed()
+}
+
+func (s *Service) closed() bool {
+	select {
+	case <-s.done:
+		// Service is closing.
+		return true
+	default:
+	}
+	return s.done == nil
+}
+
+// createInternalStorage ensures that the required database has been created.
+func (s *Service) createInternalStorage() error {
+	s.mu.RLock()
+	ready := s.ready
+	s.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if _, err := s.MetaClient.CreateDatabase(s.config.Database); err != nil {
+		return err
+	}
+
+	// The service is now ready.
+	s.mu.Lock()
+	s.ready = true
+	s.mu.Unlock()
+	return nil
+}
+
+// WithLogger sets the logger on the service.
+func (s *Service) WithLogger(log *zap.Logger) {
+	s.Logger = log.With(zap.String("service", "amqp"))
+}
@@ -0,0 +1,456 @@
+package amqp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/toml"
+)
+
+// Ensure the service declares and binds its queue, writes the points of the
+// messages delivered and acknowledges them.
+func TestService_Write(t *testing.T) {
+	t.Parallel()
+
+	broker := NewTestBroker(t)
+	defer broker.Close()
+
+	c := NewConfig()
+	c.URL = "amqp://user:pass@" + broker.Addr().String() + "/telegraf"
+	c.Queue = "metrics"
+	c.Exchange = "telegraf"
+	c.BindingKeys = []string{"cpu.#", "mem.#"}
+	c.PrefetchCount = 3
+	c.BatchTimeout = toml.Duration(time.Minute)
+	s := NewTestService(&c)
+
+	written := make(chan []models.Point, 1)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		if database != "amqp" {
+			t.Errorf("unexpected database: %s", database)
+		}
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn := broker.Accept()
+	defer conn.Close()
+	if response, vhost := conn.Open(0); response != "\x00user\x00pass" || vhost != "telegraf" {
+		t.Fatalf("unexpected response %q and vhost %q", response, vhost)
+	}
+
+	d := conn.Expect(basicQos)
+	if d.long(); d.short() != 3 {
+		t.Fatal("unexpected prefetch count")
+	}
+	conn.Reply(basicQosOk, nil)
+
+	d = conn.Expect(queueDeclare)
+	if d.short(); d.shortstr() != "metrics" || d.octet() != 0x02 {
+		t.Fatal("unexpected queue declaration")
+	}
+	conn.Reply(queueDeclareOk, nil)
+
+	for _, key := range c.BindingKeys {
+		d = conn.Expect(queueBind)
+		if d.short(); d.shortstr() != "metrics" || d.shortstr() != "telegraf" || d.shortstr() != key {
+			t.Fatalf("unexpected binding for %s", key)
+		}
+		conn.Reply(queueBindOk, nil)
+	}
+
+	conn.Expect(basicConsume)
+	conn.Reply(basicConsumeOk, nil)
+
+	// The batch is written once prefetch-count messages are delivered.
+	conn.Deliver(1, "cpu.host1", "cpu value=1 1000000000")
+	conn.Deliver(2, "cpu.host2", "cpu value=2 2000000000\ncpu value=x")
+	conn.Deliver(3, "mem.host1", "mem value=3 3000000000")
+
+	select {
+	case points := <-written:
+		if len(points) != 2 {
+			t.Fatalf("got %d points, expected 2", len(points))
+		}
+		if got, exp := points[0].String(), "cpu value=1 1000000000"; got != exp {
+			t.Fatalf("got %q, expected %q", got, exp)
+		} else if got, exp := points[1].String(), "mem value=3 3000000000"; got != exp {
+			t.Fatalf("got %q, expected %q", got, exp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("points not written")
+	}
+
+	// All messages are acknowledged, including the invalid one.
+	d = conn.Expect(basicAck)
+	if d.longlong() != 3 || d.octet() != 1 {
+		t.Fatal("unexpected acknowledgement")
+	}
+
+	stats := s.Service.Statistics(nil)[0].Values
+	if got, exp := stats[statMessagesReceived], int64(3); got != exp {
+		t.Fatalf("got %v messages received, expected %d", got, exp)
+	} else if got, exp := stats[statPointsParseFail], int64(1); got != exp {
+		t.Fatalf("got %v parse failures, expected %d", got, exp)
+	}
+}
+
+// Ensure messages are requeued when their points cannot be written, and
+// that the service reconnects.
+func TestService_WriteFail(t *testing.T) {
+	t.Parallel()
+
+	broker := NewTestBroker(t)
+	defer broker.Close()
+
+	c := NewConfig()
+	c.URL = "amqp://" + broker.Addr().String()
+	c.BatchTimeout = toml.Duration(10 * time.Millisecond)
+	c.Heartbeat = 0
+	c.ReconnectInterval = toml.Duration(10 * time.Millisecond)
+	s := NewTestService(&c)
+
+	writes := 0
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		if writes++; writes == 1 {
+			return errors.New("write failed")
+		}
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn := broker.Accept()
+	defer conn.Close()
+	if response, vhost := conn.Open(0); response != "\x00guest\x00guest" || vhost != "/" {
+		t.Fatalf("unexpected response %q and vhost %q", response, vhost)
+	}
+	conn.Subscribe()
+
+	// The batch is written after batch-timeout.
+	conn.Deliver(1, "cpu", "cpu value=1")
+	conn.Deliver(2, "cpu", "cpu value=2")
+	d := conn.Expect(basicNack)
+	if d.longlong() != 2 || d.octet() != 0x03 {
+		t.Fatal("unexpected rejection")
+	}
+
+	// The broker drops the connection and redelivers the messages to the
+	// next one.
+	if _, err := conn.ReadFrame(); err != io.EOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	conn = broker.Accept()
+	defer conn.Close()
+	conn.Open(0)
+	conn.Subscribe()
+	conn.Deliver(1, "cpu", "cpu value=1\ncpu value=2")
+	if d := conn.Expect(basicAck); d.longlong() != 1 {
+		t.Fatal("unexpected acknowledgement")
+	}
+
+	stats := s.Service.Statistics(nil)[0].Values
+	if got, exp := stats[statMessagesRequeued], int64(2); got != exp {
+		t.Fatalf("got %v messages requeued, expected %d", got, exp)
+	} else if got, exp := stats[statBatchesTransmitFail], int64(1); got != exp {
+		t.Fatalf("got %v failed batches, expected %d", got, exp)
+	} else if got, exp := stats[statConnects], int64(2); got != exp {
+		t.Fatalf("got %v connections, expected %d", got, exp)
+	}
+}
+
+// Ensure the service sends heartbeats at half the interval negotiated with
+// the broker.
+func TestService_Heartbeat(t *testing.T) {
+	t.Parallel()
+
+	broker := NewTestBroker(t)
+	defer broker.Close()
+
+	c := NewConfig()
+	c.URL = "amqp://" + broker.Addr().String()
+	c.Heartbeat = toml.Duration(time.Second)
+	s := NewTestService(&c)
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn := broker.Accept()
+	defer conn.Close()
+
+	// The broker proposes a longer interval than the configured one.
+	conn.Open(60)
+	d := conn.TuneOk
+	d.short()
+	d.long()
+	if d.short() != 1 {
+		t.Fatal("unexpected heartbeat interval")
+	}
+	conn.Subscribe()
+
+	if typ, err := conn.ReadFrame(); err != nil {
+		t.Fatal(err)
+	} else if typ != frameHeartbeat {
+		t.Fatalf("got frame type %d, expected heartbeat", typ)
+	}
+}
+
+// Ensure the service can be closed while connected to the broker.
+func TestService_OpenClose(t *testing.T) {
+	broker := NewTestBroker(t)
+	defer broker.Close()
+
+	c := NewConfig()
+	c.URL = "amqp://" + broker.Addr().String()
+	s := NewTestService(&c)
+
+	// Closing a closed service is fine.
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Opening an already open service is fine.
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		conn := broker.Accept()
+		conn.Open(0)
+		conn.Subscribe()
+
+		if err := s.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+}
+
+// TestBroker accepts connections from the service. The test plays the role
+// of the broker over the accepted connections.
+type TestBroker struct {
+	t     *testing.T
+	ln    net.Listener
+	conns chan *TestConn
+}
+
+func NewTestBroker(t *testing.T) *TestBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &TestBroker{t: t, ln: ln, conns: make(chan *TestConn, 1)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				close(b.conns)
+				return
+			}
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+			b.conns <- &TestConn{t: t, c: newClient(conn)}
+		}
+	}()
+	return b
+}
+
+// Accept returns the next connection of the service.
+func (b *TestBroker) Accept() *TestConn {
+	select {
+	case conn := <-b.conns:
+		return conn
+	case <-time.After(5 * time.Second):
+		b.t.Fatal(errors.New("no connection"))
+		return nil
+	}
+}
+
+func (b *TestBroker) Addr() net.Addr { return b.ln.Addr() }
+func (b *TestBroker) Close() error   { return b.ln.Close() }
+
+// TestConn is a connection of the service to a TestBroker. The frames are
+// read and written with a client, as the framing is the same both ways.
+type TestConn struct {
+	t *testing.T
+	c *client
+
+	// TuneOk holds the arguments of the Connection.TuneOk of the service.
+	TuneOk *decoder
+}
+
+// Open performs the handshake of the service, proposing the heartbeat
+// interval heartbeat in seconds. It returns the PLAIN response and the
+// virtual host of the service.
+func (c *TestConn) Open(heartbeat uint16) (response, vhost string) {
+	header := make([]byte, len(protocolHeader))
+	if _, err := io.ReadFull(c.c.r, header); err != nil {
+		c.t.Fatal(err)
+	} else if string(header) != string(protocolHeader) {
+		c.t.Fatalf("unexpected protocol header: %q", header)
+	}
+
+	var e encoder
+	e.octet(0)
+	e.octet(9)
+	e.table(map[string]string{"product": "TestBroker"})
+	e.longstr([]byte("AMQPLAIN PLAIN"))
+	e.longstr([]byte("en_US"))
+	c.write(0, connectionStart, e.buf)
+
+	d := c.expect(0, connectionStartOk)
+	d.table()
+	if mechanism := d.shortstr(); mechanism != "PLAIN" {
+		c.t.Fatalf("unexpected mechanism: %s", mechanism)
+	}
+	response = string(d.longstr())
+
+	e = encoder{}
+	e.short(2047)
+	e.long(131072)
+	e.short(heartbeat)
+	c.write(0, connectionTune, e.buf)
+	c.TuneOk = c.expect(0, connectionTuneOk)
+
+	vhost = c.expect(0, connectionOpen).shortstr()
+	c.write(0, connectionOpenOk, []byte{0})
+
+	c.expect(channelID, channelOpen)
+	c.write(channelID, channelOpenOk, []byte{0, 0, 0, 0})
+	return response, vhost
+}
+
+// Subscribe accepts the setup of the consumer, without exchange.
+func (c *TestConn) Subscribe() {
+	c.Expect(basicQos)
+	c.Reply(basicQosOk, nil)
+	c.Expect(queueDeclare)
+	c.Reply(queueDeclareOk, nil)
+	c.Expect(basicConsume)
+	c.Reply(basicConsumeOk, nil)
+}
+
+// Expect reads the next method of the service on its channel, skipping
+// heartbeats, and fails unless it is m.
+func (c *TestConn) Expect(m method) *decoder {
+	return c.expect(channelID, m)
+}
+
+func (c *TestConn) expect(channel uint16, m method) *decoder {
+	for {
+		typ, ch, payload, err := c.c.readFrame()
+		if err != nil {
+			c.t.Fatal(err)
+		} else if typ == frameHeartbeat {
+			continue
+		}
+
+		d := &decoder{buf: payload}
+		if got := (method{d.short(), d.short()}); typ != frameMethod || ch != channel || got != m {
+			c.t.Fatalf("got frame type %d, method %d.%d on channel %d, expected method %d.%d on channel %d",
+				typ, got.class, got.id, ch, m.class, m.id, channel)
+		}
+		return d
+	}
+}
+
+// Reply sends the method m on the channel of the service.
+func (c *TestConn) Reply(m method, args []byte) {
+	c.write(channelID, m, args)
+}
+
+// Deliver sends a message to the service.
+func (c *TestConn) Deliver(tag uint64, routingKey, body string) {
+	var e encoder
+	e.shortstr("ctag")
+	e.longlong(tag)
+	e.octet(0) // redelivered
+	e.shortstr("telegraf")
+	e.shortstr(routingKey)
+	c.write(channelID, basicDeliver, e.buf)
+
+	e = encoder{}
+	e.short(basicDeliver.class)
+	e.short(0) // weight
+	e.longlong(uint64(len(body)))
+	e.short(0) // property flags
+	if err := c.c.writeFrame(frameHeader, channelID, e.buf); err != nil {
+		c.t.Fatal(err)
+	} else if err := c.c.writeFrame(frameBody, channelID, []byte(body)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// ReadFrame returns the type of the next frame sent by the service.
+func (c *TestConn) ReadFrame() (byte, error) {
+	typ, _, _, err := c.c.readFrame()
+	return typ, err
+}
+
+func (c *TestConn) write(channel uint16, m method, args []byte) {
+	if err := c.c.writeMethod(channel, m, args); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *TestConn) Close() error { return c.c.Close() }
+
+type TestService struct {
+	Service       *Service
+	Config        Config
+	MetaClient    *internal.MetaClientMock
+	WritePointsFn func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+}
+
+func NewTestService(c *Config) *TestService {
+	if c == nil {
+		defaultC := NewConfig()
+		c = &defaultC
+	}
+
+	service := &TestService{
+		Service:    NewService(*c),
+		Config:     *c,
+		MetaClient: &internal.MetaClientMock{},
+	}
+	service.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	if testing.Verbose() {
+		service.Service.WithLogger(logger.New(os.Stderr))
+	}
+
+	service.Service.MetaClient = service.MetaClient
+	service.Service.PointsWriter = service
+	return service
+}
+
+func (s *TestService) WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	if s.WritePointsFn == nil {
+		return nil
+	}
+	return s.WritePointsFn(database, retentionPolicy, consistencyLevel, points)
+}
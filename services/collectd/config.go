@@ -2,6 +2,7 @@ package collectd
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/influxdata/influxdb/monitor/diagnostics"
@@ -12,6 +13,9 @@ const (
 	// DefaultBindAddress is the default port to bind to.
 	DefaultBindAddress = ":25826"
 
+	// DefaultProtocol is the default protocol to listen on.
+	DefaultProtocol = "udp"
+
 	// DefaultDatabase is the default DB to write to.
 	DefaultDatabase = "collectd"
 
@@ -57,6 +61,7 @@ const (
 type Config struct {
 	Enabled               bool          `toml:"enabled"`
 	BindAddress           string        `toml:"bind-address"`
+	Protocol              string        `toml:"protocol"`
 	Database              string        `toml:"database"`
 	RetentionPolicy       string        `toml:"retention-policy"`
 	BatchSize             int           `toml:"batch-size"`
@@ -64,15 +69,21 @@ type Config struct {
 	BatchDuration         toml.Duration `toml:"batch-timeout"`
 	ReadBuffer            int           `toml:"read-buffer"`
 	TypesDB               string        `toml:"typesdb"`
+	TypesDBReloadInterval toml.Duration `toml:"typesdb-reload-interval"`
 	SecurityLevel         string        `toml:"security-level"`
 	AuthFile              string        `toml:"auth-file"`
 	ParseMultiValuePlugin string        `toml:"parse-multivalue-plugin"`
+
+	// NotificationsMeasurement is the measurement collectd notifications
+	// are written to. Notifications are discarded if it is empty.
+	NotificationsMeasurement string `toml:"notifications-measurement"`
 }
 
 // NewConfig returns a new instance of Config with defaults.
 func NewConfig() Config {
 	return Config{
 		BindAddress:           DefaultBindAddress,
+		Protocol:              DefaultProtocol,
 		Database:              DefaultDatabase,
 		RetentionPolicy:       DefaultRetentionPolicy,
 		ReadBuffer:            DefaultReadBuffer,
@@ -93,6 +104,9 @@ func (c *Config) WithDefaults() *Config {
 	if d.BindAddress == "" {
 		d.BindAddress = DefaultBindAddress
 	}
+	if d.Protocol == "" {
+		d.Protocol = DefaultProtocol
+	}
 	if d.Database == "" {
 		d.Database = DefaultDatabase
 	}
@@ -129,16 +143,34 @@ func (c *Config) WithDefaults() *Config {
 
 // Validate returns an error if the Config is invalid.
 func (c *Config) Validate() error {
-	switch c.SecurityLevel {
-	case "none", "sign", "encrypt":
+	switch strings.ToLower(c.SecurityLevel) {
+	case "none":
+	case "sign", "encrypt":
+		if c.AuthFile == "" {
+			return errors.New("auth-file is required when security-level is sign or encrypt")
+		}
 	default:
 		return errors.New("Invalid security level")
 	}
 
+	if c.TypesDBReloadInterval < 0 {
+		return errors.New("typesdb-reload-interval must not be negative")
+	}
+
+	switch strings.ToLower(c.Protocol) {
+	case "", "udp":
+	case "tcp":
+		if strings.ToLower(c.SecurityLevel) == "sign" {
+			return errors.New(`security-level "sign" is not supported over tcp, use "encrypt"`)
+		}
+	default:
+		return errors.New(`Invalid value for protocol. Valid options are "udp" and "tcp"`)
+	}
+
 	switch c.ParseMultiValuePlugin {
-	case "split", "join":
+	case "split", "join", "tag":
 	default:
-		return errors.New(`Invalid value for parse-multivalue-plugin. Valid options are "split" and "join"`)
+		return errors.New(`Invalid value for parse-multivalue-plugin. Valid options are "split", "join" and "tag"`)
 	}
 
 	return nil
@@ -2,9 +2,11 @@ package collectd_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/influxdata/influxdb/services/collectd"
+	itoml "github.com/influxdata/influxdb/toml"
 )
 
 func TestConfig_Parse(t *testing.T) {
@@ -30,3 +32,62 @@ typesdb = "yyy"
 		t.Fatalf("unexpected types db: %s", c.TypesDB)
 	}
 }
+
+func TestConfig_Validate_SecurityLevel(t *testing.T) {
+	c := collectd.NewConfig()
+	c.SecurityLevel = "Encrypt"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c.AuthFile = ""
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for missing auth file")
+	}
+
+	c.SecurityLevel = "none"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c.SecurityLevel = "strict"
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for invalid security level")
+	}
+}
+
+func TestConfig_Validate_Protocol(t *testing.T) {
+	c := collectd.NewConfig()
+	c.Protocol = "tcp"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c.SecurityLevel = "sign"
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for signed packets over tcp")
+	}
+
+	c.SecurityLevel = "encrypt"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c.Protocol = "sctp"
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for invalid protocol")
+	}
+}
+
+func TestConfig_Validate_TypesDBReloadInterval(t *testing.T) {
+	c := collectd.NewConfig()
+	c.TypesDBReloadInterval = itoml.Duration(time.Minute)
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c.TypesDBReloadInterval = itoml.Duration(-time.Minute)
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for negative reload interval")
+	}
+}
@@ -0,0 +1,113 @@
+package collectd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/cdtime"
+)
+
+// errInvalidPart is returned when a notification part cannot be decoded.
+var errInvalidPart = errors.New("invalid notification part")
+
+// notification is a collectd notification, such as one raised when a
+// threshold is crossed.
+type notification struct {
+	api.Identifier
+	Time     time.Time
+	Severity string
+	Message  string
+}
+
+// parseNotifications returns the notifications in the packet in buf. collectd
+// sends a notification as a message part following the parts setting its
+// identifier, time and severity. The contents of encrypted parts are skipped,
+// and signatures are not checked, so buf must already have been verified.
+func parseNotifications(buf []byte) ([]notification, error) {
+	var (
+		state         notification
+		notifications []notification
+	)
+	for len(buf) > 0 {
+		if len(buf) < 4 {
+			return notifications, errInvalidPart
+		}
+		typ := binary.BigEndian.Uint16(buf)
+		length := int(binary.BigEndian.Uint16(buf[2:]))
+		if length < 4 || length > len(buf) {
+			return notifications, fmt.Errorf("invalid part length %d", length)
+		}
+		payload := buf[4:length]
+		buf = buf[length:]
+
+		var err error
+		switch typ {
+		case partTypeHost:
+			state.Host, err = partString(payload)
+		case partTypePlugin:
+			state.Plugin, err = partString(payload)
+		case partTypePluginInstance:
+			state.PluginInstance, err = partString(payload)
+		case partTypeType:
+			state.Type, err = partString(payload)
+		case partTypeTypeInstance:
+			state.TypeInstance, err = partString(payload)
+		case partTypeTime, partTypeTimeHR:
+			var v uint64
+			v, err = partInt(payload)
+			if typ == partTypeTime {
+				state.Time = time.Unix(int64(v), 0)
+			} else {
+				state.Time = cdtime.Time(v).Time()
+			}
+		case partTypeSeverity:
+			var v uint64
+			v, err = partInt(payload)
+			state.Severity = severity(v)
+		case partTypeMessage:
+			state.Message, err = partString(payload)
+			notifications = append(notifications, state)
+		default:
+			// Value lists and the headers of signed parts hold no
+			// notification state.
+		}
+		if err != nil {
+			return notifications, err
+		}
+	}
+	return notifications, nil
+}
+
+// severity returns the name of a collectd notification severity.
+func severity(v uint64) string {
+	switch v {
+	case 1:
+		return "failure"
+	case 2:
+		return "warning"
+	case 4:
+		return "okay"
+	default:
+		return strconv.FormatUint(v, 10)
+	}
+}
+
+// partString decodes the null terminated string in the payload of a part.
+func partString(payload []byte) (string, error) {
+	if len(payload) == 0 || payload[len(payload)-1] != 0 {
+		return "", errInvalidPart
+	}
+	return string(payload[:len(payload)-1]), nil
+}
+
+// partInt decodes the integer in the payload of a part.
+func partInt(payload []byte) (uint64, error) {
+	if len(payload) != 8 {
+		return 0, errInvalidPart
+	}
+	return binary.BigEndian.Uint64(payload), nil
+}
@@ -0,0 +1,82 @@
+package collectd
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+)
+
+func TestParseNotifications(t *testing.T) {
+	got, err := parseNotifications(testNotificationData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := api.Identifier{Host: "server01", Plugin: "df", Type: "df", TypeInstance: "root"}
+	exp := []notification{
+		{Identifier: id, Time: time.Unix(1414080767, 0), Severity: "warning", Message: "Disk almost full"},
+		{Identifier: id, Time: time.Unix(1414080767, 0), Severity: "okay", Message: "Disk usage normal"},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("\n\texp = %+v\n\tgot = %+v\n", exp, got)
+	}
+
+	// Value lists hold no notifications.
+	if got, err := parseNotifications(testData); err != nil {
+		t.Fatal(err)
+	} else if len(got) != 0 {
+		t.Fatalf("unexpected notifications: %+v", got)
+	}
+}
+
+func TestParseNotifications_Invalid(t *testing.T) {
+	for _, buf := range [][]byte{
+		{0x01, 0x00},
+		{0x01, 0x00, 0x00, 0x08, 'a'},
+		notificationPart(partTypeMessage, []byte("no terminator")),
+		notificationPart(partTypeSeverity, []byte{0x01}),
+	} {
+		if _, err := parseNotifications(buf); err == nil {
+			t.Fatalf("expected error parsing %x", buf)
+		}
+	}
+}
+
+// testNotificationData is a packet holding two notifications.
+var testNotificationData = func() []byte {
+	var buf []byte
+	for _, part := range [][]byte{
+		notificationString(partTypeHost, "server01"),
+		notificationInt(partTypeTime, 1414080767),
+		notificationString(partTypePlugin, "df"),
+		notificationString(partTypeType, "df"),
+		notificationString(partTypeTypeInstance, "root"),
+		notificationInt(partTypeSeverity, 2),
+		notificationString(partTypeMessage, "Disk almost full"),
+		notificationInt(partTypeSeverity, 4),
+		notificationString(partTypeMessage, "Disk usage normal"),
+	} {
+		buf = append(buf, part...)
+	}
+	return buf
+}()
+
+func notificationString(typ uint16, s string) []byte {
+	return notificationPart(typ, append([]byte(s), 0))
+}
+
+func notificationInt(typ uint16, v uint64) []byte {
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, v)
+	return notificationPart(typ, payload)
+}
+
+func notificationPart(typ uint16, payload []byte) []byte {
+	part := make([]byte, 4, 4+len(payload))
+	binary.BigEndian.PutUint16(part, typ)
+	binary.BigEndian.PutUint16(part[2:], uint16(4+len(payload)))
+	return append(part, payload...)
+}
@@ -2,10 +2,14 @@
 package collectd // import "github.com/influxdata/influxdb/services/collectd"
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -24,14 +28,38 @@ import (
 
 // statistics gathered by the collectd service.
 const (
-	statPointsReceived       = "pointsRx"
-	statBytesReceived        = "bytesRx"
-	statPointsParseFail      = "pointsParseFail"
-	statReadFail             = "readFail"
-	statBatchesTransmitted   = "batchesTx"
-	statPointsTransmitted    = "pointsTx"
-	statBatchesTransmitFail  = "batchesTxFail"
-	statDroppedPointsInvalid = "droppedPointsInvalid"
+	statPointsReceived         = "pointsRx"
+	statBytesReceived          = "bytesRx"
+	statPointsParseFail        = "pointsParseFail"
+	statReadFail               = "readFail"
+	statBatchesTransmitted     = "batchesTx"
+	statPointsTransmitted      = "pointsTx"
+	statBatchesTransmitFail    = "batchesTxFail"
+	statDroppedPointsInvalid   = "droppedPointsInvalid"
+	statDroppedPacketsInsecure = "droppedPacketsInsecure"
+	statTypesDBReloadFail      = "typesdbReloadFail"
+	statNotificationsReceived  = "notificationsRx"
+)
+
+// typesDBFetchTimeout is the time allowed to fetch a types db from a URL.
+const typesDBFetchTimeout = 30 * time.Second
+
+// Types of the collectd network protocol parts handled by the service.
+const (
+	partTypeHost           = 0x0000
+	partTypeTime           = 0x0001
+	partTypePlugin         = 0x0002
+	partTypePluginInstance = 0x0003
+	partTypeType           = 0x0004
+	partTypeTypeInstance   = 0x0005
+	partTypeValues         = 0x0006
+	partTypeInterval       = 0x0007
+	partTypeTimeHR         = 0x0008
+	partTypeIntervalHR     = 0x0009
+	partTypeMessage        = 0x0100
+	partTypeSeverity       = 0x0101
+	partTypeSignSHA256     = 0x0200
+	partTypeEncryptAES256  = 0x0210
 )
 
 // pointsWriter is an internal interface to make testing easier.
@@ -49,13 +77,29 @@ func TypesDBFile(path string) (typesdb *api.TypesDB, err error) {
 	var reader *os.File
 	reader, err = os.Open(path)
 	if err == nil {
+		defer reader.Close()
 		typesdb, err = api.NewTypesDB(reader)
 	}
 	return
 }
 
-// Service represents a UDP server which receives metrics in collectd's binary
-// protocol and stores them in InfluxDB.
+// TypesDBURL reads a collectd types db from an http or https URL.
+func TypesDBURL(url string) (*api.TypesDB, error) {
+	client := http.Client{Timeout: typesDBFetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch %s: %s", url, resp.Status)
+	}
+	return api.NewTypesDB(resp.Body)
+}
+
+// Service represents a UDP or TCP server which receives metrics in collectd's
+// binary protocol and stores them in InfluxDB.
 type Service struct {
 	Config       *Config
 	MetaClient   metaClient
@@ -64,10 +108,14 @@ type Service struct {
 
 	wg      sync.WaitGroup
 	conn    *net.UDPConn
+	ln      net.Listener
 	batcher *tsdb.PointBatcher
 	popts   network.ParseOpts
 	addr    net.Addr
 
+	tcpConnsMu sync.Mutex
+	tcpConns   map[net.Conn]struct{}
+
 	mu    sync.RWMutex
 	ready bool          // Has the required database been created?
 	done  chan struct{} // Is the service closing or closed?
@@ -112,55 +160,16 @@ func (s *Service) Open() error {
 	}
 
 	if s.popts.TypesDB == nil {
-		// Open collectd types.
-		if stat, err := os.Stat(s.Config.TypesDB); err != nil {
-			return fmt.Errorf("Stat(): %s", err)
-		} else if stat.IsDir() {
-			alltypesdb, err := api.NewTypesDB(&bytes.Buffer{})
-			if err != nil {
-				return err
-			}
-			var readdir func(path string)
-			readdir = func(path string) {
-				files, err := ioutil.ReadDir(path)
-				if err != nil {
-					s.Logger.Info("Unable to read directory",
-						zap.String("path", path), zap.Error(err))
-					return
-				}
-
-				for _, f := range files {
-					fullpath := filepath.Join(path, f.Name())
-					if f.IsDir() {
-						readdir(fullpath)
-						continue
-					}
-
-					s.Logger.Info("Loading types from file", zap.String("path", fullpath))
-					types, err := TypesDBFile(fullpath)
-					if err != nil {
-						s.Logger.Info("Unable to parse collectd types file", zap.String("path", f.Name()))
-						continue
-					}
-
-					alltypesdb.Merge(types)
-				}
-			}
-			readdir(s.Config.TypesDB)
-			s.popts.TypesDB = alltypesdb
-		} else {
-			s.Logger.Info("Loading types from file", zap.String("path", s.Config.TypesDB))
-			types, err := TypesDBFile(s.Config.TypesDB)
-			if err != nil {
-				return fmt.Errorf("Open(): %s", err)
-			}
-			s.popts.TypesDB = types
+		types, err := s.loadTypesDB(s.Config.TypesDB)
+		if err != nil {
+			return fmt.Errorf("Open(): %s", err)
 		}
+		s.popts.TypesDB = types
 	}
 
 	// Sets the security level according to the config.
 	// Default not necessary because we validate the config.
-	switch s.Config.SecurityLevel {
+	switch strings.ToLower(s.Config.SecurityLevel) {
 	case "none":
 		s.popts.SecurityLevel = network.None
 	case "sign":
@@ -169,11 +178,156 @@ func (s *Service) Open() error {
 		s.popts.SecurityLevel = network.Encrypt
 	}
 
-	// Sets the auth file according to the config.
+	// Sets the auth file according to the config. The file is read again
+	// whenever it changes, but it must exist if packets are to be verified.
 	if s.popts.PasswordLookup == nil {
+		if s.popts.SecurityLevel != network.None {
+			if _, err := os.Stat(s.Config.AuthFile); err != nil {
+				return fmt.Errorf("unable to read auth file: %s", err)
+			}
+		}
 		s.popts.PasswordLookup = network.NewAuthFile(s.Config.AuthFile)
 	}
 
+	// Start listening
+	serve := s.serve
+	if strings.ToLower(s.Config.Protocol) == "tcp" {
+		if err := s.openTCPListener(); err != nil {
+			return err
+		}
+		serve = s.serveTCP
+	} else if err := s.openUDPListener(); err != nil {
+		return err
+	}
+
+	// Start the points batcher.
+	s.batcher = tsdb.NewPointBatcher(s.Config.BatchSize, s.Config.BatchPending, time.Duration(s.Config.BatchDuration))
+	s.batcher.Start()
+
+	// Create waitgroup for signalling goroutines to stop and start goroutines
+	// that process collectd packets.
+	s.wg.Add(2)
+	go func() { defer s.wg.Done(); serve() }()
+	go func() { defer s.wg.Done(); s.writePoints() }()
+
+	if interval := time.Duration(s.Config.TypesDBReloadInterval); interval > 0 {
+		s.wg.Add(1)
+		go func() { defer s.wg.Done(); s.reloadTypesDB(interval) }()
+	}
+
+	return nil
+}
+
+// loadTypesDB reads the collectd types db from path, which may name a file, a
+// directory of files, or an http or https URL.
+func (s *Service) loadTypesDB(path string) (*api.TypesDB, error) {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		s.Logger.Info("Loading types from URL", zap.String("url", path))
+		return TypesDBURL(path)
+	}
+
+	// Open collectd types.
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("Stat(): %s", err)
+	} else if !stat.IsDir() {
+		s.Logger.Info("Loading types from file", zap.String("path", path))
+		return TypesDBFile(path)
+	}
+
+	alltypesdb, err := api.NewTypesDB(&bytes.Buffer{})
+	if err != nil {
+		return nil, err
+	}
+	var readdir func(path string)
+	readdir = func(path string) {
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			s.Logger.Info("Unable to read directory",
+				zap.String("path", path), zap.Error(err))
+			return
+		}
+
+		for _, f := range files {
+			fullpath := filepath.Join(path, f.Name())
+			if f.IsDir() {
+				readdir(fullpath)
+				continue
+			}
+
+			s.Logger.Info("Loading types from file", zap.String("path", fullpath))
+			types, err := TypesDBFile(fullpath)
+			if err != nil {
+				s.Logger.Info("Unable to parse collectd types file", zap.String("path", f.Name()))
+				continue
+			}
+
+			alltypesdb.Merge(types)
+		}
+	}
+	readdir(path)
+	return alltypesdb, nil
+}
+
+// reloadTypesDB reloads the types db every interval until the service closes.
+func (s *Service) reloadTypesDB(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			path := s.Config.TypesDB
+			s.mu.RUnlock()
+
+			types, err := s.loadTypesDB(path)
+			if err != nil {
+				atomic.AddInt64(&s.stats.TypesDBReloadFail, 1)
+				s.Logger.Info("Unable to reload collectd types", zap.Error(err))
+				continue
+			}
+			s.setTypesDB(types)
+		}
+	}
+}
+
+// Reload reloads the types db from the location in c, so that new types
+// become parseable without restarting the service.
+func (s *Service) Reload(c Config) error {
+	d := c.WithDefaults()
+	types, err := s.loadTypesDB(d.TypesDB)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.Config.TypesDB = d.TypesDB
+	s.popts.TypesDB = types
+	s.mu.Unlock()
+
+	s.Logger.Info("Reloaded collectd types", zap.String("path", d.TypesDB))
+	return nil
+}
+
+// setTypesDB replaces the types db used to parse packets.
+func (s *Service) setTypesDB(types *api.TypesDB) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.popts.TypesDB = types
+}
+
+// parseOpts returns the options used to parse packets.
+func (s *Service) parseOpts() network.ParseOpts {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.popts
+}
+
+// openUDPListener starts listening for collectd packets over UDP.
+func (s *Service) openUDPListener() error {
 	// Resolve our address.
 	addr, err := net.ResolveUDPAddr("udp", s.Config.BindAddress)
 	if err != nil {
@@ -181,7 +335,6 @@ func (s *Service) Open() error {
 	}
 	s.addr = addr
 
-	// Start listening
 	conn, err := net.ListenUDP("udp", addr)
 	if err != nil {
 		return fmt.Errorf("unable to listen on UDP: %s", err)
@@ -197,17 +350,20 @@ func (s *Service) Open() error {
 	s.conn = conn
 
 	s.Logger.Info("Listening on UDP", zap.Stringer("addr", conn.LocalAddr()))
+	return nil
+}
 
-	// Start the points batcher.
-	s.batcher = tsdb.NewPointBatcher(s.Config.BatchSize, s.Config.BatchPending, time.Duration(s.Config.BatchDuration))
-	s.batcher.Start()
-
-	// Create waitgroup for signalling goroutines to stop and start goroutines
-	// that process collectd packets.
-	s.wg.Add(2)
-	go func() { defer s.wg.Done(); s.serve() }()
-	go func() { defer s.wg.Done(); s.writePoints() }()
+// openTCPListener starts listening for streams of collectd packets over TCP.
+func (s *Service) openTCPListener() error {
+	ln, err := net.Listen("tcp", s.Config.BindAddress)
+	if err != nil {
+		return fmt.Errorf("unable to listen on TCP: %s", err)
+	}
+	s.ln = ln
+	s.addr = ln.Addr()
+	s.tcpConns = make(map[net.Conn]struct{})
 
+	s.Logger.Info("Listening on TCP", zap.Stringer("addr", ln.Addr()))
 	return nil
 }
 
@@ -226,6 +382,10 @@ func (s *Service) Close() error {
 		if s.conn != nil {
 			s.conn.Close()
 		}
+		if s.ln != nil {
+			s.ln.Close()
+			s.closeTCPConnections()
+		}
 		if s.batcher != nil {
 			s.batcher.Stop()
 		}
@@ -242,6 +402,8 @@ func (s *Service) Close() error {
 	defer s.mu.Unlock()
 
 	s.conn = nil
+	s.ln = nil
+	s.tcpConns = nil
 	s.batcher = nil
 	s.Logger.Info("Closed collectd service")
 	s.done = nil
@@ -285,14 +447,17 @@ func (s *Service) WithLogger(log *zap.Logger) {
 
 // Statistics maintains statistics for the collectd service.
 type Statistics struct {
-	PointsReceived       int64
-	BytesReceived        int64
-	PointsParseFail      int64
-	ReadFail             int64
-	BatchesTransmitted   int64
-	PointsTransmitted    int64
-	BatchesTransmitFail  int64
-	InvalidDroppedPoints int64
+	PointsReceived         int64
+	BytesReceived          int64
+	PointsParseFail        int64
+	ReadFail               int64
+	BatchesTransmitted     int64
+	PointsTransmitted      int64
+	BatchesTransmitFail    int64
+	InvalidDroppedPoints   int64
+	InsecureDroppedPackets int64
+	TypesDBReloadFail      int64
+	NotificationsReceived  int64
 }
 
 // Statistics returns statistics for periodic monitoring.
@@ -301,27 +466,36 @@ func (s *Service) Statistics(tags map[string]string) []models.Statistic {
 		Name: "collectd",
 		Tags: s.defaultTags.Merge(tags),
 		Values: map[string]interface{}{
-			statPointsReceived:       atomic.LoadInt64(&s.stats.PointsReceived),
-			statBytesReceived:        atomic.LoadInt64(&s.stats.BytesReceived),
-			statPointsParseFail:      atomic.LoadInt64(&s.stats.PointsParseFail),
-			statReadFail:             atomic.LoadInt64(&s.stats.ReadFail),
-			statBatchesTransmitted:   atomic.LoadInt64(&s.stats.BatchesTransmitted),
-			statPointsTransmitted:    atomic.LoadInt64(&s.stats.PointsTransmitted),
-			statBatchesTransmitFail:  atomic.LoadInt64(&s.stats.BatchesTransmitFail),
-			statDroppedPointsInvalid: atomic.LoadInt64(&s.stats.InvalidDroppedPoints),
+			statPointsReceived:         atomic.LoadInt64(&s.stats.PointsReceived),
+			statBytesReceived:          atomic.LoadInt64(&s.stats.BytesReceived),
+			statPointsParseFail:        atomic.LoadInt64(&s.stats.PointsParseFail),
+			statReadFail:               atomic.LoadInt64(&s.stats.ReadFail),
+			statBatchesTransmitted:     atomic.LoadInt64(&s.stats.BatchesTransmitted),
+			statPointsTransmitted:      atomic.LoadInt64(&s.stats.PointsTransmitted),
+			statBatchesTransmitFail:    atomic.LoadInt64(&s.stats.BatchesTransmitFail),
+			statDroppedPointsInvalid:   atomic.LoadInt64(&s.stats.InvalidDroppedPoints),
+			statDroppedPacketsInsecure: atomic.LoadInt64(&s.stats.InsecureDroppedPackets),
+			statTypesDBReloadFail:      atomic.LoadInt64(&s.stats.TypesDBReloadFail),
+			statNotificationsReceived:  atomic.LoadInt64(&s.stats.NotificationsReceived),
 		},
 	}}
 }
 
 // SetTypes sets collectd types db.
-func (s *Service) SetTypes(types string) (err error) {
-	reader := strings.NewReader(types)
-	s.popts.TypesDB, err = api.NewTypesDB(reader)
-	return
+func (s *Service) SetTypes(types string) error {
+	typesdb, err := api.NewTypesDB(strings.NewReader(types))
+	if err != nil {
+		return err
+	}
+	s.setTypesDB(typesdb)
+	return nil
 }
 
 // Addr returns the listener's address. It returns nil if listener is closed.
 func (s *Service) Addr() net.Addr {
+	if s.ln != nil {
+		return s.ln.Addr()
+	}
 	return s.conn.LocalAddr()
 }
 
@@ -366,8 +540,97 @@ func (s *Service) serve() {
 	}
 }
 
+// serveTCP accepts TCP connections until the listener is closed.
+func (s *Service) serveTCP() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			if opErr, ok := err.(*net.OpError); ok && !opErr.Temporary() {
+				s.Logger.Info("collectd TCP listener closed", zap.Error(err))
+				return
+			}
+			s.Logger.Info("Error accepting TCP connection", zap.Error(err))
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.handleTCPConnection(conn)
+	}
+}
+
+// handleTCPConnection reads a stream of collectd network protocol parts from
+// conn until it is closed.
+func (s *Service) handleTCPConnection(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	if !s.trackTCPConnection(conn) {
+		return
+	}
+	defer s.untrackTCPConnection(conn)
+
+	r := newStreamReader(bufio.NewReader(conn))
+	for {
+		pkt, n, err := r.next()
+		atomic.AddInt64(&s.stats.BytesReceived, int64(n))
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			atomic.AddInt64(&s.stats.ReadFail, 1)
+			s.Logger.Info("Error reading from TCP connection",
+				zap.Stringer("remote_addr", conn.RemoteAddr()), zap.Error(err))
+			return
+		}
+		s.handleMessage(pkt)
+	}
+}
+
+// trackTCPConnection records conn so it can be closed with the service. It
+// returns false if the service is closing.
+func (s *Service) trackTCPConnection(conn net.Conn) bool {
+	s.tcpConnsMu.Lock()
+	defer s.tcpConnsMu.Unlock()
+	if s.closed() {
+		return false
+	}
+	s.tcpConns[conn] = struct{}{}
+	return true
+}
+
+func (s *Service) untrackTCPConnection(conn net.Conn) {
+	s.tcpConnsMu.Lock()
+	defer s.tcpConnsMu.Unlock()
+	delete(s.tcpConns, conn)
+}
+
+// closeTCPConnections closes all open TCP connections.
+func (s *Service) closeTCPConnections() {
+	s.tcpConnsMu.Lock()
+	defer s.tcpConnsMu.Unlock()
+	for conn := range s.tcpConns {
+		conn.Close()
+	}
+}
+
 func (s *Service) handleMessage(buffer []byte) {
-	valueLists, err := network.Parse(buffer, s.popts)
+	if !s.secure(buffer) {
+		atomic.AddInt64(&s.stats.InsecureDroppedPackets, 1)
+		s.Logger.Info("Dropping packet below security level",
+			zap.String("security-level", s.Config.SecurityLevel))
+		return
+	}
+
+	valueLists, err := network.Parse(buffer, s.parseOpts())
 	if err != nil {
 		atomic.AddInt64(&s.stats.PointsParseFail, 1)
 		s.Logger.Info("collectd parse error", zap.Error(err))
@@ -375,16 +638,58 @@ func (s *Service) handleMessage(buffer []byte) {
 	}
 	var points []models.Point
 	for _, valueList := range valueLists {
-		if s.Config.ParseMultiValuePlugin == "join" {
-			points = s.UnmarshalValueListPacked(valueList)
-		} else {
-			points = s.UnmarshalValueList(valueList)
-		}
+		points = s.unmarshal(valueList)
 		for _, p := range points {
 			s.batcher.In() <- p
 		}
 		atomic.AddInt64(&s.stats.PointsReceived, int64(len(points)))
 	}
+
+	if s.Config.NotificationsMeasurement != "" {
+		s.handleNotifications(buffer)
+	}
+}
+
+// handleNotifications writes the notifications in buffer as events.
+func (s *Service) handleNotifications(buffer []byte) {
+	notifications, err := parseNotifications(buffer)
+	if err != nil {
+		atomic.AddInt64(&s.stats.PointsParseFail, 1)
+		s.Logger.Info("collectd notification parse error", zap.Error(err))
+	}
+
+	for _, n := range notifications {
+		p, err := s.unmarshalNotification(n)
+		if err != nil {
+			s.Logger.Info("Dropping notification", zap.String("name", s.Config.NotificationsMeasurement), zap.Error(err))
+			atomic.AddInt64(&s.stats.InvalidDroppedPoints, 1)
+			continue
+		}
+		s.batcher.In() <- p
+		atomic.AddInt64(&s.stats.NotificationsReceived, 1)
+		atomic.AddInt64(&s.stats.PointsReceived, 1)
+	}
+}
+
+// secure returns true if the packet in buffer meets the configured security
+// level. The collectd parser silently skips data below the security level, so
+// packets are checked up front to account for them. Signatures and encrypted
+// data are verified when the packet is parsed.
+func (s *Service) secure(buffer []byte) bool {
+	if s.popts.SecurityLevel == network.None {
+		return true
+	} else if len(buffer) < 2 {
+		return false
+	}
+
+	switch binary.BigEndian.Uint16(buffer) {
+	case partTypeEncryptAES256:
+		return true
+	case partTypeSignSHA256:
+		return s.popts.SecurityLevel == network.Sign
+	default:
+		return false
+	}
 }
 
 func (s *Service) writePoints() {
@@ -412,6 +717,19 @@ func (s *Service) writePoints() {
 	}
 }
 
+// unmarshal translates a ValueList into InfluxDB data points shaped according
+// to the parse-multivalue-plugin option.
+func (s *Service) unmarshal(vl *api.ValueList) []models.Point {
+	switch s.Config.ParseMultiValuePlugin {
+	case "join":
+		return s.UnmarshalValueListPacked(vl)
+	case "tag":
+		return s.UnmarshalValueListTagged(vl)
+	default:
+		return s.UnmarshalValueList(vl)
+	}
+}
+
 // UnmarshalValueListPacked is an alternative to the original UnmarshalValueList.
 // The difference is that the original provided measurements like (PLUGIN_DSNAME, ["value",xxx])
 // while this one will provide measurements like (PLUGIN, {["DSNAME",xxx]}).
@@ -459,6 +777,96 @@ func (s *Service) UnmarshalValueListPacked(vl *api.ValueList) []models.Point {
 	return []models.Point{p}
 }
 
+// unmarshalNotification translates a notification into an event point in the
+// notifications measurement, with the message as its field.
+func (s *Service) unmarshalNotification(n notification) (models.Point, error) {
+	tags := make(map[string]string, 6)
+	if n.Host != "" {
+		tags["host"] = n.Host
+	}
+	if n.Plugin != "" {
+		tags["plugin"] = n.Plugin
+	}
+	if n.PluginInstance != "" {
+		tags["instance"] = n.PluginInstance
+	}
+	if n.Type != "" {
+		tags["type"] = n.Type
+	}
+	if n.TypeInstance != "" {
+		tags["type_instance"] = n.TypeInstance
+	}
+	if n.Severity != "" {
+		tags["severity"] = n.Severity
+	}
+
+	timestamp := n.Time.UTC()
+	if n.Time.IsZero() {
+		timestamp = time.Now().UTC()
+	}
+
+	fields := map[string]interface{}{"message": n.Message}
+	return models.NewPoint(s.Config.NotificationsMeasurement, models.NewTags(tags), fields, timestamp)
+}
+
+// UnmarshalValueListTagged is an alternative to the original UnmarshalValueList.
+// It provides one point per data source in a measurement named after the plugin,
+// and records the data source name of multi-value types in the type_instance
+// tag, appended to any type instance: (df, type_instance=root_used, ["value",1000]).
+// Single-value types keep their type instance.
+func (s *Service) UnmarshalValueListTagged(vl *api.ValueList) []models.Point {
+	timestamp := vl.Time.UTC()
+	name := vl.Identifier.Plugin
+
+	var points []models.Point
+	for i := range vl.Values {
+		tags := make(map[string]string, 4)
+		fields := make(map[string]interface{}, 1)
+
+		// Convert interface back to actual type, then to float64
+		switch value := vl.Values[i].(type) {
+		case api.Gauge:
+			fields["value"] = float64(value)
+		case api.Derive:
+			fields["value"] = float64(value)
+		case api.Counter:
+			fields["value"] = float64(value)
+		}
+
+		if vl.Identifier.Host != "" {
+			tags["host"] = vl.Identifier.Host
+		}
+		if vl.Identifier.PluginInstance != "" {
+			tags["instance"] = vl.Identifier.PluginInstance
+		}
+		if vl.Identifier.Type != "" {
+			tags["type"] = vl.Identifier.Type
+		}
+
+		typeInstance := vl.Identifier.TypeInstance
+		if len(vl.Values) > 1 {
+			if typeInstance != "" {
+				typeInstance += "_"
+			}
+			typeInstance += vl.DSName(i)
+		}
+		if typeInstance != "" {
+			tags["type_instance"] = typeInstance
+		}
+
+		// Drop invalid points
+		p, err := models.NewPoint(name, models.NewTags(tags), fields, timestamp)
+		if err != nil {
+			s.Logger.Info("Dropping point", zap.String("name", name), zap.Error(err))
+			atomic.AddInt64(&s.stats.InvalidDroppedPoints, 1)
+			continue
+		}
+
+		points = append(points, p)
+	}
+	return points
+}
+
 // UnmarshalValueList translates a ValueList into InfluxDB data points.
 func (s *Service) UnmarshalValueList(vl *api.ValueList) []models.Point {
 	timestamp := vl.Time.UTC()
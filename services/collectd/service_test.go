@@ -1,16 +1,24 @@
 package collectd
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"collectd.org/api"
+	"collectd.org/network"
 	"github.com/influxdata/influxdb/internal"
 	"github.com/influxdata/influxdb/logger"
 	"github.com/influxdata/influxdb/models"
@@ -106,6 +114,117 @@ func TestService_Open_TypesDBDir(t *testing.T) {
 	}
 }
 
+func TestService_Open_TypesDBURL(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/types.db" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(typesDBText))
+	}))
+	defer ts.Close()
+
+	s := NewTypesDBTestService(ts.URL+"/types.db", 0)
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	if _, ok := s.Service.parseOpts().TypesDB.DataSet("cpu"); !ok {
+		t.Fatal("expected cpu type to be loaded")
+	}
+
+	// A missing types db fails to open.
+	s = NewTypesDBTestService(ts.URL+"/missing.db", 0)
+	if err := s.Service.Open(); err == nil {
+		t.Fatal("expected error opening missing types db")
+	}
+}
+
+// Test that the types db can be reloaded, both on demand and periodically.
+func TestService_Reload_TypesDB(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, interval := range []time.Duration{0, 10 * time.Millisecond} {
+		func() {
+			typesPath := path.Join(tmpDir, fmt.Sprintf("types-%d.db", interval))
+			if err := ioutil.WriteFile(typesPath, []byte("entropy value:GAUGE:0:U\n"), 0666); err != nil {
+				t.Fatal(err)
+			}
+
+			s := NewTypesDBTestService(typesPath, interval)
+			if err := s.Service.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer s.Service.Close()
+
+			if _, ok := s.Service.parseOpts().TypesDB.DataSet("cpu"); ok {
+				t.Fatal("unexpected cpu type")
+			}
+
+			if err := ioutil.WriteFile(typesPath, []byte(typesDBText), 0666); err != nil {
+				t.Fatal(err)
+			}
+			if interval == 0 {
+				if err := s.Service.Reload(s.Config); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			timeout := time.After(5 * time.Second)
+			for {
+				if _, ok := s.Service.parseOpts().TypesDB.DataSet("cpu"); ok {
+					break
+				}
+				select {
+				case <-timeout:
+					t.Fatalf("interval %s: timed out waiting for types db to reload", interval)
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		}()
+	}
+}
+
+// NewTypesDBTestService returns a test service that reads its types db from
+// typesDB, reloading it every interval.
+func NewTypesDBTestService(typesDB string, interval time.Duration) *TestService {
+	c := Config{
+		BindAddress:           "127.0.0.1:0",
+		Database:              "collectd_test",
+		BatchSize:             1000,
+		BatchDuration:         toml.Duration(time.Second),
+		TypesDB:               typesDB,
+		TypesDBReloadInterval: toml.Duration(interval),
+	}
+
+	s := &TestService{
+		Config:     c,
+		Service:    NewService(c),
+		MetaClient: &internal.MetaClientMock{},
+	}
+
+	if testing.Verbose() {
+		s.Service.WithLogger(logger.New(os.Stderr))
+	}
+
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	s.Service.PointsWriter = s
+	s.Service.MetaClient = s.MetaClient
+	return s
+}
+
 // Test that the service checks / creates the target database every time we
 // try to write points.
 func TestService_CreatesDatabase(t *testing.T) {
@@ -386,6 +505,312 @@ Loop:
 	}
 }
 
+// Test that the service accepts collectd packets over TCP.
+func TestService_TCP(t *testing.T) {
+	t.Parallel()
+
+	totalPoints := len(expPoints)
+
+	s := NewTestService(5000, 250*time.Millisecond, "split")
+	s.Service.Config.Protocol = "tcp"
+
+	pointCh := make(chan models.Point, 1000)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		for _, p := range points {
+			pointCh <- p
+		}
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { t.Log("closing service"); s.Service.Close() }()
+
+	conn, err := net.Dial("tcp", s.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Send the test data to the service in two halves, splitting a part.
+	for _, buf := range [][]byte{testData[:100], testData[100:]} {
+		if _, err := conn.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var points []models.Point
+	timer := time.NewTimer(time.Second)
+Loop:
+	for {
+		timer.Reset(time.Second)
+		select {
+		case p := <-pointCh:
+			points = append(points, p)
+			if len(points) == totalPoints {
+				break Loop
+			}
+		case <-timer.C:
+			t.Logf("exp %d points, got %d", totalPoints, len(points))
+			t.Fatal("timed out waiting for points from collectd service")
+		}
+	}
+
+	for i, exp := range expPoints {
+		got := points[i].String()
+		if got != exp {
+			t.Fatalf("\n\texp = %s\n\tgot = %s\n", exp, got)
+		}
+	}
+
+	if got, exp := atomic.LoadInt64(&s.Service.stats.BytesReceived), int64(len(testData)); got != exp {
+		t.Fatalf("got %d bytes received, expected %d", got, exp)
+	}
+}
+
+// Test that notifications are written to the notifications measurement.
+func TestService_Notifications(t *testing.T) {
+	t.Parallel()
+
+	s := NewTestService(1, time.Second, "split")
+	s.Service.Config.NotificationsMeasurement = "collectd_events"
+
+	pointCh := make(chan models.Point, 10)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		for _, p := range points {
+			pointCh <- p
+		}
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn, err := net.Dial("udp", s.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(testNotificationData); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, exp := range []string{
+		`collectd_events,host=server01,plugin=df,severity=warning,type=df,type_instance=root message="Disk almost full" 1414080767000000000`,
+		`collectd_events,host=server01,plugin=df,severity=okay,type=df,type_instance=root message="Disk usage normal" 1414080767000000000`,
+	} {
+		select {
+		case p := <-pointCh:
+			if got := p.String(); got != exp {
+				t.Fatalf("\n\texp = %s\n\tgot = %s\n", exp, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for points from collectd service")
+		}
+	}
+
+	if got, exp := atomic.LoadInt64(&s.Service.stats.NotificationsReceived), int64(2); got != exp {
+		t.Fatalf("got %d notifications, expected %d", got, exp)
+	}
+}
+
+// Test that packets below the configured security level are dropped, and that
+// signed and encrypted packets are verified against the auth file.
+func TestService_SecurityLevel(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, err := ioutil.TempDir("", "collectd-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	authFile := path.Join(tmpDir, "auth_file")
+	if err := ioutil.WriteFile(authFile, []byte("alice: w0nderl4nd\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range []network.SecurityLevel{network.Sign, network.Encrypt} {
+		func() {
+			s := NewTestService(1, time.Second, "split")
+			s.Service.Config.AuthFile = authFile
+			if level == network.Sign {
+				s.Service.Config.SecurityLevel = "sign"
+			} else {
+				s.Service.Config.SecurityLevel = "encrypt"
+			}
+
+			pointCh := make(chan models.Point, 10)
+			s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+				for _, p := range points {
+					pointCh <- p
+				}
+				return nil
+			}
+
+			if err := s.Service.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer s.Service.Close()
+
+			conn, err := net.Dial("udp", s.Service.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			// Only the last packet carries the configured security level
+			// and the right password.
+			for _, pkt := range [][]byte{
+				securePacket(t, network.None, "", ""),
+				securePacket(t, level, "alice", "wrong"),
+				securePacket(t, level, "bob", "w0nderl4nd"),
+				securePacket(t, level, "alice", "w0nderl4nd"),
+			} {
+				if _, err := conn.Write(pkt); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			select {
+			case p := <-pointCh:
+				if got, exp := p.String(), "memory_value,host=server01,type=bytes value=42 1414080767000000000"; got != exp {
+					t.Fatalf("\n\texp = %s\n\tgot = %s\n", exp, got)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for points from collectd service")
+			}
+
+			if got, exp := atomic.LoadInt64(&s.Service.stats.InsecureDroppedPackets), int64(1); got != exp {
+				t.Fatalf("got %d insecure packets, expected %d", got, exp)
+			} else if got, exp := atomic.LoadInt64(&s.Service.stats.PointsParseFail), int64(2); got != exp {
+				t.Fatalf("got %d parse failures, expected %d", got, exp)
+			}
+		}()
+	}
+}
+
+// securePacket returns a packet holding a single value list, signed or
+// encrypted with the given credentials according to level.
+func securePacket(t *testing.T, level network.SecurityLevel, username, password string) []byte {
+	b := network.NewBuffer(0)
+	switch level {
+	case network.Sign:
+		b.Sign(username, password)
+	case network.Encrypt:
+		b.Encrypt(username, password)
+	default:
+	}
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{Host: "server01", Plugin: "memory", Type: "bytes"},
+		Time:       time.Unix(1414080767, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Gauge(42)},
+	}
+	if err := b.Write(context.Background(), vl); err != nil {
+		t.Fatal(err)
+	}
+
+	pkt, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkt
+}
+
+// Test how each parse-multivalue-plugin option shapes common types.
+func TestService_UnmarshalValueList_MultiValue(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Unix(1414080767, 0)
+	valueLists := []*api.ValueList{
+		{
+			Identifier: api.Identifier{Host: "server01", Plugin: "df", Type: "df", TypeInstance: "root"},
+			Time:       ts,
+			Values:     []api.Value{api.Gauge(1000), api.Gauge(2500)},
+			DSNames:    []string{"used", "free"},
+		},
+		{
+			Identifier: api.Identifier{Host: "server01", Plugin: "interface", PluginInstance: "eth0", Type: "if_octets"},
+			Time:       ts,
+			Values:     []api.Value{api.Derive(10), api.Derive(20)},
+			DSNames:    []string{"rx", "tx"},
+		},
+		{
+			Identifier: api.Identifier{Host: "server01", Plugin: "load", Type: "load"},
+			Time:       ts,
+			Values:     []api.Value{api.Gauge(1), api.Gauge(2), api.Gauge(3)},
+			DSNames:    []string{"shortterm", "midterm", "longterm"},
+		},
+		{
+			Identifier: api.Identifier{Host: "server01", Plugin: "cpu", PluginInstance: "0", Type: "cpu", TypeInstance: "idle"},
+			Time:       ts,
+			Values:     []api.Value{api.Derive(42)},
+			DSNames:    []string{"value"},
+		},
+	}
+
+	for _, test := range []struct {
+		option string
+		exp    []string
+	}{
+		{
+			option: "split",
+			exp: []string{
+				"df_used,host=server01,type=df,type_instance=root value=1000 1414080767000000000",
+				"df_free,host=server01,type=df,type_instance=root value=2500 1414080767000000000",
+				"interface_rx,host=server01,instance=eth0,type=if_octets value=10 1414080767000000000",
+				"interface_tx,host=server01,instance=eth0,type=if_octets value=20 1414080767000000000",
+				"load_shortterm,host=server01,type=load value=1 1414080767000000000",
+				"load_midterm,host=server01,type=load value=2 1414080767000000000",
+				"load_longterm,host=server01,type=load value=3 1414080767000000000",
+				"cpu_value,host=server01,instance=0,type=cpu,type_instance=idle value=42 1414080767000000000",
+			},
+		},
+		{
+			option: "join",
+			exp: []string{
+				"df,host=server01,type=df,type_instance=root free=2500,used=1000 1414080767000000000",
+				"interface,host=server01,instance=eth0,type=if_octets rx=10,tx=20 1414080767000000000",
+				"load,host=server01,type=load longterm=3,midterm=2,shortterm=1 1414080767000000000",
+				"cpu,host=server01,instance=0,type=cpu,type_instance=idle value=42 1414080767000000000",
+			},
+		},
+		{
+			option: "tag",
+			exp: []string{
+				"df,host=server01,type=df,type_instance=root_used value=1000 1414080767000000000",
+				"df,host=server01,type=df,type_instance=root_free value=2500 1414080767000000000",
+				"interface,host=server01,instance=eth0,type=if_octets,type_instance=rx value=10 1414080767000000000",
+				"interface,host=server01,instance=eth0,type=if_octets,type_instance=tx value=20 1414080767000000000",
+				"load,host=server01,type=load,type_instance=shortterm value=1 1414080767000000000",
+				"load,host=server01,type=load,type_instance=midterm value=2 1414080767000000000",
+				"load,host=server01,type=load,type_instance=longterm value=3 1414080767000000000",
+				"cpu,host=server01,instance=0,type=cpu,type_instance=idle value=42 1414080767000000000",
+			},
+		},
+	} {
+		s := NewService(Config{ParseMultiValuePlugin: test.option})
+
+		var got []string
+		for _, vl := range valueLists {
+			for _, p := range s.unmarshal(vl) {
+				got = append(got, p.String())
+			}
+		}
+
+		if !reflect.DeepEqual(got, test.exp) {
+			t.Fatalf("%s:\n\texp = %s\n\tgot = %s\n", test.option, strings.Join(test.exp, "\n\t      "), strings.Join(got, "\n\t      "))
+		}
+	}
+}
+
 type TestService struct {
 	Service       *Service
 	Config        Config
@@ -0,0 +1,105 @@
+package collectd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// errSignedStream is returned when a signed part is read from a stream.
+var errSignedStream = errors.New("signed parts are not supported over TCP")
+
+// streamReader splits a stream of collectd network protocol parts, as sent
+// over TCP, into packets that can be parsed on their own.
+//
+// Parts other than values and message parts set state, such as the host or
+// time, for the values and notifications that follow them. The latest part of
+// each type is kept and prepended to every values and message part. collectd starts every packet with a host
+// part, so a host part clears the state, and packets written back to back
+// parse as they would on their own. Encrypted parts hold a complete packet
+// and are returned as is. Signed parts cover the rest of the packet they
+// start, which has no end on a stream, so they are rejected.
+type streamReader struct {
+	r     io.Reader
+	state map[uint16][]byte
+}
+
+// newStreamReader returns a streamReader reading from r.
+func newStreamReader(r io.Reader) *streamReader {
+	return &streamReader{r: r, state: make(map[uint16][]byte)}
+}
+
+// next returns the next packet along with the number of bytes read from the
+// stream to produce it. It returns io.EOF if the stream ends between parts.
+func (r *streamReader) next() ([]byte, int, error) {
+	var n int
+	for {
+		part, err := r.readPart()
+		n += len(part)
+		if err != nil {
+			return nil, n, err
+		}
+
+		typ := binary.BigEndian.Uint16(part)
+		switch typ {
+		case partTypeValues, partTypeMessage:
+			return r.packet(part), n, nil
+		case partTypeEncryptAES256:
+			return part, n, nil
+		case partTypeSignSHA256:
+			return nil, n, errSignedStream
+		case partTypeHost:
+			r.state = make(map[uint16][]byte)
+		case partTypeTime, partTypeTimeHR:
+			delete(r.state, partTypeTime)
+			delete(r.state, partTypeTimeHR)
+		case partTypeInterval, partTypeIntervalHR:
+			delete(r.state, partTypeInterval)
+			delete(r.state, partTypeIntervalHR)
+		default:
+		}
+		r.state[typ] = part
+	}
+}
+
+// readPart reads a single part, including its header, from the stream.
+func (r *streamReader) readPart() ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		return nil, err
+	}
+
+	length := int(binary.BigEndian.Uint16(hdr[2:]))
+	if length < len(hdr) {
+		return nil, fmt.Errorf("invalid part length %d", length)
+	}
+
+	part := make([]byte, length)
+	copy(part, hdr[:])
+	if _, err := io.ReadFull(r.r, part[len(hdr):]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return part, nil
+}
+
+// packet returns a packet holding the current state and the values part.
+func (r *streamReader) packet(values []byte) []byte {
+	types := make([]int, 0, len(r.state))
+	size := len(values)
+	for typ, part := range r.state {
+		types = append(types, int(typ))
+		size += len(part)
+	}
+	sort.Ints(types)
+
+	pkt := make([]byte, 0, size)
+	for _, typ := range types {
+		pkt = append(pkt, r.state[uint16(typ)]...)
+	}
+	return append(pkt, values...)
+}
@@ -0,0 +1,87 @@
+package collectd
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"collectd.org/api"
+	"collectd.org/network"
+)
+
+// Test that a packet split into parts by a streamReader parses into the same
+// value lists as the packet itself.
+func TestStreamReader(t *testing.T) {
+	exp, err := network.Parse(testData, network.ParseOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Send the packet twice to check that it parses the same way both times.
+	r := newStreamReader(bytes.NewReader(append(append([]byte{}, testData...), testData...)))
+
+	var (
+		got   []*api.ValueList
+		total int
+	)
+	for {
+		pkt, n, err := r.next()
+		total += n
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		vls, err := network.Parse(pkt, network.ParseOpts{})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(vls) != 1 {
+			t.Fatalf("got %d value lists in packet, expected 1", len(vls))
+		}
+		got = append(got, vls...)
+	}
+
+	if total != 2*len(testData) {
+		t.Fatalf("read %d bytes, expected %d", total, 2*len(testData))
+	} else if len(got) != 2*len(exp) {
+		t.Fatalf("got %d value lists, expected %d", len(got), 2*len(exp))
+	}
+	for i := range got {
+		if !reflect.DeepEqual(got[i], exp[i%len(exp)]) {
+			t.Fatalf("value list %d:\n\texp = %v\n\tgot = %v\n", i, exp[i%len(exp)], got[i])
+		}
+	}
+}
+
+func TestStreamReader_Errors(t *testing.T) {
+	signed := securePacket(t, network.Sign, "alice", "w0nderl4nd")
+	encrypted := securePacket(t, network.Encrypt, "alice", "w0nderl4nd")
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "truncated header", data: []byte{0x00, 0x06, 0x00}, err: io.ErrUnexpectedEOF},
+		{name: "truncated part", data: testData[:10], err: io.ErrUnexpectedEOF},
+		{name: "invalid length", data: []byte{0x00, 0x06, 0x00, 0x02}},
+		{name: "signed", data: signed, err: errSignedStream},
+	} {
+		_, _, err := newStreamReader(bytes.NewReader(test.data)).next()
+		if err == nil || err == io.EOF {
+			t.Fatalf("%s: expected error, got %v", test.name, err)
+		} else if test.err != nil && err != test.err {
+			t.Fatalf("%s: got %v, expected %v", test.name, err, test.err)
+		}
+	}
+
+	// Encrypted parts are passed on as is.
+	pkt, _, err := newStreamReader(bytes.NewReader(encrypted)).next()
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(pkt, encrypted) {
+		t.Fatal("encrypted packet was modified")
+	}
+}
@@ -38,6 +38,10 @@ const (
 type ContinuousQuerier interface {
 	// Run executes the named query in the named database.  Blank database or name matches all.
 	Run(database, name string, t time.Time) error
+
+	// LastRun returns the time the named query last ran, and the error of
+	// its last execution.
+	LastRun(database, name string) (time.Time, error)
 }
 
 // metaClient is an internal interface to make testing easier.
@@ -95,8 +99,10 @@ type Service struct {
 	// lastRuns maps CQ name to last time it was run.
 	mu       sync.RWMutex
 	lastRuns map[string]time.Time
-	stop     chan struct{}
-	wg       *sync.WaitGroup
+	// lastErrors maps CQ name to the error of its last execution.
+	lastErrors map[string]error
+	stop       chan struct{}
+	wg         *sync.WaitGroup
 }
 
 // NewService returns a new instance of Service.
@@ -111,6 +117,7 @@ func NewService(c Config) *Service {
 		Logger:            zap.NewNop(),
 		stats:             &Statistics{},
 		lastRuns:          map[string]time.Time{},
+		lastErrors:        map[string]error{},
 	}
 
 	return s
@@ -205,6 +212,16 @@ func (s *Service) Run(database, name string, t time.Time) error {
 	return nil
 }
 
+// LastRun returns the time the continuous query last ran, or the zero time if
+// it has not run since the service started, and the error of its last
+// execution.
+func (s *Service) LastRun(database, name string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id := fmt.Sprintf("%s%s%s", database, idDelimiter, name)
+	return s.lastRuns[id], s.lastErrors[id]
+}
+
 // backgroundLoop runs on a go routine and periodically executes CQs.
 func (s *Service) backgroundLoop() {
 	leaseName := "continuous_querier"
@@ -261,11 +278,18 @@ func (s *Service) runContinuousQueries(req *RunRequest) {
 			if !req.matches(&cq) {
 				continue
 			}
+			id := fmt.Sprintf("%s%s%s", db.Name, idDelimiter, cq.Name)
 			if ok, err := s.ExecuteContinuousQuery(&db, &cq, req.Now); err != nil {
 				s.Logger.Info("Error executing query", zap.String("query", cq.Query), zap.Error(err))
 				atomic.AddInt64(&s.stats.QueryFail, 1)
+				s.mu.Lock()
+				s.lastErrors[id] = err
+				s.mu.Unlock()
 			} else if ok {
 				atomic.AddInt64(&s.stats.QueryOK, 1)
+				s.mu.Lock()
+				delete(s.lastErrors, id)
+				s.mu.Unlock()
 			}
 		}
 	}
@@ -431,6 +455,7 @@ func (s *Service) runContinuousQueryAndWriteResult(cq *ContinuousQuery) *query.R
 	// Execute the SELECT.
 	ch := s.QueryExecutor.ExecuteQuery(q, query.ExecutionOptions{
 		Database: cq.Database,
+		Class:    query.BatchQuery,
 	}, closing)
 
 	// There is only one statement, so we will only ever receive one result
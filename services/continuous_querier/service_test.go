@@ -607,6 +607,37 @@ func TestExecuteContinuousQuery_QueryExecutor_Error(t *testing.T) {
 	}
 }
 
+// Test the last run and error of CQs are reported.
+func TestContinuousQueryService_LastRun(t *testing.T) {
+	s := NewTestService(t)
+	s.QueryExecutor.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+			return errExpected
+		},
+	}
+
+	if lastRun, err := s.LastRun("db", "cq"); !lastRun.IsZero() || err != nil {
+		t.Fatalf("unexpected last run: %s, %v", lastRun, err)
+	}
+
+	now := time.Now().Truncate(10 * time.Minute)
+	s.runContinuousQueries(&RunRequest{Now: now, CQs: []string{"cq"}})
+	if lastRun, err := s.LastRun("db", "cq"); !lastRun.Equal(now) || err != errExpected {
+		t.Fatalf("unexpected last run: %s, %v", lastRun, err)
+	}
+
+	s.QueryExecutor.StatementExecutor = &StatementExecutor{
+		ExecuteStatementFn: func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+			ctx.Results <- &query.Result{}
+			return nil
+		},
+	}
+	s.runContinuousQueries(&RunRequest{Now: now.Add(time.Second), CQs: []string{"cq"}})
+	if lastRun, err := s.LastRun("db", "cq"); !lastRun.Equal(now.Add(time.Second)) || err != nil {
+		t.Fatalf("unexpected last run: %s, %v", lastRun, err)
+	}
+}
+
 func TestService_ExecuteContinuousQuery_LogsToMonitor(t *testing.T) {
 	s := NewTestService(t)
 	const writeN = int64(50)
@@ -0,0 +1,138 @@
+package graphite
+
+import (
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// aggregateKey identifies the points combined into a single aggregate: the
+// points of a series and field, written to the same target, whose
+// timestamps fall into the same window.
+type aggregateKey struct {
+	target target
+	series string
+	field  string
+	start  int64
+}
+
+// aggregate holds the running aggregates of the points for an aggregateKey.
+type aggregate struct {
+	name    string
+	tags    models.Tags
+	arrived time.Time
+
+	count               int
+	sum, min, max, last float64
+}
+
+// aggregatedPoint is a point emitted by an aggregator along with its target.
+type aggregatedPoint struct {
+	target target
+	point  models.Point
+}
+
+// aggregator combines the points of a series received within a window into
+// a single point, timestamped with the start of the window, using one of the
+// Aggregate* functions.
+type aggregator struct {
+	mu         sync.Mutex
+	window     time.Duration
+	fn         string
+	aggregates map[aggregateKey]*aggregate
+}
+
+// newAggregator returns an aggregator for the given window and function.
+func newAggregator(window time.Duration, fn string) *aggregator {
+	return &aggregator{
+		window:     window,
+		fn:         fn,
+		aggregates: make(map[aggregateKey]*aggregate),
+	}
+}
+
+// add adds p to the aggregates for t. It returns false, leaving p to be
+// written as is, unless p has a single float field.
+func (a *aggregator) add(t target, p models.Point, now time.Time) bool {
+	fields, err := p.Fields()
+	if err != nil || len(fields) != 1 {
+		return false
+	}
+
+	var (
+		field string
+		v     float64
+	)
+	for k, fv := range fields {
+		f, ok := fv.(float64)
+		if !ok {
+			return false
+		}
+		field, v = k, f
+	}
+
+	key := aggregateKey{
+		target: t,
+		series: string(p.Key()),
+		field:  field,
+		start:  p.Time().Truncate(a.window).UnixNano(),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	agg := a.aggregates[key]
+	if agg == nil {
+		agg = &aggregate{name: string(p.Name()), tags: p.Tags(), arrived: now, min: v, max: v}
+		a.aggregates[key] = agg
+	}
+	agg.count++
+	agg.sum += v
+	agg.last = v
+	if v < agg.min {
+		agg.min = v
+	}
+	if v > agg.max {
+		agg.max = v
+	}
+	return true
+}
+
+// flush removes and returns the aggregates that have been collecting points
+// for at least one window as of now.
+func (a *aggregator) flush(now time.Time) []aggregatedPoint {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var points []aggregatedPoint
+	for key, agg := range a.aggregates {
+		if now.Sub(agg.arrived) < a.window {
+			continue
+		}
+		delete(a.aggregates, key)
+
+		p, err := models.NewPoint(agg.name, agg.tags, models.Fields{key.field: agg.value(a.fn)}, time.Unix(0, key.start))
+		if err != nil {
+			continue
+		}
+		points = append(points, aggregatedPoint{target: key.target, point: p})
+	}
+	return points
+}
+
+// value returns the aggregate computed by fn.
+func (agg *aggregate) value(fn string) float64 {
+	switch fn {
+	case AggregateSum:
+		return agg.sum
+	case AggregateMin:
+		return agg.min
+	case AggregateMax:
+		return agg.max
+	case AggregateLast:
+		return agg.last
+	default:
+		return agg.sum / float64(agg.count)
+	}
+}
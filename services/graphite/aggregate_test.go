@@ -0,0 +1,69 @@
+package graphite
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+func TestAggregator(t *testing.T) {
+	for _, test := range []struct {
+		fn  string
+		exp []string
+	}{
+		{fn: AggregateAvg, exp: []string{"cpu,host=a value=2 0", "cpu,host=a value=5 10000000000", "cpu,host=b value=4 0"}},
+		{fn: AggregateSum, exp: []string{"cpu,host=a value=6 0", "cpu,host=a value=5 10000000000", "cpu,host=b value=4 0"}},
+		{fn: AggregateMin, exp: []string{"cpu,host=a value=1 0", "cpu,host=a value=5 10000000000", "cpu,host=b value=4 0"}},
+		{fn: AggregateMax, exp: []string{"cpu,host=a value=3 0", "cpu,host=a value=5 10000000000", "cpu,host=b value=4 0"}},
+		{fn: AggregateLast, exp: []string{"cpu,host=a value=3 0", "cpu,host=a value=5 10000000000", "cpu,host=b value=4 0"}},
+	} {
+		a := newAggregator(10*time.Second, test.fn)
+		now := time.Unix(100, 0)
+
+		points, err := models.ParsePointsString(`cpu,host=a value=1 1000000000
+cpu,host=a value=2 2000000000
+cpu,host=a value=3 9000000000
+cpu,host=a value=5 11000000000
+cpu,host=b value=4 3000000000
+cpu,host=a value="x" 3000000000`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, p := range points {
+			if got, exp := a.add(target{database: "db"}, p, now), i < 5; got != exp {
+				t.Fatalf("%s: point %d: got aggregated %v, expected %v", test.fn, i, got, exp)
+			}
+		}
+
+		// Nothing is emitted until the aggregates are a window old.
+		if got := a.flush(now.Add(5 * time.Second)); len(got) != 0 {
+			t.Fatalf("%s: unexpected points flushed: %v", test.fn, got)
+		}
+
+		var got []string
+		for _, p := range a.flush(now.Add(10 * time.Second)) {
+			if p.target.database != "db" {
+				t.Fatalf("%s: unexpected target: %v", test.fn, p.target)
+			}
+			got = append(got, p.point.String())
+		}
+		sort.Strings(got)
+		sort.Strings(test.exp)
+
+		if len(got) != len(test.exp) {
+			t.Fatalf("%s: got %v, expected %v", test.fn, got, test.exp)
+		}
+		for i := range got {
+			if got[i] != test.exp[i] {
+				t.Fatalf("%s: got %v, expected %v", test.fn, got, test.exp)
+			}
+		}
+
+		if got := a.flush(now.Add(time.Hour)); len(got) != 0 {
+			t.Fatalf("%s: unexpected points flushed: %v", test.fn, got)
+		}
+	}
+}
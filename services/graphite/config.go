@@ -36,6 +36,20 @@ const (
 	// DefaultBatchTimeout is the default Graphite batch timeout.
 	DefaultBatchTimeout = time.Second
 
+	// DefaultNonFiniteValues is the default handling of NaN and infinite values.
+	DefaultNonFiniteValues = NonFiniteDrop
+
+	// DefaultAggregationFunction is the default function used to aggregate
+	// points within the aggregation window.
+	DefaultAggregationFunction = AggregateAvg
+
+	// DefaultCompression is the default compression of UDP payloads.
+	DefaultCompression = CompressionNone
+
+	// DefaultCertificate is the default location of the certificate used when
+	// TLS is enabled.
+	DefaultCertificate = "/etc/ssl/influxdb.pem"
+
 	// DefaultUDPReadBuffer is the default buffer size for the UDP listener.
 	// Sets the size of the operating system's receive buffer associated with
 	// the UDP traffic. Keep in mind that the OS must be able
@@ -50,6 +64,42 @@ const (
 	DefaultUDPReadBuffer = 0
 )
 
+const (
+	// NonFiniteDrop drops points with NaN or infinite values.
+	NonFiniteDrop = "drop"
+
+	// NonFiniteSentinel replaces NaN and infinite values with the configured
+	// sentinel value.
+	NonFiniteSentinel = "sentinel"
+
+	// NonFiniteNull leaves the value field of points with NaN or infinite
+	// values unset, and writes the original value as a string to a field
+	// named after the value field with a "_non_finite" suffix.
+	NonFiniteNull = "null"
+)
+
+const (
+	// CompressionNone expects UDP payloads to be uncompressed.
+	CompressionNone = "none"
+
+	// CompressionAuto decompresses gzip compressed UDP payloads, detected by
+	// their magic number, and accepts uncompressed payloads as-is.
+	CompressionAuto = "auto"
+
+	// CompressionGzip expects every UDP payload to be gzip compressed.
+	CompressionGzip = "gzip"
+)
+
+// Functions used to aggregate the points of a series within the aggregation
+// window.
+const (
+	AggregateAvg  = "avg"
+	AggregateSum  = "sum"
+	AggregateMin  = "min"
+	AggregateMax  = "max"
+	AggregateLast = "last"
+)
+
 // Config represents the configuration for Graphite endpoints.
 type Config struct {
 	Enabled          bool          `toml:"enabled"`
@@ -65,6 +115,38 @@ type Config struct {
 	Tags             []string      `toml:"tags"`
 	Separator        string        `toml:"separator"`
 	UDPReadBuffer    int           `toml:"udp-read-buffer"`
+
+	// MaxConnections limits the number of concurrent TCP connections, if
+	// set. IdleTimeout closes TCP connections on which no line is started
+	// for that long, and ReadTimeout those on which a started line is not
+	// completed within that time.
+	MaxConnections int           `toml:"max-connections"`
+	IdleTimeout    toml.Duration `toml:"idle-timeout"`
+	ReadTimeout    toml.Duration `toml:"read-timeout"`
+
+	// Compression selects how UDP payloads are compressed. A compressed
+	// payload may hold many metrics, one per line.
+	Compression string `toml:"compression"`
+
+	// NonFiniteValues selects how NaN and infinite values are handled.
+	// NonFiniteSentinel is the value written in their place if
+	// NonFiniteValues is "sentinel".
+	NonFiniteValues   string  `toml:"non-finite-values"`
+	NonFiniteSentinel float64 `toml:"non-finite-sentinel"`
+
+	// AggregationWindow, if set, enables combining the points of a series
+	// whose timestamps fall into the same window of that length into a
+	// single point, using AggregationFunction.
+	AggregationWindow   toml.Duration `toml:"aggregation-window"`
+	AggregationFunction string        `toml:"aggregation-function"`
+
+	// TLS settings of the TCP listener. PrivateKey defaults to Certificate.
+	// If CACertificate is set, clients must present a certificate signed by
+	// one of its certificate authorities.
+	TLSEnabled    bool   `toml:"tls-enabled"`
+	Certificate   string `toml:"certificate"`
+	PrivateKey    string `toml:"private-key"`
+	CACertificate string `toml:"ca-certificate"`
 }
 
 // NewConfig returns a new instance of Config with defaults.
@@ -78,6 +160,11 @@ func NewConfig() Config {
 		BatchTimeout:     toml.Duration(DefaultBatchTimeout),
 		ConsistencyLevel: DefaultConsistencyLevel,
 		Separator:        DefaultSeparator,
+		NonFiniteValues:  DefaultNonFiniteValues,
+		Compression:      DefaultCompression,
+		Certificate:      DefaultCertificate,
+
+		AggregationFunction: DefaultAggregationFunction,
 	}
 }
 
@@ -112,6 +199,18 @@ func (c *Config) WithDefaults() *Config {
 	if d.UDPReadBuffer == 0 {
 		d.UDPReadBuffer = DefaultUDPReadBuffer
 	}
+	if d.NonFiniteValues == "" {
+		d.NonFiniteValues = DefaultNonFiniteValues
+	}
+	if d.Compression == "" {
+		d.Compression = DefaultCompression
+	}
+	if d.AggregationFunction == "" {
+		d.AggregationFunction = DefaultAggregationFunction
+	}
+	if d.Certificate == "" {
+		d.Certificate = DefaultCertificate
+	}
 	return &d
 }
 
@@ -135,6 +234,40 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	switch c.NonFiniteValues {
+	case "", NonFiniteDrop, NonFiniteSentinel, NonFiniteNull:
+	default:
+		return fmt.Errorf(`invalid non-finite-values %q. Valid options are "drop", "sentinel" and "null"`, c.NonFiniteValues)
+	}
+
+	switch c.Compression {
+	case "", CompressionNone, CompressionAuto, CompressionGzip:
+	default:
+		return fmt.Errorf(`invalid compression %q. Valid options are "none", "auto" and "gzip"`, c.Compression)
+	}
+
+	if c.MaxConnections < 0 {
+		return fmt.Errorf("max-connections must not be negative")
+	} else if c.IdleTimeout < 0 {
+		return fmt.Errorf("idle-timeout must not be negative")
+	} else if c.ReadTimeout < 0 {
+		return fmt.Errorf("read-timeout must not be negative")
+	}
+
+	if c.AggregationWindow < 0 {
+		return fmt.Errorf("aggregation-window must not be negative")
+	}
+
+	switch c.AggregationFunction {
+	case "", AggregateAvg, AggregateSum, AggregateMin, AggregateMax, AggregateLast:
+	default:
+		return fmt.Errorf(`invalid aggregation-function %q. Valid options are "avg", "sum", "min", "max" and "last"`, c.AggregationFunction)
+	}
+
+	if c.TLSEnabled && strings.ToLower(c.Protocol) == "udp" {
+		return fmt.Errorf("tls-enabled is only supported with the tcp protocol")
+	}
+
 	return nil
 }
 
@@ -143,7 +276,10 @@ func (c *Config) validateTemplates() error {
 	filters := map[string]struct{}{}
 
 	for i, t := range c.Templates {
-		parts := strings.Fields(t)
+		parts, _, _, err := splitTarget(strings.Fields(t))
+		if err != nil {
+			return err
+		}
 		// Ensure template string is non-empty
 		if len(parts) == 0 {
 			return fmt.Errorf("missing template at position: %d", i)
@@ -156,7 +292,7 @@ func (c *Config) validateTemplates() error {
 			return fmt.Errorf("invalid template format: '%s'", t)
 		}
 
-		template := t
+		template := parts[0]
 		filter := ""
 		tags := ""
 		if len(parts) >= 2 {
@@ -261,7 +397,7 @@ type Configs []Config
 // Diagnostics returns one set of diagnostics for all of the Configs.
 func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
 	d := &diagnostics.Diagnostics{
-		Columns: []string{"enabled", "bind-address", "protocol", "database", "retention-policy", "batch-size", "batch-pending", "batch-timeout"},
+		Columns: []string{"enabled", "bind-address", "protocol", "database", "retention-policy", "batch-size", "batch-pending", "batch-timeout", "tls-enabled"},
 	}
 
 	for _, cc := range c {
@@ -270,7 +406,7 @@ func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
 			continue
 		}
 
-		r := []interface{}{true, cc.BindAddress, cc.Protocol, cc.Database, cc.RetentionPolicy, cc.BatchSize, cc.BatchPending, cc.BatchTimeout}
+		r := []interface{}{true, cc.BindAddress, cc.Protocol, cc.Database, cc.RetentionPolicy, cc.BatchSize, cc.BatchPending, cc.BatchTimeout, cc.TLSEnabled}
 		d.AddRow(r)
 	}
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/influxdata/influxdb/services/graphite"
+	itoml "github.com/influxdata/influxdb/toml"
 )
 
 func TestConfig_Parse(t *testing.T) {
@@ -23,6 +24,10 @@ batch-timeout="1s"
 consistency-level="one"
 templates=["servers.* .host.measurement*"]
 tags=["region=us-east"]
+tls-enabled=true
+certificate="/etc/ssl/graphite.pem"
+private-key="/etc/ssl/graphite.key"
+ca-certificate="/etc/ssl/carbon-ca.pem"
 `, &c); err != nil {
 		t.Fatal(err)
 	}
@@ -46,6 +51,14 @@ tags=["region=us-east"]
 		t.Fatalf("unexpected graphite batch timeout: %v", c.BatchTimeout)
 	} else if c.ConsistencyLevel != "one" {
 		t.Fatalf("unexpected graphite consistency setting: %s", c.ConsistencyLevel)
+	} else if !c.TLSEnabled {
+		t.Fatalf("unexpected graphite tls enabled: %v", c.TLSEnabled)
+	} else if c.Certificate != "/etc/ssl/graphite.pem" {
+		t.Fatalf("unexpected graphite certificate: %s", c.Certificate)
+	} else if c.PrivateKey != "/etc/ssl/graphite.key" {
+		t.Fatalf("unexpected graphite private key: %s", c.PrivateKey)
+	} else if c.CACertificate != "/etc/ssl/carbon-ca.pem" {
+		t.Fatalf("unexpected graphite ca certificate: %s", c.CACertificate)
 	}
 
 	if len(c.Templates) != 1 && c.Templates[0] != "servers.* .host.measurement*" {
@@ -168,3 +181,61 @@ func TestConfigValidateFilterDuplicates(t *testing.T) {
 	}
 
 }
+
+func TestConfigValidateTLS(t *testing.T) {
+	c := &graphite.Config{Protocol: "tcp", TLSEnabled: true}
+	if err := c.Validate(); err != nil {
+		t.Errorf("config validate expected success, got %v", err)
+	}
+
+	c.Protocol = "udp"
+	if err := c.Validate(); err == nil {
+		t.Errorf("config validate expected error. got nil")
+	}
+}
+
+func TestConfigValidateTemplateTargets(t *testing.T) {
+	c := &graphite.Config{}
+	c.Templates = []string{
+		"cpu.* .host.measurement* region=us-east @cpu:week",
+		"mem.* measurement* @mem",
+	}
+	if err := c.Validate(); err != nil {
+		t.Errorf("config validate expected success, got %v", err)
+	}
+
+	c.Templates = []string{"cpu.* measurement* @:week"}
+	if err := c.Validate(); err == nil {
+		t.Errorf("config validate expected error. got nil")
+	}
+}
+
+func TestConfigValidateNonFiniteValues(t *testing.T) {
+	c := &graphite.Config{NonFiniteValues: graphite.NonFiniteNull}
+	if err := c.Validate(); err != nil {
+		t.Errorf("config validate expected success, got %v", err)
+	}
+
+	c.NonFiniteValues = "zero"
+	if err := c.Validate(); err == nil {
+		t.Errorf("config validate expected error. got nil")
+	}
+}
+
+func TestConfigValidateConnectionLimits(t *testing.T) {
+	c := &graphite.Config{MaxConnections: 10, IdleTimeout: itoml.Duration(time.Minute)}
+	if err := c.Validate(); err != nil {
+		t.Errorf("config validate expected success, got %v", err)
+	}
+
+	c.MaxConnections = -1
+	if err := c.Validate(); err == nil {
+		t.Errorf("config validate expected error. got nil")
+	}
+
+	c.MaxConnections = 0
+	c.ReadTimeout = itoml.Duration(-time.Second)
+	if err := c.Validate(); err == nil {
+		t.Errorf("config validate expected error. got nil")
+	}
+}
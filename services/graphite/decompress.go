@@ -0,0 +1,54 @@
+package graphite
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// MaxDecompressedPayload is the largest size a compressed UDP payload may
+// expand to before it is rejected.
+const MaxDecompressedPayload = 16 * 1024 * 1024
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// errPayloadTooLarge is returned when a decompressed payload exceeds
+// MaxDecompressedPayload.
+var errPayloadTooLarge = fmt.Errorf("decompressed payload exceeds %d bytes", MaxDecompressedPayload)
+
+// decompress returns the uncompressed contents of buf according to the
+// compression setting. Uncompressed payloads are returned as-is when
+// compression is CompressionAuto.
+func decompress(compression string, buf []byte) ([]byte, error) {
+	switch compression {
+	case CompressionAuto:
+		if !bytes.HasPrefix(buf, gzipMagic) {
+			return buf, nil
+		}
+		return gunzip(buf)
+	case CompressionGzip:
+		return gunzip(buf)
+	default:
+		return buf, nil
+	}
+}
+
+// gunzip decompresses a gzip encoded payload, returning errPayloadTooLarge if
+// it expands to more than MaxDecompressedPayload bytes.
+func gunzip(buf []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(r, MaxDecompressedPayload+1))
+	if err != nil {
+		return nil, err
+	} else if len(b) > MaxDecompressedPayload {
+		return nil, errPayloadTooLarge
+	}
+	return b, nil
+}
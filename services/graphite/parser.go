@@ -20,6 +20,10 @@ var (
 	MaxDate = time.Date(2038, 1, 19, 0, 0, 0, 0, time.UTC)
 )
 
+// nonFiniteFieldSuffix is appended to the field name to form the name of the
+// field that holds NaN and infinite values when they are written as nulls.
+const nonFiniteFieldSuffix = "_non_finite"
+
 var defaultTemplate *template
 
 func init() {
@@ -32,8 +36,10 @@ func init() {
 
 // Parser encapsulates a Graphite Parser.
 type Parser struct {
-	matcher *matcher
-	tags    models.Tags
+	matcher           *matcher
+	tags              models.Tags
+	nonFinite         string
+	nonFiniteSentinel float64
 }
 
 // Options are configurable values that can be provided to a Parser.
@@ -41,6 +47,12 @@ type Options struct {
 	Separator   string
 	Templates   []string
 	DefaultTags models.Tags
+
+	// NonFiniteValues selects how NaN and infinite values are handled, and
+	// NonFiniteSentinel is the value written in their place if NonFiniteValues
+	// is NonFiniteSentinel. By default, they are rejected.
+	NonFiniteValues   string
+	NonFiniteSentinel float64
 }
 
 // NewParserWithOptions returns a graphite parser using the given options.
@@ -51,10 +63,14 @@ func NewParserWithOptions(options Options) (*Parser, error) {
 
 	for _, pattern := range options.Templates {
 
-		template := pattern
+		// Format is [filter] <template> [tag1=value1,tag2=value2] [@database[:retention-policy]]
+		parts, database, retentionPolicy, err := splitTarget(strings.Fields(pattern))
+		if err != nil {
+			return nil, err
+		}
+
+		template := strings.Join(parts, " ")
 		filter := ""
-		// Format is [filter] <template> [tag1=value1,tag2=value2]
-		parts := strings.Fields(pattern)
 		if len(parts) < 1 {
 			continue
 		} else if len(parts) >= 2 {
@@ -80,9 +96,15 @@ func NewParserWithOptions(options Options) (*Parser, error) {
 		if err != nil {
 			return nil, err
 		}
+		tmpl.database, tmpl.retentionPolicy = database, retentionPolicy
 		matcher.Add(filter, tmpl)
 	}
-	return &Parser{matcher: matcher, tags: options.DefaultTags}, nil
+	return &Parser{
+		matcher:           matcher,
+		tags:              options.DefaultTags,
+		nonFinite:         options.NonFiniteValues,
+		nonFiniteSentinel: options.NonFiniteSentinel,
+	}, nil
 }
 
 // NewParser returns a GraphiteParser instance.
@@ -95,19 +117,56 @@ func NewParser(templates []string, defaultTags models.Tags) (*Parser, error) {
 		})
 }
 
+// splitTarget removes the optional "@database[:retention-policy]" target
+// from the end of the fields of a template definition, and returns the
+// remaining fields along with the target database and retention policy.
+func splitTarget(parts []string) ([]string, string, string, error) {
+	if len(parts) == 0 || !strings.HasPrefix(parts[len(parts)-1], "@") {
+		return parts, "", "", nil
+	}
+
+	target := parts[len(parts)-1][1:]
+	database, retentionPolicy := target, ""
+	if i := strings.IndexByte(target, ':'); i >= 0 {
+		database, retentionPolicy = target[:i], target[i+1:]
+	}
+	if database == "" {
+		return nil, "", "", fmt.Errorf("missing database in template target '@%s'", target)
+	}
+	return parts[:len(parts)-1], database, retentionPolicy, nil
+}
+
 // Parse performs Graphite parsing of a single line.
 func (p *Parser) Parse(line string) (models.Point, error) {
+	point, _, _, err := p.parse(line)
+	return point, err
+}
+
+// parse parses a single line and returns the point along with the template
+// that was applied. Tagged metrics are not subject to templates, so the
+// template returned for them is nil. nonFinite reports whether a NaN or
+// infinite value was replaced according to the parser's options.
+func (p *Parser) parse(line string) (point models.Point, tmpl *template, nonFinite bool, err error) {
 	// Break into 3 fields (name, value, timestamp).
 	fields := strings.Fields(line)
 	if len(fields) != 2 && len(fields) != 3 {
-		return nil, fmt.Errorf("received %q which doesn't have required fields", line)
+		return nil, nil, false, fmt.Errorf("received %q which doesn't have required fields", line)
 	}
 
-	// decode the name and tags
-	template := p.matcher.Match(fields[0])
-	measurement, tags, field, err := template.Apply(fields[0])
+	// decode the name and tags. Tagged metrics carry their tags and are
+	// not subject to templates.
+	var (
+		measurement, field string
+		tags               map[string]string
+	)
+	if strings.Contains(fields[0], ";") {
+		measurement, tags, err = parseTaggedMetric(fields[0])
+	} else {
+		tmpl = p.matcher.Match(fields[0])
+		measurement, tags, field, err = tmpl.Apply(fields[0])
+	}
 	if err != nil {
-		return nil, err
+		return nil, nil, false, err
 	}
 
 	// Could not extract measurement, use the raw value
@@ -118,18 +177,28 @@ func (p *Parser) Parse(line string) (models.Point, error) {
 	// Parse value.
 	v, err := strconv.ParseFloat(fields[1], 64)
 	if err != nil {
-		return nil, fmt.Errorf(`field "%s" value: %s`, fields[0], err)
+		return nil, nil, false, fmt.Errorf(`field "%s" value: %s`, fields[0], err)
 	}
 
-	if math.IsNaN(v) || math.IsInf(v, 0) {
-		return nil, &UnsupportedValueError{Field: fields[0], Value: v}
+	if field == "" {
+		field = "value"
 	}
 
 	fieldValues := map[string]interface{}{}
-	if field != "" {
-		fieldValues[field] = v
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		switch p.nonFinite {
+		case NonFiniteSentinel:
+			fieldValues[field] = p.nonFiniteSentinel
+		case NonFiniteNull:
+			// Leave the field unset, and keep the original value in a
+			// field of its own that cannot conflict with the field type.
+			fieldValues[field+nonFiniteFieldSuffix] = fields[1]
+		default:
+			return nil, nil, false, &UnsupportedValueError{Field: fields[0], Value: v}
+		}
+		nonFinite = true
 	} else {
-		fieldValues["value"] = v
+		fieldValues[field] = v
 	}
 
 	// If no 3rd field, use now as timestamp
@@ -139,7 +208,7 @@ func (p *Parser) Parse(line string) (models.Point, error) {
 		// Parse timestamp.
 		unixTime, err := strconv.ParseFloat(fields[2], 64)
 		if err != nil {
-			return nil, fmt.Errorf(`field "%s" time: %s`, fields[0], err)
+			return nil, nil, false, fmt.Errorf(`field "%s" time: %s`, fields[0], err)
 		}
 
 		// -1 is a special value that gets converted to current UTC time
@@ -148,7 +217,7 @@ func (p *Parser) Parse(line string) (models.Point, error) {
 			// Check if we have fractional seconds
 			timestamp = time.Unix(int64(unixTime), int64((unixTime-math.Floor(unixTime))*float64(time.Second)))
 			if timestamp.Before(MinDate) || timestamp.After(MaxDate) {
-				return nil, fmt.Errorf("timestamp out of range")
+				return nil, nil, false, fmt.Errorf("timestamp out of range")
 			}
 		}
 	}
@@ -159,7 +228,32 @@ func (p *Parser) Parse(line string) (models.Point, error) {
 			tags[string(t.Key)] = string(t.Value)
 		}
 	}
-	return models.NewPoint(measurement, models.NewTags(tags), fieldValues, timestamp)
+	point, err = models.NewPoint(measurement, models.NewTags(tags), fieldValues, timestamp)
+	return point, tmpl, nonFinite, err
+}
+
+// parseTaggedMetric splits a metric name in the tagged format introduced in
+// Graphite 1.1, e.g. "cpu.load;host=server01;dc=us-east", into the metric
+// path, which is used as the measurement, and its tags.
+func parseTaggedMetric(name string) (string, map[string]string, error) {
+	parts := strings.Split(name, ";")
+	if parts[0] == "" {
+		return "", nil, fmt.Errorf("missing metric name in tagged metric %q", name)
+	}
+
+	tags := make(map[string]string, len(parts)-1)
+	for _, kv := range parts[1:] {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 || i == len(kv)-1 {
+			return "", nil, fmt.Errorf("invalid tag %q in tagged metric %q", kv, name)
+		}
+		key, value := kv[:i], kv[i+1:]
+		if strings.ContainsAny(key, "!^") || strings.HasPrefix(value, "~") {
+			return "", nil, fmt.Errorf("invalid tag %q in tagged metric %q", kv, name)
+		}
+		tags[key] = value
+	}
+	return parts[0], tags, nil
 }
 
 // ApplyTemplate extracts the template fields from the given line and
@@ -188,6 +282,11 @@ type template struct {
 	defaultTags       models.Tags
 	greedyMeasurement bool
 	separator         string
+
+	// database and retentionPolicy, if set, override the destination of
+	// points matched by the template.
+	database        string
+	retentionPolicy string
 }
 
 // NewTemplate returns a new template ensuring it has a measurement
@@ -396,16 +495,18 @@ type nodes []*node
 // less than a non-wildcard value.
 //
 // For example, the filters:
-//             "*.*"
-//             "servers.*"
-//             "servers.localhost"
-//             "*.localhost"
+//
+//	"*.*"
+//	"servers.*"
+//	"servers.localhost"
+//	"*.localhost"
 //
 // Would be sorted as:
-//             "servers.localhost"
-//             "servers.*"
-//             "*.localhost"
-//             "*.*"
+//
+//	"servers.localhost"
+//	"servers.*"
+//	"*.localhost"
+//	"*.*"
 func (n *nodes) Less(j, k int) bool {
 	if (*n)[j].value == "*" && (*n)[k].value != "*" {
 		return false
@@ -258,6 +258,47 @@ func TestParseNaN(t *testing.T) {
 	}
 }
 
+func TestParseNonFinite(t *testing.T) {
+	for _, test := range []struct {
+		mode  string
+		input string
+		exp   string
+	}{
+		{mode: graphite.NonFiniteSentinel, input: "cpu_load NaN 1435077219", exp: "cpu_load value=-1 1435077219000000000"},
+		{mode: graphite.NonFiniteSentinel, input: "cpu_load +Inf 1435077219", exp: "cpu_load value=-1 1435077219000000000"},
+		{mode: graphite.NonFiniteNull, input: "cpu_load NaN 1435077219", exp: `cpu_load value_non_finite="NaN" 1435077219000000000`},
+		{mode: graphite.NonFiniteNull, input: "cpu_load -Inf 1435077219", exp: `cpu_load value_non_finite="-Inf" 1435077219000000000`},
+	} {
+		p, err := graphite.NewParserWithOptions(graphite.Options{
+			Templates:         []string{"measurement*"},
+			Separator:         graphite.DefaultSeparator,
+			NonFiniteValues:   test.mode,
+			NonFiniteSentinel: -1,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error creating parser, got %v", err)
+		}
+
+		pt, err := p.Parse(test.input)
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", test.mode, err)
+		} else if got := pt.String(); got != test.exp {
+			t.Errorf("%s: parse mismatch: got %v, exp %v", test.mode, got, test.exp)
+		}
+	}
+
+	// Infinite values are dropped by default.
+	p, err := graphite.NewParser([]string{"measurement*"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating parser, got %v", err)
+	}
+	if _, err := p.Parse("cpu_load Inf 1435077219"); err == nil {
+		t.Fatalf("expected error. got nil")
+	} else if _, ok := err.(*graphite.UnsupportedValueError); !ok {
+		t.Fatalf("expected *graphite.ErrUnsupportedValue, got %v", reflect.TypeOf(err))
+	}
+}
+
 func TestFilterMatchDefault(t *testing.T) {
 	p, err := graphite.NewParser([]string{"servers.localhost .host.measurement*"}, nil)
 	if err != nil {
@@ -465,6 +506,42 @@ func TestFilterMatchMultipleWildcards(t *testing.T) {
 	}
 }
 
+func TestParseTaggedMetric(t *testing.T) {
+	p, err := graphite.NewParser([]string{"servers.* .host.measurement*"}, models.NewTags(map[string]string{
+		"region": "us-east",
+		"host":   "should not set",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error creating parser, got %v", err)
+	}
+
+	exp := models.MustNewPoint("servers.cpu_load",
+		models.NewTags(map[string]string{"host": "localhost", "dc": "dc1", "region": "us-east"}),
+		models.Fields{"value": float64(11)},
+		time.Unix(1435077219, 0))
+
+	pt, err := p.Parse("servers.cpu_load;host=localhost;dc=dc1 11 1435077219")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	if exp.String() != pt.String() {
+		t.Errorf("parse mismatch: got %v, exp %v", pt.String(), exp.String())
+	}
+
+	for _, line := range []string{
+		";host=localhost 11 1435077219",
+		"cpu_load;host 11 1435077219",
+		"cpu_load;=localhost 11 1435077219",
+		"cpu_load;host= 11 1435077219",
+		"cpu_load;host=~localhost 11 1435077219",
+	} {
+		if _, err := p.Parse(line); err == nil {
+			t.Errorf("expected error parsing %q", line)
+		}
+	}
+}
+
 func TestParseDefaultTags(t *testing.T) {
 	p, err := graphite.NewParser([]string{"servers.localhost .host.measurement*"}, models.NewTags(map[string]string{
 		"region": "us-east",
@@ -3,7 +3,10 @@ package graphite // import "github.com/influxdata/influxdb/services/graphite"
 
 import (
 	"bufio"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"net"
 	"strings"
@@ -27,13 +30,26 @@ const (
 	statBytesReceived       = "bytesRx"
 	statPointsParseFail     = "pointsParseFail"
 	statPointsNaNFail       = "pointsNaNFail"
+	statPointsInfFail       = "pointsInfFail"
+	statPointsSentinel      = "pointsNonFiniteSentinel"
+	statPointsNull          = "pointsNonFiniteNull"
+	statPointsAggregated    = "pointsAggregated"
 	statBatchesTransmitted  = "batchesTx"
 	statPointsTransmitted   = "pointsTx"
 	statBatchesTransmitFail = "batchesTxFail"
+	statDecompressFail      = "decompressFail"
 	statConnectionsActive   = "connsActive"
 	statConnectionsHandled  = "connsHandled"
+	statConnectionsRejected = "connsRejected"
+	statConnectionsExpired  = "connsExpired"
 )
 
+// target identifies the database and retention policy points are written to.
+type target struct {
+	database        string
+	retentionPolicy string
+}
+
 type tcpConnection struct {
 	conn        net.Conn
 	connectTime time.Time
@@ -53,16 +69,34 @@ type Service struct {
 	batchPending    int
 	batchTimeout    time.Duration
 	udpReadBuffer   int
+	compression     string
+
+	tls    bool
+	cert   string
+	key    string
+	caCert string
 
 	batcher *tsdb.PointBatcher
 	parser  *Parser
 
+	aggregationWindow   time.Duration
+	aggregationFunction string
+	aggregator          *aggregator
+
+	// batchers holds a batcher for every template target points have been
+	// received for. Points for the configured database and retention policy
+	// use batcher.
+	batchers map[target]*tsdb.PointBatcher
+
 	logger      *zap.Logger
 	stats       *Statistics
 	defaultTags models.StatisticTags
 
 	tcpConnectionsMu sync.Mutex
 	tcpConnections   map[string]*tcpConnection
+	connSlots        chan struct{} // Limits concurrent TCP connections, if set.
+	idleTimeout      time.Duration
+	readTimeout      time.Duration
 	diagsKey         string
 
 	ln      net.Listener
@@ -71,9 +105,10 @@ type Service struct {
 
 	wg sync.WaitGroup
 
-	mu    sync.RWMutex
-	ready bool          // Has the required database been created?
-	done  chan struct{} // Is the service closing or closed?
+	mu      sync.RWMutex
+	ready   bool            // Has the required database been created?
+	targets map[target]bool // Have the template targets been created?
+	done    chan struct{}   // Is the service closing or closed?
 
 	Monitor interface {
 		RegisterDiagnosticsClient(name string, client diagnostics.Client)
@@ -103,19 +138,29 @@ func NewService(c Config) (*Service, error) {
 		batchSize:       d.BatchSize,
 		batchPending:    d.BatchPending,
 		udpReadBuffer:   d.UDPReadBuffer,
+		compression:     d.Compression,
 		batchTimeout:    time.Duration(d.BatchTimeout),
+		tls:             d.TLSEnabled,
+		cert:            d.Certificate,
+		key:             d.PrivateKey,
+		caCert:          d.CACertificate,
 		logger:          zap.NewNop(),
 		stats:           &Statistics{},
 		defaultTags:     models.StatisticTags{"proto": d.Protocol, "bind": d.BindAddress},
 		tcpConnections:  make(map[string]*tcpConnection),
+		idleTimeout:     time.Duration(d.IdleTimeout),
+		readTimeout:     time.Duration(d.ReadTimeout),
 		diagsKey:        strings.Join([]string{"graphite", d.Protocol, d.BindAddress}, ":"),
+
+		aggregationWindow:   time.Duration(d.AggregationWindow),
+		aggregationFunction: d.AggregationFunction,
 	}
 
-	parser, err := NewParserWithOptions(Options{
-		Templates:   d.Templates,
-		DefaultTags: d.DefaultTags(),
-		Separator:   d.Separator})
+	if d.MaxConnections > 0 {
+		s.connSlots = make(chan struct{}, d.MaxConnections)
+	}
 
+	parser, err := newParser(d)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +169,17 @@ func NewService(c Config) (*Service, error) {
 	return &s, nil
 }
 
+// newParser returns a parser for the templates and tags of c.
+func newParser(c *Config) (*Parser, error) {
+	return NewParserWithOptions(Options{
+		Templates:         c.Templates,
+		DefaultTags:       c.DefaultTags(),
+		Separator:         c.Separator,
+		NonFiniteValues:   c.NonFiniteValues,
+		NonFiniteSentinel: c.NonFiniteSentinel,
+	})
+}
+
 // Open starts the Graphite input processing data.
 func (s *Service) Open() error {
 	s.mu.Lock()
@@ -145,10 +201,18 @@ func (s *Service) Open() error {
 
 	s.batcher = tsdb.NewPointBatcher(s.batchSize, s.batchPending, s.batchTimeout)
 	s.batcher.Start()
+	s.batchers = make(map[target]*tsdb.PointBatcher)
+	s.targets = make(map[target]bool)
 
 	// Start processing batches.
 	s.wg.Add(1)
-	go s.processBatches(s.batcher)
+	go s.processBatches(s.batcher, s.defaultTarget())
+
+	if s.aggregationWindow > 0 {
+		s.aggregator = newAggregator(s.aggregationWindow, s.aggregationFunction)
+		s.wg.Add(1)
+		go s.aggregate()
+	}
 
 	var err error
 	if strings.ToLower(s.protocol) == "tcp" {
@@ -164,7 +228,8 @@ func (s *Service) Open() error {
 
 	s.logger.Info("Listening",
 		zap.String("protocol", s.protocol),
-		zap.Stringer("addr", s.addr))
+		zap.Stringer("addr", s.addr),
+		zap.Bool("tls", s.tls))
 	return nil
 }
 
@@ -199,6 +264,9 @@ func (s *Service) Close() error {
 		if s.batcher != nil {
 			s.batcher.Stop()
 		}
+		for _, b := range s.batchers {
+			b.Stop()
+		}
 
 		if s.Monitor != nil {
 			s.Monitor.DeregisterDiagnosticsClient(s.diagsKey)
@@ -264,6 +332,29 @@ func (s *Service) createInternalStorage() error {
 	return nil
 }
 
+// createTargetStorage ensures that the database of a template target has been
+// created. Unlike the configured retention policy, the retention policy of a
+// target must be created explicitly.
+func (s *Service) createTargetStorage(t target) error {
+	s.mu.RLock()
+	ready := s.targets[t]
+	s.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if db := s.MetaClient.Database(t.database); db == nil {
+		if _, err := s.MetaClient.CreateDatabaseWithRetentionPolicy(t.database, &meta.RetentionPolicySpec{}); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.targets[t] = true
+	s.mu.Unlock()
+	return nil
+}
+
 // WithLogger sets the logger on the service.
 func (s *Service) WithLogger(log *zap.Logger) {
 	s.logger = log.With(
@@ -278,11 +369,18 @@ type Statistics struct {
 	BytesReceived       int64
 	PointsParseFail     int64
 	PointsNaNFail       int64
+	PointsInfFail       int64
+	PointsSentinel      int64
+	PointsNull          int64
+	PointsAggregated    int64
 	BatchesTransmitted  int64
 	PointsTransmitted   int64
 	BatchesTransmitFail int64
+	DecompressFail      int64
 	ActiveConnections   int64
 	HandledConnections  int64
+	RejectedConnections int64
+	ExpiredConnections  int64
 }
 
 // Statistics returns statistics for periodic monitoring.
@@ -295,15 +393,50 @@ func (s *Service) Statistics(tags map[string]string) []models.Statistic {
 			statBytesReceived:       atomic.LoadInt64(&s.stats.BytesReceived),
 			statPointsParseFail:     atomic.LoadInt64(&s.stats.PointsParseFail),
 			statPointsNaNFail:       atomic.LoadInt64(&s.stats.PointsNaNFail),
+			statPointsInfFail:       atomic.LoadInt64(&s.stats.PointsInfFail),
+			statPointsSentinel:      atomic.LoadInt64(&s.stats.PointsSentinel),
+			statPointsNull:          atomic.LoadInt64(&s.stats.PointsNull),
+			statPointsAggregated:    atomic.LoadInt64(&s.stats.PointsAggregated),
 			statBatchesTransmitted:  atomic.LoadInt64(&s.stats.BatchesTransmitted),
 			statPointsTransmitted:   atomic.LoadInt64(&s.stats.PointsTransmitted),
 			statBatchesTransmitFail: atomic.LoadInt64(&s.stats.BatchesTransmitFail),
+			statDecompressFail:      atomic.LoadInt64(&s.stats.DecompressFail),
 			statConnectionsActive:   atomic.LoadInt64(&s.stats.ActiveConnections),
 			statConnectionsHandled:  atomic.LoadInt64(&s.stats.HandledConnections),
+			statConnectionsRejected: atomic.LoadInt64(&s.stats.RejectedConnections),
+			statConnectionsExpired:  atomic.LoadInt64(&s.stats.ExpiredConnections),
 		},
 	}}
 }
 
+// Reload replaces the templates, tags and separator used to parse metrics
+// with those of c. Open connections are kept, and lines received after Reload
+// returns are parsed with the new settings.
+func (s *Service) Reload(c Config) error {
+	d := c.WithDefaults()
+	parser, err := newParser(d)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.parser = parser
+	s.mu.Unlock()
+
+	s.logger.Info("Reloaded graphite templates", zap.Int("templates", len(d.Templates)))
+	return nil
+}
+
+// BindAddress returns the configured bind address of the service.
+func (s *Service) BindAddress() string {
+	return s.bindAddress
+}
+
+// Protocol returns the configured protocol of the service.
+func (s *Service) Protocol() string {
+	return s.protocol
+}
+
 // Addr returns the address the Service binds to.
 func (s *Service) Addr() net.Addr {
 	return s.addr
@@ -311,7 +444,19 @@ func (s *Service) Addr() net.Addr {
 
 // openTCPServer opens the Graphite input in TCP mode and starts processing data.
 func (s *Service) openTCPServer() (net.Addr, error) {
-	ln, err := net.Listen("tcp", s.bindAddress)
+	var (
+		ln  net.Listener
+		err error
+	)
+	if s.tls {
+		config, err := s.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		ln, err = tls.Listen("tcp", s.bindAddress, config)
+	} else {
+		ln, err = net.Listen("tcp", s.bindAddress)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -331,6 +476,18 @@ func (s *Service) openTCPServer() (net.Addr, error) {
 				continue
 			}
 
+			if s.connSlots != nil {
+				select {
+				case s.connSlots <- struct{}{}:
+				default:
+					atomic.AddInt64(&s.stats.RejectedConnections, 1)
+					s.logger.Info("Rejected TCP connection, too many open connections",
+						zap.Stringer("remote_addr", conn.RemoteAddr()))
+					conn.Close()
+					continue
+				}
+			}
+
 			s.wg.Add(1)
 			go s.handleTCPConnection(conn)
 		}
@@ -338,10 +495,40 @@ func (s *Service) openTCPServer() (net.Addr, error) {
 	return ln.Addr(), nil
 }
 
+// tlsConfig returns the TLS configuration of the TCP listener.
+func (s *Service) tlsConfig() (*tls.Config, error) {
+	key := s.key
+	if key == "" {
+		key = s.cert
+	}
+	cert, err := tls.LoadX509KeyPair(s.cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if s.caCert != "" {
+		pem, err := ioutil.ReadFile(s.caCert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", s.caCert)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
 // handleTCPConnection services an individual TCP connection for the Graphite input.
 func (s *Service) handleTCPConnection(conn net.Conn) {
 	defer s.wg.Done()
 	defer conn.Close()
+	if s.connSlots != nil {
+		defer func() { <-s.connSlots }()
+	}
 	defer atomic.AddInt64(&s.stats.ActiveConnections, -1)
 	defer s.untrackConnection(conn)
 	atomic.AddInt64(&s.stats.ActiveConnections, 1)
@@ -351,9 +538,25 @@ func (s *Service) handleTCPConnection(conn net.Conn) {
 	reader := bufio.NewReader(conn)
 
 	for {
+		// Wait up to the idle timeout for the next line to start, and up to
+		// the read timeout for it to be complete.
+		if s.idleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+			if _, err := reader.Peek(1); err != nil {
+				s.expired(conn, err)
+				return
+			}
+		}
+		if s.readTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+		} else if s.idleTimeout > 0 {
+			conn.SetReadDeadline(time.Time{})
+		}
+
 		// Read up to the next newline.
 		buf, err := reader.ReadBytes('\n')
 		if err != nil {
+			s.expired(conn, err)
 			return
 		}
 
@@ -366,6 +569,15 @@ func (s *Service) handleTCPConnection(conn net.Conn) {
 	}
 }
 
+// expired records the closing of conn if err is a timeout.
+func (s *Service) expired(conn net.Conn, err error) {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		atomic.AddInt64(&s.stats.ExpiredConnections, 1)
+		s.logger.Info("Closing expired TCP connection",
+			zap.Stringer("remote_addr", conn.RemoteAddr()), zap.Error(err))
+	}
+}
+
 func (s *Service) trackConnection(c net.Conn) {
 	s.tcpConnectionsMu.Lock()
 	defer s.tcpConnectionsMu.Unlock()
@@ -411,12 +623,20 @@ func (s *Service) openUDPServer() (net.Addr, error) {
 				return
 			}
 
-			lines := strings.Split(string(buf[:n]), "\n")
+			atomic.AddInt64(&s.stats.BytesReceived, int64(n))
+
+			payload, err := decompress(s.compression, buf[:n])
+			if err != nil {
+				atomic.AddInt64(&s.stats.DecompressFail, 1)
+				s.logger.Info("Failed to decompress UDP payload", zap.Error(err))
+				continue
+			}
+
+			lines := strings.Split(string(payload), "\n")
 			for _, line := range lines {
 				s.handleLine(line)
 			}
 			atomic.AddInt64(&s.stats.PointsReceived, int64(len(lines)))
-			atomic.AddInt64(&s.stats.BytesReceived, int64(n))
 		}
 	}()
 	return s.udpConn.LocalAddr(), nil
@@ -428,42 +648,139 @@ func (s *Service) handleLine(line string) {
 	}
 
 	// Parse it.
-	point, err := s.parser.Parse(line)
+	s.mu.RLock()
+	parser := s.parser
+	s.mu.RUnlock()
+	point, tmpl, nonFinite, err := parser.parse(line)
 	if err != nil {
 		switch err := err.(type) {
 		case *UnsupportedValueError:
-			// Graphite ignores NaN values with no error.
+			// Graphite ignores NaN values with no error, and so does the
+			// default non-finite-values setting for infinite values.
 			if math.IsNaN(err.Value) {
 				atomic.AddInt64(&s.stats.PointsNaNFail, 1)
-				return
+			} else {
+				atomic.AddInt64(&s.stats.PointsInfFail, 1)
 			}
+			return
+		default:
+			s.logger.Info("Unable to parse line", zap.String("line", line), zap.Error(err))
+			atomic.AddInt64(&s.stats.PointsParseFail, 1)
+			return
+		}
+	}
+
+	if nonFinite {
+		switch parser.nonFinite {
+		case NonFiniteSentinel:
+			atomic.AddInt64(&s.stats.PointsSentinel, 1)
+		case NonFiniteNull:
+			atomic.AddInt64(&s.stats.PointsNull, 1)
+		default:
 		}
-		s.logger.Info("Unable to parse line", zap.String("line", line), zap.Error(err))
-		atomic.AddInt64(&s.stats.PointsParseFail, 1)
+	}
+
+	t := s.defaultTarget()
+	if tmpl != nil && tmpl.database != "" {
+		t = target{database: tmpl.database, retentionPolicy: tmpl.retentionPolicy}
+	}
+
+	if s.aggregator != nil && s.aggregator.add(t, point, time.Now()) {
+		atomic.AddInt64(&s.stats.PointsAggregated, 1)
 		return
 	}
+	s.enqueue(t, point)
+}
 
-	s.batcher.In() <- point
+// defaultTarget returns the configured database and retention policy.
+func (s *Service) defaultTarget() target {
+	return target{database: s.database, retentionPolicy: s.retentionPolicy}
 }
 
-// processBatches continually drains the given batcher and writes the batches to the database.
-func (s *Service) processBatches(batcher *tsdb.PointBatcher) {
+// enqueue hands point to the batcher for t.
+func (s *Service) enqueue(t target, point models.Point) {
+	if t == s.defaultTarget() {
+		s.batcher.In() <- point
+		return
+	}
+
+	if b := s.targetBatcher(t); b != nil {
+		b.In() <- point
+	}
+}
+
+// aggregate periodically writes the points emitted by the aggregator until
+// the service is closed. Aggregates still pending when the service is
+// closed are discarded.
+func (s *Service) aggregate() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.aggregator.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, p := range s.aggregator.flush(now) {
+				s.enqueue(p.target, p.point)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// targetBatcher returns the batcher for points written to t, creating it if
+// necessary. It returns nil if the service is closing.
+func (s *Service) targetBatcher(t target) *tsdb.PointBatcher {
+	s.mu.RLock()
+	b := s.batchers[t]
+	s.mu.RUnlock()
+	if b != nil {
+		return b
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed() {
+		return nil
+	}
+	if b = s.batchers[t]; b == nil {
+		b = tsdb.NewPointBatcher(s.batchSize, s.batchPending, s.batchTimeout)
+		b.Start()
+		s.batchers[t] = b
+
+		s.wg.Add(1)
+		go s.processBatches(b, t)
+	}
+	return b
+}
+
+// processBatches continually drains the given batcher and writes the batches to t.
+func (s *Service) processBatches(batcher *tsdb.PointBatcher, t target) {
 	defer s.wg.Done()
 	for {
 		select {
 		case batch := <-batcher.Out():
 			// Will attempt to create database if not yet created.
-			if err := s.createInternalStorage(); err != nil {
-				s.logger.Info("Required database or retention policy do not yet exist", zap.Error(err))
+			var err error
+			if t == s.defaultTarget() {
+				err = s.createInternalStorage()
+			} else {
+				err = s.createTargetStorage(t)
+			}
+			if err != nil {
+				s.logger.Info("Required database or retention policy do not yet exist",
+					logger.Database(t.database), zap.Error(err))
 				continue
 			}
 
-			if err := s.PointsWriter.WritePointsPrivileged(s.database, s.retentionPolicy, models.ConsistencyLevelAny, batch); err == nil {
+			if err := s.PointsWriter.WritePointsPrivileged(t.database, t.retentionPolicy, models.ConsistencyLevelAny, batch); err == nil {
 				atomic.AddInt64(&s.stats.BatchesTransmitted, 1)
 				atomic.AddInt64(&s.stats.PointsTransmitted, int64(len(batch)))
 			} else {
 				s.logger.Info("Failed to write point batch to database",
-					logger.Database(s.database), zap.Error(err))
+					logger.Database(t.database), zap.Error(err))
 				atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
 			}
 
@@ -1,11 +1,15 @@
 package graphite
 
 import (
+	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -252,6 +256,226 @@ func Test_Service_UDP(t *testing.T) {
 	conn.Close()
 }
 
+func TestService_Reload(t *testing.T) {
+	t.Parallel()
+
+	config := Config{}
+	config.Database = "graphitedb"
+	config.BatchSize = 0 // No batching.
+	config.BatchTimeout = toml.Duration(time.Second)
+	config.BindAddress = "127.0.0.1:0"
+
+	service := NewTestService(&config)
+
+	written := make(chan []models.Point, 1)
+	service.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, points []models.Point) error {
+		written <- points
+		return nil
+	}
+
+	if err := service.Service.Open(); err != nil {
+		t.Fatalf("failed to open Graphite service: %s", err.Error())
+	}
+	defer service.Service.Close()
+
+	conn, err := net.Dial("tcp", service.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The connection stays open across the reload.
+	for i, exp := range []string{
+		"servers.localhost.cpu value=1 1435077219000000000",
+		"cpu,host=localhost value=1 1435077219000000000",
+	} {
+		if i == 1 {
+			config.Templates = []string{".host.measurement*"}
+			if err := service.Service.Reload(config); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := conn.Write([]byte("servers.localhost.cpu 1 1435077219\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case points := <-written:
+			if got := points[0].String(); got != exp {
+				t.Fatalf("unexpected point: got %s, expected %s", got, exp)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for points to be written")
+		}
+	}
+
+	// Invalid templates are rejected and leave the current ones in place.
+	config.Templates = []string{"servers.host"}
+	if err := service.Service.Reload(config); err == nil {
+		t.Fatal("expected error reloading invalid template")
+	}
+}
+
+func TestService_TemplateTargets(t *testing.T) {
+	t.Parallel()
+
+	config := Config{}
+	config.Database = "graphitedb"
+	config.BatchSize = 0 // No batching.
+	config.BatchTimeout = toml.Duration(time.Second)
+	config.BindAddress = "127.0.0.1:0"
+	config.Templates = []string{
+		"cpu.* .host.measurement* @cpudb:week",
+		"measurement*",
+	}
+
+	service := NewTestService(&config)
+
+	type write struct {
+		database, retentionPolicy, point string
+	}
+	written := make(chan write, 2)
+	service.WritePointsFn = func(database, retentionPolicy string, _ models.ConsistencyLevel, points []models.Point) error {
+		written <- write{database: database, retentionPolicy: retentionPolicy, point: points[0].String()}
+		return nil
+	}
+
+	if err := service.Service.Open(); err != nil {
+		t.Fatalf("failed to open Graphite service: %s", err.Error())
+	}
+	defer service.Service.Close()
+
+	service.Service.handleLine("cpu.server01.load 1 1435077219")
+	service.Service.handleLine("mem.server01.free 2 1435077219")
+
+	got := make(map[write]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case w := <-written:
+			got[w] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for points to be written")
+		}
+	}
+
+	for _, exp := range []write{
+		{database: "cpudb", retentionPolicy: "week", point: "load,host=server01 value=1 1435077219000000000"},
+		{database: "graphitedb", point: "mem.server01.free value=2 1435077219000000000"},
+	} {
+		if !got[exp] {
+			t.Errorf("expected write %v, got %v", exp, got)
+		}
+	}
+}
+
+func Test_Service_UDP_Gzip(t *testing.T) {
+	t.Parallel()
+
+	config := Config{}
+	config.Database = "graphitedb"
+	config.BatchSize = 2
+	config.BatchTimeout = toml.Duration(time.Second)
+	config.BindAddress = "127.0.0.1:0"
+	config.Protocol = "udp"
+	config.Compression = CompressionAuto
+
+	service := NewTestService(&config)
+
+	written := make(chan []models.Point, 1)
+	service.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, points []models.Point) error {
+		written <- points
+		return nil
+	}
+
+	if err := service.Service.Open(); err != nil {
+		t.Fatalf("failed to open Graphite service: %s", err.Error())
+	}
+	defer service.Service.Close()
+
+	conn, err := net.Dial("udp", service.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte("cpu 1 1435077219\nmem 2 1435077219\n")); err != nil {
+		t.Fatal(err)
+	} else if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case points := <-written:
+		if len(points) != 2 {
+			t.Fatalf("expected 2 points, got %d", len(points))
+		} else if got, exp := points[1].String(), "mem value=2 1435077219000000000"; got != exp {
+			t.Fatalf("unexpected point: got %s, expected %s", got, exp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for points to be written")
+	}
+}
+
+func Test_Service_TCP_ConnectionLimits(t *testing.T) {
+	t.Parallel()
+
+	config := Config{}
+	config.BindAddress = "127.0.0.1:0"
+	config.MaxConnections = 1
+	config.IdleTimeout = toml.Duration(100 * time.Millisecond)
+
+	service := NewTestService(&config)
+	service.WritePointsFn = func(string, string, models.ConsistencyLevel, []models.Point) error {
+		return nil
+	}
+
+	if err := service.Service.Open(); err != nil {
+		t.Fatalf("failed to open Graphite service: %s", err.Error())
+	}
+	defer service.Service.Close()
+
+	// waitFor waits for the statistic read by fn to reach exp.
+	waitFor := func(name string, fn func() int64, exp int64) {
+		timeout := time.After(5 * time.Second)
+		for fn() != exp {
+			select {
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s to reach %d, got %d", name, exp, fn())
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	conn1, err := net.Dial("tcp", service.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn1.Close()
+	waitFor("active connections", func() int64 { return atomic.LoadInt64(&service.Service.stats.ActiveConnections) }, 1)
+
+	// A second connection exceeds the limit and is closed right away.
+	conn2, err := net.Dial("tcp", service.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+	conn2.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn2.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected connection to be closed, got %v", err)
+	}
+	waitFor("rejected connections", func() int64 { return atomic.LoadInt64(&service.Service.stats.RejectedConnections) }, 1)
+
+	// The idle connection expires, making room for a new one.
+	waitFor("expired connections", func() int64 { return atomic.LoadInt64(&service.Service.stats.ExpiredConnections) }, 1)
+	waitFor("active connections", func() int64 { return atomic.LoadInt64(&service.Service.stats.ActiveConnections) }, 0)
+}
+
 type TestService struct {
 	Service       *Service
 	MetaClient    *internal.MetaClientMock
@@ -0,0 +1,550 @@
+package grpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Types of the header of Arrow IPC messages.
+const (
+	arrowHeaderSchema          = 1
+	arrowHeaderDictionaryBatch = 2
+	arrowHeaderRecordBatch     = 3
+)
+
+// Arrow data types of the columns of record batches.
+const (
+	arrowTypeNull          = 1
+	arrowTypeInt           = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeUtf8          = 5
+	arrowTypeBool          = 6
+	arrowTypeTimestamp     = 10
+	arrowTypeLargeUtf8     = 20
+)
+
+// Precisions of the FloatingPoint type.
+const (
+	arrowPrecisionSingle = 1
+	arrowPrecisionDouble = 2
+)
+
+// Arrow metadata versions supported: V4 and V5, which share the layout of
+// the messages decoded here.
+const (
+	arrowMetadataV4 = 3
+	arrowMetadataV5 = 4
+)
+
+var errTruncatedFlatbuffer = errors.New("truncated flatbuffer")
+
+// arrowField is a field, or column, of an Arrow schema.
+type arrowField struct {
+	name       string
+	typ        byte
+	bitWidth   int    // Int
+	signed     bool   // Int
+	precision  int    // FloatingPoint
+	unit       string // Timestamp, as a precision of models.SafeCalcTime.
+	dictionary bool
+	metadata   map[string]string
+}
+
+// validate returns an error if columns of the type of f are not supported.
+func (f *arrowField) validate() error {
+	switch {
+	case f.dictionary:
+		return fmt.Errorf("column %q: dictionary-encoded columns are not supported", f.name)
+	case f.typ == arrowTypeInt:
+		if w := f.bitWidth; w != 8 && w != 16 && w != 32 && w != 64 {
+			return fmt.Errorf("column %q: invalid integer width %d", f.name, w)
+		}
+	case f.typ == arrowTypeFloatingPoint:
+		if f.precision != arrowPrecisionSingle && f.precision != arrowPrecisionDouble {
+			return fmt.Errorf("column %q: half-precision floats are not supported", f.name)
+		}
+	case f.typ == arrowTypeTimestamp:
+		if f.unit == "" {
+			return fmt.Errorf("column %q: invalid timestamp unit", f.name)
+		}
+	case f.typ == arrowTypeNull, f.typ == arrowTypeUtf8, f.typ == arrowTypeLargeUtf8, f.typ == arrowTypeBool:
+	default:
+		return fmt.Errorf("column %q: unsupported arrow type %d", f.name, f.typ)
+	}
+	return nil
+}
+
+// arrowSchema is the schema of the record batches of a stream.
+type arrowSchema struct {
+	fields []arrowField
+}
+
+// arrowFieldNode describes a column of a record batch.
+type arrowFieldNode struct {
+	length, nullCount int64
+}
+
+// arrowBuffer locates a buffer in the body of a record batch message.
+type arrowBuffer struct {
+	offset, length int64
+}
+
+// arrowRecordBatch is the header of a record batch message.
+type arrowRecordBatch struct {
+	length  int64
+	nodes   []arrowFieldNode
+	buffers []arrowBuffer
+}
+
+// arrowMessage is a decoded Arrow IPC message header.
+type arrowMessage struct {
+	headerType byte
+	schema     *arrowSchema
+	batch      *arrowRecordBatch
+}
+
+// decodeArrowMessage decodes the flatbuffer of an Arrow IPC message header.
+// Only the parts of schemas and record batches that columns of points are
+// made of are decoded.
+func decodeArrowMessage(buf []byte) (*arrowMessage, error) {
+	b := &flatbuffer{buf: buf}
+	root := b.root()
+
+	if v := root.int16(0, 0); b.err == nil && v != arrowMetadataV4 && v != arrowMetadataV5 {
+		return nil, fmt.Errorf("unsupported arrow metadata version %d", v)
+	}
+
+	m := &arrowMessage{headerType: root.uint8(1, 0)}
+	header, ok := root.table(2)
+	if !ok {
+		if b.err != nil {
+			return nil, b.err
+		}
+		return nil, errors.New("arrow message has no header")
+	}
+
+	switch m.headerType {
+	case arrowHeaderSchema:
+		m.schema = decodeArrowSchema(header)
+	case arrowHeaderRecordBatch:
+		m.batch = decodeArrowRecordBatch(header)
+		if b.err == nil && m.batch == nil {
+			return nil, errors.New("compressed record batches are not supported")
+		}
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	return m, nil
+}
+
+func decodeArrowSchema(t fbTable) *arrowSchema {
+	if t.int16(0, 0) != 0 {
+		t.b.fail(errors.New("big-endian arrow data is not supported"))
+		return nil
+	}
+
+	pos, n := t.vector(1)
+	schema := &arrowSchema{fields: make([]arrowField, 0, n)}
+	for i := 0; i < n && t.b.err == nil; i++ {
+		ft := t.b.table(t.b.indirect(pos + 4*i))
+		f := arrowField{
+			name: ft.string(0),
+			typ:  ft.uint8(2, 0),
+		}
+		if typ, ok := ft.table(3); ok {
+			switch f.typ {
+			case arrowTypeInt:
+				f.bitWidth, f.signed = int(typ.int32(0, 0)), typ.bool(1)
+			case arrowTypeFloatingPoint:
+				f.precision = int(typ.int16(0, 0))
+			case arrowTypeTimestamp:
+				switch typ.int16(0, 0) {
+				case 0:
+					f.unit = "s"
+				case 1:
+					f.unit = "ms"
+				case 2:
+					f.unit = "u"
+				case 3:
+					f.unit = "n"
+				}
+			}
+		}
+		_, f.dictionary = ft.table(4)
+
+		mpos, mn := ft.vector(6)
+		for j := 0; j < mn && t.b.err == nil; j++ {
+			kv := t.b.table(t.b.indirect(mpos + 4*j))
+			if f.metadata == nil {
+				f.metadata = make(map[string]string, mn)
+			}
+			f.metadata[kv.string(0)] = kv.string(1)
+		}
+		schema.fields = append(schema.fields, f)
+	}
+	return schema
+}
+
+// decodeArrowRecordBatch decodes the header of a record batch. It returns
+// nil if the body of the batch is compressed.
+func decodeArrowRecordBatch(t fbTable) *arrowRecordBatch {
+	if _, ok := t.table(3); ok {
+		return nil
+	}
+
+	batch := &arrowRecordBatch{length: t.int64(0, 0)}
+	pos, n := t.vector(1)
+	for i := 0; i < n && t.b.err == nil; i++ {
+		batch.nodes = append(batch.nodes, arrowFieldNode{
+			length:    t.b.int64(pos + 16*i),
+			nullCount: t.b.int64(pos + 16*i + 8),
+		})
+	}
+	pos, n = t.vector(2)
+	for i := 0; i < n && t.b.err == nil; i++ {
+		batch.buffers = append(batch.buffers, arrowBuffer{
+			offset: t.b.int64(pos + 16*i),
+			length: t.b.int64(pos + 16*i + 8),
+		})
+	}
+	return batch
+}
+
+// arrowColumn is a column of a record batch, of one of the types supported
+// by arrowColumns.
+type arrowColumn struct {
+	field    *arrowField
+	validity []byte // Nil if no value is null.
+	offsets  []byte // Utf8 and LargeUtf8
+	values   []byte
+}
+
+// arrowColumns returns the columns of a record batch, whose buffers are in
+// body. The number of rows of the batch is returned as well.
+func arrowColumns(schema *arrowSchema, batch *arrowRecordBatch, body []byte) ([]arrowColumn, int, error) {
+	if batch.length < 0 || batch.length > math.MaxInt32 {
+		return nil, 0, fmt.Errorf("invalid record batch length %d", batch.length)
+	} else if len(batch.nodes) != len(schema.fields) {
+		return nil, 0, fmt.Errorf("record batch has %d columns, schema has %d", len(batch.nodes), len(schema.fields))
+	}
+	n := int(batch.length)
+
+	buffers := batch.buffers
+	buffer := func() ([]byte, error) {
+		if len(buffers) == 0 {
+			return nil, errors.New("missing buffer")
+		}
+		buf := buffers[0]
+		buffers = buffers[1:]
+		if buf.offset < 0 || buf.length < 0 || buf.offset > int64(len(body)) || buf.length > int64(len(body))-buf.offset {
+			return nil, errors.New("buffer out of the message body")
+		}
+		return body[buf.offset : buf.offset+buf.length], nil
+	}
+
+	columns := make([]arrowColumn, len(schema.fields))
+	for i := range schema.fields {
+		f, node := &schema.fields[i], batch.nodes[i]
+		c := &columns[i]
+		c.field = f
+		if err := f.validate(); err != nil {
+			return nil, 0, err
+		} else if node.length != batch.length {
+			return nil, 0, fmt.Errorf("column %q has %d rows, expected %d", f.name, node.length, batch.length)
+		}
+		if f.typ == arrowTypeNull {
+			continue // Null columns have no buffers.
+		}
+
+		validity, err := buffer()
+		if err != nil {
+			return nil, 0, fmt.Errorf("column %q: %s", f.name, err)
+		}
+		if node.nullCount > 0 {
+			if len(validity) < (n+7)/8 {
+				return nil, 0, fmt.Errorf("column %q: validity buffer too short", f.name)
+			}
+			c.validity = validity
+		}
+
+		var width int
+		switch f.typ {
+		case arrowTypeUtf8:
+			width = 4
+		case arrowTypeLargeUtf8:
+			width = 8
+		}
+		if width > 0 {
+			if c.offsets, err = buffer(); err == nil {
+				c.values, err = buffer()
+			}
+			if err != nil {
+				return nil, 0, fmt.Errorf("column %q: %s", f.name, err)
+			} else if n > 0 && len(c.offsets) < (n+1)*width {
+				return nil, 0, fmt.Errorf("column %q: offsets buffer too short", f.name)
+			}
+			// Validate the offsets so strings can be read without checks.
+			for j, prev := 0, int64(0); n > 0 && j <= n; j++ {
+				off := c.offset(j)
+				if off < prev || off > int64(len(c.values)) {
+					return nil, 0, fmt.Errorf("column %q: invalid offset %d", f.name, off)
+				}
+				prev = off
+			}
+			continue
+		}
+
+		if c.values, err = buffer(); err != nil {
+			return nil, 0, fmt.Errorf("column %q: %s", f.name, err)
+		}
+		switch f.typ {
+		case arrowTypeBool:
+			width = 0
+			if len(c.values) < (n+7)/8 {
+				return nil, 0, fmt.Errorf("column %q: values buffer too short", f.name)
+			}
+		case arrowTypeInt:
+			width = f.bitWidth / 8
+		case arrowTypeFloatingPoint:
+			width = 4
+			if f.precision == arrowPrecisionDouble {
+				width = 8
+			}
+		case arrowTypeTimestamp:
+			width = 8
+		}
+		if len(c.values) < n*width {
+			return nil, 0, fmt.Errorf("column %q: values buffer too short", f.name)
+		}
+	}
+	return columns, n, nil
+}
+
+// null returns true if the value of row i is null.
+func (c *arrowColumn) null(i int) bool {
+	if c.field.typ == arrowTypeNull {
+		return true
+	}
+	return c.validity != nil && c.validity[i/8]&(1<<uint(i%8)) == 0
+}
+
+func (c *arrowColumn) offset(i int) int64 {
+	if c.field.typ == arrowTypeLargeUtf8 {
+		return int64(binary.LittleEndian.Uint64(c.offsets[8*i:]))
+	}
+	return int64(int32(binary.LittleEndian.Uint32(c.offsets[4*i:])))
+}
+
+// string returns the value of row i of a Utf8 or LargeUtf8 column.
+func (c *arrowColumn) string(i int) string {
+	return string(c.values[c.offset(i):c.offset(i+1)])
+}
+
+// int64 returns the value of row i of a Timestamp column, or of an Int
+// column of 64 bits.
+func (c *arrowColumn) int64(i int) int64 {
+	return int64(binary.LittleEndian.Uint64(c.values[8*i:]))
+}
+
+// value returns the value of row i as a field value. Unsigned integers of 64
+// bits are returned as uint64, other integers as int64.
+func (c *arrowColumn) value(i int) interface{} {
+	switch c.field.typ {
+	case arrowTypeBool:
+		return c.values[i/8]&(1<<uint(i%8)) != 0
+	case arrowTypeFloatingPoint:
+		if c.field.precision == arrowPrecisionDouble {
+			return math.Float64frombits(binary.LittleEndian.Uint64(c.values[8*i:]))
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(c.values[4*i:])))
+	case arrowTypeUtf8, arrowTypeLargeUtf8:
+		return c.string(i)
+	}
+
+	// Int
+	var v uint64
+	switch c.field.bitWidth {
+	case 8:
+		v = uint64(c.values[i])
+		if c.field.signed {
+			return int64(int8(v))
+		}
+	case 16:
+		v = uint64(binary.LittleEndian.Uint16(c.values[2*i:]))
+		if c.field.signed {
+			return int64(int16(v))
+		}
+	case 32:
+		v = uint64(binary.LittleEndian.Uint32(c.values[4*i:]))
+		if c.field.signed {
+			return int64(int32(v))
+		}
+	default:
+		v = binary.LittleEndian.Uint64(c.values[8*i:])
+		if c.field.signed {
+			return int64(v)
+		}
+		return v
+	}
+	return int64(v)
+}
+
+// flatbuffer reads the tables of a FlatBuffers buffer, as used for the
+// headers of Arrow IPC messages. Reads out of the buffer set err and return
+// zero values, so err is checked once decoding is done.
+type flatbuffer struct {
+	buf []byte
+	err error
+}
+
+func (b *flatbuffer) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+func (b *flatbuffer) read(pos, n int) []byte {
+	if b.err != nil || pos < 0 || n < 0 || pos > len(b.buf)-n {
+		b.fail(errTruncatedFlatbuffer)
+		return nil
+	}
+	return b.buf[pos : pos+n]
+}
+
+func (b *flatbuffer) uint16(pos int) uint16 {
+	if v := b.read(pos, 2); v != nil {
+		return binary.LittleEndian.Uint16(v)
+	}
+	return 0
+}
+
+func (b *flatbuffer) uint32(pos int) uint32 {
+	if v := b.read(pos, 4); v != nil {
+		return binary.LittleEndian.Uint32(v)
+	}
+	return 0
+}
+
+func (b *flatbuffer) int64(pos int) int64 {
+	if v := b.read(pos, 8); v != nil {
+		return int64(binary.LittleEndian.Uint64(v))
+	}
+	return 0
+}
+
+// indirect returns the position referenced by the offset at pos.
+func (b *flatbuffer) indirect(pos int) int {
+	off := b.uint32(pos)
+	if b.err != nil || off > uint32(len(b.buf)) {
+		b.fail(errTruncatedFlatbuffer)
+		return 0
+	}
+	return pos + int(off)
+}
+
+// root returns the root table of the buffer.
+func (b *flatbuffer) root() fbTable {
+	return b.table(b.indirect(0))
+}
+
+// table returns the table at pos.
+func (b *flatbuffer) table(pos int) fbTable {
+	vtable := pos - int(int32(b.uint32(pos)))
+	return fbTable{b: b, pos: pos, vtable: vtable, vsize: int(b.uint16(vtable))}
+}
+
+// fbTable is a table of a flatbuffer.
+type fbTable struct {
+	b      *flatbuffer
+	pos    int
+	vtable int
+	vsize  int
+}
+
+// field returns the position of field i, or 0 if the field is absent.
+func (t fbTable) field(i int) int {
+	o := 4 + 2*i
+	if t.b.err != nil || o+2 > t.vsize {
+		return 0
+	}
+	if off := t.b.uint16(t.vtable + o); off != 0 {
+		return t.pos + int(off)
+	}
+	return 0
+}
+
+func (t fbTable) uint8(i int, def uint8) uint8 {
+	if pos := t.field(i); pos != 0 {
+		if v := t.b.read(pos, 1); v != nil {
+			return v[0]
+		}
+	}
+	return def
+}
+
+func (t fbTable) bool(i int) bool {
+	return t.uint8(i, 0) != 0
+}
+
+func (t fbTable) int16(i int, def int16) int16 {
+	if pos := t.field(i); pos != 0 {
+		return int16(t.b.uint16(pos))
+	}
+	return def
+}
+
+func (t fbTable) int32(i int, def int32) int32 {
+	if pos := t.field(i); pos != 0 {
+		return int32(t.b.uint32(pos))
+	}
+	return def
+}
+
+func (t fbTable) int64(i int, def int64) int64 {
+	if pos := t.field(i); pos != 0 {
+		return t.b.int64(pos)
+	}
+	return def
+}
+
+// table returns the table referenced by field i.
+func (t fbTable) table(i int) (fbTable, bool) {
+	pos := t.field(i)
+	if pos == 0 {
+		return fbTable{}, false
+	}
+	pos = t.b.indirect(pos)
+	if t.b.err != nil {
+		return fbTable{}, false
+	}
+	return t.b.table(pos), true
+}
+
+// string returns the string referenced by field i.
+func (t fbTable) string(i int) string {
+	pos := t.field(i)
+	if pos == 0 {
+		return ""
+	}
+	pos = t.b.indirect(pos)
+	return string(t.b.read(pos+4, int(t.b.uint32(pos))))
+}
+
+// vector returns the position of the first element of the vector referenced
+// by field i, and its number of elements.
+func (t fbTable) vector(i int) (int, int) {
+	pos := t.field(i)
+	if pos == 0 {
+		return 0, 0
+	}
+	pos = t.b.indirect(pos)
+	n := int(t.b.uint32(pos))
+	if t.b.err != nil || n > len(t.b.buf) {
+		t.b.fail(errTruncatedFlatbuffer)
+		return 0, 0
+	}
+	return pos + 4, n
+}
@@ -0,0 +1,406 @@
+package grpc
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeArrowMessage_Schema(t *testing.T) {
+	columns := []testColumn{
+		{name: "time", typ: "timestamp_ms"},
+		{name: "host", typ: "utf8"},
+		{name: "msg", typ: "utf8", metadata: map[string]string{columnMetadataKey: "field"}},
+		{name: "count", typ: "uint16"},
+		{name: "load", typ: "float32"},
+		{name: "up", typ: "bool"},
+		{name: "none", typ: "null"},
+	}
+	msg, err := decodeArrowMessage(testSchemaMessage(columns))
+	if err != nil {
+		t.Fatal(err)
+	} else if msg.headerType != arrowHeaderSchema {
+		t.Fatalf("unexpected header type %d", msg.headerType)
+	}
+
+	exp := []arrowField{
+		{name: "time", typ: arrowTypeTimestamp, unit: "ms"},
+		{name: "host", typ: arrowTypeUtf8},
+		{name: "msg", typ: arrowTypeUtf8, metadata: map[string]string{columnMetadataKey: "field"}},
+		{name: "count", typ: arrowTypeInt, bitWidth: 16},
+		{name: "load", typ: arrowTypeFloatingPoint, precision: arrowPrecisionSingle},
+		{name: "up", typ: arrowTypeBool},
+		{name: "none", typ: arrowTypeNull},
+	}
+	if !reflect.DeepEqual(msg.schema.fields, exp) {
+		t.Fatalf("unexpected fields:\ngot      %+v\nexpected %+v", msg.schema.fields, exp)
+	}
+}
+
+func TestArrowColumns(t *testing.T) {
+	columns := []testColumn{
+		{name: "i8", typ: "int8", values: []interface{}{int64(-1), nil, int64(3)}},
+		{name: "i32", typ: "int32", values: []interface{}{int64(-100000), int64(0), int64(7)}},
+		{name: "u64", typ: "uint64", values: []interface{}{uint64(math.MaxUint64), uint64(1), nil}},
+		{name: "f32", typ: "float32", values: []interface{}{1.5, nil, -2.25}},
+		{name: "f64", typ: "float64", values: []interface{}{0.1, 2.0, 3.0}},
+		{name: "b", typ: "bool", values: []interface{}{true, false, nil}},
+		{name: "s", typ: "utf8", values: []interface{}{"a", nil, "ccc"}},
+		{name: "ls", typ: "large_utf8", values: []interface{}{"", "bb", "c"}},
+		{name: "n", typ: "null", values: []interface{}{nil, nil, nil}},
+	}
+
+	schema, err := decodeArrowMessage(testSchemaMessage(columns))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, body := testRecordBatch(columns)
+	batch, err := decodeArrowMessage(header)
+	if err != nil {
+		t.Fatal(err)
+	} else if batch.headerType != arrowHeaderRecordBatch {
+		t.Fatalf("unexpected header type %d", batch.headerType)
+	}
+
+	got, rows, err := arrowColumns(schema.schema, batch.batch, body)
+	if err != nil {
+		t.Fatal(err)
+	} else if rows != 3 {
+		t.Fatalf("got %d rows, expected 3", rows)
+	}
+	for i, c := range columns {
+		for j, exp := range c.values {
+			if exp == nil {
+				if !got[i].null(j) {
+					t.Errorf("%s[%d]: expected null", c.name, j)
+				}
+				continue
+			}
+			if got[i].null(j) {
+				t.Errorf("%s[%d]: unexpected null", c.name, j)
+			} else if v := got[i].value(j); v != exp {
+				t.Errorf("%s[%d]: got %v (%T), expected %v (%T)", c.name, j, v, v, exp, exp)
+			}
+		}
+	}
+
+	// Buffers must lie in the body.
+	if _, _, err := arrowColumns(schema.schema, batch.batch, body[:len(body)-8]); err == nil {
+		t.Fatal("expected error for truncated body")
+	}
+}
+
+// Ensure malformed messages are rejected without panicking.
+func TestDecodeArrowMessage_Truncated(t *testing.T) {
+	columns := []testColumn{
+		{name: "time", typ: "timestamp_ns", values: []interface{}{int64(1)}},
+		{name: "host", typ: "utf8", values: []interface{}{"a"}},
+		{name: "v", typ: "float64", values: []interface{}{1.0}},
+	}
+	schema := testSchemaMessage(columns)
+	header, body := testRecordBatch(columns)
+
+	for _, buf := range [][]byte{schema, header} {
+		for i := 0; i < len(buf); i++ {
+			decodeArrowMessage(buf[:i])
+
+			// Corrupt a byte of the message.
+			b := append([]byte(nil), buf...)
+			b[i] ^= 0xff
+			if msg, err := decodeArrowMessage(b); err == nil && msg.batch != nil {
+				if s, err := decodeArrowMessage(schema); err == nil {
+					arrowColumns(s.schema, msg.batch, body)
+				}
+			}
+		}
+	}
+}
+
+func TestArrowField_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		f   arrowField
+		err bool
+	}{
+		{f: arrowField{typ: arrowTypeInt, bitWidth: 64}},
+		{f: arrowField{typ: arrowTypeInt, bitWidth: 12}, err: true},
+		{f: arrowField{typ: arrowTypeFloatingPoint, precision: 0}, err: true},
+		{f: arrowField{typ: arrowTypeTimestamp}, err: true},
+		{f: arrowField{typ: arrowTypeUtf8, dictionary: true}, err: true},
+		{f: arrowField{typ: 12}, err: true}, // List
+	} {
+		if err := tt.f.validate(); tt.err && err == nil {
+			t.Errorf("%+v: expected error", tt.f)
+		} else if !tt.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", tt.f, err)
+		}
+	}
+}
+
+// testColumn is a column of a test record batch. Nil values are null.
+type testColumn struct {
+	name     string
+	typ      string
+	metadata map[string]string
+	values   []interface{}
+}
+
+// testSchemaMessage returns an Arrow IPC schema message for columns.
+func testSchemaMessage(columns []testColumn) []byte {
+	var fields []fbField
+	for _, c := range columns {
+		c := c
+		var typ byte
+		var typeFields []fbField
+		switch c.typ {
+		case "null":
+			typ = arrowTypeNull
+		case "int8", "int16", "int32", "int64", "uint16", "uint64":
+			width := map[string]int32{"int8": 8, "int16": 16, "int32": 32, "int64": 64, "uint16": 16, "uint64": 64}[c.typ]
+			typ, typeFields = arrowTypeInt, []fbField{fbInt32(width), fbBool(c.typ[0] == 'i')}
+		case "float32":
+			typ, typeFields = arrowTypeFloatingPoint, []fbField{fbInt16(arrowPrecisionSingle)}
+		case "float64":
+			typ, typeFields = arrowTypeFloatingPoint, []fbField{fbInt16(arrowPrecisionDouble)}
+		case "utf8":
+			typ = arrowTypeUtf8
+		case "large_utf8":
+			typ = arrowTypeLargeUtf8
+		case "bool":
+			typ = arrowTypeBool
+		case "timestamp_ms":
+			typ, typeFields = arrowTypeTimestamp, []fbField{fbInt16(1)}
+		case "timestamp_ns":
+			typ, typeFields = arrowTypeTimestamp, []fbField{fbInt16(3), fbString("UTC")}
+		default:
+			panic("unknown test column type " + c.typ)
+		}
+
+		var metadata [][]fbField
+		for k, v := range c.metadata {
+			metadata = append(metadata, []fbField{fbString(k), fbString(v)})
+		}
+		fields = append(fields, fbTableField([]fbField{
+			fbString(c.name),
+			fbBool(true),
+			fbUint8(typ),
+			fbTableField(typeFields),
+			{},
+			fbTables(nil),
+			fbTables(metadata),
+		}))
+	}
+
+	var vector [][]fbField
+	for _, f := range fields {
+		vector = append(vector, f.table)
+	}
+	return testMessage(arrowHeaderSchema, []fbField{fbInt16(0), fbTables(vector)}, 0)
+}
+
+// testRecordBatch returns an Arrow IPC record batch message for columns,
+// and its body.
+func testRecordBatch(columns []testColumn) (header, body []byte) {
+	var nodes, buffers []byte
+	addBuffer := func(b []byte) {
+		buffers = append(buffers, le64(int64(len(body)))...)
+		buffers = append(buffers, le64(int64(len(b)))...)
+		body = append(body, b...)
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	n := len(columns[0].values)
+	for _, c := range columns {
+		validity := make([]byte, (n+7)/8)
+		var nulls int
+		for i, v := range c.values {
+			if v == nil {
+				nulls++
+			} else {
+				validity[i/8] |= 1 << uint(i%8)
+			}
+		}
+		nodes = append(nodes, le64(int64(n))...)
+		nodes = append(nodes, le64(int64(nulls))...)
+		if c.typ == "null" {
+			continue
+		}
+		if nulls == 0 {
+			validity = nil
+		}
+		addBuffer(validity)
+
+		var offsets, values []byte
+		for i, v := range c.values {
+			switch c.typ {
+			case "int8":
+				x, _ := v.(int64)
+				values = append(values, byte(x))
+			case "int16", "uint16":
+				x, _ := v.(int64)
+				values = append(values, le64(x)[:2]...)
+			case "int32":
+				x, _ := v.(int64)
+				values = append(values, le64(x)[:4]...)
+			case "int64", "timestamp_ms", "timestamp_ns":
+				x, _ := v.(int64)
+				values = append(values, le64(x)...)
+			case "uint64":
+				x, _ := v.(uint64)
+				values = append(values, le64(int64(x))...)
+			case "float32":
+				x, _ := v.(float64)
+				values = append(values, le64(int64(math.Float32bits(float32(x))))[:4]...)
+			case "float64":
+				x, _ := v.(float64)
+				values = append(values, le64(int64(math.Float64bits(x)))...)
+			case "bool":
+				if i%8 == 0 {
+					values = append(values, 0)
+				}
+				if x, _ := v.(bool); x {
+					values[i/8] |= 1 << uint(i%8)
+				}
+			case "utf8", "large_utf8":
+				width := 4
+				if c.typ == "large_utf8" {
+					width = 8
+				}
+				if i == 0 {
+					offsets = append(offsets, make([]byte, width)...)
+				}
+				x, _ := v.(string)
+				values = append(values, x...)
+				offsets = append(offsets, le64(int64(len(values)))[:width]...)
+			}
+		}
+		if offsets != nil {
+			addBuffer(offsets)
+		}
+		addBuffer(values)
+	}
+
+	header = testMessage(arrowHeaderRecordBatch, []fbField{
+		fbInt64(int64(n)),
+		fbStructs(nodes, len(columns)),
+		fbStructs(buffers, len(buffers)/16),
+	}, int64(len(body)))
+	return header, body
+}
+
+// testMessage returns an Arrow IPC message with the header table.
+func testMessage(headerType byte, header []fbField, bodyLength int64) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	root := b.table([]fbField{
+		fbInt16(arrowMetadataV5),
+		fbUint8(headerType),
+		fbTableField(header),
+		fbInt64(bodyLength),
+	})
+	binary.LittleEndian.PutUint32(b.buf, uint32(root))
+	return b.buf
+}
+
+func le64(v int64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(v))
+	return b
+}
+
+// fbField is a field of a test flatbuffer table. A zero fbField is absent.
+type fbField struct {
+	scalar []byte
+	ref    func(b *fbBuilder) int // Writes the referenced object.
+	table  []fbField
+}
+
+func fbUint8(v byte) fbField { return fbField{scalar: []byte{v}} }
+func fbBool(v bool) fbField {
+	if v {
+		return fbUint8(1)
+	}
+	return fbUint8(0)
+}
+func fbInt16(v int16) fbField { return fbField{scalar: le64(int64(v))[:2]} }
+func fbInt32(v int32) fbField { return fbField{scalar: le64(int64(v))[:4]} }
+func fbInt64(v int64) fbField { return fbField{scalar: le64(v)} }
+
+func fbString(s string) fbField {
+	return fbField{ref: func(b *fbBuilder) int {
+		pos := len(b.buf)
+		b.buf = append(b.buf, le64(int64(len(s)))[:4]...)
+		b.buf = append(append(b.buf, s...), 0)
+		return pos
+	}}
+}
+
+func fbTableField(fields []fbField) fbField {
+	return fbField{table: fields, ref: func(b *fbBuilder) int { return b.table(fields) }}
+}
+
+func fbTables(tables [][]fbField) fbField {
+	return fbField{ref: func(b *fbBuilder) int {
+		pos := len(b.buf)
+		b.buf = append(b.buf, le64(int64(len(tables)))[:4]...)
+		b.buf = append(b.buf, make([]byte, 4*len(tables))...)
+		for i, t := range tables {
+			at, ref := pos+4+4*i, b.table(t)
+			binary.LittleEndian.PutUint32(b.buf[at:], uint32(ref-at))
+		}
+		return pos
+	}}
+}
+
+func fbStructs(data []byte, n int) fbField {
+	return fbField{ref: func(b *fbBuilder) int {
+		pos := len(b.buf)
+		b.buf = append(b.buf, le64(int64(n))[:4]...)
+		b.buf = append(b.buf, data...)
+		return pos
+	}}
+}
+
+// fbBuilder builds flatbuffers for tests. Unlike the FlatBuffers library,
+// it writes objects after the offsets referencing them, which readers
+// accept as offsets are unsigned.
+type fbBuilder struct {
+	buf []byte
+}
+
+// table writes a table and the objects it references, and returns its
+// position.
+func (b *fbBuilder) table(fields []fbField) int {
+	vtable := len(b.buf)
+	offsets := make([]int, len(fields))
+	size := 4
+	for i, f := range fields {
+		switch {
+		case f.scalar != nil:
+			offsets[i], size = size, size+len(f.scalar)
+		case f.ref != nil:
+			offsets[i], size = size, size+4
+		}
+	}
+
+	b.buf = append(b.buf, le64(int64(4 + 2*len(fields)))[:2]...)
+	b.buf = append(b.buf, le64(int64(size))[:2]...)
+	for _, off := range offsets {
+		b.buf = append(b.buf, le64(int64(off))[:2]...)
+	}
+
+	pos := len(b.buf)
+	b.buf = append(b.buf, le64(int64(pos - vtable))[:4]...)
+	b.buf = append(b.buf, make([]byte, size-4)...)
+	for i, f := range fields {
+		switch {
+		case f.scalar != nil:
+			copy(b.buf[pos+offsets[i]:], f.scalar)
+		case f.ref != nil:
+			at, ref := pos+offsets[i], f.ref(b)
+			binary.LittleEndian.PutUint32(b.buf[at:], uint32(ref-at))
+		}
+	}
+	return pos
+}
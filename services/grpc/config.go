@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"errors"
+
+	"github.com/influxdata/influxdb/monitor/diagnostics"
+)
+
+const (
+	// DefaultBindAddress is the default address to bind to.
+	DefaultBindAddress = ":8090"
+
+	// DefaultMaxMessageSize is the default size of the largest request
+	// message, after decompression. It matches the default of gRPC servers.
+	DefaultMaxMessageSize = 4 * 1024 * 1024
+
+	// DefaultMaxConcurrentStreams is the default number of concurrent calls
+	// allowed on a connection.
+	DefaultMaxConcurrentStreams = 250
+
+	// DefaultCertificate is the default location of the certificate used when
+	// TLS is enabled.
+	DefaultCertificate = "/etc/ssl/influxdb.pem"
+)
+
+// Config represents the configuration of the gRPC write service.
+type Config struct {
+	Enabled     bool   `toml:"enabled"`
+	BindAddress string `toml:"bind-address"`
+	AuthEnabled bool   `toml:"auth-enabled"`
+
+	MaxMessageSize       int `toml:"max-message-size"`
+	MaxConcurrentStreams int `toml:"max-concurrent-streams"`
+
+	// TLS settings. PrivateKey defaults to Certificate. Without TLS, clients
+	// must connect with HTTP/2 over cleartext TCP.
+	TLSEnabled  bool   `toml:"tls-enabled"`
+	Certificate string `toml:"certificate"`
+	PrivateKey  string `toml:"private-key"`
+}
+
+// NewConfig returns a new Config with default settings.
+func NewConfig() Config {
+	return Config{
+		BindAddress:          DefaultBindAddress,
+		MaxMessageSize:       DefaultMaxMessageSize,
+		MaxConcurrentStreams: DefaultMaxConcurrentStreams,
+		Certificate:          DefaultCertificate,
+	}
+}
+
+// Validate returns an error if the config is invalid.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.BindAddress == "" {
+		return errors.New("bind-address must be specified")
+	} else if c.MaxMessageSize <= 0 {
+		return errors.New("max-message-size must be positive")
+	} else if c.MaxConcurrentStreams <= 0 {
+		return errors.New("max-concurrent-streams must be positive")
+	} else if c.TLSEnabled && c.Certificate == "" {
+		return errors.New("certificate must be specified when tls-enabled is set")
+	}
+	return nil
+}
+
+// Diagnostics returns a diagnostics representation of a subset of the Config.
+func (c Config) Diagnostics() (*diagnostics.Diagnostics, error) {
+	if !c.Enabled {
+		return diagnostics.RowFromMap(map[string]interface{}{
+			"enabled": false,
+		}), nil
+	}
+
+	return diagnostics.RowFromMap(map[string]interface{}{
+		"enabled":                true,
+		"bind-address":           c.BindAddress,
+		"auth-enabled":           c.AuthEnabled,
+		"max-message-size":       c.MaxMessageSize,
+		"max-concurrent-streams": c.MaxConcurrentStreams,
+		"tls-enabled":            c.TLSEnabled,
+	}), nil
+}
@@ -0,0 +1,69 @@
+package grpc_test
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdata/influxdb/services/grpc"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	c := grpc.NewConfig()
+	if _, err := toml.Decode(`
+enabled = true
+bind-address = ":9090"
+auth-enabled = true
+max-message-size = 1048576
+max-concurrent-streams = 10
+tls-enabled = true
+certificate = "/etc/ssl/grpc.pem"
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled: %v", c.Enabled)
+	} else if c.BindAddress != ":9090" {
+		t.Fatalf("unexpected bind address: %s", c.BindAddress)
+	} else if !c.AuthEnabled {
+		t.Fatalf("unexpected auth enabled: %v", c.AuthEnabled)
+	} else if c.MaxMessageSize != 1048576 {
+		t.Fatalf("unexpected max message size: %d", c.MaxMessageSize)
+	} else if c.MaxConcurrentStreams != 10 {
+		t.Fatalf("unexpected max concurrent streams: %d", c.MaxConcurrentStreams)
+	} else if !c.TLSEnabled {
+		t.Fatalf("unexpected tls enabled: %v", c.TLSEnabled)
+	} else if c.Certificate != "/etc/ssl/grpc.pem" {
+		t.Fatalf("unexpected certificate: %s", c.Certificate)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, test := range []struct {
+		fn  func(c *grpc.Config)
+		err bool
+	}{
+		{fn: func(c *grpc.Config) {}},
+		{fn: func(c *grpc.Config) { c.Enabled = false; c.BindAddress = "" }},
+		{fn: func(c *grpc.Config) { c.BindAddress = "" }, err: true},
+		{fn: func(c *grpc.Config) { c.MaxMessageSize = 0 }, err: true},
+		{fn: func(c *grpc.Config) { c.MaxConcurrentStreams = -1 }, err: true},
+		{fn: func(c *grpc.Config) { c.TLSEnabled = true }},
+		{fn: func(c *grpc.Config) { c.TLSEnabled = true; c.Certificate = "" }, err: true},
+	} {
+		c := grpc.NewConfig()
+		c.Enabled = true
+		test.fn(&c)
+		if err := c.Validate(); test.err && err == nil {
+			t.Errorf("%+v: expected error", c)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", c, err)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+)
+
+// doPutPath is the path of the DoPut method of the Arrow Flight service.
+const doPutPath = "/arrow.flight.protocol.FlightService/DoPut"
+
+// flightDescriptorPath is the type of FlightDescriptors holding a path.
+const flightDescriptorPath = 1
+
+// columnMetadataKey is the key of the metadata of a column setting whether
+// the column holds a tag or a field.
+const columnMetadataKey = "influxdb.column"
+
+// flightData is a decoded FlightData message.
+type flightData struct {
+	descriptor []string // Path of the FlightDescriptor, nil if absent.
+	header     []byte
+	body       []byte
+}
+
+// decodeFlightData decodes a FlightData message. The descriptor must hold a
+// path.
+func decodeFlightData(buf []byte) (*flightData, error) {
+	data := &flightData{}
+	err := walkProtobuf(buf, func(field int, wire int, v uint64, b []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			path, err := decodeFlightDescriptor(b)
+			if err != nil {
+				return err
+			}
+			data.descriptor = path
+		case field == 2 && wire == wireBytes:
+			data.header = b
+		case field == 1000 && wire == wireBytes:
+			data.body = b
+		default:
+			// Ignore app_metadata and unknown fields.
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func decodeFlightDescriptor(buf []byte) ([]string, error) {
+	var (
+		typ  uint64
+		path = []string{}
+	)
+	err := walkProtobuf(buf, func(field int, wire int, v uint64, b []byte) error {
+		switch {
+		case field == 1 && wire == wireVarint:
+			typ = v
+		case field == 3 && wire == wireBytes:
+			path = append(path, string(b))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	} else if typ != flightDescriptorPath {
+		return nil, errors.New("flight descriptor must be a path")
+	}
+	return path, nil
+}
+
+// flightDestination is where the points of a DoPut call are written, given
+// by the path of its descriptor: [database, measurement] or [database,
+// retention policy, measurement].
+type flightDestination struct {
+	database        string
+	retentionPolicy string
+	measurement     string
+}
+
+func parseFlightDestination(path []string) (*flightDestination, error) {
+	switch len(path) {
+	case 2:
+		return &flightDestination{database: path[0], measurement: path[1]}, nil
+	case 3:
+		return &flightDestination{database: path[0], retentionPolicy: path[1], measurement: path[2]}, nil
+	default:
+		return nil, fmt.Errorf("flight descriptor path must be [database, measurement] or [database, retention policy, measurement], got %q", path)
+	}
+}
+
+// flightLayout sets the role of the columns of a schema.
+type flightLayout struct {
+	time   int // Index of the time column, -1 if absent.
+	tags   []int
+	fields []int
+}
+
+// newFlightLayout returns the layout of schema. The column named "time"
+// holds the timestamps, string columns hold tags unless their metadata marks
+// them as fields, and the other columns hold fields.
+func newFlightLayout(schema *arrowSchema) (*flightLayout, error) {
+	l := &flightLayout{time: -1}
+	for i := range schema.fields {
+		f := &schema.fields[i]
+		if err := f.validate(); err != nil {
+			return nil, err
+		}
+
+		role := f.metadata[columnMetadataKey]
+		switch role {
+		case "", "tag", "field":
+		default:
+			return nil, fmt.Errorf("column %q: invalid %s %q", f.name, columnMetadataKey, role)
+		}
+
+		switch {
+		case f.name == "time":
+			if f.typ != arrowTypeTimestamp && !(f.typ == arrowTypeInt && f.bitWidth == 64 && f.signed) {
+				return nil, errors.New(`column "time" must be a timestamp or a signed 64-bit integer`)
+			}
+			l.time = i
+		case f.typ == arrowTypeNull:
+			// Columns of nulls have no values to write.
+		case f.typ == arrowTypeUtf8 || f.typ == arrowTypeLargeUtf8:
+			if role == "field" {
+				l.fields = append(l.fields, i)
+			} else {
+				l.tags = append(l.tags, i)
+			}
+		case role == "tag":
+			return nil, fmt.Errorf("column %q: tag columns must hold strings", f.name)
+		default:
+			l.fields = append(l.fields, i)
+		}
+	}
+
+	if len(l.fields) == 0 {
+		return nil, errors.New("schema has no field column")
+	}
+	return l, nil
+}
+
+// points returns a point for every row of columns having a field value.
+// Rows without a timestamp are assigned the time now.
+func (l *flightLayout) points(measurement string, columns []arrowColumn, rows int, now time.Time) ([]models.Point, error) {
+	points := make([]models.Point, 0, rows)
+	for i := 0; i < rows; i++ {
+		fields := make(models.Fields, len(l.fields))
+		for _, j := range l.fields {
+			if c := &columns[j]; !c.null(i) {
+				fields[c.field.name] = c.value(i)
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		tags := make(map[string]string, len(l.tags))
+		for _, j := range l.tags {
+			if c := &columns[j]; !c.null(i) {
+				if v := c.string(i); v != "" {
+					tags[c.field.name] = v
+				}
+			}
+		}
+
+		t := now
+		if l.time >= 0 && !columns[l.time].null(i) {
+			unit := columns[l.time].field.unit
+			if unit == "" {
+				unit = "n"
+			}
+			var err error
+			if t, err = models.SafeCalcTime(columns[l.time].int64(i), unit); err != nil {
+				return nil, fmt.Errorf("row %d: %s", i, err)
+			}
+		}
+
+		pt, err := models.NewPoint(measurement, models.NewTags(tags), fields, t)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %s", i, err)
+		}
+		points = append(points, pt)
+	}
+	return points, nil
+}
+
+// doPut serves the DoPut method of the Arrow Flight service. The descriptor
+// of the first message sets where points are written, and every record batch
+// following the schema is written as it is received. No PutResult is sent.
+func (s *Service) doPut(user meta.User, mr *messageReader) error {
+	var (
+		dest   *flightDestination
+		schema *arrowSchema
+		layout *flightLayout
+	)
+	for {
+		buf, err := mr.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		atomic.AddInt64(&s.stats.MessagesReceived, 1)
+		atomic.AddInt64(&s.stats.BytesReceived, int64(len(buf)))
+
+		data, err := decodeFlightData(buf)
+		if err != nil {
+			return errorf(codeInvalidArgument, "%s", err)
+		}
+
+		if dest == nil {
+			if data.descriptor == nil {
+				return errorf(codeInvalidArgument, "first message must carry a flight descriptor")
+			} else if dest, err = parseFlightDestination(data.descriptor); err != nil {
+				return errorf(codeInvalidArgument, "%s", err)
+			} else if err := s.authorizeWrite(user, dest.database); err != nil {
+				return err
+			}
+		}
+		if len(data.header) == 0 {
+			continue // Metadata only.
+		}
+
+		msg, err := decodeArrowMessage(data.header)
+		if err != nil {
+			return errorf(codeInvalidArgument, "%s", err)
+		}
+		switch msg.headerType {
+		case arrowHeaderSchema:
+			if schema != nil {
+				return errorf(codeInvalidArgument, "schema sent twice")
+			} else if layout, err = newFlightLayout(msg.schema); err != nil {
+				return errorf(codeInvalidArgument, "%s", err)
+			}
+			schema = msg.schema
+		case arrowHeaderRecordBatch:
+			if schema == nil {
+				return errorf(codeInvalidArgument, "record batch sent before the schema")
+			}
+			atomic.AddInt64(&s.stats.RecordBatchesReceived, 1)
+
+			columns, rows, err := arrowColumns(schema, msg.batch, data.body)
+			if err != nil {
+				return errorf(codeInvalidArgument, "%s", err)
+			}
+			points, err := layout.points(dest.measurement, columns, rows, time.Now().UTC())
+			if err != nil {
+				return errorf(codeInvalidArgument, "%s", err)
+			}
+			if _, err := s.writeToDatabase(dest.database, dest.retentionPolicy, models.ConsistencyLevelOne, user, points); err != nil {
+				return err
+			}
+		case arrowHeaderDictionaryBatch:
+			return errorf(codeUnimplemented, "dictionary-encoded columns are not supported")
+		default:
+			return errorf(codeUnimplemented, "unsupported arrow message type %d", msg.headerType)
+		}
+	}
+
+	if dest == nil {
+		return errorf(codeInvalidArgument, "missing flight descriptor")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package grpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlightLayout_Points(t *testing.T) {
+	columns := []testColumn{
+		{name: "time", typ: "timestamp_ms", values: []interface{}{int64(1000), nil, int64(3000), int64(4000)}},
+		{name: "host", typ: "utf8", values: []interface{}{"a", "b", nil, ""}},
+		{name: "msg", typ: "utf8", metadata: map[string]string{columnMetadataKey: "field"}, values: []interface{}{"x", nil, nil, "z"}},
+		{name: "value", typ: "float64", values: []interface{}{1.0, 2.0, nil, 4.0}},
+		{name: "n", typ: "null", values: []interface{}{nil, nil, nil, nil}},
+	}
+
+	msg, err := decodeArrowMessage(testSchemaMessage(columns))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := newFlightLayout(msg.schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, body := testRecordBatch(columns)
+	batch, err := decodeArrowMessage(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols, rows, err := arrowColumns(msg.schema, batch.batch, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := l.points("m", cols, rows, time.Unix(0, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The third row has no field and is skipped.
+	exp := []string{
+		`m,host=a msg="x",value=1 1000000000`,
+		`m,host=b value=2 5`,
+		`m msg="z",value=4 4000000000`,
+	}
+	if len(points) != len(exp) {
+		t.Fatalf("got %d points, expected %d", len(points), len(exp))
+	}
+	for i := range exp {
+		if got := points[i].String(); got != exp[i] {
+			t.Errorf("point %d: got %q, expected %q", i, got, exp[i])
+		}
+	}
+}
+
+func TestNewFlightLayout_Invalid(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		columns []testColumn
+	}{
+		{"no field", []testColumn{{name: "time", typ: "int64"}, {name: "host", typ: "utf8"}}},
+		{"time type", []testColumn{{name: "time", typ: "float64"}, {name: "v", typ: "float64"}}},
+		{"tag type", []testColumn{{name: "v", typ: "float64", metadata: map[string]string{columnMetadataKey: "tag"}}}},
+		{"role", []testColumn{{name: "v", typ: "float64", metadata: map[string]string{columnMetadataKey: "time"}}}},
+	} {
+		msg, err := decodeArrowMessage(testSchemaMessage(tt.columns))
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if _, err := newFlightLayout(msg.schema); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestParseFlightDestination(t *testing.T) {
+	if d, err := parseFlightDestination([]string{"db0", "cpu"}); err != nil {
+		t.Fatal(err)
+	} else if *d != (flightDestination{database: "db0", measurement: "cpu"}) {
+		t.Fatalf("unexpected destination: %+v", d)
+	}
+	if d, err := parseFlightDestination([]string{"db0", "rp0", "cpu"}); err != nil {
+		t.Fatal(err)
+	} else if *d != (flightDestination{database: "db0", retentionPolicy: "rp0", measurement: "cpu"}) {
+		t.Fatalf("unexpected destination: %+v", d)
+	}
+	if _, err := parseFlightDestination([]string{"db0"}); err == nil {
+		t.Fatal("expected error")
+	}
+}
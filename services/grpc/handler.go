@@ -0,0 +1,301 @@
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/coordinator"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/tsdb"
+	"go.uber.org/zap"
+)
+
+// Paths of the methods of the Write service.
+const (
+	writePointsPath       = "/influxdb.write.Write/WritePoints"
+	writePointsStreamPath = "/influxdb.write.Write/WritePointsStream"
+)
+
+// Status codes of gRPC calls.
+const (
+	codeOK                = 0
+	codeInvalidArgument   = 3
+	codeDeadlineExceeded  = 4
+	codeNotFound          = 5
+	codePermissionDenied  = 7
+	codeResourceExhausted = 8
+	codeUnimplemented     = 12
+	codeInternal          = 13
+	codeUnauthenticated   = 16
+)
+
+// statusError is an error ending a call with a status code.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+func errorf(code int, format string, a ...interface{}) error {
+	return &statusError{code: code, msg: fmt.Sprintf(format, a...)}
+}
+
+// serveCall serves a call of the Write service. The status of the call is
+// sent in the trailers of the response.
+func (s *Service) serveCall(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.stats.Requests, 1)
+	atomic.AddInt64(&s.stats.ActiveRequests, 1)
+	defer atomic.AddInt64(&s.stats.ActiveRequests, -1)
+
+	h := w.Header()
+	h.Set("Content-Type", "application/grpc")
+	h.Set("Grpc-Accept-Encoding", "gzip")
+	h.Add("Trailer", "Grpc-Status")
+	h.Add("Trailer", "Grpc-Message")
+
+	resp, err := s.call(r)
+	if err == nil {
+		w.WriteHeader(http.StatusOK)
+		for _, msg := range resp {
+			var prefix [5]byte
+			binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+			w.Write(prefix[:])
+			w.Write(msg)
+		}
+		h.Set("Grpc-Status", strconv.Itoa(codeOK))
+		return
+	}
+
+	atomic.AddInt64(&s.stats.FailedRequests, 1)
+	serr, ok := err.(*statusError)
+	if !ok {
+		serr = &statusError{code: codeInternal, msg: err.Error()}
+	}
+	w.WriteHeader(http.StatusOK)
+	h.Set("Grpc-Status", strconv.Itoa(serr.code))
+	h.Set("Grpc-Message", encodeGRPCMessage(serr.msg))
+}
+
+// call reads the request messages of the call and writes their points. It
+// returns the encoded response messages.
+func (s *Service) call(r *http.Request) ([][]byte, error) {
+	switch r.URL.Path {
+	case writePointsPath, writePointsStreamPath, doPutPath:
+	default:
+		return nil, errorf(codeUnimplemented, "unknown method %s", r.URL.Path)
+	}
+
+	user, err := s.authenticate(r)
+	if err != nil {
+		atomic.AddInt64(&s.stats.AuthFail, 1)
+		return nil, err
+	}
+
+	mr := &messageReader{r: r.Body, encoding: r.Header.Get("Grpc-Encoding"), maxSize: s.config.MaxMessageSize}
+	switch r.URL.Path {
+	case writePointsPath:
+		return s.writePoints(user, mr, false)
+	case writePointsStreamPath:
+		return s.writePoints(user, mr, true)
+	default:
+		return nil, s.doPut(user, mr)
+	}
+}
+
+// writePoints serves WritePoints, or WritePointsStream if stream is set.
+func (s *Service) writePoints(user meta.User, mr *messageReader, stream bool) ([][]byte, error) {
+	var n, written int64
+	for {
+		buf, err := mr.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		atomic.AddInt64(&s.stats.MessagesReceived, 1)
+		atomic.AddInt64(&s.stats.BytesReceived, int64(len(buf)))
+
+		if n++; !stream && n > 1 {
+			return nil, errorf(codeUnimplemented, "%s expects a single request", writePointsPath)
+		}
+
+		pointsWritten, err := s.write(user, buf)
+		written += pointsWritten
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !stream && n == 0 {
+		return nil, errorf(codeUnimplemented, "%s expects a single request", writePointsPath)
+	}
+	return [][]byte{encodeWriteResponse(written)}, nil
+}
+
+// authenticate returns the user of the basic credentials of the request if
+// authentication is enabled.
+func (s *Service) authenticate(r *http.Request) (meta.User, error) {
+	if !s.config.AuthEnabled {
+		return nil, nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Basic ") {
+		return nil, errorf(codeUnauthenticated, "basic credentials are required")
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+	if err != nil {
+		return nil, errorf(codeUnauthenticated, "invalid basic credentials")
+	}
+	creds := strings.SplitN(string(b), ":", 2)
+	if len(creds) != 2 {
+		return nil, errorf(codeUnauthenticated, "invalid basic credentials")
+	}
+
+	user, err := s.MetaClient.Authenticate(creds[0], creds[1])
+	if err != nil {
+		return nil, errorf(codeUnauthenticated, "authorization failed")
+	}
+	return user, nil
+}
+
+// write writes the points of a WriteRequest message and returns the number
+// of points written.
+func (s *Service) write(user meta.User, buf []byte) (int64, error) {
+	req, err := decodeWriteRequest(buf, time.Now().UTC())
+	if err != nil {
+		return 0, errorf(codeInvalidArgument, "%s", err)
+	}
+
+	if err := s.authorizeWrite(user, req.database); err != nil {
+		return 0, err
+	}
+
+	consistency := models.ConsistencyLevelOne
+	if req.consistency != "" {
+		if consistency, err = models.ParseConsistencyLevel(req.consistency); err != nil {
+			return 0, errorf(codeInvalidArgument, "%s", err)
+		}
+	}
+
+	return s.writeToDatabase(req.database, req.retentionPolicy, consistency, user, req.points)
+}
+
+// authorizeWrite returns an error if database does not exist or user may not
+// write to it.
+func (s *Service) authorizeWrite(user meta.User, database string) error {
+	if database == "" {
+		return errorf(codeInvalidArgument, "database is required")
+	} else if s.MetaClient.Database(database) == nil {
+		return errorf(codeNotFound, "database not found: %q", database)
+	}
+
+	if s.config.AuthEnabled {
+		if err := s.WriteAuthorizer.AuthorizeWrite(user.ID(), database); err != nil {
+			return errorf(codePermissionDenied, "%q user is not authorized to write to database %q", user.ID(), database)
+		}
+	}
+	return nil
+}
+
+// writeToDatabase writes points and returns the number of points written.
+func (s *Service) writeToDatabase(database, retentionPolicy string, consistency models.ConsistencyLevel, user meta.User, points []models.Point) (int64, error) {
+	if len(points) == 0 {
+		return 0, nil
+	}
+
+	n := int64(len(points))
+	err := s.PointsWriter.WritePoints(database, retentionPolicy, consistency, user, points)
+	if werr, ok := err.(tsdb.PartialWriteError); ok {
+		atomic.AddInt64(&s.stats.PointsWrittenOK, n-int64(werr.Dropped))
+		atomic.AddInt64(&s.stats.PointsWrittenFail, int64(werr.Dropped))
+		return n - int64(werr.Dropped), errorf(codeInvalidArgument, "%s", werr)
+	} else if err != nil {
+		atomic.AddInt64(&s.stats.PointsWrittenFail, n)
+		switch {
+		case influxdb.IsClientError(err):
+			return 0, errorf(codeInvalidArgument, "%s", err)
+		case influxdb.IsAuthorizationError(err):
+			return 0, errorf(codePermissionDenied, "%s", err)
+		case err == coordinator.ErrTimeout:
+			return 0, errorf(codeDeadlineExceeded, "%s", err)
+		default:
+			s.Logger.Info("Failed to write points", zap.String("db", database), zap.Error(err))
+			return 0, errorf(codeInternal, "%s", err)
+		}
+	}
+	atomic.AddInt64(&s.stats.PointsWrittenOK, n)
+	return n, nil
+}
+
+// messageReader reads the length-prefixed messages of a call.
+type messageReader struct {
+	r        io.Reader
+	encoding string
+	maxSize  int
+}
+
+// next returns the next message. It returns io.EOF once the client has sent
+// every message.
+func (mr *messageReader) next() ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(mr.r, prefix[:]); err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, errorf(codeInternal, "reading message: %s", err)
+	}
+
+	compressed, size := prefix[0], binary.BigEndian.Uint32(prefix[1:])
+	if size > uint32(mr.maxSize) {
+		return nil, errorf(codeResourceExhausted, "message of %d bytes exceeds max-message-size", size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(mr.r, buf); err != nil {
+		return nil, errorf(codeInternal, "reading message: %s", err)
+	}
+
+	switch {
+	case compressed == 0:
+		return buf, nil
+	case compressed == 1 && mr.encoding == "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(buf))
+		if err != nil {
+			return nil, errorf(codeInternal, "decompressing message: %s", err)
+		}
+		buf, err := ioutil.ReadAll(io.LimitReader(gr, int64(mr.maxSize)+1))
+		if err != nil {
+			return nil, errorf(codeInternal, "decompressing message: %s", err)
+		} else if len(buf) > mr.maxSize {
+			return nil, errorf(codeResourceExhausted, "decompressed message exceeds max-message-size")
+		}
+		return buf, nil
+	default:
+		return nil, errorf(codeUnimplemented, "unsupported grpc-encoding %q", mr.encoding)
+	}
+}
+
+// encodeGRPCMessage percent-encodes msg as required for the grpc-message
+// trailer.
+func encodeGRPCMessage(msg string) string {
+	var b []byte
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c < ' ' || c > '~' || c == '%' {
+			b = append(b, fmt.Sprintf("%%%02X", c)...)
+		} else {
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
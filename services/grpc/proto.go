@@ -0,0 +1,216 @@
+package grpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// Protocol buffer wire types used by the messages of write.proto.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncatedProtobuf = errors.New("truncated protobuf message")
+
+// writeRequest is a decoded WriteRequest message.
+type writeRequest struct {
+	database        string
+	retentionPolicy string
+	precision       string
+	consistency     string
+	points          []models.Point
+}
+
+// decodeWriteRequest decodes a WriteRequest message. Points without a
+// timestamp are assigned the time now.
+func decodeWriteRequest(buf []byte, now time.Time) (*writeRequest, error) {
+	req := &writeRequest{}
+
+	// The precision may follow the points, so they are decoded last.
+	var points [][]byte
+	err := walkProtobuf(buf, func(field int, wire int, v uint64, b []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			req.database = string(b)
+		case field == 2 && wire == wireBytes:
+			req.retentionPolicy = string(b)
+		case field == 3 && wire == wireBytes:
+			req.precision = string(b)
+		case field == 4 && wire == wireBytes:
+			req.consistency = string(b)
+		case field == 5 && wire == wireBytes:
+			points = append(points, b)
+		default:
+			// Ignore unknown fields.
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.precision {
+	case "", "n", "u", "ms", "s", "m", "h":
+	default:
+		return nil, fmt.Errorf("invalid precision %q", req.precision)
+	}
+
+	req.points = make([]models.Point, 0, len(points))
+	for i, b := range points {
+		p, err := decodePoint(b, now, req.precision)
+		if err != nil {
+			return nil, fmt.Errorf("point %d: %s", i, err)
+		}
+		req.points = append(req.points, p)
+	}
+	return req, nil
+}
+
+// decodePoint decodes a single Point message.
+func decodePoint(buf []byte, now time.Time, precision string) (models.Point, error) {
+	var (
+		name   string
+		tags   []string
+		fields = make(models.Fields)
+		ts     int64
+		hasTS  bool
+	)
+
+	err := walkProtobuf(buf, func(field int, wire int, v uint64, b []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			name = string(b)
+		case field == 2 && wire == wireBytes:
+			tags = append(tags, string(b))
+		case field == 3 && wire == wireBytes:
+			key, value, err := decodeField(b)
+			if err != nil {
+				return err
+			}
+			fields[key] = value
+		case field == 4 && wire == wireVarint:
+			ts, hasTS = int64(v), true
+		default:
+			// Ignore unknown fields.
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	} else if name == "" {
+		return nil, errors.New("missing measurement")
+	} else if len(tags)%2 != 0 {
+		return nil, fmt.Errorf("missing value for tag %q", tags[len(tags)-1])
+	}
+
+	m := make(map[string]string, len(tags)/2)
+	for i := 0; i < len(tags); i += 2 {
+		m[tags[i]] = tags[i+1]
+	}
+
+	t := now
+	if hasTS {
+		if t, err = models.SafeCalcTime(ts, precision); err != nil {
+			return nil, err
+		}
+	}
+	return models.NewPoint(name, models.NewTags(m), fields, t)
+}
+
+// decodeField decodes a single Field message.
+func decodeField(buf []byte) (string, interface{}, error) {
+	var (
+		key   string
+		value interface{}
+	)
+	err := walkProtobuf(buf, func(field int, wire int, v uint64, b []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			key = string(b)
+		case field == 2 && wire == wireFixed64:
+			value = math.Float64frombits(v)
+		case field == 3 && wire == wireVarint:
+			value = int64(v)
+		case field == 4 && wire == wireBytes:
+			value = string(b)
+		case field == 5 && wire == wireVarint:
+			value = v != 0
+		default:
+			// Ignore unknown fields.
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	} else if value == nil {
+		return "", nil, fmt.Errorf("missing value for field %q", key)
+	}
+	return key, value, nil
+}
+
+// encodeWriteResponse encodes a WriteResponse message.
+func encodeWriteResponse(pointsWritten int64) []byte {
+	if pointsWritten == 0 {
+		return nil // Default values are not encoded.
+	}
+	buf := make([]byte, 1+binary.MaxVarintLen64)
+	buf[0] = 1<<3 | wireVarint
+	return buf[:1+binary.PutUvarint(buf[1:], uint64(pointsWritten))]
+}
+
+// walkProtobuf calls fn for every field of the protobuf message in buf.
+// Varint and fixed-width values are passed as v, length-delimited values as b.
+func walkProtobuf(buf []byte, fn func(field int, wire int, v uint64, b []byte) error) error {
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return errTruncatedProtobuf
+		}
+		buf = buf[n:]
+
+		var (
+			field = int(key >> 3)
+			wire  = int(key & 7)
+			v     uint64
+			b     []byte
+		)
+		switch wire {
+		case wireVarint:
+			if v, n = binary.Uvarint(buf); n <= 0 {
+				return errTruncatedProtobuf
+			}
+			buf = buf[n:]
+		case wireFixed64:
+			if len(buf) < 8 {
+				return errTruncatedProtobuf
+			}
+			v, buf = binary.LittleEndian.Uint64(buf), buf[8:]
+		case wireFixed32:
+			if len(buf) < 4 {
+				return errTruncatedProtobuf
+			}
+			v, buf = uint64(binary.LittleEndian.Uint32(buf)), buf[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(buf)
+			if n <= 0 || uint64(len(buf)-n) < l {
+				return errTruncatedProtobuf
+			}
+			b, buf = buf[n:n+int(l)], buf[n+int(l):]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wire)
+		}
+
+		if err := fn(field, wire, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package grpc
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDecodeWriteRequest(t *testing.T) {
+	field := func(key string, valueField, wire int, value []byte) []byte {
+		return append(pbBytes(1, []byte(key)), append(pbKey(valueField, wire), value...)...)
+	}
+
+	float := make([]byte, 8)
+	binary.LittleEndian.PutUint64(float, math.Float64bits(1.5))
+
+	var point []byte
+	point = append(point, pbBytes(1, []byte("cpu"))...)
+	point = append(point, pbBytes(2, []byte("host"))...)
+	point = append(point, pbBytes(2, []byte("a"))...)
+	point = append(point, pbBytes(3, field("f", 2, wireFixed64, float))...)
+	point = append(point, pbBytes(3, field("i", 3, wireVarint, pbUvarint(7)))...)
+	point = append(point, pbBytes(3, field("s", 4, wireBytes, append(pbUvarint(1), 'x')))...)
+	point = append(point, pbBytes(3, field("b", 5, wireVarint, pbUvarint(1)))...)
+	point = append(point, append(pbKey(4, wireVarint), pbUvarint(2)...)...)
+
+	// The precision follows the points and an unknown field must be skipped.
+	buf := append(pbBytes(1, []byte("db0")), pbBytes(2, []byte("rp0"))...)
+	buf = append(buf, pbBytes(5, point)...)
+	buf = append(buf, pbBytes(5, append(pbBytes(1, []byte("mem")), pbBytes(3, field("v", 3, wireVarint, pbUvarint(1)))...))...)
+	buf = append(buf, pbBytes(3, []byte("s"))...)
+	buf = append(buf, append(pbKey(15, wireVarint), pbUvarint(1)...)...)
+
+	now := time.Unix(0, 100)
+	req, err := decodeWriteRequest(buf, now)
+	if err != nil {
+		t.Fatal(err)
+	} else if req.database != "db0" || req.retentionPolicy != "rp0" || req.precision != "s" {
+		t.Fatalf("unexpected request: %+v", req)
+	} else if len(req.points) != 2 {
+		t.Fatalf("got %d points, expected 2", len(req.points))
+	}
+	if got, exp := req.points[0].String(), `cpu,host=a b=true,f=1.5,i=7i,s="x" 2000000000`; got != exp {
+		t.Fatalf("unexpected point: got %q, expected %q", got, exp)
+	} else if got, exp := req.points[1].String(), `mem v=1i 100`; got != exp {
+		t.Fatalf("unexpected point: got %q, expected %q", got, exp)
+	}
+
+	if _, err := decodeWriteRequest(buf[:len(buf)-4], now); err == nil {
+		t.Fatal("expected error for truncated message")
+	}
+}
+
+func TestDecodeWriteRequest_Invalid(t *testing.T) {
+	point := func(b ...[]byte) []byte {
+		var p []byte
+		for _, v := range b {
+			p = append(p, v...)
+		}
+		return pbBytes(5, p)
+	}
+	value := pbBytes(3, append(pbBytes(1, []byte("v")), append(pbKey(3, wireVarint), 1)...))
+
+	for _, tt := range []struct {
+		name string
+		buf  []byte
+	}{
+		{"precision", pbBytes(3, []byte("ns"))},
+		{"odd tags", point(pbBytes(1, []byte("cpu")), pbBytes(2, []byte("host")), value)},
+		{"missing value", point(pbBytes(1, []byte("cpu")), pbBytes(3, pbBytes(1, []byte("v"))))},
+		{"missing measurement", point(value)},
+		{"wire type", append(pbKey(1, 3), 0)},
+	} {
+		if _, err := decodeWriteRequest(tt.buf, time.Now()); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestEncodeWriteResponse(t *testing.T) {
+	if b := encodeWriteResponse(0); len(b) != 0 {
+		t.Fatalf("unexpected encoding of 0 points: %x", b)
+	}
+	if got, exp := encodeWriteResponse(300), append(pbKey(1, wireVarint), pbUvarint(300)...); string(got) != string(exp) {
+		t.Fatalf("got %x, expected %x", got, exp)
+	}
+}
+
+func pbUvarint(v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, v)]
+}
+
+func pbKey(field, wire int) []byte {
+	return pbUvarint(uint64(field<<3 | wire))
+}
+
+func pbBytes(field int, b []byte) []byte {
+	return append(append(pbKey(field, wireBytes), pbUvarint(uint64(len(b)))...), b...)
+}
@@ -0,0 +1,285 @@
+// Package grpc provides a gRPC service for writing points encoded with
+// protocol buffers or as Arrow record batches.
+package grpc // import "github.com/influxdata/influxdb/services/grpc"
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+)
+
+// statistics gathered by the grpc package.
+const (
+	statRequests          = "req"
+	statRequestsActive    = "reqActive"
+	statRequestsFail      = "reqFail"
+	statMessagesReceived  = "messagesRx"
+	statBytesReceived     = "bytesRx"
+	statPointsWrittenOK   = "pointsWrittenOK"
+	statPointsWrittenFail = "pointsWrittenFail"
+	statAuthFail          = "authFail"
+	statConnectionsActive = "connsActive"
+
+	statRecordBatchesReceived = "recordBatchesRx"
+)
+
+// Service serves the Write service described in write.proto, and the DoPut
+// method of the Arrow Flight service, over HTTP/2. gRPC is implemented
+// directly on top of HTTP/2, without a gRPC library.
+type Service struct {
+	wg sync.WaitGroup
+
+	mu   sync.Mutex
+	ln   net.Listener
+	addr net.Addr
+	done chan struct{} // Is the service closing or closed?
+
+	connsMu sync.Mutex
+	conns   map[net.Conn]struct{}
+
+	config Config
+	server *http2.Server
+
+	MetaClient interface {
+		Database(name string) *meta.DatabaseInfo
+		Authenticate(username, password string) (ui meta.User, err error)
+	}
+
+	WriteAuthorizer interface {
+		AuthorizeWrite(username, database string) error
+	}
+
+	PointsWriter interface {
+		WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error
+	}
+
+	Logger *zap.Logger
+	stats  *Statistics
+}
+
+// NewService returns a new instance of Service.
+func NewService(c Config) *Service {
+	return &Service{
+		config: c,
+		server: &http2.Server{
+			MaxConcurrentStreams: uint32(c.MaxConcurrentStreams),
+		},
+		conns:  make(map[net.Conn]struct{}),
+		Logger: zap.NewNop(),
+		stats:  &Statistics{},
+	}
+}
+
+// Open starts the service.
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		return nil // Already open.
+	}
+
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+
+	var (
+		ln  net.Listener
+		err error
+	)
+	if s.config.TLSEnabled {
+		key := s.config.PrivateKey
+		if key == "" {
+			key = s.config.Certificate
+		}
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(s.config.Certificate, key); err == nil {
+			ln, err = tls.Listen("tcp", s.config.BindAddress, &tls.Config{
+				Certificates: []tls.Certificate{cert},
+				NextProtos:   []string{http2.NextProtoTLS},
+			})
+		}
+	} else {
+		ln, err = net.Listen("tcp", s.config.BindAddress)
+	}
+	if err != nil {
+		return err
+	}
+	s.ln, s.addr = ln, ln.Addr()
+	s.done = make(chan struct{})
+
+	s.Logger.Info("Listening",
+		zap.Stringer("addr", s.addr),
+		zap.Bool("tls", s.config.TLSEnabled))
+
+	s.wg.Add(1)
+	go s.serve(ln)
+	return nil
+}
+
+// serve accepts connections until the listener is closed.
+func (s *Service) serve(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if opErr, ok := err.(*net.OpError); ok && !opErr.Temporary() {
+			s.Logger.Info("gRPC listener closed")
+			return
+		} else if err != nil {
+			s.Logger.Info("Error accepting connection", zap.Error(err))
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn serves the calls of a connection. Cleartext connections must
+// start with the HTTP/2 connection preface, as gRPC clients do.
+func (s *Service) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	if !s.trackConnection(conn) {
+		return
+	}
+	defer s.untrackConnection(conn)
+	atomic.AddInt64(&s.stats.ActiveConnections, 1)
+	defer atomic.AddInt64(&s.stats.ActiveConnections, -1)
+
+	if tc, ok := conn.(*tls.Conn); ok {
+		if err := tc.Handshake(); err != nil {
+			s.Logger.Info("TLS handshake failed",
+				zap.Stringer("remote_addr", conn.RemoteAddr()), zap.Error(err))
+			return
+		} else if p := tc.ConnectionState().NegotiatedProtocol; p != http2.NextProtoTLS {
+			s.Logger.Info("Client does not support HTTP/2",
+				zap.Stringer("remote_addr", conn.RemoteAddr()))
+			return
+		}
+	}
+
+	s.server.ServeConn(conn, &http2.ServeConnOpts{Handler: s})
+}
+
+// trackConnection registers conn so it is closed along with the service. It
+// returns false if the service is closing.
+func (s *Service) trackConnection(conn net.Conn) bool {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	if s.conns == nil {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Service) untrackConnection(conn net.Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	delete(s.conns, conn)
+}
+
+// Close closes the listener and the open connections, interrupting the calls
+// in progress.
+func (s *Service) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done == nil {
+		return nil // Already closed.
+	}
+	close(s.done)
+	s.ln.Close()
+
+	s.connsMu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+	s.connsMu.Unlock()
+
+	s.wg.Wait()
+
+	s.connsMu.Lock()
+	s.conns = make(map[net.Conn]struct{})
+	s.connsMu.Unlock()
+
+	s.done, s.ln = nil, nil
+	return nil
+}
+
+// Addr returns the address the service listens on.
+func (s *Service) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+// WithLogger sets the logger on the service.
+func (s *Service) WithLogger(log *zap.Logger) {
+	s.Logger = log.With(zap.String("service", "grpc"))
+}
+
+// Statistics maintains statistics for the gRPC service.
+type Statistics struct {
+	Requests          int64
+	ActiveRequests    int64
+	FailedRequests    int64
+	MessagesReceived  int64
+	BytesReceived     int64
+	PointsWrittenOK   int64
+	PointsWrittenFail int64
+	AuthFail          int64
+	ActiveConnections int64
+
+	RecordBatchesReceived int64
+}
+
+// Statistics returns statistics for periodic monitoring.
+func (s *Service) Statistics(tags map[string]string) []models.Statistic {
+	return []models.Statistic{{
+		Name: "grpc",
+		Tags: models.StatisticTags{"bind": s.config.BindAddress}.Merge(tags),
+		Values: map[string]interface{}{
+			statRequests:          atomic.LoadInt64(&s.stats.Requests),
+			statRequestsActive:    atomic.LoadInt64(&s.stats.ActiveRequests),
+			statRequestsFail:      atomic.LoadInt64(&s.stats.FailedRequests),
+			statMessagesReceived:  atomic.LoadInt64(&s.stats.MessagesReceived),
+			statBytesReceived:     atomic.LoadInt64(&s.stats.BytesReceived),
+			statPointsWrittenOK:   atomic.LoadInt64(&s.stats.PointsWrittenOK),
+			statPointsWrittenFail: atomic.LoadInt64(&s.stats.PointsWrittenFail),
+			statAuthFail:          atomic.LoadInt64(&s.stats.AuthFail),
+			statConnectionsActive: atomic.LoadInt64(&s.stats.ActiveConnections),
+
+			statRecordBatchesReceived: atomic.LoadInt64(&s.stats.RecordBatchesReceived),
+		},
+	}}
+}
+
+// isGRPCContentType returns true if typ is the content type of gRPC calls
+// using protocol buffers.
+func isGRPCContentType(typ string) bool {
+	if i := strings.IndexByte(typ, ';'); i >= 0 {
+		typ = typ[:i]
+	}
+	return typ == "application/grpc" || typ == "application/grpc+proto"
+}
+
+// ServeHTTP serves a gRPC call.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || !isGRPCContentType(r.Header.Get("Content-Type")) {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	s.serveCall(w, r)
+}
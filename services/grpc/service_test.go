@@ -0,0 +1,440 @@
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"golang.org/x/net/http2"
+)
+
+// Ensure points of a unary call are written.
+func TestService_WritePoints(t *testing.T) {
+	t.Parallel()
+
+	s := NewTestService(nil)
+	var written []models.Point
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error {
+		if database != "db0" || retentionPolicy != "rp0" {
+			t.Errorf("unexpected destination: %s.%s", database, retentionPolicy)
+		} else if consistencyLevel != models.ConsistencyLevelOne {
+			t.Errorf("unexpected consistency level: %v", consistencyLevel)
+		}
+		written = append(written, points...)
+		return nil
+	}
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	resp := s.Call(t, writePointsPath, nil, false, testRequest("db0", 2))
+	if resp.Status != codeOK {
+		t.Fatalf("unexpected status %d: %s", resp.Status, resp.Message)
+	} else if len(resp.Messages) != 1 {
+		t.Fatalf("got %d response messages, expected 1", len(resp.Messages))
+	} else if got, exp := resp.Messages[0], encodeWriteResponse(2); !bytes.Equal(got, exp) {
+		t.Fatalf("unexpected response: got %x, expected %x", got, exp)
+	}
+	if len(written) != 2 {
+		t.Fatalf("got %d points written, expected 2", len(written))
+	} else if got, exp := written[1].String(), "cpu,host=server01 value=1i 1"; got != exp {
+		t.Fatalf("unexpected point: got %q, expected %q", got, exp)
+	}
+
+	// A unary call only accepts a single request.
+	resp = s.Call(t, writePointsPath, nil, false, testRequest("db0", 1), testRequest("db0", 1))
+	if resp.Status != codeUnimplemented {
+		t.Fatalf("unexpected status %d: %s", resp.Status, resp.Message)
+	}
+}
+
+// Ensure points of every request of a streaming call are written, including
+// compressed requests.
+func TestService_WritePointsStream(t *testing.T) {
+	t.Parallel()
+
+	s := NewTestService(nil)
+	var written int
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error {
+		written += len(points)
+		return nil
+	}
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	resp := s.Call(t, writePointsStreamPath, nil, true, testRequest("db0", 3), testRequest("db0", 2), testRequest("db0", 0))
+	if resp.Status != codeOK {
+		t.Fatalf("unexpected status %d: %s", resp.Status, resp.Message)
+	} else if len(resp.Messages) != 1 || !bytes.Equal(resp.Messages[0], encodeWriteResponse(5)) {
+		t.Fatalf("unexpected response: %x", resp.Messages)
+	} else if written != 5 {
+		t.Fatalf("got %d points written, expected 5", written)
+	}
+
+	stats := s.Service.Statistics(nil)[0].Values
+	if got, exp := stats[statMessagesReceived], int64(3); got != exp {
+		t.Fatalf("got %v messages received, expected %d", got, exp)
+	} else if got, exp := stats[statPointsWrittenOK], int64(5); got != exp {
+		t.Fatalf("got %v points written, expected %d", got, exp)
+	}
+}
+
+// Ensure calls fail with the expected status.
+func TestService_Errors(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.MaxMessageSize = 1024
+	s := NewTestService(&c)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error {
+		return errors.New("write failed")
+	}
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	for _, tt := range []struct {
+		name   string
+		path   string
+		req    []byte
+		status int
+	}{
+		{"unknown method", "/influxdb.write.Write/Query", testRequest("db0", 1), codeUnimplemented},
+		{"invalid message", writePointsPath, []byte{0xff}, codeInvalidArgument},
+		{"database required", writePointsPath, testRequest("", 1), codeInvalidArgument},
+		{"database not found", writePointsPath, testRequest("missing", 1), codeNotFound},
+		{"too large", writePointsPath, testRequest("db0", 100), codeResourceExhausted},
+		{"write failed", writePointsPath, testRequest("db0", 1), codeInternal},
+	} {
+		if resp := s.Call(t, tt.path, nil, false, tt.req); resp.Status != tt.status {
+			t.Errorf("%s: got status %d (%s), expected %d", tt.name, resp.Status, resp.Message, tt.status)
+		}
+	}
+}
+
+// Ensure credentials are required when authentication is enabled.
+func TestService_Auth(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.AuthEnabled = true
+	s := NewTestService(&c)
+	s.MetaClient.AuthenticateFn = func(username, password string) (meta.User, error) {
+		if username != "alice" || password != "secret" {
+			return nil, meta.ErrAuthenticate
+		}
+		return &meta.UserInfo{Name: username}, nil
+	}
+	s.Service.WriteAuthorizer = authorizerFunc(func(username, database string) error {
+		if database != "db0" {
+			return errors.New("not authorized")
+		}
+		return nil
+	})
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	auth := func(username, password string) http.Header {
+		r, _ := http.NewRequest("POST", "/", nil)
+		r.SetBasicAuth(username, password)
+		return r.Header
+	}
+
+	if resp := s.Call(t, writePointsPath, nil, false, testRequest("db0", 1)); resp.Status != codeUnauthenticated {
+		t.Fatalf("unexpected status %d: %s", resp.Status, resp.Message)
+	}
+	if resp := s.Call(t, writePointsPath, auth("alice", "wrong"), false, testRequest("db0", 1)); resp.Status != codeUnauthenticated {
+		t.Fatalf("unexpected status %d: %s", resp.Status, resp.Message)
+	}
+	if resp := s.Call(t, writePointsPath, auth("alice", "secret"), false, testRequest("db1", 1)); resp.Status != codePermissionDenied {
+		t.Fatalf("unexpected status %d: %s", resp.Status, resp.Message)
+	}
+	if resp := s.Call(t, writePointsPath, auth("alice", "secret"), false, testRequest("db0", 1)); resp.Status != codeOK {
+		t.Fatalf("unexpected status %d: %s", resp.Status, resp.Message)
+	}
+
+	if got, exp := s.Service.Statistics(nil)[0].Values[statAuthFail], int64(2); got != exp {
+		t.Fatalf("got %v auth failures, expected %d", got, exp)
+	}
+}
+
+// Ensure the record batches of a DoPut call are written.
+func TestService_DoPut(t *testing.T) {
+	t.Parallel()
+
+	s := NewTestService(nil)
+	var written []models.Point
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error {
+		if database != "db0" || retentionPolicy != "rp0" {
+			t.Errorf("unexpected destination: %s.%s", database, retentionPolicy)
+		}
+		written = append(written, points...)
+		return nil
+	}
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	batch := func(host string, values ...float64) []testColumn {
+		columns := []testColumn{{name: "time", typ: "timestamp_ms"}, {name: "host", typ: "utf8"}, {name: "value", typ: "float64"}}
+		for i, v := range values {
+			columns[0].values = append(columns[0].values, int64(i))
+			columns[1].values = append(columns[1].values, host)
+			columns[2].values = append(columns[2].values, v)
+		}
+		return columns
+	}
+
+	header1, body1 := testRecordBatch(batch("a", 1, 2))
+	header2, body2 := testRecordBatch(batch("b", 3))
+	resp := s.Call(t, doPutPath, nil, false,
+		testFlightData([]string{"db0", "rp0", "cpu"}, testSchemaMessage(batch("")), nil),
+		testFlightData(nil, header1, body1),
+		testFlightData(nil, header2, body2),
+	)
+	if resp.Status != codeOK {
+		t.Fatalf("unexpected status %d: %s", resp.Status, resp.Message)
+	} else if len(resp.Messages) != 0 {
+		t.Fatalf("got %d response messages, expected none", len(resp.Messages))
+	}
+
+	exp := []string{"cpu,host=a value=1 0", "cpu,host=a value=2 1000000", "cpu,host=b value=3 0"}
+	if len(written) != len(exp) {
+		t.Fatalf("got %d points written, expected %d", len(written), len(exp))
+	}
+	for i := range exp {
+		if got := written[i].String(); got != exp[i] {
+			t.Fatalf("point %d: got %q, expected %q", i, got, exp[i])
+		}
+	}
+	if got, exp := s.Service.Statistics(nil)[0].Values[statRecordBatchesReceived], int64(2); got != exp {
+		t.Fatalf("got %v record batches received, expected %d", got, exp)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		reqs   [][]byte
+		status int
+	}{
+		{"no descriptor", [][]byte{testFlightData(nil, testSchemaMessage(batch("")), nil)}, codeInvalidArgument},
+		{"database not found", [][]byte{testFlightData([]string{"missing", "cpu"}, nil, nil)}, codeNotFound},
+		{"batch before schema", [][]byte{testFlightData([]string{"db0", "cpu"}, header1, body1)}, codeInvalidArgument},
+		{"empty", nil, codeInvalidArgument},
+	} {
+		if resp := s.Call(t, doPutPath, nil, false, tt.reqs...); resp.Status != tt.status {
+			t.Errorf("%s: got status %d (%s), expected %d", tt.name, resp.Status, resp.Message, tt.status)
+		}
+	}
+}
+
+// Ensure the service can be reopened and closes open connections.
+func TestService_OpenClose(t *testing.T) {
+	t.Parallel()
+
+	s := NewTestService(nil)
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", s.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(http2.ClientPreface)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type TestService struct {
+	Service       *Service
+	MetaClient    *internal.MetaClientMock
+	WritePointsFn func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error
+}
+
+func NewTestService(c *Config) *TestService {
+	if c == nil {
+		defaultC := NewConfig()
+		c = &defaultC
+	}
+	c.Enabled = true
+	c.BindAddress = "127.0.0.1:0"
+
+	service := &TestService{
+		Service:    NewService(*c),
+		MetaClient: &internal.MetaClientMock{},
+	}
+	service.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		if name == "missing" {
+			return nil
+		}
+		return &meta.DatabaseInfo{Name: name}
+	}
+
+	if testing.Verbose() {
+		service.Service.WithLogger(logger.New(os.Stderr))
+	}
+
+	service.Service.MetaClient = service.MetaClient
+	service.Service.PointsWriter = service
+	return service
+}
+
+func (s *TestService) WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error {
+	if s.WritePointsFn == nil {
+		return nil
+	}
+	return s.WritePointsFn(database, retentionPolicy, consistencyLevel, user, points)
+}
+
+// TestResponse is the result of a call.
+type TestResponse struct {
+	Status   int
+	Message  string
+	Messages [][]byte
+}
+
+// Call calls the method at path over cleartext HTTP/2 with the requests,
+// compressed with gzip if compress is set.
+func (s *TestService) Call(t *testing.T, path string, header http.Header, compress bool, reqs ...[]byte) TestResponse {
+	t.Helper()
+
+	var body bytes.Buffer
+	for _, req := range reqs {
+		var prefix [5]byte
+		if compress {
+			var buf bytes.Buffer
+			gw := gzip.NewWriter(&buf)
+			gw.Write(req)
+			gw.Close()
+			req, prefix[0] = buf.Bytes(), 1
+		}
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(req)))
+		body.Write(prefix[:])
+		body.Write(req)
+	}
+
+	r, err := http.NewRequest("POST", "http://"+s.Service.Addr().String()+path, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("TE", "trailers")
+	if compress {
+		r.Header.Set("Grpc-Encoding", "gzip")
+	}
+
+	tr := &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}
+	defer tr.CloseIdleConnections()
+
+	resp, err := tr.RoundTrip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected HTTP status: %d", resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result TestResponse
+	for len(b) >= 5 {
+		n := binary.BigEndian.Uint32(b[1:5])
+		result.Messages = append(result.Messages, b[5:5+n])
+		b = b[5+n:]
+	}
+	if result.Status, err = strconv.Atoi(resp.Trailer.Get("Grpc-Status")); err != nil {
+		t.Fatalf("invalid grpc-status: %s", err)
+	}
+	result.Message = resp.Trailer.Get("Grpc-Message")
+	return result
+}
+
+// testRequest returns a WriteRequest message with n points.
+func testRequest(database string, n int) []byte {
+	req := pbBytes(1, []byte(database))
+	req = append(req, pbBytes(2, []byte("rp0"))...)
+	for i := 0; i < n; i++ {
+		var point []byte
+		point = append(point, pbBytes(1, []byte("cpu"))...)
+		point = append(point, pbBytes(2, []byte("host"))...)
+		point = append(point, pbBytes(2, []byte("server01"))...)
+		point = append(point, pbBytes(3, append(pbBytes(1, []byte("value")), append(pbKey(3, wireVarint), pbUvarint(uint64(i))...)...))...)
+		point = append(point, append(pbKey(4, wireVarint), pbUvarint(uint64(i))...)...)
+		req = append(req, pbBytes(5, point)...)
+	}
+	return req
+}
+
+// testFlightData returns a FlightData message. The descriptor is left out
+// if path is nil.
+func testFlightData(path []string, header, body []byte) []byte {
+	var buf []byte
+	if path != nil {
+		desc := append(pbKey(1, wireVarint), pbUvarint(flightDescriptorPath)...)
+		for _, p := range path {
+			desc = append(desc, pbBytes(3, []byte(p))...)
+		}
+		buf = append(buf, pbBytes(1, desc)...)
+	}
+	if header != nil {
+		buf = append(buf, pbBytes(2, header)...)
+	}
+	if body != nil {
+		buf = append(buf, pbBytes(1000, body)...)
+	}
+	return buf
+}
+
+type authorizerFunc func(username, database string) error
+
+func (fn authorizerFunc) AuthorizeWrite(username, database string) error {
+	return fn(username, database)
+}
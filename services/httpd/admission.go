@@ -0,0 +1,177 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// errWriteQueueFull is returned when a write request cannot be admitted
+	// and the queue of waiting requests is full.
+	errWriteQueueFull = errors.New("too many concurrent write requests")
+
+	// errWriteQueueTimeout is returned when a write request is not admitted
+	// within the enqueued write timeout.
+	errWriteQueueTimeout = errors.New("timed out waiting for concurrent write requests")
+)
+
+// writeAdmission limits the number of write requests in flight and the bytes
+// of their bodies. Requests beyond the limits wait in a queue, in order, until
+// earlier requests complete.
+type writeAdmission struct {
+	maxRequests int   // 0 if unlimited.
+	maxBytes    int64 // 0 if unlimited.
+	maxQueued   int
+	timeout     time.Duration // 0 if requests wait until admitted.
+
+	mu       sync.Mutex
+	requests int
+	bytes    int64
+	queue    []*writeWaiter
+
+	rejected int64 // Number of requests rejected, updated atomically.
+}
+
+// writeWaiter is a write request waiting to be admitted.
+type writeWaiter struct {
+	n     int64
+	ready chan struct{} // Closed once the request is admitted.
+}
+
+// newWriteAdmission returns the admission control of c, or nil if neither the
+// number of write requests nor their bytes are limited.
+func newWriteAdmission(c Config) *writeAdmission {
+	if c.MaxConcurrentWriteLimit == 0 && c.MaxInflightWriteBytes == 0 {
+		return nil
+	}
+	return &writeAdmission{
+		maxRequests: c.MaxConcurrentWriteLimit,
+		maxBytes:    int64(c.MaxInflightWriteBytes),
+		maxQueued:   c.MaxEnqueuedWriteLimit,
+		timeout:     time.Duration(c.EnqueuedWriteTimeout),
+	}
+}
+
+// acquire waits for a write request of n bytes to be admitted, until ctx is
+// done or the timeout expires. It returns a function releasing the request
+// once it completes. Requests larger than the bytes limit are admitted while
+// no other request is in flight.
+func (a *writeAdmission) acquire(ctx context.Context, n int64) (func(), error) {
+	if a.maxBytes > 0 && n > a.maxBytes {
+		n = a.maxBytes
+	}
+	release := func() {
+		a.mu.Lock()
+		a.requests--
+		a.bytes -= n
+		a.admit()
+		a.mu.Unlock()
+	}
+
+	a.mu.Lock()
+	if len(a.queue) == 0 && a.fits(n) {
+		a.requests++
+		a.bytes += n
+		a.mu.Unlock()
+		return release, nil
+	} else if len(a.queue) >= a.maxQueued {
+		a.mu.Unlock()
+		atomic.AddInt64(&a.rejected, 1)
+		return nil, errWriteQueueFull
+	}
+	w := &writeWaiter{n: n, ready: make(chan struct{})}
+	a.queue = append(a.queue, w)
+	a.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if a.timeout > 0 {
+		t := time.NewTimer(a.timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		return release, nil
+	case <-timeout:
+		err = errWriteQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	select {
+	case <-w.ready:
+		// Admitted while giving up.
+		return release, nil
+	default:
+	}
+	for i := range a.queue {
+		if a.queue[i] == w {
+			a.queue = append(a.queue[:i], a.queue[i+1:]...)
+			break
+		}
+	}
+	// The requests behind this one may fit now.
+	a.admit()
+	atomic.AddInt64(&a.rejected, 1)
+	return nil, err
+}
+
+// fits returns true if a request of n bytes is within the limits. It must be
+// called with the lock held.
+func (a *writeAdmission) fits(n int64) bool {
+	if a.maxRequests > 0 && a.requests >= a.maxRequests {
+		return false
+	}
+	return a.maxBytes == 0 || a.bytes+n <= a.maxBytes
+}
+
+// admit admits the waiting requests within the limits, in order. It must be
+// called with the lock held.
+func (a *writeAdmission) admit() {
+	for len(a.queue) > 0 && a.fits(a.queue[0].n) {
+		w := a.queue[0]
+		a.queue = a.queue[1:]
+		a.requests++
+		a.bytes += w.n
+		close(w.ready)
+	}
+}
+
+// stats returns the number of waiting requests, the bytes of the requests
+// in flight and the number of rejected requests.
+func (a *writeAdmission) stats() (queued int, bytes, rejected int64) {
+	if a == nil {
+		return 0, 0, 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.queue), a.bytes, atomic.LoadInt64(&a.rejected)
+}
+
+// admitWrite waits for the write request r to be admitted. It responds with
+// 429 Too Many Requests and returns false if the request is not admitted.
+// The bytes of requests without a content length are assumed to be the
+// maximum body size.
+func (h *Handler) admitWrite(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	if h.admission == nil {
+		return func() {}, true
+	}
+	n := r.ContentLength
+	if n < 0 {
+		n = int64(h.Config.MaxBodySize)
+	}
+	release, err := h.admission.acquire(r.Context(), n)
+	if err != nil {
+		h.httpError(w, err.Error(), http.StatusTooManyRequests)
+		return nil, false
+	}
+	return release, true
+}
@@ -0,0 +1,113 @@
+package httpd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Ensure write requests beyond the limit of requests wait in order, and are
+// rejected once the queue is full.
+func TestWriteAdmission_Requests(t *testing.T) {
+	a := newWriteAdmission(Config{MaxConcurrentWriteLimit: 1, MaxEnqueuedWriteLimit: 1})
+
+	release, err := a.acquire(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan func())
+	go func() {
+		release, err := a.acquire(context.Background(), 10)
+		if err != nil {
+			t.Error(err)
+		}
+		admitted <- release
+	}()
+	waitQueued(t, a, 1)
+
+	if _, err := a.acquire(context.Background(), 10); err != errWriteQueueFull {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	release()
+	(<-admitted)()
+	if queued, bytes, rejected := a.stats(); queued != 0 || bytes != 0 || rejected != 1 {
+		t.Fatalf("unexpected stats: queued=%d bytes=%d rejected=%d", queued, bytes, rejected)
+	}
+}
+
+// Ensure write requests beyond the limit of bytes wait until enough bytes
+// are released, and requests larger than the limit are admitted alone.
+func TestWriteAdmission_Bytes(t *testing.T) {
+	a := newWriteAdmission(Config{MaxInflightWriteBytes: 100, MaxEnqueuedWriteLimit: 10})
+
+	release1, err := a.acquire(context.Background(), 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release2, err := a.acquire(context.Background(), 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan func())
+	go func() {
+		release, err := a.acquire(context.Background(), 1000)
+		if err != nil {
+			t.Error(err)
+		}
+		admitted <- release
+	}()
+	waitQueued(t, a, 1)
+
+	release1()
+	select {
+	case <-admitted:
+		t.Fatal("request admitted beyond the limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	release2()
+	release := <-admitted
+	if _, bytes, _ := a.stats(); bytes != 100 {
+		t.Fatalf("unexpected bytes: %d", bytes)
+	}
+	release()
+}
+
+// Ensure waiting write requests give up after the timeout or once their
+// context is done.
+func TestWriteAdmission_Timeout(t *testing.T) {
+	a := newWriteAdmission(Config{MaxConcurrentWriteLimit: 1, MaxEnqueuedWriteLimit: 10, EnqueuedWriteTimeout: 1})
+
+	release, err := a.acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	if _, err := a.acquire(context.Background(), 0); err != errWriteQueueTimeout {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	a.timeout = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := a.acquire(ctx, 0); err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queued, _, rejected := a.stats(); queued != 0 || rejected != 2 {
+		t.Fatalf("unexpected stats: queued=%d rejected=%d", queued, rejected)
+	}
+}
+
+// waitQueued waits for n write requests to wait to be admitted.
+func waitQueued(t *testing.T, a *writeAdmission, n int) {
+	for i := 0; i < 1000; i++ {
+		if queued, _, _ := a.stats(); queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("write requests not queued")
+}
@@ -0,0 +1,431 @@
+package httpd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxql"
+	"go.uber.org/zap"
+)
+
+// auditTypes are the types of the entries of the audit log: the first
+// keyword of the statements of queries, and "write" for writes.
+var auditTypes = []string{
+	"alter", "create", "delete", "drop", "explain", "grant",
+	"kill", "revoke", "select", "set", "show", "write",
+}
+
+// isAuditType returns true if typ is a type of audit log entries.
+func isAuditType(typ string) bool {
+	for _, t := range auditTypes {
+		if strings.EqualFold(t, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditStatementType returns the type of the audit log entries of stmt.
+func auditStatementType(stmt influxql.Statement) string {
+	s := stmt.String()
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	return strings.ToLower(s)
+}
+
+// auditEntry is an entry of the audit log.
+type auditEntry struct {
+	Time         time.Time     `json:"time"`
+	Type         string        `json:"type"`
+	User         string        `json:"user"`
+	Addr         string        `json:"addr"`
+	ForwardedFor string        `json:"forwarded_for,omitempty"`
+	Database     string        `json:"database,omitempty"`
+	Statement    string        `json:"statement,omitempty"`
+	Status       string        `json:"status"`
+	Error        string        `json:"error,omitempty"`
+	Duration     time.Duration `json:"duration_ns"`
+}
+
+// auditLog records the queries, writes and admin statements of authenticated
+// users to a file of JSON lines and to the monitor. Entries are logged to the
+// logger if neither is set.
+type auditLog struct {
+	include map[string]bool
+	exclude map[string]bool
+
+	// Monitor records the entries as points, if set.
+	Monitor interface {
+		Enabled() bool
+		WritePoints(models.Points) error
+	}
+
+	Logger *zap.Logger
+
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+// newAuditLog returns an audit log of c. The file of the log, if any, must be
+// opened before entries are written to it.
+func newAuditLog(c AuditConfig) *auditLog {
+	l := &auditLog{
+		path:       c.Path,
+		maxSize:    int64(c.MaxSize),
+		maxBackups: c.MaxBackups,
+		Logger:     zap.NewNop(),
+	}
+	if len(c.Include) > 0 {
+		l.include = make(map[string]bool)
+		for _, typ := range c.Include {
+			l.include[strings.ToLower(typ)] = true
+		}
+	}
+	l.exclude = make(map[string]bool)
+	for _, typ := range c.Exclude {
+		l.exclude[strings.ToLower(typ)] = true
+	}
+	return l
+}
+
+// open opens the file of the log, appending to it if it exists.
+func (l *auditLog) open() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.path == "" {
+		return nil
+	}
+	return l.openFile(os.O_APPEND)
+}
+
+// openFile opens the file of the log with the given additional flag.
+func (l *auditLog) openFile(flag int) error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|flag, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, fi.Size()
+	return nil
+}
+
+// rotate moves the file of the log aside, shifting the names of the files
+// rotated before and removing the oldest, and starts a new one.
+func (l *auditLog) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	l.f = nil
+
+	if l.maxBackups == 0 {
+		if err := os.Remove(l.path); err != nil {
+			return err
+		}
+		return l.openFile(os.O_TRUNC)
+	}
+	for i := l.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	return l.openFile(os.O_TRUNC)
+}
+
+// close closes the file of the log.
+func (l *auditLog) close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// includes returns true if the entries of type typ are recorded.
+func (l *auditLog) includes(typ string) bool {
+	if l.include != nil && !l.include[typ] {
+		return false
+	}
+	return !l.exclude[typ]
+}
+
+// entry returns an entry of the request r of user.
+func (l *auditLog) entry(r *http.Request, user meta.User, database string) auditEntry {
+	addr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		addr = r.RemoteAddr
+	}
+	return auditEntry{
+		Time:         time.Now().UTC(),
+		User:         user.ID(),
+		Addr:         addr,
+		ForwardedFor: strings.Join(r.Header["X-Forwarded-For"], ","),
+		Database:     database,
+	}
+}
+
+// record writes e to the destinations of the log.
+func (l *auditLog) record(e *auditEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		l.Logger.Info("Unable to encode audit entry", zap.Error(err))
+		return
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	file := l.f != nil
+	if file {
+		if err := l.writeLine(b); err != nil {
+			l.Logger.Error("Unable to write audit entry", zap.Error(err))
+		}
+	}
+	l.mu.Unlock()
+
+	monitored := l.Monitor != nil && l.Monitor.Enabled()
+	if monitored {
+		tags := map[string]string{"type": e.Type, "user": e.User, "status": e.Status}
+		if e.Database != "" {
+			tags["db"] = e.Database
+		}
+		fields := map[string]interface{}{
+			"addr":       e.Addr,
+			"statement":  e.Statement,
+			"error":      e.Error,
+			"durationNs": int64(e.Duration),
+		}
+		p, err := models.NewPoint("audit", models.NewTags(tags), fields, e.Time)
+		if err != nil {
+			l.Logger.Info("Unable to record audit entry", zap.Error(err))
+		} else {
+			l.Monitor.WritePoints(models.Points{p})
+		}
+	}
+
+	if !file && !monitored {
+		l.Logger.Info("Audit",
+			zap.String("type", e.Type),
+			zap.String("user", e.User),
+			zap.String("addr", e.Addr),
+			zap.String("db", e.Database),
+			zap.String("statement", e.Statement),
+			zap.String("status", e.Status),
+			zap.String("error", e.Error),
+			zap.Duration("duration", e.Duration))
+	}
+}
+
+// writeLine appends the line b to the file of the log, rotating it first if
+// it would grow beyond its maximum size.
+func (l *auditLog) writeLine(b []byte) error {
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	return err
+}
+
+// auditWriter records the status of the response to an audited request.
+type auditWriter struct {
+	ResponseWriter
+	status int
+}
+
+func (w *auditWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// startWrite starts auditing the write request r of user to database. It
+// returns the writer the response must be written to, and a function
+// recording the write once the response is written. Only the requests of
+// authenticated users are audited.
+func (l *auditLog) startWrite(w http.ResponseWriter, r *http.Request, user meta.User, database string) (http.ResponseWriter, func()) {
+	rw, ok := w.(ResponseWriter)
+	if l == nil || user == nil || !l.includes("write") || !ok {
+		return w, func() {}
+	}
+	e := l.entry(r, user, database)
+	e.Type = "write"
+
+	aw := &auditWriter{ResponseWriter: rw, status: http.StatusOK}
+	start := time.Now()
+	return aw, func() {
+		e.Duration = time.Since(start)
+		e.Status = "ok"
+		if aw.status/100 != 2 {
+			e.Status = "error"
+			e.Error = aw.Header().Get("X-InfluxDB-Error")
+			if e.Error == "" {
+				e.Error = http.StatusText(aw.status)
+			}
+		}
+		l.record(&e)
+	}
+}
+
+// auditQuery records the statements of an audited query request.
+type auditQuery struct {
+	log   *auditLog
+	e     auditEntry
+	start time.Time
+	stmts []influxql.Statement
+
+	// The time of the last result of each statement, and its error.
+	ends []time.Time
+	errs []error
+}
+
+// startQuery starts auditing the query q of the request r of user against
+// database. It returns nil if none of the statements of q are audited. Only
+// the requests of authenticated users are audited.
+func (l *auditLog) startQuery(r *http.Request, user meta.User, database string, q *influxql.Query) *auditQuery {
+	if l == nil || user == nil {
+		return nil
+	}
+	audited := false
+	for _, stmt := range q.Statements {
+		audited = audited || l.includes(auditStatementType(stmt))
+	}
+	if !audited {
+		return nil
+	}
+	return &auditQuery{
+		log:   l,
+		e:     l.entry(r, user, database),
+		start: time.Now(),
+		stmts: q.Statements,
+		ends:  make([]time.Time, len(q.Statements)),
+		errs:  make([]error, len(q.Statements)),
+	}
+}
+
+// reject records the statements of the query as failed with err before
+// they ran.
+func (a *auditQuery) reject(err error) {
+	if a == nil {
+		return
+	}
+	now := time.Now()
+	for i := range a.stmts {
+		a.ends[i], a.errs[i] = now, err
+	}
+	a.finish()
+}
+
+// results returns a channel forwarding the results of in, and records the
+// statements of the query once in is closed. Results are no longer forwarded
+// once abort or closing is closed, but the statements are still recorded.
+func (a *auditQuery) results(in <-chan *query.Result, abort, closing <-chan struct{}) <-chan *query.Result {
+	if a == nil {
+		return in
+	}
+	out := make(chan *query.Result)
+	go func() {
+		defer close(out)
+		defer a.finish()
+
+		aborted := false
+		for r := range in {
+			a.observe(r)
+			if aborted {
+				continue
+			}
+			select {
+			case out <- r:
+			case <-abort:
+				aborted = true
+			case <-closing:
+				aborted = true
+			}
+		}
+	}()
+	return out
+}
+
+// observe records the result r of a statement of the query.
+func (a *auditQuery) observe(r *query.Result) {
+	if r == nil {
+		return
+	}
+	now := time.Now()
+	if r.StatementID < 0 || r.StatementID >= len(a.stmts) {
+		// The query failed as a whole.
+		if r.Err != nil {
+			for i := range a.stmts {
+				if a.errs[i] == nil {
+					a.ends[i], a.errs[i] = now, r.Err
+				}
+			}
+		}
+		return
+	}
+	a.ends[r.StatementID] = now
+	if r.Err != nil {
+		a.errs[r.StatementID] = r.Err
+	}
+}
+
+// finish records the statements of the query. Statements without results
+// were not executed.
+func (a *auditQuery) finish() {
+	prev := a.start
+	for i, stmt := range a.stmts {
+		typ := auditStatementType(stmt)
+		end, err := a.ends[i], a.errs[i]
+		if end.IsZero() {
+			end, err = prev, query.ErrNotExecuted
+		}
+		if !a.log.includes(typ) {
+			prev = end
+			continue
+		}
+
+		e := a.e
+		e.Type = typ
+		e.Statement = influxql.Sanitize(stmt.String())
+		if e.Database == "" {
+			if s, ok := stmt.(influxql.HasDefaultDatabase); ok {
+				e.Database = s.DefaultDatabase()
+			}
+		}
+		e.Status = "ok"
+		if err != nil {
+			e.Status, e.Error = "error", err.Error()
+		}
+		if end.After(prev) {
+			e.Duration = end.Sub(prev)
+		}
+		a.log.record(&e)
+		prev = end
+	}
+}
@@ -0,0 +1,178 @@
+package httpd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxql"
+)
+
+// Ensure the statements of queries are recorded with their outcome, and
+// statements of excluded types are not.
+func TestAuditLog_Query(t *testing.T) {
+	l, path := newTestAuditLog(t, AuditConfig{Exclude: []string{"show"}})
+	defer os.RemoveAll(filepath.Dir(path))
+	defer l.close()
+
+	q, err := influxql.ParseQuery(`CREATE DATABASE db0; SHOW DATABASES; CREATE USER bob WITH PASSWORD 'secret'; DROP DATABASE db1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "/query", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "192.168.0.1")
+
+	a := l.startQuery(r, &meta.UserInfo{Name: "alice"}, "", q)
+	in := make(chan *query.Result, 3)
+	in <- &query.Result{StatementID: 0}
+	in <- &query.Result{StatementID: 1}
+	in <- &query.Result{StatementID: 2, Err: errors.New("user already exists")}
+	close(in)
+	for range a.results(in, nil, nil) {
+	}
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 3 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if e := entries[0]; e.Type != "create" || e.User != "alice" || e.Addr != "10.0.0.1" || e.ForwardedFor != "192.168.0.1" ||
+		e.Statement != "CREATE DATABASE db0" || e.Status != "ok" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if e := entries[1]; e.Type != "create" || e.Statement != "CREATE USER bob WITH PASSWORD [REDACTED]" || e.Status != "error" || e.Error != "user already exists" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if e := entries[2]; e.Type != "drop" || e.Status != "error" || e.Error != query.ErrNotExecuted.Error() {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+
+	// Requests of unauthenticated users aren't recorded.
+	if a := l.startQuery(r, nil, "", q); a != nil {
+		t.Fatal("unexpected audited query")
+	}
+}
+
+// Ensure queries rejected before they run are recorded.
+func TestAuditLog_Query_Reject(t *testing.T) {
+	l, path := newTestAuditLog(t, AuditConfig{Include: []string{"select"}})
+	defer os.RemoveAll(filepath.Dir(path))
+	defer l.close()
+
+	q, err := influxql.ParseQuery(`SELECT * FROM cpu`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/query", nil)
+	l.startQuery(r, &meta.UserInfo{Name: "alice"}, "db0", q).reject(errors.New("not authorized"))
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	} else if e := entries[0]; e.Type != "select" || e.Database != "db0" || e.Status != "error" || e.Error != "not authorized" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+
+	// Queries without included statements aren't audited.
+	q, err = influxql.ParseQuery(`DROP DATABASE db0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := l.startQuery(r, &meta.UserInfo{Name: "alice"}, "", q); a != nil {
+		t.Fatal("unexpected audited query")
+	}
+}
+
+// Ensure writes are recorded with the status of their response.
+func TestAuditLog_Write(t *testing.T) {
+	l, path := newTestAuditLog(t, AuditConfig{})
+	defer os.RemoveAll(filepath.Dir(path))
+	defer l.close()
+
+	r := httptest.NewRequest("POST", "/write?db=db0", nil)
+	w, done := l.startWrite(NewResponseWriter(httptest.NewRecorder(), r), r, &meta.UserInfo{Name: "alice"}, "db0")
+	w.WriteHeader(http.StatusNoContent)
+	done()
+
+	w, done = l.startWrite(NewResponseWriter(httptest.NewRecorder(), r), r, &meta.UserInfo{Name: "bob"}, "db0")
+	w.Header().Set("X-InfluxDB-Error", "not authorized")
+	w.WriteHeader(http.StatusForbidden)
+	done()
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	} else if e := entries[0]; e.Type != "write" || e.User != "alice" || e.Database != "db0" || e.Status != "ok" {
+		t.Fatalf("unexpected entry: %+v", e)
+	} else if e := entries[1]; e.User != "bob" || e.Status != "error" || e.Error != "not authorized" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+}
+
+// Ensure the file of the log is rotated once it grows beyond its maximum
+// size, keeping the configured number of rotated files.
+func TestAuditLog_Rotate(t *testing.T) {
+	l, path := newTestAuditLog(t, AuditConfig{MaxSize: 1, MaxBackups: 2})
+	defer os.RemoveAll(filepath.Dir(path))
+	defer l.close()
+
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		l.record(&auditEntry{Type: "write", User: user, Status: "ok"})
+	}
+
+	for p, user := range map[string]string{path: "dave", path + ".1": "carol", path + ".2": "bob"} {
+		if entries := readAuditLog(t, p); len(entries) != 1 || entries[0].User != user {
+			t.Fatalf("unexpected entries of %s: %+v", p, entries)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("unexpected rotated file: %v", err)
+	}
+}
+
+// newTestAuditLog returns an opened audit log of c writing to a file of a
+// temporary directory, and the path of the file. The directory must be
+// removed by the caller.
+func newTestAuditLog(t *testing.T, c AuditConfig) (*auditLog, string) {
+	dir, err := ioutil.TempDir("", "httpd-audit-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Path = filepath.Join(dir, "audit.log")
+	l := newAuditLog(c)
+	if err := l.open(); err != nil {
+		t.Fatal(err)
+	}
+	return l, c.Path
+}
+
+// readAuditLog returns the entries of the audit log file at path.
+func readAuditLog(t *testing.T, path string) []auditEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
@@ -0,0 +1,66 @@
+package httpd
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// clientCertificateCredentials returns the credentials of the user the
+// verified client certificate of the request maps to, or nil if there is no
+// such certificate or it does not map to a user. Certificates are only
+// verified if https-client-ca is set.
+func (h *Handler) clientCertificateCredentials(r *http.Request) *credentials {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	subject := r.TLS.VerifiedChains[0][0].Subject
+	for _, u := range h.Config.ClientCertificateUsers {
+		if !u.matches(subject) {
+			continue
+		}
+		username := u.User
+		if username == "" {
+			username = subject.CommonName
+		}
+		if username == "" {
+			return nil
+		}
+		return &credentials{
+			Method:   ClientCertificateAuthentication,
+			Username: username,
+		}
+	}
+	return nil
+}
+
+// matches returns true if the subject of a certificate matches u.
+func (u *ClientCertificateUser) matches(subject pkix.Name) bool {
+	if u.CN != "" && u.CN != subject.CommonName {
+		return false
+	}
+	if u.OU != "" {
+		for _, ou := range subject.OrganizationalUnit {
+			if ou == u.OU {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// loadCertPool returns a pool of the PEM certificates of the file.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
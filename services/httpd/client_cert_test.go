@@ -0,0 +1,60 @@
+package httpd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"testing"
+)
+
+// Ensure verified client certificates map to the user of the first entry
+// matching their subject.
+func TestHandler_ClientCertificateCredentials(t *testing.T) {
+	c := NewConfig()
+	c.HTTPSClientCA = "/etc/ssl/clients.pem"
+	c.ClientCertificateUsers = []ClientCertificateUser{
+		{CN: "telegraf-01", User: "telegraf"},
+		{CN: "grafana", OU: "dashboards", User: "reader"},
+		{OU: "operators"},
+	}
+	h := NewHandler(c)
+
+	for _, tt := range []struct {
+		subject pkix.Name
+		user    string
+	}{
+		{subject: pkix.Name{CommonName: "telegraf-01"}, user: "telegraf"},
+		{subject: pkix.Name{CommonName: "telegraf-01", OrganizationalUnit: []string{"operators"}}, user: "telegraf"},
+		{subject: pkix.Name{CommonName: "grafana", OrganizationalUnit: []string{"monitoring", "dashboards"}}, user: "reader"},
+		{subject: pkix.Name{CommonName: "grafana"}},
+		{subject: pkix.Name{CommonName: "alice", OrganizationalUnit: []string{"operators"}}, user: "alice"},
+		{subject: pkix.Name{OrganizationalUnit: []string{"operators"}}},
+		{subject: pkix.Name{CommonName: "telegraf-02"}},
+	} {
+		r := httptest.NewRequest("GET", "/query", nil)
+		r.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: tt.subject}}},
+		}
+		creds := h.clientCertificateCredentials(r)
+		if tt.user == "" {
+			if creds != nil {
+				t.Errorf("%+v: unexpected credentials: %+v", tt.subject, creds)
+			}
+		} else if creds == nil || creds.Method != ClientCertificateAuthentication || creds.Username != tt.user {
+			t.Errorf("%+v: unexpected credentials: %+v", tt.subject, creds)
+		}
+	}
+
+	// Certificates that were not verified are ignored.
+	r := httptest.NewRequest("GET", "/query", nil)
+	r.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "telegraf-01"}}},
+	}
+	if creds := h.clientCertificateCredentials(r); creds != nil {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+	if creds := h.clientCertificateCredentials(httptest.NewRequest("GET", "/query", nil)); creds != nil {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+}
@@ -0,0 +1,167 @@
+package httpd
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressWriter is an encoder of compressed responses.
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoders are the pools of the encoders of each supported content coding.
+var encoders = map[string]*sync.Pool{
+	"gzip": {
+		New: func() interface{} {
+			return gzip.NewWriter(nil)
+		},
+	},
+}
+
+// encodingPreference orders the content codings by preference, when a client
+// accepts several equally.
+var encodingPreference = []string{"gzip"}
+
+type lazyCompressResponseWriter struct {
+	io.Writer
+	http.ResponseWriter
+	http.Flusher
+	http.CloseNotifier
+	encoding    string
+	cw          compressWriter
+	wroteHeader bool
+}
+
+// compressFilter determines if the client can accept compressed responses, and encodes accordingly.
+func compressFilter(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &lazyCompressResponseWriter{ResponseWriter: w, Writer: w, encoding: encoding}
+
+		if f, ok := w.(http.Flusher); ok {
+			cw.Flusher = f
+		}
+
+		if cn, ok := w.(http.CloseNotifier); ok {
+			cw.CloseNotifier = cn
+		}
+
+		defer cw.Close()
+
+		inner.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding returns the supported content coding the header of
+// accepted encodings prefers, or an empty string if it accepts none of them.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if coding == "*" {
+			wildcard = q
+		} else {
+			accepted[coding] = q
+		}
+	}
+
+	var best string
+	var bestQ float64
+	for _, coding := range encodingPreference {
+		if _, ok := encoders[coding]; !ok {
+			continue
+		}
+		q, ok := accepted[coding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+func (w *lazyCompressResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	if code == http.StatusOK {
+		w.Header().Set("Content-Encoding", w.encoding)
+		// Add compressor
+		if w.cw == nil {
+			w.cw = getCompressWriter(w.encoding, w.Writer)
+			w.Writer = w.cw
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *lazyCompressResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.Writer.Write(p)
+}
+
+func (w *lazyCompressResponseWriter) Flush() {
+	// Flush writer, if supported
+	if w.cw != nil {
+		w.cw.Flush()
+	}
+
+	// Flush the HTTP response
+	if w.Flusher != nil {
+		w.Flusher.Flush()
+	}
+}
+
+func (w *lazyCompressResponseWriter) Close() error {
+	if w.cw != nil {
+		putCompressWriter(w.encoding, w.cw)
+	}
+
+	return nil
+}
+
+func getCompressWriter(encoding string, w io.Writer) compressWriter {
+	cw := encoders[encoding].Get().(compressWriter)
+	cw.Reset(w)
+	return cw
+}
+
+func putCompressWriter(encoding string, cw compressWriter) {
+	cw.Close()
+	encoders[encoding].Put(cw)
+}
@@ -0,0 +1,84 @@
+package httpd
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Ensure the content coding of responses is the supported one clients prefer.
+func TestNegotiateEncoding(t *testing.T) {
+	defer func(e map[string]*sync.Pool, p []string) {
+		encoders, encodingPreference = e, p
+	}(encoders, encodingPreference)
+	encoders = map[string]*sync.Pool{"gzip": encoders["gzip"], "zstd": {}}
+	encodingPreference = []string{"zstd", "gzip"}
+
+	for _, tt := range []struct {
+		header   string
+		encoding string
+	}{
+		{header: "", encoding: ""},
+		{header: "identity", encoding: ""},
+		{header: "gzip", encoding: "gzip"},
+		{header: "deflate, gzip", encoding: "gzip"},
+		{header: "gzip, zstd", encoding: "zstd"},
+		{header: "gzip;q=1.0, zstd;q=0.5", encoding: "gzip"},
+		{header: "GZIP, br", encoding: "gzip"},
+		{header: "*", encoding: "zstd"},
+		{header: "zstd;q=0, *", encoding: "gzip"},
+		{header: "gzip;q=0", encoding: ""},
+	} {
+		if encoding := negotiateEncoding(tt.header); encoding != tt.encoding {
+			t.Errorf("%q: unexpected encoding: %q", tt.header, encoding)
+		}
+	}
+}
+
+// Ensure successful responses are compressed, and others are not.
+func TestCompressFilter(t *testing.T) {
+	h := compressFilter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("cpu value=1"))
+	}))
+
+	r := httptest.NewRequest("GET", "/query", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("unexpected encoding: %q", enc)
+	} else if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Fatalf("unexpected vary: %q", vary)
+	}
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadAll(gr); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "cpu value=1" {
+		t.Fatalf("unexpected body: %q", b)
+	}
+
+	r = httptest.NewRequest("GET", "/missing", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Fatalf("unexpected encoding: %q", enc)
+	}
+
+	r = httptest.NewRequest("GET", "/query", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if enc := w.Header().Get("Content-Encoding"); enc != "" || w.Body.String() != "cpu value=1" {
+		t.Fatalf("unexpected response: %q, %q", enc, w.Body.String())
+	}
+}
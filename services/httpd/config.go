@@ -1,6 +1,15 @@
 package httpd
 
-import "github.com/influxdata/influxdb/monitor/diagnostics"
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/monitor/diagnostics"
+	"github.com/influxdata/influxdb/toml"
+)
 
 const (
 	// DefaultBindAddress is the default address to bind to.
@@ -14,44 +23,651 @@ const (
 
 	// DefaultMaxBodySize is the default maximum size of a client request body, in bytes. Specify 0 for no limit.
 	DefaultMaxBodySize = 25e6
+
+	// DefaultTCPKeepAlivePeriod is the default period of the TCP keep-alive
+	// probes of the connections of HTTP clients.
+	DefaultTCPKeepAlivePeriod = 3 * time.Minute
+
+	// DefaultJWKSRefreshInterval is the default interval between fetches of
+	// the keys of the JWKS URL.
+	DefaultJWKSRefreshInterval = time.Hour
+
+	// DefaultOIDCUsernameClaim is the default claim of OpenID Connect tokens
+	// naming their user.
+	DefaultOIDCUsernameClaim = "preferred_username"
+
+	// DefaultOIDCRolesClaim is the default claim of OpenID Connect tokens
+	// listing their roles.
+	DefaultOIDCRolesClaim = "roles"
+
+	// DefaultLDAPSearchFilter is the default filter of the search for the
+	// entry of a user in the LDAP directory.
+	DefaultLDAPSearchFilter = "(uid={username})"
+
+	// DefaultLDAPGroupAttribute is the default attribute of the entry of a
+	// user listing its groups.
+	DefaultLDAPGroupAttribute = "memberOf"
+
+	// DefaultLDAPTimeout is the default time allowed for each operation on
+	// the LDAP directory.
+	DefaultLDAPTimeout = 10 * time.Second
+
+	// DefaultLDAPMaxIdleConnections is the default number of idle connections
+	// to the LDAP directory kept for reuse.
+	DefaultLDAPMaxIdleConnections = 4
+
+	// DefaultQueryCursorTimeout is the default time the cursor of a paged
+	// query is kept between requests.
+	DefaultQueryCursorTimeout = time.Minute
+
+	// DefaultMaxQueryCursors is the default maximum number of open cursors
+	// of paged queries.
+	DefaultMaxQueryCursors = 100
+
+	// DefaultMaxEnqueuedWriteLimit is the default number of write requests
+	// waiting to be admitted beyond the limits of concurrent writes.
+	DefaultMaxEnqueuedWriteLimit = 0
+
+	// DefaultEnqueuedWriteTimeout is the default time write requests wait
+	// to be admitted.
+	DefaultEnqueuedWriteTimeout = 30 * time.Second
+
+	// DefaultAuditMaxSize is the default size, in bytes, beyond which the
+	// audit log file is rotated.
+	DefaultAuditMaxSize = 100 * 1024 * 1024
+
+	// DefaultAuditMaxBackups is the default number of rotated audit log
+	// files kept.
+	DefaultAuditMaxBackups = 5
+
+	// DefaultWriteIntakeMaxSize is the default size, in bytes, of the writes
+	// enqueued in the write intake and not yet written.
+	DefaultWriteIntakeMaxSize = 1024 * 1024 * 1024
+
+	// DefaultWriteIntakeSegmentSize is the default size, in bytes, beyond
+	// which the segments of the write intake are rolled.
+	DefaultWriteIntakeSegmentSize = 10 * 1024 * 1024
 )
 
 // Config represents a configuration for a HTTP service.
 type Config struct {
-	Enabled            bool   `toml:"enabled"`
-	BindAddress        string `toml:"bind-address"`
-	AuthEnabled        bool   `toml:"auth-enabled"`
-	LogEnabled         bool   `toml:"log-enabled"`
-	WriteTracing       bool   `toml:"write-tracing"`
-	PprofEnabled       bool   `toml:"pprof-enabled"`
-	HTTPSEnabled       bool   `toml:"https-enabled"`
-	HTTPSCertificate   string `toml:"https-certificate"`
-	HTTPSPrivateKey    string `toml:"https-private-key"`
-	MaxRowLimit        int    `toml:"max-row-limit"`
-	MaxConnectionLimit int    `toml:"max-connection-limit"`
-	SharedSecret       string `toml:"shared-secret"`
-	Realm              string `toml:"realm"`
-	UnixSocketEnabled  bool   `toml:"unix-socket-enabled"`
-	BindSocket         string `toml:"bind-socket"`
-	MaxBodySize        int    `toml:"max-body-size"`
-	AccessLogPath      string `toml:"access-log-path"`
+	Enabled             bool          `toml:"enabled"`
+	BindAddress         string        `toml:"bind-address"`
+	AuthEnabled         bool          `toml:"auth-enabled"`
+	LogEnabled          bool          `toml:"log-enabled"`
+	WriteTracing        bool          `toml:"write-tracing"`
+	PprofEnabled        bool          `toml:"pprof-enabled"`
+	HTTPSEnabled        bool          `toml:"https-enabled"`
+	HTTPSCertificate    string        `toml:"https-certificate"`
+	HTTPSPrivateKey     string        `toml:"https-private-key"`
+	MaxRowLimit         int           `toml:"max-row-limit"`
+	MaxConnectionLimit  int           `toml:"max-connection-limit"`
+	SharedSecret        string        `toml:"shared-secret"`
+	SharedSecrets       []string      `toml:"shared-secrets"`
+	JWKSURL             string        `toml:"jwks-url"`
+	JWKSRefreshInterval toml.Duration `toml:"jwks-refresh-interval"`
+	Realm               string        `toml:"realm"`
+	UnixSocketEnabled   bool          `toml:"unix-socket-enabled"`
+	BindSocket          string        `toml:"bind-socket"`
+	MaxBodySize         int           `toml:"max-body-size"`
+	AccessLogPath       string        `toml:"access-log-path"`
+
+	// MaxRequestMemory limits the memory allocated on behalf of a request,
+	// for the results of a query buffered before they are written or for
+	// the body of a write and its points. Requests exceeding it fail.
+	// Zero is unlimited.
+	MaxRequestMemory toml.Size `toml:"max-request-memory"`
+
+	// HTTP2Enabled negotiates HTTP/2 with the HTTPS clients supporting it.
+	HTTP2Enabled bool `toml:"http2-enabled"`
+
+	// Connections are closed when a request is not read within ReadTimeout,
+	// when its response is not written within WriteTimeout, or when they are
+	// idle between requests for IdleTimeout. Zero disables a timeout, except
+	// for IdleTimeout which is then ReadTimeout. The headers of requests are
+	// limited to MaxHeaderSize bytes, or 1MB if it is 0.
+	ReadTimeout   toml.Duration `toml:"read-timeout"`
+	WriteTimeout  toml.Duration `toml:"write-timeout"`
+	IdleTimeout   toml.Duration `toml:"idle-timeout"`
+	MaxHeaderSize toml.Size     `toml:"max-header-size"`
+
+	// Connections are closed after each request if KeepAlivesDisabled is
+	// set. TCP keep-alive probes are sent every TCPKeepAlivePeriod on the
+	// connections of HTTP clients, unless it is 0.
+	KeepAlivesDisabled bool          `toml:"keep-alives-disabled"`
+	TCPKeepAlivePeriod toml.Duration `toml:"tcp-keep-alive-period"`
+
+	// Listeners are the addresses the service listens on besides
+	// BindAddress, each with its own policy.
+	Listeners []ListenerConfig `toml:"listener"`
+
+	// CORS are the rules of the cross-origin requests of browsers to groups
+	// of endpoints. Requests use the first rule of their endpoint, and are
+	// not allowed cross-origin if there is none. All origins are allowed to
+	// all endpoints if there are no rules.
+	CORS []CORSConfig `toml:"cors"`
+
+	// Paged queries keep running between requests. Their cursors are closed
+	// when not resumed within QueryCursorTimeout, or the default timeout if
+	// it is 0. Queries cannot be paged
+	// while MaxQueryCursors cursors are open, unless it is 0.
+	QueryCursorTimeout toml.Duration `toml:"query-cursor-timeout"`
+	MaxQueryCursors    int           `toml:"max-query-cursors"`
+
+	// PromReadDownsamples are the retention policies holding the Prometheus
+	// samples downsampled, served to remote reads whose hints aggregate
+	// samples within steps at least as long as their resolution.
+	PromReadDownsamples []PromReadDownsampleConfig `toml:"prom-read-downsample"`
+
+	// Write requests are admitted while fewer than MaxConcurrentWriteLimit
+	// are in flight and their bodies total at most MaxInflightWriteBytes.
+	// Beyond these limits, up to MaxEnqueuedWriteLimit requests wait to be
+	// admitted for EnqueuedWriteTimeout, or until they are if it is 0, and
+	// other requests are rejected with 429 Too Many Requests. Zero disables
+	// the limits of requests and bytes.
+	MaxConcurrentWriteLimit int           `toml:"max-concurrent-write-limit"`
+	MaxInflightWriteBytes   toml.Size     `toml:"max-inflight-write-bytes"`
+	MaxEnqueuedWriteLimit   int           `toml:"max-enqueued-write-limit"`
+	EnqueuedWriteTimeout    toml.Duration `toml:"enqueued-write-timeout"`
+
+	// OIDCIssuer, if set, enables the authentication of OpenID Connect bearer
+	// tokens of the issuer. OIDCAudience, if set, must be an audience of the
+	// tokens. Tokens authenticate as the user named by OIDCUsernameClaim, or
+	// else as the user OIDCRoleUsers maps one of the OIDCRolesClaim roles to.
+	OIDCIssuer        string            `toml:"oidc-issuer"`
+	OIDCAudience      string            `toml:"oidc-audience"`
+	OIDCUsernameClaim string            `toml:"oidc-username-claim"`
+	OIDCRolesClaim    string            `toml:"oidc-roles-claim"`
+	OIDCRoleUsers     map[string]string `toml:"oidc-role-users"`
+
+	// HTTPSClientCA, if set, is a file of the certificates of the CAs client
+	// certificates are verified against. Requests without credentials
+	// authenticate as the user ClientCertificateUsers maps the verified
+	// client certificate to. Clients must present a certificate if
+	// HTTPSRequireClientCert is set.
+	HTTPSClientCA          string                  `toml:"https-client-ca"`
+	HTTPSRequireClientCert bool                    `toml:"https-require-client-cert"`
+	ClientCertificateUsers []ClientCertificateUser `toml:"client-certificate-user"`
+
+	// LDAP, if enabled, authenticates usernames and passwords against an LDAP
+	// directory instead of the users of the meta store.
+	LDAP LDAPConfig `toml:"ldap"`
+
+	// UserQuota limits the requests of each authenticated user, and
+	// DatabaseQuota the requests to each database. UserQuotas and
+	// DatabaseQuotas replace them for the users and databases they name.
+	UserQuota      QuotaConfig            `toml:"user-quota"`
+	UserQuotas     map[string]QuotaConfig `toml:"user-quotas"`
+	DatabaseQuota  QuotaConfig            `toml:"database-quota"`
+	DatabaseQuotas map[string]QuotaConfig `toml:"database-quotas"`
+
+	// Audit records the queries, writes and admin statements of
+	// authenticated users.
+	Audit AuditConfig `toml:"audit"`
+
+	// WriteIntake queues the writes acknowledged once enqueued.
+	WriteIntake WriteIntakeConfig `toml:"write-intake"`
+}
+
+// AuditConfig represents the configuration of the audit log.
+type AuditConfig struct {
+	Enabled bool `toml:"enabled"`
+
+	// Entries are appended to the file at Path as JSON lines, if set. The
+	// file is rotated once it would grow beyond MaxSize bytes, unless it is
+	// 0, and MaxBackups rotated files are kept.
+	Path       string    `toml:"path"`
+	MaxSize    toml.Size `toml:"max-size"`
+	MaxBackups int       `toml:"max-backups"`
+
+	// Internal records the entries in the audit measurement of the
+	// _internal database. Entries are written to the log if neither
+	// Internal nor Path is set.
+	Internal bool `toml:"internal"`
+
+	// The types of the statements recorded, such as "select", "create" or
+	// "write", are those of Include, or all types if it is empty, except
+	// those of Exclude.
+	Include []string `toml:"include"`
+	Exclude []string `toml:"exclude"`
+}
+
+// Validate returns an error if the audit config is invalid.
+func (c AuditConfig) Validate() error {
+	if c.MaxBackups < 0 {
+		return errors.New("max-backups cannot be negative")
+	}
+	for _, typ := range append(append([]string{}, c.Include...), c.Exclude...) {
+		if !isAuditType(typ) {
+			return fmt.Errorf("unknown statement type %q", typ)
+		}
+	}
+	return nil
+}
+
+// WriteIntakeConfig represents the configuration of the write intake, the
+// durable queue of the writes acknowledged once enqueued rather than once
+// written.
+type WriteIntakeConfig struct {
+	Enabled bool `toml:"enabled"`
+
+	// Dir is the directory of the segment files of the queue. Segments are
+	// rolled once they would grow beyond SegmentSize bytes.
+	Dir         string    `toml:"dir"`
+	SegmentSize toml.Size `toml:"segment-size"`
+
+	// Writes are rejected once the writes enqueued and not yet written
+	// would total more than MaxSize bytes, unless it is 0.
+	MaxSize toml.Size `toml:"max-size"`
+}
+
+// Validate returns an error if the write intake config is invalid.
+func (c WriteIntakeConfig) Validate() error {
+	if c.Dir == "" {
+		return errors.New("dir must be set")
+	} else if c.SegmentSize == 0 {
+		return errors.New("segment-size must be positive")
+	}
+	return nil
+}
+
+// QuotaConfig limits the rate of queries, the rate of points and bytes
+// written and the number of concurrent requests. Zero disables a limit.
+type QuotaConfig struct {
+	QueriesPerSecond      int `toml:"queries-per-second"`
+	PointsPerSecond       int `toml:"points-per-second"`
+	BytesPerSecond        int `toml:"bytes-per-second"`
+	MaxConcurrentRequests int `toml:"max-concurrent-requests"`
+}
+
+// Validate returns an error if the quota config is invalid.
+func (c QuotaConfig) Validate() error {
+	if c.QueriesPerSecond < 0 {
+		return errors.New("queries-per-second cannot be negative")
+	} else if c.PointsPerSecond < 0 {
+		return errors.New("points-per-second cannot be negative")
+	} else if c.BytesPerSecond < 0 {
+		return errors.New("bytes-per-second cannot be negative")
+	} else if c.MaxConcurrentRequests < 0 {
+		return errors.New("max-concurrent-requests cannot be negative")
+	}
+	return nil
+}
+
+// unlimited returns true if c disables all limits.
+func (c QuotaConfig) unlimited() bool {
+	return c == QuotaConfig{}
+}
+
+// quotasEnabled returns true if any user or database quota has a limit.
+func (c *Config) quotasEnabled() bool {
+	if !c.UserQuota.unlimited() || !c.DatabaseQuota.unlimited() {
+		return true
+	}
+	for _, q := range c.UserQuotas {
+		if !q.unlimited() {
+			return true
+		}
+	}
+	for _, q := range c.DatabaseQuotas {
+		if !q.unlimited() {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientCertificateUser maps the client certificates whose subject has the
+// common name CN and the organizational unit OU to User. Empty CN or OU match
+// any certificate, and an empty User is the common name of the certificate.
+type ClientCertificateUser struct {
+	CN   string `toml:"cn"`
+	OU   string `toml:"ou"`
+	User string `toml:"user"`
+}
+
+// ListenerConfig represents an address the service listens on, and the policy
+// of the requests it receives.
+type ListenerConfig struct {
+	BindAddress string `toml:"bind-address"`
+
+	// HTTPSEnabled serves HTTPS with HTTPSCertificate and HTTPSPrivateKey,
+	// or else the certificate and key of the service.
+	HTTPSEnabled     bool   `toml:"https-enabled"`
+	HTTPSCertificate string `toml:"https-certificate"`
+	HTTPSPrivateKey  string `toml:"https-private-key"`
+
+	// AuthEnabled, if set, requires the requests of the listener to be
+	// authenticated or not instead of the auth-enabled setting of the
+	// service.
+	AuthEnabled *bool `toml:"auth-enabled"`
+
+	// Endpoints are the paths of the endpoints served, such as "/write" or
+	// "/query", with the paths below them. Other requests are forbidden. All
+	// endpoints are served if it is empty.
+	Endpoints []string `toml:"endpoints"`
+}
+
+// Validate returns an error if the listener config is invalid.
+func (c ListenerConfig) Validate() error {
+	if c.BindAddress == "" {
+		return errors.New("bind-address must be set")
+	}
+	for _, e := range c.Endpoints {
+		if !strings.HasPrefix(e, "/") {
+			return fmt.Errorf("endpoint %q must be a path", e)
+		}
+	}
+	return nil
+}
+
+// CORSConfig represents the rule of the cross-origin requests to a group of
+// endpoints.
+type CORSConfig struct {
+	// Endpoints are the paths of the endpoints of the rule, such as "/query",
+	// with the paths below them. The rule applies to all endpoints if it is
+	// empty.
+	Endpoints []string `toml:"endpoints"`
+
+	// AllowedOrigins are the origins allowed, such as
+	// "https://grafana.example.com", "https://*.example.com" for the
+	// subdomains of a domain, or "*" for all origins.
+	AllowedOrigins []string `toml:"allowed-origins"`
+
+	// AllowedMethods and AllowedHeaders are the methods and request headers
+	// allowed, or the default ones if empty. "*" allows all headers.
+	AllowedMethods []string `toml:"allowed-methods"`
+	AllowedHeaders []string `toml:"allowed-headers"`
+
+	// ExposedHeaders are the response headers exposed to browsers, or the
+	// default ones if empty.
+	ExposedHeaders []string `toml:"exposed-headers"`
+
+	// AllowCredentials allows requests with credentials, such as cookies
+	// and the Authorization header.
+	AllowCredentials bool `toml:"allow-credentials"`
+
+	// MaxAge is how long browsers may cache the results of preflight
+	// requests, or the default of browsers if it is 0.
+	MaxAge toml.Duration `toml:"max-age"`
+}
+
+// Validate returns an error if the CORS config is invalid.
+func (c CORSConfig) Validate() error {
+	for _, e := range c.Endpoints {
+		if !strings.HasPrefix(e, "/") {
+			return fmt.Errorf("endpoint %q must be a path", e)
+		}
+	}
+	if len(c.AllowedOrigins) == 0 {
+		return errors.New("allowed-origins must be set")
+	}
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			continue
+		}
+		u, err := url.Parse(strings.Replace(o, "*.", "", 1))
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("invalid origin %q", o)
+		}
+	}
+	if c.MaxAge < 0 {
+		return errors.New("max-age cannot be negative")
+	}
+	return nil
+}
+
+// PromReadDownsampleConfig represents a retention policy holding the
+// Prometheus samples aggregated by a continuous query, such as:
+//
+//	CREATE CONTINUOUS QUERY prom_5m ON prometheus BEGIN
+//	  SELECT mean(f64) AS f64 INTO prometheus."5m"._ FROM prometheus.autogen._
+//	  GROUP BY time(5m), *
+//	END
+type PromReadDownsampleConfig struct {
+	RetentionPolicy string `toml:"retention-policy"`
+
+	// Resolution is the interval the samples are aggregated over.
+	Resolution toml.Duration `toml:"resolution"`
+
+	// Function is the InfluxQL aggregate of the samples: "mean", "min",
+	// "max" or "sum". Only reads aggregating samples with it are served.
+	Function string `toml:"function"`
+}
+
+// Validate returns an error if the downsample config is invalid.
+func (c PromReadDownsampleConfig) Validate() error {
+	if c.RetentionPolicy == "" {
+		return errors.New("retention-policy must be set")
+	} else if c.Resolution <= 0 {
+		return errors.New("resolution must be positive")
+	}
+	switch c.Function {
+	case "mean", "min", "max", "sum":
+	default:
+		return fmt.Errorf("unsupported function %q", c.Function)
+	}
+	return nil
+}
+
+// LDAPConfig represents the configuration of the LDAP authentication backend.
+type LDAPConfig struct {
+	Enabled bool `toml:"enabled"`
+
+	// URL of the directory, "ldap://host:port" or "ldaps://host:port". The
+	// system certificate pool is used to verify the directory unless TLSCA
+	// is set.
+	URL                string `toml:"url"`
+	StartTLS           bool   `toml:"start-tls"`
+	TLSCA              string `toml:"tls-ca"`
+	InsecureSkipVerify bool   `toml:"insecure-skip-verify"`
+
+	// The account searching the directory. Searches are anonymous if BindDN
+	// is empty.
+	BindDN       string `toml:"bind-dn"`
+	BindPassword string `toml:"bind-password"`
+
+	// Users are searched below SearchBaseDN with SearchFilter, in which
+	// {username} is replaced by the username.
+	SearchBaseDN string `toml:"search-base-dn"`
+	SearchFilter string `toml:"search-filter"`
+
+	// The groups of a user are the values of GroupAttribute of its entry or,
+	// if GroupSearchFilter is set, the entries below GroupSearchBaseDN
+	// matching GroupSearchFilter, in which {dn} is replaced by the DN of the
+	// user and {username} by the username.
+	GroupAttribute    string `toml:"group-attribute"`
+	GroupSearchBaseDN string `toml:"group-search-base-dn"`
+	GroupSearchFilter string `toml:"group-search-filter"`
+
+	Timeout            toml.Duration `toml:"timeout"`
+	MaxIdleConnections int           `toml:"max-idle-connections"`
+
+	// LocalFallback authenticates the users of the meta store whose
+	// usernames and passwords the directory does not accept, such as an
+	// admin when the directory is unreachable.
+	LocalFallback bool `toml:"local-fallback"`
+
+	// Groups grant privileges to their members. Users that are not members
+	// of any of them cannot authenticate.
+	Groups []LDAPGroup `toml:"group"`
+}
+
+// LDAPGroup grants privileges to the members of a group of the directory:
+// all privileges if Admin is set, or else Privilege, one of "READ", "WRITE"
+// and "ALL", on Database.
+type LDAPGroup struct {
+	DN        string `toml:"dn"`
+	Admin     bool   `toml:"admin"`
+	Database  string `toml:"database"`
+	Privilege string `toml:"privilege"`
 }
 
 // NewConfig returns a new Config with default settings.
 func NewConfig() Config {
 	return Config{
-		Enabled:           true,
-		BindAddress:       DefaultBindAddress,
-		LogEnabled:        true,
-		PprofEnabled:      true,
-		HTTPSEnabled:      false,
-		HTTPSCertificate:  "/etc/ssl/influxdb.pem",
-		MaxRowLimit:       0,
-		Realm:             DefaultRealm,
-		UnixSocketEnabled: false,
-		BindSocket:        DefaultBindSocket,
-		MaxBodySize:       DefaultMaxBodySize,
+		Enabled:               true,
+		BindAddress:           DefaultBindAddress,
+		LogEnabled:            true,
+		PprofEnabled:          true,
+		HTTPSEnabled:          false,
+		HTTPSCertificate:      "/etc/ssl/influxdb.pem",
+		MaxRowLimit:           0,
+		Realm:                 DefaultRealm,
+		UnixSocketEnabled:     false,
+		BindSocket:            DefaultBindSocket,
+		MaxBodySize:           DefaultMaxBodySize,
+		JWKSRefreshInterval:   toml.Duration(DefaultJWKSRefreshInterval),
+		OIDCUsernameClaim:     DefaultOIDCUsernameClaim,
+		OIDCRolesClaim:        DefaultOIDCRolesClaim,
+		QueryCursorTimeout:    toml.Duration(DefaultQueryCursorTimeout),
+		MaxQueryCursors:       DefaultMaxQueryCursors,
+		MaxEnqueuedWriteLimit: DefaultMaxEnqueuedWriteLimit,
+		EnqueuedWriteTimeout:  toml.Duration(DefaultEnqueuedWriteTimeout),
+		Audit: AuditConfig{
+			MaxSize:    DefaultAuditMaxSize,
+			MaxBackups: DefaultAuditMaxBackups,
+		},
+		WriteIntake: WriteIntakeConfig{
+			SegmentSize: DefaultWriteIntakeSegmentSize,
+			MaxSize:     DefaultWriteIntakeMaxSize,
+		},
+		LDAP: LDAPConfig{
+			SearchFilter:       DefaultLDAPSearchFilter,
+			GroupAttribute:     DefaultLDAPGroupAttribute,
+			Timeout:            toml.Duration(DefaultLDAPTimeout),
+			MaxIdleConnections: DefaultLDAPMaxIdleConnections,
+		},
+	}
+}
+
+// Validate returns an error if the config is invalid.
+func (c Config) Validate() error {
+	if c.JWKSURL != "" {
+		if u, err := url.Parse(c.JWKSURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid jwks-url %q", c.JWKSURL)
+		}
+	}
+	if c.ReadTimeout < 0 {
+		return errors.New("read-timeout cannot be negative")
+	} else if c.WriteTimeout < 0 {
+		return errors.New("write-timeout cannot be negative")
+	} else if c.IdleTimeout < 0 {
+		return errors.New("idle-timeout cannot be negative")
+	} else if c.TCPKeepAlivePeriod < 0 {
+		return errors.New("tcp-keep-alive-period cannot be negative")
+	}
+	for _, l := range c.Listeners {
+		if err := l.Validate(); err != nil {
+			return fmt.Errorf("invalid listener %q: %v", l.BindAddress, err)
+		}
+	}
+	for i, r := range c.CORS {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("invalid cors rule %d: %v", i, err)
+		}
+	}
+	for _, d := range c.PromReadDownsamples {
+		if err := d.Validate(); err != nil {
+			return fmt.Errorf("invalid prom-read-downsample %q: %v", d.RetentionPolicy, err)
+		}
+	}
+	if c.QueryCursorTimeout < 0 {
+		return errors.New("query-cursor-timeout cannot be negative")
+	} else if c.MaxQueryCursors < 0 {
+		return errors.New("max-query-cursors cannot be negative")
+	}
+	if c.MaxConcurrentWriteLimit < 0 {
+		return errors.New("max-concurrent-write-limit cannot be negative")
+	} else if c.MaxEnqueuedWriteLimit < 0 {
+		return errors.New("max-enqueued-write-limit cannot be negative")
+	} else if c.EnqueuedWriteTimeout < 0 {
+		return errors.New("enqueued-write-timeout cannot be negative")
+	}
+	if c.OIDCIssuer != "" {
+		if u, err := url.Parse(c.OIDCIssuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid oidc-issuer %q", c.OIDCIssuer)
+		} else if c.OIDCUsernameClaim == "" && len(c.OIDCRoleUsers) == 0 {
+			return errors.New("oidc-username-claim or oidc-role-users must be set")
+		}
+	}
+	if c.HTTPSClientCA == "" && (c.HTTPSRequireClientCert || len(c.ClientCertificateUsers) > 0) {
+		return errors.New("https-client-ca must be set to verify client certificates")
+	}
+	for _, u := range c.ClientCertificateUsers {
+		if u.CN == "" && u.OU == "" {
+			return errors.New("client-certificate-user must match a cn or an ou")
+		}
+	}
+	if c.LDAP.Enabled {
+		if err := c.LDAP.Validate(); err != nil {
+			return fmt.Errorf("invalid ldap config: %v", err)
+		}
+	}
+	if err := c.UserQuota.Validate(); err != nil {
+		return fmt.Errorf("invalid user-quota: %v", err)
+	}
+	for name, q := range c.UserQuotas {
+		if err := q.Validate(); err != nil {
+			return fmt.Errorf("invalid user-quotas %q: %v", name, err)
+		}
+	}
+	if err := c.DatabaseQuota.Validate(); err != nil {
+		return fmt.Errorf("invalid database-quota: %v", err)
+	}
+	for name, q := range c.DatabaseQuotas {
+		if err := q.Validate(); err != nil {
+			return fmt.Errorf("invalid database-quotas %q: %v", name, err)
+		}
+	}
+	if c.Audit.Enabled {
+		if err := c.Audit.Validate(); err != nil {
+			return fmt.Errorf("invalid audit config: %v", err)
+		}
+	}
+	if c.WriteIntake.Enabled {
+		if err := c.WriteIntake.Validate(); err != nil {
+			return fmt.Errorf("invalid write-intake config: %v", err)
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if the LDAP config is invalid.
+func (c LDAPConfig) Validate() error {
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", c.URL)
+	} else if u.Scheme == "ldaps" && c.StartTLS {
+		return errors.New("start-tls cannot be used with an ldaps url")
+	}
+	if c.SearchBaseDN == "" {
+		return errors.New("search-base-dn must be set")
+	} else if !strings.Contains(c.SearchFilter, "{username}") {
+		return errors.New("search-filter must contain {username}")
+	}
+	if c.GroupSearchFilter != "" && c.GroupSearchBaseDN == "" {
+		return errors.New("group-search-base-dn must be set with group-search-filter")
+	} else if c.GroupSearchFilter == "" && c.GroupAttribute == "" {
+		return errors.New("group-attribute or group-search-filter must be set")
+	}
+	if c.MaxIdleConnections < 0 {
+		return errors.New("max-idle-connections cannot be negative")
+	}
+	if len(c.Groups) == 0 {
+		return errors.New("at least one group must be configured")
+	}
+	for _, g := range c.Groups {
+		if g.DN == "" {
+			return errors.New("group dn must be set")
+		} else if g.Admin {
+			continue
+		} else if g.Database == "" {
+			return fmt.Errorf("group %q must be admin or have a database", g.DN)
+		} else if _, err := parseLDAPPrivilege(g.Privilege); err != nil {
+			return fmt.Errorf("group %q: %v", g.DN, err)
+		}
 	}
+	return nil
 }
 
 // Diagnostics returns a diagnostics representation of a subset of the Config.
@@ -68,6 +684,7 @@ func (c Config) Diagnostics() (*diagnostics.Diagnostics, error) {
 		"https-enabled":        c.HTTPSEnabled,
 		"max-row-limit":        c.MaxRowLimit,
 		"max-connection-limit": c.MaxConnectionLimit,
+		"max-request-memory":   c.MaxRequestMemory,
 		"access-log-path":      c.AccessLogPath,
 	}), nil
 }
@@ -1,10 +1,13 @@
 package httpd_test
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/influxdata/influxdb/services/httpd"
+	itoml "github.com/influxdata/influxdb/toml"
 )
 
 func TestConfig_Parse(t *testing.T) {
@@ -21,6 +24,97 @@ https-certificate = "/dev/null"
 unix-socket-enabled = true
 bind-socket = "/var/run/influxdb.sock"
 max-body-size = 100
+http2-enabled = false
+read-timeout = "30s"
+write-timeout = "1m"
+idle-timeout = "2m"
+max-header-size = "64k"
+keep-alives-disabled = true
+tcp-keep-alive-period = "1m"
+query-cursor-timeout = "5m"
+max-query-cursors = 10
+max-concurrent-write-limit = 100
+max-inflight-write-bytes = "512m"
+max-enqueued-write-limit = 1000
+enqueued-write-timeout = "10s"
+https-client-ca = "/etc/ssl/clients.pem"
+https-require-client-cert = true
+shared-secrets = ["old key", "older key"]
+jwks-url = "https://example.com/.well-known/jwks.json"
+jwks-refresh-interval = "10m"
+oidc-issuer = "https://accounts.example.com"
+oidc-audience = "influxdb"
+oidc-username-claim = "email"
+
+[oidc-role-users]
+  admins = "admin"
+
+[[client-certificate-user]]
+  cn = "telegraf-01"
+  user = "telegraf"
+
+[[client-certificate-user]]
+  ou = "operators"
+
+[[listener]]
+  bind-address = "127.0.0.1:8087"
+  auth-enabled = false
+  endpoints = ["/write", "/ping"]
+
+[[listener]]
+  bind-address = ":8443"
+  https-enabled = true
+  endpoints = ["/query"]
+
+[[cors]]
+  endpoints = ["/query"]
+  allowed-origins = ["https://*.example.com"]
+  allow-credentials = true
+  max-age = "10m"
+
+[[prom-read-downsample]]
+  retention-policy = "5m"
+  resolution = "5m"
+  function = "mean"
+
+[ldap]
+  enabled = true
+  url = "ldaps://ldap.example.com"
+  bind-dn = "cn=influxdb,dc=example,dc=com"
+  bind-password = "secret"
+  search-base-dn = "ou=people,dc=example,dc=com"
+  search-filter = "(sAMAccountName={username})"
+  group-attribute = "memberOf"
+  max-idle-connections = 8
+  local-fallback = true
+
+  [[ldap.group]]
+    dn = "cn=admins,ou=groups,dc=example,dc=com"
+    admin = true
+
+  [[ldap.group]]
+    dn = "cn=telegraf,ou=groups,dc=example,dc=com"
+    database = "telegraf"
+    privilege = "WRITE"
+
+[user-quota]
+  queries-per-second = 10
+  max-concurrent-requests = 4
+
+[user-quotas.telegraf]
+  points-per-second = 100000
+
+[database-quota]
+  points-per-second = 500000
+  bytes-per-second = 50000000
+
+[audit]
+  enabled = true
+  path = "/var/log/influxdb/audit.log"
+  max-size = "10m"
+  max-backups = 3
+  internal = true
+  exclude = ["select", "show"]
 `, &c); err != nil {
 		t.Fatal(err)
 	}
@@ -46,9 +140,171 @@ max-body-size = 100
 		t.Fatalf("unexpected bind unix socket: %v", c.BindSocket)
 	} else if c.MaxBodySize != 100 {
 		t.Fatalf("unexpected max-body-size: %v", c.MaxBodySize)
+	} else if c.HTTP2Enabled || !c.KeepAlivesDisabled || time.Duration(c.TCPKeepAlivePeriod) != time.Minute {
+		t.Fatalf("unexpected connection settings: %v, %v, %v", c.HTTP2Enabled, c.KeepAlivesDisabled, c.TCPKeepAlivePeriod)
+	} else if time.Duration(c.ReadTimeout) != 30*time.Second || time.Duration(c.WriteTimeout) != time.Minute ||
+		time.Duration(c.IdleTimeout) != 2*time.Minute || c.MaxHeaderSize != 64*1024 {
+		t.Fatalf("unexpected timeouts: %v, %v, %v, %v", c.ReadTimeout, c.WriteTimeout, c.IdleTimeout, c.MaxHeaderSize)
+	} else if time.Duration(c.QueryCursorTimeout) != 5*time.Minute || c.MaxQueryCursors != 10 {
+		t.Fatalf("unexpected query cursors: %v, %v", c.QueryCursorTimeout, c.MaxQueryCursors)
+	} else if c.MaxConcurrentWriteLimit != 100 || c.MaxInflightWriteBytes != 512*1024*1024 ||
+		c.MaxEnqueuedWriteLimit != 1000 || time.Duration(c.EnqueuedWriteTimeout) != 10*time.Second {
+		t.Fatalf("unexpected write limits: %v, %v, %v, %v", c.MaxConcurrentWriteLimit, c.MaxInflightWriteBytes, c.MaxEnqueuedWriteLimit, c.EnqueuedWriteTimeout)
+	} else if !reflect.DeepEqual(c.SharedSecrets, []string{"old key", "older key"}) {
+		t.Fatalf("unexpected shared-secrets: %v", c.SharedSecrets)
+	} else if c.JWKSURL != "https://example.com/.well-known/jwks.json" {
+		t.Fatalf("unexpected jwks-url: %v", c.JWKSURL)
+	} else if time.Duration(c.JWKSRefreshInterval) != 10*time.Minute {
+		t.Fatalf("unexpected jwks-refresh-interval: %v", c.JWKSRefreshInterval)
+	} else if c.OIDCIssuer != "https://accounts.example.com" {
+		t.Fatalf("unexpected oidc-issuer: %v", c.OIDCIssuer)
+	} else if c.OIDCAudience != "influxdb" {
+		t.Fatalf("unexpected oidc-audience: %v", c.OIDCAudience)
+	} else if c.OIDCUsernameClaim != "email" {
+		t.Fatalf("unexpected oidc-username-claim: %v", c.OIDCUsernameClaim)
+	} else if !reflect.DeepEqual(c.OIDCRoleUsers, map[string]string{"admins": "admin"}) {
+		t.Fatalf("unexpected oidc-role-users: %v", c.OIDCRoleUsers)
+	} else if c.HTTPSClientCA != "/etc/ssl/clients.pem" || !c.HTTPSRequireClientCert {
+		t.Fatalf("unexpected https client ca: %v, %v", c.HTTPSClientCA, c.HTTPSRequireClientCert)
+	} else if !reflect.DeepEqual(c.ClientCertificateUsers, []httpd.ClientCertificateUser{{CN: "telegraf-01", User: "telegraf"}, {OU: "operators"}}) {
+		t.Fatalf("unexpected client-certificate-user: %+v", c.ClientCertificateUsers)
+	} else if len(c.Listeners) != 2 || c.Listeners[0].BindAddress != "127.0.0.1:8087" || c.Listeners[0].AuthEnabled == nil || *c.Listeners[0].AuthEnabled ||
+		!reflect.DeepEqual(c.Listeners[0].Endpoints, []string{"/write", "/ping"}) {
+		t.Fatalf("unexpected listener: %+v", c.Listeners)
+	} else if !c.Listeners[1].HTTPSEnabled || c.Listeners[1].AuthEnabled != nil || !reflect.DeepEqual(c.Listeners[1].Endpoints, []string{"/query"}) {
+		t.Fatalf("unexpected listener: %+v", c.Listeners[1])
+	} else if !reflect.DeepEqual(c.PromReadDownsamples, []httpd.PromReadDownsampleConfig{{RetentionPolicy: "5m", Resolution: itoml.Duration(5 * time.Minute), Function: "mean"}}) {
+		t.Fatalf("unexpected prom-read-downsample: %+v", c.PromReadDownsamples)
+	} else if !reflect.DeepEqual(c.CORS, []httpd.CORSConfig{{
+		Endpoints:        []string{"/query"},
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowCredentials: true,
+		MaxAge:           itoml.Duration(10 * time.Minute),
+	}}) {
+		t.Fatalf("unexpected cors: %+v", c.CORS)
+	} else if !c.LDAP.Enabled || c.LDAP.URL != "ldaps://ldap.example.com" || c.LDAP.BindDN != "cn=influxdb,dc=example,dc=com" {
+		t.Fatalf("unexpected ldap: %+v", c.LDAP)
+	} else if c.LDAP.SearchFilter != "(sAMAccountName={username})" || c.LDAP.MaxIdleConnections != 8 || !c.LDAP.LocalFallback {
+		t.Fatalf("unexpected ldap: %+v", c.LDAP)
+	} else if !reflect.DeepEqual(c.LDAP.Groups, []httpd.LDAPGroup{
+		{DN: "cn=admins,ou=groups,dc=example,dc=com", Admin: true},
+		{DN: "cn=telegraf,ou=groups,dc=example,dc=com", Database: "telegraf", Privilege: "WRITE"},
+	}) {
+		t.Fatalf("unexpected ldap groups: %+v", c.LDAP.Groups)
+	} else if c.UserQuota != (httpd.QuotaConfig{QueriesPerSecond: 10, MaxConcurrentRequests: 4}) {
+		t.Fatalf("unexpected user-quota: %+v", c.UserQuota)
+	} else if !reflect.DeepEqual(c.UserQuotas, map[string]httpd.QuotaConfig{"telegraf": {PointsPerSecond: 100000}}) {
+		t.Fatalf("unexpected user-quotas: %+v", c.UserQuotas)
+	} else if c.DatabaseQuota != (httpd.QuotaConfig{PointsPerSecond: 500000, BytesPerSecond: 50000000}) {
+		t.Fatalf("unexpected database-quota: %+v", c.DatabaseQuota)
+	} else if !c.Audit.Enabled || c.Audit.Path != "/var/log/influxdb/audit.log" || c.Audit.MaxSize != 10*1024*1024 || c.Audit.MaxBackups != 3 || !c.Audit.Internal {
+		t.Fatalf("unexpected audit: %+v", c.Audit)
+	} else if !reflect.DeepEqual(c.Audit.Exclude, []string{"select", "show"}) {
+		t.Fatalf("unexpected audit exclude: %v", c.Audit.Exclude)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
 	}
 }
 
+func TestConfig_Validate(t *testing.T) {
+	for _, test := range []struct {
+		fn  func(c *httpd.Config)
+		err bool
+	}{
+		{fn: func(c *httpd.Config) {}},
+		{fn: func(c *httpd.Config) { c.JWKSURL = "https://example.com/jwks" }},
+		{fn: func(c *httpd.Config) { c.JWKSURL = "example.com/jwks" }, err: true},
+		{fn: func(c *httpd.Config) { c.OIDCIssuer = "https://accounts.example.com" }},
+		{fn: func(c *httpd.Config) { c.OIDCIssuer = "accounts.example.com" }, err: true},
+		{fn: func(c *httpd.Config) { c.OIDCIssuer = "https://accounts.example.com"; c.OIDCUsernameClaim = "" }, err: true},
+		{fn: func(c *httpd.Config) {
+			c.OIDCIssuer = "https://accounts.example.com"
+			c.OIDCUsernameClaim = ""
+			c.OIDCRoleUsers = map[string]string{"admins": "admin"}
+		}},
+		{fn: func(c *httpd.Config) { c.HTTPSRequireClientCert = true }, err: true},
+		{fn: func(c *httpd.Config) { c.ClientCertificateUsers = []httpd.ClientCertificateUser{{CN: "client1"}} }, err: true},
+		{fn: func(c *httpd.Config) {
+			c.HTTPSClientCA = "/etc/ssl/clients.pem"
+			c.HTTPSRequireClientCert = true
+			c.ClientCertificateUsers = []httpd.ClientCertificateUser{{OU: "operators"}, {CN: "client1", User: "user1"}}
+		}},
+		{fn: func(c *httpd.Config) {
+			c.HTTPSClientCA = "/etc/ssl/clients.pem"
+			c.ClientCertificateUsers = []httpd.ClientCertificateUser{{User: "user1"}}
+		}, err: true},
+		{fn: func(c *httpd.Config) { c.LDAP.URL = "invalid" }},
+		{fn: func(c *httpd.Config) { enableLDAP(c) }},
+		{fn: func(c *httpd.Config) { enableLDAP(c); c.LDAP.URL = "ldap.example.com" }, err: true},
+		{fn: func(c *httpd.Config) { enableLDAP(c); c.LDAP.URL = "ldaps://ldap.example.com"; c.LDAP.StartTLS = true }, err: true},
+		{fn: func(c *httpd.Config) { enableLDAP(c); c.LDAP.SearchBaseDN = "" }, err: true},
+		{fn: func(c *httpd.Config) { enableLDAP(c); c.LDAP.SearchFilter = "(uid=alice)" }, err: true},
+		{fn: func(c *httpd.Config) { enableLDAP(c); c.LDAP.GroupSearchFilter = "(member={dn})" }, err: true},
+		{fn: func(c *httpd.Config) { enableLDAP(c); c.LDAP.Groups = nil }, err: true},
+		{fn: func(c *httpd.Config) { enableLDAP(c); c.LDAP.Groups[0].Privilege = "OWNER" }, err: true},
+		{fn: func(c *httpd.Config) { enableLDAP(c); c.LDAP.Groups[0].Database = "" }, err: true},
+		{fn: func(c *httpd.Config) {
+			enableLDAP(c)
+			c.LDAP.Groups[0] = httpd.LDAPGroup{DN: "cn=admins,dc=example,dc=com", Admin: true}
+		}},
+		{fn: func(c *httpd.Config) { c.QueryCursorTimeout = -1 }, err: true},
+		{fn: func(c *httpd.Config) { c.MaxQueryCursors = -1 }, err: true},
+		{fn: func(c *httpd.Config) { c.Listeners = []httpd.ListenerConfig{{BindAddress: ":8087"}} }},
+		{fn: func(c *httpd.Config) { c.Listeners = []httpd.ListenerConfig{{Endpoints: []string{"/write"}}} }, err: true},
+		{fn: func(c *httpd.Config) {
+			c.CORS = []httpd.CORSConfig{{AllowedOrigins: []string{"*", "https://grafana.example.com", "http://*.example.com:3000"}}}
+		}},
+		{fn: func(c *httpd.Config) { c.CORS = []httpd.CORSConfig{{Endpoints: []string{"/query"}}} }, err: true},
+		{fn: func(c *httpd.Config) { c.CORS = []httpd.CORSConfig{{AllowedOrigins: []string{"grafana.example.com"}}} }, err: true},
+		{fn: func(c *httpd.Config) {
+			c.CORS = []httpd.CORSConfig{{AllowedOrigins: []string{"*"}, Endpoints: []string{"query"}}}
+		}, err: true},
+		{fn: func(c *httpd.Config) {
+			c.PromReadDownsamples = []httpd.PromReadDownsampleConfig{{RetentionPolicy: "5m", Resolution: itoml.Duration(5 * time.Minute), Function: "max"}}
+		}},
+		{fn: func(c *httpd.Config) {
+			c.PromReadDownsamples = []httpd.PromReadDownsampleConfig{{RetentionPolicy: "5m", Function: "max"}}
+		}, err: true},
+		{fn: func(c *httpd.Config) {
+			c.PromReadDownsamples = []httpd.PromReadDownsampleConfig{{RetentionPolicy: "5m", Resolution: itoml.Duration(5 * time.Minute), Function: "last"}}
+		}, err: true},
+		{fn: func(c *httpd.Config) { c.ReadTimeout = -1 }, err: true},
+		{fn: func(c *httpd.Config) { c.IdleTimeout = -1 }, err: true},
+		{fn: func(c *httpd.Config) { c.TCPKeepAlivePeriod = -1 }, err: true},
+		{fn: func(c *httpd.Config) { c.MaxConcurrentWriteLimit = -1 }, err: true},
+		{fn: func(c *httpd.Config) { c.MaxEnqueuedWriteLimit = -1 }, err: true},
+		{fn: func(c *httpd.Config) { c.EnqueuedWriteTimeout = -1 }, err: true},
+		{fn: func(c *httpd.Config) { c.UserQuota.QueriesPerSecond = 1 }},
+		{fn: func(c *httpd.Config) { c.UserQuota.PointsPerSecond = -1 }, err: true},
+		{fn: func(c *httpd.Config) { c.DatabaseQuota.BytesPerSecond = -1 }, err: true},
+		{fn: func(c *httpd.Config) { c.DatabaseQuota.MaxConcurrentRequests = -1 }, err: true},
+		{fn: func(c *httpd.Config) {
+			c.DatabaseQuotas = map[string]httpd.QuotaConfig{"db0": {QueriesPerSecond: -1}}
+		}, err: true},
+		{fn: func(c *httpd.Config) { c.Audit.Enabled = true; c.Audit.Include = []string{"create", "DROP", "write"} }},
+		{fn: func(c *httpd.Config) { c.Audit.Enabled = true; c.Audit.Exclude = []string{"selects"} }, err: true},
+		{fn: func(c *httpd.Config) { c.Audit.Enabled = true; c.Audit.MaxBackups = -1 }, err: true},
+	} {
+		c := httpd.NewConfig()
+		test.fn(&c)
+		if err := c.Validate(); test.err && err == nil {
+			t.Errorf("%+v: expected error", c)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", c, err)
+		}
+	}
+}
+
+// enableLDAP enables a valid LDAP config.
+func enableLDAP(c *httpd.Config) {
+	c.LDAP.Enabled = true
+	c.LDAP.URL = "ldap://ldap.example.com"
+	c.LDAP.SearchBaseDN = "ou=people,dc=example,dc=com"
+	c.LDAP.Groups = []httpd.LDAPGroup{{DN: "cn=readers,dc=example,dc=com", Database: "db0", Privilege: "read"}}
+}
+
 func TestConfig_WriteTracing(t *testing.T) {
 	c := httpd.Config{WriteTracing: true}
 	s := httpd.NewService(c)
@@ -0,0 +1,378 @@
+package httpd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxql"
+	"go.uber.org/zap"
+)
+
+// continuousQueryRequest is the definition of a continuous query created with
+// the continuous query endpoints.
+type continuousQueryRequest struct {
+	Database string `json:"database,omitempty"`
+	Name     string `json:"name,omitempty"`
+
+	// Query is the SELECT ... INTO statement of the continuous query.
+	Query string `json:"query"`
+
+	// Resample sets the RESAMPLE clause of the continuous query, with
+	// durations such as "30m".
+	Resample struct {
+		Every string `json:"every,omitempty"`
+		For   string `json:"for,omitempty"`
+	} `json:"resample"`
+}
+
+// continuousQueryStatus is a continuous query, and its status.
+type continuousQueryStatus struct {
+	Database  string     `json:"database"`
+	Name      string     `json:"name"`
+	Query     string     `json:"query"`
+	LastRun   *time.Time `json:"lastRun,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// errContinuousQueryDatabaseNotFound is returned when the database of a
+// continuous query does not exist.
+var errContinuousQueryDatabaseNotFound = errors.New("database not found")
+
+// errContinuousQuerierDisabled is returned when continuous queries are run
+// while the continuous query service is disabled.
+var errContinuousQuerierDisabled = errors.New("continuous query service is not enabled")
+
+// serveContinuousQueries lists the continuous queries of the database of the
+// db parameter, or of all databases the user may read if it is not set.
+func (h *Handler) serveContinuousQueries(w http.ResponseWriter, r *http.Request, user meta.User) {
+	var dbs []meta.DatabaseInfo
+	if db := r.FormValue("db"); db != "" {
+		di := h.MetaClient.Database(db)
+		if di == nil {
+			h.continuousQueryError(w, errContinuousQueryDatabaseNotFound)
+			return
+		}
+		if err := h.authorizeContinuousQueries(r, user, db); err != nil {
+			h.continuousQueryError(w, err)
+			return
+		}
+		dbs = append(dbs, *di)
+	} else {
+		for _, di := range h.MetaClient.Databases() {
+			if err := h.authorizeContinuousQueries(r, user, di.Name); err == nil {
+				dbs = append(dbs, di)
+			}
+		}
+	}
+
+	cqs := make([]continuousQueryStatus, 0)
+	for _, di := range dbs {
+		for _, cqi := range di.ContinuousQueries {
+			cqs = append(cqs, h.continuousQueryStatus(di.Name, cqi))
+		}
+	}
+	h.writeJSON(w, http.StatusOK, struct {
+		ContinuousQueries []continuousQueryStatus `json:"continuousQueries"`
+	}{cqs})
+}
+
+// serveContinuousQuery responds with the continuous query of the path.
+func (h *Handler) serveContinuousQuery(w http.ResponseWriter, r *http.Request, user meta.User) {
+	db, name := r.URL.Query().Get(":db"), r.URL.Query().Get(":name")
+	if err := h.authorizeContinuousQueries(r, user, db); err != nil {
+		h.continuousQueryError(w, err)
+		return
+	}
+	cqi, err := h.continuousQuery(db, name)
+	if err != nil {
+		h.continuousQueryError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, h.continuousQueryStatus(db, *cqi))
+}
+
+// serveCreateContinuousQuery creates the continuous query of the request body,
+// which must not exist yet.
+func (h *Handler) serveCreateContinuousQuery(w http.ResponseWriter, r *http.Request, user meta.User) {
+	var req continuousQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.httpError(w, "error parsing continuous query: "+err.Error(), http.StatusBadRequest)
+		return
+	} else if req.Database == "" || req.Name == "" {
+		h.httpError(w, "database and name are required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.continuousQuery(req.Database, req.Name); err == nil {
+		h.continuousQueryError(w, meta.ErrContinuousQueryExists)
+		return
+	} else if err != meta.ErrContinuousQueryNotFound {
+		h.continuousQueryError(w, err)
+		return
+	}
+	h.putContinuousQuery(w, r, user, req.Database, req.Name, req)
+}
+
+// servePutContinuousQuery creates the continuous query of the path with the
+// definition of the request body, replacing it if it exists with another
+// definition.
+func (h *Handler) servePutContinuousQuery(w http.ResponseWriter, r *http.Request, user meta.User) {
+	var req continuousQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.httpError(w, "error parsing continuous query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.putContinuousQuery(w, r, user, r.URL.Query().Get(":db"), r.URL.Query().Get(":name"), req)
+}
+
+// putContinuousQuery creates or replaces the continuous query name of the
+// database db. Continuous queries cannot be altered, so they are replaced by
+// dropping and creating them again.
+func (h *Handler) putContinuousQuery(w http.ResponseWriter, r *http.Request, user meta.User, db, name string, req continuousQueryRequest) {
+	existing, err := h.continuousQuery(db, name)
+	if err != nil && err != meta.ErrContinuousQueryNotFound {
+		h.continuousQueryError(w, err)
+		return
+	}
+
+	stmt, err := newCreateContinuousQueryStatement(h.MetaClient.Database(db), name, req)
+	if err != nil {
+		h.httpError(w, "error parsing continuous query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	code := http.StatusCreated
+	var stmts []influxql.Statement
+	if existing != nil {
+		code = http.StatusOK
+		if existing.Query != stmt.String() {
+			stmts = append(stmts, &influxql.DropContinuousQueryStatement{Name: name, Database: db})
+		}
+	}
+	if existing == nil || existing.Query != stmt.String() {
+		stmts = append(stmts, stmt)
+	}
+
+	// Unchanged continuous queries are authorized like their creation.
+	if len(stmts) == 0 {
+		if h.authEnabled(r) {
+			if err := h.authorizeQuery(user, &influxql.Query{Statements: []influxql.Statement{stmt}}, db); err != nil {
+				h.continuousQueryError(w, err)
+				return
+			}
+		}
+	} else if err := h.executeStatements(r, user, db, stmts...); err != nil {
+		h.continuousQueryError(w, err)
+		return
+	}
+
+	cqi, err := h.continuousQuery(db, name)
+	if err != nil {
+		h.continuousQueryError(w, err)
+		return
+	}
+	h.writeJSON(w, code, h.continuousQueryStatus(db, *cqi))
+}
+
+// serveDeleteContinuousQuery drops the continuous query of the path.
+func (h *Handler) serveDeleteContinuousQuery(w http.ResponseWriter, r *http.Request, user meta.User) {
+	db, name := r.URL.Query().Get(":db"), r.URL.Query().Get(":name")
+	if _, err := h.continuousQuery(db, name); err != nil {
+		h.continuousQueryError(w, err)
+		return
+	}
+	if err := h.executeStatements(r, user, db, &influxql.DropContinuousQueryStatement{Name: name, Database: db}); err != nil {
+		h.continuousQueryError(w, err)
+		return
+	}
+	h.writeHeader(w, http.StatusNoContent)
+}
+
+// serveRunContinuousQuery runs the continuous query of the path now, for the
+// intervals it would run for at this time.
+func (h *Handler) serveRunContinuousQuery(w http.ResponseWriter, r *http.Request, user meta.User) {
+	db, name := r.URL.Query().Get(":db"), r.URL.Query().Get(":name")
+	cqi, err := h.continuousQuery(db, name)
+	if err != nil {
+		h.continuousQueryError(w, err)
+		return
+	}
+
+	// Running a continuous query is authorized like its SELECT ... INTO
+	// statement.
+	if h.authEnabled(r) {
+		stmt, err := influxql.ParseStatement(cqi.Query)
+		if err != nil {
+			h.httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cq, ok := stmt.(*influxql.CreateContinuousQueryStatement)
+		if !ok {
+			h.httpError(w, fmt.Sprintf("invalid continuous query: %s", cqi.Query), http.StatusInternalServerError)
+			return
+		}
+		if err := h.authorizeQuery(user, &influxql.Query{Statements: []influxql.Statement{cq.Source}}, db); err != nil {
+			h.continuousQueryError(w, err)
+			return
+		}
+	}
+
+	if h.ContinuousQuerier == nil {
+		h.httpError(w, errContinuousQuerierDisabled.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err := h.ContinuousQuerier.Run(db, name, time.Now()); err != nil {
+		h.continuousQueryError(w, err)
+		return
+	}
+	h.writeHeader(w, http.StatusAccepted)
+}
+
+// newCreateContinuousQueryStatement returns the statement creating the
+// continuous query name of the database di with the definition of req. The
+// statement is validated by parsing it, and its measurements are qualified
+// with the database and its default retention policy, as the continuous
+// queries are stored.
+func newCreateContinuousQueryStatement(di *meta.DatabaseInfo, name string, req continuousQueryRequest) (*influxql.CreateContinuousQueryStatement, error) {
+	stmt, err := influxql.ParseStatement(req.Query)
+	if err != nil {
+		return nil, err
+	}
+	source, ok := stmt.(*influxql.SelectStatement)
+	if !ok {
+		return nil, errors.New("query must be a SELECT statement")
+	}
+
+	influxql.WalkFunc(source, func(n influxql.Node) {
+		if m, ok := n.(*influxql.Measurement); ok {
+			if m.Database == "" {
+				m.Database = di.Name
+			}
+			if m.RetentionPolicy == "" {
+				m.RetentionPolicy = di.DefaultRetentionPolicy
+			}
+		}
+	})
+
+	cq := &influxql.CreateContinuousQueryStatement{Name: name, Database: di.Name, Source: source}
+	if req.Resample.Every != "" {
+		if cq.ResampleEvery, err = influxql.ParseDuration(req.Resample.Every); err != nil {
+			return nil, fmt.Errorf("invalid resample every: %s", err)
+		}
+	}
+	if req.Resample.For != "" {
+		if cq.ResampleFor, err = influxql.ParseDuration(req.Resample.For); err != nil {
+			return nil, fmt.Errorf("invalid resample for: %s", err)
+		}
+	}
+
+	stmt, err = influxql.ParseStatement(cq.String())
+	if err != nil {
+		return nil, err
+	}
+	return stmt.(*influxql.CreateContinuousQueryStatement), nil
+}
+
+// continuousQuery returns the continuous query name of the database db.
+func (h *Handler) continuousQuery(db, name string) (*meta.ContinuousQueryInfo, error) {
+	di := h.MetaClient.Database(db)
+	if di == nil {
+		return nil, errContinuousQueryDatabaseNotFound
+	}
+	for i := range di.ContinuousQueries {
+		if di.ContinuousQueries[i].Name == name {
+			return &di.ContinuousQueries[i], nil
+		}
+	}
+	return nil, meta.ErrContinuousQueryNotFound
+}
+
+// continuousQueryStatus returns the status of the continuous query cqi of the
+// database db.
+func (h *Handler) continuousQueryStatus(db string, cqi meta.ContinuousQueryInfo) continuousQueryStatus {
+	status := continuousQueryStatus{Database: db, Name: cqi.Name, Query: cqi.Query}
+	if h.ContinuousQuerier != nil {
+		lastRun, err := h.ContinuousQuerier.LastRun(db, cqi.Name)
+		if !lastRun.IsZero() {
+			status.LastRun = &lastRun
+		}
+		if err != nil {
+			status.LastError = err.Error()
+		}
+	}
+	return status
+}
+
+// authorizeContinuousQueries authorizes the user to list the continuous
+// queries of the database db.
+func (h *Handler) authorizeContinuousQueries(r *http.Request, user meta.User, db string) error {
+	if !h.authEnabled(r) {
+		return nil
+	}
+	return h.authorizeQuery(user, &influxql.Query{Statements: []influxql.Statement{&influxql.ShowContinuousQueriesStatement{}}}, db)
+}
+
+// executeStatements executes the statements on the database db, authorized
+// like the statements of queries.
+func (h *Handler) executeStatements(r *http.Request, user meta.User, db string, stmts ...influxql.Statement) error {
+	q := &influxql.Query{Statements: stmts}
+	opts := query.ExecutionOptions{Database: db}
+	if h.authEnabled(r) {
+		if err := h.authorizeQuery(user, q, db); err != nil {
+			return err
+		}
+		// The current user determines the authorized actions.
+		opts.Authorizer = user
+	} else {
+		// Auth is disabled, so allow everything.
+		opts.Authorizer = query.OpenAuthorizer
+	}
+
+	closing := make(chan struct{})
+	defer close(closing)
+	for result := range h.QueryExecutor.ExecuteQuery(q, opts, closing) {
+		if result != nil && result.Err != nil {
+			return result.Err
+		}
+	}
+	return nil
+}
+
+// continuousQueryError responds with the error of a continuous query request.
+func (h *Handler) continuousQueryError(w http.ResponseWriter, err error) {
+	switch err := err.(type) {
+	case *meta.ErrAuthorize:
+		h.Logger.Info("Unauthorized request",
+			zap.String("user", err.User),
+			zap.Stringer("query", err.Query),
+			logger.Database(err.Database))
+		h.httpError(w, "error authorizing query: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	switch err {
+	case meta.ErrContinuousQueryNotFound, errContinuousQueryDatabaseNotFound:
+		h.httpError(w, err.Error(), http.StatusNotFound)
+	case meta.ErrContinuousQueryExists:
+		h.httpError(w, err.Error(), http.StatusConflict)
+	default:
+		h.httpError(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// writeJSON writes the JSON encoding of v with the status code.
+func (h *Handler) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		h.httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	h.writeHeader(w, code)
+	w.Write(b)
+}
@@ -0,0 +1,202 @@
+package httpd
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The methods and headers of the cross-origin requests allowed by default.
+var (
+	defaultCORSMethods = []string{
+		`DELETE`,
+		`GET`,
+		`OPTIONS`,
+		`POST`,
+		`PUT`,
+	}
+	defaultCORSHeaders = []string{
+		`Accept`,
+		`Accept-Encoding`,
+		`Authorization`,
+		`Content-Length`,
+		`Content-Type`,
+		`X-CSRF-Token`,
+		`X-HTTP-Method-Override`,
+	}
+	defaultCORSExposedHeaders = []string{
+		`Date`,
+		`X-InfluxDB-Version`,
+		`X-InfluxDB-Build`,
+	}
+)
+
+// corsRule is the rule of the cross-origin requests to a group of endpoints.
+type corsRule struct {
+	endpoints   []string // Paths of the endpoints, or nil for all.
+	origins     []string
+	methods     []string
+	headers     []string
+	exposed     []string
+	credentials bool
+	maxAge      time.Duration
+}
+
+// newCORSRules returns the rules of configs. Without any, all origins are
+// allowed to all endpoints.
+func newCORSRules(configs []CORSConfig) []*corsRule {
+	if len(configs) == 0 {
+		return []*corsRule{{
+			origins: []string{"*"},
+			methods: defaultCORSMethods,
+			headers: defaultCORSHeaders,
+			exposed: defaultCORSExposedHeaders,
+		}}
+	}
+
+	rules := make([]*corsRule, 0, len(configs))
+	for _, c := range configs {
+		rule := &corsRule{
+			origins:     c.AllowedOrigins,
+			methods:     c.AllowedMethods,
+			headers:     c.AllowedHeaders,
+			exposed:     c.ExposedHeaders,
+			credentials: c.AllowCredentials,
+			maxAge:      time.Duration(c.MaxAge),
+		}
+		for _, e := range c.Endpoints {
+			rule.endpoints = append(rule.endpoints, strings.TrimSuffix(e, "/"))
+		}
+		if len(rule.methods) == 0 {
+			rule.methods = defaultCORSMethods
+		}
+		if len(rule.headers) == 0 {
+			rule.headers = defaultCORSHeaders
+		}
+		if len(rule.exposed) == 0 {
+			rule.exposed = defaultCORSExposedHeaders
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// allowsOrigin returns true if the rule allows requests from origin.
+func (rule *corsRule) allowsOrigin(origin string) bool {
+	for _, o := range rule.origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+
+		// Wildcards allow the subdomains of a domain.
+		if i := strings.Index(o, "://*."); i >= 0 {
+			scheme, domain := strings.ToLower(o[:i+3]), strings.ToLower(o[i+4:])
+			lower := strings.ToLower(origin)
+			if strings.HasPrefix(lower, scheme) && strings.HasSuffix(lower, domain) && len(lower) > len(scheme)+len(domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsMethod returns true if the rule allows requests of method.
+func (rule *corsRule) allowsMethod(method string) bool {
+	return containsFold(rule.methods, method)
+}
+
+// allowsHeaders returns true if the rule allows requests with the headers of
+// the comma-separated list.
+func (rule *corsRule) allowsHeaders(list string) bool {
+	if containsFold(rule.headers, "*") {
+		return true
+	}
+	for _, header := range strings.Split(list, ",") {
+		if header = strings.TrimSpace(header); header != "" && !containsFold(rule.headers, header) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(a []string, s string) bool {
+	for _, v := range a {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsRule returns the rule of the cross-origin requests to the endpoint of
+// path, or nil if there is none.
+func (h *Handler) corsRule(path string) *corsRule {
+	for _, rule := range h.corsRules {
+		if matchesEndpoint(rule.endpoints, path) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// setCORSHeaders sets the CORS headers of the response to r if the rule of
+// its endpoint allows it. Preflight requests are allowed if the rule allows
+// their method and headers too.
+func (h *Handler) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	w.Header().Add("Vary", "Origin")
+
+	rule := h.corsRule(r.URL.Path)
+	if rule == nil || !rule.allowsOrigin(origin) {
+		return
+	}
+
+	if r.Method == "OPTIONS" {
+		if method := r.Header.Get("Access-Control-Request-Method"); method != "" && !rule.allowsMethod(method) {
+			return
+		}
+		requested := r.Header.Get("Access-Control-Request-Headers")
+		if !rule.allowsHeaders(requested) {
+			return
+		}
+
+		w.Header().Set(`Access-Control-Allow-Methods`, strings.Join(rule.methods, ", "))
+		if containsFold(rule.headers, "*") && requested != "" {
+			w.Header().Set(`Access-Control-Allow-Headers`, requested)
+		} else {
+			w.Header().Set(`Access-Control-Allow-Headers`, strings.Join(rule.headers, ", "))
+		}
+		if rule.maxAge > 0 {
+			w.Header().Set(`Access-Control-Max-Age`, strconv.Itoa(int(rule.maxAge/time.Second)))
+		}
+	}
+
+	w.Header().Set(`Access-Control-Allow-Origin`, origin)
+	if rule.credentials {
+		w.Header().Set(`Access-Control-Allow-Credentials`, "true")
+	}
+	w.Header().Set(`Access-Control-Expose-Headers`, strings.Join(rule.exposed, ", "))
+}
+
+// cors responds to incoming requests and adds the appropriate cors headers
+func (h *Handler) cors(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.setCORSHeaders(w, r)
+
+		if r.Method == "OPTIONS" {
+			return
+		}
+
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// isPreflight returns true if r is the preflight request of a cross-origin
+// request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
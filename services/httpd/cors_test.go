@@ -0,0 +1,91 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/toml"
+)
+
+// Ensure cross-origin requests are allowed by the first rule of their
+// endpoint.
+func TestHandler_CORS(t *testing.T) {
+	h := &Handler{corsRules: newCORSRules([]CORSConfig{
+		{
+			Endpoints:        []string{"/query"},
+			AllowedOrigins:   []string{"https://*.example.com"},
+			AllowedHeaders:   []string{"Authorization", "Content-Type"},
+			AllowCredentials: true,
+			MaxAge:           toml.Duration(10 * time.Minute),
+		},
+		{
+			AllowedOrigins: []string{"https://grafana.example.org"},
+			AllowedMethods: []string{"GET"},
+		},
+	})}
+	inner := h.cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, tt := range []struct {
+		name        string
+		method      string
+		path        string
+		origin      string
+		reqMethod   string
+		reqHeaders  string
+		allowed     bool
+		credentials string
+		maxAge      string
+	}{
+		{name: "subdomain", method: "GET", path: "/query", origin: "https://app.example.com", allowed: true, credentials: "true"},
+		{name: "domain", method: "GET", path: "/query", origin: "https://example.com"},
+		{name: "scheme", method: "GET", path: "/query", origin: "http://app.example.com"},
+		{name: "other rule", method: "GET", path: "/query", origin: "https://grafana.example.org"},
+		{name: "preflight", method: "OPTIONS", path: "/query", origin: "https://app.example.com", reqMethod: "POST", reqHeaders: "authorization, content-type", allowed: true, credentials: "true", maxAge: "600"},
+		{name: "preflight header", method: "OPTIONS", path: "/query", origin: "https://app.example.com", reqMethod: "POST", reqHeaders: "X-Custom"},
+		{name: "all endpoints", method: "GET", path: "/ping", origin: "https://grafana.example.org", allowed: true},
+		{name: "preflight method", method: "OPTIONS", path: "/ping", origin: "https://grafana.example.org", reqMethod: "POST"},
+		{name: "origin", method: "GET", path: "/ping", origin: "https://app.example.com"},
+	} {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		r.Header.Set("Origin", tt.origin)
+		if tt.reqMethod != "" {
+			r.Header.Set("Access-Control-Request-Method", tt.reqMethod)
+		}
+		if tt.reqHeaders != "" {
+			r.Header.Set("Access-Control-Request-Headers", tt.reqHeaders)
+		}
+		w := httptest.NewRecorder()
+		inner.ServeHTTP(w, r)
+
+		if origin := w.Header().Get("Access-Control-Allow-Origin"); tt.allowed && origin != tt.origin || !tt.allowed && origin != "" {
+			t.Errorf("%s: unexpected allowed origin: %q", tt.name, origin)
+		} else if credentials := w.Header().Get("Access-Control-Allow-Credentials"); credentials != tt.credentials {
+			t.Errorf("%s: unexpected allowed credentials: %q", tt.name, credentials)
+		} else if maxAge := w.Header().Get("Access-Control-Max-Age"); maxAge != tt.maxAge {
+			t.Errorf("%s: unexpected max age: %q", tt.name, maxAge)
+		} else if vary := w.Header().Get("Vary"); vary != "Origin" {
+			t.Errorf("%s: unexpected vary: %q", tt.name, vary)
+		}
+	}
+}
+
+// Ensure all origins are allowed to all endpoints without rules.
+func TestHandler_CORS_Default(t *testing.T) {
+	h := &Handler{corsRules: newCORSRules(nil)}
+	inner := h.cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest("OPTIONS", "/write", nil)
+	r.Header.Set("Origin", "http://localhost:3000")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	inner.ServeHTTP(w, r)
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "http://localhost:3000" {
+		t.Fatalf("unexpected allowed origin: %q", origin)
+	} else if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != "DELETE, GET, OPTIONS, POST, PUT" {
+		t.Fatalf("unexpected allowed methods: %q", methods)
+	}
+}
@@ -0,0 +1,186 @@
+package httpd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/query"
+)
+
+// queryCursor is a paged query. Each request for a page returns its next
+// result, and the cursor is kept for the next request until the results are
+// exhausted.
+type queryCursor struct {
+	id    string
+	user  string // Name of the user that started the query, if any.
+	db    string
+	epoch string
+
+	results <-chan *query.Result
+	next    *query.Result // Next result, or nil if there are no more.
+	closing chan struct{}
+	timer   *time.Timer
+	puts    int // Number of times the cursor was put, to ignore stale timers.
+}
+
+// newQueryCursor returns a cursor of the results of a query, and waits for
+// the first of them. Closing closing aborts the query.
+func newQueryCursor(results <-chan *query.Result, closing chan struct{}) *queryCursor {
+	c := &queryCursor{
+		results: results,
+		closing: closing,
+	}
+	c.next = c.read()
+	return c
+}
+
+// page returns the next result, or nil if there are none, and waits for the
+// one after it. more is true if there is another result.
+func (c *queryCursor) page() (r *query.Result, more bool) {
+	r = c.next
+	if r != nil {
+		c.next = c.read()
+	}
+	return r, c.next != nil
+}
+
+// read returns the next non-nil result, or nil if the query is done.
+func (c *queryCursor) read() *query.Result {
+	for r := range c.results {
+		if r != nil {
+			return r
+		}
+	}
+	return nil
+}
+
+// close aborts the query, and discards its remaining results.
+func (c *queryCursor) close() {
+	close(c.closing)
+	go func() {
+		for range c.results {
+		}
+	}()
+}
+
+// queryCursors holds the cursors of paged queries between requests. Cursors
+// not resumed within the timeout are closed.
+type queryCursors struct {
+	timeout time.Duration
+	max     int // 0 if unlimited.
+
+	mu      sync.Mutex
+	cursors map[string]*queryCursor
+	open    int // Number of cursors, including those in use by requests.
+	closed  bool
+}
+
+func newQueryCursors(timeout time.Duration, max int) *queryCursors {
+	if timeout == 0 {
+		timeout = DefaultQueryCursorTimeout
+	}
+	return &queryCursors{
+		timeout: timeout,
+		max:     max,
+		cursors: make(map[string]*queryCursor),
+	}
+}
+
+// reserve counts a new cursor against the maximum number of cursors. It
+// returns false if the maximum is reached. The cursor must be given to put or
+// done.
+func (cs *queryCursors) reserve() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.closed || (cs.max > 0 && cs.open >= cs.max) {
+		return false
+	}
+	cs.open++
+	return true
+}
+
+// put keeps c until it is taken or expires, and returns its id.
+func (cs *queryCursors) put(c *queryCursor) string {
+	if c.id == "" {
+		c.id = newQueryCursorID()
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.closed {
+		cs.open--
+		c.close()
+		return c.id
+	}
+	cs.cursors[c.id] = c
+	c.puts++
+	puts := c.puts
+	c.timer = time.AfterFunc(cs.timeout, func() { cs.expire(c, puts) })
+	return c.id
+}
+
+// take removes and returns the cursor with id, or nil if there is no such
+// cursor or it was started by another user. The cursor must be given back to
+// put or done.
+func (cs *queryCursors) take(id, user string) *queryCursor {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	c, ok := cs.cursors[id]
+	if !ok || c.user != user {
+		return nil
+	}
+	delete(cs.cursors, id)
+	c.timer.Stop()
+	return c
+}
+
+// done closes c, whose results are exhausted or no longer wanted.
+func (cs *queryCursors) done(c *queryCursor) {
+	cs.mu.Lock()
+	cs.open--
+	cs.mu.Unlock()
+	c.close()
+}
+
+// expire closes c if it was not taken since its puts-th put.
+func (cs *queryCursors) expire(c *queryCursor, puts int) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.cursors[c.id] != c || c.puts != puts {
+		return
+	}
+	delete(cs.cursors, c.id)
+	cs.open--
+	c.close()
+}
+
+// len returns the number of open cursors.
+func (cs *queryCursors) len() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.open
+}
+
+// close closes all cursors. Cursors in use are closed when given back.
+func (cs *queryCursors) close() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.closed = true
+	for id, c := range cs.cursors {
+		c.timer.Stop()
+		delete(cs.cursors, id)
+		cs.open--
+		c.close()
+	}
+}
+
+// newQueryCursorID returns a random, unguessable cursor id.
+func newQueryCursorID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
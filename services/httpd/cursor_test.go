@@ -0,0 +1,100 @@
+package httpd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/query"
+)
+
+// Ensure cursors page through the results of their query, and can only be
+// resumed by the user that started them.
+func TestQueryCursors(t *testing.T) {
+	cs := newQueryCursors(time.Minute, 0)
+	c := newTestQueryCursor(3)
+	c.user = "alice"
+	if !cs.reserve() {
+		t.Fatal("expected reservation")
+	}
+
+	for i := 0; i < 3; i++ {
+		r, more := c.page()
+		if r == nil || r.StatementID != i {
+			t.Fatalf("unexpected result: %+v", r)
+		} else if more != (i < 2) {
+			t.Fatalf("%d: unexpected more: %v", i, more)
+		} else if !more {
+			cs.done(c)
+			break
+		}
+
+		id := cs.put(c)
+		if cs.take(id, "bob") != nil {
+			t.Fatal("unexpected cursor of another user")
+		} else if cs.take(id, "alice") != c {
+			t.Fatal("expected cursor")
+		} else if cs.take(id, "alice") != nil {
+			t.Fatal("unexpected cursor in use")
+		}
+	}
+	if n := cs.len(); n != 0 {
+		t.Fatalf("unexpected open cursors: %d", n)
+	}
+}
+
+// Ensure cursors are closed when not resumed in time, and the number of
+// cursors is limited.
+func TestQueryCursors_Expire(t *testing.T) {
+	cs := newQueryCursors(10*time.Millisecond, 1)
+	if !cs.reserve() {
+		t.Fatal("expected reservation")
+	} else if cs.reserve() {
+		t.Fatal("unexpected reservation")
+	}
+
+	c := newTestQueryCursor(2)
+	id := cs.put(c)
+	time.Sleep(50 * time.Millisecond)
+	if cs.take(id, "") != nil {
+		t.Fatal("unexpected expired cursor")
+	}
+	select {
+	case <-c.closing:
+	default:
+		t.Fatal("expected query to be aborted")
+	}
+	if !cs.reserve() {
+		t.Fatal("expected reservation")
+	}
+
+	// Cursors are closed with the handler.
+	c = newTestQueryCursor(2)
+	cs.put(c)
+	cs.close()
+	select {
+	case <-c.closing:
+	default:
+		t.Fatal("expected query to be aborted")
+	}
+	if cs.reserve() {
+		t.Fatal("unexpected reservation")
+	}
+}
+
+// newTestQueryCursor returns a cursor of a query with n results, which
+// sends them until aborted.
+func newTestQueryCursor(n int) *queryCursor {
+	results := make(chan *query.Result)
+	closing := make(chan struct{})
+	go func() {
+		defer close(results)
+		for i := 0; i < n; i++ {
+			select {
+			case results <- &query.Result{StatementID: i}:
+			case <-closing:
+				return
+			}
+		}
+	}()
+	return newQueryCursor(results, closing)
+}
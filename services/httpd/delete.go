@@ -0,0 +1,488 @@
+package httpd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxql"
+	"go.uber.org/zap"
+)
+
+// deleteTaskRetention is how long the status of a finished delete is kept.
+const deleteTaskRetention = time.Hour
+
+// The statuses of deletes.
+const (
+	deleteStatusRunning   = "running"
+	deleteStatusCompleted = "completed"
+	deleteStatusFailed    = "failed"
+	deleteStatusCanceled  = "canceled"
+)
+
+// deleteRequest is the body of a delete request, as in the InfluxDB 2.0 API.
+type deleteRequest struct {
+	Start     string `json:"start"`
+	Stop      string `json:"stop"`
+	Predicate string `json:"predicate"`
+}
+
+// deleteStatus is the status of a delete.
+type deleteStatus struct {
+	ID           string     `json:"id"`
+	Database     string     `json:"database"`
+	Start        time.Time  `json:"start"`
+	Stop         time.Time  `json:"stop"`
+	Predicate    string     `json:"predicate,omitempty"`
+	Status       string     `json:"status"`
+	Measurements int        `json:"measurements"` // Number of measurements to delete from, once known.
+	Deleted      int        `json:"deleted"`      // Number of measurements deleted from.
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+}
+
+// deleteTask is a delete running in the background. Its points are deleted
+// from one measurement at a time, so that it can be canceled between them.
+type deleteTask struct {
+	user        string        // Name of the user that started the delete, if any.
+	measurement string        // Measurement of the predicate, if any.
+	tagCond     influxql.Expr // Condition of the predicate on tags, if any.
+	cond        influxql.Expr // Condition of the delete statements.
+
+	mu       sync.Mutex
+	status   deleteStatus
+	canceled bool
+	closing  chan struct{}
+}
+
+// cancel cancels the delete if it is running.
+func (t *deleteTask) cancel() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status.Status == deleteStatusRunning && !t.canceled {
+		t.canceled = true
+		close(t.closing)
+	}
+}
+
+// finish sets the status of the delete when it is done.
+func (t *deleteTask) finish(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now().UTC()
+	t.status.FinishedAt = &now
+	switch {
+	case t.canceled && (err != nil || t.status.Deleted < t.status.Measurements):
+		t.status.Status = deleteStatusCanceled
+	case err != nil:
+		t.status.Status = deleteStatusFailed
+		t.status.Error = err.Error()
+	default:
+		t.status.Status = deleteStatusCompleted
+	}
+}
+
+// snapshot returns the current status of the delete.
+func (t *deleteTask) snapshot() deleteStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// deleteTasks holds the running deletes, and the finished ones until they
+// expire.
+type deleteTasks struct {
+	mu     sync.Mutex
+	tasks  map[string]*deleteTask
+	closed bool
+}
+
+func newDeleteTasks() *deleteTasks {
+	return &deleteTasks{tasks: make(map[string]*deleteTask)}
+}
+
+// add keeps the task t, and returns its id. It returns false if the deletes
+// are closed.
+func (ts *deleteTasks) add(t *deleteTask) (string, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.closed {
+		return "", false
+	}
+	id := newQueryCursorID()
+	t.status.ID = id
+	ts.tasks[id] = t
+	return id, true
+}
+
+// get returns the task with id, or nil if there is no such task or it was
+// started by another user.
+func (ts *deleteTasks) get(id, user string) *deleteTask {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t, ok := ts.tasks[id]
+	if !ok || t.user != user {
+		return nil
+	}
+	return t
+}
+
+// expire removes the task with id once its status has been kept for the
+// retention time.
+func (ts *deleteTasks) expire(id string) {
+	time.AfterFunc(deleteTaskRetention, func() {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		delete(ts.tasks, id)
+	})
+}
+
+// close cancels the running deletes.
+func (ts *deleteTasks) close() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.closed = true
+	for _, t := range ts.tasks {
+		t.cancel()
+	}
+}
+
+// serveDelete starts deleting the points of the database of the db parameter
+// between the start and stop times of the request, and matching its
+// predicate. It responds with the status of the delete, which runs in the
+// background, before it is done.
+func (h *Handler) serveDelete(w http.ResponseWriter, r *http.Request, user meta.User) {
+	atomic.AddInt64(&h.stats.DeleteRequests, 1)
+
+	db := r.FormValue("db")
+	if db == "" {
+		h.httpError(w, "database is required", http.StatusBadRequest)
+		return
+	}
+
+	var req deleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.httpError(w, "error parsing delete request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse(time.RFC3339Nano, req.Start)
+	if err != nil {
+		h.httpError(w, "invalid start: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	stop, err := time.Parse(time.RFC3339Nano, req.Stop)
+	if err != nil {
+		h.httpError(w, "invalid stop: "+err.Error(), http.StatusBadRequest)
+		return
+	} else if stop.Before(start) {
+		h.httpError(w, "stop must not be before start", http.StatusBadRequest)
+		return
+	}
+	measurement, tagCond, err := parseDeletePredicate(req.Predicate)
+	if err != nil {
+		h.httpError(w, "invalid predicate: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.MetaClient.Database(db) == nil {
+		h.httpError(w, query.ErrDatabaseNotFound(db).Error(), http.StatusNotFound)
+		return
+	}
+
+	// The time range is inclusive, as in the InfluxDB 2.0 API.
+	cond := influxql.Expr(&influxql.BinaryExpr{
+		Op:  influxql.AND,
+		LHS: &influxql.BinaryExpr{Op: influxql.GTE, LHS: &influxql.VarRef{Val: "time"}, RHS: &influxql.TimeLiteral{Val: start.UTC()}},
+		RHS: &influxql.BinaryExpr{Op: influxql.LTE, LHS: &influxql.VarRef{Val: "time"}, RHS: &influxql.TimeLiteral{Val: stop.UTC()}},
+	})
+	if tagCond != nil {
+		cond = &influxql.BinaryExpr{Op: influxql.AND, LHS: cond, RHS: tagCond}
+	}
+
+	// Check authorization of a delete from all the measurements.
+	if h.authEnabled(r) {
+		stmt := &influxql.DeleteSeriesStatement{Condition: cond}
+		if measurement != "" {
+			stmt.Sources = influxql.Sources{&influxql.Measurement{Name: measurement}}
+		}
+		if err := h.authorizeQuery(user, &influxql.Query{Statements: influxql.Statements{stmt}}, db); err != nil {
+			if err, ok := err.(*meta.ErrAuthorize); ok {
+				h.Logger.Info("Unauthorized request",
+					zap.String("user", err.User),
+					zap.Stringer("query", err.Query),
+					logger.Database(err.Database))
+			}
+			h.httpError(w, "error authorizing query: "+err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	t := &deleteTask{
+		user:        cursorUser(user),
+		measurement: measurement,
+		tagCond:     tagCond,
+		cond:        cond,
+		closing:     make(chan struct{}),
+		status: deleteStatus{
+			Database:  db,
+			Start:     start.UTC(),
+			Stop:      stop.UTC(),
+			Predicate: req.Predicate,
+			Status:    deleteStatusRunning,
+			CreatedAt: time.Now().UTC(),
+		},
+	}
+	id, ok := h.deletes.add(t)
+	if !ok {
+		h.httpError(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	opts := query.ExecutionOptions{Database: db}
+	if h.authEnabled(r) {
+		// The current user determines the authorized actions.
+		opts.Authorizer = user
+	} else {
+		// Auth is disabled, so allow everything.
+		opts.Authorizer = query.OpenAuthorizer
+	}
+	status := t.snapshot()
+	go func() {
+		err := h.runDelete(t, opts)
+		t.finish(err)
+		if err != nil {
+			h.Logger.Info("Delete failed", zap.String("id", id), logger.Database(db), zap.Error(err))
+		}
+		h.deletes.expire(id)
+	}()
+
+	w.Header().Set("Location", "/api/v2/delete/"+id)
+	h.writeJSON(w, http.StatusAccepted, status)
+}
+
+// serveDeleteStatus responds with the status of the delete of the path.
+func (h *Handler) serveDeleteStatus(w http.ResponseWriter, r *http.Request, user meta.User) {
+	t := h.deletes.get(r.URL.Query().Get(":id"), cursorUser(user))
+	if t == nil {
+		h.httpError(w, "delete not found", http.StatusNotFound)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, t.snapshot())
+}
+
+// serveCancelDelete cancels the delete of the path. The points of the
+// measurement being deleted from are still deleted, and the delete is
+// canceled before the next measurement.
+func (h *Handler) serveCancelDelete(w http.ResponseWriter, r *http.Request, user meta.User) {
+	t := h.deletes.get(r.URL.Query().Get(":id"), cursorUser(user))
+	if t == nil {
+		h.httpError(w, "delete not found", http.StatusNotFound)
+		return
+	}
+	t.cancel()
+	h.writeJSON(w, http.StatusAccepted, t.snapshot())
+}
+
+// runDelete deletes the points of the delete t from each of its measurements.
+func (h *Handler) runDelete(t *deleteTask, opts query.ExecutionOptions) error {
+	measurements := []string{t.measurement}
+	if t.measurement == "" {
+		var err error
+		if measurements, err = h.deleteMeasurements(t, opts); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	t.status.Measurements = len(measurements)
+	t.mu.Unlock()
+	if len(measurements) == 0 {
+		return nil
+	}
+
+	q := &influxql.Query{}
+	for _, name := range measurements {
+		q.Statements = append(q.Statements, &influxql.DeleteSeriesStatement{
+			Sources:   influxql.Sources{&influxql.Measurement{Name: name}},
+			Condition: influxql.CloneExpr(t.cond),
+		})
+	}
+
+	var err error
+	for r := range h.QueryExecutor.ExecuteQuery(q, opts, t.closing) {
+		if r == nil || err != nil {
+			continue
+		} else if r.Err != nil {
+			err = r.Err
+			continue
+		}
+		t.mu.Lock()
+		t.status.Deleted++
+		t.mu.Unlock()
+	}
+	return err
+}
+
+// deleteMeasurements returns the measurements with series matching the tag
+// condition of the delete t.
+func (h *Handler) deleteMeasurements(t *deleteTask, opts query.ExecutionOptions) ([]string, error) {
+	stmt := &influxql.ShowMeasurementsStatement{Database: opts.Database, Condition: influxql.CloneExpr(t.tagCond)}
+
+	var names []string
+	for r := range h.QueryExecutor.ExecuteQuery(&influxql.Query{Statements: influxql.Statements{stmt}}, opts, t.closing) {
+		if r == nil {
+			continue
+		} else if r.Err != nil {
+			return nil, r.Err
+		}
+		for _, row := range r.Series {
+			for _, v := range row.Values {
+				if name, ok := v[0].(string); ok {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// parseDeletePredicate parses a delete predicate of the InfluxDB 2.0 API,
+// such as _measurement="cpu" AND host="server01". Tags are compared to
+// double-quoted strings with = or !=, and the comparisons are combined with
+// AND. It returns the measurement of the predicate, if any, and the
+// condition on the tags, if any.
+func parseDeletePredicate(s string) (measurement string, cond influxql.Expr, err error) {
+	p := &deletePredicateParser{s: s}
+	for {
+		p.skipSpace()
+		if p.done() {
+			return measurement, cond, nil
+		}
+		if cond != nil || measurement != "" {
+			if !p.keyword("AND") {
+				return "", nil, fmt.Errorf("expected AND at position %d", p.pos)
+			}
+			p.skipSpace()
+		}
+
+		key, err := p.key()
+		if err != nil {
+			return "", nil, err
+		}
+		p.skipSpace()
+		var op influxql.Token
+		switch {
+		case strings.HasPrefix(s[p.pos:], "!="):
+			op, p.pos = influxql.NEQ, p.pos+2
+		case strings.HasPrefix(s[p.pos:], "="):
+			op, p.pos = influxql.EQ, p.pos+1
+		default:
+			return "", nil, fmt.Errorf("expected = or != at position %d", p.pos)
+		}
+		p.skipSpace()
+		value, err := p.quoted()
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch key {
+		case "_measurement":
+			if op != influxql.EQ {
+				return "", nil, errors.New("_measurement must be compared with =")
+			} else if measurement != "" {
+				return "", nil, errors.New("_measurement must be compared once")
+			}
+			measurement = value
+			continue
+		case "_field":
+			return "", nil, errors.New("deleting the points of fields is not supported")
+		}
+
+		expr := &influxql.BinaryExpr{Op: op, LHS: &influxql.VarRef{Val: key}, RHS: &influxql.StringLiteral{Val: value}}
+		if cond == nil {
+			cond = expr
+		} else {
+			cond = &influxql.BinaryExpr{Op: influxql.AND, LHS: cond, RHS: expr}
+		}
+	}
+}
+
+// deletePredicateParser scans a delete predicate.
+type deletePredicateParser struct {
+	s   string
+	pos int
+}
+
+func (p *deletePredicateParser) done() bool { return p.pos >= len(p.s) }
+
+func (p *deletePredicateParser) skipSpace() {
+	for !p.done() && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// keyword consumes the case-insensitive keyword kw, if it is next.
+func (p *deletePredicateParser) keyword(kw string) bool {
+	end := p.pos + len(kw)
+	if end > len(p.s) || !strings.EqualFold(p.s[p.pos:end], kw) {
+		return false
+	} else if end < len(p.s) && isDeletePredicateKeyChar(p.s[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// key consumes a tag key, bare or double-quoted.
+func (p *deletePredicateParser) key() (string, error) {
+	if !p.done() && p.s[p.pos] == '"' {
+		return p.quoted()
+	}
+	start := p.pos
+	for !p.done() && isDeletePredicateKeyChar(p.s[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("expected tag key at position %d", start)
+	}
+	return p.s[start:p.pos], nil
+}
+
+// quoted consumes a double-quoted string, with \" and \\ escapes.
+func (p *deletePredicateParser) quoted() (string, error) {
+	if p.done() || p.s[p.pos] != '"' {
+		return "", fmt.Errorf("expected double-quoted string at position %d", p.pos)
+	}
+	start := p.pos
+	var buf []byte
+	for p.pos++; !p.done(); p.pos++ {
+		switch c := p.s[p.pos]; c {
+		case '\\':
+			if p.pos+1 < len(p.s) && (p.s[p.pos+1] == '"' || p.s[p.pos+1] == '\\') {
+				p.pos++
+				buf = append(buf, p.s[p.pos])
+				continue
+			}
+			buf = append(buf, c)
+		case '"':
+			p.pos++
+			return string(buf), nil
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string at position %d", start)
+}
+
+func isDeletePredicateKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.'
+}
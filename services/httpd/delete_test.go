@@ -0,0 +1,44 @@
+package httpd
+
+import "testing"
+
+func TestParseDeletePredicate(t *testing.T) {
+	for _, tt := range []struct {
+		predicate   string
+		measurement string
+		cond        string
+		err         string
+	}{
+		{predicate: ``},
+		{predicate: `_measurement="cpu"`, measurement: "cpu"},
+		{predicate: `host="server01"`, cond: `host = 'server01'`},
+		{predicate: ` _measurement = "cpu" and host != "a \"b\"" AND "region-code"="us.west" `, measurement: "cpu", cond: `host != 'a "b"' AND "region-code" = 'us.west'`},
+		{predicate: `host="a" OR host="b"`, err: `expected AND at position 9`},
+		{predicate: `host=a`, err: `expected double-quoted string at position 5`},
+		{predicate: `host > "a"`, err: `expected = or != at position 5`},
+		{predicate: `host="a`, err: `unterminated string at position 5`},
+		{predicate: `_measurement!="cpu"`, err: `_measurement must be compared with =`},
+		{predicate: `_field="value"`, err: `deleting the points of fields is not supported`},
+	} {
+		measurement, cond, err := parseDeletePredicate(tt.predicate)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: unexpected error: %v", tt.predicate, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.predicate, err)
+			continue
+		}
+
+		var s string
+		if cond != nil {
+			s = cond.String()
+		}
+		if measurement != tt.measurement {
+			t.Errorf("%s: unexpected measurement: %q", tt.predicate, measurement)
+		} else if s != tt.cond {
+			t.Errorf("%s: unexpected condition: %s", tt.predicate, s)
+		}
+	}
+}
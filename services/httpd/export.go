@@ -0,0 +1,484 @@
+package httpd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/parquet"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxql"
+	"go.uber.org/zap"
+)
+
+// Formats of exports.
+const (
+	exportFormatCSV     = "csv"
+	exportFormatParquet = "parquet"
+)
+
+// serveExport streams the results of a query, or of a measurement in a time
+// range, as a CSV or Parquet file. Results are read in chunks, and each chunk
+// is written to the client before the next is read, so a slow client slows
+// down the query rather than making results pile up in memory.
+func (h *Handler) serveExport(w http.ResponseWriter, r *http.Request, user meta.User) {
+	atomic.AddInt64(&h.stats.ExportRequests, 1)
+	h.requestTracker.Add(r, user)
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = exportFormatCSV
+	} else if format != exportFormatCSV && format != exportFormatParquet {
+		h.httpError(w, fmt.Sprintf("unknown export format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	db := r.FormValue("db")
+	var qr io.Reader
+	if qp := strings.TrimSpace(r.FormValue("q")); qp != "" {
+		qr = strings.NewReader(qp)
+	} else if m := r.FormValue("measurement"); m != "" {
+		qp, err := exportQuery(db, r.FormValue("rp"), m, r.FormValue("start"), r.FormValue("end"))
+		if err != nil {
+			h.httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		qr = strings.NewReader(qp)
+	} else {
+		h.httpError(w, `missing required parameter "q" or "measurement"`, http.StatusBadRequest)
+		return
+	}
+
+	// Sanitize the request query params so it doesn't show up in the response logger.
+	sanitize(r)
+
+	q, err := influxql.NewParser(qr).ParseQuery()
+	if err != nil {
+		h.httpError(w, "error parsing query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(q.Statements) != 1 {
+		h.httpError(w, "export requires a single SELECT statement", http.StatusBadRequest)
+		return
+	} else if stmt, ok := q.Statements[0].(*influxql.SelectStatement); !ok || stmt.Target != nil {
+		h.httpError(w, "export requires a single SELECT statement", http.StatusBadRequest)
+		return
+	}
+
+	// Check authorization.
+	if h.authEnabled(r) {
+		if err := h.authorizeQuery(user, q, db); err != nil {
+			if err, ok := err.(*meta.ErrAuthorize); ok {
+				h.Logger.Info("Unauthorized request",
+					zap.String("user", err.User),
+					zap.Stringer("query", err.Query),
+					logger.Database(err.Database))
+			}
+			h.httpError(w, "error authorizing query: "+err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	// Check quotas.
+	if h.quotas != nil {
+		quotaDB := h.quotaDatabase(db)
+		release, err := h.quotas.acquire(user, quotaDB)
+		if err != nil {
+			h.quotaError(w, err)
+			return
+		}
+		defer release()
+		if err := h.quotas.allowQuery(user, quotaDB); err != nil {
+			h.quotaError(w, err)
+			return
+		}
+	}
+
+	chunkSize := DefaultChunkSize
+	if n, err := strconv.ParseInt(r.FormValue("chunk_size"), 10, 64); err == nil && int(n) > 0 {
+		chunkSize = int(n)
+	}
+
+	opts := query.ExecutionOptions{
+		Database:  db,
+		ChunkSize: chunkSize,
+		ReadOnly:  true,
+		Class:     query.BatchQuery,
+	}
+	if h.authEnabled(r) {
+		opts.Authorizer = user
+	} else {
+		opts.Authorizer = query.OpenAuthorizer
+	}
+
+	// Abort the query if the client disconnects, or once the export is
+	// done, so it doesn't block sending results nobody reads.
+	closing := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	opts.AbortCh = done
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		notify := notifier.CloseNotify()
+		go func() {
+			select {
+			case <-done:
+			case <-notify:
+				close(closing)
+			}
+		}()
+	}
+
+	results := h.QueryExecutor.ExecuteQuery(q, opts, closing)
+
+	cw := &countingWriter{w: w}
+	var e exporter
+	if format == exportFormatParquet {
+		e = &parquetExporter{w: cw, rowGroupSize: chunkSize}
+	} else {
+		e = &csvExporter{w: csv.NewWriter(cw)}
+	}
+	defer func() {
+		atomic.AddInt64(&h.stats.ExportBytesTransmitted, cw.n)
+	}()
+
+	started := false
+	for r := range results {
+		if r == nil {
+			continue
+		}
+
+		err := r.Err
+		if err == nil {
+			for _, row := range r.Series {
+				if err = e.writeSeries(row); err != nil {
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			if !started {
+				h.httpError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			// The status has already been sent, so report the error in a
+			// trailer. The file is left incomplete.
+			h.Logger.Info("Export failed", zap.Error(err))
+			w.Header().Set("X-InfluxDB-Error", err.Error())
+			return
+		}
+
+		if !started {
+			h.startExport(w, format)
+			started = true
+		}
+		if err := e.flush(); err != nil {
+			return
+		}
+		w.(http.Flusher).Flush()
+	}
+
+	if !started {
+		h.startExport(w, format)
+	}
+	if err := e.close(); err != nil {
+		w.Header().Set("X-InfluxDB-Error", err.Error())
+	}
+}
+
+// startExport writes the header of a successful export.
+func (h *Handler) startExport(w http.ResponseWriter, format string) {
+	if format == exportFormatParquet {
+		w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	} else {
+		w.Header().Set("Content-Type", "text/csv")
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=export."+format)
+	w.Header().Set("Trailer", "X-InfluxDB-Error")
+	h.writeHeader(w, http.StatusOK)
+}
+
+// exportQuery returns the query selecting all the points of measurement m in
+// the time range from start to end, given in RFC3339. The range is unbounded
+// on the sides not given.
+func exportQuery(db, rp, m, start, end string) (string, error) {
+	if db == "" {
+		return "", fmt.Errorf(`missing required parameter "db"`)
+	}
+
+	var conds []string
+	for _, t := range []struct {
+		param, value, op string
+	}{
+		{"start", start, ">="},
+		{"end", end, "<"},
+	} {
+		if t.value == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, t.value)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s: %s", t.param, err)
+		}
+		conds = append(conds, fmt.Sprintf("time %s '%s'", t.op, ts.UTC().Format(time.RFC3339Nano)))
+	}
+
+	qp := "SELECT * FROM " + influxql.QuoteIdent(db, rp, m)
+	if len(conds) > 0 {
+		qp += " WHERE " + strings.Join(conds, " AND ")
+	}
+	return qp, nil
+}
+
+// exporter writes series to an export.
+type exporter interface {
+	// writeSeries writes the rows of a series. All series must have the
+	// same tags and columns as the first.
+	writeSeries(row *models.Row) error
+
+	// flush writes the series buffered.
+	flush() error
+
+	// close completes the export.
+	close() error
+}
+
+// exportColumns returns the columns of the export of the series of row: its
+// name, its tags in order, and its columns. Tags with the name of a column
+// are left out.
+func exportColumns(row *models.Row) []string {
+	columns := []string{"name"}
+	for k := range row.Tags {
+		if !containsString(row.Columns, k) {
+			columns = append(columns, k)
+		}
+	}
+	sort.Strings(columns[1:])
+	return append(columns, row.Columns...)
+}
+
+// exportValues returns the rows of values of the export of row, for the
+// columns returned by exportColumns.
+func exportValues(row *models.Row, columns []string) [][]interface{} {
+	prefix := make([]interface{}, len(columns)-len(row.Columns))
+	prefix[0] = row.Name
+	for i, k := range columns[1:len(prefix)] {
+		prefix[i+1] = row.Tags[k]
+	}
+
+	rows := make([][]interface{}, len(row.Values))
+	for i, values := range row.Values {
+		rows[i] = append(append(make([]interface{}, 0, len(columns)), prefix...), values...)
+	}
+	return rows
+}
+
+// checkExportColumns returns an error if the columns of the export of row
+// aren't columns.
+func checkExportColumns(row *models.Row, columns []string) error {
+	other := exportColumns(row)
+	if len(other) != len(columns) {
+		return fmt.Errorf("series %q has different columns", row.Name)
+	}
+	for i := range columns {
+		if other[i] != columns[i] {
+			return fmt.Errorf("series %q has different columns", row.Name)
+		}
+	}
+	return nil
+}
+
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// csvExporter writes series as CSV, with a header of their columns.
+type csvExporter struct {
+	w       *csv.Writer
+	columns []string
+	record  []string
+}
+
+func (e *csvExporter) writeSeries(row *models.Row) error {
+	if e.columns == nil {
+		e.columns = exportColumns(row)
+		e.record = make([]string, len(e.columns))
+		if err := e.w.Write(e.columns); err != nil {
+			return err
+		}
+	} else if err := checkExportColumns(row, e.columns); err != nil {
+		return err
+	}
+
+	for _, values := range exportValues(row, e.columns) {
+		for i, v := range values {
+			e.record[i] = formatValue(v)
+		}
+		if err := e.w.Write(e.record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *csvExporter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) close() error {
+	return e.flush()
+}
+
+// parquetExporter writes series as a Parquet file, in row groups of up to
+// rowGroupSize rows. The types of the columns are those of the values of the
+// first rows written.
+type parquetExporter struct {
+	w            io.Writer
+	rowGroupSize int
+
+	pw      *parquet.Writer
+	columns []string
+	types   []parquet.Type
+	rows    [][]interface{}
+}
+
+func (e *parquetExporter) writeSeries(row *models.Row) error {
+	if e.columns == nil {
+		e.columns = exportColumns(row)
+	} else if err := checkExportColumns(row, e.columns); err != nil {
+		return err
+	}
+
+	values := exportValues(row, e.columns)
+	if e.pw == nil {
+		e.types = make([]parquet.Type, len(e.columns))
+		schema := make([]parquet.Column, len(e.columns))
+		for i := range e.columns {
+			e.types[i] = parquetType(values, i)
+			schema[i] = parquet.Column{Name: e.columns[i], Type: e.types[i]}
+		}
+		e.pw = parquet.NewWriter(e.w, schema)
+	}
+
+	for _, v := range values {
+		for i := range v {
+			var err error
+			if v[i], err = parquetValue(v[i], e.types[i]); err != nil {
+				return fmt.Errorf("column %q: %s", e.columns[i], err)
+			}
+		}
+		e.rows = append(e.rows, v)
+		if len(e.rows) >= e.rowGroupSize {
+			if err := e.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flush writes the rows buffered as a row group.
+func (e *parquetExporter) flush() error {
+	if len(e.rows) == 0 {
+		return nil
+	}
+	err := e.pw.WriteRowGroup(e.rows)
+	e.rows = e.rows[:0]
+	return err
+}
+
+func (e *parquetExporter) close() error {
+	if e.pw == nil {
+		// Nothing was exported, so write a file without columns.
+		e.pw = parquet.NewWriter(e.w, nil)
+	} else if err := e.flush(); err != nil {
+		return err
+	}
+	return e.pw.Close()
+}
+
+// parquetType returns the type of column i of rows. Columns of values of
+// different types, or without values, are strings.
+func parquetType(rows [][]interface{}, i int) parquet.Type {
+	typ, typed := parquet.String, false
+	for _, row := range rows {
+		var t parquet.Type
+		switch row[i].(type) {
+		case float64:
+			t = parquet.Float64
+		case int64:
+			t = parquet.Int64
+		case uint64:
+			t = parquet.Uint64
+		case bool:
+			t = parquet.Boolean
+		case string:
+			t = parquet.String
+		case time.Time:
+			t = parquet.Timestamp
+		default:
+			continue
+		}
+		if !typed {
+			typ, typed = t, true
+		} else if t != typ {
+			return parquet.String
+		}
+	}
+	return typ
+}
+
+// parquetValue returns v as a value of a column of type typ. Values are
+// formatted as in CSV exports in string columns, and integers are converted
+// in float columns.
+func parquetValue(v interface{}, typ parquet.Type) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch typ {
+	case parquet.String:
+		if _, ok := v.(string); !ok {
+			return formatValue(v), nil
+		}
+		return v, nil
+	case parquet.Float64:
+		switch x := v.(type) {
+		case int64:
+			return float64(x), nil
+		case uint64:
+			return float64(x), nil
+		}
+	}
+	if parquetType([][]interface{}{{v}}, 0) != typ {
+		return nil, fmt.Errorf("unexpected value of type %T", v)
+	}
+	return v, nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,140 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/parquet"
+)
+
+func TestExportQuery(t *testing.T) {
+	for _, tt := range []struct {
+		db, rp, m, start, end string
+		exp                   string
+		err                   bool
+	}{
+		{db: "db0", m: "cpu", exp: `SELECT * FROM "db0"..cpu`},
+		{db: "db0", rp: "rp0", m: "cpu", start: "2017-01-01T00:00:00Z", end: "2017-01-02T00:00:00+01:00",
+			exp: `SELECT * FROM "db0"."rp0".cpu WHERE time >= '2017-01-01T00:00:00Z' AND time < '2017-01-01T23:00:00Z'`},
+		{db: "db0", m: `c"pu`, end: "2017-01-01T00:00:00.5Z", exp: `SELECT * FROM "db0".."c\"pu" WHERE time < '2017-01-01T00:00:00.5Z'`},
+		{m: "cpu", err: true},
+		{db: "db0", m: "cpu", start: "yesterday", err: true},
+	} {
+		q, err := exportQuery(tt.db, tt.rp, tt.m, tt.start, tt.end)
+		if tt.err {
+			if err == nil {
+				t.Fatalf("expected error: %s", q)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		} else if q != tt.exp {
+			t.Fatalf("unexpected query: %s", q)
+		}
+	}
+}
+
+func TestCSVExporter(t *testing.T) {
+	var buf bytes.Buffer
+	e := &csvExporter{w: csv.NewWriter(&buf)}
+	if err := e.writeSeries(&models.Row{
+		Name: "cpu", Tags: map[string]string{"region": "west", "host": "a"},
+		Columns: []string{"time", "host", "value"},
+		Values:  [][]interface{}{{time.Unix(0, 1), "a", 1.5}, {time.Unix(0, 2), "a", nil}},
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := e.writeSeries(&models.Row{
+		Name: "cpu", Tags: map[string]string{"region": "east", "host": "b"},
+		Columns: []string{"time", "host", "value"},
+		Values:  [][]interface{}{{time.Unix(0, 3), "b", int64(2)}},
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := e.close(); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "name,region,time,host,value\ncpu,west,1,a,1.5\ncpu,west,2,a,\ncpu,east,3,b,2\n"; buf.String() != exp {
+		t.Fatalf("unexpected export: %s", buf.String())
+	}
+
+	// All series must have the same columns.
+	if err := e.writeSeries(&models.Row{Name: "mem", Columns: []string{"time", "free"}}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParquetExporter(t *testing.T) {
+	var buf bytes.Buffer
+	e := &parquetExporter{w: &buf, rowGroupSize: 2}
+	if err := e.writeSeries(&models.Row{
+		Name: "cpu", Columns: []string{"time", "value", "count", "mixed", "empty"},
+		Values: [][]interface{}{
+			{time.Unix(0, 1), 1.5, int64(1), int64(1), nil},
+			{time.Unix(0, 2), nil, int64(2), "a", nil},
+			{time.Unix(0, 3), 2.5, int64(3), nil, nil},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []parquet.Type{parquet.String, parquet.Timestamp, parquet.Float64, parquet.Int64, parquet.String, parquet.String}; !reflect.DeepEqual(e.types, exp) {
+		t.Fatalf("unexpected types: %v", e.types)
+	} else if len(e.rows) != 1 {
+		t.Fatalf("unexpected rows buffered: %d", len(e.rows))
+	} else if exp := []interface{}{"cpu", time.Unix(0, 3), 2.5, int64(3), nil, nil}; !reflect.DeepEqual(e.rows[0], exp) {
+		t.Fatalf("unexpected row: %v", e.rows[0])
+	}
+
+	// Rows are written in row groups.
+	if err := e.writeSeries(&models.Row{
+		Name: "cpu", Columns: []string{"time", "value", "count", "mixed", "empty"},
+		Values: [][]interface{}{{time.Unix(0, 4), int64(3), int64(4), 1.5, true}},
+	}); err != nil {
+		t.Fatal(err)
+	} else if len(e.rows) != 0 {
+		t.Fatalf("unexpected rows buffered: %d", len(e.rows))
+	} else if err := e.writeSeries(&models.Row{
+		Name: "cpu", Columns: []string{"time", "value", "count", "mixed", "empty"},
+		Values: [][]interface{}{{time.Unix(0, 5), 1.5, 2.5, nil, nil}},
+	}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if err := e.close(); err != nil {
+		t.Fatal(err)
+	} else if b := buf.Bytes(); !bytes.HasPrefix(b, []byte("PAR1")) || !bytes.HasSuffix(b, []byte("PAR1")) {
+		t.Fatal("expected parquet file")
+	}
+}
+
+// Ensure values are converted to the types of their columns, if possible.
+func TestParquetValue(t *testing.T) {
+	for _, tt := range []struct {
+		v   interface{}
+		typ parquet.Type
+		exp interface{}
+		err bool
+	}{
+		{v: nil, typ: parquet.Int64, exp: nil},
+		{v: int64(3), typ: parquet.Int64, exp: int64(3)},
+		{v: int64(3), typ: parquet.Float64, exp: float64(3)},
+		{v: uint64(3), typ: parquet.Float64, exp: float64(3)},
+		{v: 1.5, typ: parquet.String, exp: "1.5"},
+		{v: true, typ: parquet.String, exp: "true"},
+		{v: time.Unix(0, 1), typ: parquet.Timestamp, exp: time.Unix(0, 1)},
+		{v: 1.5, typ: parquet.Int64, err: true},
+		{v: "a", typ: parquet.Boolean, err: true},
+	} {
+		v, err := parquetValue(tt.v, tt.typ)
+		if tt.err {
+			if err == nil {
+				t.Fatalf("expected error for %v", tt.v)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(v, tt.exp) {
+			t.Fatalf("unexpected value: %v", v)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package httpd
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"expvar"
@@ -14,6 +15,7 @@ import (
 	"net/http"
 	"os"
 	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -28,10 +30,13 @@ import (
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/monitor"
 	"github.com/influxdata/influxdb/monitor/diagnostics"
+	"github.com/influxdata/influxdb/pkg/tracing"
+	"github.com/influxdata/influxdb/pkg/tracing/fields"
 	"github.com/influxdata/influxdb/prometheus"
 	"github.com/influxdata/influxdb/prometheus/remote"
 	"github.com/influxdata/influxdb/query"
 	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/services/otel"
 	"github.com/influxdata/influxdb/tsdb"
 	"github.com/influxdata/influxdb/uuid"
 	"github.com/influxdata/influxql"
@@ -61,6 +66,9 @@ const (
 
 	// Authenticate with jwt.
 	BearerAuthentication
+
+	// Authenticate with a verified client certificate.
+	ClientCertificateAuthentication
 )
 
 // TODO: Check HTTP response codes: 400, 401, 403, 409.
@@ -87,6 +95,8 @@ type Handler struct {
 		Authenticate(username, password string) (ui meta.User, err error)
 		User(username string) (meta.User, error)
 		AdminUserExists() bool
+		TruncateShardGroups(t time.Time) error
+		SetUserLimits(username string, limits meta.UserLimits) error
 	}
 
 	QueryAuthorizer interface {
@@ -108,12 +118,64 @@ type Handler struct {
 		WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error
 	}
 
+	// Tracer traces the write and query requests, if set.
+	Tracer interface {
+		Start(r *http.Request, name string) *otel.Request
+	}
+
+	// Health reports the health of the subsystems of the server, if set.
+	Health interface {
+		Health() []HealthCheck
+	}
+
+	// TSDBStore holds the shards stored on this node, if set.
+	TSDBStore interface {
+		Shard(id uint64) *tsdb.Shard
+	}
+
+	// ContinuousQuerier runs the continuous queries, if the continuous query
+	// service is enabled.
+	ContinuousQuerier interface {
+		Run(database, name string, t time.Time) error
+		LastRun(database, name string) (time.Time, error)
+	}
+
 	Config    *Config
 	Logger    *zap.Logger
 	CLFLogger *log.Logger
 	accessLog *os.File
 	stats     *Statistics
 
+	// jwks caches the keys of the JWKS URL, if configured.
+	jwks *jwks
+
+	// oidc validates the tokens of the OpenID Connect issuer, if configured.
+	oidc *oidcProvider
+
+	// ldap authenticates users against the LDAP directory, if enabled.
+	ldap *ldapAuthenticator
+
+	// quotas limits the requests of users and to databases, if configured.
+	quotas *quotas
+
+	// cursors holds the cursors of paged queries between requests.
+	cursors *queryCursors
+
+	// admission limits the write requests in flight, if configured.
+	admission *writeAdmission
+
+	// audit records the requests of authenticated users, if enabled.
+	audit *auditLog
+
+	// corsRules are the rules of cross-origin requests, in order.
+	corsRules []*corsRule
+
+	// deletes holds the deletes running in the background.
+	deletes *deleteTasks
+
+	// intake queues the writes acknowledged once enqueued, if enabled.
+	intake *writeIntake
+
 	requestTracker *RequestTracker
 }
 
@@ -125,8 +187,24 @@ func NewHandler(c Config) *Handler {
 		Logger:         zap.NewNop(),
 		CLFLogger:      log.New(os.Stderr, "[httpd] ", 0),
 		stats:          &Statistics{},
+		cursors:        newQueryCursors(time.Duration(c.QueryCursorTimeout), c.MaxQueryCursors),
+		admission:      newWriteAdmission(c),
+		corsRules:      newCORSRules(c.CORS),
+		deletes:        newDeleteTasks(),
 		requestTracker: NewRequestTracker(),
 	}
+	if c.JWKSURL != "" {
+		h.jwks = newJWKS(c.JWKSURL, time.Duration(c.JWKSRefreshInterval))
+	}
+	if c.OIDCIssuer != "" {
+		h.oidc = newOIDCProvider(&c)
+	}
+	if c.LDAP.Enabled {
+		h.ldap = newLDAPAuthenticator(c.LDAP)
+	}
+	if c.quotasEnabled() {
+		h.quotas = newQuotas(h.Config)
+	}
 
 	h.AddRoutes([]Route{
 		Route{
@@ -141,6 +219,18 @@ func NewHandler(c Config) *Handler {
 			"query", // Query serving route.
 			"POST", "/query", true, true, h.serveQuery,
 		},
+		Route{
+			"kill-queries", // Query killing route.
+			"DELETE", "/queries", false, true, h.serveKillQueries,
+		},
+		Route{
+			"export", // Export serving route.
+			"GET", "/export", true, true, h.serveExport,
+		},
+		Route{
+			"export", // Export serving route.
+			"POST", "/export", true, true, h.serveExport,
+		},
 		Route{
 			"write-options", // Satisfy CORS checks.
 			"OPTIONS", "/write", false, true, h.serveOptions,
@@ -157,6 +247,82 @@ func NewHandler(c Config) *Handler {
 			"prometheus-read", // Prometheus remote read
 			"POST", "/api/v1/prom/read", true, true, h.servePromRead,
 		},
+		Route{
+			"prometheus-query", // Prometheus HTTP API query
+			"GET", "/api/v1/query", true, true, h.servePromQuery,
+		},
+		Route{
+			"prometheus-query", // Prometheus HTTP API query
+			"POST", "/api/v1/query", true, true, h.servePromQuery,
+		},
+		Route{
+			"prometheus-query-range", // Prometheus HTTP API range query
+			"GET", "/api/v1/query_range", true, true, h.servePromQueryRange,
+		},
+		Route{
+			"prometheus-query-range", // Prometheus HTTP API range query
+			"POST", "/api/v1/query_range", true, true, h.servePromQueryRange,
+		},
+		Route{
+			"continuous-queries", // List continuous queries
+			"GET", "/api/v1/continuous-queries", true, true, h.serveContinuousQueries,
+		},
+		Route{
+			"continuous-queries-create", // Create a continuous query
+			"POST", "/api/v1/continuous-queries", false, true, h.serveCreateContinuousQuery,
+		},
+		Route{
+			"continuous-query", // Show a continuous query and its status
+			"GET", "/api/v1/continuous-queries/:db/:name", true, true, h.serveContinuousQuery,
+		},
+		Route{
+			"continuous-query-put", // Create or replace a continuous query
+			"PUT", "/api/v1/continuous-queries/:db/:name", false, true, h.servePutContinuousQuery,
+		},
+		Route{
+			"continuous-query-delete", // Drop a continuous query
+			"DELETE", "/api/v1/continuous-queries/:db/:name", false, true, h.serveDeleteContinuousQuery,
+		},
+		Route{
+			"continuous-query-run", // Run a continuous query now
+			"POST", "/api/v1/continuous-queries/:db/:name/run", false, true, h.serveRunContinuousQuery,
+		},
+		Route{
+			"shards", // List shards
+			"GET", "/api/v1/shards", true, true, h.serveShards,
+		},
+		Route{
+			"shards-truncate", // Truncate shard groups
+			"POST", "/api/v1/shards/truncate", false, true, h.serveTruncateShards,
+		},
+		Route{
+			"shard-delete", // Drop a shard
+			"DELETE", "/api/v1/shards/:id", false, true, h.serveDropShard,
+		},
+		Route{
+			"shard-compact", // Compact a shard
+			"POST", "/api/v1/shards/:id/compact", false, true, h.serveCompactShard,
+		},
+		Route{
+			"user-limits", // Show the query limits of a user
+			"GET", "/api/v1/users/:name/limits", true, true, h.serveUserLimits,
+		},
+		Route{
+			"user-limits-set", // Set the query limits of a user
+			"PUT", "/api/v1/users/:name/limits", false, true, h.servePutUserLimits,
+		},
+		Route{
+			"delete", // Delete points in the background
+			"POST", "/api/v2/delete", false, true, h.serveDelete,
+		},
+		Route{
+			"delete-status", // Status of a delete
+			"GET", "/api/v2/delete/:id", false, true, h.serveDeleteStatus,
+		},
+		Route{
+			"delete-cancel", // Cancel a delete
+			"DELETE", "/api/v2/delete/:id", false, true, h.serveCancelDelete,
+		},
 		Route{ // Ping
 			"ping",
 			"GET", "/ping", false, true, h.servePing,
@@ -173,6 +339,14 @@ func NewHandler(c Config) *Handler {
 			"status-head",
 			"HEAD", "/status", false, true, h.serveStatus,
 		},
+		Route{ // Health of the subsystems
+			"health",
+			"GET", "/health", false, true, h.serveHealth,
+		},
+		Route{ // Health of the subsystems
+			"health-head",
+			"HEAD", "/health", false, true, h.serveHealth,
+		},
 		Route{
 			"prometheus-metrics",
 			"GET", "/metrics", false, true, promhttp.Handler().ServeHTTP,
@@ -183,6 +357,32 @@ func NewHandler(c Config) *Handler {
 }
 
 func (h *Handler) Open() {
+	if h.Config.Audit.Enabled {
+		l := newAuditLog(h.Config.Audit)
+		l.Logger = h.Logger.With(zap.String("log", "audit"))
+		if m, ok := h.Monitor.(interface {
+			Enabled() bool
+			WritePoints(models.Points) error
+		}); ok && h.Config.Audit.Internal {
+			l.Monitor = m
+		}
+		if err := l.open(); err != nil {
+			h.Logger.Error("unable to open audit log, falling back to the log", zap.Error(err), zap.String("path", h.Config.Audit.Path))
+		}
+		h.audit = l
+	}
+
+	if h.Config.WriteIntake.Enabled {
+		q := newWriteIntake(h.Config.WriteIntake)
+		q.write = h.writeIntakePoints
+		q.Logger = h.Logger.With(zap.String("log", "write-intake"))
+		if err := q.open(); err != nil {
+			h.Logger.Error("unable to open write intake, writes cannot be enqueued", zap.Error(err), zap.String("dir", h.Config.WriteIntake.Dir))
+		} else {
+			h.intake = q
+		}
+	}
+
 	if h.Config.LogEnabled {
 		path := "stderr"
 
@@ -205,6 +405,13 @@ func (h *Handler) Close() {
 		h.accessLog.Close()
 		h.accessLog = nil
 	}
+	if h.ldap != nil {
+		h.ldap.close()
+	}
+	h.cursors.close()
+	h.deletes.close()
+	h.intake.close()
+	h.audit.close()
 }
 
 // Statistics maintains statistics for the httpd service.
@@ -231,11 +438,20 @@ type Statistics struct {
 	RecoveredPanics              int64
 	PromWriteRequests            int64
 	PromReadRequests             int64
+	PromQueryRequests            int64
+	QuotaExceeded                int64
+	ExportRequests               int64
+	ExportBytesTransmitted       int64
+	DeleteRequests               int64
+	RequestMemoryExceeded        int64
+	WriteRequestsEnqueued        int64
 }
 
 // Statistics returns statistics for periodic monitoring.
 func (h *Handler) Statistics(tags map[string]string) []models.Statistic {
-	return []models.Statistic{{
+	writeQueued, writeBytes, writeRejected := h.admission.stats()
+	intakeBytes, intakeRejected := h.intake.stats()
+	statistics := []models.Statistic{{
 		Name: "httpd",
 		Tags: tags,
 		Values: map[string]interface{}{
@@ -260,8 +476,25 @@ func (h *Handler) Statistics(tags map[string]string) []models.Statistic {
 			statRecoveredPanics:              atomic.LoadInt64(&h.stats.RecoveredPanics),
 			statPromWriteRequest:             atomic.LoadInt64(&h.stats.PromWriteRequests),
 			statPromReadRequest:              atomic.LoadInt64(&h.stats.PromReadRequests),
+			statPromQueryRequest:             atomic.LoadInt64(&h.stats.PromQueryRequests),
+			statQuotaExceeded:                atomic.LoadInt64(&h.stats.QuotaExceeded),
+			statQueryCursors:                 int64(h.cursors.len()),
+			statWriteRequestsQueued:          int64(writeQueued),
+			statWriteRequestBytesActive:      writeBytes,
+			statWriteRequestsRejected:        writeRejected,
+			statExportRequest:                atomic.LoadInt64(&h.stats.ExportRequests),
+			statExportBytesTransmitted:       atomic.LoadInt64(&h.stats.ExportBytesTransmitted),
+			statDeleteRequest:                atomic.LoadInt64(&h.stats.DeleteRequests),
+			statRequestMemoryExceeded:        atomic.LoadInt64(&h.stats.RequestMemoryExceeded),
+			statWriteRequestsEnqueued:        atomic.LoadInt64(&h.stats.WriteRequestsEnqueued),
+			statWriteIntakeBytes:             intakeBytes,
+			statWriteIntakeRejected:          intakeRejected,
 		},
 	}}
+	if h.quotas != nil {
+		statistics = append(statistics, h.quotas.statistics(tags)...)
+	}
+	return statistics
 }
 
 // AddRoutes sets the provided routes on the handler.
@@ -271,7 +504,7 @@ func (h *Handler) AddRoutes(routes ...Route) {
 
 		// If it's a handler func that requires authorization, wrap it in authentication
 		if hf, ok := r.HandlerFunc.(func(http.ResponseWriter, *http.Request, meta.User)); ok {
-			handler = authenticate(hf, h, h.Config.AuthEnabled)
+			handler = authenticate(hf, h)
 		}
 
 		// This is a normal handler signature and does not require authentication
@@ -281,9 +514,9 @@ func (h *Handler) AddRoutes(routes ...Route) {
 
 		handler = h.responseWriter(handler)
 		if r.Gzipped {
-			handler = gzipFilter(handler)
+			handler = compressFilter(handler)
 		}
-		handler = cors(handler)
+		handler = h.cors(handler)
 		handler = requestID(handler)
 		if h.Config.LogEnabled && r.LoggingEnabled {
 			handler = h.logging(handler, r.Name)
@@ -305,7 +538,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("X-Influxdb-Version", h.Version)
 	w.Header().Add("X-Influxdb-Build", h.BuildType)
 
-	if strings.HasPrefix(r.URL.Path, "/debug/pprof") && h.Config.PprofEnabled {
+	if p := policy(r); p != nil && !p.allows(r.URL.Path) {
+		h.httpError(w, fmt.Sprintf("endpoint %s is not served by this listener", r.URL.Path), http.StatusForbidden)
+	} else if isPreflight(r) {
+		// Preflight requests are answered for all endpoints.
+		h.setCORSHeaders(w, r)
+	} else if strings.HasPrefix(r.URL.Path, "/debug/pprof") && h.Config.PprofEnabled {
 		h.handleProfiles(w, r)
 	} else if strings.HasPrefix(r.URL.Path, "/debug/vars") {
 		h.serveExpvar(w, r)
@@ -338,12 +576,21 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 	}(time.Now())
 	h.requestTracker.Add(r, user)
 
+	tr := h.startTrace(r, "query")
+	defer tr.Finish()
+
 	// Retrieve the underlying ResponseWriter or initialize our own.
 	rw, ok := w.(ResponseWriter)
 	if !ok {
 		rw = NewResponseWriter(w, r)
 	}
 
+	// Resume a paged query.
+	if id := r.FormValue("cursor"); id != "" {
+		h.serveQueryCursor(rw, user, id)
+		return
+	}
+
 	// Retrieve the node id the query should be executed on.
 	nodeID, _ := strconv.ParseUint(r.FormValue("node_id"), 10, 64)
 
@@ -379,32 +626,12 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 	sanitize(r)
 
 	// Parse the parameters
-	rawParams := r.FormValue("params")
-	if rawParams != "" {
-		var params map[string]interface{}
-		decoder := json.NewDecoder(strings.NewReader(rawParams))
-		decoder.UseNumber()
-		if err := decoder.Decode(&params); err != nil {
+	if rawParams := r.FormValue("params"); rawParams != "" {
+		params, err := parseQueryParams(rawParams)
+		if err != nil {
 			h.httpError(rw, "error parsing query parameters: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		// Convert json.Number into int64 and float64 values
-		for k, v := range params {
-			if v, ok := v.(json.Number); ok {
-				var err error
-				if strings.Contains(string(v), ".") {
-					params[k], err = v.Float64()
-				} else {
-					params[k], err = v.Int64()
-				}
-
-				if err != nil {
-					h.httpError(rw, "error parsing json value: "+err.Error(), http.StatusBadRequest)
-					return
-				}
-			}
-		}
 		p.SetParams(params)
 	}
 
@@ -414,10 +641,12 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 		h.httpError(rw, "error parsing query: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	audit := h.audit.startQuery(r, user, db, q)
 
 	// Check authorization.
-	if h.Config.AuthEnabled {
-		if err := h.QueryAuthorizer.AuthorizeQuery(user, q, db); err != nil {
+	if h.authEnabled(r) {
+		if err := h.authorizeQuery(user, q, db); err != nil {
+			audit.reject(err)
 			if err, ok := err.(meta.ErrAuthorize); ok {
 				h.Logger.Info("Unauthorized request",
 					zap.String("user", err.User),
@@ -429,6 +658,23 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 		}
 	}
 
+	// Check quotas.
+	if h.quotas != nil {
+		quotaDB := h.quotaDatabase(db)
+		release, err := h.quotas.acquire(user, quotaDB)
+		if err != nil {
+			audit.reject(err)
+			h.quotaError(rw, err)
+			return
+		}
+		defer release()
+		if err := h.quotas.allowQuery(user, quotaDB); err != nil {
+			audit.reject(err)
+			h.quotaError(rw, err)
+			return
+		}
+	}
+
 	// Parse chunk size. Use default if not provided or unparsable.
 	chunked := r.FormValue("chunked") == "true"
 	chunkSize := DefaultChunkSize
@@ -441,14 +687,50 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 	// Parse whether this is an async command.
 	async := r.FormValue("async") == "true"
 
+	// Parse whether the results are paged, and the page size.
+	paginate := r.FormValue("paginate") == "true"
+	if paginate {
+		if chunked || async {
+			err := errors.New("paginate cannot be used with chunked or async")
+			audit.reject(err)
+			h.httpError(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if n, err := strconv.ParseInt(r.FormValue("page_size"), 10, 64); err == nil && int(n) > 0 {
+			chunkSize = int(n)
+		}
+		if h.Config.MaxRowLimit > 0 && chunkSize > h.Config.MaxRowLimit {
+			chunkSize = h.Config.MaxRowLimit
+		}
+	}
+
+	// Parse the class of the query for the scheduler.
+	class := query.InteractiveQuery
+	if v := r.FormValue("class"); v != "" {
+		if class, err = query.ParseQueryClass(v); err != nil {
+			audit.reject(err)
+			h.httpError(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	opts := query.ExecutionOptions{
 		Database:  db,
 		ChunkSize: chunkSize,
 		ReadOnly:  r.Method == "GET",
 		NodeID:    nodeID,
+		Class:     class,
+	}
+
+	// Statements outliving the request aren't traced.
+	if tr != nil {
+		tr.Span().MergeLabels("db.name", db, "db.statement", q.String())
+		if !async && !paginate {
+			opts.Span = tr.Span()
+		}
 	}
 
-	if h.Config.AuthEnabled {
+	if h.authEnabled(r) {
 		// The current user determines the authorized actions.
 		opts.Authorizer = user
 	} else {
@@ -458,7 +740,18 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 
 	// Make sure if the client disconnects we signal the query to abort
 	var closing chan struct{}
-	if !async {
+	if paginate {
+		// Paged queries outlive the request, and are aborted when their
+		// cursor is closed.
+		if !h.cursors.reserve() {
+			err := errors.New("too many open query cursors")
+			audit.reject(err)
+			h.httpError(rw, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		closing = make(chan struct{})
+		opts.AbortCh = closing
+	} else if !async {
 		closing = make(chan struct{})
 		if notifier, ok := w.(http.CloseNotifier); ok {
 			// CloseNotify() is not guaranteed to send a notification when the query
@@ -485,6 +778,7 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 
 	// Execute query.
 	results := h.QueryExecutor.ExecuteQuery(q, opts, closing)
+	results = audit.results(results, opts.AbortCh, closing)
 
 	// If we are running in async mode, open a goroutine to drain the results
 	// and return with a StatusNoContent.
@@ -494,6 +788,14 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 		return
 	}
 
+	// Return the first page of a paged query.
+	if paginate {
+		c := newQueryCursor(results, closing)
+		c.user, c.db, c.epoch = cursorUser(user), db, epoch
+		h.writeQueryPage(rw, c)
+		return
+	}
+
 	// if we're not chunking, this will be the in memory buffer for all results before sending to client
 	resp := Response{Results: make([]*query.Result, 0)}
 
@@ -507,6 +809,7 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 
 	// pull all results from the channel
 	rows := 0
+	mem := h.newRequestMemory(user)
 	for r := range results {
 		// Ignore nil results.
 		if r == nil {
@@ -557,6 +860,14 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 			}
 		}
 
+		// Account for the memory of the buffered results, and drop them if it
+		// exceeds the limit.
+		if err := mem.grow(resultSize(r)); err != nil {
+			atomic.AddInt64(&h.stats.RequestMemoryExceeded, 1)
+			resp = Response{Results: []*query.Result{{StatementID: r.StatementID, Err: err}}}
+			break
+		}
+
 		// It's not chunked so buffer results in memory.
 		// Results for statements need to be combined together.
 		// We need to check if this new result is for the same statement as
@@ -617,6 +928,105 @@ func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request, user meta.U
 	}
 }
 
+// serveQueryCursor returns the next page of the paged query of a cursor.
+func (h *Handler) serveQueryCursor(rw ResponseWriter, user meta.User, id string) {
+	c := h.cursors.take(id, cursorUser(user))
+	if c == nil {
+		h.httpError(rw, "query cursor not found", http.StatusNotFound)
+		return
+	}
+
+	// Check quotas.
+	if h.quotas != nil {
+		release, err := h.quotas.acquire(user, h.quotaDatabase(c.db))
+		if err != nil {
+			h.cursors.put(c)
+			h.quotaError(rw, err)
+			return
+		}
+		defer release()
+	}
+
+	h.writeQueryPage(rw, c)
+}
+
+// writeQueryPage writes the next result of a paged query and, if there are
+// more, the cursor resuming it.
+func (h *Handler) writeQueryPage(rw ResponseWriter, c *queryCursor) {
+	resp := Response{Results: make([]*query.Result, 0)}
+	r, more := c.page()
+	if r != nil {
+		if c.epoch != "" {
+			convertToEpoch(r, c.epoch)
+		}
+		resp.Results = append(resp.Results, r)
+	}
+	if more {
+		resp.Cursor = h.cursors.put(c)
+		rw.Header().Set("X-InfluxDB-Cursor", resp.Cursor)
+	} else {
+		h.cursors.done(c)
+	}
+
+	h.writeHeader(rw, http.StatusOK)
+	n, _ := rw.WriteResponse(resp)
+	atomic.AddInt64(&h.stats.QueryRequestBytesTransmitted, int64(n))
+}
+
+// cursorUser returns the name of the user owning the cursors it starts.
+func cursorUser(user meta.User) string {
+	if user == nil {
+		return ""
+	}
+	return user.ID()
+}
+
+// serveKillQueries kills the running query of an id, or the queries of a
+// user or against a database, and returns the ids of the queries killed.
+func (h *Handler) serveKillQueries(w http.ResponseWriter, r *http.Request, user meta.User) {
+	var qid uint64
+	if s := r.FormValue("qid"); s != "" {
+		var err error
+		if qid, err = strconv.ParseUint(s, 10, 64); err != nil {
+			h.httpError(w, "invalid qid: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Killing queries requires the privileges of KILL QUERY.
+	if h.authEnabled(r) {
+		q := &influxql.Query{Statements: influxql.Statements{&influxql.KillQueryStatement{QueryID: qid}}}
+		if err := h.authorizeQuery(user, q, ""); err != nil {
+			h.httpError(w, "error authorizing query: "+err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	req := query.KillRequest{
+		QueryID:  qid,
+		User:     r.FormValue("user"),
+		Database: r.FormValue("db"),
+		Reason:   r.FormValue("reason"),
+	}
+	if user != nil {
+		req.KilledBy = user.ID()
+	}
+	ids, err := h.QueryExecutor.TaskManager.KillQueries(req)
+	if err != nil {
+		h.httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values := make([][]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = []interface{}{id}
+	}
+	h.writeHeader(w, http.StatusOK)
+	w.(ResponseWriter).WriteResponse(Response{Results: []*query.Result{{
+		Series: models.Rows{{Name: "killed", Columns: []string{"qid"}, Values: values}},
+	}}})
+}
+
 // async drains the results from an async query and logs a message if it fails.
 func (h *Handler) async(q *influxql.Query, results <-chan *query.Result) {
 	for r := range results {
@@ -645,33 +1055,73 @@ func (h *Handler) serveWrite(w http.ResponseWriter, r *http.Request, user meta.U
 	}(time.Now())
 	h.requestTracker.Add(r, user)
 
+	tr := h.startTrace(r, "write")
+	defer tr.Finish()
+
 	database := r.URL.Query().Get("db")
 	if database == "" {
 		h.httpError(w, "database is required", http.StatusBadRequest)
 		return
 	}
 
+	w, done := h.audit.startWrite(w, r, user, database)
+	defer done()
+
 	if di := h.MetaClient.Database(database); di == nil {
 		h.httpError(w, fmt.Sprintf("database not found: %q", database), http.StatusNotFound)
 		return
 	}
 
-	if h.Config.AuthEnabled {
+	// Writes are acknowledged once applied, or once enqueued in the intake.
+	var enqueue bool
+	switch ack := r.URL.Query().Get("ack"); ack {
+	case "", "applied":
+	case "enqueued":
+		if !h.Config.WriteIntake.Enabled {
+			h.httpError(w, "write intake is not enabled", http.StatusBadRequest)
+			return
+		} else if h.intake == nil {
+			h.httpError(w, "write intake is unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		enqueue = true
+	default:
+		h.httpError(w, fmt.Sprintf("invalid ack %q: must be applied or enqueued", ack), http.StatusBadRequest)
+		return
+	}
+
+	if h.authEnabled(r) {
 		if user == nil {
 			h.httpError(w, fmt.Sprintf("user is required to write to database %q", database), http.StatusForbidden)
 			return
 		}
 
-		if err := h.WriteAuthorizer.AuthorizeWrite(user.ID(), database); err != nil {
+		if err := h.authorizeWrite(user, database); err != nil {
 			h.httpError(w, fmt.Sprintf("%q user is not authorized to write to database %q", user.ID(), database), http.StatusForbidden)
 			return
 		}
 	}
 
+	if h.quotas != nil {
+		release, err := h.quotas.acquire(user, database)
+		if err != nil {
+			h.quotaError(w, err)
+			return
+		}
+		defer release()
+	}
+
+	release, ok := h.admitWrite(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
 	body := r.Body
 	if h.Config.MaxBodySize > 0 {
 		body = truncateReader(body, int64(h.Config.MaxBodySize))
 	}
+	mem := h.newRequestMemory(user)
 
 	// Handle gzip decoding of the body
 	if r.Header.Get("Content-Encoding") == "gzip" {
@@ -697,11 +1147,16 @@ func (h *Handler) serveWrite(w http.ResponseWriter, r *http.Request, user meta.U
 	}
 	buf := bytes.NewBuffer(bs)
 
-	_, err := buf.ReadFrom(body)
+	// The body is not read beyond the memory of the request.
+	_, err := buf.ReadFrom(mem.reader(body))
 	if err != nil {
 		if err == errTruncated {
 			h.httpError(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
 			return
+		} else if _, ok := err.(requestMemoryError); ok {
+			atomic.AddInt64(&h.stats.RequestMemoryExceeded, 1)
+			h.httpError(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
 		}
 
 		if h.Config.WriteTracing {
@@ -716,7 +1171,29 @@ func (h *Handler) serveWrite(w http.ResponseWriter, r *http.Request, user meta.U
 		h.Logger.Info("Write body received by handler", zap.ByteString("body", buf.Bytes()))
 	}
 
-	points, parseError := models.ParsePointsWithPrecision(buf.Bytes(), time.Now().UTC(), r.URL.Query().Get("precision"))
+	var points []models.Point
+	var parseError error
+	if isJSONWrite(r) {
+		// Points in the JSON format are written all or none.
+		if points, parseError = parseJSONPoints(buf.Bytes(), time.Now().UTC(), r.URL.Query().Get("precision")); parseError != nil {
+			h.httpError(w, parseError.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("dry-run") == "true" {
+			lines := make([]int, len(points))
+			for i := range lines {
+				lines[i] = i + 1
+			}
+			h.writeDryRunResult(w, r, database, points, lines, []lineError{})
+			return
+		}
+	} else if r.URL.Query().Get("dry-run") == "true" {
+		h.serveWriteDryRun(w, r, database, buf.Bytes())
+		return
+	} else {
+		points, parseError = models.ParsePointsWithPrecision(buf.Bytes(), time.Now().UTC(), r.URL.Query().Get("precision"))
+	}
+
 	// Not points parsed correctly so return the error now
 	if parseError != nil && len(points) == 0 {
 		if parseError.Error() == "EOF" {
@@ -727,6 +1204,24 @@ func (h *Handler) serveWrite(w http.ResponseWriter, r *http.Request, user meta.U
 		return
 	}
 
+	size := pointsSize(points)
+	if isJSONWrite(r) {
+		// The points of JSON bodies are copied from them.
+		size += int64(buf.Len())
+	}
+	if err := mem.grow(size); err != nil {
+		atomic.AddInt64(&h.stats.RequestMemoryExceeded, 1)
+		h.httpError(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if h.quotas != nil {
+		if err := h.quotas.allowWrite(user, database, len(points), buf.Len()); err != nil {
+			h.quotaError(w, err)
+			return
+		}
+	}
+
 	// Determine required consistency level.
 	level := r.URL.Query().Get("consistency")
 	consistency := models.ConsistencyLevelOne
@@ -739,8 +1234,13 @@ func (h *Handler) serveWrite(w http.ResponseWriter, r *http.Request, user meta.U
 		}
 	}
 
+	if enqueue {
+		h.enqueueWrite(w, database, r.URL.Query().Get("rp"), consistency, points, parseError)
+		return
+	}
+
 	// Write points.
-	if err := h.PointsWriter.WritePoints(database, r.URL.Query().Get("rp"), consistency, user, points); influxdb.IsClientError(err) {
+	if err := h.writePoints(tr, database, r.URL.Query().Get("rp"), consistency, user, points); influxdb.IsClientError(err) {
 		atomic.AddInt64(&h.stats.PointsWrittenFail, int64(len(points)))
 		h.httpError(w, err.Error(), http.StatusBadRequest)
 		return
@@ -770,6 +1270,150 @@ func (h *Handler) serveWrite(w http.ResponseWriter, r *http.Request, user meta.U
 	h.writeHeader(w, http.StatusNoContent)
 }
 
+// enqueueWrite enqueues a write of points in the intake, and responds with
+// 202 Accepted once it is synced, or with the error of the points that failed
+// to parse.
+func (h *Handler) enqueueWrite(w http.ResponseWriter, database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point, parseError error) {
+	if err := h.intake.enqueue(database, retentionPolicy, consistencyLevel, points); err == errWriteIntakeFull {
+		h.httpError(w, err.Error(), http.StatusTooManyRequests)
+		return
+	} else if err == errWriteIntakeClosed {
+		h.httpError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		h.httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	atomic.AddInt64(&h.stats.WriteRequestsEnqueued, 1)
+
+	if parseError != nil {
+		h.httpError(w, tsdb.PartialWriteError{Reason: parseError.Error()}.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeHeader(w, http.StatusAccepted)
+}
+
+// writeIntakePoints writes the points of a write of the intake, which was
+// authorized when it was enqueued. It returns an error if the write failed
+// and should be retried: writes failing because of their points, or because
+// their database or retention policy no longer exists, are dropped.
+func (h *Handler) writeIntakePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	err := h.PointsWriter.WritePoints(database, retentionPolicy, consistencyLevel, nil, points)
+	if err == nil {
+		atomic.AddInt64(&h.stats.PointsWrittenOK, int64(len(points)))
+		return nil
+	} else if werr, ok := err.(tsdb.PartialWriteError); ok {
+		atomic.AddInt64(&h.stats.PointsWrittenOK, int64(len(points)-werr.Dropped))
+		atomic.AddInt64(&h.stats.PointsWrittenDropped, int64(werr.Dropped))
+	} else if influxdb.IsClientError(err) || influxdb.IsAuthorizationError(err) ||
+		strings.HasPrefix(err.Error(), "database not found") ||
+		strings.HasPrefix(err.Error(), "retention policy not found") {
+		atomic.AddInt64(&h.stats.PointsWrittenFail, int64(len(points)))
+	} else {
+		return err
+	}
+	h.Logger.Info("Dropped write of the write intake", zap.String("db", database), zap.Int("points", len(points)), zap.Error(err))
+	return nil
+}
+
+// startTrace starts tracing r with a span of name, if a tracer is set and r is
+// sampled. The returned request may be nil, and must be finished.
+func (h *Handler) startTrace(r *http.Request, name string) *otel.Request {
+	if h.Tracer == nil {
+		return nil
+	}
+	return h.Tracer.Start(r, name)
+}
+
+// writePoints writes points with the points writer, within the span of tr if
+// the request is traced and the points writer records spans.
+func (h *Handler) writePoints(tr *otel.Request, database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error {
+	if tr == nil {
+		return h.PointsWriter.WritePoints(database, retentionPolicy, consistencyLevel, user, points)
+	}
+	span := tr.Span()
+	span.MergeLabels("db.name", database)
+	span.MergeFields(fields.Int64("points", int64(len(points))))
+
+	pw, ok := h.PointsWriter.(interface {
+		WritePointsWithContext(ctx context.Context, database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error
+	})
+	if !ok {
+		return h.PointsWriter.WritePoints(database, retentionPolicy, consistencyLevel, user, points)
+	}
+	ctx := tracing.NewContextWithSpan(context.Background(), span)
+	return pw.WritePointsWithContext(ctx, database, retentionPolicy, consistencyLevel, user, points)
+}
+
+// lineError is the error of a line of a write validated by a dry run.
+type lineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// serveWriteDryRun parses body and validates its points against the schema
+// and limits of database, as a write would, and responds with the errors of
+// each line. Nothing is written.
+func (h *Handler) serveWriteDryRun(w http.ResponseWriter, r *http.Request, database string, body []byte) {
+	var (
+		points []models.Point
+		lines  []int
+		errs   = []lineError{}
+	)
+	models.ParseLines(body, time.Now().UTC(), r.URL.Query().Get("precision"), func(line int, _ []byte, pt models.Point, err error) {
+		if err != nil {
+			errs = append(errs, lineError{Line: line, Error: err.Error()})
+			return
+		}
+		points = append(points, pt)
+		lines = append(lines, line)
+	})
+	h.writeDryRunResult(w, r, database, points, lines, errs)
+}
+
+// writeDryRunResult validates the points of a dry run write, which are on the
+// lines of the body, and writes the errors of their validation and of the
+// parsing of the body.
+func (h *Handler) writeDryRunResult(w http.ResponseWriter, r *http.Request, database string, points []models.Point, lines []int, errs []lineError) {
+	parseErrors := len(errs)
+
+	// Validate the points that parsed, if the points writer supports it.
+	invalid := 0
+	if pv, ok := h.PointsWriter.(interface {
+		ValidatePoints(database, retentionPolicy string, points []models.Point) (map[int]error, error)
+	}); ok && len(points) > 0 {
+		pointErrs, err := pv.ValidatePoints(database, r.URL.Query().Get("rp"), points)
+		if influxdb.IsClientError(err) {
+			h.httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
+			h.httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i, err := range pointErrs {
+			errs = append(errs, lineError{Line: lines[i], Error: err.Error()})
+		}
+		invalid = len(pointErrs)
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+
+	code := http.StatusOK
+	if len(errs) > 0 {
+		code = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	h.writeHeader(w, code)
+	json.NewEncoder(w).Encode(struct {
+		Points int         `json:"points"`
+		Valid  int         `json:"valid"`
+		Errors []lineError `json:"errors"`
+	}{
+		Points: len(points) + parseErrors,
+		Valid:  len(points) - invalid,
+		Errors: errs,
+	})
+}
+
 // serveOptions returns an empty response to comply with OPTIONS pre-flight requests
 func (h *Handler) serveOptions(w http.ResponseWriter, r *http.Request) {
 	h.writeHeader(w, http.StatusNoContent)
@@ -832,23 +1476,41 @@ func (h *Handler) servePromWrite(w http.ResponseWriter, r *http.Request, user me
 		return
 	}
 
+	w, done := h.audit.startWrite(w, r, user, database)
+	defer done()
+
 	if di := h.MetaClient.Database(database); di == nil {
 		h.httpError(w, fmt.Sprintf("database not found: %q", database), http.StatusNotFound)
 		return
 	}
 
-	if h.Config.AuthEnabled {
+	if h.authEnabled(r) {
 		if user == nil {
 			h.httpError(w, fmt.Sprintf("user is required to write to database %q", database), http.StatusForbidden)
 			return
 		}
 
-		if err := h.WriteAuthorizer.AuthorizeWrite(user.ID(), database); err != nil {
+		if err := h.authorizeWrite(user, database); err != nil {
 			h.httpError(w, fmt.Sprintf("%q user is not authorized to write to database %q", user.ID(), database), http.StatusForbidden)
 			return
 		}
 	}
 
+	if h.quotas != nil {
+		release, err := h.quotas.acquire(user, database)
+		if err != nil {
+			h.quotaError(w, err)
+			return
+		}
+		defer release()
+	}
+
+	release, ok := h.admitWrite(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
 	body := r.Body
 	if h.Config.MaxBodySize > 0 {
 		body = truncateReader(body, int64(h.Config.MaxBodySize))
@@ -911,6 +1573,13 @@ func (h *Handler) servePromWrite(w http.ResponseWriter, r *http.Request, user me
 		}
 	}
 
+	if h.quotas != nil {
+		if err := h.quotas.allowWrite(user, database, len(points), len(reqBuf)); err != nil {
+			h.quotaError(w, err)
+			return
+		}
+	}
+
 	// Determine required consistency level.
 	level := r.URL.Query().Get("consistency")
 	consistency := models.ConsistencyLevelOne
@@ -969,15 +1638,19 @@ func (h *Handler) servePromRead(w http.ResponseWriter, r *http.Request, user met
 
 	// Query the DB and create a ReadResponse for Prometheus
 	db := r.FormValue("db")
-	q, err := prometheus.ReadRequestToInfluxQLQuery(&req, db, r.FormValue("rp"))
+	rp := r.FormValue("rp")
+	if rp == "" && len(req.Queries) == 1 {
+		rp = h.promReadRetentionPolicy(db, req.Queries[0])
+	}
+	q, err := prometheus.ReadRequestToInfluxQLQuery(&req, db, rp)
 	if err != nil {
 		h.httpError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Check authorization.
-	if h.Config.AuthEnabled {
-		if err := h.QueryAuthorizer.AuthorizeQuery(user, q, db); err != nil {
+	if h.authEnabled(r) {
+		if err := h.authorizeQuery(user, q, db); err != nil {
 			if err, ok := err.(meta.ErrAuthorize); ok {
 				h.Logger.Info("Unauthorized request",
 					zap.String("user", err.User),
@@ -989,13 +1662,28 @@ func (h *Handler) servePromRead(w http.ResponseWriter, r *http.Request, user met
 		}
 	}
 
+	// Check quotas.
+	if h.quotas != nil {
+		quotaDB := h.quotaDatabase(db)
+		release, err := h.quotas.acquire(user, quotaDB)
+		if err != nil {
+			h.quotaError(w, err)
+			return
+		}
+		defer release()
+		if err := h.quotas.allowQuery(user, quotaDB); err != nil {
+			h.quotaError(w, err)
+			return
+		}
+	}
+
 	opts := query.ExecutionOptions{
 		Database:  db,
 		ChunkSize: DefaultChunkSize,
 		ReadOnly:  true,
 	}
 
-	if h.Config.AuthEnabled {
+	if h.authEnabled(r) {
 		// The current user determines the authorized actions.
 		opts.Authorizer = user
 	} else {
@@ -1030,15 +1718,43 @@ func (h *Handler) servePromRead(w http.ResponseWriter, r *http.Request, user met
 	// Execute query.
 	results := h.QueryExecutor.ExecuteQuery(q, opts, closing)
 
+	timeseries, err := promSeries(results)
+	if err != nil {
+		h.httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	resp := &remote.ReadResponse{
-		Results: []*remote.QueryResult{{}},
+		Results: []*remote.QueryResult{{Timeseries: timeseries}},
 	}
 
-	// pull all results from the channel
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		h.httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+
+	compressed = snappy.Encode(nil, data)
+	if _, err := w.Write(compressed); err != nil {
+		h.httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	atomic.AddInt64(&h.stats.QueryRequestBytesTransmitted, int64(len(compressed)))
+}
+
+// promSeries reads the series of the results of a query converted from a
+// Prometheus remote read query, and converts them to Prometheus time series.
+func promSeries(results <-chan *query.Result) ([]*remote.TimeSeries, error) {
+	var timeseries []*remote.TimeSeries
 	for r := range results {
 		// Ignore nil results.
 		if r == nil {
 			continue
+		} else if r.Err != nil {
+			return nil, r.Err
 		}
 
 		// read the series data and convert into Prometheus samples
@@ -1050,12 +1766,11 @@ func (h *Handler) servePromRead(w http.ResponseWriter, r *http.Request, user met
 			for _, v := range s.Values {
 				t, ok := v[0].(time.Time)
 				if !ok {
-					h.httpError(w, fmt.Sprintf("value %v wasn't a time", v[0]), http.StatusBadRequest)
-					return
+					return nil, fmt.Errorf("value %v wasn't a time", v[0])
 				}
 				val, ok := v[1].(float64)
 				if !ok {
-					h.httpError(w, fmt.Sprintf("value %v wasn't a float64", v[1]), http.StatusBadRequest)
+					return nil, fmt.Errorf("value %v wasn't a float64", v[1])
 				}
 				timestamp := t.UnixNano() / int64(time.Millisecond) / int64(time.Nanosecond)
 				ts.Samples = append(ts.Samples, &remote.Sample{
@@ -1064,26 +1779,42 @@ func (h *Handler) servePromRead(w http.ResponseWriter, r *http.Request, user met
 				})
 			}
 
-			resp.Results[0].Timeseries = append(resp.Results[0].Timeseries, ts)
+			timeseries = append(timeseries, ts)
 		}
 	}
+	return timeseries, nil
+}
 
-	data, err := proto.Marshal(resp)
-	if err != nil {
-		h.httpError(w, err.Error(), http.StatusInternalServerError)
-		return
+// promReadRetentionPolicy returns the coarsest downsampled retention policy
+// of the database db serving the remote read query q, or an empty string if
+// none holds the aggregated samples of its whole range.
+func (h *Handler) promReadRetentionPolicy(db string, q *remote.Query) string {
+	agg := prometheus.HintAggregate(q.Hints)
+	if agg == "" || len(h.Config.PromReadDownsamples) == 0 {
+		return ""
 	}
 
-	w.Header().Set("Content-Type", "application/x-protobuf")
-	w.Header().Set("Content-Encoding", "snappy")
-
-	compressed = snappy.Encode(nil, data)
-	if _, err := w.Write(compressed); err != nil {
-		h.httpError(w, err.Error(), http.StatusInternalServerError)
-		return
+	di := h.MetaClient.Database(db)
+	if di == nil {
+		return ""
 	}
 
-	atomic.AddInt64(&h.stats.QueryRequestBytesTransmitted, int64(len(compressed)))
+	step := time.Duration(q.Hints.StepMs) * time.Millisecond
+	start := time.Unix(0, q.StartTimestampMs*int64(time.Millisecond))
+	var rp string
+	var resolution time.Duration
+	for _, d := range h.Config.PromReadDownsamples {
+		if d.Function != agg || time.Duration(d.Resolution) > step || time.Duration(d.Resolution) <= resolution {
+			continue
+		}
+		// The retention policy must still hold the samples at the start.
+		rpi := di.RetentionPolicy(d.RetentionPolicy)
+		if rpi == nil || (rpi.Duration != 0 && start.Before(time.Now().Add(-rpi.Duration))) {
+			continue
+		}
+		rp, resolution = d.RetentionPolicy, time.Duration(d.Resolution)
+	}
+	return rp
 }
 
 // serveExpvar serves internal metrics in /debug/vars format over HTTP.
@@ -1282,6 +2013,23 @@ func parseSystemDiagnostics(d *diagnostics.Diagnostics) (map[string]interface{},
 	return m, nil
 }
 
+// quotaDatabase returns db if it exists. Quotas are only kept for existing
+// databases.
+func (h *Handler) quotaDatabase(db string) string {
+	if db == "" || h.MetaClient.Database(db) == nil {
+		return ""
+	}
+	return db
+}
+
+// quotaError responds to a request exceeding a quota, telling the client when
+// to retry it.
+func (h *Handler) quotaError(w http.ResponseWriter, err *quotaExceededError) {
+	atomic.AddInt64(&h.stats.QuotaExceeded, 1)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.retryAfter.Seconds()))))
+	h.httpError(w, err.Error(), http.StatusTooManyRequests)
+}
+
 // httpError writes an error to the client in a standard format.
 func (h *Handler) httpError(w http.ResponseWriter, errmsg string, code int) {
 	if code == http.StatusUnauthorized {
@@ -1359,14 +2107,59 @@ func parseCredentials(r *http.Request) (*credentials, error) {
 	return nil, fmt.Errorf("unable to parse authentication credentials")
 }
 
+// authorizeQuery authorizes user to execute q on db. Users of the LDAP
+// directory are not in the meta store, so they are authorized by their own
+// privileges.
+func (h *Handler) authorizeQuery(user meta.User, q *influxql.Query, db string) error {
+	if u, ok := user.(*ldapUser); ok {
+		return u.AuthorizeQuery(db, q)
+	}
+	return h.QueryAuthorizer.AuthorizeQuery(user, q, db)
+}
+
+// authorizeWrite authorizes user to write to db.
+func (h *Handler) authorizeWrite(user meta.User, db string) error {
+	if u, ok := user.(*ldapUser); ok {
+		if !u.AuthorizeDatabase(influxql.WritePrivilege, db) {
+			return &meta.ErrAuthorize{
+				User:     u.Name,
+				Database: db,
+				Message:  fmt.Sprintf("%s not authorized to write to %s", u.Name, db),
+			}
+		}
+		return nil
+	}
+	return h.WriteAuthorizer.AuthorizeWrite(user.ID(), db)
+}
+
 // authenticate wraps a handler and ensures that if user credentials are passed in
 // an attempt is made to authenticate that user. If authentication fails, an error is returned.
 //
 // There is one exception: if there are no users in the system, authentication is not required. This
 // is to facilitate bootstrapping of a system with authentication enabled.
-func authenticate(inner func(http.ResponseWriter, *http.Request, meta.User), h *Handler, requireAuthentication bool) http.Handler {
+// authenticateUser returns the user with username if password is its password
+// in the LDAP directory, if enabled, or else in the meta store. The users of
+// the meta store are also tried after the directory if LDAP falls back to them.
+func (h *Handler) authenticateUser(username, password string) (meta.User, error) {
+	if h.ldap == nil {
+		return h.MetaClient.Authenticate(username, password)
+	}
+
+	user, err := h.ldap.authenticate(username, password)
+	if err != nil && err != meta.ErrAuthenticate {
+		h.Logger.Info("LDAP authentication failed",
+			zap.String("username", username), zap.Error(err))
+	}
+	if err != nil && h.Config.LDAP.LocalFallback {
+		return h.MetaClient.Authenticate(username, password)
+	}
+	return user, err
+}
+
+func authenticate(inner func(http.ResponseWriter, *http.Request, meta.User), h *Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Return early if we are not authenticating
+		requireAuthentication := h.authEnabled(r)
 		if !requireAuthentication {
 			inner(w, r, nil)
 			return
@@ -1374,8 +2167,15 @@ func authenticate(inner func(http.ResponseWriter, *http.Request, meta.User), h *
 		var user meta.User
 
 		// TODO corylanou: never allow this in the future without users
-		if requireAuthentication && h.MetaClient.AdminUserExists() {
+		if requireAuthentication && (h.ldap != nil || h.MetaClient.AdminUserExists()) {
 			creds, err := parseCredentials(r)
+			if err != nil {
+				// Requests without credentials may authenticate with their
+				// client certificate.
+				if c := h.clientCertificateCredentials(r); c != nil {
+					creds, err = c, nil
+				}
+			}
 			if err != nil {
 				atomic.AddInt64(&h.stats.AuthenticationFailures, 1)
 				h.httpError(w, err.Error(), http.StatusUnauthorized)
@@ -1390,23 +2190,25 @@ func authenticate(inner func(http.ResponseWriter, *http.Request, meta.User), h *
 					return
 				}
 
-				user, err = h.MetaClient.Authenticate(creds.Username, creds.Password)
+				user, err = h.authenticateUser(creds.Username, creds.Password)
 				if err != nil {
 					atomic.AddInt64(&h.stats.AuthenticationFailures, 1)
 					h.httpError(w, "authorization failed", http.StatusUnauthorized)
 					return
 				}
 			case BearerAuthentication:
-				keyLookupFn := func(token *jwt.Token) (interface{}, error) {
-					// Check for expected signing method.
-					if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-						return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+				// Tokens of the OpenID Connect issuer are validated against its keys.
+				if h.oidc != nil && h.oidc.issued(creds.Token) {
+					if user, err = h.authenticateOIDC(creds.Token); err != nil {
+						atomic.AddInt64(&h.stats.AuthenticationFailures, 1)
+						h.httpError(w, err.Error(), http.StatusUnauthorized)
+						return
 					}
-					return []byte(h.Config.SharedSecret), nil
+					break
 				}
 
 				// Parse and validate the token.
-				token, err := jwt.Parse(creds.Token, keyLookupFn)
+				token, err := h.parseJWT(creds.Token)
 				if err != nil {
 					h.httpError(w, err.Error(), http.StatusUnauthorized)
 					return
@@ -1446,6 +2248,12 @@ func authenticate(inner func(http.ResponseWriter, *http.Request, meta.User), h *
 					h.httpError(w, meta.ErrUserNotFound.Error(), http.StatusUnauthorized)
 					return
 				}
+			case ClientCertificateAuthentication:
+				if user, err = h.MetaClient.User(creds.Username); err != nil || user == nil {
+					atomic.AddInt64(&h.stats.AuthenticationFailures, 1)
+					h.httpError(w, "authorization failed", http.StatusUnauthorized)
+					return
+				}
 			default:
 				h.httpError(w, "unsupported authentication", http.StatusUnauthorized)
 			}
@@ -1455,45 +2263,6 @@ func authenticate(inner func(http.ResponseWriter, *http.Request, meta.User), h *
 	})
 }
 
-// cors responds to incoming requests and adds the appropriate cors headers
-// TODO: corylanou: add the ability to configure this in our config
-func cors(inner http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if origin := r.Header.Get("Origin"); origin != "" {
-			w.Header().Set(`Access-Control-Allow-Origin`, origin)
-			w.Header().Set(`Access-Control-Allow-Methods`, strings.Join([]string{
-				`DELETE`,
-				`GET`,
-				`OPTIONS`,
-				`POST`,
-				`PUT`,
-			}, ", "))
-
-			w.Header().Set(`Access-Control-Allow-Headers`, strings.Join([]string{
-				`Accept`,
-				`Accept-Encoding`,
-				`Authorization`,
-				`Content-Length`,
-				`Content-Type`,
-				`X-CSRF-Token`,
-				`X-HTTP-Method-Override`,
-			}, ", "))
-
-			w.Header().Set(`Access-Control-Expose-Headers`, strings.Join([]string{
-				`Date`,
-				`X-InfluxDB-Version`,
-				`X-InfluxDB-Build`,
-			}, ", "))
-		}
-
-		if r.Method == "OPTIONS" {
-			return
-		}
-
-		inner.ServeHTTP(w, r)
-	})
-}
-
 func requestID(inner http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// X-Request-Id takes priority.
@@ -1590,6 +2359,9 @@ func (h *Handler) recovery(inner http.Handler, name string) http.Handler {
 type Response struct {
 	Results []*query.Result
 	Err     error
+
+	// Cursor, if set, resumes a paged query at its next result.
+	Cursor string
 }
 
 // MarshalJSON encodes a Response struct into JSON.
@@ -1598,10 +2370,12 @@ func (r Response) MarshalJSON() ([]byte, error) {
 	var o struct {
 		Results []*query.Result `json:"results,omitempty"`
 		Err     string          `json:"error,omitempty"`
+		Cursor  string          `json:"cursor,omitempty"`
 	}
 
 	// Copy fields to output struct.
 	o.Results = r.Results
+	o.Cursor = r.Cursor
 	if r.Err != nil {
 		o.Err = r.Err.Error()
 	}
@@ -1614,6 +2388,7 @@ func (r *Response) UnmarshalJSON(b []byte) error {
 	var o struct {
 		Results []*query.Result `json:"results,omitempty"`
 		Err     string          `json:"error,omitempty"`
+		Cursor  string          `json:"cursor,omitempty"`
 	}
 
 	err := json.Unmarshal(b, &o)
@@ -1621,6 +2396,7 @@ func (r *Response) UnmarshalJSON(b []byte) error {
 		return err
 	}
 	r.Results = o.Results
+	r.Cursor = o.Cursor
 	if o.Err != "" {
 		r.Err = errors.New(o.Err)
 	}
@@ -2,17 +2,24 @@ package httpd_test
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"math"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -25,6 +32,7 @@ import (
 	"github.com/influxdata/influxdb/query"
 	"github.com/influxdata/influxdb/services/httpd"
 	"github.com/influxdata/influxdb/services/meta"
+	itoml "github.com/influxdata/influxdb/toml"
 	"github.com/influxdata/influxql"
 )
 
@@ -235,6 +243,76 @@ func TestHandler_Query_Auth(t *testing.T) {
 	}
 }
 
+// Ensure requests without credentials authenticate with their verified
+// client certificate.
+func TestHandler_Query_ClientCertificate(t *testing.T) {
+	h := NewHandler(true)
+	h.Config.HTTPSClientCA = "/etc/ssl/clients.pem"
+	h.Config.ClientCertificateUsers = []httpd.ClientCertificateUser{
+		{CN: "client1", User: "user1"},
+		{CN: "client2", User: "user2"},
+	}
+	h.MetaClient.AdminUserExistsFn = func() bool { return true }
+	h.MetaClient.UserFn = func(username string) (meta.User, error) {
+		if username != "user1" {
+			return nil, meta.ErrUserNotFound
+		}
+		return &meta.UserInfo{Name: "user1", Hash: "abcd"}, nil
+	}
+	h.MetaClient.AuthenticateFn = func(u, p string) (meta.User, error) {
+		return nil, meta.ErrAuthenticate
+	}
+	h.QueryAuthorizer.AuthorizeQueryFn = func(u meta.User, query *influxql.Query, database string) error {
+		if u.ID() != "user1" {
+			t.Fatalf("unexpected user: %s", u.ID())
+		}
+		return nil
+	}
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+		ctx.Results <- &query.Result{StatementID: 1, Series: models.Rows([]*models.Row{{Name: "series0"}})}
+		return nil
+	}
+
+	request := func(cn string) *http.Request {
+		r := MustNewJSONRequest("GET", "/query?db=foo&q=SELECT+*+FROM+bar", nil)
+		r.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}},
+		}
+		return r
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request("client1"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	}
+
+	// Certificates of users that do not exist are rejected.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, request("client2"))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"error":"authorization failed"}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	// Certificates that map to no user do not authenticate.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, request("client3"))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	}
+
+	// Credentials take precedence over the certificate.
+	w = httptest.NewRecorder()
+	r := request("client1")
+	r.SetBasicAuth("user1", "efgh")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	}
+}
+
 // Ensure the handler returns results from a query (including nil results).
 func TestHandler_QueryRegex(t *testing.T) {
 	h := NewHandler(false)
@@ -270,6 +348,30 @@ func TestHandler_Query_MergeResults(t *testing.T) {
 	}
 }
 
+// Ensure results buffered beyond the memory of a request are dropped.
+func TestHandler_Query_MaxRequestMemory(t *testing.T) {
+	config := httpd.NewConfig()
+	config.MaxRequestMemory = 300
+	h := NewHandlerWithConfig(config)
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+		for i := 0; i < 2; i++ {
+			ctx.Results <- &query.Result{StatementID: 1, Series: models.Rows([]*models.Row{{
+				Name:   "series0",
+				Values: [][]interface{}{{time.Unix(0, 0), 1.0}},
+			}})}
+		}
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?db=foo&q=SELECT+*+FROM+bar", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"results":[{"statement_id":1,"error":"max-request-memory limit exceeded: (430/300)"}]}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
 // Ensure the handler merges results from the same statement.
 func TestHandler_Query_MergeEmptyResults(t *testing.T) {
 	h := NewHandler(false)
@@ -312,6 +414,141 @@ func TestHandler_Query_Chunked(t *testing.T) {
 }
 
 // Ensure the handler can accept an async query.
+// Ensure paged queries return one result per request, with a cursor resuming
+// them until their results are exhausted.
+func TestHandler_Query_Paginate(t *testing.T) {
+	h := NewHandler(false)
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+		if ctx.ChunkSize != 2 {
+			t.Fatalf("unexpected chunk size: %d", ctx.ChunkSize)
+		}
+		ctx.Results <- &query.Result{StatementID: 1, Series: models.Rows([]*models.Row{{Name: "series0"}})}
+		ctx.Results <- &query.Result{StatementID: 1, Series: models.Rows([]*models.Row{{Name: "series1"}})}
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?db=foo&q=SELECT+*+FROM+bar&paginate=true&page_size=2", nil))
+	cursor := w.Header().Get("X-InfluxDB-Cursor")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if cursor == "" {
+		t.Fatal("expected cursor")
+	} else if w.Body.String() != `{"results":[{"statement_id":1,"series":[{"name":"series0"}]}],"cursor":"`+cursor+"\"}\n" {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?cursor="+cursor, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Header().Get("X-InfluxDB-Cursor") != "" {
+		t.Fatalf("unexpected cursor: %s", w.Header().Get("X-InfluxDB-Cursor"))
+	} else if w.Body.String() != `{"results":[{"statement_id":1,"series":[{"name":"series1"}]}]}
+` {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+
+	// Exhausted cursors cannot be resumed.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?cursor="+cursor, nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure the handler kills the queries against a database, with a reason.
+func TestHandler_KillQueries(t *testing.T) {
+	h := NewHandler(false)
+	started := make(chan struct{})
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+		close(started)
+		select {
+		case <-ctx.InterruptCh:
+			return query.ErrQueryInterrupted
+		case <-time.After(time.Second):
+			return errors.New("query not killed")
+		}
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, MustNewJSONRequest("GET", "/query?db=foo&q=SELECT+*+FROM+bar", nil))
+		done <- w
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("DELETE", "/queries?db=foo&reason=maintenance", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"results":[{"statement_id":0,"series":[{"name":"killed","columns":["qid"],"values":[[1]]}]}]}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	w = <-done
+	if body := strings.TrimSpace(w.Body.String()); body != `{"results":[{"statement_id":0,"error":"query killed: maintenance"}]}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	// Queries to kill must be given.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("DELETE", "/queries", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure the handler exports the points of a measurement as CSV or Parquet.
+func TestHandler_Export(t *testing.T) {
+	h := NewHandler(false)
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+		s := stmt.(*influxql.SelectStatement)
+		if m := s.Sources[0].(*influxql.Measurement); m.Database != "foo" || m.Name != "bar" {
+			t.Fatalf("unexpected source: %s", m)
+		} else if s.Condition == nil {
+			t.Fatal("expected condition")
+		} else if ctx.ChunkSize != 1 {
+			t.Fatalf("unexpected chunk size: %d", ctx.ChunkSize)
+		}
+		ctx.Results <- &query.Result{StatementID: 0, Series: models.Rows([]*models.Row{{
+			Name: "bar", Tags: map[string]string{"host": "a"}, Columns: []string{"time", "value"},
+			Values: [][]interface{}{{time.Unix(0, 1), 1.5}},
+		}})}
+		ctx.Results <- &query.Result{StatementID: 0, Series: models.Rows([]*models.Row{{
+			Name: "bar", Tags: map[string]string{"host": "b"}, Columns: []string{"time", "value"},
+			Values: [][]interface{}{{time.Unix(0, 2), 2.5}},
+		}})}
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/export?db=foo&measurement=bar&start=2017-01-01T00:00:00Z&chunk_size=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("unexpected content type: %s", w.Header().Get("Content-Type"))
+	} else if w.Body.String() != "name,host,time,value\nbar,a,1,1.5\nbar,b,2,2.5\n" {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/export?db=foo&measurement=bar&start=2017-01-01T00:00:00Z&chunk_size=1&format=parquet", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := w.Body.String(); !strings.HasPrefix(body, "PAR1") || !strings.HasSuffix(body, "PAR1") {
+		t.Fatalf("unexpected body: %q", body)
+	}
+
+	// Only single SELECT statements can be exported.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("GET", "/export?db=foo&q=SHOW+DATABASES", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
 func TestHandler_Query_Async(t *testing.T) {
 	done := make(chan struct{})
 	h := NewHandler(false)
@@ -660,6 +897,395 @@ func TestHandler_PromRead(t *testing.T) {
 	}
 }
 
+// Ensure Prometheus remote read requests aggregating samples within steps are
+// served from the coarsest downsampled retention policy holding their range.
+func TestHandler_PromRead_Downsampled(t *testing.T) {
+	now := time.Now()
+	req := &remote.ReadRequest{
+		Queries: []*remote.Query{{
+			Matchers: []*remote.LabelMatcher{
+				{Type: remote.MatchType_EQUAL, Name: "eq", Value: "a"},
+			},
+			StartTimestampMs: now.Add(-24*time.Hour).UnixNano() / int64(time.Millisecond),
+			EndTimestampMs:   now.UnixNano() / int64(time.Millisecond),
+			Hints:            &remote.ReadHints{StepMs: int64(time.Hour / time.Millisecond), Func: "avg_over_time"},
+		}},
+	}
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal("couldn't marshal prometheus request")
+	}
+
+	config := httpd.NewConfig()
+	config.PromReadDownsamples = []httpd.PromReadDownsampleConfig{
+		{RetentionPolicy: "1m", Resolution: itoml.Duration(time.Minute), Function: "mean"},
+		{RetentionPolicy: "5m", Resolution: itoml.Duration(5 * time.Minute), Function: "mean"},
+		{RetentionPolicy: "5m_max", Resolution: itoml.Duration(5 * time.Minute), Function: "max"},
+		{RetentionPolicy: "1h", Resolution: itoml.Duration(time.Hour), Function: "mean"},
+	}
+	h := NewHandlerWithConfig(config)
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		return &meta.DatabaseInfo{
+			Name: name,
+			RetentionPolicies: []meta.RetentionPolicyInfo{
+				{Name: "1m", Duration: 7 * 24 * time.Hour},
+				{Name: "5m", Duration: 30 * 24 * time.Hour},
+				{Name: "5m_max", Duration: 30 * 24 * time.Hour},
+				{Name: "1h", Duration: 12 * time.Hour},
+			},
+		}
+	}
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+		s := stmt.(*influxql.SelectStatement)
+		if src := s.Sources[0].(*influxql.Measurement); src.RetentionPolicy != "5m" {
+			t.Fatalf("unexpected retention policy: %s", src.RetentionPolicy)
+		} else if f := s.Fields[0].String(); f != "mean(f64) AS f64" {
+			t.Fatalf("unexpected field: %s", f)
+		} else if d := s.Dimensions.String(); d != "time(1h), *" {
+			t.Fatalf("unexpected dimensions: %s", d)
+		}
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewJSONRequest("POST", "/api/v1/prom/read?db=foo", bytes.NewReader(snappy.Encode(nil, data))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure PromQL queries are evaluated over the series read from the samples
+// written by Prometheus, and answered like the Prometheus HTTP API.
+func TestHandler_PromQuery(t *testing.T) {
+	h := NewHandler(false)
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+		if !strings.HasPrefix(stmt.String(), `SELECT f64 FROM foo.._ WHERE __name__ = 'requests' AND job = 'api' AND time >= `) {
+			t.Fatalf("unexpected query: %s", stmt.String())
+		}
+		row := &models.Row{
+			Name:    "_",
+			Tags:    map[string]string{"__name__": "requests", "job": "api"},
+			Columns: []string{"time", "f64"},
+		}
+		for ts := int64(0); ts <= 420; ts += 15 {
+			row.Values = append(row.Values, []interface{}{time.Unix(ts, 0), float64(ts) * 1.5})
+		}
+		ctx.Results <- &query.Result{StatementID: 0, Series: models.Rows([]*models.Row{row})}
+		return nil
+	}
+
+	for _, tt := range []struct {
+		url  string
+		code int
+		body string
+	}{
+		{
+			url:  `/api/v1/query_range?db=foo&query=rate(requests{job="api"}[1m])&start=300&end=420&step=2m`,
+			code: http.StatusOK,
+			body: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"api"},"values":[[300,"1.5"],[420,"1.5"]]}]}}`,
+		},
+		{
+			url:  `/api/v1/query?db=foo&query=sum(requests{job="api"})&time=1970-01-01T00:05:00Z`,
+			code: http.StatusOK,
+			body: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[300,"450"]}]}}`,
+		},
+		{
+			url:  `/api/v1/query?db=foo&query=2*3&time=300.5`,
+			code: http.StatusOK,
+			body: `{"status":"success","data":{"resultType":"scalar","result":[300.5,"6"]}}`,
+		},
+		{
+			url:  `/api/v1/query?db=foo&query=rate(requests)`,
+			code: http.StatusBadRequest,
+			body: `{"status":"error","errorType":"bad_data","error":"expected type matrix in call to function \"rate\", got vector"}`,
+		},
+		{
+			url:  `/api/v1/query_range?db=foo&query=requests&start=0&end=86400&step=1`,
+			code: http.StatusBadRequest,
+			body: `{"status":"error","errorType":"bad_data","error":"exceeded maximum resolution of 11000 points per timeseries. Try decreasing the query resolution (?step=XX)"}`,
+		},
+		{
+			url:  `/api/v1/query?query=requests`,
+			code: http.StatusBadRequest,
+			body: `{"status":"error","errorType":"bad_data","error":"database is required"}`,
+		},
+	} {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.RawQuery = u.Query().Encode()
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, MustNewRequest("GET", u.String(), nil))
+		if w.Code != tt.code {
+			t.Fatalf("%s: unexpected status: %d", tt.url, w.Code)
+		} else if body := strings.TrimSpace(w.Body.String()); body != tt.body {
+			t.Fatalf("%s: unexpected body: %s", tt.url, body)
+		}
+	}
+}
+
+// Ensure continuous queries are managed with the continuous query endpoints.
+func TestHandler_ContinuousQueries(t *testing.T) {
+	h := NewHandler(false)
+	di := &meta.DatabaseInfo{Name: "foo", DefaultRetentionPolicy: "autogen"}
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		if name != "foo" {
+			return nil
+		}
+		return di
+	}
+	h.MetaClient.DatabasesFn = func() []meta.DatabaseInfo {
+		return []meta.DatabaseInfo{*di}
+	}
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+		switch stmt := stmt.(type) {
+		case *influxql.CreateContinuousQueryStatement:
+			di.ContinuousQueries = append(di.ContinuousQueries, meta.ContinuousQueryInfo{Name: stmt.Name, Query: stmt.String()})
+		case *influxql.DropContinuousQueryStatement:
+			di.ContinuousQueries = nil
+		default:
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+		ctx.Results <- &query.Result{StatementID: ctx.StatementID}
+		return nil
+	}
+	var runs []string
+	lastRun := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.Handler.ContinuousQuerier = &HandlerContinuousQuerier{
+		RunFn: func(database, name string, t time.Time) error {
+			runs = append(runs, database+"/"+name)
+			return nil
+		},
+		LastRunFn: func(database, name string) (time.Time, error) {
+			return lastRun, errors.New("shard is disabled")
+		},
+	}
+
+	const cq = `{"database":"foo","name":"cq","query":"SELECT mean(value) INTO bar FROM cpu GROUP BY time(10m)","resample":{"every":"20m"}}`
+	const status = `{"database":"foo","name":"cq","query":"CREATE CONTINUOUS QUERY cq ON foo RESAMPLE EVERY 20m BEGIN SELECT mean(value) INTO foo.autogen.bar FROM foo.autogen.cpu GROUP BY time(10m) END","lastRun":"2018-01-01T00:00:00Z","lastError":"shard is disabled"}`
+	for _, tt := range []struct {
+		method string
+		url    string
+		body   string
+		code   int
+		resp   string
+	}{
+		{method: "POST", url: "/api/v1/continuous-queries", body: cq, code: http.StatusCreated, resp: status},
+		{method: "POST", url: "/api/v1/continuous-queries", body: cq, code: http.StatusConflict, resp: `{"error":"continuous query already exists"}`},
+		{method: "PUT", url: "/api/v1/continuous-queries/foo/cq", body: cq, code: http.StatusOK, resp: status},
+		{method: "PUT", url: "/api/v1/continuous-queries/foo/cq", body: `{"query":"DROP DATABASE foo"}`, code: http.StatusBadRequest, resp: `{"error":"error parsing continuous query: query must be a SELECT statement"}`},
+		{method: "GET", url: "/api/v1/continuous-queries", code: http.StatusOK, resp: `{"continuousQueries":[` + status + `]}`},
+		{method: "GET", url: "/api/v1/continuous-queries/foo/cq", code: http.StatusOK, resp: status},
+		{method: "GET", url: "/api/v1/continuous-queries/bar/cq", code: http.StatusNotFound, resp: `{"error":"database not found"}`},
+		{method: "POST", url: "/api/v1/continuous-queries/foo/cq/run", code: http.StatusAccepted},
+		{method: "DELETE", url: "/api/v1/continuous-queries/foo/cq", code: http.StatusNoContent},
+		{method: "DELETE", url: "/api/v1/continuous-queries/foo/cq", code: http.StatusNotFound, resp: `{"error":"continuous query not found"}`},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, MustNewRequest(tt.method, tt.url, strings.NewReader(tt.body)))
+		if w.Code != tt.code {
+			t.Fatalf("%s %s: unexpected status: %d: %s", tt.method, tt.url, w.Code, w.Body.String())
+		} else if resp := strings.TrimSpace(w.Body.String()); resp != tt.resp {
+			t.Fatalf("%s %s: unexpected body: %s", tt.method, tt.url, resp)
+		}
+	}
+	if !reflect.DeepEqual(runs, []string{"foo/cq"}) {
+		t.Fatalf("unexpected runs: %v", runs)
+	}
+}
+
+// Ensure shards are managed with the shard endpoints.
+func TestHandler_Shards(t *testing.T) {
+	h := NewHandler(false)
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.MetaClient.DatabasesFn = func() []meta.DatabaseInfo {
+		return []meta.DatabaseInfo{{
+			Name: "foo",
+			RetentionPolicies: []meta.RetentionPolicyInfo{{
+				Name:     "autogen",
+				Duration: 24 * time.Hour,
+				ShardGroups: []meta.ShardGroupInfo{
+					{ID: 1, StartTime: start, EndTime: start.Add(time.Hour), Shards: []meta.ShardInfo{{ID: 2, Owners: []meta.ShardOwner{{NodeID: 1}}}}},
+					{ID: 3, StartTime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour), DeletedAt: start, Shards: []meta.ShardInfo{{ID: 4}}},
+				},
+			}},
+		}}
+	}
+	var dropped []uint64
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+		drop, ok := stmt.(*influxql.DropShardStatement)
+		if !ok {
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+		dropped = append(dropped, drop.ID)
+		ctx.Results <- &query.Result{StatementID: ctx.StatementID}
+		return nil
+	}
+	var truncated time.Time
+	h.MetaClient.TruncateShardGroupsFn = func(t time.Time) error {
+		truncated = t
+		return nil
+	}
+
+	for _, tt := range []struct {
+		method string
+		url    string
+		code   int
+		body   string
+	}{
+		{method: "GET", url: "/api/v1/shards", code: http.StatusOK, body: `{"shards":[{"id":2,"database":"foo","retentionPolicy":"autogen","shardGroup":1,"startTime":"2018-01-01T00:00:00Z","endTime":"2018-01-01T01:00:00Z","expiryTime":"2018-01-02T01:00:00Z","owners":[1]}]}`},
+		{method: "GET", url: "/api/v1/shards?db=bar", code: http.StatusOK, body: `{"shards":[]}`},
+		{method: "DELETE", url: "/api/v1/shards/2", code: http.StatusNoContent},
+		{method: "DELETE", url: "/api/v1/shards/4", code: http.StatusNotFound, body: `{"error":"shard not found"}`},
+		{method: "POST", url: "/api/v1/shards/truncate?time=2018-01-01T00:30:00Z", code: http.StatusNoContent},
+		{method: "POST", url: "/api/v1/shards/2/compact", code: http.StatusNotFound, body: `{"error":"shard not found"}`},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, MustNewRequest(tt.method, tt.url, nil))
+		if w.Code != tt.code {
+			t.Fatalf("%s %s: unexpected status: %d: %s", tt.method, tt.url, w.Code, w.Body.String())
+		} else if body := strings.TrimSpace(w.Body.String()); body != tt.body {
+			t.Fatalf("%s %s: unexpected body: %s", tt.method, tt.url, body)
+		}
+	}
+	if !reflect.DeepEqual(dropped, []uint64{2}) {
+		t.Fatalf("unexpected dropped shards: %v", dropped)
+	} else if !truncated.Equal(start.Add(30 * time.Minute)) {
+		t.Fatalf("unexpected truncation time: %s", truncated)
+	}
+}
+
+// Ensure the query limits of users are shown and set with the user limits
+// endpoints.
+func TestHandler_UserLimits(t *testing.T) {
+	h := NewHandler(false)
+	users := map[string]*meta.UserInfo{"alice": {Name: "alice", Limits: meta.UserLimits{MaxSelectPointN: 1000}}}
+	h.MetaClient.UserFn = func(username string) (meta.User, error) {
+		if u, ok := users[username]; ok {
+			return u, nil
+		}
+		return nil, meta.ErrUserNotFound
+	}
+	h.MetaClient.SetUserLimitsFn = func(username string, limits meta.UserLimits) error {
+		u, ok := users[username]
+		if !ok {
+			return meta.ErrUserNotFound
+		}
+		u.Limits = limits
+		return nil
+	}
+
+	for _, tt := range []struct {
+		method string
+		url    string
+		body   string
+		code   int
+		exp    string
+	}{
+		{method: "GET", url: "/api/v1/users/alice/limits", code: http.StatusOK, exp: `{"maxSelectPoint":1000}`},
+		{method: "GET", url: "/api/v1/users/bob/limits", code: http.StatusNotFound, exp: `{"error":"user not found"}`},
+		{method: "PUT", url: "/api/v1/users/alice/limits", body: `{"maxSelectSeries":10,"maxRequestMemory":1048576}`, code: http.StatusNoContent},
+		{method: "GET", url: "/api/v1/users/alice/limits", code: http.StatusOK, exp: `{"maxSelectSeries":10,"maxRequestMemory":1048576}`},
+		{method: "PUT", url: "/api/v1/users/alice/limits", body: `{"maxSelectBuckets":-1}`, code: http.StatusBadRequest, exp: `{"error":"limits must not be negative"}`},
+		{method: "PUT", url: "/api/v1/users/bob/limits", body: `{}`, code: http.StatusNotFound, exp: `{"error":"user not found"}`},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, MustNewRequest(tt.method, tt.url, strings.NewReader(tt.body)))
+		if w.Code != tt.code {
+			t.Fatalf("%s %s: unexpected status: %d: %s", tt.method, tt.url, w.Code, w.Body.String())
+		} else if body := strings.TrimSpace(w.Body.String()); body != tt.exp {
+			t.Fatalf("%s %s: unexpected body: %s", tt.method, tt.url, body)
+		}
+	}
+}
+
+// Ensure points are deleted in the background with the delete endpoint.
+func TestHandler_Delete(t *testing.T) {
+	h := NewHandler(false)
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		if name != "foo" {
+			return nil
+		}
+		return &meta.DatabaseInfo{Name: name}
+	}
+	var mu sync.Mutex
+	var deletes []string
+	h.StatementExecutor.ExecuteStatementFn = func(stmt influxql.Statement, ctx query.ExecutionContext) error {
+		switch stmt := stmt.(type) {
+		case *influxql.ShowMeasurementsStatement:
+			if stmt.Condition.String() != `host = 'a'` {
+				t.Errorf("unexpected condition: %s", stmt.Condition)
+			}
+			ctx.Results <- &query.Result{StatementID: ctx.StatementID, Series: models.Rows{{
+				Name:    "measurements",
+				Columns: []string{"name"},
+				Values:  [][]interface{}{{"cpu"}, {"mem"}},
+			}}}
+		case *influxql.DeleteSeriesStatement:
+			mu.Lock()
+			deletes = append(deletes, stmt.String())
+			mu.Unlock()
+			ctx.Results <- &query.Result{StatementID: ctx.StatementID}
+		default:
+			t.Errorf("unexpected statement: %s", stmt)
+		}
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/api/v2/delete?db=foo", strings.NewReader(`{"start":"2018-01-01T00:00:00Z","stop":"2018-01-02T00:00:00Z","predicate":"host=\"a\""}`)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	}
+	var status struct {
+		ID           string `json:"id"`
+		Status       string `json:"status"`
+		Measurements int    `json:"measurements"`
+		Deleted      int    `json:"deleted"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	} else if loc := w.Header().Get("Location"); loc != "/api/v2/delete/"+status.ID {
+		t.Fatalf("unexpected location: %s", loc)
+	}
+
+	// Wait for the delete to finish.
+	for timeout := time.Now().Add(5 * time.Second); status.Status == "running"; {
+		if time.Now().After(timeout) {
+			t.Fatal("delete did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, MustNewRequest("GET", "/api/v2/delete/"+status.ID, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+		} else if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status.Status != "completed" || status.Measurements != 2 || status.Deleted != 2 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(deletes, []string{
+		`DELETE FROM cpu WHERE time >= '2018-01-01T00:00:00Z' AND time <= '2018-01-02T00:00:00Z' AND host = 'a'`,
+		`DELETE FROM mem WHERE time >= '2018-01-01T00:00:00Z' AND time <= '2018-01-02T00:00:00Z' AND host = 'a'`,
+	}) {
+		t.Fatalf("unexpected deletes: %q", deletes)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("DELETE", "/api/v2/delete/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
 // Ensure the handler handles ping requests correctly.
 // TODO: This should be expanded to verify the MetaClient check in servePing is working correctly
 func TestHandler_Ping(t *testing.T) {
@@ -675,6 +1301,47 @@ func TestHandler_Ping(t *testing.T) {
 	}
 }
 
+// Ensure the handler reports the health of the subsystems, failing on failed
+// subsystems and, in strict mode, on degraded ones.
+func TestHandler_Health(t *testing.T) {
+	h := NewHandler(false)
+	checks := []httpd.HealthCheck{
+		{Name: "meta", Status: httpd.HealthPass},
+		{Name: "wal", Status: httpd.HealthWarn, Message: "write errors"},
+	}
+	h.Handler.Health = HandlerHealth(func() []httpd.HealthCheck { return checks })
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	var resp struct {
+		Status string              `json:"status"`
+		Checks []httpd.HealthCheck `json:"checks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	} else if resp.Status != httpd.HealthWarn || len(resp.Checks) != 2 || resp.Checks[1].Message != "write errors" {
+		t.Fatalf("unexpected response: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("GET", "/health?strict=true", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	checks = append(checks, httpd.HealthCheck{Name: "shard", Status: httpd.HealthFail})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("HEAD", "/health", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Body.Len() != 0 {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
 // Ensure the handler returns the version correctly from the different endpoints.
 func TestHandler_Version(t *testing.T) {
 	h := NewHandler(false)
@@ -817,6 +1484,276 @@ func TestHandler_Write_NegativeMaxBodySize(t *testing.T) {
 	}
 }
 
+// Ensure writes exceeding the points per second of a database quota are
+// rejected, and the client is told when to retry.
+func TestHandler_Write_Quota(t *testing.T) {
+	config := httpd.NewConfig()
+	config.DatabaseQuota.PointsPerSecond = 2
+	h := NewHandlerWithConfig(config)
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		return &meta.DatabaseInfo{}
+	}
+	h.PointsWriter.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, _ meta.User, _ []models.Point) error {
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo", strings.NewReader("cpu value=1\ncpu value=2")))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo", strings.NewReader("cpu value=3")))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("unexpected Retry-After: %q", w.Header().Get("Retry-After"))
+	}
+
+	// Other databases have their own quota.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=bar", strings.NewReader("cpu value=3")))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure writes exceeding the bytes per second of a database quota are
+// rejected.
+func TestHandler_Write_QuotaBytes(t *testing.T) {
+	config := httpd.NewConfig()
+	config.DatabaseQuotas = map[string]httpd.QuotaConfig{"foo": {BytesPerSecond: 16}}
+	h := NewHandlerWithConfig(config)
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		return &meta.DatabaseInfo{}
+	}
+	h.PointsWriter.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, _ meta.User, _ []models.Point) error {
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo", strings.NewReader("cpu value=1")))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo", strings.NewReader("cpu value=2")))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if !strings.Contains(w.Body.String(), "11 bytes") {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+
+	// Other databases are unlimited.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=bar", strings.NewReader("cpu value=2")))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure writes beyond the limit of concurrent write requests are rejected
+// while the queue of waiting requests is full.
+func TestHandler_Write_ConcurrentWriteLimit(t *testing.T) {
+	config := httpd.NewConfig()
+	config.MaxConcurrentWriteLimit = 1
+	h := NewHandlerWithConfig(config)
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		return &meta.DatabaseInfo{}
+	}
+	writing, unblock := make(chan struct{}), make(chan struct{})
+	h.PointsWriter.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, _ meta.User, _ []models.Point) error {
+		writing <- struct{}{}
+		<-unblock
+		return nil
+	}
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo", strings.NewReader("cpu value=1")))
+		done <- w.Code
+	}()
+	<-writing
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo", strings.NewReader("cpu value=2")))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	close(unblock)
+	if code := <-done; code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", code)
+	}
+}
+
+// Ensure a dry run reports the errors of each line without writing points.
+func TestHandler_Write_DryRun(t *testing.T) {
+	h := NewHandler(false)
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		return &meta.DatabaseInfo{}
+	}
+	h.PointsWriter.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, _ meta.User, _ []models.Point) error {
+		t.Fatal("unexpected write")
+		return nil
+	}
+	h.PointsWriter.ValidatePointsFn = func(db, rp string, points []models.Point) (map[int]error, error) {
+		if db != "foo" || rp != "bar" {
+			t.Fatalf("unexpected db/rp: %s/%s", db, rp)
+		} else if len(points) != 2 {
+			t.Fatalf("unexpected points: %v", points)
+		}
+		return map[int]error{1: errors.New("field type conflict")}, nil
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo&rp=bar&dry-run=true", strings.NewReader("cpu value=1\ncpu value=\ncpu value=\"x\"")))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); !strings.HasPrefix(body, `{"points":3,"valid":1,"errors":[{"line":2,`) || !strings.HasSuffix(body, `{"line":3,"error":"field type conflict"}]}`) {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	// Valid points are reported without errors.
+	h.PointsWriter.ValidatePointsFn = nil
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo&dry-run=true", strings.NewReader("cpu value=1")))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"points":1,"valid":1,"errors":[]}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
+// Ensure writes exceeding the memory of a request fail.
+func TestHandler_Write_MaxRequestMemory(t *testing.T) {
+	config := httpd.NewConfig()
+	config.MaxRequestMemory = 20
+	h := NewHandlerWithConfig(config)
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		return &meta.DatabaseInfo{}
+	}
+	h.PointsWriter.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, _ meta.User, _ []models.Point) error {
+		t.Fatal("unexpected write")
+		return nil
+	}
+
+	// The body exceeds the memory.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo", strings.NewReader("cpu value=1\ncpu value=2")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"error":"max-request-memory limit exceeded: (23/20)"}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	// The body and its points exceed the memory.
+	h.Config.MaxRequestMemory = 300
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo", strings.NewReader("cpu value=1\ncpu value=2")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"error":"max-request-memory limit exceeded: (407/300)"}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
+// Ensure points are written in the JSON write format.
+func TestHandler_Write_JSON(t *testing.T) {
+	h := NewHandler(false)
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		return &meta.DatabaseInfo{}
+	}
+	var written []string
+	h.PointsWriter.WritePointsFn = func(db, rp string, _ models.ConsistencyLevel, _ meta.User, points []models.Point) error {
+		if db != "foo" || rp != "bar" {
+			t.Fatalf("unexpected db/rp: %s/%s", db, rp)
+		}
+		for _, pt := range points {
+			written = append(written, pt.String())
+		}
+		return nil
+	}
+
+	body := `{"measurement":"cpu","tags":{"host":"a"},"fields":{"value":[1,2]},"time":[1514764800,1514764810]}`
+	w := httptest.NewRecorder()
+	r := MustNewRequest("POST", "/write?db=foo&rp=bar&precision=s", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	} else if !reflect.DeepEqual(written, []string{"cpu,host=a value=1 1514764800000000000", "cpu,host=a value=2 1514764810000000000"}) {
+		t.Fatalf("unexpected points: %q", written)
+	}
+
+	// Invalid points are not written at all.
+	written = nil
+	w = httptest.NewRecorder()
+	r = MustNewRequest("POST", "/write?db=foo&rp=bar", strings.NewReader(`[{"measurement":"cpu","fields":{"value":[1]}},{"fields":{"value":[1]}}]`))
+	r.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"error":"series 1: missing measurement"}` {
+		t.Fatalf("unexpected body: %s", body)
+	} else if written != nil {
+		t.Fatalf("unexpected points: %q", written)
+	}
+}
+
+// Ensure writes with ack=enqueued are accepted once enqueued, and written in
+// the background.
+func TestHandler_Write_AckEnqueued(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpd-intake-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := httpd.NewConfig()
+	config.WriteIntake.Enabled = true
+	config.WriteIntake.Dir = dir
+	h := NewHandlerWithConfig(config)
+	h.MetaClient.DatabaseFn = func(name string) *meta.DatabaseInfo {
+		return &meta.DatabaseInfo{}
+	}
+	written := make(chan string, 1)
+	h.PointsWriter.WritePointsFn = func(db, rp string, _ models.ConsistencyLevel, _ meta.User, points []models.Point) error {
+		if db != "foo" || rp != "bar" || len(points) != 1 {
+			t.Errorf("unexpected write: %s/%s %v", db, rp, points)
+		}
+		written <- points[0].String()
+		return nil
+	}
+	h.Open()
+	defer h.Close()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo&rp=bar&ack=enqueued", strings.NewReader("cpu value=1 10")))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status: %d: %s", w.Code, w.Body.String())
+	}
+	select {
+	case s := <-written:
+		if s != "cpu value=1 10" {
+			t.Fatalf("unexpected point: %s", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for write")
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, MustNewRequest("POST", "/write?db=foo&ack=later", strings.NewReader("cpu value=1 10")))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"error":"invalid ack \"later\": must be applied or enqueued"}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
 // Ensure X-Forwarded-For header writes the correct log message.
 func TestHandler_XForwardedFor(t *testing.T) {
 	var buf bytes.Buffer
@@ -909,12 +1846,21 @@ type Handler struct {
 	PointsWriter      HandlerPointsWriter
 }
 
+// HandlerHealth is a mock implementation of Handler.Health.
+type HandlerHealth func() []httpd.HealthCheck
+
+func (fn HandlerHealth) Health() []httpd.HealthCheck { return fn() }
+
 // NewHandler returns a new instance of Handler.
 func NewHandler(requireAuthentication bool) *Handler {
 	config := httpd.NewConfig()
 	config.AuthEnabled = requireAuthentication
 	config.SharedSecret = "super secret key"
+	return NewHandlerWithConfig(config)
+}
 
+// NewHandlerWithConfig returns a new instance of Handler with config.
+func NewHandlerWithConfig(config httpd.Config) *Handler {
 	h := &Handler{
 		Handler: httpd.NewHandler(config),
 	}
@@ -931,6 +1877,20 @@ func NewHandler(requireAuthentication bool) *Handler {
 	return h
 }
 
+// HandlerContinuousQuerier is a mock implementation of Handler.ContinuousQuerier.
+type HandlerContinuousQuerier struct {
+	RunFn     func(database, name string, t time.Time) error
+	LastRunFn func(database, name string) (time.Time, error)
+}
+
+func (c *HandlerContinuousQuerier) Run(database, name string, t time.Time) error {
+	return c.RunFn(database, name, t)
+}
+
+func (c *HandlerContinuousQuerier) LastRun(database, name string) (time.Time, error) {
+	return c.LastRunFn(database, name)
+}
+
 // HandlerStatementExecutor is a mock implementation of Handler.StatementExecutor.
 type HandlerStatementExecutor struct {
 	ExecuteStatementFn func(stmt influxql.Statement, ctx query.ExecutionContext) error
@@ -950,13 +1910,21 @@ func (a *HandlerQueryAuthorizer) AuthorizeQuery(u meta.User, query *influxql.Que
 }
 
 type HandlerPointsWriter struct {
-	WritePointsFn func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error
+	WritePointsFn    func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error
+	ValidatePointsFn func(database, retentionPolicy string, points []models.Point) (map[int]error, error)
 }
 
 func (h *HandlerPointsWriter) WritePoints(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, user meta.User, points []models.Point) error {
 	return h.WritePointsFn(database, retentionPolicy, consistencyLevel, user, points)
 }
 
+func (h *HandlerPointsWriter) ValidatePoints(database, retentionPolicy string, points []models.Point) (map[int]error, error) {
+	if h.ValidatePointsFn == nil {
+		return nil, nil
+	}
+	return h.ValidatePointsFn(database, retentionPolicy, points)
+}
+
 // MustNewRequest returns a new HTTP request. Panic on error.
 func MustNewRequest(method, urlStr string, body io.Reader) *http.Request {
 	r, err := http.NewRequest(method, urlStr, body)
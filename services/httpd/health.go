@@ -0,0 +1,73 @@
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Statuses of health checks, from the best to the worst.
+const (
+	HealthPass = "pass"
+	HealthWarn = "warn"
+	HealthFail = "fail"
+)
+
+// HealthCheck is the health of a subsystem of the server. Degraded
+// subsystems warn, and failed ones fail.
+type HealthCheck struct {
+	Name    string                 `json:"name"`
+	Status  string                 `json:"status"`
+	Message string                 `json:"message,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// healthRank returns the rank of status, higher for worse statuses.
+func healthRank(status string) int {
+	switch status {
+	case HealthPass:
+		return 0
+	case HealthWarn:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// serveHealth returns the health of the server and of its subsystems. The
+// response is 503 Service Unavailable if a subsystem failed or, with
+// strict=true, if any is degraded.
+func (h *Handler) serveHealth(w http.ResponseWriter, r *http.Request) {
+	var checks []HealthCheck
+	if h.Health != nil {
+		checks = h.Health.Health()
+	}
+
+	status := HealthPass
+	for _, c := range checks {
+		if healthRank(c.Status) > healthRank(status) {
+			status = c.Status
+		}
+	}
+
+	code := http.StatusOK
+	if status == HealthFail || (status != HealthPass && r.URL.Query().Get("strict") == "true") {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	h.writeHeader(w, code)
+	if r.Method == "HEAD" {
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		Name    string        `json:"name"`
+		Version string        `json:"version"`
+		Status  string        `json:"status"`
+		Checks  []HealthCheck `json:"checks"`
+	}{
+		Name:    "influxdb",
+		Version: h.Version,
+		Status:  status,
+		Checks:  checks,
+	})
+}
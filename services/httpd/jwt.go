@@ -0,0 +1,313 @@
+package httpd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// jwksMinRefreshInterval limits how often the keys of the JWKS URL are
+	// fetched for tokens signed with an unknown key ID.
+	jwksMinRefreshInterval = 10 * time.Second
+
+	// jwksTimeout is the time allowed to fetch the keys of the JWKS URL.
+	jwksTimeout = 10 * time.Second
+
+	// jwksMaxSize is the size of the largest key set accepted.
+	jwksMaxSize = 1024 * 1024
+)
+
+// parseJWT parses and validates a token signed with one of the keys of the
+// handler.
+func (h *Handler) parseJWT(s string) (*jwt.Token, error) {
+	return parseJWT(s, h.jwtKeys)
+}
+
+// parseJWT parses and validates a token signed with one of the keys
+// returned by keysFn. The keys are tried in order, so that tokens signed
+// with an old key keep working while the keys are rotated.
+func parseJWT(s string, keysFn func(*jwt.Token) ([]interface{}, error)) (*jwt.Token, error) {
+	var keys []interface{}
+	token, err := jwt.Parse(s, func(token *jwt.Token) (interface{}, error) {
+		var err error
+		keys, err = keysFn(token)
+		if err != nil {
+			return nil, err
+		}
+		return keys[0], nil
+	})
+	for i := 1; i < len(keys) && signatureInvalid(err); i++ {
+		key := keys[i]
+		token, err = jwt.Parse(s, func(*jwt.Token) (interface{}, error) { return key, nil })
+	}
+	return token, err
+}
+
+// signatureInvalid returns true if err is a failure to verify the signature
+// of a token with a key.
+func signatureInvalid(err error) bool {
+	e, ok := err.(*jwt.ValidationError)
+	return ok && e.Errors&jwt.ValidationErrorSignatureInvalid != 0
+}
+
+// jwtKeys returns the keys token may be signed with. HMAC tokens may be
+// signed with the shared secrets, in the order they are configured, or with
+// the symmetric keys of the JWKS URL. RSA and ECDSA tokens may be signed
+// with the keys of the JWKS URL. If the token names a key ID, only the key
+// of the JWKS URL with that ID is returned.
+func (h *Handler) jwtKeys(token *jwt.Token) ([]interface{}, error) {
+	var keys []interface{}
+	hmac := false
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		hmac = true
+		if h.Config.SharedSecret != "" {
+			keys = append(keys, []byte(h.Config.SharedSecret))
+		}
+		for _, secret := range h.Config.SharedSecrets {
+			if secret != "" {
+				keys = append(keys, []byte(secret))
+			}
+		}
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	if h.jwks != nil {
+		kid, _ := token.Header["kid"].(string)
+		jwks, err := h.jwks.lookup(kid)
+		if err != nil {
+			h.Logger.Info("Failed to fetch JWKS", zap.String("url", h.jwks.url), zap.Error(err))
+		}
+		for _, k := range jwks {
+			if _, ok := k.([]byte); ok == hmac {
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key to verify token signed with %v", token.Header["alg"])
+	}
+	return keys, nil
+}
+
+// jwk is a key of a JSON Web Key Set, as defined by RFC 7517 and RFC 7518.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// Elliptic curve keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	// Symmetric keys.
+	K string `json:"k"`
+}
+
+// key returns the public key of k: an *rsa.PublicKey, an *ecdsa.PublicKey or
+// the []byte of a symmetric key.
+func (k *jwk) key() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		} else if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		} else if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "oct":
+		b, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("invalid symmetric key")
+		}
+		return b, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// jwks caches the keys of a JWKS URL. The keys are fetched when they are
+// first needed, and again once they are older than the refresh interval or
+// when a token names a key ID they lack. The cached keys are kept when
+// fetching fails.
+type jwks struct {
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	// minRefreshInterval limits how often keys are fetched for unknown key
+	// IDs.
+	minRefreshInterval time.Duration
+
+	fetchMu sync.Mutex // Serializes fetches.
+
+	mu      sync.RWMutex
+	keys    []jwksKey
+	fetched time.Time // Time of the last fetch, even if it failed.
+}
+
+// jwksKey is a key of a JWKS with its ID.
+type jwksKey struct {
+	kid string
+	key interface{}
+}
+
+func newJWKS(url string, refreshInterval time.Duration) *jwks {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultJWKSRefreshInterval
+	}
+	return &jwks{
+		url:                url,
+		refreshInterval:    refreshInterval,
+		client:             &http.Client{Timeout: jwksTimeout},
+		minRefreshInterval: jwksMinRefreshInterval,
+	}
+}
+
+// lookup returns the keys with kid, or all keys if kid is empty. The cached
+// keys are returned along with any error fetching them.
+func (j *jwks) lookup(kid string) ([]interface{}, error) {
+	keys, fetched := j.cached()
+	var err error
+	if age := time.Since(fetched); age >= j.refreshInterval ||
+		(kid != "" && !hasKeyID(keys, kid) && age >= j.minRefreshInterval) {
+		err = j.refresh(fetched)
+		keys, _ = j.cached()
+	}
+
+	var a []interface{}
+	for _, k := range keys {
+		if kid == "" || k.kid == kid {
+			a = append(a, k.key)
+		}
+	}
+	return a, err
+}
+
+func (j *jwks) cached() ([]jwksKey, time.Time) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.keys, j.fetched
+}
+
+func hasKeyID(keys []jwksKey, kid string) bool {
+	for _, k := range keys {
+		if k.kid == kid {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh fetches the keys, unless they were fetched since prev.
+func (j *jwks) refresh(prev time.Time) error {
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+	if _, fetched := j.cached(); !fetched.Equal(prev) {
+		return nil // Fetched while waiting.
+	}
+
+	keys, err := j.fetch()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.fetched = time.Now()
+	if err != nil {
+		return err
+	}
+	j.keys = keys
+	return nil
+}
+
+// fetch returns the keys of the URL. Keys that are not used for signatures,
+// or whose type is not supported, are left out.
+func (j *jwks) fetch() ([]jwksKey, error) {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, jwksMaxSize)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid key set: %s", err)
+	}
+
+	var keys []jwksKey
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.key()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, jwksKey{kid: k.Kid, key: key})
+	}
+	return keys, nil
+}
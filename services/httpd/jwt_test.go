@@ -0,0 +1,211 @@
+package httpd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Ensure tokens signed with any of the shared secrets are accepted.
+func TestHandler_ParseJWT_SharedSecrets(t *testing.T) {
+	c := NewConfig()
+	c.SharedSecret = "new key"
+	c.SharedSecrets = []string{"old key", "older key"}
+	h := NewHandler(c)
+
+	for _, secret := range []string{"new key", "old key", "older key"} {
+		if _, err := h.parseJWT(testJWT(t, jwt.SigningMethodHS256, "", []byte(secret), false)); err != nil {
+			t.Errorf("%s: %s", secret, err)
+		}
+	}
+
+	if _, err := h.parseJWT(testJWT(t, jwt.SigningMethodHS256, "", []byte("invalid key"), false)); err == nil || err.Error() != "signature is invalid" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Claims are still validated with the key that signed the token.
+	if _, err := h.parseJWT(testJWT(t, jwt.SigningMethodHS256, "", []byte("old key"), true)); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// RSA tokens cannot be verified without a JWKS URL.
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.parseJWT(testJWT(t, jwt.SigningMethodRS256, "", key, false)); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// Ensure tokens are verified with the keys of the JWKS URL, which are fetched
+// again for unknown key IDs.
+func TestHandler_ParseJWT_JWKS(t *testing.T) {
+	rsaKey1, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey2, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewTestJWKSServer()
+	defer srv.Close()
+	srv.SetKeys(
+		map[string]interface{}{"kty": "RSA", "kid": "rsa1", "use": "sig", "n": b64(rsaKey1.N), "e": b64(big.NewInt(int64(rsaKey1.E)))},
+		map[string]interface{}{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+		map[string]interface{}{"kty": "oct", "kid": "hmac1", "k": base64.RawURLEncoding.EncodeToString([]byte("jwks secret"))},
+		map[string]interface{}{"kty": "RSA", "kid": "enc1", "use": "enc", "n": b64(rsaKey2.N), "e": b64(big.NewInt(int64(rsaKey2.E)))},
+	)
+
+	c := NewConfig()
+	c.SharedSecret = "shared secret"
+	c.JWKSURL = srv.URL
+	h := NewHandler(c)
+	h.jwks.minRefreshInterval = 0
+
+	for _, tt := range []struct {
+		method jwt.SigningMethod
+		kid    string
+		key    interface{}
+	}{
+		{method: jwt.SigningMethodRS256, kid: "rsa1", key: rsaKey1},
+		{method: jwt.SigningMethodRS512, key: rsaKey1},
+		{method: jwt.SigningMethodPS256, kid: "rsa1", key: rsaKey1},
+		{method: jwt.SigningMethodES256, kid: "ec1", key: ecKey},
+		{method: jwt.SigningMethodES256, key: ecKey},
+		{method: jwt.SigningMethodHS256, kid: "hmac1", key: []byte("jwks secret")},
+		{method: jwt.SigningMethodHS256, key: []byte("shared secret")},
+	} {
+		if _, err := h.parseJWT(testJWT(t, tt.method, tt.kid, tt.key, false)); err != nil {
+			t.Errorf("%s %q: %s", tt.method.Alg(), tt.kid, err)
+		}
+	}
+	if n := srv.Fetches(); n != 1 {
+		t.Fatalf("unexpected number of fetches: %d", n)
+	}
+
+	// Keys not used for signatures are ignored, and keys are only used for
+	// their own key ID.
+	for _, kid := range []string{"enc1", "ec1"} {
+		if _, err := h.parseJWT(testJWT(t, jwt.SigningMethodRS256, kid, rsaKey2, false)); err == nil {
+			t.Errorf("%s: expected error", kid)
+		}
+	}
+
+	// Rotate the RSA key. Tokens with the new key ID are accepted once the
+	// keys are fetched again.
+	srv.SetKeys(map[string]interface{}{"kty": "RSA", "kid": "rsa2", "n": b64(rsaKey2.N), "e": b64(big.NewInt(int64(rsaKey2.E)))})
+	if _, err := h.parseJWT(testJWT(t, jwt.SigningMethodRS256, "rsa2", rsaKey2, false)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.parseJWT(testJWT(t, jwt.SigningMethodRS256, "rsa1", rsaKey1, false)); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// The cached keys are kept when fetching fails.
+	srv.SetStatus(http.StatusInternalServerError)
+	h.jwks.refreshInterval = 0
+	if _, err := h.parseJWT(testJWT(t, jwt.SigningMethodRS256, "rsa2", rsaKey2, false)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJWK_Key_Invalid(t *testing.T) {
+	for _, k := range []jwk{
+		{Kty: "RSA", N: "AQAB"},
+		{Kty: "RSA", N: "AQAB", E: "AQ"},
+		{Kty: "RSA", N: "!", E: "AQAB"},
+		{Kty: "EC", Crv: "P-256", X: "AQ", Y: "AQ"},
+		{Kty: "EC", Crv: "secp256k1", X: "AQ", Y: "AQ"},
+		{Kty: "oct"},
+		{Kty: "OKP"},
+	} {
+		if _, err := k.key(); err == nil {
+			t.Errorf("%+v: expected error", k)
+		}
+	}
+}
+
+// testJWT returns a token for user1 signed with key.
+func testJWT(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, expired bool) string {
+	token := jwt.New(method)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	token.Claims.(jwt.MapClaims)["username"] = "user1"
+	if expired {
+		token.Claims.(jwt.MapClaims)["exp"] = time.Now().Add(-time.Second).Unix()
+	} else {
+		token.Claims.(jwt.MapClaims)["exp"] = time.Now().Add(10 * time.Minute).Unix()
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// TestJWKSServer serves a JSON Web Key Set.
+type TestJWKSServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    []map[string]interface{}
+	status  int
+	fetches int
+}
+
+func NewTestJWKSServer() *TestJWKSServer {
+	s := &TestJWKSServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		w.WriteHeader(s.status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+	}))
+	return s
+}
+
+// SetKeys replaces the keys served.
+func (s *TestJWKSServer) SetKeys(keys ...map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+// SetStatus sets the status of the responses.
+func (s *TestJWKSServer) SetStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// Fetches returns the number of times the keys were fetched.
+func (s *TestJWKSServer) Fetches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
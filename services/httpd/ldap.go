@@ -0,0 +1,308 @@
+package httpd
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/pkg/ldap"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxql"
+)
+
+// ldapConn is a connection to the LDAP directory.
+type ldapConn interface {
+	Bind(dn, password string) error
+	Search(req *ldap.SearchRequest) ([]*ldap.Entry, error)
+	Close() error
+}
+
+// ldapUser is a user of the LDAP directory. Its privileges come from its
+// groups rather than the meta store, so it is authorized on its own.
+type ldapUser struct {
+	meta.UserInfo
+}
+
+// ldapAuthenticator authenticates users against an LDAP directory. The
+// entry of a user is searched as the configured account, and its password
+// is verified by binding as the entry. Connections are kept for reuse.
+type ldapAuthenticator struct {
+	config LDAPConfig
+	dial   func() (ldapConn, error)
+	idle   chan ldapConn
+}
+
+func newLDAPAuthenticator(c LDAPConfig) *ldapAuthenticator {
+	a := &ldapAuthenticator{
+		config: c,
+		idle:   make(chan ldapConn, c.MaxIdleConnections),
+	}
+	a.dial = a.dialDirectory
+	return a
+}
+
+// authenticate returns the user with username if password is its password
+// in the directory. meta.ErrAuthenticate is returned if the user does not
+// exist or the password is wrong.
+func (a *ldapAuthenticator) authenticate(username, password string) (meta.User, error) {
+	// An empty password would request an unauthenticated bind, which
+	// directories accept for any entry.
+	if password == "" {
+		return nil, meta.ErrAuthenticate
+	}
+
+	for {
+		c, reused, err := a.conn()
+		if err != nil {
+			return nil, err
+		}
+		groups, err := a.groups(c, username, password)
+		if _, ok := err.(*ldap.Error); ok || err == nil || err == meta.ErrAuthenticate {
+			a.release(c)
+			if err != nil {
+				return nil, err
+			}
+			return a.user(username, groups)
+		}
+
+		// The connection failed. Idle connections may have been closed by
+		// the directory, so try again with another one.
+		c.Close()
+		if !reused {
+			return nil, err
+		}
+	}
+}
+
+// groups verifies the password of the user over c, and returns the DNs of
+// its groups.
+func (a *ldapAuthenticator) groups(c ldapConn, username, password string) ([]string, error) {
+	if err := c.Bind(a.config.BindDN, a.config.BindPassword); err != nil {
+		return nil, err
+	}
+
+	attrs := []string{"1.1"} // No attributes.
+	if a.config.GroupSearchFilter == "" {
+		attrs = []string{a.config.GroupAttribute}
+	}
+	entries, err := c.Search(&ldap.SearchRequest{
+		BaseDN:     a.config.SearchBaseDN,
+		Scope:      ldap.ScopeWholeSubtree,
+		Filter:     ldapFilter(a.config.SearchFilter, username, ""),
+		Attributes: attrs,
+		SizeLimit:  2,
+	})
+	if err != nil && !ldap.IsResultCode(err, ldap.ResultSizeLimitExceeded) {
+		return nil, err
+	} else if len(entries) != 1 {
+		// The user does not exist, or the filter is ambiguous.
+		return nil, meta.ErrAuthenticate
+	}
+	entry := entries[0]
+
+	if err := c.Bind(entry.DN, password); ldap.IsResultCode(err, ldap.ResultInvalidCredentials) {
+		return nil, meta.ErrAuthenticate
+	} else if err != nil {
+		return nil, err
+	}
+
+	if a.config.GroupSearchFilter == "" {
+		return entry.Values(a.config.GroupAttribute), nil
+	}
+
+	// Users may not be allowed to search the groups themselves.
+	if err := c.Bind(a.config.BindDN, a.config.BindPassword); err != nil {
+		return nil, err
+	}
+	entries, err = c.Search(&ldap.SearchRequest{
+		BaseDN:     a.config.GroupSearchBaseDN,
+		Scope:      ldap.ScopeWholeSubtree,
+		Filter:     ldapFilter(a.config.GroupSearchFilter, username, entry.DN),
+		Attributes: []string{"1.1"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, len(entries))
+	for i, e := range entries {
+		groups[i] = e.DN
+	}
+	return groups, nil
+}
+
+// user returns the user with the privileges its groups grant.
+func (a *ldapAuthenticator) user(username string, groups []string) (meta.User, error) {
+	u := &ldapUser{UserInfo: meta.UserInfo{
+		Name:       username,
+		Privileges: make(map[string]influxql.Privilege),
+	}}
+	member := false
+	for _, g := range a.config.Groups {
+		if !containsDN(groups, g.DN) {
+			continue
+		}
+		member = true
+		if g.Admin {
+			u.Admin = true
+		} else if p, err := parseLDAPPrivilege(g.Privilege); err == nil {
+			u.Privileges[g.Database] = mergePrivileges(u.Privileges[g.Database], p)
+		}
+	}
+	if !member {
+		return nil, fmt.Errorf("user %q is not a member of any configured group", username)
+	}
+	return u, nil
+}
+
+// conn returns an idle connection, or a new one. reused is true if the
+// connection was idle.
+func (a *ldapAuthenticator) conn() (c ldapConn, reused bool, err error) {
+	select {
+	case c := <-a.idle:
+		return c, true, nil
+	default:
+	}
+	c, err = a.dial()
+	return c, false, err
+}
+
+// release keeps c for reuse, or closes it if enough connections are idle.
+func (a *ldapAuthenticator) release(c ldapConn) {
+	select {
+	case a.idle <- c:
+	default:
+		c.Close()
+	}
+}
+
+// close closes the idle connections.
+func (a *ldapAuthenticator) close() {
+	for {
+		select {
+		case c := <-a.idle:
+			c.Close()
+		default:
+			return
+		}
+	}
+}
+
+// dialDirectory connects to the directory of the URL.
+func (a *ldapAuthenticator) dialDirectory() (ldapConn, error) {
+	u, err := url.Parse(a.config.URL)
+	if err != nil {
+		return nil, err
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "ldaps" {
+			addr = net.JoinHostPort(u.Hostname(), "636")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "389")
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if u.Scheme == "ldaps" || a.config.StartTLS {
+		if tlsConfig, err = a.tlsConfig(u.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := time.Duration(a.config.Timeout)
+	if u.Scheme == "ldaps" {
+		c, err := ldap.DialTLS(addr, tlsConfig, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	c, err := ldap.Dial(addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if a.config.StartTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// tlsConfig returns the TLS configuration used to connect to host.
+func (a *ldapAuthenticator) tlsConfig(host string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: a.config.InsecureSkipVerify,
+	}
+	if a.config.TLSCA != "" {
+		pool, err := loadCertPool(a.config.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// ldapFilter replaces {username} and {dn} in filter by the escaped username
+// and dn.
+func ldapFilter(filter, username, dn string) string {
+	return strings.NewReplacer(
+		"{username}", ldap.EscapeFilter(username),
+		"{dn}", ldap.EscapeFilter(dn),
+	).Replace(filter)
+}
+
+// containsDN returns true if dns contains dn.
+func containsDN(dns []string, dn string) bool {
+	dn = normalizeDN(dn)
+	for _, s := range dns {
+		if strings.EqualFold(normalizeDN(s), dn) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDN removes the spaces around the separators of the DN.
+func normalizeDN(dn string) string {
+	rdns := strings.Split(dn, ",")
+	for i, rdn := range rdns {
+		kv := strings.SplitN(rdn, "=", 2)
+		for j := range kv {
+			kv[j] = strings.TrimSpace(kv[j])
+		}
+		rdns[i] = strings.Join(kv, "=")
+	}
+	return strings.Join(rdns, ",")
+}
+
+// parseLDAPPrivilege returns the privilege named by s.
+func parseLDAPPrivilege(s string) (influxql.Privilege, error) {
+	switch strings.ToUpper(s) {
+	case "READ":
+		return influxql.ReadPrivilege, nil
+	case "WRITE":
+		return influxql.WritePrivilege, nil
+	case "ALL":
+		return influxql.AllPrivileges, nil
+	}
+	return influxql.NoPrivileges, errors.New(`privilege must be "READ", "WRITE" or "ALL"`)
+}
+
+// mergePrivileges returns the privilege granting both a and b.
+func mergePrivileges(a, b influxql.Privilege) influxql.Privilege {
+	if a == b || b == influxql.NoPrivileges {
+		return a
+	} else if a == influxql.NoPrivileges {
+		return b
+	}
+	return influxql.AllPrivileges
+}
@@ -0,0 +1,320 @@
+package httpd
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/pkg/ldap"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxql"
+)
+
+// Ensure users authenticate with their password in the directory, and get
+// the privileges of their groups.
+func TestLDAPAuthenticator_Authenticate(t *testing.T) {
+	d := NewTestDirectory()
+	a := newTestLDAPAuthenticator(d)
+
+	for _, tt := range []struct {
+		username, password string
+		admin              bool
+		privileges         map[string]influxql.Privilege
+		err                string
+	}{
+		{username: "alice", password: "alice password", admin: true, privileges: map[string]influxql.Privilege{}},
+		{username: "bob", password: "bob password", privileges: map[string]influxql.Privilege{"db0": influxql.AllPrivileges, "db1": influxql.ReadPrivilege}},
+		{username: "carol", password: "carol password", err: `user "carol" is not a member of any configured group`},
+		{username: "alice", password: "wrong", err: meta.ErrAuthenticate.Error()},
+		{username: "alice", password: "", err: meta.ErrAuthenticate.Error()},
+		{username: "nobody", password: "alice password", err: meta.ErrAuthenticate.Error()},
+		{username: "ali*", password: "alice password", err: meta.ErrAuthenticate.Error()},
+	} {
+		user, err := a.authenticate(tt.username, tt.password)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: unexpected error: %v", tt.username, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: %s", tt.username, err)
+			continue
+		}
+
+		u, ok := user.(*ldapUser)
+		if !ok {
+			t.Errorf("%s: unexpected user type: %T", tt.username, user)
+		} else if u.ID() != tt.username || u.Admin != tt.admin || !reflect.DeepEqual(u.Privileges, tt.privileges) {
+			t.Errorf("%s: unexpected user: %+v", tt.username, u.UserInfo)
+		}
+	}
+}
+
+// Ensure the groups of users can be searched rather than read from their
+// entries.
+func TestLDAPAuthenticator_GroupSearch(t *testing.T) {
+	d := NewTestDirectory()
+	a := newTestLDAPAuthenticator(d)
+	a.config.GroupSearchBaseDN = "ou=groups,dc=example,dc=com"
+	a.config.GroupSearchFilter = "(member={dn})"
+
+	user, err := a.authenticate("bob", "bob password")
+	if err != nil {
+		t.Fatal(err)
+	} else if u := user.(*ldapUser); u.Admin || u.Privileges["db0"] != influxql.AllPrivileges {
+		t.Fatalf("unexpected user: %+v", u.UserInfo)
+	}
+	if _, err := a.authenticate("carol", "carol password"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// Ensure users of the meta store authenticate only if LDAP falls back to them.
+func TestHandler_AuthenticateUser_LocalFallback(t *testing.T) {
+	h := NewHandler(NewConfig())
+	h.ldap = newTestLDAPAuthenticator(NewTestDirectory())
+	h.MetaClient = &internal.MetaClientMock{
+		AuthenticateFn: func(username, password string) (meta.User, error) {
+			if username != "admin" || password != "admin password" {
+				return nil, meta.ErrAuthenticate
+			}
+			return &meta.UserInfo{Name: "admin", Admin: true}, nil
+		},
+	}
+
+	if _, err := h.authenticateUser("admin", "admin password"); err != meta.ErrAuthenticate {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	h.Config.LDAP.LocalFallback = true
+	if user, err := h.authenticateUser("admin", "admin password"); err != nil {
+		t.Fatal(err)
+	} else if _, ok := user.(*meta.UserInfo); !ok || user.ID() != "admin" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	if user, err := h.authenticateUser("alice", "alice password"); err != nil {
+		t.Fatal(err)
+	} else if _, ok := user.(*ldapUser); !ok {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	if _, err := h.authenticateUser("admin", "wrong"); err != meta.ErrAuthenticate {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure connections are reused, and idle connections closed by the
+// directory are replaced.
+func TestLDAPAuthenticator_Pool(t *testing.T) {
+	d := NewTestDirectory()
+	a := newTestLDAPAuthenticator(d)
+
+	for i := 0; i < 3; i++ {
+		if _, err := a.authenticate("alice", "alice password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a.authenticate("alice", "wrong"); err != meta.ErrAuthenticate {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := d.Dials(); n != 1 {
+		t.Fatalf("unexpected number of dials: %d", n)
+	}
+
+	d.Disconnect()
+	if _, err := a.authenticate("alice", "alice password"); err != nil {
+		t.Fatal(err)
+	} else if n := d.Dials(); n != 2 {
+		t.Fatalf("unexpected number of dials: %d", n)
+	}
+
+	// Concurrent authentications use their own connections, and at most
+	// max-idle-connections are kept.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := a.authenticate("bob", "bob password"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(a.idle); n > a.config.MaxIdleConnections {
+		t.Fatalf("unexpected number of idle connections: %d", n)
+	}
+
+	a.close()
+	if n := d.Open(); n != 0 {
+		t.Fatalf("unexpected number of open connections: %d", n)
+	}
+}
+
+func TestContainsDN(t *testing.T) {
+	dns := []string{"CN=Admins, OU=Groups, DC=example, DC=com"}
+	if !containsDN(dns, "cn=admins,ou=groups,dc=example,dc=com") {
+		t.Error("expected match")
+	} else if containsDN(dns, "cn=admins,dc=example,dc=com") {
+		t.Error("unexpected match")
+	}
+}
+
+// newTestLDAPAuthenticator returns an authenticator of the directory, with
+// groups of admins, readers and writers.
+func newTestLDAPAuthenticator(d *TestDirectory) *ldapAuthenticator {
+	c := NewConfig().LDAP
+	c.Enabled = true
+	c.BindDN = testDirectoryBindDN
+	c.BindPassword = "secret"
+	c.SearchBaseDN = "ou=people,dc=example,dc=com"
+	c.MaxIdleConnections = 2
+	c.Groups = []LDAPGroup{
+		{DN: "cn=admins,ou=groups,dc=example,dc=com", Admin: true},
+		{DN: "cn=readers,ou=groups,dc=example,dc=com", Database: "db0", Privilege: "READ"},
+		{DN: "cn=readers,ou=groups,dc=example,dc=com", Database: "db1", Privilege: "READ"},
+		{DN: "cn=writers,ou=groups,dc=example,dc=com", Database: "db0", Privilege: "WRITE"},
+	}
+	a := newLDAPAuthenticator(c)
+	a.dial = d.Dial
+	return a
+}
+
+const testDirectoryBindDN = "cn=influxdb,dc=example,dc=com"
+
+// TestDirectory is an LDAP directory of people and groups. Only the account
+// of testDirectoryBindDN may search it.
+type TestDirectory struct {
+	mu        sync.Mutex
+	passwords map[string]string   // By DN.
+	members   map[string][]string // DNs of the members of groups, by DN.
+	conns     []*testDirectoryConn
+	dials     int
+}
+
+func NewTestDirectory() *TestDirectory {
+	return &TestDirectory{
+		passwords: map[string]string{
+			testDirectoryBindDN:                     "secret",
+			"uid=alice,ou=people,dc=example,dc=com": "alice password",
+			"uid=bob,ou=people,dc=example,dc=com":   "bob password",
+			"uid=carol,ou=people,dc=example,dc=com": "carol password",
+		},
+		members: map[string][]string{
+			"cn=admins,ou=groups,dc=example,dc=com":  {"uid=alice,ou=people,dc=example,dc=com"},
+			"cn=readers,ou=groups,dc=example,dc=com": {"uid=bob,ou=people,dc=example,dc=com"},
+			"cn=writers,ou=groups,dc=example,dc=com": {"uid=bob,ou=people,dc=example,dc=com"},
+			"cn=others,ou=groups,dc=example,dc=com":  {"uid=carol,ou=people,dc=example,dc=com"},
+		},
+	}
+}
+
+// Dial returns a new connection to the directory.
+func (d *TestDirectory) Dial() (ldapConn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dials++
+	c := &testDirectoryConn{d: d}
+	d.conns = append(d.conns, c)
+	return c, nil
+}
+
+// Dials returns the number of connections made.
+func (d *TestDirectory) Dials() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dials
+}
+
+// Open returns the number of connections not closed by their client.
+func (d *TestDirectory) Open() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, c := range d.conns {
+		if !c.closed {
+			n++
+		}
+	}
+	return n
+}
+
+// Disconnect makes the open connections fail, as if the directory closed
+// them.
+func (d *TestDirectory) Disconnect() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, c := range d.conns {
+		c.disconnected = true
+	}
+}
+
+type testDirectoryConn struct {
+	d            *TestDirectory
+	bound        string
+	disconnected bool
+	closed       bool
+}
+
+func (c *testDirectoryConn) Bind(dn, password string) error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if c.disconnected {
+		return io.ErrUnexpectedEOF
+	} else if p, ok := c.d.passwords[dn]; !ok || p != password {
+		c.bound = ""
+		return &ldap.Error{ResultCode: ldap.ResultInvalidCredentials, Message: "invalid credentials"}
+	}
+	c.bound = dn
+	return nil
+}
+
+// Search supports the searches of users by uid and of groups by member.
+func (c *testDirectoryConn) Search(req *ldap.SearchRequest) ([]*ldap.Entry, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if c.disconnected {
+		return nil, io.ErrUnexpectedEOF
+	} else if c.bound != testDirectoryBindDN {
+		return nil, &ldap.Error{ResultCode: 50, Message: "insufficient access"}
+	}
+
+	var entries []*ldap.Entry
+	switch req.BaseDN {
+	case "ou=people,dc=example,dc=com":
+		for dn := range c.d.passwords {
+			if !strings.HasSuffix(dn, ","+req.BaseDN) || req.Filter != "(uid="+ldap.EscapeFilter(strings.TrimPrefix(strings.SplitN(dn, ",", 2)[0], "uid="))+")" {
+				continue
+			}
+			var groups []string
+			for group, members := range c.d.members {
+				for _, m := range members {
+					if m == dn {
+						groups = append(groups, group)
+					}
+				}
+			}
+			entries = append(entries, &ldap.Entry{DN: dn, Attributes: map[string][]string{"memberOf": groups}})
+		}
+	case "ou=groups,dc=example,dc=com":
+		for group, members := range c.d.members {
+			for _, m := range members {
+				if req.Filter == "(member="+ldap.EscapeFilter(m)+")" {
+					entries = append(entries, &ldap.Entry{DN: group, Attributes: map[string][]string{}})
+				}
+			}
+		}
+	default:
+		return nil, &ldap.Error{ResultCode: ldap.ResultNoSuchObject, Message: "no such object"}
+	}
+	return entries, nil
+}
+
+func (c *testDirectoryConn) Close() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.closed = true
+	return nil
+}
@@ -3,6 +3,7 @@ package httpd
 import (
 	"net"
 	"sync"
+	"time"
 )
 
 // LimitListener returns a Listener that accepts at most n simultaneous
@@ -49,3 +50,21 @@ func (l *limitListenerConn) Close() error {
 	l.releaseOnce.Do(l.release)
 	return err
 }
+
+// tcpKeepAliveListener sets TCP keep-alive probes on the connections it
+// accepts, so that connections of clients that went away are eventually
+// closed.
+type tcpKeepAliveListener struct {
+	*net.TCPListener
+	period time.Duration
+}
+
+func (l *tcpKeepAliveListener) Accept() (net.Conn, error) {
+	c, err := l.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	c.SetKeepAlive(true)
+	c.SetKeepAlivePeriod(l.period)
+	return c, nil
+}
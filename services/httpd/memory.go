@@ -0,0 +1,123 @@
+package httpd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxdb/services/meta"
+)
+
+// Estimates of the bytes allocated for the parts of results and points,
+// besides their strings.
+const (
+	rowOverhead   = 128 // A row, with its tags and columns.
+	valueOverhead = 16  // An interface value.
+	pointOverhead = 192 // A parsed point, whose key and fields refer to the body.
+)
+
+// requestMemoryError is returned when the memory allocated for a request
+// exceeds the limit.
+type requestMemoryError struct {
+	used, limit int64
+}
+
+func (e requestMemoryError) Error() string {
+	return fmt.Sprintf("max-request-memory limit exceeded: (%d/%d)", e.used, e.limit)
+}
+
+// requestMemory accounts for the memory allocated on behalf of a request, for
+// the results of a query buffered before they are written or for the body of
+// a write and its points, and limits it. A nil requestMemory is unlimited.
+type requestMemory struct {
+	limit int64
+	used  int64
+}
+
+// newRequestMemory returns the memory account of a request of user, or nil
+// if the memory of its requests is unlimited. The limit of the user, if set,
+// overrides the limit of the configuration.
+func (h *Handler) newRequestMemory(user meta.User) *requestMemory {
+	limit := int64(h.Config.MaxRequestMemory)
+	if u, ok := user.(*meta.UserInfo); ok && u.Limits.MaxRequestMemory > 0 {
+		limit = u.Limits.MaxRequestMemory
+	}
+	if limit == 0 {
+		return nil
+	}
+	return &requestMemory{limit: limit}
+}
+
+// grow accounts for n more bytes. It returns an error if they exceed the
+// limit.
+func (m *requestMemory) grow(n int64) error {
+	if m == nil {
+		return nil
+	}
+	m.used += n
+	if m.used > m.limit {
+		return requestMemoryError{used: m.used, limit: m.limit}
+	}
+	return nil
+}
+
+// reader returns a reader of r accounting for the bytes read, which fails
+// with the error of grow once they exceed the limit.
+func (m *requestMemory) reader(r io.Reader) io.Reader {
+	if m == nil {
+		return r
+	}
+	return &requestMemoryReader{r: r, m: m}
+}
+
+type requestMemoryReader struct {
+	r io.Reader
+	m *requestMemory
+}
+
+func (r *requestMemoryReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err := r.m.grow(int64(n)); err != nil {
+		return n, err
+	}
+	return n, err
+}
+
+// resultSize estimates the bytes allocated for the series and messages of r.
+func resultSize(r *query.Result) int64 {
+	var n int64
+	for _, row := range r.Series {
+		n += rowOverhead + int64(len(row.Name))
+		for k, v := range row.Tags {
+			n += int64(len(k) + len(v))
+		}
+		for _, c := range row.Columns {
+			n += int64(len(c))
+		}
+		for _, values := range row.Values {
+			n += valueOverhead * int64(len(values)+1)
+			for _, v := range values {
+				switch v := v.(type) {
+				case string:
+					n += int64(len(v))
+				case time.Time:
+					n += 24
+				default:
+					n += 8
+				}
+			}
+		}
+	}
+	for _, m := range r.Messages {
+		n += int64(len(m.Level) + len(m.Text))
+	}
+	return n
+}
+
+// pointsSize estimates the bytes allocated for parsed points, besides the
+// body they were parsed from.
+func pointsSize(points []models.Point) int64 {
+	return pointOverhead * int64(len(points))
+}
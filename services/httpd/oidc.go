@@ -0,0 +1,217 @@
+package httpd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/influxdata/influxdb/services/meta"
+	"go.uber.org/zap"
+)
+
+// oidcProvider validates the bearer tokens of an OpenID Connect issuer. The
+// keys of the issuer are discovered from its provider configuration, which
+// is fetched when a token is first validated.
+type oidcProvider struct {
+	issuer          string
+	audience        string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	mu         sync.Mutex
+	jwks       *jwks     // The keys of the issuer, once discovered.
+	discovered time.Time // Time of the last discovery attempt.
+	err        error     // Error of the last discovery attempt.
+}
+
+func newOIDCProvider(c *Config) *oidcProvider {
+	return &oidcProvider{
+		issuer:          c.OIDCIssuer,
+		audience:        c.OIDCAudience,
+		refreshInterval: time.Duration(c.JWKSRefreshInterval),
+		client:          &http.Client{Timeout: jwksTimeout},
+	}
+}
+
+// issued returns true if the token claims to be issued by the provider. The
+// claim is not verified.
+func (p *oidcProvider) issued(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	b, err := jwt.DecodeSegment(parts[1])
+	if err != nil {
+		return false
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	return json.Unmarshal(b, &claims) == nil && claims.Issuer == p.issuer
+}
+
+// keySet returns the keys of the issuer, discovering them if needed. Failed
+// discoveries are retried at most every jwksMinRefreshInterval.
+func (p *oidcProvider) keySet() (*jwks, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.jwks != nil {
+		return p.jwks, nil
+	} else if time.Since(p.discovered) < jwksMinRefreshInterval {
+		return nil, p.err
+	}
+
+	p.discovered = time.Now()
+	url, err := p.discover()
+	if err != nil {
+		p.err = fmt.Errorf("OIDC discovery failed: %s", err)
+		return nil, p.err
+	}
+	p.jwks, p.err = newJWKS(url, p.refreshInterval), nil
+	return p.jwks, nil
+}
+
+// discover returns the JWKS URL of the provider configuration of the
+// issuer, as defined by OpenID Connect Discovery 1.0.
+func (p *oidcProvider) discover() (string, error) {
+	resp, err := p.client.Get(strings.TrimSuffix(p.issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var config struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, jwksMaxSize)).Decode(&config); err != nil {
+		return "", fmt.Errorf("invalid provider configuration: %s", err)
+	} else if config.Issuer != p.issuer {
+		return "", fmt.Errorf("provider configuration is for issuer %q", config.Issuer)
+	} else if config.JWKSURI == "" {
+		return "", errors.New("provider configuration has no jwks_uri")
+	}
+	return config.JWKSURI, nil
+}
+
+// parse parses and validates a token of the issuer, and returns its claims.
+// Tokens must be signed with one of the RSA or ECDSA keys of the issuer, be
+// intended for the audience, if configured, and expire.
+func (p *oidcProvider) parse(s string) (jwt.MapClaims, error) {
+	ks, err := p.keySet()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := parseJWT(s, func(token *jwt.Token) ([]interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		keys, err := ks.lookup(kid)
+		var a []interface{}
+		for _, k := range keys {
+			if _, ok := k.([]byte); !ok {
+				a = append(a, k)
+			}
+		}
+		if len(a) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			return nil, errors.New("no key of the issuer to verify token")
+		}
+		return a, nil
+	})
+	if err != nil {
+		return nil, err
+	} else if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if iss, _ := claims["iss"].(string); iss != p.issuer {
+		return nil, errors.New("token issuer mismatch")
+	} else if p.audience != "" && !hasAudience(claims["aud"], p.audience) {
+		return nil, errors.New("token audience mismatch")
+	} else if exp, ok := claims["exp"].(float64); !ok || exp <= 0.0 {
+		return nil, errors.New("token expiration required")
+	}
+	return claims, nil
+}
+
+// hasAudience returns true if the aud claim, a string or an array of
+// strings, contains audience.
+func hasAudience(aud interface{}, audience string) bool {
+	for _, s := range claimStrings(aud) {
+		if s == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// claimStrings returns the strings of a claim that is a string or an array
+// of strings.
+func claimStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		a := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				a = append(a, s)
+			}
+		}
+		return a
+	}
+	return nil
+}
+
+// authenticateOIDC validates an OpenID Connect token and returns the user it
+// maps to: the user named by the username claim if it exists, or else the
+// user mapped to the first of the roles of the token that is mapped.
+func (h *Handler) authenticateOIDC(token string) (meta.User, error) {
+	claims, err := h.oidc.parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if username, _ := claims[h.Config.OIDCUsernameClaim].(string); username != "" {
+		user, err := h.MetaClient.User(username)
+		if err == nil && user != nil {
+			return user, nil
+		} else if err != nil && err != meta.ErrUserNotFound {
+			return nil, err
+		}
+	}
+
+	for _, role := range claimStrings(claims[h.Config.OIDCRolesClaim]) {
+		username, ok := h.Config.OIDCRoleUsers[role]
+		if !ok {
+			continue
+		}
+		user, err := h.MetaClient.User(username)
+		if err != nil {
+			h.Logger.Info("User of OIDC role not found",
+				zap.String("role", role), zap.String("username", username), zap.Error(err))
+			continue
+		} else if user != nil {
+			return user, nil
+		}
+	}
+	return nil, errors.New("token does not map to a user")
+}
@@ -0,0 +1,214 @@
+package httpd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/services/meta"
+)
+
+// Ensure tokens of the OpenID Connect issuer authenticate as the user named
+// by their claims, or as the user their roles map to.
+func TestHandler_AuthenticateOIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := NewTestOIDCIssuer(key)
+	defer issuer.Close()
+
+	c := NewConfig()
+	c.OIDCIssuer = issuer.URL
+	c.OIDCAudience = "influxdb"
+	c.OIDCRoleUsers = map[string]string{"readers": "reader", "writers": "writer"}
+	h := NewHandler(c)
+	h.MetaClient = &internal.MetaClientMock{
+		UserFn: func(username string) (meta.User, error) {
+			switch username {
+			case "alice", "reader", "writer":
+				return &meta.UserInfo{Name: username}, nil
+			}
+			return nil, meta.ErrUserNotFound
+		},
+	}
+
+	for _, tt := range []struct {
+		claims jwt.MapClaims
+		user   string
+		err    string
+	}{
+		{claims: jwt.MapClaims{"preferred_username": "alice"}, user: "alice"},
+		{claims: jwt.MapClaims{"preferred_username": "alice", "aud": []interface{}{"other", "influxdb"}}, user: "alice"},
+		{claims: jwt.MapClaims{"preferred_username": "bob", "roles": []interface{}{"admins", "writers", "readers"}}, user: "writer"},
+		{claims: jwt.MapClaims{"roles": "readers"}, user: "reader"},
+		{claims: jwt.MapClaims{"preferred_username": "bob", "roles": []interface{}{"admins"}}, err: "token does not map to a user"},
+		{claims: jwt.MapClaims{"preferred_username": "alice", "aud": "other"}, err: "token audience mismatch"},
+		{claims: jwt.MapClaims{"preferred_username": "alice", "exp": nil}, err: "token expiration required"},
+		{claims: jwt.MapClaims{"preferred_username": "alice", "exp": time.Now().Add(-time.Minute).Unix()}, err: "Token is expired"},
+	} {
+		claims := jwt.MapClaims{
+			"iss": issuer.URL,
+			"aud": "influxdb",
+			"exp": time.Now().Add(10 * time.Minute).Unix(),
+		}
+		for k, v := range tt.claims {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		token := issuer.Token(claims)
+		if !h.oidc.issued(token) {
+			t.Fatalf("%v: token not recognized", tt.claims)
+		}
+
+		user, err := h.authenticateOIDC(token)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%v: unexpected error: %v", tt.claims, err)
+			}
+		} else if err != nil {
+			t.Errorf("%v: %s", tt.claims, err)
+		} else if user.ID() != tt.user {
+			t.Errorf("%v: unexpected user: %s", tt.claims, user.ID())
+		}
+	}
+
+	// Tokens signed with other keys are rejected.
+	other, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                issuer.URL,
+		"exp":                time.Now().Add(10 * time.Minute).Unix(),
+		"preferred_username": "alice",
+	})
+	signed, err := token.SignedString(other)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err := h.authenticateOIDC(signed); err == nil {
+		t.Error("expected error")
+	}
+
+	// Tokens signed with a shared secret are rejected.
+	token = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":                issuer.URL,
+		"exp":                time.Now().Add(10 * time.Minute).Unix(),
+		"preferred_username": "alice",
+	})
+	if signed, err = token.SignedString([]byte("secret")); err != nil {
+		t.Fatal(err)
+	} else if _, err := h.authenticateOIDC(signed); err == nil {
+		t.Error("expected error")
+	}
+
+	// The provider configuration is only fetched once.
+	if n := issuer.Discoveries(); n != 1 {
+		t.Fatalf("unexpected number of discoveries: %d", n)
+	}
+}
+
+// Ensure tokens of other issuers are left to the shared secrets.
+func TestOIDCProvider_Issued(t *testing.T) {
+	p := newOIDCProvider(&Config{OIDCIssuer: "https://issuer.example.com"})
+	for _, tt := range []struct {
+		claims jwt.MapClaims
+		exp    bool
+	}{
+		{claims: jwt.MapClaims{"iss": "https://issuer.example.com"}, exp: true},
+		{claims: jwt.MapClaims{"iss": "https://issuer.example.com/"}},
+		{claims: jwt.MapClaims{"username": "alice"}},
+	} {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.issued(token); got != tt.exp {
+			t.Errorf("%v: got %v, exp %v", tt.claims, got, tt.exp)
+		}
+	}
+	if p.issued("invalid") {
+		t.Error("invalid token recognized")
+	}
+}
+
+// Ensure a provider configuration for another issuer is rejected.
+func TestOIDCProvider_Discover_IssuerMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   "https://other.example.com",
+			"jwks_uri": "https://other.example.com/jwks",
+		})
+	}))
+	defer srv.Close()
+
+	p := newOIDCProvider(&Config{OIDCIssuer: srv.URL})
+	if _, err := p.keySet(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestOIDCIssuer is an OpenID Connect issuer signing tokens with an RSA key.
+type TestOIDCIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu          sync.Mutex
+	discoveries int
+}
+
+func NewTestOIDCIssuer(key *rsa.PrivateKey) *TestOIDCIssuer {
+	s := &TestOIDCIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.discoveries++
+		s.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   s.URL,
+			"jwks_uri": s.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key1",
+				"n":   b64(key.N),
+				"e":   b64(big.NewInt(int64(key.E))),
+			}},
+		})
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Token returns a token with claims signed by the issuer.
+func (s *TestOIDCIssuer) Token(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "key1"
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+// Discoveries returns the number of times the provider configuration was
+// fetched.
+func (s *TestOIDCIssuer) Discoveries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.discoveries
+}
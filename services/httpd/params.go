@@ -0,0 +1,173 @@
+package httpd
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxql"
+)
+
+// paramTypes maps the types of typed query parameters, and their aliases, to
+// the types bound by the parser.
+var paramTypes = map[string]string{
+	"ident":      "identifier",
+	"identifier": "identifier",
+	"bool":       "boolean",
+	"boolean":    "boolean",
+	"str":        "string",
+	"string":     "string",
+	"float":      "number",
+	"number":     "number",
+	"int":        "integer",
+	"integer":    "integer",
+	"duration":   "duration",
+	"regex":      "regex",
+	"time":       "time",
+}
+
+// parseQueryParams parses the JSON object of the bound parameters of a query.
+// Parameters are numbers, strings and booleans, or typed objects such as
+// {"identifier": "cpu"}, {"duration": "5m"} or {"regex": "^server[0-9]+$"},
+// whose values are validated here rather than interpolated into the query.
+func parseQueryParams(raw string) (map[string]interface{}, error) {
+	var params map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&params); err != nil {
+		return nil, err
+	}
+
+	for k, v := range params {
+		bound, err := bindQueryParam(v)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %s", k, err)
+		}
+		params[k] = bound
+	}
+	return params, nil
+}
+
+// bindQueryParam returns the value of the parameter v bound by the parser.
+func bindQueryParam(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case json.Number:
+		// Convert json.Number into int64 and float64 values
+		if strings.ContainsAny(string(v), ".eE") {
+			return v.Float64()
+		}
+		return v.Int64()
+	case string, bool:
+		return v, nil
+	case map[string]interface{}:
+		return bindTypedQueryParam(v)
+	case nil:
+		return nil, fmt.Errorf("value cannot be null")
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T", v)
+	}
+}
+
+// bindTypedQueryParam returns the value of the typed parameter m, an object
+// of a single type and its value, bound by the parser.
+func bindTypedQueryParam(m map[string]interface{}) (interface{}, error) {
+	if len(m) != 1 {
+		return nil, fmt.Errorf("typed value must have a single type")
+	}
+	var typ string
+	var v interface{}
+	for k, val := range m {
+		typ, v = k, val
+	}
+	t, ok := paramTypes[strings.ToLower(typ)]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+
+	invalid := func(err error) (interface{}, error) {
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", t, err)
+		}
+		return nil, fmt.Errorf("invalid %s: %v", t, v)
+	}
+
+	switch t {
+	case "identifier":
+		if s, ok := v.(string); !ok || s == "" {
+			return invalid(nil)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return invalid(nil)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return invalid(nil)
+		}
+	case "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return invalid(nil)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return invalid(err)
+		}
+		v = f
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return invalid(nil)
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return invalid(err)
+		}
+		v = i
+	case "duration":
+		// Durations are InfluxQL duration literals, or integer nanoseconds.
+		switch d := v.(type) {
+		case string:
+			if _, err := influxql.ParseDuration(d); err != nil {
+				return invalid(err)
+			}
+		case json.Number:
+			i, err := d.Int64()
+			if err != nil {
+				return invalid(err)
+			}
+			v = i
+		default:
+			return invalid(nil)
+		}
+	case "regex":
+		s, ok := v.(string)
+		if !ok {
+			return invalid(nil)
+		}
+		if _, err := regexp.Compile(s); err != nil {
+			return invalid(err)
+		}
+	case "time":
+		// Times are bound as RFC3339 strings, or integer nanoseconds since
+		// the epoch.
+		switch ts := v.(type) {
+		case string:
+			if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+				return invalid(err)
+			}
+			return map[string]interface{}{"string": ts}, nil
+		case json.Number:
+			i, err := ts.Int64()
+			if err != nil {
+				return invalid(err)
+			}
+			return map[string]interface{}{"integer": i}, nil
+		default:
+			return invalid(nil)
+		}
+	}
+	return map[string]interface{}{t: v}, nil
+}
@@ -0,0 +1,61 @@
+package httpd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Ensure query parameters are bound with their type, and invalid ones are
+// rejected.
+func TestParseQueryParams(t *testing.T) {
+	for _, tt := range []struct {
+		raw    string
+		params map[string]interface{}
+		err    string
+	}{
+		{
+			raw:    `{"host": "server01", "value": 10, "ratio": 0.5, "big": 1e3, "ok": true}`,
+			params: map[string]interface{}{"host": "server01", "value": int64(10), "ratio": 0.5, "big": 1000.0, "ok": true},
+		},
+		{
+			raw: `{"m": {"identifier": "cpu"}, "f": {"ident": "usage idle"}, "n": {"int": 5}, "x": {"number": 2}}`,
+			params: map[string]interface{}{
+				"m": map[string]interface{}{"identifier": "cpu"},
+				"f": map[string]interface{}{"identifier": "usage idle"},
+				"n": map[string]interface{}{"integer": int64(5)},
+				"x": map[string]interface{}{"number": 2.0},
+			},
+		},
+		{
+			raw: `{"d": {"duration": "1h30m"}, "r": {"regex": "^server[0-9]+$"}, "t": {"time": "2018-01-01T00:00:00Z"}, "ns": {"time": 1514764800000000000}}`,
+			params: map[string]interface{}{
+				"d":  map[string]interface{}{"duration": "1h30m"},
+				"r":  map[string]interface{}{"regex": "^server[0-9]+$"},
+				"t":  map[string]interface{}{"string": "2018-01-01T00:00:00Z"},
+				"ns": map[string]interface{}{"integer": int64(1514764800000000000)},
+			},
+		},
+		{raw: `{"m": {"identifier": ""}}`, err: `parameter "m": invalid identifier: `},
+		{raw: `{"m": {"identifier": "cpu", "string": "cpu"}}`, err: `parameter "m": typed value must have a single type`},
+		{raw: `{"m": {"table": "cpu"}}`, err: `parameter "m": unknown type "table"`},
+		{raw: `{"n": {"integer": 1.5}}`, err: `parameter "n": invalid integer: `},
+		{raw: `{"d": {"duration": "soon"}}`, err: `parameter "d": invalid duration: `},
+		{raw: `{"r": {"regex": "("}}`, err: `parameter "r": invalid regex: `},
+		{raw: `{"t": {"time": "yesterday"}}`, err: `parameter "t": invalid time: `},
+		{raw: `{"v": null}`, err: `parameter "v": value cannot be null`},
+		{raw: `{"v": [1, 2]}`, err: `parameter "v": unsupported value of type []interface {}`},
+	} {
+		params, err := parseQueryParams(tt.raw)
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("%s: unexpected error: %v", tt.raw, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.raw, err)
+		} else if !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%s: unexpected params: %#v", tt.raw, params)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package httpd
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// listenerPolicy is the policy of the requests received by a listener of the
+// service.
+type listenerPolicy struct {
+	authEnabled bool
+	endpoints   []string // Paths of the endpoints served, or nil for all.
+}
+
+// newListenerPolicy returns the policy of the listener of c. Listeners follow
+// the auth-enabled setting of the service unless they set their own.
+func newListenerPolicy(c ListenerConfig, authEnabled bool) *listenerPolicy {
+	p := &listenerPolicy{authEnabled: authEnabled}
+	if c.AuthEnabled != nil {
+		p.authEnabled = *c.AuthEnabled
+	}
+	for _, e := range c.Endpoints {
+		p.endpoints = append(p.endpoints, strings.TrimSuffix(e, "/"))
+	}
+	return p
+}
+
+// allows returns true if the endpoint of path is served by the listener.
+// Endpoints also serve the paths below them.
+func (p *listenerPolicy) allows(path string) bool {
+	return matchesEndpoint(p.endpoints, path)
+}
+
+// matchesEndpoint returns true if path is the path of one of the endpoints,
+// or below it, or if endpoints is nil.
+func matchesEndpoint(endpoints []string, path string) bool {
+	if endpoints == nil {
+		return true
+	}
+	for _, e := range endpoints {
+		if path == e || strings.HasPrefix(path, e+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+type policyKey struct{}
+
+// withPolicy returns a handler serving the requests of a listener with policy
+// p.
+func (h *Handler) withPolicy(p *listenerPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), policyKey{}, p)))
+	})
+}
+
+// policy returns the policy of the listener that received r, or nil if r was
+// received by the main listener of the service.
+func policy(r *http.Request) *listenerPolicy {
+	p, _ := r.Context().Value(policyKey{}).(*listenerPolicy)
+	return p
+}
+
+// authEnabled returns true if the request r must be authenticated.
+func (h *Handler) authEnabled(r *http.Request) bool {
+	if p := policy(r); p != nil {
+		return p.authEnabled
+	}
+	return h.Config.AuthEnabled
+}
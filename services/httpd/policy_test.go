@@ -0,0 +1,65 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/services/meta"
+)
+
+// Ensure the requests of listeners are served with their own auth and
+// endpoints.
+func TestHandler_ListenerPolicy(t *testing.T) {
+	c := NewConfig()
+	c.AuthEnabled = true
+	h := NewHandler(c)
+	h.MetaClient = &internal.MetaClientMock{
+		AdminUserExistsFn: func() bool { return true },
+		DatabaseFn:        func(name string) *meta.DatabaseInfo { return nil },
+	}
+
+	disabled := false
+	internalPolicy := newListenerPolicy(ListenerConfig{AuthEnabled: &disabled, Endpoints: []string{"/write", "/ping/"}}, c.AuthEnabled)
+	externalPolicy := newListenerPolicy(ListenerConfig{Endpoints: []string{"/query"}}, c.AuthEnabled)
+
+	for _, tt := range []struct {
+		handler http.Handler
+		method  string
+		url     string
+		code    int
+	}{
+		// The main listener serves all endpoints with the auth of the service.
+		{handler: h, method: "POST", url: "/write?db=db0", code: http.StatusUnauthorized},
+		{handler: h, method: "GET", url: "/query?q=SHOW+DATABASES", code: http.StatusUnauthorized},
+
+		// The internal listener serves writes without auth.
+		{handler: h.withPolicy(internalPolicy), method: "POST", url: "/write?db=db0", code: http.StatusNotFound},
+		{handler: h.withPolicy(internalPolicy), method: "GET", url: "/ping", code: http.StatusNoContent},
+		{handler: h.withPolicy(internalPolicy), method: "GET", url: "/query?q=SHOW+DATABASES", code: http.StatusForbidden},
+		{handler: h.withPolicy(internalPolicy), method: "GET", url: "/writes", code: http.StatusForbidden},
+
+		// The external listener serves queries with the auth of the service.
+		{handler: h.withPolicy(externalPolicy), method: "GET", url: "/query?q=SHOW+DATABASES", code: http.StatusUnauthorized},
+		{handler: h.withPolicy(externalPolicy), method: "POST", url: "/write?db=db0", code: http.StatusForbidden},
+		{handler: h.withPolicy(externalPolicy), method: "GET", url: "/debug/vars", code: http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		tt.handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s %s: unexpected status: %d", tt.method, tt.url, w.Code)
+		}
+	}
+}
+
+// Ensure validating a listener config requires an address and endpoint paths.
+func TestListenerConfig_Validate(t *testing.T) {
+	if err := (ListenerConfig{BindAddress: ":8087", Endpoints: []string{"/write"}}).Validate(); err != nil {
+		t.Fatal(err)
+	} else if err := (ListenerConfig{}).Validate(); err == nil {
+		t.Fatal("expected error")
+	} else if err := (ListenerConfig{BindAddress: ":8087", Endpoints: []string{"write"}}).Validate(); err == nil {
+		t.Fatal("expected error")
+	}
+}
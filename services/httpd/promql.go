@@ -0,0 +1,291 @@
+package httpd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/prometheus"
+	"github.com/influxdata/influxdb/prometheus/remote"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxdb/services/meta"
+	"go.uber.org/zap"
+)
+
+// maxPromQueryPoints is the maximum number of points of each series of a range
+// query, as in Prometheus.
+const maxPromQueryPoints = 11000
+
+// The error types of the Prometheus HTTP API.
+const (
+	promErrorBadData   = "bad_data"
+	promErrorExecution = "execution"
+)
+
+// promAPIResponse is a response of the Prometheus HTTP API.
+type promAPIResponse struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType string      `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// promQueryData is the result of a query of the Prometheus HTTP API.
+type promQueryData struct {
+	ResultType prometheus.ValueType `json:"resultType"`
+	Result     interface{}          `json:"result"`
+}
+
+// promSample is a series of the result of an instant query.
+type promSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  promPoint         `json:"value"`
+}
+
+// promSeriesValues is a series of the result of a range query.
+type promSeriesValues struct {
+	Metric map[string]string `json:"metric"`
+	Values []promPoint       `json:"values"`
+}
+
+// promPoint is encoded as a pair of a timestamp in seconds and the value as a
+// string.
+type promPoint prometheus.Point
+
+func (p promPoint) MarshalJSON() ([]byte, error) {
+	t := strconv.FormatFloat(float64(p.T)/1000, 'f', -1, 64)
+	return []byte(fmt.Sprintf(`[%s,%q]`, t, strconv.FormatFloat(p.V, 'f', -1, 64))), nil
+}
+
+// servePromQuery evaluates a PromQL expression at a single time, like the
+// /api/v1/query endpoint of Prometheus, over the data written with the
+// Prometheus remote write endpoint.
+func (h *Handler) servePromQuery(w http.ResponseWriter, r *http.Request, user meta.User) {
+	atomic.AddInt64(&h.stats.PromQueryRequests, 1)
+
+	ts := time.Now()
+	if v := r.FormValue("time"); v != "" {
+		t, err := parsePromTime(v)
+		if err != nil {
+			h.promAPIError(w, http.StatusBadRequest, promErrorBadData, fmt.Errorf("invalid parameter 'time': %s", err))
+			return
+		}
+		ts = t
+	}
+
+	expr, series, ok := h.evalPromQuery(w, r, user, ts, ts, time.Second)
+	if !ok {
+		return
+	}
+
+	data := promQueryData{ResultType: expr.Type()}
+	if expr.Type() == prometheus.ValueTypeScalar {
+		data.Result = promPoint(series[0].Points[0])
+	} else {
+		samples := make([]promSample, 0, len(series))
+		for _, s := range series {
+			samples = append(samples, promSample{Metric: s.Metric, Value: promPoint(s.Points[0])})
+		}
+		data.Result = samples
+	}
+	h.promAPIRespond(w, data)
+}
+
+// servePromQueryRange evaluates a PromQL expression at each step of a range,
+// like the /api/v1/query_range endpoint of Prometheus.
+func (h *Handler) servePromQueryRange(w http.ResponseWriter, r *http.Request, user meta.User) {
+	atomic.AddInt64(&h.stats.PromQueryRequests, 1)
+
+	start, err := parsePromTime(r.FormValue("start"))
+	if err != nil {
+		h.promAPIError(w, http.StatusBadRequest, promErrorBadData, fmt.Errorf("invalid parameter 'start': %s", err))
+		return
+	}
+	end, err := parsePromTime(r.FormValue("end"))
+	if err != nil {
+		h.promAPIError(w, http.StatusBadRequest, promErrorBadData, fmt.Errorf("invalid parameter 'end': %s", err))
+		return
+	} else if end.Before(start) {
+		h.promAPIError(w, http.StatusBadRequest, promErrorBadData, fmt.Errorf("end timestamp must not be before start time"))
+		return
+	}
+	step, err := parsePromDuration(r.FormValue("step"))
+	if err != nil {
+		h.promAPIError(w, http.StatusBadRequest, promErrorBadData, fmt.Errorf("invalid parameter 'step': %s", err))
+		return
+	} else if step <= 0 {
+		h.promAPIError(w, http.StatusBadRequest, promErrorBadData, fmt.Errorf("zero or negative query resolution step widths are not accepted. Try a positive integer"))
+		return
+	} else if end.Sub(start)/step > maxPromQueryPoints {
+		h.promAPIError(w, http.StatusBadRequest, promErrorBadData, fmt.Errorf("exceeded maximum resolution of %d points per timeseries. Try decreasing the query resolution (?step=XX)", maxPromQueryPoints))
+		return
+	}
+
+	_, series, ok := h.evalPromQuery(w, r, user, start, end, step)
+	if !ok {
+		return
+	}
+
+	result := make([]promSeriesValues, 0, len(series))
+	for _, s := range series {
+		values := make([]promPoint, len(s.Points))
+		for i, p := range s.Points {
+			values[i] = promPoint(p)
+		}
+		metric := s.Metric
+		if metric == nil {
+			metric = map[string]string{}
+		}
+		result = append(result, promSeriesValues{Metric: metric, Values: values})
+	}
+	h.promAPIRespond(w, promQueryData{ResultType: prometheus.ValueTypeMatrix, Result: result})
+}
+
+// evalPromQuery parses the PromQL expression of the query parameter and
+// evaluates it from start to end, reading the series of its selectors from the
+// database of the db parameter. It responds with the error and returns false
+// if the expression cannot be evaluated.
+func (h *Handler) evalPromQuery(w http.ResponseWriter, r *http.Request, user meta.User, start, end time.Time, step time.Duration) (prometheus.Expr, []*prometheus.Series, bool) {
+	expr, err := prometheus.ParseExpr(r.FormValue("query"))
+	if err != nil {
+		h.promAPIError(w, http.StatusBadRequest, promErrorBadData, err)
+		return nil, nil, false
+	}
+
+	db, rp := r.FormValue("db"), r.FormValue("rp")
+	if db == "" {
+		h.promAPIError(w, http.StatusBadRequest, promErrorBadData, fmt.Errorf("database is required"))
+		return nil, nil, false
+	}
+
+	// Check quotas.
+	if h.quotas != nil {
+		quotaDB := h.quotaDatabase(db)
+		release, err := h.quotas.acquire(user, quotaDB)
+		if err != nil {
+			h.quotaError(w, err)
+			return nil, nil, false
+		}
+		defer release()
+		if err := h.quotas.allowQuery(user, quotaDB); err != nil {
+			h.quotaError(w, err)
+			return nil, nil, false
+		}
+	}
+
+	opts := query.ExecutionOptions{
+		Database:  db,
+		ChunkSize: DefaultChunkSize,
+		ReadOnly:  true,
+	}
+
+	if h.authEnabled(r) {
+		// The current user determines the authorized actions.
+		opts.Authorizer = user
+	} else {
+		// Auth is disabled, so allow everything.
+		opts.Authorizer = query.OpenAuthorizer
+	}
+
+	// Make sure if the client disconnects we signal the queries to abort
+	closing := make(chan struct{})
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		done := make(chan struct{})
+		defer close(done)
+
+		notify := notifier.CloseNotify()
+		go func() {
+			select {
+			case <-done:
+			case <-notify:
+				close(closing)
+			}
+		}()
+		opts.AbortCh = done
+	} else {
+		defer close(closing)
+	}
+
+	// Each selector is read with the InfluxQL query of a remote read.
+	read := func(q *remote.Query) ([]*remote.TimeSeries, error) {
+		influxQuery, err := prometheus.ReadRequestToInfluxQLQuery(&remote.ReadRequest{Queries: []*remote.Query{q}}, db, rp)
+		if err != nil {
+			return nil, err
+		}
+		if h.authEnabled(r) {
+			if err := h.authorizeQuery(user, influxQuery, db); err != nil {
+				return nil, err
+			}
+		}
+		return promSeries(h.QueryExecutor.ExecuteQuery(influxQuery, opts, closing))
+	}
+
+	series, err := prometheus.Eval(expr, read, start, end, step)
+	if err != nil {
+		if err, ok := err.(*meta.ErrAuthorize); ok {
+			h.Logger.Info("Unauthorized request",
+				zap.String("user", err.User),
+				zap.Stringer("query", err.Query),
+				logger.Database(err.Database))
+			h.promAPIError(w, http.StatusForbidden, promErrorExecution, fmt.Errorf("error authorizing query: %s", err))
+			return nil, nil, false
+		}
+		h.promAPIError(w, http.StatusUnprocessableEntity, promErrorExecution, err)
+		return nil, nil, false
+	}
+	return expr, series, true
+}
+
+// promAPIRespond writes the successful response of the data.
+func (h *Handler) promAPIRespond(w http.ResponseWriter, data interface{}) {
+	h.writePromAPIResponse(w, http.StatusOK, promAPIResponse{Status: "success", Data: data})
+}
+
+// promAPIError writes the error response of the Prometheus HTTP API.
+func (h *Handler) promAPIError(w http.ResponseWriter, code int, typ string, err error) {
+	h.writePromAPIResponse(w, code, promAPIResponse{Status: "error", ErrorType: typ, Error: err.Error()})
+}
+
+func (h *Handler) writePromAPIResponse(w http.ResponseWriter, code int, resp promAPIResponse) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		h.httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	h.writeHeader(w, code)
+	n, _ := w.Write(b)
+	atomic.AddInt64(&h.stats.QueryRequestBytesTransmitted, int64(n))
+}
+
+// parsePromTime parses a time of the Prometheus HTTP API, in seconds since
+// the epoch or in RFC3339 format.
+func parsePromTime(s string) (time.Time, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+	}
+	return t, nil
+}
+
+// parsePromDuration parses a duration of the Prometheus HTTP API, in seconds
+// or as a PromQL duration such as 5m.
+func parsePromDuration(s string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	d, err := prometheus.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %q to a valid duration", s)
+	}
+	return d, nil
+}
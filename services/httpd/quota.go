@@ -0,0 +1,263 @@
+package httpd
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/limiter"
+	"github.com/influxdata/influxdb/services/meta"
+	"golang.org/x/time/rate"
+)
+
+const (
+	statQuotaQueriesLimited    = "queryReqLimited"      // Number of query requests rejected by the queries-per-second limit.
+	statQuotaPointsLimited     = "writeReqLimited"      // Number of write requests rejected by the points-per-second limit.
+	statQuotaBytesLimited      = "writeBytesLimited"    // Number of write requests rejected by the bytes-per-second limit.
+	statQuotaConcurrentLimited = "concurrentReqLimited" // Number of requests rejected by the max-concurrent-requests limit.
+	statQuotaRequestsActive    = "reqActive"            // Number of currently active requests counted by the quota.
+)
+
+// quotaExceededError is returned for requests exceeding a quota. Clients
+// should not retry them before retryAfter.
+type quotaExceededError struct {
+	msg        string
+	retryAfter time.Duration
+}
+
+func (e *quotaExceededError) Error() string { return e.msg }
+
+// quota limits the requests of a user or to a database.
+type quota struct {
+	queries    *rate.Limiter // nil if unlimited.
+	points     *rate.Limiter // nil if unlimited.
+	bytes      *rate.Limiter // nil if unlimited.
+	concurrent limiter.Fixed // nil if unlimited.
+
+	// Number of requests rejected by each limit.
+	queriesLimited    int64
+	pointsLimited     int64
+	bytesLimited      int64
+	concurrentLimited int64
+	active            int64
+}
+
+func newQuota(c QuotaConfig) *quota {
+	q := &quota{}
+	if c.QueriesPerSecond > 0 {
+		q.queries = newRateLimiter(c.QueriesPerSecond)
+	}
+	if c.PointsPerSecond > 0 {
+		q.points = newRateLimiter(c.PointsPerSecond)
+	}
+	if c.BytesPerSecond > 0 {
+		q.bytes = newRateLimiter(c.BytesPerSecond)
+	}
+	if c.MaxConcurrentRequests > 0 {
+		q.concurrent = limiter.NewFixed(c.MaxConcurrentRequests)
+	}
+	return q
+}
+
+// newRateLimiter returns a limiter of r events per second, allowing bursts
+// of a second's worth of events.
+func newRateLimiter(r int) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(r), r)
+}
+
+// quotas holds the quotas of users and databases. The quota of a user or
+// database is created on its first request.
+type quotas struct {
+	config *Config
+
+	mu        sync.Mutex
+	users     map[string]*quota // nil for unlimited users.
+	databases map[string]*quota // nil for unlimited databases.
+}
+
+func newQuotas(c *Config) *quotas {
+	return &quotas{
+		config:    c,
+		users:     make(map[string]*quota),
+		databases: make(map[string]*quota),
+	}
+}
+
+// acquire counts a request of user to db against the concurrent requests of
+// their quotas. The returned function must be called when the request ends.
+// db must be empty if the database does not exist. user may be nil.
+func (q *quotas) acquire(user meta.User, db string) (func(), *quotaExceededError) {
+	var taken []*quota
+	release := func() {
+		for _, qu := range taken {
+			atomic.AddInt64(&qu.active, -1)
+			if qu.concurrent != nil {
+				qu.concurrent.Release()
+			}
+		}
+	}
+	for _, qu := range q.get(user, db) {
+		if qu.concurrent != nil && !qu.concurrent.TryTake() {
+			release()
+			atomic.AddInt64(&qu.concurrentLimited, 1)
+			return nil, &quotaExceededError{
+				msg:        "too many concurrent requests",
+				retryAfter: time.Second,
+			}
+		}
+		atomic.AddInt64(&qu.active, 1)
+		taken = append(taken, qu)
+	}
+	return release, nil
+}
+
+// allowQuery counts a query of user to db against the queries per second of
+// their quotas.
+func (q *quotas) allowQuery(user meta.User, db string) *quotaExceededError {
+	qus := q.get(user, db)
+	limiters, ns := make([]*rate.Limiter, len(qus)), make([]int, len(qus))
+	for i, qu := range qus {
+		limiters[i], ns[i] = qu.queries, 1
+	}
+	if i, delay := reserve(limiters, ns); delay > 0 {
+		atomic.AddInt64(&qus[i].queriesLimited, 1)
+		return &quotaExceededError{
+			msg:        "query rate limit exceeded",
+			retryAfter: delay,
+		}
+	}
+	return nil
+}
+
+// allowWrite counts a write by user to db of n points, encoded in size bytes,
+// against the points and bytes per second of their quotas. Writes of more
+// than a second's worth of points or bytes take a second's worth.
+func (q *quotas) allowWrite(user meta.User, db string, n, size int) *quotaExceededError {
+	qus := q.get(user, db)
+	limiters, ns := make([]*rate.Limiter, 0, 2*len(qus)), make([]int, 0, 2*len(qus))
+	for _, qu := range qus {
+		limiters, ns = append(limiters, qu.points, qu.bytes), append(ns, n, size)
+	}
+	i, delay := reserve(limiters, ns)
+	if delay <= 0 {
+		return nil
+	}
+
+	err := &quotaExceededError{retryAfter: delay}
+	if qu := qus[i/2]; i%2 == 0 {
+		atomic.AddInt64(&qu.pointsLimited, 1)
+		err.msg = fmt.Sprintf("write rate limit exceeded: %d points", n)
+	} else {
+		atomic.AddInt64(&qu.bytesLimited, 1)
+		err.msg = fmt.Sprintf("write rate limit exceeded: %d bytes", size)
+	}
+	return err
+}
+
+// get returns the quotas limiting the requests of user to db.
+func (q *quotas) get(user meta.User, db string) []*quota {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var qus []*quota
+	if user != nil {
+		name := user.ID()
+		qu, ok := q.users[name]
+		if !ok {
+			c, ok := q.config.UserQuotas[name]
+			if !ok {
+				c = q.config.UserQuota
+			}
+			if !c.unlimited() {
+				qu = newQuota(c)
+			}
+			q.users[name] = qu
+		}
+		if qu != nil {
+			qus = append(qus, qu)
+		}
+	}
+	if db != "" {
+		qu, ok := q.databases[db]
+		if !ok {
+			c, ok := q.config.DatabaseQuotas[db]
+			if !ok {
+				c = q.config.DatabaseQuota
+			}
+			if !c.unlimited() {
+				qu = newQuota(c)
+			}
+			q.databases[db] = qu
+		}
+		if qu != nil {
+			qus = append(qus, qu)
+		}
+	}
+	return qus
+}
+
+// statistics returns the statistics of the quotas of users and databases.
+func (q *quotas) statistics(tags map[string]string) []models.Statistic {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var statistics []models.Statistic
+	add := func(key, name string, qu *quota) {
+		statistics = append(statistics, models.Statistic{
+			Name: "httpd_quota",
+			Tags: models.StatisticTags{key: name}.Merge(tags),
+			Values: map[string]interface{}{
+				statQuotaQueriesLimited:    atomic.LoadInt64(&qu.queriesLimited),
+				statQuotaPointsLimited:     atomic.LoadInt64(&qu.pointsLimited),
+				statQuotaBytesLimited:      atomic.LoadInt64(&qu.bytesLimited),
+				statQuotaConcurrentLimited: atomic.LoadInt64(&qu.concurrentLimited),
+				statQuotaRequestsActive:    atomic.LoadInt64(&qu.active),
+			},
+		})
+	}
+	for name, qu := range q.users {
+		if qu != nil {
+			add("user", name, qu)
+		}
+	}
+	for name, qu := range q.databases {
+		if qu != nil {
+			add("database", name, qu)
+		}
+	}
+	return statistics
+}
+
+// reserve takes ns[i] tokens from each of the limiters, ignoring nil ones. If
+// any of them has too few tokens, none are taken, and the index of the
+// limiter waited on longest is returned with the time until it has enough.
+func reserve(limiters []*rate.Limiter, ns []int) (int, time.Duration) {
+	now := time.Now()
+	var (
+		index        int
+		delay        time.Duration
+		reservations []*rate.Reservation
+	)
+	for i, l := range limiters {
+		if l == nil {
+			continue
+		}
+		k := ns[i]
+		if b := l.Burst(); k > b {
+			k = b
+		}
+		r := l.ReserveN(now, k)
+		reservations = append(reservations, r)
+		if d := r.DelayFrom(now); d > delay {
+			index, delay = i, d
+		}
+	}
+	if delay > 0 {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+	return index, delay
+}
@@ -0,0 +1,146 @@
+package httpd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/services/meta"
+)
+
+// Ensure queries are limited by the quotas of both their user and database,
+// and users named in user-quotas get their own limits.
+func TestQuotas_AllowQuery(t *testing.T) {
+	c := NewConfig()
+	c.UserQuota.QueriesPerSecond = 2
+	c.UserQuotas = map[string]QuotaConfig{"admin": {}}
+	c.DatabaseQuota.QueriesPerSecond = 3
+	q := newQuotas(&c)
+
+	alice, bob, admin := &meta.UserInfo{Name: "alice"}, &meta.UserInfo{Name: "bob"}, &meta.UserInfo{Name: "admin"}
+	for i := 0; i < 2; i++ {
+		if err := q.allowQuery(alice, "db0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.allowQuery(alice, "db0"); err == nil {
+		t.Fatal("expected error")
+	} else if err.retryAfter <= 0 || err.retryAfter > time.Second {
+		t.Fatalf("unexpected retry after: %s", err.retryAfter)
+	}
+
+	// The rejected query did not count against the database.
+	if err := q.allowQuery(bob, "db0"); err != nil {
+		t.Fatal(err)
+	} else if err := q.allowQuery(admin, "db0"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Unlimited users are only limited by their database.
+	for i := 0; i < 10; i++ {
+		if err := q.allowQuery(admin, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.allowQuery(nil, "db1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if q.users["alice"].queriesLimited != 1 || q.databases["db0"].queriesLimited != 1 {
+		t.Fatalf("unexpected limited queries: %d, %d", q.users["alice"].queriesLimited, q.databases["db0"].queriesLimited)
+	}
+}
+
+// Ensure writes are limited by the points per second of their quotas, and
+// writes larger than a second's worth of points are not always rejected.
+func TestQuotas_AllowWrite(t *testing.T) {
+	c := NewConfig()
+	c.DatabaseQuotas = map[string]QuotaConfig{"db0": {PointsPerSecond: 100}}
+	q := newQuotas(&c)
+
+	user := &meta.UserInfo{Name: "alice"}
+	if err := q.allowWrite(user, "db0", 60, 600); err != nil {
+		t.Fatal(err)
+	} else if err := q.allowWrite(user, "db0", 60, 600); err == nil {
+		t.Fatal("expected error")
+	} else if err := q.allowWrite(user, "db0", 40, 400); err != nil {
+		t.Fatal(err)
+	}
+
+	q = newQuotas(&c)
+	if err := q.allowWrite(user, "db0", 1000, 10000); err != nil {
+		t.Fatal(err)
+	} else if err := q.allowWrite(user, "db1", 1000, 10000); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure writes are limited by the bytes per second of their quotas, and
+// writes rejected by one limit don't count against the others.
+func TestQuotas_AllowWrite_Bytes(t *testing.T) {
+	c := NewConfig()
+	c.DatabaseQuota = QuotaConfig{PointsPerSecond: 100, BytesPerSecond: 1000}
+	q := newQuotas(&c)
+
+	if err := q.allowWrite(nil, "db0", 10, 800); err != nil {
+		t.Fatal(err)
+	} else if err := q.allowWrite(nil, "db0", 10, 800); err == nil {
+		t.Fatal("expected error")
+	} else if err.Error() != "write rate limit exceeded: 800 bytes" {
+		t.Fatalf("unexpected error: %s", err)
+	} else if err := q.allowWrite(nil, "db0", 90, 200); err != nil {
+		t.Fatal(err)
+	}
+
+	// Other databases have their own quota.
+	if err := q.allowWrite(nil, "db1", 10, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	if qu := q.databases["db0"]; qu.bytesLimited != 1 || qu.pointsLimited != 0 {
+		t.Fatalf("unexpected limited writes: %d, %d", qu.bytesLimited, qu.pointsLimited)
+	}
+}
+
+// Ensure concurrent requests are limited, and released on completion.
+func TestQuotas_Acquire(t *testing.T) {
+	c := NewConfig()
+	c.UserQuota.MaxConcurrentRequests = 1
+	c.DatabaseQuota.MaxConcurrentRequests = 2
+	q := newQuotas(&c)
+
+	alice, bob, carol := &meta.UserInfo{Name: "alice"}, &meta.UserInfo{Name: "bob"}, &meta.UserInfo{Name: "carol"}
+	release, err := q.acquire(alice, "db0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.acquire(alice, "db1"); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := q.acquire(bob, "db0"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A request rejected by the database quota releases the user quota.
+	if _, err := q.acquire(carol, "db0"); err == nil {
+		t.Fatal("expected error")
+	} else if n := len(q.users["carol"].concurrent); n != 0 {
+		t.Fatalf("unexpected concurrent requests: %d", n)
+	}
+
+	release()
+	if _, err := q.acquire(alice, "db1"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := q.statistics(map[string]string{"bind": ":8086"})
+	if len(stats) != 5 {
+		t.Fatalf("unexpected statistics: %+v", stats)
+	}
+	for _, s := range stats {
+		if s.Name != "httpd_quota" || s.Tags["bind"] != ":8086" {
+			t.Fatalf("unexpected statistic: %+v", s)
+		} else if s.Tags["user"] == "alice" && (s.Values[statQuotaConcurrentLimited] != int64(1) || s.Values[statQuotaRequestsActive] != int64(1)) {
+			t.Fatalf("unexpected statistic: %+v", s)
+		}
+	}
+}
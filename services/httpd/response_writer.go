@@ -4,11 +4,15 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/arrow"
+	"github.com/influxdata/influxdb/query"
 	"github.com/tinylib/msgp/msgp"
 )
 
@@ -25,13 +29,16 @@ type ResponseWriter interface {
 func NewResponseWriter(w http.ResponseWriter, r *http.Request) ResponseWriter {
 	pretty := r.URL.Query().Get("pretty") == "true"
 	rw := &responseWriter{ResponseWriter: w}
-	switch r.Header.Get("Accept") {
+	switch negotiateFormat(r.Header.Get("Accept")) {
 	case "application/csv", "text/csv":
 		w.Header().Add("Content-Type", "text/csv")
 		rw.formatter = &csvFormatter{statementID: -1, Writer: w}
-	case "application/x-msgpack":
+	case "application/x-msgpack", "application/msgpack", "application/vnd.msgpack":
 		w.Header().Add("Content-Type", "application/x-msgpack")
 		rw.formatter = &msgpackFormatter{Writer: w}
+	case "application/vnd.apache.arrow.stream":
+		w.Header().Add("Content-Type", "application/vnd.apache.arrow.stream")
+		rw.formatter = &arrowFormatter{Writer: w}
 	case "application/json":
 		fallthrough
 	default:
@@ -41,6 +48,36 @@ func NewResponseWriter(w http.ResponseWriter, r *http.Request) ResponseWriter {
 	return rw
 }
 
+// negotiateFormat returns the supported media type of the Accept header with
+// the highest quality, or the empty string if there is none.
+func negotiateFormat(accept string) string {
+	var format string
+	best := 0.0
+	for _, s := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(s)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= best {
+			continue
+		}
+		switch mediaType {
+		case "application/csv", "text/csv",
+			"application/x-msgpack", "application/msgpack", "application/vnd.msgpack",
+			"application/vnd.apache.arrow.stream",
+			"application/json":
+			format, best = mediaType, q
+		}
+	}
+	return format
+}
+
 // WriteError is a convenience function for writing an error response to the ResponseWriter.
 func WriteError(w ResponseWriter, err error) (int, error) {
 	return w.WriteResponse(Response{Err: err})
@@ -156,31 +193,7 @@ func (w *csvFormatter) WriteResponse(resp Response) (n int, err error) {
 			}
 			for _, values := range row.Values {
 				for i, value := range values {
-					if value == nil {
-						w.columns[i+2] = ""
-						continue
-					}
-
-					switch v := value.(type) {
-					case float64:
-						w.columns[i+2] = strconv.FormatFloat(v, 'f', -1, 64)
-					case int64:
-						w.columns[i+2] = strconv.FormatInt(v, 10)
-					case uint64:
-						w.columns[i+2] = strconv.FormatUint(v, 10)
-					case string:
-						w.columns[i+2] = v
-					case bool:
-						if v {
-							w.columns[i+2] = "true"
-						} else {
-							w.columns[i+2] = "false"
-						}
-					case time.Time:
-						w.columns[i+2] = strconv.FormatInt(v.UnixNano(), 10)
-					case *float64, *int64, *string, *bool:
-						w.columns[i+2] = ""
-					}
+					w.columns[i+2] = formatValue(value)
 				}
 				csv.Write(w.columns)
 			}
@@ -190,6 +203,28 @@ func (w *csvFormatter) WriteResponse(resp Response) (n int, err error) {
 	return n, csv.Error()
 }
 
+// formatValue returns the text of a value in CSV responses.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case time.Time:
+		return strconv.FormatInt(v.UnixNano(), 10)
+	}
+	return ""
+}
+
 type msgpackFormatter struct {
 	io.Writer
 }
@@ -202,7 +237,11 @@ func (f *msgpackFormatter) WriteResponse(resp Response) (n int, err error) {
 	enc := msgp.NewWriter(f.Writer)
 	defer enc.Flush()
 
-	enc.WriteMapHeader(1)
+	if resp.Err != nil || resp.Cursor == "" {
+		enc.WriteMapHeader(1)
+	} else {
+		enc.WriteMapHeader(2)
+	}
 	if resp.Err != nil {
 		enc.WriteString("error")
 		enc.WriteString(resp.Err.Error())
@@ -288,6 +327,126 @@ func (f *msgpackFormatter) WriteResponse(resp Response) (n int, err error) {
 				enc.WriteBool(true)
 			}
 		}
+		if resp.Cursor != "" {
+			enc.WriteString("cursor")
+			enc.WriteString(resp.Cursor)
+		}
 	}
 	return 0, nil
 }
+
+// arrowFormatter writes each series of a response as an Arrow IPC stream of
+// its own, as series have different columns. The statement, name, tags and
+// partial flag of a series are in the custom metadata of its schema, and
+// results without series or with an error are written as empty tables.
+type arrowFormatter struct {
+	io.Writer
+}
+
+func (f *arrowFormatter) ContentType() string {
+	return "application/vnd.apache.arrow.stream"
+}
+
+func (f *arrowFormatter) WriteResponse(resp Response) (n int, err error) {
+	w := arrow.NewStreamWriter(f.Writer)
+	if resp.Err != nil {
+		err := w.WriteTable(&arrow.Table{
+			Metadata: []arrow.KeyValue{{Key: "error", Value: resp.Err.Error()}},
+		})
+		return w.N(), err
+	}
+
+	for _, result := range resp.Results {
+		metadata := []arrow.KeyValue{{Key: "statement_id", Value: strconv.Itoa(result.StatementID)}}
+		if result.Err != nil {
+			metadata = append(metadata, arrow.KeyValue{Key: "error", Value: result.Err.Error()})
+		} else if len(result.Messages) > 0 {
+			b, _ := json.Marshal(result.Messages)
+			metadata = append(metadata, arrow.KeyValue{Key: "messages", Value: string(b)})
+		}
+		if result.Err != nil || len(result.Series) == 0 {
+			if err := w.WriteTable(&arrow.Table{Metadata: metadata}); err != nil {
+				return w.N(), err
+			}
+			continue
+		}
+
+		for _, row := range result.Series {
+			if err := w.WriteTable(arrowTable(result, row, metadata)); err != nil {
+				return w.N(), err
+			}
+		}
+	}
+	return w.N(), nil
+}
+
+// arrowTable returns the table of a series of a result.
+func arrowTable(result *query.Result, row *models.Row, metadata []arrow.KeyValue) *arrow.Table {
+	t := &arrow.Table{Metadata: metadata[:len(metadata):len(metadata)]}
+	if row.Name != "" {
+		t.Metadata = append(t.Metadata, arrow.KeyValue{Key: "name", Value: row.Name})
+	}
+	if len(row.Tags) > 0 {
+		t.Metadata = append(t.Metadata, arrow.KeyValue{Key: "tags", Value: string(models.NewTags(row.Tags).HashKey()[1:])})
+	}
+	if row.Partial || result.Partial {
+		t.Metadata = append(t.Metadata, arrow.KeyValue{Key: "partial", Value: "true"})
+	}
+
+	t.Columns = make([]arrow.Column, len(row.Columns))
+	for i, name := range row.Columns {
+		c := &t.Columns[i]
+		c.Name = name
+		c.Values = make([]interface{}, len(row.Values))
+		for j, values := range row.Values {
+			if i < len(values) {
+				c.Values[j] = values[i]
+			}
+		}
+		c.Type = arrowType(c.Values)
+	}
+	return t
+}
+
+// arrowType returns the type of a column of values, and replaces values
+// without a type by nulls. Columns of values of different types are strings,
+// formatted as in CSV responses.
+func arrowType(values []interface{}) arrow.Type {
+	typ, mixed := arrow.String, false
+	typed := false
+	for i, v := range values {
+		var t arrow.Type
+		switch v.(type) {
+		case float64:
+			t = arrow.Float64
+		case int64:
+			t = arrow.Int64
+		case uint64:
+			t = arrow.Uint64
+		case bool:
+			t = arrow.Boolean
+		case string:
+			t = arrow.String
+		case time.Time:
+			t = arrow.Timestamp
+		default:
+			values[i] = nil
+			continue
+		}
+		if !typed {
+			typ, typed = t, true
+		} else if t != typ {
+			mixed = true
+		}
+	}
+
+	if mixed {
+		for i, v := range values {
+			if v != nil {
+				values[i] = formatValue(v)
+			}
+		}
+		return arrow.String
+	}
+	return typ
+}
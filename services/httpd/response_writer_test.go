@@ -154,3 +154,86 @@ func TestResponseWriter_MessagePack_Error(t *testing.T) {
 		t.Fatalf("unexpected output: %s != %s", have, want)
 	}
 }
+
+func TestResponseWriter_Arrow(t *testing.T) {
+	header := make(http.Header)
+	header.Set("Accept", "application/vnd.apache.arrow.stream")
+	r := &http.Request{
+		Header: header,
+		URL:    &url.URL{},
+	}
+	w := httptest.NewRecorder()
+
+	writer := httpd.NewResponseWriter(w, r)
+	n, err := writer.WriteResponse(httpd.Response{
+		Results: []*query.Result{
+			{
+				StatementID: 0,
+				Series: []*models.Row{
+					{
+						Name:    "cpu",
+						Tags:    map[string]string{"host": "server01"},
+						Columns: []string{"time", "value"},
+						Values: [][]interface{}{
+							{time.Unix(0, 10), float64(2.5)},
+							{time.Unix(0, 20), "foobar"},
+						},
+					},
+					{
+						Name:    "cpu",
+						Tags:    map[string]string{"host": "server02"},
+						Columns: []string{"time", "value"},
+						Values: [][]interface{}{
+							{time.Unix(0, 10), int64(3)},
+						},
+					},
+				},
+			},
+			{StatementID: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if n != w.Body.Len() {
+		t.Fatalf("unexpected length: %d", n)
+	}
+
+	body := w.Body.Bytes()
+	eos := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}
+	if got := w.Header().Get("Content-Type"); got != "application/vnd.apache.arrow.stream" {
+		t.Fatalf("unexpected content type: %s", got)
+	} else if !bytes.HasPrefix(body, eos[:4]) || !bytes.HasSuffix(body, eos) {
+		t.Fatalf("unexpected stream: %x", body)
+	} else if n := bytes.Count(body, eos); n != 3 {
+		t.Fatalf("unexpected number of streams: %d", n)
+	}
+
+	// Columns of values of different types are strings.
+	for _, s := range []string{"host=server01", "host=server02", "statement_id", "2.5foobar"} {
+		if !bytes.Contains(body, []byte(s)) {
+			t.Errorf("expected %q in stream", s)
+		}
+	}
+}
+
+func TestNewResponseWriter_Accept(t *testing.T) {
+	for accept, contentType := range map[string]string{
+		"":                                    "application/json",
+		"*/*":                                 "application/json",
+		"text/csv":                            "text/csv",
+		"text/csv; charset=utf-8":             "text/csv",
+		"application/msgpack":                 "application/x-msgpack",
+		"text/html, application/x-msgpack":    "application/x-msgpack",
+		"application/json;q=0.5, text/csv":    "text/csv",
+		"application/vnd.apache.arrow.stream": "application/vnd.apache.arrow.stream",
+		"application/vnd.apache.arrow.stream;q=0.9, application/json;q=0.1": "application/vnd.apache.arrow.stream",
+	} {
+		header := make(http.Header)
+		header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		httpd.NewResponseWriter(w, &http.Request{Header: header, URL: &url.URL{}})
+		if got := w.Header().Get("Content-Type"); got != contentType {
+			t.Errorf("%q: unexpected content type: %s", accept, got)
+		}
+	}
+}
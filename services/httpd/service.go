@@ -38,10 +38,23 @@ const (
 	statClientError                  = "clientError"          // Number of HTTP responses due to client error.
 	statServerError                  = "serverError"          // Number of HTTP responses due to server error.
 	statRecoveredPanics              = "recoveredPanics"      // Number of panics recovered by HTTP handler.
+	statQuotaExceeded                = "quotaExceeded"        // Number of requests rejected for exceeding a quota.
+	statQueryCursors                 = "queryCursors"         // Number of open cursors of paged queries.
+	statWriteRequestsQueued          = "writeReqQueued"       // Number of write requests waiting to be admitted.
+	statWriteRequestBytesActive      = "writeReqBytesActive"  // Sum of the bytes of the write requests admitted.
+	statWriteRequestsRejected        = "writeReqRejected"     // Number of write requests rejected by the concurrent write limits.
+	statExportRequest                = "exportReq"            // Number of export requests served.
+	statExportBytesTransmitted       = "exportRespBytes"      // Sum of all bytes returned in export responses.
+	statDeleteRequest                = "deleteReq"            // Number of delete requests served.
+	statRequestMemoryExceeded        = "reqMemoryExceeded"    // Number of requests failed for exceeding max-request-memory.
+	statWriteRequestsEnqueued        = "writeReqEnqueued"     // Number of write requests enqueued in the write intake.
+	statWriteIntakeBytes             = "writeIntakeBytes"     // Sum of the bytes of the writes enqueued and not yet written.
+	statWriteIntakeRejected          = "writeIntakeRejected"  // Number of write requests rejected by the full write intake.
 
 	// Prometheus stats
 	statPromWriteRequest = "promWriteReq" // Number of write requests to the promtheus endpoint
 	statPromReadRequest  = "promReadReq"  // Number of read requests to the prometheus endpoint
+	statPromQueryRequest = "promQueryReq" // Number of PromQL query requests to the prometheus endpoints
 )
 
 // Service manages the listener and handler for an HTTP endpoint.
@@ -54,10 +67,27 @@ type Service struct {
 	limit int
 	err   chan error
 
+	// Settings of the connections of clients.
+	http2          bool
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	idleTimeout    time.Duration
+	maxHeaderBytes int
+	keepAlives     bool
+	tcpKeepAlive   time.Duration
+
+	// Client certificates are verified against clientCA, if set.
+	clientCA          string
+	requireClientCert bool
+
 	unixSocket         bool
 	bindSocket         string
 	unixSocketListener net.Listener
 
+	// Additional listeners, and the policies of their requests.
+	listenerConfigs []ListenerConfig
+	listeners       []net.Listener
+
 	Handler *Handler
 
 	Logger *zap.Logger
@@ -76,6 +106,19 @@ func NewService(c Config) *Service {
 		bindSocket: c.BindSocket,
 		Handler:    NewHandler(c),
 		Logger:     zap.NewNop(),
+
+		http2:          c.HTTP2Enabled,
+		readTimeout:    time.Duration(c.ReadTimeout),
+		writeTimeout:   time.Duration(c.WriteTimeout),
+		idleTimeout:    time.Duration(c.IdleTimeout),
+		maxHeaderBytes: int(c.MaxHeaderSize),
+		keepAlives:     !c.KeepAlivesDisabled,
+		tcpKeepAlive:   time.Duration(c.TCPKeepAlivePeriod),
+
+		clientCA:          c.HTTPSClientCA,
+		requireClientCert: c.HTTPSRequireClientCert,
+
+		listenerConfigs: c.Listeners,
 	}
 	if s.key == "" {
 		s.key = s.cert
@@ -91,31 +134,16 @@ func (s *Service) Open() error {
 	s.Handler.Open()
 
 	// Open listener.
-	if s.https {
-		cert, err := tls.LoadX509KeyPair(s.cert, s.key)
-		if err != nil {
-			return err
-		}
-
-		listener, err := tls.Listen("tcp", s.addr, &tls.Config{
-			Certificates: []tls.Certificate{cert},
-		})
-		if err != nil {
-			return err
-		}
-
-		s.ln = listener
-	} else {
-		listener, err := net.Listen("tcp", s.addr)
-		if err != nil {
-			return err
-		}
-
-		s.ln = listener
+	listener, err := s.listen(s.addr, s.https, s.cert, s.key)
+	if err != nil {
+		return err
 	}
+	s.ln = listener
+
 	s.Logger.Info("Listening on HTTP",
 		zap.Stringer("addr", s.ln.Addr()),
-		zap.Bool("https", s.https))
+		zap.Bool("https", s.https),
+		zap.Bool("http2", s.https && s.http2))
 
 	// Open unix socket listener.
 	if s.unixSocket {
@@ -141,11 +169,6 @@ func (s *Service) Open() error {
 		go s.serveUnixSocket()
 	}
 
-	// Enforce a connection limit if one has been given.
-	if s.limit > 0 {
-		s.ln = LimitListener(s.ln, s.limit)
-	}
-
 	// wait for the listeners to start
 	timeout := time.Now().Add(time.Second)
 	for {
@@ -161,9 +184,81 @@ func (s *Service) Open() error {
 
 	// Begin listening for requests in a separate goroutine.
 	go s.serveTCP()
+
+	// Open the additional listeners.
+	for _, c := range s.listenerConfigs {
+		cert, key := s.cert, s.key
+		if c.HTTPSCertificate != "" {
+			cert, key = c.HTTPSCertificate, c.HTTPSPrivateKey
+			if key == "" {
+				key = cert
+			}
+		}
+		listener, err := s.listen(c.BindAddress, c.HTTPSEnabled, cert, key)
+		if err != nil {
+			return err
+		}
+		s.listeners = append(s.listeners, listener)
+
+		p := newListenerPolicy(c, s.Handler.Config.AuthEnabled)
+		s.Logger.Info("Listening on HTTP",
+			zap.Stringer("addr", listener.Addr()),
+			zap.Bool("https", c.HTTPSEnabled),
+			zap.Bool("authentication", p.authEnabled),
+			zap.Strings("endpoints", c.Endpoints))
+		go s.serve(listener, s.Handler.withPolicy(p))
+	}
 	return nil
 }
 
+// listen opens a TCP listener on addr, serving TLS with the certificate cert
+// and the key key if https is set.
+func (s *Service) listen(addr string, https bool, cert, key string) (net.Listener, error) {
+	var config *tls.Config
+	if https {
+		cert, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+
+		config = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"http/1.1"},
+		}
+		if s.http2 {
+			config.NextProtos = []string{"h2", "http/1.1"}
+		}
+		if s.clientCA != "" {
+			if config.ClientCAs, err = loadCertPool(s.clientCA); err != nil {
+				return nil, err
+			}
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+			if s.requireClientCert {
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+		}
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if s.tcpKeepAlive > 0 {
+		listener = &tcpKeepAliveListener{TCPListener: listener.(*net.TCPListener), period: s.tcpKeepAlive}
+	}
+
+	// Enforce a connection limit if one has been given. The limit applies
+	// to the TCP connections, so that the HTTP server sees the TLS
+	// connections and can negotiate HTTP/2 on them.
+	if s.limit > 0 {
+		listener = LimitListener(listener, s.limit)
+	}
+	if config != nil {
+		listener = tls.NewListener(listener, config)
+	}
+	return listener, nil
+}
+
 // Close closes the underlying listener.
 func (s *Service) Close() error {
 	s.Handler.Close()
@@ -178,6 +273,11 @@ func (s *Service) Close() error {
 			return err
 		}
 	}
+	for _, listener := range s.listeners {
+		if err := listener.Close(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -211,20 +311,32 @@ func (s *Service) BoundHTTPAddr() string {
 
 // serveTCP serves the handler from the TCP listener.
 func (s *Service) serveTCP() {
-	s.serve(s.ln)
+	s.serve(s.ln, s.Handler)
 }
 
 // serveUnixSocket serves the handler from the unix socket listener.
 func (s *Service) serveUnixSocket() {
-	s.serve(s.unixSocketListener)
+	s.serve(s.unixSocketListener, s.Handler)
 }
 
-// serve serves the handler from the listener.
-func (s *Service) serve(listener net.Listener) {
+// serve serves handler from the listener.
+func (s *Service) serve(listener net.Listener, handler http.Handler) {
 	// The listener was closed so exit
 	// See https://github.com/golang/go/issues/4373
-	err := http.Serve(listener, s.Handler)
+	srv := &http.Server{
+		Handler:        handler,
+		ReadTimeout:    s.readTimeout,
+		WriteTimeout:   s.writeTimeout,
+		IdleTimeout:    s.idleTimeout,
+		MaxHeaderBytes: s.maxHeaderBytes,
+	}
+	if !s.http2 {
+		// Disable the HTTP/2 support of the server.
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	srv.SetKeepAlivesEnabled(s.keepAlives)
+	err := srv.Serve(listener)
 	if err != nil && !strings.Contains(err.Error(), "closed") {
-		s.err <- fmt.Errorf("listener failed: addr=%s, err=%s", s.Addr(), err)
+		s.err <- fmt.Errorf("listener failed: addr=%s, err=%s", listener.Addr(), err)
 	}
 }
@@ -0,0 +1,98 @@
+package httpd_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/services/httpd"
+)
+
+// Ensure HTTP/2 is negotiated with HTTPS clients unless it is disabled, also
+// when the number of connections is limited.
+func TestService_HTTP2(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpd-service-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cert := writeTestCertificate(t, dir)
+
+	for _, tt := range []struct {
+		http2 bool
+		limit int
+		proto string
+	}{
+		{http2: true, proto: "h2"},
+		{http2: true, limit: 10, proto: "h2"},
+		{http2: false, proto: "http/1.1"},
+	} {
+		c := httpd.NewConfig()
+		c.BindAddress = "127.0.0.1:0"
+		c.HTTPSEnabled = true
+		c.HTTPSCertificate = cert
+		c.HTTP2Enabled = tt.http2
+		c.MaxConnectionLimit = tt.limit
+		s := httpd.NewService(c)
+		if err := s.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := tls.Dial("tcp", s.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{"h2", "http/1.1"},
+		})
+		if err != nil {
+			s.Close()
+			t.Fatal(err)
+		}
+		if proto := conn.ConnectionState().NegotiatedProtocol; proto != tt.proto {
+			t.Errorf("http2=%v limit=%d: unexpected protocol: %q", tt.http2, tt.limit, proto)
+		}
+		conn.Close()
+		s.Close()
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate of 127.0.0.1 and its
+// key to a file of dir, and returns the path of the file.
+func writeTestCertificate(t *testing.T, dir string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "influxdb.pem")
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	b = append(b, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})...)
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,224 @@
+package httpd
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxql"
+	"go.uber.org/zap"
+)
+
+// shardStatus is a shard of the meta store, with its storage if it is stored
+// on this node.
+type shardStatus struct {
+	ID              uint64        `json:"id"`
+	Database        string        `json:"database"`
+	RetentionPolicy string        `json:"retentionPolicy"`
+	ShardGroup      uint64        `json:"shardGroup"`
+	StartTime       time.Time     `json:"startTime"`
+	EndTime         time.Time     `json:"endTime"`
+	ExpiryTime      time.Time     `json:"expiryTime"`
+	TruncatedAt     *time.Time    `json:"truncatedAt,omitempty"`
+	Owners          []uint64      `json:"owners"`
+	Storage         *shardStorage `json:"storage,omitempty"`
+}
+
+// shardStorage is the storage of a shard on this node.
+type shardStorage struct {
+	Path         string    `json:"path"`
+	IndexType    string    `json:"indexType"`
+	Size         int64     `json:"size"`
+	SeriesN      int64     `json:"series"`
+	LastModified time.Time `json:"lastModified"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// serveShards lists the shards, of the database of the db parameter and the
+// retention policy of the rp parameter if they are set, like SHOW SHARDS.
+func (h *Handler) serveShards(w http.ResponseWriter, r *http.Request, user meta.User) {
+	if !h.authorizeStatement(w, r, user, &influxql.ShowShardsStatement{}) {
+		return
+	}
+
+	db, rp := r.FormValue("db"), r.FormValue("rp")
+	shards := make([]shardStatus, 0)
+	for _, di := range h.MetaClient.Databases() {
+		if db != "" && di.Name != db {
+			continue
+		}
+		for _, rpi := range di.RetentionPolicies {
+			if rp != "" && rpi.Name != rp {
+				continue
+			}
+			for _, sgi := range rpi.ShardGroups {
+				// Shards of deleted shard groups are effectively deleted.
+				if sgi.Deleted() {
+					continue
+				}
+				for _, si := range sgi.Shards {
+					shards = append(shards, h.shardStatus(di.Name, rpi, sgi, si))
+				}
+			}
+		}
+	}
+	h.writeJSON(w, http.StatusOK, struct {
+		Shards []shardStatus `json:"shards"`
+	}{shards})
+}
+
+// serveDropShard drops the shard of the path, like DROP SHARD.
+func (h *Handler) serveDropShard(w http.ResponseWriter, r *http.Request, user meta.User) {
+	id, err := strconv.ParseUint(r.URL.Query().Get(":id"), 10, 64)
+	if err != nil {
+		h.httpError(w, "invalid shard id: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	stmt := &influxql.DropShardStatement{ID: id}
+	if !h.authorizeStatement(w, r, user, stmt) {
+		return
+	} else if !h.shardExists(id) {
+		h.httpError(w, "shard not found", http.StatusNotFound)
+		return
+	}
+	if err := h.executeStatements(r, user, "", stmt); err != nil {
+		h.httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeHeader(w, http.StatusNoContent)
+}
+
+// serveTruncateShards truncates the shard groups at the time of the time
+// parameter, or now if it is not set, so that the points written after it
+// are written to new shards.
+func (h *Handler) serveTruncateShards(w http.ResponseWriter, r *http.Request, user meta.User) {
+	// Truncating shard groups requires the privileges of SHOW SHARDS.
+	if !h.authorizeStatement(w, r, user, &influxql.ShowShardsStatement{}) {
+		return
+	}
+
+	t := time.Now()
+	if v := r.FormValue("time"); v != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			h.httpError(w, "invalid time: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.MetaClient.TruncateShardGroups(t); err != nil {
+		h.httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeHeader(w, http.StatusNoContent)
+}
+
+// serveCompactShard schedules a full compaction of the shard of the path,
+// which must be stored on this node.
+func (h *Handler) serveCompactShard(w http.ResponseWriter, r *http.Request, user meta.User) {
+	// Compacting shards requires the privileges of SHOW SHARDS.
+	if !h.authorizeStatement(w, r, user, &influxql.ShowShardsStatement{}) {
+		return
+	}
+
+	id, err := strconv.ParseUint(r.URL.Query().Get(":id"), 10, 64)
+	if err != nil {
+		h.httpError(w, "invalid shard id: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.TSDBStore == nil {
+		h.httpError(w, "shard not found", http.StatusNotFound)
+		return
+	}
+	sh := h.TSDBStore.Shard(id)
+	if sh == nil {
+		h.httpError(w, "shard not found", http.StatusNotFound)
+		return
+	}
+	if err := sh.ScheduleFullCompaction(); err != nil {
+		h.httpError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	h.writeHeader(w, http.StatusAccepted)
+}
+
+// shardStatus returns the status of the shard si of the shard group sgi.
+func (h *Handler) shardStatus(db string, rpi meta.RetentionPolicyInfo, sgi meta.ShardGroupInfo, si meta.ShardInfo) shardStatus {
+	status := shardStatus{
+		ID:              si.ID,
+		Database:        db,
+		RetentionPolicy: rpi.Name,
+		ShardGroup:      sgi.ID,
+		StartTime:       sgi.StartTime.UTC(),
+		EndTime:         sgi.EndTime.UTC(),
+		ExpiryTime:      sgi.EndTime.Add(rpi.Duration).UTC(),
+		Owners:          make([]uint64, len(si.Owners)),
+	}
+	if sgi.Truncated() {
+		truncatedAt := sgi.TruncatedAt.UTC()
+		status.TruncatedAt = &truncatedAt
+	}
+	for i, owner := range si.Owners {
+		status.Owners[i] = owner.NodeID
+	}
+
+	if h.TSDBStore == nil {
+		return status
+	}
+	sh := h.TSDBStore.Shard(si.ID)
+	if sh == nil {
+		return status
+	}
+	status.Storage = &shardStorage{
+		Path:         sh.Path(),
+		IndexType:    sh.IndexType(),
+		LastModified: sh.LastModified().UTC(),
+	}
+	size, err := sh.DiskSize()
+	if err != nil {
+		status.Storage.Error = err.Error()
+		return status
+	}
+	status.Storage.Size = size
+	status.Storage.SeriesN = sh.SeriesN()
+	return status
+}
+
+// shardExists returns true if the shard id is in the meta store.
+func (h *Handler) shardExists(id uint64) bool {
+	for _, di := range h.MetaClient.Databases() {
+		for _, rpi := range di.RetentionPolicies {
+			for _, sgi := range rpi.ShardGroups {
+				if sgi.Deleted() {
+					continue
+				}
+				for _, si := range sgi.Shards {
+					if si.ID == id {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// authorizeStatement authorizes the user to execute the statement. It
+// responds with the error and returns false if the user is not authorized.
+func (h *Handler) authorizeStatement(w http.ResponseWriter, r *http.Request, user meta.User, stmt influxql.Statement) bool {
+	if !h.authEnabled(r) {
+		return true
+	}
+	if err := h.authorizeQuery(user, &influxql.Query{Statements: []influxql.Statement{stmt}}, ""); err != nil {
+		if err, ok := err.(*meta.ErrAuthorize); ok {
+			h.Logger.Info("Unauthorized request",
+				zap.String("user", err.User),
+				zap.Stringer("query", err.Query),
+				logger.Database(err.Database))
+		}
+		h.httpError(w, "error authorizing query: "+err.Error(), http.StatusForbidden)
+		return false
+	}
+	return true
+}
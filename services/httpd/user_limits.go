@@ -0,0 +1,68 @@
+package httpd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxql"
+)
+
+// serveUserLimits shows the query limits of the user of the path. Zero
+// limits are left out, since the limits of the node apply.
+func (h *Handler) serveUserLimits(w http.ResponseWriter, r *http.Request, user meta.User) {
+	// Managing the limits of users requires the privileges of SHOW USERS.
+	if !h.authorizeStatement(w, r, user, &influxql.ShowUsersStatement{}) {
+		return
+	}
+
+	u, err := h.MetaClient.User(r.URL.Query().Get(":name"))
+	if err == meta.ErrUserNotFound {
+		h.httpError(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		h.httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var limits meta.UserLimits
+	if ui, ok := u.(*meta.UserInfo); ok {
+		limits = ui.Limits
+	}
+	h.writeJSON(w, http.StatusOK, limits)
+}
+
+// servePutUserLimits replaces the query limits of the user of the path with
+// the limits of the body.
+func (h *Handler) servePutUserLimits(w http.ResponseWriter, r *http.Request, user meta.User) {
+	if !h.authorizeStatement(w, r, user, &influxql.ShowUsersStatement{}) {
+		return
+	}
+
+	var limits meta.UserLimits
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		h.httpError(w, "error parsing limits: "+err.Error(), http.StatusBadRequest)
+		return
+	} else if err := validateUserLimits(limits); err != nil {
+		h.httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.MetaClient.SetUserLimits(r.URL.Query().Get(":name"), limits); err == meta.ErrUserNotFound {
+		h.httpError(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		h.httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeHeader(w, http.StatusNoContent)
+}
+
+// validateUserLimits returns an error if a limit is negative.
+func validateUserLimits(limits meta.UserLimits) error {
+	if limits.MaxSelectPointN < 0 || limits.MaxSelectSeriesN < 0 || limits.MaxSelectBucketsN < 0 || limits.MaxRequestMemory < 0 {
+		return errors.New("limits must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,448 @@
+package httpd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/file"
+	"go.uber.org/zap"
+)
+
+const (
+	// writeIntakeSegmentExt is the extension of the segment files of the
+	// write intake.
+	writeIntakeSegmentExt = ".wal"
+
+	// writeIntakeHeaderSize is the size of the header of the entries of
+	// segments: the length and the CRC-32 checksum of their payload.
+	writeIntakeHeaderSize = 8
+
+	// writeIntakeRetryInterval is the time waited before writing again an
+	// entry whose write failed.
+	writeIntakeRetryInterval = time.Second
+)
+
+var (
+	// errWriteIntakeFull is returned when a write cannot be enqueued
+	// without exceeding the maximum size of the write intake.
+	errWriteIntakeFull = errors.New("write intake is full")
+
+	// errWriteIntakeClosed is returned when a write is enqueued after the
+	// write intake is closed.
+	errWriteIntakeClosed = errors.New("write intake is closed")
+
+	// errWriteIntakeCorrupt is returned when an entry of a segment does not
+	// match its checksum.
+	errWriteIntakeCorrupt = errors.New("corrupt write intake entry")
+)
+
+// writeIntake is a durable queue of the writes acknowledged once enqueued,
+// which are written in the background, in order. Entries are appended to
+// segment files and synced before writes are acknowledged, and segments are
+// removed once all their entries are written. The entries not yet written
+// when the intake is closed are written once it is opened again, and those
+// being written may be written twice, which overwrites their points with the
+// same values.
+type writeIntake struct {
+	dir         string
+	maxSize     int64 // 0 if unlimited.
+	segmentSize int64
+
+	// write writes the points of an entry. It returns an error if the write
+	// failed and should be retried.
+	write func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+
+	retryInterval time.Duration
+
+	Logger *zap.Logger
+
+	mu       sync.Mutex
+	segments []uint64 // IDs of the segments, oldest first. The last is active.
+	f        *os.File // The active segment.
+	fsize    int64    // Bytes of the active segment.
+	size     int64    // Bytes of the entries not yet written.
+	closed   bool
+
+	rejected int64 // Number of writes rejected, updated atomically.
+
+	notify  chan struct{} // Signaled when entries are appended.
+	closing chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newWriteIntake returns the write intake of c.
+func newWriteIntake(c WriteIntakeConfig) *writeIntake {
+	return &writeIntake{
+		dir:           c.Dir,
+		maxSize:       int64(c.MaxSize),
+		segmentSize:   int64(c.SegmentSize),
+		retryInterval: writeIntakeRetryInterval,
+		Logger:        zap.NewNop(),
+		notify:        make(chan struct{}, 1),
+		closing:       make(chan struct{}),
+	}
+}
+
+// open recovers the segments of the directory, truncating the entries torn
+// by a crash, and starts writing their entries.
+func (q *writeIntake) open() error {
+	if err := os.MkdirAll(q.dir, 0777); err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(q.dir, "*"+writeIntakeSegmentExt))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), writeIntakeSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		size, err := q.recoverSegment(path)
+		if err != nil {
+			return err
+		}
+		q.segments = append(q.segments, id)
+		q.size += size
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+
+	if err := q.roll(); err != nil {
+		return err
+	}
+	if q.size > 0 {
+		q.Logger.Info("Recovered write intake", zap.Int("segments", len(q.segments)), zap.Int64("bytes", q.size))
+	}
+
+	q.wg.Add(1)
+	go q.run()
+	return nil
+}
+
+// close stops writing the entries and closes the active segment.
+func (q *writeIntake) close() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.closing)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.f != nil {
+		q.f.Close()
+		q.f = nil
+	}
+}
+
+// enqueue appends a write of points to the intake, and returns once it is
+// synced to disk.
+func (q *writeIntake) enqueue(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	b := encodeWriteIntakeEntry(database, retentionPolicy, consistencyLevel, points)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return errWriteIntakeClosed
+	} else if q.maxSize > 0 && q.size+int64(len(b)) > q.maxSize {
+		atomic.AddInt64(&q.rejected, 1)
+		return errWriteIntakeFull
+	}
+
+	if q.fsize > 0 && q.fsize+int64(len(b)) > q.segmentSize {
+		if err := q.roll(); err != nil {
+			return err
+		}
+	}
+	if _, err := q.f.Write(b); err != nil {
+		q.f.Truncate(q.fsize)
+		return err
+	} else if err := q.f.Sync(); err != nil {
+		q.f.Truncate(q.fsize)
+		return err
+	}
+	q.fsize += int64(len(b))
+	q.size += int64(len(b))
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// stats returns the bytes of the entries not yet written and the number of
+// writes rejected because the intake is full.
+func (q *writeIntake) stats() (bytes, rejected int64) {
+	if q == nil {
+		return 0, 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size, atomic.LoadInt64(&q.rejected)
+}
+
+// roll closes the active segment, and creates a new active segment. The
+// lock must be held, except when opening.
+func (q *writeIntake) roll() error {
+	var id uint64 = 1
+	if len(q.segments) > 0 {
+		id = q.segments[len(q.segments)-1] + 1
+	}
+	f, err := os.OpenFile(q.segmentPath(id), os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	if err := file.SyncDir(q.dir); err != nil {
+		f.Close()
+		return err
+	}
+	if q.f != nil {
+		q.f.Close()
+	}
+	q.f, q.fsize = f, 0
+	q.segments = append(q.segments, id)
+	return nil
+}
+
+// segmentPath returns the path of the segment id.
+func (q *writeIntake) segmentPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, writeIntakeSegmentExt))
+}
+
+// recoverSegment returns the bytes of the entries of the segment at path,
+// after truncating the entries that are torn or corrupt.
+func (q *writeIntake) recoverSegment(path string) (int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0666)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	var off int64
+	for off < fi.Size() {
+		_, n, err := readWriteIntakeEntry(f, off, fi.Size())
+		if err != nil {
+			q.Logger.Warn("Truncating write intake segment",
+				zap.String("path", path), zap.Int64("offset", off), zap.Error(err))
+			if err := f.Truncate(off); err != nil {
+				return 0, err
+			}
+			return off, f.Sync()
+		}
+		off += n
+	}
+	return off, nil
+}
+
+// run writes the entries of the segments in order, and removes the segments
+// once all their entries are written, until the intake is closed.
+func (q *writeIntake) run() {
+	defer q.wg.Done()
+
+	var (
+		id  uint64
+		f   *os.File
+		off int64
+	)
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	for {
+		q.mu.Lock()
+		oldest, active, limit := q.segments[0], len(q.segments) == 1, q.fsize
+		q.mu.Unlock()
+
+		if f == nil || id != oldest {
+			if f != nil {
+				f.Close()
+			}
+			var err error
+			if f, err = os.Open(q.segmentPath(oldest)); err != nil {
+				q.Logger.Error("Unable to open write intake segment", zap.Uint64("segment", oldest), zap.Error(err))
+				if !q.wait(q.retryInterval) {
+					return
+				}
+				continue
+			}
+			id, off = oldest, 0
+		}
+
+		// Segments other than the active one are no longer appended to.
+		if !active {
+			fi, err := f.Stat()
+			if err != nil {
+				q.Logger.Error("Unable to stat write intake segment", zap.Uint64("segment", id), zap.Error(err))
+				if !q.wait(q.retryInterval) {
+					return
+				}
+				continue
+			}
+			limit = fi.Size()
+		}
+
+		if off < limit {
+			b, n, err := readWriteIntakeEntry(f, off, limit)
+			if err != nil {
+				// The rest of the segment cannot be read.
+				q.Logger.Error("Skipping write intake entries",
+					zap.Uint64("segment", id), zap.Int64("offset", off), zap.Error(err))
+				n = limit - off
+			} else if !q.apply(b) {
+				return
+			}
+			off += n
+			q.mu.Lock()
+			q.size -= n
+			q.mu.Unlock()
+			continue
+		}
+
+		if active {
+			select {
+			case <-q.notify:
+			case <-q.closing:
+				return
+			}
+			continue
+		}
+
+		f.Close()
+		f = nil
+		if err := os.Remove(q.segmentPath(id)); err != nil {
+			q.Logger.Error("Unable to remove write intake segment", zap.Uint64("segment", id), zap.Error(err))
+		}
+		q.mu.Lock()
+		q.segments = q.segments[1:]
+		q.mu.Unlock()
+	}
+}
+
+// apply writes the entry b, retrying until it is written. It returns false
+// if the intake is closed before.
+func (q *writeIntake) apply(b []byte) bool {
+	database, retentionPolicy, consistencyLevel, points, err := decodeWriteIntakeEntry(b)
+	if err != nil {
+		q.Logger.Error("Unable to decode write intake entry", zap.Error(err))
+		return true
+	}
+	for {
+		err := q.write(database, retentionPolicy, consistencyLevel, points)
+		if err == nil {
+			return true
+		}
+		q.Logger.Warn("Write of write intake entry failed, retrying",
+			zap.String("db", database), zap.Int("points", len(points)), zap.Error(err))
+		if !q.wait(q.retryInterval) {
+			return false
+		}
+	}
+}
+
+// wait waits for d. It returns false if the intake is closed before.
+func (q *writeIntake) wait(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-q.closing:
+		return false
+	}
+}
+
+// encodeWriteIntakeEntry returns the entry of a write of points, with its
+// header. The payload is the database and retention policy, prefixed by their
+// lengths, the consistency level, and the points in line protocol.
+func encodeWriteIntakeEntry(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) []byte {
+	b := make([]byte, writeIntakeHeaderSize, writeIntakeHeaderSize+len(database)+len(retentionPolicy)+binary.MaxVarintLen64*2+1)
+	for _, s := range []string{database, retentionPolicy} {
+		b = appendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+	b = append(b, byte(consistencyLevel))
+	for _, p := range points {
+		b = p.AppendString(b)
+		b = append(b, '\n')
+	}
+
+	payload := b[writeIntakeHeaderSize:]
+	binary.BigEndian.PutUint32(b[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[4:8], crc32.ChecksumIEEE(payload))
+	return b
+}
+
+// decodeWriteIntakeEntry decodes the payload of an entry.
+func decodeWriteIntakeEntry(b []byte) (database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point, err error) {
+	var s [2]string
+	for i := range s {
+		n, m := binary.Uvarint(b)
+		if m <= 0 || uint64(len(b)-m) < n {
+			return "", "", 0, nil, errWriteIntakeCorrupt
+		}
+		s[i], b = string(b[m:m+int(n)]), b[m+int(n):]
+	}
+	if len(b) == 0 {
+		return "", "", 0, nil, errWriteIntakeCorrupt
+	}
+	consistencyLevel, b = models.ConsistencyLevel(b[0]), b[1:]
+	if points, err = models.ParsePoints(b); err != nil {
+		return "", "", 0, nil, err
+	}
+	return s[0], s[1], consistencyLevel, points, nil
+}
+
+// readWriteIntakeEntry reads the payload of the entry at off of a segment of
+// size bytes, and returns it with the size of the entry.
+func readWriteIntakeEntry(r io.ReaderAt, off, size int64) ([]byte, int64, error) {
+	var hdr [writeIntakeHeaderSize]byte
+	if size-off < writeIntakeHeaderSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	} else if _, err := r.ReadAt(hdr[:], off); err != nil {
+		return nil, 0, err
+	}
+	n := int64(binary.BigEndian.Uint32(hdr[0:4]))
+	if size-off-writeIntakeHeaderSize < n {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, off+writeIntakeHeaderSize); err != nil {
+		return nil, 0, err
+	} else if crc32.ChecksumIEEE(b) != binary.BigEndian.Uint32(hdr[4:8]) {
+		return nil, 0, errWriteIntakeCorrupt
+	}
+	return b, writeIntakeHeaderSize + n, nil
+}
+
+// appendUvarint appends the varint encoding of v to b.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
@@ -0,0 +1,171 @@
+package httpd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// intakeWrite is a write of points applied by a write intake.
+type intakeWrite struct {
+	database, retentionPolicy string
+	points                    string
+}
+
+// newTestWriteIntake returns a write intake of dir sending the writes it
+// applies to writes, and failing them while fail returns an error.
+func newTestWriteIntake(dir string, segmentSize int64, writes chan<- intakeWrite, fail func() error) *writeIntake {
+	q := newWriteIntake(WriteIntakeConfig{Dir: dir})
+	q.segmentSize = segmentSize
+	q.retryInterval = time.Millisecond
+	q.write = func(database, retentionPolicy string, _ models.ConsistencyLevel, points []models.Point) error {
+		if err := fail(); err != nil {
+			return err
+		}
+		var lines []string
+		for _, p := range points {
+			lines = append(lines, p.String())
+		}
+		writes <- intakeWrite{database, retentionPolicy, strings.Join(lines, "\n")}
+		return nil
+	}
+	return q
+}
+
+func mustParsePoints(t *testing.T, s string) []models.Point {
+	points, err := models.ParsePointsString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return points
+}
+
+func waitIntakeWrite(t *testing.T, writes <-chan intakeWrite) intakeWrite {
+	select {
+	case w := <-writes:
+		return w
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for write")
+		return intakeWrite{}
+	}
+}
+
+// Ensure writes are applied in order, and their segments are removed once
+// applied.
+func TestWriteIntake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpd-intake-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writes := make(chan intakeWrite)
+	q := newTestWriteIntake(dir, 1, writes, func() error { return nil })
+	if err := q.open(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+
+	if err := q.enqueue("db0", "rp0", models.ConsistencyLevelOne, mustParsePoints(t, "cpu value=1 10\ncpu value=2 20")); err != nil {
+		t.Fatal(err)
+	} else if err := q.enqueue("db1", "", models.ConsistencyLevelOne, mustParsePoints(t, "mem value=3 30")); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := waitIntakeWrite(t, writes); w != (intakeWrite{"db0", "rp0", "cpu value=1 10\ncpu value=2 20"}) {
+		t.Fatalf("unexpected write: %+v", w)
+	} else if w := waitIntakeWrite(t, writes); w != (intakeWrite{"db1", "", "mem value=3 30"}) {
+		t.Fatalf("unexpected write: %+v", w)
+	}
+
+	// Each write rolled the segments, so only the active segment remains.
+	for i := 0; ; i++ {
+		paths, _ := filepath.Glob(filepath.Join(dir, "*"+writeIntakeSegmentExt))
+		if bytes, _ := q.stats(); bytes == 0 && len(paths) == 1 {
+			break
+		} else if i == 500 {
+			t.Fatalf("unexpected bytes and segments: %d %v", bytes, paths)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Ensure the writes not yet applied are applied once the intake is opened
+// again, and torn entries are truncated.
+func TestWriteIntake_Recover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpd-intake-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writes := make(chan intakeWrite, 1)
+	q := newTestWriteIntake(dir, 1<<20, writes, func() error { return errors.New("timeout") })
+	if err := q.open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.enqueue("db0", "", models.ConsistencyLevelOne, mustParsePoints(t, "cpu value=1 10")); err != nil {
+		t.Fatal(err)
+	}
+	q.close()
+
+	// Append a torn entry to the segment.
+	path := q.segmentPath(1)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := encodeWriteIntakeEntry("db0", "", models.ConsistencyLevelOne, mustParsePoints(t, "cpu value=2 20"))
+	if _, err := f.Write(b[:len(b)-1]); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	q = newTestWriteIntake(dir, 1<<20, writes, func() error { return nil })
+	if err := q.open(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+
+	if w := waitIntakeWrite(t, writes); w != (intakeWrite{"db0", "", "cpu value=1 10"}) {
+		t.Fatalf("unexpected write: %+v", w)
+	}
+	select {
+	case w := <-writes:
+		t.Fatalf("unexpected write: %+v", w)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Ensure writes are rejected once the intake is full.
+func TestWriteIntake_Full(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpd-intake-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writes := make(chan intakeWrite)
+	q := newTestWriteIntake(dir, 1<<20, writes, func() error { return errors.New("timeout") })
+	points := mustParsePoints(t, "cpu value=1 10")
+	q.maxSize = int64(len(encodeWriteIntakeEntry("db0", "", models.ConsistencyLevelOne, points)))
+	if err := q.open(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.close()
+
+	if err := q.enqueue("db0", "", models.ConsistencyLevelOne, points); err != nil {
+		t.Fatal(err)
+	} else if err := q.enqueue("db0", "", models.ConsistencyLevelOne, points); err != errWriteIntakeFull {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, rejected := q.stats(); rejected != 1 {
+		t.Fatalf("unexpected rejected: %d", rejected)
+	}
+}
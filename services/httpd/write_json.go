@@ -0,0 +1,180 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// jsonSeries is a series of points of the JSON write format, for clients
+// that cannot write line protocol. The body of a write is a series, or an
+// array of series:
+//
+//	[{
+//	    "measurement": "cpu",
+//	    "tags": {"host": "server01"},
+//	    "fields": {"usage": [0.64, 0.72], "state": ["idle", null]},
+//	    "types": {"count": "integer"},
+//	    "time": [1514764800000000000, "2018-01-01T00:00:10Z"]
+//	}]
+//
+// Each field has a value for each time, or null if the point at the time does
+// not have the field. Times are integers in the precision of the write, or
+// RFC3339 strings. Without times, each field has a single value, and the
+// point is written at the current time.
+//
+// Numbers are written as floats, unless the types set their fields to
+// "integer" or "unsigned". Strings and booleans are written as such.
+type jsonSeries struct {
+	Measurement string                   `json:"measurement"`
+	Tags        map[string]string        `json:"tags"`
+	Fields      map[string][]interface{} `json:"fields"`
+	Types       map[string]string        `json:"types"`
+	Time        []interface{}            `json:"time"`
+}
+
+// isJSONWrite returns true if the body of the write request r is in the JSON
+// write format.
+func isJSONWrite(r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mt == "application/json"
+}
+
+// parseJSONPoints parses the points of a body in the JSON write format.
+// Integer times are in the precision, and points without times are written
+// at now.
+func parseJSONPoints(b []byte, now time.Time, precision string) ([]models.Point, error) {
+	var series []jsonSeries
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		if err := dec.Decode(&series); err != nil {
+			return nil, fmt.Errorf("unable to parse JSON points: %s", err)
+		}
+	} else {
+		var s jsonSeries
+		if err := dec.Decode(&s); err != nil {
+			return nil, fmt.Errorf("unable to parse JSON points: %s", err)
+		}
+		series = append(series, s)
+	}
+
+	var points []models.Point
+	for i, s := range series {
+		pts, err := s.points(now, precision)
+		if err != nil {
+			return nil, fmt.Errorf("series %d: %s", i, err)
+		}
+		points = append(points, pts...)
+	}
+	return points, nil
+}
+
+// points returns the points of the series.
+func (s *jsonSeries) points(now time.Time, precision string) ([]models.Point, error) {
+	if s.Measurement == "" {
+		return nil, fmt.Errorf("missing measurement")
+	}
+
+	n := len(s.Time)
+	if s.Time == nil {
+		n = 1
+	}
+	for k, values := range s.Fields {
+		if len(values) != n {
+			return nil, fmt.Errorf("field %q has %d values for %d times", k, len(values), n)
+		}
+	}
+	for k, typ := range s.Types {
+		switch typ {
+		case "float", "integer", "unsigned", "string", "boolean":
+		default:
+			return nil, fmt.Errorf("invalid type %q of field %q", typ, k)
+		}
+	}
+
+	tags := models.NewTags(s.Tags)
+	points := make([]models.Point, 0, n)
+	for i := 0; i < n; i++ {
+		t := now
+		if s.Time != nil {
+			var err error
+			if t, err = parseJSONTime(s.Time[i], precision); err != nil {
+				return nil, fmt.Errorf("point %d: %s", i, err)
+			}
+		}
+
+		fields := make(models.Fields, len(s.Fields))
+		for k, values := range s.Fields {
+			if values[i] == nil {
+				continue
+			}
+			v, err := jsonFieldValue(values[i], s.Types[k])
+			if err != nil {
+				return nil, fmt.Errorf("point %d: field %q: %s", i, k, err)
+			}
+			fields[k] = v
+		}
+
+		pt, err := models.NewPoint(s.Measurement, tags, fields, t)
+		if err != nil {
+			return nil, fmt.Errorf("point %d: %s", i, err)
+		}
+		points = append(points, pt)
+	}
+	return points, nil
+}
+
+// parseJSONTime parses a time of the JSON write format, an integer in the
+// precision or an RFC3339 string.
+func parseJSONTime(v interface{}, precision string) (time.Time, error) {
+	switch v := v.(type) {
+	case json.Number:
+		ts, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %s", v)
+		}
+		return models.SafeCalcTime(ts, precision)
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q", v)
+		}
+		return t.UTC(), models.CheckTime(t)
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %v", v)
+	}
+}
+
+// jsonFieldValue returns the field value of the JSON value v, of the type typ
+// if it is set.
+func jsonFieldValue(v interface{}, typ string) (interface{}, error) {
+	switch v := v.(type) {
+	case json.Number:
+		switch typ {
+		case "", "float":
+			return strconv.ParseFloat(string(v), 64)
+		case "integer":
+			return strconv.ParseInt(string(v), 10, 64)
+		case "unsigned":
+			return strconv.ParseUint(string(v), 10, 64)
+		}
+	case string:
+		if typ == "" || typ == "string" {
+			return v, nil
+		}
+	case bool:
+		if typ == "" || typ == "boolean" {
+			return v, nil
+		}
+	default:
+		return nil, fmt.Errorf("invalid value %v", v)
+	}
+	return nil, fmt.Errorf("value %v is not of type %s", v, typ)
+}
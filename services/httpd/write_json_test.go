@@ -0,0 +1,80 @@
+package httpd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseJSONPoints(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		body      string
+		precision string
+		points    []string
+		err       string
+	}{
+		{
+			body:      `[{"measurement":"cpu","tags":{"host":"server01"},"fields":{"usage":[0.64,0.72],"state":["idle",null]},"time":[1514764800,"2018-01-01T00:00:10Z"]}]`,
+			precision: "s",
+			points: []string{
+				`cpu,host=server01 state="idle",usage=0.64 1514764800000000000`,
+				`cpu,host=server01 usage=0.72 1514764810000000000`,
+			},
+		},
+		{
+			body: `{"measurement":"mem","fields":{"free":[1024],"swap":[0],"ok":[true]},"types":{"free":"integer","swap":"unsigned"}}`,
+			points: []string{
+				`mem free=1024i,ok=true,swap=0u 1514764800000000000`,
+			},
+		},
+		{
+			body: `{"fields":{"value":[1]}}`,
+			err:  `series 0: missing measurement`,
+		},
+		{
+			body: `{"measurement":"cpu","fields":{"value":[1,2]},"time":[1]}`,
+			err:  `series 0: field "value" has 2 values for 1 times`,
+		},
+		{
+			body: `{"measurement":"cpu","fields":{"value":[1.5]},"types":{"value":"integer"}}`,
+			err:  `series 0: point 0: field "value": strconv.ParseInt: parsing "1.5": invalid syntax`,
+		},
+		{
+			body: `{"measurement":"cpu","fields":{"value":["a"]},"types":{"value":"float"}}`,
+			err:  `series 0: point 0: field "value": value a is not of type float`,
+		},
+		{
+			body: `{"measurement":"cpu","fields":{"value":[null]}}`,
+			err:  `series 0: point 0: point without fields is unsupported`,
+		},
+		{
+			body: `{"measurement":"cpu","fields":{"value":[1]},"time":["yesterday"]}`,
+			err:  `series 0: point 0: invalid time "yesterday"`,
+		},
+		{
+			body: `[{"measurement":"cpu"`,
+			err:  `unable to parse JSON points: unexpected EOF`,
+		},
+	} {
+		points, err := parseJSONPoints([]byte(tt.body), now, tt.precision)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: unexpected error: %v", tt.body, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.body, err)
+			continue
+		}
+
+		if len(points) != len(tt.points) {
+			t.Errorf("%s: unexpected points: %v", tt.body, points)
+			continue
+		}
+		for i, pt := range points {
+			if pt.String() != tt.points[i] {
+				t.Errorf("%s: unexpected point %d: %s", tt.body, i, pt.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package kafka
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxResponseSize is the largest response accepted from a broker.
+const maxResponseSize = 256 * 1024 * 1024
+
+// broker is a connection to a Kafka broker. Requests are sent one at a time.
+type broker struct {
+	mu            sync.Mutex
+	conn          net.Conn
+	r             *bufio.Reader
+	clientID      string
+	correlationID int32
+	timeout       time.Duration
+}
+
+// dialBroker connects to the broker at addr, with TLS if tlsConfig is set,
+// and authenticates if c sets a SASL mechanism.
+func dialBroker(addr string, c *Config, tlsConfig *tls.Config) (*broker, error) {
+	timeout := time.Duration(c.DialTimeout)
+
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := &broker{
+		conn:     conn,
+		r:        bufio.NewReader(conn),
+		clientID: c.ClientID,
+		timeout:  timeout,
+	}
+
+	if c.SASLMechanism != "" {
+		if err := b.authenticate(c.SASLMechanism, c.SASLUsername, c.SASLPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("SASL authentication with %s failed: %s", addr, err)
+		}
+	}
+	return b, nil
+}
+
+// request sends a request for the given API and returns a decoder for the
+// body of the response. The broker is given wait in addition to the request
+// timeout to respond, for requests the broker may hold.
+func (b *broker) request(apiKey, apiVersion int16, body []byte, wait time.Duration) (*decoder, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.correlationID++
+	var e encoder
+	e.int32(0) // size
+	e.int16(apiKey)
+	e.int16(apiVersion)
+	e.int32(b.correlationID)
+	e.string(b.clientID)
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+
+	b.conn.SetDeadline(time.Now().Add(b.timeout + wait))
+	defer b.conn.SetDeadline(time.Time{})
+
+	if _, err := b.conn.Write(e.buf); err != nil {
+		return nil, err
+	}
+
+	var header [8]byte
+	if _, err := io.ReadFull(b.r, header[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(header[:]))
+	if size < 4 || size > maxResponseSize {
+		return nil, fmt.Errorf("kafka: invalid response size %d", size)
+	} else if id := int32(binary.BigEndian.Uint32(header[4:])); id != b.correlationID {
+		return nil, fmt.Errorf("kafka: unexpected correlation id %d", id)
+	}
+
+	resp := make([]byte, size-4)
+	if _, err := io.ReadFull(b.r, resp); err != nil {
+		return nil, err
+	}
+	return &decoder{buf: resp}, nil
+}
+
+// authenticate authenticates with mechanism using the SaslHandshake and
+// SaslAuthenticate APIs, supported by Kafka 1.0 and later.
+func (b *broker) authenticate(mechanism, username, password string) error {
+	d, err := b.request(apiSaslHandshake, 1, encodeSaslHandshakeRequest(mechanism), 0)
+	if err != nil {
+		return err
+	} else if err := decodeSaslHandshakeResponse(d); err != nil {
+		return err
+	}
+
+	switch mechanism {
+	case SASLPlain:
+		_, err := b.saslAuthenticate([]byte("\x00" + username + "\x00" + password))
+		return err
+	case SASLScramSHA256, SASLScramSHA512:
+		s := newScramClient(mechanism, username, password)
+		resp, err := b.saslAuthenticate(s.first())
+		if err != nil {
+			return err
+		}
+		final, err := s.final(resp)
+		if err != nil {
+			return err
+		}
+		if resp, err = b.saslAuthenticate(final); err != nil {
+			return err
+		}
+		return s.verify(resp)
+	default:
+		return fmt.Errorf("unsupported SASL mechanism %q", mechanism)
+	}
+}
+
+func (b *broker) saslAuthenticate(auth []byte) ([]byte, error) {
+	d, err := b.request(apiSaslAuthenticate, 0, encodeSaslAuthenticateRequest(auth), 0)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSaslAuthenticateResponse(d)
+}
+
+// metadata returns the metadata of topics.
+func (b *broker) metadata(topics []string) (*metadata, error) {
+	d, err := b.request(apiMetadata, 1, encodeMetadataRequest(topics), 0)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMetadataResponse(d)
+}
+
+// findCoordinator returns the coordinator of group.
+func (b *broker) findCoordinator(group string) (brokerInfo, error) {
+	d, err := b.request(apiFindCoordinator, 0, encodeFindCoordinatorRequest(group), 0)
+	if err != nil {
+		return brokerInfo{}, err
+	}
+	return decodeFindCoordinatorResponse(d)
+}
+
+// listOffsets returns the offsets of partitions at timestamp, which is
+// either timestampOldest or timestampNewest.
+func (b *broker) listOffsets(partitions []topicPartition, timestamp int64) (map[topicPartition]int64, error) {
+	d, err := b.request(apiListOffsets, 1, encodeListOffsetsRequest(partitions, timestamp), 0)
+	if err != nil {
+		return nil, err
+	}
+	return decodeListOffsetsResponse(d)
+}
+
+// fetch fetches records of partitions from their offsets, waiting up to
+// maxWait for records to be available.
+func (b *broker) fetch(offsets map[topicPartition]int64, partitions []topicPartition, maxWait time.Duration, maxBytes int) ([]fetchedPartition, error) {
+	body := encodeFetchRequest(offsets, partitions, int32(maxWait/time.Millisecond), int32(maxBytes))
+	d, err := b.request(apiFetch, 4, body, maxWait)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFetchResponse(d)
+}
+
+// Close closes the connection.
+func (b *broker) Close() error {
+	return b.conn.Close()
+}
@@ -0,0 +1,252 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/influxdata/influxdb/monitor/diagnostics"
+	"github.com/influxdata/influxdb/toml"
+)
+
+const (
+	// DefaultConsumerGroup is the default consumer group of the service.
+	DefaultConsumerGroup = "influxdb"
+
+	// DefaultClientID is the default client identifier sent to the brokers.
+	DefaultClientID = "influxdb"
+
+	// DefaultOffset is the default offset consumed from when the consumer
+	// group has no committed offset for a partition.
+	DefaultOffset = OffsetNewest
+
+	// DefaultDatabase is the default database for Kafka points.
+	DefaultDatabase = "kafka"
+
+	// DefaultRetentionPolicy is the default retention policy used for writes.
+	DefaultRetentionPolicy = ""
+
+	// DefaultBatchSize is the default Kafka batch size.
+	DefaultBatchSize = 5000
+
+	// DefaultBatchTimeout is the default Kafka batch timeout.
+	DefaultBatchTimeout = time.Second
+
+	// DefaultFetchMaxBytes is the default number of bytes fetched from a
+	// partition per request.
+	DefaultFetchMaxBytes = 1024 * 1024
+
+	// DefaultSessionTimeout is the default time after which the consumer
+	// group coordinator considers the service dead without heartbeats.
+	DefaultSessionTimeout = 10 * time.Second
+
+	// DefaultHeartbeatInterval is the default interval between heartbeats
+	// to the consumer group coordinator.
+	DefaultHeartbeatInterval = 3 * time.Second
+
+	// DefaultDialTimeout is the default time allowed to connect to a broker.
+	DefaultDialTimeout = 10 * time.Second
+
+	// DefaultReconnectInterval is the default time waited before
+	// reconnecting after an error.
+	DefaultReconnectInterval = 5 * time.Second
+)
+
+// Offsets consumed from when the consumer group has no committed offset for
+// a partition.
+const (
+	// OffsetOldest consumes the oldest messages retained by the brokers.
+	OffsetOldest = "oldest"
+
+	// OffsetNewest only consumes messages produced from now on.
+	OffsetNewest = "newest"
+)
+
+// SASL mechanisms supported to authenticate with the brokers.
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// Config represents the configuration of a Kafka service.
+type Config struct {
+	Enabled bool `toml:"enabled"`
+
+	// Brokers are the addresses of the brokers used to discover the cluster.
+	Brokers       []string `toml:"brokers"`
+	Topics        []string `toml:"topics"`
+	ConsumerGroup string   `toml:"consumer-group"`
+	ClientID      string   `toml:"client-id"`
+	Offset        string   `toml:"offset"`
+
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+	Precision       string `toml:"precision"`
+
+	BatchSize     int           `toml:"batch-size"`
+	BatchTimeout  toml.Duration `toml:"batch-timeout"`
+	FetchMaxBytes int           `toml:"fetch-max-bytes"`
+
+	SessionTimeout    toml.Duration `toml:"session-timeout"`
+	HeartbeatInterval toml.Duration `toml:"heartbeat-interval"`
+	DialTimeout       toml.Duration `toml:"dial-timeout"`
+	ReconnectInterval toml.Duration `toml:"reconnect-interval"`
+
+	// TLS settings. The system certificate pool is used unless TLSCA is
+	// set, and a client certificate is only presented if TLSCert and TLSKey
+	// are set.
+	TLSEnabled         bool   `toml:"tls-enabled"`
+	TLSCA              string `toml:"tls-ca"`
+	TLSCert            string `toml:"tls-cert"`
+	TLSKey             string `toml:"tls-key"`
+	InsecureSkipVerify bool   `toml:"insecure-skip-verify"`
+
+	// SASL authentication is used if SASLMechanism is set.
+	SASLMechanism string `toml:"sasl-mechanism"`
+	SASLUsername  string `toml:"sasl-username"`
+	SASLPassword  string `toml:"sasl-password"`
+}
+
+// NewConfig returns a new instance of Config with defaults.
+func NewConfig() Config {
+	return Config{
+		ConsumerGroup:     DefaultConsumerGroup,
+		ClientID:          DefaultClientID,
+		Offset:            DefaultOffset,
+		Database:          DefaultDatabase,
+		RetentionPolicy:   DefaultRetentionPolicy,
+		BatchSize:         DefaultBatchSize,
+		BatchTimeout:      toml.Duration(DefaultBatchTimeout),
+		FetchMaxBytes:     DefaultFetchMaxBytes,
+		SessionTimeout:    toml.Duration(DefaultSessionTimeout),
+		HeartbeatInterval: toml.Duration(DefaultHeartbeatInterval),
+		DialTimeout:       toml.Duration(DefaultDialTimeout),
+		ReconnectInterval: toml.Duration(DefaultReconnectInterval),
+	}
+}
+
+// WithDefaults takes the given config and returns a new config with any required
+// default values set.
+func (c *Config) WithDefaults() *Config {
+	d := *c
+	if d.ConsumerGroup == "" {
+		d.ConsumerGroup = DefaultConsumerGroup
+	}
+	if d.ClientID == "" {
+		d.ClientID = DefaultClientID
+	}
+	if d.Offset == "" {
+		d.Offset = DefaultOffset
+	}
+	if d.Database == "" {
+		d.Database = DefaultDatabase
+	}
+	if d.BatchSize == 0 {
+		d.BatchSize = DefaultBatchSize
+	}
+	if d.BatchTimeout == 0 {
+		d.BatchTimeout = toml.Duration(DefaultBatchTimeout)
+	}
+	if d.FetchMaxBytes == 0 {
+		d.FetchMaxBytes = DefaultFetchMaxBytes
+	}
+	if d.SessionTimeout == 0 {
+		d.SessionTimeout = toml.Duration(DefaultSessionTimeout)
+	}
+	if d.HeartbeatInterval == 0 {
+		d.HeartbeatInterval = toml.Duration(DefaultHeartbeatInterval)
+	}
+	if d.DialTimeout == 0 {
+		d.DialTimeout = toml.Duration(DefaultDialTimeout)
+	}
+	if d.ReconnectInterval == 0 {
+		d.ReconnectInterval = toml.Duration(DefaultReconnectInterval)
+	}
+	return &d
+}
+
+// Validate returns an error if the config is invalid.
+func (c *Config) Validate() error {
+	for _, addr := range c.Brokers {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid broker address %q", addr)
+		}
+	}
+
+	for _, topic := range c.Topics {
+		if topic == "" {
+			return errors.New("topics must not contain empty topics")
+		}
+	}
+
+	switch c.Offset {
+	case "", OffsetOldest, OffsetNewest:
+	default:
+		return errors.New(`Invalid value for offset. Valid options are "oldest" and "newest"`)
+	}
+
+	switch c.Precision {
+	case "", "n", "u", "ms", "s", "m", "h":
+	default:
+		return fmt.Errorf("invalid precision %q", c.Precision)
+	}
+
+	if c.BatchSize < 0 {
+		return errors.New("batch-size must not be negative")
+	}
+	if c.FetchMaxBytes < 0 {
+		return errors.New("fetch-max-bytes must not be negative")
+	}
+	if c.HeartbeatInterval > 0 && c.SessionTimeout > 0 && c.HeartbeatInterval >= c.SessionTimeout {
+		return errors.New("heartbeat-interval must be lower than session-timeout")
+	}
+
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("tls-cert and tls-key must be set together")
+	}
+
+	switch c.SASLMechanism {
+	case "":
+	case SASLPlain, SASLScramSHA256, SASLScramSHA512:
+		if c.SASLUsername == "" {
+			return errors.New("sasl-username has to be specified with sasl-mechanism")
+		}
+	default:
+		return errors.New(`Invalid value for sasl-mechanism. Valid options are "PLAIN", "SCRAM-SHA-256" and "SCRAM-SHA-512"`)
+	}
+	return nil
+}
+
+// Configs wraps a slice of Config to aggregate diagnostics.
+type Configs []Config
+
+// Diagnostics returns one set of diagnostics for all of the Configs.
+func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
+	d := &diagnostics.Diagnostics{
+		Columns: []string{"enabled", "brokers", "topics", "consumer-group", "database", "retention-policy", "batch-size", "batch-timeout"},
+	}
+
+	for _, cc := range c {
+		if !cc.Enabled {
+			d.AddRow([]interface{}{false})
+			continue
+		}
+
+		r := []interface{}{true, cc.Brokers, cc.Topics, cc.ConsumerGroup, cc.Database, cc.RetentionPolicy, cc.BatchSize, cc.BatchTimeout}
+		d.AddRow(r)
+	}
+
+	return d, nil
+}
+
+// Enabled returns true if any underlying Config is Enabled.
+func (c Configs) Enabled() bool {
+	for _, cc := range c {
+		if cc.Enabled {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package kafka_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdata/influxdb/services/kafka"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	var c kafka.Config
+	if _, err := toml.Decode(`
+enabled = true
+brokers = ["kafka-1:9092", "kafka-2:9092"]
+topics = ["metrics", "events"]
+consumer-group = "influxdb-1"
+offset = "oldest"
+database = "telegraf"
+precision = "ms"
+batch-size = 100
+fetch-max-bytes = 65536
+session-timeout = "30s"
+tls-enabled = true
+tls-ca = "/etc/ssl/ca.pem"
+sasl-mechanism = "SCRAM-SHA-512"
+sasl-username = "user"
+sasl-password = "pass"
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled: %v", c.Enabled)
+	} else if len(c.Brokers) != 2 || c.Brokers[0] != "kafka-1:9092" || c.Brokers[1] != "kafka-2:9092" {
+		t.Fatalf("unexpected brokers: %v", c.Brokers)
+	} else if len(c.Topics) != 2 || c.Topics[0] != "metrics" || c.Topics[1] != "events" {
+		t.Fatalf("unexpected topics: %v", c.Topics)
+	} else if c.ConsumerGroup != "influxdb-1" {
+		t.Fatalf("unexpected consumer group: %s", c.ConsumerGroup)
+	} else if c.Offset != kafka.OffsetOldest {
+		t.Fatalf("unexpected offset: %s", c.Offset)
+	} else if c.Database != "telegraf" {
+		t.Fatalf("unexpected database: %s", c.Database)
+	} else if c.Precision != "ms" {
+		t.Fatalf("unexpected precision: %s", c.Precision)
+	} else if c.BatchSize != 100 {
+		t.Fatalf("unexpected batch size: %d", c.BatchSize)
+	} else if c.FetchMaxBytes != 65536 {
+		t.Fatalf("unexpected fetch max bytes: %d", c.FetchMaxBytes)
+	} else if time.Duration(c.SessionTimeout) != 30*time.Second {
+		t.Fatalf("unexpected session timeout: %v", c.SessionTimeout)
+	} else if !c.TLSEnabled || c.TLSCA != "/etc/ssl/ca.pem" {
+		t.Fatalf("unexpected tls settings: %v %s", c.TLSEnabled, c.TLSCA)
+	} else if c.SASLMechanism != kafka.SASLScramSHA512 {
+		t.Fatalf("unexpected sasl mechanism: %s", c.SASLMechanism)
+	} else if c.SASLUsername != "user" || c.SASLPassword != "pass" {
+		t.Fatalf("unexpected sasl credentials: %s:%s", c.SASLUsername, c.SASLPassword)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, test := range []struct {
+		fn  func(c *kafka.Config)
+		err bool
+	}{
+		{fn: func(c *kafka.Config) {}},
+		{fn: func(c *kafka.Config) { c.Brokers = []string{"localhost:9092"} }},
+		{fn: func(c *kafka.Config) { c.Brokers = []string{"localhost"} }, err: true},
+		{fn: func(c *kafka.Config) { c.Topics = []string{""} }, err: true},
+		{fn: func(c *kafka.Config) { c.Offset = "latest" }, err: true},
+		{fn: func(c *kafka.Config) { c.Precision = "ns" }, err: true},
+		{fn: func(c *kafka.Config) { c.BatchSize = -1 }, err: true},
+		{fn: func(c *kafka.Config) { c.HeartbeatInterval = c.SessionTimeout }, err: true},
+		{fn: func(c *kafka.Config) { c.TLSKey = "/etc/ssl/key.pem" }, err: true},
+		{fn: func(c *kafka.Config) { c.SASLMechanism = kafka.SASLPlain }, err: true},
+		{fn: func(c *kafka.Config) { c.SASLMechanism = "GSSAPI"; c.SASLUsername = "user" }, err: true},
+	} {
+		c := kafka.NewConfig()
+		test.fn(&c)
+		if err := c.Validate(); test.err && err == nil {
+			t.Errorf("%+v: expected error", c)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", c, err)
+		}
+	}
+}
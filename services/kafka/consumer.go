@@ -0,0 +1,482 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// rebalanceTimeout is the time allowed to the members of the consumer
+	// group to rejoin it during a rebalance.
+	rebalanceTimeout = time.Minute
+
+	// fetchMaxWait is the time brokers may wait for records to be available
+	// before answering a fetch.
+	fetchMaxWait = 500 * time.Millisecond
+)
+
+var (
+	errNoBrokers      = errors.New("kafka: no brokers available")
+	errConsumerClosed = errors.New("kafka: consumer closed")
+)
+
+// consumer consumes the partitions assigned to the service as a member of
+// its consumer group.
+type consumer struct {
+	config    *Config
+	tlsConfig *tls.Config
+	logger    *zap.Logger
+
+	mu          sync.Mutex
+	closed      bool
+	bootstrap   *broker
+	coordinator *broker
+	leaders     map[int32]*broker // Connections to partition leaders by node id.
+
+	metadata     *metadata
+	memberID     string
+	generationID int32
+	partitions   []topicPartition         // Assigned partitions.
+	offsets      map[topicPartition]int64 // Next offsets to fetch.
+	committed    map[topicPartition]int64 // Offsets committed for the group.
+
+	rebalance     int32 // Set by heartbeats when the group rebalances.
+	heartbeatDone chan struct{}
+	heartbeatWG   sync.WaitGroup
+}
+
+// newConsumer returns a consumer for the topics and group of config.
+func newConsumer(config *Config, tlsConfig *tls.Config, logger *zap.Logger) *consumer {
+	return &consumer{
+		config:    config,
+		tlsConfig: tlsConfig,
+		logger:    logger,
+		leaders:   make(map[int32]*broker),
+	}
+}
+
+// connect connects to the first available broker and to the coordinator of
+// the consumer group.
+func (c *consumer) connect() error {
+	var err error
+	for _, addr := range c.config.Brokers {
+		var b *broker
+		if b, err = c.dial(addr); err != nil {
+			c.logger.Info("Failed to connect to Kafka broker", zap.String("broker", addr), zap.Error(err))
+			continue
+		}
+		c.mu.Lock()
+		c.bootstrap = b
+		c.mu.Unlock()
+		break
+	}
+	if c.bootstrap == nil {
+		if err == nil {
+			err = errNoBrokers
+		}
+		return err
+	}
+
+	info, err := c.bootstrap.findCoordinator(c.config.ConsumerGroup)
+	if err != nil {
+		return err
+	}
+	coordinator, err := c.dial(info.addr())
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.coordinator = coordinator
+	c.mu.Unlock()
+	return nil
+}
+
+// dial connects to the broker at addr unless the consumer is closed.
+func (c *consumer) dial(addr string) (*broker, error) {
+	b, err := dialBroker(addr, c.config, c.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		b.Close()
+		return nil, errConsumerClosed
+	}
+	return b, nil
+}
+
+// join joins the consumer group, assigning the partitions of the group to
+// its members if the service is elected leader, and starts sending
+// heartbeats. It fetches the committed offsets of the partitions assigned to
+// the service.
+func (c *consumer) join() error {
+	c.stopHeartbeats()
+	atomic.StoreInt32(&c.rebalance, 0)
+
+	var joined *joinGroupResponse
+	for {
+		body := encodeJoinGroupRequest(c.config.ConsumerGroup, c.memberID,
+			int32(time.Duration(c.config.SessionTimeout)/time.Millisecond),
+			int32(rebalanceTimeout/time.Millisecond), c.config.Topics)
+		d, err := c.coordinator.request(apiJoinGroup, 1, body, rebalanceTimeout)
+		if err != nil {
+			return err
+		}
+
+		joined, err = decodeJoinGroupResponse(d)
+		if err == kafkaError(errUnknownMemberID) && c.memberID != "" {
+			c.memberID = ""
+			continue
+		} else if err != nil {
+			return err
+		}
+		break
+	}
+	c.memberID, c.generationID = joined.memberID, joined.generationID
+
+	var assignments map[string]map[string][]int32
+	if joined.leaderID == joined.memberID {
+		topics := make(map[string]struct{})
+		for _, m := range joined.members {
+			for _, topic := range m.topics {
+				topics[topic] = struct{}{}
+			}
+		}
+		names := make([]string, 0, len(topics))
+		for topic := range topics {
+			names = append(names, topic)
+		}
+		sort.Strings(names)
+
+		m, err := c.bootstrap.metadata(names)
+		if err != nil {
+			return err
+		}
+		assignments = rangeAssign(joined.members, m.topics)
+	}
+
+	body := encodeSyncGroupRequest(c.config.ConsumerGroup, c.generationID, c.memberID, assignments)
+	d, err := c.coordinator.request(apiSyncGroup, 0, body, rebalanceTimeout)
+	if err != nil {
+		return err
+	}
+	assignment, err := decodeSyncGroupResponse(d)
+	if err != nil {
+		return err
+	}
+
+	c.partitions = c.partitions[:0]
+	var topics []string
+	for topic, partitions := range assignment {
+		topics = append(topics, topic)
+		for _, p := range partitions {
+			c.partitions = append(c.partitions, topicPartition{topic, p})
+		}
+	}
+	sort.Slice(c.partitions, func(i, j int) bool {
+		a, b := c.partitions[i], c.partitions[j]
+		return a.topic < b.topic || (a.topic == b.topic && a.partition < b.partition)
+	})
+	c.logger.Info("Joined Kafka consumer group",
+		zap.String("group", c.config.ConsumerGroup),
+		zap.Int32("generation", c.generationID),
+		zap.Int("partitions", len(c.partitions)))
+
+	c.heartbeatDone = make(chan struct{})
+	c.heartbeatWG.Add(1)
+	go c.heartbeat(c.heartbeatDone, c.generationID, c.memberID)
+
+	if len(c.partitions) == 0 {
+		c.offsets, c.committed = nil, nil
+		return nil
+	}
+
+	if c.metadata, err = c.bootstrap.metadata(topics); err != nil {
+		return err
+	}
+	return c.fetchOffsets()
+}
+
+// fetchOffsets fetches the committed offsets of the assigned partitions.
+// Partitions without a committed offset are consumed from the offset set in
+// the configuration.
+func (c *consumer) fetchOffsets() error {
+	d, err := c.coordinator.request(apiOffsetFetch, 1, encodeOffsetFetchRequest(c.config.ConsumerGroup, c.partitions), 0)
+	if err != nil {
+		return err
+	}
+	committed, err := decodeOffsetFetchResponse(d)
+	if err != nil {
+		return err
+	}
+
+	c.offsets = make(map[topicPartition]int64, len(c.partitions))
+	c.committed = make(map[topicPartition]int64, len(c.partitions))
+	var reset []topicPartition
+	for _, tp := range c.partitions {
+		if offset, ok := committed[tp]; ok && offset >= 0 {
+			c.offsets[tp], c.committed[tp] = offset, offset
+		} else {
+			reset = append(reset, tp)
+		}
+	}
+	return c.resetOffsets(reset)
+}
+
+// resetOffsets sets the offsets of partitions to the oldest or newest
+// offset, according to the configuration.
+func (c *consumer) resetOffsets(partitions []topicPartition) error {
+	timestamp := int64(timestampNewest)
+	if c.config.Offset == OffsetOldest {
+		timestamp = timestampOldest
+	}
+
+	for leader, partitions := range c.byLeader(partitions) {
+		b, err := c.leader(leader)
+		if err != nil {
+			return err
+		}
+		offsets, err := b.listOffsets(partitions, timestamp)
+		if err != nil {
+			return err
+		}
+		for tp, offset := range offsets {
+			c.offsets[tp] = offset
+		}
+	}
+	return nil
+}
+
+// byLeader groups partitions by the node id of their leader.
+func (c *consumer) byLeader(partitions []topicPartition) map[int32][]topicPartition {
+	m := make(map[int32][]topicPartition)
+	for _, tp := range partitions {
+		leader := c.metadata.leaders[tp]
+		m[leader] = append(m[leader], tp)
+	}
+	return m
+}
+
+// leader returns a connection to the broker with the given node id.
+func (c *consumer) leader(id int32) (*broker, error) {
+	c.mu.Lock()
+	b := c.leaders[id]
+	c.mu.Unlock()
+	if b != nil {
+		return b, nil
+	}
+
+	info, ok := c.metadata.brokers[id]
+	if !ok {
+		return nil, kafkaError(5) // leader not available
+	}
+	b, err := c.dial(info.addr())
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.leaders[id] = b
+	c.mu.Unlock()
+	return b, nil
+}
+
+// fetch fetches the next records of the assigned partitions. It returns the
+// records along with the offset following the records fetched from every
+// partition.
+func (c *consumer) fetch() (map[topicPartition][]record, map[topicPartition]int64, error) {
+	if len(c.partitions) == 0 {
+		// Nothing is assigned, wait as a fetch would.
+		time.Sleep(fetchMaxWait)
+		return nil, nil, nil
+	}
+
+	records := make(map[topicPartition][]record)
+	next := make(map[topicPartition]int64)
+	for leader, partitions := range c.byLeader(c.partitions) {
+		b, err := c.leader(leader)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		fetched, err := b.fetch(c.offsets, partitions, fetchMaxWait, c.config.FetchMaxBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var outOfRange []topicPartition
+		for _, fp := range fetched {
+			if fp.err == kafkaError(errOffsetOutOfRange) {
+				outOfRange = append(outOfRange, fp.topicPartition)
+				continue
+			} else if fp.err != nil {
+				return nil, nil, fp.err
+			}
+
+			offset := c.offsets[fp.topicPartition]
+			rs, n, err := decodeRecords(fp.records, offset)
+			if err != nil {
+				return nil, nil, err
+			}
+			if n > offset {
+				records[fp.topicPartition] = rs
+				next[fp.topicPartition] = n
+				c.offsets[fp.topicPartition] = n
+			}
+		}
+
+		if len(outOfRange) > 0 {
+			c.logger.Info("Kafka offsets out of range, resetting", zap.String("offset", c.config.Offset))
+			if err := c.resetOffsets(outOfRange); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return records, next, nil
+}
+
+// commit commits offsets for the consumer group.
+func (c *consumer) commit(offsets map[topicPartition]int64) error {
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	body := encodeOffsetCommitRequest(c.config.ConsumerGroup, c.generationID, c.memberID, offsets)
+	d, err := c.coordinator.request(apiOffsetCommit, 2, body, 0)
+	if err != nil {
+		return err
+	} else if err := decodeOffsetCommitResponse(d); err != nil {
+		return err
+	}
+
+	for tp, offset := range offsets {
+		c.committed[tp] = offset
+	}
+	return nil
+}
+
+// rewind resets the offsets to fetch to the committed offsets, so records
+// that could not be written are fetched again.
+func (c *consumer) rewind() {
+	for tp, offset := range c.committed {
+		c.offsets[tp] = offset
+	}
+}
+
+// rebalancing returns true if the consumer must rejoin its group.
+func (c *consumer) rebalancing() bool {
+	return atomic.LoadInt32(&c.rebalance) != 0
+}
+
+// heartbeat sends heartbeats to the coordinator until done is closed or the
+// group rebalances.
+func (c *consumer) heartbeat(done chan struct{}, generationID int32, memberID string) {
+	defer c.heartbeatWG.Done()
+
+	ticker := time.NewTicker(time.Duration(c.config.HeartbeatInterval))
+	defer ticker.Stop()
+
+	body := encodeHeartbeatRequest(c.config.ConsumerGroup, generationID, memberID)
+	for {
+		select {
+		case <-ticker.C:
+			d, err := c.coordinator.request(apiHeartbeat, 0, body, 0)
+			if err == nil {
+				err = decodeErrorResponse(d)
+			}
+			if err != nil {
+				if !rebalancing(err) {
+					c.logger.Info("Kafka heartbeat failed", zap.Error(err))
+				}
+				atomic.StoreInt32(&c.rebalance, 1)
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// stopHeartbeats stops sending heartbeats.
+func (c *consumer) stopHeartbeats() {
+	if c.heartbeatDone != nil {
+		close(c.heartbeatDone)
+		c.heartbeatWG.Wait()
+		c.heartbeatDone = nil
+	}
+}
+
+// leave leaves the consumer group, so its partitions are reassigned without
+// waiting for the session to time out.
+func (c *consumer) leave() {
+	c.stopHeartbeats()
+	if c.coordinator == nil || c.memberID == "" {
+		return
+	}
+	if d, err := c.coordinator.request(apiLeaveGroup, 0, encodeLeaveGroupRequest(c.config.ConsumerGroup, c.memberID), 0); err == nil {
+		decodeErrorResponse(d)
+	}
+}
+
+// Close closes the connections of the consumer. Pending requests fail.
+func (c *consumer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for _, b := range []*broker{c.bootstrap, c.coordinator} {
+		if b != nil {
+			b.Close()
+		}
+	}
+	for _, b := range c.leaders {
+		b.Close()
+	}
+	return nil
+}
+
+// rangeAssign assigns the partitions of every topic to the members
+// subscribed to it, the way the range assignor of the Kafka clients does:
+// every member gets a range of consecutive partitions, and the first members
+// get one more partition if they cannot be divided evenly.
+func rangeAssign(members []groupMember, partitions map[string][]int32) map[string]map[string][]int32 {
+	assignments := make(map[string]map[string][]int32, len(members))
+	subscribers := make(map[string][]string)
+	for _, m := range members {
+		assignments[m.id] = make(map[string][]int32)
+		for _, topic := range m.topics {
+			subscribers[topic] = append(subscribers[topic], m.id)
+		}
+	}
+
+	for topic, ids := range subscribers {
+		ps := append([]int32(nil), partitions[topic]...)
+		if len(ps) == 0 {
+			continue
+		}
+		sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+		sort.Strings(ids)
+
+		n, extra := len(ps)/len(ids), len(ps)%len(ids)
+		start := 0
+		for i, id := range ids {
+			count := n
+			if i < extra {
+				count++
+			}
+			if count > 0 {
+				assignments[id][topic] = ps[start : start+count]
+			}
+			start += count
+		}
+	}
+	return assignments
+}
@@ -0,0 +1,28 @@
+package kafka
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRangeAssign(t *testing.T) {
+	members := []groupMember{
+		{id: "b", topics: []string{"cpu", "mem"}},
+		{id: "a", topics: []string{"cpu"}},
+		{id: "c", topics: []string{"cpu"}},
+	}
+	partitions := map[string][]int32{
+		"cpu": {3, 0, 1, 2},
+		"mem": {0, 1},
+	}
+
+	got := rangeAssign(members, partitions)
+	exp := map[string]map[string][]int32{
+		"a": {"cpu": {0, 1}},
+		"b": {"cpu": {2}, "mem": {0, 1}},
+		"c": {"cpu": {3}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
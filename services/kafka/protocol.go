@@ -0,0 +1,708 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Keys of the Kafka protocol APIs used by the service.
+const (
+	apiFetch            = 1
+	apiListOffsets      = 2
+	apiMetadata         = 3
+	apiOffsetCommit     = 8
+	apiOffsetFetch      = 9
+	apiFindCoordinator  = 10
+	apiJoinGroup        = 11
+	apiHeartbeat        = 12
+	apiLeaveGroup       = 13
+	apiSyncGroup        = 14
+	apiSaslHandshake    = 17
+	apiSaslAuthenticate = 36
+)
+
+// Error codes of the Kafka protocol handled by the service.
+const (
+	errNone                = 0
+	errOffsetOutOfRange    = 1
+	errIllegalGeneration   = 22
+	errUnknownMemberID     = 25
+	errRebalanceInProgress = 27
+)
+
+// kafkaErrors names the error codes most likely to be returned to the
+// service.
+var kafkaErrors = map[int16]string{
+	1:  "offset out of range",
+	3:  "unknown topic or partition",
+	5:  "leader not available",
+	6:  "not leader for partition",
+	7:  "request timed out",
+	14: "coordinator load in progress",
+	15: "coordinator not available",
+	16: "not coordinator",
+	22: "illegal generation",
+	23: "inconsistent group protocol",
+	24: "invalid group id",
+	25: "unknown member id",
+	26: "invalid session timeout",
+	27: "rebalance in progress",
+	29: "topic authorization failed",
+	30: "group authorization failed",
+	31: "cluster authorization failed",
+	33: "unsupported SASL mechanism",
+	34: "illegal SASL state",
+	35: "unsupported version",
+	58: "SASL authentication failed",
+}
+
+// kafkaError is an error code returned by a broker.
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	if s, ok := kafkaErrors[int16(e)]; ok {
+		return "kafka: " + s
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+// errorCode returns the error for code, or nil.
+func errorCode(code int16) error {
+	if code == errNone {
+		return nil
+	}
+	return kafkaError(code)
+}
+
+// rebalancing returns true if err requires the service to rejoin its
+// consumer group.
+func rebalancing(err error) bool {
+	switch err {
+	case kafkaError(errIllegalGeneration), kafkaError(errUnknownMemberID), kafkaError(errRebalanceInProgress):
+		return true
+	}
+	return false
+}
+
+var errMalformed = errors.New("kafka: malformed response")
+
+// encoder encodes the primitive types of the Kafka protocol.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int8(v int8) { e.buf = append(e.buf, byte(v)) }
+
+func (e *encoder) int16(v int16) {
+	e.buf = append(e.buf, 0, 0)
+	binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], uint16(v))
+}
+
+func (e *encoder) int32(v int32) {
+	e.buf = append(e.buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.buf = append(e.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], uint64(v))
+}
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// nullableString encodes an empty string as null.
+func (e *encoder) nullableString(s string) {
+	if s == "" {
+		e.int16(-1)
+		return
+	}
+	e.string(s)
+}
+
+// bytes encodes nil as null.
+func (e *encoder) bytes(b []byte) {
+	if b == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) arrayLen(n int) { e.int32(int32(n)) }
+
+func (e *encoder) strings(a []string) {
+	e.arrayLen(len(a))
+	for _, s := range a {
+		e.string(s)
+	}
+}
+
+// decoder decodes the primitive types of the Kafka protocol. Once the
+// buffer is exhausted, all methods return zero values and err is set.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	} else if n < 0 || len(d.buf) < n {
+		d.err = errMalformed
+		d.buf = nil
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) bool() bool { return d.int8() != 0 }
+
+func (d *decoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string decodes a string or nullable string. Null decodes as "".
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// bytes decodes bytes or nullable bytes. Null decodes as nil.
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// arrayLen decodes the length of an array. A null array has no elements.
+func (d *decoder) arrayLen() int {
+	n := int(d.int32())
+	if n < 0 {
+		return 0
+	} else if n > len(d.buf) {
+		// Every element takes at least a byte.
+		d.err = errMalformed
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) strings() []string {
+	a := make([]string, d.arrayLen())
+	for i := range a {
+		a[i] = d.string()
+	}
+	return a
+}
+
+// varint decodes a zigzag encoded variable length integer.
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errMalformed
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// varbytes decodes bytes prefixed with their varint length. A negative
+// length decodes as nil.
+func (d *decoder) varbytes() []byte {
+	n := d.varint()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// topicPartition identifies a partition of a topic.
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// brokerInfo is a broker of the cluster.
+type brokerInfo struct {
+	id   int32
+	host string
+	port int32
+}
+
+func (b brokerInfo) addr() string {
+	return fmt.Sprintf("%s:%d", b.host, b.port)
+}
+
+// metadata describes the brokers of the cluster and the partitions of topics.
+type metadata struct {
+	brokers map[int32]brokerInfo
+	leaders map[topicPartition]int32
+	topics  map[string][]int32 // partitions of every topic
+}
+
+// encodeMetadataRequest encodes a Metadata v1 request for topics.
+func encodeMetadataRequest(topics []string) []byte {
+	var e encoder
+	e.strings(topics)
+	return e.buf
+}
+
+// decodeMetadataResponse decodes a Metadata v1 response. Topics with errors,
+// such as unknown topics, are left out.
+func decodeMetadataResponse(d *decoder) (*metadata, error) {
+	m := &metadata{
+		brokers: make(map[int32]brokerInfo),
+		leaders: make(map[topicPartition]int32),
+		topics:  make(map[string][]int32),
+	}
+
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		b := brokerInfo{id: d.int32(), host: d.string(), port: d.int32()}
+		d.string() // rack
+		m.brokers[b.id] = b
+	}
+	d.int32() // controller id
+
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		code, topic := d.int16(), d.string()
+		d.bool() // is internal
+
+		var partitions []int32
+		for j, n := 0, d.arrayLen(); j < n; j++ {
+			d.int16() // error code
+			partition, leader := d.int32(), d.int32()
+			for k, n := 0, d.arrayLen(); k < n; k++ {
+				d.int32() // replicas
+			}
+			for k, n := 0, d.arrayLen(); k < n; k++ {
+				d.int32() // in-sync replicas
+			}
+			partitions = append(partitions, partition)
+			m.leaders[topicPartition{topic, partition}] = leader
+		}
+		if code == errNone {
+			m.topics[topic] = partitions
+		}
+	}
+	return m, d.err
+}
+
+// encodeFindCoordinatorRequest encodes a FindCoordinator v0 request.
+func encodeFindCoordinatorRequest(group string) []byte {
+	var e encoder
+	e.string(group)
+	return e.buf
+}
+
+// decodeFindCoordinatorResponse decodes a FindCoordinator v0 response.
+func decodeFindCoordinatorResponse(d *decoder) (brokerInfo, error) {
+	code := d.int16()
+	b := brokerInfo{id: d.int32(), host: d.string(), port: d.int32()}
+	if d.err != nil {
+		return b, d.err
+	}
+	return b, errorCode(code)
+}
+
+// groupMember is a member of a consumer group, along with the topics it
+// subscribes to.
+type groupMember struct {
+	id     string
+	topics []string
+}
+
+// joinGroupResponse is the response to a JoinGroup request.
+type joinGroupResponse struct {
+	generationID int32
+	protocol     string
+	leaderID     string
+	memberID     string
+	members      []groupMember // only sent to the leader
+}
+
+// assignor is the partition assignment strategy of the service.
+const assignor = "range"
+
+// encodeJoinGroupRequest encodes a JoinGroup v1 request, subscribing to
+// topics with the "consumer" protocol.
+func encodeJoinGroupRequest(group, memberID string, sessionTimeout, rebalanceTimeout int32, topics []string) []byte {
+	// Subscription metadata of the consumer protocol.
+	var m encoder
+	m.int16(0) // version
+	m.strings(topics)
+	m.bytes(nil) // user data
+
+	var e encoder
+	e.string(group)
+	e.int32(sessionTimeout)
+	e.int32(rebalanceTimeout)
+	e.string(memberID)
+	e.string("consumer")
+	e.arrayLen(1)
+	e.string(assignor)
+	e.bytes(m.buf)
+	return e.buf
+}
+
+// decodeJoinGroupResponse decodes a JoinGroup v1 response.
+func decodeJoinGroupResponse(d *decoder) (*joinGroupResponse, error) {
+	code := d.int16()
+	r := &joinGroupResponse{
+		generationID: d.int32(),
+		protocol:     d.string(),
+		leaderID:     d.string(),
+		memberID:     d.string(),
+	}
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		member := groupMember{id: d.string()}
+
+		m := decoder{buf: d.bytes()}
+		m.int16() // version
+		member.topics = m.strings()
+		if m.err != nil {
+			return nil, m.err
+		}
+		r.members = append(r.members, member)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return r, errorCode(code)
+}
+
+// encodeSyncGroupRequest encodes a SyncGroup v0 request. Only the leader
+// sends the assignments of the members.
+func encodeSyncGroupRequest(group string, generationID int32, memberID string, assignments map[string]map[string][]int32) []byte {
+	var e encoder
+	e.string(group)
+	e.int32(generationID)
+	e.string(memberID)
+	e.arrayLen(len(assignments))
+	for member, assignment := range assignments {
+		e.string(member)
+		e.bytes(encodeAssignment(assignment))
+	}
+	return e.buf
+}
+
+// encodeAssignment encodes the partitions assigned to a member with the
+// consumer protocol.
+func encodeAssignment(assignment map[string][]int32) []byte {
+	var e encoder
+	e.int16(0) // version
+	e.arrayLen(len(assignment))
+	for topic, partitions := range assignment {
+		e.string(topic)
+		e.arrayLen(len(partitions))
+		for _, p := range partitions {
+			e.int32(p)
+		}
+	}
+	e.bytes(nil) // user data
+	return e.buf
+}
+
+// decodeSyncGroupResponse decodes a SyncGroup v0 response and returns the
+// partitions assigned to the member.
+func decodeSyncGroupResponse(d *decoder) (map[string][]int32, error) {
+	code := d.int16()
+	b := d.bytes()
+	if d.err != nil {
+		return nil, d.err
+	} else if err := errorCode(code); err != nil {
+		return nil, err
+	}
+
+	assignment := make(map[string][]int32)
+	if len(b) == 0 {
+		return assignment, nil
+	}
+
+	a := decoder{buf: b}
+	a.int16() // version
+	for i, n := 0, a.arrayLen(); i < n; i++ {
+		topic := a.string()
+		for j, n := 0, a.arrayLen(); j < n; j++ {
+			assignment[topic] = append(assignment[topic], a.int32())
+		}
+	}
+	return assignment, a.err
+}
+
+// encodeHeartbeatRequest encodes a Heartbeat v0 request.
+func encodeHeartbeatRequest(group string, generationID int32, memberID string) []byte {
+	var e encoder
+	e.string(group)
+	e.int32(generationID)
+	e.string(memberID)
+	return e.buf
+}
+
+// encodeLeaveGroupRequest encodes a LeaveGroup v0 request.
+func encodeLeaveGroupRequest(group, memberID string) []byte {
+	var e encoder
+	e.string(group)
+	e.string(memberID)
+	return e.buf
+}
+
+// decodeErrorResponse decodes a response holding only an error code, such
+// as Heartbeat v0 and LeaveGroup v0 responses.
+func decodeErrorResponse(d *decoder) error {
+	code := d.int16()
+	if d.err != nil {
+		return d.err
+	}
+	return errorCode(code)
+}
+
+// encodePartitions encodes partitions grouped by topic, calling fn to
+// encode the fields of every partition after its index.
+func encodePartitions(e *encoder, partitions []topicPartition, fn func(tp topicPartition)) {
+	byTopic := make(map[string][]topicPartition)
+	var topics []string
+	for _, tp := range partitions {
+		if _, ok := byTopic[tp.topic]; !ok {
+			topics = append(topics, tp.topic)
+		}
+		byTopic[tp.topic] = append(byTopic[tp.topic], tp)
+	}
+
+	e.arrayLen(len(topics))
+	for _, topic := range topics {
+		e.string(topic)
+		e.arrayLen(len(byTopic[topic]))
+		for _, tp := range byTopic[topic] {
+			e.int32(tp.partition)
+			if fn != nil {
+				fn(tp)
+			}
+		}
+	}
+}
+
+// encodeOffsetFetchRequest encodes an OffsetFetch v1 request.
+func encodeOffsetFetchRequest(group string, partitions []topicPartition) []byte {
+	var e encoder
+	e.string(group)
+	encodePartitions(&e, partitions, nil)
+	return e.buf
+}
+
+// decodeOffsetFetchResponse decodes an OffsetFetch v1 response. Partitions
+// without a committed offset have an offset of -1.
+func decodeOffsetFetchResponse(d *decoder) (map[topicPartition]int64, error) {
+	offsets := make(map[topicPartition]int64)
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		topic := d.string()
+		for j, n := 0, d.arrayLen(); j < n; j++ {
+			tp := topicPartition{topic, d.int32()}
+			offset := d.int64()
+			d.string() // metadata
+			if err := errorCode(d.int16()); err != nil {
+				return nil, err
+			}
+			offsets[tp] = offset
+		}
+	}
+	return offsets, d.err
+}
+
+// encodeOffsetCommitRequest encodes an OffsetCommit v2 request using the
+// retention time of the broker.
+func encodeOffsetCommitRequest(group string, generationID int32, memberID string, offsets map[topicPartition]int64) []byte {
+	partitions := make([]topicPartition, 0, len(offsets))
+	for tp := range offsets {
+		partitions = append(partitions, tp)
+	}
+
+	var e encoder
+	e.string(group)
+	e.int32(generationID)
+	e.string(memberID)
+	e.int64(-1) // retention time
+	encodePartitions(&e, partitions, func(tp topicPartition) {
+		e.int64(offsets[tp])
+		e.nullableString("")
+	})
+	return e.buf
+}
+
+// decodeOffsetCommitResponse decodes an OffsetCommit v2 response and
+// returns the first error of a partition.
+func decodeOffsetCommitResponse(d *decoder) error {
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		d.string() // topic
+		for j, n := 0, d.arrayLen(); j < n; j++ {
+			d.int32() // partition
+			if err := errorCode(d.int16()); err != nil {
+				return err
+			}
+		}
+	}
+	return d.err
+}
+
+// Timestamps of ListOffsets requests.
+const (
+	timestampNewest = -1
+	timestampOldest = -2
+)
+
+// encodeListOffsetsRequest encodes a ListOffsets v1 request for the offsets
+// of partitions at timestamp.
+func encodeListOffsetsRequest(partitions []topicPartition, timestamp int64) []byte {
+	var e encoder
+	e.int32(-1) // replica id
+	encodePartitions(&e, partitions, func(tp topicPartition) {
+		e.int64(timestamp)
+	})
+	return e.buf
+}
+
+// decodeListOffsetsResponse decodes a ListOffsets v1 response.
+func decodeListOffsetsResponse(d *decoder) (map[topicPartition]int64, error) {
+	offsets := make(map[topicPartition]int64)
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		topic := d.string()
+		for j, n := 0, d.arrayLen(); j < n; j++ {
+			tp := topicPartition{topic, d.int32()}
+			code := d.int16()
+			d.int64() // timestamp
+			offset := d.int64()
+			if err := errorCode(code); err != nil {
+				return nil, err
+			}
+			offsets[tp] = offset
+		}
+	}
+	return offsets, d.err
+}
+
+// encodeFetchRequest encodes a Fetch v4 request for partitions, starting
+// at their offsets.
+func encodeFetchRequest(offsets map[topicPartition]int64, partitions []topicPartition, maxWait, maxBytes int32) []byte {
+	var e encoder
+	e.int32(-1) // replica id
+	e.int32(maxWait)
+	e.int32(1) // min bytes
+	if total := int64(maxBytes) * int64(len(partitions)); total < math.MaxInt32 {
+		e.int32(int32(total))
+	} else {
+		e.int32(math.MaxInt32)
+	}
+	e.int8(0) // read uncommitted
+	encodePartitions(&e, partitions, func(tp topicPartition) {
+		e.int64(offsets[tp])
+		e.int32(maxBytes)
+	})
+	return e.buf
+}
+
+// fetchedPartition holds the records fetched from a partition.
+type fetchedPartition struct {
+	topicPartition
+	err     error
+	records []byte
+}
+
+// decodeFetchResponse decodes a Fetch v4 response.
+func decodeFetchResponse(d *decoder) ([]fetchedPartition, error) {
+	d.int32() // throttle time
+
+	var fetched []fetchedPartition
+	for i, n := 0, d.arrayLen(); i < n; i++ {
+		topic := d.string()
+		for j, n := 0, d.arrayLen(); j < n; j++ {
+			fp := fetchedPartition{topicPartition: topicPartition{topic, d.int32()}}
+			fp.err = errorCode(d.int16())
+			d.int64() // high watermark
+			d.int64() // last stable offset
+			for k, n := 0, d.arrayLen(); k < n; k++ {
+				d.int64() // aborted transaction producer id
+				d.int64() // aborted transaction first offset
+			}
+			fp.records = d.bytes()
+			fetched = append(fetched, fp)
+		}
+	}
+	return fetched, d.err
+}
+
+// encodeSaslHandshakeRequest encodes a SaslHandshake v1 request.
+func encodeSaslHandshakeRequest(mechanism string) []byte {
+	var e encoder
+	e.string(mechanism)
+	return e.buf
+}
+
+// decodeSaslHandshakeResponse decodes a SaslHandshake v1 response.
+func decodeSaslHandshakeResponse(d *decoder) error {
+	code := d.int16()
+	d.strings() // enabled mechanisms
+	if d.err != nil {
+		return d.err
+	}
+	return errorCode(code)
+}
+
+// encodeSaslAuthenticateRequest encodes a SaslAuthenticate v0 request.
+func encodeSaslAuthenticateRequest(auth []byte) []byte {
+	var e encoder
+	e.bytes(auth)
+	return e.buf
+}
+
+// decodeSaslAuthenticateResponse decodes a SaslAuthenticate v0 response.
+func decodeSaslAuthenticateResponse(d *decoder) ([]byte, error) {
+	code, msg, auth := d.int16(), d.string(), d.bytes()
+	if d.err != nil {
+		return nil, d.err
+	} else if code != errNone {
+		if msg != "" {
+			return nil, fmt.Errorf("%s: %s", kafkaError(code), msg)
+		}
+		return nil, kafkaError(code)
+	}
+	return auth, nil
+}
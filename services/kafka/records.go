@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+)
+
+// record is a message of a partition.
+type record struct {
+	offset    int64
+	timestamp int64 // milliseconds since the epoch
+	value     []byte
+}
+
+// Compression codecs of record batches.
+const (
+	compressionNone   = 0
+	compressionGzip   = 1
+	compressionSnappy = 2
+	compressionLZ4    = 3
+	compressionZstd   = 4
+)
+
+// recordBatchHeaderSize is the size of a record batch up to its records.
+const recordBatchHeaderSize = 61
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// decodeRecords decodes the records of the record batches in buf, skipping
+// records before offset. It returns the offset following the last complete
+// batch, which is offset if buf holds no complete batch. Brokers may return
+// a partial batch at the end of buf, which is ignored.
+func decodeRecords(buf []byte, offset int64) ([]record, int64, error) {
+	var records []record
+	next := offset
+	for len(buf) >= 12 {
+		baseOffset := int64(binary.BigEndian.Uint64(buf))
+		length := int(int32(binary.BigEndian.Uint32(buf[8:])))
+		if length < 0 {
+			return nil, offset, errors.New("kafka: invalid record batch length")
+		} else if len(buf) < 12+length {
+			break // partial batch
+		}
+		batch := buf[:12+length]
+		buf = buf[12+length:]
+
+		if len(batch) < 17 {
+			return nil, offset, errors.New("kafka: record batch too short")
+		} else if magic := batch[16]; magic != 2 {
+			return nil, offset, fmt.Errorf("kafka: unsupported message format %d, brokers must use format 2 (Kafka 0.11 or later)", magic)
+		} else if len(batch) < recordBatchHeaderSize {
+			return nil, offset, errors.New("kafka: record batch too short")
+		}
+
+		if crc := binary.BigEndian.Uint32(batch[17:]); crc32.Checksum(batch[21:], castagnoli) != crc {
+			return nil, offset, errors.New("kafka: record batch checksum mismatch")
+		}
+
+		attributes := int16(binary.BigEndian.Uint16(batch[21:]))
+		lastOffsetDelta := int64(int32(binary.BigEndian.Uint32(batch[23:])))
+		firstTimestamp := int64(binary.BigEndian.Uint64(batch[27:]))
+		count := int(int32(binary.BigEndian.Uint32(batch[57:])))
+		next = baseOffset + lastOffsetDelta + 1
+
+		// Control batches mark the end of transactions.
+		if attributes&0x20 != 0 {
+			continue
+		}
+
+		data, err := decompressRecords(int(attributes&0x07), batch[recordBatchHeaderSize:])
+		if err != nil {
+			return nil, offset, err
+		}
+
+		d := decoder{buf: data}
+		for i := 0; i < count; i++ {
+			d.varint() // length
+			d.int8()   // attributes
+			timestampDelta := d.varint()
+			offsetDelta := d.varint()
+			d.varbytes() // key
+			value := d.varbytes()
+			for j, n := 0, int(d.varint()); j < n; j++ {
+				d.varbytes() // header key
+				d.varbytes() // header value
+			}
+			if d.err != nil {
+				return nil, offset, d.err
+			}
+
+			if r := baseOffset + offsetDelta; r >= offset {
+				records = append(records, record{
+					offset:    r,
+					timestamp: firstTimestamp + timestampDelta,
+					value:     value,
+				})
+			}
+		}
+	}
+	return records, next, nil
+}
+
+// xerialHeader starts snappy data framed by the xerial snappy-java library,
+// used by the Java clients.
+var xerialHeader = []byte{0x82, 'S', 'N', 'A', 'P', 'P', 'Y', 0}
+
+// decompressRecords decompresses the records of a batch.
+func decompressRecords(codec int, buf []byte) ([]byte, error) {
+	switch codec {
+	case compressionNone:
+		return buf, nil
+	case compressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	case compressionSnappy:
+		if !bytes.HasPrefix(buf, xerialHeader) {
+			return snappy.Decode(nil, buf)
+		}
+
+		// Skip the header and versions, then decode the chunks.
+		if len(buf) < len(xerialHeader)+8 {
+			return nil, errors.New("kafka: truncated snappy header")
+		}
+		var out []byte
+		buf = buf[len(xerialHeader)+8:]
+		for len(buf) >= 4 {
+			n := int(binary.BigEndian.Uint32(buf))
+			if len(buf) < 4+n {
+				return nil, errors.New("kafka: truncated snappy chunk")
+			}
+			chunk, err := snappy.Decode(nil, buf[4:4+n])
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, chunk...)
+			buf = buf[4+n:]
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("kafka: unsupported compression codec %d", codec)
+	}
+}
@@ -0,0 +1,131 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+
+	"github.com/golang/snappy"
+)
+
+func TestDecodeRecords(t *testing.T) {
+	values := [][]byte{[]byte("cpu value=1"), []byte("cpu value=2"), []byte("cpu value=3")}
+
+	gz := func(b []byte) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write(b)
+		w.Close()
+		return buf.Bytes()
+	}
+	xerial := func(b []byte) []byte {
+		buf := append([]byte(nil), xerialHeader...)
+		buf = append(buf, 0, 0, 0, 1, 0, 0, 0, 1)
+		chunk := snappy.Encode(nil, b)
+		buf = append(buf, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(buf[len(buf)-4:], uint32(len(chunk)))
+		return append(buf, chunk...)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		codec    int16
+		compress func([]byte) []byte
+	}{
+		{name: "none", codec: compressionNone},
+		{name: "gzip", codec: compressionGzip, compress: gz},
+		{name: "snappy", codec: compressionSnappy, compress: func(b []byte) []byte { return snappy.Encode(nil, b) }},
+		{name: "xerial", codec: compressionSnappy, compress: xerial},
+	} {
+		buf := NewRecordBatch(10, 1500000000000, values, tt.codec, tt.compress)
+		records, next, err := decodeRecords(buf, 11)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		} else if next != 13 {
+			t.Fatalf("%s: got next offset %d, expected 13", tt.name, next)
+		}
+
+		exp := []record{
+			{offset: 11, timestamp: 1500000000001, value: values[1]},
+			{offset: 12, timestamp: 1500000000002, value: values[2]},
+		}
+		if !reflect.DeepEqual(records, exp) {
+			t.Fatalf("%s: got %+v, expected %+v", tt.name, records, exp)
+		}
+	}
+}
+
+// Ensure a partial batch at the end of a fetch response is ignored.
+func TestDecodeRecords_Partial(t *testing.T) {
+	first := NewRecordBatch(0, 0, [][]byte{[]byte("a")}, compressionNone, nil)
+	second := NewRecordBatch(1, 0, [][]byte{[]byte("b")}, compressionNone, nil)
+	buf := append(first, second[:len(second)-1]...)
+
+	records, next, err := decodeRecords(buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(records) != 1 || string(records[0].value) != "a" {
+		t.Fatalf("unexpected records: %+v", records)
+	} else if next != 1 {
+		t.Fatalf("got next offset %d, expected 1", next)
+	}
+}
+
+func TestDecodeRecords_Checksum(t *testing.T) {
+	buf := NewRecordBatch(0, 0, [][]byte{[]byte("a")}, compressionNone, nil)
+	buf[len(buf)-3] ^= 0xff
+	if _, _, err := decodeRecords(buf, 0); err == nil {
+		t.Fatal("expected checksum error")
+	}
+}
+
+// NewRecordBatch returns a record batch holding values, starting at
+// baseOffset, with timestamps increasing by a millisecond from timestamp.
+// The records are compressed with compress if set.
+func NewRecordBatch(baseOffset, timestamp int64, values [][]byte, codec int16, compress func([]byte) []byte) []byte {
+	var records []byte
+	for i, v := range values {
+		var r []byte
+		r = append(r, 0) // attributes
+		r = appendVarint(r, int64(i))
+		r = appendVarint(r, int64(i))
+		r = appendVarint(r, -1) // key
+		r = appendVarint(r, int64(len(v)))
+		r = append(r, v...)
+		r = appendVarint(r, 0) // headers
+
+		records = appendVarint(records, int64(len(r)))
+		records = append(records, r...)
+	}
+	if compress != nil {
+		records = compress(records)
+	}
+
+	var e encoder
+	e.int64(baseOffset)
+	e.int32(0) // length
+	e.int32(0) // partition leader epoch
+	e.int8(2)  // magic
+	e.int32(0) // crc
+	e.int16(codec)
+	e.int32(int32(len(values) - 1))
+	e.int64(timestamp)
+	e.int64(timestamp + int64(len(values)-1))
+	e.int64(-1) // producer id
+	e.int16(-1) // producer epoch
+	e.int32(-1) // base sequence
+	e.int32(int32(len(values)))
+	e.buf = append(e.buf, records...)
+
+	binary.BigEndian.PutUint32(e.buf[8:], uint32(len(e.buf)-12))
+	binary.BigEndian.PutUint32(e.buf[17:], crc32.Checksum(e.buf[21:], castagnoli))
+	return e.buf
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
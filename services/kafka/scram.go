@@ -0,0 +1,114 @@
+package kafka
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// scramClient authenticates with the SCRAM mechanism described in RFC 5802.
+type scramClient struct {
+	hash     func() hash.Hash
+	username string
+	password string
+	nonce    string
+
+	clientFirstBare string
+	serverSignature []byte
+}
+
+// newScramClient returns a client for mechanism, either SCRAM-SHA-256 or
+// SCRAM-SHA-512.
+func newScramClient(mechanism, username, password string) *scramClient {
+	s := &scramClient{hash: sha256.New, username: username, password: password}
+	if mechanism == SASLScramSHA512 {
+		s.hash = sha512.New
+	}
+
+	var b [18]byte
+	rand.Read(b[:])
+	s.nonce = base64.RawStdEncoding.EncodeToString(b[:])
+	return s
+}
+
+// first returns the client-first-message.
+func (s *scramClient) first() []byte {
+	username := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(s.username)
+	s.clientFirstBare = "n=" + username + ",r=" + s.nonce
+	return []byte("n,," + s.clientFirstBare)
+}
+
+// final returns the client-final-message answering serverFirst.
+func (s *scramClient) final(serverFirst []byte) ([]byte, error) {
+	attrs := scramAttributes(string(serverFirst))
+	nonce, salt64, iter := attrs["r"], attrs["s"], attrs["i"]
+	if !strings.HasPrefix(nonce, s.nonce) || len(nonce) == len(s.nonce) {
+		return nil, errors.New("invalid SCRAM server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SCRAM salt: %s", err)
+	}
+	iterations, err := strconv.Atoi(iter)
+	if err != nil || iterations <= 0 {
+		return nil, fmt.Errorf("invalid SCRAM iteration count %q", iter)
+	}
+
+	saltedPassword := pbkdf2.Key([]byte(s.password), salt, iterations, s.hash().Size(), s.hash)
+	clientKey := s.hmac(saltedPassword, "Client Key")
+	h := s.hash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	clientFinal := "c=biws,r=" + nonce
+	authMessage := s.clientFirstBare + "," + string(serverFirst) + "," + clientFinal
+
+	proof := s.hmac(storedKey, authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	s.serverSignature = s.hmac(s.hmac(saltedPassword, "Server Key"), authMessage)
+
+	return []byte(clientFinal + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// verify verifies the signature of the server in serverFinal.
+func (s *scramClient) verify(serverFinal []byte) error {
+	attrs := scramAttributes(string(serverFinal))
+	if e, ok := attrs["e"]; ok {
+		return fmt.Errorf("SCRAM authentication failed: %s", e)
+	}
+
+	v, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(v, s.serverSignature) {
+		return errors.New("invalid SCRAM server signature")
+	}
+	return nil
+}
+
+func (s *scramClient) hmac(key []byte, msg string) []byte {
+	h := hmac.New(s.hash, key)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
+
+// scramAttributes returns the attributes of a SCRAM message, such as
+// "r=nonce,s=salt,i=4096".
+func scramAttributes(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(msg, ",") {
+		if i := strings.Index(attr, "="); i > 0 {
+			attrs[attr[:i]] = attr[i+1:]
+		}
+	}
+	return attrs
+}
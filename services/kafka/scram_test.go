@@ -0,0 +1,37 @@
+package kafka
+
+import "testing"
+
+// Ensure the exchange matches the SCRAM-SHA-256 example of RFC 7677.
+func TestScramClient(t *testing.T) {
+	s := newScramClient(SASLScramSHA256, "user", "pencil")
+	s.nonce = "rOprNGfwEbeRWgbNEkqO"
+
+	if got, exp := string(s.first()), "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"; got != exp {
+		t.Fatalf("got client-first-message %q, expected %q", got, exp)
+	}
+
+	final, err := s.final([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := string(final), "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="; got != exp {
+		t.Fatalf("got client-final-message %q, expected %q", got, exp)
+	}
+
+	if err := s.verify([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.verify([]byte("v=AAAATRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")); err == nil {
+		t.Fatal("expected invalid server signature error")
+	}
+}
+
+// Ensure a server nonce not extending the client nonce is rejected.
+func TestScramClient_InvalidNonce(t *testing.T) {
+	s := newScramClient(SASLScramSHA512, "user", "pencil")
+	s.first()
+	if _, err := s.final([]byte("r=other,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")); err == nil {
+		t.Fatal("expected invalid nonce error")
+	}
+}
@@ -0,0 +1,432 @@
+// Package kafka provides a service for InfluxDB to ingest line protocol consumed from Kafka topics.
+package kafka // import "github.com/influxdata/influxdb/services/kafka"
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"go.uber.org/zap"
+)
+
+// statistics gathered by the kafka package.
+const (
+	statMessagesReceived    = "messagesRx"
+	statBytesReceived       = "bytesRx"
+	statPointsReceived      = "pointsRx"
+	statPointsParseFail     = "pointsParseFail"
+	statBatchesTransmitted  = "batchesTx"
+	statPointsTransmitted   = "pointsTx"
+	statBatchesTransmitFail = "batchesTxFail"
+	statOffsetCommitFail    = "offsetCommitFail"
+	statRebalances          = "rebalances"
+	statConnectFail         = "connectFail"
+)
+
+// closeTimeout is the time allowed to the consumer to stop gracefully when
+// the service is closed.
+const closeTimeout = 5 * time.Second
+
+// Service is a Kafka service that consumes line protocol from topics as a
+// member of a consumer group. The offsets of the consumed messages are only
+// committed once their points are written, so messages are consumed at least
+// once.
+type Service struct {
+	wg sync.WaitGroup
+
+	mu       sync.RWMutex
+	ready    bool          // Has the required database been created?
+	done     chan struct{} // Is the service closing or closed?
+	consumer *consumer     // Current consumer of the topics.
+
+	tlsConfig *tls.Config
+	config    Config
+
+	PointsWriter interface {
+		WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	MetaClient interface {
+		CreateDatabase(name string) (*meta.DatabaseInfo, error)
+	}
+
+	Logger      *zap.Logger
+	stats       *Statistics
+	defaultTags models.StatisticTags
+}
+
+// NewService returns a new instance of Service.
+func NewService(c Config) *Service {
+	d := *c.WithDefaults()
+	return &Service{
+		config:      d,
+		Logger:      zap.NewNop(),
+		stats:       &Statistics{},
+		defaultTags: models.StatisticTags{"group": d.ConsumerGroup},
+	}
+}
+
+// Open starts the service.
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed() {
+		return nil // Already open.
+	}
+
+	if len(s.config.Brokers) == 0 {
+		return errors.New("brokers have to be specified in config")
+	}
+	if len(s.config.Topics) == 0 {
+		return errors.New("topics have to be specified in config")
+	}
+	if s.config.Database == "" {
+		return errors.New("database has to be specified in config")
+	}
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+
+	if s.config.TLSEnabled {
+		tlsConfig, err := s.loadTLSConfig()
+		if err != nil {
+			return err
+		}
+		s.tlsConfig = tlsConfig
+	}
+
+	s.done = make(chan struct{})
+
+	s.wg.Add(1)
+	go s.run()
+
+	return nil
+}
+
+// loadTLSConfig returns the TLS configuration used to connect to the brokers.
+func (s *Service) loadTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: s.config.InsecureSkipVerify}
+
+	if s.config.TLSCA != "" {
+		pem, err := ioutil.ReadFile(s.config.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", s.config.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if s.config.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(s.config.TLSCert, s.config.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Statistics maintains statistics for the Kafka service.
+type Statistics struct {
+	MessagesReceived    int64
+	BytesReceived       int64
+	PointsReceived      int64
+	PointsParseFail     int64
+	BatchesTransmitted  int64
+	PointsTransmitted   int64
+	BatchesTransmitFail int64
+	OffsetCommitFail    int64
+	Rebalances          int64
+	ConnectFail         int64
+}
+
+// Statistics returns statistics for periodic monitoring.
+func (s *Service) Statistics(tags map[string]string) []models.Statistic {
+	return []models.Statistic{{
+		Name: "kafka",
+		Tags: s.defaultTags.Merge(tags),
+		Values: map[string]interface{}{
+			statMessagesReceived:    atomic.LoadInt64(&s.stats.MessagesReceived),
+			statBytesReceived:       atomic.LoadInt64(&s.stats.BytesReceived),
+			statPointsReceived:      atomic.LoadInt64(&s.stats.PointsReceived),
+			statPointsParseFail:     atomic.LoadInt64(&s.stats.PointsParseFail),
+			statBatchesTransmitted:  atomic.LoadInt64(&s.stats.BatchesTransmitted),
+			statPointsTransmitted:   atomic.LoadInt64(&s.stats.PointsTransmitted),
+			statBatchesTransmitFail: atomic.LoadInt64(&s.stats.BatchesTransmitFail),
+			statOffsetCommitFail:    atomic.LoadInt64(&s.stats.OffsetCommitFail),
+			statRebalances:          atomic.LoadInt64(&s.stats.Rebalances),
+			statConnectFail:         atomic.LoadInt64(&s.stats.ConnectFail),
+		},
+	}}
+}
+
+// run consumes the topics until the service is closed, starting over from
+// the committed offsets after every error.
+func (s *Service) run() {
+	defer s.wg.Done()
+
+	for {
+		err := s.consume()
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		s.Logger.Info("Kafka consumer failed",
+			zap.String("group", s.config.ConsumerGroup), zap.Error(err))
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(time.Duration(s.config.ReconnectInterval)):
+		}
+	}
+}
+
+// consume joins the consumer group and consumes the partitions assigned to
+// the service until an error occurs. The group is joined again whenever it
+// rebalances.
+func (s *Service) consume() error {
+	c := newConsumer(&s.config, s.tlsConfig, s.Logger)
+
+	s.mu.Lock()
+	if s.closed() {
+		s.mu.Unlock()
+		return errConsumerClosed
+	}
+	s.consumer = c
+	s.mu.Unlock()
+
+	defer func() {
+		c.leave()
+		c.Close()
+
+		s.mu.Lock()
+		s.consumer = nil
+		s.mu.Unlock()
+	}()
+
+	if err := c.connect(); err != nil {
+		atomic.AddInt64(&s.stats.ConnectFail, 1)
+		return err
+	}
+
+	for {
+		if err := c.join(); err != nil {
+			return err
+		}
+
+		err := s.consumeGeneration(c)
+		if !rebalancing(err) {
+			return err
+		}
+		atomic.AddInt64(&s.stats.Rebalances, 1)
+		s.Logger.Info("Kafka consumer group is rebalancing",
+			zap.String("group", s.config.ConsumerGroup))
+	}
+}
+
+// consumeGeneration consumes the partitions assigned to the service until
+// the group rebalances or an error occurs. Points are written in batches,
+// after which the offsets of their messages are committed.
+func (s *Service) consumeGeneration(c *consumer) error {
+	var batch []models.Point
+	offsets := make(map[topicPartition]int64)
+	start := time.Now()
+
+	flush := func() error {
+		if len(batch) > 0 {
+			if err := s.write(batch); err != nil {
+				// Consume the messages of the batch again.
+				c.rewind()
+				return err
+			}
+		}
+		if err := c.commit(offsets); err != nil {
+			atomic.AddInt64(&s.stats.OffsetCommitFail, 1)
+			return err
+		}
+
+		batch = nil
+		offsets = make(map[topicPartition]int64)
+		start = time.Now()
+		return nil
+	}
+
+	for {
+		select {
+		case <-s.done:
+			// Write the pending points before leaving the group.
+			if err := flush(); err != nil {
+				return err
+			}
+			return errConsumerClosed
+		default:
+		}
+
+		if c.rebalancing() {
+			// The offsets can no longer be committed once the group has
+			// rebalanced, so try to flush right away.
+			if err := flush(); err != nil && !rebalancing(err) {
+				return err
+			}
+			return kafkaError(errRebalanceInProgress)
+		}
+
+		records, next, err := c.fetch()
+		if err != nil {
+			return err
+		}
+
+		for _, rs := range records {
+			for _, r := range rs {
+				batch = append(batch, s.parse(r)...)
+			}
+		}
+		for tp, offset := range next {
+			offsets[tp] = offset
+		}
+
+		if len(batch) >= s.config.BatchSize || (len(offsets) > 0 && time.Since(start) >= time.Duration(s.config.BatchTimeout)) {
+			if err := flush(); err != nil {
+				return err
+			}
+		} else if len(offsets) == 0 {
+			start = time.Now()
+		}
+	}
+}
+
+// parse returns the points of a message. Points without a timestamp are
+// assigned the timestamp of the message.
+func (s *Service) parse(r record) []models.Point {
+	atomic.AddInt64(&s.stats.MessagesReceived, 1)
+	atomic.AddInt64(&s.stats.BytesReceived, int64(len(r.value)))
+
+	defaultTime := time.Now().UTC()
+	if r.timestamp > 0 {
+		defaultTime = time.Unix(0, r.timestamp*int64(time.Millisecond)).UTC()
+	}
+
+	points, err := models.ParsePointsWithPrecision(r.value, defaultTime, s.config.Precision)
+	if err != nil {
+		atomic.AddInt64(&s.stats.PointsParseFail, 1)
+		s.Logger.Info("Failed to parse points",
+			zap.Int64("offset", r.offset), zap.Error(err))
+	}
+	atomic.AddInt64(&s.stats.PointsReceived, int64(len(points)))
+	return points
+}
+
+// write writes points to the configured database.
+func (s *Service) write(points []models.Point) error {
+	// Will attempt to create database if not yet created.
+	if err := s.createInternalStorage(); err != nil {
+		s.Logger.Info("Required database not yet created",
+			logger.Database(s.config.Database), zap.Error(err))
+		atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+		return err
+	}
+
+	if err := s.PointsWriter.WritePointsPrivileged(s.config.Database, s.config.RetentionPolicy, models.ConsistencyLevelAny, points); err != nil {
+		s.Logger.Info("Failed to write point batch to database",
+			logger.Database(s.config.Database), zap.Error(err))
+		atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+		return err
+	}
+	atomic.AddInt64(&s.stats.BatchesTransmitted, 1)
+	atomic.AddInt64(&s.stats.PointsTransmitted, int64(len(points)))
+	return nil
+}
+
+// Close closes the service and its connections to the brokers.
+func (s *Service) Close() error {
+	if wait := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.closed() {
+			return false // Already closed.
+		}
+		close(s.done)
+		return true
+	}(); !wait {
+		return nil
+	}
+
+	// The consumer writes its pending points and leaves the group, unless
+	// it is blocked on a broker for longer than closeTimeout.
+	timer := time.AfterFunc(closeTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.consumer != nil {
+			s.consumer.Close()
+		}
+	})
+	s.wg.Wait()
+	timer.Stop()
+
+	// Release all remaining resources.
+	s.mu.Lock()
+	s.done = nil
+	s.mu.Unlock()
+
+	s.Logger.Info("Service closed")
+
+	return nil
+}
+
+// Closed returns true if the service is currently closed.
+func (s *Service) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed()
+}
+
+func (s *Service) closed() bool {
+	select {
+	case <-s.done:
+		// Service is closing.
+		return true
+	default:
+	}
+	return s.done == nil
+}
+
+// createInternalStorage ensures that the required database has been created.
+func (s *Service) createInternalStorage() error {
+	s.mu.RLock()
+	ready := s.ready
+	s.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if _, err := s.MetaClient.CreateDatabase(s.config.Database); err != nil {
+		return err
+	}
+
+	// The service is now ready.
+	s.mu.Lock()
+	s.ready = true
+	s.mu.Unlock()
+	return nil
+}
+
+// WithLogger sets the logger on the service.
+func (s *Service) WithLogger(log *zap.Logger) {
+	s.Logger = log.With(zap.String("service", "kafka"))
+}
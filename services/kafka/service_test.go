@@ -0,0 +1,637 @@
+package kafka
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/toml"
+)
+
+// Ensure the service consumes the messages of its topics, writes their
+// points and commits their offsets.
+func TestService_Write(t *testing.T) {
+	t.Parallel()
+
+	broker := NewTestBroker(t, "metrics")
+	defer broker.Close()
+	broker.Produce("metrics", "cpu value=1 1000000000", "mem value=2\ncpu value=x")
+
+	c := NewTestConfig(broker)
+	c.Offset = OffsetOldest
+	c.SASLMechanism = SASLPlain
+	c.SASLUsername = "user"
+	c.SASLPassword = "pass"
+	broker.Auth = "\x00user\x00pass"
+	s := NewTestService(&c)
+
+	written := make(chan []models.Point, 1)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		if database != "kafka" {
+			t.Errorf("unexpected database: %s", database)
+		}
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	select {
+	case points := <-written:
+		if len(points) != 2 {
+			t.Fatalf("got %d points, expected 2", len(points))
+		}
+		// The point without a timestamp is given the timestamp of its message.
+		if got, exp := points[0].String(), "cpu value=1 1000000000"; got != exp {
+			t.Fatalf("got %q, expected %q", got, exp)
+		} else if got, exp := points[1].String(), "mem value=2 1500000000001000000"; got != exp {
+			t.Fatalf("got %q, expected %q", got, exp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("points not written")
+	}
+
+	if offset := broker.WaitCommit(t, "metrics", 2); offset != 2 {
+		t.Fatalf("got committed offset %d, expected 2", offset)
+	}
+
+	stats := s.Service.Statistics(nil)[0].Values
+	if got, exp := stats[statMessagesReceived], int64(2); got != exp {
+		t.Fatalf("got %v messages received, expected %d", got, exp)
+	} else if got, exp := stats[statPointsParseFail], int64(1); got != exp {
+		t.Fatalf("got %v parse failures, expected %d", got, exp)
+	}
+}
+
+// Ensure messages are consumed again when their points cannot be written.
+func TestService_WriteFail(t *testing.T) {
+	t.Parallel()
+
+	broker := NewTestBroker(t, "metrics")
+	defer broker.Close()
+	broker.Produce("metrics", "cpu value=1 1000000000")
+
+	c := NewTestConfig(broker)
+	c.Offset = OffsetOldest
+	s := NewTestService(&c)
+
+	var mu sync.Mutex
+	var attempts int
+	written := make(chan []models.Point, 1)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if attempts++; attempts == 1 {
+			if offset, ok := broker.Committed("metrics"); ok {
+				t.Errorf("unexpected committed offset %d", offset)
+			}
+			return errors.New("write failed")
+		}
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	select {
+	case points := <-written:
+		if got, exp := points[0].String(), "cpu value=1 1000000000"; len(points) != 1 || got != exp {
+			t.Fatalf("got %v, expected %q", points, exp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("points not written")
+	}
+
+	if offset := broker.WaitCommit(t, "metrics", 1); offset != 1 {
+		t.Fatalf("got committed offset %d, expected 1", offset)
+	}
+	if got, exp := s.Service.Statistics(nil)[0].Values[statBatchesTransmitFail], int64(1); got != exp {
+		t.Fatalf("got %v failed batches, expected %d", got, exp)
+	}
+}
+
+// Ensure the service joins its group again when the group rebalances, and
+// consumes from the committed offsets.
+func TestService_Rebalance(t *testing.T) {
+	t.Parallel()
+
+	broker := NewTestBroker(t, "metrics")
+	defer broker.Close()
+
+	broker.Produce("metrics", "cpu value=1 1000000000")
+
+	c := NewTestConfig(broker)
+	c.Offset = OffsetOldest
+	s := NewTestService(&c)
+
+	written := make(chan []models.Point, 2)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("points not written")
+	}
+	broker.WaitCommit(t, "metrics", 1)
+
+	broker.Rebalance()
+	broker.WaitJoins(t, 2)
+	broker.Produce("metrics", "cpu value=2 2000000000")
+
+	select {
+	case points := <-written:
+		if got, exp := points[0].String(), "cpu value=2 2000000000"; len(points) != 1 || got != exp {
+			t.Fatalf("got %v, expected %q", points, exp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("points not written")
+	}
+	broker.WaitCommit(t, "metrics", 2)
+
+	if got, exp := s.Service.Statistics(nil)[0].Values[statRebalances], int64(1); got != exp {
+		t.Fatalf("got %v rebalances, expected %d", got, exp)
+	}
+}
+
+// Ensure the service can be opened and closed while consuming.
+func TestService_OpenClose(t *testing.T) {
+	broker := NewTestBroker(t, "metrics")
+	defer broker.Close()
+
+	c := NewTestConfig(broker)
+	s := NewTestService(&c)
+
+	// Closing a closed service is fine.
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Opening an already open service is fine.
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		broker.WaitJoins(t, i)
+		if err := s.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// NewTestConfig returns a configuration consuming the "metrics" topic from
+// broker, with short intervals.
+func NewTestConfig(broker *TestBroker) Config {
+	c := NewConfig()
+	c.Brokers = []string{broker.Addr().String()}
+	c.Topics = []string{"metrics"}
+	c.BatchTimeout = toml.Duration(10 * time.Millisecond)
+	c.HeartbeatInterval = toml.Duration(10 * time.Millisecond)
+	c.ReconnectInterval = toml.Duration(10 * time.Millisecond)
+	return c
+}
+
+// TestBroker is a single broker cluster serving topics of one partition,
+// which also coordinates a consumer group of a single member.
+type TestBroker struct {
+	t  *testing.T
+	ln net.Listener
+
+	// Auth is the SASL PLAIN message expected from clients, if set.
+	Auth string
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	conns     map[net.Conn]struct{}
+	logs      map[string][][]byte
+	committed map[string]int64
+	joins     int
+	rebalance bool
+}
+
+// NewTestBroker returns a broker serving topics.
+func NewTestBroker(t *testing.T, topics ...string) *TestBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &TestBroker{
+		t:         t,
+		ln:        ln,
+		conns:     make(map[net.Conn]struct{}),
+		logs:      make(map[string][][]byte),
+		committed: make(map[string]int64),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	for _, topic := range topics {
+		b.logs[topic] = nil
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns[conn] = struct{}{}
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *TestBroker) Addr() net.Addr { return b.ln.Addr() }
+
+// Close closes the listener and the connections of the broker.
+func (b *TestBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn := range b.conns {
+		conn.Close()
+	}
+	return b.ln.Close()
+}
+
+// Produce appends messages to the partition of topic.
+func (b *TestBroker) Produce(topic string, values ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, v := range values {
+		b.logs[topic] = append(b.logs[topic], []byte(v))
+	}
+	b.cond.Broadcast()
+}
+
+// Rebalance makes the next heartbeat fail, so the member rejoins the group.
+func (b *TestBroker) Rebalance() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rebalance = true
+}
+
+// Committed returns the offset committed for the partition of topic.
+func (b *TestBroker) Committed(topic string) (int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	offset, ok := b.committed[topic]
+	return offset, ok
+}
+
+// WaitCommit waits for an offset of at least offset to be committed for the
+// partition of topic, and returns it.
+func (b *TestBroker) WaitCommit(t *testing.T, topic string, offset int64) int64 {
+	b.waitFor(t, "offset commit", func() bool { return b.committed[topic] >= offset })
+	committed, _ := b.Committed(topic)
+	return committed
+}
+
+// WaitJoins waits for the group to have been joined n times.
+func (b *TestBroker) WaitJoins(t *testing.T, n int) {
+	b.waitFor(t, "group join", func() bool { return b.joins >= n })
+}
+
+func (b *TestBroker) waitFor(t *testing.T, what string, fn func() bool) {
+	timer := time.AfterFunc(5*time.Second, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.cond.Broadcast()
+	})
+	defer timer.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		b.cond.Wait()
+	}
+}
+
+// serve answers the requests of a connection.
+func (b *TestBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+
+		d := &decoder{buf: req}
+		apiKey, _ := d.int16(), d.int16()
+		correlationID := d.int32()
+		d.string() // client id
+
+		var e encoder
+		e.int32(0) // size
+		e.int32(correlationID)
+		if !b.handle(apiKey, d, &e) {
+			return
+		}
+		binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+		if _, err := conn.Write(e.buf); err != nil {
+			return
+		}
+	}
+}
+
+// handle decodes a request and encodes its response. It returns false if
+// the connection must be closed.
+func (b *TestBroker) handle(apiKey int16, d *decoder, e *encoder) bool {
+	host, port, _ := net.SplitHostPort(b.Addr().String())
+	portN, _ := strconv.Atoi(port)
+
+	switch apiKey {
+	case apiSaslHandshake:
+		if mechanism := d.string(); mechanism != SASLPlain {
+			b.t.Errorf("unexpected SASL mechanism: %s", mechanism)
+		}
+		e.int16(errNone)
+		e.strings([]string{SASLPlain})
+
+	case apiSaslAuthenticate:
+		if auth := string(d.bytes()); auth != b.Auth {
+			b.t.Errorf("got SASL message %q, expected %q", auth, b.Auth)
+			e.int16(58)
+		} else {
+			e.int16(errNone)
+		}
+		e.nullableString("")
+		e.bytes(nil)
+
+	case apiMetadata:
+		topics := d.strings()
+		e.arrayLen(1)
+		e.int32(1)
+		e.string(host)
+		e.int32(int32(portN))
+		e.nullableString("") // rack
+		e.int32(1)           // controller id
+		e.arrayLen(len(topics))
+		for _, topic := range topics {
+			e.int16(errNone)
+			e.string(topic)
+			e.int8(0) // is internal
+			e.arrayLen(1)
+			e.int16(errNone)
+			e.int32(0) // partition
+			e.int32(1) // leader
+			e.arrayLen(0)
+			e.arrayLen(0)
+		}
+
+	case apiFindCoordinator:
+		e.int16(errNone)
+		e.int32(1)
+		e.string(host)
+		e.int32(int32(portN))
+
+	case apiJoinGroup:
+		d.string() // group
+		d.int32()  // session timeout
+		d.int32()  // rebalance timeout
+		d.string() // member id
+		d.string() // protocol type
+		d.arrayLen()
+		protocol, metadata := d.string(), d.bytes()
+
+		b.mu.Lock()
+		b.joins++
+		generation := int32(b.joins)
+		b.cond.Broadcast()
+		b.mu.Unlock()
+
+		e.int16(errNone)
+		e.int32(generation)
+		e.string(protocol)
+		e.string("member")
+		e.string("member")
+		e.arrayLen(1)
+		e.string("member")
+		e.bytes(metadata)
+
+	case apiSyncGroup:
+		d.string() // group
+		d.int32()  // generation
+		d.string() // member id
+		var assignment []byte
+		for i, n := 0, d.arrayLen(); i < n; i++ {
+			if member := d.string(); member == "member" {
+				assignment = d.bytes()
+			} else {
+				d.bytes()
+			}
+		}
+		e.int16(errNone)
+		e.bytes(assignment)
+
+	case apiHeartbeat:
+		b.mu.Lock()
+		rebalance := b.rebalance
+		b.rebalance = false
+		b.mu.Unlock()
+
+		if rebalance {
+			e.int16(errRebalanceInProgress)
+		} else {
+			e.int16(errNone)
+		}
+
+	case apiLeaveGroup:
+		e.int16(errNone)
+
+	case apiOffsetFetch:
+		d.string() // group
+		b.encodePartitions(d, e, func(topic string) {
+			offset, ok := b.Committed(topic)
+			if !ok {
+				offset = -1
+			}
+			e.int64(offset)
+			e.nullableString("")
+			e.int16(errNone)
+		})
+
+	case apiOffsetCommit:
+		d.string() // group
+		d.int32()  // generation
+		d.string() // member id
+		d.int64()  // retention time
+		b.encodePartitions(d, e, func(topic string) {
+			offset := d.int64()
+			d.string() // metadata
+
+			b.mu.Lock()
+			b.committed[topic] = offset
+			b.cond.Broadcast()
+			b.mu.Unlock()
+			e.int16(errNone)
+		})
+
+	case apiListOffsets:
+		d.int32() // replica id
+		b.encodePartitions(d, e, func(topic string) {
+			timestamp := d.int64()
+			b.mu.Lock()
+			offset := int64(len(b.logs[topic]))
+			b.mu.Unlock()
+			if timestamp == timestampOldest {
+				offset = 0
+			}
+			e.int16(errNone)
+			e.int64(-1)
+			e.int64(offset)
+		})
+
+	case apiFetch:
+		d.int32() // replica id
+		maxWait := time.Duration(d.int32()) * time.Millisecond
+		d.int32() // min bytes
+		d.int32() // max bytes
+		d.int8()  // isolation level
+
+		e.int32(0) // throttle time
+		b.encodePartitions(d, e, func(topic string) {
+			offset := d.int64()
+			d.int32() // max bytes
+			values := b.wait(topic, offset, maxWait)
+
+			e.int16(errNone)
+			e.int64(offset + int64(len(values)))
+			e.int64(offset + int64(len(values)))
+			e.arrayLen(0) // aborted transactions
+			if len(values) == 0 {
+				e.bytes(nil)
+				return
+			}
+			e.bytes(NewRecordBatch(offset, 1500000000000, values, compressionNone, nil))
+		})
+
+	default:
+		b.t.Errorf("unexpected api key %d", apiKey)
+		return false
+	}
+
+	if d.err != nil {
+		b.t.Errorf("malformed request for api key %d: %s", apiKey, d.err)
+		return false
+	}
+	return true
+}
+
+// encodePartitions decodes the partitions of a request, grouped by topic,
+// and encodes them in the response, calling fn for the fields of every
+// partition.
+func (b *TestBroker) encodePartitions(d *decoder, e *encoder, fn func(topic string)) {
+	n := d.arrayLen()
+	e.arrayLen(n)
+	for i := 0; i < n; i++ {
+		topic := d.string()
+		e.string(topic)
+
+		m := d.arrayLen()
+		e.arrayLen(m)
+		for j := 0; j < m; j++ {
+			e.int32(d.int32())
+			fn(topic)
+		}
+	}
+}
+
+// wait returns the messages of topic from offset, waiting up to maxWait
+// for messages to be produced.
+func (b *TestBroker) wait(topic string, offset int64, maxWait time.Duration) [][]byte {
+	timer := time.AfterFunc(maxWait, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.cond.Broadcast()
+	})
+	defer timer.Stop()
+
+	deadline := time.Now().Add(maxWait)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for int64(len(b.logs[topic])) <= offset && time.Now().Before(deadline) {
+		b.cond.Wait()
+	}
+	if int64(len(b.logs[topic])) <= offset {
+		return nil
+	}
+	return b.logs[topic][offset:]
+}
+
+type TestService struct {
+	Service       *Service
+	Config        Config
+	MetaClient    *internal.MetaClientMock
+	WritePointsFn func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+}
+
+func NewTestService(c *Config) *TestService {
+	if c == nil {
+		defaultC := NewConfig()
+		c = &defaultC
+	}
+
+	service := &TestService{
+		Service:    NewService(*c),
+		Config:     *c,
+		MetaClient: &internal.MetaClientMock{},
+	}
+	service.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	if testing.Verbose() {
+		service.Service.WithLogger(logger.New(os.Stderr))
+	}
+
+	service.Service.MetaClient = service.MetaClient
+	service.Service.PointsWriter = service
+	return service
+}
+
+func (s *TestService) WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	if s.WritePointsFn == nil {
+		return nil
+	}
+	return s.WritePointsFn(database, retentionPolicy, consistencyLevel, points)
+}
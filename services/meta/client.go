@@ -497,6 +497,24 @@ func (c *Client) SetPrivilege(username, database string, p influxql.Privilege) e
 	return nil
 }
 
+// SetUserLimits sets the query limits of a user.
+func (c *Client) SetUserLimits(username string, limits UserLimits) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data := c.cacheData.Clone()
+
+	if err := data.SetUserLimits(username, limits); err != nil {
+		return err
+	}
+
+	if err := c.commit(data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // SetAdminPrivilege sets or unsets admin privilege to the given username.
 func (c *Client) SetAdminPrivilege(username string, admin bool) error {
 	c.mu.Lock()
@@ -594,6 +594,16 @@ func (data *Data) UpdateUser(name, hash string) error {
 	return ErrUserNotFound
 }
 
+// SetUserLimits sets the query limits of a user.
+func (data *Data) SetUserLimits(name string, limits UserLimits) error {
+	ui := data.user(name)
+	if ui == nil {
+		return ErrUserNotFound
+	}
+	ui.Limits = limits
+	return nil
+}
+
 // CloneUsers returns a copy of the user infos.
 func (data *Data) CloneUsers() []UserInfo {
 	if len(data.Users) == 0 {
@@ -1574,6 +1584,18 @@ type UserInfo struct {
 
 	// Map of database name to granted privilege.
 	Privileges map[string]influxql.Privilege
+
+	// Limits of the queries of the user overriding the global limits.
+	Limits UserLimits
+}
+
+// UserLimits are the limits of the queries of a user. A zero limit leaves
+// the global limit of the node in effect.
+type UserLimits struct {
+	MaxSelectPointN   int   `json:"maxSelectPoint,omitempty"`
+	MaxSelectSeriesN  int   `json:"maxSelectSeries,omitempty"`
+	MaxSelectBucketsN int   `json:"maxSelectBuckets,omitempty"`
+	MaxRequestMemory  int64 `json:"maxRequestMemory,omitempty"`
 }
 
 type User interface {
@@ -1633,6 +1655,19 @@ func (ui UserInfo) marshal() *internal.UserInfo {
 		})
 	}
 
+	if ui.Limits.MaxSelectPointN > 0 {
+		pb.MaxSelectPointN = proto.Int64(int64(ui.Limits.MaxSelectPointN))
+	}
+	if ui.Limits.MaxSelectSeriesN > 0 {
+		pb.MaxSelectSeriesN = proto.Int64(int64(ui.Limits.MaxSelectSeriesN))
+	}
+	if ui.Limits.MaxSelectBucketsN > 0 {
+		pb.MaxSelectBucketsN = proto.Int64(int64(ui.Limits.MaxSelectBucketsN))
+	}
+	if ui.Limits.MaxRequestMemory > 0 {
+		pb.MaxRequestMemory = proto.Int64(ui.Limits.MaxRequestMemory)
+	}
+
 	return pb
 }
 
@@ -1646,6 +1681,13 @@ func (ui *UserInfo) unmarshal(pb *internal.UserInfo) {
 	for _, p := range pb.GetPrivileges() {
 		ui.Privileges[p.GetDatabase()] = influxql.Privilege(p.GetPrivilege())
 	}
+
+	ui.Limits = UserLimits{
+		MaxSelectPointN:   int(pb.GetMaxSelectPointN()),
+		MaxSelectSeriesN:  int(pb.GetMaxSelectSeriesN()),
+		MaxSelectBucketsN: int(pb.GetMaxSelectBucketsN()),
+		MaxRequestMemory:  pb.GetMaxRequestMemory(),
+	}
 }
 
 // Lease represents a lease held on a resource.
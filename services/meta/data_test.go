@@ -215,6 +215,32 @@ func TestData_SetPrivilege(t *testing.T) {
 	}
 }
 
+func TestData_SetUserLimits(t *testing.T) {
+	data := meta.Data{}
+	if err := data.CreateUser("user1", "", false); err != nil {
+		t.Fatal(err)
+	}
+
+	limits := meta.UserLimits{MaxSelectPointN: 1000, MaxSelectBucketsN: 10, MaxRequestMemory: 1 << 20}
+	if got, exp := data.SetUserLimits("not a user", limits), meta.ErrUserNotFound; got != exp {
+		t.Fatalf("got %v, expected %v", got, exp)
+	} else if err := data.SetUserLimits("user1", limits); err != nil {
+		t.Fatal(err)
+	}
+
+	// The limits are kept in the serialized meta data.
+	b, err := data.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var other meta.Data
+	if err := other.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	} else if got := other.Users[0].Limits; got != limits {
+		t.Fatalf("got %+v, expected %+v", got, limits)
+	}
+}
+
 func TestData_TruncateShardGroups(t *testing.T) {
 	data := &meta.Data{}
 
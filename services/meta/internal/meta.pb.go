@@ -621,11 +621,15 @@ func (m *ContinuousQueryInfo) GetQuery() string {
 }
 
 type UserInfo struct {
-	Name             *string          `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
-	Hash             *string          `protobuf:"bytes,2,req,name=Hash" json:"Hash,omitempty"`
-	Admin            *bool            `protobuf:"varint,3,req,name=Admin" json:"Admin,omitempty"`
-	Privileges       []*UserPrivilege `protobuf:"bytes,4,rep,name=Privileges" json:"Privileges,omitempty"`
-	XXX_unrecognized []byte           `json:"-"`
+	Name              *string          `protobuf:"bytes,1,req,name=Name" json:"Name,omitempty"`
+	Hash              *string          `protobuf:"bytes,2,req,name=Hash" json:"Hash,omitempty"`
+	Admin             *bool            `protobuf:"varint,3,req,name=Admin" json:"Admin,omitempty"`
+	Privileges        []*UserPrivilege `protobuf:"bytes,4,rep,name=Privileges" json:"Privileges,omitempty"`
+	MaxSelectPointN   *int64           `protobuf:"varint,5,opt,name=MaxSelectPointN" json:"MaxSelectPointN,omitempty"`
+	MaxSelectSeriesN  *int64           `protobuf:"varint,6,opt,name=MaxSelectSeriesN" json:"MaxSelectSeriesN,omitempty"`
+	MaxSelectBucketsN *int64           `protobuf:"varint,7,opt,name=MaxSelectBucketsN" json:"MaxSelectBucketsN,omitempty"`
+	MaxRequestMemory  *int64           `protobuf:"varint,8,opt,name=MaxRequestMemory" json:"MaxRequestMemory,omitempty"`
+	XXX_unrecognized  []byte           `json:"-"`
 }
 
 func (m *UserInfo) Reset()                    { *m = UserInfo{} }
@@ -661,6 +665,34 @@ func (m *UserInfo) GetPrivileges() []*UserPrivilege {
 	return nil
 }
 
+func (m *UserInfo) GetMaxSelectPointN() int64 {
+	if m != nil && m.MaxSelectPointN != nil {
+		return *m.MaxSelectPointN
+	}
+	return 0
+}
+
+func (m *UserInfo) GetMaxSelectSeriesN() int64 {
+	if m != nil && m.MaxSelectSeriesN != nil {
+		return *m.MaxSelectSeriesN
+	}
+	return 0
+}
+
+func (m *UserInfo) GetMaxSelectBucketsN() int64 {
+	if m != nil && m.MaxSelectBucketsN != nil {
+		return *m.MaxSelectBucketsN
+	}
+	return 0
+}
+
+func (m *UserInfo) GetMaxRequestMemory() int64 {
+	if m != nil && m.MaxRequestMemory != nil {
+		return *m.MaxRequestMemory
+	}
+	return 0
+}
+
 type UserPrivilege struct {
 	Database         *string `protobuf:"bytes,1,req,name=Database" json:"Database,omitempty"`
 	Privilege        *int32  `protobuf:"varint,2,req,name=Privilege" json:"Privilege,omitempty"`
@@ -1894,118 +1926,122 @@ func init() {
 func init() { proto.RegisterFile("internal/meta.proto", fileDescriptorMeta) }
 
 var fileDescriptorMeta = []byte{
-	// 1808 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x59, 0x4b, 0x6f, 0x1c, 0xc7,
-	0x11, 0x46, 0xcf, 0x3e, 0xb8, 0x5b, 0x7c, 0xaa, 0xf9, 0x1a, 0x4a, 0x14, 0xb3, 0x18, 0x08, 0xca,
-	0x22, 0x08, 0x98, 0x60, 0x03, 0xe8, 0x94, 0x97, 0xc4, 0x95, 0xc4, 0x85, 0xc0, 0x47, 0x66, 0xa9,
-	0x6b, 0x80, 0x11, 0xb7, 0x25, 0x6e, 0xb2, 0x3b, 0xb3, 0x99, 0x99, 0x95, 0xc4, 0x28, 0x4c, 0x18,
-	0x5f, 0x7c, 0xb5, 0x61, 0x18, 0x3e, 0xe8, 0x66, 0x1f, 0x7c, 0x34, 0x0c, 0x03, 0x06, 0x0c, 0x9f,
-	0x7c, 0xf7, 0x1f, 0xf0, 0x7f, 0xb0, 0xcf, 0xbe, 0x1a, 0xdd, 0x3d, 0x3d, 0xdd, 0x33, 0xd3, 0x3d,
-	0x24, 0x65, 0xf9, 0x36, 0x5d, 0x55, 0xdd, 0xf5, 0x55, 0x75, 0x75, 0x75, 0x55, 0x0f, 0x2c, 0x0f,
-	0xfd, 0x98, 0x84, 0xbe, 0x37, 0xfa, 0xdd, 0x98, 0xc4, 0xde, 0xf6, 0x24, 0x0c, 0xe2, 0x00, 0x57,
-	0xe9, 0xb7, 0xf3, 0x5e, 0x05, 0xaa, 0x5d, 0x2f, 0xf6, 0x30, 0x86, 0xea, 0x11, 0x09, 0xc7, 0x36,
-	0x6a, 0x59, 0xed, 0xaa, 0xcb, 0xbe, 0xf1, 0x0a, 0xd4, 0x7a, 0xfe, 0x80, 0xbc, 0xb4, 0x2d, 0x46,
-	0xe4, 0x03, 0xbc, 0x09, 0xcd, 0x9d, 0xd1, 0x34, 0x8a, 0x49, 0xd8, 0xeb, 0xda, 0x15, 0xc6, 0x91,
-	0x04, 0x7c, 0x0b, 0x6a, 0xfb, 0xc1, 0x80, 0x44, 0x76, 0xb5, 0x55, 0x69, 0xcf, 0x76, 0x16, 0xb6,
-	0x99, 0x4a, 0x4a, 0xea, 0xf9, 0x4f, 0x03, 0x97, 0x33, 0xf1, 0xef, 0xa1, 0x49, 0xb5, 0x3e, 0xf1,
-	0x22, 0x12, 0xd9, 0x35, 0x26, 0x89, 0xb9, 0xa4, 0x20, 0x33, 0x69, 0x29, 0x44, 0xd7, 0x7d, 0x1c,
-	0x91, 0x30, 0xb2, 0xeb, 0xea, 0xba, 0x94, 0xc4, 0xd7, 0x65, 0x4c, 0x8a, 0x6d, 0xcf, 0x7b, 0xc9,
-	0xb4, 0x75, 0xed, 0x19, 0x8e, 0x2d, 0x25, 0xe0, 0x36, 0x2c, 0xee, 0x79, 0x2f, 0xfb, 0x27, 0x5e,
-	0x38, 0x78, 0x18, 0x06, 0xd3, 0x49, 0xaf, 0x6b, 0x37, 0x98, 0x4c, 0x9e, 0x8c, 0xb7, 0x00, 0x04,
-	0xa9, 0xd7, 0xb5, 0x9b, 0x4c, 0x48, 0xa1, 0xe0, 0xdf, 0x72, 0xfc, 0xdc, 0x52, 0xd0, 0x5a, 0x2a,
-	0x05, 0xa8, 0xf4, 0x1e, 0x11, 0xd2, 0xb3, 0x7a, 0xe9, 0x54, 0xc0, 0xd9, 0x85, 0x86, 0x20, 0xe3,
-	0x05, 0xb0, 0x7a, 0xdd, 0x64, 0x4f, 0xac, 0x5e, 0x97, 0xee, 0xd2, 0x6e, 0x10, 0xc5, 0x6c, 0x43,
-	0x9a, 0x2e, 0xfb, 0xc6, 0x36, 0xcc, 0x1c, 0xed, 0x1c, 0x32, 0x72, 0xa5, 0x85, 0xda, 0x4d, 0x57,
-	0x0c, 0x9d, 0xef, 0x11, 0xcc, 0xa9, 0xfe, 0xa4, 0xd3, 0xf7, 0xbd, 0x31, 0x61, 0x0b, 0x36, 0x5d,
-	0xf6, 0x8d, 0xef, 0xc0, 0x5a, 0x97, 0x3c, 0xf5, 0xa6, 0xa3, 0xd8, 0x25, 0x31, 0xf1, 0xe3, 0x61,
-	0xe0, 0x1f, 0x06, 0xa3, 0xe1, 0xf1, 0x69, 0xa2, 0xc4, 0xc0, 0xc5, 0x0f, 0xe1, 0x5a, 0x96, 0x34,
-	0x24, 0x91, 0x5d, 0x61, 0xc6, 0x6d, 0x70, 0xe3, 0x72, 0x33, 0x98, 0x9d, 0xc5, 0x39, 0x74, 0xa1,
-	0x9d, 0xc0, 0x8f, 0x87, 0xfe, 0x34, 0x98, 0x46, 0x7f, 0x9b, 0x92, 0x70, 0x98, 0x46, 0x4f, 0xb2,
-	0x50, 0x96, 0x9d, 0x2c, 0x54, 0x98, 0xe3, 0xbc, 0x8f, 0x60, 0x39, 0xa7, 0xb3, 0x3f, 0x21, 0xc7,
-	0x8a, 0xd5, 0x28, 0xb5, 0xfa, 0x3a, 0x34, 0xba, 0xd3, 0xd0, 0xa3, 0x92, 0xb6, 0xd5, 0x42, 0xed,
-	0x8a, 0x9b, 0x8e, 0xf1, 0x36, 0x60, 0x19, 0x0c, 0xa9, 0x54, 0x85, 0x49, 0x69, 0x38, 0x74, 0x2d,
-	0x97, 0x4c, 0x46, 0xc3, 0x63, 0x6f, 0xdf, 0xae, 0xb6, 0x50, 0x7b, 0xde, 0x4d, 0xc7, 0xce, 0xbb,
-	0x56, 0x01, 0x93, 0x71, 0x27, 0xb2, 0x98, 0xac, 0x4b, 0x61, 0xb2, 0x2e, 0x85, 0xc9, 0x52, 0x31,
-	0xe1, 0x3b, 0x30, 0x2b, 0x67, 0x88, 0xe3, 0xb7, 0xc2, 0x5d, 0xad, 0x9c, 0x02, 0xea, 0x65, 0x55,
-	0x10, 0xff, 0x11, 0xe6, 0xfb, 0xd3, 0x27, 0xd1, 0x71, 0x38, 0x9c, 0x50, 0x1d, 0xe2, 0x28, 0xae,
-	0x25, 0x33, 0x15, 0x16, 0x9b, 0x9b, 0x15, 0x76, 0xbe, 0x41, 0xb0, 0x90, 0x5d, 0xbd, 0x10, 0xdd,
-	0x9b, 0xd0, 0xec, 0xc7, 0x5e, 0x18, 0x1f, 0x0d, 0xc7, 0x24, 0xf1, 0x80, 0x24, 0xd0, 0x38, 0xbf,
-	0xef, 0x0f, 0x18, 0x8f, 0xdb, 0x2d, 0x86, 0x74, 0x5e, 0x97, 0x8c, 0x48, 0x4c, 0x06, 0x77, 0x63,
-	0x66, 0x6d, 0xc5, 0x95, 0x04, 0xfc, 0x6b, 0xa8, 0x33, 0xbd, 0xc2, 0xd2, 0x45, 0xc5, 0x52, 0x06,
-	0x34, 0x61, 0xe3, 0x16, 0xcc, 0x1e, 0x85, 0x53, 0xff, 0xd8, 0xe3, 0x0b, 0xd5, 0xd9, 0x86, 0xab,
-	0x24, 0x87, 0x40, 0x33, 0x9d, 0x56, 0x40, 0xbf, 0x05, 0x8d, 0x83, 0x17, 0x3e, 0x4d, 0x82, 0x91,
-	0x6d, 0xb5, 0x2a, 0xed, 0xea, 0x3d, 0xcb, 0x46, 0x6e, 0x4a, 0xc3, 0x6d, 0xa8, 0xb3, 0x6f, 0x71,
-	0x4a, 0x96, 0x14, 0x1c, 0x8c, 0xe1, 0x26, 0x7c, 0xe7, 0xef, 0xb0, 0x94, 0xf7, 0xa6, 0x36, 0x60,
-	0x30, 0x54, 0xf7, 0x82, 0x01, 0x11, 0xd9, 0x80, 0x7e, 0x63, 0x07, 0xe6, 0xba, 0x24, 0x8a, 0x87,
-	0xbe, 0xc7, 0xf7, 0x88, 0xea, 0x6a, 0xba, 0x19, 0x9a, 0x73, 0x0b, 0x40, 0x6a, 0xc5, 0x6b, 0x50,
-	0x4f, 0x12, 0x26, 0xb7, 0x25, 0x19, 0x39, 0x7f, 0x81, 0x65, 0xcd, 0xc1, 0xd3, 0x02, 0x59, 0x81,
-	0x1a, 0x13, 0x48, 0x90, 0xf0, 0x81, 0x73, 0x06, 0x0d, 0x91, 0x9f, 0x4d, 0xf0, 0x77, 0xbd, 0xe8,
-	0x24, 0x4d, 0x66, 0x5e, 0x74, 0x42, 0x57, 0xba, 0x3b, 0x18, 0x0f, 0x79, 0x68, 0x37, 0x5c, 0x3e,
-	0xc0, 0x7f, 0x00, 0x38, 0x0c, 0x87, 0xcf, 0x87, 0x23, 0xf2, 0x2c, 0xcd, 0x0d, 0xcb, 0xf2, 0x06,
-	0x48, 0x79, 0xae, 0x22, 0xe6, 0xf4, 0x60, 0x3e, 0xc3, 0x64, 0xe7, 0x2b, 0xc9, 0x86, 0x09, 0x8e,
-	0x74, 0x4c, 0x43, 0x28, 0x15, 0x64, 0x80, 0x6a, 0xae, 0x24, 0x38, 0xdf, 0xd5, 0x61, 0x66, 0x27,
-	0x18, 0x8f, 0x3d, 0x7f, 0x80, 0x6f, 0x43, 0x35, 0x3e, 0x9d, 0xf0, 0x15, 0x16, 0xc4, 0xad, 0x95,
-	0x30, 0xb7, 0x8f, 0x4e, 0x27, 0xc4, 0x65, 0x7c, 0xe7, 0x75, 0x1d, 0xaa, 0x74, 0x88, 0x57, 0xe1,
-	0xda, 0x4e, 0x48, 0xbc, 0x98, 0x50, 0xbf, 0x26, 0x82, 0x4b, 0x88, 0x92, 0x79, 0x8c, 0xaa, 0x64,
-	0x0b, 0x6f, 0xc0, 0x2a, 0x97, 0x16, 0xd0, 0x04, 0xab, 0x82, 0xd7, 0x61, 0xb9, 0x1b, 0x06, 0x93,
-	0x3c, 0xa3, 0x8a, 0x5b, 0xb0, 0xc9, 0xe7, 0xe4, 0x32, 0x8d, 0x90, 0xa8, 0xe1, 0x2d, 0xb8, 0x4e,
-	0xa7, 0x1a, 0xf8, 0x75, 0x7c, 0x0b, 0x5a, 0x7d, 0x12, 0xeb, 0x33, 0xbd, 0x90, 0x9a, 0xa1, 0x7a,
-	0x1e, 0x4f, 0x06, 0x66, 0x3d, 0x0d, 0x7c, 0x03, 0xd6, 0x39, 0x12, 0x79, 0xd2, 0x05, 0xb3, 0x49,
-	0x99, 0xdc, 0xe2, 0x22, 0x13, 0xa4, 0x0d, 0xb9, 0x98, 0x13, 0x12, 0xb3, 0xc2, 0x06, 0x03, 0x7f,
-	0x4e, 0xfa, 0x99, 0xee, 0xba, 0x20, 0xcf, 0xe3, 0x65, 0x58, 0xa4, 0xd3, 0x54, 0xe2, 0x02, 0x95,
-	0xe5, 0x96, 0xa8, 0xe4, 0x45, 0xea, 0xe1, 0x3e, 0x89, 0xd3, 0x7d, 0x17, 0x8c, 0x25, 0x8c, 0x61,
-	0x81, 0xfa, 0xc7, 0x8b, 0x3d, 0x41, 0xbb, 0x86, 0x37, 0xc1, 0xee, 0x93, 0x98, 0x05, 0x68, 0x61,
-	0x06, 0x96, 0x1a, 0xd4, 0xed, 0x5d, 0xc6, 0x37, 0x61, 0x23, 0x71, 0x90, 0x72, 0xc0, 0x05, 0x7b,
-	0x95, 0xb9, 0x28, 0x0c, 0x26, 0x3a, 0xe6, 0x1a, 0x5d, 0xd2, 0x25, 0xe3, 0xe0, 0x39, 0x39, 0x24,
-	0x12, 0xf4, 0xba, 0x8c, 0x18, 0x51, 0x42, 0x08, 0x96, 0x9d, 0x0d, 0x26, 0x95, 0xb5, 0x41, 0x59,
-	0x1c, 0x5f, 0x9e, 0x75, 0x9d, 0xb2, 0xf8, 0x3e, 0xe5, 0x17, 0xbc, 0x21, 0x59, 0xf9, 0x59, 0x9b,
-	0x78, 0x0d, 0x70, 0x9f, 0xc4, 0xf9, 0x29, 0x37, 0xf1, 0x0a, 0x2c, 0x31, 0x93, 0xe8, 0x9e, 0x0b,
-	0xea, 0xd6, 0x6f, 0x1a, 0x8d, 0xc1, 0xd2, 0xf9, 0xf9, 0xf9, 0xb9, 0xe5, 0x9c, 0x69, 0x8e, 0x47,
-	0x5a, 0xe7, 0x20, 0xa5, 0xce, 0xc1, 0x50, 0x75, 0x3d, 0x7f, 0x90, 0x14, 0xa3, 0xec, 0xbb, 0xf3,
-	0x57, 0x98, 0x39, 0x4e, 0xa6, 0xcc, 0x67, 0x4e, 0xa2, 0x4d, 0x5a, 0xa8, 0x3d, 0xdb, 0x59, 0x4f,
-	0x88, 0x79, 0x05, 0xae, 0x98, 0xe6, 0xbc, 0xd2, 0x1c, 0xc3, 0x42, 0x6a, 0x5f, 0x81, 0xda, 0x83,
-	0x20, 0x3c, 0xe6, 0x99, 0xa1, 0xe1, 0xf2, 0x41, 0x89, 0xf2, 0xa7, 0xaa, 0xf2, 0xc2, 0xf2, 0x52,
-	0xf9, 0x97, 0xc8, 0x70, 0xda, 0xb5, 0xf9, 0x72, 0x07, 0x16, 0x8b, 0x25, 0x1a, 0x2a, 0xaf, 0xb7,
-	0xf2, 0x33, 0x3a, 0x5d, 0x23, 0xe8, 0x67, 0x6c, 0xad, 0x1b, 0xaa, 0xc7, 0x72, 0xa8, 0x24, 0xf0,
-	0xb1, 0x36, 0x15, 0xe9, 0x50, 0x77, 0xee, 0x19, 0x15, 0x9e, 0xa8, 0xe0, 0x35, 0xcb, 0x49, 0x75,
-	0xdf, 0xa2, 0xf2, 0x0c, 0x57, 0x9a, 0xda, 0xb5, 0x6e, 0xb3, 0xae, 0xe8, 0xb6, 0x47, 0x46, 0x2b,
-	0x86, 0xcc, 0x0a, 0x47, 0x75, 0x9b, 0x1e, 0xa4, 0x34, 0xe7, 0x23, 0x54, 0x96, 0x8e, 0x4b, 0x8d,
-	0x11, 0x1e, 0xb6, 0x14, 0x0f, 0xf7, 0x8c, 0xd8, 0xfe, 0xc1, 0xb0, 0xb5, 0xa4, 0x87, 0x2f, 0x42,
-	0xf6, 0x09, 0xba, 0xf8, 0x22, 0xb8, 0x32, 0xbe, 0x03, 0x23, 0xbe, 0x7f, 0x32, 0x7c, 0xb7, 0x93,
-	0x42, 0xe8, 0x02, 0xbd, 0x12, 0xe5, 0x0f, 0xa8, 0xfc, 0x22, 0xba, 0x2a, 0x42, 0x5a, 0x5a, 0xee,
-	0x93, 0x17, 0x8c, 0x9c, 0xb4, 0x50, 0xc9, 0x30, 0x53, 0x93, 0x57, 0x73, 0x7d, 0x82, 0x5a, 0x63,
-	0xd7, 0xb2, 0x75, 0x7f, 0x49, 0xbc, 0x8c, 0xd4, 0x78, 0x29, 0xb3, 0x42, 0xda, 0xfb, 0x05, 0x32,
-	0x5e, 0xab, 0xa5, 0xa6, 0xae, 0x41, 0x3d, 0xd3, 0xca, 0x25, 0x23, 0x5a, 0xec, 0xd0, 0xba, 0x39,
-	0x8a, 0xbd, 0xf1, 0x24, 0xa9, 0xa5, 0x25, 0xa1, 0xf3, 0xc0, 0x08, 0x7d, 0xcc, 0xa0, 0xdf, 0x54,
-	0x43, 0xbd, 0x00, 0x48, 0xa2, 0xfe, 0x0a, 0x19, 0xef, 0xfb, 0x37, 0x42, 0xed, 0xc0, 0x5c, 0xa6,
-	0x75, 0xe7, 0x4f, 0x0f, 0x19, 0x5a, 0x09, 0x76, 0x5f, 0xc5, 0x6e, 0x80, 0x25, 0xb1, 0x7f, 0x8e,
-	0xca, 0xcb, 0x91, 0x2b, 0x47, 0x58, 0x5a, 0x21, 0x57, 0x94, 0x0a, 0xb9, 0x24, 0x4a, 0x82, 0x62,
-	0x56, 0xd1, 0x23, 0x29, 0x66, 0x95, 0xb7, 0x83, 0xb8, 0x24, 0xab, 0x4c, 0xf2, 0x59, 0xe5, 0x22,
-	0x64, 0x1f, 0x20, 0x4d, 0x69, 0xf6, 0xf3, 0x5a, 0x82, 0x92, 0xcb, 0xf7, 0x5f, 0xc5, 0x9b, 0x5f,
-	0x51, 0x2b, 0x51, 0x91, 0x42, 0x61, 0xa8, 0xbd, 0xbf, 0xfe, 0x6c, 0x54, 0x14, 0x32, 0x45, 0xab,
-	0xd2, 0x0f, 0x5a, 0x35, 0x67, 0x9a, 0x52, 0xf3, 0xb2, 0xb6, 0x97, 0x58, 0x19, 0xa9, 0x56, 0x16,
-	0x14, 0x48, 0xf5, 0x9f, 0x21, 0x6d, 0x4d, 0x4b, 0xc3, 0x81, 0xca, 0xfb, 0x12, 0x45, 0x3a, 0xce,
-	0x84, 0x8a, 0x55, 0xd6, 0x28, 0x55, 0x72, 0x8d, 0x52, 0xc9, 0x65, 0x1f, 0xab, 0x97, 0xbd, 0x06,
-	0x90, 0x44, 0x1c, 0xe4, 0x6b, 0x6d, 0xbc, 0xc5, 0xdf, 0x28, 0x19, 0xce, 0xd9, 0x0e, 0xc8, 0x87,
-	0x42, 0x97, 0xd1, 0x3b, 0x7f, 0x32, 0x6a, 0x9d, 0x32, 0xad, 0x2b, 0xf2, 0x82, 0x91, 0xab, 0x4a,
-	0x85, 0x1f, 0x22, 0x73, 0x25, 0x5f, 0xea, 0xa7, 0x34, 0x32, 0x2d, 0x35, 0x32, 0x1f, 0x1a, 0xd1,
-	0x3c, 0x67, 0x68, 0xb6, 0x52, 0x34, 0x5a, 0x8d, 0x12, 0xd7, 0xa9, 0xa6, 0x85, 0xb8, 0xcc, 0x8b,
-	0x60, 0x49, 0xd4, 0xbc, 0x28, 0x46, 0x8d, 0xb6, 0x30, 0xfd, 0x11, 0x95, 0xf4, 0x29, 0xc6, 0xc7,
-	0x2b, 0x53, 0xcc, 0xb4, 0x8b, 0x15, 0x18, 0x4f, 0x83, 0x79, 0x72, 0xfa, 0xa2, 0x51, 0x2d, 0x79,
-	0xd1, 0xa8, 0x15, 0x5f, 0x34, 0x3a, 0xbb, 0x46, 0x8b, 0x4f, 0x99, 0xc5, 0xbf, 0xca, 0xdc, 0x59,
-	0x45, 0x93, 0xa4, 0xe5, 0x5f, 0x23, 0x63, 0x0b, 0xf6, 0xcb, 0xd9, 0x5d, 0x72, 0x6f, 0xfd, 0x3b,
-	0x73, 0x6f, 0xe9, 0x81, 0x65, 0x42, 0xa6, 0xd0, 0x22, 0xa6, 0x21, 0x83, 0x64, 0xc8, 0xdc, 0x1d,
-	0x0c, 0x42, 0x11, 0x32, 0xf4, 0xbb, 0x24, 0x64, 0x5e, 0xa9, 0x21, 0x53, 0x58, 0x5c, 0xaa, 0xfe,
-	0x14, 0x19, 0xfa, 0x50, 0xea, 0xa2, 0xdd, 0xa3, 0xa3, 0x43, 0xa6, 0x33, 0x39, 0x42, 0x62, 0x9c,
-	0x3c, 0x5e, 0x2b, 0x70, 0xc4, 0x30, 0x6d, 0xf7, 0x2a, 0x4a, 0xbb, 0x67, 0x6e, 0x5e, 0xfe, 0x53,
-	0x6c, 0x5e, 0x72, 0x30, 0x32, 0xd7, 0x91, 0xbe, 0x2d, 0x7e, 0x33, 0xa4, 0x25, 0xa8, 0xce, 0xf4,
-	0x2d, 0x95, 0x16, 0xd5, 0x6b, 0x64, 0xe8, 0xc8, 0xaf, 0xfe, 0x13, 0xc0, 0x52, 0x7e, 0x02, 0x94,
-	0xa0, 0xfb, 0xaf, 0x8a, 0x4e, 0xab, 0x5a, 0x6d, 0xf8, 0xf4, 0x6f, 0x02, 0x79, 0x70, 0x25, 0xea,
-	0xfe, 0xa7, 0xaa, 0xd3, 0x2e, 0x26, 0xd5, 0xf9, 0x86, 0x77, 0x86, 0x82, 0xba, 0xfb, 0x46, 0x75,
-	0xe7, 0xa8, 0xa8, 0xcf, 0x68, 0xde, 0x03, 0x5a, 0xca, 0x47, 0x93, 0xc0, 0x8f, 0x08, 0x55, 0x71,
-	0xf0, 0x88, 0xa9, 0x68, 0xb8, 0xd6, 0xc1, 0x23, 0x9a, 0xe5, 0xef, 0x87, 0x61, 0x10, 0xb2, 0x66,
-	0xbb, 0xe9, 0xf2, 0x81, 0xfc, 0x37, 0x56, 0x61, 0xe7, 0x8a, 0x0f, 0x9c, 0x8f, 0x91, 0xee, 0x15,
-	0xe4, 0x2d, 0x9e, 0x00, 0xf3, 0x05, 0xfb, 0x7f, 0x6e, 0xaf, 0x9d, 0xde, 0x2e, 0x46, 0xe7, 0x0e,
-	0x8a, 0x2f, 0x32, 0x05, 0xbf, 0x9a, 0xf3, 0xc1, 0x3b, 0x5c, 0xcf, 0x9a, 0x92, 0x91, 0x94, 0x85,
-	0x52, 0x2d, 0x3f, 0x05, 0x00, 0x00, 0xff, 0xff, 0xc7, 0x04, 0x86, 0xd9, 0x75, 0x1c, 0x00, 0x00,
+	// 1869 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x59, 0xcb, 0x8f, 0xdc, 0x4c,
+	0x11, 0x57, 0x7b, 0x1e, 0x3b, 0x53, 0xfb, 0xee, 0x7d, 0x79, 0x93, 0xcd, 0x32, 0xb2, 0xa2, 0x30,
+	0x8a, 0xa2, 0x05, 0x0d, 0x52, 0x4e, 0xbc, 0x92, 0x9d, 0x24, 0x3b, 0x8a, 0xf6, 0x81, 0x67, 0x73,
+	0x45, 0x72, 0x66, 0x3a, 0xd9, 0x21, 0x33, 0xf6, 0xc4, 0xf6, 0x24, 0x59, 0x42, 0x60, 0xe1, 0xc2,
+	0x15, 0x84, 0x10, 0x87, 0xdc, 0xe0, 0xc0, 0x09, 0x21, 0x84, 0x84, 0x84, 0x38, 0x71, 0xe7, 0x1f,
+	0xe0, 0x7f, 0x80, 0xf3, 0x77, 0xfd, 0xd4, 0xdd, 0x6e, 0x77, 0xdb, 0xee, 0xf6, 0xee, 0xe6, 0xcb,
+	0x77, 0x73, 0x57, 0x55, 0x57, 0xfd, 0xaa, 0xba, 0xba, 0xba, 0xab, 0x0d, 0x6b, 0x23, 0x3f, 0x26,
+	0xa1, 0xef, 0x8d, 0xbf, 0x35, 0x21, 0xb1, 0xb7, 0x37, 0x0d, 0x83, 0x38, 0xc0, 0x55, 0xfa, 0xed,
+	0xfc, 0xa6, 0x02, 0xd5, 0xae, 0x17, 0x7b, 0x18, 0x43, 0xf5, 0x94, 0x84, 0x13, 0x1b, 0xb5, 0xac,
+	0x76, 0xd5, 0x65, 0xdf, 0x78, 0x1d, 0x6a, 0x3d, 0x7f, 0x48, 0xde, 0xd9, 0x16, 0x23, 0xf2, 0x01,
+	0xde, 0x81, 0xe6, 0xfe, 0x78, 0x16, 0xc5, 0x24, 0xec, 0x75, 0xed, 0x0a, 0xe3, 0x48, 0x02, 0xbe,
+	0x0d, 0xb5, 0xa3, 0x60, 0x48, 0x22, 0xbb, 0xda, 0xaa, 0xb4, 0xe7, 0x3b, 0x4b, 0x7b, 0xcc, 0x24,
+	0x25, 0xf5, 0xfc, 0x17, 0x81, 0xcb, 0x99, 0xf8, 0xdb, 0xd0, 0xa4, 0x56, 0x9f, 0x7b, 0x11, 0x89,
+	0xec, 0x1a, 0x93, 0xc4, 0x5c, 0x52, 0x90, 0x99, 0xb4, 0x14, 0xa2, 0x7a, 0x9f, 0x45, 0x24, 0x8c,
+	0xec, 0xba, 0xaa, 0x97, 0x92, 0xb8, 0x5e, 0xc6, 0xa4, 0xd8, 0x0e, 0xbd, 0x77, 0xcc, 0x5a, 0xd7,
+	0x9e, 0xe3, 0xd8, 0x52, 0x02, 0x6e, 0xc3, 0xf2, 0xa1, 0xf7, 0xae, 0x7f, 0xe6, 0x85, 0xc3, 0x27,
+	0x61, 0x30, 0x9b, 0xf6, 0xba, 0x76, 0x83, 0xc9, 0xe4, 0xc9, 0x78, 0x17, 0x40, 0x90, 0x7a, 0x5d,
+	0xbb, 0xc9, 0x84, 0x14, 0x0a, 0xbe, 0xc7, 0xf1, 0x73, 0x4f, 0x41, 0xeb, 0xa9, 0x14, 0xa0, 0xd2,
+	0x87, 0x44, 0x48, 0xcf, 0xeb, 0xa5, 0x53, 0x01, 0xe7, 0x00, 0x1a, 0x82, 0x8c, 0x97, 0xc0, 0xea,
+	0x75, 0x93, 0x35, 0xb1, 0x7a, 0x5d, 0xba, 0x4a, 0x07, 0x41, 0x14, 0xb3, 0x05, 0x69, 0xba, 0xec,
+	0x1b, 0xdb, 0x30, 0x77, 0xba, 0x7f, 0xc2, 0xc8, 0x95, 0x16, 0x6a, 0x37, 0x5d, 0x31, 0x74, 0xfe,
+	0x87, 0x60, 0x41, 0x8d, 0x27, 0x9d, 0x7e, 0xe4, 0x4d, 0x08, 0x53, 0xd8, 0x74, 0xd9, 0x37, 0xbe,
+	0x0f, 0x9b, 0x5d, 0xf2, 0xc2, 0x9b, 0x8d, 0x63, 0x97, 0xc4, 0xc4, 0x8f, 0x47, 0x81, 0x7f, 0x12,
+	0x8c, 0x47, 0x83, 0xf3, 0xc4, 0x88, 0x81, 0x8b, 0x9f, 0xc0, 0x6a, 0x96, 0x34, 0x22, 0x91, 0x5d,
+	0x61, 0xce, 0x6d, 0x73, 0xe7, 0x72, 0x33, 0x98, 0x9f, 0xc5, 0x39, 0x54, 0xd1, 0x7e, 0xe0, 0xc7,
+	0x23, 0x7f, 0x16, 0xcc, 0xa2, 0x1f, 0xcd, 0x48, 0x38, 0x4a, 0xb3, 0x27, 0x51, 0x94, 0x65, 0x27,
+	0x8a, 0x0a, 0x73, 0x9c, 0xdf, 0x22, 0x58, 0xcb, 0xd9, 0xec, 0x4f, 0xc9, 0x40, 0xf1, 0x1a, 0xa5,
+	0x5e, 0xdf, 0x80, 0x46, 0x77, 0x16, 0x7a, 0x54, 0xd2, 0xb6, 0x5a, 0xa8, 0x5d, 0x71, 0xd3, 0x31,
+	0xde, 0x03, 0x2c, 0x93, 0x21, 0x95, 0xaa, 0x30, 0x29, 0x0d, 0x87, 0xea, 0x72, 0xc9, 0x74, 0x3c,
+	0x1a, 0x78, 0x47, 0x76, 0xb5, 0x85, 0xda, 0x8b, 0x6e, 0x3a, 0x76, 0x7e, 0x6d, 0x15, 0x30, 0x19,
+	0x57, 0x22, 0x8b, 0xc9, 0xba, 0x12, 0x26, 0xeb, 0x4a, 0x98, 0x2c, 0x15, 0x13, 0xbe, 0x0f, 0xf3,
+	0x72, 0x86, 0xd8, 0x7e, 0xeb, 0x3c, 0xd4, 0xca, 0x2e, 0xa0, 0x51, 0x56, 0x05, 0xf1, 0x77, 0x61,
+	0xb1, 0x3f, 0x7b, 0x1e, 0x0d, 0xc2, 0xd1, 0x94, 0xda, 0x10, 0x5b, 0x71, 0x33, 0x99, 0xa9, 0xb0,
+	0xd8, 0xdc, 0xac, 0xb0, 0xf3, 0x6f, 0x04, 0x4b, 0x59, 0xed, 0x85, 0xec, 0xde, 0x81, 0x66, 0x3f,
+	0xf6, 0xc2, 0xf8, 0x74, 0x34, 0x21, 0x49, 0x04, 0x24, 0x81, 0xe6, 0xf9, 0x23, 0x7f, 0xc8, 0x78,
+	0xdc, 0x6f, 0x31, 0xa4, 0xf3, 0xba, 0x64, 0x4c, 0x62, 0x32, 0x7c, 0x10, 0x33, 0x6f, 0x2b, 0xae,
+	0x24, 0xe0, 0x6f, 0x42, 0x9d, 0xd9, 0x15, 0x9e, 0x2e, 0x2b, 0x9e, 0x32, 0xa0, 0x09, 0x1b, 0xb7,
+	0x60, 0xfe, 0x34, 0x9c, 0xf9, 0x03, 0x8f, 0x2b, 0xaa, 0xb3, 0x05, 0x57, 0x49, 0x0e, 0x81, 0x66,
+	0x3a, 0xad, 0x80, 0x7e, 0x17, 0x1a, 0xc7, 0x6f, 0x7d, 0x5a, 0x04, 0x23, 0xdb, 0x6a, 0x55, 0xda,
+	0xd5, 0x87, 0x96, 0x8d, 0xdc, 0x94, 0x86, 0xdb, 0x50, 0x67, 0xdf, 0x62, 0x97, 0xac, 0x28, 0x38,
+	0x18, 0xc3, 0x4d, 0xf8, 0xce, 0x8f, 0x61, 0x25, 0x1f, 0x4d, 0x6d, 0xc2, 0x60, 0xa8, 0x1e, 0x06,
+	0x43, 0x22, 0xaa, 0x01, 0xfd, 0xc6, 0x0e, 0x2c, 0x74, 0x49, 0x14, 0x8f, 0x7c, 0x8f, 0xaf, 0x11,
+	0xb5, 0xd5, 0x74, 0x33, 0x34, 0xe7, 0x36, 0x80, 0xb4, 0x8a, 0x37, 0xa1, 0x9e, 0x14, 0x4c, 0xee,
+	0x4b, 0x32, 0x72, 0x7e, 0x00, 0x6b, 0x9a, 0x8d, 0xa7, 0x05, 0xb2, 0x0e, 0x35, 0x26, 0x90, 0x20,
+	0xe1, 0x03, 0xe7, 0x2f, 0x16, 0x34, 0x44, 0x81, 0x36, 0xe1, 0x3f, 0xf0, 0xa2, 0xb3, 0xb4, 0x9a,
+	0x79, 0xd1, 0x19, 0x55, 0xf5, 0x60, 0x38, 0x19, 0xf1, 0xdc, 0x6e, 0xb8, 0x7c, 0x80, 0xbf, 0x03,
+	0x70, 0x12, 0x8e, 0xde, 0x8c, 0xc6, 0xe4, 0x65, 0x5a, 0x1c, 0xd6, 0xe4, 0x11, 0x90, 0xf2, 0x5c,
+	0x45, 0x4c, 0x94, 0x7b, 0x32, 0x26, 0x83, 0xf8, 0x24, 0x18, 0xf9, 0xf1, 0x91, 0x5d, 0x63, 0x6b,
+	0x9a, 0x27, 0xe3, 0xbb, 0xb0, 0x92, 0x92, 0xfa, 0xac, 0x98, 0x1c, 0x25, 0xcb, 0x5f, 0xa0, 0xe3,
+	0x7b, 0xb0, 0x9a, 0xd2, 0x1e, 0xce, 0x06, 0xaf, 0x48, 0x1c, 0x1d, 0xd9, 0x73, 0x4c, 0xb8, 0xc8,
+	0x48, 0x34, 0xbb, 0xe4, 0xf5, 0x8c, 0x44, 0xf1, 0x21, 0x99, 0x04, 0xe1, 0xb9, 0xdd, 0x48, 0x35,
+	0x67, 0xe8, 0x4e, 0x0f, 0x16, 0x33, 0xce, 0xb0, 0x82, 0x90, 0x94, 0xef, 0x24, 0x6e, 0xe9, 0x98,
+	0xe6, 0x7c, 0x2a, 0xc8, 0x02, 0x58, 0x73, 0x25, 0xc1, 0xf9, 0x6f, 0x1d, 0xe6, 0xf6, 0x83, 0xc9,
+	0xc4, 0xf3, 0x87, 0xf8, 0x0e, 0x54, 0xe3, 0xf3, 0x29, 0xd7, 0xb0, 0x24, 0x8e, 0xd9, 0x84, 0xb9,
+	0x77, 0x7a, 0x3e, 0x25, 0x2e, 0xe3, 0x3b, 0x1f, 0xeb, 0x50, 0xa5, 0x43, 0xbc, 0x01, 0xab, 0xfb,
+	0x21, 0xf1, 0x62, 0x42, 0x13, 0x21, 0x11, 0x5c, 0x41, 0x94, 0xcc, 0x37, 0x95, 0x4a, 0xb6, 0xf0,
+	0x36, 0x6c, 0x70, 0x69, 0x01, 0x4d, 0xb0, 0x2a, 0x78, 0x0b, 0xd6, 0xba, 0x61, 0x30, 0xcd, 0x33,
+	0xaa, 0xb8, 0x05, 0x3b, 0x7c, 0x4e, 0xae, 0x34, 0x0a, 0x89, 0x1a, 0xde, 0x85, 0x1b, 0x74, 0xaa,
+	0x81, 0x5f, 0xc7, 0xb7, 0xa1, 0xd5, 0x27, 0xb1, 0xfe, 0x68, 0x12, 0x52, 0x73, 0xd4, 0xce, 0xb3,
+	0xe9, 0xd0, 0x6c, 0xa7, 0x81, 0x6f, 0xc2, 0x16, 0x47, 0x22, 0x4b, 0x93, 0x60, 0x36, 0x29, 0x93,
+	0x7b, 0x5c, 0x64, 0x82, 0xf4, 0x21, 0xb7, 0x49, 0x84, 0xc4, 0xbc, 0xf0, 0xc1, 0xc0, 0x5f, 0x90,
+	0x71, 0xa6, 0xab, 0x2e, 0xc8, 0x8b, 0x78, 0x0d, 0x96, 0xe9, 0x34, 0x95, 0xb8, 0x44, 0x65, 0xb9,
+	0x27, 0x2a, 0x79, 0x99, 0x46, 0xb8, 0x4f, 0xe2, 0x74, 0xdd, 0x05, 0x63, 0x05, 0x63, 0x58, 0xa2,
+	0xf1, 0xf1, 0x62, 0x4f, 0xd0, 0x56, 0xf1, 0x0e, 0xd8, 0x7d, 0x12, 0xb3, 0x0d, 0x55, 0x98, 0x81,
+	0xa5, 0x05, 0x75, 0x79, 0xd7, 0xf0, 0x2d, 0xd8, 0x4e, 0x02, 0xa4, 0x54, 0x24, 0xc1, 0xde, 0x60,
+	0x21, 0x0a, 0x83, 0xa9, 0x8e, 0xb9, 0x49, 0x55, 0xba, 0x64, 0x12, 0xbc, 0x21, 0x27, 0x44, 0x82,
+	0xde, 0x92, 0x19, 0x23, 0xee, 0x3c, 0x82, 0x65, 0x67, 0x93, 0x49, 0x65, 0x6d, 0x53, 0x16, 0xc7,
+	0x97, 0x67, 0xdd, 0xa0, 0x2c, 0xbe, 0x4e, 0x79, 0x85, 0x37, 0x25, 0x2b, 0x3f, 0x6b, 0x07, 0x6f,
+	0x02, 0xee, 0x93, 0x38, 0x3f, 0xe5, 0x16, 0x5e, 0x87, 0x15, 0xe6, 0x12, 0x5d, 0x73, 0x41, 0xdd,
+	0xbd, 0xdb, 0x68, 0x0c, 0x57, 0x2e, 0x2e, 0x2e, 0x2e, 0x2c, 0xe7, 0x83, 0x66, 0x7b, 0xa4, 0x17,
+	0x33, 0xa4, 0x5c, 0xcc, 0x30, 0x54, 0x5d, 0xcf, 0x1f, 0x26, 0xb7, 0x67, 0xf6, 0xdd, 0xf9, 0x21,
+	0xcc, 0x0d, 0x92, 0x29, 0x8b, 0x99, 0x9d, 0x68, 0x93, 0x16, 0x6a, 0xcf, 0x77, 0xb6, 0x12, 0x62,
+	0xde, 0x80, 0x2b, 0xa6, 0x39, 0xef, 0x35, 0xdb, 0xb0, 0x70, 0x16, 0xad, 0x43, 0xed, 0x71, 0x10,
+	0x0e, 0x78, 0x65, 0x68, 0xb8, 0x7c, 0x50, 0x62, 0xfc, 0x85, 0x6a, 0xbc, 0xa0, 0x5e, 0x1a, 0xff,
+	0x07, 0x32, 0xec, 0x76, 0x6d, 0x7d, 0xdf, 0x87, 0xe5, 0xe2, 0x9d, 0x12, 0x95, 0x5f, 0x10, 0xf3,
+	0x33, 0x3a, 0x5d, 0x23, 0xe8, 0x97, 0x4c, 0xd7, 0x4d, 0x35, 0x62, 0x39, 0x54, 0x12, 0xf8, 0x44,
+	0x5b, 0x8a, 0x74, 0xa8, 0x3b, 0x0f, 0x8d, 0x06, 0xcf, 0x54, 0xf0, 0x1a, 0x75, 0xd2, 0xdc, 0x7f,
+	0x50, 0x79, 0x85, 0x2b, 0x2d, 0xed, 0xda, 0xb0, 0x59, 0xd7, 0x0c, 0xdb, 0x53, 0xa3, 0x17, 0x23,
+	0xe6, 0x85, 0xa3, 0x86, 0x4d, 0x0f, 0x52, 0xba, 0xf3, 0x07, 0x54, 0x56, 0x8e, 0x4b, 0x9d, 0x11,
+	0x11, 0xb6, 0x94, 0x08, 0xf7, 0x8c, 0xd8, 0x7e, 0xc2, 0xb0, 0xb5, 0x64, 0x84, 0x2f, 0x43, 0xf6,
+	0x27, 0x74, 0xf9, 0x41, 0x70, 0x6d, 0x7c, 0xc7, 0x46, 0x7c, 0xaf, 0x18, 0xbe, 0x3b, 0x9c, 0x78,
+	0x99, 0x5d, 0x89, 0xf2, 0xff, 0xa8, 0xfc, 0x20, 0xba, 0x2e, 0x42, 0x7a, 0x17, 0x3e, 0x22, 0x6f,
+	0x19, 0x39, 0xe9, 0xf9, 0x92, 0x61, 0xa6, 0x89, 0xa8, 0xe6, 0x1a, 0x1b, 0xb5, 0x29, 0xa8, 0x65,
+	0x1b, 0x95, 0x92, 0x7c, 0x19, 0xab, 0xf9, 0x52, 0xe6, 0x85, 0xf4, 0xf7, 0xef, 0xc8, 0x78, 0xac,
+	0x96, 0xba, 0xba, 0x09, 0xf5, 0x4c, 0xef, 0x99, 0x8c, 0xe8, 0x65, 0x87, 0x5e, 0xf4, 0xa3, 0xd8,
+	0x9b, 0x4c, 0x93, 0xcb, 0xbf, 0x24, 0x74, 0x1e, 0x1b, 0xa1, 0x4f, 0x18, 0xf4, 0x5b, 0x6a, 0xaa,
+	0x17, 0x00, 0x49, 0xd4, 0xff, 0x44, 0xc6, 0xf3, 0xfe, 0x93, 0x50, 0x3b, 0xb0, 0x90, 0x79, 0x6b,
+	0xe0, 0x6f, 0x25, 0x19, 0x5a, 0x09, 0x76, 0x5f, 0xc5, 0x6e, 0x80, 0x25, 0xb1, 0xff, 0x0d, 0x95,
+	0x5f, 0x47, 0xae, 0x9d, 0x61, 0xe9, 0x95, 0xbe, 0xa2, 0x5c, 0xe9, 0x4b, 0xb2, 0x24, 0x28, 0x56,
+	0x15, 0x3d, 0x92, 0x62, 0x55, 0xf9, 0x3c, 0x88, 0x4b, 0xaa, 0xca, 0x34, 0x5f, 0x55, 0x2e, 0x43,
+	0xf6, 0x3b, 0xa4, 0xb9, 0x9a, 0x7d, 0xb5, 0x16, 0xa6, 0xe4, 0xf0, 0x7d, 0x5d, 0x3c, 0xf9, 0x15,
+	0xb3, 0x12, 0x15, 0x29, 0x5c, 0x0c, 0xb5, 0xe7, 0xd7, 0xf7, 0x8d, 0x86, 0x42, 0x66, 0x68, 0x43,
+	0xc6, 0x41, 0x6b, 0xe6, 0x83, 0xe6, 0xaa, 0x79, 0x55, 0xdf, 0x4b, 0xbc, 0x8c, 0x54, 0x2f, 0x0b,
+	0x06, 0xa4, 0xf9, 0xbf, 0x22, 0xed, 0x9d, 0x96, 0xa6, 0x03, 0x95, 0xf7, 0x25, 0x8a, 0x74, 0x9c,
+	0x49, 0x15, 0xab, 0xac, 0x51, 0xaa, 0xe4, 0x1a, 0xa5, 0x92, 0xc3, 0x3e, 0x56, 0x0f, 0x7b, 0x0d,
+	0x20, 0x89, 0x38, 0xc8, 0xdf, 0xb5, 0xf1, 0x2e, 0x7f, 0x54, 0x65, 0x38, 0xe7, 0x3b, 0x20, 0x5f,
+	0x36, 0x5d, 0x46, 0xef, 0x7c, 0xcf, 0x68, 0x75, 0xd6, 0x42, 0xca, 0x63, 0x4c, 0x46, 0xab, 0x34,
+	0xf8, 0x7b, 0x64, 0xbe, 0xc9, 0x97, 0xc6, 0x29, 0xcd, 0x4c, 0x4b, 0xcd, 0xcc, 0x27, 0x46, 0x34,
+	0x6f, 0x18, 0x9a, 0xdd, 0x14, 0x8d, 0xd6, 0xa2, 0xc4, 0x75, 0xae, 0x69, 0x21, 0xae, 0xf2, 0x84,
+	0x59, 0x92, 0x35, 0x6f, 0x8b, 0x59, 0xa3, 0xbd, 0x98, 0x7e, 0x81, 0x4a, 0xfa, 0x14, 0xe3, 0x6b,
+	0x9b, 0x29, 0x67, 0xda, 0xc5, 0x1b, 0x18, 0x2f, 0x83, 0x79, 0x72, 0xfa, 0x04, 0x53, 0x2d, 0x79,
+	0x82, 0xa9, 0x15, 0x9f, 0x60, 0x3a, 0x07, 0x46, 0x8f, 0xcf, 0x99, 0xc7, 0xdf, 0xc8, 0x9c, 0x59,
+	0x45, 0x97, 0xa4, 0xe7, 0xff, 0x42, 0xc6, 0x16, 0xec, 0xeb, 0xf3, 0xbb, 0xe4, 0xdc, 0xfa, 0x69,
+	0xe6, 0xdc, 0xd2, 0x03, 0xcb, 0xa4, 0x4c, 0xa1, 0x45, 0x4c, 0x53, 0x06, 0xc9, 0x94, 0x79, 0x30,
+	0x1c, 0x86, 0x22, 0x65, 0xe8, 0x77, 0x49, 0xca, 0xbc, 0x57, 0x53, 0xa6, 0xa0, 0x5c, 0x9a, 0xfe,
+	0x33, 0x32, 0xf4, 0xa1, 0x34, 0x44, 0x07, 0xa7, 0xa7, 0x27, 0xcc, 0x66, 0xb2, 0x85, 0xc4, 0x38,
+	0x79, 0x6d, 0x57, 0xe0, 0x88, 0x61, 0xda, 0xee, 0x55, 0x94, 0x76, 0xcf, 0xdc, 0xbc, 0xfc, 0xac,
+	0xd8, 0xbc, 0xe4, 0x60, 0x64, 0x8e, 0x23, 0x7d, 0x5b, 0xfc, 0x69, 0x48, 0x4b, 0x50, 0x7d, 0xd0,
+	0xb7, 0x54, 0x5a, 0x54, 0x1f, 0x91, 0xa1, 0x23, 0xbf, 0xfe, 0x5f, 0x0b, 0x4b, 0xf9, 0x6b, 0x51,
+	0x82, 0xee, 0xe7, 0x2a, 0x3a, 0xad, 0x69, 0xb5, 0xe1, 0xd3, 0xbf, 0x09, 0xe4, 0xc1, 0x95, 0x98,
+	0xfb, 0x85, 0x6a, 0x4e, 0xab, 0x4c, 0x9a, 0xf3, 0x0d, 0xef, 0x0c, 0x05, 0x73, 0x8f, 0x8c, 0xe6,
+	0x2e, 0x50, 0xd1, 0x9e, 0xd1, 0xbd, 0xc7, 0xf4, 0x2a, 0x1f, 0x4d, 0x03, 0x3f, 0x22, 0xd4, 0xc4,
+	0xf1, 0x53, 0x66, 0xa2, 0xe1, 0x5a, 0xc7, 0x4f, 0x69, 0x95, 0x7f, 0x14, 0x86, 0x41, 0xc8, 0x9a,
+	0xed, 0xa6, 0xcb, 0x07, 0xf2, 0x67, 0x5e, 0x85, 0xed, 0x2b, 0x3e, 0x70, 0xfe, 0x88, 0x74, 0xaf,
+	0x20, 0x9f, 0x71, 0x07, 0x98, 0x0f, 0xd8, 0x5f, 0x72, 0x7f, 0xed, 0xf4, 0x74, 0x31, 0x06, 0x77,
+	0x58, 0x7c, 0x91, 0x29, 0xc4, 0xd5, 0x5c, 0x0f, 0x7e, 0xc5, 0xed, 0x6c, 0x2a, 0x15, 0x49, 0x51,
+	0x94, 0x5a, 0xf9, 0x72, 0x00, 0x48, 0xe6, 0x67, 0x84, 0x26, 0x1d, 0x00, 0x00,
 }
@@ -0,0 +1,290 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Control packet types of the MQTT 3.1.1 protocol.
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetSubscribe  = 8
+	packetSuback     = 9
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+// maxKeepAlive is the longest keep alive the protocol can express.
+const maxKeepAlive = 65535 * time.Second
+
+// connackErrors describe the return codes of a refused connection.
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// message is an application message published to a subscribed topic.
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// client is a connection to an MQTT broker. It implements the subset of the
+// MQTT 3.1.1 protocol needed to receive messages with a QoS of 0 or 1.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mu sync.Mutex // serializes writes
+
+	keepAlive time.Duration
+	packetID  uint16
+}
+
+// newClient returns a client using conn.
+func newClient(conn net.Conn) *client {
+	return &client{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// connect sends a CONNECT packet and waits for the broker to accept it.
+func (c *client) connect(clientID, username, password string, keepAlive time.Duration, timeout time.Duration) error {
+	var flags byte = 0x02 // clean session
+	body := appendString(nil, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+	if username != "" {
+		flags |= 0x80
+	}
+	if password != "" {
+		flags |= 0x40
+	}
+	body = append(body, flags, 0, 0)
+	binary.BigEndian.PutUint16(body[len(body)-2:], uint16(keepAlive/time.Second))
+
+	body = appendString(body, clientID)
+	if username != "" {
+		body = appendString(body, username)
+	}
+	if password != "" {
+		body = appendString(body, password)
+	}
+
+	c.keepAlive = keepAlive
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if err := c.writePacket(packetConnect, 0, body); err != nil {
+		return err
+	}
+
+	typ, _, body, err := c.readPacket()
+	if err != nil {
+		return err
+	} else if typ != packetConnack || len(body) != 2 {
+		return fmt.Errorf("expected CONNACK, got packet type %d", typ)
+	} else if code := body[1]; code != 0 {
+		if reason, ok := connackErrors[code]; ok {
+			return fmt.Errorf("connection refused: %s", reason)
+		}
+		return fmt.Errorf("connection refused: return code %d", code)
+	}
+	return nil
+}
+
+// subscribe subscribes to topics with the given maximum QoS and waits for
+// the broker to acknowledge the subscriptions. Messages published before the
+// acknowledgement is received are returned.
+func (c *client) subscribe(topics []string, qos byte, timeout time.Duration) ([]message, error) {
+	c.packetID++
+	id := c.packetID
+
+	body := []byte{byte(id >> 8), byte(id)}
+	for _, topic := range topics {
+		body = appendString(body, topic)
+		body = append(body, qos)
+	}
+
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if err := c.writePacket(packetSubscribe, 0x02, body); err != nil {
+		return nil, err
+	}
+
+	var messages []message
+	for {
+		typ, flags, body, err := c.readPacket()
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case packetPublish:
+			m, err := c.handlePublish(flags, body)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, m)
+		case packetSuback:
+			if len(body) != 2+len(topics) || binary.BigEndian.Uint16(body) != id {
+				return nil, errors.New("invalid SUBACK")
+			}
+			for i, code := range body[2:] {
+				if code == 0x80 {
+					return nil, fmt.Errorf("subscription to %q refused", topics[i])
+				}
+			}
+			return messages, nil
+		}
+	}
+}
+
+// next returns the next message published to a subscribed topic.
+func (c *client) next() (message, error) {
+	for {
+		// The broker answers pings, so a connection without any packet for
+		// twice the keep alive is dead.
+		if c.keepAlive > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(2 * c.keepAlive))
+		}
+
+		typ, flags, body, err := c.readPacket()
+		if err != nil {
+			return message{}, err
+		}
+		if typ == packetPublish {
+			return c.handlePublish(flags, body)
+		}
+	}
+}
+
+// handlePublish decodes a PUBLISH packet and acknowledges it if required by
+// its QoS.
+func (c *client) handlePublish(flags byte, body []byte) (message, error) {
+	topic, body, err := readString(body)
+	if err != nil {
+		return message{}, err
+	}
+
+	switch qos := (flags >> 1) & 0x03; qos {
+	case 0:
+	case 1:
+		if len(body) < 2 {
+			return message{}, errors.New("invalid PUBLISH")
+		}
+		if err := c.writePacket(packetPuback, 0, body[:2]); err != nil {
+			return message{}, err
+		}
+		body = body[2:]
+	default:
+		return message{}, fmt.Errorf("unsupported QoS %d", qos)
+	}
+	return message{topic: topic, payload: body}, nil
+}
+
+// ping sends a PINGREQ packet.
+func (c *client) ping() error {
+	return c.writePacket(packetPingreq, 0, nil)
+}
+
+// Close sends a DISCONNECT packet and closes the connection.
+func (c *client) Close() error {
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.writePacket(packetDisconnect, 0, nil)
+	return c.conn.Close()
+}
+
+// writePacket writes a control packet of the given type.
+func (c *client) writePacket(typ, flags byte, body []byte) error {
+	buf := make([]byte, 0, 5+len(body))
+	buf = append(buf, typ<<4|flags)
+	buf = appendRemainingLength(buf, len(body))
+	buf = append(buf, body...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// readPacket reads a control packet and returns its type, flags and body.
+func (c *client) readPacket() (typ, flags byte, body []byte, err error) {
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	n, err := readRemainingLength(c.r)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	body = make([]byte, n)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, 0, nil, err
+	}
+	return header >> 4, header & 0x0f, body, nil
+}
+
+// appendRemainingLength appends n encoded as a variable length integer.
+func appendRemainingLength(buf []byte, n int) []byte {
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if n == 0 {
+			return buf
+		}
+	}
+}
+
+// readRemainingLength reads a variable length integer.
+func readRemainingLength(r io.ByteReader) (int, error) {
+	var n, shift int
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return n, nil
+		}
+		shift += 7
+	}
+	return 0, errors.New("malformed remaining length")
+}
+
+// appendString appends s prefixed with its length.
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, byte(len(s)>>8), byte(len(s)))
+	return append(buf, s...)
+}
+
+// readString reads a string prefixed with its length from buf and returns
+// the remainder of buf.
+func readString(buf []byte) (string, []byte, error) {
+	if len(buf) < 2 {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	n := int(binary.BigEndian.Uint16(buf))
+	if len(buf) < 2+n {
+		return "", nil, io.ErrUnexpectedEOF
+	}
+	return string(buf[2 : 2+n]), buf[2+n:], nil
+}
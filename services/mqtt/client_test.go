@@ -0,0 +1,50 @@
+package mqtt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRemainingLength(t *testing.T) {
+	for _, tt := range []struct {
+		n   int
+		enc []byte
+	}{
+		{n: 0, enc: []byte{0x00}},
+		{n: 127, enc: []byte{0x7f}},
+		{n: 128, enc: []byte{0x80, 0x01}},
+		{n: 16383, enc: []byte{0xff, 0x7f}},
+		{n: 16384, enc: []byte{0x80, 0x80, 0x01}},
+		{n: 268435455, enc: []byte{0xff, 0xff, 0xff, 0x7f}},
+	} {
+		if got := appendRemainingLength(nil, tt.n); !bytes.Equal(got, tt.enc) {
+			t.Errorf("%d: got %x, expected %x", tt.n, got, tt.enc)
+		}
+
+		n, err := readRemainingLength(bytes.NewReader(tt.enc))
+		if err != nil {
+			t.Errorf("%d: unexpected error: %s", tt.n, err)
+		} else if n != tt.n {
+			t.Errorf("%x: got %d, expected %d", tt.enc, n, tt.n)
+		}
+	}
+
+	if _, err := readRemainingLength(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0x7f})); err == nil {
+		t.Error("expected error for a remaining length of 5 bytes")
+	}
+}
+
+func TestReadString(t *testing.T) {
+	s, rest, err := readString([]byte{0x00, 0x03, 'a', '/', 'b', 0x00, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	} else if s != "a/b" {
+		t.Fatalf("got %q, expected %q", s, "a/b")
+	} else if !bytes.Equal(rest, []byte{0x00, 0x01}) {
+		t.Fatalf("got remainder %x", rest)
+	}
+
+	if _, _, err := readString([]byte{0x00, 0x03, 'a'}); err == nil {
+		t.Fatal("expected error for a truncated string")
+	}
+}
@@ -0,0 +1,259 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/influxdata/influxdb/monitor/diagnostics"
+	"github.com/influxdata/influxdb/toml"
+)
+
+const (
+	// DefaultServer is the default address of the MQTT broker.
+	DefaultServer = "tcp://localhost:1883"
+
+	// DefaultClientID is the default client identifier sent to the broker.
+	DefaultClientID = "influxdb"
+
+	// DefaultDatabase is the default database for MQTT points.
+	DefaultDatabase = "mqtt"
+
+	// DefaultRetentionPolicy is the default retention policy used for writes.
+	DefaultRetentionPolicy = ""
+
+	// DefaultQoS is the default quality of service of subscriptions.
+	DefaultQoS = 0
+
+	// DefaultFormat is the default encoding of message payloads.
+	DefaultFormat = FormatLineProtocol
+
+	// DefaultBatchSize is the default MQTT batch size.
+	DefaultBatchSize = 5000
+
+	// DefaultBatchPending is the default number of pending MQTT batches.
+	DefaultBatchPending = 10
+
+	// DefaultBatchTimeout is the default MQTT batch timeout.
+	DefaultBatchTimeout = time.Second
+
+	// DefaultKeepAlive is the default interval at which the broker is pinged.
+	DefaultKeepAlive = 30 * time.Second
+
+	// DefaultConnectTimeout is the default time allowed to connect to the
+	// broker.
+	DefaultConnectTimeout = 10 * time.Second
+
+	// DefaultReconnectInterval is the default time waited before
+	// reconnecting to the broker after the connection is lost.
+	DefaultReconnectInterval = 5 * time.Second
+)
+
+// Encodings of message payloads.
+const (
+	// FormatLineProtocol decodes payloads as line protocol.
+	FormatLineProtocol = "line"
+
+	// FormatJSON decodes payloads as a JSON point object, or an array of
+	// point objects, of the form {"measurement": ..., "tags": {...},
+	// "fields": {...}, "time": ...}.
+	FormatJSON = "json"
+)
+
+// Config represents the configuration of an MQTT service.
+type Config struct {
+	Enabled bool `toml:"enabled"`
+
+	// Server is the address of the broker, such as "tcp://localhost:1883"
+	// or "ssl://broker:8883".
+	Server   string   `toml:"server"`
+	ClientID string   `toml:"client-id"`
+	Username string   `toml:"username"`
+	Password string   `toml:"password"`
+	Topics   []string `toml:"topics"`
+	QoS      int      `toml:"qos"`
+
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+	Format          string `toml:"format"`
+	Precision       string `toml:"precision"`
+
+	// TopicTag is the tag holding the topic of the message of every point.
+	// The topic is not added if empty.
+	TopicTag string `toml:"topic-tag"`
+
+	BatchSize    int           `toml:"batch-size"`
+	BatchPending int           `toml:"batch-pending"`
+	BatchTimeout toml.Duration `toml:"batch-timeout"`
+
+	KeepAlive         toml.Duration `toml:"keep-alive"`
+	ConnectTimeout    toml.Duration `toml:"connect-timeout"`
+	ReconnectInterval toml.Duration `toml:"reconnect-interval"`
+
+	// TLS settings used for "ssl://" and "tls://" servers. The system
+	// certificate pool is used unless TLSCA is set, and a client certificate
+	// is only presented if TLSCert and TLSKey are set.
+	TLSCA              string `toml:"tls-ca"`
+	TLSCert            string `toml:"tls-cert"`
+	TLSKey             string `toml:"tls-key"`
+	InsecureSkipVerify bool   `toml:"insecure-skip-verify"`
+}
+
+// NewConfig returns a new instance of Config with defaults.
+func NewConfig() Config {
+	return Config{
+		Server:            DefaultServer,
+		ClientID:          DefaultClientID,
+		QoS:               DefaultQoS,
+		Database:          DefaultDatabase,
+		RetentionPolicy:   DefaultRetentionPolicy,
+		Format:            DefaultFormat,
+		BatchSize:         DefaultBatchSize,
+		BatchPending:      DefaultBatchPending,
+		BatchTimeout:      toml.Duration(DefaultBatchTimeout),
+		KeepAlive:         toml.Duration(DefaultKeepAlive),
+		ConnectTimeout:    toml.Duration(DefaultConnectTimeout),
+		ReconnectInterval: toml.Duration(DefaultReconnectInterval),
+	}
+}
+
+// WithDefaults takes the given config and returns a new config with any required
+// default values set.
+func (c *Config) WithDefaults() *Config {
+	d := *c
+	if d.Server == "" {
+		d.Server = DefaultServer
+	}
+	if d.ClientID == "" {
+		d.ClientID = DefaultClientID
+	}
+	if d.Database == "" {
+		d.Database = DefaultDatabase
+	}
+	if d.Format == "" {
+		d.Format = DefaultFormat
+	}
+	if d.BatchSize == 0 {
+		d.BatchSize = DefaultBatchSize
+	}
+	if d.BatchPending == 0 {
+		d.BatchPending = DefaultBatchPending
+	}
+	if d.BatchTimeout == 0 {
+		d.BatchTimeout = toml.Duration(DefaultBatchTimeout)
+	}
+	if d.KeepAlive == 0 {
+		d.KeepAlive = toml.Duration(DefaultKeepAlive)
+	}
+	if d.ConnectTimeout == 0 {
+		d.ConnectTimeout = toml.Duration(DefaultConnectTimeout)
+	}
+	if d.ReconnectInterval == 0 {
+		d.ReconnectInterval = toml.Duration(DefaultReconnectInterval)
+	}
+	return &d
+}
+
+// Validate returns an error if the config is invalid.
+func (c *Config) Validate() error {
+	if c.Server != "" {
+		if _, _, err := serverAddress(c.Server); err != nil {
+			return err
+		}
+	}
+
+	for _, topic := range c.Topics {
+		if topic == "" {
+			return errors.New("topics must not contain empty topics")
+		}
+	}
+
+	if c.QoS != 0 && c.QoS != 1 {
+		return errors.New("qos must be 0 or 1")
+	}
+
+	switch c.Format {
+	case "", FormatLineProtocol, FormatJSON:
+	default:
+		return errors.New(`Invalid value for format. Valid options are "line" and "json"`)
+	}
+
+	switch c.Precision {
+	case "", "n", "u", "ms", "s", "m", "h":
+	default:
+		return fmt.Errorf("invalid precision %q", c.Precision)
+	}
+
+	if c.KeepAlive < 0 || time.Duration(c.KeepAlive) > maxKeepAlive {
+		return fmt.Errorf("keep-alive must be between 0 and %s", maxKeepAlive)
+	}
+
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("tls-cert and tls-key must be set together")
+	}
+	return nil
+}
+
+// serverAddress returns the network address of server and whether the
+// connection uses TLS. A server without a scheme uses TCP.
+func serverAddress(server string) (addr string, secure bool, err error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		// Accept a plain "host:port".
+		if u, err = url.Parse("tcp://" + server); err != nil || u.Host == "" {
+			return "", false, fmt.Errorf("invalid server %q", server)
+		}
+	}
+
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		secure = false
+	case "ssl", "tls", "mqtts":
+		secure = true
+	default:
+		return "", false, fmt.Errorf(`invalid server %q. Valid schemes are "tcp" and "ssl"`, server)
+	}
+
+	addr = u.Host
+	if u.Port() == "" {
+		if secure {
+			addr += ":8883"
+		} else {
+			addr += ":1883"
+		}
+	}
+	return addr, secure, nil
+}
+
+// Configs wraps a slice of Config to aggregate diagnostics.
+type Configs []Config
+
+// Diagnostics returns one set of diagnostics for all of the Configs.
+func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
+	d := &diagnostics.Diagnostics{
+		Columns: []string{"enabled", "server", "topics", "database", "retention-policy", "batch-size", "batch-pending", "batch-timeout"},
+	}
+
+	for _, cc := range c {
+		if !cc.Enabled {
+			d.AddRow([]interface{}{false})
+			continue
+		}
+
+		r := []interface{}{true, cc.Server, cc.Topics, cc.Database, cc.RetentionPolicy, cc.BatchSize, cc.BatchPending, cc.BatchTimeout}
+		d.AddRow(r)
+	}
+
+	return d, nil
+}
+
+// Enabled returns true if any underlying Config is Enabled.
+func (c Configs) Enabled() bool {
+	for _, cc := range c {
+		if cc.Enabled {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package mqtt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdata/influxdb/services/mqtt"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	var c mqtt.Config
+	if _, err := toml.Decode(`
+enabled = true
+server = "ssl://broker:8883"
+client-id = "influxdb-1"
+username = "user"
+password = "pass"
+topics = ["sensors/#", "plant/+/temperature"]
+qos = 1
+database = "iot"
+format = "json"
+precision = "ms"
+topic-tag = "topic"
+batch-size = 100
+keep-alive = "1m"
+tls-ca = "/etc/ssl/ca.pem"
+insecure-skip-verify = true
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled: %v", c.Enabled)
+	} else if c.Server != "ssl://broker:8883" {
+		t.Fatalf("unexpected server: %s", c.Server)
+	} else if c.ClientID != "influxdb-1" {
+		t.Fatalf("unexpected client id: %s", c.ClientID)
+	} else if c.Username != "user" || c.Password != "pass" {
+		t.Fatalf("unexpected credentials: %s:%s", c.Username, c.Password)
+	} else if len(c.Topics) != 2 || c.Topics[0] != "sensors/#" || c.Topics[1] != "plant/+/temperature" {
+		t.Fatalf("unexpected topics: %v", c.Topics)
+	} else if c.QoS != 1 {
+		t.Fatalf("unexpected qos: %d", c.QoS)
+	} else if c.Database != "iot" {
+		t.Fatalf("unexpected database: %s", c.Database)
+	} else if c.Format != mqtt.FormatJSON {
+		t.Fatalf("unexpected format: %s", c.Format)
+	} else if c.Precision != "ms" {
+		t.Fatalf("unexpected precision: %s", c.Precision)
+	} else if c.TopicTag != "topic" {
+		t.Fatalf("unexpected topic tag: %s", c.TopicTag)
+	} else if c.BatchSize != 100 {
+		t.Fatalf("unexpected batch size: %d", c.BatchSize)
+	} else if time.Duration(c.KeepAlive) != time.Minute {
+		t.Fatalf("unexpected keep alive: %v", c.KeepAlive)
+	} else if c.TLSCA != "/etc/ssl/ca.pem" {
+		t.Fatalf("unexpected tls-ca: %s", c.TLSCA)
+	} else if !c.InsecureSkipVerify {
+		t.Fatalf("unexpected insecure-skip-verify: %v", c.InsecureSkipVerify)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, test := range []struct {
+		fn  func(c *mqtt.Config)
+		err bool
+	}{
+		{fn: func(c *mqtt.Config) {}},
+		{fn: func(c *mqtt.Config) { c.Server = "localhost:1883" }},
+		{fn: func(c *mqtt.Config) { c.Server = "tls://broker" }},
+		{fn: func(c *mqtt.Config) { c.Server = "http://broker" }, err: true},
+		{fn: func(c *mqtt.Config) { c.Topics = []string{""} }, err: true},
+		{fn: func(c *mqtt.Config) { c.QoS = 2 }, err: true},
+		{fn: func(c *mqtt.Config) { c.Format = "protobuf" }, err: true},
+		{fn: func(c *mqtt.Config) { c.Precision = "ns" }, err: true},
+		{fn: func(c *mqtt.Config) { c.TLSCert = "/etc/ssl/cert.pem" }, err: true},
+	} {
+		c := mqtt.NewConfig()
+		test.fn(&c)
+		if err := c.Validate(); test.err && err == nil {
+			t.Errorf("%+v: expected error", c)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", c, err)
+		}
+	}
+}
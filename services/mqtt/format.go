@@ -0,0 +1,95 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// parsePoints decodes the points in the payload of a message according to
+// format. Timestamps given as numbers are interpreted using precision, points
+// without a timestamp are assigned now.
+func parsePoints(format string, buf []byte, now time.Time, precision string) ([]models.Point, error) {
+	switch format {
+	case FormatJSON:
+		return parseJSONPoints(buf, now, precision)
+	default:
+		return models.ParsePointsWithPrecision(buf, now, precision)
+	}
+}
+
+// jsonPoint is a single point in the JSON payload format.
+type jsonPoint struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+	Time        json.RawMessage        `json:"time"`
+}
+
+// parseJSONPoints decodes a JSON payload holding either a single point object
+// or an array of point objects. Numeric field values are stored as floats.
+// The time may be a number in the given precision or an RFC3339 string.
+func parseJSONPoints(buf []byte, now time.Time, precision string) ([]models.Point, error) {
+	var jps []jsonPoint
+	if b := bytes.TrimSpace(buf); len(b) > 0 && b[0] == '[' {
+		if err := json.Unmarshal(b, &jps); err != nil {
+			return nil, err
+		}
+	} else {
+		var jp jsonPoint
+		if err := json.Unmarshal(b, &jp); err != nil {
+			return nil, err
+		}
+		jps = append(jps, jp)
+	}
+
+	points := make([]models.Point, 0, len(jps))
+	for _, jp := range jps {
+		t, err := jp.time(now, precision)
+		if err != nil {
+			return nil, err
+		}
+
+		fields := make(models.Fields, len(jp.Fields))
+		for k, v := range jp.Fields {
+			switch v := v.(type) {
+			case float64, string, bool:
+				fields[k] = v
+			default:
+				return nil, fmt.Errorf("unsupported value for field %q: %v", k, v)
+			}
+		}
+
+		p, err := models.NewPoint(jp.Measurement, models.NewTags(jp.Tags), fields, t)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// time returns the timestamp of the point.
+func (jp *jsonPoint) time(now time.Time, precision string) (time.Time, error) {
+	if len(jp.Time) == 0 || string(jp.Time) == "null" {
+		return now, nil
+	}
+
+	var ts int64
+	if err := json.Unmarshal(jp.Time, &ts); err == nil {
+		return models.SafeCalcTime(ts, precision)
+	}
+
+	var s string
+	if err := json.Unmarshal(jp.Time, &s); err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", jp.Time)
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), models.CheckTime(t)
+}
@@ -0,0 +1,433 @@
+// Package mqtt provides a service for InfluxDB to ingest points published to MQTT topics.
+package mqtt // import "github.com/influxdata/influxdb/services/mqtt"
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/tsdb"
+	"go.uber.org/zap"
+)
+
+// statistics gathered by the mqtt package.
+const (
+	statMessagesReceived    = "messagesRx"
+	statBytesReceived       = "bytesRx"
+	statPointsReceived      = "pointsRx"
+	statPointsParseFail     = "pointsParseFail"
+	statBatchesTransmitted  = "batchesTx"
+	statPointsTransmitted   = "pointsTx"
+	statBatchesTransmitFail = "batchesTxFail"
+	statConnects            = "connects"
+	statConnectFail         = "connectFail"
+)
+
+// Service is an MQTT service that subscribes to topics of a broker and writes
+// the points published to them.
+type Service struct {
+	wg sync.WaitGroup
+
+	mu     sync.RWMutex
+	ready  bool          // Has the required database been created?
+	done   chan struct{} // Is the service closing or closed?
+	client *client       // Current connection to the broker.
+
+	batcher   *tsdb.PointBatcher
+	tlsConfig *tls.Config
+	config    Config
+
+	PointsWriter interface {
+		WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	MetaClient interface {
+		CreateDatabase(name string) (*meta.DatabaseInfo, error)
+	}
+
+	Logger      *zap.Logger
+	stats       *Statistics
+	defaultTags models.StatisticTags
+}
+
+// NewService returns a new instance of Service.
+func NewService(c Config) *Service {
+	d := *c.WithDefaults()
+	return &Service{
+		config:      d,
+		Logger:      zap.NewNop(),
+		stats:       &Statistics{},
+		defaultTags: models.StatisticTags{"server": d.Server},
+	}
+}
+
+// Open starts the service.
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed() {
+		return nil // Already open.
+	}
+
+	if len(s.config.Topics) == 0 {
+		return errors.New("topics have to be specified in config")
+	}
+	if s.config.Database == "" {
+		return errors.New("database has to be specified in config")
+	}
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+
+	if addr, secure, _ := serverAddress(s.config.Server); secure {
+		tlsConfig, err := s.loadTLSConfig(addr)
+		if err != nil {
+			return err
+		}
+		s.tlsConfig = tlsConfig
+	}
+
+	s.done = make(chan struct{})
+
+	s.batcher = tsdb.NewPointBatcher(s.config.BatchSize, s.config.BatchPending, time.Duration(s.config.BatchTimeout))
+	s.batcher.Start()
+
+	s.wg.Add(2)
+	go s.run()
+	go s.processBatches(s.batcher)
+
+	return nil
+}
+
+// loadTLSConfig returns the TLS configuration used to connect to the broker
+// at addr.
+func (s *Service) loadTLSConfig(addr string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: s.config.InsecureSkipVerify,
+	}
+
+	if s.config.TLSCA != "" {
+		pem, err := ioutil.ReadFile(s.config.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", s.config.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if s.config.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(s.config.TLSCert, s.config.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Statistics maintains statistics for the MQTT service.
+type Statistics struct {
+	MessagesReceived    int64
+	BytesReceived       int64
+	PointsReceived      int64
+	PointsParseFail     int64
+	BatchesTransmitted  int64
+	PointsTransmitted   int64
+	BatchesTransmitFail int64
+	Connects            int64
+	ConnectFail         int64
+}
+
+// Statistics returns statistics for periodic monitoring.
+func (s *Service) Statistics(tags map[string]string) []models.Statistic {
+	return []models.Statistic{{
+		Name: "mqtt",
+		Tags: s.defaultTags.Merge(tags),
+		Values: map[string]interface{}{
+			statMessagesReceived:    atomic.LoadInt64(&s.stats.MessagesReceived),
+			statBytesReceived:       atomic.LoadInt64(&s.stats.BytesReceived),
+			statPointsReceived:      atomic.LoadInt64(&s.stats.PointsReceived),
+			statPointsParseFail:     atomic.LoadInt64(&s.stats.PointsParseFail),
+			statBatchesTransmitted:  atomic.LoadInt64(&s.stats.BatchesTransmitted),
+			statPointsTransmitted:   atomic.LoadInt64(&s.stats.PointsTransmitted),
+			statBatchesTransmitFail: atomic.LoadInt64(&s.stats.BatchesTransmitFail),
+			statConnects:            atomic.LoadInt64(&s.stats.Connects),
+			statConnectFail:         atomic.LoadInt64(&s.stats.ConnectFail),
+		},
+	}}
+}
+
+// run consumes messages from the broker until the service is closed,
+// reconnecting whenever the connection is lost.
+func (s *Service) run() {
+	defer s.wg.Done()
+
+	for {
+		err := s.consume()
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		s.Logger.Info("Lost connection to MQTT broker",
+			zap.String("server", s.config.Server), zap.Error(err))
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(time.Duration(s.config.ReconnectInterval)):
+		}
+	}
+}
+
+// consume connects to the broker, subscribes to the configured topics and
+// handles the messages published to them until the connection fails.
+func (s *Service) consume() error {
+	c, err := s.connect()
+	if err != nil {
+		atomic.AddInt64(&s.stats.ConnectFail, 1)
+		return err
+	}
+	defer s.disconnect(c)
+	atomic.AddInt64(&s.stats.Connects, 1)
+
+	messages, err := c.subscribe(s.config.Topics, byte(s.config.QoS), time.Duration(s.config.ConnectTimeout))
+	if err != nil {
+		return err
+	}
+	s.Logger.Info("Subscribed to MQTT topics",
+		zap.String("server", s.config.Server), zap.Strings("topics", s.config.Topics))
+
+	for _, m := range messages {
+		s.handleMessage(m)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.keepAlive(c, done)
+
+	for {
+		m, err := c.next()
+		if err != nil {
+			return err
+		}
+		s.handleMessage(m)
+	}
+}
+
+// connect dials the broker and registers the connection so it is closed
+// along with the service.
+func (s *Service) connect() (*client, error) {
+	addr, _, err := serverAddress(s.config.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(s.config.ConnectTimeout)
+	var conn net.Conn
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, s.tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := newClient(conn)
+	s.mu.Lock()
+	if s.closed() {
+		s.mu.Unlock()
+		conn.Close()
+		return nil, errors.New("service closed")
+	}
+	s.client = c
+	s.mu.Unlock()
+
+	if err := c.connect(s.config.ClientID, s.config.Username, s.config.Password, time.Duration(s.config.KeepAlive), timeout); err != nil {
+		s.disconnect(c)
+		return nil, err
+	}
+	return c, nil
+}
+
+// disconnect closes c and unregisters it.
+func (s *Service) disconnect(c *client) {
+	s.mu.Lock()
+	if s.client == c {
+		s.client = nil
+	}
+	s.mu.Unlock()
+	c.Close()
+}
+
+// keepAlive pings the broker at the keep alive interval until done is closed.
+func (s *Service) keepAlive(c *client, done chan struct{}) {
+	if s.config.KeepAlive == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(s.config.KeepAlive))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.ping(); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// handleMessage parses the points in the payload of m and adds them to the
+// batcher.
+func (s *Service) handleMessage(m message) {
+	atomic.AddInt64(&s.stats.MessagesReceived, 1)
+	atomic.AddInt64(&s.stats.BytesReceived, int64(len(m.payload)))
+
+	points, err := parsePoints(s.config.Format, m.payload, time.Now().UTC(), s.config.Precision)
+	if err != nil {
+		atomic.AddInt64(&s.stats.PointsParseFail, 1)
+		s.Logger.Info("Failed to parse points",
+			zap.String("topic", m.topic), zap.Error(err))
+		return
+	}
+
+	for _, p := range points {
+		if s.config.TopicTag != "" {
+			p.AddTag(s.config.TopicTag, m.topic)
+		}
+
+		select {
+		case s.batcher.In() <- p:
+		case <-s.done:
+			return
+		}
+	}
+	atomic.AddInt64(&s.stats.PointsReceived, int64(len(points)))
+}
+
+// processBatches continually drains the given batcher and writes the batches to the database.
+func (s *Service) processBatches(batcher *tsdb.PointBatcher) {
+	defer s.wg.Done()
+	for {
+		select {
+		case batch := <-batcher.Out():
+			// Will attempt to create database if not yet created.
+			if err := s.createInternalStorage(); err != nil {
+				s.Logger.Info("Required database not yet created",
+					logger.Database(s.config.Database), zap.Error(err))
+				continue
+			}
+
+			if err := s.PointsWriter.WritePointsPrivileged(s.config.Database, s.config.RetentionPolicy, models.ConsistencyLevelAny, batch); err == nil {
+				atomic.AddInt64(&s.stats.BatchesTransmitted, 1)
+				atomic.AddInt64(&s.stats.PointsTransmitted, int64(len(batch)))
+			} else {
+				s.Logger.Info("Failed to write point batch to database",
+					logger.Database(s.config.Database), zap.Error(err))
+				atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+			}
+
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close closes the service and the connection to the broker.
+func (s *Service) Close() error {
+	if wait := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.closed() {
+			return false // Already closed.
+		}
+		close(s.done)
+
+		if s.client != nil {
+			s.client.conn.Close()
+		}
+		return true
+	}(); !wait {
+		return nil
+	}
+	s.wg.Wait()
+
+	// Release all remaining resources.
+	s.mu.Lock()
+	s.batcher.Stop()
+	s.batcher = nil
+	s.done = nil
+	s.mu.Unlock()
+
+	s.Logger.Info("Service closed")
+
+	return nil
+}
+
+// Closed returns true if the service is currently closed.
+func (s *Service) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed()
+}
+
+func (s *Service) closed() bool {
+	select {
+	case <-s.done:
+		// Service is closing.
+		return true
+	default:
+	}
+	return s.done == nil
+}
+
+// createInternalStorage ensures that the required database has been created.
+func (s *Service) createInternalStorage() error {
+	s.mu.RLock()
+	ready := s.ready
+	s.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if _, err := s.MetaClient.CreateDatabase(s.config.Database); err != nil {
+		return err
+	}
+
+	// The service is now ready.
+	s.mu.Lock()
+	s.ready = true
+	s.mu.Unlock()
+	return nil
+}
+
+// WithLogger sets the logger on the service.
+func (s *Service) WithLogger(log *zap.Logger) {
+	s.Logger = log.With(zap.String("service", "mqtt"))
+}
@@ -0,0 +1,280 @@
+package mqtt
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/toml"
+)
+
+// Ensure the service subscribes to its topics and writes the points
+// published to them.
+func TestService_Write(t *testing.T) {
+	t.Parallel()
+
+	broker := NewTestBroker(t)
+	defer broker.Close()
+
+	c := NewConfig()
+	c.Server = "tcp://" + broker.Addr().String()
+	c.Username = "user"
+	c.Password = "pass"
+	c.Topics = []string{"sensors/#"}
+	c.QoS = 1
+	c.TopicTag = "topic"
+	c.BatchSize = 2
+	s := NewTestService(&c)
+
+	written := make(chan []models.Point, 1)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		if database != "mqtt" {
+			t.Errorf("unexpected database: %s", database)
+		}
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn := broker.Accept()
+	defer conn.conn.Close()
+
+	// Expect a CONNECT packet with credentials.
+	if typ, _, body, err := conn.readPacket(); err != nil {
+		t.Fatal(err)
+	} else if typ != packetConnect {
+		t.Fatalf("got packet type %d, expected CONNECT", typ)
+	} else if !bytes.HasSuffix(body, []byte("\x00\x04user\x00\x04pass")) {
+		t.Fatalf("missing credentials in CONNECT: %q", body)
+	}
+	if err := conn.writePacket(packetConnack, 0, []byte{0, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Expect a SUBSCRIBE packet for the topics.
+	typ, _, body, err := conn.readPacket()
+	if err != nil {
+		t.Fatal(err)
+	} else if typ != packetSubscribe {
+		t.Fatalf("got packet type %d, expected SUBSCRIBE", typ)
+	} else if exp := "\x00\x09sensors/#\x01"; string(body[2:]) != exp {
+		t.Fatalf("got subscriptions %q, expected %q", body[2:], exp)
+	}
+	if err := conn.writePacket(packetSuback, 0, []byte{body[0], body[1], 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Publish with QoS 1, which must be acknowledged, and QoS 0.
+	publish := appendString(nil, "sensors/room1")
+	publish = append(publish, 0x00, 0x07)
+	publish = append(publish, "cpu value=1 1000000000"...)
+	if err := conn.writePacket(packetPublish, 0x02, publish); err != nil {
+		t.Fatal(err)
+	}
+	if typ, _, body, err := conn.readPacket(); err != nil {
+		t.Fatal(err)
+	} else if typ != packetPuback || !bytes.Equal(body, []byte{0x00, 0x07}) {
+		t.Fatalf("got packet type %d %x, expected PUBACK", typ, body)
+	}
+
+	publish = appendString(nil, "sensors/room2")
+	publish = append(publish, "cpu value=2 2000000000\ncpu value=x"...)
+	if err := conn.writePacket(packetPublish, 0, publish); err != nil {
+		t.Fatal(err)
+	}
+	publish = appendString(nil, "sensors/room2")
+	publish = append(publish, "cpu value=2 2000000000"...)
+	if err := conn.writePacket(packetPublish, 0, publish); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case points := <-written:
+		if len(points) != 2 {
+			t.Fatalf("got %d points, expected 2", len(points))
+		}
+		if got, exp := points[0].String(), "cpu,topic=sensors/room1 value=1 1000000000"; got != exp {
+			t.Fatalf("got %q, expected %q", got, exp)
+		} else if got, exp := points[1].String(), "cpu,topic=sensors/room2 value=2 2000000000"; got != exp {
+			t.Fatalf("got %q, expected %q", got, exp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("points not written")
+	}
+
+	stats := s.Service.Statistics(nil)[0].Values
+	if got, exp := stats[statMessagesReceived], int64(3); got != exp {
+		t.Fatalf("got %v messages received, expected %d", got, exp)
+	} else if got, exp := stats[statPointsParseFail], int64(1); got != exp {
+		t.Fatalf("got %v parse failures, expected %d", got, exp)
+	}
+}
+
+// Ensure the service reconnects after the connection to the broker is lost.
+func TestService_Reconnect(t *testing.T) {
+	t.Parallel()
+
+	broker := NewTestBroker(t)
+	defer broker.Close()
+
+	c := NewConfig()
+	c.Server = broker.Addr().String()
+	c.Topics = []string{"sensors"}
+	c.ReconnectInterval = toml.Duration(10 * time.Millisecond)
+	s := NewTestService(&c)
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	// Refuse the first connection.
+	conn := broker.Accept()
+	if _, _, _, err := conn.readPacket(); err != nil {
+		t.Fatal(err)
+	}
+	conn.writePacket(packetConnack, 0, []byte{0, 5})
+	conn.conn.Close()
+
+	// Accept the second one.
+	conn = broker.Accept()
+	defer conn.conn.Close()
+	if typ, _, _, err := conn.readPacket(); err != nil {
+		t.Fatal(err)
+	} else if typ != packetConnect {
+		t.Fatalf("got packet type %d, expected CONNECT", typ)
+	}
+
+	if got, exp := s.Service.Statistics(nil)[0].Values[statConnectFail], int64(1); got != exp {
+		t.Fatalf("got %v failed connections, expected %d", got, exp)
+	}
+}
+
+// Ensure the service can be closed while connected to the broker.
+func TestService_OpenClose(t *testing.T) {
+	broker := NewTestBroker(t)
+	defer broker.Close()
+
+	c := NewConfig()
+	c.Server = broker.Addr().String()
+	c.Topics = []string{"sensors"}
+	s := NewTestService(&c)
+
+	// Closing a closed service is fine.
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Opening an already open service is fine.
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		conn := broker.Accept()
+		if _, _, _, err := conn.readPacket(); err != nil {
+			t.Fatal(err)
+		}
+		conn.writePacket(packetConnack, 0, []byte{0, 0})
+
+		if err := s.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+		conn.conn.Close()
+	}
+}
+
+// TestBroker accepts connections from the service. The test plays the role
+// of the broker over the accepted connections.
+type TestBroker struct {
+	t     *testing.T
+	ln    net.Listener
+	conns chan *client
+}
+
+func NewTestBroker(t *testing.T) *TestBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &TestBroker{t: t, ln: ln, conns: make(chan *client, 1)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				close(b.conns)
+				return
+			}
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+			b.conns <- newClient(conn)
+		}
+	}()
+	return b
+}
+
+// Accept returns the next connection of the service.
+func (b *TestBroker) Accept() *client {
+	select {
+	case conn := <-b.conns:
+		return conn
+	case <-time.After(5 * time.Second):
+		b.t.Fatal(errors.New("no connection"))
+		return nil
+	}
+}
+
+func (b *TestBroker) Addr() net.Addr { return b.ln.Addr() }
+func (b *TestBroker) Close() error   { return b.ln.Close() }
+
+type TestService struct {
+	Service       *Service
+	Config        Config
+	MetaClient    *internal.MetaClientMock
+	WritePointsFn func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+}
+
+func NewTestService(c *Config) *TestService {
+	if c == nil {
+		defaultC := NewConfig()
+		c = &defaultC
+	}
+
+	service := &TestService{
+		Service:    NewService(*c),
+		Config:     *c,
+		MetaClient: &internal.MetaClientMock{},
+	}
+	service.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	if testing.Verbose() {
+		service.Service.WithLogger(logger.New(os.Stderr))
+	}
+
+	service.Service.MetaClient = service.MetaClient
+	service.Service.PointsWriter = service
+	return service
+}
+
+func (s *TestService) WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	if s.WritePointsFn == nil {
+		return nil
+	}
+	return s.WritePointsFn(database, retentionPolicy, consistencyLevel, points)
+}
@@ -0,0 +1,240 @@
+package nats
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxControlLine is the longest protocol line accepted from the server.
+	// INFO lines listing the URLs of large clusters are the longest.
+	maxControlLine = 64 * 1024
+
+	// maxPingsOutstanding is the number of pings left unanswered before the
+	// connection is considered stale.
+	maxPingsOutstanding = 2
+)
+
+var errStaleConnection = errors.New("nats: stale connection")
+
+// message is a message published to a subscribed subject.
+type message struct {
+	subject string
+	payload []byte
+}
+
+// serverInfo is the information sent by the server in INFO lines.
+type serverInfo struct {
+	ServerID     string `json:"server_id"`
+	Version      string `json:"version"`
+	MaxPayload   int64  `json:"max_payload"`
+	TLSRequired  bool   `json:"tls_required"`
+	AuthRequired bool   `json:"auth_required"`
+}
+
+// connectOptions are the options sent to the server in the CONNECT line.
+type connectOptions struct {
+	Verbose     bool   `json:"verbose"`
+	Pedantic    bool   `json:"pedantic"`
+	TLSRequired bool   `json:"tls_required"`
+	Name        string `json:"name,omitempty"`
+	Lang        string `json:"lang"`
+	Version     string `json:"version"`
+	Protocol    int    `json:"protocol"`
+	User        string `json:"user,omitempty"`
+	Pass        string `json:"pass,omitempty"`
+	Token       string `json:"auth_token,omitempty"`
+}
+
+// client is a connection to a NATS server. It implements the subset of the
+// NATS client protocol needed to subscribe to subjects.
+type client struct {
+	tcp  net.Conn // underlying connection, closed by Close
+	conn net.Conn // tcp, or the TLS connection over it
+	r    *bufio.Reader
+	info serverInfo
+
+	mu    sync.Mutex // serializes writes
+	pings int32      // pings not answered yet
+}
+
+// newClient returns a client using conn.
+func newClient(conn net.Conn) *client {
+	return &client{tcp: conn, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// connect reads the INFO line of the server, upgrades the connection to TLS
+// if secure is set or the server requires it, and sends the CONNECT line. It
+// waits for the server to answer a PING, which confirms the server accepted
+// the connection.
+func (c *client) connect(opts connectOptions, secure bool, tlsConfig *tls.Config, timeout time.Duration) error {
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	op, args, err := c.readLine()
+	if err != nil {
+		return err
+	} else if op != "INFO" {
+		return fmt.Errorf("nats: expected INFO, got %q", op)
+	} else if err := json.Unmarshal(args, &c.info); err != nil {
+		return fmt.Errorf("nats: invalid INFO: %s", err)
+	}
+
+	if secure || c.info.TLSRequired {
+		if tlsConfig == nil {
+			return errors.New("nats: server requires TLS")
+		}
+		conn := tls.Client(c.conn, tlsConfig)
+		if err := conn.Handshake(); err != nil {
+			return err
+		}
+		c.conn = conn
+		c.r = bufio.NewReader(conn)
+		opts.TLSRequired = true
+	}
+
+	buf, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	if err := c.write("CONNECT " + string(buf) + "\r\nPING\r\n"); err != nil {
+		return err
+	}
+
+	for {
+		op, args, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case "PONG":
+			return nil
+		case "-ERR":
+			return serverError(args)
+		case "+OK", "INFO":
+		default:
+			return fmt.Errorf("nats: unexpected %q while connecting", op)
+		}
+	}
+}
+
+// subscribe subscribes to subject under the subscription id sid. If queue
+// is set, the messages of subject are shared with the other members of the
+// queue group.
+func (c *client) subscribe(subject, queue string, sid int) error {
+	line := "SUB " + subject
+	if queue != "" {
+		line += " " + queue
+	}
+	return c.write(line + " " + strconv.Itoa(sid) + "\r\n")
+}
+
+// next returns the next message published to a subscribed subject. It
+// answers the pings of the server in the meantime.
+func (c *client) next() (message, error) {
+	for {
+		op, args, err := c.readLine()
+		if err != nil {
+			return message{}, err
+		}
+
+		switch op {
+		case "MSG":
+			return c.readMessage(args)
+		case "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return message{}, err
+			}
+		case "PONG":
+			atomic.StoreInt32(&c.pings, 0)
+		case "-ERR":
+			return message{}, serverError(args)
+		case "+OK", "INFO":
+		default:
+			return message{}, fmt.Errorf("nats: unexpected %q", op)
+		}
+	}
+}
+
+// readMessage reads the payload of a message, given the arguments of its
+// MSG line: subject, subscription id, optional reply subject and size.
+func (c *client) readMessage(args []byte) (message, error) {
+	fields := bytes.Fields(args)
+	if len(fields) != 3 && len(fields) != 4 {
+		return message{}, fmt.Errorf("nats: invalid MSG arguments %q", args)
+	}
+
+	size, err := strconv.ParseInt(string(fields[len(fields)-1]), 10, 32)
+	if err != nil || size < 0 || (c.info.MaxPayload > 0 && size > c.info.MaxPayload) {
+		return message{}, fmt.Errorf("nats: invalid MSG size %q", fields[len(fields)-1])
+	}
+
+	payload := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return message{}, err
+	} else if !bytes.HasSuffix(payload, []byte("\r\n")) {
+		return message{}, errors.New("nats: MSG payload not terminated")
+	}
+	return message{subject: string(fields[0]), payload: payload[:size]}, nil
+}
+
+// ping sends a PING to the server. It fails if too many pings were not
+// answered, so a stale connection is detected.
+func (c *client) ping() error {
+	if atomic.AddInt32(&c.pings, 1) > maxPingsOutstanding {
+		return errStaleConnection
+	}
+	return c.write("PING\r\n")
+}
+
+// Close closes the connection. It may be called while the connection is in
+// use.
+func (c *client) Close() error {
+	return c.tcp.Close()
+}
+
+func (c *client) write(s string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := io.WriteString(c.conn, s)
+	return err
+}
+
+// readLine reads a protocol line and returns its operation in upper case
+// along with its arguments.
+func (c *client) readLine() (string, []byte, error) {
+	var line []byte
+	for {
+		b, err := c.r.ReadSlice('\n')
+		line = append(line, b...)
+		if err == nil {
+			break
+		} else if err != bufio.ErrBufferFull {
+			return "", nil, err
+		} else if len(line) > maxControlLine {
+			return "", nil, errors.New("nats: protocol line too long")
+		}
+	}
+
+	line = bytes.TrimRight(line, "\r\n")
+	op, args := line, []byte(nil)
+	if i := bytes.IndexAny(line, " \t"); i >= 0 {
+		op, args = line[:i], bytes.TrimLeft(line[i+1:], " \t")
+	}
+	return string(bytes.ToUpper(op)), args, nil
+}
+
+// serverError returns the error of an -ERR line.
+func serverError(args []byte) error {
+	return fmt.Errorf("nats: %s", bytes.Trim(args, "'"))
+}
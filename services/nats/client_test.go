@@ -0,0 +1,45 @@
+package nats
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestClient_Next(t *testing.T) {
+	r := strings.NewReader("+OK\r\nINFO {}\r\nMSG sensors.room1 1 11\r\ncpu value=1\r\n" +
+		"msg sensors.room2 2 _INBOX.1 0\r\n\r\n-ERR 'Authorization Violation'\r\n")
+	c := &client{r: bufio.NewReader(r)}
+
+	for _, exp := range []message{
+		{subject: "sensors.room1", payload: []byte("cpu value=1")},
+		{subject: "sensors.room2", payload: []byte{}},
+	} {
+		m, err := c.next()
+		if err != nil {
+			t.Fatal(err)
+		} else if m.subject != exp.subject || string(m.payload) != string(exp.payload) {
+			t.Fatalf("got %s %q, expected %s %q", m.subject, m.payload, exp.subject, exp.payload)
+		}
+	}
+
+	if _, err := c.next(); err == nil || err.Error() != "nats: Authorization Violation" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_Next_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"MSG sensors 1\r\n",
+		"MSG sensors 1 x\r\n",
+		"MSG sensors 1 4\r\ncpu value=1\r\n",
+		"MSG sensors 1 2048\r\n",
+		"UNSUB 1\r\n",
+		"INFO " + strings.Repeat("x", maxControlLine+1) + "\r\n",
+	} {
+		c := &client{r: bufio.NewReader(strings.NewReader(s)), info: serverInfo{MaxPayload: 1024}}
+		if _, err := c.next(); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/monitor/diagnostics"
+	"github.com/influxdata/influxdb/toml"
+)
+
+const (
+	// DefaultServer is the default address of the NATS server.
+	DefaultServer = "nats://localhost:4222"
+
+	// DefaultName is the default client name sent to the server.
+	DefaultName = "influxdb"
+
+	// DefaultDatabase is the default database for NATS points.
+	DefaultDatabase = "nats"
+
+	// DefaultRetentionPolicy is the default retention policy used for writes.
+	DefaultRetentionPolicy = ""
+
+	// DefaultBatchSize is the default NATS batch size.
+	DefaultBatchSize = 5000
+
+	// DefaultBatchPending is the default number of pending NATS batches.
+	DefaultBatchPending = 10
+
+	// DefaultBatchTimeout is the default NATS batch timeout.
+	DefaultBatchTimeout = time.Second
+
+	// DefaultPingInterval is the default interval at which the server is
+	// pinged.
+	DefaultPingInterval = 30 * time.Second
+
+	// DefaultConnectTimeout is the default time allowed to connect to a
+	// server.
+	DefaultConnectTimeout = 10 * time.Second
+
+	// DefaultReconnectInterval is the default time waited before
+	// reconnecting after the connection is lost.
+	DefaultReconnectInterval = 5 * time.Second
+)
+
+// Config represents the configuration of a NATS service.
+type Config struct {
+	Enabled bool `toml:"enabled"`
+
+	// Servers are the addresses of the servers of the cluster, such as
+	// "nats://localhost:4222" or "tls://nats:4222". They are tried in turn.
+	Servers  []string `toml:"servers"`
+	Name     string   `toml:"name"`
+	Username string   `toml:"username"`
+	Password string   `toml:"password"`
+	Token    string   `toml:"token"`
+
+	// Subjects are subscribed to. They may contain the "*" and ">"
+	// wildcards. If QueueGroup is set, the messages of the subjects are
+	// shared with the other members of the queue group.
+	Subjects   []string `toml:"subjects"`
+	QueueGroup string   `toml:"queue-group"`
+
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+	Precision       string `toml:"precision"`
+
+	// SubjectTag is the tag holding the subject of the message of every
+	// point. The subject is not added if empty.
+	SubjectTag string `toml:"subject-tag"`
+
+	BatchSize    int           `toml:"batch-size"`
+	BatchPending int           `toml:"batch-pending"`
+	BatchTimeout toml.Duration `toml:"batch-timeout"`
+
+	PingInterval      toml.Duration `toml:"ping-interval"`
+	ConnectTimeout    toml.Duration `toml:"connect-timeout"`
+	ReconnectInterval toml.Duration `toml:"reconnect-interval"`
+
+	// TLS settings used for "tls://" servers and servers requiring TLS. The
+	// system certificate pool is used unless TLSCA is set, and a client
+	// certificate is only presented if TLSCert and TLSKey are set.
+	TLSCA              string `toml:"tls-ca"`
+	TLSCert            string `toml:"tls-cert"`
+	TLSKey             string `toml:"tls-key"`
+	InsecureSkipVerify bool   `toml:"insecure-skip-verify"`
+}
+
+// NewConfig returns a new instance of Config with defaults.
+func NewConfig() Config {
+	return Config{
+		Servers:           []string{DefaultServer},
+		Name:              DefaultName,
+		Database:          DefaultDatabase,
+		RetentionPolicy:   DefaultRetentionPolicy,
+		BatchSize:         DefaultBatchSize,
+		BatchPending:      DefaultBatchPending,
+		BatchTimeout:      toml.Duration(DefaultBatchTimeout),
+		PingInterval:      toml.Duration(DefaultPingInterval),
+		ConnectTimeout:    toml.Duration(DefaultConnectTimeout),
+		ReconnectInterval: toml.Duration(DefaultReconnectInterval),
+	}
+}
+
+// WithDefaults takes the given config and returns a new config with any required
+// default values set.
+func (c *Config) WithDefaults() *Config {
+	d := *c
+	if len(d.Servers) == 0 {
+		d.Servers = []string{DefaultServer}
+	}
+	if d.Name == "" {
+		d.Name = DefaultName
+	}
+	if d.Database == "" {
+		d.Database = DefaultDatabase
+	}
+	if d.BatchSize == 0 {
+		d.BatchSize = DefaultBatchSize
+	}
+	if d.BatchPending == 0 {
+		d.BatchPending = DefaultBatchPending
+	}
+	if d.BatchTimeout == 0 {
+		d.BatchTimeout = toml.Duration(DefaultBatchTimeout)
+	}
+	if d.PingInterval == 0 {
+		d.PingInterval = toml.Duration(DefaultPingInterval)
+	}
+	if d.ConnectTimeout == 0 {
+		d.ConnectTimeout = toml.Duration(DefaultConnectTimeout)
+	}
+	if d.ReconnectInterval == 0 {
+		d.ReconnectInterval = toml.Duration(DefaultReconnectInterval)
+	}
+	return &d
+}
+
+// Validate returns an error if the config is invalid.
+func (c *Config) Validate() error {
+	for _, server := range c.Servers {
+		if _, _, err := serverAddress(server); err != nil {
+			return err
+		}
+	}
+
+	for _, subject := range c.Subjects {
+		if err := validateSubject(subject); err != nil {
+			return err
+		}
+	}
+	if strings.ContainsAny(c.QueueGroup, " \t\r\n") {
+		return fmt.Errorf("invalid queue-group %q", c.QueueGroup)
+	}
+
+	switch c.Precision {
+	case "", "n", "u", "ms", "s", "m", "h":
+	default:
+		return fmt.Errorf("invalid precision %q", c.Precision)
+	}
+
+	if c.Token != "" && c.Username != "" {
+		return errors.New("token and username must not be set together")
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("tls-cert and tls-key must be set together")
+	}
+	return nil
+}
+
+// validateSubject returns an error if subject is not a valid subscription
+// subject. Tokens are separated by dots, "*" matches a single token and ">"
+// matches all remaining tokens.
+func validateSubject(subject string) error {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("invalid subject %q", subject)
+	}
+
+	tokens := strings.Split(subject, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return fmt.Errorf("invalid subject %q: empty token", subject)
+		case token == ">" && i != len(tokens)-1:
+			return fmt.Errorf(`invalid subject %q: ">" must be the last token`, subject)
+		case len(token) > 1 && strings.ContainsAny(token, "*>"):
+			return fmt.Errorf("invalid subject %q: wildcards must be whole tokens", subject)
+		}
+	}
+	return nil
+}
+
+// serverAddress returns the network address of server and whether the
+// connection uses TLS. A server without a scheme uses TCP.
+func serverAddress(server string) (addr string, secure bool, err error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		// Accept a plain "host:port".
+		if u, err = url.Parse("nats://" + server); err != nil || u.Host == "" {
+			return "", false, fmt.Errorf("invalid server %q", server)
+		}
+	}
+
+	switch u.Scheme {
+	case "nats", "tcp":
+		secure = false
+	case "tls":
+		secure = true
+	default:
+		return "", false, fmt.Errorf(`invalid server %q. Valid schemes are "nats" and "tls"`, server)
+	}
+
+	addr = u.Host
+	if u.Port() == "" {
+		addr += ":4222"
+	}
+	return addr, secure, nil
+}
+
+// Configs wraps a slice of Config to aggregate diagnostics.
+type Configs []Config
+
+// Diagnostics returns one set of diagnostics for all of the Configs.
+func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
+	d := &diagnostics.Diagnostics{
+		Columns: []string{"enabled", "servers", "subjects", "queue-group", "database", "retention-policy", "batch-size", "batch-pending", "batch-timeout"},
+	}
+
+	for _, cc := range c {
+		if !cc.Enabled {
+			d.AddRow([]interface{}{false})
+			continue
+		}
+
+		r := []interface{}{true, cc.Servers, cc.Subjects, cc.QueueGroup, cc.Database, cc.RetentionPolicy, cc.BatchSize, cc.BatchPending, cc.BatchTimeout}
+		d.AddRow(r)
+	}
+
+	return d, nil
+}
+
+// Enabled returns true if any underlying Config is Enabled.
+func (c Configs) Enabled() bool {
+	for _, cc := range c {
+		if cc.Enabled {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package nats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdata/influxdb/services/nats"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	var c nats.Config
+	if _, err := toml.Decode(`
+enabled = true
+servers = ["nats://nats-1:4222", "tls://nats-2:4443"]
+name = "influxdb-1"
+username = "user"
+password = "pass"
+subjects = ["telegraf.>", "sensors.*.temperature"]
+queue-group = "influxdb"
+database = "telegraf"
+precision = "ms"
+subject-tag = "subject"
+batch-size = 100
+ping-interval = "1m"
+tls-ca = "/etc/ssl/ca.pem"
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled: %v", c.Enabled)
+	} else if len(c.Servers) != 2 || c.Servers[0] != "nats://nats-1:4222" || c.Servers[1] != "tls://nats-2:4443" {
+		t.Fatalf("unexpected servers: %v", c.Servers)
+	} else if c.Name != "influxdb-1" {
+		t.Fatalf("unexpected name: %s", c.Name)
+	} else if c.Username != "user" || c.Password != "pass" {
+		t.Fatalf("unexpected credentials: %s:%s", c.Username, c.Password)
+	} else if len(c.Subjects) != 2 || c.Subjects[0] != "telegraf.>" || c.Subjects[1] != "sensors.*.temperature" {
+		t.Fatalf("unexpected subjects: %v", c.Subjects)
+	} else if c.QueueGroup != "influxdb" {
+		t.Fatalf("unexpected queue group: %s", c.QueueGroup)
+	} else if c.Database != "telegraf" {
+		t.Fatalf("unexpected database: %s", c.Database)
+	} else if c.Precision != "ms" {
+		t.Fatalf("unexpected precision: %s", c.Precision)
+	} else if c.SubjectTag != "subject" {
+		t.Fatalf("unexpected subject tag: %s", c.SubjectTag)
+	} else if c.BatchSize != 100 {
+		t.Fatalf("unexpected batch size: %d", c.BatchSize)
+	} else if time.Duration(c.PingInterval) != time.Minute {
+		t.Fatalf("unexpected ping interval: %v", c.PingInterval)
+	} else if c.TLSCA != "/etc/ssl/ca.pem" {
+		t.Fatalf("unexpected tls-ca: %s", c.TLSCA)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, test := range []struct {
+		fn  func(c *nats.Config)
+		err bool
+	}{
+		{fn: func(c *nats.Config) {}},
+		{fn: func(c *nats.Config) { c.Servers = []string{"localhost:4222"} }},
+		{fn: func(c *nats.Config) { c.Servers = []string{"tls://nats"} }},
+		{fn: func(c *nats.Config) { c.Servers = []string{"http://nats"} }, err: true},
+		{fn: func(c *nats.Config) { c.Subjects = []string{"a.*.c", "a.>", ">"} }},
+		{fn: func(c *nats.Config) { c.Subjects = []string{""} }, err: true},
+		{fn: func(c *nats.Config) { c.Subjects = []string{"a..c"} }, err: true},
+		{fn: func(c *nats.Config) { c.Subjects = []string{"a.>.c"} }, err: true},
+		{fn: func(c *nats.Config) { c.Subjects = []string{"a.b*"} }, err: true},
+		{fn: func(c *nats.Config) { c.Subjects = []string{"a b"} }, err: true},
+		{fn: func(c *nats.Config) { c.QueueGroup = "a b" }, err: true},
+		{fn: func(c *nats.Config) { c.Precision = "ns" }, err: true},
+		{fn: func(c *nats.Config) { c.Token = "secret"; c.Username = "user" }, err: true},
+		{fn: func(c *nats.Config) { c.TLSCert = "/etc/ssl/cert.pem" }, err: true},
+	} {
+		c := nats.NewConfig()
+		test.fn(&c)
+		if err := c.Validate(); test.err && err == nil {
+			t.Errorf("%+v: expected error", c)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", c, err)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+// Package nats provides a service for InfluxDB to ingest points published to NATS subjects.
+package nats // import "github.com/influxdata/influxdb/services/nats"
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/tsdb"
+	"go.uber.org/zap"
+)
+
+const (
+	// parserChanLen is the number of messages queued for the parser.
+	parserChanLen = 1000
+)
+
+// statistics gathered by the nats package.
+const (
+	statMessagesReceived    = "messagesRx"
+	statBytesReceived       = "bytesRx"
+	statPointsReceived      = "pointsRx"
+	statPointsParseFail     = "pointsParseFail"
+	statBatchesTransmitted  = "batchesTx"
+	statPointsTransmitted   = "pointsTx"
+	statBatchesTransmitFail = "batchesTxFail"
+	statConnects            = "connects"
+	statConnectFail         = "connectFail"
+)
+
+// Service is a NATS service that subscribes to subjects and writes the
+// points published to them. Messages are read by serve, parsed by parser
+// and written in batches by writer, like the UDP service does for packets.
+type Service struct {
+	wg sync.WaitGroup
+
+	mu     sync.RWMutex
+	ready  bool          // Has the required database been created?
+	done   chan struct{} // Is the service closing or closed?
+	client *client       // Current connection to the server.
+
+	parserChan chan message
+	batcher    *tsdb.PointBatcher
+	tlsConfig  *tls.Config
+	config     Config
+
+	PointsWriter interface {
+		WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	MetaClient interface {
+		CreateDatabase(name string) (*meta.DatabaseInfo, error)
+	}
+
+	Logger      *zap.Logger
+	stats       *Statistics
+	defaultTags models.StatisticTags
+}
+
+// NewService returns a new instance of Service.
+func NewService(c Config) *Service {
+	d := *c.WithDefaults()
+	return &Service{
+		config:      d,
+		parserChan:  make(chan message, parserChanLen),
+		Logger:      zap.NewNop(),
+		stats:       &Statistics{},
+		defaultTags: models.StatisticTags{"servers": strings.Join(d.Servers, ",")},
+	}
+}
+
+// Open starts the service.
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed() {
+		return nil // Already open.
+	}
+
+	if len(s.config.Subjects) == 0 {
+		return errors.New("subjects have to be specified in config")
+	}
+	if s.config.Database == "" {
+		return errors.New("database has to be specified in config")
+	}
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+
+	// Servers may require TLS without a "tls://" address, so the TLS
+	// configuration is always loaded.
+	tlsConfig, err := s.loadTLSConfig()
+	if err != nil {
+		return err
+	}
+	s.tlsConfig = tlsConfig
+
+	s.done = make(chan struct{})
+
+	s.batcher = tsdb.NewPointBatcher(s.config.BatchSize, s.config.BatchPending, time.Duration(s.config.BatchTimeout))
+	s.batcher.Start()
+
+	s.wg.Add(3)
+	go s.run()
+	go s.parser()
+	go s.writer()
+
+	return nil
+}
+
+// loadTLSConfig returns the TLS configuration used to connect to the
+// servers.
+func (s *Service) loadTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: s.config.InsecureSkipVerify}
+
+	if s.config.TLSCA != "" {
+		pem, err := ioutil.ReadFile(s.config.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", s.config.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if s.config.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(s.config.TLSCert, s.config.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Statistics maintains statistics for the NATS service.
+type Statistics struct {
+	MessagesReceived    int64
+	BytesReceived       int64
+	PointsReceived      int64
+	PointsParseFail     int64
+	BatchesTransmitted  int64
+	PointsTransmitted   int64
+	BatchesTransmitFail int64
+	Connects            int64
+	ConnectFail         int64
+}
+
+// Statistics returns statistics for periodic monitoring.
+func (s *Service) Statistics(tags map[string]string) []models.Statistic {
+	return []models.Statistic{{
+		Name: "nats",
+		Tags: s.defaultTags.Merge(tags),
+		Values: map[string]interface{}{
+			statMessagesReceived:    atomic.LoadInt64(&s.stats.MessagesReceived),
+			statBytesReceived:       atomic.LoadInt64(&s.stats.BytesReceived),
+			statPointsReceived:      atomic.LoadInt64(&s.stats.PointsReceived),
+			statPointsParseFail:     atomic.LoadInt64(&s.stats.PointsParseFail),
+			statBatchesTransmitted:  atomic.LoadInt64(&s.stats.BatchesTransmitted),
+			statPointsTransmitted:   atomic.LoadInt64(&s.stats.PointsTransmitted),
+			statBatchesTransmitFail: atomic.LoadInt64(&s.stats.BatchesTransmitFail),
+			statConnects:            atomic.LoadInt64(&s.stats.Connects),
+			statConnectFail:         atomic.LoadInt64(&s.stats.ConnectFail),
+		},
+	}}
+}
+
+// run serves the subscriptions until the service is closed, moving on to the
+// next server whenever the connection is lost.
+func (s *Service) run() {
+	defer s.wg.Done()
+
+	for i := 0; ; i = (i + 1) % len(s.config.Servers) {
+		server := s.config.Servers[i]
+		err := s.serve(server)
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		s.Logger.Info("Lost connection to NATS server",
+			zap.String("server", server), zap.Error(err))
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(time.Duration(s.config.ReconnectInterval)):
+		}
+	}
+}
+
+// serve connects to server, subscribes to the configured subjects and hands
+// the messages published to them to the parser until the connection fails.
+func (s *Service) serve(server string) error {
+	c, err := s.connect(server)
+	if err != nil {
+		atomic.AddInt64(&s.stats.ConnectFail, 1)
+		return err
+	}
+	defer s.disconnect(c)
+	atomic.AddInt64(&s.stats.Connects, 1)
+
+	for i, subject := range s.config.Subjects {
+		if err := c.subscribe(subject, s.config.QueueGroup, i+1); err != nil {
+			return err
+		}
+	}
+	s.Logger.Info("Subscribed to NATS subjects",
+		zap.String("server", server), zap.Strings("subjects", s.config.Subjects))
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.keepAlive(c, done)
+
+	for {
+		m, err := c.next()
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(&s.stats.MessagesReceived, 1)
+		atomic.AddInt64(&s.stats.BytesReceived, int64(len(m.payload)))
+
+		select {
+		case s.parserChan <- m:
+		case <-s.done:
+			return nil
+		}
+	}
+}
+
+// connect dials server and registers the connection so it is closed along
+// with the service.
+func (s *Service) connect(server string) (*client, error) {
+	addr, secure, err := serverAddress(server)
+	if err != nil {
+		return nil, err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(s.config.ConnectTimeout)
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newClient(conn)
+	s.mu.Lock()
+	if s.closed() {
+		s.mu.Unlock()
+		conn.Close()
+		return nil, errors.New("service closed")
+	}
+	s.client = c
+	s.mu.Unlock()
+
+	tlsConfig := s.tlsConfig.Clone()
+	tlsConfig.ServerName = host
+
+	opts := connectOptions{
+		Name:     s.config.Name,
+		Lang:     "go",
+		Version:  "influxdb",
+		Protocol: 1,
+		User:     s.config.Username,
+		Pass:     s.config.Password,
+		Token:    s.config.Token,
+	}
+	if err := c.connect(opts, secure, tlsConfig, timeout); err != nil {
+		s.disconnect(c)
+		return nil, err
+	}
+	return c, nil
+}
+
+// disconnect closes c and unregisters it.
+func (s *Service) disconnect(c *client) {
+	s.mu.Lock()
+	if s.client == c {
+		s.client = nil
+	}
+	s.mu.Unlock()
+	c.Close()
+}
+
+// keepAlive pings the server at the ping interval until done is closed. The
+// connection is closed if the server stops answering.
+func (s *Service) keepAlive(c *client, done chan struct{}) {
+	ticker := time.NewTicker(time.Duration(s.config.PingInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.ping(); err != nil {
+				s.Logger.Info("NATS server stopped answering pings", zap.Error(err))
+				c.Close()
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// parser parses the points of the messages handed by serve and adds them to
+// the batcher.
+func (s *Service) parser() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case m := <-s.parserChan:
+			points, err := models.ParsePointsWithPrecision(m.payload, time.Now().UTC(), s.config.Precision)
+			if err != nil {
+				atomic.AddInt64(&s.stats.PointsParseFail, 1)
+				s.Logger.Info("Failed to parse points",
+					zap.String("subject", m.subject), zap.Error(err))
+				continue
+			}
+
+			for _, p := range points {
+				if s.config.SubjectTag != "" {
+					p.AddTag(s.config.SubjectTag, m.subject)
+				}
+
+				select {
+				case s.batcher.In() <- p:
+				case <-s.done:
+					return
+				}
+			}
+			atomic.AddInt64(&s.stats.PointsReceived, int64(len(points)))
+		}
+	}
+}
+
+// writer writes the batches emitted by the batcher to the database.
+func (s *Service) writer() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case batch := <-s.batcher.Out():
+			// Will attempt to create database if not yet created.
+			if err := s.createInternalStorage(); err != nil {
+				s.Logger.Info("Required database not yet created",
+					logger.Database(s.config.Database), zap.Error(err))
+				continue
+			}
+
+			if err := s.PointsWriter.WritePointsPrivileged(s.config.Database, s.config.RetentionPolicy, models.ConsistencyLevelAny, batch); err == nil {
+				atomic.AddInt64(&s.stats.BatchesTransmitted, 1)
+				atomic.AddInt64(&s.stats.PointsTransmitted, int64(len(batch)))
+			} else {
+				s.Logger.Info("Failed to write point batch to database",
+					logger.Database(s.config.Database), zap.Error(err))
+				atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+			}
+
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close closes the service and the connection to the server.
+func (s *Service) Close() error {
+	if wait := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.closed() {
+			return false // Already closed.
+		}
+		close(s.done)
+
+		if s.client != nil {
+			s.client.Close()
+		}
+		return true
+	}(); !wait {
+		return nil
+	}
+	s.wg.Wait()
+
+	// Release all remaining resources.
+	s.mu.Lock()
+	s.batcher.Stop()
+	s.batcher = nil
+	s.done = nil
+	s.mu.Unlock()
+
+	s.Logger.Info("Service closed")
+
+	return nil
+}
+
+// Closed returns true if the service is currently closed.
+func (s *Service) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed()
+}
+
+func (s *Service) closed() bool {
+	select {
+	case <-s.done:
+		// Service is closing.
+		return true
+	default:
+	}
+	return s.done == nil
+}
+
+// createInternalStorage ensures that the required database has been created.
+func (s *Service) createInternalStorage() error {
+	s.mu.RLock()
+	ready := s.ready
+	s.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if _, err := s.MetaClient.CreateDatabase(s.config.Database); err != nil {
+		return err
+	}
+
+	// The service is now ready.
+	s.mu.Lock()
+	s.ready = true
+	s.mu.Unlock()
+	return nil
+}
+
+// WithLogger sets the logger on the service.
+func (s *Service) WithLogger(log *zap.Logger) {
+	s.Logger = log.With(zap.String("service", "nats"))
+}
@@ -0,0 +1,339 @@
+package nats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/toml"
+)
+
+// Ensure the service subscribes to its subjects and writes the points
+// published to them.
+func TestService_Write(t *testing.T) {
+	t.Parallel()
+
+	server := NewTestServer(t)
+	defer server.Close()
+
+	c := NewConfig()
+	c.Servers = []string{"nats://" + server.Addr().String()}
+	c.Username = "user"
+	c.Password = "pass"
+	c.Subjects = []string{"sensors.>", "plant.*.temperature"}
+	c.QueueGroup = "influxdb"
+	c.SubjectTag = "subject"
+	c.BatchSize = 2
+	s := NewTestService(&c)
+
+	written := make(chan []models.Point, 1)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		if database != "nats" {
+			t.Errorf("unexpected database: %s", database)
+		}
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn := server.Accept()
+	defer conn.Close()
+
+	// Expect the credentials in CONNECT.
+	opts := conn.Connect()
+	if opts.User != "user" || opts.Pass != "pass" || opts.Name != "influxdb" {
+		t.Fatalf("unexpected connect options: %+v", opts)
+	}
+
+	// Expect a queue subscription for every subject.
+	if line := conn.ReadLine(); line != "SUB sensors.> influxdb 1" {
+		t.Fatalf("unexpected line: %q", line)
+	} else if line := conn.ReadLine(); line != "SUB plant.*.temperature influxdb 2" {
+		t.Fatalf("unexpected line: %q", line)
+	}
+
+	// The service answers pings between messages.
+	conn.Write("PING\r\n")
+	if line := conn.ReadLine(); line != "PONG" {
+		t.Fatalf("unexpected line: %q", line)
+	}
+
+	conn.Publish("sensors.room1", 1, "cpu value=1 1000000000")
+	conn.Publish("sensors.room2", 1, "cpu value=2 2000000000\ncpu value=x")
+	conn.Publish("plant.a.temperature", 2, "cpu value=3 3000000000")
+
+	select {
+	case points := <-written:
+		if len(points) != 2 {
+			t.Fatalf("got %d points, expected 2", len(points))
+		}
+		if got, exp := points[0].String(), "cpu,subject=sensors.room1 value=1 1000000000"; got != exp {
+			t.Fatalf("got %q, expected %q", got, exp)
+		} else if got, exp := points[1].String(), "cpu,subject=plant.a.temperature value=3 3000000000"; got != exp {
+			t.Fatalf("got %q, expected %q", got, exp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("points not written")
+	}
+
+	stats := s.Service.Statistics(nil)[0].Values
+	if got, exp := stats[statMessagesReceived], int64(3); got != exp {
+		t.Fatalf("got %v messages received, expected %d", got, exp)
+	} else if got, exp := stats[statPointsParseFail], int64(1); got != exp {
+		t.Fatalf("got %v parse failures, expected %d", got, exp)
+	}
+}
+
+// Ensure the service moves on to the next server when the connection fails.
+func TestService_Reconnect(t *testing.T) {
+	t.Parallel()
+
+	server1, server2 := NewTestServer(t), NewTestServer(t)
+	defer server1.Close()
+	defer server2.Close()
+
+	c := NewConfig()
+	c.Servers = []string{server1.Addr().String(), server2.Addr().String()}
+	c.Subjects = []string{"sensors"}
+	c.Token = "secret"
+	c.ReconnectInterval = toml.Duration(10 * time.Millisecond)
+	s := NewTestService(&c)
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	// Refuse the first connection.
+	conn := server1.Accept()
+	conn.ReadLine()
+	conn.Write("-ERR 'Authorization Violation'\r\n")
+	conn.Close()
+
+	// Accept the connection to the second server.
+	conn = server2.Accept()
+	defer conn.Close()
+	if opts := conn.Connect(); opts.Token != "secret" {
+		t.Fatalf("unexpected connect options: %+v", opts)
+	}
+	if line := conn.ReadLine(); line != "SUB sensors 1" {
+		t.Fatalf("unexpected line: %q", line)
+	}
+
+	if got, exp := s.Service.Statistics(nil)[0].Values[statConnectFail], int64(1); got != exp {
+		t.Fatalf("got %v failed connections, expected %d", got, exp)
+	}
+}
+
+// Ensure the connection is dropped when the server stops answering pings.
+func TestService_StaleConnection(t *testing.T) {
+	t.Parallel()
+
+	server := NewTestServer(t)
+	defer server.Close()
+
+	c := NewConfig()
+	c.Servers = []string{server.Addr().String()}
+	c.Subjects = []string{"sensors"}
+	c.PingInterval = toml.Duration(10 * time.Millisecond)
+	c.ReconnectInterval = toml.Duration(10 * time.Millisecond)
+	s := NewTestService(&c)
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn := server.Accept()
+	defer conn.Close()
+	conn.Connect()
+
+	// Ignore pings until the service reconnects.
+	conn = server.Accept()
+	defer conn.Close()
+	conn.Connect()
+	if line := conn.ReadLine(); line != "SUB sensors 1" {
+		t.Fatalf("unexpected line: %q", line)
+	}
+
+	if got, exp := s.Service.Statistics(nil)[0].Values[statConnects], int64(2); got != exp {
+		t.Fatalf("got %v connections, expected %d", got, exp)
+	}
+}
+
+// Ensure the service can be closed while connected to the server.
+func TestService_OpenClose(t *testing.T) {
+	server := NewTestServer(t)
+	defer server.Close()
+
+	c := NewConfig()
+	c.Servers = []string{server.Addr().String()}
+	c.Subjects = []string{"sensors"}
+	s := NewTestService(&c)
+
+	// Closing a closed service is fine.
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Opening an already open service is fine.
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		conn := server.Accept()
+		conn.Connect()
+
+		if err := s.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+}
+
+// TestServer accepts connections from the service. The test plays the role
+// of the server over the accepted connections.
+type TestServer struct {
+	t     *testing.T
+	ln    net.Listener
+	conns chan *TestConn
+}
+
+func NewTestServer(t *testing.T) *TestServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &TestServer{t: t, ln: ln, conns: make(chan *TestConn, 1)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				close(s.conns)
+				return
+			}
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+			fmt.Fprintf(conn, "INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+			s.conns <- &TestConn{t: t, conn: conn, r: bufio.NewReader(conn)}
+		}
+	}()
+	return s
+}
+
+// Accept returns the next connection of the service.
+func (s *TestServer) Accept() *TestConn {
+	select {
+	case conn := <-s.conns:
+		return conn
+	case <-time.After(5 * time.Second):
+		s.t.Fatal(errors.New("no connection"))
+		return nil
+	}
+}
+
+func (s *TestServer) Addr() net.Addr { return s.ln.Addr() }
+func (s *TestServer) Close() error   { return s.ln.Close() }
+
+// TestConn is a connection of the service to a TestServer.
+type TestConn struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Connect reads the CONNECT line of the service and answers the PING
+// following it.
+func (c *TestConn) Connect() connectOptions {
+	line := c.ReadLine()
+	if !strings.HasPrefix(line, "CONNECT ") {
+		c.t.Fatalf("unexpected line: %q", line)
+	}
+
+	var opts connectOptions
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &opts); err != nil {
+		c.t.Fatal(err)
+	}
+	if line := c.ReadLine(); line != "PING" {
+		c.t.Fatalf("unexpected line: %q", line)
+	}
+	c.Write("PONG\r\n")
+	return opts
+}
+
+// ReadLine returns the next line sent by the service.
+func (c *TestConn) ReadLine() string {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+// Publish sends a message to the service.
+func (c *TestConn) Publish(subject string, sid int, payload string) {
+	c.Write(fmt.Sprintf("MSG %s %d %d\r\n%s\r\n", subject, sid, len(payload), payload))
+}
+
+func (c *TestConn) Write(s string) {
+	if _, err := c.conn.Write([]byte(s)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *TestConn) Close() error { return c.conn.Close() }
+
+type TestService struct {
+	Service       *Service
+	Config        Config
+	MetaClient    *internal.MetaClientMock
+	WritePointsFn func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+}
+
+func NewTestService(c *Config) *TestService {
+	if c == nil {
+		defaultC := NewConfig()
+		c = &defaultC
+	}
+
+	service := &TestService{
+		Service:    NewService(*c),
+		Config:     *c,
+		MetaClient: &internal.MetaClientMock{},
+	}
+	service.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	if testing.Verbose() {
+		service.Service.WithLogger(logger.New(os.Stderr))
+	}
+
+	service.Service.MetaClient = service.MetaClient
+	service.Service.PointsWriter = service
+	return service
+}
+
+func (s *TestService) WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	if s.WritePointsFn == nil {
+		return nil
+	}
+	return s.WritePointsFn(database, retentionPolicy, consistencyLevel, points)
+}
@@ -1,6 +1,9 @@
 package opentsdb
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/influxdata/influxdb/monitor/diagnostics"
@@ -31,6 +34,35 @@ const (
 
 	// DefaultCertificate is the default location of the certificate used when TLS is enabled.
 	DefaultCertificate = "/etc/ssl/influxdb.pem"
+
+	// DefaultPrecision is the default unit of telnet timestamps.
+	DefaultPrecision = PrecisionAuto
+
+	// DefaultDownsampleFunction is the default function used to combine the
+	// points of a downsampled metric.
+	DefaultDownsampleFunction = DownsampleAvg
+)
+
+// Functions used to combine the points of a series within the interval of a
+// downsample rule.
+const (
+	DownsampleAvg  = "avg"
+	DownsampleSum  = "sum"
+	DownsampleMin  = "min"
+	DownsampleMax  = "max"
+	DownsampleLast = "last"
+)
+
+const (
+	// PrecisionAuto detects the unit of telnet timestamps from their length:
+	// 10 digits for seconds and 13 digits for milliseconds.
+	PrecisionAuto = "auto"
+
+	// PrecisionSeconds reads telnet timestamps as seconds.
+	PrecisionSeconds = "s"
+
+	// PrecisionMilliseconds reads telnet timestamps as milliseconds.
+	PrecisionMilliseconds = "ms"
 )
 
 // Config represents the configuration of the OpenTSDB service.
@@ -46,6 +78,22 @@ type Config struct {
 	BatchPending     int           `toml:"batch-pending"`
 	BatchTimeout     toml.Duration `toml:"batch-timeout"`
 	LogPointErrors   bool          `toml:"log-point-errors"`
+	QueryEnabled     bool          `toml:"query-enabled"`
+	Precision        string        `toml:"precision"`
+
+	// DownsampleRules downsample the points of selected metrics as they are
+	// received, before they are batched. The first rule whose pattern
+	// matches a metric is used.
+	DownsampleRules []DownsampleRule `toml:"downsample"`
+}
+
+// DownsampleRule combines the points of each series of the metrics matching
+// the regular expression Metric, whose timestamps fall into the same
+// Interval, into a single point using Function.
+type DownsampleRule struct {
+	Metric   string        `toml:"metric"`
+	Interval toml.Duration `toml:"interval"`
+	Function string        `toml:"function"`
 }
 
 // NewConfig returns a new config for the service.
@@ -61,6 +109,7 @@ func NewConfig() Config {
 		BatchPending:     DefaultBatchPending,
 		BatchTimeout:     toml.Duration(DefaultBatchTimeout),
 		LogPointErrors:   true,
+		Precision:        DefaultPrecision,
 	}
 }
 
@@ -92,10 +141,37 @@ func (c *Config) WithDefaults() *Config {
 	if d.BatchTimeout == 0 {
 		d.BatchTimeout = toml.Duration(DefaultBatchTimeout)
 	}
+	if d.Precision == "" {
+		d.Precision = DefaultPrecision
+	}
 
 	return &d
 }
 
+// Validate returns an error if the config is invalid.
+func (c *Config) Validate() error {
+	switch c.Precision {
+	case "", PrecisionAuto, PrecisionSeconds, PrecisionMilliseconds:
+	default:
+		return errors.New(`Invalid value for precision. Valid options are "auto", "s" and "ms"`)
+	}
+
+	for _, r := range c.DownsampleRules {
+		if _, err := regexp.Compile(r.Metric); err != nil {
+			return fmt.Errorf("invalid downsample metric %q: %s", r.Metric, err)
+		} else if r.Interval <= 0 {
+			return fmt.Errorf("downsample interval for %q must be positive", r.Metric)
+		}
+
+		switch r.Function {
+		case "", DownsampleAvg, DownsampleSum, DownsampleMin, DownsampleMax, DownsampleLast:
+		default:
+			return fmt.Errorf(`invalid downsample function %q. Valid options are "avg", "sum", "min", "max" and "last"`, r.Function)
+		}
+	}
+	return nil
+}
+
 // Configs wraps a slice of Config to aggregate diagnostics.
 type Configs []Config
 
@@ -2,9 +2,11 @@ package opentsdb_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/influxdata/influxdb/services/opentsdb"
+	itoml "github.com/influxdata/influxdb/toml"
 )
 
 func TestConfig_Parse(t *testing.T) {
@@ -18,6 +20,11 @@ consistency-level ="all"
 tls-enabled = true
 certificate = "/etc/ssl/cert.pem"
 log-point-errors = true
+
+[[downsample]]
+metric = "^sys\\.cpu\\."
+interval = "10s"
+function = "max"
 `, &c); err != nil {
 		t.Fatal(err)
 	}
@@ -37,5 +44,44 @@ log-point-errors = true
 		t.Fatalf("unexpected certificate: %s", c.Certificate)
 	} else if !c.LogPointErrors {
 		t.Fatalf("unexpected log-point-errors: %v", c.LogPointErrors)
+	} else if exp := []opentsdb.DownsampleRule{{Metric: `^sys\.cpu\.`, Interval: itoml.Duration(10 * time.Second), Function: "max"}}; len(c.DownsampleRules) != 1 || c.DownsampleRules[0] != exp[0] {
+		t.Fatalf("unexpected downsample rules: %+v", c.DownsampleRules)
+	}
+}
+
+func TestConfig_Validate_Precision(t *testing.T) {
+	for _, precision := range []string{"", "auto", "s", "ms"} {
+		c := opentsdb.NewConfig()
+		c.Precision = precision
+		if err := c.Validate(); err != nil {
+			t.Errorf("%q: unexpected error: %s", precision, err)
+		}
+	}
+
+	c := opentsdb.NewConfig()
+	c.Precision = "us"
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for precision us")
+	}
+}
+
+func TestConfig_Validate_DownsampleRules(t *testing.T) {
+	for _, test := range []struct {
+		rule opentsdb.DownsampleRule
+		err  bool
+	}{
+		{rule: opentsdb.DownsampleRule{Metric: `^sys\.cpu\.`, Interval: itoml.Duration(10 * time.Second)}},
+		{rule: opentsdb.DownsampleRule{Metric: `^sys\.`, Interval: itoml.Duration(time.Minute), Function: "max"}},
+		{rule: opentsdb.DownsampleRule{Metric: `^sys\.(`, Interval: itoml.Duration(time.Minute)}, err: true},
+		{rule: opentsdb.DownsampleRule{Metric: `^sys\.`}, err: true},
+		{rule: opentsdb.DownsampleRule{Metric: `^sys\.`, Interval: itoml.Duration(time.Minute), Function: "median"}, err: true},
+	} {
+		c := opentsdb.NewConfig()
+		c.DownsampleRules = []opentsdb.DownsampleRule{test.rule}
+		if err := c.Validate(); test.err && err == nil {
+			t.Errorf("%+v: expected error", test.rule)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", test.rule, err)
+		}
 	}
 }
@@ -0,0 +1,172 @@
+package opentsdb
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// downsampleRule is a compiled DownsampleRule.
+type downsampleRule struct {
+	metric   *regexp.Regexp
+	interval time.Duration
+	fn       string
+}
+
+// ingestKey identifies the points combined into a single point: the points
+// of a series whose timestamps fall into the same interval.
+type ingestKey struct {
+	series string
+	start  int64
+}
+
+// ingestAggregate holds the running aggregates of the points for an
+// ingestKey.
+type ingestAggregate struct {
+	name    string
+	tags    models.Tags
+	rule    *downsampleRule
+	arrived time.Time
+
+	count               int
+	sum, min, max, last float64
+}
+
+// ingestDownsampler downsamples the points of the metrics matching its rules
+// as they are received. The points of a series received within the interval
+// of a rule are combined into a single point, timestamped with the start of
+// the interval.
+type ingestDownsampler struct {
+	mu         sync.Mutex
+	rules      []downsampleRule
+	aggregates map[ingestKey]*ingestAggregate
+}
+
+// newIngestDownsampler returns a downsampler for the given rules.
+func newIngestDownsampler(rules []DownsampleRule) (*ingestDownsampler, error) {
+	d := &ingestDownsampler{aggregates: make(map[ingestKey]*ingestAggregate)}
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Metric)
+		if err != nil {
+			return nil, err
+		} else if r.Interval <= 0 {
+			return nil, fmt.Errorf("downsample interval for %q must be positive", r.Metric)
+		}
+
+		fn := r.Function
+		if fn == "" {
+			fn = DefaultDownsampleFunction
+		}
+		d.rules = append(d.rules, downsampleRule{
+			metric:   re,
+			interval: time.Duration(r.Interval),
+			fn:       fn,
+		})
+	}
+	return d, nil
+}
+
+// tick returns how often the downsampler should be flushed: the shortest
+// interval of its rules.
+func (d *ingestDownsampler) tick() time.Duration {
+	var tick time.Duration
+	for _, r := range d.rules {
+		if tick == 0 || r.interval < tick {
+			tick = r.interval
+		}
+	}
+	return tick
+}
+
+// rule returns the first rule matching name, or nil.
+func (d *ingestDownsampler) rule(name string) *downsampleRule {
+	for i := range d.rules {
+		if d.rules[i].metric.MatchString(name) {
+			return &d.rules[i]
+		}
+	}
+	return nil
+}
+
+// add adds p to the aggregates. It returns false, leaving p to be written as
+// is, unless a rule matches the metric of p and p has a float value.
+func (d *ingestDownsampler) add(p models.Point, now time.Time) bool {
+	r := d.rule(string(p.Name()))
+	if r == nil {
+		return false
+	}
+
+	fields, err := p.Fields()
+	if err != nil || len(fields) != 1 {
+		return false
+	}
+	v, ok := fields["value"].(float64)
+	if !ok {
+		return false
+	}
+
+	key := ingestKey{
+		series: string(p.Key()),
+		start:  p.Time().Truncate(r.interval).UnixNano(),
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	agg := d.aggregates[key]
+	if agg == nil {
+		agg = &ingestAggregate{name: string(p.Name()), tags: p.Tags(), rule: r, arrived: now, min: v, max: v}
+		d.aggregates[key] = agg
+	}
+	agg.count++
+	agg.sum += v
+	agg.last = v
+	if v < agg.min {
+		agg.min = v
+	}
+	if v > agg.max {
+		agg.max = v
+	}
+	return true
+}
+
+// flush removes and returns the aggregates that have been collecting points
+// for at least the interval of their rule as of now.
+func (d *ingestDownsampler) flush(now time.Time) []models.Point {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var points []models.Point
+	for key, agg := range d.aggregates {
+		if now.Sub(agg.arrived) < agg.rule.interval {
+			continue
+		}
+		delete(d.aggregates, key)
+
+		p, err := models.NewPoint(agg.name, agg.tags, models.Fields{"value": agg.value()}, time.Unix(0, key.start))
+		if err != nil {
+			continue
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// value returns the aggregate computed by the function of its rule.
+func (agg *ingestAggregate) value() float64 {
+	switch agg.rule.fn {
+	case DownsampleSum:
+		return agg.sum
+	case DownsampleMin:
+		return agg.min
+	case DownsampleMax:
+		return agg.max
+	case DownsampleLast:
+		return agg.last
+	default:
+		return agg.sum / float64(agg.count)
+	}
+}
@@ -0,0 +1,75 @@
+package opentsdb
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/toml"
+)
+
+func TestIngestDownsampler(t *testing.T) {
+	d, err := newIngestDownsampler([]DownsampleRule{
+		{Metric: `^sys\.cpu\.`, Interval: toml.Duration(10 * time.Second)},
+		{Metric: `^sys\.`, Interval: toml.Duration(time.Minute), Function: DownsampleMax},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := d.tick(), 10*time.Second; got != exp {
+		t.Fatalf("got tick %s, expected %s", got, exp)
+	}
+
+	now := time.Unix(100, 0)
+	points, err := models.ParsePointsString(`sys.cpu.user,host=a value=1 1000000000
+sys.cpu.user,host=a value=2 2000000000
+sys.cpu.user,host=a value=6 11000000000
+sys.mem.free,host=a value=3 1000000000
+sys.mem.free,host=a value=5 2000000000
+net.bytes,host=a value=4 1000000000`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range points {
+		if got, exp := d.add(p, now), i < 5; got != exp {
+			t.Fatalf("point %d: got downsampled %v, expected %v", i, got, exp)
+		}
+	}
+
+	// Each aggregate is flushed once it is an interval old.
+	flush := func(after time.Duration) []string {
+		var got []string
+		for _, p := range d.flush(now.Add(after)) {
+			got = append(got, p.String())
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	if got := flush(5 * time.Second); len(got) != 0 {
+		t.Fatalf("unexpected points flushed: %v", got)
+	}
+	if got, exp := flush(10*time.Second), []string{"sys.cpu.user,host=a value=1.5 0", "sys.cpu.user,host=a value=6 10000000000"}; !equalStrings(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+	if got, exp := flush(time.Minute), []string{"sys.mem.free,host=a value=5 0"}; !equalStrings(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+	if got := flush(time.Hour); len(got) != 0 {
+		t.Fatalf("unexpected points flushed: %v", got)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
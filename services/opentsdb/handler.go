@@ -8,12 +8,17 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/influxdb"
 	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxql"
 	"go.uber.org/zap"
 )
 
@@ -26,9 +31,16 @@ type Handler struct {
 		WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
 	}
 
+	// QueryExecutor runs the queries of the /api/query endpoint, which is
+	// disabled if it is nil.
+	QueryExecutor interface {
+		ExecuteQuery(query *influxql.Query, opt query.ExecutionOptions, closing chan struct{}) <-chan *query.Result
+	}
+
 	Logger *zap.Logger
 
-	stats *Statistics
+	stats       *Statistics
+	downsampler *ingestDownsampler
 }
 
 // ServeHTTP handles an HTTP request of the OpenTSDB REST API.
@@ -38,11 +50,259 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
 	case "/api/put":
 		h.servePut(w, r)
+	case "/api/query":
+		h.serveQuery(w, r)
+	case "/api/search/lookup":
+		h.serveLookup(w, r)
+	case "/api/suggest":
+		h.serveSuggest(w, r)
 	default:
 		http.NotFound(w, r)
 	}
 }
 
+// serveQuery implements OpenTSDB's HTTP /api/query endpoint. Each metric
+// query is translated into an InfluxQL SELECT statement against the
+// handler's database and retention policy.
+func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if h.QueryExecutor == nil {
+		http.Error(w, "queries are disabled", http.StatusForbidden)
+		return
+	}
+
+	var req queryRequest
+	switch r.Method {
+	case "GET":
+		values := r.URL.Query()
+		req.Start = queryTime(values.Get("start"))
+		req.End = queryTime(values.Get("end"))
+		_, req.MsResolution = values["ms"]
+		for _, m := range values["m"] {
+			q, err := parseMetricQuery(m)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.Queries = append(req.Queries, q)
+		}
+	case "POST":
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "json object decode error", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if req.Start == "" {
+		http.Error(w, "missing start time", http.StatusBadRequest)
+		return
+	} else if len(req.Queries) == 0 {
+		http.Error(w, "missing queries", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	start, err := parseTime(string(req.Start), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end, err := parseTime(string(req.End), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	q := &influxql.Query{Statements: make(influxql.Statements, 0, len(req.Queries))}
+	for i := range req.Queries {
+		stmt, err := req.Queries[i].statement(h.Database, h.RetentionPolicy, start, end)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.Statements = append(q.Statements, stmt)
+	}
+
+	rows, err := h.execute(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := []*queryResult{}
+	for i := range req.Queries {
+		for _, row := range rows[i] {
+			results = append(results, rowToResult(&req.Queries[i], row, req.MsResolution))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// serveSuggest implements OpenTSDB's HTTP /api/suggest endpoint, suggesting
+// measurements, tag keys or tag values starting with a prefix.
+func (h *Handler) serveSuggest(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if h.QueryExecutor == nil {
+		http.Error(w, "queries are disabled", http.StatusForbidden)
+		return
+	}
+
+	var req suggestRequest
+	switch r.Method {
+	case "GET":
+		values := r.URL.Query()
+		req.Type, req.Q = values.Get("type"), values.Get("q")
+		if s := values.Get("max"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				http.Error(w, "invalid max: "+s, http.StatusBadRequest)
+				return
+			}
+			req.Max = n
+		}
+	case "POST":
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "json object decode error", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Max <= 0 {
+		req.Max = DefaultSuggestMax
+	}
+
+	stmt, err := req.statement(h.Database)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.execute(&influxql.Query{Statements: influxql.Statements{stmt}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req.suggestions(rows[0]))
+}
+
+// serveLookup implements OpenTSDB's HTTP /api/search/lookup endpoint, listing
+// the series of a metric matching tag filters.
+func (h *Handler) serveLookup(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if h.QueryExecutor == nil {
+		http.Error(w, "queries are disabled", http.StatusForbidden)
+		return
+	}
+
+	var (
+		q     subQuery
+		limit int
+	)
+	switch r.Method {
+	case "GET":
+		values := r.URL.Query()
+		metric, tags, err := parseMetric(values.Get("m"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.Metric, q.Tags = metric, tags
+		if s := values.Get("limit"); s != "" {
+			if limit, err = strconv.Atoi(s); err != nil {
+				http.Error(w, "invalid limit: "+s, http.StatusBadRequest)
+				return
+			}
+		}
+	case "POST":
+		var req struct {
+			Metric string      `json:"metric"`
+			Tags   []lookupTag `json:"tags"`
+			Limit  int         `json:"limit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "json object decode error", http.StatusBadRequest)
+			return
+		}
+		q.Metric, limit = req.Metric, req.Limit
+		q.Tags = make(map[string]string, len(req.Tags))
+		for _, tag := range req.Tags {
+			q.Tags[tag.Key] = tag.Value
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if limit <= 0 {
+		limit = DefaultLookupLimit
+	}
+
+	stmt, err := lookupStatement(h.Database, q, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.execute(&influxql.Query{Statements: influxql.Statements{stmt}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := lookupResponse{
+		Type:    "LOOKUP",
+		Metric:  q.Metric,
+		Tags:    make([]lookupTag, 0, len(q.Tags)),
+		Limit:   limit,
+		Results: lookupResults(rows[0]),
+	}
+	for k, v := range q.Tags {
+		resp.Tags = append(resp.Tags, lookupTag{Key: k, Value: v})
+	}
+	sort.Slice(resp.Tags, func(i, j int) bool { return resp.Tags[i].Key < resp.Tags[j].Key })
+	resp.TotalResults = len(resp.Results)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// execute runs q against the handler's database and returns the rows of
+// each of its statements, or the first error returned.
+func (h *Handler) execute(q *influxql.Query) ([]models.Rows, error) {
+	closing := make(chan struct{})
+	defer close(closing)
+
+	// Drain every result so the executor is never left blocked.
+	rows := make([]models.Rows, len(q.Statements))
+	var err error
+	for res := range h.QueryExecutor.ExecuteQuery(q, query.ExecutionOptions{Database: h.Database}, closing) {
+		if res.Err != nil {
+			if err == nil {
+				err = res.Err
+			}
+			continue
+		} else if res.StatementID < 0 || res.StatementID >= len(rows) {
+			continue
+		}
+		rows[res.StatementID] = append(rows[res.StatementID], res.Series...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
 // servePut implements OpenTSDB's HTTP /api/put endpoint.
 func (h *Handler) servePut(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
@@ -54,18 +314,16 @@ func (h *Handler) servePut(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Wrap reader if it's gzip encoded.
-	var br *bufio.Reader
-	if r.Header.Get("Content-Encoding") == "gzip" {
-		zr, err := gzip.NewReader(r.Body)
-		if err != nil {
-			http.Error(w, "could not read gzip, "+err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		br = bufio.NewReader(zr)
-	} else {
-		br = bufio.NewReader(r.Body)
+	body, err := requestBody(r)
+	if err == errUnsupportedEncoding {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	} else if err != nil {
+		http.Error(w, "could not read gzip, "+err.Error(), http.StatusBadRequest)
+		return
 	}
+	defer body.Close()
+	br := bufio.NewReader(body)
 
 	// Lookahead at the first byte.
 	f, err := br.Peek(1)
@@ -105,12 +363,12 @@ func (h *Handler) servePut(w http.ResponseWriter, r *http.Request) {
 		p := dps[i]
 
 		// Convert timestamp to Go time.
-		// If time value is over a billion then it's microseconds.
+		// If time value is over ten billion then it's milliseconds.
 		var ts time.Time
 		if p.Time < 10000000000 {
 			ts = time.Unix(p.Time, 0)
 		} else {
-			ts = time.Unix(p.Time/1000, (p.Time%1000)*1000)
+			ts = time.Unix(0, p.Time*int64(time.Millisecond))
 		}
 
 		pt, err := models.NewPoint(p.Metric, models.NewTags(p.Tags), map[string]interface{}{"value": p.Value}, ts)
@@ -121,8 +379,18 @@ func (h *Handler) servePut(w http.ResponseWriter, r *http.Request) {
 			}
 			continue
 		}
+		if h.downsampler != nil && h.downsampler.add(pt, time.Now()) {
+			if h.stats != nil {
+				atomic.AddInt64(&h.stats.PointsDownsampled, 1)
+			}
+			continue
+		}
 		points = append(points, pt)
 	}
+	if len(points) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 
 	// Write points.
 	if err := h.PointsWriter.WritePointsPrivileged(h.Database, h.RetentionPolicy, models.ConsistencyLevelAny, points); influxdb.IsClientError(err) {
@@ -138,6 +406,23 @@ func (h *Handler) servePut(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// errUnsupportedEncoding is returned for request bodies with a content
+// encoding other than gzip.
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding, only gzip is supported")
+
+// requestBody returns the body of r, decompressed according to its
+// Content-Encoding header.
+func requestBody(r *http.Request) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return r.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r.Body)
+	default:
+		return nil, errUnsupportedEncoding
+	}
+}
+
 // chanListener represents a listener that receives connections through a channel.
 type chanListener struct {
 	addr   net.Addr
@@ -0,0 +1,487 @@
+package opentsdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxql"
+)
+
+// queryRequest represents an OpenTSDB /api/query request.
+type queryRequest struct {
+	Start        queryTime  `json:"start"`
+	End          queryTime  `json:"end,omitempty"`
+	Queries      []subQuery `json:"queries"`
+	MsResolution bool       `json:"msResolution,omitempty"`
+}
+
+// subQuery represents a single metric query within a queryRequest.
+type subQuery struct {
+	Aggregator  string            `json:"aggregator"`
+	Metric      string            `json:"metric"`
+	Downsample  string            `json:"downsample,omitempty"`
+	Rate        bool              `json:"rate,omitempty"`
+	RateOptions rateOptions       `json:"rateOptions,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// rateOptions controls how a rate is computed.
+type rateOptions struct {
+	Counter bool `json:"counter,omitempty"`
+}
+
+// queryResult represents a single series in an OpenTSDB /api/query response.
+type queryResult struct {
+	Metric        string             `json:"metric"`
+	Tags          map[string]string  `json:"tags"`
+	AggregateTags []string           `json:"aggregateTags"`
+	DPS           map[string]float64 `json:"dps"`
+}
+
+// queryTime is an absolute or relative OpenTSDB time, which may be given as
+// a JSON number or string.
+type queryTime string
+
+// UnmarshalJSON decodes a time given as a JSON number or string.
+func (t *queryTime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = queryTime(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*t = queryTime(n.String())
+	return nil
+}
+
+// aggregators maps OpenTSDB aggregators and downsampling functions onto
+// InfluxQL functions. Percentiles are handled separately.
+var aggregators = map[string]string{
+	"avg":    "mean",
+	"count":  "count",
+	"dev":    "stddev",
+	"first":  "first",
+	"last":   "last",
+	"max":    "max",
+	"median": "median",
+	"mimmax": "max",
+	"mimmin": "min",
+	"min":    "min",
+	"sum":    "sum",
+	"zimsum": "sum",
+}
+
+// percentileRegex matches OpenTSDB percentile aggregators, such as p99 or p999.
+var percentileRegex = regexp.MustCompile(`^p(\d{2})(\d*)$`)
+
+// aggregateCall returns the InfluxQL call for the OpenTSDB aggregator name
+// applied to the value field.
+func aggregateCall(name string) (*influxql.Call, error) {
+	value := &influxql.VarRef{Val: "value"}
+	if fn, ok := aggregators[name]; ok {
+		return &influxql.Call{Name: fn, Args: []influxql.Expr{value}}, nil
+	}
+
+	m := percentileRegex.FindStringSubmatch(name)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported aggregator: %q", name)
+	}
+	p, err := strconv.ParseFloat(m[1]+"."+m[2]+"0", 64)
+	if err != nil {
+		return nil, err
+	}
+	return &influxql.Call{Name: "percentile", Args: []influxql.Expr{value, &influxql.NumberLiteral{Val: p}}}, nil
+}
+
+// downsampler is a parsed OpenTSDB downsampling specification, such as
+// "1m-avg" or "0all-max-zero".
+type downsampler struct {
+	interval time.Duration // zero downsamples the whole range into one value
+	call     *influxql.Call
+	fill     influxql.FillOption
+}
+
+// parseDownsample parses an OpenTSDB downsampling specification.
+func parseDownsample(s string) (*downsampler, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid downsample: %q", s)
+	}
+
+	d := &downsampler{fill: influxql.NoFill}
+	if parts[0] != "0all" {
+		interval, err := parseDuration(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		d.interval = interval
+	}
+
+	call, err := aggregateCall(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	d.call = call
+
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "none", "nan", "null":
+		case "zero":
+			d.fill = influxql.NumberFill
+		default:
+			return nil, fmt.Errorf("unsupported downsample fill policy: %q", parts[2])
+		}
+	}
+	return d, nil
+}
+
+// parseDuration parses an OpenTSDB duration, such as "30s" or "1d". Months
+// ("n") count as 30 days and years as 365 days.
+func parseDuration(s string) (time.Duration, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return 0, fmt.Errorf("invalid duration: %q", s)
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	var unit time.Duration
+	switch s[i:] {
+	case "ms":
+		unit = time.Millisecond
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	case "w":
+		unit = 7 * 24 * time.Hour
+	case "n":
+		unit = 30 * 24 * time.Hour
+	case "y":
+		unit = 365 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("invalid duration unit: %q", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// absoluteTimeFormats are the absolute date formats accepted by OpenTSDB.
+var absoluteTimeFormats = []string{
+	"2006/01/02-15:04:05",
+	"2006/01/02 15:04:05",
+	"2006/01/02-15:04",
+	"2006/01/02 15:04",
+	"2006/01/02",
+}
+
+// parseTime parses an OpenTSDB time: a Unix timestamp in seconds or
+// milliseconds, a relative time such as "1h-ago", or an absolute date. An
+// empty time or "now" returns now.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	switch {
+	case s == "" || s == "now":
+		return now, nil
+	case strings.HasSuffix(s, "-ago"):
+		d, err := parseDuration(strings.TrimSuffix(s, "-ago"))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(-d), nil
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if len(s) > 10 {
+			return time.Unix(0, n*int64(time.Millisecond)), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+
+	for _, layout := range absoluteTimeFormats {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %q", s)
+}
+
+// parseMetricQuery parses the m parameter of a GET /api/query request, of the
+// form aggregator:[downsample:][rate[{counter}]:]metric[{tag=value,...}].
+func parseMetricQuery(s string) (subQuery, error) {
+	var q subQuery
+
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return q, fmt.Errorf("invalid metric query: %q", s)
+	}
+	q.Aggregator, s = s[:i], s[i+1:]
+
+	for {
+		i := strings.Index(s, ":")
+		if i < 0 {
+			break
+		}
+		part := s[:i]
+		if strings.HasPrefix(part, "rate") {
+			if opts := strings.TrimPrefix(part, "rate"); opts != "" {
+				if !strings.HasPrefix(opts, "{") || !strings.HasSuffix(opts, "}") {
+					return q, fmt.Errorf("invalid rate options: %q", opts)
+				}
+				q.RateOptions.Counter = strings.HasPrefix(opts[1:], "counter")
+			}
+			q.Rate = true
+		} else if part != "" && part[0] >= '0' && part[0] <= '9' && !strings.Contains(part, "{") {
+			q.Downsample = part
+		} else {
+			break
+		}
+		s = s[i+1:]
+	}
+
+	metric, tags, err := parseMetric(s)
+	if err != nil {
+		return q, err
+	} else if metric == "" {
+		return q, errors.New("missing metric")
+	}
+	q.Metric, q.Tags = metric, tags
+	return q, nil
+}
+
+// parseMetric parses a metric with optional tags, of the form
+// metric[{tag=value,...}]. The metric may be empty.
+func parseMetric(s string) (string, map[string]string, error) {
+	i := strings.Index(s, "{")
+	if i < 0 {
+		return s, nil, nil
+	} else if !strings.HasSuffix(s, "}") {
+		return "", nil, fmt.Errorf("invalid tags: %q", s[i:])
+	}
+
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(s[i+1:len(s)-1], ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return "", nil, fmt.Errorf("invalid tag: %q", pair)
+		}
+		tags[kv[0]] = kv[1]
+	}
+	return s[:i], tags, nil
+}
+
+// statement translates q into an InfluxQL SELECT statement against the
+// given database and retention policy for the time range [start, end].
+//
+// Each series matching the metric and tags is first selected on its own, in
+// a subquery, downsampled and turned into a rate as requested. The outer
+// query then aggregates the series, grouped by the tags of the query.
+// Without downsampling, the series are aggregated per second.
+func (q *subQuery) statement(db, rp string, start, end time.Time) (*influxql.SelectStatement, error) {
+	timeCond := &influxql.BinaryExpr{
+		Op: influxql.AND,
+		LHS: &influxql.BinaryExpr{
+			Op:  influxql.GTE,
+			LHS: &influxql.VarRef{Val: "time"},
+			RHS: &influxql.TimeLiteral{Val: start.UTC()},
+		},
+		RHS: &influxql.BinaryExpr{
+			Op:  influxql.LTE,
+			LHS: &influxql.VarRef{Val: "time"},
+			RHS: &influxql.TimeLiteral{Val: end.UTC()},
+		},
+	}
+
+	cond := influxql.Expr(timeCond)
+	tagKeys := make([]string, 0, len(q.Tags))
+	for k := range q.Tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	for i := len(tagKeys) - 1; i >= 0; i-- {
+		tc, err := tagCondition(tagKeys[i], q.Tags[tagKeys[i]])
+		if err != nil {
+			return nil, err
+		} else if tc != nil {
+			cond = &influxql.BinaryExpr{Op: influxql.AND, LHS: tc, RHS: cond}
+		}
+	}
+
+	// Select each series on its own.
+	var (
+		field      influxql.Expr = &influxql.VarRef{Val: "value"}
+		dimensions               = []*influxql.Dimension{{Expr: &influxql.Wildcard{}}}
+		interval   time.Duration
+		fill       = influxql.NullFill
+	)
+	if q.Downsample != "" {
+		d, err := parseDownsample(q.Downsample)
+		if err != nil {
+			return nil, err
+		}
+		field, interval, fill = d.call, d.interval, d.fill
+		if interval > 0 {
+			dimensions = append([]*influxql.Dimension{timeDimension(interval)}, dimensions...)
+		}
+	}
+	if q.Rate {
+		name := "derivative"
+		if q.RateOptions.Counter {
+			name = "non_negative_derivative"
+		}
+		field = &influxql.Call{Name: name, Args: []influxql.Expr{field, &influxql.DurationLiteral{Val: time.Second}}}
+	}
+
+	_, raw := field.(*influxql.VarRef)
+	inner := &influxql.SelectStatement{
+		IsRawQuery: raw,
+		Fields:     []*influxql.Field{{Expr: field, Alias: "value"}},
+		Sources: []influxql.Source{&influxql.Measurement{
+			Name:            q.Metric,
+			Database:        db,
+			RetentionPolicy: rp,
+		}},
+		Condition:  cond,
+		Dimensions: dimensions,
+		Fill:       fill,
+		FillValue:  fillValue(fill),
+	}
+	if q.Aggregator == "none" {
+		return inner, nil
+	}
+
+	// Aggregate the series, grouped by the tags of the query.
+	call, err := aggregateCall(q.Aggregator)
+	if err != nil {
+		return nil, err
+	}
+	if interval == 0 && q.Downsample == "" {
+		interval = time.Second
+	}
+
+	outerFill := influxql.NoFill
+	if fill == influxql.NumberFill {
+		outerFill = fill
+	}
+
+	var outerDimensions []*influxql.Dimension
+	if interval > 0 {
+		outerDimensions = append(outerDimensions, timeDimension(interval))
+	}
+	for _, k := range tagKeys {
+		outerDimensions = append(outerDimensions, &influxql.Dimension{Expr: &influxql.VarRef{Val: k}})
+	}
+
+	return &influxql.SelectStatement{
+		Fields:     []*influxql.Field{{Expr: call, Alias: "value"}},
+		Sources:    []influxql.Source{&influxql.SubQuery{Statement: inner}},
+		Condition:  timeCond,
+		Dimensions: outerDimensions,
+		Fill:       outerFill,
+		FillValue:  fillValue(outerFill),
+	}, nil
+}
+
+// fillValue returns the value used with fill. The only number downsampling
+// fills with is zero.
+func fillValue(fill influxql.FillOption) interface{} {
+	if fill == influxql.NumberFill {
+		return int64(0)
+	}
+	return nil
+}
+
+// timeDimension returns a GROUP BY time(interval) dimension.
+func timeDimension(interval time.Duration) *influxql.Dimension {
+	return &influxql.Dimension{Expr: &influxql.Call{
+		Name: "time",
+		Args: []influxql.Expr{&influxql.DurationLiteral{Val: interval}},
+	}}
+}
+
+// tagCondition returns the condition matching an OpenTSDB tag filter. A value
+// of "*" matches any value and returns nil. Values may list alternatives
+// separated by "|", and use "*" as a wildcard.
+func tagCondition(key, value string) (influxql.Expr, error) {
+	if value == "*" {
+		return nil, nil
+	} else if !strings.ContainsAny(value, "|*") {
+		return &influxql.BinaryExpr{
+			Op:  influxql.EQ,
+			LHS: &influxql.VarRef{Val: key},
+			RHS: &influxql.StringLiteral{Val: value},
+		}, nil
+	}
+
+	alternatives := strings.Split(value, "|")
+	for i, alt := range alternatives {
+		alternatives[i] = strings.Replace(regexp.QuoteMeta(alt), `\*`, `.*`, -1)
+	}
+	re, err := regexp.Compile("^(" + strings.Join(alternatives, "|") + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return &influxql.BinaryExpr{
+		Op:  influxql.EQREGEX,
+		LHS: &influxql.VarRef{Val: key},
+		RHS: &influxql.RegexLiteral{Val: re},
+	}, nil
+}
+
+// rowToResult converts a row returned for q into an OpenTSDB query result.
+// Timestamps are in seconds, or milliseconds if ms is set.
+func rowToResult(q *subQuery, row *models.Row, ms bool) *queryResult {
+	result := &queryResult{
+		Metric:        q.Metric,
+		Tags:          make(map[string]string, len(row.Tags)),
+		AggregateTags: []string{},
+		DPS:           make(map[string]float64, len(row.Values)),
+	}
+	for k, v := range row.Tags {
+		if v != "" {
+			result.Tags[k] = v
+		}
+	}
+
+	for _, values := range row.Values {
+		if len(values) < 2 {
+			continue
+		}
+		t, ok := values[0].(time.Time)
+		if !ok {
+			continue
+		}
+
+		var v float64
+		switch value := values[1].(type) {
+		case float64:
+			v = value
+		case int64:
+			v = float64(value)
+		default:
+			continue
+		}
+
+		ts := t.Unix()
+		if ms {
+			ts = t.UnixNano() / int64(time.Millisecond)
+		}
+		result.DPS[strconv.FormatInt(ts, 10)] = v
+	}
+	return result
+}
@@ -0,0 +1,188 @@
+package opentsdb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxql"
+	"go.uber.org/zap"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	for _, test := range []struct {
+		s   string
+		exp time.Time
+		err bool
+	}{
+		{s: "", exp: now},
+		{s: "now", exp: now},
+		{s: "1h-ago", exp: now.Add(-time.Hour)},
+		{s: "2d-ago", exp: now.Add(-48 * time.Hour)},
+		{s: "500ms-ago", exp: now.Add(-500 * time.Millisecond)},
+		{s: "1346846400", exp: time.Unix(1346846400, 0)},
+		{s: "1346846400123", exp: time.Unix(1346846400, 123000000)},
+		{s: "2012/09/05-12:00:00", exp: time.Date(2012, 9, 5, 12, 0, 0, 0, time.UTC)},
+		{s: "2012/09/05 12:00", exp: time.Date(2012, 9, 5, 12, 0, 0, 0, time.UTC)},
+		{s: "2012/09/05", exp: time.Date(2012, 9, 5, 0, 0, 0, 0, time.UTC)},
+		{s: "1x-ago", err: true},
+		{s: "yesterday", err: true},
+	} {
+		got, err := parseTime(test.s, now)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error", test.s)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.s, err)
+		} else if !got.Equal(test.exp) {
+			t.Errorf("%q: got %s, expected %s", test.s, got, test.exp)
+		}
+	}
+}
+
+func TestParseMetricQuery(t *testing.T) {
+	for _, test := range []struct {
+		s   string
+		exp subQuery
+		err bool
+	}{
+		{s: "sum:sys.cpu", exp: subQuery{Aggregator: "sum", Metric: "sys.cpu"}},
+		{
+			s:   "avg:1m-max:rate{counter}:sys.cpu{host=web01|web02,dc=*}",
+			exp: subQuery{Aggregator: "avg", Metric: "sys.cpu", Downsample: "1m-max", Rate: true, RateOptions: rateOptions{Counter: true}, Tags: map[string]string{"host": "web01|web02", "dc": "*"}},
+		},
+		{s: "max:rate:sys.cpu", exp: subQuery{Aggregator: "max", Metric: "sys.cpu", Rate: true}},
+		{s: "sys.cpu", err: true},
+		{s: "sum:", err: true},
+		{s: "sum:sys.cpu{host}", err: true},
+		{s: "sum:sys.cpu{host=a", err: true},
+	} {
+		got, err := parseMetricQuery(test.s)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error", test.s)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.s, err)
+		} else if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("%q: got %+v, expected %+v", test.s, got, test.exp)
+		}
+	}
+}
+
+func TestSubQuery_Statement(t *testing.T) {
+	start, end := time.Unix(1346846400, 0), time.Unix(1346850000, 0)
+	const timeRange = `time >= '2012-09-05T12:00:00Z' AND time <= '2012-09-05T13:00:00Z'`
+
+	for _, test := range []struct {
+		q   subQuery
+		exp string
+		err bool
+	}{
+		{
+			q:   subQuery{Aggregator: "sum", Metric: "sys.cpu"},
+			exp: `SELECT sum(value) AS value FROM (SELECT value AS value FROM db0.autogen."sys.cpu" WHERE ` + timeRange + ` GROUP BY *) WHERE ` + timeRange + ` GROUP BY time(1s) fill(none)`,
+		},
+		{
+			q:   subQuery{Aggregator: "none", Metric: "sys.cpu", Tags: map[string]string{"host": "web01"}},
+			exp: `SELECT value AS value FROM db0.autogen."sys.cpu" WHERE host = 'web01' AND ` + timeRange + ` GROUP BY *`,
+		},
+		{
+			q:   subQuery{Aggregator: "avg", Metric: "sys.cpu", Downsample: "1m-max", Rate: true, RateOptions: rateOptions{Counter: true}, Tags: map[string]string{"host": "web*|db01", "dc": "*"}},
+			exp: `SELECT mean(value) AS value FROM (SELECT non_negative_derivative(max(value), 1s) AS value FROM db0.autogen."sys.cpu" WHERE host =~ /^(web.*|db01)$/ AND ` + timeRange + ` GROUP BY time(1m), * fill(none)) WHERE ` + timeRange + ` GROUP BY time(1m), dc, host fill(none)`,
+		},
+		{
+			q:   subQuery{Aggregator: "p99", Metric: "sys.cpu", Downsample: "0all-count-zero"},
+			exp: `SELECT percentile(value, 99.0) AS value FROM (SELECT count(value) AS value FROM db0.autogen."sys.cpu" WHERE ` + timeRange + ` GROUP BY * fill(0)) WHERE ` + timeRange + ` fill(0)`,
+		},
+		{q: subQuery{Aggregator: "mult", Metric: "sys.cpu"}, err: true},
+		{q: subQuery{Aggregator: "sum", Metric: "sys.cpu", Downsample: "1m"}, err: true},
+		{q: subQuery{Aggregator: "sum", Metric: "sys.cpu", Downsample: "1m-avg-linear"}, err: true},
+	} {
+		stmt, err := test.q.statement("db0", "autogen", start, end)
+		if test.err {
+			if err == nil {
+				t.Errorf("%+v: expected error", test.q)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%+v: unexpected error: %s", test.q, err)
+			continue
+		}
+
+		if got, exp := stmt.String(), influxql.MustParseStatement(test.exp).String(); got != exp {
+			t.Errorf("%+v:\ngot      %s\nexpected %s", test.q, got, exp)
+		}
+	}
+}
+
+func TestHandler_Query(t *testing.T) {
+	var executed *influxql.Query
+	h := &Handler{
+		Database: "db0",
+		QueryExecutor: QueryExecutorFunc(func(q *influxql.Query, opt query.ExecutionOptions, closing chan struct{}) <-chan *query.Result {
+			executed = q
+			ch := make(chan *query.Result, 1)
+			ch <- &query.Result{
+				StatementID: 0,
+				Series: models.Rows{{
+					Name:    "sys.cpu",
+					Tags:    map[string]string{"host": "web01"},
+					Columns: []string{"time", "value"},
+					Values: [][]interface{}{
+						{time.Unix(1346846400, 0), 18.0},
+						{time.Unix(1346846401, 0), int64(20)},
+					},
+				}},
+			}
+			close(ch)
+			return ch
+		}),
+		Logger: zap.NewNop(),
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/api/query", strings.NewReader(`{"start":1346846400,"end":"2012/09/05-13:00:00","queries":[{"aggregator":"sum","metric":"sys.cpu","tags":{"host":"*"}}]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d: %s", w.Code, w.Body.String())
+	} else if executed == nil || len(executed.Statements) != 1 {
+		t.Fatalf("unexpected query: %v", executed)
+	}
+
+	var results []queryResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	} else if exp := []queryResult{{
+		Metric:        "sys.cpu",
+		Tags:          map[string]string{"host": "web01"},
+		AggregateTags: []string{},
+		DPS:           map[string]float64{"1346846400": 18, "1346846401": 20},
+	}}; !reflect.DeepEqual(results, exp) {
+		t.Fatalf("got %+v, expected %+v", results, exp)
+	}
+
+	// Queries are rejected without a query executor.
+	h.QueryExecutor = nil
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/query?start=1h-ago&m=sum:sys.cpu", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	}
+}
+
+// QueryExecutorFunc is a function that implements the Handler's QueryExecutor.
+type QueryExecutorFunc func(q *influxql.Query, opt query.ExecutionOptions, closing chan struct{}) <-chan *query.Result
+
+func (fn QueryExecutorFunc) ExecuteQuery(q *influxql.Query, opt query.ExecutionOptions, closing chan struct{}) <-chan *query.Result {
+	return fn(q, opt, closing)
+}
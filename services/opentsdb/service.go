@@ -5,6 +5,7 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/tls"
+	"errors"
 	"io"
 	"net"
 	"net/http"
@@ -17,8 +18,10 @@ import (
 
 	"github.com/influxdata/influxdb/logger"
 	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/query"
 	"github.com/influxdata/influxdb/services/meta"
 	"github.com/influxdata/influxdb/tsdb"
+	"github.com/influxdata/influxql"
 	"go.uber.org/zap"
 )
 
@@ -40,6 +43,7 @@ const (
 	statConnectionsActive        = "connsActive"
 	statConnectionsHandled       = "connsHandled"
 	statDroppedPointsInvalid     = "droppedPointsInvalid"
+	statPointsDownsampled        = "pointsDownsampled"
 )
 
 // Service manages the listener and handler for an HTTP endpoint.
@@ -65,6 +69,12 @@ type Service struct {
 	MetaClient interface {
 		CreateDatabase(name string) (*meta.DatabaseInfo, error)
 	}
+	QueryExecutor interface {
+		ExecuteQuery(query *influxql.Query, opt query.ExecutionOptions, closing chan struct{}) <-chan *query.Result
+	}
+
+	// Queries are only served over HTTP if enabled.
+	queryEnabled bool
 
 	// Points received over the telnet protocol are batched.
 	batchSize    int
@@ -72,6 +82,14 @@ type Service struct {
 	batchTimeout time.Duration
 	batcher      *tsdb.PointBatcher
 
+	// Unit of telnet timestamps without the "ms" extension.
+	precision string
+
+	// Points of the metrics matching a downsample rule are downsampled
+	// before they are batched.
+	downsampleRules []DownsampleRule
+	downsampler     *ingestDownsampler
+
 	LogPointErrors bool
 	Logger         *zap.Logger
 
@@ -95,6 +113,9 @@ func NewService(c Config) (*Service, error) {
 		batchTimeout:    time.Duration(d.BatchTimeout),
 		Logger:          zap.NewNop(),
 		LogPointErrors:  d.LogPointErrors,
+		queryEnabled:    d.QueryEnabled,
+		precision:       d.Precision,
+		downsampleRules: d.DownsampleRules,
 		stats:           &Statistics{},
 		defaultTags:     models.StatisticTags{"bind": d.BindAddress},
 	}
@@ -109,6 +130,16 @@ func (s *Service) Open() error {
 	if s.done != nil {
 		return nil // Already open.
 	}
+
+	s.downsampler = nil
+	if len(s.downsampleRules) > 0 {
+		d, err := newIngestDownsampler(s.downsampleRules)
+		if err != nil {
+			return err
+		}
+		s.downsampler = d
+	}
+
 	s.done = make(chan struct{})
 
 	s.Logger.Info("Starting OpenTSDB service")
@@ -120,6 +151,11 @@ func (s *Service) Open() error {
 	s.wg.Add(1)
 	go func() { defer s.wg.Done(); s.processBatches(s.batcher) }()
 
+	if s.downsampler != nil {
+		s.wg.Add(1)
+		go func() { defer s.wg.Done(); s.downsample() }()
+	}
+
 	// Open listener.
 	if s.tls {
 		cert, err := tls.LoadX509KeyPair(s.cert, s.cert)
@@ -253,6 +289,7 @@ type Statistics struct {
 	ActiveConnections        int64
 	HandledConnections       int64
 	InvalidDroppedPoints     int64
+	PointsDownsampled        int64
 }
 
 // Statistics returns statistics for periodic monitoring.
@@ -277,6 +314,7 @@ func (s *Service) Statistics(tags map[string]string) []models.Statistic {
 			statConnectionsActive:        atomic.LoadInt64(&s.stats.ActiveConnections),
 			statConnectionsHandled:       atomic.LoadInt64(&s.stats.HandledConnections),
 			statDroppedPointsInvalid:     atomic.LoadInt64(&s.stats.InvalidDroppedPoints),
+			statPointsDownsampled:        atomic.LoadInt64(&s.stats.PointsDownsampled),
 		},
 	}}
 }
@@ -383,24 +421,11 @@ func (s *Service) handleTelnetConn(conn net.Conn) {
 		valueStr := inputStrs[3]
 		tagStrs := inputStrs[4:]
 
-		var t time.Time
-		ts, err := strconv.ParseInt(tsStr, 10, 64)
+		t, err := parseTimestamp(tsStr, s.precision)
 		if err != nil {
 			atomic.AddInt64(&s.stats.TelnetBadTime, 1)
 			if s.LogPointErrors {
-				s.Logger.Info("Malformed time", zap.String("time", tsStr), zap.String("remote_addr", remoteAddr))
-			}
-		}
-
-		switch len(tsStr) {
-		case 10:
-			t = time.Unix(ts, 0)
-		case 13:
-			t = time.Unix(ts/1000, (ts%1000)*1000)
-		default:
-			atomic.AddInt64(&s.stats.TelnetBadTime, 1)
-			if s.LogPointErrors {
-				s.Logger.Info("Time must be 10 or 13 chars", zap.String("time", tsStr), zap.String("remote_addr", remoteAddr))
+				s.Logger.Info("Malformed time", zap.String("time", tsStr), zap.String("remote_addr", remoteAddr), zap.Error(err))
 			}
 			continue
 		}
@@ -439,10 +464,45 @@ func (s *Service) handleTelnetConn(conn net.Conn) {
 			}
 			continue
 		}
+		if s.downsampler != nil && s.downsampler.add(pt, time.Now()) {
+			atomic.AddInt64(&s.stats.PointsDownsampled, 1)
+			continue
+		}
 		s.batcher.In() <- pt
 	}
 }
 
+// parseTimestamp parses the timestamp of a telnet put command. A timestamp
+// ending in "ms" is in milliseconds. Otherwise, its unit is given by
+// precision, and automatically detected from its length of 10 digits for
+// seconds or 13 digits for milliseconds.
+func parseTimestamp(s, precision string) (time.Time, error) {
+	if strings.HasSuffix(s, "ms") {
+		s, precision = strings.TrimSuffix(s, "ms"), PrecisionMilliseconds
+	}
+
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if precision == PrecisionAuto {
+		switch len(s) {
+		case 10:
+			precision = PrecisionSeconds
+		case 13:
+			precision = PrecisionMilliseconds
+		default:
+			return time.Time{}, errors.New("time must be 10 or 13 chars")
+		}
+	}
+
+	if precision == PrecisionMilliseconds {
+		return time.Unix(0, ts*int64(time.Millisecond)), nil
+	}
+	return time.Unix(ts, 0), nil
+}
+
 // serveHTTP handles connections in HTTP format.
 func (s *Service) serveHTTP() {
 	handler := &Handler{
@@ -451,11 +511,38 @@ func (s *Service) serveHTTP() {
 		PointsWriter:    s.PointsWriter,
 		Logger:          s.Logger,
 		stats:           s.stats,
+		downsampler:     s.downsampler,
+	}
+	if s.queryEnabled {
+		handler.QueryExecutor = s.QueryExecutor
 	}
 	srv := &http.Server{Handler: handler}
 	srv.Serve(s.httpln)
 }
 
+// downsample periodically batches the points emitted by the downsampler
+// until the service is closed. Points still being downsampled when the
+// service is closed are discarded.
+func (s *Service) downsample() {
+	ticker := time.NewTicker(s.downsampler.tick())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, p := range s.downsampler.flush(now) {
+				select {
+				case s.batcher.In() <- p:
+				case <-s.done:
+					return
+				}
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
 // processBatches continually drains the given batcher and writes the batches to the database.
 func (s *Service) processBatches(batcher *tsdb.PointBatcher) {
 	for {
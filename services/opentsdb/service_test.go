@@ -1,6 +1,8 @@
 package opentsdb
 
 import (
+	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"net"
@@ -198,6 +200,38 @@ func TestService_Telnet(t *testing.T) {
 	}
 }
 
+// Ensure telnet timestamps are read in the configured precision.
+func TestParseTimestamp(t *testing.T) {
+	for _, test := range []struct {
+		s         string
+		precision string
+		exp       time.Time
+		err       bool
+	}{
+		{s: "1356998400", precision: PrecisionAuto, exp: time.Unix(1356998400, 0)},
+		{s: "1356998400123", precision: PrecisionAuto, exp: time.Unix(1356998400, 123000000)},
+		{s: "1356998400123ms", precision: PrecisionAuto, exp: time.Unix(1356998400, 123000000)},
+		{s: "1356998400ms", precision: PrecisionSeconds, exp: time.Unix(1356998, 400000000)},
+		{s: "13569984", precision: PrecisionSeconds, exp: time.Unix(13569984, 0)},
+		{s: "13569984", precision: PrecisionMilliseconds, exp: time.Unix(13569, 984000000)},
+		{s: "13569984", precision: PrecisionAuto, err: true},
+		{s: "1356998400s", precision: PrecisionAuto, err: true},
+		{s: "ms", precision: PrecisionAuto, err: true},
+	} {
+		got, err := parseTimestamp(test.s, test.precision)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s (%s): expected error", test.s, test.precision)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s (%s): unexpected error: %s", test.s, test.precision, err)
+		} else if !got.Equal(test.exp) {
+			t.Errorf("%s (%s): got %s, expected %s", test.s, test.precision, got, test.exp)
+		}
+	}
+}
+
 // Ensure a point can be written via the HTTP protocol.
 func TestService_HTTP(t *testing.T) {
 	t.Parallel()
@@ -248,6 +282,64 @@ func TestService_HTTP(t *testing.T) {
 	}
 }
 
+// Ensure gzip compressed points can be written via the HTTP protocol.
+func TestService_HTTP_Gzip(t *testing.T) {
+	t.Parallel()
+
+	s := NewTestService("db0", "127.0.0.1:0")
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	var written int64
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		atomic.AddInt64(&written, int64(len(points)))
+		return nil
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write([]byte(`[{"metric":"sys.cpu.nice", "timestamp":1346846400, "value":18, "tags":{"host":"web01"}}]`)); err != nil {
+		t.Fatal(err)
+	} else if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		encoding string
+		body     []byte
+		status   int
+	}{
+		{encoding: "gzip", body: compressed.Bytes(), status: http.StatusNoContent},
+		{encoding: "GZIP", body: compressed.Bytes(), status: http.StatusNoContent},
+		{encoding: "x-gzip", body: compressed.Bytes(), status: http.StatusNoContent},
+		{encoding: "gzip", body: []byte(`{"metric":"sys.cpu.nice"}`), status: http.StatusBadRequest},
+		{encoding: "br", body: compressed.Bytes(), status: http.StatusUnsupportedMediaType},
+	} {
+		req, err := http.NewRequest("POST", "http://"+s.Service.Addr().String()+"/api/put", bytes.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", test.encoding)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.status {
+			t.Fatalf("%s: unexpected status code: %d, expected %d", test.encoding, resp.StatusCode, test.status)
+		}
+	}
+
+	if got, exp := atomic.LoadInt64(&written), int64(3); got != exp {
+		t.Fatalf("got %d points written, expected %d", got, exp)
+	}
+}
+
 type TestService struct {
 	Service       *Service
 	MetaClient    *internal.MetaClientMock
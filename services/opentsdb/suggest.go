@@ -0,0 +1,164 @@
+package opentsdb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxql"
+)
+
+// DefaultSuggestMax is the default number of suggestions returned by
+// /api/suggest.
+const DefaultSuggestMax = 25
+
+// DefaultLookupLimit is the default number of series returned by
+// /api/search/lookup.
+const DefaultLookupLimit = 25
+
+// suggestRequest represents an OpenTSDB /api/suggest request.
+type suggestRequest struct {
+	Type string `json:"type"`
+	Q    string `json:"q,omitempty"`
+	Max  int    `json:"max,omitempty"`
+}
+
+// statement returns the SHOW statement listing the candidates for the
+// suggestions of r.
+func (r *suggestRequest) statement(db string) (influxql.Statement, error) {
+	switch r.Type {
+	case "metrics":
+		// Measurements are sorted, so only the first suggestions are needed.
+		return &influxql.ShowMeasurementsStatement{
+			Database: db,
+			Source: &influxql.Measurement{
+				Regex: &influxql.RegexLiteral{Val: regexp.MustCompile("^" + regexp.QuoteMeta(r.Q))},
+			},
+			Limit: r.Max,
+		}, nil
+	case "tagk":
+		return &influxql.ShowTagKeysStatement{Database: db}, nil
+	case "tagv":
+		return &influxql.ShowTagValuesStatement{
+			Database:   db,
+			Op:         influxql.EQREGEX,
+			TagKeyExpr: &influxql.RegexLiteral{Val: regexp.MustCompile(`.*`)},
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid suggest type: %q", r.Type)
+	}
+}
+
+// suggestions returns the sorted, distinct values of rows, returned by the
+// statement of r, that start with the prefix of r, up to the maximum of r.
+// Tag keys and values are returned per measurement, so the same value may
+// appear in several rows.
+func (r *suggestRequest) suggestions(rows models.Rows) []string {
+	// The value is the last column of every SHOW statement used.
+	seen := make(map[string]struct{})
+	for _, row := range rows {
+		for _, values := range row.Values {
+			if len(values) == 0 {
+				continue
+			}
+			s, ok := values[len(values)-1].(string)
+			if !ok || !strings.HasPrefix(s, r.Q) {
+				continue
+			}
+			seen[s] = struct{}{}
+		}
+	}
+
+	suggestions := make([]string, 0, len(seen))
+	for s := range seen {
+		suggestions = append(suggestions, s)
+	}
+	sort.Strings(suggestions)
+	if r.Max > 0 && len(suggestions) > r.Max {
+		suggestions = suggestions[:r.Max]
+	}
+	return suggestions
+}
+
+// lookupResponse represents an OpenTSDB /api/search/lookup response.
+type lookupResponse struct {
+	Type         string             `json:"type"`
+	Metric       string             `json:"metric"`
+	Tags         []lookupTag        `json:"tags"`
+	Limit        int                `json:"limit"`
+	Results      []lookupResultItem `json:"results"`
+	StartIndex   int                `json:"startIndex"`
+	TotalResults int                `json:"totalResults"`
+}
+
+// lookupTag is a tag filter of a lookup.
+type lookupTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// lookupResultItem is a series found by a lookup. InfluxDB has no UIDs, so
+// the tsuid is always empty.
+type lookupResultItem struct {
+	TSUID  string            `json:"tsuid"`
+	Metric string            `json:"metric"`
+	Tags   map[string]string `json:"tags"`
+}
+
+// lookupStatement returns the SHOW SERIES statement finding the series of q,
+// as parsed by parseMetricQuery without an aggregator. Tag values are
+// matched as in queries. An empty or "*" metric matches every measurement.
+func lookupStatement(db string, q subQuery, limit int) (*influxql.ShowSeriesStatement, error) {
+	stmt := &influxql.ShowSeriesStatement{Database: db, Limit: limit}
+	if q.Metric != "" && q.Metric != "*" {
+		stmt.Sources = influxql.Sources{&influxql.Measurement{Name: q.Metric}}
+	}
+
+	keys := make([]string, 0, len(q.Tags))
+	for k := range q.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cond, err := tagCondition(k, q.Tags[k])
+		if err != nil {
+			return nil, err
+		} else if cond == nil {
+			cond = &influxql.BinaryExpr{
+				Op:  influxql.NEQ,
+				LHS: &influxql.VarRef{Val: k},
+				RHS: &influxql.StringLiteral{Val: ""},
+			}
+		}
+
+		if stmt.Condition == nil {
+			stmt.Condition = cond
+		} else {
+			stmt.Condition = &influxql.BinaryExpr{Op: influxql.AND, LHS: stmt.Condition, RHS: cond}
+		}
+	}
+	return stmt, nil
+}
+
+// lookupResults converts the series keys returned by a SHOW SERIES statement
+// into lookup results.
+func lookupResults(rows models.Rows) []lookupResultItem {
+	results := []lookupResultItem{}
+	for _, row := range rows {
+		for _, values := range row.Values {
+			if len(values) == 0 {
+				continue
+			}
+			key, ok := values[0].(string)
+			if !ok {
+				continue
+			}
+
+			name, tags := models.ParseKey([]byte(key))
+			results = append(results, lookupResultItem{Metric: name, Tags: tags.Map()})
+		}
+	}
+	return results
+}
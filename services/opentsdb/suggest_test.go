@@ -0,0 +1,150 @@
+package opentsdb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/query"
+	"github.com/influxdata/influxql"
+	"go.uber.org/zap"
+)
+
+func TestSuggestRequest_Statement(t *testing.T) {
+	for _, test := range []struct {
+		req suggestRequest
+		exp string
+	}{
+		{req: suggestRequest{Type: "metrics", Q: "sys.c", Max: 10}, exp: `SHOW MEASUREMENTS ON db0 WITH MEASUREMENT =~ /^sys\.c/ LIMIT 10`},
+		{req: suggestRequest{Type: "tagk", Q: "ho"}, exp: `SHOW TAG KEYS ON db0`},
+		{req: suggestRequest{Type: "tagv", Q: "web"}, exp: `SHOW TAG VALUES ON db0 WITH KEY =~ /.*/`},
+	} {
+		stmt, err := test.req.statement("db0")
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %s", test.req, err)
+		} else if got, exp := stmt.String(), influxql.MustParseStatement(test.exp).String(); got != exp {
+			t.Errorf("%+v:\ngot      %s\nexpected %s", test.req, got, exp)
+		}
+	}
+
+	req := suggestRequest{Type: "uids"}
+	if _, err := req.statement("db0"); err == nil {
+		t.Fatal("expected error for invalid type")
+	}
+}
+
+func TestSuggestRequest_Suggestions(t *testing.T) {
+	rows := models.Rows{
+		{Name: "cpu", Columns: []string{"key", "value"}, Values: [][]interface{}{{"host", "web02"}, {"host", "web01"}, {"dc", "lga"}}},
+		{Name: "mem", Columns: []string{"key", "value"}, Values: [][]interface{}{{"host", "web01"}, {"host", "db01"}, {"host", "web03"}}},
+	}
+
+	req := suggestRequest{Type: "tagv", Q: "web", Max: 2}
+	if got, exp := req.suggestions(rows), []string{"web01", "web02"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
+
+func TestLookupStatement(t *testing.T) {
+	for _, test := range []struct {
+		q   subQuery
+		exp string
+	}{
+		{q: subQuery{Metric: "sys.cpu"}, exp: `SHOW SERIES ON db0 FROM "sys.cpu" LIMIT 25`},
+		{q: subQuery{Metric: "*", Tags: map[string]string{"host": "web01"}}, exp: `SHOW SERIES ON db0 WHERE host = 'web01' LIMIT 25`},
+		{
+			q:   subQuery{Metric: "sys.cpu", Tags: map[string]string{"host": "web*", "dc": "*"}},
+			exp: `SHOW SERIES ON db0 FROM "sys.cpu" WHERE dc != '' AND host =~ /^(web.*)$/ LIMIT 25`,
+		},
+	} {
+		stmt, err := lookupStatement("db0", test.q, 25)
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %s", test.q, err)
+		} else if got, exp := stmt.String(), influxql.MustParseStatement(test.exp).String(); got != exp {
+			t.Errorf("%+v:\ngot      %s\nexpected %s", test.q, got, exp)
+		}
+	}
+}
+
+func TestHandler_Suggest(t *testing.T) {
+	h := &Handler{
+		Database: "db0",
+		QueryExecutor: QueryExecutorFunc(func(q *influxql.Query, opt query.ExecutionOptions, closing chan struct{}) <-chan *query.Result {
+			ch := make(chan *query.Result, 1)
+			ch <- &query.Result{
+				Series: models.Rows{{
+					Name:    "measurements",
+					Columns: []string{"name"},
+					Values:  [][]interface{}{{"sys.cpu.nice"}, {"sys.cpu.user"}},
+				}},
+			}
+			close(ch)
+			return ch
+		}),
+		Logger: zap.NewNop(),
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/suggest?type=metrics&q=sys.cpu", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d: %s", w.Code, w.Body.String())
+	}
+
+	var suggestions []string
+	if err := json.Unmarshal(w.Body.Bytes(), &suggestions); err != nil {
+		t.Fatal(err)
+	} else if exp := []string{"sys.cpu.nice", "sys.cpu.user"}; !reflect.DeepEqual(suggestions, exp) {
+		t.Fatalf("got %v, expected %v", suggestions, exp)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/api/suggest", strings.NewReader(`{"type":"uids"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	}
+}
+
+func TestHandler_Lookup(t *testing.T) {
+	h := &Handler{
+		Database: "db0",
+		QueryExecutor: QueryExecutorFunc(func(q *influxql.Query, opt query.ExecutionOptions, closing chan struct{}) <-chan *query.Result {
+			ch := make(chan *query.Result, 1)
+			ch <- &query.Result{
+				Series: models.Rows{{
+					Columns: []string{"key"},
+					Values:  [][]interface{}{{"sys.cpu,host=web01"}, {"sys.cpu,dc=lga,host=web02"}},
+				}},
+			}
+			close(ch)
+			return ch
+		}),
+		Logger: zap.NewNop(),
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/search/lookup?m=sys.cpu{host=*}", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp lookupResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	} else if exp := (lookupResponse{
+		Type:   "LOOKUP",
+		Metric: "sys.cpu",
+		Tags:   []lookupTag{{Key: "host", Value: "*"}},
+		Limit:  DefaultLookupLimit,
+		Results: []lookupResultItem{
+			{Metric: "sys.cpu", Tags: map[string]string{"host": "web01"}},
+			{Metric: "sys.cpu", Tags: map[string]string{"dc": "lga", "host": "web02"}},
+		},
+		TotalResults: 2,
+	}); !reflect.DeepEqual(resp, exp) {
+		t.Fatalf("got %+v, expected %+v", resp, exp)
+	}
+}
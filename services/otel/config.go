@@ -0,0 +1,100 @@
+package otel
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/influxdata/influxdb/monitor/diagnostics"
+	"github.com/influxdata/influxdb/toml"
+)
+
+const (
+	// DefaultEndpoint is the default OTLP/HTTP endpoint of the collector
+	// receiving the traces.
+	DefaultEndpoint = "http://localhost:4318/v1/traces"
+
+	// DefaultServiceName is the default name of the service the traces are
+	// reported for.
+	DefaultServiceName = "influxdb"
+
+	// DefaultSampleRatio is the default ratio of requests traced, when the
+	// client didn't decide it with a traceparent header.
+	DefaultSampleRatio = 1.0
+
+	// DefaultBatchSize is the default number of spans exported per request
+	// to the collector.
+	DefaultBatchSize = 512
+
+	// DefaultFlushInterval is the default maximum time spans are buffered
+	// before being exported.
+	DefaultFlushInterval = 5 * time.Second
+
+	// DefaultTimeout is the default timeout of requests to the collector.
+	DefaultTimeout = 10 * time.Second
+)
+
+// Config represents the configuration of the trace exporter.
+type Config struct {
+	Enabled     bool    `toml:"enabled"`
+	Endpoint    string  `toml:"endpoint"`
+	ServiceName string  `toml:"service-name"`
+	SampleRatio float64 `toml:"sample-ratio"`
+
+	BatchSize     int           `toml:"batch-size"`
+	FlushInterval toml.Duration `toml:"flush-interval"`
+	Timeout       toml.Duration `toml:"timeout"`
+}
+
+// NewConfig returns a new Config with default settings.
+func NewConfig() Config {
+	return Config{
+		Endpoint:      DefaultEndpoint,
+		ServiceName:   DefaultServiceName,
+		SampleRatio:   DefaultSampleRatio,
+		BatchSize:     DefaultBatchSize,
+		FlushInterval: toml.Duration(DefaultFlushInterval),
+		Timeout:       toml.Duration(DefaultTimeout),
+	}
+}
+
+// Validate returns an error if the config is invalid.
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if u, err := url.Parse(c.Endpoint); err != nil {
+		return fmt.Errorf("invalid endpoint: %s", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("endpoint must be an http or https URL")
+	} else if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return errors.New("sample-ratio must be between 0 and 1")
+	} else if c.BatchSize <= 0 {
+		return errors.New("batch-size must be positive")
+	} else if c.FlushInterval <= 0 {
+		return errors.New("flush-interval must be positive")
+	} else if c.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+// Diagnostics returns a diagnostics representation of a subset of the Config.
+func (c Config) Diagnostics() (*diagnostics.Diagnostics, error) {
+	if !c.Enabled {
+		return diagnostics.RowFromMap(map[string]interface{}{
+			"enabled": false,
+		}), nil
+	}
+
+	return diagnostics.RowFromMap(map[string]interface{}{
+		"enabled":        true,
+		"endpoint":       c.Endpoint,
+		"service-name":   c.ServiceName,
+		"sample-ratio":   c.SampleRatio,
+		"batch-size":     c.BatchSize,
+		"flush-interval": c.FlushInterval,
+		"timeout":        c.Timeout,
+	}), nil
+}
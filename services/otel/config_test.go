@@ -0,0 +1,65 @@
+package otel_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdata/influxdb/services/otel"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	c := otel.NewConfig()
+	if _, err := toml.Decode(`
+enabled = true
+endpoint = "https://tempo:4318/v1/traces"
+service-name = "influxdb-eu"
+sample-ratio = 0.25
+batch-size = 100
+flush-interval = "1s"
+timeout = "3s"
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled: %v", c.Enabled)
+	} else if c.Endpoint != "https://tempo:4318/v1/traces" {
+		t.Fatalf("unexpected endpoint: %s", c.Endpoint)
+	} else if c.ServiceName != "influxdb-eu" {
+		t.Fatalf("unexpected service name: %s", c.ServiceName)
+	} else if c.SampleRatio != 0.25 {
+		t.Fatalf("unexpected sample ratio: %v", c.SampleRatio)
+	} else if c.BatchSize != 100 {
+		t.Fatalf("unexpected batch size: %d", c.BatchSize)
+	} else if time.Duration(c.FlushInterval) != time.Second {
+		t.Fatalf("unexpected flush interval: %s", c.FlushInterval)
+	} else if time.Duration(c.Timeout) != 3*time.Second {
+		t.Fatalf("unexpected timeout: %s", c.Timeout)
+	} else if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	// Disabled configs aren't validated.
+	if err := (otel.Config{}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fn := range []func(c *otel.Config){
+		func(c *otel.Config) { c.Endpoint = "localhost:4318" },
+		func(c *otel.Config) { c.SampleRatio = 1.5 },
+		func(c *otel.Config) { c.BatchSize = 0 },
+		func(c *otel.Config) { c.FlushInterval = 0 },
+	} {
+		c := otel.NewConfig()
+		c.Enabled = true
+		fn(&c)
+		if err := c.Validate(); err == nil {
+			t.Fatalf("expected error: %+v", c)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Package otel traces HTTP requests and exports their spans to an
+// OpenTelemetry collector, such as Jaeger or Tempo, with the OTLP/HTTP
+// protocol encoded as JSON.
+package otel // import "github.com/influxdata/influxdb/services/otel"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/pkg/tracing"
+	"github.com/influxdata/influxdb/pkg/tracing/fields"
+	"go.uber.org/zap"
+)
+
+// maxPendingBatches is the number of batches of spans buffered while the
+// collector is slow or unavailable. Newer spans are dropped beyond it.
+const maxPendingBatches = 8
+
+// Kinds of spans of OTLP.
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+)
+
+// Service traces requests and exports their spans in batches.
+type Service struct {
+	wg sync.WaitGroup
+
+	mu      sync.Mutex
+	done    chan struct{} // Is the service closing or closed?
+	flushCh chan struct{}
+	pending []span
+	dropped int
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+
+	config Config
+	client *http.Client
+
+	// Version is the version of the server reported with the spans.
+	Version string
+
+	Logger *zap.Logger
+}
+
+// NewService returns a new instance of Service.
+func NewService(c Config) *Service {
+	return &Service{
+		config:  c,
+		client:  &http.Client{Timeout: time.Duration(c.Timeout)},
+		flushCh: make(chan struct{}, 1),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		Logger:  zap.NewNop(),
+	}
+}
+
+// WithLogger sets the logger for the service.
+func (s *Service) WithLogger(log *zap.Logger) {
+	s.Logger = log.With(zap.String("service", "otel"))
+}
+
+// Open starts exporting spans.
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		return nil // Already open.
+	}
+
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+	s.done = make(chan struct{})
+
+	s.Logger.Info("Exporting traces", zap.String("endpoint", s.config.Endpoint))
+
+	s.wg.Add(1)
+	go s.run(s.done)
+	return nil
+}
+
+// Close exports the pending spans and stops the service.
+func (s *Service) Close() error {
+	s.mu.Lock()
+	if s.done == nil {
+		s.mu.Unlock()
+		return nil // Already closed.
+	}
+	close(s.done)
+	s.done = nil
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// Request is a traced request.
+type Request struct {
+	s    *Service
+	high uint64
+	tr   *tracing.Trace
+	span *tracing.Span
+}
+
+// Start starts tracing the request r with a span of name. The trace of the
+// traceparent header of r is continued, if set. Otherwise, a new trace is
+// started for a ratio of requests. Start returns nil if r isn't traced.
+func (s *Service) Start(r *http.Request, name string) *Request {
+	s.mu.Lock()
+	open := s.done != nil
+	s.mu.Unlock()
+	if !open {
+		return nil
+	}
+
+	req := &Request{s: s}
+	if tp, err := ParseTraceParent(r.Header.Get("traceparent")); err == nil {
+		if !tp.Sampled {
+			return nil
+		}
+		req.high = tp.TraceIDHigh
+		req.tr, req.span = tracing.NewTraceFromSpan(name, tracing.SpanContext{TraceID: tp.TraceIDLow, SpanID: tp.SpanID})
+	} else {
+		s.randMu.Lock()
+		sampled := s.rand.Float64() < s.config.SampleRatio
+		req.high = uint64(s.rand.Int63())
+		s.randMu.Unlock()
+		if !sampled {
+			return nil
+		}
+		req.tr, req.span = tracing.NewTrace(name)
+	}
+	req.span.SetLabels("http.method", r.Method, "http.target", r.URL.Path)
+	return req
+}
+
+// Span returns the span of the request, or nil if r is nil.
+func (r *Request) Span() *tracing.Span {
+	if r == nil {
+		return nil
+	}
+	return r.span
+}
+
+// Finish finishes the span of the request and queues the spans of its trace
+// for export. Spans finished afterwards are not exported.
+func (r *Request) Finish() {
+	if r == nil {
+		return
+	}
+	r.span.Finish()
+
+	root := r.span.Context().SpanID
+	raws := r.tr.Spans()
+	spans := make([]span, 0, len(raws))
+	for _, raw := range raws {
+		kind := spanKindInternal
+		if raw.Context.SpanID == root {
+			kind = spanKindServer
+		}
+		spans = append(spans, newSpan(r.high, raw, kind))
+	}
+	r.s.enqueue(spans)
+}
+
+// enqueue buffers spans until the next flush.
+func (s *Service) enqueue(spans []span) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := maxPendingBatches*s.config.BatchSize - len(s.pending); len(spans) > n {
+		if n < 0 {
+			n = 0
+		}
+		s.dropped += len(spans) - n
+		spans = spans[:n]
+	}
+	s.pending = append(s.pending, spans...)
+
+	if len(s.pending) >= s.config.BatchSize {
+		select {
+		case s.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Service) run(done <-chan struct{}) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(s.config.FlushInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			s.flush()
+			return
+		case <-ticker.C:
+		case <-s.flushCh:
+		}
+		s.flush()
+	}
+}
+
+// flush exports the pending spans.
+func (s *Service) flush() {
+	s.mu.Lock()
+	pending, dropped := s.pending, s.dropped
+	s.pending, s.dropped = nil, 0
+	s.mu.Unlock()
+
+	if dropped > 0 {
+		s.Logger.Info("Dropped spans while the collector was slow", zap.Int("spans", dropped))
+	}
+
+	for len(pending) > 0 {
+		n := s.config.BatchSize
+		if n > len(pending) {
+			n = len(pending)
+		}
+		if err := s.export(pending[:n]); err != nil {
+			s.Logger.Info("Unable to export spans", zap.Int("spans", n), zap.Error(err))
+		}
+		pending = pending[n:]
+	}
+}
+
+// export sends spans to the collector.
+func (s *Service) export(spans []span) error {
+	attrs := []attribute{stringAttribute("service.name", s.config.ServiceName)}
+	if s.Version != "" {
+		attrs = append(attrs, stringAttribute("service.version", s.Version))
+	}
+	b, err := json.Marshal(&exportRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource: resource{Attributes: attrs},
+			ScopeSpans: []scopeSpans{{
+				Scope: scope{Name: "github.com/influxdata/influxdb", Version: s.Version},
+				Spans: spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.config.Endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// exportRequest is the ExportTraceServiceRequest message of OTLP, in the
+// JSON encoding of protocol buffers. IDs are hex encoded, as required by
+// OTLP, rather than base64.
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type span struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes,omitempty"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    string   `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func stringAttribute(key, value string) attribute {
+	return attribute{Key: key, Value: attributeValue{StringValue: &value}}
+}
+
+// newSpan returns the OTLP span of raw, in the trace with the high half of
+// its ID high.
+func newSpan(high uint64, raw tracing.RawSpan, kind int) span {
+	sp := span{
+		TraceID:           fmt.Sprintf("%016x%016x", high, raw.Context.TraceID),
+		SpanID:            fmt.Sprintf("%016x", raw.Context.SpanID),
+		Name:              raw.Name,
+		Kind:              kind,
+		StartTimeUnixNano: strconv.FormatInt(raw.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(raw.Start.Add(raw.Duration).UnixNano(), 10),
+	}
+	if raw.ParentSpanID != 0 {
+		sp.ParentSpanID = fmt.Sprintf("%016x", raw.ParentSpanID)
+	}
+
+	for _, l := range raw.Labels {
+		sp.Attributes = append(sp.Attributes, stringAttribute(l.Key, l.Value))
+	}
+	for _, f := range raw.Fields {
+		sp.Attributes = append(sp.Attributes, fieldAttribute(f))
+	}
+	return sp
+}
+
+// fieldAttribute returns the attribute of a field of a span. Durations are
+// in nanoseconds.
+func fieldAttribute(f fields.Field) attribute {
+	a := attribute{Key: f.Key()}
+	switch v := f.Value().(type) {
+	case string:
+		a.Value.StringValue = &v
+	case bool:
+		a.Value.BoolValue = &v
+	case int64:
+		a.Value.IntValue = strconv.FormatInt(v, 10)
+	case uint64:
+		a.Value.IntValue = strconv.FormatInt(int64(v), 10)
+	case time.Duration:
+		a.Value.IntValue = strconv.FormatInt(int64(v), 10)
+	case float64:
+		a.Value.DoubleValue = &v
+	}
+	return a
+}
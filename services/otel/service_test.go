@@ -0,0 +1,229 @@
+package otel_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/pkg/tracing/fields"
+	"github.com/influxdata/influxdb/services/otel"
+	"github.com/influxdata/influxdb/toml"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	s := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tp, err := otel.ParseTraceParent(s)
+	if err != nil {
+		t.Fatal(err)
+	} else if tp.TraceIDHigh != 0x4bf92f3577b34da6 || tp.TraceIDLow != 0xa3ce929d0e0e4736 {
+		t.Fatalf("unexpected trace id: %x%x", tp.TraceIDHigh, tp.TraceIDLow)
+	} else if tp.SpanID != 0x00f067aa0ba902b7 || !tp.Sampled {
+		t.Fatalf("unexpected traceparent: %+v", tp)
+	} else if tp.String() != s {
+		t.Fatalf("unexpected string: %s", tp.String())
+	}
+
+	// Future versions may add fields.
+	if tp, err := otel.ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-ab"); err != nil {
+		t.Fatal(err)
+	} else if tp.Sampled {
+		t.Fatal("unexpected sampled")
+	}
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-ab",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		if _, err := otel.ParseTraceParent(s); err != otel.ErrInvalidTraceParent {
+			t.Fatalf("unexpected error for %q: %v", s, err)
+		}
+	}
+}
+
+// Ensure the spans of traced requests are exported to the collector.
+func TestService_Export(t *testing.T) {
+	c := NewCollector()
+	defer c.Close()
+
+	s := NewService(c.URL)
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/write?db=db0", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req := s.Start(r, "write")
+	if req == nil {
+		t.Fatal("expected traced request")
+	}
+	span := req.Span().StartSpan("write_points")
+	span.SetLabels("db", "db0")
+	span.SetFields(fields.New(fields.Int64("points", 3), fields.Duration("wait", time.Millisecond)))
+	span.Finish()
+	req.Finish()
+
+	// Requests of unsampled traces aren't traced.
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if req := s.Start(r, "write"); req != nil {
+		t.Fatal("unexpected traced request")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := c.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("unexpected number of spans: %d", len(spans))
+	}
+	root, child := spans[0], spans[1]
+	if root.Name != "write" || root.Kind != 2 || root.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || root.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected root span: %+v", root)
+	} else if child.Name != "write_points" || child.Kind != 1 || child.TraceID != root.TraceID || child.ParentSpanID != root.SpanID {
+		t.Fatalf("unexpected child span: %+v", child)
+	} else if child.EndTimeUnixNano < child.StartTimeUnixNano || root.EndTimeUnixNano < child.EndTimeUnixNano {
+		t.Fatalf("unexpected times: %+v, %+v", root, child)
+	}
+
+	attrs := make(map[string]map[string]interface{})
+	for _, a := range child.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if attrs["db"]["stringValue"] != "db0" || attrs["points"]["intValue"] != "3" || attrs["wait"]["intValue"] != "1000000" {
+		t.Fatalf("unexpected attributes: %v", attrs)
+	}
+	if c.Resource() != `[{"key":"service.name","value":{"stringValue":"influxdb"}}]` {
+		t.Fatalf("unexpected resource: %s", c.Resource())
+	}
+}
+
+// Ensure new traces are started for a ratio of requests.
+func TestService_SampleRatio(t *testing.T) {
+	c := NewCollector()
+	defer c.Close()
+
+	s := NewService(c.URL)
+	s.Config.SampleRatio = 0
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	r := httptest.NewRequest("GET", "/query", nil)
+	if req := s.Start(r, "query"); req != nil {
+		t.Fatal("unexpected traced request")
+	}
+
+	// Clients decide whether their traces are sampled.
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if req := s.Start(r, "query"); req == nil {
+		t.Fatal("expected traced request")
+	}
+}
+
+// Service is a test wrapper for otel.Service.
+type Service struct {
+	*otel.Service
+	Config otel.Config
+}
+
+// NewService returns a new instance of Service exporting to endpoint.
+func NewService(endpoint string) *Service {
+	c := otel.NewConfig()
+	c.Enabled = true
+	c.Endpoint = endpoint
+	c.FlushInterval = toml.Duration(time.Hour)
+	return &Service{Config: c}
+}
+
+// Open creates the service of the config and opens it.
+func (s *Service) Open() error {
+	s.Service = otel.NewService(s.Config)
+	return s.Service.Open()
+}
+
+// Collector is a test OTLP/HTTP collector.
+type Collector struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	resource json.RawMessage
+	spans    []CollectorSpan
+}
+
+// CollectorSpan is a span received by a collector.
+type CollectorSpan struct {
+	TraceID           string `json:"traceId"`
+	SpanID            string `json:"spanId"`
+	ParentSpanID      string `json:"parentSpanId"`
+	Name              string `json:"name"`
+	Kind              int    `json:"kind"`
+	StartTimeUnixNano string `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string `json:"endTimeUnixNano"`
+	Attributes        []struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"attributes"`
+}
+
+// NewCollector returns a new, running, instance of Collector.
+func NewCollector() *Collector {
+	c := &Collector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serveTraces))
+	return c
+}
+
+func (c *Collector) serveTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes json.RawMessage `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []CollectorSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		c.resource = rs.Resource.Attributes
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+// Spans returns the spans received by the collector.
+func (c *Collector) Spans() []CollectorSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spans
+}
+
+// Resource returns the attributes of the resource of the last spans received.
+func (c *Collector) Resource() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return string(c.resource)
+}
@@ -0,0 +1,67 @@
+package otel
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidTraceParent is returned when a traceparent header is malformed.
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceParent identifies the span of a client in a trace, as propagated by
+// the traceparent header of W3C Trace Context.
+type TraceParent struct {
+	// TraceID is the 128-bit identifier of the trace, as its high and low
+	// halves. The low half is the trace ID of pkg/tracing.
+	TraceIDHigh uint64
+	TraceIDLow  uint64
+
+	SpanID  uint64
+	Sampled bool
+}
+
+// ParseTraceParent parses the value of a traceparent header.
+func ParseTraceParent(s string) (TraceParent, error) {
+	// Newer versions may append fields, but must keep the first four.
+	a := strings.Split(strings.TrimSpace(s), "-")
+	if len(a) < 4 || len(a[0]) != 2 || len(a[1]) != 32 || len(a[2]) != 16 || len(a[3]) != 2 {
+		return TraceParent{}, ErrInvalidTraceParent
+	} else if a[0] == "ff" || (a[0] == "00" && len(a) != 4) {
+		return TraceParent{}, ErrInvalidTraceParent
+	}
+
+	var b [1 + 16 + 8 + 1]byte
+	for i, v := range []struct {
+		s string
+		b []byte
+	}{{a[0], b[:1]}, {a[1], b[1:17]}, {a[2], b[17:25]}, {a[3], b[25:]}} {
+		if strings.ToLower(v.s) != v.s {
+			return TraceParent{}, ErrInvalidTraceParent
+		} else if _, err := hex.Decode(v.b, []byte(a[i])); err != nil {
+			return TraceParent{}, ErrInvalidTraceParent
+		}
+	}
+
+	tp := TraceParent{
+		TraceIDHigh: binary.BigEndian.Uint64(b[1:9]),
+		TraceIDLow:  binary.BigEndian.Uint64(b[9:17]),
+		SpanID:      binary.BigEndian.Uint64(b[17:25]),
+		Sampled:     b[25]&0x01 != 0,
+	}
+	if (tp.TraceIDHigh == 0 && tp.TraceIDLow == 0) || tp.SpanID == 0 {
+		return TraceParent{}, ErrInvalidTraceParent
+	}
+	return tp, nil
+}
+
+// String returns tp as the value of a traceparent header.
+func (tp TraceParent) String() string {
+	var flags byte
+	if tp.Sampled {
+		flags = 0x01
+	}
+	return fmt.Sprintf("00-%016x%016x-%016x-%02x", tp.TraceIDHigh, tp.TraceIDLow, tp.SpanID, flags)
+}
@@ -0,0 +1,202 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// maxGetOIDs is the largest number of variables requested at once.
+const maxGetOIDs = 32
+
+// udpBufferSize is the size of the largest UDP datagram.
+const udpBufferSize = 65536
+
+// client requests the variables of an agent. It is not safe for concurrent
+// use.
+type client struct {
+	conn      net.Conn
+	version   int
+	community string
+	timeout   time.Duration
+	retries   int
+
+	// maxRepetitions is the number of variables requested per GetBulk
+	// request when walking with SNMPv2c.
+	maxRepetitions int
+
+	requestID int32
+	buf       []byte
+}
+
+// dialClient returns a client of the agent at addr.
+func dialClient(addr string, c *Config) (*client, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	cl := &client{
+		conn:           conn,
+		version:        version2c,
+		community:      c.Community,
+		timeout:        time.Duration(c.Timeout),
+		retries:        c.Retries,
+		maxRepetitions: c.MaxRepetitions,
+		buf:            make([]byte, udpBufferSize),
+	}
+	if c.Version == "1" {
+		cl.version = version1
+	}
+	return cl, nil
+}
+
+// Close closes the connection of the client.
+func (c *client) Close() error {
+	return c.conn.Close()
+}
+
+// request sends a request and returns its response, retrying when no
+// response is received within the timeout.
+func (c *client) request(typ byte, errorStatus, errorIndex int, oids []oid) (*pdu, error) {
+	c.requestID++
+	if c.requestID <= 0 {
+		c.requestID = 1
+	}
+	req := message{
+		version:   c.version,
+		community: c.community,
+		pdu: pdu{
+			typ:         typ,
+			requestID:   c.requestID,
+			errorStatus: errorStatus,
+			errorIndex:  errorIndex,
+		},
+	}
+	for _, o := range oids {
+		req.pdu.varbinds = append(req.pdu.varbinds, varbind{oid: o})
+	}
+	b := req.marshal()
+
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if _, err := c.conn.Write(b); err != nil {
+			return nil, err
+		}
+		resp, err := c.receive(req.pdu.requestID, time.Now().Add(c.timeout))
+		if err, ok := err.(net.Error); ok && err.Timeout() {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("request timed out after %d attempts", c.retries+1)
+}
+
+// receive reads datagrams until the response to requestID or the deadline.
+// Responses to other requests, such as those of timed out attempts, and
+// invalid datagrams are ignored.
+func (c *client) receive(requestID int32, deadline time.Time) (*pdu, error) {
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	for {
+		n, err := c.conn.Read(c.buf)
+		if err != nil {
+			return nil, err
+		}
+		m, err := unmarshalMessage(c.buf[:n])
+		if err != nil || m.pdu.typ != pduResponse || m.pdu.requestID != requestID {
+			continue
+		}
+		// The values are copied as the buffer is reused.
+		for i := range m.pdu.varbinds {
+			if b := m.pdu.varbinds[i].value.bytes; b != nil {
+				m.pdu.varbinds[i].value.bytes = append([]byte(nil), b...)
+			}
+		}
+		return &m.pdu, nil
+	}
+}
+
+// get returns the values of oids. Variables that do not exist are left out.
+func (c *client) get(oids []oid) ([]varbind, error) {
+	var result []varbind
+	for len(oids) > 0 {
+		n := len(oids)
+		if n > maxGetOIDs {
+			n = maxGetOIDs
+		}
+		batch := oids[:n]
+		oids = oids[n:]
+
+		for len(batch) > 0 {
+			resp, err := c.request(pduGetRequest, 0, 0, batch)
+			if err != nil {
+				return nil, err
+			}
+
+			// SNMPv1 agents fail the whole request if any variable is
+			// missing, so the request is repeated without it.
+			if resp.errorStatus == errNoSuchName && c.version == version1 &&
+				resp.errorIndex >= 1 && resp.errorIndex <= len(batch) {
+				i := resp.errorIndex - 1
+				batch = append(batch[:i:i], batch[i+1:]...)
+				continue
+			} else if resp.errorStatus != 0 {
+				return nil, fmt.Errorf("agent returned error-status %d", resp.errorStatus)
+			} else if len(resp.varbinds) != len(batch) {
+				return nil, errors.New("agent returned an unexpected number of variables")
+			}
+
+			for _, vb := range resp.varbinds {
+				if vb.value.exists() {
+					result = append(result, vb)
+				}
+			}
+			break
+		}
+	}
+	return result, nil
+}
+
+// walk returns the variables of the subtree of root, in order. It uses
+// GetBulk requests with SNMPv2c, and GetNext requests with SNMPv1.
+func (c *client) walk(root oid) ([]varbind, error) {
+	var result []varbind
+	last := root
+	for {
+		var (
+			resp *pdu
+			err  error
+		)
+		if c.version == version1 {
+			resp, err = c.request(pduGetNextRequest, 0, 0, []oid{last})
+		} else {
+			resp, err = c.request(pduGetBulkRequest, 0, c.maxRepetitions, []oid{last})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.errorStatus == errNoSuchName && c.version == version1 {
+			return result, nil // End of the MIB view.
+		} else if resp.errorStatus != 0 {
+			return nil, fmt.Errorf("agent returned error-status %d", resp.errorStatus)
+		} else if len(resp.varbinds) == 0 {
+			return nil, errors.New("agent returned no variables")
+		}
+
+		for _, vb := range resp.varbinds {
+			if vb.value.typ == tagEndOfMibView || !vb.oid.hasPrefix(root) {
+				return result, nil
+			} else if vb.oid.compare(last) <= 0 {
+				return nil, fmt.Errorf("agent returned OID %s out of order", vb.oid)
+			}
+			last = vb.oid
+			if vb.value.exists() {
+				result = append(result, vb)
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package snmp
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/toml"
+)
+
+func TestClient_Get(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []string{"1", "2c"} {
+		agent := NewTestAgent(t, testVariables())
+		defer agent.Close()
+		cl := agent.Client(version)
+		defer cl.Close()
+
+		vbs, err := cl.get([]oid{
+			{1, 3, 6, 1, 2, 1, 1, 5, 0},
+			{1, 3, 6, 1, 2, 1, 1, 9, 0}, // Missing.
+			{1, 3, 6, 1, 2, 1, 1, 3, 0},
+		})
+		if err != nil {
+			t.Fatalf("version %s: %s", version, err)
+		}
+		exp := []varbind{
+			{oid: oid{1, 3, 6, 1, 2, 1, 1, 5, 0}, value: value{typ: tagOctetString, bytes: []byte("router1")}},
+			{oid: oid{1, 3, 6, 1, 2, 1, 1, 3, 0}, value: value{typ: tagTimeTicks, uint: 12345}},
+		}
+		if !reflect.DeepEqual(vbs, exp) {
+			t.Fatalf("version %s: unexpected variables:\n got %+v\n exp %+v", version, vbs, exp)
+		}
+	}
+}
+
+// Ensure requests of many variables are split.
+func TestClient_Get_Many(t *testing.T) {
+	t.Parallel()
+
+	vars := make(map[string]value)
+	var oids []oid
+	for i := 0; i < 2*maxGetOIDs+1; i++ {
+		o := oid{1, 3, 6, 1, 4, 1, 1, uint32(i)}
+		vars[o.String()] = value{typ: tagInteger, int: int64(i)}
+		oids = append(oids, o)
+	}
+	agent := NewTestAgent(t, vars)
+	defer agent.Close()
+	cl := agent.Client("2c")
+	defer cl.Close()
+
+	vbs, err := cl.get(oids)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(vbs) != len(oids) {
+		t.Fatalf("unexpected number of variables: %d", len(vbs))
+	}
+	for i, vb := range vbs {
+		if vb.value.int != int64(i) {
+			t.Fatalf("unexpected value of %s: %d", vb.oid, vb.value.int)
+		}
+	}
+	if n := agent.Requests(); n != 3 {
+		t.Fatalf("unexpected number of requests: %d", n)
+	}
+}
+
+func TestClient_Walk(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []string{"1", "2c"} {
+		agent := NewTestAgent(t, testVariables())
+		defer agent.Close()
+		cl := agent.Client(version)
+		cl.maxRepetitions = 2
+		defer cl.Close()
+
+		vbs, err := cl.walk(oid{1, 3, 6, 1, 2, 1, 2, 2, 1, 2})
+		if err != nil {
+			t.Fatalf("version %s: %s", version, err)
+		}
+		var got []string
+		for _, vb := range vbs {
+			got = append(got, vb.oid.String()+"="+string(vb.value.bytes))
+		}
+		exp := []string{
+			".1.3.6.1.2.1.2.2.1.2.1=lo",
+			".1.3.6.1.2.1.2.2.1.2.2=eth0",
+			".1.3.6.1.2.1.2.2.1.2.3=eth1",
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("version %s: unexpected variables: %v", version, got)
+		}
+
+		// Walking past the last variable ends the walk.
+		if vbs, err := cl.walk(oid{1, 3, 6, 1, 2, 1, 31}); err != nil {
+			t.Fatalf("version %s: %s", version, err)
+		} else if len(vbs) != 2 {
+			t.Fatalf("version %s: unexpected variables: %+v", version, vbs)
+		}
+	}
+}
+
+// Ensure requests are retried when no response is received.
+func TestClient_Retry(t *testing.T) {
+	t.Parallel()
+
+	agent := NewTestAgent(t, testVariables())
+	defer agent.Close()
+	agent.Drop(2)
+
+	cl := agent.Client("2c")
+	defer cl.Close()
+	cl.timeout = 50 * time.Millisecond
+	cl.retries = 2
+
+	if vbs, err := cl.get([]oid{{1, 3, 6, 1, 2, 1, 1, 5, 0}}); err != nil {
+		t.Fatal(err)
+	} else if len(vbs) != 1 {
+		t.Fatalf("unexpected variables: %+v", vbs)
+	}
+
+	agent.Drop(3)
+	if _, err := cl.get([]oid{{1, 3, 6, 1, 2, 1, 1, 5, 0}}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// testVariables returns the variables of a small router.
+func testVariables() map[string]value {
+	return map[string]value{
+		".1.3.6.1.2.1.1.3.0":            {typ: tagTimeTicks, uint: 12345},
+		".1.3.6.1.2.1.1.5.0":            {typ: tagOctetString, bytes: []byte("router1")},
+		".1.3.6.1.2.1.2.2.1.2.1":        {typ: tagOctetString, bytes: []byte("lo")},
+		".1.3.6.1.2.1.2.2.1.2.2":        {typ: tagOctetString, bytes: []byte("eth0")},
+		".1.3.6.1.2.1.2.2.1.2.3":        {typ: tagOctetString, bytes: []byte("eth1")},
+		".1.3.6.1.2.1.2.2.1.10.1":       {typ: tagCounter32, uint: 100},
+		".1.3.6.1.2.1.2.2.1.10.2":       {typ: tagCounter32, uint: 200},
+		".1.3.6.1.2.1.2.2.1.10.3":       {typ: tagCounter32, uint: 300},
+		".1.3.6.1.2.1.4.20.1.1.192.0.2": {typ: tagIPAddress, bytes: []byte{192, 0, 2, 1}},
+		".1.3.6.1.2.1.31.1.1.1.6.2":     {typ: tagCounter64, uint: 1 << 40},
+		".1.3.6.1.2.1.31.1.1.1.6.3":     {typ: tagCounter64, uint: 1 << 41},
+	}
+}
+
+// TestAgent is an SNMP agent serving a fixed set of variables over UDP.
+type TestAgent struct {
+	conn *net.UDPConn
+	vars map[string]value
+	oids []oid // Sorted.
+	wg   sync.WaitGroup
+
+	mu       sync.Mutex
+	drop     int
+	requests int
+}
+
+// NewTestAgent returns a running agent serving vars, keyed by OID.
+func NewTestAgent(t *testing.T, vars map[string]value) *TestAgent {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &TestAgent{conn: conn, vars: vars}
+	for s := range vars {
+		o, err := parseOID(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.oids = append(a.oids, o)
+	}
+	sort.Slice(a.oids, func(i, j int) bool { return a.oids[i].compare(a.oids[j]) < 0 })
+
+	a.wg.Add(1)
+	go a.serve()
+	return a
+}
+
+// Addr returns the address of the agent.
+func (a *TestAgent) Addr() string { return a.conn.LocalAddr().String() }
+
+// Client returns a client of the agent using version.
+func (a *TestAgent) Client(version string) *client {
+	c := NewConfig()
+	c.Version = version
+	c.Timeout = toml.Duration(time.Second)
+	cl, err := dialClient(a.Addr(), &c)
+	if err != nil {
+		panic(err)
+	}
+	return cl
+}
+
+// Drop makes the agent ignore the next n requests.
+func (a *TestAgent) Drop(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.drop = n
+}
+
+// Requests returns the number of requests answered.
+func (a *TestAgent) Requests() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.requests
+}
+
+// Close stops the agent.
+func (a *TestAgent) Close() {
+	a.conn.Close()
+	a.wg.Wait()
+}
+
+func (a *TestAgent) serve() {
+	defer a.wg.Done()
+	buf := make([]byte, udpBufferSize)
+	for {
+		n, addr, err := a.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		m, err := unmarshalMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		a.mu.Lock()
+		drop := a.drop > 0
+		if drop {
+			a.drop--
+		} else {
+			a.requests++
+		}
+		a.mu.Unlock()
+		if drop {
+			continue
+		}
+
+		m.pdu = a.respond(m.version, m.pdu)
+		a.conn.WriteToUDP(m.marshal(), addr)
+	}
+}
+
+// respond returns the response to a request.
+func (a *TestAgent) respond(version int, req pdu) pdu {
+	resp := pdu{typ: pduResponse, requestID: req.requestID}
+	for i, vb := range req.varbinds {
+		switch req.typ {
+		case pduGetRequest:
+			v, ok := a.vars[vb.oid.String()]
+			if !ok && version == version1 {
+				return pdu{typ: pduResponse, requestID: req.requestID, errorStatus: errNoSuchName, errorIndex: i + 1, varbinds: req.varbinds}
+			} else if !ok {
+				v = value{typ: tagNoSuchObject}
+			}
+			resp.varbinds = append(resp.varbinds, varbind{oid: vb.oid, value: v})
+
+		case pduGetNextRequest, pduGetBulkRequest:
+			n := 1
+			if req.typ == pduGetBulkRequest {
+				n = req.errorIndex
+			}
+			last := vb.oid
+			for j := 0; j < n; j++ {
+				k := sort.Search(len(a.oids), func(k int) bool { return a.oids[k].compare(last) > 0 })
+				if k == len(a.oids) {
+					if version == version1 {
+						return pdu{typ: pduResponse, requestID: req.requestID, errorStatus: errNoSuchName, errorIndex: i + 1, varbinds: req.varbinds}
+					}
+					resp.varbinds = append(resp.varbinds, varbind{oid: last, value: value{typ: tagEndOfMibView}})
+					break
+				}
+				last = a.oids[k]
+				resp.varbinds = append(resp.varbinds, varbind{oid: last, value: a.vars[last.String()]})
+			}
+		}
+	}
+	return resp
+}
@@ -0,0 +1,251 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/monitor/diagnostics"
+	"github.com/influxdata/influxdb/toml"
+)
+
+const (
+	// DefaultDatabase is the default database for polled values.
+	DefaultDatabase = "snmp"
+
+	// DefaultRetentionPolicy is the default retention policy used for writes.
+	DefaultRetentionPolicy = ""
+
+	// DefaultMeasurement is the default measurement of the fields that are
+	// not part of a table.
+	DefaultMeasurement = "snmp"
+
+	// DefaultVersion is the default SNMP version.
+	DefaultVersion = "2c"
+
+	// DefaultCommunity is the default community string.
+	DefaultCommunity = "public"
+
+	// DefaultPort is the port of agents whose address has none.
+	DefaultPort = "161"
+
+	// DefaultInterval is the default interval between polls of an agent.
+	DefaultInterval = 10 * time.Second
+
+	// DefaultTimeout is the default time to wait for the response to a
+	// request.
+	DefaultTimeout = 5 * time.Second
+
+	// DefaultRetries is the default number of times a request is retried.
+	DefaultRetries = 3
+
+	// DefaultMaxRepetitions is the default number of variables requested per
+	// GetBulk request.
+	DefaultMaxRepetitions = 10
+
+	// DefaultBatchSize is the default write batch size.
+	DefaultBatchSize = 5000
+
+	// DefaultBatchPending is the default number of pending write batches.
+	DefaultBatchPending = 10
+
+	// DefaultBatchTimeout is the default SNMP batch timeout.
+	DefaultBatchTimeout = time.Second
+)
+
+// Config represents the configuration of an SNMP poller.
+type Config struct {
+	Enabled         bool   `toml:"enabled"`
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+
+	// Agents are the addresses of the agents polled, as host:port. The port
+	// defaults to 161.
+	Agents []string `toml:"agents"`
+
+	// Version is the SNMP version, "1" or "2c", and Community the community
+	// string sent with requests.
+	Version   string `toml:"version"`
+	Community string `toml:"community"`
+
+	Interval       toml.Duration `toml:"interval"`
+	Timeout        toml.Duration `toml:"timeout"`
+	Retries        int           `toml:"retries"`
+	MaxRepetitions int           `toml:"max-repetitions"`
+
+	BatchSize    int           `toml:"batch-size"`
+	BatchPending int           `toml:"batch-pending"`
+	BatchTimeout toml.Duration `toml:"batch-timeout"`
+
+	// Measurement names the measurement of Fields. Tables are written to
+	// measurements of their own.
+	Measurement string  `toml:"measurement"`
+	Fields      []Field `toml:"field"`
+	Tables      []Table `toml:"table"`
+}
+
+// Field maps the variable OID to a field, or to a tag if IsTag is set.
+// Within a table, OID is the column of the table.
+type Field struct {
+	Name  string `toml:"name"`
+	OID   string `toml:"oid"`
+	IsTag bool   `toml:"is-tag"`
+}
+
+// Table is a table walked on every poll. A point is written to the
+// measurement Name for every row of the table, with a field or tag for each
+// of the columns mapped by Fields.
+type Table struct {
+	Name   string  `toml:"name"`
+	Fields []Field `toml:"field"`
+}
+
+// NewConfig returns a new instance of Config with defaults.
+func NewConfig() Config {
+	return Config{
+		Database:        DefaultDatabase,
+		RetentionPolicy: DefaultRetentionPolicy,
+		Version:         DefaultVersion,
+		Community:       DefaultCommunity,
+		Interval:        toml.Duration(DefaultInterval),
+		Timeout:         toml.Duration(DefaultTimeout),
+		Retries:         DefaultRetries,
+		MaxRepetitions:  DefaultMaxRepetitions,
+		BatchSize:       DefaultBatchSize,
+		BatchPending:    DefaultBatchPending,
+		BatchTimeout:    toml.Duration(DefaultBatchTimeout),
+		Measurement:     DefaultMeasurement,
+	}
+}
+
+// WithDefaults takes the given config and returns a new config with any required
+// default values set.
+func (c *Config) WithDefaults() *Config {
+	d := *c
+	if d.Database == "" {
+		d.Database = DefaultDatabase
+	}
+	if d.Version == "" {
+		d.Version = DefaultVersion
+	}
+	if d.Community == "" {
+		d.Community = DefaultCommunity
+	}
+	if d.Interval == 0 {
+		d.Interval = toml.Duration(DefaultInterval)
+	}
+	if d.Timeout == 0 {
+		d.Timeout = toml.Duration(DefaultTimeout)
+	}
+	if d.MaxRepetitions == 0 {
+		d.MaxRepetitions = DefaultMaxRepetitions
+	}
+	if d.BatchSize == 0 {
+		d.BatchSize = DefaultBatchSize
+	}
+	if d.BatchPending == 0 {
+		d.BatchPending = DefaultBatchPending
+	}
+	if d.BatchTimeout == 0 {
+		d.BatchTimeout = toml.Duration(DefaultBatchTimeout)
+	}
+	if d.Measurement == "" {
+		d.Measurement = DefaultMeasurement
+	}
+	d.Agents = make([]string, len(c.Agents))
+	for i, a := range c.Agents {
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			a = net.JoinHostPort(a, DefaultPort)
+		}
+		d.Agents[i] = a
+	}
+	return &d
+}
+
+// Validate returns an error if the config is invalid.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	switch c.Version {
+	case "", "1", "2c":
+	default:
+		return fmt.Errorf(`invalid version %q. Valid options are "1" and "2c"`, c.Version)
+	}
+
+	if len(c.Agents) == 0 {
+		return errors.New("at least one agent must be configured")
+	} else if len(c.Fields) == 0 && len(c.Tables) == 0 {
+		return errors.New("at least one field or table must be configured")
+	} else if c.Interval < 0 || c.Timeout < 0 {
+		return errors.New("interval and timeout must not be negative")
+	} else if c.Retries < 0 || c.MaxRepetitions < 0 {
+		return errors.New("retries and max-repetitions must not be negative")
+	} else if c.BatchSize < 0 || c.BatchPending < 0 {
+		return errors.New("batch-size and batch-pending must not be negative")
+	}
+
+	if err := validateFields(c.Fields); err != nil {
+		return err
+	}
+	for _, t := range c.Tables {
+		if t.Name == "" {
+			return errors.New("table name must be set")
+		} else if len(t.Fields) == 0 {
+			return fmt.Errorf("table %s: at least one field must be configured", t.Name)
+		} else if err := validateFields(t.Fields); err != nil {
+			return fmt.Errorf("table %s: %s", t.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateFields(fields []Field) error {
+	for _, f := range fields {
+		if f.Name == "" {
+			return fmt.Errorf("name of OID %q must be set", f.OID)
+		} else if _, err := parseOID(f.OID); err != nil {
+			return fmt.Errorf("field %s: %s", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// Configs wraps a slice of Config to aggregate diagnostics.
+type Configs []Config
+
+// Diagnostics returns one set of diagnostics for all of the Configs.
+func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
+	d := &diagnostics.Diagnostics{
+		Columns: []string{"enabled", "agents", "version", "database", "retention-policy", "interval", "fields", "tables"},
+	}
+
+	for _, cc := range c {
+		if !cc.Enabled {
+			d.AddRow([]interface{}{false})
+			continue
+		}
+
+		tables := make([]string, 0, len(cc.Tables))
+		for _, t := range cc.Tables {
+			tables = append(tables, t.Name)
+		}
+		r := []interface{}{true, strings.Join(cc.Agents, ","), cc.Version, cc.Database, cc.RetentionPolicy, cc.Interval, len(cc.Fields), strings.Join(tables, ",")}
+		d.AddRow(r)
+	}
+
+	return d, nil
+}
+
+// Enabled returns true if any underlying Config is Enabled.
+func (c Configs) Enabled() bool {
+	for _, cc := range c {
+		if cc.Enabled {
+			return true
+		}
+	}
+	return false
+}
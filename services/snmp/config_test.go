@@ -0,0 +1,106 @@
+package snmp_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdata/influxdb/services/snmp"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	var c snmp.Config
+	if _, err := toml.Decode(`
+enabled = true
+agents = ["192.0.2.1", "192.0.2.2:1161"]
+version = "1"
+community = "private"
+interval = "1m"
+measurement = "system"
+
+[[field]]
+  name = "uptime"
+  oid = ".1.3.6.1.2.1.1.3.0"
+
+[[field]]
+  name = "sysName"
+  oid = ".1.3.6.1.2.1.1.5.0"
+  is-tag = true
+
+[[table]]
+  name = "interface"
+
+  [[table.field]]
+    name = "ifDescr"
+    oid = ".1.3.6.1.2.1.2.2.1.2"
+    is-tag = true
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled: %v", c.Enabled)
+	} else if !reflect.DeepEqual(c.Agents, []string{"192.0.2.1", "192.0.2.2:1161"}) {
+		t.Fatalf("unexpected agents: %v", c.Agents)
+	} else if c.Version != "1" {
+		t.Fatalf("unexpected version: %s", c.Version)
+	} else if c.Community != "private" {
+		t.Fatalf("unexpected community: %s", c.Community)
+	} else if time.Duration(c.Interval) != time.Minute {
+		t.Fatalf("unexpected interval: %v", c.Interval)
+	} else if c.Measurement != "system" {
+		t.Fatalf("unexpected measurement: %s", c.Measurement)
+	} else if len(c.Fields) != 2 || c.Fields[0].Name != "uptime" || !c.Fields[1].IsTag {
+		t.Fatalf("unexpected fields: %+v", c.Fields)
+	} else if len(c.Tables) != 1 || c.Tables[0].Name != "interface" || len(c.Tables[0].Fields) != 1 {
+		t.Fatalf("unexpected tables: %+v", c.Tables)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Agents default to port 161.
+	if d := c.WithDefaults(); !reflect.DeepEqual(d.Agents, []string{"192.0.2.1:161", "192.0.2.2:1161"}) {
+		t.Fatalf("unexpected agents: %v", d.Agents)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, test := range []struct {
+		fn  func(c *snmp.Config)
+		err bool
+	}{
+		{fn: func(c *snmp.Config) {}},
+		{fn: func(c *snmp.Config) { c.Enabled = false; c.Agents = nil }},
+		{fn: func(c *snmp.Config) { c.Version = "1" }},
+		{fn: func(c *snmp.Config) { c.Version = "3" }, err: true},
+		{fn: func(c *snmp.Config) { c.Agents = nil }, err: true},
+		{fn: func(c *snmp.Config) { c.Fields = nil }, err: true},
+		{fn: func(c *snmp.Config) {
+			c.Fields = nil
+			c.Tables = []snmp.Table{{Name: "t", Fields: []snmp.Field{{Name: "f", OID: "1.3.6"}}}}
+		}},
+		{fn: func(c *snmp.Config) { c.Tables = []snmp.Table{{Name: "t"}} }, err: true},
+		{fn: func(c *snmp.Config) { c.Tables = []snmp.Table{{Fields: []snmp.Field{{Name: "f", OID: "1.3.6"}}}} }, err: true},
+		{fn: func(c *snmp.Config) { c.Fields[0].Name = "" }, err: true},
+		{fn: func(c *snmp.Config) { c.Fields[0].OID = "1.3.x" }, err: true},
+		{fn: func(c *snmp.Config) { c.Interval = -1 }, err: true},
+		{fn: func(c *snmp.Config) { c.Retries = -1 }, err: true},
+		{fn: func(c *snmp.Config) { c.BatchSize = -1 }, err: true},
+	} {
+		c := snmp.NewConfig()
+		c.Enabled = true
+		c.Agents = []string{"192.0.2.1"}
+		c.Fields = []snmp.Field{{Name: "uptime", OID: ".1.3.6.1.2.1.1.3.0"}}
+		test.fn(&c)
+		if err := c.Validate(); test.err && err == nil {
+			t.Errorf("%+v: expected error", c)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", c, err)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BER tags of the SNMP messages and values, as defined by RFC 1157 and RFC
+// 3416.
+const (
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagNull        = 0x05
+	tagOID         = 0x06
+	tagSequence    = 0x30
+
+	tagIPAddress = 0x40
+	tagCounter32 = 0x41
+	tagGauge32   = 0x42
+	tagTimeTicks = 0x43
+	tagOpaque    = 0x44
+	tagCounter64 = 0x46
+
+	tagNoSuchObject   = 0x80
+	tagNoSuchInstance = 0x81
+	tagEndOfMibView   = 0x82
+
+	pduGetRequest     = 0xa0
+	pduGetNextRequest = 0xa1
+	pduResponse       = 0xa2
+	pduGetBulkRequest = 0xa5
+)
+
+// Protocol versions, as encoded in messages.
+const (
+	version1  = 0
+	version2c = 1
+)
+
+// errNoSuchName is the error-status of SNMPv1 responses to requests of
+// variables that do not exist.
+const errNoSuchName = 2
+
+// oid is an object identifier.
+type oid []uint32
+
+// parseOID parses an object identifier in dotted notation. A leading dot is
+// allowed.
+func parseOID(s string) (oid, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	o := make(oid, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+		o[i] = uint32(n)
+	}
+	if o[0] > 2 || (o[0] < 2 && o[1] >= 40) {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	return o, nil
+}
+
+// String returns o in dotted notation, with a leading dot.
+func (o oid) String() string {
+	var buf []byte
+	for _, n := range o {
+		buf = append(buf, '.')
+		buf = strconv.AppendUint(buf, uint64(n), 10)
+	}
+	return string(buf)
+}
+
+// hasPrefix returns true if o is within the subtree of prefix.
+func (o oid) hasPrefix(prefix oid) bool {
+	if len(o) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if o[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// compare returns -1, 0 or 1 if o sorts before, equal to or after other in
+// the lexicographic order of agents.
+func (o oid) compare(other oid) int {
+	for i := 0; i < len(o) && i < len(other); i++ {
+		if o[i] < other[i] {
+			return -1
+		} else if o[i] > other[i] {
+			return 1
+		}
+	}
+	switch {
+	case len(o) < len(other):
+		return -1
+	case len(o) > len(other):
+		return 1
+	}
+	return 0
+}
+
+// value is the value of a variable. Its type is the BER tag it is encoded
+// with.
+type value struct {
+	typ   byte
+	int   int64  // INTEGER
+	uint  uint64 // Counter32, Gauge32, TimeTicks and Counter64
+	bytes []byte // OCTET STRING, IpAddress and Opaque
+	oid   oid    // OBJECT IDENTIFIER
+}
+
+// exists returns false for the exceptions reported for missing variables.
+func (v value) exists() bool {
+	switch v.typ {
+	case tagNull, tagNoSuchObject, tagNoSuchInstance, tagEndOfMibView:
+		return false
+	}
+	return true
+}
+
+// varbind is a variable binding, the name of a variable and its value.
+type varbind struct {
+	oid   oid
+	value value
+}
+
+// pdu is a protocol data unit. For GetBulk requests, errorStatus and
+// errorIndex hold the non-repeaters and max-repetitions.
+type pdu struct {
+	typ         byte
+	requestID   int32
+	errorStatus int
+	errorIndex  int
+	varbinds    []varbind
+}
+
+// message is an SNMPv1 or SNMPv2c message.
+type message struct {
+	version   int
+	community string
+	pdu       pdu
+}
+
+// marshal returns the BER encoding of m. The values of the variable
+// bindings of requests are always NULL.
+func (m *message) marshal() []byte {
+	var vbs []byte
+	for _, vb := range m.pdu.varbinds {
+		vbs = append(vbs, tlv(tagSequence, append(tlv(tagOID, encodeOID(vb.oid)), encodeValue(vb.value)...))...)
+	}
+
+	var p []byte
+	p = append(p, tlv(tagInteger, encodeInt(int64(m.pdu.requestID)))...)
+	p = append(p, tlv(tagInteger, encodeInt(int64(m.pdu.errorStatus)))...)
+	p = append(p, tlv(tagInteger, encodeInt(int64(m.pdu.errorIndex)))...)
+	p = append(p, tlv(tagSequence, vbs)...)
+
+	var b []byte
+	b = append(b, tlv(tagInteger, encodeInt(int64(m.version)))...)
+	b = append(b, tlv(tagOctetString, []byte(m.community))...)
+	b = append(b, tlv(m.pdu.typ, p)...)
+	return tlv(tagSequence, b)
+}
+
+// tlv returns the encoding of a value with its tag and length.
+func tlv(tag byte, v []byte) []byte {
+	b := []byte{tag}
+	switch n := len(v); {
+	case n < 0x80:
+		b = append(b, byte(n))
+	default:
+		var l []byte
+		for ; n > 0; n >>= 8 {
+			l = append([]byte{byte(n)}, l...)
+		}
+		b = append(b, 0x80|byte(len(l)))
+		b = append(b, l...)
+	}
+	return append(b, v...)
+}
+
+// encodeInt returns the shortest two's complement encoding of i.
+func encodeInt(i int64) []byte {
+	b := []byte{byte(i)}
+	for (i > 0x7f || i < -0x80) && len(b) < 8 {
+		i >>= 8
+		b = append([]byte{byte(i)}, b...)
+	}
+	return b
+}
+
+// encodeUint returns the encoding of the unsigned integer u.
+func encodeUint(u uint64) []byte {
+	b := []byte{byte(u)}
+	for u >>= 8; u > 0; u >>= 8 {
+		b = append([]byte{byte(u)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+func encodeOID(o oid) []byte {
+	if len(o) < 2 {
+		return []byte{0}
+	}
+	b := encodeSubidentifier(nil, o[0]*40+o[1])
+	for _, n := range o[2:] {
+		b = encodeSubidentifier(b, n)
+	}
+	return b
+}
+
+func encodeSubidentifier(b []byte, n uint32) []byte {
+	var tmp [5]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(n & 0x7f)
+	for n >>= 7; n > 0; n >>= 7 {
+		i--
+		tmp[i] = byte(n&0x7f) | 0x80
+	}
+	return append(b, tmp[i:]...)
+}
+
+func encodeValue(v value) []byte {
+	switch v.typ {
+	case tagInteger:
+		return tlv(v.typ, encodeInt(v.int))
+	case tagCounter32, tagGauge32, tagTimeTicks, tagCounter64:
+		return tlv(v.typ, encodeUint(v.uint))
+	case tagOctetString, tagIPAddress, tagOpaque:
+		return tlv(v.typ, v.bytes)
+	case tagOID:
+		return tlv(v.typ, encodeOID(v.oid))
+	case 0:
+		return tlv(tagNull, nil)
+	default:
+		return tlv(v.typ, nil)
+	}
+}
+
+// errTruncated is returned when a message ends in the middle of a value.
+var errTruncated = errors.New("truncated message")
+
+// decoder reads the BER encoded values of a message.
+type decoder struct {
+	buf []byte
+}
+
+// next returns the tag and contents of the next value.
+func (d *decoder) next() (byte, []byte, error) {
+	if len(d.buf) < 2 {
+		return 0, nil, errTruncated
+	}
+	tag, n := d.buf[0], int(d.buf[1])
+	d.buf = d.buf[2:]
+	if n&0x80 != 0 {
+		l := n & 0x7f
+		if l == 0 || l > 4 || len(d.buf) < l {
+			return 0, nil, errors.New("invalid length")
+		}
+		n = 0
+		for _, c := range d.buf[:l] {
+			n = n<<8 | int(c)
+		}
+		d.buf = d.buf[l:]
+	}
+	if n < 0 || n > len(d.buf) {
+		return 0, nil, errTruncated
+	}
+	v := d.buf[:n]
+	d.buf = d.buf[n:]
+	return tag, v, nil
+}
+
+// expect returns the contents of the next value, which must have tag.
+func (d *decoder) expect(tag byte) ([]byte, error) {
+	t, v, err := d.next()
+	if err != nil {
+		return nil, err
+	} else if t != tag {
+		return nil, fmt.Errorf("unexpected tag 0x%02x, expected 0x%02x", t, tag)
+	}
+	return v, nil
+}
+
+// int returns the next INTEGER.
+func (d *decoder) int() (int64, error) {
+	v, err := d.expect(tagInteger)
+	if err != nil {
+		return 0, err
+	}
+	return decodeInt(v)
+}
+
+// unmarshalMessage decodes a message.
+func unmarshalMessage(buf []byte) (*message, error) {
+	d := &decoder{buf: buf}
+	b, err := d.expect(tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	d = &decoder{buf: b}
+
+	var m message
+	version, err := d.int()
+	if err != nil {
+		return nil, err
+	}
+	m.version = int(version)
+	community, err := d.expect(tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	m.community = string(community)
+
+	typ, b, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+	m.pdu.typ = typ
+	d = &decoder{buf: b}
+
+	var ints [3]int64
+	for i := range ints {
+		if ints[i], err = d.int(); err != nil {
+			return nil, err
+		}
+	}
+	m.pdu.requestID = int32(ints[0])
+	m.pdu.errorStatus, m.pdu.errorIndex = int(ints[1]), int(ints[2])
+
+	b, err = d.expect(tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	d = &decoder{buf: b}
+	for len(d.buf) > 0 {
+		b, err := d.expect(tagSequence)
+		if err != nil {
+			return nil, err
+		}
+		vd := &decoder{buf: b}
+
+		b, err = vd.expect(tagOID)
+		if err != nil {
+			return nil, err
+		}
+		o, err := decodeOID(b)
+		if err != nil {
+			return nil, err
+		}
+
+		typ, b, err := vd.next()
+		if err != nil {
+			return nil, err
+		}
+		v, err := decodeValue(typ, b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", o, err)
+		}
+		m.pdu.varbinds = append(m.pdu.varbinds, varbind{oid: o, value: v})
+	}
+	return &m, nil
+}
+
+func decodeInt(b []byte) (int64, error) {
+	if len(b) == 0 || len(b) > 8 {
+		return 0, errors.New("invalid integer")
+	}
+	i := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		i = i<<8 | int64(c)
+	}
+	return i, nil
+}
+
+func decodeUint(b []byte) (uint64, error) {
+	if len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	if len(b) > 8 {
+		return 0, errors.New("invalid unsigned integer")
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func decodeOID(b []byte) (oid, error) {
+	if len(b) == 0 {
+		return nil, errors.New("empty OID")
+	}
+	var o oid
+	var n uint64
+	for i, c := range b {
+		n = n<<7 | uint64(c&0x7f)
+		if n > 0xffffffff {
+			return nil, errors.New("invalid OID")
+		} else if c&0x80 != 0 {
+			if i == len(b)-1 {
+				return nil, errors.New("invalid OID")
+			}
+			continue
+		}
+		if len(o) == 0 {
+			switch {
+			case n < 40:
+				o = append(o, 0, uint32(n))
+			case n < 80:
+				o = append(o, 1, uint32(n-40))
+			default:
+				o = append(o, 2, uint32(n-80))
+			}
+		} else {
+			o = append(o, uint32(n))
+		}
+		n = 0
+	}
+	return o, nil
+}
+
+func decodeValue(typ byte, b []byte) (value, error) {
+	v := value{typ: typ}
+	var err error
+	switch typ {
+	case tagInteger:
+		v.int, err = decodeInt(b)
+	case tagCounter32, tagGauge32, tagTimeTicks:
+		v.uint, err = decodeUint(b)
+		if err == nil && v.uint > 0xffffffff {
+			err = errors.New("invalid 32-bit value")
+		}
+	case tagCounter64:
+		v.uint, err = decodeUint(b)
+	case tagOctetString, tagOpaque:
+		v.bytes = b
+	case tagIPAddress:
+		if len(b) != 4 {
+			err = errors.New("invalid IP address")
+		}
+		v.bytes = b
+	case tagOID:
+		v.oid, err = decodeOID(b)
+	case tagNull, tagNoSuchObject, tagNoSuchInstance, tagEndOfMibView:
+	default:
+		err = fmt.Errorf("unsupported value type 0x%02x", typ)
+	}
+	return v, err
+}
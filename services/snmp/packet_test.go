@@ -0,0 +1,135 @@
+package snmp
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOID(t *testing.T) {
+	for _, tt := range []struct {
+		s   string
+		oid oid
+	}{
+		{s: ".1.3.6.1.2.1.1.3.0", oid: oid{1, 3, 6, 1, 2, 1, 1, 3, 0}},
+		{s: "1.3.6", oid: oid{1, 3, 6}},
+		{s: "2.999.4294967295", oid: oid{2, 999, 4294967295}},
+	} {
+		o, err := parseOID(tt.s)
+		if err != nil {
+			t.Errorf("%s: %s", tt.s, err)
+		} else if !reflect.DeepEqual(o, tt.oid) {
+			t.Errorf("%s: got %v, exp %v", tt.s, o, tt.oid)
+		}
+	}
+
+	for _, s := range []string{"", ".", "1", "1..3", "1.3.x", "3.1", "1.40", "1.3.4294967296"} {
+		if _, err := parseOID(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestOID_Compare(t *testing.T) {
+	a, b, c := oid{1, 3, 6}, oid{1, 3, 6, 1}, oid{1, 3, 7}
+	if a.compare(b) != -1 || b.compare(a) != 1 || b.compare(c) != -1 || c.compare(c) != 0 {
+		t.Fatal("unexpected order")
+	}
+	if !b.hasPrefix(a) || c.hasPrefix(a) || a.hasPrefix(b) {
+		t.Fatal("unexpected prefixes")
+	}
+}
+
+// Ensure messages are decoded as they are encoded.
+func TestMessage_Marshal(t *testing.T) {
+	m := &message{
+		version:   version2c,
+		community: "public",
+		pdu: pdu{
+			typ:       pduResponse,
+			requestID: 2147483647,
+			varbinds: []varbind{
+				{oid: oid{1, 3, 6, 1, 2, 1, 1, 1, 0}, value: value{typ: tagOctetString, bytes: []byte(strings.Repeat("x", 300))}},
+				{oid: oid{1, 3, 6, 1, 2, 1, 1, 3, 0}, value: value{typ: tagTimeTicks, uint: 4294967295}},
+				{oid: oid{1, 3, 6, 1, 2, 1, 1, 7, 0}, value: value{typ: tagInteger, int: -129}},
+				{oid: oid{1, 3, 6, 1, 2, 1, 1, 8, 0}, value: value{typ: tagInteger, int: 128}},
+				{oid: oid{1, 3, 6, 1, 2, 1, 31, 1, 1, 1, 6, 1}, value: value{typ: tagCounter64, uint: 18446744073709551615}},
+				{oid: oid{1, 3, 6, 1, 2, 1, 4, 20, 1, 1}, value: value{typ: tagIPAddress, bytes: []byte{192, 0, 2, 1}}},
+				{oid: oid{1, 3, 6, 1, 2, 1, 1, 2, 0}, value: value{typ: tagOID, oid: oid{1, 3, 6, 1, 4, 1, 8072, 3, 2, 10}}},
+				{oid: oid{1, 3, 6, 1, 2, 1, 1, 9, 0}, value: value{typ: tagNoSuchInstance}},
+			},
+		},
+	}
+
+	other, err := unmarshalMessage(m.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(other, m) {
+		t.Fatalf("unexpected message:\n got %+v\n exp %+v", other, m)
+	}
+}
+
+// Ensure a request is encoded as in the examples of agents.
+func TestMessage_Marshal_Request(t *testing.T) {
+	m := &message{
+		version:   version1,
+		community: "public",
+		pdu: pdu{
+			typ:       pduGetRequest,
+			requestID: 1,
+			varbinds:  []varbind{{oid: oid{1, 3, 6, 1, 2, 1, 1, 5, 0}}},
+		},
+	}
+	exp := []byte{
+		0x30, 0x26,
+		0x02, 0x01, 0x00,
+		0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+		0xa0, 0x19,
+		0x02, 0x01, 0x01,
+		0x02, 0x01, 0x00,
+		0x02, 0x01, 0x00,
+		0x30, 0x0e,
+		0x30, 0x0c,
+		0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x05, 0x00,
+		0x05, 0x00,
+	}
+	if b := m.marshal(); !bytes.Equal(b, exp) {
+		t.Fatalf("unexpected encoding:\n got % x\n exp % x", b, exp)
+	}
+}
+
+// Ensure invalid messages are rejected rather than misread.
+func TestUnmarshalMessage_Invalid(t *testing.T) {
+	m := &message{
+		version:   version2c,
+		community: "public",
+		pdu: pdu{
+			typ:      pduResponse,
+			varbinds: []varbind{{oid: oid{1, 3, 6, 1}, value: value{typ: tagCounter32, uint: 1}}},
+		},
+	}
+	b := m.marshal()
+	for i := 0; i < len(b); i++ {
+		if _, err := unmarshalMessage(b[:i]); err == nil {
+			t.Errorf("%d bytes: expected error", i)
+		}
+	}
+
+	for _, tt := range []struct {
+		typ byte
+		b   []byte
+	}{
+		{typ: tagCounter32, b: []byte{1, 0, 0, 0, 0}},
+		{typ: tagIPAddress, b: []byte{127, 0, 1}},
+		{typ: tagOID, b: []byte{0x2b, 0x86}},
+		{typ: tagOID, b: []byte{0x2b, 0x90, 0x80, 0x80, 0x80, 0x00}},
+		{typ: tagInteger, b: nil},
+		{typ: 0x47, b: nil},
+	} {
+		if _, err := decodeValue(tt.typ, tt.b); err == nil {
+			t.Errorf("0x%02x % x: expected error", tt.typ, tt.b)
+		}
+	}
+}
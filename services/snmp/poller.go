@@ -0,0 +1,219 @@
+package snmp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// field is a Field with its parsed OID.
+type field struct {
+	name  string
+	oid   oid
+	isTag bool
+}
+
+// table is a Table with its parsed columns.
+type table struct {
+	name    string
+	columns []field
+}
+
+// poller maps the variables of an agent to points.
+type poller struct {
+	measurement string
+	fields      []field
+	tables      []table
+}
+
+// newPoller returns the poller of c, which must be valid.
+func newPoller(c *Config) (*poller, error) {
+	p := &poller{measurement: c.Measurement}
+	var err error
+	if p.fields, err = newFields(c.Fields); err != nil {
+		return nil, err
+	}
+	for _, t := range c.Tables {
+		columns, err := newFields(t.Fields)
+		if err != nil {
+			return nil, err
+		}
+		p.tables = append(p.tables, table{name: t.Name, columns: columns})
+	}
+	return p, nil
+}
+
+func newFields(fields []Field) ([]field, error) {
+	a := make([]field, 0, len(fields))
+	for _, f := range fields {
+		o, err := parseOID(f.OID)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, field{name: f.Name, oid: o, isTag: f.IsTag})
+	}
+	return a, nil
+}
+
+// poll requests the variables of the agent at addr and returns their
+// points, timestamped with now. Every point is tagged with the host of the
+// agent, and the tags of the fields that are not part of a table. The rows
+// of tables are also tagged with their index.
+func (p *poller) poll(cl *client, addr string, now time.Time) ([]models.Point, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	tags := map[string]string{"agent_host": host}
+
+	var points []models.Point
+	if len(p.fields) > 0 {
+		oids := make([]oid, len(p.fields))
+		for i, f := range p.fields {
+			oids[i] = f.oid
+		}
+		vbs, err := cl.get(oids)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]value, len(vbs))
+		for _, vb := range vbs {
+			values[vb.oid.String()] = vb.value
+		}
+
+		fields := make(models.Fields)
+		for _, f := range p.fields {
+			v, ok := values[f.oid.String()]
+			if !ok {
+				continue
+			} else if f.isTag {
+				if s := tagValue(v); s != "" {
+					tags[f.name] = s
+				}
+			} else {
+				fields[f.name] = fieldValue(v)
+			}
+		}
+		if len(fields) > 0 {
+			pt, err := models.NewPoint(p.measurement, models.NewTags(tags), fields, now)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, pt)
+		}
+	}
+
+	for _, t := range p.tables {
+		pts, err := t.points(cl, tags, now)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %s", t.name, err)
+		}
+		points = append(points, pts...)
+	}
+	return points, nil
+}
+
+// points walks the columns of the table and returns a point for every row
+// with at least one field.
+func (t *table) points(cl *client, tags map[string]string, now time.Time) ([]models.Point, error) {
+	type row struct {
+		tags   map[string]string
+		fields models.Fields
+	}
+	var (
+		rows  = make(map[string]*row)
+		order []string
+	)
+
+	for _, col := range t.columns {
+		vbs, err := cl.walk(col.oid)
+		if err != nil {
+			return nil, err
+		}
+		for _, vb := range vbs {
+			index := vb.oid[len(col.oid):].String()
+			if index == "" {
+				continue // Not a column.
+			}
+			index = index[1:]
+
+			r := rows[index]
+			if r == nil {
+				r = &row{tags: map[string]string{"index": index}, fields: make(models.Fields)}
+				for k, v := range tags {
+					r.tags[k] = v
+				}
+				rows[index] = r
+				order = append(order, index)
+			}
+			if col.isTag {
+				if s := tagValue(vb.value); s != "" {
+					r.tags[col.name] = s
+				}
+			} else {
+				r.fields[col.name] = fieldValue(vb.value)
+			}
+		}
+	}
+
+	var points []models.Point
+	for _, index := range order {
+		r := rows[index]
+		if len(r.fields) == 0 {
+			continue
+		}
+		pt, err := models.NewPoint(t.name, models.NewTags(r.tags), r.fields, now)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, pt)
+	}
+	return points, nil
+}
+
+// fieldValue returns the field value of v, which must exist. Counter64
+// values are unsigned integers, and the other numeric values integers.
+// Octet strings that are not valid UTF-8 are written in hexadecimal.
+func fieldValue(v value) interface{} {
+	switch v.typ {
+	case tagInteger:
+		return v.int
+	case tagCounter32, tagGauge32, tagTimeTicks:
+		return int64(v.uint)
+	case tagCounter64:
+		return v.uint
+	default:
+		return stringValue(v)
+	}
+}
+
+// tagValue returns the tag value of v, which must exist.
+func tagValue(v value) string {
+	switch v.typ {
+	case tagInteger:
+		return strconv.FormatInt(v.int, 10)
+	case tagCounter32, tagGauge32, tagTimeTicks, tagCounter64:
+		return strconv.FormatUint(v.uint, 10)
+	default:
+		return stringValue(v)
+	}
+}
+
+func stringValue(v value) string {
+	switch v.typ {
+	case tagIPAddress:
+		return net.IP(v.bytes).String()
+	case tagOID:
+		return v.oid.String()
+	case tagOctetString:
+		if utf8.Valid(v.bytes) {
+			return string(v.bytes)
+		}
+	}
+	return hex.EncodeToString(v.bytes)
+}
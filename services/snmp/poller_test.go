@@ -0,0 +1,82 @@
+package snmp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPoller_Poll(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []string{"1", "2c"} {
+		agent := NewTestAgent(t, testVariables())
+		defer agent.Close()
+		cl := agent.Client(version)
+		defer cl.Close()
+
+		c := NewConfig()
+		c.Fields = []Field{
+			{Name: "uptime", OID: ".1.3.6.1.2.1.1.3.0"},
+			{Name: "sysName", OID: ".1.3.6.1.2.1.1.5.0", IsTag: true},
+			{Name: "missing", OID: ".1.3.6.1.2.1.1.9.0"},
+		}
+		c.Tables = []Table{{
+			Name: "interface",
+			Fields: []Field{
+				{Name: "ifDescr", OID: ".1.3.6.1.2.1.2.2.1.2", IsTag: true},
+				{Name: "ifInOctets", OID: ".1.3.6.1.2.1.2.2.1.10"},
+				{Name: "ifHCInOctets", OID: ".1.3.6.1.2.1.31.1.1.1.6"},
+			},
+		}, {
+			Name:   "address",
+			Fields: []Field{{Name: "ipAdEntAddr", OID: ".1.3.6.1.2.1.4.20.1.1"}},
+		}}
+		p, err := newPoller(&c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		points, err := p.poll(cl, agent.Addr(), time.Unix(0, 1500000000000000000))
+		if err != nil {
+			t.Fatalf("version %s: %s", version, err)
+		}
+		var got []string
+		for _, pt := range points {
+			got = append(got, pt.String())
+		}
+		exp := []string{
+			"snmp,agent_host=127.0.0.1,sysName=router1 uptime=12345i 1500000000000000000",
+			"interface,agent_host=127.0.0.1,ifDescr=lo,index=1,sysName=router1 ifInOctets=100i 1500000000000000000",
+			"interface,agent_host=127.0.0.1,ifDescr=eth0,index=2,sysName=router1 ifHCInOctets=1099511627776u,ifInOctets=200i 1500000000000000000",
+			"interface,agent_host=127.0.0.1,ifDescr=eth1,index=3,sysName=router1 ifHCInOctets=2199023255552u,ifInOctets=300i 1500000000000000000",
+			"address,agent_host=127.0.0.1,index=192.0.2,sysName=router1 ipAdEntAddr=\"192.0.2.1\" 1500000000000000000",
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Fatalf("version %s: unexpected points:\n got %q\n exp %q", version, got, exp)
+		}
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	for _, tt := range []struct {
+		v     value
+		field interface{}
+		tag   string
+	}{
+		{v: value{typ: tagInteger, int: -3}, field: int64(-3), tag: "-3"},
+		{v: value{typ: tagGauge32, uint: 4294967295}, field: int64(4294967295), tag: "4294967295"},
+		{v: value{typ: tagCounter64, uint: 18446744073709551615}, field: uint64(18446744073709551615), tag: "18446744073709551615"},
+		{v: value{typ: tagOctetString, bytes: []byte("eth0")}, field: "eth0", tag: "eth0"},
+		{v: value{typ: tagOctetString, bytes: []byte{0x00, 0x1b, 0xff}}, field: "001bff", tag: "001bff"},
+		{v: value{typ: tagOID, oid: oid{1, 3, 6}}, field: ".1.3.6", tag: ".1.3.6"},
+		{v: value{typ: tagOpaque, bytes: []byte{0x9f, 0x78}}, field: "9f78", tag: "9f78"},
+	} {
+		if v := fieldValue(tt.v); v != tt.field {
+			t.Errorf("%+v: unexpected field value %#v", tt.v, v)
+		}
+		if v := tagValue(tt.v); v != tt.tag {
+			t.Errorf("%+v: unexpected tag value %q", tt.v, v)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package snmp provides a service for InfluxDB to poll the variables of SNMP
+// agents.
+package snmp // import "github.com/influxdata/influxdb/services/snmp"
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/tsdb"
+	"go.uber.org/zap"
+)
+
+// statistics gathered by the snmp package.
+const (
+	statPolls               = "polls"
+	statPollsFail           = "pollsFail"
+	statPointsReceived      = "pointsRx"
+	statBatchesTransmitted  = "batchesTx"
+	statPointsTransmitted   = "pointsTx"
+	statBatchesTransmitFail = "batchesTxFail"
+)
+
+// Service is an SNMP poller. It polls the variables of its agents on an
+// interval, and writes the points they are mapped to.
+type Service struct {
+	wg sync.WaitGroup
+
+	mu    sync.RWMutex
+	ready bool          // Has the required database been created?
+	done  chan struct{} // Is the service closing or closed?
+
+	config  Config
+	poller  *poller
+	batcher *tsdb.PointBatcher
+
+	clientsMu sync.Mutex
+	clients   map[*client]struct{}
+
+	PointsWriter interface {
+		WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	MetaClient interface {
+		CreateDatabase(name string) (*meta.DatabaseInfo, error)
+	}
+
+	Logger      *zap.Logger
+	stats       *Statistics
+	defaultTags models.StatisticTags
+}
+
+// NewService returns a new instance of Service.
+func NewService(c Config) *Service {
+	d := *c.WithDefaults()
+	return &Service{
+		config:      d,
+		clients:     make(map[*client]struct{}),
+		Logger:      zap.NewNop(),
+		stats:       &Statistics{},
+		defaultTags: models.StatisticTags{"database": d.Database},
+	}
+}
+
+// Open starts polling the agents.
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed() {
+		return nil // Already open.
+	}
+
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+	p, err := newPoller(&s.config)
+	if err != nil {
+		return err
+	}
+	s.poller = p
+
+	s.done = make(chan struct{})
+
+	s.batcher = tsdb.NewPointBatcher(s.config.BatchSize, s.config.BatchPending, time.Duration(s.config.BatchTimeout))
+	s.batcher.Start()
+
+	s.wg.Add(1)
+	go s.processBatches()
+
+	for _, addr := range s.config.Agents {
+		s.wg.Add(1)
+		go s.pollAgent(addr)
+	}
+
+	s.Logger.Info("Polling agents",
+		zap.Strings("agents", s.config.Agents),
+		zap.Duration("interval", time.Duration(s.config.Interval)))
+	return nil
+}
+
+// pollAgent polls the agent at addr on every interval until the service is
+// closed. A poll starts once the previous one has completed.
+func (s *Service) pollAgent(addr string) {
+	defer s.wg.Done()
+
+	var cl *client
+	defer func() {
+		if cl != nil {
+			s.untrackClient(cl)
+			cl.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(time.Duration(s.config.Interval))
+	defer ticker.Stop()
+	for {
+		if cl == nil {
+			var err error
+			if cl, err = dialClient(addr, &s.config); err != nil {
+				atomic.AddInt64(&s.stats.PollsFail, 1)
+				s.Logger.Info("Unable to connect to agent", zap.String("agent", addr), zap.Error(err))
+			} else if !s.trackClient(cl) {
+				cl.Close()
+				return
+			}
+		}
+		if cl != nil {
+			s.poll(cl, addr)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// poll polls the agent once and hands its points to the batcher.
+func (s *Service) poll(cl *client, addr string) {
+	atomic.AddInt64(&s.stats.Polls, 1)
+
+	points, err := s.poller.poll(cl, addr, time.Now().UTC())
+	if err != nil {
+		select {
+		case <-s.done:
+			return // The client was closed along with the service.
+		default:
+		}
+		atomic.AddInt64(&s.stats.PollsFail, 1)
+		s.Logger.Info("Unable to poll agent", zap.String("agent", addr), zap.Error(err))
+		return
+	}
+	atomic.AddInt64(&s.stats.PointsReceived, int64(len(points)))
+
+	for _, pt := range points {
+		select {
+		case s.batcher.In() <- pt:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// trackClient registers cl so it is closed along with the service,
+// interrupting the requests in progress. It returns false if the service is
+// closing.
+func (s *Service) trackClient(cl *client) bool {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	if s.clients == nil {
+		return false
+	}
+	s.clients[cl] = struct{}{}
+	return true
+}
+
+func (s *Service) untrackClient(cl *client) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	delete(s.clients, cl)
+}
+
+// processBatches writes the batches of the batcher until the service is
+// closed.
+func (s *Service) processBatches() {
+	defer s.wg.Done()
+	for {
+		select {
+		case batch := <-s.batcher.Out():
+			// Will attempt to create database if not yet created.
+			if err := s.createInternalStorage(); err != nil {
+				s.Logger.Info("Required database not yet created",
+					logger.Database(s.config.Database), zap.Error(err))
+				atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+				continue
+			}
+
+			if err := s.PointsWriter.WritePointsPrivileged(s.config.Database, s.config.RetentionPolicy, models.ConsistencyLevelAny, batch); err == nil {
+				atomic.AddInt64(&s.stats.BatchesTransmitted, 1)
+				atomic.AddInt64(&s.stats.PointsTransmitted, int64(len(batch)))
+			} else {
+				s.Logger.Info("Failed to write point batch to database",
+					logger.Database(s.config.Database), zap.Error(err))
+				atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+			}
+
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Statistics maintains statistics for the snmp service.
+type Statistics struct {
+	Polls               int64
+	PollsFail           int64
+	PointsReceived      int64
+	BatchesTransmitted  int64
+	PointsTransmitted   int64
+	BatchesTransmitFail int64
+}
+
+// Statistics returns statistics for periodic monitoring.
+func (s *Service) Statistics(tags map[string]string) []models.Statistic {
+	return []models.Statistic{{
+		Name: "snmp",
+		Tags: s.defaultTags.Merge(tags),
+		Values: map[string]interface{}{
+			statPolls:               atomic.LoadInt64(&s.stats.Polls),
+			statPollsFail:           atomic.LoadInt64(&s.stats.PollsFail),
+			statPointsReceived:      atomic.LoadInt64(&s.stats.PointsReceived),
+			statBatchesTransmitted:  atomic.LoadInt64(&s.stats.BatchesTransmitted),
+			statPointsTransmitted:   atomic.LoadInt64(&s.stats.PointsTransmitted),
+			statBatchesTransmitFail: atomic.LoadInt64(&s.stats.BatchesTransmitFail),
+		},
+	}}
+}
+
+// Close stops polling the agents, interrupting the polls in progress, and
+// stops writing points. Points still batched are discarded.
+func (s *Service) Close() error {
+	if wait := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.closed() {
+			return false // Already closed.
+		}
+		close(s.done)
+
+		s.clientsMu.Lock()
+		for cl := range s.clients {
+			cl.Close()
+		}
+		s.clients = nil
+		s.clientsMu.Unlock()
+
+		s.batcher.Stop()
+		return true
+	}(); !wait {
+		return nil
+	}
+	s.wg.Wait()
+
+	// Release all remaining resources.
+	s.mu.Lock()
+	s.done = nil
+	s.mu.Unlock()
+
+	s.clientsMu.Lock()
+	s.clients = make(map[*client]struct{})
+	s.clientsMu.Unlock()
+
+	s.Logger.Info("Service closed")
+
+	return nil
+}
+
+// Closed returns true if the service is currently closed.
+func (s *Service) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed()
+}
+
+func (s *Service) closed() bool {
+	select {
+	case <-s.done:
+		// Service is closing.
+		return true
+	default:
+	}
+	return s.done == nil
+}
+
+// createInternalStorage ensures that the required database has been created.
+func (s *Service) createInternalStorage() error {
+	s.mu.RLock()
+	ready := s.ready
+	s.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if _, err := s.MetaClient.CreateDatabase(s.config.Database); err != nil {
+		return err
+	}
+
+	// The service is now ready.
+	s.mu.Lock()
+	s.ready = true
+	s.mu.Unlock()
+	return nil
+}
+
+// WithLogger sets the logger on the service.
+func (s *Service) WithLogger(log *zap.Logger) {
+	s.Logger = log.With(zap.String("service", "snmp"))
+}
@@ -0,0 +1,185 @@
+package snmp
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/toml"
+)
+
+// Ensure the variables of agents are polled and written on every interval.
+func TestService_Poll(t *testing.T) {
+	t.Parallel()
+
+	agent := NewTestAgent(t, testVariables())
+	defer agent.Close()
+
+	c := NewConfig()
+	c.Enabled = true
+	c.Agents = []string{agent.Addr()}
+	c.Database = "network"
+	c.Interval = toml.Duration(50 * time.Millisecond)
+	c.BatchSize = 2
+	c.Fields = []Field{{Name: "uptime", OID: ".1.3.6.1.2.1.1.3.0"}}
+	c.Tables = []Table{{
+		Name:   "interface",
+		Fields: []Field{{Name: "ifInOctets", OID: ".1.3.6.1.2.1.2.2.1.10"}},
+	}}
+	s := NewTestService(&c)
+
+	written := make(chan []models.Point, 10)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		if database != "network" {
+			t.Errorf("unexpected database: %s", database)
+		}
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	// Every poll writes 4 points, in batches of 2.
+	seen := make(map[int64]int)
+	for len(seen) < 2 {
+		select {
+		case points := <-written:
+			if len(points) != 2 {
+				t.Fatalf("unexpected number of points: %d", len(points))
+			}
+			for _, pt := range points {
+				seen[pt.UnixNano()]++
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("points not written")
+		}
+	}
+
+	stats := s.Service.Statistics(nil)[0].Values
+	if stats[statPolls].(int64) < 2 || stats[statPollsFail].(int64) != 0 {
+		t.Fatalf("unexpected statistics: %v", stats)
+	}
+}
+
+// Ensure polls that fail are counted, and do not stop polling.
+func TestService_Poll_Timeout(t *testing.T) {
+	t.Parallel()
+
+	agent := NewTestAgent(t, testVariables())
+	defer agent.Close()
+	agent.Drop(1)
+
+	c := NewConfig()
+	c.Enabled = true
+	c.Agents = []string{agent.Addr()}
+	c.Interval = toml.Duration(10 * time.Millisecond)
+	c.Timeout = toml.Duration(10 * time.Millisecond)
+	c.Retries = 0
+	c.BatchSize = 1
+	c.Fields = []Field{{Name: "uptime", OID: ".1.3.6.1.2.1.1.3.0"}}
+	s := NewTestService(&c)
+
+	written := make(chan []models.Point, 10)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("points not written")
+	}
+	if n := s.Service.Statistics(nil)[0].Values[statPollsFail].(int64); n != 1 {
+		t.Fatalf("unexpected failed polls: %d", n)
+	}
+}
+
+// Ensure the service can be closed while polling, and reopened.
+func TestService_OpenClose(t *testing.T) {
+	agent := NewTestAgent(t, testVariables())
+	defer agent.Close()
+	agent.Drop(1000)
+
+	c := NewConfig()
+	c.Enabled = true
+	c.Agents = []string{agent.Addr()}
+	c.Timeout = toml.Duration(time.Minute)
+	c.Fields = []Field{{Name: "uptime", OID: ".1.3.6.1.2.1.1.3.0"}}
+	s := NewTestService(&c)
+
+	// Closing a closed service is fine.
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Opening an already open service is fine.
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Closing interrupts the requests in progress.
+		time.Sleep(10 * time.Millisecond)
+		if err := s.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.Service.Statistics(nil)[0].Values[statPollsFail].(int64); n != 0 {
+		t.Fatalf("unexpected failed polls: %d", n)
+	}
+}
+
+type TestService struct {
+	Service       *Service
+	Config        Config
+	MetaClient    *internal.MetaClientMock
+	WritePointsFn func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+}
+
+func NewTestService(c *Config) *TestService {
+	if c == nil {
+		defaultC := NewConfig()
+		c = &defaultC
+	}
+
+	service := &TestService{
+		Service:    NewService(*c),
+		Config:     *c,
+		MetaClient: &internal.MetaClientMock{},
+	}
+	service.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	if testing.Verbose() {
+		service.Service.WithLogger(logger.New(os.Stderr))
+	}
+
+	service.Service.MetaClient = service.MetaClient
+	service.Service.PointsWriter = service
+	return service
+}
+
+func (s *TestService) WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	if s.WritePointsFn == nil {
+		return nil
+	}
+	return s.WritePointsFn(database, retentionPolicy, consistencyLevel, points)
+}
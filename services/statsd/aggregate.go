@@ -0,0 +1,200 @@
+package statsd
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// series identifies a statsd metric by its name and tags.
+type series struct {
+	name string
+	tags models.Tags
+}
+
+// counter holds the sum of a counter since the last flush.
+type counter struct {
+	series
+	value float64
+}
+
+// timer holds the values of a timer received since the last flush.
+type timer struct {
+	series
+	values []float64
+	count  float64 // values received, scaled by their sample rate
+}
+
+// gauge holds the current value of a gauge.
+type gauge struct {
+	series
+	value   float64
+	updated bool
+}
+
+// aggregator aggregates statsd metrics between flushes, following the
+// semantics of the statsd daemon: counters are summed, scaled by their
+// sample rate, and reset at every flush; gauges keep their last value; the
+// values of timers are summarized; and sets count their distinct members.
+type aggregator struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+	gauges   map[string]*gauge
+	timers   map[string]*timer
+	sets     map[string]map[string]struct{}
+	setNames map[string]series
+
+	percentiles  []float64
+	deleteGauges bool
+}
+
+// newAggregator returns an aggregator computing the given percentiles of
+// timers. If deleteGauges is set, gauges not updated since the last flush
+// are not written again.
+func newAggregator(percentiles []float64, deleteGauges bool) *aggregator {
+	return &aggregator{
+		counters:     make(map[string]*counter),
+		gauges:       make(map[string]*gauge),
+		timers:       make(map[string]*timer),
+		sets:         make(map[string]map[string]struct{}),
+		setNames:     make(map[string]series),
+		percentiles:  percentiles,
+		deleteGauges: deleteGauges,
+	}
+}
+
+// add adds m to the aggregates.
+func (a *aggregator) add(m metric) {
+	key := string(models.MakeKey([]byte(m.name), m.tags))
+	s := series{name: m.name, tags: m.tags}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch m.typ {
+	case typeCounter:
+		c := a.counters[key]
+		if c == nil {
+			c = &counter{series: s}
+			a.counters[key] = c
+		}
+		c.value += m.value / m.sampleRate
+	case typeGauge:
+		g := a.gauges[key]
+		if g == nil {
+			g = &gauge{series: s}
+			a.gauges[key] = g
+		}
+		if m.delta {
+			g.value += m.value
+		} else {
+			g.value = m.value
+		}
+		g.updated = true
+	case typeTimer, typeHistogram:
+		t := a.timers[key]
+		if t == nil {
+			t = &timer{series: s}
+			a.timers[key] = t
+		}
+		t.values = append(t.values, m.value)
+		t.count += 1 / m.sampleRate
+	case typeSet:
+		set := a.sets[key]
+		if set == nil {
+			set = make(map[string]struct{})
+			a.sets[key] = set
+			a.setNames[key] = s
+		}
+		set[m.set] = struct{}{}
+	}
+}
+
+// flush returns the aggregates as points timestamped with now, and resets
+// them. Every point is tagged with the type of its metric.
+func (a *aggregator) flush(now time.Time) []models.Point {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var points []models.Point
+	add := func(s series, typ string, fields models.Fields) {
+		tags := s.tags.Clone()
+		tags.SetString("metric_type", typ)
+		if p, err := models.NewPoint(s.name, tags, fields, now); err == nil {
+			points = append(points, p)
+		}
+	}
+
+	for key, c := range a.counters {
+		add(c.series, "counter", models.Fields{"value": c.value})
+		delete(a.counters, key)
+	}
+
+	for key, g := range a.gauges {
+		if !g.updated && a.deleteGauges {
+			delete(a.gauges, key)
+			continue
+		}
+		add(g.series, "gauge", models.Fields{"value": g.value})
+		g.updated = false
+	}
+
+	for key, t := range a.timers {
+		add(t.series, "timing", a.timerFields(t))
+		delete(a.timers, key)
+	}
+
+	for key, set := range a.sets {
+		add(a.setNames[key], "set", models.Fields{"value": int64(len(set))})
+		delete(a.sets, key)
+		delete(a.setNames, key)
+	}
+
+	return points
+}
+
+// timerFields summarizes the values of t.
+func (a *aggregator) timerFields(t *timer) models.Fields {
+	values := t.values
+	sort.Float64s(values)
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+
+	fields := models.Fields{
+		"count":  t.count,
+		"lower":  values[0],
+		"upper":  values[len(values)-1],
+		"mean":   mean,
+		"sum":    sum,
+		"stddev": math.Sqrt(variance / float64(len(values))),
+	}
+	for _, p := range a.percentiles {
+		// Use the nearest-rank method.
+		i := int(math.Ceil(p/100*float64(len(values)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		fields[percentileField(p)] = values[i]
+	}
+	return fields
+}
+
+// percentileField returns the name of the field holding percentile p, such
+// as "p90" or "p99_9".
+func percentileField(p float64) string {
+	return "p" + strings.Replace(strconv.FormatFloat(p, 'f', -1, 64), ".", "_", -1)
+}
@@ -0,0 +1,89 @@
+package statsd
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestAggregator(t *testing.T) {
+	a := newAggregator([]float64{50, 99.9}, true)
+	for _, line := range []string{
+		"requests:1|c",
+		"requests:2|c|@0.5",
+		"requests:1|c|#host:web01",
+		"temperature:20|g",
+		"temperature:+2|g",
+		"latency:10|ms",
+		"latency:30|ms",
+		"latency:20|ms|@0.5",
+		"users:alice|s",
+		"users:bob|s",
+		"users:alice|s",
+	} {
+		m, err := parseLine(line, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.add(m)
+	}
+
+	flush := func(now time.Time) []string {
+		var got []string
+		for _, p := range a.flush(now) {
+			got = append(got, p.String())
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	exp := []string{
+		"latency,metric_type=timing count=4,lower=10,mean=20,p50=20,p99_9=30,stddev=8.16496580927726,sum=60,upper=30 10000000000",
+		"requests,host=web01,metric_type=counter value=1 10000000000",
+		"requests,metric_type=counter value=5 10000000000",
+		"temperature,metric_type=gauge value=22 10000000000",
+		"users,metric_type=set value=2i 10000000000",
+	}
+	if got := flush(time.Unix(10, 0)); !equalStrings(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+
+	// Gauges that were not updated are deleted, and updated with deltas from
+	// their last value otherwise.
+	if got := flush(time.Unix(20, 0)); len(got) != 0 {
+		t.Fatalf("unexpected points flushed: %v", got)
+	}
+
+	a = newAggregator(nil, false)
+	m, _ := parseLine("temperature:20|g", false)
+	a.add(m)
+	flush(time.Unix(10, 0))
+	if got, exp := flush(time.Unix(20, 0)), []string{"temperature,metric_type=gauge value=20 20000000000"}; !equalStrings(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+	m, _ = parseLine("temperature:-5|g", false)
+	a.add(m)
+	if got, exp := flush(time.Unix(30, 0)), []string{"temperature,metric_type=gauge value=15 30000000000"}; !equalStrings(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
+
+func TestPercentileField(t *testing.T) {
+	for p, exp := range map[float64]string{90: "p90", 99.9: "p99_9", 99.99: "p99_99"} {
+		if got := percentileField(p); got != exp {
+			t.Errorf("%v: got %s, expected %s", p, got, exp)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb/monitor/diagnostics"
+	"github.com/influxdata/influxdb/toml"
+)
+
+const (
+	// DefaultBindAddress is the default binding interface if none is specified.
+	DefaultBindAddress = ":8125"
+
+	// DefaultProtocol is the default protocol the statsd service listens on.
+	DefaultProtocol = "udp"
+
+	// DefaultDatabase is the default database for statsd metrics.
+	DefaultDatabase = "statsd"
+
+	// DefaultRetentionPolicy is the default retention policy used for writes.
+	DefaultRetentionPolicy = ""
+
+	// DefaultFlushInterval is the default interval at which the aggregated
+	// metrics are written.
+	DefaultFlushInterval = 10 * time.Second
+
+	// DefaultReadBuffer is the default buffer size for the UDP listener. Zero
+	// uses the operating system's default.
+	DefaultReadBuffer = 0
+
+	// DefaultMaxTCPConnections is the default number of concurrent TCP
+	// connections accepted.
+	DefaultMaxTCPConnections = 250
+)
+
+// DefaultPercentiles are the percentiles computed for timers by default.
+var DefaultPercentiles = []float64{90}
+
+// Config represents the configuration of a statsd service.
+type Config struct {
+	Enabled         bool          `toml:"enabled"`
+	BindAddress     string        `toml:"bind-address"`
+	Protocol        string        `toml:"protocol"`
+	Database        string        `toml:"database"`
+	RetentionPolicy string        `toml:"retention-policy"`
+	FlushInterval   toml.Duration `toml:"flush-interval"`
+	ReadBuffer      int           `toml:"read-buffer"`
+
+	// MaxTCPConnections limits the number of concurrent TCP connections.
+	// Further connections are closed as soon as they are accepted.
+	MaxTCPConnections int `toml:"max-tcp-connections"`
+
+	// Percentiles are the percentiles computed for every timer, such as 90
+	// or 99.9.
+	Percentiles []float64 `toml:"percentiles"`
+
+	// ParseDataDogTags enables the "|#tag:value,..." extension of the
+	// DogStatsD protocol.
+	ParseDataDogTags bool `toml:"datadog-tags"`
+
+	// DeleteGauges stops writing gauges that were not updated since the
+	// last flush. Otherwise, the last value of every gauge is written at
+	// every flush.
+	DeleteGauges bool `toml:"delete-gauges"`
+}
+
+// NewConfig returns a new instance of Config with defaults.
+func NewConfig() Config {
+	return Config{
+		BindAddress:       DefaultBindAddress,
+		Protocol:          DefaultProtocol,
+		Database:          DefaultDatabase,
+		RetentionPolicy:   DefaultRetentionPolicy,
+		FlushInterval:     toml.Duration(DefaultFlushInterval),
+		ReadBuffer:        DefaultReadBuffer,
+		MaxTCPConnections: DefaultMaxTCPConnections,
+		Percentiles:       DefaultPercentiles,
+		ParseDataDogTags:  true,
+		DeleteGauges:      true,
+	}
+}
+
+// WithDefaults takes the given config and returns a new config with any required
+// default values set.
+func (c *Config) WithDefaults() *Config {
+	d := *c
+	if d.BindAddress == "" {
+		d.BindAddress = DefaultBindAddress
+	}
+	if d.Protocol == "" {
+		d.Protocol = DefaultProtocol
+	}
+	if d.Database == "" {
+		d.Database = DefaultDatabase
+	}
+	if d.FlushInterval == 0 {
+		d.FlushInterval = toml.Duration(DefaultFlushInterval)
+	}
+	if d.MaxTCPConnections == 0 {
+		d.MaxTCPConnections = DefaultMaxTCPConnections
+	}
+	if d.Percentiles == nil {
+		d.Percentiles = DefaultPercentiles
+	}
+	return &d
+}
+
+// Validate returns an error if the config is invalid.
+func (c *Config) Validate() error {
+	switch c.Protocol {
+	case "", "udp", "tcp":
+	default:
+		return errors.New(`Invalid value for protocol. Valid options are "udp" and "tcp"`)
+	}
+
+	if c.FlushInterval < 0 {
+		return errors.New("flush-interval must not be negative")
+	}
+	if c.MaxTCPConnections < 0 {
+		return errors.New("max-tcp-connections must not be negative")
+	}
+
+	for _, p := range c.Percentiles {
+		if p <= 0 || p >= 100 {
+			return fmt.Errorf("percentile %v must be between 0 and 100", p)
+		}
+	}
+	return nil
+}
+
+// Configs wraps a slice of Config to aggregate diagnostics.
+type Configs []Config
+
+// Diagnostics returns one set of diagnostics for all of the Configs.
+func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
+	d := &diagnostics.Diagnostics{
+		Columns: []string{"enabled", "bind-address", "protocol", "database", "retention-policy", "flush-interval"},
+	}
+
+	for _, cc := range c {
+		if !cc.Enabled {
+			d.AddRow([]interface{}{false})
+			continue
+		}
+
+		r := []interface{}{true, cc.BindAddress, cc.Protocol, cc.Database, cc.RetentionPolicy, cc.FlushInterval}
+		d.AddRow(r)
+	}
+
+	return d, nil
+}
+
+// Enabled returns true if any underlying Config is Enabled.
+func (c Configs) Enabled() bool {
+	for _, cc := range c {
+		if cc.Enabled {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package statsd_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdata/influxdb/services/statsd"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	var c statsd.Config
+	if _, err := toml.Decode(`
+enabled = true
+bind-address = ":8126"
+protocol = "tcp"
+database = "metrics"
+retention-policy = "rp0"
+flush-interval = "1m"
+percentiles = [90.0, 99.9]
+datadog-tags = true
+delete-gauges = false
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled: %v", c.Enabled)
+	} else if c.BindAddress != ":8126" {
+		t.Fatalf("unexpected bind address: %s", c.BindAddress)
+	} else if c.Protocol != "tcp" {
+		t.Fatalf("unexpected protocol: %s", c.Protocol)
+	} else if c.Database != "metrics" {
+		t.Fatalf("unexpected database: %s", c.Database)
+	} else if c.RetentionPolicy != "rp0" {
+		t.Fatalf("unexpected retention policy: %s", c.RetentionPolicy)
+	} else if time.Duration(c.FlushInterval) != time.Minute {
+		t.Fatalf("unexpected flush interval: %v", c.FlushInterval)
+	} else if !reflect.DeepEqual(c.Percentiles, []float64{90, 99.9}) {
+		t.Fatalf("unexpected percentiles: %v", c.Percentiles)
+	} else if !c.ParseDataDogTags {
+		t.Fatalf("unexpected datadog-tags: %v", c.ParseDataDogTags)
+	} else if c.DeleteGauges {
+		t.Fatalf("unexpected delete-gauges: %v", c.DeleteGauges)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, test := range []struct {
+		fn  func(c *statsd.Config)
+		err bool
+	}{
+		{fn: func(c *statsd.Config) {}},
+		{fn: func(c *statsd.Config) { c.Protocol = "tcp" }},
+		{fn: func(c *statsd.Config) { c.Protocol = "sctp" }, err: true},
+		{fn: func(c *statsd.Config) { c.Percentiles = []float64{0} }, err: true},
+		{fn: func(c *statsd.Config) { c.Percentiles = []float64{100} }, err: true},
+		{fn: func(c *statsd.Config) { c.MaxTCPConnections = -1 }, err: true},
+	} {
+		c := statsd.NewConfig()
+		test.fn(&c)
+		if err := c.Validate(); test.err && err == nil {
+			t.Errorf("%+v: expected error", c)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", c, err)
+		}
+	}
+}
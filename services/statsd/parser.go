@@ -0,0 +1,127 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// Metric types of the statsd protocol.
+const (
+	typeCounter   = "c"
+	typeGauge     = "g"
+	typeTimer     = "ms"
+	typeHistogram = "h"
+	typeSet       = "s"
+)
+
+// metric is a single statsd metric, such as "requests:1|c|@0.5".
+type metric struct {
+	name string
+	tags models.Tags
+	typ  string
+
+	// value is the value of counters, gauges and timers, and set is the
+	// member added to a set.
+	value float64
+	set   string
+
+	// delta is set for gauges whose value is prefixed with a sign, which
+	// change the gauge by that amount rather than replacing its value.
+	delta bool
+
+	// sampleRate is the rate at which counters and timers were sampled by
+	// the client.
+	sampleRate float64
+}
+
+// parseLine parses a line of the statsd protocol, of the form
+// name:value|type[|@sample_rate][|#tag:value,...]. DogStatsD tags are only
+// parsed if datadogTags is set.
+func parseLine(line string, datadogTags bool) (metric, error) {
+	m := metric{sampleRate: 1}
+
+	i := strings.LastIndex(line, ":")
+	if j := strings.Index(line, "|"); j >= 0 {
+		i = strings.LastIndex(line[:j], ":")
+	}
+	if i <= 0 {
+		return m, fmt.Errorf("invalid line: %q", line)
+	}
+	m.name = line[:i]
+
+	parts := strings.Split(line[i+1:], "|")
+	if len(parts) < 2 {
+		return m, fmt.Errorf("missing metric type: %q", line)
+	}
+	value := parts[0]
+	m.typ = parts[1]
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return m, fmt.Errorf("invalid sample rate: %q", part)
+			}
+			m.sampleRate = rate
+		case strings.HasPrefix(part, "#"):
+			if !datadogTags {
+				continue
+			}
+			tags, err := parseTags(part[1:])
+			if err != nil {
+				return m, err
+			}
+			m.tags = tags
+		default:
+			return m, fmt.Errorf("invalid field: %q", part)
+		}
+	}
+
+	switch m.typ {
+	case typeSet:
+		if value == "" {
+			return m, errors.New("empty set member")
+		}
+		m.set = value
+		return m, nil
+	case typeGauge:
+		m.delta = strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+	case typeCounter, typeTimer, typeHistogram:
+	default:
+		return m, fmt.Errorf("invalid metric type: %q", m.typ)
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return m, fmt.Errorf("invalid value: %q", value)
+	}
+	m.value = v
+	return m, nil
+}
+
+// parseTags parses DogStatsD tags, of the form tag:value,... A tag without
+// a value is set to "true".
+func parseTags(s string) (models.Tags, error) {
+	var tags models.Tags
+	for _, tag := range strings.Split(s, ",") {
+		if tag == "" {
+			continue
+		}
+
+		kv := strings.SplitN(tag, ":", 2)
+		if kv[0] == "" {
+			return nil, fmt.Errorf("invalid tag: %q", tag)
+		}
+		v := "true"
+		if len(kv) == 2 && kv[1] != "" {
+			v = kv[1]
+		}
+		tags.SetString(kv[0], v)
+	}
+	return tags, nil
+}
@@ -0,0 +1,59 @@
+package statsd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+func TestParseLine(t *testing.T) {
+	for _, test := range []struct {
+		line        string
+		datadogTags bool
+		exp         metric
+		err         bool
+	}{
+		{line: "requests:1|c", exp: metric{name: "requests", typ: typeCounter, value: 1, sampleRate: 1}},
+		{line: "requests:2|c|@0.1", exp: metric{name: "requests", typ: typeCounter, value: 2, sampleRate: 0.1}},
+		{line: "temperature:21.5|g", exp: metric{name: "temperature", typ: typeGauge, value: 21.5, sampleRate: 1}},
+		{line: "temperature:-3|g", exp: metric{name: "temperature", typ: typeGauge, value: -3, delta: true, sampleRate: 1}},
+		{line: "temperature:+3|g", exp: metric{name: "temperature", typ: typeGauge, value: 3, delta: true, sampleRate: 1}},
+		{line: "latency:320|ms|@0.5", exp: metric{name: "latency", typ: typeTimer, value: 320, sampleRate: 0.5}},
+		{line: "size:42|h", exp: metric{name: "size", typ: typeHistogram, value: 42, sampleRate: 1}},
+		{line: "users:alice|s", exp: metric{name: "users", typ: typeSet, set: "alice", sampleRate: 1}},
+		{
+			line:        "requests:1|c|#host:web01,env:prod,canary",
+			datadogTags: true,
+			exp: metric{
+				name:       "requests",
+				tags:       models.NewTags(map[string]string{"host": "web01", "env": "prod", "canary": "true"}),
+				typ:        typeCounter,
+				value:      1,
+				sampleRate: 1,
+			},
+		},
+		{line: "requests:1|c|#host:web01", exp: metric{name: "requests", typ: typeCounter, value: 1, sampleRate: 1}},
+		{line: "requests", err: true},
+		{line: ":1|c", err: true},
+		{line: "requests:1", err: true},
+		{line: "requests:x|c", err: true},
+		{line: "requests:1|q", err: true},
+		{line: "requests:1|c|@2", err: true},
+		{line: "requests:1|c|x", err: true},
+		{line: "users:|s", err: true},
+		{line: "requests:1|c|#:web01", datadogTags: true, err: true},
+	} {
+		got, err := parseLine(test.line, test.datadogTags)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error", test.line)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.line, err)
+		} else if !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("%q: got %+v, expected %+v", test.line, got, test.exp)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+// Package statsd provides a service for InfluxDB to ingest metrics using the statsd protocol.
+package statsd // import "github.com/influxdata/influxdb/services/statsd"
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"go.uber.org/zap"
+)
+
+// MaxUDPPayload is largest payload size the statsd service will accept.
+const MaxUDPPayload = 64 * 1024
+
+// statistics gathered by the statsd package.
+const (
+	statMetricsReceived       = "metricsRx"
+	statBytesReceived         = "bytesRx"
+	statMetricsParseFail      = "metricsParseFail"
+	statReadFail              = "readFail"
+	statBatchesTransmitted    = "batchesTx"
+	statPointsTransmitted     = "pointsTx"
+	statBatchesTransmitFail   = "batchesTxFail"
+	statTCPConnectionsActive  = "tcpConnsActive"
+	statTCPConnectionsDropped = "tcpConnsDropped"
+)
+
+// Service is a statsd service that aggregates the metrics it receives over
+// UDP or TCP and writes them at every flush interval.
+type Service struct {
+	conn *net.UDPConn
+	ln   net.Listener
+	wg   sync.WaitGroup
+
+	mu    sync.RWMutex
+	ready bool          // Has the required database been created?
+	done  chan struct{} // Is the service closing or closed?
+
+	tcpConnsMu sync.Mutex
+	tcpConns   map[net.Conn]struct{}
+
+	aggregator *aggregator
+	config     Config
+
+	PointsWriter interface {
+		WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	MetaClient interface {
+		CreateDatabase(name string) (*meta.DatabaseInfo, error)
+	}
+
+	Logger      *zap.Logger
+	stats       *Statistics
+	defaultTags models.StatisticTags
+}
+
+// NewService returns a new instance of Service.
+func NewService(c Config) *Service {
+	d := *c.WithDefaults()
+	return &Service{
+		config:      d,
+		tcpConns:    make(map[net.Conn]struct{}),
+		Logger:      zap.NewNop(),
+		stats:       &Statistics{},
+		defaultTags: models.StatisticTags{"bind": d.BindAddress, "proto": d.Protocol},
+	}
+}
+
+// Open starts the service.
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed() {
+		return nil // Already open.
+	}
+
+	if s.config.BindAddress == "" {
+		return errors.New("bind address has to be specified in config")
+	}
+	if s.config.Database == "" {
+		return errors.New("database has to be specified in config")
+	}
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+
+	switch s.config.Protocol {
+	case "tcp":
+		ln, err := net.Listen("tcp", s.config.BindAddress)
+		if err != nil {
+			s.Logger.Info("Failed to set up TCP listener",
+				zap.String("bind_address", s.config.BindAddress), zap.Error(err))
+			return err
+		}
+		s.ln = ln
+	default:
+		addr, err := net.ResolveUDPAddr("udp", s.config.BindAddress)
+		if err != nil {
+			s.Logger.Info("Failed to resolve UDP address",
+				zap.String("bind_address", s.config.BindAddress), zap.Error(err))
+			return err
+		}
+
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			s.Logger.Info("Failed to set up UDP listener",
+				zap.Stringer("addr", addr), zap.Error(err))
+			return err
+		}
+
+		if s.config.ReadBuffer != 0 {
+			if err := conn.SetReadBuffer(s.config.ReadBuffer); err != nil {
+				conn.Close()
+				s.Logger.Info("Failed to set UDP read buffer",
+					zap.Int("buffer_size", s.config.ReadBuffer), zap.Error(err))
+				return err
+			}
+		}
+		s.conn = conn
+	}
+
+	s.done = make(chan struct{})
+	s.aggregator = newAggregator(s.config.Percentiles, s.config.DeleteGauges)
+
+	s.Logger.Info("Started listening",
+		zap.String("protocol", s.config.Protocol), zap.Stringer("addr", s.Addr()))
+
+	s.wg.Add(2)
+	go s.serve()
+	go s.flusher()
+
+	return nil
+}
+
+// Statistics maintains statistics for the statsd service.
+type Statistics struct {
+	MetricsReceived       int64
+	BytesReceived         int64
+	MetricsParseFail      int64
+	ReadFail              int64
+	BatchesTransmitted    int64
+	PointsTransmitted     int64
+	BatchesTransmitFail   int64
+	ActiveTCPConnections  int64
+	DroppedTCPConnections int64
+}
+
+// Statistics returns statistics for periodic monitoring.
+func (s *Service) Statistics(tags map[string]string) []models.Statistic {
+	return []models.Statistic{{
+		Name: "statsd",
+		Tags: s.defaultTags.Merge(tags),
+		Values: map[string]interface{}{
+			statMetricsReceived:       atomic.LoadInt64(&s.stats.MetricsReceived),
+			statBytesReceived:         atomic.LoadInt64(&s.stats.BytesReceived),
+			statMetricsParseFail:      atomic.LoadInt64(&s.stats.MetricsParseFail),
+			statReadFail:              atomic.LoadInt64(&s.stats.ReadFail),
+			statBatchesTransmitted:    atomic.LoadInt64(&s.stats.BatchesTransmitted),
+			statPointsTransmitted:     atomic.LoadInt64(&s.stats.PointsTransmitted),
+			statBatchesTransmitFail:   atomic.LoadInt64(&s.stats.BatchesTransmitFail),
+			statTCPConnectionsActive:  atomic.LoadInt64(&s.stats.ActiveTCPConnections),
+			statTCPConnectionsDropped: atomic.LoadInt64(&s.stats.DroppedTCPConnections),
+		},
+	}}
+}
+
+// serve reads from the listener until the service is closed.
+func (s *Service) serve() {
+	defer s.wg.Done()
+
+	if s.ln != nil {
+		s.serveTCP()
+		return
+	}
+
+	buf := make([]byte, MaxUDPPayload)
+	for {
+		n, _, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+				// We closed the connection, time to go.
+				return
+			default:
+			}
+			atomic.AddInt64(&s.stats.ReadFail, 1)
+			s.Logger.Info("Failed to read UDP message", zap.Error(err))
+			continue
+		}
+		atomic.AddInt64(&s.stats.BytesReceived, int64(n))
+
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			s.handleLine(line)
+		}
+	}
+}
+
+// serveTCP accepts TCP connections until the listener is closed.
+func (s *Service) serveTCP() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			if opErr, ok := err.(*net.OpError); ok && !opErr.Temporary() {
+				s.Logger.Info("statsd TCP listener closed", zap.Error(err))
+				return
+			}
+			s.Logger.Info("Error accepting TCP connection", zap.Error(err))
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.handleTCPConnection(conn)
+	}
+}
+
+// handleTCPConnection reads newline separated metrics from conn until it is
+// closed.
+func (s *Service) handleTCPConnection(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	if !s.trackTCPConnection(conn) {
+		return
+	}
+	defer s.untrackTCPConnection(conn)
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		atomic.AddInt64(&s.stats.BytesReceived, int64(len(scanner.Bytes())+1))
+		s.handleLine(scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		atomic.AddInt64(&s.stats.ReadFail, 1)
+		s.Logger.Info("Error reading from TCP connection",
+			zap.Stringer("remote_addr", conn.RemoteAddr()), zap.Error(err))
+	}
+}
+
+// trackTCPConnection records conn so it can be closed with the service. It
+// returns false if the service is closing or the connection limit is reached.
+func (s *Service) trackTCPConnection(conn net.Conn) bool {
+	s.tcpConnsMu.Lock()
+	defer s.tcpConnsMu.Unlock()
+	if s.Closed() {
+		return false
+	} else if len(s.tcpConns) >= s.config.MaxTCPConnections {
+		atomic.AddInt64(&s.stats.DroppedTCPConnections, 1)
+		s.Logger.Info("Dropping TCP connection over the connection limit",
+			zap.Stringer("remote_addr", conn.RemoteAddr()))
+		return false
+	}
+	s.tcpConns[conn] = struct{}{}
+	atomic.AddInt64(&s.stats.ActiveTCPConnections, 1)
+	return true
+}
+
+func (s *Service) untrackTCPConnection(conn net.Conn) {
+	s.tcpConnsMu.Lock()
+	defer s.tcpConnsMu.Unlock()
+	delete(s.tcpConns, conn)
+	atomic.AddInt64(&s.stats.ActiveTCPConnections, -1)
+}
+
+// closeTCPConnections closes all open TCP connections.
+func (s *Service) closeTCPConnections() {
+	s.tcpConnsMu.Lock()
+	defer s.tcpConnsMu.Unlock()
+	for conn := range s.tcpConns {
+		conn.Close()
+	}
+}
+
+// handleLine parses a single line of the statsd protocol and adds it to the
+// aggregates. Empty lines are ignored.
+func (s *Service) handleLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+
+	m, err := parseLine(string(line), s.config.ParseDataDogTags)
+	if err != nil {
+		atomic.AddInt64(&s.stats.MetricsParseFail, 1)
+		s.Logger.Info("Failed to parse metric", zap.Error(err))
+		return
+	}
+	s.aggregator.add(m)
+	atomic.AddInt64(&s.stats.MetricsReceived, 1)
+}
+
+// flusher writes the aggregates at every flush interval. The aggregates are
+// written one last time when the service is closed.
+func (s *Service) flusher() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(s.config.FlushInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.write(s.aggregator.flush(now))
+		case <-s.done:
+			s.write(s.aggregator.flush(time.Now()))
+			return
+		}
+	}
+}
+
+// write writes points to the configured database.
+func (s *Service) write(points []models.Point) {
+	if len(points) == 0 {
+		return
+	}
+
+	// Will attempt to create database if not yet created.
+	if err := s.createInternalStorage(); err != nil {
+		s.Logger.Info("Required database does not yet exist",
+			logger.Database(s.config.Database), zap.Error(err))
+		atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+		return
+	}
+
+	if err := s.PointsWriter.WritePointsPrivileged(s.config.Database, s.config.RetentionPolicy, models.ConsistencyLevelAny, points); err == nil {
+		atomic.AddInt64(&s.stats.BatchesTransmitted, 1)
+		atomic.AddInt64(&s.stats.PointsTransmitted, int64(len(points)))
+	} else {
+		s.Logger.Info("Failed to write point batch to database",
+			logger.Database(s.config.Database), zap.Error(err))
+		atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+	}
+}
+
+// Close closes the service and the underlying listener.
+func (s *Service) Close() error {
+	if wait := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.closed() {
+			return false // Already closed.
+		}
+		close(s.done)
+
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		if s.ln != nil {
+			s.ln.Close()
+		}
+		return true
+	}(); !wait {
+		return nil
+	}
+	s.closeTCPConnections()
+	s.wg.Wait()
+
+	// Release all remaining resources.
+	s.mu.Lock()
+	s.done = nil
+	s.conn = nil
+	s.ln = nil
+	s.mu.Unlock()
+
+	s.Logger.Info("Service closed")
+
+	return nil
+}
+
+// Closed returns true if the service is currently closed.
+func (s *Service) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed()
+}
+
+func (s *Service) closed() bool {
+	select {
+	case <-s.done:
+		// Service is closing.
+		return true
+	default:
+	}
+	return s.done == nil
+}
+
+// createInternalStorage ensures that the required database has been created.
+func (s *Service) createInternalStorage() error {
+	s.mu.RLock()
+	ready := s.ready
+	s.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if _, err := s.MetaClient.CreateDatabase(s.config.Database); err != nil {
+		return err
+	}
+
+	// The service is now ready.
+	s.mu.Lock()
+	s.ready = true
+	s.mu.Unlock()
+	return nil
+}
+
+// WithLogger sets the logger on the service.
+func (s *Service) WithLogger(log *zap.Logger) {
+	s.Logger = log.With(zap.String("service", "statsd"))
+}
+
+// Addr returns the listener's address. Returns nil if the service is closed.
+func (s *Service) Addr() net.Addr {
+	if s.ln != nil {
+		return s.ln.Addr()
+	} else if s.conn != nil {
+		return s.conn.LocalAddr()
+	}
+	return nil
+}
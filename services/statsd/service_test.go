@@ -0,0 +1,259 @@
+package statsd
+
+import (
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/toml"
+)
+
+func TestService_OpenClose(t *testing.T) {
+	for _, protocol := range []string{"udp", "tcp"} {
+		c := NewConfig()
+		c.BindAddress = "127.0.0.1:0"
+		c.Protocol = protocol
+		service := NewTestService(&c)
+
+		// Closing a closed service is fine.
+		if err := service.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := service.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Opening an already open service is fine.
+		if err := service.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Reopening a previously opened service is fine.
+		if err := service.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := service.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Tidy up.
+		if err := service.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Ensure metrics received over UDP and TCP are aggregated and written at
+// every flush.
+func TestService_Write(t *testing.T) {
+	t.Parallel()
+
+	for _, protocol := range []string{"udp", "tcp"} {
+		c := NewConfig()
+		c.BindAddress = "127.0.0.1:0"
+		c.Protocol = protocol
+		c.FlushInterval = toml.Duration(50 * time.Millisecond)
+		s := NewTestService(&c)
+
+		written := make(chan []models.Point, 10)
+		s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+			if database != "statsd" {
+				t.Errorf("unexpected database: %s", database)
+			}
+			written <- points
+			return nil
+		}
+
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := net.Dial(protocol, s.Service.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write([]byte("requests:1|c\nrequests:2|c|#host:web01\r\n\nrequests:x|c\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		timeout := time.After(5 * time.Second)
+		for len(got) < 2 {
+			select {
+			case points := <-written:
+				for _, p := range points {
+					got = append(got, withoutTime(p))
+				}
+			case <-timeout:
+				t.Fatalf("%s: points not written, got %v", protocol, got)
+			}
+		}
+		conn.Close()
+
+		sort.Strings(got)
+		if exp := []string{"requests,host=web01,metric_type=counter value=2", "requests,metric_type=counter value=1"}; !equalStrings(got, exp) {
+			t.Fatalf("%s: got %v, expected %v", protocol, got, exp)
+		}
+
+		stats := s.Service.Statistics(nil)[0].Values
+		if got, exp := stats[statMetricsReceived], int64(2); got != exp {
+			t.Fatalf("%s: got %v metrics received, expected %d", protocol, got, exp)
+		} else if got, exp := stats[statMetricsParseFail], int64(1); got != exp {
+			t.Fatalf("%s: got %v parse failures, expected %d", protocol, got, exp)
+		}
+
+		if err := s.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Ensure the aggregates are written when the service is closed.
+func TestService_Close_Flush(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.BindAddress = "127.0.0.1:0"
+	c.Protocol = "tcp"
+	c.FlushInterval = toml.Duration(time.Hour)
+	s := NewTestService(&c)
+
+	var got []string
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		for _, p := range points {
+			got = append(got, withoutTime(p))
+		}
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", s.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("temperature:21|g\n")); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// Wait for the metric to be received.
+	timeout := time.After(5 * time.Second)
+	for s.Service.Statistics(nil)[0].Values[statMetricsReceived] != int64(1) {
+		select {
+		case <-timeout:
+			t.Fatal("metric not received")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"temperature,metric_type=gauge value=21"}; !equalStrings(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
+
+// Ensure TCP connections over the limit are closed.
+func TestService_TCP_MaxConnections(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.BindAddress = "127.0.0.1:0"
+	c.Protocol = "tcp"
+	c.MaxTCPConnections = 1
+	s := NewTestService(&c)
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	first, err := net.Dial("tcp", s.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	// Wait for the first connection to be tracked.
+	timeout := time.After(5 * time.Second)
+	for s.Service.Statistics(nil)[0].Values[statTCPConnectionsActive] != int64(1) {
+		select {
+		case <-timeout:
+			t.Fatal("connection not accepted")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	second, err := net.Dial("tcp", s.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected the connection to be closed")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("connection over the limit was not closed")
+	}
+
+	if got, exp := s.Service.Statistics(nil)[0].Values[statTCPConnectionsDropped], int64(1); got != exp {
+		t.Fatalf("got %v dropped connections, expected %d", got, exp)
+	}
+}
+
+// withoutTime returns the line protocol of p without its timestamp.
+func withoutTime(p models.Point) string {
+	s := p.String()
+	return s[:strings.LastIndex(s, " ")]
+}
+
+type TestService struct {
+	Service       *Service
+	Config        Config
+	MetaClient    *internal.MetaClientMock
+	WritePointsFn func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+}
+
+func NewTestService(c *Config) *TestService {
+	if c == nil {
+		defaultC := NewConfig()
+		c = &defaultC
+	}
+
+	service := &TestService{
+		Service:    NewService(*c),
+		Config:     *c,
+		MetaClient: &internal.MetaClientMock{},
+	}
+	service.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	if testing.Verbose() {
+		service.Service.WithLogger(logger.New(os.Stderr))
+	}
+
+	service.Service.MetaClient = service.MetaClient
+	service.Service.PointsWriter = service
+	return service
+}
+
+func (s *TestService) WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	if s.WritePointsFn == nil {
+		return nil
+	}
+	return s.WritePointsFn(database, retentionPolicy, consistencyLevel, points)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -229,6 +230,43 @@ func (s *Service) Points() chan<- *coordinator.WritePointsRequest {
 	return s.points
 }
 
+// SubscriptionQueue is the queue of the writes sent to a subscription.
+// Writes are dropped while it is full.
+type SubscriptionQueue struct {
+	Database        string `json:"database"`
+	RetentionPolicy string `json:"retentionPolicy"`
+	Name            string `json:"name"`
+	Len             int    `json:"len"`
+	Cap             int    `json:"cap"`
+}
+
+// Queues returns the queues of the subscriptions.
+func (s *Service) Queues() []SubscriptionQueue {
+	s.subMu.RLock()
+	defer s.subMu.RUnlock()
+
+	queues := make([]SubscriptionQueue, 0, len(s.subs))
+	for se, cw := range s.subs {
+		queues = append(queues, SubscriptionQueue{
+			Database:        se.db,
+			RetentionPolicy: se.rp,
+			Name:            se.name,
+			Len:             len(cw.writeRequests),
+			Cap:             cap(cw.writeRequests),
+		})
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		a, b := queues[i], queues[j]
+		if a.Database != b.Database {
+			return a.Database < b.Database
+		} else if a.RetentionPolicy != b.RetentionPolicy {
+			return a.RetentionPolicy < b.RetentionPolicy
+		}
+		return a.Name < b.Name
+	})
+	return queues
+}
+
 // run read points from the points channel and writes them to the subscriptions.
 func (s *Service) run() {
 	var wg sync.WaitGroup
@@ -0,0 +1,182 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/monitor/diagnostics"
+	"github.com/influxdata/influxdb/toml"
+)
+
+const (
+	// DefaultBindAddress is the default binding interface if none is specified.
+	DefaultBindAddress = ":6514"
+
+	// DefaultProtocol is the default IP protocol used by the syslog input.
+	DefaultProtocol = "tcp"
+
+	// DefaultDatabase is the default database for syslog messages.
+	DefaultDatabase = "syslog"
+
+	// DefaultRetentionPolicy is the default retention policy used for writes.
+	DefaultRetentionPolicy = ""
+
+	// DefaultMeasurement is the default measurement messages are written to.
+	DefaultMeasurement = "syslog"
+
+	// DefaultBatchSize is the default write batch size.
+	DefaultBatchSize = 5000
+
+	// DefaultBatchPending is the default number of pending write batches.
+	DefaultBatchPending = 10
+
+	// DefaultBatchTimeout is the default syslog batch timeout.
+	DefaultBatchTimeout = time.Second
+
+	// DefaultMaxMessageSize is the default size of the largest message
+	// accepted over TCP.
+	DefaultMaxMessageSize = 64 * 1024
+
+	// DefaultCertificate is the default location of the certificate used when
+	// TLS is enabled.
+	DefaultCertificate = "/etc/ssl/influxdb.pem"
+)
+
+// Config represents the configuration of a syslog listener.
+type Config struct {
+	Enabled         bool   `toml:"enabled"`
+	BindAddress     string `toml:"bind-address"`
+	Protocol        string `toml:"protocol"`
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+	Measurement     string `toml:"measurement"`
+
+	BatchSize     int           `toml:"batch-size"`
+	BatchPending  int           `toml:"batch-pending"`
+	BatchTimeout  toml.Duration `toml:"batch-timeout"`
+	UDPReadBuffer int           `toml:"udp-read-buffer"`
+
+	// MaxMessageSize is the size of the largest message accepted over TCP.
+	// MaxConnections limits the number of concurrent TCP connections, if
+	// set, and ReadTimeout closes TCP connections on which no message is
+	// received for that long.
+	MaxMessageSize int           `toml:"max-message-size"`
+	MaxConnections int           `toml:"max-connections"`
+	ReadTimeout    toml.Duration `toml:"read-timeout"`
+
+	// TLS settings of the TCP listener. PrivateKey defaults to Certificate.
+	// If CACertificate is set, clients must present a certificate signed by
+	// one of its certificate authorities.
+	TLSEnabled    bool   `toml:"tls-enabled"`
+	Certificate   string `toml:"certificate"`
+	PrivateKey    string `toml:"private-key"`
+	CACertificate string `toml:"ca-certificate"`
+}
+
+// NewConfig returns a new instance of Config with defaults.
+func NewConfig() Config {
+	return Config{
+		BindAddress:     DefaultBindAddress,
+		Protocol:        DefaultProtocol,
+		Database:        DefaultDatabase,
+		RetentionPolicy: DefaultRetentionPolicy,
+		Measurement:     DefaultMeasurement,
+		BatchSize:       DefaultBatchSize,
+		BatchPending:    DefaultBatchPending,
+		BatchTimeout:    toml.Duration(DefaultBatchTimeout),
+		MaxMessageSize:  DefaultMaxMessageSize,
+		Certificate:     DefaultCertificate,
+	}
+}
+
+// WithDefaults takes the given config and returns a new config with any required
+// default values set.
+func (c *Config) WithDefaults() *Config {
+	d := *c
+	if d.BindAddress == "" {
+		d.BindAddress = DefaultBindAddress
+	}
+	if d.Protocol == "" {
+		d.Protocol = DefaultProtocol
+	}
+	if d.Database == "" {
+		d.Database = DefaultDatabase
+	}
+	if d.Measurement == "" {
+		d.Measurement = DefaultMeasurement
+	}
+	if d.BatchSize == 0 {
+		d.BatchSize = DefaultBatchSize
+	}
+	if d.BatchPending == 0 {
+		d.BatchPending = DefaultBatchPending
+	}
+	if d.BatchTimeout == 0 {
+		d.BatchTimeout = toml.Duration(DefaultBatchTimeout)
+	}
+	if d.MaxMessageSize == 0 {
+		d.MaxMessageSize = DefaultMaxMessageSize
+	}
+	if d.Certificate == "" {
+		d.Certificate = DefaultCertificate
+	}
+	return &d
+}
+
+// Validate returns an error if the config is invalid.
+func (c *Config) Validate() error {
+	switch strings.ToLower(c.Protocol) {
+	case "", "tcp", "udp":
+	default:
+		return fmt.Errorf(`invalid protocol %q. Valid options are "tcp" and "udp"`, c.Protocol)
+	}
+
+	if c.BatchSize < 0 || c.BatchPending < 0 {
+		return errors.New("batch-size and batch-pending must not be negative")
+	} else if c.MaxMessageSize < 0 {
+		return errors.New("max-message-size must not be negative")
+	} else if c.MaxConnections < 0 {
+		return errors.New("max-connections must not be negative")
+	} else if c.ReadTimeout < 0 {
+		return errors.New("read-timeout must not be negative")
+	}
+
+	if c.TLSEnabled && strings.ToLower(c.Protocol) == "udp" {
+		return errors.New("tls-enabled is only supported with the tcp protocol")
+	}
+	return nil
+}
+
+// Configs wraps a slice of Config to aggregate diagnostics.
+type Configs []Config
+
+// Diagnostics returns one set of diagnostics for all of the Configs.
+func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
+	d := &diagnostics.Diagnostics{
+		Columns: []string{"enabled", "bind-address", "protocol", "database", "retention-policy", "measurement", "batch-size", "batch-pending", "batch-timeout", "tls-enabled"},
+	}
+
+	for _, cc := range c {
+		if !cc.Enabled {
+			d.AddRow([]interface{}{false})
+			continue
+		}
+
+		r := []interface{}{true, cc.BindAddress, cc.Protocol, cc.Database, cc.RetentionPolicy, cc.Measurement, cc.BatchSize, cc.BatchPending, cc.BatchTimeout, cc.TLSEnabled}
+		d.AddRow(r)
+	}
+
+	return d, nil
+}
+
+// Enabled returns true if any underlying Config is Enabled.
+func (c Configs) Enabled() bool {
+	for _, cc := range c {
+		if cc.Enabled {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package syslog_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdata/influxdb/services/syslog"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	var c syslog.Config
+	if _, err := toml.Decode(`
+enabled = true
+bind-address = ":514"
+protocol = "udp"
+database = "logs"
+measurement = "messages"
+batch-size = 100
+udp-read-buffer = 1048576
+read-timeout = "5m"
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled: %v", c.Enabled)
+	} else if c.BindAddress != ":514" {
+		t.Fatalf("unexpected bind address: %s", c.BindAddress)
+	} else if c.Protocol != "udp" {
+		t.Fatalf("unexpected protocol: %s", c.Protocol)
+	} else if c.Database != "logs" {
+		t.Fatalf("unexpected database: %s", c.Database)
+	} else if c.Measurement != "messages" {
+		t.Fatalf("unexpected measurement: %s", c.Measurement)
+	} else if c.BatchSize != 100 {
+		t.Fatalf("unexpected batch size: %d", c.BatchSize)
+	} else if c.UDPReadBuffer != 1048576 {
+		t.Fatalf("unexpected udp read buffer: %d", c.UDPReadBuffer)
+	} else if time.Duration(c.ReadTimeout) != 5*time.Minute {
+		t.Fatalf("unexpected read timeout: %v", c.ReadTimeout)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, test := range []struct {
+		fn  func(c *syslog.Config)
+		err bool
+	}{
+		{fn: func(c *syslog.Config) {}},
+		{fn: func(c *syslog.Config) { c.Protocol = "UDP" }},
+		{fn: func(c *syslog.Config) { c.Protocol = "tls" }, err: true},
+		{fn: func(c *syslog.Config) { c.TLSEnabled = true }},
+		{fn: func(c *syslog.Config) { c.TLSEnabled = true; c.Protocol = "udp" }, err: true},
+		{fn: func(c *syslog.Config) { c.BatchSize = -1 }, err: true},
+		{fn: func(c *syslog.Config) { c.MaxMessageSize = -1 }, err: true},
+		{fn: func(c *syslog.Config) { c.MaxConnections = -1 }, err: true},
+		{fn: func(c *syslog.Config) { c.ReadTimeout = -1 }, err: true},
+	} {
+		c := syslog.NewConfig()
+		test.fn(&c)
+		if err := c.Validate(); test.err && err == nil {
+			t.Errorf("%+v: expected error", c)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", c, err)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// facilities are the names of the facility codes, as used by syslog
+// implementations.
+var facilities = [...]string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// severities are the names of the severity codes.
+var severities = [...]string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+// utf8BOM may start the MSG part of a message to mark it as UTF-8.
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// message is a syslog message as defined by RFC 5424. Header fields that
+// were nil in the message are empty.
+type message struct {
+	priority  int
+	version   int
+	timestamp time.Time
+	hostname  string
+	appname   string
+	procid    string
+	msgid     string
+	elements  []element
+	msg       []byte
+}
+
+// element is an element of the structured data of a message.
+type element struct {
+	id     string
+	params []param
+}
+
+// param is a parameter of a structured data element.
+type param struct {
+	name, value string
+}
+
+// point returns the point of m, written to measurement. Points of messages
+// without a timestamp are assigned the time now.
+func (m *message) point(measurement string, now time.Time) (models.Point, error) {
+	tags := map[string]string{
+		"facility": facilities[m.priority/8],
+		"severity": severities[m.priority%8],
+	}
+	if m.hostname != "" {
+		tags["hostname"] = m.hostname
+	}
+	if m.appname != "" {
+		tags["appname"] = m.appname
+	}
+	for _, e := range m.elements {
+		for _, p := range e.params {
+			if p.value != "" {
+				tags[e.id+"_"+p.name] = p.value
+			}
+		}
+	}
+
+	fields := models.Fields{
+		"facility_code": int64(m.priority / 8),
+		"severity_code": int64(m.priority % 8),
+		"version":       int64(m.version),
+	}
+	if m.procid != "" {
+		fields["procid"] = m.procid
+	}
+	if m.msgid != "" {
+		fields["msgid"] = m.msgid
+	}
+	if len(m.msg) > 0 {
+		fields["message"] = string(m.msg)
+	}
+
+	t := m.timestamp
+	if t.IsZero() {
+		t = now
+	}
+	return models.NewPoint(measurement, models.NewTags(tags), fields, t)
+}
+
+// parseMessage parses a message in the format of RFC 5424. Trailing newlines
+// are trimmed from the MSG part.
+func parseMessage(b []byte) (*message, error) {
+	p := parser{buf: b}
+	m := &message{}
+
+	// PRI and VERSION.
+	if !p.consume('<') {
+		return nil, p.errorf("expected '<'")
+	}
+	m.priority = p.number(3)
+	if m.priority < 0 || m.priority > 191 || !p.consume('>') {
+		return nil, p.errorf("invalid priority")
+	}
+	if m.version = p.number(2); m.version < 1 {
+		return nil, p.errorf("invalid version")
+	}
+
+	// TIMESTAMP, HOSTNAME, APP-NAME, PROCID and MSGID.
+	if !p.consume(' ') {
+		return nil, p.errorf("expected timestamp")
+	}
+	if ts := p.field(64); ts == "" {
+		return nil, p.errorf("expected timestamp")
+	} else if ts != "-" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return nil, p.errorf("invalid timestamp %q", ts)
+		}
+		m.timestamp = t.UTC()
+	}
+	for _, f := range []struct {
+		s      *string
+		name   string
+		maxLen int
+	}{
+		{&m.hostname, "hostname", 255},
+		{&m.appname, "app-name", 48},
+		{&m.procid, "procid", 128},
+		{&m.msgid, "msgid", 32},
+	} {
+		if !p.consume(' ') {
+			return nil, p.errorf("expected %s", f.name)
+		}
+		v := p.field(f.maxLen)
+		if v == "" {
+			return nil, p.errorf("invalid %s", f.name)
+		} else if v != "-" {
+			*f.s = v
+		}
+	}
+
+	// STRUCTURED-DATA.
+	if !p.consume(' ') {
+		return nil, p.errorf("expected structured data")
+	}
+	if !p.consume('-') {
+		if p.peek() != '[' {
+			return nil, p.errorf("expected structured data")
+		}
+		for p.peek() == '[' {
+			e, err := p.element()
+			if err != nil {
+				return nil, err
+			}
+			m.elements = append(m.elements, e)
+		}
+	}
+
+	// MSG.
+	if p.pos < len(p.buf) {
+		if !p.consume(' ') {
+			return nil, p.errorf("expected message")
+		}
+		msg := bytes.TrimPrefix(p.buf[p.pos:], utf8BOM)
+		m.msg = bytes.TrimRight(msg, "\r\n")
+	}
+	return m, nil
+}
+
+// parser reads the parts of a message.
+type parser struct {
+	buf []byte
+	pos int
+}
+
+// peek returns the next byte, or 0 at the end of the message.
+func (p *parser) peek() byte {
+	if p.pos < len(p.buf) {
+		return p.buf[p.pos]
+	}
+	return 0
+}
+
+// consume skips the next byte if it is c.
+func (p *parser) consume(c byte) bool {
+	if p.pos == len(p.buf) || p.buf[p.pos] != c {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+// number reads a decimal number of at most n digits. It returns -1 if no
+// digit is read.
+func (p *parser) number(n int) int {
+	start := p.pos
+	for p.pos < len(p.buf) && p.pos-start < n && p.buf[p.pos] >= '0' && p.buf[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return -1
+	}
+	v, _ := strconv.Atoi(string(p.buf[start:p.pos]))
+	return v
+}
+
+// field reads a header field of printable US-ASCII characters, at most
+// maxLen of them. It returns "" if the field is empty or too long.
+func (p *parser) field(maxLen int) string {
+	start := p.pos
+	for p.pos < len(p.buf) && p.buf[p.pos] >= 33 && p.buf[p.pos] <= 126 {
+		p.pos++
+	}
+	if p.pos-start > maxLen {
+		return ""
+	}
+	return string(p.buf[start:p.pos])
+}
+
+// name reads an SD-NAME: up to 32 printable US-ASCII characters, except '=',
+// ' ', ']' and '"'.
+func (p *parser) name() string {
+	start := p.pos
+	for p.pos < len(p.buf) {
+		c := p.buf[p.pos]
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			break
+		}
+		p.pos++
+	}
+	if p.pos-start > 32 {
+		return ""
+	}
+	return string(p.buf[start:p.pos])
+}
+
+// element reads a structured data element.
+func (p *parser) element() (element, error) {
+	p.consume('[')
+	e := element{id: p.name()}
+	if e.id == "" {
+		return element{}, p.errorf("invalid SD-ID")
+	}
+
+	for !p.consume(']') {
+		if !p.consume(' ') {
+			return element{}, p.errorf("expected SD-PARAM in %s", e.id)
+		}
+		name := p.name()
+		if name == "" {
+			return element{}, p.errorf("invalid PARAM-NAME in %s", e.id)
+		} else if !p.consume('=') || !p.consume('"') {
+			return element{}, p.errorf("expected PARAM-VALUE of %s in %s", name, e.id)
+		}
+
+		value, ok := p.value()
+		if !ok {
+			return element{}, p.errorf("unterminated PARAM-VALUE of %s in %s", name, e.id)
+		}
+		e.params = append(e.params, param{name: name, value: value})
+	}
+	return e, nil
+}
+
+// value reads a PARAM-VALUE up to its closing quote, which is skipped. The
+// escaped characters '"', '\' and ']' are unescaped, and other backslashes
+// are kept.
+func (p *parser) value() (string, bool) {
+	var v []byte
+	for p.pos < len(p.buf) {
+		c := p.buf[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return string(v), true
+		case '\\':
+			if n := p.peek(); n == '"' || n == '\\' || n == ']' {
+				c = n
+				p.pos++
+			}
+		}
+		v = append(v, c)
+	}
+	return "", false
+}
+
+// errorf returns an error at the current position.
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("syslog: "+format+" at offset %d", append(a, p.pos)...)
+}
@@ -0,0 +1,126 @@
+package syslog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMessage(t *testing.T) {
+	for _, test := range []struct {
+		s   string
+		exp message
+	}{
+		// Examples of RFC 5424.
+		{
+			s: "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - \xEF\xBB\xBF'su root' failed for lonvick on /dev/pts/8",
+			exp: message{
+				priority:  34,
+				version:   1,
+				timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+				hostname:  "mymachine.example.com",
+				appname:   "su",
+				msgid:     "ID47",
+				msg:       []byte("'su root' failed for lonvick on /dev/pts/8"),
+			},
+		},
+		{
+			s: "<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - %% It's time to make the do-nuts.\n",
+			exp: message{
+				priority:  165,
+				version:   1,
+				timestamp: time.Date(2003, 8, 24, 12, 14, 15, 3000, time.UTC),
+				hostname:  "192.0.2.1",
+				appname:   "myproc",
+				procid:    "8710",
+				msg:       []byte("%% It's time to make the do-nuts."),
+			},
+		},
+		{
+			s: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"]`,
+			exp: message{
+				priority:  165,
+				version:   1,
+				timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+				hostname:  "mymachine.example.com",
+				appname:   "evntslog",
+				msgid:     "ID47",
+				elements: []element{
+					{id: "exampleSDID@32473", params: []param{{"iut", "3"}, {"eventSource", "Application"}, {"eventID", "1011"}}},
+					{id: "examplePriority@32473", params: []param{{"class", "high"}}},
+				},
+			},
+		},
+		// Nil header fields and escaped parameter values.
+		{
+			s: `<0>1 - - - - - [meta][origin ip="192.0.2.1" x="a\"b\\c\]d\e"] hello`,
+			exp: message{
+				priority: 0,
+				version:  1,
+				elements: []element{
+					{id: "meta"},
+					{id: "origin", params: []param{{"ip", "192.0.2.1"}, {"x", `a"b\c]d\e`}}},
+				},
+				msg: []byte("hello"),
+			},
+		},
+	} {
+		m, err := parseMessage([]byte(test.s))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.s, err)
+		} else if !reflect.DeepEqual(*m, test.exp) {
+			t.Errorf("%q: got %+v, expected %+v", test.s, *m, test.exp)
+		}
+	}
+}
+
+func TestParseMessage_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"<34> 1 - - - - - -",
+		"<192>1 - - - - - -",
+		"<34>0 - - - - - -",
+		"<34>1 2003-10-11 - - - - -",
+		"<34>1 - - - - -",
+		"<34>1 - - - - - -hello",
+		"<34>1 - - - - - [id",
+		`<34>1 - - - - - [id x=3]`,
+		`<34>1 - - - - - [id x="3]`,
+		`<34>1 - - - - - [id x="3"`,
+		`<34>1 - - - - - [ x="3"]`,
+		"<34>1 - - " + strings.Repeat("a", 49) + " - - -",
+	} {
+		if _, err := parseMessage([]byte(s)); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestMessage_Point(t *testing.T) {
+	m, err := parseMessage([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine su 1234 ID47 [origin ip="192.0.2.1" software=""] failed`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pt, err := m.point("logs", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `logs,appname=su,facility=local4,hostname=mymachine,origin_ip=192.0.2.1,severity=notice facility_code=20i,message="failed",msgid="ID47",procid="1234",severity_code=5i,version=1i 1065910455003000000`
+	if got := pt.String(); got != exp {
+		t.Fatalf("got %s, expected %s", got, exp)
+	}
+
+	// Messages without a timestamp are assigned the time they are received.
+	m, err = parseMessage([]byte("<0>1 - - - - - -"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(10, 0)
+	if pt, err = m.point("logs", now); err != nil {
+		t.Fatal(err)
+	} else if got, exp := pt.String(), "logs,facility=kern,severity=emerg facility_code=0i,severity_code=0i,version=1i 10000000000"; got != exp {
+		t.Fatalf("got %s, expected %s", got, exp)
+	}
+}
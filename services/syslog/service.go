@@ -0,0 +1,546 @@
+// Package syslog provides a service for InfluxDB to ingest syslog messages.
+package syslog // import "github.com/influxdata/influxdb/services/syslog"
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/tsdb"
+	"go.uber.org/zap"
+)
+
+// udpBufferSize is the size of the largest UDP datagram.
+const udpBufferSize = 65536
+
+// statistics gathered by the syslog package.
+const (
+	statMessagesReceived    = "messagesRx"
+	statBytesReceived       = "bytesRx"
+	statMessagesParseFail   = "messagesParseFail"
+	statBatchesTransmitted  = "batchesTx"
+	statPointsTransmitted   = "pointsTx"
+	statBatchesTransmitFail = "batchesTxFail"
+	statConnectionsActive   = "connsActive"
+	statConnectionsHandled  = "connsHandled"
+	statConnectionsRejected = "connsRejected"
+)
+
+// Service is a syslog listener. It receives messages in the format of RFC
+// 5424 over UDP, TCP or TLS, and writes a point for every message.
+type Service struct {
+	wg sync.WaitGroup
+
+	mu    sync.RWMutex
+	ready bool          // Has the required database been created?
+	done  chan struct{} // Is the service closing or closed?
+
+	config  Config
+	batcher *tsdb.PointBatcher
+
+	ln      net.Listener
+	udpConn *net.UDPConn
+	addr    net.Addr
+
+	connsMu   sync.Mutex
+	conns     map[net.Conn]struct{}
+	connSlots chan struct{} // Limits concurrent TCP connections, if set.
+
+	PointsWriter interface {
+		WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	MetaClient interface {
+		CreateDatabase(name string) (*meta.DatabaseInfo, error)
+	}
+
+	Logger      *zap.Logger
+	stats       *Statistics
+	defaultTags models.StatisticTags
+}
+
+// NewService returns a new instance of Service.
+func NewService(c Config) *Service {
+	d := *c.WithDefaults()
+	s := &Service{
+		config:      d,
+		conns:       make(map[net.Conn]struct{}),
+		Logger:      zap.NewNop(),
+		stats:       &Statistics{},
+		defaultTags: models.StatisticTags{"proto": d.Protocol, "bind": d.BindAddress},
+	}
+	if d.MaxConnections > 0 {
+		s.connSlots = make(chan struct{}, d.MaxConnections)
+	}
+	return s
+}
+
+// Open starts the service.
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed() {
+		return nil // Already open.
+	}
+
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+
+	s.done = make(chan struct{})
+
+	s.batcher = tsdb.NewPointBatcher(s.config.BatchSize, s.config.BatchPending, time.Duration(s.config.BatchTimeout))
+	s.batcher.Start()
+
+	s.wg.Add(1)
+	go s.processBatches()
+
+	var err error
+	if strings.ToLower(s.config.Protocol) == "udp" {
+		s.addr, err = s.openUDPServer()
+	} else {
+		s.addr, err = s.openTCPServer()
+	}
+	if err != nil {
+		close(s.done)
+		s.batcher.Stop()
+		s.wg.Wait()
+		s.done = nil
+		return err
+	}
+
+	s.Logger.Info("Listening",
+		zap.String("protocol", s.config.Protocol),
+		zap.Stringer("addr", s.addr),
+		zap.Bool("tls", s.config.TLSEnabled))
+	return nil
+}
+
+// openTCPServer opens the TCP listener and starts accepting connections.
+func (s *Service) openTCPServer() (net.Addr, error) {
+	var (
+		ln  net.Listener
+		err error
+	)
+	if s.config.TLSEnabled {
+		var config *tls.Config
+		if config, err = s.tlsConfig(); err == nil {
+			ln, err = tls.Listen("tcp", s.config.BindAddress, config)
+		}
+	} else {
+		ln, err = net.Listen("tcp", s.config.BindAddress)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.ln = ln
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if opErr, ok := err.(*net.OpError); ok && !opErr.Temporary() {
+				s.Logger.Info("Syslog TCP listener closed")
+				return
+			}
+			if err != nil {
+				s.Logger.Info("Error accepting TCP connection", zap.Error(err))
+				continue
+			}
+
+			if s.connSlots != nil {
+				select {
+				case s.connSlots <- struct{}{}:
+				default:
+					atomic.AddInt64(&s.stats.RejectedConnections, 1)
+					s.Logger.Info("Rejected TCP connection, too many open connections",
+						zap.Stringer("remote_addr", conn.RemoteAddr()))
+					conn.Close()
+					continue
+				}
+			}
+
+			s.wg.Add(1)
+			go s.handleTCPConnection(conn)
+		}
+	}()
+	return ln.Addr(), nil
+}
+
+// tlsConfig returns the TLS configuration of the TCP listener.
+func (s *Service) tlsConfig() (*tls.Config, error) {
+	key := s.config.PrivateKey
+	if key == "" {
+		key = s.config.Certificate
+	}
+	cert, err := tls.LoadX509KeyPair(s.config.Certificate, key)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if s.config.CACertificate != "" {
+		pem, err := ioutil.ReadFile(s.config.CACertificate)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", s.config.CACertificate)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// handleTCPConnection reads the messages of a TCP connection until it is
+// closed or its framing is invalid.
+func (s *Service) handleTCPConnection(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+	if s.connSlots != nil {
+		defer func() { <-s.connSlots }()
+	}
+	defer atomic.AddInt64(&s.stats.ActiveConnections, -1)
+	atomic.AddInt64(&s.stats.ActiveConnections, 1)
+	atomic.AddInt64(&s.stats.HandledConnections, 1)
+
+	if !s.trackConnection(conn) {
+		return
+	}
+	defer s.untrackConnection(conn)
+
+	r := bufio.NewReader(conn)
+	for {
+		if s.config.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(time.Duration(s.config.ReadTimeout)))
+		}
+
+		buf, err := readFrame(r, s.config.MaxMessageSize)
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			s.Logger.Info("Closing TCP connection",
+				zap.Stringer("remote_addr", conn.RemoteAddr()), zap.Error(err))
+			return
+		}
+		s.handleMessage(buf)
+	}
+}
+
+// readFrame reads a message framed as described by RFC 6587. Messages are
+// either preceded by their length in octets, as RFC 5425 requires for TLS,
+// or terminated by a newline. The framing is detected for every message.
+func readFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		// Octet counting.
+		if b[0] >= '0' && b[0] <= '9' {
+			s, err := r.ReadSlice(' ')
+			if err != nil || len(s) > 11 {
+				return nil, errors.New("invalid message length")
+			}
+			n, err := strconv.Atoi(string(s[:len(s)-1]))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid message length %q", s[:len(s)-1])
+			} else if n > maxSize {
+				return nil, fmt.Errorf("message of %d bytes exceeds max-message-size", n)
+			}
+
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, err
+			}
+			return buf, nil
+		}
+
+		// Non-transparent framing.
+		var buf []byte
+		for {
+			line, err := r.ReadSlice('\n')
+			buf = append(buf, line...)
+			if len(buf) > maxSize+1 {
+				return nil, errors.New("message exceeds max-message-size")
+			} else if err == nil {
+				buf = buf[:len(buf)-1]
+				break
+			} else if err == io.EOF && len(buf) > 0 {
+				break // The last message may not be terminated.
+			} else if err != bufio.ErrBufferFull {
+				return nil, err
+			}
+		}
+		if len(buf) > 0 && buf[len(buf)-1] == '\r' {
+			buf = buf[:len(buf)-1]
+		}
+		if len(buf) > 0 {
+			return buf, nil
+		}
+		// Skip empty lines.
+	}
+}
+
+// trackConnection registers conn so it is closed along with the service. It
+// returns false if the service is closing.
+func (s *Service) trackConnection(conn net.Conn) bool {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	if s.conns == nil {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Service) untrackConnection(conn net.Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	delete(s.conns, conn)
+}
+
+// openUDPServer opens the UDP listener and starts reading messages, one per
+// datagram.
+func (s *Service) openUDPServer() (net.Addr, error) {
+	addr, err := net.ResolveUDPAddr("udp", s.config.BindAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s.udpConn, err = net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.config.UDPReadBuffer != 0 {
+		if err := s.udpConn.SetReadBuffer(s.config.UDPReadBuffer); err != nil {
+			s.udpConn.Close()
+			return nil, fmt.Errorf("unable to set UDP read buffer to %d: %s",
+				s.config.UDPReadBuffer, err)
+		}
+	}
+
+	conn := s.udpConn
+	buf := make([]byte, udpBufferSize)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			n, _, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			if n > 0 {
+				s.handleMessage(buf[:n])
+			}
+		}
+	}()
+	return s.udpConn.LocalAddr(), nil
+}
+
+// handleMessage parses a message and hands its point to the batcher.
+func (s *Service) handleMessage(buf []byte) {
+	atomic.AddInt64(&s.stats.MessagesReceived, 1)
+	atomic.AddInt64(&s.stats.BytesReceived, int64(len(buf)))
+
+	m, err := parseMessage(buf)
+	if err != nil {
+		atomic.AddInt64(&s.stats.MessagesParseFail, 1)
+		s.Logger.Info("Unable to parse message", zap.Error(err))
+		return
+	}
+
+	pt, err := m.point(s.config.Measurement, time.Now().UTC())
+	if err != nil {
+		atomic.AddInt64(&s.stats.MessagesParseFail, 1)
+		s.Logger.Info("Unable to create point", zap.Error(err))
+		return
+	}
+
+	select {
+	case s.batcher.In() <- pt:
+	case <-s.done:
+	}
+}
+
+// processBatches writes the batches of the batcher until the service is
+// closed.
+func (s *Service) processBatches() {
+	defer s.wg.Done()
+	for {
+		select {
+		case batch := <-s.batcher.Out():
+			// Will attempt to create database if not yet created.
+			if err := s.createInternalStorage(); err != nil {
+				s.Logger.Info("Required database not yet created",
+					logger.Database(s.config.Database), zap.Error(err))
+				atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+				continue
+			}
+
+			if err := s.PointsWriter.WritePointsPrivileged(s.config.Database, s.config.RetentionPolicy, models.ConsistencyLevelAny, batch); err == nil {
+				atomic.AddInt64(&s.stats.BatchesTransmitted, 1)
+				atomic.AddInt64(&s.stats.PointsTransmitted, int64(len(batch)))
+			} else {
+				s.Logger.Info("Failed to write point batch to database",
+					logger.Database(s.config.Database), zap.Error(err))
+				atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+			}
+
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Statistics maintains statistics for the syslog service.
+type Statistics struct {
+	MessagesReceived    int64
+	BytesReceived       int64
+	MessagesParseFail   int64
+	BatchesTransmitted  int64
+	PointsTransmitted   int64
+	BatchesTransmitFail int64
+	ActiveConnections   int64
+	HandledConnections  int64
+	RejectedConnections int64
+}
+
+// Statistics returns statistics for periodic monitoring.
+func (s *Service) Statistics(tags map[string]string) []models.Statistic {
+	return []models.Statistic{{
+		Name: "syslog",
+		Tags: s.defaultTags.Merge(tags),
+		Values: map[string]interface{}{
+			statMessagesReceived:    atomic.LoadInt64(&s.stats.MessagesReceived),
+			statBytesReceived:       atomic.LoadInt64(&s.stats.BytesReceived),
+			statMessagesParseFail:   atomic.LoadInt64(&s.stats.MessagesParseFail),
+			statBatchesTransmitted:  atomic.LoadInt64(&s.stats.BatchesTransmitted),
+			statPointsTransmitted:   atomic.LoadInt64(&s.stats.PointsTransmitted),
+			statBatchesTransmitFail: atomic.LoadInt64(&s.stats.BatchesTransmitFail),
+			statConnectionsActive:   atomic.LoadInt64(&s.stats.ActiveConnections),
+			statConnectionsHandled:  atomic.LoadInt64(&s.stats.HandledConnections),
+			statConnectionsRejected: atomic.LoadInt64(&s.stats.RejectedConnections),
+		},
+	}}
+}
+
+// Addr returns the address the service listens on.
+func (s *Service) Addr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.addr
+}
+
+// Close stops the listener, closes the open connections and stops writing
+// points. Points still batched are discarded.
+func (s *Service) Close() error {
+	if wait := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.closed() {
+			return false // Already closed.
+		}
+		close(s.done)
+
+		if s.ln != nil {
+			s.ln.Close()
+		}
+		if s.udpConn != nil {
+			s.udpConn.Close()
+		}
+
+		s.connsMu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.conns = nil
+		s.connsMu.Unlock()
+
+		s.batcher.Stop()
+		return true
+	}(); !wait {
+		return nil
+	}
+	s.wg.Wait()
+
+	// Release all remaining resources.
+	s.mu.Lock()
+	s.done = nil
+	s.ln, s.udpConn = nil, nil
+	s.mu.Unlock()
+
+	s.connsMu.Lock()
+	s.conns = make(map[net.Conn]struct{})
+	s.connsMu.Unlock()
+
+	s.Logger.Info("Service closed")
+
+	return nil
+}
+
+// Closed returns true if the service is currently closed.
+func (s *Service) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed()
+}
+
+func (s *Service) closed() bool {
+	select {
+	case <-s.done:
+		// Service is closing.
+		return true
+	default:
+	}
+	return s.done == nil
+}
+
+// createInternalStorage ensures that the required database has been created.
+func (s *Service) createInternalStorage() error {
+	s.mu.RLock()
+	ready := s.ready
+	s.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if _, err := s.MetaClient.CreateDatabase(s.config.Database); err != nil {
+		return err
+	}
+
+	// The service is now ready.
+	s.mu.Lock()
+	s.ready = true
+	s.mu.Unlock()
+	return nil
+}
+
+// WithLogger sets the logger on the service.
+func (s *Service) WithLogger(log *zap.Logger) {
+	s.Logger = log.With(
+		zap.String("service", "syslog"),
+		zap.String("addr", s.config.BindAddress),
+	)
+}
@@ -0,0 +1,232 @@
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/toml"
+)
+
+// Ensure messages received over TCP are written with both framings.
+func TestService_TCP(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.BindAddress = "127.0.0.1:0"
+	c.Database = "logs"
+	c.BatchSize = 3
+	s := NewTestService(&c)
+
+	written := make(chan []models.Point, 1)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		if database != "logs" {
+			t.Errorf("unexpected database: %s", database)
+		}
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn, err := net.Dial("tcp", s.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	msg := "<34>1 2003-10-11T22:14:15.003Z host1 su - - - message 1"
+	if _, err := conn.Write([]byte(fmt.Sprintf("%d %s", len(msg), msg) +
+		"<34>1 2003-10-11T22:14:15.004Z host1 su - - - message 2\r\n\n" +
+		"invalid\n" +
+		"<34>1 2003-10-11T22:14:15.005Z host1 su - - - message 3\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case points := <-written:
+		if len(points) != 3 {
+			t.Fatalf("got %d points, expected 3", len(points))
+		}
+		for i, pt := range points {
+			if fields, err := pt.Fields(); err != nil {
+				t.Fatal(err)
+			} else if got, exp := fields["message"], fmt.Sprintf("message %d", i+1); got != exp {
+				t.Fatalf("got message %q, expected %q", got, exp)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("points not written")
+	}
+
+	if got, exp := s.Service.Statistics(nil)[0].Values[statMessagesParseFail], int64(1); got != exp {
+		t.Fatalf("got %v parse failures, expected %d", got, exp)
+	}
+}
+
+// Ensure connections are closed when a message exceeds max-message-size.
+func TestService_TCP_MaxMessageSize(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.BindAddress = "127.0.0.1:0"
+	c.MaxMessageSize = 64
+	s := NewTestService(&c)
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	for _, data := range []string{
+		"65 " + strings.Repeat("a", 65),
+		strings.Repeat("a", 65) + "\n",
+	} {
+		conn, err := net.Dial("tcp", s.Service.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Write([]byte(data))
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := bufio.NewReader(conn).ReadByte(); err != io.EOF {
+			t.Fatalf("%q: connection not closed: %v", data, err)
+		}
+		conn.Close()
+	}
+}
+
+// Ensure messages received over UDP are written, one per datagram.
+func TestService_UDP(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.BindAddress = "127.0.0.1:0"
+	c.Protocol = "udp"
+	c.BatchSize = 2
+	c.Measurement = "logs"
+	s := NewTestService(&c)
+
+	written := make(chan []models.Point, 1)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn, err := net.Dial("udp", s.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, msg := range []string{
+		`<165>1 2003-10-11T22:14:15.003Z host1 app - - [origin ip="192.0.2.1"] first`,
+		"<13>1 2003-10-11T22:14:15.004Z host2 app - - - second\nline\n",
+	} {
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case points := <-written:
+		if len(points) != 2 {
+			t.Fatalf("got %d points, expected 2", len(points))
+		}
+		if got, exp := points[0].String(), `logs,appname=app,facility=local4,hostname=host1,origin_ip=192.0.2.1,severity=notice facility_code=20i,message="first",severity_code=5i,version=1i 1065910455003000000`; got != exp {
+			t.Fatalf("got %s, expected %s", got, exp)
+		} else if got, exp := points[1].String(), "logs,appname=app,facility=user,hostname=host2,severity=notice facility_code=1i,message=\"second\nline\",severity_code=5i,version=1i 1065910455004000000"; got != exp {
+			t.Fatalf("got %s, expected %s", got, exp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("points not written")
+	}
+}
+
+// Ensure the service can be closed with open connections, and reopened.
+func TestService_OpenClose(t *testing.T) {
+	c := NewConfig()
+	c.BindAddress = "127.0.0.1:0"
+	c.ReadTimeout = toml.Duration(time.Minute)
+	s := NewTestService(&c)
+
+	// Closing a closed service is fine.
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Opening an already open service is fine.
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := net.Dial("tcp", s.Service.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+}
+
+type TestService struct {
+	Service       *Service
+	Config        Config
+	MetaClient    *internal.MetaClientMock
+	WritePointsFn func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+}
+
+func NewTestService(c *Config) *TestService {
+	if c == nil {
+		defaultC := NewConfig()
+		c = &defaultC
+	}
+
+	service := &TestService{
+		Service:    NewService(*c),
+		Config:     *c,
+		MetaClient: &internal.MetaClientMock{},
+	}
+	service.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	if testing.Verbose() {
+		service.Service.WithLogger(logger.New(os.Stderr))
+	}
+
+	service.Service.MetaClient = service.MetaClient
+	service.Service.PointsWriter = service
+	return service
+}
+
+func (s *TestService) WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	if s.WritePointsFn == nil {
+		return nil
+	}
+	return s.WritePointsFn(database, retentionPolicy, consistencyLevel, points)
+}
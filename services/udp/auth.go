@@ -0,0 +1,29 @@
+package udp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+// signatureSize is the size of the HMAC-SHA256 signature prefixed to each
+// datagram when a shared secret is configured.
+const signatureSize = sha256.Size
+
+var errInvalidSignature = errors.New("invalid packet signature")
+
+// verifySignature checks the HMAC-SHA256 signature at the start of buf
+// against the remainder of buf, and returns that remainder.
+func verifySignature(secret, buf []byte) ([]byte, error) {
+	if len(buf) < signatureSize {
+		return nil, errInvalidSignature
+	}
+	sig, payload := buf[:signatureSize], buf[signatureSize:]
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errInvalidSignature
+	}
+	return payload, nil
+}
@@ -0,0 +1,107 @@
+package udp
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// packetCapture records raw datagrams to a file for debugging. Each datagram
+// is written as a single line holding the time it was captured, its source,
+// the error it was rejected with, or "ok", and its payload as a quoted Go
+// string. Once the file would grow beyond maxSize, it is renamed by appending
+// ".1" to its name, replacing any previous rotated file, and a new file is
+// started.
+type packetCapture struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	limit   int
+	f       *os.File
+	size    int64
+	n       int
+}
+
+// openPacketCapture opens the capture file at path, appending to it if it
+// exists. At most limit datagrams are captured, unless limit is 0.
+func openPacketCapture(path string, maxSize int64, limit int) (*packetCapture, error) {
+	c := &packetCapture{path: path, maxSize: maxSize, limit: limit}
+	if err := c.open(os.O_APPEND); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// open opens the capture file with the given additional flag.
+func (c *packetCapture) open(flag int) error {
+	f, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|flag, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	c.f, c.size = f, fi.Size()
+	return nil
+}
+
+// capture records pkt, rejected with err, or accepted if err is nil. It
+// returns false without recording pkt once the capture limit is reached.
+func (c *packetCapture) capture(pkt packet, err error) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.f == nil || (c.limit > 0 && c.n >= c.limit) {
+		return false, nil
+	}
+
+	reason := "ok"
+	if err != nil {
+		reason = err.Error()
+	}
+	line := fmt.Sprintf("%s %s %s %s\n",
+		time.Now().UTC().Format(time.RFC3339Nano), pkt.source, strconv.Quote(reason), strconv.Quote(string(pkt.buf)))
+
+	if c.size > 0 && c.size+int64(len(line)) > c.maxSize {
+		if err := c.rotate(); err != nil {
+			return false, err
+		}
+	}
+
+	n, err := c.f.WriteString(line)
+	c.size += int64(n)
+	if err != nil {
+		return false, err
+	}
+	c.n++
+	return true, nil
+}
+
+// rotate moves the current capture file aside and starts a new one.
+func (c *packetCapture) rotate() error {
+	if err := c.f.Close(); err != nil {
+		return err
+	}
+	c.f = nil
+	if err := os.Rename(c.path, c.path+".1"); err != nil {
+		return err
+	}
+	return c.open(os.O_TRUNC)
+}
+
+// Close closes the capture file.
+func (c *packetCapture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.f == nil {
+		return nil
+	}
+	err := c.f.Close()
+	c.f = nil
+	return err
+}
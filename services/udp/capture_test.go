@@ -0,0 +1,62 @@
+package udp
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPacketCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "udp-capture-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "capture.log")
+
+	c, err := openPacketCapture(path, 150, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i, pkt := range []packet{
+		{buf: []byte("cpu value=\n"), source: "10.0.0.1"},
+		{buf: []byte{0x1f, 0x8b, 0x00}, source: "10.0.0.2"},
+		{buf: []byte("mem value=1\n"), source: "10.0.0.3"},
+		{buf: []byte("disk value=1\n"), source: "10.0.0.4"},
+	} {
+		ok, err := c.capture(pkt, errors.New("bad packet"))
+		if err != nil {
+			t.Fatal(err)
+		} else if exp := i < 3; ok != exp {
+			t.Fatalf("packet %d: got captured %v, expected %v", i, ok, exp)
+		}
+	}
+
+	// The third packet did not fit and rotated the file.
+	rotated, err := ioutil.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(rotated), "\n"), "\n")
+	if got, exp := len(lines), 2; got != exp {
+		t.Fatalf("got %d lines in rotated file, expected %d", got, exp)
+	} else if !strings.HasSuffix(lines[0], ` 10.0.0.1 "bad packet" "cpu value=\n"`) {
+		t.Fatalf("unexpected line: %s", lines[0])
+	} else if !strings.HasSuffix(lines[1], ` 10.0.0.2 "bad packet" "\x1f\x8b\x00"`) {
+		t.Fatalf("unexpected line: %s", lines[1])
+	}
+
+	if !strings.HasSuffix(string(current), ` 10.0.0.3 "bad packet" "mem value=1\n"`+"\n") {
+		t.Fatalf("unexpected capture file: %s", current)
+	}
+}
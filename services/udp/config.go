@@ -1,8 +1,13 @@
 package udp
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/monitor/diagnostics"
 	"github.com/influxdata/influxdb/toml"
 )
@@ -41,6 +46,106 @@ const (
 	//     Linux:      sudo sysctl -w net.core.rmem_max=<read-buffer>
 	//     BSD/Darwin: sudo sysctl -w kern.ipc.maxsockbuf=<read-buffer>
 	DefaultReadBuffer = 0
+
+	// DefaultUDPPayloadSize is the default size of the largest datagram the
+	// UDP listener accepts. It matches the maximum size of a UDP datagram.
+	DefaultUDPPayloadSize = MaxUDPPayload
+
+	// MaxUDPPayloadSize is the largest udp-payload-size that may be
+	// configured. Datagrams this large can only be delivered over unix
+	// datagram sockets or, as IPv6 jumbograms, on suitable networks.
+	MaxUDPPayloadSize = 16 * 1024 * 1024
+
+	// DefaultConsistencyLevel is the default write consistency level.
+	DefaultConsistencyLevel = "any"
+
+	// DefaultWriteRetries is the default number of times a failed write is
+	// retried.
+	DefaultWriteRetries = 0
+
+	// DefaultWriteRetryBackoff is the default delay before the first retry
+	// of a failed write. The delay doubles with every further retry.
+	DefaultWriteRetryBackoff = 100 * time.Millisecond
+
+	// DefaultCaptureMode is the default selection of captured datagrams.
+	DefaultCaptureMode = CaptureRejected
+
+	// DefaultCaptureLimit is the default number of datagrams captured.
+	DefaultCaptureLimit = 1000
+
+	// DefaultCaptureMaxSize is the default size at which the capture file is
+	// rotated.
+	DefaultCaptureMaxSize = 10 * 1024 * 1024
+
+	// DefaultSpillMaxSize is the default maximum size of the spill queue.
+	DefaultSpillMaxSize = 1024 * 1024 * 1024
+
+	// DefaultSpillReplayInterval is the default interval between attempts
+	// to replay spilled batches.
+	DefaultSpillReplayInterval = 10 * time.Second
+
+	// DefaultFormat is the default encoding of UDP payloads.
+	DefaultFormat = FormatLineProtocol
+
+	// DefaultCompression is the default compression of UDP payloads.
+	DefaultCompression = CompressionNone
+
+	// DefaultOverflowPolicy is the default policy applied when the UDP
+	// listener receives data faster than it can be parsed and batched.
+	DefaultOverflowPolicy = OverflowBlock
+)
+
+// Supported values for the overflow-policy setting.
+const (
+	// OverflowBlock stops reading from the socket until there is room for
+	// the incoming data. Datagrams are then dropped by the operating system
+	// once its receive buffer is full, without being accounted for.
+	OverflowBlock = "block"
+
+	// OverflowDropNewest discards incoming data when the queue is full.
+	OverflowDropNewest = "drop-newest"
+
+	// OverflowDropOldest discards the oldest queued datagram to make room for
+	// an incoming one. Points that do not fit into the batcher are discarded.
+	OverflowDropOldest = "drop-oldest"
+)
+
+// Supported values for the format setting.
+const (
+	// FormatLineProtocol decodes payloads as line protocol.
+	FormatLineProtocol = "line"
+
+	// FormatJSON decodes payloads as a JSON point object or an array of them.
+	FormatJSON = "json"
+
+	// FormatProtobuf decodes payloads as the Points message in points.proto.
+	FormatProtobuf = "protobuf"
+)
+
+// Supported values for the compression setting.
+const (
+	// CompressionNone treats every payload as uncompressed line protocol.
+	CompressionNone = "none"
+
+	// CompressionAuto decompresses gzip and framed snappy payloads, detected
+	// by their magic bytes, and treats all other payloads as uncompressed.
+	CompressionAuto = "auto"
+
+	// CompressionGzip expects every payload to be gzip compressed.
+	CompressionGzip = "gzip"
+
+	// CompressionSnappy expects every payload to be snappy compressed, using
+	// either the block or the framed format.
+	CompressionSnappy = "snappy"
+)
+
+const (
+	// CaptureRejected captures only datagrams that are rejected, for example
+	// because they fail to authenticate, decompress or parse.
+	CaptureRejected = "rejected"
+
+	// CaptureAll captures every datagram.
+	CaptureAll = "all"
 )
 
 // Config holds various configuration settings for the UDP listener.
@@ -48,6 +153,10 @@ type Config struct {
 	Enabled     bool   `toml:"enabled"`
 	BindAddress string `toml:"bind-address"`
 
+	// BindAddresses lists further addresses to listen on. Datagrams received
+	// on any address share the batcher, statistics and destination.
+	BindAddresses []string `toml:"bind-addresses"`
+
 	Database        string        `toml:"database"`
 	RetentionPolicy string        `toml:"retention-policy"`
 	BatchSize       int           `toml:"batch-size"`
@@ -55,6 +164,67 @@ type Config struct {
 	ReadBuffer      int           `toml:"read-buffer"`
 	BatchTimeout    toml.Duration `toml:"batch-timeout"`
 	Precision       string        `toml:"precision"`
+
+	ConsistencyLevel  string        `toml:"consistency-level"`
+	WriteRetries      int           `toml:"write-retries"`
+	WriteRetryBackoff toml.Duration `toml:"write-retry-backoff"`
+
+	UDPPayloadSize int    `toml:"udp-payload-size"`
+	Format         string `toml:"format"`
+	Compression    string `toml:"compression"`
+
+	// MulticastInterface names the network interface used to join the
+	// multicast group when BindAddress is a multicast address.
+	MulticastInterface string `toml:"multicast-interface"`
+
+	// SharedSecret, if set, requires every datagram to be prefixed with the
+	// HMAC-SHA256 signature of the rest of the datagram, keyed by the secret.
+	// Datagrams without a valid signature are rejected.
+	SharedSecret   string `toml:"shared-secret"`
+	OverflowPolicy string `toml:"overflow-policy"`
+
+	// DatabaseTag and RetentionPolicyTag name tags whose values select the
+	// destination of a point. The routing tags are removed from the point
+	// before it is written. Points without a routing tag are written to the
+	// configured Database and RetentionPolicy.
+	DatabaseTag        string `toml:"database-tag"`
+	RetentionPolicyTag string `toml:"retention-policy-tag"`
+
+	// RoutedDatabases lists regular expressions matching the databases that
+	// points may be routed to by DatabaseTag. A pattern must match the whole
+	// database name. If empty, points may be routed to any existing database.
+	RoutedDatabases []string `toml:"routed-databases"`
+
+	// CreateRoutedDatabases creates the routed databases that do not exist
+	// yet, like the configured Database. It requires RoutedDatabases.
+	CreateRoutedDatabases bool `toml:"create-routed-databases"`
+
+	// SpillDir, if set, enables a disk-backed queue in that directory for
+	// batches that could not be written. Spilled batches are replayed every
+	// SpillReplayInterval until they are written.
+	SpillDir            string        `toml:"spill-dir"`
+	SpillMaxSize        toml.Size     `toml:"spill-max-size"`
+	SpillReplayInterval toml.Duration `toml:"spill-replay-interval"`
+
+	// CaptureFile, if set, enables capturing raw datagrams to that file to
+	// help diagnose misbehaving senders. CaptureMode selects the captured
+	// datagrams, and at most CaptureLimit of them are captured. The file is
+	// rotated once it reaches CaptureMaxSize.
+	CaptureFile    string    `toml:"capture-file"`
+	CaptureMode    string    `toml:"capture-mode"`
+	CaptureLimit   int       `toml:"capture-limit"`
+	CaptureMaxSize toml.Size `toml:"capture-max-size"`
+
+	// RetentionPolicyMappings select the retention policy of points by
+	// measurement name. The first mapping whose pattern matches is used.
+	RetentionPolicyMappings []RetentionPolicyMapping `toml:"retention-policy-mapping"`
+}
+
+// RetentionPolicyMapping writes points whose measurement name matches the
+// regular expression Measurement into RetentionPolicy.
+type RetentionPolicyMapping struct {
+	Measurement     string `toml:"measurement"`
+	RetentionPolicy string `toml:"retention-policy"`
 }
 
 // NewConfig returns a new instance of Config with defaults.
@@ -66,6 +236,21 @@ func NewConfig() Config {
 		BatchSize:       DefaultBatchSize,
 		BatchPending:    DefaultBatchPending,
 		BatchTimeout:    toml.Duration(DefaultBatchTimeout),
+		UDPPayloadSize:  DefaultUDPPayloadSize,
+		Format:          DefaultFormat,
+		Compression:     DefaultCompression,
+		OverflowPolicy:  DefaultOverflowPolicy,
+
+		ConsistencyLevel:  DefaultConsistencyLevel,
+		WriteRetries:      DefaultWriteRetries,
+		WriteRetryBackoff: toml.Duration(DefaultWriteRetryBackoff),
+
+		SpillMaxSize:        toml.Size(DefaultSpillMaxSize),
+		SpillReplayInterval: toml.Duration(DefaultSpillReplayInterval),
+
+		CaptureMode:    DefaultCaptureMode,
+		CaptureLimit:   DefaultCaptureLimit,
+		CaptureMaxSize: toml.Size(DefaultCaptureMaxSize),
 	}
 }
 
@@ -91,16 +276,126 @@ func (c *Config) WithDefaults() *Config {
 	if d.ReadBuffer == 0 {
 		d.ReadBuffer = DefaultReadBuffer
 	}
+	if d.UDPPayloadSize == 0 {
+		d.UDPPayloadSize = DefaultUDPPayloadSize
+	}
+	if d.Format == "" {
+		d.Format = DefaultFormat
+	}
+	if d.Compression == "" {
+		d.Compression = DefaultCompression
+	}
+	if d.OverflowPolicy == "" {
+		d.OverflowPolicy = DefaultOverflowPolicy
+	}
+	if d.ConsistencyLevel == "" {
+		d.ConsistencyLevel = DefaultConsistencyLevel
+	}
+	if d.WriteRetryBackoff == 0 {
+		d.WriteRetryBackoff = toml.Duration(DefaultWriteRetryBackoff)
+	}
+	if d.SpillMaxSize == 0 {
+		d.SpillMaxSize = toml.Size(DefaultSpillMaxSize)
+	}
+	if d.SpillReplayInterval == 0 {
+		d.SpillReplayInterval = toml.Duration(DefaultSpillReplayInterval)
+	}
+	if d.CaptureMode == "" {
+		d.CaptureMode = DefaultCaptureMode
+	}
+	if d.CaptureMaxSize == 0 {
+		d.CaptureMaxSize = toml.Size(DefaultCaptureMaxSize)
+	}
 	return &d
 }
 
+// Addresses returns all addresses the service listens on, BindAddress first.
+func (c *Config) Addresses() []string {
+	return append([]string{c.BindAddress}, c.BindAddresses...)
+}
+
+// Validate returns an error if the Config is invalid.
+func (c *Config) Validate() error {
+	seen := map[string]bool{c.BindAddress: true}
+	for _, addr := range c.BindAddresses {
+		if addr == "" {
+			return errors.New("bind-addresses must not contain empty addresses")
+		} else if seen[addr] {
+			return fmt.Errorf("duplicate bind address %q", addr)
+		}
+		seen[addr] = true
+	}
+
+	if c.UDPPayloadSize < 0 || c.UDPPayloadSize > MaxUDPPayloadSize {
+		return fmt.Errorf("udp-payload-size must be between 0 and %d", MaxUDPPayloadSize)
+	}
+
+	switch c.Format {
+	case "", FormatLineProtocol, FormatJSON, FormatProtobuf:
+	default:
+		return errors.New(`Invalid value for format. Valid options are "line", "json" and "protobuf"`)
+	}
+
+	switch c.Compression {
+	case "", CompressionNone, CompressionAuto, CompressionGzip, CompressionSnappy:
+	default:
+		return errors.New(`Invalid value for compression. Valid options are "none", "auto", "gzip" and "snappy"`)
+	}
+
+	if c.ConsistencyLevel != "" {
+		if _, err := models.ParseConsistencyLevel(c.ConsistencyLevel); err != nil {
+			return fmt.Errorf("invalid consistency-level %q", c.ConsistencyLevel)
+		}
+	}
+
+	if c.WriteRetries < 0 {
+		return errors.New("write-retries must not be negative")
+	}
+
+	if c.SpillReplayInterval < 0 {
+		return errors.New("spill-replay-interval must not be negative")
+	}
+
+	for _, pattern := range c.RoutedDatabases {
+		if _, err := compileRoutedDatabase(pattern); err != nil {
+			return fmt.Errorf("invalid routed-databases pattern %q: %s", pattern, err)
+		}
+	}
+	if c.CreateRoutedDatabases && len(c.RoutedDatabases) == 0 {
+		return errors.New("create-routed-databases requires routed-databases")
+	}
+
+	for _, m := range c.RetentionPolicyMappings {
+		if _, err := regexp.Compile(m.Measurement); err != nil {
+			return fmt.Errorf("invalid retention-policy-mapping measurement %q: %s", m.Measurement, err)
+		}
+	}
+
+	switch c.CaptureMode {
+	case "", CaptureRejected, CaptureAll:
+	default:
+		return errors.New(`Invalid value for capture-mode. Valid options are "rejected" and "all"`)
+	}
+
+	if c.CaptureLimit < 0 {
+		return errors.New("capture-limit must not be negative")
+	}
+
+	switch c.OverflowPolicy {
+	case "", OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+	default:
+		return errors.New(`Invalid value for overflow-policy. Valid options are "block", "drop-newest" and "drop-oldest"`)
+	}
+	return nil
+}
+
 // Configs wraps a slice of Config to aggregate diagnostics.
 type Configs []Config
 
 // Diagnostics returns one set of diagnostics for all of the Configs.
 func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
 	d := &diagnostics.Diagnostics{
-		Columns: []string{"enabled", "bind-address", "database", "retention-policy", "batch-size", "batch-pending", "batch-timeout"},
+		Columns: []string{"enabled", "bind-address", "bind-addresses", "database", "retention-policy", "batch-size", "batch-pending", "batch-timeout", "udp-payload-size", "format", "compression", "overflow-policy", "consistency-level", "write-retries", "database-tag", "retention-policy-tag", "routed-databases", "create-routed-databases", "multicast-interface", "spill-dir", "capture-file"},
 	}
 
 	for _, cc := range c {
@@ -109,7 +404,7 @@ func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
 			continue
 		}
 
-		r := []interface{}{true, cc.BindAddress, cc.Database, cc.RetentionPolicy, cc.BatchSize, cc.BatchPending, cc.BatchTimeout}
+		r := []interface{}{true, cc.BindAddress, strings.Join(cc.BindAddresses, ","), cc.Database, cc.RetentionPolicy, cc.BatchSize, cc.BatchPending, cc.BatchTimeout, cc.UDPPayloadSize, cc.Format, cc.Compression, cc.OverflowPolicy, cc.ConsistencyLevel, cc.WriteRetries, cc.DatabaseTag, cc.RetentionPolicyTag, strings.Join(cc.RoutedDatabases, ","), cc.CreateRoutedDatabases, cc.MulticastInterface, cc.SpillDir, cc.CaptureFile}
 		d.AddRow(r)
 	}
 
@@ -1,6 +1,7 @@
 package udp_test
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
@@ -39,5 +40,106 @@ udp-payload-size = 1500
 		t.Fatalf("unexpected batch pending: %d", c.BatchPending)
 	} else if time.Duration(c.BatchTimeout) != (10 * time.Millisecond) {
 		t.Fatalf("unexpected batch timeout: %v", c.BatchTimeout)
+	} else if c.UDPPayloadSize != 1500 {
+		t.Fatalf("unexpected udp payload size: %d", c.UDPPayloadSize)
+	}
+}
+
+func TestConfig_Parse_RetentionPolicyMappings(t *testing.T) {
+	var c udp.Config
+	if _, err := toml.Decode(`
+retention-policy = "default"
+
+[[retention-policy-mapping]]
+measurement = "^cpu"
+retention-policy = "short"
+
+[[retention-policy-mapping]]
+measurement = "^disk$"
+retention-policy = "long"
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []udp.RetentionPolicyMapping{
+		{Measurement: "^cpu", RetentionPolicy: "short"},
+		{Measurement: "^disk$", RetentionPolicy: "long"},
+	}
+	if !reflect.DeepEqual(c.RetentionPolicyMappings, exp) {
+		t.Fatalf("unexpected retention policy mappings: %v", c.RetentionPolicyMappings)
+	}
+
+	c.RetentionPolicyMappings[0].Measurement = "("
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for invalid measurement pattern")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := udp.NewConfig()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+
+	c.Compression = udp.CompressionAuto
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+
+	c.Compression = "lzma"
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for invalid compression")
+	}
+
+	c = udp.NewConfig()
+	c.UDPPayloadSize = udp.MaxUDPPayloadSize + 1
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for invalid udp payload size")
+	}
+
+	c = udp.NewConfig()
+	c.OverflowPolicy = "drop-random"
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for invalid overflow policy")
+	}
+
+	c = udp.NewConfig()
+	c.BindAddresses = []string{":8090", udp.DefaultBindAddress}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for duplicate bind address")
+	}
+
+	c = udp.NewConfig()
+	c.CaptureMode = "accepted"
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for invalid capture mode")
+	}
+
+	c = udp.NewConfig()
+	c.ConsistencyLevel = "most"
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for invalid consistency level")
+	}
+
+	c = udp.NewConfig()
+	c.WriteRetries = -1
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for negative write retries")
+	}
+
+	c = udp.NewConfig()
+	c.RoutedDatabases = []string{"("}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for invalid routed database pattern")
+	}
+
+	c = udp.NewConfig()
+	c.CreateRoutedDatabases = true
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected error for creating routed databases without routed-databases")
+	}
+	c.RoutedDatabases = []string{"telegraf_.*"}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
 	}
 }
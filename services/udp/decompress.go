@@ -0,0 +1,76 @@
+package udp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+)
+
+// MaxDecompressedPayload is the largest size a compressed payload may expand
+// to before it is rejected.
+const MaxDecompressedPayload = 16 * 1024 * 1024
+
+var (
+	gzipMagic         = []byte{0x1f, 0x8b}
+	snappyStreamMagic = []byte("\xff\x06\x00\x00sNaPpY")
+)
+
+// errPayloadTooLarge is returned when a decompressed payload exceeds
+// MaxDecompressedPayload.
+var errPayloadTooLarge = fmt.Errorf("decompressed payload exceeds %d bytes", MaxDecompressedPayload)
+
+// decompress returns the uncompressed contents of buf according to the
+// compression setting. Uncompressed payloads are returned as-is when
+// compression is CompressionAuto.
+func decompress(compression string, buf []byte) ([]byte, error) {
+	switch compression {
+	case CompressionAuto:
+		if bytes.HasPrefix(buf, gzipMagic) {
+			return gunzip(buf)
+		} else if bytes.HasPrefix(buf, snappyStreamMagic) {
+			return readLimited(snappy.NewReader(bytes.NewReader(buf)))
+		}
+		return buf, nil
+	case CompressionGzip:
+		return gunzip(buf)
+	case CompressionSnappy:
+		if bytes.HasPrefix(buf, snappyStreamMagic) {
+			return readLimited(snappy.NewReader(bytes.NewReader(buf)))
+		}
+		n, err := snappy.DecodedLen(buf)
+		if err != nil {
+			return nil, err
+		} else if n > MaxDecompressedPayload {
+			return nil, errPayloadTooLarge
+		}
+		return snappy.Decode(nil, buf)
+	default:
+		return buf, nil
+	}
+}
+
+// gunzip decompresses a gzip encoded payload.
+func gunzip(buf []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r)
+}
+
+// readLimited reads all of r, returning errPayloadTooLarge if more than
+// MaxDecompressedPayload bytes are available.
+func readLimited(r io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, MaxDecompressedPayload+1))
+	if err != nil {
+		return nil, err
+	} else if len(b) > MaxDecompressedPayload {
+		return nil, errPayloadTooLarge
+	}
+	return b, nil
+}
@@ -0,0 +1,290 @@
+package udp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// parsePoints decodes the points in buf according to format. Timestamps given
+// as numbers are interpreted using precision, points without a timestamp are
+// assigned now.
+func parsePoints(format string, buf []byte, now time.Time, precision string) ([]models.Point, error) {
+	switch format {
+	case FormatJSON:
+		return parseJSONPoints(buf, now, precision)
+	case FormatProtobuf:
+		return parseProtobufPoints(buf, now, precision)
+	default:
+		return models.ParsePointsWithPrecision(buf, now, precision)
+	}
+}
+
+// precisionPrefix starts the optional first line of a payload that overrides
+// the configured precision for that payload, e.g. "#precision=ms\n". Line
+// protocol treats the line as a comment, so senders may add it unconditionally.
+var precisionPrefix = []byte("#precision=")
+
+// splitPrecision returns the precision given by the precision prefix of buf,
+// and the remainder of buf. If buf has no precision prefix, precision is
+// returned along with buf unchanged.
+func splitPrecision(buf []byte, precision string) (string, []byte, error) {
+	if !bytes.HasPrefix(buf, precisionPrefix) {
+		return precision, buf, nil
+	}
+
+	line, rest := buf[len(precisionPrefix):], []byte(nil)
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line, rest = line[:i], line[i+1:]
+	}
+
+	switch p := string(bytes.TrimSpace(line)); p {
+	case "n", "u", "ms", "s", "m", "h":
+		return p, rest, nil
+	default:
+		return "", nil, fmt.Errorf("invalid precision %q", p)
+	}
+}
+
+// jsonPoint is a single point in the JSON payload format.
+type jsonPoint struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+	Time        json.RawMessage        `json:"time"`
+}
+
+// parseJSONPoints decodes a JSON payload holding either a single point object
+// or an array of point objects. Numeric field values are stored as floats.
+// The time may be a number in the given precision or an RFC3339 string.
+func parseJSONPoints(buf []byte, now time.Time, precision string) ([]models.Point, error) {
+	var jps []jsonPoint
+	if b := bytes.TrimSpace(buf); len(b) > 0 && b[0] == '[' {
+		if err := json.Unmarshal(b, &jps); err != nil {
+			return nil, err
+		}
+	} else {
+		var jp jsonPoint
+		if err := json.Unmarshal(b, &jp); err != nil {
+			return nil, err
+		}
+		jps = append(jps, jp)
+	}
+
+	points := make([]models.Point, 0, len(jps))
+	for _, jp := range jps {
+		t, err := jp.time(now, precision)
+		if err != nil {
+			return nil, err
+		}
+
+		fields := make(models.Fields, len(jp.Fields))
+		for k, v := range jp.Fields {
+			switch v := v.(type) {
+			case float64, string, bool:
+				fields[k] = v
+			default:
+				return nil, fmt.Errorf("unsupported value for field %q: %v", k, v)
+			}
+		}
+
+		p, err := models.NewPoint(jp.Measurement, models.NewTags(jp.Tags), fields, t)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// time returns the timestamp of the point.
+func (jp *jsonPoint) time(now time.Time, precision string) (time.Time, error) {
+	if len(jp.Time) == 0 || string(jp.Time) == "null" {
+		return now, nil
+	}
+
+	var ts int64
+	if err := json.Unmarshal(jp.Time, &ts); err == nil {
+		return models.SafeCalcTime(ts, precision)
+	}
+
+	var s string
+	if err := json.Unmarshal(jp.Time, &s); err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", jp.Time)
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), models.CheckTime(t)
+}
+
+// Protocol buffer wire types used by the protobuf payload format.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncatedProtobuf = errors.New("truncated protobuf message")
+
+// parseProtobufPoints decodes a payload encoded as the Points message
+// described in points.proto.
+func parseProtobufPoints(buf []byte, now time.Time, precision string) ([]models.Point, error) {
+	var points []models.Point
+	err := walkProtobuf(buf, func(field int, wire int, v uint64, b []byte) error {
+		if field != 1 || wire != wireBytes {
+			return nil
+		}
+		p, err := parseProtobufPoint(b, now, precision)
+		if err != nil {
+			return err
+		}
+		points = append(points, p)
+		return nil
+	})
+	return points, err
+}
+
+// parseProtobufPoint decodes a single Point message.
+func parseProtobufPoint(buf []byte, now time.Time, precision string) (models.Point, error) {
+	var (
+		name   string
+		tags   = make(map[string]string)
+		fields = make(models.Fields)
+		ts     int64
+		hasTS  bool
+	)
+
+	err := walkProtobuf(buf, func(field int, wire int, v uint64, b []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			name = string(b)
+		case field == 2 && wire == wireBytes:
+			var key, value string
+			if err := walkProtobuf(b, func(field int, wire int, _ uint64, b []byte) error {
+				switch {
+				case field == 1 && wire == wireBytes:
+					key = string(b)
+				case field == 2 && wire == wireBytes:
+					value = string(b)
+				default:
+					// Ignore unknown fields.
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			tags[key] = value
+		case field == 3 && wire == wireBytes:
+			key, value, err := parseProtobufField(b)
+			if err != nil {
+				return err
+			}
+			fields[key] = value
+		case field == 4 && wire == wireVarint:
+			ts, hasTS = int64(v), true
+		default:
+			// Ignore unknown fields.
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t := now
+	if hasTS {
+		if t, err = models.SafeCalcTime(ts, precision); err != nil {
+			return nil, err
+		}
+	}
+	return models.NewPoint(name, models.NewTags(tags), fields, t)
+}
+
+// parseProtobufField decodes a single Field message.
+func parseProtobufField(buf []byte) (string, interface{}, error) {
+	var (
+		key   string
+		value interface{}
+	)
+	err := walkProtobuf(buf, func(field int, wire int, v uint64, b []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			key = string(b)
+		case field == 2 && wire == wireFixed64:
+			value = math.Float64frombits(v)
+		case field == 3 && wire == wireVarint:
+			value = int64(v)
+		case field == 4 && wire == wireBytes:
+			value = string(b)
+		case field == 5 && wire == wireVarint:
+			value = v != 0
+		default:
+			// Ignore unknown fields.
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	} else if value == nil {
+		return "", nil, fmt.Errorf("missing value for field %q", key)
+	}
+	return key, value, nil
+}
+
+// walkProtobuf calls fn for every field of the protobuf message in buf.
+// Varint and fixed-width values are passed as v, length-delimited values as b.
+func walkProtobuf(buf []byte, fn func(field int, wire int, v uint64, b []byte) error) error {
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return errTruncatedProtobuf
+		}
+		buf = buf[n:]
+
+		var (
+			field = int(key >> 3)
+			wire  = int(key & 7)
+			v     uint64
+			b     []byte
+		)
+		switch wire {
+		case wireVarint:
+			if v, n = binary.Uvarint(buf); n <= 0 {
+				return errTruncatedProtobuf
+			}
+			buf = buf[n:]
+		case wireFixed64:
+			if len(buf) < 8 {
+				return errTruncatedProtobuf
+			}
+			v, buf = binary.LittleEndian.Uint64(buf), buf[8:]
+		case wireFixed32:
+			if len(buf) < 4 {
+				return errTruncatedProtobuf
+			}
+			v, buf = uint64(binary.LittleEndian.Uint32(buf)), buf[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(buf)
+			if n <= 0 || uint64(len(buf)-n) < l {
+				return errTruncatedProtobuf
+			}
+			b, buf = buf[n:n+int(l)], buf[n+int(l):]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wire)
+		}
+
+		if err := fn(field, wire, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
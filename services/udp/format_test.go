@@ -0,0 +1,128 @@
+package udp
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePoints_JSON(t *testing.T) {
+	now := time.Unix(0, 42).UTC()
+
+	for _, tt := range []struct {
+		name    string
+		payload string
+		exp     []string
+	}{
+		{
+			name:    "object",
+			payload: `{"measurement":"cpu","tags":{"host":"a"},"fields":{"value":1.5,"ok":true,"s":"x"},"time":2}`,
+			exp:     []string{`cpu,host=a ok=true,s="x",value=1.5 2000000000`},
+		},
+		{
+			name:    "array",
+			payload: `[{"measurement":"cpu","fields":{"value":1}},{"measurement":"mem","fields":{"free":2},"time":"1970-01-01T00:00:03Z"}]`,
+			exp:     []string{"cpu value=1 42", "mem free=2 3000000000"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			points, err := parsePoints(FormatJSON, []byte(tt.payload), now, "s")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, p := range points {
+				got = append(got, p.String())
+			}
+			if !reflect.DeepEqual(got, tt.exp) {
+				t.Fatalf("unexpected points:\n\tgot = %v\n\texp = %v", got, tt.exp)
+			}
+		})
+	}
+
+	if _, err := parsePoints(FormatJSON, []byte(`{"measurement":"cpu","fields":{"value":[1]}}`), now, "s"); err == nil {
+		t.Fatal("expected error for unsupported field value")
+	}
+}
+
+func TestParsePoints_Protobuf(t *testing.T) {
+	field := func(key string, valueField, wire int, value []byte) []byte {
+		return append(pbBytes(1, []byte(key)), append(pbKey(valueField, wire), value...)...)
+	}
+
+	float := make([]byte, 8)
+	binary.LittleEndian.PutUint64(float, math.Float64bits(1.5))
+
+	var point []byte
+	point = append(point, pbBytes(1, []byte("cpu"))...)
+	point = append(point, pbBytes(2, append(pbBytes(1, []byte("host")), pbBytes(2, []byte("a"))...))...)
+	point = append(point, pbBytes(3, field("f", 2, wireFixed64, float))...)
+	point = append(point, pbBytes(3, field("i", 3, wireVarint, pbUvarint(7)))...)
+	point = append(point, pbBytes(3, field("s", 4, wireBytes, append(pbUvarint(1), 'x')))...)
+	point = append(point, append(pbKey(4, wireVarint), pbUvarint(2)...)...)
+
+	// An unknown field must be skipped.
+	payload := append(pbBytes(1, point), append(pbKey(15, wireVarint), pbUvarint(1)...)...)
+
+	points, err := parsePoints(FormatProtobuf, payload, time.Now(), "s")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(points) != 1 {
+		t.Fatalf("got %d points, expected 1", len(points))
+	}
+	if got, exp := points[0].String(), `cpu,host=a f=1.5,i=7i,s="x" 2000000000`; got != exp {
+		t.Fatalf("unexpected point: got %q, expected %q", got, exp)
+	}
+
+	if _, err := parsePoints(FormatProtobuf, payload[:len(payload)-4], time.Now(), "s"); err == nil {
+		t.Fatal("expected error for truncated payload")
+	}
+}
+
+func pbUvarint(v uint64) []byte {
+	b := make([]byte, binary.MaxVarintLen64)
+	return b[:binary.PutUvarint(b, v)]
+}
+
+func pbKey(field, wire int) []byte {
+	return pbUvarint(uint64(field<<3 | wire))
+}
+
+func pbBytes(field int, b []byte) []byte {
+	return append(append(pbKey(field, wireBytes), pbUvarint(uint64(len(b)))...), b...)
+}
+
+func TestSplitPrecision(t *testing.T) {
+	for _, tt := range []struct {
+		payload   string
+		precision string
+		rest      string
+		err       bool
+	}{
+		{payload: "cpu value=1 1\n", precision: "s", rest: "cpu value=1 1\n"},
+		{payload: "#precision=ms\ncpu value=1 1\n", precision: "ms", rest: "cpu value=1 1\n"},
+		{payload: "#precision=h", precision: "h", rest: ""},
+		{payload: "#precision=fortnight\ncpu value=1 1\n", err: true},
+	} {
+		precision, rest, err := splitPrecision([]byte(tt.payload), "s")
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: expected error", tt.payload)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.payload, err)
+			continue
+		}
+
+		if precision != tt.precision {
+			t.Errorf("%q: unexpected precision: got %q, expected %q", tt.payload, precision, tt.precision)
+		}
+		if string(rest) != tt.rest {
+			t.Errorf("%q: unexpected remainder: got %q, expected %q", tt.payload, rest, tt.rest)
+		}
+	}
+}
@@ -3,11 +3,16 @@ package udp // import "github.com/influxdata/influxdb/services/udp"
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"os"
+	"regexp"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/influxdata/influxdb"
 	"github.com/influxdata/influxdb/logger"
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/services/meta"
@@ -19,8 +24,30 @@ const (
 	// Arbitrary, testing indicated that this doesn't typically get over 10
 	parserChanLen = 1000
 
-	// MaxUDPPayload is largest payload size the UDP service will accept.
+	// MaxUDPPayload is the largest payload size of a UDP datagram. It is the
+	// default for the configurable udp-payload-size.
 	MaxUDPPayload = 64 * 1024
+
+	// maxSources is the maximum number of remote sources tracked
+	// individually. Datagrams from any further sources are accounted
+	// for under otherSource.
+	maxSources = 1024
+
+	// otherSource is the source tag value used once maxSources is reached.
+	otherSource = "other"
+
+	// maxDestinations is the maximum number of routed destinations tracked
+	// individually. Points routed to any further destinations are accounted
+	// for under otherDestination.
+	maxDestinations = 1024
+
+	// otherDestination is the database tag value used once maxDestinations
+	// is reached.
+	otherDestination = "other"
+
+	// unixgramScheme is the bind address prefix selecting a unix datagram
+	// socket instead of a UDP socket.
+	unixgramScheme = "unixgram://"
 )
 
 // statistics gathered by the UDP package.
@@ -32,21 +59,88 @@ const (
 	statBatchesTransmitted  = "batchesTx"
 	statPointsTransmitted   = "pointsTx"
 	statBatchesTransmitFail = "batchesTxFail"
+	statDecompressFail      = "decompressFail"
+	statPacketsDropped      = "packetsDropped"
+	statPointsDropped       = "pointsDropped"
+	statPacketsTooLarge     = "packetsTooLarge"
+	statAuthFail            = "authFail"
+	statBatchesSpilled      = "batchesSpilled"
+	statBatchesReplayed     = "batchesReplayed"
+	statSpillFail           = "spillFail"
+	statSpillDropped        = "spillDropped"
+	statSpillBytes          = "spillBytes"
+	statWriteRetries        = "writeRetries"
+	statPacketsCaptured     = "packetsCaptured"
+	statCaptureFail         = "captureFail"
+	statPointsRouteRejected = "pointsRouteRejected"
 )
 
+// retentionPolicyMapping is a compiled RetentionPolicyMapping.
+type retentionPolicyMapping struct {
+	measurement     *regexp.Regexp
+	retentionPolicy string
+}
+
+// compileRetentionPolicyMappings compiles the measurement patterns of ms.
+func compileRetentionPolicyMappings(ms []RetentionPolicyMapping) ([]retentionPolicyMapping, error) {
+	var compiled []retentionPolicyMapping
+	for _, m := range ms {
+		re, err := regexp.Compile(m.Measurement)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention-policy-mapping measurement %q: %s", m.Measurement, err)
+		}
+		compiled = append(compiled, retentionPolicyMapping{measurement: re, retentionPolicy: m.RetentionPolicy})
+	}
+	return compiled, nil
+}
+
+// compileRoutedDatabase compiles a routed-databases pattern so that it must
+// match the whole database name.
+func compileRoutedDatabase(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// packet is a datagram read from the listener along with the source it was
+// received from.
+type packet struct {
+	buf    []byte
+	source string
+}
+
+// destination identifies the database and retention policy a batch of points
+// is written to.
+type destination struct {
+	database        string
+	retentionPolicy string
+}
+
+// packetConn is a datagram connection whose receive buffer can be sized.
+// It is implemented by both *net.UDPConn and *net.UnixConn.
+type packetConn interface {
+	net.PacketConn
+	SetReadBuffer(bytes int) error
+}
+
 // Service is a UDP service that will listen for incoming packets of line protocol.
 type Service struct {
-	conn *net.UDPConn
-	addr *net.UDPAddr
-	wg   sync.WaitGroup
+	conns []packetConn
+	addrs []net.Addr
+	wg    sync.WaitGroup
 
-	mu    sync.RWMutex
-	ready bool          // Has the required database been created?
-	done  chan struct{} // Is the service closing or closed?
+	mu     sync.RWMutex
+	ready  bool            // Has the required database been created?
+	routed map[string]bool // Have the routed databases been created?
+	done   chan struct{}   // Is the service closing or closed?
 
-	parserChan chan []byte
-	batcher    *tsdb.PointBatcher
-	config     Config
+	parserChan       chan packet
+	consistencyLevel models.ConsistencyLevel
+	rpMappings       []retentionPolicyMapping
+	routedDatabases  []*regexp.Regexp
+	spill            *spillQueue
+	capture          *packetCapture
+	batcher          *tsdb.PointBatcher
+	batcherCh        chan *tsdb.PointBatcher // Replacement batchers for the parser.
+	config           Config
 
 	PointsWriter interface {
 		WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
@@ -59,6 +153,12 @@ type Service struct {
 	Logger      *zap.Logger
 	stats       *Statistics
 	defaultTags models.StatisticTags
+
+	dbStatsMu sync.RWMutex
+	dbStats   map[destination]*DatabaseStatistics
+
+	srcStatsMu sync.RWMutex
+	srcStats   map[string]*SourceStatistics
 }
 
 // NewService returns a new instance of Service.
@@ -66,10 +166,14 @@ func NewService(c Config) *Service {
 	d := *c.WithDefaults()
 	return &Service{
 		config:      d,
-		parserChan:  make(chan []byte, parserChanLen),
+		parserChan:  make(chan packet, parserChanLen),
+		batcherCh:   make(chan *tsdb.PointBatcher),
 		Logger:      zap.NewNop(),
 		stats:       &Statistics{},
 		defaultTags: models.StatisticTags{"bind": d.BindAddress},
+		routed:      make(map[string]bool),
+		dbStats:     make(map[destination]*DatabaseStatistics),
+		srcStats:    make(map[string]*SourceStatistics),
 	}
 }
 
@@ -90,41 +194,189 @@ func (s *Service) Open() (err error) {
 		return errors.New("database has to be specified in config")
 	}
 
-	s.addr, err = net.ResolveUDPAddr("udp", s.config.BindAddress)
-	if err != nil {
-		s.Logger.Info("Failed to resolve UDP address",
-			zap.String("bind_address", s.config.BindAddress), zap.Error(err))
+	if s.consistencyLevel, err = models.ParseConsistencyLevel(s.config.ConsistencyLevel); err != nil {
 		return err
 	}
 
-	s.conn, err = net.ListenUDP("udp", s.addr)
-	if err != nil {
-		s.Logger.Info("Failed to set up UDP listener",
-			zap.Stringer("addr", s.addr), zap.Error(err))
+	if s.rpMappings, err = compileRetentionPolicyMappings(s.config.RetentionPolicyMappings); err != nil {
 		return err
 	}
 
-	if s.config.ReadBuffer != 0 {
-		err = s.conn.SetReadBuffer(s.config.ReadBuffer)
+	s.routedDatabases = nil
+	for _, pattern := range s.config.RoutedDatabases {
+		re, err := compileRoutedDatabase(pattern)
 		if err != nil {
-			s.Logger.Info("Failed to set UDP read buffer",
-				zap.Int("buffer_size", s.config.ReadBuffer), zap.Error(err))
+			return fmt.Errorf("invalid routed-databases pattern %q: %s", pattern, err)
+		}
+		s.routedDatabases = append(s.routedDatabases, re)
+	}
+
+	if s.config.SpillDir != "" {
+		if s.spill, err = openSpillQueue(s.config.SpillDir, int64(s.config.SpillMaxSize)); err != nil {
+			s.Logger.Info("Failed to open spill queue",
+				zap.String("path", s.config.SpillDir), zap.Error(err))
+			return err
+		}
+	}
+
+	for _, addr := range s.config.Addresses() {
+		if err := s.listen(addr); err != nil {
+			s.closeConns()
+			return err
+		}
+	}
+
+	if s.config.CaptureFile != "" {
+		if s.capture, err = openPacketCapture(s.config.CaptureFile, int64(s.config.CaptureMaxSize), s.config.CaptureLimit); err != nil {
+			s.Logger.Info("Failed to open packet capture file",
+				zap.String("path", s.config.CaptureFile), zap.Error(err))
+			s.closeConns()
 			return err
 		}
+		s.Logger.Info("Capturing UDP packets",
+			zap.String("path", s.config.CaptureFile), zap.String("mode", s.config.CaptureMode))
 	}
+
 	s.batcher = tsdb.NewPointBatcher(s.config.BatchSize, s.config.BatchPending, time.Duration(s.config.BatchTimeout))
 	s.batcher.Start()
 
-	s.Logger.Info("Started listening on UDP", zap.String("addr", s.config.BindAddress))
-
-	s.wg.Add(3)
-	go s.serve()
+	s.wg.Add(len(s.conns) + 2)
+	for _, conn := range s.conns {
+		go s.serve(conn)
+	}
 	go s.parser()
-	go s.writer()
+	go s.writer(s.batcher, s.done)
+
+	if s.spill != nil {
+		s.wg.Add(1)
+		go s.replaySpill()
+	}
 
 	return nil
 }
 
+// listen opens a socket bound to the bind address addr and adds it to the
+// connections served by the service.
+func (s *Service) listen(addr string) error {
+	var (
+		conn  packetConn
+		laddr net.Addr
+		err   error
+	)
+	if path := unixgramPath(addr); path != "" {
+		conn, laddr, err = s.listenUnixgram(path)
+	} else {
+		conn, laddr, err = s.listenUDP(addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.config.ReadBuffer != 0 {
+		if err := conn.SetReadBuffer(s.config.ReadBuffer); err != nil {
+			s.Logger.Info("Failed to set UDP read buffer",
+				zap.Int("buffer_size", s.config.ReadBuffer), zap.Error(err))
+			conn.Close()
+			return err
+		}
+	}
+
+	s.Logger.Info("Started listening on UDP", zap.Stringer("addr", laddr))
+	s.conns = append(s.conns, conn)
+	s.addrs = append(s.addrs, laddr)
+	return nil
+}
+
+// closeConns closes all connections of the service and removes the socket
+// files of its unix datagram sockets.
+func (s *Service) closeConns() {
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	for _, addr := range s.addrs {
+		if addr, ok := addr.(*net.UnixAddr); ok {
+			os.Remove(addr.Name)
+		}
+	}
+	s.conns, s.addrs = nil, nil
+}
+
+// listenUDP opens a UDP socket bound to addr.
+func (s *Service) listenUDP(addr string) (packetConn, net.Addr, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		s.Logger.Info("Failed to resolve UDP address",
+			zap.String("bind_address", addr), zap.Error(err))
+		return nil, nil, err
+	}
+
+	var conn *net.UDPConn
+	if udpAddr.IP.IsMulticast() {
+		conn, err = s.listenMulticast(udpAddr)
+	} else {
+		conn, err = net.ListenUDP("udp", udpAddr)
+	}
+	if err != nil {
+		s.Logger.Info("Failed to set up UDP listener",
+			zap.Stringer("addr", udpAddr), zap.Error(err))
+		return nil, nil, err
+	}
+	// Report the address actually bound, which carries the port chosen by
+	// the system when addr uses port 0.
+	return conn, conn.LocalAddr(), nil
+}
+
+// listenMulticast opens a UDP socket that joins the multicast group addr on
+// the configured multicast interface, or on the system default interface if
+// none is configured.
+func (s *Service) listenMulticast(addr *net.UDPAddr) (*net.UDPConn, error) {
+	var ifi *net.Interface
+	if s.config.MulticastInterface != "" {
+		var err error
+		if ifi, err = net.InterfaceByName(s.config.MulticastInterface); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := net.ListenMulticastUDP("udp", ifi, addr)
+	if err != nil {
+		return nil, err
+	}
+	s.Logger.Info("Joined multicast group",
+		zap.Stringer("group", addr), zap.String("interface", s.config.MulticastInterface))
+	return conn, nil
+}
+
+// listenUnixgram opens a unix datagram socket at path. A stale socket file
+// left behind by a previous process is removed first.
+func (s *Service) listenUnixgram(path string) (packetConn, net.Addr, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			s.Logger.Info("Failed to remove stale unix socket",
+				zap.String("path", path), zap.Error(err))
+			return nil, nil, err
+		}
+	}
+
+	unixAddr := &net.UnixAddr{Name: path, Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", unixAddr)
+	if err != nil {
+		s.Logger.Info("Failed to set up unix datagram listener",
+			zap.String("path", path), zap.Error(err))
+		return nil, nil, err
+	}
+	return conn, unixAddr, nil
+}
+
+// unixgramPath returns the socket path of a "unixgram://" bind address, or
+// an empty string if addr is not a unix datagram address.
+func unixgramPath(addr string) string {
+	if !strings.HasPrefix(addr, unixgramScheme) {
+		return ""
+	}
+	return strings.TrimPrefix(addr, unixgramScheme)
+}
+
 // Statistics maintains statistics for the UDP service.
 type Statistics struct {
 	PointsReceived      int64
@@ -134,11 +386,41 @@ type Statistics struct {
 	BatchesTransmitted  int64
 	PointsTransmitted   int64
 	BatchesTransmitFail int64
+	DecompressFail      int64
+	PacketsDropped      int64
+	PointsDropped       int64
+	PacketsTooLarge     int64
+	AuthFail            int64
+	BatchesSpilled      int64
+	BatchesReplayed     int64
+	SpillFail           int64
+	SpillDropped        int64
+	WriteRetries        int64
+	PacketsCaptured     int64
+	CaptureFail         int64
+	PointsRouteRejected int64
+}
+
+// DatabaseStatistics maintains statistics for points routed to a single
+// database and retention policy by the UDP service.
+type DatabaseStatistics struct {
+	BatchesTransmitted  int64
+	PointsTransmitted   int64
+	BatchesTransmitFail int64
+}
+
+// SourceStatistics maintains statistics for datagrams received from a single
+// remote source by the UDP service.
+type SourceStatistics struct {
+	PointsReceived  int64
+	BytesReceived   int64
+	PointsParseFail int64
+	AuthFail        int64
 }
 
 // Statistics returns statistics for periodic monitoring.
 func (s *Service) Statistics(tags map[string]string) []models.Statistic {
-	return []models.Statistic{{
+	statistics := []models.Statistic{{
 		Name: "udp",
 		Tags: s.defaultTags.Merge(tags),
 		Values: map[string]interface{}{
@@ -149,30 +431,221 @@ func (s *Service) Statistics(tags map[string]string) []models.Statistic {
 			statBatchesTransmitted:  atomic.LoadInt64(&s.stats.BatchesTransmitted),
 			statPointsTransmitted:   atomic.LoadInt64(&s.stats.PointsTransmitted),
 			statBatchesTransmitFail: atomic.LoadInt64(&s.stats.BatchesTransmitFail),
+			statDecompressFail:      atomic.LoadInt64(&s.stats.DecompressFail),
+			statPacketsDropped:      atomic.LoadInt64(&s.stats.PacketsDropped),
+			statPointsDropped:       atomic.LoadInt64(&s.stats.PointsDropped),
+			statPacketsTooLarge:     atomic.LoadInt64(&s.stats.PacketsTooLarge),
+			statAuthFail:            atomic.LoadInt64(&s.stats.AuthFail),
+			statBatchesSpilled:      atomic.LoadInt64(&s.stats.BatchesSpilled),
+			statBatchesReplayed:     atomic.LoadInt64(&s.stats.BatchesReplayed),
+			statSpillFail:           atomic.LoadInt64(&s.stats.SpillFail),
+			statSpillDropped:        atomic.LoadInt64(&s.stats.SpillDropped),
+			statWriteRetries:        atomic.LoadInt64(&s.stats.WriteRetries),
+			statPacketsCaptured:     atomic.LoadInt64(&s.stats.PacketsCaptured),
+			statCaptureFail:         atomic.LoadInt64(&s.stats.CaptureFail),
+			statPointsRouteRejected: atomic.LoadInt64(&s.stats.PointsRouteRejected),
 		},
 	}}
+	if spill := s.spillQueue(); spill != nil {
+		statistics[0].Values[statSpillBytes] = spill.Size()
+	}
+
+	s.dbStatsMu.RLock()
+	defer s.dbStatsMu.RUnlock()
+	for dest, stats := range s.dbStats {
+		dbTags := s.defaultTags.Merge(tags)
+		dbTags["database"] = dest.database
+		if dest.retentionPolicy != "" {
+			dbTags["retention_policy"] = dest.retentionPolicy
+		}
+		statistics = append(statistics, models.Statistic{
+			Name: "udp_database",
+			Tags: dbTags,
+			Values: map[string]interface{}{
+				statBatchesTransmitted:  atomic.LoadInt64(&stats.BatchesTransmitted),
+				statPointsTransmitted:   atomic.LoadInt64(&stats.PointsTransmitted),
+				statBatchesTransmitFail: atomic.LoadInt64(&stats.BatchesTransmitFail),
+			},
+		})
+	}
+
+	s.srcStatsMu.RLock()
+	defer s.srcStatsMu.RUnlock()
+	for src, stats := range s.srcStats {
+		srcTags := s.defaultTags.Merge(tags)
+		srcTags["source"] = src
+		statistics = append(statistics, models.Statistic{
+			Name: "udp_source",
+			Tags: srcTags,
+			Values: map[string]interface{}{
+				statPointsReceived:  atomic.LoadInt64(&stats.PointsReceived),
+				statBytesReceived:   atomic.LoadInt64(&stats.BytesReceived),
+				statPointsParseFail: atomic.LoadInt64(&stats.PointsParseFail),
+				statAuthFail:        atomic.LoadInt64(&stats.AuthFail),
+			},
+		})
+	}
+	return statistics
 }
 
-func (s *Service) writer() {
+// spillQueue returns the spill queue of the service, if spilling is enabled.
+func (s *Service) spillQueue() *spillQueue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.spill
+}
+
+// databaseStatistics returns the statistics for the routed destination dest,
+// creating them if necessary. Once maxDestinations destinations are tracked,
+// the statistics of otherDestination are returned for any new destination.
+func (s *Service) databaseStatistics(dest destination) *DatabaseStatistics {
+	s.dbStatsMu.RLock()
+	stats := s.dbStats[dest]
+	s.dbStatsMu.RUnlock()
+	if stats != nil {
+		return stats
+	}
+
+	s.dbStatsMu.Lock()
+	defer s.dbStatsMu.Unlock()
+	if stats = s.dbStats[dest]; stats != nil {
+		return stats
+	}
+	if len(s.dbStats) >= maxDestinations {
+		dest = destination{database: otherDestination}
+		if stats = s.dbStats[dest]; stats != nil {
+			return stats
+		}
+	}
+	stats = &DatabaseStatistics{}
+	s.dbStats[dest] = stats
+	return stats
+}
+
+// writer writes the batches emitted by b until stop is closed.
+func (s *Service) writer(b *tsdb.PointBatcher, stop <-chan struct{}) {
 	defer s.wg.Done()
 
 	for {
 		select {
-		case batch := <-s.batcher.Out():
-			// Will attempt to create database if not yet created.
-			if err := s.createInternalStorage(); err != nil {
-				s.Logger.Info("Required database does not yet exist",
-					logger.Database(s.config.Database), zap.Error(err))
+		case batch := <-b.Out():
+			if !s.routing() {
+				s.writeBatch(destination{database: s.config.Database, retentionPolicy: s.config.RetentionPolicy}, batch)
 				continue
 			}
 
-			if err := s.PointsWriter.WritePointsPrivileged(s.config.Database, s.config.RetentionPolicy, models.ConsistencyLevelAny, batch); err == nil {
-				atomic.AddInt64(&s.stats.BatchesTransmitted, 1)
-				atomic.AddInt64(&s.stats.PointsTransmitted, int64(len(batch)))
-			} else {
-				s.Logger.Info("Failed to write point batch to database",
-					logger.Database(s.config.Database), zap.Error(err))
-				atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+			for dest, points := range s.route(batch) {
+				s.writeBatch(dest, points)
+			}
+
+		case <-stop:
+			return
+		}
+	}
+}
+
+// writeBatch writes a batch of points to the given destination. If the batch
+// cannot be written, spilling is enabled and the write may succeed later, it
+// is queued on disk instead.
+func (s *Service) writeBatch(dest destination, batch []models.Point) {
+	var dbStats *DatabaseStatistics
+	if s.routing() {
+		dbStats = s.databaseStatistics(dest)
+	}
+
+	err := s.writePoints(dest, batch)
+	if err == nil {
+		atomic.AddInt64(&s.stats.BatchesTransmitted, 1)
+		atomic.AddInt64(&s.stats.PointsTransmitted, int64(len(batch)))
+		if dbStats != nil {
+			atomic.AddInt64(&dbStats.BatchesTransmitted, 1)
+			atomic.AddInt64(&dbStats.PointsTransmitted, int64(len(batch)))
+		}
+		return
+	}
+
+	atomic.AddInt64(&s.stats.BatchesTransmitFail, 1)
+	if dbStats != nil {
+		atomic.AddInt64(&dbStats.BatchesTransmitFail, 1)
+	}
+
+	if s.spill == nil || permanentWriteError(err) {
+		return
+	}
+	if err := s.spill.push(dest, batch); err != nil {
+		atomic.AddInt64(&s.stats.SpillFail, 1)
+		s.Logger.Info("Failed to spill point batch to disk",
+			logger.Database(dest.database), zap.Error(err))
+		return
+	}
+	atomic.AddInt64(&s.stats.BatchesSpilled, 1)
+}
+
+// writePoints writes points to the given destination, creating the database
+// if necessary. Failed writes are retried up to WriteRetries times, doubling
+// the delay between attempts, unless the failure is permanent.
+func (s *Service) writePoints(dest destination, points []models.Point) error {
+	// Will attempt to create database if not yet created.
+	if err := s.createDatabase(dest.database); err != nil {
+		s.Logger.Info("Required database does not yet exist",
+			logger.Database(dest.database), zap.Error(err))
+		return err
+	}
+
+	backoff := time.Duration(s.config.WriteRetryBackoff)
+	for attempt := 0; ; attempt++ {
+		err := s.PointsWriter.WritePointsPrivileged(dest.database, dest.retentionPolicy, s.consistencyLevel, points)
+		if err == nil {
+			return nil
+		}
+
+		if permanentWriteError(err) || attempt >= s.config.WriteRetries {
+			s.Logger.Info("Failed to write point batch to database",
+				logger.Database(dest.database), zap.Int("attempts", attempt+1), zap.Error(err))
+			return err
+		}
+
+		atomic.AddInt64(&s.stats.WriteRetries, 1)
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-s.done:
+			return err
+		}
+	}
+}
+
+// permanentWriteError returns true if err is a write error that retrying the
+// write can't fix, such as points rejected by a partial write or a missing
+// retention policy. Such batches are dropped instead of being retried or
+// spilled.
+func permanentWriteError(err error) bool {
+	if err == nil {
+		return false
+	} else if _, ok := err.(tsdb.PartialWriteError); ok {
+		return true
+	}
+	return influxdb.IsClientError(err) || influxdb.IsAuthorizationError(err) ||
+		strings.HasPrefix(err.Error(), "database not found") ||
+		strings.HasPrefix(err.Error(), "retention policy not found")
+}
+
+// replaySpill periodically replays the batches in the spill queue until
+// the service is closed.
+func (s *Service) replaySpill() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(s.config.SpillReplayInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, dropped, err := s.spill.replay(s.writePoints)
+			atomic.AddInt64(&s.stats.BatchesReplayed, int64(n))
+			atomic.AddInt64(&s.stats.SpillDropped, int64(dropped))
+			if err != nil {
+				s.Logger.Info("Failed to replay spilled point batches", zap.Error(err))
 			}
 
 		case <-s.done:
@@ -181,10 +654,134 @@ func (s *Service) writer() {
 	}
 }
 
-func (s *Service) serve() {
+// sourceStatistics returns the statistics for the remote source src,
+// creating them if necessary. Once maxSources sources are tracked, the
+// statistics of otherSource are returned for any new source.
+func (s *Service) sourceStatistics(src string) *SourceStatistics {
+	s.srcStatsMu.RLock()
+	stats := s.srcStats[src]
+	s.srcStatsMu.RUnlock()
+	if stats != nil {
+		return stats
+	}
+
+	s.srcStatsMu.Lock()
+	defer s.srcStatsMu.Unlock()
+	if stats = s.srcStats[src]; stats != nil {
+		return stats
+	}
+	if len(s.srcStats) >= maxSources {
+		src = otherSource
+		if stats = s.srcStats[src]; stats != nil {
+			return stats
+		}
+	}
+	stats = &SourceStatistics{}
+	s.srcStats[src] = stats
+	return stats
+}
+
+// sourceName returns the name used to account for datagrams received from addr.
+// UDP sources are identified by IP address alone, as senders typically write
+// from ephemeral ports.
+func sourceName(addr net.Addr) string {
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		return addr.IP.String()
+	case *net.UnixAddr:
+		if addr != nil && addr.Name != "" {
+			return addr.Name
+		}
+		return "unixgram"
+	default:
+		if addr == nil {
+			return "unknown"
+		}
+		return addr.String()
+	}
+}
+
+// routing returns true if points are routed to their destination by tag or
+// by measurement name.
+func (s *Service) routing() bool {
+	return s.config.DatabaseTag != "" || s.config.RetentionPolicyTag != "" || len(s.rpMappings) > 0
+}
+
+// route groups the points in batch by their destination. Routing tags are
+// removed from each point. A retention policy given by tag takes precedence
+// over the retention policy mappings. Points routed to a database not allowed
+// by RoutedDatabases are dropped.
+func (s *Service) route(batch []models.Point) map[destination][]models.Point {
+	dbTag, rpTag := []byte(s.config.DatabaseTag), []byte(s.config.RetentionPolicyTag)
+
+	groups := make(map[destination][]models.Point)
+	var rejected int64
+	for _, p := range batch {
+		dest := destination{database: s.config.Database, retentionPolicy: s.config.RetentionPolicy}
+
+		tags := p.Tags()
+		n := len(tags)
+		if len(dbTag) > 0 {
+			if v := tags.Get(dbTag); len(v) > 0 {
+				if !s.routedDatabase(v) {
+					rejected++
+					continue
+				}
+				dest.database = string(v)
+				// Fall back to the default retention policy of the routed
+				// database rather than the configured one.
+				dest.retentionPolicy = ""
+				tags.Delete(dbTag)
+			}
+		}
+		var rpTagged bool
+		if len(rpTag) > 0 {
+			if v := tags.Get(rpTag); len(v) > 0 {
+				dest.retentionPolicy, rpTagged = string(v), true
+				tags.Delete(rpTag)
+			}
+		}
+		if !rpTagged {
+			for _, m := range s.rpMappings {
+				if m.measurement.Match(p.Name()) {
+					dest.retentionPolicy = m.retentionPolicy
+					break
+				}
+			}
+		}
+		if len(tags) != n {
+			p.SetTags(tags)
+		}
+
+		groups[dest] = append(groups[dest], p)
+	}
+	if rejected > 0 {
+		atomic.AddInt64(&s.stats.PointsRouteRejected, rejected)
+	}
+	return groups
+}
+
+// routedDatabase returns true if points may be routed to the database db.
+func (s *Service) routedDatabase(db []byte) bool {
+	if len(s.routedDatabases) == 0 || string(db) == s.config.Database {
+		return true
+	}
+	for _, re := range s.routedDatabases {
+		if re.Match(db) {
+			return true
+		}
+	}
+	return false
+}
+
+// serve reads datagrams from conn and hands them to the parser.
+func (s *Service) serve(conn packetConn) {
 	defer s.wg.Done()
 
-	buf := make([]byte, MaxUDPPayload)
+	// The buffer is one byte larger than the largest accepted payload. The
+	// operating system truncates datagrams that do not fit the buffer, so a
+	// read filling it entirely identifies a datagram that is too large.
+	buf := make([]byte, s.config.UDPPayloadSize+1)
 	for {
 		select {
 		case <-s.done:
@@ -192,21 +789,84 @@ func (s *Service) serve() {
 			return
 		default:
 			// Keep processing.
-			n, _, err := s.conn.ReadFromUDP(buf)
+			n, addr, err := conn.ReadFrom(buf)
 			if err != nil {
 				atomic.AddInt64(&s.stats.ReadFail, 1)
 				s.Logger.Info("Failed to read UDP message", zap.Error(err))
 				continue
 			}
+			if n > s.config.UDPPayloadSize {
+				atomic.AddInt64(&s.stats.PacketsTooLarge, 1)
+				s.Logger.Info("Dropped datagram exceeding udp-payload-size",
+					zap.Int("udp_payload_size", s.config.UDPPayloadSize))
+				continue
+			}
 			atomic.AddInt64(&s.stats.BytesReceived, int64(n))
 
+			src := sourceName(addr)
+			atomic.AddInt64(&s.sourceStatistics(src).BytesReceived, int64(n))
+
 			bufCopy := make([]byte, n)
 			copy(bufCopy, buf[:n])
-			s.parserChan <- bufCopy
+			s.enqueuePacket(packet{buf: bufCopy, source: src})
 		}
 	}
 }
 
+// enqueuePacket hands pkt to the parser, applying the overflow policy if the
+// parser has fallen behind.
+func (s *Service) enqueuePacket(pkt packet) {
+	switch s.config.OverflowPolicy {
+	case OverflowDropNewest:
+		select {
+		case s.parserChan <- pkt:
+		default:
+			atomic.AddInt64(&s.stats.PacketsDropped, 1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.parserChan <- pkt:
+				return
+			default:
+			}
+
+			// Make room by discarding the oldest queued packet. The parser may
+			// have drained the queue in the meantime, so retry either way.
+			select {
+			case <-s.parserChan:
+				atomic.AddInt64(&s.stats.PacketsDropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.parserChan <- pkt:
+		case <-s.done:
+		}
+	}
+}
+
+// enqueuePoint hands p to the batcher. Unless the overflow policy is to block,
+// the point is discarded if the batcher is full. Points already queued in the
+// batcher cannot be discarded, so the newest point is dropped for both drop
+// policies.
+func (s *Service) enqueuePoint(p models.Point) {
+	if s.config.OverflowPolicy == OverflowBlock {
+		select {
+		case s.batcher.In() <- p:
+		case <-s.done:
+		}
+		return
+	}
+
+	select {
+	case s.batcher.In() <- p:
+	default:
+		atomic.AddInt64(&s.stats.PointsDropped, 1)
+	}
+}
+
 func (s *Service) parser() {
 	defer s.wg.Done()
 
@@ -214,22 +874,162 @@ func (s *Service) parser() {
 		select {
 		case <-s.done:
 			return
-		case buf := <-s.parserChan:
-			points, err := models.ParsePointsWithPrecision(buf, time.Now().UTC(), s.config.Precision)
+		case b := <-s.batcherCh:
+			s.mu.Lock()
+			old := s.batcher
+			s.batcher = b
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go s.retire(old)
+
+		case pkt := <-s.parserChan:
+			srcStats := s.sourceStatistics(pkt.source)
+
+			buf := pkt.buf
+			if s.config.SharedSecret != "" {
+				var err error
+				if buf, err = verifySignature([]byte(s.config.SharedSecret), buf); err != nil {
+					atomic.AddInt64(&s.stats.AuthFail, 1)
+					atomic.AddInt64(&srcStats.AuthFail, 1)
+					s.Logger.Info("Rejected unauthenticated packet",
+						zap.String("source", pkt.source), zap.Error(err))
+					s.capturePacket(pkt, err)
+					continue
+				}
+			}
+
+			buf, err := decompress(s.config.Compression, buf)
+			if err != nil {
+				atomic.AddInt64(&s.stats.DecompressFail, 1)
+				s.Logger.Info("Failed to decompress payload", zap.Error(err))
+				s.capturePacket(pkt, err)
+				continue
+			}
+
+			precision, buf, err := splitPrecision(buf, s.config.Precision)
 			if err != nil {
 				atomic.AddInt64(&s.stats.PointsParseFail, 1)
-				s.Logger.Info("Failed to parse points", zap.Error(err))
+				atomic.AddInt64(&srcStats.PointsParseFail, 1)
+				s.Logger.Info("Failed to parse precision prefix",
+					zap.String("source", pkt.source), zap.Error(err))
+				s.capturePacket(pkt, err)
 				continue
 			}
 
+			points, err := parsePoints(s.config.Format, buf, time.Now().UTC(), precision)
+			if err != nil {
+				atomic.AddInt64(&s.stats.PointsParseFail, 1)
+				atomic.AddInt64(&srcStats.PointsParseFail, 1)
+				s.Logger.Info("Failed to parse points",
+					zap.String("source", pkt.source), zap.Error(err))
+				s.capturePacket(pkt, err)
+				continue
+			}
+			s.capturePacket(pkt, nil)
+
 			for _, point := range points {
-				s.batcher.In() <- point
+				s.enqueuePoint(point)
 			}
 			atomic.AddInt64(&s.stats.PointsReceived, int64(len(points)))
+			atomic.AddInt64(&srcStats.PointsReceived, int64(len(points)))
 		}
 	}
 }
 
+// capturePacket records pkt in the capture file, if packet capture is
+// enabled. A nil err marks pkt as accepted, and accepted packets are only
+// recorded if every packet is captured.
+func (s *Service) capturePacket(pkt packet, err error) {
+	if s.capture == nil || (err == nil && s.config.CaptureMode != CaptureAll) {
+		return
+	}
+
+	ok, err := s.capture.capture(pkt, err)
+	if err != nil {
+		atomic.AddInt64(&s.stats.CaptureFail, 1)
+		s.Logger.Info("Failed to capture packet", zap.Error(err))
+	} else if ok {
+		atomic.AddInt64(&s.stats.PacketsCaptured, 1)
+	}
+}
+
+// retire stops a batcher that has been replaced, once all of the points
+// queued in it have been written. A dedicated writer drains b so that no
+// points are lost if the service is closed in the meantime.
+func (s *Service) retire(b *tsdb.PointBatcher) {
+	defer s.wg.Done()
+
+	stop := make(chan struct{})
+	s.wg.Add(1)
+	go s.writer(b, stop)
+
+	// The parser no longer sends to b, so its queue only drains from here on.
+	for len(b.In()) > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	b.Stop()
+	close(stop)
+}
+
+// Reload applies the batching and read buffer settings of c without closing
+// the listener. All other settings in c are ignored. Points already queued
+// are written using the previous batch settings.
+func (s *Service) Reload(c Config) error {
+	d := *c.WithDefaults()
+
+	s.mu.Lock()
+	if d.ReadBuffer != s.config.ReadBuffer && d.ReadBuffer != 0 {
+		for _, conn := range s.conns {
+			if err := conn.SetReadBuffer(d.ReadBuffer); err != nil {
+				s.mu.Unlock()
+				return err
+			}
+		}
+	}
+	s.config.ReadBuffer = d.ReadBuffer
+
+	changed := d.BatchSize != s.config.BatchSize ||
+		d.BatchPending != s.config.BatchPending ||
+		d.BatchTimeout != s.config.BatchTimeout
+	s.config.BatchSize = d.BatchSize
+	s.config.BatchPending = d.BatchPending
+	s.config.BatchTimeout = d.BatchTimeout
+
+	if !changed || s.closed() {
+		s.mu.Unlock()
+		return nil
+	}
+
+	b := tsdb.NewPointBatcher(d.BatchSize, d.BatchPending, time.Duration(d.BatchTimeout))
+	b.Start()
+	done := s.done
+	s.wg.Add(1)
+	go s.writer(b, done)
+	s.mu.Unlock()
+
+	// Hand the new batcher to the parser, which retires the current one.
+	select {
+	case s.batcherCh <- b:
+	case <-done:
+		// Nothing was sent to b, so stopping it emits nothing.
+		b.Stop()
+		return nil
+	}
+
+	s.Logger.Info("Reloaded UDP batch settings",
+		zap.Int("batch_size", d.BatchSize),
+		zap.Int("batch_pending", d.BatchPending),
+		zap.Duration("batch_timeout", time.Duration(d.BatchTimeout)),
+		zap.Int("read_buffer", d.ReadBuffer))
+	return nil
+}
+
+// BindAddress returns the configured bind address of the service.
+func (s *Service) BindAddress() string {
+	return s.config.BindAddress
+}
+
 // Close closes the service and the underlying listener.
 func (s *Service) Close() error {
 	if wait := func() bool {
@@ -241,9 +1041,7 @@ func (s *Service) Close() error {
 		}
 		close(s.done)
 
-		if s.conn != nil {
-			s.conn.Close()
-		}
+		s.closeConns()
 
 		if s.batcher != nil {
 			s.batcher.Stop()
@@ -257,8 +1055,12 @@ func (s *Service) Close() error {
 	// Release all remaining resources.
 	s.mu.Lock()
 	s.done = nil
-	s.conn = nil
 	s.batcher = nil
+	s.spill = nil
+	if s.capture != nil {
+		s.capture.Close()
+		s.capture = nil
+	}
 	s.mu.Unlock()
 
 	s.Logger.Info("Service closed")
@@ -303,12 +1105,58 @@ func (s *Service) createInternalStorage() error {
 	return nil
 }
 
+// createDatabase ensures that the database db has been created. Routed
+// databases are only created if CreateRoutedDatabases is set.
+func (s *Service) createDatabase(db string) error {
+	if db == s.config.Database {
+		return s.createInternalStorage()
+	} else if !s.config.CreateRoutedDatabases {
+		return nil
+	}
+
+	s.mu.RLock()
+	ready := s.routed[db]
+	s.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if _, err := s.MetaClient.CreateDatabase(db); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if len(s.routed) >= maxDestinations {
+		s.routed = make(map[string]bool)
+	}
+	s.routed[db] = true
+	s.mu.Unlock()
+	return nil
+}
+
 // WithLogger sets the logger on the service.
 func (s *Service) WithLogger(log *zap.Logger) {
 	s.Logger = log.With(zap.String("service", "udp"))
 }
 
-// Addr returns the listener's address.
+// Addr returns the bound address of the first listener, or nil if the
+// service is not open.
 func (s *Service) Addr() net.Addr {
-	return s.addr
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.addrs) == 0 {
+		return nil
+	}
+	return s.addrs[0]
+}
+
+// Addrs returns the bound addresses of all listeners, in the order of
+// Config.Addresses. A bind address using port 0 is reported with the port
+// chosen by the system.
+func (s *Service) Addrs() []net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	addrs := make([]net.Addr, len(s.addrs))
+	copy(addrs, s.addrs)
+	return addrs
 }
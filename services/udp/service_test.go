@@ -1,15 +1,30 @@
 package udp
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang/snappy"
 	"github.com/influxdata/influxdb/internal"
 	"github.com/influxdata/influxdb/logger"
 	"github.com/influxdata/influxdb/models"
 	"github.com/influxdata/influxdb/services/meta"
+	"github.com/influxdata/influxdb/toml"
+	"github.com/influxdata/influxdb/tsdb"
 )
 
 func TestService_OpenClose(t *testing.T) {
@@ -124,6 +139,788 @@ func TestService_CreatesDatabase(t *testing.T) {
 	s.Service.Close()
 }
 
+func TestService_RoutesPointsByTag(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.DatabaseTag = "db"
+	c.RetentionPolicyTag = "rp"
+	c.RoutedDatabases = []string{"fo+"}
+	c.CreateRoutedDatabases = true
+	s := NewTestService(&c)
+	var mu sync.Mutex
+	created := make(map[string]bool)
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		created[name] = true
+		return nil, nil
+	}
+
+	type write struct {
+		database, retentionPolicy string
+		points                    []string
+	}
+	written := make(chan write, 3)
+	s.WritePointsFn = func(database, retentionPolicy string, _ models.ConsistencyLevel, points []models.Point) error {
+		w := write{database: database, retentionPolicy: retentionPolicy}
+		for _, p := range points {
+			w.points = append(w.points, p.String())
+		}
+		written <- w
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	points, err := models.ParsePointsString(`cpu,db=foo,host=a value=1 1
+cpu,db=foo,rp=short,host=b value=2 2
+cpu,host=c value=3 3
+cpu,db=xfoo,host=d value=4 4`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range points {
+		s.Service.batcher.In() <- p
+	}
+	s.Service.batcher.Flush()
+
+	got := make(map[string]write)
+	for i := 0; i < 3; i++ {
+		select {
+		case w := <-written:
+			got[w.database+"."+w.retentionPolicy] = w
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for points to be written")
+		}
+	}
+
+	exp := map[string]write{
+		"foo.":      {database: "foo", points: []string{"cpu,host=a value=1 1"}},
+		"foo.short": {database: "foo", retentionPolicy: "short", points: []string{"cpu,host=b value=2 2"}},
+		"udp.":      {database: "udp", points: []string{"cpu,host=c value=3 3"}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected writes:\n\tgot = %v\n\texp = %v", got, exp)
+	}
+
+	mu.Lock()
+	if exp := map[string]bool{"foo": true, "udp": true}; !reflect.DeepEqual(created, exp) {
+		t.Fatalf("unexpected databases created: %v", created)
+	}
+	mu.Unlock()
+
+	var dbStats int
+	for _, stat := range s.Service.Statistics(nil) {
+		switch stat.Name {
+		case "udp":
+			if got, exp := stat.Values[statPointsRouteRejected], int64(1); got != exp {
+				t.Fatalf("got %v rejected points, expected %d", got, exp)
+			}
+		case "udp_database":
+			dbStats++
+		}
+	}
+	if got, exp := dbStats, 3; got != exp {
+		t.Fatalf("got %d database statistics, expected %d", got, exp)
+	}
+}
+
+func TestService_DoesNotCreateRoutedDatabases(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.DatabaseTag = "db"
+	s := NewTestService(&c)
+	var mu sync.Mutex
+	var created []string
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		created = append(created, name)
+		return nil, nil
+	}
+
+	written := make(chan string, 1)
+	s.WritePointsFn = func(database, _ string, _ models.ConsistencyLevel, _ []models.Point) error {
+		written <- database
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	points, err := models.ParsePointsString(`cpu,db=foo,host=a value=1 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Service.batcher.In() <- points[0]
+	s.Service.batcher.Flush()
+
+	select {
+	case db := <-written:
+		if db != "foo" {
+			t.Fatalf("got database %q, expected %q", db, "foo")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for points to be written")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(created) != 0 {
+		t.Fatalf("unexpected databases created: %v", created)
+	}
+}
+
+func TestService_RetentionPolicyMappings(t *testing.T) {
+	c := NewConfig()
+	c.RetentionPolicy = "default"
+	c.RetentionPolicyTag = "rp"
+	c.RetentionPolicyMappings = []RetentionPolicyMapping{
+		{Measurement: "^cpu", RetentionPolicy: "short"},
+		{Measurement: "^disk$", RetentionPolicy: "long"},
+		{Measurement: "^disk", RetentionPolicy: "unreachable"},
+	}
+	s := NewService(c)
+
+	var err error
+	if s.rpMappings, err = compileRetentionPolicyMappings(c.RetentionPolicyMappings); err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := models.ParsePointsString(`cpu_load value=1 1
+cpu_load,rp=explicit value=2 2
+disk value=3 3
+mem value=4 4`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string][]string)
+	for dest, points := range s.route(points) {
+		for _, p := range points {
+			got[dest.retentionPolicy] = append(got[dest.retentionPolicy], p.String())
+		}
+	}
+
+	exp := map[string][]string{
+		"short":    {"cpu_load value=1 1"},
+		"explicit": {"cpu_load value=2 2"},
+		"long":     {"disk value=3 3"},
+		"default":  {"mem value=4 4"},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected routes:\n\tgot = %v\n\texp = %v", got, exp)
+	}
+}
+
+func TestService_DecompressesPayloads(t *testing.T) {
+	t.Parallel()
+
+	const data = "cpu value=1 1\n"
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(data))
+	w.Close()
+
+	var framed bytes.Buffer
+	sw := snappy.NewWriter(&framed)
+	sw.Write([]byte(data))
+	sw.Close()
+
+	block := snappy.Encode(nil, []byte(data))
+
+	for _, tt := range []struct {
+		name        string
+		compression string
+		payload     []byte
+	}{
+		{name: "none", compression: CompressionNone, payload: []byte(data)},
+		{name: "auto plain", compression: CompressionAuto, payload: []byte(data)},
+		{name: "auto gzip", compression: CompressionAuto, payload: gz.Bytes()},
+		{name: "auto snappy", compression: CompressionAuto, payload: framed.Bytes()},
+		{name: "gzip", compression: CompressionGzip, payload: gz.Bytes()},
+		{name: "snappy block", compression: CompressionSnappy, payload: block},
+		{name: "snappy framed", compression: CompressionSnappy, payload: framed.Bytes()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfig()
+			c.Compression = tt.compression
+			s := NewTestService(&c)
+			s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+				return nil, nil
+			}
+
+			written := make(chan []models.Point, 1)
+			s.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, points []models.Point) error {
+				written <- points
+				return nil
+			}
+
+			if err := s.Service.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer s.Service.Close()
+
+			s.Service.parserChan <- packet{buf: tt.payload, source: "127.0.0.1"}
+			var points []models.Point
+			timeout := time.After(5 * time.Second)
+			for len(points) == 0 {
+				select {
+				case points = <-written:
+				case <-timeout:
+					t.Fatal("timed out waiting for points to be written")
+				case <-time.After(10 * time.Millisecond):
+					s.Service.batcher.Flush()
+				}
+			}
+
+			if got, exp := points[0].String(), "cpu value=1 1"; got != exp {
+				t.Fatalf("unexpected point: got %q, expected %q", got, exp)
+			}
+		})
+	}
+}
+
+func TestService_DecompressRejectsInvalidPayload(t *testing.T) {
+	if _, err := decompress(CompressionGzip, []byte("cpu value=1")); err == nil {
+		t.Fatal("expected error decompressing plain payload as gzip")
+	}
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(make([]byte, MaxDecompressedPayload+1))
+	w.Close()
+	if _, err := decompress(CompressionAuto, gz.Bytes()); err != errPayloadTooLarge {
+		t.Fatalf("unexpected error: got %v, expected %v", err, errPayloadTooLarge)
+	}
+}
+
+func TestService_Unixgram(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "udp-unixgram-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "influxdb-udp.sock")
+
+	c := NewConfig()
+	c.BindAddress = "unixgram://" + path
+	c.BatchSize = 1
+	s := NewTestService(&c)
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	written := make(chan []models.Point, 1)
+	s.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, points []models.Point) error {
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("cpu value=1 1\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case points := <-written:
+		if got, exp := points[0].String(), "cpu value=1 1"; got != exp {
+			t.Fatalf("unexpected point: got %q, expected %q", got, exp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for points to be written")
+	}
+
+	if err := s.Service.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected socket to be removed, got %v", err)
+	}
+}
+
+func TestService_MultipleBindAddresses(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "udp-bind-addresses-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "influxdb-udp.sock")
+
+	c := NewConfig()
+	c.BindAddress = "127.0.0.1:0"
+	c.BindAddresses = []string{"unixgram://" + path}
+	c.BatchSize = 2
+	s := NewTestService(&c)
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	written := make(chan []models.Point, 1)
+	s.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, points []models.Point) error {
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	if got, exp := len(s.Service.Addrs()), 2; got != exp {
+		t.Fatalf("got %d listeners, expected %d", got, exp)
+	}
+
+	for i, addr := range s.Service.Addrs() {
+		conn, err := net.Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fmt.Fprintf(conn, "cpu value=%d %d\n", i, i); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	select {
+	case points := <-written:
+		if got, exp := len(points), 2; got != exp {
+			t.Fatalf("got %d points, expected %d", got, exp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for points to be written")
+	}
+}
+
+func TestService_SourceStatistics(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.BindAddress = "127.0.0.1:0"
+	s := NewTestService(&c)
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+	s.WritePointsFn = func(string, string, models.ConsistencyLevel, []models.Point) error {
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn, err := net.Dial("udp", s.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, payload := range []string{"cpu value=1 1\ncpu value=2 2\n", "cpu value="} {
+		if _, err := conn.Write([]byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		var values map[string]interface{}
+		for _, stat := range s.Service.Statistics(nil) {
+			if stat.Name == "udp_source" && stat.Tags["source"] == "127.0.0.1" {
+				values = stat.Values
+			}
+		}
+
+		if values != nil && values[statPointsReceived] == int64(2) && values[statPointsParseFail] == int64(1) {
+			if got, exp := values[statBytesReceived], int64(38); got != exp {
+				t.Fatalf("unexpected bytes received: got %v, expected %v", got, exp)
+			}
+			return
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("timed out waiting for source statistics, got %v", values)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestService_SourceStatistics_Limit(t *testing.T) {
+	s := NewService(NewConfig())
+	for i := 0; i < maxSources; i++ {
+		s.sourceStatistics(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+
+	if s.sourceStatistics("192.168.0.1") != s.srcStats[otherSource] {
+		t.Fatal("expected statistics for new sources to be accounted as other")
+	}
+	if got, exp := len(s.srcStats), maxSources+1; got != exp {
+		t.Fatalf("got %d sources, expected %d", got, exp)
+	}
+}
+
+func TestService_DatabaseStatistics_Limit(t *testing.T) {
+	s := NewService(NewConfig())
+	for i := 0; i < maxDestinations; i++ {
+		s.databaseStatistics(destination{database: fmt.Sprintf("db%d", i)})
+	}
+
+	if s.databaseStatistics(destination{database: "foo"}) != s.dbStats[destination{database: otherDestination}] {
+		t.Fatal("expected statistics for new destinations to be accounted as other")
+	}
+	if got, exp := len(s.dbStats), maxDestinations+1; got != exp {
+		t.Fatalf("got %d destinations, expected %d", got, exp)
+	}
+}
+
+// Ensure only batches that may be written later are spilled.
+func TestService_SpillsRetryableFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "udp-spill-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewTestService(nil)
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+	if s.Service.spill, err = openSpillQueue(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := models.ParsePointsString("cpu value=1 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := destination{database: "udp"}
+
+	s.WritePointsFn = func(string, string, models.ConsistencyLevel, []models.Point) error {
+		return tsdb.PartialWriteError{Reason: "field type conflict", Dropped: 1}
+	}
+	s.Service.writeBatch(dest, points)
+	if got := s.Service.spill.Size(); got != 0 {
+		t.Fatalf("expected partial write not to be spilled, spill size %d", got)
+	}
+
+	s.WritePointsFn = func(string, string, models.ConsistencyLevel, []models.Point) error {
+		return errors.New("timeout")
+	}
+	s.Service.writeBatch(dest, points)
+	if got, exp := s.Service.stats.BatchesSpilled, int64(1); got != exp {
+		t.Fatalf("unexpected batches spilled: got %d, expected %d", got, exp)
+	}
+}
+
+func TestService_OverflowPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy string
+		exp    string
+	}{
+		{policy: OverflowDropNewest, exp: "first"},
+		{policy: OverflowDropOldest, exp: "second"},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			c := NewConfig()
+			c.OverflowPolicy = tt.policy
+			s := NewService(c)
+			s.parserChan = make(chan packet, 1)
+
+			s.enqueuePacket(packet{buf: []byte("first")})
+			s.enqueuePacket(packet{buf: []byte("second")})
+
+			if got := string((<-s.parserChan).buf); got != tt.exp {
+				t.Fatalf("unexpected queued packet: got %q, expected %q", got, tt.exp)
+			}
+			if got, exp := s.stats.PacketsDropped, int64(1); got != exp {
+				t.Fatalf("unexpected packets dropped: got %d, expected %d", got, exp)
+			}
+		})
+	}
+}
+
+func TestService_Reload(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.BindAddress = "127.0.0.1:0"
+	c.BatchSize = 1000
+	c.BatchTimeout = toml.Duration(time.Hour)
+	s := NewTestService(&c)
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	written := make(chan []models.Point, 2)
+	s.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, points []models.Point) error {
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+	addr := s.Service.Addr()
+
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The first point is queued in the original batcher and must be written
+	// once that batcher is retired.
+	if _, err := conn.Write([]byte("cpu value=1 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(5 * time.Second)
+	for atomic.LoadInt64(&s.Service.stats.PointsReceived) == 0 {
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for point to be received")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	c.BatchSize = 1
+	if err := s.Service.Reload(c); err != nil {
+		t.Fatal(err)
+	}
+	if s.Service.Addr() != addr {
+		t.Fatal("expected listener to be kept open across reload")
+	}
+
+	if _, err := conn.Write([]byte("cpu value=2 2\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case points := <-written:
+			for _, p := range points {
+				got = append(got, p.String())
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for points to be written, got %v", got)
+		}
+	}
+	sort.Strings(got)
+	if exp := []string{"cpu value=1 1", "cpu value=2 2"}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected points:\n\tgot = %v\n\texp = %v", got, exp)
+	}
+}
+
+func TestService_DropsOversizedPayloads(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.BindAddress = "127.0.0.1:0"
+	c.UDPPayloadSize = 16
+	s := NewTestService(&c)
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+	s.WritePointsFn = func(string, string, models.ConsistencyLevel, []models.Point) error {
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	conn, err := net.Dial("udp", s.Service.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The first datagram exceeds the payload size, the second fits exactly.
+	for _, payload := range []string{"cpu value=1 1000000000\n", "cpu value=1 100\n"} {
+		if _, err := conn.Write([]byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeout := time.After(5 * time.Second)
+	for atomic.LoadInt64(&s.Service.stats.PointsReceived) == 0 {
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for point to be received")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if got, exp := atomic.LoadInt64(&s.Service.stats.PacketsTooLarge), int64(1); got != exp {
+		t.Fatalf("unexpected packets too large: got %d, expected %d", got, exp)
+	}
+	if got, exp := atomic.LoadInt64(&s.Service.stats.BytesReceived), int64(16); got != exp {
+		t.Fatalf("unexpected bytes received: got %d, expected %d", got, exp)
+	}
+}
+
+func TestService_SharedSecret(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.SharedSecret = "secret"
+	s := NewTestService(&c)
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	written := make(chan []models.Point, 1)
+	s.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, points []models.Point) error {
+		written <- points
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	sign := func(key, payload string) []byte {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(payload))
+		return append(mac.Sum(nil), payload...)
+	}
+
+	for _, buf := range [][]byte{
+		[]byte("cpu value=1 1\n"),
+		sign("wrong", "cpu value=2 2\n"),
+		sign("secret", "cpu value=3 3\n"),
+	} {
+		s.Service.parserChan <- packet{buf: buf, source: "127.0.0.1"}
+	}
+
+	var points []models.Point
+	timeout := time.After(5 * time.Second)
+	for len(points) == 0 {
+		select {
+		case points = <-written:
+		case <-timeout:
+			t.Fatal("timed out waiting for points to be written")
+		case <-time.After(10 * time.Millisecond):
+			s.Service.batcher.Flush()
+		}
+	}
+
+	if got, exp := len(points), 1; got != exp {
+		t.Fatalf("got %d points, expected %d", got, exp)
+	} else if got, exp := points[0].String(), "cpu value=3 3"; got != exp {
+		t.Fatalf("unexpected point: got %q, expected %q", got, exp)
+	}
+	if got, exp := atomic.LoadInt64(&s.Service.stats.AuthFail), int64(2); got != exp {
+		t.Fatalf("unexpected auth failures: got %d, expected %d", got, exp)
+	}
+}
+
+func TestService_WriteRetries(t *testing.T) {
+	t.Parallel()
+
+	c := NewConfig()
+	c.ConsistencyLevel = "one"
+	c.WriteRetries = 2
+	c.WriteRetryBackoff = toml.Duration(time.Millisecond)
+	s := NewTestService(&c)
+	s.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	var attempts int
+	s.WritePointsFn = func(_, _ string, cl models.ConsistencyLevel, _ []models.Point) error {
+		if cl != models.ConsistencyLevelOne {
+			t.Errorf("unexpected consistency level: got %v, expected %v", cl, models.ConsistencyLevelOne)
+		}
+		if attempts++; attempts < 3 {
+			return errors.New("timeout")
+		}
+		return nil
+	}
+
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	points, err := models.ParsePointsString(`cpu value=1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest := destination{database: c.Database}
+	if err := s.Service.writePoints(dest, points); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if got, exp := attempts, 3; got != exp {
+		t.Fatalf("got %d attempts, expected %d", got, exp)
+	}
+
+	// Retries are exhausted.
+	attempts = 0
+	s.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, _ []models.Point) error {
+		attempts++
+		return errors.New("timeout")
+	}
+	if err := s.Service.writePoints(dest, points); err == nil {
+		t.Fatal("expected error after retries are exhausted")
+	} else if got, exp := attempts, 3; got != exp {
+		t.Fatalf("got %d attempts, expected %d", got, exp)
+	}
+
+	// Partial writes are not retried.
+	attempts = 0
+	s.WritePointsFn = func(_, _ string, _ models.ConsistencyLevel, _ []models.Point) error {
+		attempts++
+		return tsdb.PartialWriteError{Reason: "field type conflict", Dropped: 1}
+	}
+	if err := s.Service.writePoints(dest, points); err == nil {
+		t.Fatal("expected partial write error")
+	} else if got, exp := attempts, 1; got != exp {
+		t.Fatalf("got %d attempts, expected %d", got, exp)
+	}
+
+	if got, exp := atomic.LoadInt64(&s.Service.stats.WriteRetries), int64(4); got != exp {
+		t.Fatalf("unexpected write retries: got %d, expected %d", got, exp)
+	}
+}
+
+func TestService_Multicast_UnknownInterface(t *testing.T) {
+	c := NewConfig()
+	c.BindAddress = "239.0.0.1:0"
+	c.MulticastInterface = "no-such-interface"
+	s := NewTestService(&c)
+
+	if err := s.Service.Open(); err == nil {
+		s.Service.Close()
+		t.Fatal("expected error joining multicast group on unknown interface")
+	}
+}
+
 type TestService struct {
 	Service       *Service
 	Config        Config
@@ -0,0 +1,209 @@
+package udp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/pkg/file"
+)
+
+// spillFileExt is the extension of files written by the spill queue.
+const spillFileExt = ".spill"
+
+// errSpillFull is returned when a batch does not fit into the spill queue.
+var errSpillFull = errors.New("spill queue is full")
+
+// spillHeader is the first line of a spill file, identifying where the
+// batch stored in the rest of the file is written to.
+type spillHeader struct {
+	Database        string `json:"database"`
+	RetentionPolicy string `json:"retention-policy"`
+}
+
+// spillQueue persists batches that could not be written, one file per batch,
+// so that they can be replayed once writes succeed again. Batches are stored
+// as line protocol with nanosecond timestamps, and are synced to disk before
+// push returns.
+type spillQueue struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	size    int64
+	seq     uint64
+}
+
+// openSpillQueue opens the spill queue in dir, creating dir if necessary.
+// Batches left over from a previous run are kept and count towards maxSize.
+func openSpillQueue(dir string, maxSize int64) (*spillQueue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	q := &spillQueue{dir: dir, maxSize: maxSize}
+	names, err := q.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		q.size += fi.Size()
+	}
+	return q, nil
+}
+
+// Size returns the number of bytes currently queued.
+func (q *spillQueue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// push appends a batch for dest to the queue.
+func (q *spillQueue) push(dest destination, points []models.Point) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(spillHeader{Database: dest.database, RetentionPolicy: dest.retentionPolicy}); err != nil {
+		return err
+	}
+	for _, p := range points {
+		buf.WriteString(p.String())
+		buf.WriteByte('\n')
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.maxSize > 0 && q.size+int64(buf.Len()) > q.maxSize {
+		return errSpillFull
+	}
+
+	// Names sort in the order the batches were queued.
+	q.seq++
+	name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), q.seq, spillFileExt)
+	path := filepath.Join(q.dir, name)
+	if err := writeSpillFile(path+".tmp", buf.Bytes()); err != nil {
+		os.Remove(path + ".tmp")
+		return err
+	}
+	if err := file.RenameFile(path+".tmp", path); err != nil {
+		os.Remove(path + ".tmp")
+		return err
+	}
+	q.size += int64(buf.Len())
+	return file.SyncDir(q.dir)
+}
+
+// writeSpillFile writes b to a new file at path and syncs it to disk.
+func writeSpillFile(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replay passes the queued batches, oldest first, to write and removes each
+// batch that is written successfully. A batch whose write fails permanently
+// can never be replayed, so it is dropped rather than blocking the queue.
+// Replay stops at the first other failed write, leaving that batch and all
+// later ones queued. It returns the number of batches replayed and dropped.
+func (q *spillQueue) replay(write func(dest destination, points []models.Point) error) (replayed, dropped int, err error) {
+	names, err := q.files()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, name := range names {
+		path := filepath.Join(q.dir, name)
+		dest, points, size, err := readSpillFile(path)
+		if err != nil {
+			// A corrupt batch can never be replayed either.
+			q.remove(path, size)
+			return replayed, dropped + 1, fmt.Errorf("dropped unreadable spill file %s: %s", name, err)
+		}
+
+		if err := write(dest, points); permanentWriteError(err) {
+			q.remove(path, size)
+			dropped++
+			continue
+		} else if err != nil {
+			return replayed, dropped, err
+		}
+		q.remove(path, size)
+		replayed++
+	}
+	return replayed, dropped, nil
+}
+
+// remove deletes the spill file at path, whose size is size.
+func (q *spillQueue) remove(path string, size int64) {
+	if err := os.Remove(path); err != nil {
+		return
+	}
+	q.mu.Lock()
+	q.size -= size
+	q.mu.Unlock()
+}
+
+// files returns the names of the queued spill files, oldest first.
+func (q *spillQueue) files() ([]string, error) {
+	fis, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, fi := range fis {
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), spillFileExt) {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readSpillFile reads the batch stored at path. The size of the file is
+// returned even if the batch cannot be decoded.
+func readSpillFile(path string) (destination, []models.Point, int64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return destination{}, nil, 0, err
+	}
+	size := int64(len(b))
+
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return destination{}, nil, size, errors.New("missing spill header")
+	}
+
+	var hdr spillHeader
+	if err := json.Unmarshal(b[:i], &hdr); err != nil {
+		return destination{}, nil, size, err
+	}
+
+	points, err := models.ParsePoints(b[i+1:])
+	if err != nil {
+		return destination{}, nil, size, err
+	}
+	return destination{database: hdr.Database, retentionPolicy: hdr.RetentionPolicy}, points, size, nil
+}
@@ -0,0 +1,152 @@
+package udp
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/tsdb"
+)
+
+func TestSpillQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "udp-spill-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := openSpillQueue(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range []struct {
+		dest   destination
+		points string
+	}{
+		{dest: destination{database: "db0"}, points: "cpu value=1 1\ncpu value=2 2"},
+		{dest: destination{database: "db1", retentionPolicy: "rp"}, points: "mem value=3 3"},
+	} {
+		points, err := models.ParsePointsString(b.points)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := q.push(b.dest, points); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A failing write leaves every batch queued.
+	errWrite := errors.New("write failed")
+	if n, dropped, err := q.replay(func(destination, []models.Point) error { return errWrite }); err != errWrite || n != 0 || dropped != 0 {
+		t.Fatalf("unexpected replay result: n=%d, dropped=%d, err=%v", n, dropped, err)
+	}
+
+	// Reopening the queue accounts for the queued batches.
+	size := q.Size()
+	if q, err = openSpillQueue(dir, 0); err != nil {
+		t.Fatal(err)
+	} else if got := q.Size(); got != size || size == 0 {
+		t.Fatalf("unexpected size after reopen: got %d, expected %d", got, size)
+	}
+
+	type batch struct {
+		dest   destination
+		points []string
+	}
+	var got []batch
+	n, _, err := q.replay(func(dest destination, points []models.Point) error {
+		b := batch{dest: dest}
+		for _, p := range points {
+			b.points = append(b.points, p.String())
+		}
+		got = append(got, b)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("replayed %d batches, expected 2", n)
+	}
+
+	exp := []batch{
+		{dest: destination{database: "db0"}, points: []string{"cpu value=1 1", "cpu value=2 2"}},
+		{dest: destination{database: "db1", retentionPolicy: "rp"}, points: []string{"mem value=3 3"}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("unexpected batches:\n\tgot = %v\n\texp = %v", got, exp)
+	}
+	if got := q.Size(); got != 0 {
+		t.Fatalf("unexpected size after replay: %d", got)
+	}
+}
+
+func TestSpillQueue_MaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "udp-spill-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := openSpillQueue(dir, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := models.ParsePointsString("cpu value=1 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.push(destination{database: "db0"}, points); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.push(destination{database: "db0"}, points); err != errSpillFull {
+		t.Fatalf("unexpected error: got %v, expected %v", err, errSpillFull)
+	}
+}
+
+// Ensure batches whose write fails permanently are dropped without blocking
+// the batches queued after them.
+func TestSpillQueue_DropsPermanentFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "udp-spill-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := openSpillQueue(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, db := range []string{"db0", "db1"} {
+		points, err := models.ParsePointsString("cpu value=1 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := q.push(destination{database: db}, points); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var written []string
+	n, dropped, err := q.replay(func(dest destination, points []models.Point) error {
+		if dest.database == "db0" {
+			return tsdb.PartialWriteError{Reason: "field type conflict", Dropped: len(points)}
+		}
+		written = append(written, dest.database)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 1 || dropped != 1 {
+		t.Fatalf("unexpected replay result: n=%d, dropped=%d", n, dropped)
+	} else if !reflect.DeepEqual(written, []string{"db1"}) {
+		t.Fatalf("unexpected batches written: %v", written)
+	} else if got := q.Size(); got != 0 {
+		t.Fatalf("unexpected size after replay: %d", got)
+	}
+}
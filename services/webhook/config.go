@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/influxdb/monitor/diagnostics"
+)
+
+const (
+	// DefaultBindAddress is the default binding interface if none is specified.
+	DefaultBindAddress = ":8095"
+
+	// DefaultDatabase is the default database for webhook payloads.
+	DefaultDatabase = "webhooks"
+
+	// DefaultRetentionPolicy is the default retention policy used for writes.
+	DefaultRetentionPolicy = ""
+
+	// DefaultMaxBodySize is the default size of the largest payload accepted.
+	DefaultMaxBodySize = 1024 * 1024
+
+	// DefaultTimeFormat is the default format of the timestamps of payloads.
+	DefaultTimeFormat = "rfc3339"
+
+	// DefaultCertificate is the default location of the certificate used when
+	// TLS is enabled.
+	DefaultCertificate = "/etc/ssl/influxdb.pem"
+)
+
+// Config represents the configuration of a webhook listener.
+type Config struct {
+	Enabled         bool   `toml:"enabled"`
+	BindAddress     string `toml:"bind-address"`
+	Database        string `toml:"database"`
+	RetentionPolicy string `toml:"retention-policy"`
+	MaxBodySize     int    `toml:"max-body-size"`
+
+	// Token, if set, must be sent with every payload, either in the "token"
+	// query parameter or as a bearer token in the Authorization header.
+	Token string `toml:"token"`
+
+	// TLS settings. PrivateKey defaults to Certificate.
+	TLSEnabled  bool   `toml:"tls-enabled"`
+	Certificate string `toml:"certificate"`
+	PrivateKey  string `toml:"private-key"`
+
+	// Endpoints are the URL paths payloads are posted to, and how their
+	// payloads are mapped to points.
+	Endpoints []Endpoint `toml:"endpoint"`
+}
+
+// Endpoint maps the JSON payloads posted to Path to points. The values of
+// points are selected by paths, whose syntax is described in the README.
+type Endpoint struct {
+	Path            string `toml:"path"`
+	RetentionPolicy string `toml:"retention-policy"`
+
+	// Measurement names the measurement of points, unless MeasurementPath
+	// selects a string of the payload naming it.
+	Measurement     string `toml:"measurement"`
+	MeasurementPath string `toml:"measurement-path"`
+
+	// PointsPath, if set, selects an array of the payload, each element of
+	// which is written as a point. Otherwise the payload is written as a
+	// single point, or a point per element if it is an array.
+	PointsPath string `toml:"points-path"`
+
+	// TimePath selects the timestamp of points, in TimeFormat: "rfc3339",
+	// "unix", "unix_ms", "unix_us", "unix_ns" or a Go time layout. Points
+	// without a timestamp are assigned the time the payload is received.
+	TimePath   string `toml:"time-path"`
+	TimeFormat string `toml:"time-format"`
+
+	// Tags and Fields map tag and field keys to the paths of their values.
+	Tags   map[string]string `toml:"tags"`
+	Fields map[string]string `toml:"fields"`
+}
+
+// NewConfig returns a new instance of Config with defaults.
+func NewConfig() Config {
+	return Config{
+		BindAddress:     DefaultBindAddress,
+		Database:        DefaultDatabase,
+		RetentionPolicy: DefaultRetentionPolicy,
+		MaxBodySize:     DefaultMaxBodySize,
+		Certificate:     DefaultCertificate,
+	}
+}
+
+// WithDefaults takes the given config and returns a new config with any required
+// default values set.
+func (c *Config) WithDefaults() *Config {
+	d := *c
+	if d.BindAddress == "" {
+		d.BindAddress = DefaultBindAddress
+	}
+	if d.Database == "" {
+		d.Database = DefaultDatabase
+	}
+	if d.MaxBodySize == 0 {
+		d.MaxBodySize = DefaultMaxBodySize
+	}
+	if d.Certificate == "" {
+		d.Certificate = DefaultCertificate
+	}
+	d.Endpoints = make([]Endpoint, len(c.Endpoints))
+	for i, e := range c.Endpoints {
+		if e.RetentionPolicy == "" {
+			e.RetentionPolicy = d.RetentionPolicy
+		}
+		if e.TimeFormat == "" {
+			e.TimeFormat = DefaultTimeFormat
+		}
+		d.Endpoints[i] = e
+	}
+	return &d
+}
+
+// Validate returns an error if the config is invalid.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.MaxBodySize < 0 {
+		return errors.New("max-body-size must not be negative")
+	} else if len(c.Endpoints) == 0 {
+		return errors.New("at least one endpoint must be configured")
+	}
+
+	seen := make(map[string]bool, len(c.Endpoints))
+	for _, e := range c.Endpoints {
+		if !strings.HasPrefix(e.Path, "/") {
+			return fmt.Errorf("endpoint path %q must start with '/'", e.Path)
+		} else if seen[e.Path] {
+			return fmt.Errorf("duplicate endpoint path %q", e.Path)
+		}
+		seen[e.Path] = true
+
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("endpoint %s: %s", e.Path, err)
+		}
+	}
+	return nil
+}
+
+func (e *Endpoint) validate() error {
+	if e.Measurement == "" && e.MeasurementPath == "" {
+		return errors.New("measurement or measurement-path must be set")
+	} else if len(e.Fields) == 0 {
+		return errors.New("at least one field must be mapped")
+	}
+
+	switch e.TimeFormat {
+	case "", "rfc3339", "unix", "unix_ms", "unix_us", "unix_ns":
+	default:
+		if !strings.Contains(e.TimeFormat, "2006") {
+			return fmt.Errorf("invalid time-format %q", e.TimeFormat)
+		}
+	}
+
+	for name, p := range map[string]string{
+		"measurement-path": e.MeasurementPath,
+		"points-path":      e.PointsPath,
+		"time-path":        e.TimePath,
+	} {
+		if _, err := parsePath(p); err != nil {
+			return fmt.Errorf("invalid %s %q: %s", name, p, err)
+		}
+	}
+	for key, p := range e.Tags {
+		if _, err := parsePath(p); err != nil || p == "" {
+			return fmt.Errorf("invalid path %q of tag %q", p, key)
+		}
+	}
+	for key, p := range e.Fields {
+		if _, err := parsePath(p); err != nil || p == "" {
+			return fmt.Errorf("invalid path %q of field %q", p, key)
+		}
+	}
+	return nil
+}
+
+// Configs wraps a slice of Config to aggregate diagnostics.
+type Configs []Config
+
+// Diagnostics returns one set of diagnostics for all of the Configs.
+func (c Configs) Diagnostics() (*diagnostics.Diagnostics, error) {
+	d := &diagnostics.Diagnostics{
+		Columns: []string{"enabled", "bind-address", "database", "retention-policy", "endpoints", "tls-enabled"},
+	}
+
+	for _, cc := range c {
+		if !cc.Enabled {
+			d.AddRow([]interface{}{false})
+			continue
+		}
+
+		paths := make([]string, 0, len(cc.Endpoints))
+		for _, e := range cc.Endpoints {
+			paths = append(paths, e.Path)
+		}
+		r := []interface{}{true, cc.BindAddress, cc.Database, cc.RetentionPolicy, strings.Join(paths, ","), cc.TLSEnabled}
+		d.AddRow(r)
+	}
+
+	return d, nil
+}
+
+// Enabled returns true if any underlying Config is Enabled.
+func (c Configs) Enabled() bool {
+	for _, cc := range c {
+		if cc.Enabled {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package webhook_test
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/influxdata/influxdb/services/webhook"
+)
+
+func TestConfig_Parse(t *testing.T) {
+	// Parse configuration.
+	var c webhook.Config
+	if _, err := toml.Decode(`
+enabled = true
+bind-address = ":9000"
+database = "alerts"
+token = "secret"
+
+[[endpoint]]
+  path = "/alertmanager"
+  measurement = "alert"
+  points-path = "alerts"
+  time-path = "startsAt"
+
+  [endpoint.tags]
+    alertname = "labels.alertname"
+    receiver = "$.receiver"
+
+  [endpoint.fields]
+    status = "status"
+
+[[endpoint]]
+  path = "/ci"
+  measurement-path = "kind"
+  time-format = "unix_ms"
+  fields = { duration = "build.duration" }
+`, &c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Validate configuration.
+	if !c.Enabled {
+		t.Fatalf("unexpected enabled: %v", c.Enabled)
+	} else if c.BindAddress != ":9000" {
+		t.Fatalf("unexpected bind address: %s", c.BindAddress)
+	} else if c.Database != "alerts" {
+		t.Fatalf("unexpected database: %s", c.Database)
+	} else if c.Token != "secret" {
+		t.Fatalf("unexpected token: %s", c.Token)
+	} else if len(c.Endpoints) != 2 {
+		t.Fatalf("unexpected endpoints: %d", len(c.Endpoints))
+	}
+
+	e := c.Endpoints[0]
+	if e.Path != "/alertmanager" {
+		t.Fatalf("unexpected path: %s", e.Path)
+	} else if e.Measurement != "alert" {
+		t.Fatalf("unexpected measurement: %s", e.Measurement)
+	} else if e.PointsPath != "alerts" {
+		t.Fatalf("unexpected points path: %s", e.PointsPath)
+	} else if e.TimePath != "startsAt" {
+		t.Fatalf("unexpected time path: %s", e.TimePath)
+	} else if len(e.Tags) != 2 || e.Tags["receiver"] != "$.receiver" {
+		t.Fatalf("unexpected tags: %v", e.Tags)
+	} else if len(e.Fields) != 1 || e.Fields["status"] != "status" {
+		t.Fatalf("unexpected fields: %v", e.Fields)
+	}
+
+	e = c.Endpoints[1]
+	if e.MeasurementPath != "kind" {
+		t.Fatalf("unexpected measurement path: %s", e.MeasurementPath)
+	} else if e.TimeFormat != "unix_ms" {
+		t.Fatalf("unexpected time format: %s", e.TimeFormat)
+	} else if e.Fields["duration"] != "build.duration" {
+		t.Fatalf("unexpected fields: %v", e.Fields)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, test := range []struct {
+		fn  func(c *webhook.Config)
+		err bool
+	}{
+		{fn: func(c *webhook.Config) {}},
+		{fn: func(c *webhook.Config) { c.Enabled = false; c.Endpoints = nil }},
+		{fn: func(c *webhook.Config) { c.Endpoints = nil }, err: true},
+		{fn: func(c *webhook.Config) { c.MaxBodySize = -1 }, err: true},
+		{fn: func(c *webhook.Config) { c.Endpoints[0].Path = "hook" }, err: true},
+		{fn: func(c *webhook.Config) { c.Endpoints = append(c.Endpoints, c.Endpoints[0]) }, err: true},
+		{fn: func(c *webhook.Config) { c.Endpoints[0].Measurement = "" }, err: true},
+		{fn: func(c *webhook.Config) { c.Endpoints[0].Measurement = ""; c.Endpoints[0].MeasurementPath = "kind" }},
+		{fn: func(c *webhook.Config) { c.Endpoints[0].Fields = nil }, err: true},
+		{fn: func(c *webhook.Config) { c.Endpoints[0].Fields["v"] = "a..b" }, err: true},
+		{fn: func(c *webhook.Config) { c.Endpoints[0].Tags = map[string]string{"t": ""} }, err: true},
+		{fn: func(c *webhook.Config) { c.Endpoints[0].PointsPath = "$." }, err: true},
+		{fn: func(c *webhook.Config) { c.Endpoints[0].TimeFormat = "unix_ms" }},
+		{fn: func(c *webhook.Config) { c.Endpoints[0].TimeFormat = "2006-01-02 15:04:05" }},
+		{fn: func(c *webhook.Config) { c.Endpoints[0].TimeFormat = "epoch" }, err: true},
+	} {
+		c := webhook.NewConfig()
+		c.Enabled = true
+		c.Endpoints = []webhook.Endpoint{{
+			Path:        "/hook",
+			Measurement: "events",
+			Fields:      map[string]string{"value": "value"},
+		}}
+		test.fn(&c)
+		if err := c.Validate(); test.err && err == nil {
+			t.Errorf("%+v: expected error", c)
+		} else if !test.err && err != nil {
+			t.Errorf("%+v: unexpected error: %s", c, err)
+		}
+	}
+}
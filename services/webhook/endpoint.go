@@ -0,0 +1,253 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// mapping is a tag or field key and the path of its value.
+type mapping struct {
+	key  string
+	path path
+}
+
+// endpoint maps the payloads of an Endpoint to points.
+type endpoint struct {
+	retentionPolicy string
+	measurement     string
+	measurementPath *path
+	pointsPath      *path
+	timePath        *path
+	timeFormat      string
+	tags            []mapping
+	fields          []mapping
+}
+
+// newEndpoint compiles the paths of e, which must be valid.
+func newEndpoint(e Endpoint) (*endpoint, error) {
+	ep := &endpoint{
+		retentionPolicy: e.RetentionPolicy,
+		measurement:     e.Measurement,
+		timeFormat:      e.TimeFormat,
+	}
+
+	for _, f := range []struct {
+		s string
+		p **path
+	}{
+		{e.MeasurementPath, &ep.measurementPath},
+		{e.PointsPath, &ep.pointsPath},
+		{e.TimePath, &ep.timePath},
+	} {
+		if f.s == "" {
+			continue
+		}
+		p, err := parsePath(f.s)
+		if err != nil {
+			return nil, err
+		}
+		*f.p = &p
+	}
+
+	var err error
+	if ep.tags, err = newMappings(e.Tags); err != nil {
+		return nil, err
+	} else if ep.fields, err = newMappings(e.Fields); err != nil {
+		return nil, err
+	}
+	return ep, nil
+}
+
+// newMappings returns the mappings of m, sorted by key.
+func newMappings(m map[string]string) ([]mapping, error) {
+	mappings := make([]mapping, 0, len(m))
+	for key, s := range m {
+		p, err := parsePath(s)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping{key: key, path: p})
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].key < mappings[j].key })
+	return mappings, nil
+}
+
+// points returns the points of the decoded payload doc. Elements without any
+// field value are skipped. Elements without a timestamp are assigned the
+// time now.
+func (ep *endpoint) points(doc interface{}, now time.Time) ([]models.Point, error) {
+	v := doc
+	if ep.pointsPath != nil {
+		var ok bool
+		if v, ok = ep.pointsPath.lookup(doc, doc); !ok {
+			return nil, nil
+		}
+	}
+	elems, ok := v.([]interface{})
+	if !ok {
+		elems = []interface{}{v}
+	}
+
+	var points []models.Point
+	for i, elem := range elems {
+		pt, err := ep.point(doc, elem, now)
+		if err != nil {
+			if len(elems) > 1 {
+				return nil, fmt.Errorf("element %d: %s", i, err)
+			}
+			return nil, err
+		} else if pt != nil {
+			points = append(points, pt)
+		}
+	}
+	return points, nil
+}
+
+// point returns the point of elem, or nil if elem has no field value.
+func (ep *endpoint) point(root, elem interface{}, now time.Time) (models.Point, error) {
+	fields := make(models.Fields, len(ep.fields))
+	for _, m := range ep.fields {
+		if v, ok := m.path.lookup(root, elem); ok {
+			if value := fieldValue(v); value != nil {
+				fields[m.key] = value
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(ep.tags))
+	for _, m := range ep.tags {
+		if v, ok := m.path.lookup(root, elem); ok {
+			if value := tagValue(v); value != "" {
+				tags[m.key] = value
+			}
+		}
+	}
+
+	name := ep.measurement
+	if ep.measurementPath != nil {
+		if v, ok := ep.measurementPath.lookup(root, elem); ok {
+			if s, ok := v.(string); ok && s != "" {
+				name = s
+			}
+		}
+	}
+	if name == "" {
+		return nil, errors.New("missing measurement name")
+	}
+
+	t := now
+	if ep.timePath != nil {
+		if v, ok := ep.timePath.lookup(root, elem); ok && v != nil {
+			var err error
+			if t, err = parseTime(v, ep.timeFormat); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return models.NewPoint(name, models.NewTags(tags), fields, t)
+}
+
+// fieldValue returns the field value of the JSON value v. Numbers are
+// floats, and objects and arrays are encoded as JSON strings. It returns
+// nil for null values.
+func fieldValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case string, bool:
+		return x
+	case json.Number:
+		f, err := x.Float64()
+		if err != nil || math.IsInf(f, 0) {
+			return nil
+		}
+		return f
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return nil
+		}
+		return string(b)
+	}
+}
+
+// tagValue returns the tag value of the JSON value v, or "" for null
+// values.
+func tagValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case json.Number:
+		return x.String()
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+// parseTime parses the timestamp v in format.
+func parseTime(v interface{}, format string) (time.Time, error) {
+	var s string
+	switch x := v.(type) {
+	case string:
+		s = x
+	case json.Number:
+		s = x.String()
+	default:
+		return time.Time{}, fmt.Errorf("invalid timestamp %v", v)
+	}
+
+	var precision string
+	switch format {
+	case "rfc3339":
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+		}
+		return t.UTC(), nil
+	case "unix":
+		precision = "s"
+	case "unix_ms":
+		precision = "ms"
+	case "unix_us":
+		precision = "u"
+	case "unix_ns":
+		precision = "n"
+	default:
+		t, err := time.Parse(format, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+		}
+		return t.UTC(), nil
+	}
+
+	// Integers are parsed exactly, so timestamps in nanoseconds keep their
+	// precision.
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return models.SafeCalcTime(i, precision)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+	mul := map[string]float64{"s": 1e9, "ms": 1e6, "u": 1e3, "n": 1}[precision]
+	if ns := f * mul; ns >= math.MinInt64 && ns < math.MaxInt64 {
+		return models.SafeCalcTime(int64(ns), "n")
+	}
+	return time.Time{}, fmt.Errorf("timestamp %q out of range", s)
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEndpoint_Points(t *testing.T) {
+	ep, err := newEndpoint(Endpoint{
+		Measurement: "alert",
+		PointsPath:  "alerts",
+		TimePath:    "startsAt",
+		TimeFormat:  "rfc3339",
+		Tags: map[string]string{
+			"alertname": "labels.alertname",
+			"receiver":  "$.receiver",
+			"firing":    "firing",
+		},
+		Fields: map[string]string{
+			"status":      "status",
+			"value":       "value",
+			"annotations": "annotations",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := decodeJSON(t, `{
+  "receiver": "ops",
+  "alerts": [
+    {"status": "firing", "firing": true, "labels": {"alertname": "HighLoad"}, "value": 2.5, "startsAt": "2018-01-02T03:04:05Z"},
+    {"status": null, "labels": {"alertname": "Empty"}},
+    {"status": "resolved", "labels": {}, "annotations": {"summary": "ok"}}
+  ]
+}`)
+	points, err := ep.points(doc, time.Unix(0, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second alert has no field value and is skipped.
+	exp := []string{
+		`alert,alertname=HighLoad,firing=true,receiver=ops status="firing",value=2.5 1514862245000000000`,
+		`alert,receiver=ops annotations="{\"summary\":\"ok\"}",status="resolved" 5`,
+	}
+	if len(points) != len(exp) {
+		t.Fatalf("got %d points, expected %d", len(points), len(exp))
+	}
+	for i := range exp {
+		if got := points[i].String(); got != exp[i] {
+			t.Errorf("point %d: got %q, expected %q", i, got, exp[i])
+		}
+	}
+}
+
+func TestEndpoint_Points_MeasurementPath(t *testing.T) {
+	ep, err := newEndpoint(Endpoint{
+		Measurement:     "event",
+		MeasurementPath: "kind",
+		TimePath:        "ts",
+		TimeFormat:      "unix_ms",
+		Fields:          map[string]string{"duration": "build.duration"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The payload is an array, so each element is a point.
+	doc := decodeJSON(t, `[
+  {"kind": "build", "ts": 1500000000123, "build": {"duration": 12}},
+  {"kind": 3, "ts": "1500000000.5", "build": {"duration": 1}}
+]`)
+	points, err := ep.points(doc, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{
+		`build duration=12 1500000000123000000`,
+		`event duration=1 1500000000500000`,
+	}
+	if len(points) != len(exp) {
+		t.Fatalf("got %d points, expected %d", len(points), len(exp))
+	}
+	for i := range exp {
+		if got := points[i].String(); got != exp[i] {
+			t.Errorf("point %d: got %q, expected %q", i, got, exp[i])
+		}
+	}
+
+	if _, err := ep.points(decodeJSON(t, `{"ts": "yesterday", "build": {"duration": 1}}`), time.Now()); err == nil {
+		t.Fatal("expected error for invalid timestamp")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	for _, tt := range []struct {
+		v      interface{}
+		format string
+		exp    int64
+		fail   bool
+	}{
+		{v: "2018-01-02T03:04:05.5+01:00", format: "rfc3339", exp: 1514858645500000000},
+		{v: "1514858645", format: "unix", exp: 1514858645000000000},
+		{v: "1514858645123456789", format: "unix_ns", exp: 1514858645123456789},
+		{v: "1514858645123456", format: "unix_us", exp: 1514858645123456000},
+		{v: "1.5", format: "unix", exp: 1500000000},
+		{v: "02/01/2018 03:04", format: "02/01/2006 15:04", exp: 1514862240000000000},
+		{v: "NaN", format: "unix", fail: true},
+		{v: "1e30", format: "unix", fail: true},
+		{v: true, format: "unix", fail: true},
+		{v: "2018-01-02", format: "rfc3339", fail: true},
+	} {
+		got, err := parseTime(tt.v, tt.format)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%v (%s): expected error", tt.v, tt.format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v (%s): unexpected error: %s", tt.v, tt.format, err)
+		} else if got.UnixNano() != tt.exp {
+			t.Errorf("%v (%s): got %d, expected %d", tt.v, tt.format, got.UnixNano(), tt.exp)
+		}
+	}
+}
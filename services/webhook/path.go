@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// path is a path to a value of a JSON document, in the dotted syntax of
+// gjson: keys are separated by dots, array elements are selected by index,
+// and "#" is the length of an array. A backslash escapes the following
+// character, so "a\.b" is the key "a.b". Paths starting with "$." are
+// resolved from the root of the payload rather than from the point element.
+type path struct {
+	root bool
+	keys []string
+}
+
+// parsePath parses a path. The empty path refers to the point element and
+// "$" to the root of the payload.
+func parsePath(s string) (path, error) {
+	var p path
+	if s == "$" {
+		return path{root: true}, nil
+	} else if strings.HasPrefix(s, "$.") {
+		p.root, s = true, s[2:]
+	}
+	if s == "" {
+		if p.root {
+			return path{}, errors.New(`empty path after "$."`)
+		}
+		return p, nil
+	}
+
+	var key []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i++; i == len(s) {
+				return path{}, errors.New("path ends with an escape character")
+			}
+			key = append(key, s[i])
+		case '.':
+			if len(key) == 0 {
+				return path{}, errors.New("path has an empty key")
+			}
+			p.keys, key = append(p.keys, string(key)), nil
+		default:
+			key = append(key, c)
+		}
+	}
+	if len(key) == 0 {
+		return path{}, errors.New("path has an empty key")
+	}
+	p.keys = append(p.keys, string(key))
+	return p, nil
+}
+
+// lookup returns the value at the path, or false if there is none. Relative
+// paths start from elem and absolute paths from root.
+func (p path) lookup(root, elem interface{}) (interface{}, bool) {
+	v := elem
+	if p.root {
+		v = root
+	}
+
+	for _, key := range p.keys {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = x[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			if key == "#" {
+				v = json.Number(strconv.Itoa(len(x)))
+				continue
+			}
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, false
+			}
+			v = x[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		exp  path
+		fail bool
+	}{
+		{s: "", exp: path{}},
+		{s: "$", exp: path{root: true}},
+		{s: "a", exp: path{keys: []string{"a"}}},
+		{s: "a.0.b", exp: path{keys: []string{"a", "0", "b"}}},
+		{s: "$.a.#", exp: path{root: true, keys: []string{"a", "#"}}},
+		{s: `a\.b.c\\`, exp: path{keys: []string{"a.b", `c\`}}},
+		{s: "$.", fail: true},
+		{s: "a..b", fail: true},
+		{s: ".a", fail: true},
+		{s: "a.", fail: true},
+		{s: `a\`, fail: true},
+	} {
+		p, err := parsePath(tt.s)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%q: expected error", tt.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.s, err)
+		} else if !reflect.DeepEqual(p, tt.exp) {
+			t.Errorf("%q: got %+v, expected %+v", tt.s, p, tt.exp)
+		}
+	}
+}
+
+func TestPath_Lookup(t *testing.T) {
+	root := decodeJSON(t, `{"name": "root", "items": [{"name": "a", "tags": {"x.y": 1}}, {"name": "b"}]}`)
+	elem := root.(map[string]interface{})["items"].([]interface{})[0]
+
+	for _, tt := range []struct {
+		path string
+		exp  interface{}
+		ok   bool
+	}{
+		{path: "", exp: elem, ok: true},
+		{path: "name", exp: "a", ok: true},
+		{path: `tags.x\.y`, exp: json.Number("1"), ok: true},
+		{path: "$.name", exp: "root", ok: true},
+		{path: "$.items.1.name", exp: "b", ok: true},
+		{path: "$.items.#", exp: json.Number("2"), ok: true},
+		{path: "$.items.2.name"},
+		{path: "$.items.-1"},
+		{path: "$.items.x"},
+		{path: "name.x"},
+		{path: "missing"},
+	} {
+		p, err := parsePath(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, ok := p.lookup(root, elem)
+		if ok != tt.ok {
+			t.Errorf("%q: got ok=%v, expected %v", tt.path, ok, tt.ok)
+		} else if !reflect.DeepEqual(v, tt.exp) {
+			t.Errorf("%q: got %v, expected %v", tt.path, v, tt.exp)
+		}
+	}
+}
+
+func decodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
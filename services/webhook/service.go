@@ -0,0 +1,307 @@
+// Package webhook provides a service for InfluxDB to ingest JSON webhook
+// payloads.
+package webhook // import "github.com/influxdata/influxdb/services/webhook"
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+	"go.uber.org/zap"
+)
+
+// statistics gathered by the webhook package.
+const (
+	statRequests          = "req"
+	statRequestsRejected  = "reqRejected"
+	statBytesReceived     = "bytesRx"
+	statPayloadsParseFail = "payloadsParseFail"
+	statPointsWrittenOK   = "pointsWrittenOK"
+	statPointsWrittenFail = "pointsWrittenFail"
+)
+
+// Service is a webhook listener. It receives JSON payloads posted to the
+// paths of its endpoints, and writes the points they are mapped to.
+type Service struct {
+	wg sync.WaitGroup
+
+	mu     sync.RWMutex
+	ready  bool // Has the required database been created?
+	ln     net.Listener
+	addr   net.Addr
+	server *http.Server
+
+	config    Config
+	endpoints map[string]*endpoint
+
+	PointsWriter interface {
+		WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+	}
+
+	MetaClient interface {
+		CreateDatabase(name string) (*meta.DatabaseInfo, error)
+	}
+
+	Logger      *zap.Logger
+	stats       *Statistics
+	defaultTags models.StatisticTags
+}
+
+// NewService returns a new instance of Service.
+func NewService(c Config) *Service {
+	d := *c.WithDefaults()
+	return &Service{
+		config:      d,
+		Logger:      zap.NewNop(),
+		stats:       &Statistics{},
+		defaultTags: models.StatisticTags{"bind": d.BindAddress},
+	}
+}
+
+// Open starts the service.
+func (s *Service) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ln != nil {
+		return nil // Already open.
+	}
+
+	if err := s.config.Validate(); err != nil {
+		return err
+	}
+
+	endpoints := make(map[string]*endpoint, len(s.config.Endpoints))
+	for _, e := range s.config.Endpoints {
+		ep, err := newEndpoint(e)
+		if err != nil {
+			return fmt.Errorf("endpoint %s: %s", e.Path, err)
+		}
+		endpoints[e.Path] = ep
+	}
+	s.endpoints = endpoints
+
+	var (
+		ln  net.Listener
+		err error
+	)
+	if s.config.TLSEnabled {
+		key := s.config.PrivateKey
+		if key == "" {
+			key = s.config.Certificate
+		}
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(s.config.Certificate, key); err == nil {
+			ln, err = tls.Listen("tcp", s.config.BindAddress, &tls.Config{
+				Certificates: []tls.Certificate{cert},
+			})
+		}
+	} else {
+		ln, err = net.Listen("tcp", s.config.BindAddress)
+	}
+	if err != nil {
+		return err
+	}
+	s.ln, s.addr = ln, ln.Addr()
+	s.server = &http.Server{Handler: s}
+
+	s.Logger.Info("Listening",
+		zap.Stringer("addr", s.addr),
+		zap.Bool("tls", s.config.TLSEnabled))
+
+	s.wg.Add(1)
+	go func(server *http.Server) {
+		defer s.wg.Done()
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.Logger.Info("Webhook listener closed", zap.Error(err))
+		}
+	}(s.server)
+	return nil
+}
+
+// ServeHTTP maps the payload posted to an endpoint to points and writes
+// them. The response is sent once the points are written, so senders can
+// retry payloads that failed.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.stats.Requests, 1)
+
+	s.mu.RLock()
+	ep := s.endpoints[r.URL.Path]
+	s.mu.RUnlock()
+	if ep == nil {
+		atomic.AddInt64(&s.stats.RequestsRejected, 1)
+		http.NotFound(w, r)
+		return
+	} else if r.Method != "POST" {
+		atomic.AddInt64(&s.stats.RequestsRejected, 1)
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	} else if !s.authorized(r) {
+		atomic.AddInt64(&s.stats.RequestsRejected, 1)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(s.config.MaxBodySize)+1))
+	if err != nil {
+		atomic.AddInt64(&s.stats.RequestsRejected, 1)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if len(body) > s.config.MaxBodySize {
+		atomic.AddInt64(&s.stats.RequestsRejected, 1)
+		http.Error(w, "payload exceeds max-body-size", http.StatusRequestEntityTooLarge)
+		return
+	}
+	atomic.AddInt64(&s.stats.BytesReceived, int64(len(body)))
+
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		atomic.AddInt64(&s.stats.PayloadsParseFail, 1)
+		http.Error(w, fmt.Sprintf("invalid JSON payload: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	points, err := ep.points(doc, time.Now().UTC())
+	if err != nil {
+		atomic.AddInt64(&s.stats.PayloadsParseFail, 1)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if len(points) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Will attempt to create database if not yet created.
+	if err := s.createInternalStorage(); err != nil {
+		s.Logger.Info("Required database not yet created",
+			logger.Database(s.config.Database), zap.Error(err))
+		atomic.AddInt64(&s.stats.PointsWrittenFail, int64(len(points)))
+		http.Error(w, "database not created", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := s.PointsWriter.WritePointsPrivileged(s.config.Database, ep.retentionPolicy, models.ConsistencyLevelOne, points); err != nil {
+		s.Logger.Info("Failed to write points to database",
+			logger.Database(s.config.Database), zap.Error(err))
+		atomic.AddInt64(&s.stats.PointsWrittenFail, int64(len(points)))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	atomic.AddInt64(&s.stats.PointsWrittenOK, int64(len(points)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorized returns true if r carries the token of the service, if any.
+func (s *Service) authorized(r *http.Request) bool {
+	if s.config.Token == "" {
+		return true
+	}
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) == 1
+}
+
+// Statistics maintains statistics for the webhook service.
+type Statistics struct {
+	Requests          int64
+	RequestsRejected  int64
+	BytesReceived     int64
+	PayloadsParseFail int64
+	PointsWrittenOK   int64
+	PointsWrittenFail int64
+}
+
+// Statistics returns statistics for periodic monitoring.
+func (s *Service) Statistics(tags map[string]string) []models.Statistic {
+	return []models.Statistic{{
+		Name: "webhook",
+		Tags: s.defaultTags.Merge(tags),
+		Values: map[string]interface{}{
+			statRequests:          atomic.LoadInt64(&s.stats.Requests),
+			statRequestsRejected:  atomic.LoadInt64(&s.stats.RequestsRejected),
+			statBytesReceived:     atomic.LoadInt64(&s.stats.BytesReceived),
+			statPayloadsParseFail: atomic.LoadInt64(&s.stats.PayloadsParseFail),
+			statPointsWrittenOK:   atomic.LoadInt64(&s.stats.PointsWrittenOK),
+			statPointsWrittenFail: atomic.LoadInt64(&s.stats.PointsWrittenFail),
+		},
+	}}
+}
+
+// Addr returns the address the service listens on.
+func (s *Service) Addr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.addr
+}
+
+// Close stops the listener and closes the open connections, interrupting
+// the requests in progress.
+func (s *Service) Close() error {
+	s.mu.Lock()
+	if s.ln == nil {
+		s.mu.Unlock()
+		return nil // Already closed.
+	}
+	server := s.server
+	s.ln, s.server = nil, nil
+	s.mu.Unlock()
+
+	server.Close()
+	s.wg.Wait()
+
+	s.Logger.Info("Service closed")
+	return nil
+}
+
+// Closed returns true if the service is currently closed.
+func (s *Service) Closed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ln == nil
+}
+
+// createInternalStorage ensures that the required database has been created.
+func (s *Service) createInternalStorage() error {
+	s.mu.RLock()
+	ready := s.ready
+	s.mu.RUnlock()
+	if ready {
+		return nil
+	}
+
+	if _, err := s.MetaClient.CreateDatabase(s.config.Database); err != nil {
+		return err
+	}
+
+	// The service is now ready.
+	s.mu.Lock()
+	s.ready = true
+	s.mu.Unlock()
+	return nil
+}
+
+// WithLogger sets the logger on the service.
+func (s *Service) WithLogger(log *zap.Logger) {
+	s.Logger = log.With(
+		zap.String("service", "webhook"),
+		zap.String("addr", s.config.BindAddress),
+	)
+}
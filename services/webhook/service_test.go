@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb/internal"
+	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/models"
+	"github.com/influxdata/influxdb/services/meta"
+)
+
+// Ensure payloads posted to an endpoint are written.
+func TestService_Write(t *testing.T) {
+	t.Parallel()
+
+	s := NewTestService(nil)
+	var written []models.Point
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		if database != "hooks" || retentionPolicy != "rp0" {
+			t.Errorf("unexpected destination: %s.%s", database, retentionPolicy)
+		}
+		written = append(written, points...)
+		return nil
+	}
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	resp := s.Post(t, "/events", `{"host": "a", "value": 1}`)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+	if len(written) != 1 {
+		t.Fatalf("got %d points written, expected 1", len(written))
+	} else if got := written[0].Tags().GetString("host"); got != "a" {
+		t.Fatalf("unexpected host tag: %q", got)
+	}
+
+	// Payloads without a field value write nothing.
+	if resp := s.Post(t, "/events", `{"host": "a"}`); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	} else if len(written) != 1 {
+		t.Fatalf("got %d points written, expected 1", len(written))
+	}
+
+	stats := s.Service.Statistics(nil)[0].Values
+	if got, exp := stats[statRequests], int64(2); got != exp {
+		t.Fatalf("got %v requests, expected %d", got, exp)
+	} else if got, exp := stats[statPointsWrittenOK], int64(1); got != exp {
+		t.Fatalf("got %v points written, expected %d", got, exp)
+	}
+}
+
+// Ensure invalid requests are rejected.
+func TestService_Errors(t *testing.T) {
+	t.Parallel()
+
+	c := testConfig()
+	c.MaxBodySize = 64
+	c.Token = "secret"
+	s := NewTestService(&c)
+	s.WritePointsFn = func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+		if points[0].Tags().GetString("host") == "fail" {
+			return errors.New("write failed")
+		}
+		return nil
+	}
+	if err := s.Service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Service.Close()
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		path   string
+		auth   string
+		body   string
+		status int
+	}{
+		{"query token", "POST", "/events?token=secret", "", `{"value": 1}`, http.StatusNoContent},
+		{"bearer token", "POST", "/events", "Bearer secret", `{"value": 1}`, http.StatusNoContent},
+		{"missing token", "POST", "/events", "", `{"value": 1}`, http.StatusUnauthorized},
+		{"wrong token", "POST", "/events?token=wrong", "", `{"value": 1}`, http.StatusUnauthorized},
+		{"unknown path", "POST", "/other?token=secret", "", `{"value": 1}`, http.StatusNotFound},
+		{"method", "GET", "/events?token=secret", "", "", http.StatusMethodNotAllowed},
+		{"invalid JSON", "POST", "/events?token=secret", "", `{"value": `, http.StatusBadRequest},
+		{"too large", "POST", "/events?token=secret", "", `{"value": 1, "host": "` + strings.Repeat("a", 64) + `"}`, http.StatusRequestEntityTooLarge},
+		{"write failed", "POST", "/events?token=secret", "", `{"value": 1, "host": "fail"}`, http.StatusInternalServerError},
+	} {
+		req, err := http.NewRequest(tt.method, "http://"+s.Service.Addr().String()+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: got status %d, expected %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+// Ensure the service can be reopened.
+func TestService_OpenClose(t *testing.T) {
+	t.Parallel()
+
+	s := NewTestService(nil)
+	for i := 0; i < 2; i++ {
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Service.Open(); err != nil {
+			t.Fatal(err)
+		}
+		if s.Service.Closed() {
+			t.Fatal("service closed after open")
+		}
+		if resp := s.Post(t, "/events", `{"value": 1}`); resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("unexpected status: %d", resp.StatusCode)
+		}
+
+		if err := s.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Service.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !s.Service.Closed() {
+			t.Fatal("service open after close")
+		}
+	}
+}
+
+type TestService struct {
+	Service       *Service
+	Config        Config
+	MetaClient    *internal.MetaClientMock
+	WritePointsFn func(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error
+}
+
+func testConfig() Config {
+	c := NewConfig()
+	c.Enabled = true
+	c.BindAddress = "127.0.0.1:0"
+	c.Database = "hooks"
+	c.RetentionPolicy = "rp0"
+	c.Endpoints = []Endpoint{{
+		Path:        "/events",
+		Measurement: "events",
+		Tags:        map[string]string{"host": "host"},
+		Fields:      map[string]string{"value": "value"},
+	}}
+	return c
+}
+
+func NewTestService(c *Config) *TestService {
+	if c == nil {
+		defaultC := testConfig()
+		c = &defaultC
+	}
+
+	service := &TestService{
+		Service:    NewService(*c),
+		Config:     *c,
+		MetaClient: &internal.MetaClientMock{},
+	}
+	service.MetaClient.CreateDatabaseFn = func(name string) (*meta.DatabaseInfo, error) {
+		return nil, nil
+	}
+
+	if testing.Verbose() {
+		service.Service.WithLogger(logger.New(os.Stderr))
+	}
+
+	service.Service.MetaClient = service.MetaClient
+	service.Service.PointsWriter = service
+	return service
+}
+
+func (s *TestService) WritePointsPrivileged(database, retentionPolicy string, consistencyLevel models.ConsistencyLevel, points []models.Point) error {
+	if s.WritePointsFn == nil {
+		return nil
+	}
+	return s.WritePointsFn(database, retentionPolicy, consistencyLevel, points)
+}
+
+// Post posts body to the path of the service.
+func (s *TestService) Post(t *testing.T, path, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post("http://"+s.Service.Addr().String()+path, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
@@ -71,6 +71,8 @@ type Engine interface {
 	HasTagKey(name, key []byte) (bool, error)
 	MeasurementTagKeysByExpr(name []byte, expr influxql.Expr) (map[string]struct{}, error)
 	TagKeyCardinality(name, key []byte) int
+	HasSeriesValues(name []byte, tags models.Tags, min, max int64) (bool, error)
+	TimeRange() (min, max int64)
 
 	// Statistics will return statistics relevant to this engine.
 	Statistics(tags map[string]string) []models.Statistic
@@ -633,6 +633,38 @@ func (c *Cache) values(key []byte) Values {
 	return v
 }
 
+// TimeRange returns the minimum and maximum time of the values in the cache and
+// its snapshot. The minimum is greater than the maximum if the cache is empty.
+func (c *Cache) TimeRange() (min, max int64) {
+	min, max = math.MaxInt64, math.MinInt64
+
+	c.mu.RLock()
+	stores := []storer{c.store}
+	if c.snapshot != nil {
+		stores = append(stores, c.snapshot.store)
+	}
+	c.mu.RUnlock()
+
+	for _, store := range stores {
+		// applySerial cannot return an error in this invocation.
+		_ = store.applySerial(func(_ []byte, e *entry) error {
+			e.mu.RLock()
+			for _, v := range e.values {
+				ts := v.UnixNano()
+				if ts < min {
+					min = ts
+				}
+				if ts > max {
+					max = ts
+				}
+			}
+			e.mu.RUnlock()
+			return nil
+		})
+	}
+	return min, max
+}
+
 // ApplyEntryFn applies the function f to each entry in the Cache.
 // ApplyEntryFn calls f on each entry in turn, within the same goroutine.
 // It is safe for use by multiple goroutines.
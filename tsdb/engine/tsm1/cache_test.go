@@ -385,6 +385,30 @@ func TestCache_CacheValues(t *testing.T) {
 	}
 }
 
+func TestCache_TimeRange(t *testing.T) {
+	c := NewCache(512, "")
+	if min, max := c.TimeRange(); min <= max {
+		t.Fatalf("time range of empty cache incorrect, got [%d, %d]", min, max)
+	}
+
+	if err := c.Write([]byte("foo"), Values{NewValue(5, 1.0)}); err != nil {
+		t.Fatalf("failed to write value, key foo to cache: %s", err.Error())
+	}
+	if min, max := c.TimeRange(); min != 5 || max != 5 {
+		t.Fatalf("time range incorrect, exp [5, 5], got [%d, %d]", min, max)
+	}
+
+	if _, err := c.Snapshot(); err != nil {
+		t.Fatalf("failed to snapshot cache: %v", err)
+	}
+	if err := c.Write([]byte("bar"), Values{NewValue(2, 1.0), NewValue(3, 2.0)}); err != nil {
+		t.Fatalf("failed to write values, key bar to cache: %s", err.Error())
+	}
+	if min, max := c.TimeRange(); min != 2 || max != 5 {
+		t.Fatalf("time range incorrect, exp [2, 5], got [%d, %d]", min, max)
+	}
+}
+
 func TestCache_CacheSnapshot(t *testing.T) {
 	v0 := NewValue(2, 0.0)
 	v1 := NewValue(3, 2.0)
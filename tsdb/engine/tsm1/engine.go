@@ -61,7 +61,9 @@ var (
 	numberOfRefCursorsCounter  = metrics.MustRegisterCounter("cursors_ref", metrics.WithGroup(tsmGroup))
 	numberOfAuxCursorsCounter  = metrics.MustRegisterCounter("cursors_aux", metrics.WithGroup(tsmGroup))
 	numberOfCondCursorsCounter = metrics.MustRegisterCounter("cursors_cond", metrics.WithGroup(tsmGroup))
+	numberOfRowsCounter        = metrics.MustRegisterCounter("rows", metrics.WithGroup(tsmGroup))
 	planningTimer              = metrics.MustRegisterTimer("planning_time", metrics.WithGroup(tsmGroup))
+	executionTimer             = metrics.MustRegisterTimer("execution_time", metrics.WithGroup(tsmGroup))
 )
 
 // NewContextWithMetricsGroup creates a new context with a tsm1 metrics.Group for tracking
@@ -528,6 +530,43 @@ func (e *Engine) TagKeyCardinality(name, key []byte) int {
 	return e.index.TagKeyCardinality(name, key)
 }
 
+// HasSeriesValues returns true if any field of the series has a value between
+// min and max, inclusive, in the cache or the TSM files.
+func (e *Engine) HasSeriesValues(name []byte, tags models.Tags, min, max int64) (bool, error) {
+	mf := e.fieldset.Fields(string(name))
+	if mf == nil {
+		return false, nil
+	}
+
+	seriesKey := string(models.MakeKey(name, tags))
+	for _, field := range mf.FieldKeys() {
+		key := SeriesFieldKeyBytes(seriesKey, field)
+		if ok, err := e.FileStore.HasValuesInRange(key, min, max); err != nil || ok {
+			return ok, err
+		}
+		if values := e.Cache.Values(key); len(values.Include(min, max)) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// TimeRange returns the minimum and maximum time of the values in the cache
+// and the TSM files. Deleted values may widen the range. The minimum is
+// greater than the maximum if the engine has no values.
+func (e *Engine) TimeRange() (min, max int64) {
+	min, max = e.FileStore.TimeRange()
+	if cmin, cmax := e.Cache.TimeRange(); cmin <= cmax {
+		if cmin < min {
+			min = cmin
+		}
+		if cmax > max {
+			max = cmax
+		}
+	}
+	return min, max
+}
+
 // SeriesN returns the unique number of series in the index.
 func (e *Engine) SeriesN() int64 {
 	return e.index.SeriesN()
@@ -153,6 +153,7 @@ var (
 	stringBlocksSizeCounter      = metrics.MustRegisterCounter("string_blocks_size_bytes", metrics.WithGroup(tsmGroup))
 	booleanBlocksDecodedCounter  = metrics.MustRegisterCounter("boolean_blocks_decoded", metrics.WithGroup(tsmGroup))
 	booleanBlocksSizeCounter     = metrics.MustRegisterCounter("boolean_blocks_size_bytes", metrics.WithGroup(tsmGroup))
+	cursorSeeksCounter           = metrics.MustRegisterCounter("cursor_seeks", metrics.WithGroup(tsmGroup))
 )
 
 // FileStore is an abstraction around multiple TSM files.
@@ -568,6 +569,73 @@ func (f *FileStore) Read(key []byte, t int64) ([]Value, error) {
 	return nil, nil
 }
 
+// HasValuesInRange returns true if any file has a value for the given key
+// between min and max, inclusive, that has not been deleted. Only the blocks
+// that are not entirely within the range or have tombstones are read.
+func (f *FileStore) HasValuesInRange(key []byte, min, max int64) (bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var cache []IndexEntry
+	var values []Value
+	for _, fd := range f.files {
+		if !fd.OverlapsTimeRange(min, max) {
+			continue
+		}
+		tombstones := fd.TombstoneRange(key)
+
+		entries := fd.ReadEntries(key, &cache)
+		for i := range entries {
+			ie := &entries[i]
+			if !ie.OverlapsTimeRange(min, max) {
+				continue
+			} else if len(tombstones) == 0 && ie.MinTime >= min && ie.MaxTime <= max {
+				return true, nil
+			}
+
+			var err error
+			if values, err = fd.ReadAt(ie, values[:0]); err != nil {
+				return false, err
+			}
+
+		VALUES:
+			for _, v := range values {
+				ts := v.UnixNano()
+				if ts < min || ts > max {
+					continue
+				}
+				for _, t := range tombstones {
+					if t.Min <= ts && t.Max >= ts {
+						continue VALUES
+					}
+				}
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// TimeRange returns the minimum and maximum time of the values in the files,
+// including deleted values. The minimum is greater than the maximum if there
+// are no files.
+func (f *FileStore) TimeRange() (min, max int64) {
+	min, max = math.MaxInt64, math.MinInt64
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, fd := range f.files {
+		fmin, fmax := fd.TimeRange()
+		if fmin < min {
+			min = fmin
+		}
+		if fmax > max {
+			max = fmax
+		}
+	}
+	return min, max
+}
+
 func (f *FileStore) Cost(key []byte, min, max int64) query.IteratorCost {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
@@ -1099,6 +1167,9 @@ func (c *KeyCursor) Close() {
 
 // seek positions the cursor at the given time.
 func (c *KeyCursor) seek(t int64) {
+	if c.col != nil {
+		c.col.GetCounter(cursorSeeksCounter).Add(1)
+	}
 	if len(c.seeks) == 0 {
 		return
 	}
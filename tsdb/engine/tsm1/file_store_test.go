@@ -53,6 +53,50 @@ func TestFileStore_Read(t *testing.T) {
 	}
 }
 
+func TestFileStore_HasValuesInRange(t *testing.T) {
+	dir := MustTempDir()
+	defer os.RemoveAll(dir)
+	fs := tsm1.NewFileStore(dir)
+
+	// Setup 2 files
+	data := []keyValues{
+		keyValues{"cpu", []tsm1.Value{tsm1.NewValue(0, 1.0), tsm1.NewValue(10, 2.0)}},
+		keyValues{"cpu", []tsm1.Value{tsm1.NewValue(20, 3.0), tsm1.NewValue(30, 4.0)}},
+	}
+
+	files, err := newFiles(dir, data...)
+	if err != nil {
+		t.Fatalf("unexpected error creating files: %v", err)
+	}
+
+	fs.Replace(nil, files)
+
+	if err := fs.DeleteRange([][]byte{[]byte("cpu")}, 20, 25); err != nil {
+		t.Fatalf("unexpected error deleting range: %v", err)
+	}
+
+	for _, tt := range []struct {
+		key      string
+		min, max int64
+		exp      bool
+	}{
+		{key: "cpu", min: 0, max: 10, exp: true},
+		{key: "cpu", min: 5, max: 15, exp: true},
+		{key: "cpu", min: 1, max: 9, exp: false},
+		{key: "cpu", min: 11, max: 25, exp: false},
+		{key: "cpu", min: 25, max: 35, exp: true},
+		{key: "cpu", min: 31, max: 40, exp: false},
+		{key: "mem", min: 0, max: 30, exp: false},
+	} {
+		got, err := fs.HasValuesInRange([]byte(tt.key), tt.min, tt.max)
+		if err != nil {
+			t.Fatalf("unexpected error reading values: %v", err)
+		} else if got != tt.exp {
+			t.Fatalf("%s in [%d, %d]: got %v, exp %v", tt.key, tt.min, tt.max, got, tt.exp)
+		}
+	}
+}
+
 func TestFileStore_SeekToAsc_FromStart(t *testing.T) {
 	dir := MustTempDir()
 	defer os.RemoveAll(dir)
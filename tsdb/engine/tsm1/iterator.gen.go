@@ -11,13 +11,13 @@ import (
 	"runtime"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/influxdata/influxdb/pkg/metrics"
 	"github.com/influxdata/influxdb/pkg/tracing"
 	"github.com/influxdata/influxdb/pkg/tracing/fields"
 	"github.com/influxdata/influxdb/query"
 	"github.com/influxdata/influxdb/tsdb"
-	"github.com/influxdata/influxql"
 	"go.uber.org/zap"
 )
 
@@ -153,12 +153,32 @@ func (itr *floatFinalizerIterator) Close() error {
 
 type floatInstrumentedIterator struct {
 	query.FloatIterator
-	span  *tracing.Span
-	group *metrics.Group
+	span      *tracing.Span
+	group     *metrics.Group
+	rows      *metrics.Counter
+	execution *metrics.Timer
 }
 
 func newFloatInstrumentedIterator(inner query.FloatIterator, span *tracing.Span, group *metrics.Group) *floatInstrumentedIterator {
-	return &floatInstrumentedIterator{FloatIterator: inner, span: span, group: group}
+	return &floatInstrumentedIterator{
+		FloatIterator: inner,
+		span:          span,
+		group:         group,
+		rows:          group.GetCounter(numberOfRowsCounter),
+		execution:     group.GetTimer(executionTimer),
+	}
+}
+
+// Next returns the next point of the inner iterator, recording the rows
+// produced and the time spent producing them.
+func (itr *floatInstrumentedIterator) Next() (*query.FloatPoint, error) {
+	start := time.Now()
+	p, err := itr.FloatIterator.Next()
+	itr.execution.Add(time.Since(start))
+	if p != nil {
+		itr.rows.Add(1)
+	}
+	return p, err
 }
 
 func (itr *floatInstrumentedIterator) Close() error {
@@ -270,7 +290,7 @@ func (itr *floatIterator) Next() (*query.FloatPoint, error) {
 		}
 
 		// Evaluate condition, if one exists. Retry if it fails.
-		if itr.opt.Condition != nil && !influxql.EvalBool(itr.opt.Condition, itr.m) {
+		if itr.opt.Condition != nil && !query.EvalBool(itr.opt.Condition, itr.m) {
 			continue
 		}
 
@@ -625,12 +645,32 @@ func (itr *integerFinalizerIterator) Close() error {
 
 type integerInstrumentedIterator struct {
 	query.IntegerIterator
-	span  *tracing.Span
-	group *metrics.Group
+	span      *tracing.Span
+	group     *metrics.Group
+	rows      *metrics.Counter
+	execution *metrics.Timer
 }
 
 func newIntegerInstrumentedIterator(inner query.IntegerIterator, span *tracing.Span, group *metrics.Group) *integerInstrumentedIterator {
-	return &integerInstrumentedIterator{IntegerIterator: inner, span: span, group: group}
+	return &integerInstrumentedIterator{
+		IntegerIterator: inner,
+		span:            span,
+		group:           group,
+		rows:            group.GetCounter(numberOfRowsCounter),
+		execution:       group.GetTimer(executionTimer),
+	}
+}
+
+// Next returns the next point of the inner iterator, recording the rows
+// produced and the time spent producing them.
+func (itr *integerInstrumentedIterator) Next() (*query.IntegerPoint, error) {
+	start := time.Now()
+	p, err := itr.IntegerIterator.Next()
+	itr.execution.Add(time.Since(start))
+	if p != nil {
+		itr.rows.Add(1)
+	}
+	return p, err
 }
 
 func (itr *integerInstrumentedIterator) Close() error {
@@ -742,7 +782,7 @@ func (itr *integerIterator) Next() (*query.IntegerPoint, error) {
 		}
 
 		// Evaluate condition, if one exists. Retry if it fails.
-		if itr.opt.Condition != nil && !influxql.EvalBool(itr.opt.Condition, itr.m) {
+		if itr.opt.Condition != nil && !query.EvalBool(itr.opt.Condition, itr.m) {
 			continue
 		}
 
@@ -1097,12 +1137,32 @@ func (itr *unsignedFinalizerIterator) Close() error {
 
 type unsignedInstrumentedIterator struct {
 	query.UnsignedIterator
-	span  *tracing.Span
-	group *metrics.Group
+	span      *tracing.Span
+	group     *metrics.Group
+	rows      *metrics.Counter
+	execution *metrics.Timer
 }
 
 func newUnsignedInstrumentedIterator(inner query.UnsignedIterator, span *tracing.Span, group *metrics.Group) *unsignedInstrumentedIterator {
-	return &unsignedInstrumentedIterator{UnsignedIterator: inner, span: span, group: group}
+	return &unsignedInstrumentedIterator{
+		UnsignedIterator: inner,
+		span:             span,
+		group:            group,
+		rows:             group.GetCounter(numberOfRowsCounter),
+		execution:        group.GetTimer(executionTimer),
+	}
+}
+
+// Next returns the next point of the inner iterator, recording the rows
+// produced and the time spent producing them.
+func (itr *unsignedInstrumentedIterator) Next() (*query.UnsignedPoint, error) {
+	start := time.Now()
+	p, err := itr.UnsignedIterator.Next()
+	itr.execution.Add(time.Since(start))
+	if p != nil {
+		itr.rows.Add(1)
+	}
+	return p, err
 }
 
 func (itr *unsignedInstrumentedIterator) Close() error {
@@ -1214,7 +1274,7 @@ func (itr *unsignedIterator) Next() (*query.UnsignedPoint, error) {
 		}
 
 		// Evaluate condition, if one exists. Retry if it fails.
-		if itr.opt.Condition != nil && !influxql.EvalBool(itr.opt.Condition, itr.m) {
+		if itr.opt.Condition != nil && !query.EvalBool(itr.opt.Condition, itr.m) {
 			continue
 		}
 
@@ -1569,12 +1629,32 @@ func (itr *stringFinalizerIterator) Close() error {
 
 type stringInstrumentedIterator struct {
 	query.StringIterator
-	span  *tracing.Span
-	group *metrics.Group
+	span      *tracing.Span
+	group     *metrics.Group
+	rows      *metrics.Counter
+	execution *metrics.Timer
 }
 
 func newStringInstrumentedIterator(inner query.StringIterator, span *tracing.Span, group *metrics.Group) *stringInstrumentedIterator {
-	return &stringInstrumentedIterator{StringIterator: inner, span: span, group: group}
+	return &stringInstrumentedIterator{
+		StringIterator: inner,
+		span:           span,
+		group:          group,
+		rows:           group.GetCounter(numberOfRowsCounter),
+		execution:      group.GetTimer(executionTimer),
+	}
+}
+
+// Next returns the next point of the inner iterator, recording the rows
+// produced and the time spent producing them.
+func (itr *stringInstrumentedIterator) Next() (*query.StringPoint, error) {
+	start := time.Now()
+	p, err := itr.StringIterator.Next()
+	itr.execution.Add(time.Since(start))
+	if p != nil {
+		itr.rows.Add(1)
+	}
+	return p, err
 }
 
 func (itr *stringInstrumentedIterator) Close() error {
@@ -1686,7 +1766,7 @@ func (itr *stringIterator) Next() (*query.StringPoint, error) {
 		}
 
 		// Evaluate condition, if one exists. Retry if it fails.
-		if itr.opt.Condition != nil && !influxql.EvalBool(itr.opt.Condition, itr.m) {
+		if itr.opt.Condition != nil && !query.EvalBool(itr.opt.Condition, itr.m) {
 			continue
 		}
 
@@ -2041,12 +2121,32 @@ func (itr *booleanFinalizerIterator) Close() error {
 
 type booleanInstrumentedIterator struct {
 	query.BooleanIterator
-	span  *tracing.Span
-	group *metrics.Group
+	span      *tracing.Span
+	group     *metrics.Group
+	rows      *metrics.Counter
+	execution *metrics.Timer
 }
 
 func newBooleanInstrumentedIterator(inner query.BooleanIterator, span *tracing.Span, group *metrics.Group) *booleanInstrumentedIterator {
-	return &booleanInstrumentedIterator{BooleanIterator: inner, span: span, group: group}
+	return &booleanInstrumentedIterator{
+		BooleanIterator: inner,
+		span:            span,
+		group:           group,
+		rows:            group.GetCounter(numberOfRowsCounter),
+		execution:       group.GetTimer(executionTimer),
+	}
+}
+
+// Next returns the next point of the inner iterator, recording the rows
+// produced and the time spent producing them.
+func (itr *booleanInstrumentedIterator) Next() (*query.BooleanPoint, error) {
+	start := time.Now()
+	p, err := itr.BooleanIterator.Next()
+	itr.execution.Add(time.Since(start))
+	if p != nil {
+		itr.rows.Add(1)
+	}
+	return p, err
 }
 
 func (itr *booleanInstrumentedIterator) Close() error {
@@ -2158,7 +2258,7 @@ func (itr *booleanIterator) Next() (*query.BooleanPoint, error) {
 		}
 
 		// Evaluate condition, if one exists. Retry if it fails.
-		if itr.opt.Condition != nil && !influxql.EvalBool(itr.opt.Condition, itr.m) {
+		if itr.opt.Condition != nil && !query.EvalBool(itr.opt.Condition, itr.m) {
 			continue
 		}
 
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/pkg/metrics"
+	"github.com/influxdata/influxdb/pkg/tracing"
 	"github.com/influxdata/influxdb/query"
 	"github.com/influxdata/influxql"
 )
@@ -147,6 +149,63 @@ func TestFinalizerIterator(t *testing.T) {
 	}
 }
 
+type testFloatIterator struct {
+	points []query.FloatPoint
+}
+
+func (itr *testFloatIterator) Next() (*query.FloatPoint, error) {
+	if len(itr.points) == 0 {
+		return nil, nil
+	}
+	p := &itr.points[0]
+	itr.points = itr.points[1:]
+	return p, nil
+}
+
+func (itr *testFloatIterator) Close() error               { return nil }
+func (itr *testFloatIterator) Stats() query.IteratorStats { return query.IteratorStats{} }
+
+// Ensure the instrumented iterator records the rows it produces in the fields
+// of its span.
+func TestInstrumentedIterator_Rows(t *testing.T) {
+	trace, span := tracing.NewTrace("select")
+	group := metrics.NewGroup(tsmGroup)
+	itr := newFloatInstrumentedIterator(&testFloatIterator{
+		points: []query.FloatPoint{{Time: 0, Value: 1}, {Time: 10, Value: 2}},
+	}, span, group)
+	for {
+		p, err := itr.Next()
+		if err != nil {
+			t.Fatal(err)
+		} else if p == nil {
+			break
+		}
+	}
+	if err := itr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := trace.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("unexpected spans: %d", len(spans))
+	}
+	var rows, execution bool
+	for _, f := range spans[0].Fields {
+		switch f.Key() {
+		case "rows":
+			if v := f.Value(); v != int64(2) {
+				t.Fatalf("unexpected rows: %v", v)
+			}
+			rows = true
+		case "execution_time":
+			execution = true
+		}
+	}
+	if !rows || !execution {
+		t.Fatalf("missing fields: %v", spans[0].Fields)
+	}
+}
+
 func TestBufCursor_DoubleClose(t *testing.T) {
 	c := newBufCursor(nilCursor{}, true)
 	if err := c.close(); err != nil {
@@ -1094,6 +1094,15 @@ func (itr *tagValueMergeIterator) Next() (_ []byte, err error) {
 type IndexSet struct {
 	Indexes    []Index
 	SeriesFile *SeriesFile
+
+	// SeriesFilter, if set, restricts the series used by the tag key and tag
+	// value lookups in addition to any authorizer.
+	SeriesFilter SeriesFilter
+}
+
+// SeriesFilter determines whether a series should be included in a result.
+type SeriesFilter interface {
+	MatchSeries(id uint64, name []byte, tags models.Tags) (bool, error)
 }
 
 // Database returns the database name of the first index.
@@ -1104,6 +1113,23 @@ func (is IndexSet) Database() string {
 	return is.Indexes[0].Database()
 }
 
+// filtersSeries returns true if series must be checked by readSeries.
+func (is IndexSet) filtersSeries(auth query.Authorizer) bool {
+	return !query.AuthorizerIsOpen(auth) || is.SeriesFilter != nil
+}
+
+// readSeries returns true if the series is authorized and matches the series
+// filter, if any.
+func (is IndexSet) readSeries(auth query.Authorizer, id uint64, name []byte, tags models.Tags) (bool, error) {
+	if !query.AuthorizerIsOpen(auth) && !auth.AuthorizeSeriesRead(is.Database(), name, tags) {
+		return false, nil
+	}
+	if is.SeriesFilter == nil {
+		return true, nil
+	}
+	return is.SeriesFilter.MatchSeries(id, name, tags)
+}
+
 // FieldSet returns the fieldset of the first index.
 func (is IndexSet) FieldSet() *MeasurementFieldSet {
 	if len(is.Indexes) == 0 {
@@ -1114,7 +1140,11 @@ func (is IndexSet) FieldSet() *MeasurementFieldSet {
 
 // DedupeInmemIndexes returns an index set which removes duplicate in-memory indexes.
 func (is IndexSet) DedupeInmemIndexes() IndexSet {
-	other := IndexSet{Indexes: make([]Index, 0, len(is.Indexes)), SeriesFile: is.SeriesFile}
+	other := IndexSet{
+		Indexes:      make([]Index, 0, len(is.Indexes)),
+		SeriesFile:   is.SeriesFile,
+		SeriesFilter: is.SeriesFilter,
+	}
 
 	var hasInmem bool
 	for _, idx := range is.Indexes {
@@ -1430,6 +1460,42 @@ func (is IndexSet) measurementAuthorizedSeries(auth query.Authorizer, name []byt
 	}
 }
 
+// countSeries returns the number of series of an iterator authorized to be
+// read, and closes the iterator. Series can only be counted from the index if
+// they are not filtered by a condition on fields.
+func (is IndexSet) countSeries(auth query.Authorizer, itr SeriesIDIterator) (int64, error) {
+	if itr == nil {
+		return 0, nil
+	}
+	defer itr.Close()
+
+	var n int64
+	for {
+		e, err := itr.Next()
+		if err != nil {
+			return 0, err
+		} else if e.SeriesID == 0 {
+			return n, nil
+		}
+
+		if e.Expr != nil {
+			if lit, ok := e.Expr.(*influxql.BooleanLiteral); !ok {
+				return 0, fmt.Errorf("series cannot be counted with a condition on fields: %s", e.Expr)
+			} else if !lit.Val {
+				continue
+			}
+		}
+
+		if !query.AuthorizerIsOpen(auth) {
+			name, tags := is.SeriesFile.Series(e.SeriesID)
+			if !auth.AuthorizeSeriesRead(is.Database(), name, tags) {
+				continue
+			}
+		}
+		n++
+	}
+}
+
 // HasTagKey returns true if the tag key exists in any index for the provided
 // measurement.
 func (is IndexSet) HasTagKey(name, key []byte) (bool, error) {
@@ -1549,12 +1615,14 @@ func (is IndexSet) TagKeyHasAuthorizedSeries(auth query.Authorizer, name, tagKey
 			return false, nil
 		}
 
-		if query.AuthorizerIsOpen(auth) {
+		if !is.filtersSeries(auth) {
 			return true, nil
 		}
 
 		name, tags := is.SeriesFile.Series(e.SeriesID)
-		if auth.AuthorizeSeriesRead(is.Database(), name, tags) {
+		if ok, err := is.readSeries(auth, e.SeriesID, name, tags); err != nil {
+			return false, err
+		} else if ok {
 			return true, nil
 		}
 	}
@@ -1818,6 +1886,17 @@ func (is IndexSet) seriesByBinaryExprIterator(name []byte, n *influxql.BinaryExp
 		return newSeriesIDExprIterator(itr, n), nil
 	}
 
+	// Function calls are evaluated on every point by the underlying query.
+	_, lhsCall := n.LHS.(*influxql.Call)
+	_, rhsCall := n.RHS.(*influxql.Call)
+	if lhsCall || rhsCall {
+		itr, err := is.measurementSeriesIDIterator(name)
+		if err != nil {
+			return nil, err
+		}
+		return newSeriesIDExprIterator(itr, n), nil
+	}
+
 	// Retrieve the variable reference from the correct side of the expression.
 	key, ok := n.LHS.(*influxql.VarRef)
 	value := n.RHS
@@ -2139,8 +2218,6 @@ func (is IndexSet) TagValuesByKeyAndExpr(auth query.Authorizer, name []byte, key
 // tagValuesByKeyAndExpr guarantees to never take any locks on the underlying
 // series file.
 func (is IndexSet) tagValuesByKeyAndExpr(auth query.Authorizer, name []byte, keys []string, expr influxql.Expr, fieldset *MeasurementFieldSet) ([]map[string]struct{}, error) {
-	database := is.Database()
-
 	itr, err := is.seriesByExprIterator(name, expr, fieldset.Fields(string(name)))
 	if err != nil {
 		return nil, err
@@ -2179,9 +2256,11 @@ func (is IndexSet) tagValuesByKeyAndExpr(auth query.Authorizer, name []byte, key
 			continue
 		}
 
-		if auth != nil {
+		if is.filtersSeries(auth) {
 			name, tags := ParseSeriesKey(buf)
-			if !auth.AuthorizeSeriesRead(database, name, tags) {
+			if ok, err := is.readSeries(auth, e.SeriesID, name, tags); err != nil {
+				return nil, err
+			} else if !ok {
 				continue
 			}
 		}
@@ -2231,8 +2310,8 @@ func (is IndexSet) MeasurementTagKeyValuesByExpr(auth query.Authorizer, name []b
 			}
 			defer vitr.Close()
 
-			// If no authorizer present then return all values.
-			if query.AuthorizerIsOpen(auth) {
+			// If no authorizer or series filter present then return all values.
+			if !is.filtersSeries(auth) {
 				for {
 					val, err := vitr.Next()
 					if err != nil {
@@ -2274,7 +2353,9 @@ func (is IndexSet) MeasurementTagKeyValuesByExpr(auth query.Authorizer, name []b
 					}
 
 					name, tags := is.SeriesFile.Series(se.SeriesID)
-					if auth.AuthorizeSeriesRead(is.Database(), name, tags) {
+					if ok, err := is.readSeries(auth, se.SeriesID, name, tags); err != nil {
+						return nil, err
+					} else if ok {
 						results[ki] = append(results[ki], string(val))
 						break
 					}
@@ -626,6 +626,13 @@ func (m *measurement) idsForExpr(n *influxql.BinaryExpr) (seriesIDs, influxql.Ex
 		return m.SeriesIDs(), n, nil
 	}
 
+	// Function calls are evaluated on every point by the underlying query.
+	if _, ok := n.LHS.(*influxql.Call); ok {
+		return m.SeriesIDs(), n, nil
+	} else if _, ok := n.RHS.(*influxql.Call); ok {
+		return m.SeriesIDs(), n, nil
+	}
+
 	// Retrieve the variable reference from the correct side of the expression.
 	name, ok := n.LHS.(*influxql.VarRef)
 	value := n.RHS
@@ -651,6 +651,109 @@ func (s *Shard) validateSeriesAndFields(points []models.Point) ([]models.Point,
 	return points, fieldsToCreate, err
 }
 
+// ValidatePoints returns the errors the points would be dropped with if they
+// were written to the shard, by index, without writing them. Points are checked
+// for invalid keys, for conflicts with the types of existing fields and of the
+// fields of earlier points, and against the max-values-per-tag limit.
+func (s *Shard) ValidatePoints(points []models.Point) (map[int]error, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	engine, err := s.engineNoLock()
+	if err != nil {
+		return nil, err
+	}
+
+	// The limits of the config are only enforced by the inmem index.
+	var maxValuesPerTag int
+	if s.index.Type() == "inmem" {
+		maxValuesPerTag = s.options.Config.MaxValuesPerTag
+	}
+
+	errs := make(map[int]error)
+	types := make(map[string]map[string]influxql.DataType)
+	for i, p := range points {
+		name := p.Name()
+		if p.Tags().Get(timeBytes) != nil {
+			errs[i] = fmt.Errorf("invalid tag key: input tag \"%s\" on measurement \"%s\" is invalid", "time", name)
+			continue
+		}
+
+		if maxValuesPerTag > 0 {
+			for _, tag := range p.Tags() {
+				if ok, _ := s.index.HasTagValue(name, tag.Key, tag.Value); ok {
+					continue
+				}
+				if n := s.index.TagKeyCardinality(name, tag.Key); n >= maxValuesPerTag {
+					errs[i] = fmt.Errorf("max-values-per-tag limit exceeded (%d/%d): measurement=%q tag=%q value=%q",
+						n, maxValuesPerTag, name, string(tag.Key), string(tag.Value))
+					break
+				}
+			}
+			if errs[i] != nil {
+				continue
+			}
+		}
+
+		mtypes := types[string(name)]
+		if mtypes == nil {
+			mtypes = make(map[string]influxql.DataType)
+			types[string(name)] = mtypes
+		}
+		mf := engine.MeasurementFields(name)
+
+		var fields []*Field
+		iter := p.FieldIterator()
+		for iter.Next() {
+			if bytes.Equal(iter.FieldKey(), timeBytes) {
+				continue
+			}
+			f := &Field{Name: string(iter.FieldKey())}
+			switch iter.Type() {
+			case models.Float:
+				f.Type = influxql.Float
+			case models.Integer:
+				f.Type = influxql.Integer
+			case models.Unsigned:
+				f.Type = influxql.Unsigned
+			case models.Boolean:
+				f.Type = influxql.Boolean
+			case models.String:
+				f.Type = influxql.String
+			default:
+				continue
+			}
+			fields = append(fields, f)
+		}
+		if len(fields) == 0 {
+			errs[i] = fmt.Errorf("invalid field name: input field \"%s\" on measurement \"%s\" is invalid", "time", name)
+			continue
+		}
+
+		for _, f := range fields {
+			typ, ok := mtypes[f.Name]
+			if !ok {
+				if existing := mf.Field(f.Name); existing != nil {
+					typ, ok = existing.Type, true
+				}
+			}
+			if ok && typ != f.Type {
+				errs[i] = fmt.Errorf("%s: input field \"%s\" on measurement \"%s\" is type %s, already exists as type %s", ErrFieldTypeConflict, f.Name, name, f.Type, typ)
+				break
+			}
+		}
+		if errs[i] != nil {
+			continue
+		}
+
+		// Only the fields of valid points are created by writes.
+		for _, f := range fields {
+			mtypes[f.Name] = f.Type
+		}
+	}
+	return errs, nil
+}
+
 func (s *Shard) createFieldsAndMeasurements(fieldsToCreate []*FieldCreate) error {
 	if len(fieldsToCreate) == 0 {
 		return nil
@@ -1082,6 +1185,27 @@ func (s *Shard) TagKeyCardinality(name, key []byte) int {
 	return engine.TagKeyCardinality(name, key)
 }
 
+// HasSeriesValues returns true if the series has a value between min and max,
+// inclusive, in the shard.
+func (s *Shard) HasSeriesValues(name []byte, tags models.Tags, min, max int64) (bool, error) {
+	engine, err := s.engine()
+	if err != nil {
+		return false, err
+	}
+	return engine.HasSeriesValues(name, tags, min, max)
+}
+
+// TimeRange returns the minimum and maximum time of the values in the shard.
+// The minimum is greater than the maximum if the shard has no values.
+func (s *Shard) TimeRange() (min, max int64, err error) {
+	engine, err := s.engine()
+	if err != nil {
+		return 0, 0, err
+	}
+	min, max = engine.TimeRange()
+	return min, max, nil
+}
+
 // Digest returns a digest of the shard.
 func (s *Shard) Digest() (io.ReadCloser, int64, error) {
 	engine, err := s.engine()
@@ -2,6 +2,7 @@ package tsdb // import "github.com/influxdata/influxdb/tsdb"
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -962,6 +963,117 @@ func (s *Store) SeriesCardinality(database string) (int64, error) {
 	return int64(ss.Cardinality()), nil
 }
 
+// MeasurementCardinality is the exact series cardinality of a measurement and
+// of each of its tag keys.
+type MeasurementCardinality struct {
+	Measurement string
+	Series      int64
+
+	// TagKeys are the tag keys of the measurement in order, and TagKeySeries
+	// the number of series having each of them.
+	TagKeys      []string
+	TagKeySeries []int64
+}
+
+// SeriesCardinalityByMeasurement calls fn with the exact series cardinality of
+// each measurement of a database matching cond, in order of measurement name.
+// The condition may only filter on the measurement name and tags.
+//
+// Unlike SeriesCardinality, the series are counted by iterating the series IDs
+// of each measurement merged across the shards' indexes, so only the counts of
+// the current measurement are held in memory, however many series the
+// database has.
+func (s *Store) SeriesCardinalityByMeasurement(auth query.Authorizer, database string, cond influxql.Expr, fn func(c MeasurementCardinality) error) error {
+	s.mu.RLock()
+	shards := s.filterShards(byDatabase(database))
+	s.mu.RUnlock()
+
+	sfile := s.seriesFile(database)
+	if sfile == nil {
+		return nil
+	}
+
+	// Build indexset.
+	is := IndexSet{Indexes: make([]Index, 0, len(shards)), SeriesFile: sfile}
+	for _, sh := range shards {
+		index, err := sh.Index()
+		if err != nil {
+			return err
+		}
+		is.Indexes = append(is.Indexes, index)
+	}
+	is = is.DedupeInmemIndexes()
+
+	release := sfile.Retain()
+	defer release()
+
+	measurementExpr, filterExpr := splitMeasurementCondition(cond)
+	names, err := is.MeasurementNamesByExpr(auth, measurementExpr)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		itr, err := is.measurementSeriesByExprIterator(name, filterExpr)
+		if err != nil {
+			return err
+		}
+		n, err := is.countSeries(auth, itr)
+		if err != nil {
+			return err
+		} else if n == 0 {
+			continue
+		}
+		c := MeasurementCardinality{Measurement: string(name), Series: n}
+
+		keys, err := is.tagKeyIterator(name)
+		if err != nil {
+			return err
+		}
+		for keys != nil {
+			key, err := keys.Next()
+			if err != nil {
+				keys.Close()
+				return err
+			} else if key == nil {
+				break
+			}
+
+			// Only count the series of the key matching the condition.
+			itr, err := is.tagKeySeriesIDIterator(name, key)
+			if err == nil && filterExpr != nil {
+				var fitr SeriesIDIterator
+				if fitr, err = is.measurementSeriesByExprIterator(name, filterExpr); err == nil {
+					itr = IntersectSeriesIDIterators(itr, fitr)
+				} else if itr != nil {
+					itr.Close()
+				}
+			}
+			if err != nil {
+				keys.Close()
+				return err
+			}
+
+			n, err := is.countSeries(auth, itr)
+			if err != nil {
+				keys.Close()
+				return err
+			} else if n > 0 {
+				c.TagKeys = append(c.TagKeys, string(key))
+				c.TagKeySeries = append(c.TagKeySeries, n)
+			}
+		}
+		if keys != nil {
+			keys.Close()
+		}
+
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SeriesSketches returns the sketches associated with the series data in all
 // the shards in the provided database.
 //
@@ -1086,6 +1198,18 @@ func (s *Store) ShardRelativePath(id uint64) (string, error) {
 // DeleteSeries loops through the local shards and deletes the series data for
 // the passed in series keys.
 func (s *Store) DeleteSeries(database string, sources []influxql.Source, condition influxql.Expr) error {
+	return s.deleteSeries(database, sources, condition, false)
+}
+
+// DeletePoints deletes the points matching the condition. The data of the
+// series matched by the conditions on tags and time is deleted like with
+// DeleteSeries, and the runs of consecutive points of a series matched by the
+// conditions on fields are deleted as time ranges.
+func (s *Store) DeletePoints(database string, sources []influxql.Source, condition influxql.Expr) error {
+	return s.deleteSeries(database, sources, condition, true)
+}
+
+func (s *Store) deleteSeries(database string, sources []influxql.Source, condition influxql.Expr, points bool) error {
 	// Expand regex expressions in the FROM clause.
 	a, err := s.ExpandSources(sources)
 	if err != nil {
@@ -1163,6 +1287,12 @@ func (s *Store) DeleteSeries(database string, sources []influxql.Source, conditi
 				continue
 			}
 			defer itr.Close()
+			if points {
+				if err := deletePoints(sh, sfile, []byte(name), itr, condition, min, max); err != nil {
+					return err
+				}
+				continue
+			}
 			if err := sh.DeleteSeriesRange(NewSeriesIteratorAdapter(sfile, itr), min, max); err != nil {
 				return err
 			}
@@ -1173,6 +1303,196 @@ func (s *Store) DeleteSeries(database string, sources []influxql.Source, conditi
 	})
 }
 
+// deletePoints deletes the points of a measurement in a shard matching the
+// condition. The series whose tags match the condition are deleted between min
+// and max, and then the points of the series whose fields must be compared are
+// read from the shard to delete those that match.
+func deletePoints(sh *Shard, sfile *SeriesFile, name []byte, itr SeriesIDIterator, condition influxql.Expr, min, max int64) error {
+	var ids []uint64
+	var hasFields bool
+	for {
+		e, err := itr.Next()
+		if err != nil {
+			return err
+		} else if e.SeriesID == 0 {
+			break
+		}
+
+		if e.Expr == nil {
+			ids = append(ids, e.SeriesID)
+		} else if v, ok := e.Expr.(*influxql.BooleanLiteral); !ok {
+			hasFields = true
+		} else if v.Val {
+			ids = append(ids, e.SeriesID)
+		}
+	}
+
+	if len(ids) > 0 {
+		if err := sh.DeleteSeriesRange(NewSeriesIteratorAdapter(sfile, NewSeriesIDSliceIterator(ids)), min, max); err != nil {
+			return err
+		}
+	}
+	if !hasFields {
+		return nil
+	}
+
+	// Every field is read so that a point without the fields of the condition
+	// still ends the run of matching points before it.
+	mf := sh.MeasurementFields(name)
+	if mf == nil {
+		return nil
+	}
+	var aux []influxql.VarRef
+	mf.ForEachField(func(k string, typ influxql.DataType) bool {
+		aux = append(aux, influxql.VarRef{Val: k, Type: typ})
+		return true
+	})
+	if len(aux) == 0 {
+		return nil
+	}
+	sort.Slice(aux, func(i, j int) bool { return aux[i].Val < aux[j].Val })
+
+	// Group by every tag key to read the tags of the series of each point.
+	keySet, err := sh.MeasurementTagKeysByExpr(name, nil)
+	if err != nil {
+		return err
+	}
+	dimensions := make([]string, 0, len(keySet))
+	for k := range keySet {
+		dimensions = append(dimensions, k)
+	}
+	sort.Strings(dimensions)
+
+	return deleteMatchingPoints(sh, sfile, name, condition, query.IteratorOptions{
+		Aux:        aux,
+		Dimensions: dimensions,
+		StartTime:  min,
+		EndTime:    max,
+		Ascending:  true,
+	})
+}
+
+// deletePointsBatchSize is the number of series ranges collected before they
+// are deleted from a shard.
+const deletePointsBatchSize = 10000
+
+// pointRange is a time range of the points of a series.
+type pointRange struct {
+	min, max int64
+}
+
+// deleteMatchingPoints reads the points of a measurement in a shard with the
+// iterator created with opt and deletes those matching the condition.
+// Consecutive matching points of a series are deleted as a single range, and
+// the series with the same range are deleted together.
+func deleteMatchingPoints(sh *Shard, sfile *SeriesFile, name []byte, condition influxql.Expr, opt query.IteratorOptions) error {
+	itr, err := sh.CreateIterator(context.Background(), &influxql.Measurement{Name: string(name)}, opt)
+	if err != nil {
+		return err
+	} else if itr == nil {
+		return nil
+	}
+	defer itr.Close()
+
+	fitr, ok := itr.(query.FloatIterator)
+	if !ok {
+		return fmt.Errorf("unexpected iterator type for %s: %T", name, itr)
+	}
+
+	// runs holds the range of matching points of each series since its last
+	// point that did not match.
+	runs := make(map[uint64]pointRange)
+	pending := make(map[pointRange][]uint64)
+	var n int
+
+	flush := func() error {
+		for r, ids := range pending {
+			if err := sh.DeleteSeriesRange(NewSeriesIteratorAdapter(sfile, NewSeriesIDSliceIterator(ids)), r.min, r.max); err != nil {
+				return err
+			}
+			delete(pending, r)
+		}
+		n = 0
+		return nil
+	}
+	end := func(id uint64) error {
+		r, ok := runs[id]
+		if !ok {
+			return nil
+		}
+		delete(runs, id)
+		pending[r] = append(pending[r], id)
+		if n++; n >= deletePointsBatchSize {
+			return flush()
+		}
+		return nil
+	}
+
+	var (
+		id     uint64
+		tagsID string
+		seen   bool
+		m      = make(map[string]interface{})
+		buf    = make([]byte, 1024)
+	)
+	for {
+		p, err := fitr.Next()
+		if err != nil {
+			return err
+		} else if p == nil {
+			break
+		}
+
+		// The points of a series are read one after another, so the series
+		// is only looked up when the tags change.
+		if k := p.Tags.ID(); !seen || k != tagsID {
+			var tags models.Tags
+			for k, v := range p.Tags.KeyValues() {
+				if v != "" {
+					tags = append(tags, models.NewTag([]byte(k), []byte(v)))
+				}
+			}
+			sort.Sort(tags)
+			id, tagsID, seen = sfile.SeriesID(name, tags, buf), k, true
+		}
+		if id == 0 {
+			continue
+		}
+
+		for k := range m {
+			delete(m, k)
+		}
+		for k, v := range p.Tags.KeyValues() {
+			m[k] = v
+		}
+		for i, ref := range opt.Aux {
+			if i < len(p.Aux) && p.Aux[i] != nil {
+				m[ref.Val] = p.Aux[i]
+			}
+		}
+
+		if !query.EvalBool(condition, m) {
+			if err := end(id); err != nil {
+				return err
+			}
+			continue
+		}
+		if r, ok := runs[id]; ok {
+			r.max = p.Time
+			runs[id] = r
+		} else {
+			runs[id] = pointRange{min: p.Time, max: p.Time}
+		}
+	}
+
+	for id := range runs {
+		if err := end(id); err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
 // ExpandSources expands sources against all local shards.
 func (s *Store) ExpandSources(sources influxql.Sources) (influxql.Sources, error) {
 	shards := func() Shards {
@@ -1210,6 +1530,17 @@ func (s *Store) WriteToShard(shardID uint64, points []models.Point) error {
 	return sh.WritePoints(points)
 }
 
+// ValidateShardPoints returns the errors the points would be dropped with if
+// they were written to the shard identified by its ID, by index, without
+// writing them. Points are valid in shards that don't exist yet.
+func (s *Store) ValidateShardPoints(shardID uint64, points []models.Point) (map[int]error, error) {
+	sh := s.Shard(shardID)
+	if sh == nil {
+		return nil, nil
+	}
+	return sh.ValidatePoints(points)
+}
+
 // MeasurementNames returns a slice of all measurements. Measurements accepts an
 // optional condition expression. If cond is nil, then all measurements for the
 // database will be returned.
@@ -1260,52 +1591,39 @@ func (s *Store) TagKeys(auth query.Authorizer, shardIDs []uint64, cond influxql.
 		return nil, nil
 	}
 
-	measurementExpr := influxql.CloneExpr(cond)
-	measurementExpr = influxql.Reduce(influxql.RewriteExpr(measurementExpr, func(e influxql.Expr) influxql.Expr {
-		switch e := e.(type) {
-		case *influxql.BinaryExpr:
-			switch e.Op {
-			case influxql.EQ, influxql.NEQ, influxql.EQREGEX, influxql.NEQREGEX:
-				tag, ok := e.LHS.(*influxql.VarRef)
-				if !ok || tag.Val != "_name" {
-					return nil
-				}
-			}
-		}
-		return e
-	}), nil)
-
-	filterExpr := influxql.CloneExpr(cond)
-	filterExpr = influxql.Reduce(influxql.RewriteExpr(filterExpr, func(e influxql.Expr) influxql.Expr {
-		switch e := e.(type) {
-		case *influxql.BinaryExpr:
-			switch e.Op {
-			case influxql.EQ, influxql.NEQ, influxql.EQREGEX, influxql.NEQREGEX:
-				tag, ok := e.LHS.(*influxql.VarRef)
-				if !ok || strings.HasPrefix(tag.Val, "_") {
-					return nil
-				}
-			}
-		}
-		return e
-	}), nil)
+	cond, timeRange, err := influxql.ConditionExpr(cond, nil)
+	if err != nil {
+		return nil, err
+	}
+	measurementExpr, filterExpr := splitMeasurementCondition(cond)
 
 	// Get all the shards we're interested in.
-	is := IndexSet{Indexes: make([]Index, 0, len(shardIDs))}
+	shards := make([]*Shard, 0, len(shardIDs))
 	s.mu.RLock()
 	for _, sid := range shardIDs {
 		shard, ok := s.shards[sid]
 		if !ok {
 			continue
 		}
+		shards = append(shards, shard)
+	}
+	s.mu.RUnlock()
+
+	// Only keep the series with values in the time range of the condition.
+	var filter SeriesFilter
+	if !timeRange.Min.IsZero() || !timeRange.Max.IsZero() {
+		if filter, shards, err = newSeriesTimeRangeFilter(shards, timeRange.MinTimeNano(), timeRange.MaxTimeNano()); err != nil {
+			return nil, err
+		}
+	}
 
+	is := IndexSet{Indexes: make([]Index, 0, len(shards)), SeriesFilter: filter}
+	for _, shard := range shards {
 		if is.SeriesFile == nil {
 			is.SeriesFile = shard.sfile
 		}
-
 		is.Indexes = append(is.Indexes, shard.index)
 	}
-	s.mu.RUnlock()
 
 	// Determine list of measurements.
 	is = is.DedupeInmemIndexes()
@@ -1379,9 +1697,100 @@ func (s *Store) TagKeys(auth query.Authorizer, shardIDs []uint64, cond influxql.
 			Keys:        finalKeys,
 		})
 	}
+
 	return results, nil
 }
 
+// splitMeasurementCondition splits a condition into the condition on the
+// measurement name and the condition on the tags of the series.
+func splitMeasurementCondition(cond influxql.Expr) (measurementExpr, filterExpr influxql.Expr) {
+	measurementExpr = influxql.CloneExpr(cond)
+	measurementExpr = influxql.Reduce(influxql.RewriteExpr(measurementExpr, func(e influxql.Expr) influxql.Expr {
+		switch e := e.(type) {
+		case *influxql.BinaryExpr:
+			switch e.Op {
+			case influxql.EQ, influxql.NEQ, influxql.EQREGEX, influxql.NEQREGEX:
+				tag, ok := e.LHS.(*influxql.VarRef)
+				if !ok || tag.Val != "_name" {
+					return nil
+				}
+			}
+		}
+		return e
+	}), nil)
+
+	filterExpr = influxql.CloneExpr(cond)
+	filterExpr = influxql.Reduce(influxql.RewriteExpr(filterExpr, func(e influxql.Expr) influxql.Expr {
+		switch e := e.(type) {
+		case *influxql.BinaryExpr:
+			switch e.Op {
+			case influxql.EQ, influxql.NEQ, influxql.EQREGEX, influxql.NEQREGEX:
+				tag, ok := e.LHS.(*influxql.VarRef)
+				if !ok || strings.HasPrefix(tag.Val, "_") {
+					return nil
+				}
+			}
+		}
+		return e
+	}), nil)
+	return measurementExpr, filterExpr
+}
+
+// seriesTimeRangeFilter matches the series with values between min and max in
+// any of the shards it was built from. Series in the shards whose data lies
+// entirely within the time range match without reading the shards. Every other
+// series is looked up in each shard that partially overlaps the time range,
+// which costs a read of the cache and the TSM file indexes per series and shard,
+// so a time range that cuts through many large shards is slow.
+type seriesTimeRangeFilter struct {
+	within   *SeriesIDSet
+	shards   []*Shard
+	min, max int64
+}
+
+// newSeriesTimeRangeFilter returns a filter for the series with values between
+// min and max in the shards, along with the shards that have values in the
+// time range. The other shards can be left out of the lookup entirely.
+func newSeriesTimeRangeFilter(shards []*Shard, min, max int64) (*seriesTimeRangeFilter, []*Shard, error) {
+	f := &seriesTimeRangeFilter{within: NewSeriesIDSet(), min: min, max: max}
+	overlapping := make([]*Shard, 0, len(shards))
+	for _, sh := range shards {
+		smin, smax, err := sh.TimeRange()
+		if err != nil {
+			return nil, nil, err
+		} else if smin > smax || smax < min || smin > max {
+			continue
+		}
+		overlapping = append(overlapping, sh)
+
+		// The series of a shard lying within the time range all match,
+		// unless the index can't list the series of the shard.
+		if idx, ok := sh.index.(interface {
+			SeriesIDSet() *SeriesIDSet
+		}); ok && smin >= min && smax <= max {
+			f.within.Merge(idx.SeriesIDSet())
+			continue
+		}
+		f.shards = append(f.shards, sh)
+	}
+	return f, overlapping, nil
+}
+
+// MatchSeries returns true if the series has values in the time range.
+func (f *seriesTimeRangeFilter) MatchSeries(id uint64, name []byte, tags models.Tags) (bool, error) {
+	if f.within.Contains(id) {
+		return true, nil
+	}
+	for _, sh := range f.shards {
+		if ok, err := sh.HasSeriesValues(name, tags, f.min, f.max); err != nil {
+			return false, err
+		} else if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 type TagValues struct {
 	Measurement string
 	Values      []KeyValue
@@ -1416,6 +1825,11 @@ func (s *Store) TagValues(auth query.Authorizer, shardIDs []uint64, cond influxq
 		return nil, errors.New("a condition is required")
 	}
 
+	cond, timeRange, err := influxql.ConditionExpr(cond, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	measurementExpr := influxql.CloneExpr(cond)
 	measurementExpr = influxql.Reduce(influxql.RewriteExpr(measurementExpr, func(e influxql.Expr) influxql.Expr {
 		switch e := e.(type) {
@@ -1446,21 +1860,33 @@ func (s *Store) TagValues(auth query.Authorizer, shardIDs []uint64, cond influxq
 		return e
 	}), nil)
 
-	// Build index set to work on.
-	is := IndexSet{Indexes: make([]Index, 0, len(shardIDs))}
+	shards := make([]*Shard, 0, len(shardIDs))
 	s.mu.RLock()
 	for _, sid := range shardIDs {
 		shard, ok := s.shards[sid]
 		if !ok {
 			continue
 		}
+		shards = append(shards, shard)
+	}
+	s.mu.RUnlock()
+
+	// Only keep the series with values in the time range of the condition.
+	var filter SeriesFilter
+	if !timeRange.Min.IsZero() || !timeRange.Max.IsZero() {
+		if filter, shards, err = newSeriesTimeRangeFilter(shards, timeRange.MinTimeNano(), timeRange.MaxTimeNano()); err != nil {
+			return nil, err
+		}
+	}
 
+	// Build index set to work on.
+	is := IndexSet{Indexes: make([]Index, 0, len(shards)), SeriesFilter: filter}
+	for _, shard := range shards {
 		if is.SeriesFile == nil {
 			is.SeriesFile = shard.sfile
 		}
 		is.Indexes = append(is.Indexes, shard.index)
 	}
-	s.mu.RUnlock()
 	is = is.DedupeInmemIndexes()
 
 	// Stores each list of TagValues for each measurement.
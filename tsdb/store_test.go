@@ -160,6 +160,166 @@ func TestStore_DeleteSeries_NonExistentDB(t *testing.T) {
 	}
 }
 
+// Ensure the store can delete the points matching a condition on fields.
+func TestStore_DeletePoints(t *testing.T) {
+	t.Parallel()
+
+	test := func(index string) {
+		s := MustOpenStore(index)
+		defer s.Close()
+
+		s.MustCreateShardWithData("db0", "rp0", 0,
+			`cpu,host=serverA value=1  0`,
+			`cpu,host=serverA value=500,status="bad" 10`,
+			`cpu,host=serverA value=2 20`,
+			`cpu,host=serverB value=600 10`,
+			`mem,host=serverA value=700 10`,
+		)
+
+		sources := []influxql.Source{&influxql.Measurement{Name: "cpu"}}
+		cond := influxql.MustParseExpr(`value > 100`)
+		if err := s.DeleteSeries("db0", sources, cond); err == nil {
+			t.Fatal("expected error deleting series with a condition on fields")
+		}
+		if err := s.DeletePoints("db0", sources, cond); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range []struct {
+			name string
+			exp  []*query.FloatPoint
+		}{
+			{
+				name: "cpu",
+				exp: []*query.FloatPoint{
+					{Name: "cpu", Tags: ParseTags("host=serverA"), Time: time.Unix(0, 0).UnixNano(), Value: 1},
+					{Name: "cpu", Tags: ParseTags("host=serverA"), Time: time.Unix(20, 0).UnixNano(), Value: 2},
+				},
+			},
+			{
+				name: "mem",
+				exp: []*query.FloatPoint{
+					{Name: "mem", Tags: ParseTags("host=serverA"), Time: time.Unix(10, 0).UnixNano(), Value: 700},
+				},
+			},
+		} {
+			itr, err := s.Shard(0).CreateIterator(context.Background(), &influxql.Measurement{Name: tt.name}, query.IteratorOptions{
+				Expr:       influxql.MustParseExpr(`value`),
+				Dimensions: []string{"host"},
+				Ascending:  true,
+				StartTime:  influxql.MinTime,
+				EndTime:    influxql.MaxTime,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			fitr := itr.(query.FloatIterator)
+
+			for i, exp := range tt.exp {
+				if p, err := fitr.Next(); err != nil {
+					t.Fatalf("unexpected error(%d): %s", i, err)
+				} else if !deep.Equal(p, exp) {
+					t.Fatalf("unexpected point(%d): %s", i, spew.Sdump(p))
+				}
+			}
+			if p, err := fitr.Next(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			} else if p != nil {
+				t.Fatalf("unexpected point: %s", spew.Sdump(p))
+			}
+			itr.Close()
+		}
+	}
+
+	for _, index := range tsdb.RegisteredIndexes() {
+		t.Run(index, func(t *testing.T) { test(index) })
+	}
+}
+
+// Ensure the store deletes runs of many points matching a condition on fields
+// and keeps the points between them.
+func TestStore_DeletePoints_ManyPoints(t *testing.T) {
+	t.Parallel()
+
+	test := func(index string) {
+		s := MustOpenStore(index)
+		defer s.Close()
+
+		var data []string
+		var exp []int64
+		for _, host := range []string{"serverA", "serverB"} {
+			for i := 0; i < 10000; i++ {
+				value := 500
+				if i%1000 == 999 {
+					value = 1
+					if host == "serverA" {
+						exp = append(exp, time.Unix(int64(i), 0).UnixNano())
+					}
+				}
+				data = append(data, fmt.Sprintf(`cpu,host=%s value=%d %d`, host, value, i))
+			}
+		}
+		// A point without the field of the condition splits a run of matching points.
+		data = append(data,
+			`cpu,host=serverA value=500 10001`,
+			`cpu,host=serverA status="ok" 10002`,
+			`cpu,host=serverA value=500 10003`,
+		)
+		exp = append(exp, time.Unix(10002, 0).UnixNano())
+		s.MustCreateShardWithData("db0", "rp0", 0, data...)
+
+		sources := []influxql.Source{&influxql.Measurement{Name: "cpu"}}
+		if err := s.DeletePoints("db0", sources, influxql.MustParseExpr(`value > 100`)); err != nil {
+			t.Fatal(err)
+		}
+
+		itr, err := s.Shard(0).CreateIterator(context.Background(), &influxql.Measurement{Name: "cpu"}, query.IteratorOptions{
+			Aux:        []influxql.VarRef{{Val: "status", Type: influxql.String}, {Val: "value", Type: influxql.Float}},
+			Dimensions: []string{"host"},
+			Ascending:  true,
+			StartTime:  influxql.MinTime,
+			EndTime:    influxql.MaxTime,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer itr.Close()
+		fitr := itr.(query.FloatIterator)
+
+		var serverA, serverB int
+		for {
+			p, err := fitr.Next()
+			if err != nil {
+				t.Fatal(err)
+			} else if p == nil {
+				break
+			}
+
+			switch p.Tags.KeyValues()["host"] {
+			case "serverA":
+				if serverA >= len(exp) || p.Time != exp[serverA] {
+					t.Fatalf("unexpected point(%d): %s", serverA, spew.Sdump(p))
+				}
+				serverA++
+			case "serverB":
+				if v := p.Aux[1]; v != float64(1) {
+					t.Fatalf("unexpected point(%d): %s", serverB, spew.Sdump(p))
+				}
+				serverB++
+			}
+		}
+		if serverA != len(exp) {
+			t.Fatalf("unexpected number of serverA points: %d", serverA)
+		} else if serverB != 10 {
+			t.Fatalf("unexpected number of serverB points: %d", serverB)
+		}
+	}
+
+	for _, index := range tsdb.RegisteredIndexes() {
+		t.Run(index, func(t *testing.T) { test(index) })
+	}
+}
+
 // Ensure the store can delete an existing shard.
 func TestStore_DeleteShard(t *testing.T) {
 	t.Parallel()
@@ -645,6 +805,66 @@ func TestStore_MeasurementNames_Deduplicate(t *testing.T) {
 	}
 }
 
+func TestStore_SeriesCardinalityByMeasurement(t *testing.T) {
+	t.Parallel()
+
+	test := func(index string) {
+		s := MustOpenStore(index)
+		defer s.Close()
+
+		s.MustCreateShardWithData("db0", "rp0", 1,
+			`cpu,host=a,region=east value=1 0`,
+			`cpu,host=b value=1 0`,
+			`mem,host=a value=1 0`,
+		)
+
+		// The series of the 2nd shard overlap with the 1st.
+		s.MustCreateShardWithData("db0", "rp0", 2,
+			`cpu,host=a,region=east value=2 10`,
+			`cpu,host=c,region=west value=2 10`,
+			`disk,device=sda value=2 10`,
+		)
+
+		for _, tt := range []struct {
+			cond string
+			exp  []tsdb.MeasurementCardinality
+		}{
+			{
+				exp: []tsdb.MeasurementCardinality{
+					{Measurement: "cpu", Series: 3, TagKeys: []string{"host", "region"}, TagKeySeries: []int64{3, 2}},
+					{Measurement: "disk", Series: 1, TagKeys: []string{"device"}, TagKeySeries: []int64{1}},
+					{Measurement: "mem", Series: 1, TagKeys: []string{"host"}, TagKeySeries: []int64{1}},
+				},
+			},
+			{
+				cond: `_name = 'cpu' AND host != 'a'`,
+				exp: []tsdb.MeasurementCardinality{
+					{Measurement: "cpu", Series: 2, TagKeys: []string{"host", "region"}, TagKeySeries: []int64{2, 1}},
+				},
+			},
+		} {
+			var cond influxql.Expr
+			if tt.cond != "" {
+				cond = influxql.MustParseExpr(tt.cond)
+			}
+
+			var got []tsdb.MeasurementCardinality
+			if err := s.SeriesCardinalityByMeasurement(query.OpenAuthorizer, "db0", cond, func(c tsdb.MeasurementCardinality) error {
+				got = append(got, c)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(got, tt.exp) {
+				t.Fatalf("%q: unexpected cardinality: exp %v, got %v", tt.cond, tt.exp, got)
+			}
+		}
+	}
+
+	for _, index := range tsdb.RegisteredIndexes() {
+		t.Run(index, func(t *testing.T) { test(index) })
+	}
+}
+
 func testStoreCardinalityTombstoning(t *testing.T, store *Store) {
 	// Generate point data to write to the shards.
 	series := genTestSeries(10, 2, 4) // 160 series
@@ -1498,6 +1718,66 @@ func TestStore_TagValues_Auth(t *testing.T) {
 	}
 }
 
+func TestStore_TagKeysValues_TimeRange(t *testing.T) {
+	t.Parallel()
+
+	test := func(index string) error {
+		s := MustOpenStore(index)
+		defer s.Close()
+
+		s.MustCreateShardWithData("db0", "rp0", 0,
+			`cpu,host=serverA value=1 0`,
+			`cpu,host=serverB value=2 10`,
+		)
+		s.MustCreateShardWithData("db0", "rp0", 1,
+			`cpu,host=serverB,region=us value=3 20`,
+			`cpu,host=serverC value=4 30`,
+		)
+
+		cond, err := influxql.ParseExpr(`_tagKey = 'host' AND time >= '1970-01-01T00:00:10Z' AND time < '1970-01-01T00:00:25Z'`)
+		if err != nil {
+			return err
+		}
+		values, err := s.TagValues(nil, []uint64{0, 1}, cond)
+		if err != nil {
+			return err
+		} else if got, exp := values, []tsdb.TagValues{createTagValues("cpu", map[string][]string{"host": {"serverB"}})}; !reflect.DeepEqual(got, exp) {
+			return fmt.Errorf("got tag values %#v, expected %#v", got, exp)
+		}
+
+		for _, tt := range []struct {
+			cond string
+			exp  []string
+		}{
+			{cond: `time < '1970-01-01T00:00:15Z'`, exp: []string{"host"}},
+			{cond: `time >= '1970-01-01T00:00:15Z'`, exp: []string{"host", "region"}},
+			{cond: `region = 'us' AND time > '1970-01-01T00:00:20Z'`, exp: []string{}},
+		} {
+			cond, err := influxql.ParseExpr(tt.cond)
+			if err != nil {
+				return err
+			}
+			keys, err := s.TagKeys(nil, []uint64{0, 1}, cond)
+			if err != nil {
+				return err
+			} else if len(keys) != 1 {
+				return fmt.Errorf("%s: got %d measurements, expected 1", tt.cond, len(keys))
+			} else if got := keys[0].Keys; !reflect.DeepEqual(got, tt.exp) {
+				return fmt.Errorf("%s: got tag keys %v, expected %v", tt.cond, got, tt.exp)
+			}
+		}
+		return nil
+	}
+
+	for _, index := range tsdb.RegisteredIndexes() {
+		t.Run(index, func(t *testing.T) {
+			if err := test(index); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
 // Helper to create some tag values
 func createTagValues(mname string, kvs map[string][]string) tsdb.TagValues {
 	var sz int